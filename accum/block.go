@@ -5,11 +5,13 @@ import (
 	"errors"
 	"io"
 	"sync"
+	"unsafe"
 
 	"github.com/filecoin-project/go-leb128"
 	"github.com/ipfs/go-cid"
 	"github.com/rpcpool/yellowstone-faithful/carreader"
 	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/trackedpool"
 )
 
 type ObjectAccumulator struct {
@@ -19,7 +21,7 @@ type ObjectAccumulator struct {
 	ignoreKinds iplddecoders.KindSlice
 	callback    func(*ObjectWithMetadata, []ObjectWithMetadata) error
 	flushWg     sync.WaitGroup
-	flushQueue  chan *flushBuffer
+	flushQueue  chan flushRequest
 }
 
 var ErrStop = errors.New("stop")
@@ -39,7 +41,7 @@ func NewObjectAccumulator(
 		ignoreKinds: ignoreKinds,
 		flushOnKind: flushOnKind,
 		callback:    callback,
-		flushQueue:  make(chan *flushBuffer, 1000),
+		flushQueue:  make(chan flushRequest, 1000),
 	}
 }
 
@@ -48,26 +50,50 @@ func (oa *ObjectAccumulator) SetSkip(n uint64) {
 	oa.skipNodes = n
 }
 
-var flushBufferPool = sync.Pool{
-	New: func() interface{} {
+var flushBufferPool = trackedpool.New(
+	"accum.flushBuffer",
+	func() *flushBuffer {
 		return &flushBuffer{}
 	},
-}
+	func(fb *flushBuffer) uintptr {
+		return uintptr(unsafe.Pointer(fb))
+	},
+	func(fb *flushBuffer) *flushBuffer {
+		fb.Reset()
+		return fb
+	},
+)
 
-func getFlushBuffer() *flushBuffer {
-	return flushBufferPool.Get().(*flushBuffer)
+// getFlushBuffer returns a buffer from the pool, and the generation it was acquired at,
+// which the holder keeps to check the buffer with.
+func getFlushBuffer() (*flushBuffer, uint64) {
+	fb := flushBufferPool.Get()
+	return fb, fb.guard.Acquire()
 }
 
 func putFlushBuffer(fb *flushBuffer) {
-	fb.Reset()
+	fb.guard.Release(flushBufferPool.Name())
 	flushBufferPool.Put(fb)
 }
 
 type flushBuffer struct {
+	guard    trackedpool.Guard
 	parent   *ObjectWithMetadata
 	children []ObjectWithMetadata
 }
 
+// check panics if the buffer was returned to the pool since it was acquired at the generation
+// (when the pool guards are enabled).
+func (fb *flushBuffer) check(generation uint64) {
+	fb.guard.Check(flushBufferPool.Name(), generation)
+}
+
+// flushRequest is a buffer queued for the flusher, with the generation it was acquired at.
+type flushRequest struct {
+	fb         *flushBuffer
+	generation uint64
+}
+
 // Reset resets the flushBuffer.
 func (fb *flushBuffer) Reset() {
 	fb.parent = nil
@@ -86,10 +112,12 @@ func (oa *ObjectAccumulator) startFlusher(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case fb := <-oa.flushQueue:
+		case req := <-oa.flushQueue:
+			fb := req.fb
 			if fb == nil {
 				return
 			}
+			fb.check(req.generation)
 			if err := oa.flush(fb.parent, fb.children); err != nil {
 				if isStop(err) {
 					return
@@ -104,10 +132,10 @@ func (oa *ObjectAccumulator) startFlusher(ctx context.Context) {
 
 func (oa *ObjectAccumulator) sendToFlusher(head *ObjectWithMetadata, other []ObjectWithMetadata) {
 	oa.flushWg.Add(1)
-	fb := getFlushBuffer()
+	fb, generation := getFlushBuffer()
 	fb.parent = head
 	fb.children = other
-	oa.flushQueue <- fb
+	oa.flushQueue <- flushRequest{fb: fb, generation: generation}
 }
 
 func (oa *ObjectAccumulator) Run(ctx context.Context) error {
@@ -0,0 +1,38 @@
+package accum
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/trackedpool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushBufferCheck(t *testing.T) {
+	defer trackedpool.SetGuard(trackedpool.IsGuard())
+	trackedpool.SetGuard(true)
+
+	// the pool may drop a buffer instead of handing it out again, so retry until it's recycled.
+	for i := 0; i < 100; i++ {
+		fb, generation := getFlushBuffer()
+		fb.check(generation)
+		putFlushBuffer(fb)
+		require.Panics(t, func() { fb.check(generation) }, "use after Put")
+
+		recycled, newGeneration := getFlushBuffer()
+		if recycled != fb {
+			putFlushBuffer(recycled)
+			continue
+		}
+		// the new holder can use it, the old one can't.
+		require.NotPanics(t, func() { recycled.check(newGeneration) })
+		require.Equal(t, generation+1, newGeneration)
+		require.PanicsWithValue(t,
+			fmt.Sprintf("trackedpool: accum.flushBuffer: use after Put: acquired at generation %d, now at generation %d", generation, newGeneration),
+			func() { fb.check(generation) },
+		)
+		putFlushBuffer(recycled)
+		return
+	}
+	t.Fatal("the pool never recycled the buffer")
+}
@@ -3,7 +3,8 @@ package accum
 import (
 	"context"
 	"fmt"
-	"sync"
+	"reflect"
+	"unsafe"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/ipfs/go-cid"
@@ -11,6 +12,7 @@ import (
 	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
 	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
 	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/rpcpool/yellowstone-faithful/trackedpool"
 )
 
 type TransactionWithSlot struct {
@@ -103,26 +105,40 @@ func newTxMetaErrorParseError(sig solana.Signature, err error) txMetaError {
 	}
 }
 
-var poolOfTransactionWithSlotSlices = sync.Pool{
-	New: func() interface{} {
+var poolOfTransactionWithSlotSlices = trackedpool.New(
+	"accum.TransactionWithSlotSlice",
+	func() []*TransactionWithSlot {
 		return make([]*TransactionWithSlot, 0, 1000)
 	},
-}
+	func(slice []*TransactionWithSlot) uintptr {
+		return uintptr(unsafe.Pointer(unsafe.SliceData(slice)))
+	},
+	func(slice []*TransactionWithSlot) []*TransactionWithSlot {
+		return slice[:0]
+	},
+)
 
 func getTransactionWithSlotSlice() []*TransactionWithSlot {
-	return poolOfTransactionWithSlotSlices.Get().([]*TransactionWithSlot)
+	return poolOfTransactionWithSlotSlices.Get()
 }
 
 func PutTransactionWithSlotSlice(slice []*TransactionWithSlot) {
-	slice = slice[:0]
 	poolOfTransactionWithSlotSlices.Put(slice)
 }
 
-var poolDataBlocksMap = sync.Pool{
-	New: func() interface{} {
+var poolDataBlocksMap = trackedpool.New(
+	"accum.DataBlocksMap",
+	func() map[string]ObjectWithMetadata {
 		return make(map[string]ObjectWithMetadata, 0)
 	},
-}
+	func(m map[string]ObjectWithMetadata) uintptr {
+		return reflect.ValueOf(m).Pointer()
+	},
+	func(m map[string]ObjectWithMetadata) map[string]ObjectWithMetadata {
+		clearDataBlocksMap(m)
+		return m
+	},
+)
 
 func clearDataBlocksMap(m map[string]ObjectWithMetadata) {
 	for k := range m {
@@ -131,11 +147,10 @@ func clearDataBlocksMap(m map[string]ObjectWithMetadata) {
 }
 
 func getDatablocksMap() map[string]ObjectWithMetadata {
-	return poolDataBlocksMap.Get().(map[string]ObjectWithMetadata)
+	return poolDataBlocksMap.Get()
 }
 
 func putDataBlocksMap(m map[string]ObjectWithMetadata) {
-	clearDataBlocksMap(m)
 	poolDataBlocksMap.Put(m)
 }
 
@@ -143,9 +158,17 @@ func ObjectsToTransactionsAndMetadata(
 	block *ipldbindcode.Block,
 	objects []ObjectWithMetadata,
 ) ([]*TransactionWithSlot, error) {
-	transactions := getTransactionWithSlotSlice()
+	pooled := getTransactionWithSlotSlice()
+	transactions := pooled
 	dataBlocksMap := getDatablocksMap()
 	defer putDataBlocksMap(dataBlocksMap)
+	ok := false
+	defer func() {
+		if !ok {
+			// Don't leak the pooled slice on error.
+			PutTransactionWithSlotSlice(pooled)
+		}
+	}()
 	for objI := range objects {
 		object := objects[objI]
 		// check if the object is a transaction:
@@ -204,7 +227,7 @@ func ObjectsToTransactionsAndMetadata(
 			clearDataBlocksMap(dataBlocksMap)
 		} else {
 			// metadata didn't fit into the transaction object, and was split into multiple dataframes:
-			metaBuffer, err := tooling.LoadDataFromDataFrames(
+			metaChunks, err := tooling.LoadChunksFromDataFrames(
 				&decodedTxObj.Metadata,
 				func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error) {
 					if dataBlock, ok := dataBlocksMap[wantedCid.String()]; ok {
@@ -223,8 +246,8 @@ func ObjectsToTransactionsAndMetadata(
 			clearDataBlocksMap(dataBlocksMap)
 
 			// if we have a metadata buffer, try to decompress it:
-			if len(metaBuffer) > 0 {
-				uncompressedMeta, err := tooling.DecompressZstd(metaBuffer)
+			if metaChunks.Len() > 0 {
+				uncompressedMeta, err := tooling.DecompressZstdChunks(metaChunks)
 				if err != nil {
 					return nil, fmt.Errorf("failed to decompress metadata: %w", err)
 				}
@@ -241,5 +264,8 @@ func ObjectsToTransactionsAndMetadata(
 
 		transactions = append(transactions, tws)
 	}
+	// append might have reallocated the slice.
+	poolOfTransactionWithSlotSlices.Replace(pooled, transactions)
+	ok = true
 	return transactions, nil
 }
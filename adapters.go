@@ -11,6 +11,10 @@ func ptrToUint64(v uint64) *uint64 {
 	return &v
 }
 
+func ptrToBool(v bool) *bool {
+	return &v
+}
+
 // byteSliceAsIntegerSlice converts a byte slice to an integer slice.
 func byteSliceAsIntegerSlice(b []byte) []uint64 {
 	var ret []uint64
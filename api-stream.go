@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gagliardetto/solana-go"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"k8s.io/klog/v2"
+)
+
+// streamBlocksHandler handles GET /api/v1/stream/blocks?start=&end=[&accountInclude=...][&progressIntervalMs=]:
+// it replays the blocks of the slot range as Server-Sent Events, using the same machinery as the StreamBlocks gRPC method.
+//
+// Each block is sent as a "block" event with the slot as event ID, so that a reconnecting client
+// (that sends the Last-Event-ID header) resumes after the last block it received. Progress frames
+// are sent as "progress" events, and the stream ends with an "end" event (or an "error" event).
+func (multi *MultiEpoch) streamBlocksHandler(reqCtx *fasthttp.RequestCtx) {
+	params, err := parseStreamBlocksQuery(reqCtx)
+	if err != nil {
+		replyJSON(reqCtx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	reqCtx.SetContentType("text/event-stream")
+	reqCtx.Response.Header.Set(fasthttp.HeaderCacheControl, "no-cache")
+	// Tell nginx not to buffer the events.
+	reqCtx.Response.Header.Set("X-Accel-Buffering", "no")
+	reqCtx.SetStatusCode(fasthttp.StatusOK)
+	reqCtx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request context can't be used once the handler has returned;
+		// the stream is stopped when writing to the client fails, or by the shutdown.
+		ctx, done := multi.drain.stream()
+		defer done()
+		ser := &sseBlocksServer{ctx: ctx, w: w}
+		// The writer runs after the handler returned, out of reach of the handler's recover.
+		defer func() {
+			if r := recover(); r != nil {
+				recoveredPanic("sse", "streamBlocks", r)
+				if ser.writeErr == nil {
+					ser.writeEvent("error", "", mustJSON(map[string]string{"error": errInternal}))
+				}
+			}
+		}()
+		if err := multi.StreamBlocks(params, ser); err != nil {
+			if errors.Is(context.Cause(ctx), errShuttingDown) {
+				err = errShuttingDown
+			}
+			klog.V(2).Infof("SSE block stream %d-%d stopped: %s", params.StartSlot, params.GetEndSlot(), err)
+			if ser.writeErr == nil {
+				ser.writeEvent("error", "", mustJSON(map[string]string{"error": err.Error()}))
+			}
+			return
+		}
+		ser.writeEvent("end", "", "{}")
+	})
+}
+
+// parseStreamBlocksQuery returns the StreamBlocks request of the query params.
+func parseStreamBlocksQuery(reqCtx *fasthttp.RequestCtx) (*old_faithful_grpc.StreamBlocksRequest, error) {
+	args := reqCtx.QueryArgs()
+	params := &old_faithful_grpc.StreamBlocksRequest{}
+	if lastEventID := string(reqCtx.Request.Header.Peek("Last-Event-ID")); lastEventID != "" {
+		lastSlot, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Last-Event-ID: %w", err)
+		}
+		params.StartSlot = lastSlot + 1
+	} else {
+		start, err := strconv.ParseUint(string(args.Peek("start")), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start: %w", err)
+		}
+		params.StartSlot = start
+	}
+	if args.Has("end") {
+		end, err := strconv.ParseUint(string(args.Peek("end")), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end: %w", err)
+		}
+		if end < params.StartSlot {
+			return nil, fmt.Errorf("end must be >= start")
+		}
+		params.EndSlot = &end
+	}
+	for _, account := range args.PeekMulti("accountInclude") {
+		for _, acc := range strings.Split(string(account), ",") {
+			if acc == "" {
+				continue
+			}
+			if params.Filter == nil {
+				params.Filter = &old_faithful_grpc.StreamBlocksFilter{}
+			}
+			params.Filter.AccountInclude = append(params.Filter.AccountInclude, acc)
+		}
+	}
+	if args.Has("progressIntervalMs") {
+		interval, err := strconv.ParseUint(string(args.Peek("progressIntervalMs")), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid progressIntervalMs: %w", err)
+		}
+		intervalMs := uint32(interval)
+		params.ProgressIntervalMs = &intervalMs
+	}
+	return params, nil
+}
+
+// sseBlocksServer is the OldFaithful_StreamBlocksServer that writes the blocks as Server-Sent Events.
+// StreamBlocks only uses Context and Send; the other grpc.ServerStream methods must not be called.
+type sseBlocksServer struct {
+	grpc.ServerStream
+	ctx      context.Context
+	w        *bufio.Writer
+	writeErr error
+}
+
+func (s *sseBlocksServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *sseBlocksServer) Send(block *old_faithful_grpc.BlockResponse) error {
+	if block.Progress != nil {
+		progress, err := protojson.Marshal(block.Progress)
+		if err != nil {
+			return err
+		}
+		return s.writeEvent("progress", "", string(progress))
+	}
+	return s.writeEvent("block", strconv.FormatUint(block.Slot, 10), mustJSON(newSSEBlock(block)))
+}
+
+func (s *sseBlocksServer) writeEvent(event string, id string, data string) error {
+	if s.writeErr != nil {
+		return s.writeErr
+	}
+	if id != "" {
+		fmt.Fprintf(s.w, "id: %s\n", id)
+	}
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	s.writeErr = s.w.Flush()
+	return s.writeErr
+}
+
+// sseBlock is the JSON of a block event. The transactions are in the Solana wire format,
+// and their meta and the rewards are in the format of the CAR (protobuf or bincode), all base64-encoded.
+type sseBlock struct {
+	Slot              uint64                `json:"slot"`
+	ParentSlot        uint64                `json:"parentSlot"`
+	BlockTime         int64                 `json:"blockTime"`
+	BlockHeight       uint64                `json:"blockHeight"`
+	Blockhash         string                `json:"blockhash"`
+	PreviousBlockhash string                `json:"previousBlockhash"`
+	Transactions      []sseBlockTransaction `json:"transactions"`
+	Rewards           string                `json:"rewards,omitempty"`
+	NumPartitions     *uint64               `json:"numPartitions,omitempty"`
+}
+
+type sseBlockTransaction struct {
+	Transaction string  `json:"transaction"`
+	Meta        string  `json:"meta,omitempty"`
+	Index       *uint64 `json:"index,omitempty"`
+}
+
+func newSSEBlock(block *old_faithful_grpc.BlockResponse) *sseBlock {
+	out := &sseBlock{
+		Slot:              block.Slot,
+		ParentSlot:        block.ParentSlot,
+		BlockTime:         block.BlockTime,
+		BlockHeight:       block.BlockHeight,
+		Blockhash:         solana.HashFromBytes(block.Blockhash).String(),
+		PreviousBlockhash: solana.HashFromBytes(block.PreviousBlockhash).String(),
+		Transactions:      make([]sseBlockTransaction, 0, len(block.Transactions)),
+		Rewards:           base64.StdEncoding.EncodeToString(block.Rewards),
+		NumPartitions:     block.NumPartitions,
+	}
+	for _, tx := range block.Transactions {
+		out.Transactions = append(out.Transactions, sseBlockTransaction{
+			Transaction: base64.StdEncoding.EncodeToString(tx.Transaction),
+			Meta:        base64.StdEncoding.EncodeToString(tx.Meta),
+			Index:       tx.Index,
+		})
+	}
+	return out
+}
+
+func mustJSON(v any) string {
+	buf, err := fasterJson.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(buf)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+type testSSEEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+func parseTestSSEEvents(t *testing.T, body []byte) []testSSEEvent {
+	var events []testSSEEvent
+	var current testSSEEvent
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			events = append(events, current)
+			current = testSSEEvent{}
+			continue
+		}
+		field, value, ok := strings.Cut(line, ": ")
+		require.True(t, ok, line)
+		switch field {
+		case "id":
+			current.id = value
+		case "event":
+			current.event = value
+		case "data":
+			current.data = value
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestStreamBlocksSSE(t *testing.T) {
+	config, _, slots, _ := buildTestEpoch(t)
+	epoch := openTestEpoch(t, config)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+	get := func(path string, lastEventID string) *fasthttp.Response {
+		var req fasthttp.Request
+		req.Header.SetMethod(fasthttp.MethodGet)
+		req.SetRequestURI(path)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		multi.apiHandler(&reqCtx)
+		var resp fasthttp.Response
+		reqCtx.Response.CopyTo(&resp)
+		// reads the whole stream:
+		resp.SetBody(reqCtx.Response.Body())
+		return &resp
+	}
+
+	resp := get("/api/v1/stream/blocks?start=0&end=9&progressIntervalMs=0", "")
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+	require.Equal(t, "text/event-stream", string(resp.Header.ContentType()))
+	events := parseTestSSEEvents(t, resp.Body())
+	var blockSlots []uint64
+	for _, event := range events {
+		if event.event != "block" {
+			continue
+		}
+		var block sseBlock
+		require.NoError(t, json.Unmarshal([]byte(event.data), &block))
+		require.Equal(t, strconv.FormatUint(block.Slot, 10), event.id)
+		require.NotEmpty(t, block.Blockhash)
+		blockSlots = append(blockSlots, block.Slot)
+	}
+	require.Equal(t, slots, blockSlots)
+	require.Equal(t, "progress", events[len(events)-2].event)
+	var progress map[string]any
+	require.NoError(t, json.Unmarshal([]byte(events[len(events)-2].data), &progress))
+	require.Equal(t, true, progress["done"])
+	require.Equal(t, "end", events[len(events)-1].event)
+
+	// A reconnecting client resumes after the last event it received.
+	events = parseTestSSEEvents(t, get("/api/v1/stream/blocks?start=0&end=9", "7").Body())
+	require.Len(t, events, 3)
+	require.Equal(t, "8", events[0].id)
+	require.Equal(t, "9", events[1].id)
+	require.Equal(t, "end", events[2].event)
+
+	require.Equal(t, fasthttp.StatusBadRequest, get("/api/v1/stream/blocks", "").StatusCode())
+	require.Equal(t, fasthttp.StatusBadRequest, get("/api/v1/stream/blocks?start=5&end=4", "").StatusCode())
+}
@@ -2,21 +2,49 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
 	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/sourcegraph/jsonrpc2"
 	"github.com/valyala/fasthttp"
+	"k8s.io/klog/v2"
 )
 
 func (multi *MultiEpoch) apiHandler(reqCtx *fasthttp.RequestCtx) {
+	if strings.HasPrefix(string(reqCtx.Path()), "/api/v1/warmup/") {
+		multi.warmupHandler(reqCtx)
+		return
+	}
 	if !reqCtx.IsGet() {
 		reqCtx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
 		return
 	}
+	if string(reqCtx.Path()) == "/api/v1/epochs" || string(reqCtx.Path()) == "/api/v1/epochs/" {
+		epochNumbers := multi.GetEpochNumbers()
+		sort.Slice(epochNumbers, func(i, j int) bool { return epochNumbers[i] < epochNumbers[j] })
+		replyJSON(reqCtx, fasthttp.StatusOK, epochNumbers)
+		return
+	}
+	if strings.HasPrefix(string(reqCtx.Path()), "/api/v1/block/") {
+		multi.restBlockHandler(reqCtx)
+		return
+	}
+	if strings.HasPrefix(string(reqCtx.Path()), "/api/v1/tx/") {
+		multi.restTransactionHandler(reqCtx)
+		return
+	}
+	if strings.TrimRight(string(reqCtx.Path()), "/") == "/api/v1/stream/blocks" {
+		multi.streamBlocksHandler(reqCtx)
+		return
+	}
 	// Add a CLI command that takes a config file (or a command line argument) pointing at slot-to-cid and sig-to-cid and looks up the CID for a given block or transaction.
 	// slot-to-cid API endpoint: /api/v1/slot-to-cid/{slot}
 	// sig-to-cid API endpoint: /api/v1/sig-to-cid/{sig}
@@ -37,11 +65,12 @@ func (multi *MultiEpoch) apiHandler(reqCtx *fasthttp.RequestCtx) {
 		}
 		// find the epoch that contains the requested slot
 		epochNumber := slottools.CalcEpochForSlot(slot)
-		epochHandler, err := multi.GetEpoch(epochNumber)
+		epochHandler, release, err := multi.GetEpoch(epochNumber)
 		if err != nil {
 			reqCtx.SetStatusCode(fasthttp.StatusNotFound) // TODO: this means epoch is not available, and probably should be another dedicated status code
 			return
 		}
+		defer release()
 
 		blockCid, err := epochHandler.FindCidFromSlot(context.TODO(), slot)
 		if err != nil {
@@ -75,11 +104,12 @@ func (multi *MultiEpoch) apiHandler(reqCtx *fasthttp.RequestCtx) {
 			return
 		}
 
-		epochHandler, err := multi.GetEpoch(uint64(epochNumber))
+		epochHandler, release, err := multi.GetEpoch(uint64(epochNumber))
 		if err != nil {
 			reqCtx.SetStatusCode(fasthttp.StatusNotFound) // TODO: this means epoch is not available, and probably should be another dedicated status code
 			return
 		}
+		defer release()
 
 		transactionCid, err := epochHandler.FindCidFromSignature(context.TODO(), sig)
 		if err != nil {
@@ -96,3 +126,125 @@ func (multi *MultiEpoch) apiHandler(reqCtx *fasthttp.RequestCtx) {
 	}
 	reqCtx.SetStatusCode(fasthttp.StatusNotFound)
 }
+
+// warmupHandler handles POST /api/v1/warmup/{epoch}[?full=true]: it warms up the epoch (see Epoch.Warmup),
+// and replies with the stats once it's done. Concurrent warmups of the same epoch share the same run.
+func (multi *MultiEpoch) warmupHandler(reqCtx *fasthttp.RequestCtx) {
+	if !reqCtx.IsPost() {
+		reqCtx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		return
+	}
+	epochStr := strings.TrimRight(string(reqCtx.Path())[len("/api/v1/warmup/"):], "/")
+	epochNumber, err := strconv.ParseUint(epochStr, 10, 64)
+	if err != nil {
+		replyJSON(reqCtx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid epoch"})
+		return
+	}
+	epochHandler, release, err := multi.GetEpoch(epochNumber)
+	if err != nil {
+		replyJSON(reqCtx, fasthttp.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	defer release()
+	full := string(reqCtx.QueryArgs().Peek("full")) == "true"
+	stats, err, _ := multi.warmups.Do(fmt.Sprintf("%d-%t", epochNumber, full), func() (any, error) {
+		return epochHandler.Warmup(context.Background(), full)
+	})
+	if err != nil {
+		replyJSON(reqCtx, fasthttp.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	replyJSON(reqCtx, fasthttp.StatusOK, stats)
+}
+
+// restBlockHandler handles GET /api/v1/block/{slot}: it replies with the result of getBlock, without the JSON-RPC envelope.
+// The options of getBlock can be set as query params, e.g. ?encoding=json&transactionDetails=signatures&rewards=false.
+func (multi *MultiEpoch) restBlockHandler(reqCtx *fasthttp.RequestCtx) {
+	slotStr := strings.TrimRight(string(reqCtx.Path())[len("/api/v1/block/"):], "/")
+	slot, err := strconv.ParseUint(slotStr, 10, 64)
+	if err != nil {
+		replyJSON(reqCtx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid slot"})
+		return
+	}
+	multi.handleRESTRequest(reqCtx, multi.handleGetBlock, slot)
+}
+
+// restTransactionHandler handles GET /api/v1/tx/{signature}: it replies with the result of getTransaction, without the JSON-RPC envelope.
+// The options of getTransaction can be set as query params, e.g. ?encoding=base64&maxSupportedTransactionVersion=0.
+func (multi *MultiEpoch) restTransactionHandler(reqCtx *fasthttp.RequestCtx) {
+	sigStr := strings.TrimRight(string(reqCtx.Path())[len("/api/v1/tx/"):], "/")
+	if _, err := solana.SignatureFromBase58(sigStr); err != nil {
+		replyJSON(reqCtx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid signature"})
+		return
+	}
+	multi.handleRESTRequest(reqCtx, multi.handleGetTransaction, sigStr)
+}
+
+// handleRESTRequest calls the JSON-RPC method handler with the params [arg, options], where the options are the query params
+// (numbers and booleans are converted), and replies with the bare result, or with the error and a matching HTTP status code.
+func (multi *MultiEpoch) handleRESTRequest(
+	reqCtx *fasthttp.RequestCtx,
+	method func(context.Context, *requestContext, *jsonrpc2.Request) (*jsonrpc2.Error, error),
+	arg any,
+) {
+	options := make(map[string]any)
+	reqCtx.QueryArgs().VisitAll(func(key, value []byte) {
+		options[string(key)] = queryParamValue(string(value))
+	})
+	params := []any{arg}
+	if len(options) > 0 {
+		params = append(params, options)
+	}
+	paramsRaw, err := json.Marshal(params)
+	if err != nil {
+		replyJSON(reqCtx, fasthttp.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	rawMessage := json.RawMessage(paramsRaw)
+	conn := &requestContext{ctx: reqCtx, rest: true}
+	errorResp, err := method(reqCtx, conn, &jsonrpc2.Request{Params: &rawMessage})
+	if err != nil {
+		klog.V(2).Infof("REST request %s failed: %s", reqCtx.Path(), err)
+	}
+	if errorResp != nil {
+		if errors.Is(err, ErrNotFound) {
+			replyJSON(reqCtx, fasthttp.StatusNotFound, map[string]any{"error": errorResp})
+			return
+		}
+		conn.ReplyWithError(reqCtx, jsonrpc2.ID{}, errorResp)
+	}
+}
+
+// queryParamValue converts a query param value to the JSON type expected by the JSON-RPC options.
+func queryParamValue(value string) any {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return n
+	}
+	return value
+}
+
+// restStatusCodeForError returns the HTTP status code of the REST replies for the JSON-RPC error.
+func restStatusCodeForError(respErr *jsonrpc2.Error) int {
+	if respErr == nil {
+		return fasthttp.StatusInternalServerError
+	}
+	switch respErr.Code {
+	case jsonrpc2.CodeInvalidParams, jsonrpc2.CodeInvalidRequest:
+		return fasthttp.StatusBadRequest
+	case rpcerrors.CodeSlotSkipped,
+		rpcerrors.CodeLongTermStorageSlotSkipped,
+		rpcerrors.CodeBlockNotAvailable,
+		rpcerrors.CodeBlockCleanedUp:
+		return fasthttp.StatusNotFound
+	case rpcerrors.CodeNodeUnhealthy:
+		return fasthttp.StatusServiceUnavailable
+	default:
+		return fasthttp.StatusInternalServerError
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestRESTAPI(t *testing.T) {
+	config, _, slots, sigs := buildTestEpoch(t)
+	epoch := openTestEpoch(t, config)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+	get := func(path string) *fasthttp.Response {
+		var req fasthttp.Request
+		req.Header.SetMethod(fasthttp.MethodGet)
+		req.SetRequestURI(path)
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		multi.apiHandler(&reqCtx)
+		return &reqCtx.Response
+	}
+
+	t.Run("epochs", func(t *testing.T) {
+		resp := get("/api/v1/epochs")
+		require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+		require.JSONEq(t, `[0]`, string(resp.Body()))
+	})
+	t.Run("block", func(t *testing.T) {
+		resp := get("/api/v1/block/" + strconv.FormatUint(slots[1], 10) + "?encoding=base64&rewards=false")
+		require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), string(resp.Body()))
+		require.NotEmpty(t, resp.Header.Peek("DAG-Root-CID"))
+		var block map[string]any
+		require.NoError(t, json.Unmarshal(resp.Body(), &block))
+		require.NotContains(t, block, "jsonrpc")
+		require.Contains(t, block, "blockhash")
+		transactions, ok := block["transactions"].([]any)
+		require.True(t, ok)
+		require.NotEmpty(t, transactions)
+		require.IsType(t, []any{}, transactions[0].(map[string]any)["transaction"])
+
+		require.Equal(t, fasthttp.StatusBadRequest, get("/api/v1/block/abc").StatusCode())
+		require.Equal(t, fasthttp.StatusBadRequest, get("/api/v1/block/1?rewards=maybe").StatusCode())
+		require.Equal(t, fasthttp.StatusNotFound, get("/api/v1/block/500000").StatusCode())
+	})
+	t.Run("tx", func(t *testing.T) {
+		resp := get("/api/v1/tx/" + sigs[0].String() + "?encoding=base64&maxSupportedTransactionVersion=0")
+		require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), string(resp.Body()))
+		var tx map[string]any
+		require.NoError(t, json.Unmarshal(resp.Body(), &tx))
+		require.NotContains(t, tx, "jsonrpc")
+		require.Contains(t, tx, "slot")
+		require.IsType(t, []any{}, tx["transaction"])
+
+		require.Equal(t, fasthttp.StatusBadRequest, get("/api/v1/tx/abc").StatusCode())
+		resp = get("/api/v1/tx/" + solana.Signature{1, 2, 3}.String())
+		require.Equal(t, fasthttp.StatusNotFound, resp.StatusCode())
+		require.Contains(t, string(resp.Body()), `"error"`)
+	})
+}
@@ -0,0 +1,225 @@
+// Package attestation generates and verifies signed epoch manifests: the root CID of an epoch,
+// the SHA256 of its CAR file, its piece CIDs, and the SHA256 of its indexes, signed with an
+// operator (ed25519, solana keypair) key. Consumers can use them to verify that the epoch files
+// they downloaded are the ones published by the operator.
+package attestation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// ManifestVersion is the version of the manifest format.
+const ManifestVersion = 1
+
+// FileDigest describes the content of a file (or of a directory, see DigestPath).
+type FileDigest struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// IndexDigest is the digest of one index of an epoch.
+type IndexDigest struct {
+	Kind string `json:"kind"` // e.g. "slot_to_cid"
+	FileDigest
+}
+
+// Manifest describes the files of an epoch.
+type Manifest struct {
+	Version   int    `json:"version"`
+	Epoch     uint64 `json:"epoch"`
+	RootCid   string `json:"root_cid"`
+	CreatedAt string `json:"created_at"` // RFC3339
+	// Car is nil if the CAR file is only available as pieces.
+	Car     *FileDigest   `json:"car,omitempty"`
+	Pieces  []string      `json:"pieces,omitempty"` // piece CIDs (commP), in order
+	Indexes []IndexDigest `json:"indexes"`
+}
+
+// NewManifest returns a manifest for the given epoch and root CID, with the current time.
+func NewManifest(epoch uint64, rootCid string) *Manifest {
+	return &Manifest{
+		Version:   ManifestVersion,
+		Epoch:     epoch,
+		RootCid:   rootCid,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// Index returns the digest of the index of the given kind.
+func (m *Manifest) Index(kind string) (*IndexDigest, bool) {
+	for i := range m.Indexes {
+		if m.Indexes[i].Kind == kind {
+			return &m.Indexes[i], true
+		}
+	}
+	return nil, false
+}
+
+// signedBytes are the bytes that are signed: the JSON encoding of the manifest.
+func (m *Manifest) signedBytes() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// Attestation is a manifest signed by an operator.
+type Attestation struct {
+	Manifest  Manifest         `json:"manifest"`
+	Signer    solana.PublicKey `json:"signer"`
+	Signature solana.Signature `json:"signature"`
+}
+
+// Sign signs the manifest with the given key.
+func Sign(manifest *Manifest, key solana.PrivateKey) (*Attestation, error) {
+	payload, err := manifest.signedBytes()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := key.Sign(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign manifest: %w", err)
+	}
+	return &Attestation{
+		Manifest:  *manifest,
+		Signer:    key.PublicKey(),
+		Signature: sig,
+	}, nil
+}
+
+var (
+	ErrInvalidSignature = errors.New("invalid attestation signature")
+	ErrUntrustedSigner  = errors.New("attestation signed by an untrusted key")
+)
+
+// Verify checks the signature of the attestation, and that it was signed by one of the trusted keys.
+func (a *Attestation) Verify(trusted ...solana.PublicKey) error {
+	if len(trusted) == 0 {
+		return errors.New("at least one trusted key is required")
+	}
+	isTrusted := false
+	for _, key := range trusted {
+		if key.Equals(a.Signer) {
+			isTrusted = true
+			break
+		}
+	}
+	if !isTrusted {
+		return fmt.Errorf("%w: %s", ErrUntrustedSigner, a.Signer)
+	}
+	payload, err := a.Manifest.signedBytes()
+	if err != nil {
+		return err
+	}
+	if !a.Signature.Verify(a.Signer, payload) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ReadFile reads an attestation from a JSON file.
+func ReadFile(path string) (*Attestation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var a Attestation
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("failed to parse attestation %q: %w", path, err)
+	}
+	return &a, nil
+}
+
+// WriteFile writes the attestation to a JSON file.
+func (a *Attestation) WriteFile(path string) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// DigestPath returns the digest of a local file; for a directory (e.g. the gsfa index),
+// the digest is the SHA256 of the sorted list of "<relative path> <sha256>\n" lines of its files,
+// and the size is the sum of their sizes.
+func DigestPath(path string) (*FileDigest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		sum, size, err := sha256File(path)
+		if err != nil {
+			return nil, err
+		}
+		return &FileDigest{Name: filepath.Base(path), Size: size, Sha256: sum}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	h := sha256.New()
+	var total int64
+	for _, file := range files {
+		sum, size, err := sha256File(file)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(h, "%s %s\n", filepath.ToSlash(rel), sum)
+		total += size
+	}
+	return &FileDigest{Name: filepath.Base(path), Size: total, Sha256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// Check returns an error if the local file (or directory) at path doesn't match the digest.
+func (d *FileDigest) Check(path string) error {
+	got, err := DigestPath(path)
+	if err != nil {
+		return err
+	}
+	if got.Size != d.Size {
+		return fmt.Errorf("%s: size mismatch: expected %d, got %d", path, d.Size, got.Size)
+	}
+	if got.Sha256 != d.Sha256 {
+		return fmt.Errorf("%s: sha256 mismatch: expected %s, got %s", path, d.Sha256, got.Sha256)
+	}
+	return nil
+}
+
+func sha256File(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
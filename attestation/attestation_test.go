@@ -0,0 +1,62 @@
+package attestation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	carPath := filepath.Join(dir, "epoch-0.car")
+	require.NoError(t, os.WriteFile(carPath, []byte("car data"), 0o644))
+	gsfaPath := filepath.Join(dir, "gsfa")
+	require.NoError(t, os.MkdirAll(filepath.Join(gsfaPath, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(gsfaPath, "a"), []byte("aaa"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(gsfaPath, "sub", "b"), []byte("bb"), 0o644))
+
+	manifest := NewManifest(0, "bafyreia7j4o364whssrt36odmq3tgryjcjfyjy7ab73akykjppk2f2qnhi")
+	car, err := DigestPath(carPath)
+	require.NoError(t, err)
+	require.Equal(t, int64(8), car.Size)
+	manifest.Car = car
+	gsfa, err := DigestPath(gsfaPath)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), gsfa.Size)
+	manifest.Indexes = append(manifest.Indexes, IndexDigest{Kind: "gsfa", FileDigest: *gsfa})
+
+	key, err := solana.NewRandomPrivateKey()
+	require.NoError(t, err)
+	att, err := Sign(manifest, key)
+	require.NoError(t, err)
+
+	attPath := filepath.Join(dir, "attestation.json")
+	require.NoError(t, att.WriteFile(attPath))
+	got, err := ReadFile(attPath)
+	require.NoError(t, err)
+	require.Equal(t, att, got)
+
+	require.NoError(t, got.Verify(key.PublicKey()))
+	other, err := solana.NewRandomPrivateKey()
+	require.NoError(t, err)
+	require.ErrorIs(t, got.Verify(other.PublicKey()), ErrUntrustedSigner)
+	require.Error(t, got.Verify())
+
+	require.NoError(t, got.Manifest.Car.Check(carPath))
+	index, ok := got.Manifest.Index("gsfa")
+	require.True(t, ok)
+	require.NoError(t, index.Check(gsfaPath))
+
+	// tampering with the manifest invalidates the signature.
+	got.Manifest.Car.Sha256 = "00"
+	require.ErrorIs(t, got.Verify(key.PublicKey()), ErrInvalidSignature)
+
+	// tampering with the files is detected.
+	require.NoError(t, os.WriteFile(carPath, []byte("CAR data"), 0o644))
+	require.Error(t, att.Manifest.Car.Check(carPath))
+	require.NoError(t, os.WriteFile(filepath.Join(gsfaPath, "sub", "b"), []byte("bB"), 0o644))
+	require.Error(t, index.Check(gsfaPath))
+}
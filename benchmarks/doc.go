@@ -0,0 +1,8 @@
+// Package benchmarks has the benchmarks of the read path that don't need the RPC server: reading
+// the sections of a CAR, assembling the DAG of a block, and decoding the transaction metas. They
+// run on the fixture CARs, so they are reproducible. The end-to-end getBlock benchmarks (and the
+// encoding of its JSON response) are next to the getBlock tests in the main package.
+//
+// `make bench` runs all of them; `make bench-compare` runs them on the working tree and on
+// BENCH_BASELINE (default: main), and compares the results with benchstat.
+package benchmarks
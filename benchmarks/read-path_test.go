@@ -0,0 +1,122 @@
+package benchmarks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	metalatest "github.com/rpcpool/yellowstone-faithful/parse_legacy_transaction_status_meta/v-latest"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"google.golang.org/protobuf/proto"
+)
+
+var fixtureCar = filepath.Join("..", "fixtures", "epoch-0-1.car")
+
+// BenchmarkCarSectionRead reads all the sections (nodes) of the fixture CAR, in file order.
+func BenchmarkCarSectionRead(b *testing.B) {
+	info, err := os.Stat(fixtureCar)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(info.Size())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		file, err := os.Open(fixtureCar)
+		if err != nil {
+			b.Fatal(err)
+		}
+		rd, err := carreader.New(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for {
+			_, _, _, err := rd.NextNodeBytes()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		file.Close()
+	}
+}
+
+// BenchmarkDAGAssembly reads the DAG of each block of the fixture CAR from its position, and
+// assembles the block (entries, transactions, metas and rewards) from its nodes.
+func BenchmarkDAGAssembly(b *testing.B) {
+	source := carwriter.NewCarSource(fixtureCar)
+	defer source.Close()
+	var positions []carwriter.BlockPosition
+	err := source.Positions(context.Background(), func(pos carwriter.BlockPosition) error {
+		positions = append(positions, pos)
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := source.ReadBlock(positions[i%len(positions)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkMetaDecode decodes the same transaction meta in the protobuf and serde (bincode) encodings.
+func BenchmarkMetaDecode(b *testing.B) {
+	const numAccounts = 12
+	balances := make([]uint64, numAccounts)
+	for i := range balances {
+		balances[i] = uint64(i) * 1_000_000_007
+	}
+
+	var protobufInner []*confirmed_block.InnerInstruction
+	var serdeInner []metalatest.CompiledInstruction
+	for i := 0; i < 4; i++ {
+		protobufInner = append(protobufInner, &confirmed_block.InnerInstruction{ProgramIdIndex: uint32(i)})
+		serdeInner = append(serdeInner, metalatest.CompiledInstruction{ProgramIdIndex: uint8(i)})
+	}
+	protobuf, err := proto.Marshal(&confirmed_block.TransactionStatusMeta{
+		Fee:               5000,
+		PreBalances:       balances,
+		PostBalances:      balances,
+		InnerInstructions: []*confirmed_block.InnerInstructions{{Index: 0, Instructions: protobufInner}},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	serde, err := (&metalatest.TransactionStatusMeta{
+		Status:            &metalatest.Result__Ok{},
+		Fee:               5000,
+		PreBalances:       balances,
+		PostBalances:      balances,
+		InnerInstructions: &[]metalatest.InnerInstructions{{Index: 0, Instructions: serdeInner}},
+	}).BincodeSerialize()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bench := range []struct {
+		name string
+		data []byte
+	}{
+		{"protobuf", protobuf},
+		{"serde", serde},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bench.data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(bench.data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car/util"
+	"github.com/rpcpool/yellowstone-faithful/metrics"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+	"k8s.io/klog/v2"
+)
+
+// ErrBlockMemoryBudgetExceeded is returned when a block can't be assembled
+// because the blocks being assembled use the whole memory budget for too long.
+var ErrBlockMemoryBudgetExceeded = errors.New("block memory budget exceeded")
+
+const (
+	// blockMemoryFactor is how much memory assembling a block takes, relative to the size of its DAG in the CAR:
+	// the nodes as read, the decoded nodes, and the encoded response.
+	blockMemoryFactor = 3
+	// maxPrefetchSize caps the size of the CAR section read at once to prefetch the nodes of a block.
+	maxPrefetchSize = 10 * 1024 * 1024
+)
+
+// blockMemoryBudget is the memory budget shared by the concurrent getBlock requests (JSON-RPC, REST and gRPC).
+// Each request reserves the estimated memory of its block before assembling it, and waits (for up to maxWait)
+// while the reservations of the other requests leave no room for it.
+type blockMemoryBudget struct {
+	sem     *semaphore.Weighted
+	size    int64
+	maxWait time.Duration
+}
+
+// newBlockMemoryBudget returns a budget of size bytes; a reservation waits for up to maxWait
+// (zero means until the request is canceled). It returns nil (no budget) if size is not positive.
+func newBlockMemoryBudget(size int64, maxWait time.Duration) *blockMemoryBudget {
+	if size <= 0 {
+		return nil
+	}
+	return &blockMemoryBudget{
+		sem:     semaphore.NewWeighted(size),
+		size:    size,
+		maxWait: maxWait,
+	}
+}
+
+// reserve reserves n bytes of the budget; the returned function releases them.
+// A block larger than the whole budget reserves the whole budget, i.e. it's assembled alone.
+func (b *blockMemoryBudget) reserve(ctx context.Context, n int64) (release func(), err error) {
+	if b == nil || n <= 0 {
+		return func() {}, nil
+	}
+	if n > b.size {
+		n = b.size
+	}
+	if !b.sem.TryAcquire(n) {
+		metrics.BlockMemoryWaits.Inc()
+		waitCtx := ctx
+		if b.maxWait > 0 {
+			var cancel context.CancelFunc
+			waitCtx, cancel = context.WithTimeout(ctx, b.maxWait)
+			defer cancel()
+		}
+		if err := b.sem.Acquire(waitCtx, n); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			metrics.BlockMemoryRejections.Inc()
+			return nil, fmt.Errorf("%w: waited %s for %d bytes", ErrBlockMemoryBudgetExceeded, b.maxWait, n)
+		}
+	}
+	metrics.BlockMemoryReserved.Add(float64(n))
+	return func() {
+		metrics.BlockMemoryReserved.Sub(float64(n))
+		b.sem.Release(n)
+	}, nil
+}
+
+// blockMemoryEstimate returns the estimated memory needed to assemble the block in the section;
+// if the section is unknown (e.g. the block is fetched with lassie), the block is assumed
+// to be as large as the biggest prefetch.
+func blockMemoryEstimate(section *blockCarSection) int64 {
+	size := uint64(maxPrefetchSize)
+	if section != nil {
+		size = section.size()
+	}
+	return int64(size) * blockMemoryFactor
+}
+
+// blockCarSection is where the DAG of a block is in the CAR: the nodes of a block
+// are written after the parent block node, and before the block node.
+type blockCarSection struct {
+	blockCid  cid.Cid
+	parentCid cid.Cid
+	// parentInPreviousEpoch is true if the block is the first of the CAR,
+	// and then start is the end of the CAR header.
+	parentInPreviousEpoch bool
+	// start is the offset of the parent block node.
+	start uint64
+	// end is the offset of the block node.
+	end uint64
+}
+
+func (s *blockCarSection) size() uint64 {
+	if s.end < s.start {
+		return 0
+	}
+	return s.end - s.start
+}
+
+// findBlockCarSection returns the section of the CAR with the DAG of the block in the slot,
+// or nil if the block or its parent is replaced by the overlay (the DAG is not a section of the CAR then).
+func (ser *Epoch) findBlockCarSection(ctx context.Context, slot uint64, parentSlot uint64) (*blockCarSection, error) {
+	if ser.overlay != nil && (ser.overlay.hasSlot(slot) || ser.overlay.hasSlot(parentSlot)) {
+		return nil, nil
+	}
+	section := &blockCarSection{
+		parentInPreviousEpoch: slottools.CalcEpochForSlot(parentSlot) != slottools.CalcEpochForSlot(slot),
+	}
+	if slot == 0 {
+		section.parentInPreviousEpoch = true
+	}
+	if slot > 1 && parentSlot == 0 {
+		section.parentInPreviousEpoch = true
+	}
+
+	wg := new(errgroup.Group)
+	wg.Go(func() (err error) {
+		section.blockCid, err = ser.FindCidFromSlot(ctx, slot)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+	wg.Go(func() (err error) {
+		if section.parentInPreviousEpoch {
+			return nil
+		}
+		section.parentCid, err = ser.FindCidFromSlot(ctx, parentSlot)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+	if err := wg.Wait(); err != nil {
+		return nil, err
+	}
+
+	wg = new(errgroup.Group)
+	wg.Go(func() (err error) {
+		offsetAndSize, err := ser.FindOffsetAndSizeFromCid(ctx, section.blockCid)
+		if err != nil {
+			return err
+		}
+		section.end = offsetAndSize.Offset
+		return nil
+	})
+	wg.Go(func() (err error) {
+		if section.parentInPreviousEpoch {
+			// get car file header size
+			section.start = ser.carHeaderSize
+			return nil
+		}
+		offsetAndSize, err := ser.FindOffsetAndSizeFromCid(ctx, section.parentCid)
+		if err != nil {
+			return err
+		}
+		section.start = offsetAndSize.Offset
+		return nil
+	})
+	if err := wg.Wait(); err != nil {
+		return nil, err
+	}
+	return section, nil
+}
+
+// prefetchBlockCarSection reads the section (up to maxPrefetchSize) with a single read,
+// and caches its nodes, so that assembling the block doesn't read them one by one.
+func (ser *Epoch) prefetchBlockCarSection(ctx context.Context, section *blockCarSection) error {
+	length := section.size()
+	if length > maxPrefetchSize {
+		length = maxPrefetchSize
+	}
+	klog.V(4).Infof("prefetching CAR: start=%d length=%d", section.start, length)
+	carSection, err := ser.ReadAtFromCar(ctx, section.start, length)
+	if err != nil {
+		return err
+	}
+	br := bufio.NewReader(bytes.NewReader(carSection))
+
+	gotCid, data, err := util.ReadNode(br)
+	if err != nil {
+		return fmt.Errorf("failed to read first node: %w", err)
+	}
+	if !section.parentInPreviousEpoch && !gotCid.Equals(section.parentCid) {
+		return fmt.Errorf("CID mismatch: expected %s, got %s", section.parentCid, gotCid)
+	}
+	ser.cacheNode(gotCid, data)
+
+	for {
+		gotCid, data, err = util.ReadNode(br)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("failed to read node: %w", err)
+		}
+		if gotCid.Equals(section.blockCid) {
+			break
+		}
+		ser.cacheNode(gotCid, data)
+	}
+	return nil
+}
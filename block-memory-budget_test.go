@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBlockMemoryBudget(t *testing.T) {
+	ctx := context.Background()
+	{
+		// no budget
+		require.Nil(t, newBlockMemoryBudget(0, time.Second))
+		var budget *blockMemoryBudget
+		release, err := budget.reserve(ctx, 1<<40)
+		require.NoError(t, err)
+		release()
+	}
+	budget := newBlockMemoryBudget(100, 50*time.Millisecond)
+	first, err := budget.reserve(ctx, 60)
+	require.NoError(t, err)
+	second, err := budget.reserve(ctx, 40)
+	require.NoError(t, err)
+
+	// The budget is full: the request is rejected after waiting.
+	_, err = budget.reserve(ctx, 10)
+	require.ErrorIs(t, err, ErrBlockMemoryBudgetExceeded)
+
+	// A waiting request gets the memory once it's released.
+	done := make(chan error)
+	go func() {
+		release, err := budget.reserve(ctx, 50)
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+	first()
+	require.NoError(t, <-done)
+
+	// A canceled request returns the context error.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = budget.reserve(canceled, 80)
+	require.ErrorIs(t, err, context.Canceled)
+	second()
+
+	// A block larger than the budget takes the whole budget.
+	whole, err := budget.reserve(ctx, 1000)
+	require.NoError(t, err)
+	_, err = budget.reserve(ctx, 1)
+	require.ErrorIs(t, err, ErrBlockMemoryBudgetExceeded)
+	whole()
+}
+
+func TestGetBlockMemoryBudget(t *testing.T) {
+	config, _, slots, _ := buildTestEpoch(t)
+	epoch := openTestEpoch(t, config)
+	multi := NewMultiEpoch(&Options{
+		BlockMemoryBudget: 1 << 30,
+		BlockMemoryWait:   50 * time.Millisecond,
+	})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+	get := func(slot uint64) *fasthttp.Response {
+		var req fasthttp.Request
+		req.SetRequestURI("/api/v1/block/" + strconv.FormatUint(slot, 10) + "?encoding=base64&rewards=false")
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		multi.apiHandler(&reqCtx)
+		var resp fasthttp.Response
+		reqCtx.Response.CopyTo(&resp)
+		return &resp
+	}
+
+	section, err := epoch.findBlockCarSection(context.Background(), slots[1], slots[0])
+	require.NoError(t, err)
+	require.NotZero(t, section.size())
+	require.Equal(t, int64(section.size())*blockMemoryFactor, blockMemoryEstimate(section))
+
+	require.Equal(t, fasthttp.StatusOK, get(slots[1]).StatusCode())
+	_, err = multi.GetBlock(context.Background(), &old_faithful_grpc.BlockRequest{Slot: slots[1]})
+	require.NoError(t, err)
+
+	// While the budget is used up by other blocks, the requests are rejected.
+	release, err := multi.blockMemory.reserve(context.Background(), 1<<30)
+	require.NoError(t, err)
+	resp := get(slots[1])
+	require.Equal(t, fasthttp.StatusServiceUnavailable, resp.StatusCode(), string(resp.Body()))
+	_, err = multi.GetBlock(context.Background(), &old_faithful_grpc.BlockRequest{Slot: slots[1]})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	release()
+
+	require.Equal(t, fasthttp.StatusOK, get(slots[1]).StatusCode())
+}
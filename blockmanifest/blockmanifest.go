@@ -0,0 +1,293 @@
+// Package blockmanifest implements a compact manifest of all the blocks of an epoch:
+// for each block it stores the slot, the block CID, the CID of the parent block, and the blockhash.
+//
+// It's small enough to be loaded in memory, and it's enough to validate the continuity
+// of the chain of an epoch without reading the whole CAR file.
+package blockmanifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+)
+
+var magic = []byte("blockmanifest")
+
+const Version = uint64(1)
+
+// Entry describes one block.
+type Entry struct {
+	Slot uint64
+	Cid  cid.Cid
+	// ParentCid is cid.Undef if the parent block is not in the same epoch.
+	ParentCid  cid.Cid
+	ParentSlot uint64
+	Blockhash  solana.Hash
+}
+
+// Manifest is the list of all the blocks of an epoch, sorted by slot.
+type Manifest struct {
+	epoch   uint64
+	entries []Entry
+}
+
+// New creates an empty manifest for the given epoch.
+func New(epoch uint64) *Manifest {
+	return &Manifest{
+		epoch:   epoch,
+		entries: make([]Entry, 0),
+	}
+}
+
+// Epoch returns the epoch of the manifest.
+func (m *Manifest) Epoch() uint64 {
+	return m.epoch
+}
+
+// Add adds a block to the manifest; blocks can be added in any order.
+func (m *Manifest) Add(entry Entry) {
+	m.entries = append(m.entries, entry)
+}
+
+// Len returns the number of blocks in the manifest.
+func (m *Manifest) Len() int {
+	return len(m.entries)
+}
+
+// Entries returns the blocks, sorted by slot.
+func (m *Manifest) Entries() []Entry {
+	m.sort()
+	return m.entries
+}
+
+func (m *Manifest) sort() {
+	if sort.SliceIsSorted(m.entries, func(i, j int) bool {
+		return m.entries[i].Slot < m.entries[j].Slot
+	}) {
+		return
+	}
+	sort.Slice(m.entries, func(i, j int) bool {
+		return m.entries[i].Slot < m.entries[j].Slot
+	})
+}
+
+// Get returns the block for the given slot.
+func (m *Manifest) Get(slot uint64) (Entry, bool) {
+	m.sort()
+	i := sort.Search(len(m.entries), func(i int) bool {
+		return m.entries[i].Slot >= slot
+	})
+	if i < len(m.entries) && m.entries[i].Slot == slot {
+		return m.entries[i], true
+	}
+	return Entry{}, false
+}
+
+// Slots returns the slots of the blocks between start and end (inclusive), in ascending order.
+// If limit is positive, at most limit slots are returned.
+func (m *Manifest) Slots(start, end uint64, limit int) []uint64 {
+	m.sort()
+	i := sort.Search(len(m.entries), func(i int) bool {
+		return m.entries[i].Slot >= start
+	})
+	var slots []uint64
+	for ; i < len(m.entries) && m.entries[i].Slot <= end; i++ {
+		if limit > 0 && len(slots) >= limit {
+			break
+		}
+		slots = append(slots, m.entries[i].Slot)
+	}
+	return slots
+}
+
+// ErrDiscontinuity is returned when a block's parent is not the previous block in the manifest.
+type ErrDiscontinuity struct {
+	Slot           uint64
+	ParentSlot     uint64
+	PreviousSlot   uint64
+	ParentCid      cid.Cid
+	PreviousCid    cid.Cid
+	ParentMismatch bool
+}
+
+func (e *ErrDiscontinuity) Error() string {
+	if e.ParentMismatch {
+		return fmt.Sprintf("block %d: parent CID is %s but the block at slot %d is %s", e.Slot, e.ParentCid, e.PreviousSlot, e.PreviousCid)
+	}
+	return fmt.Sprintf("block %d: parent slot is %d but the previous block is at slot %d", e.Slot, e.ParentSlot, e.PreviousSlot)
+}
+
+// CheckContinuity verifies that every block (except the first) has the previous block as parent,
+// and that the parent CID matches. It returns all the discontinuities found.
+func (m *Manifest) CheckContinuity() []error {
+	m.sort()
+	var errs []error
+	for i := 1; i < len(m.entries); i++ {
+		prev, cur := m.entries[i-1], m.entries[i]
+		if cur.ParentSlot != prev.Slot {
+			errs = append(errs, &ErrDiscontinuity{
+				Slot:         cur.Slot,
+				ParentSlot:   cur.ParentSlot,
+				PreviousSlot: prev.Slot,
+			})
+			continue
+		}
+		if cur.ParentCid.Defined() && !cur.ParentCid.Equals(prev.Cid) {
+			errs = append(errs, &ErrDiscontinuity{
+				Slot:           cur.Slot,
+				ParentSlot:     cur.ParentSlot,
+				PreviousSlot:   prev.Slot,
+				ParentCid:      cur.ParentCid,
+				PreviousCid:    prev.Cid,
+				ParentMismatch: true,
+			})
+		}
+	}
+	return errs
+}
+
+var _ io.WriterTo = (*Manifest)(nil)
+
+// WriteTo writes the manifest in its binary format.
+func (m *Manifest) WriteTo(w io.Writer) (int64, error) {
+	m.sort()
+	buf := new(bytes.Buffer)
+	buf.Write(magic)
+	binary.Write(buf, binary.LittleEndian, Version)
+	binary.Write(buf, binary.LittleEndian, m.epoch)
+	binary.Write(buf, binary.LittleEndian, uint64(len(m.entries)))
+	for _, entry := range m.entries {
+		binary.Write(buf, binary.LittleEndian, entry.Slot)
+		binary.Write(buf, binary.LittleEndian, entry.ParentSlot)
+		writeCid(buf, entry.Cid)
+		writeCid(buf, entry.ParentCid)
+		buf.Write(entry.Blockhash[:])
+	}
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+func writeCid(buf *bytes.Buffer, c cid.Cid) {
+	if !c.Defined() {
+		buf.Write(binary.AppendUvarint(nil, 0))
+		return
+	}
+	b := c.Bytes()
+	buf.Write(binary.AppendUvarint(nil, uint64(len(b))))
+	buf.Write(b)
+}
+
+func readCid(r *bufio.Reader) (cid.Cid, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if size == 0 {
+		return cid.Undef, nil
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return cid.Undef, err
+	}
+	_, c, err := cid.CidFromBytes(b)
+	return c, err
+}
+
+// FromReader reads a manifest in its binary format.
+func FromReader(rd io.Reader) (*Manifest, error) {
+	r := bufio.NewReader(rd)
+	gotMagic := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, gotMagic); err != nil {
+		return nil, fmt.Errorf("failed to read magic: %w", err)
+	}
+	if !bytes.Equal(gotMagic, magic) {
+		return nil, fmt.Errorf("invalid magic: %q", gotMagic)
+	}
+	var version, epoch, count uint64
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, fmt.Errorf("failed to read version: %w", err)
+	}
+	if version != Version {
+		return nil, fmt.Errorf("unsupported version: %d", version)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &epoch); err != nil {
+		return nil, fmt.Errorf("failed to read epoch: %w", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, fmt.Errorf("failed to read count: %w", err)
+	}
+	m := &Manifest{
+		epoch:   epoch,
+		entries: make([]Entry, 0, min(count, 432_000)),
+	}
+	for i := uint64(0); i < count; i++ {
+		var entry Entry
+		if err := binary.Read(r, binary.LittleEndian, &entry.Slot); err != nil {
+			return nil, fmt.Errorf("failed to read slot of entry %d: %w", i, err)
+		}
+		if err := binary.Read(r, binary.LittleEndian, &entry.ParentSlot); err != nil {
+			return nil, fmt.Errorf("failed to read parent slot of entry %d: %w", i, err)
+		}
+		var err error
+		if entry.Cid, err = readCid(r); err != nil {
+			return nil, fmt.Errorf("failed to read CID of entry %d: %w", i, err)
+		}
+		if entry.ParentCid, err = readCid(r); err != nil {
+			return nil, fmt.Errorf("failed to read parent CID of entry %d: %w", i, err)
+		}
+		if _, err := io.ReadFull(r, entry.Blockhash[:]); err != nil {
+			return nil, fmt.Errorf("failed to read blockhash of entry %d: %w", i, err)
+		}
+		m.entries = append(m.entries, entry)
+	}
+	if _, err := r.ReadByte(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unexpected trailing data")
+	}
+	return m, nil
+}
+
+// IsManifestFile returns whether the file starts with the magic of a manifest.
+func IsManifestFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	gotMagic := make([]byte, len(magic))
+	if _, err := io.ReadFull(file, gotMagic); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	return bytes.Equal(gotMagic, magic), nil
+}
+
+// FromFile reads a manifest file.
+func FromFile(path string) (*Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return FromReader(file)
+}
+
+func FormatFilename(epoch uint64, rootCid cid.Cid, network indexes.Network) string {
+	return fmt.Sprintf(
+		"epoch-%d-%s-%s-%s",
+		epoch,
+		rootCid.String(),
+		network,
+		"block-manifest.index",
+	)
+}
@@ -0,0 +1,88 @@
+package blockmanifest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func testCid(t *testing.T, s string) cid.Cid {
+	h, err := multihash.Sum([]byte(s), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	return cid.NewCidV1(cid.DagCBOR, h)
+}
+
+func TestRoundTrip(t *testing.T) {
+	m := New(1)
+	a, b, c := testCid(t, "a"), testCid(t, "b"), testCid(t, "c")
+	// add out of order: they must be sorted by slot.
+	m.Add(Entry{Slot: 432002, ParentSlot: 432001, Cid: b, ParentCid: a, Blockhash: solana.Hash{2}})
+	m.Add(Entry{Slot: 432001, ParentSlot: 431999, Cid: a, ParentCid: cid.Undef, Blockhash: solana.Hash{1}})
+	m.Add(Entry{Slot: 432005, ParentSlot: 432002, Cid: c, ParentCid: b, Blockhash: solana.Hash{3}})
+
+	buf := new(bytes.Buffer)
+	_, err := m.WriteTo(buf)
+	require.NoError(t, err)
+
+	got, err := FromReader(buf)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), got.Epoch())
+	require.Equal(t, 3, got.Len())
+	require.Equal(t, m.Entries(), got.Entries())
+	require.False(t, got.Entries()[0].ParentCid.Defined())
+
+	entry, ok := got.Get(432002)
+	require.True(t, ok)
+	require.Equal(t, b, entry.Cid)
+	require.Equal(t, solana.Hash{2}, entry.Blockhash)
+
+	_, ok = got.Get(432003)
+	require.False(t, ok)
+
+	require.Equal(t, []uint64{432001, 432002, 432005}, got.Slots(0, 500000, 0))
+	require.Equal(t, []uint64{432002}, got.Slots(432002, 432004, 0))
+	require.Equal(t, []uint64{432001, 432002}, got.Slots(432001, 432005, 2))
+	require.Empty(t, got.Slots(432003, 432004, 0))
+
+	require.Empty(t, got.CheckContinuity())
+}
+
+func TestCheckContinuity(t *testing.T) {
+	m := New(0)
+	a, b, c := testCid(t, "a"), testCid(t, "b"), testCid(t, "c")
+	m.Add(Entry{Slot: 1, ParentSlot: 0, Cid: a})
+	// wrong parent CID:
+	m.Add(Entry{Slot: 2, ParentSlot: 1, Cid: b, ParentCid: c})
+	// parent is a slot that is not the previous block (i.e. a block is missing):
+	m.Add(Entry{Slot: 5, ParentSlot: 4, Cid: c, ParentCid: testCid(t, "d")})
+
+	errs := m.CheckContinuity()
+	require.Len(t, errs, 2)
+	var disc *ErrDiscontinuity
+	require.True(t, errors.As(errs[0], &disc))
+	require.True(t, disc.ParentMismatch)
+	require.Equal(t, uint64(2), disc.Slot)
+	require.True(t, errors.As(errs[1], &disc))
+	require.False(t, disc.ParentMismatch)
+	require.Equal(t, uint64(5), disc.Slot)
+	require.Equal(t, uint64(4), disc.ParentSlot)
+	require.Equal(t, uint64(2), disc.PreviousSlot)
+}
+
+func TestInvalid(t *testing.T) {
+	_, err := FromReader(bytes.NewReader([]byte("nope")))
+	require.Error(t, err)
+
+	m := New(0)
+	buf := new(bytes.Buffer)
+	_, err = m.WriteTo(buf)
+	require.NoError(t, err)
+	buf.WriteByte(0)
+	_, err = FromReader(buf)
+	require.Error(t, err)
+}
@@ -39,12 +39,15 @@ func NewIndexer(start, end, capacity uint64) *Index {
 	}
 }
 
-const DefaultCapacityForEpoch = 432_000
+// DefaultCapacityForEpoch returns the number of slots of the epoch, according to the default epoch schedule.
+func DefaultCapacityForEpoch(epoch uint64) uint64 {
+	return slottools.DefaultEpochSchedule().SlotsInEpoch(epoch)
+}
 
-// NewForEpoch creates a new Index for the given epoch.
+// NewForEpoch creates a new Index for the given epoch (according to the default epoch schedule).
 func NewForEpoch(epoch uint64) *Index {
 	start, end := slottools.CalcEpochLimits(epoch)
-	return NewIndexer(start, end, DefaultCapacityForEpoch)
+	return NewIndexer(start, end, DefaultCapacityForEpoch(epoch))
 }
 
 // Set sets the blocktime for the given slot.
@@ -66,7 +69,7 @@ func (i *Index) Get(slot uint64) (int64, error) {
 
 func (i *Index) marshalBinary() ([]byte, error) {
 	writer := bytes.NewBuffer(nil)
-	writer.Grow(DefaultIndexByteSize)
+	writer.Grow(indexByteSize(i.capacity))
 	_, err := writer.Write(magic)
 	if err != nil {
 		return nil, fmt.Errorf("failed to write magic: %w", err)
@@ -252,7 +255,14 @@ func FormatFilename(epoch uint64, rootCid cid.Cid, network indexes.Network) stri
 	)
 }
 
-var DefaultIndexByteSize = len(magic) + 8 + 8 + 8 + 8 + (432000 * 4)
+// DefaultIndexByteSize returns the size of the index of the epoch, according to the default epoch schedule.
+func DefaultIndexByteSize(epoch uint64) int {
+	return indexByteSize(DefaultCapacityForEpoch(epoch))
+}
+
+func indexByteSize(capacity uint64) int {
+	return len(magic) + 8 + 8 + 8 + 8 + int(capacity)*4
+}
 
 func (i *Index) Epoch() uint64 {
 	return i.epoch
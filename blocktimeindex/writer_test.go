@@ -4,7 +4,9 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/rpcpool/yellowstone-faithful/slottools"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestWriter(t *testing.T) {
@@ -100,7 +102,7 @@ func TestWriter(t *testing.T) {
 			}
 			// the expected length is:
 			// - len(magic) + len(start) + len(end) + len(capacity) + len(epoch) + (len(values) * 4)
-			expectedLen := DefaultIndexByteSize
+			expectedLen := DefaultIndexByteSize(1)
 			if len(buf) != expectedLen {
 				t.Errorf("expected %v, got %v", expectedLen, len(buf))
 			}
@@ -119,3 +121,27 @@ func TestWriter(t *testing.T) {
 		}
 	}
 }
+
+func TestWriterEpochSchedule(t *testing.T) {
+	defer slottools.SetDefaultEpochSchedule(slottools.DefaultEpochSchedule())
+	slottools.SetDefaultEpochSchedule(slottools.DevnetEpochSchedule)
+
+	// the warmup epochs are shorter, the normal ones are full length.
+	for epoch, slots := range map[uint64]uint64{0: 32, 1: 64, 13: 262_144, 14: 432_000} {
+		require.Equal(t, slots, DefaultCapacityForEpoch(epoch), "epoch %d", epoch)
+		w := NewForEpoch(epoch)
+		require.Equal(t, slots, w.capacity)
+		start, end := slottools.CalcEpochLimits(epoch)
+		require.NoError(t, w.Set(end, 123))
+		require.Error(t, w.Set(end+1, 123))
+		buf, err := w.MarshalBinary()
+		require.NoError(t, err)
+		require.Len(t, buf, DefaultIndexByteSize(epoch))
+		got, err := FromBytes(buf)
+		require.NoError(t, err)
+		require.Equal(t, start, got.start)
+		blocktime, err := got.Get(end)
+		require.NoError(t, err)
+		require.Equal(t, int64(123), blocktime)
+	}
+}
@@ -191,6 +191,25 @@ func TestBucketteer(t *testing.T) {
 			got, ok := reader.Meta().Get(indexmeta.MetadataKey_Epoch)
 			require.True(t, ok)
 			require.Equal(t, []byte("test"), got)
+
+			numHashes, err := reader.NumHashes()
+			require.NoError(t, err)
+			require.Equal(t, uint64(5), numHashes)
+			var hashes []uint64
+			require.NoError(t, reader.ForEachHash(func(hash uint64) error {
+				hashes = append(hashes, hash)
+				return nil
+			}))
+			require.Len(t, hashes, 5)
+			require.Contains(t, hashes, Hash(firstSig))
+
+			warmed, err := reader.Warmup(true)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(contentBuf)), warmed)
+			// the buckets are small enough to be read whole:
+			warmed, err = reader.Warmup(false)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(contentBuf)), warmed)
 		}
 	}
 }
@@ -202,3 +221,47 @@ func getFizeSize(path string) (int64, error) {
 	}
 	return info.Size(), nil
 }
+
+func TestBucketteerSparse(t *testing.T) {
+	// a few signatures fill only a few buckets: the others are never allocated,
+	// and are sealed as empty.
+	path := filepath.Join(t.TempDir(), "test-bucketteer-sparse")
+	wr, err := NewWriter(path)
+	require.NoError(t, err)
+	var sigs [][64]byte
+	for i := 0; i < 100; i++ {
+		sig := [64]byte{byte(i % 3), 7, byte(i)}
+		sigs = append(sigs, sig)
+		wr.Put(sig)
+	}
+	allocated := 0
+	for _, bucket := range wr.prefixToHashes {
+		if bucket != nil {
+			allocated++
+		}
+	}
+	require.Equal(t, 3, allocated)
+
+	meta := indexmeta.Meta{}
+	meta.Add([]byte("epoch"), []byte("test"))
+	_, err = wr.Seal(meta)
+	require.NoError(t, err)
+	require.NoError(t, wr.Close())
+
+	reader, err := Open(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	for _, sig := range sigs {
+		ok, err := reader.Has(sig)
+		require.NoError(t, err)
+		require.True(t, ok, "%x", sig[:3])
+	}
+	// a signature of a filled bucket, and signatures of empty buckets.
+	for _, sig := range [][64]byte{{0, 7, 200}, {3, 7, 0}, {0xff, 0xff}} {
+		ok, err := reader.Has(sig)
+		require.NoError(t, err)
+		require.False(t, ok, "%x", sig[:3])
+	}
+	_, err = reader.Warmup(true)
+	require.NoError(t, err)
+}
@@ -228,6 +228,93 @@ func (r *Reader) Has(sig [64]byte) (bool, error) {
 	return got == wantedHash, nil
 }
 
+// warmupBucketPrefix is how much of the start of each bucket Warmup reads: the count and the
+// top levels of the Eytzinger layout, which every search in the bucket goes through.
+const warmupBucketPrefix = 4 + 8*63
+
+// Warmup reads the start of every bucket so that the first lookups don't pay for the page faults.
+// That's one small read per bucket, meant for local (memory-mapped) files. If full is true, the
+// whole content is read sequentially instead. It returns the number of bytes of the index read.
+func (r *Reader) Warmup(full bool) (int64, error) {
+	var total int64
+	if full {
+		buf := make([]byte, 1<<20)
+		for off := int64(0); ; {
+			n, err := r.contentReader.ReadAt(buf, off)
+			total += int64(n)
+			off += int64(n)
+			if errors.Is(err, io.EOF) {
+				return total, nil
+			}
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+	buf := make([]byte, warmupBucketPrefix)
+	for _, offset := range r.prefixToOffset {
+		if offset == math.MaxUint64 {
+			continue
+		}
+		n, err := r.contentReader.ReadAt(buf, int64(offset))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return total, err
+		}
+		if n < 4 {
+			continue
+		}
+		// don't count the bytes of the next buckets:
+		bucketSize := 4 + int64(binary.LittleEndian.Uint32(buf[:4]))*8
+		total += min(int64(n), bucketSize)
+	}
+	return total, nil
+}
+
+// NumHashes returns the number of hashes (signatures) in the index; that's one small read per bucket.
+func (r *Reader) NumHashes() (uint64, error) {
+	var total uint64
+	buf := make([]byte, 4)
+	for _, offset := range r.prefixToOffset {
+		if offset == math.MaxUint64 {
+			continue
+		}
+		if _, err := r.contentReader.ReadAt(buf, int64(offset)); err != nil {
+			return total, err
+		}
+		total += uint64(binary.LittleEndian.Uint32(buf))
+	}
+	return total, nil
+}
+
+// ForEachHash calls fn with every hash of the index (see Hash), bucket by bucket.
+// It stops at the first error returned by fn.
+func (r *Reader) ForEachHash(fn func(hash uint64) error) error {
+	countBuf := make([]byte, 4)
+	var buf []byte
+	for _, offset := range r.prefixToOffset {
+		if offset == math.MaxUint64 {
+			continue
+		}
+		if _, err := r.contentReader.ReadAt(countBuf, int64(offset)); err != nil {
+			return err
+		}
+		numHashes := int(binary.LittleEndian.Uint32(countBuf))
+		if cap(buf) < numHashes*8 {
+			buf = make([]byte, numHashes*8)
+		}
+		buf = buf[:numHashes*8]
+		if n, err := r.contentReader.ReadAt(buf, int64(offset)+4); n < len(buf) {
+			return fmt.Errorf("failed to read the bucket at %d: %w", offset, err)
+		}
+		for i := 0; i < numHashes; i++ {
+			if err := fn(binary.LittleEndian.Uint64(buf[i*8:])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func searchEytzinger(min int, max int, x uint64, getter func(int) (uint64, error)) (uint64, error) {
 	var index int
 	for index < max {
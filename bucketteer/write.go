@@ -23,12 +23,15 @@ type Writer struct {
 
 type prefixToHashes [math.MaxUint16 + 1][]uint64 // prefix -> hashes
 
+// bucketCapacity is the initial capacity of a bucket: about the number of signatures
+// per prefix in a mainnet epoch.
+const bucketCapacity = 16_000
+
 func newPrefixToHashes() *prefixToHashes {
-	var out prefixToHashes
-	for i := range out {
-		out[i] = make([]uint64, 0, 16_000)
-	}
-	return &out
+	// The buckets are allocated on first use: allocating all of them upfront takes 8 GiB,
+	// whatever the number of signatures, and the index of an epoch with few transactions
+	// leaves most buckets empty.
+	return &prefixToHashes{}
 }
 
 const (
@@ -60,6 +63,9 @@ func (b *Writer) Put(sig [64]byte) {
 	var prefix [2]byte
 	copy(prefix[:], sig[:2])
 	pU16 := prefixToUint16(prefix)
+	if b.prefixToHashes[pU16] == nil {
+		b.prefixToHashes[pU16] = make([]uint64, 0, bucketCapacity)
+	}
 	b.prefixToHashes[pU16] = append(b.prefixToHashes[pU16], Hash(sig))
 }
 
@@ -0,0 +1,179 @@
+// Package canonicalize normalizes JSON-RPC responses (getBlock, getTransaction, ...) before
+// they are compared across RPC providers, so that differences that are known not to be
+// real divergences (fields that old-faithful can't know, or that different validator
+// versions encode differently) are not reported.
+//
+// The rules are versioned: a tool records the version it compared with, and a change to
+// the rules is a new version, so results obtained with different rules are not mixed up.
+package canonicalize
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Latest is the latest version of the rules.
+const Latest = 1
+
+// LogTruncatedMarker is the log message the runtime appends when the logs of a
+// transaction exceed the log limit.
+const LogTruncatedMarker = "Log truncated"
+
+// Rule rewrites the value of every object field with a given name.
+type Rule struct {
+	Name string
+	// Field is the name of the object fields the rule applies to, at any depth.
+	Field string
+	// Since is the first version of the rules that includes this rule.
+	Since int
+	// Apply returns the canonical value of the field; if keep is false, the field is removed.
+	Apply func(value any) (canonical any, keep bool)
+}
+
+var allRules = []Rule{
+	{
+		// old-faithful doesn't know the stack height of instructions, and older
+		// validators didn't record it.
+		Name:  "drop-stack-height",
+		Field: "stackHeight",
+		Since: 1,
+		Apply: func(any) (any, bool) {
+			return nil, false
+		},
+	},
+	{
+		// where the logs are cut depends on the validator that executed the transaction.
+		Name:  "cut-truncated-logs",
+		Field: "logMessages",
+		Since: 1,
+		Apply: func(value any) (any, bool) {
+			logs, ok := value.([]any)
+			if !ok {
+				return value, true
+			}
+			for i, msg := range logs {
+				if msg == LogTruncatedMarker {
+					return logs[:i], true
+				}
+			}
+			return logs, true
+		},
+	},
+	{
+		// a block without rewards is either null or an empty list.
+		Name:  "null-rewards-as-empty",
+		Field: "rewards",
+		Since: 1,
+		Apply: func(value any) (any, bool) {
+			if value == nil {
+				return []any{}, true
+			}
+			return value, true
+		},
+	},
+	{
+		// blocks from before the block height was recorded have a zero block height
+		// in old-faithful and a null one in solana RPC.
+		Name:  "zero-block-height-as-null",
+		Field: "blockHeight",
+		Since: 1,
+		Apply: func(value any) (any, bool) {
+			if n, ok := value.(json.Number); ok && n.String() == "0" {
+				return nil, true
+			}
+			return value, true
+		},
+	},
+}
+
+// Rules returns the rules of the given version.
+func Rules(version int) ([]Rule, error) {
+	if version < 1 || version > Latest {
+		return nil, fmt.Errorf("unknown canonicalization rules version %d (latest is %d)", version, Latest)
+	}
+	var rules []Rule
+	for _, rule := range allRules {
+		if rule.Since <= version {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// Value canonicalizes a decoded JSON value (numbers must be decoded as json.Number)
+// in place, and returns it.
+func Value(v any, rules []Rule) any {
+	switch vv := v.(type) {
+	case map[string]any:
+		for key, field := range vv {
+			field = Value(field, rules)
+			keep := true
+			for _, rule := range rules {
+				if rule.Field == key {
+					field, keep = rule.Apply(field)
+					if !keep {
+						break
+					}
+				}
+			}
+			if keep {
+				vv[key] = field
+			} else {
+				delete(vv, key)
+			}
+		}
+	case []any:
+		for i := range vv {
+			vv[i] = Value(vv[i], rules)
+		}
+	}
+	return v
+}
+
+// JSON returns the canonical encoding of a JSON document: the rules are applied and
+// the object keys are sorted.
+func JSON(data []byte, version int) ([]byte, error) {
+	rules, err := Rules(version)
+	if err != nil {
+		return nil, err
+	}
+	return withRules(data, rules)
+}
+
+func withRules(data []byte, rules []Rule) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(Value(v, rules))
+}
+
+// Equal returns true if the two JSON documents are the same once canonicalized.
+func Equal(a, b []byte, version int) (bool, error) {
+	ca, err := JSON(a, version)
+	if err != nil {
+		return false, fmt.Errorf("first document: %w", err)
+	}
+	cb, err := JSON(b, version)
+	if err != nil {
+		return false, fmt.Errorf("second document: %w", err)
+	}
+	return bytes.Equal(ca, cb), nil
+}
+
+// EqualExact returns true if the two JSON documents are the same, apart from the order
+// of the object keys: no rule is applied (e.g. to compare two old-faithful instances).
+func EqualExact(a, b []byte) (bool, error) {
+	ca, err := withRules(a, nil)
+	if err != nil {
+		return false, fmt.Errorf("first document: %w", err)
+	}
+	cb, err := withRules(b, nil)
+	if err != nil {
+		return false, fmt.Errorf("second document: %w", err)
+	}
+	return bytes.Equal(ca, cb), nil
+}
@@ -0,0 +1,79 @@
+package canonicalize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func canonical(t *testing.T, doc string) string {
+	t.Helper()
+	out, err := JSON([]byte(doc), Latest)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestStackHeight(t *testing.T) {
+	require.Equal(t,
+		`{"instructions":[{"programId":"p"},{"programId":"q"}]}`,
+		canonical(t, `{"instructions":[{"programId":"p","stackHeight":null},{"programId":"q","stackHeight":2}]}`),
+	)
+}
+
+func TestTruncatedLogs(t *testing.T) {
+	require.Equal(t,
+		`{"logMessages":["a","b"]}`,
+		canonical(t, `{"logMessages":["a","b","Log truncated"]}`),
+	)
+	require.Equal(t,
+		`{"logMessages":["a","b","c"]}`,
+		canonical(t, `{"logMessages":["a","b","c"]}`),
+	)
+	require.Equal(t, `{"logMessages":null}`, canonical(t, `{"logMessages":null}`))
+}
+
+func TestRewards(t *testing.T) {
+	require.Equal(t, `{"rewards":[]}`, canonical(t, `{"rewards":null}`))
+	require.Equal(t, `{"rewards":[]}`, canonical(t, `{"rewards":[]}`))
+}
+
+func TestBlockHeight(t *testing.T) {
+	require.Equal(t, `{"blockHeight":null}`, canonical(t, `{"blockHeight":0}`))
+	require.Equal(t, `{"blockHeight":12}`, canonical(t, `{"blockHeight":12}`))
+}
+
+func TestLargeNumbersAreKept(t *testing.T) {
+	require.Equal(t, `{"lamports":18446744073709551615}`, canonical(t, `{"lamports":18446744073709551615}`))
+}
+
+func TestEqual(t *testing.T) {
+	faithful := `{"blockHeight":0,"rewards":null,"transactions":[{"meta":{"logMessages":["a","Log truncated"],"innerInstructions":[{"instructions":[{"stackHeight":null}]}]}}]}`
+	solana := `{"transactions":[{"meta":{"innerInstructions":[{"instructions":[{"stackHeight":2}]}],"logMessages":["a"]}}],"rewards":[],"blockHeight":null}`
+	equal, err := Equal([]byte(faithful), []byte(solana), Latest)
+	require.NoError(t, err)
+	require.True(t, equal)
+
+	equal, err = Equal([]byte(`{"fee":5000}`), []byte(`{"fee":10000}`), Latest)
+	require.NoError(t, err)
+	require.False(t, equal)
+}
+
+func TestEqualExact(t *testing.T) {
+	equal, err := EqualExact([]byte(`{"a":1,"b":[{"c":null,"d":"x"}]}`), []byte(`{"b":[{"d":"x","c":null}],"a":1}`))
+	require.NoError(t, err)
+	require.True(t, equal)
+
+	equal, err = EqualExact([]byte(`{"rewards":null,"stackHeight":null}`), []byte(`{"rewards":[],"stackHeight":null}`))
+	require.NoError(t, err)
+	require.False(t, equal)
+}
+
+func TestRulesVersions(t *testing.T) {
+	_, err := Rules(0)
+	require.Error(t, err)
+	_, err = Rules(Latest + 1)
+	require.Error(t, err)
+	rules, err := Rules(Latest)
+	require.NoError(t, err)
+	require.Len(t, rules, len(allRules))
+}
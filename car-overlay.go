@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	pagecache "github.com/rpcpool/yellowstone-faithful/page-cache"
+)
+
+// carOverlay is a CAR with corrected blocks of an epoch (see faithful-cli car-patch --overlay);
+// its nodes take precedence over the ones of the epoch CAR.
+type carOverlay struct {
+	car   ReaderAtCloser
+	index *indexes.CidToOffsetAndSize_Reader
+	// slots and sigs are the blocks and the transactions of the overlay,
+	// which replace the ones of the epoch CAR.
+	slots map[uint64]cid.Cid
+	sigs  map[solana.Signature]cid.Cid
+}
+
+// openCarOverlay opens the overlay of the config, and checks that it's for the epoch and the CAR (with the root CID).
+// The overlay CAR is read once to find its blocks and transactions.
+func openCarOverlay(ctx context.Context, config *Config, pages *pagecache.Cache, epoch uint64, rootCid cid.Cid) (*carOverlay, error) {
+	indexFile, err := openIndexStorage(ctx, string(config.Overlay.Index.URI), pages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open overlay index file: %w", err)
+	}
+	index, err := indexes.OpenWithReader_CidToOffsetAndSize(indexFile)
+	if err != nil {
+		indexFile.Close()
+		return nil, fmt.Errorf("failed to open overlay index: %w", err)
+	}
+	if index.Meta().Epoch != epoch {
+		index.Close()
+		return nil, fmt.Errorf("epoch mismatch in overlay index: expected %d, got %d", epoch, index.Meta().Epoch)
+	}
+	if !rootCid.Equals(index.Meta().RootCid) {
+		index.Close()
+		return nil, fmt.Errorf("root CID mismatch in overlay index: expected %s, got %s", rootCid, index.Meta().RootCid)
+	}
+	carFile, err := openIndexStorage(ctx, string(config.Overlay.Car.URI), pages)
+	if err != nil {
+		index.Close()
+		return nil, fmt.Errorf("failed to open overlay CAR: %w", err)
+	}
+	overlay := &carOverlay{
+		car:   carFile,
+		index: index,
+		slots: make(map[uint64]cid.Cid),
+		sigs:  make(map[solana.Signature]cid.Cid),
+	}
+	if err := overlay.scan(rootCid); err != nil {
+		overlay.Close()
+		return nil, fmt.Errorf("failed to read overlay CAR: %w", err)
+	}
+	return overlay, nil
+}
+
+// scan reads the overlay CAR, and records its blocks and transactions.
+func (o *carOverlay) scan(rootCid cid.Cid) error {
+	rd, err := carreader.New(io.NopCloser(io.NewSectionReader(o.car, 0, math.MaxInt64)))
+	if err != nil {
+		return err
+	}
+	if len(rd.Header.Roots) != 1 || !rd.Header.Roots[0].Equals(rootCid) {
+		return fmt.Errorf("the root of the overlay CAR is not %s", rootCid)
+	}
+	for {
+		c, _, data, err := rd.NextNodeBytes()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		kind, err := iplddecoders.GetKind(data)
+		if err != nil {
+			return fmt.Errorf("failed to get the kind of node %s: %w", c, err)
+		}
+		switch kind {
+		case iplddecoders.KindBlock:
+			block, err := iplddecoders.DecodeBlock(data)
+			if err != nil {
+				return fmt.Errorf("failed to decode block %s: %w", c, err)
+			}
+			o.slots[uint64(block.Slot)] = c
+		case iplddecoders.KindTransaction:
+			tx, err := iplddecoders.DecodeTransaction(data)
+			if err != nil {
+				return fmt.Errorf("failed to decode transaction %s: %w", c, err)
+			}
+			sig, err := tx.Signature()
+			if err != nil {
+				return fmt.Errorf("failed to read the signature of transaction %s: %w", c, err)
+			}
+			o.sigs[sig] = c
+		}
+	}
+}
+
+// hasSlot returns true if the block in the slot is replaced by the overlay.
+func (o *carOverlay) hasSlot(slot uint64) bool {
+	_, ok := o.slots[slot]
+	return ok
+}
+
+// getNode returns the node with the CID from the overlay, or (nil, false) if the overlay doesn't have it.
+func (o *carOverlay) getNode(wantedCid cid.Cid) ([]byte, bool, error) {
+	oas, err := o.index.Get(wantedCid)
+	if err != nil {
+		if errors.Is(err, compactindexsized.ErrNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to look up CID %s in the overlay: %w", wantedCid, err)
+	}
+	data, err := readNodeFromReaderAtWithOffsetAndSize(o.car, &wantedCid, oas.Offset, oas.Size)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read CID %s from the overlay: %w", wantedCid, err)
+	}
+	return data, true, nil
+}
+
+func (o *carOverlay) Close() error {
+	return errors.Join(o.index.Close(), o.car.Close())
+}
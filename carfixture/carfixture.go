@@ -0,0 +1,381 @@
+// Package carfixture generates tiny but structurally complete epoch CARs for tests,
+// so that the readers, indexers and RPC handlers can be tested without real (multi-GB) epoch CARs.
+//
+// The content is synthetic: the signatures, accounts and hashes are random (but deterministic),
+// and the entry hashes are not a valid PoH chain.
+package carfixture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	metalatest "github.com/rpcpool/yellowstone-faithful/parse_legacy_transaction_status_meta/v-latest"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	solanaerrors "github.com/rpcpool/yellowstone-faithful/solana-errors"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// MetaEncoding is how the meta of a transaction is stored in the CAR.
+type MetaEncoding int
+
+const (
+	// MetaProtobuf is the protobuf meta written by current validators.
+	MetaProtobuf MetaEncoding = iota
+	// MetaSerde is the legacy bincode (serde) meta of older epochs.
+	MetaSerde
+	// MetaNone is a transaction without meta.
+	MetaNone
+)
+
+func (e MetaEncoding) String() string {
+	switch e {
+	case MetaProtobuf:
+		return "protobuf"
+	case MetaSerde:
+		return "serde"
+	case MetaNone:
+		return "none"
+	default:
+		return fmt.Sprintf("MetaEncoding(%d)", int(e))
+	}
+}
+
+// TransactionSpec describes a transaction of a generated block.
+type TransactionSpec struct {
+	Meta MetaEncoding
+	// AddressLookups makes the transaction a v0 transaction that loads one writable
+	// and one readonly account from an address lookup table.
+	AddressLookups bool
+	// Failed makes the transaction fail with a custom program error; only the protobuf meta records it.
+	Failed bool
+	// Vote makes the transaction a simple vote transaction: its only instruction is sent to the
+	// vote program instead of the system program. It can't have address lookups.
+	Vote bool
+}
+
+// BlockSpec describes a generated block.
+type BlockSpec struct {
+	Slot uint64
+	// Entries are the transactions of each entry; an entry without transactions is a tick.
+	Entries [][]TransactionSpec
+	// NumRewards is the number of rewards of the block; with LargeRewards or more,
+	// the rewards are split into several data frames.
+	NumRewards int
+	// NumRewardPartitions, if not zero, is the number of partitions of the epoch rewards stored
+	// with the rewards, as in the first block of an epoch with partitioned rewards.
+	NumRewardPartitions uint64
+}
+
+// LargeRewards is a number of rewards whose (compressed) encoding is larger than one data frame.
+const LargeRewards = 20000
+
+// Fee is the fee of every generated transaction.
+const Fee = 5000
+
+// DefaultBlocks returns blocks in slots 0-5 of epoch 0 (slot 3 is skipped) with every kind of
+// transaction meta, a v0 transaction with address lookups, a failed transaction, an empty block,
+// and rewards split into several data frames.
+func DefaultBlocks() []BlockSpec {
+	return []BlockSpec{
+		{
+			Slot: 0,
+			Entries: [][]TransactionSpec{
+				{{Meta: MetaProtobuf}, {Meta: MetaSerde}},
+				{},
+				{{Meta: MetaProtobuf, Failed: true}},
+			},
+			NumRewards: 2,
+		},
+		{
+			Slot: 1,
+			Entries: [][]TransactionSpec{
+				{{Meta: MetaNone}},
+				{{Meta: MetaProtobuf, AddressLookups: true}, {Meta: MetaSerde}},
+			},
+		},
+		{
+			Slot:    2,
+			Entries: [][]TransactionSpec{{}},
+		},
+		{
+			Slot: 4,
+			Entries: [][]TransactionSpec{
+				{{Meta: MetaProtobuf}},
+			},
+			NumRewards: LargeRewards,
+		},
+		{
+			Slot: 5,
+			Entries: [][]TransactionSpec{
+				{{Meta: MetaSerde}, {Meta: MetaNone}},
+			},
+		},
+	}
+}
+
+// Transaction is a transaction of a generated CAR.
+type Transaction struct {
+	Slot uint64
+	// Position is the index of the transaction in its block.
+	Position  int
+	Signature solana.Signature
+	Spec      TransactionSpec
+	// Accounts are the accounts of the transaction, including the ones loaded from the lookup table.
+	Accounts []solana.PublicKey
+	// Data is the wire-encoded transaction.
+	Data []byte
+}
+
+// Fixture is a generated CAR.
+type Fixture struct {
+	Path  string
+	Root  cid.Cid
+	Epoch uint64
+	// Blocks are the blocks as written to the CAR.
+	Blocks       []*carwriter.Block
+	Transactions []Transaction
+}
+
+// Slots returns the slots of the blocks.
+func (f *Fixture) Slots() []uint64 {
+	slots := make([]uint64, len(f.Blocks))
+	for i, block := range f.Blocks {
+		slots[i] = block.Slot
+	}
+	return slots
+}
+
+// Generate writes the blocks (in ascending slot order, all in the same epoch) to a CAR at path.
+// The same blocks always produce the same CAR.
+func Generate(path string, specs []BlockSpec) (*Fixture, error) {
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no blocks")
+	}
+	fixture := &Fixture{
+		Path:  path,
+		Epoch: slottools.CalcEpochForSlot(specs[0].Slot),
+	}
+	parentSlot := specs[0].Slot
+	if parentSlot > 0 {
+		parentSlot--
+	}
+	for i, spec := range specs {
+		block, transactions, err := newBlock(spec, parentSlot, uint64(i))
+		if err != nil {
+			return nil, fmt.Errorf("slot %d: %w", spec.Slot, err)
+		}
+		fixture.Blocks = append(fixture.Blocks, block)
+		fixture.Transactions = append(fixture.Transactions, transactions...)
+		parentSlot = spec.Slot
+	}
+
+	w, err := carwriter.Create(path, fixture.Epoch, carwriter.DefaultMaxSubsetBlocks)
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range fixture.Blocks {
+		if _, err := w.WriteBlock(block); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	fixture.Root, err = w.Finish()
+	if err != nil {
+		return nil, err
+	}
+	return fixture, nil
+}
+
+func newBlock(spec BlockSpec, parentSlot uint64, height uint64) (*carwriter.Block, []Transaction, error) {
+	rng := rand.New(rand.NewSource(int64(spec.Slot)))
+	block := &carwriter.Block{
+		Slot:        spec.Slot,
+		ParentSlot:  parentSlot,
+		BlockTime:   1700000000 + int64(spec.Slot),
+		BlockHeight: &height,
+	}
+	var transactions []Transaction
+	for _, entrySpec := range spec.Entries {
+		entry := carwriter.Entry{
+			NumHashes: 12500,
+			Hash:      solana.Hash(randomPubkey(rng)),
+		}
+		for _, txSpec := range entrySpec {
+			tx, err := newTransaction(rng, txSpec)
+			if err != nil {
+				return nil, nil, err
+			}
+			tx.Slot = spec.Slot
+			tx.Position = len(transactions)
+			transactions = append(transactions, *tx)
+			metadata, err := encodeMeta(tx)
+			if err != nil {
+				return nil, nil, err
+			}
+			entry.Transactions = append(entry.Transactions, carwriter.Transaction{Data: tx.Data, Metadata: metadata})
+		}
+		block.Entries = append(block.Entries, entry)
+	}
+	if spec.NumRewards > 0 || spec.NumRewardPartitions > 0 {
+		rewards, err := encodeRewards(rng, spec.NumRewards, spec.NumRewardPartitions)
+		if err != nil {
+			return nil, nil, err
+		}
+		block.Rewards = rewards
+	}
+	return block, transactions, nil
+}
+
+func randomPubkey(rng *rand.Rand) solana.PublicKey {
+	var pubkey solana.PublicKey
+	rng.Read(pubkey[:])
+	return pubkey
+}
+
+// newTransaction returns a system transfer between two random accounts (or, for a vote, the same
+// instruction sent to the vote program).
+func newTransaction(rng *rand.Rand, spec TransactionSpec) (*Transaction, error) {
+	if spec.Vote && spec.AddressLookups {
+		return nil, fmt.Errorf("a vote transaction can't have address lookups")
+	}
+	programID := solana.SystemProgramID
+	if spec.Vote {
+		programID = solana.VoteProgramID
+	}
+	payer, recipient := randomPubkey(rng), randomPubkey(rng)
+	transfer := make([]byte, 12)
+	binary.LittleEndian.PutUint32(transfer, 2)
+	binary.LittleEndian.PutUint64(transfer[4:], uint64(rng.Intn(1_000_000_000)))
+
+	message := solana.Message{
+		Header: solana.MessageHeader{
+			NumRequiredSignatures:       1,
+			NumReadonlyUnsignedAccounts: 1,
+		},
+		AccountKeys:     solana.PublicKeySlice{payer, recipient, programID},
+		RecentBlockhash: solana.Hash(randomPubkey(rng)),
+	}
+	instruction := solana.CompiledInstruction{ProgramIDIndex: 2, Accounts: []uint16{0, 1}, Data: transfer}
+	accounts := []solana.PublicKey{payer, recipient, programID}
+	if spec.AddressLookups {
+		// The loaded accounts come after the static ones: writable first, then readonly.
+		message.SetAddressTableLookups([]solana.MessageAddressTableLookup{{
+			AccountKey:      randomPubkey(rng),
+			WritableIndexes: []uint8{0},
+			ReadonlyIndexes: []uint8{1},
+		}})
+		instruction.Accounts = append(instruction.Accounts, 3, 4)
+		accounts = append(accounts, randomPubkey(rng), randomPubkey(rng))
+	}
+	message.Instructions = []solana.CompiledInstruction{instruction}
+
+	tx := &solana.Transaction{Message: message}
+	var signature solana.Signature
+	rng.Read(signature[:])
+	tx.Signatures = []solana.Signature{signature}
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transaction: %w", err)
+	}
+	return &Transaction{
+		Signature: signature,
+		Spec:      spec,
+		Accounts:  accounts,
+		Data:      data,
+	}, nil
+}
+
+// encodeMeta returns the meta of the transaction as stored in the CAR (zstd-compressed).
+func encodeMeta(tx *Transaction) ([]byte, error) {
+	preBalances := make([]uint64, len(tx.Accounts))
+	postBalances := make([]uint64, len(tx.Accounts))
+	for i := range tx.Accounts {
+		preBalances[i] = 1_000_000_000
+		postBalances[i] = 1_000_000_000
+	}
+	postBalances[0] -= Fee
+
+	var raw []byte
+	switch tx.Spec.Meta {
+	case MetaNone:
+		return nil, nil
+	case MetaProtobuf:
+		meta := &confirmed_block.TransactionStatusMeta{
+			Fee:          Fee,
+			PreBalances:  preBalances,
+			PostBalances: postBalances,
+			LogMessages: []string{
+				fmt.Sprintf("Program %s invoke [1]", tx.Accounts[2]),
+				fmt.Sprintf("Program %s success", tx.Accounts[2]),
+			},
+		}
+		if tx.Spec.AddressLookups {
+			meta.LoadedWritableAddresses = [][]byte{tx.Accounts[3].Bytes()}
+			meta.LoadedReadonlyAddresses = [][]byte{tx.Accounts[4].Bytes()}
+		}
+		if tx.Spec.Failed {
+			txErr, err := solanaerrors.FromJSONToProtobuf(map[string]any{
+				solanaerrors.InstructionError: []any{0.0, map[string]any{"Custom": 1.0}},
+			})
+			if err != nil {
+				return nil, err
+			}
+			meta.Err = &confirmed_block.TransactionError{Err: txErr}
+		}
+		var err error
+		raw, err = proto.Marshal(meta)
+		if err != nil {
+			return nil, err
+		}
+	case MetaSerde:
+		meta := metalatest.TransactionStatusMeta{
+			Status:       &metalatest.Result__Ok{},
+			Fee:          Fee,
+			PreBalances:  preBalances,
+			PostBalances: postBalances,
+		}
+		var err error
+		raw, err = meta.BincodeSerialize()
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown meta encoding %d", tx.Spec.Meta)
+	}
+	return tooling.CompressZstd(raw)
+}
+
+// encodeRewards returns random voting rewards as stored in the CAR (zstd-compressed protobuf),
+// followed by the number of partitions if it is not zero.
+func encodeRewards(rng *rand.Rand, numRewards int, numPartitions uint64) ([]byte, error) {
+	rewards := &confirmed_block.Rewards{Rewards: make([]*confirmed_block.Reward, numRewards)}
+	for i := range rewards.Rewards {
+		rewards.Rewards[i] = &confirmed_block.Reward{
+			Pubkey:      randomPubkey(rng).String(),
+			Lamports:    rng.Int63n(1_000_000),
+			PostBalance: rng.Uint64(),
+			RewardType:  confirmed_block.RewardType_Voting,
+			Commission:  "10",
+		}
+	}
+	raw, err := proto.Marshal(rewards)
+	if err != nil {
+		return nil, err
+	}
+	if numPartitions > 0 {
+		// NumPartitions num_partitions = 2, which the vendored proto doesn't have.
+		var msg []byte
+		msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, numPartitions)
+		raw = protowire.AppendTag(raw, 2, protowire.BytesType)
+		raw = protowire.AppendBytes(raw, msg)
+	}
+	return tooling.CompressZstd(raw)
+}
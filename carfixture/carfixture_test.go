@@ -0,0 +1,122 @@
+package carfixture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	solanablockrewards "github.com/rpcpool/yellowstone-faithful/solana-block-rewards"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/stretchr/testify/require"
+)
+
+func countDataFrames(t *testing.T, path string) int {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	rd, err := carreader.New(file)
+	require.NoError(t, err)
+	count := 0
+	for {
+		_, _, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			return count
+		}
+		require.NoError(t, err)
+		if iplddecoders.Kind(data[1]) == iplddecoders.KindDataFrame {
+			count++
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "epoch-0.car")
+	fixture, err := Generate(path, DefaultBlocks())
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 1, 2, 4, 5}, fixture.Slots())
+	require.Len(t, fixture.Transactions, 9)
+
+	stats, err := carwriter.Validate(path)
+	require.NoError(t, err)
+	require.Equal(t, fixture.Root, stats.Root)
+	require.Equal(t, uint64(5), stats.Blocks)
+	require.Equal(t, uint64(9), stats.Transactions)
+	// The large rewards don't fit in the rewards node: the rest is in separate data frame nodes.
+	require.NotZero(t, countDataFrames(t, path))
+
+	// The CAR reads back as generated.
+	var read []*carwriter.Block
+	require.NoError(t, carwriter.NewCarSource(path).Blocks(context.Background(), 0, 10, func(block *carwriter.Block) error {
+		read = append(read, block)
+		return nil
+	}))
+	require.Len(t, read, len(fixture.Blocks))
+	byTx := 0
+	for i, block := range read {
+		want := fixture.Blocks[i]
+		require.Equal(t, want.Slot, block.Slot)
+		require.Equal(t, want.ParentSlot, block.ParentSlot)
+		require.Equal(t, want.Rewards, block.Rewards)
+		if block.Rewards != nil {
+			raw, err := tooling.DecompressZstd(block.Rewards)
+			require.NoError(t, err)
+			_, err = solanablockrewards.ParseRewards(raw)
+			require.NoError(t, err)
+		}
+		for _, entry := range block.Entries {
+			for _, tx := range entry.Transactions {
+				generated := fixture.Transactions[byTx]
+				byTx++
+				require.Equal(t, generated.Data, tx.Data)
+
+				var decoded solana.Transaction
+				require.NoError(t, bin.UnmarshalBin(&decoded, tx.Data))
+				require.Equal(t, generated.Signature, decoded.Signatures[0])
+				require.Equal(t, generated.Spec.AddressLookups, decoded.Message.IsVersioned())
+
+				if generated.Spec.Meta == MetaNone {
+					require.Empty(t, tx.Metadata)
+					continue
+				}
+				raw, err := tooling.DecompressZstd(tx.Metadata)
+				require.NoError(t, err)
+				meta, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(raw)
+				require.NoError(t, err)
+				require.Equal(t, uint64(Fee), meta.Fee())
+				require.Equal(t, generated.Spec.Failed, meta.Failed())
+				switch generated.Spec.Meta {
+				case MetaProtobuf:
+					require.True(t, meta.IsProtobuf())
+					if generated.Spec.AddressLookups {
+						require.Len(t, meta.GetProtobuf().LoadedWritableAddresses, 1)
+						require.Len(t, meta.GetProtobuf().LoadedReadonlyAddresses, 1)
+					}
+				case MetaSerde:
+					require.True(t, meta.IsSerdeLatest())
+				}
+			}
+		}
+	}
+	require.Equal(t, len(fixture.Transactions), byTx)
+
+	// Generating is deterministic.
+	again := filepath.Join(dir, "again.car")
+	_, err = Generate(again, DefaultBlocks())
+	require.NoError(t, err)
+	first, err := os.ReadFile(path)
+	require.NoError(t, err)
+	second, err := os.ReadFile(again)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(first, second))
+}
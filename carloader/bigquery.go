@@ -0,0 +1,254 @@
+package carloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rpcpool/yellowstone-faithful/gcpauth"
+)
+
+// BigQueryScope is the OAuth2 scope of the BigQuery API.
+const BigQueryScope = "https://www.googleapis.com/auth/bigquery"
+
+const (
+	defaultBigQueryEndpoint = "https://bigquery.googleapis.com/bigquery/v2"
+	// maxInsertAllRows is the number of rows per insertAll request (the API recommends at most 500).
+	maxInsertAllRows = 500
+)
+
+// BigQuery loads the tables into a BigQuery dataset over its REST API, with streaming inserts.
+// The rows have their key as insertId, so that the rows inserted again after a resumed load
+// are deduplicated (on a best-effort basis, by BigQuery).
+type BigQuery struct {
+	Project string
+	Dataset string
+	Tokens  *gcpauth.Cache
+	// Endpoint defaults to https://bigquery.googleapis.com/bigquery/v2.
+	Endpoint string
+	Client   *http.Client
+}
+
+var _ Sink = (*BigQuery)(nil)
+
+type bigQueryField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode,omitempty"`
+}
+
+type bigQueryTable struct {
+	TableReference struct {
+		ProjectID string `json:"projectId"`
+		DatasetID string `json:"datasetId"`
+		TableID   string `json:"tableId"`
+	} `json:"tableReference"`
+	Schema struct {
+		Fields []bigQueryField `json:"fields"`
+	} `json:"schema"`
+	Clustering *struct {
+		Fields []string `json:"fields"`
+	} `json:"clustering,omitempty"`
+}
+
+// errBigQueryNotFound is returned by do on a 404.
+var errBigQueryNotFound = errors.New("not found")
+
+func bigQueryFieldOf(column Column) bigQueryField {
+	// all the fields are NULLABLE (or REPEATED): REQUIRED fields can't be added to an existing table.
+	field := bigQueryField{Name: column.Name, Mode: "NULLABLE"}
+	switch column.Type {
+	case UInt64, Int64:
+		field.Type = "INT64"
+	case String:
+		field.Type = "STRING"
+	case Bool:
+		field.Type = "BOOL"
+	case StringArray:
+		field.Type, field.Mode = "STRING", "REPEATED"
+	default:
+		panic(fmt.Sprintf("unknown column type %d", column.Type))
+	}
+	return field
+}
+
+func (b *BigQuery) CreateTables(ctx context.Context, tables []Table) error {
+	datasetPath := fmt.Sprintf("/projects/%s/datasets/%s", url.PathEscape(b.Project), url.PathEscape(b.Dataset))
+	err := b.do(ctx, http.MethodGet, datasetPath, nil, nil)
+	if errors.Is(err, errBigQueryNotFound) {
+		dataset := map[string]any{
+			"datasetReference": map[string]string{"projectId": b.Project, "datasetId": b.Dataset},
+		}
+		err = b.do(ctx, http.MethodPost, fmt.Sprintf("/projects/%s/datasets", url.PathEscape(b.Project)), dataset, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("dataset %s: %w", b.Dataset, err)
+	}
+	for _, table := range tables {
+		if err := b.createTable(ctx, datasetPath, table); err != nil {
+			return fmt.Errorf("table %s: %w", table.Name, err)
+		}
+	}
+	return nil
+}
+
+func (b *BigQuery) createTable(ctx context.Context, datasetPath string, table Table) error {
+	tablePath := datasetPath + "/tables/" + url.PathEscape(table.Name)
+	var existing bigQueryTable
+	err := b.do(ctx, http.MethodGet, tablePath, nil, &existing)
+	if errors.Is(err, errBigQueryNotFound) {
+		var created bigQueryTable
+		created.TableReference.ProjectID = b.Project
+		created.TableReference.DatasetID = b.Dataset
+		created.TableReference.TableID = table.Name
+		for _, column := range table.Columns {
+			created.Schema.Fields = append(created.Schema.Fields, bigQueryFieldOf(column))
+		}
+		created.Clustering = &struct {
+			Fields []string `json:"fields"`
+		}{Fields: table.Key}
+		return b.do(ctx, http.MethodPost, datasetPath+"/tables", created, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the tables created by an older version get the fields added since; a patch replaces
+	// the whole schema, so it has the existing fields too.
+	have := make(map[string]bool)
+	for _, field := range existing.Schema.Fields {
+		have[field.Name] = true
+	}
+	fields := existing.Schema.Fields
+	for _, column := range table.Columns {
+		if !have[column.Name] {
+			fields = append(fields, bigQueryFieldOf(column))
+		}
+	}
+	if len(fields) == len(existing.Schema.Fields) {
+		return nil
+	}
+	patch := map[string]any{"schema": map[string]any{"fields": fields}}
+	return b.do(ctx, http.MethodPatch, tablePath, patch, nil)
+}
+
+func (b *BigQuery) Insert(ctx context.Context, table Table, rows []Row) error {
+	path := fmt.Sprintf(
+		"/projects/%s/datasets/%s/tables/%s/insertAll",
+		url.PathEscape(b.Project), url.PathEscape(b.Dataset), url.PathEscape(table.Name),
+	)
+	for len(rows) > 0 {
+		n := min(len(rows), maxInsertAllRows)
+		type insertRow struct {
+			InsertID string         `json:"insertId"`
+			JSON     map[string]any `json:"json"`
+		}
+		request := struct {
+			Rows []insertRow `json:"rows"`
+		}{Rows: make([]insertRow, n)}
+		for i, row := range rows[:n] {
+			request.Rows[i] = insertRow{InsertID: table.Name + ":" + table.rowID(row), JSON: bigQueryRow(row)}
+		}
+		var response struct {
+			InsertErrors []struct {
+				Index  int `json:"index"`
+				Errors []struct {
+					Reason  string `json:"reason"`
+					Message string `json:"message"`
+				} `json:"errors"`
+			} `json:"insertErrors"`
+		}
+		if err := b.do(ctx, http.MethodPost, path, request, &response); err != nil {
+			return fmt.Errorf("failed to insert into %s: %w", table.Name, err)
+		}
+		if len(response.InsertErrors) > 0 {
+			first := response.InsertErrors[0]
+			msg := "unknown error"
+			if len(first.Errors) > 0 {
+				msg = first.Errors[0].Reason + ": " + first.Errors[0].Message
+			}
+			return fmt.Errorf("failed to insert %d rows into %s (row %d: %s)", len(response.InsertErrors), table.Name, first.Index, msg)
+		}
+		rows = rows[n:]
+	}
+	return nil
+}
+
+// bigQueryRow converts the integers of a row to strings: the JSON numbers of BigQuery are
+// doubles, which can't hold all the 64-bit integers.
+func bigQueryRow(row Row) map[string]any {
+	out := make(map[string]any, len(row))
+	for name, value := range row {
+		switch v := value.(type) {
+		case uint64:
+			out[name] = strconv.FormatUint(v, 10)
+		case *uint64:
+			if v != nil {
+				out[name] = strconv.FormatUint(*v, 10)
+			}
+		case int64:
+			out[name] = strconv.FormatInt(v, 10)
+		case nil:
+		default:
+			out[name] = v
+		}
+	}
+	return out
+}
+
+// do sends a request to the API, with request (if not nil) as the JSON body, and decodes
+// the JSON response into response (if not nil).
+func (b *BigQuery) do(ctx context.Context, method string, path string, request any, response any) error {
+	var body io.Reader
+	if request != nil {
+		buf, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+	}
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = defaultBigQueryEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(endpoint, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	token, err := b.Tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errBigQueryNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("BigQuery returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if response == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
@@ -0,0 +1,164 @@
+package carloader
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/gcpauth"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBigQuery serves the parts of the BigQuery API used by the sink.
+type fakeBigQuery struct {
+	mu       sync.Mutex
+	datasets map[string]bool
+	tables   map[string]*bigQueryTable
+	patches  int
+	inserted map[string][]map[string]any
+	// insertIDs are the insertIds received, by table.
+	insertIDs map[string][]string
+}
+
+func newFakeBigQuery() *fakeBigQuery {
+	return &fakeBigQuery{
+		datasets:  make(map[string]bool),
+		tables:    make(map[string]*bigQueryTable),
+		inserted:  make(map[string][]map[string]any),
+		insertIDs: make(map[string][]string),
+	}
+}
+
+func (f *fakeBigQuery) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	decode := func(r *http.Request, v any) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(v))
+	}
+	mux.HandleFunc("/projects/p/datasets/", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		// /projects/p/datasets/<dataset>[/tables[/<table>[/insertAll]]]
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/projects/p/datasets/"), "/")
+		dataset := parts[0]
+		var table, insertAll string
+		if len(parts) > 2 {
+			table = parts[2]
+		}
+		if len(parts) > 3 {
+			insertAll = parts[3]
+		}
+		switch {
+		case table == "" && r.Method == http.MethodGet:
+			if !f.datasets[dataset] {
+				http.NotFound(w, r)
+				return
+			}
+		case r.URL.Path == "/projects/p/datasets/d/tables" && r.Method == http.MethodPost:
+			var created bigQueryTable
+			decode(r, &created)
+			f.tables[created.TableReference.TableID] = &created
+		case insertAll != "":
+			var request struct {
+				Rows []struct {
+					InsertID string         `json:"insertId"`
+					JSON     map[string]any `json:"json"`
+				} `json:"rows"`
+			}
+			decode(r, &request)
+			if table == "broken" {
+				json.NewEncoder(w).Encode(map[string]any{"insertErrors": []any{
+					map[string]any{"index": 0, "errors": []any{map[string]any{"reason": "invalid", "message": "no such field"}}},
+				}})
+				return
+			}
+			require.LessOrEqual(t, len(request.Rows), maxInsertAllRows)
+			for _, row := range request.Rows {
+				f.inserted[table] = append(f.inserted[table], row.JSON)
+				f.insertIDs[table] = append(f.insertIDs[table], row.InsertID)
+			}
+		case r.Method == http.MethodGet:
+			existing, ok := f.tables[table]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(existing)
+			return
+		case r.Method == http.MethodPatch:
+			var patch bigQueryTable
+			decode(r, &patch)
+			f.tables[table].Schema = patch.Schema
+			f.patches++
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte("{}"))
+	})
+	mux.HandleFunc("/projects/p/datasets", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		var created struct {
+			DatasetReference struct {
+				DatasetID string `json:"datasetId"`
+			} `json:"datasetReference"`
+		}
+		decode(r, &created)
+		f.mu.Lock()
+		f.datasets[created.DatasetReference.DatasetID] = true
+		f.mu.Unlock()
+		w.Write([]byte("{}"))
+	})
+	return mux
+}
+
+func newTestBigQuery(t *testing.T, fake *fakeBigQuery) *BigQuery {
+	server := httptest.NewServer(fake.handler(t))
+	t.Cleanup(server.Close)
+	tokens, err := gcpauth.New("tok", "", BigQueryScope)
+	require.NoError(t, err)
+	return &BigQuery{Project: "p", Dataset: "d", Tokens: gcpauth.NewCache(tokens), Endpoint: server.URL}
+}
+
+func TestBigQuery(t *testing.T) {
+	fake := newFakeBigQuery()
+	sink := newTestBigQuery(t, fake)
+	ctx := context.Background()
+
+	// an older version of the blocks table, without its last column.
+	old := BlocksTable
+	old.Columns = old.Columns[:len(old.Columns)-1]
+	require.NoError(t, sink.CreateTables(ctx, []Table{old}))
+	require.True(t, fake.datasets["d"])
+	require.Len(t, fake.tables["blocks"].Schema.Fields, len(BlocksTable.Columns)-1)
+	require.Equal(t, []string{"slot"}, fake.tables["blocks"].Clustering.Fields)
+
+	require.NoError(t, sink.CreateTables(ctx, Tables))
+	require.Equal(t, 1, fake.patches)
+	require.Equal(t, bigQueryField{Name: "num_transactions", Type: "INT64", Mode: "NULLABLE"}, fake.tables["blocks"].Schema.Fields[len(BlocksTable.Columns)-1])
+	require.Len(t, fake.tables, len(Tables))
+	require.Contains(t, fake.tables["instructions"].Schema.Fields, bigQueryField{Name: "accounts", Type: "STRING", Mode: "REPEATED"})
+
+	// the schemas are up to date.
+	require.NoError(t, sink.CreateTables(ctx, Tables))
+	require.Equal(t, 1, fake.patches)
+
+	// the rows are inserted in chunks, with their key as insertId and the integers as strings.
+	height := uint64(7)
+	var rows []Row
+	for slot := uint64(0); slot < maxInsertAllRows+10; slot++ {
+		rows = append(rows, Row{"slot": slot, "block_time": int64(-1), "block_height": &height, "blockhash": "h"})
+	}
+	rows[1]["block_height"] = (*uint64)(nil)
+	require.NoError(t, sink.Insert(ctx, BlocksTable, rows))
+	require.Len(t, fake.inserted["blocks"], len(rows))
+	require.Equal(t, map[string]any{"slot": "0", "block_time": "-1", "block_height": "7", "blockhash": "h"}, fake.inserted["blocks"][0])
+	require.NotContains(t, fake.inserted["blocks"][1], "block_height")
+	require.Equal(t, "blocks:509", fake.insertIDs["blocks"][509])
+
+	err := sink.Insert(ctx, Table{Name: "broken"}, []Row{{"slot": uint64(1)}})
+	require.ErrorContains(t, err, "invalid: no such field")
+}
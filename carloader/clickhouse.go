@@ -0,0 +1,139 @@
+package carloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Sink is a database the tables are loaded into.
+type Sink interface {
+	// CreateTables creates the tables that don't exist, and adds the missing columns to the ones that do.
+	CreateTables(ctx context.Context, tables []Table) error
+	// Insert inserts rows into a table.
+	Insert(ctx context.Context, table Table, rows []Row) error
+}
+
+// ClickHouse loads the tables into a ClickHouse database over its HTTP interface.
+// The tables are ReplacingMergeTrees ordered by their key, so that the rows inserted
+// again after a resumed load are deduplicated when the parts are merged (or with FINAL).
+type ClickHouse struct {
+	// URL is the address of the HTTP interface, e.g. http://localhost:8123.
+	URL      string
+	Database string
+	User     string
+	Password string
+	Client   *http.Client
+}
+
+var _ Sink = (*ClickHouse)(nil)
+
+func clickHouseType(column Column) string {
+	var typ string
+	switch column.Type {
+	case UInt64:
+		typ = "UInt64"
+	case Int64:
+		typ = "Int64"
+	case String:
+		typ = "String"
+	case Bool:
+		typ = "Bool"
+	case StringArray:
+		typ = "Array(String)"
+	default:
+		panic(fmt.Sprintf("unknown column type %d", column.Type))
+	}
+	if column.Nullable {
+		typ = "Nullable(" + typ + ")"
+	}
+	return typ
+}
+
+func (c *ClickHouse) CreateTables(ctx context.Context, tables []Table) error {
+	if err := c.exec(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", c.Database), nil); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		columns := make([]string, len(table.Columns))
+		for i, column := range table.Columns {
+			columns[i] = fmt.Sprintf("`%s` %s", column.Name, clickHouseType(column))
+		}
+		key := make([]string, len(table.Key))
+		for i, column := range table.Key {
+			key[i] = "`" + column + "`"
+		}
+		create := fmt.Sprintf(
+			"CREATE TABLE IF NOT EXISTS `%s`.`%s` (%s) ENGINE = ReplacingMergeTree ORDER BY (%s)",
+			c.Database, table.Name, strings.Join(columns, ", "), strings.Join(key, ", "),
+		)
+		if err := c.exec(ctx, create, nil); err != nil {
+			return fmt.Errorf("table %s: %w", table.Name, err)
+		}
+		// the tables created by an older version get the columns added since.
+		for _, column := range columns {
+			alter := fmt.Sprintf("ALTER TABLE `%s`.`%s` ADD COLUMN IF NOT EXISTS %s", c.Database, table.Name, column)
+			if err := c.exec(ctx, alter, nil); err != nil {
+				return fmt.Errorf("table %s: %w", table.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *ClickHouse) Insert(ctx context.Context, table Table, rows []Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return fmt.Errorf("failed to encode row of %s: %w", table.Name, err)
+		}
+	}
+	query := fmt.Sprintf("INSERT INTO `%s`.`%s` FORMAT JSONEachRow", c.Database, table.Name)
+	if err := c.exec(ctx, query, &body); err != nil {
+		return fmt.Errorf("failed to insert into %s: %w", table.Name, err)
+	}
+	return nil
+}
+
+// exec runs a query, with the data of an INSERT as the body of the request.
+func (c *ClickHouse) exec(ctx context.Context, query string, body io.Reader) error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid ClickHouse URL: %w", err)
+	}
+	params := u.Query()
+	params.Set("query", query)
+	u.RawQuery = params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), body)
+	if err != nil {
+		return err
+	}
+	if c.User != "" {
+		req.Header.Set("X-ClickHouse-User", c.User)
+		req.Header.Set("X-ClickHouse-Key", c.Password)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("ClickHouse returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
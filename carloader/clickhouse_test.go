@@ -0,0 +1,73 @@
+package carloader
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClickHouse(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries []string
+		// inserted are the rows received, by query.
+		inserted = make(map[string][]map[string]any)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "loader", r.Header.Get("X-ClickHouse-User"))
+		require.Equal(t, "secret", r.Header.Get("X-ClickHouse-Key"))
+		query := r.URL.Query().Get("query")
+		if strings.Contains(query, "broken") {
+			http.Error(w, "Code: 60. DB::Exception: Table broken doesn't exist", http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, query)
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var row map[string]any
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+			inserted[query] = append(inserted[query], row)
+		}
+		io.WriteString(w, "")
+	}))
+	defer server.Close()
+
+	sink := &ClickHouse{URL: server.URL, Database: "faithful", User: "loader", Password: "secret"}
+	ctx := context.Background()
+	require.NoError(t, sink.CreateTables(ctx, []Table{BlocksTable}))
+	require.Equal(t, "CREATE DATABASE IF NOT EXISTS `faithful`", queries[0])
+	require.Equal(t,
+		"CREATE TABLE IF NOT EXISTS `faithful`.`blocks` (`slot` UInt64, `parent_slot` UInt64, `block_time` Int64, "+
+			"`block_height` Nullable(UInt64), `blockhash` String, `num_entries` UInt64, `num_transactions` UInt64) "+
+			"ENGINE = ReplacingMergeTree ORDER BY (`slot`)",
+		queries[1],
+	)
+	require.Len(t, queries, 2+len(BlocksTable.Columns))
+	require.Equal(t, "ALTER TABLE `faithful`.`blocks` ADD COLUMN IF NOT EXISTS `block_height` Nullable(UInt64)", queries[5])
+
+	require.NoError(t, sink.Insert(ctx, InstructionsTable, []Row{
+		{"slot": uint64(1), "inner_index": int64(-1), "stack_height": nil, "accounts": []string{"a", "b"}},
+		{"slot": uint64(2), "inner_index": int64(0), "accounts": []string{}},
+	}))
+	rows := inserted["INSERT INTO `faithful`.`instructions` FORMAT JSONEachRow"]
+	require.Len(t, rows, 2)
+	require.Equal(t, map[string]any{"slot": 1.0, "inner_index": -1.0, "stack_height": nil, "accounts": []any{"a", "b"}}, rows[0])
+
+	// nothing is sent without rows.
+	n := len(queries)
+	require.NoError(t, sink.Insert(ctx, InstructionsTable, nil))
+	require.Len(t, queries, n)
+
+	err := sink.Insert(ctx, Table{Name: "broken"}, []Row{{"slot": uint64(1)}})
+	require.ErrorContains(t, err, "Table broken doesn't exist")
+}
@@ -0,0 +1,143 @@
+package carloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"k8s.io/klog/v2"
+)
+
+// Checkpoint is the progress of a load: the blocks up to LastSlot have been inserted.
+type Checkpoint struct {
+	From     uint64  `json:"from"`
+	To       uint64  `json:"to"`
+	LastSlot *uint64 `json:"last_slot,omitempty"`
+}
+
+// LoadCheckpoint reads a checkpoint file.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint %q: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// Save atomically writes the checkpoint to path.
+func (cp *Checkpoint) Save(path string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+type Options struct {
+	// BatchBlocks is the number of blocks whose rows are inserted together.
+	BatchBlocks int
+	// CheckpointFile, if set, is where the progress is saved after every batch, and
+	// where a load of the same slot range resumes from.
+	CheckpointFile string
+}
+
+type Stats struct {
+	Blocks int
+	// Rows are the numbers of rows inserted, by table name.
+	Rows map[string]int
+	// ResumedAfter is the last slot of the checkpoint the load resumed from, if any.
+	ResumedAfter *uint64
+}
+
+// Load creates the tables in the sink and inserts the rows of the blocks of the slot
+// range [from, to] of source, in batches of opts.BatchBlocks blocks. A batch is inserted
+// table by table before the checkpoint moves past it, so the rows of a batch interrupted
+// midway are inserted again when the load resumes; the sinks deduplicate them by their key.
+func Load(ctx context.Context, source carwriter.BlockSource, from, to uint64, sink Sink, opts Options) (*Stats, error) {
+	if opts.BatchBlocks <= 0 {
+		opts.BatchBlocks = 100
+	}
+	stats := &Stats{Rows: make(map[string]int)}
+	cp := &Checkpoint{From: from, To: to}
+	if opts.CheckpointFile != "" {
+		saved, err := LoadCheckpoint(opts.CheckpointFile)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, err
+		case saved.From != from || saved.To != to:
+			return nil, fmt.Errorf("the checkpoint %s is of slots %d-%d, not %d-%d", opts.CheckpointFile, saved.From, saved.To, from, to)
+		default:
+			cp = saved
+			stats.ResumedAfter = saved.LastSlot
+		}
+	}
+	if err := sink.CreateTables(ctx, Tables); err != nil {
+		return nil, fmt.Errorf("failed to create the tables: %w", err)
+	}
+
+	next := from
+	if cp.LastSlot != nil {
+		if *cp.LastSlot >= to {
+			return stats, nil
+		}
+		next = *cp.LastSlot + 1
+		klog.Infof("Resuming the load after slot %d", *cp.LastSlot)
+	}
+	var (
+		batch  = make(Rows)
+		blocks int
+	)
+	flush := func(last uint64) error {
+		for _, table := range Tables {
+			if err := sink.Insert(ctx, table, batch[table.Name]); err != nil {
+				return err
+			}
+			stats.Rows[table.Name] += len(batch[table.Name])
+		}
+		stats.Blocks += blocks
+		cp.LastSlot = &last
+		if opts.CheckpointFile != "" {
+			if err := cp.Save(opts.CheckpointFile); err != nil {
+				return fmt.Errorf("failed to save the checkpoint: %w", err)
+			}
+		}
+		klog.V(2).Infof("Loaded %d blocks, up to slot %d", blocks, last)
+		batch, blocks = make(Rows), 0
+		return nil
+	}
+	var last uint64
+	err := source.Blocks(ctx, next, to, func(block *carwriter.Block) error {
+		rows, err := BlockRows(block)
+		if err != nil {
+			return fmt.Errorf("slot %d: %w", block.Slot, err)
+		}
+		batch.append(rows)
+		blocks++
+		last = block.Slot
+		if blocks == opts.BatchBlocks {
+			return flush(last)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if blocks > 0 {
+		if err := flush(last); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
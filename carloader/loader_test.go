@@ -0,0 +1,89 @@
+package carloader
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySink keeps the rows in memory, deduplicated by key like the real sinks do.
+type memorySink struct {
+	tables map[string]bool
+	rows   map[string]map[string]Row
+	// failAfter, if not zero, makes the insert after failAfter inserts fail.
+	failAfter int
+	inserts   int
+}
+
+func newMemorySink() *memorySink {
+	return &memorySink{tables: make(map[string]bool), rows: make(map[string]map[string]Row)}
+}
+
+func (s *memorySink) CreateTables(_ context.Context, tables []Table) error {
+	for _, table := range tables {
+		s.tables[table.Name] = true
+		if s.rows[table.Name] == nil {
+			s.rows[table.Name] = make(map[string]Row)
+		}
+	}
+	return nil
+}
+
+func (s *memorySink) Insert(_ context.Context, table Table, rows []Row) error {
+	if s.failAfter != 0 && s.inserts == s.failAfter {
+		return errors.New("connection reset")
+	}
+	s.inserts++
+	for _, row := range rows {
+		s.rows[table.Name][table.rowID(row)] = row
+	}
+	return nil
+}
+
+func TestLoad(t *testing.T) {
+	fixture := generateFixture(t)
+	source := carwriter.NewCarSource(fixture.Path)
+	ctx := context.Background()
+
+	want := newMemorySink()
+	stats, err := Load(ctx, source, 0, 5, want, Options{BatchBlocks: 2})
+	require.NoError(t, err)
+	require.Equal(t, len(fixture.Blocks), stats.Blocks)
+	require.Equal(t, len(fixture.Transactions), stats.Rows[TransactionsTable.Name])
+	require.Len(t, want.rows[BlocksTable.Name], len(fixture.Blocks))
+	require.Len(t, want.rows[TransactionsTable.Name], len(fixture.Transactions))
+	require.Nil(t, stats.ResumedAfter)
+
+	// the second batch (slots 2 and 4) fails midway, after its blocks and transactions are inserted.
+	checkpoint := filepath.Join(t.TempDir(), "load.json")
+	sink := newMemorySink()
+	sink.failAfter = len(Tables) + 2
+	_, err = Load(ctx, source, 0, 5, sink, Options{BatchBlocks: 2, CheckpointFile: checkpoint})
+	require.ErrorContains(t, err, "connection reset")
+	cp, err := LoadCheckpoint(checkpoint)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), *cp.LastSlot)
+
+	// another slot range doesn't resume from the checkpoint.
+	_, err = Load(ctx, source, 0, 4, sink, Options{BatchBlocks: 2, CheckpointFile: checkpoint})
+	require.ErrorContains(t, err, "is of slots 0-5")
+
+	sink.failAfter = 0
+	stats, err = Load(ctx, source, 0, 5, sink, Options{BatchBlocks: 2, CheckpointFile: checkpoint})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), *stats.ResumedAfter)
+	require.Equal(t, 3, stats.Blocks)
+	require.Equal(t, want.rows, sink.rows)
+	cp, err = LoadCheckpoint(checkpoint)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), *cp.LastSlot)
+
+	// a completed load has nothing left to do.
+	stats, err = Load(ctx, source, 0, 5, sink, Options{BatchBlocks: 2, CheckpointFile: checkpoint})
+	require.NoError(t, err)
+	require.Zero(t, stats.Blocks)
+}
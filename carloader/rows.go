@@ -0,0 +1,234 @@
+package carloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/mr-tron/base58"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	solanaerrors "github.com/rpcpool/yellowstone-faithful/solana-errors"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+)
+
+// Rows are rows of the tables, by table name.
+type Rows map[string][]Row
+
+func (r Rows) append(other Rows) {
+	for table, rows := range other {
+		r[table] = append(r[table], rows...)
+	}
+}
+
+// BlockRows returns the rows of a block in the tables.
+func BlockRows(block *carwriter.Block) (Rows, error) {
+	if len(block.Entries) == 0 {
+		return nil, errors.New("the block has no entries")
+	}
+	rows := make(Rows)
+	blockRow := Row{
+		"slot":             block.Slot,
+		"parent_slot":      block.ParentSlot,
+		"block_time":       block.BlockTime,
+		"block_height":     block.BlockHeight,
+		"blockhash":        block.Entries[len(block.Entries)-1].Hash.String(),
+		"num_entries":      uint64(len(block.Entries)),
+		"num_transactions": uint64(0),
+	}
+	var position uint64
+	for _, entry := range block.Entries {
+		for i := range entry.Transactions {
+			txRows, err := transactionRows(block, position, &entry.Transactions[i])
+			if err != nil {
+				return nil, fmt.Errorf("transaction %d: %w", position, err)
+			}
+			rows.append(txRows)
+			position++
+		}
+	}
+	blockRow["num_transactions"] = position
+	rows[BlocksTable.Name] = []Row{blockRow}
+	return rows, nil
+}
+
+func transactionRows(block *carwriter.Block, position uint64, carTx *carwriter.Transaction) (Rows, error) {
+	tx, err := solana.TransactionFromDecoder(bin.NewBinDecoder(carTx.Data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	if len(tx.Signatures) == 0 || len(tx.Message.AccountKeys) == 0 {
+		return nil, errors.New("transaction without signatures or accounts")
+	}
+	signature := tx.Signatures[0].String()
+	version := "legacy"
+	if tx.Message.IsVersioned() {
+		version = "0"
+	}
+	txRow := Row{
+		"slot":                   block.Slot,
+		"block_time":             block.BlockTime,
+		"position":               position,
+		"signature":              signature,
+		"signer":                 tx.Message.AccountKeys[0].String(),
+		"version":                version,
+		"recent_blockhash":       tx.Message.RecentBlockhash.String(),
+		"num_instructions":       uint64(len(tx.Message.Instructions)),
+		"has_meta":               false,
+		"failed":                 false,
+		"err":                    nil,
+		"fee":                    nil,
+		"compute_units_consumed": nil,
+	}
+
+	// The legacy metas are converted to protobuf when they can be; the ones that can't still
+	// have their fee and status.
+	var meta *confirmed_block.TransactionStatusMeta
+	if len(carTx.Metadata) > 0 {
+		buf, err := tooling.DecompressZstd(carTx.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress meta: %w", err)
+		}
+		container, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode meta: %w", err)
+		}
+		txRow["has_meta"] = true
+		txRow["failed"] = container.Failed()
+		txRow["fee"] = container.Fee()
+		meta, err = container.ToProtobuf()
+		if err != nil && !errors.Is(err, solanatxmetaparsers.ErrNotUpgradable) {
+			return nil, fmt.Errorf("failed to convert meta: %w", err)
+		}
+	}
+	if meta != nil {
+		if meta.Err != nil {
+			parsed, err := solanaerrors.ParseTransactionError(meta.Err)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode error: %w", err)
+			}
+			buf, err := json.Marshal(parsed)
+			if err != nil {
+				return nil, err
+			}
+			txRow["err"] = string(buf)
+		}
+		txRow["compute_units_consumed"] = meta.ComputeUnitsConsumed
+	}
+
+	rows := Rows{TransactionsTable.Name: {txRow}}
+	instructions, err := instructionRows(block.Slot, signature, tx, meta)
+	if err != nil {
+		return nil, err
+	}
+	rows[InstructionsTable.Name] = instructions
+	if meta != nil {
+		rows[TokenBalanceChangesTable.Name] = tokenBalanceChangeRows(block.Slot, signature, tx, meta)
+	}
+	return rows, nil
+}
+
+// instructionRows returns the outer instructions of the transaction, each followed by its inner instructions.
+func instructionRows(slot uint64, signature string, tx *solana.Transaction, meta *confirmed_block.TransactionStatusMeta) ([]Row, error) {
+	instructions, err := nodetools.FlattenInstructions(tx, meta)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]Row, len(instructions))
+	for i, ix := range instructions {
+		accounts := make([]string, len(ix.Accounts))
+		for j, account := range ix.Accounts {
+			accounts[j] = account.String()
+		}
+		rows[i] = Row{
+			"slot":         slot,
+			"signature":    signature,
+			"index":        uint64(ix.OuterIndex),
+			"inner_index":  int64(ix.InnerIndex),
+			"stack_height": nil,
+			"program_id":   ix.ProgramID.String(),
+			"accounts":     accounts,
+			"data":         base58.Encode(ix.Data),
+		}
+		// the stack heights that the meta doesn't have are left null rather than inferred.
+		if !ix.IsTopLevel() && ix.StackHeightRecorded {
+			rows[i]["stack_height"] = uint64(ix.StackHeight)
+		}
+	}
+	return rows, nil
+}
+
+// tokenBalanceChangeRows returns the token accounts whose balance changed in the transaction.
+func tokenBalanceChangeRows(slot uint64, signature string, tx *solana.Transaction, meta *confirmed_block.TransactionStatusMeta) []Row {
+	type balances struct {
+		pre, post *confirmed_block.TokenBalance
+	}
+	byAccount := make(map[uint32]*balances)
+	get := func(index uint32) *balances {
+		b, ok := byAccount[index]
+		if !ok {
+			b = &balances{}
+			byAccount[index] = b
+		}
+		return b
+	}
+	for _, balance := range meta.PreTokenBalances {
+		get(balance.AccountIndex).pre = balance
+	}
+	for _, balance := range meta.PostTokenBalances {
+		get(balance.AccountIndex).post = balance
+	}
+	indexes := make([]uint32, 0, len(byAccount))
+	for index := range byAccount {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	amount := func(balance *confirmed_block.TokenBalance) string {
+		if balance == nil || balance.UiTokenAmount == nil || balance.UiTokenAmount.Amount == "" {
+			return "0"
+		}
+		return balance.UiTokenAmount.Amount
+	}
+	var rows []Row
+	for _, index := range indexes {
+		b := byAccount[index]
+		pre, post := amount(b.pre), amount(b.post)
+		if pre == post {
+			continue
+		}
+		// the mint, owner and decimals of a closed account are in its pre balance.
+		described := b.post
+		if described == nil {
+			described = b.pre
+		}
+		var account string
+		if int(index) < len(tx.Message.AccountKeys) {
+			account = tx.Message.AccountKeys[index].String()
+		} else if loaded := int(index) - len(tx.Message.AccountKeys); loaded < len(meta.LoadedWritableAddresses) {
+			account = base58.Encode(meta.LoadedWritableAddresses[loaded])
+		}
+		var decimals uint64
+		if described.UiTokenAmount != nil {
+			decimals = uint64(described.UiTokenAmount.Decimals)
+		}
+		rows = append(rows, Row{
+			"slot":          slot,
+			"signature":     signature,
+			"account_index": uint64(index),
+			"account":       account,
+			"mint":          described.Mint,
+			"owner":         described.Owner,
+			"program_id":    described.ProgramId,
+			"decimals":      decimals,
+			"pre_amount":    pre,
+			"post_amount":   post,
+		})
+	}
+	return rows
+}
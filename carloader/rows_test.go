@@ -0,0 +1,140 @@
+package carloader
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/mr-tron/base58"
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/stretchr/testify/require"
+)
+
+func generateFixture(t *testing.T) *carfixture.Fixture {
+	fixture, err := carfixture.Generate(filepath.Join(t.TempDir(), "epoch-0.car"), carfixture.DefaultBlocks())
+	require.NoError(t, err)
+	return fixture
+}
+
+func TestBlockRows(t *testing.T) {
+	fixture := generateFixture(t)
+	all := make(Rows)
+	require.NoError(t, carwriter.NewCarSource(fixture.Path).Blocks(context.Background(), 0, 5, func(block *carwriter.Block) error {
+		rows, err := BlockRows(block)
+		if err != nil {
+			return err
+		}
+		all.append(rows)
+		return nil
+	}))
+
+	require.Len(t, all[BlocksTable.Name], len(fixture.Blocks))
+	for i, block := range fixture.Blocks {
+		row := all[BlocksTable.Name][i]
+		require.Equal(t, block.Slot, row["slot"])
+		require.Equal(t, block.ParentSlot, row["parent_slot"])
+		require.Equal(t, block.Entries[len(block.Entries)-1].Hash.String(), row["blockhash"])
+	}
+
+	require.Len(t, all[TransactionsTable.Name], len(fixture.Transactions))
+	require.Len(t, all[InstructionsTable.Name], len(fixture.Transactions))
+	for i, tx := range fixture.Transactions {
+		row := all[TransactionsTable.Name][i]
+		require.Equal(t, tx.Slot, row["slot"])
+		require.Equal(t, uint64(tx.Position), row["position"])
+		require.Equal(t, tx.Signature.String(), row["signature"])
+		require.Equal(t, tx.Accounts[0].String(), row["signer"])
+		require.Equal(t, tx.Spec.Meta != carfixture.MetaNone, row["has_meta"])
+		require.Equal(t, tx.Spec.Failed, row["failed"])
+		if tx.Spec.Meta == carfixture.MetaNone {
+			require.Nil(t, row["fee"])
+		} else {
+			require.Equal(t, uint64(carfixture.Fee), row["fee"])
+		}
+		if tx.Spec.Failed {
+			require.JSONEq(t, `{"InstructionError":[0,{"Custom":1}]}`, row["err"].(string))
+		} else {
+			require.Nil(t, row["err"])
+		}
+		if tx.Spec.AddressLookups {
+			require.Equal(t, "0", row["version"])
+		} else {
+			require.Equal(t, "legacy", row["version"])
+		}
+
+		// the transfer has the accounts loaded from the lookup table as well.
+		ix := all[InstructionsTable.Name][i]
+		require.Equal(t, tx.Signature.String(), ix["signature"])
+		require.Equal(t, int64(-1), ix["inner_index"])
+		require.Equal(t, solana.SystemProgramID.String(), ix["program_id"])
+		accounts := []string{tx.Accounts[0].String(), tx.Accounts[1].String()}
+		if tx.Spec.AddressLookups {
+			accounts = append(accounts, tx.Accounts[3].String(), tx.Accounts[4].String())
+		}
+		require.Equal(t, accounts, ix["accounts"])
+	}
+	require.Empty(t, all[TokenBalanceChangesTable.Name])
+}
+
+func TestInstructionAndTokenBalanceRows(t *testing.T) {
+	keys := []solana.PublicKey{solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.TokenProgramID}
+	tx := &solana.Transaction{Message: solana.Message{
+		AccountKeys:  keys,
+		Instructions: []solana.CompiledInstruction{{ProgramIDIndex: 2, Accounts: []uint16{0, 1}, Data: []byte{3}}},
+	}}
+	loaded := solana.NewWallet().PublicKey()
+	height := uint32(2)
+	amount := func(amount string) *confirmed_block.UiTokenAmount {
+		return &confirmed_block.UiTokenAmount{Amount: amount, Decimals: 6}
+	}
+	meta := &confirmed_block.TransactionStatusMeta{
+		LoadedWritableAddresses: [][]byte{loaded.Bytes()},
+		InnerInstructions: []*confirmed_block.InnerInstructions{{
+			Index: 0,
+			Instructions: []*confirmed_block.InnerInstruction{
+				{ProgramIdIndex: 2, Accounts: []byte{3}, Data: []byte{1, 2}, StackHeight: &height},
+			},
+		}},
+		PreTokenBalances: []*confirmed_block.TokenBalance{
+			{AccountIndex: 0, Mint: "mint", Owner: "a", ProgramId: "token", UiTokenAmount: amount("100")},
+			{AccountIndex: 1, Mint: "mint", Owner: "b", ProgramId: "token", UiTokenAmount: amount("7")},
+		},
+		PostTokenBalances: []*confirmed_block.TokenBalance{
+			{AccountIndex: 0, Mint: "mint", Owner: "a", ProgramId: "token", UiTokenAmount: amount("100")},
+			{AccountIndex: 3, Mint: "mint", Owner: "c", ProgramId: "token", UiTokenAmount: amount("5")},
+		},
+	}
+
+	rows, err := instructionRows(10, "sig", tx, meta)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.Equal(t, Row{
+		"slot": uint64(10), "signature": "sig", "index": uint64(0), "inner_index": int64(-1), "stack_height": nil,
+		"program_id": keys[2].String(), "accounts": []string{keys[0].String(), keys[1].String()}, "data": base58.Encode([]byte{3}),
+	}, rows[0])
+	require.Equal(t, Row{
+		"slot": uint64(10), "signature": "sig", "index": uint64(0), "inner_index": int64(0), "stack_height": uint64(2),
+		"program_id": keys[2].String(), "accounts": []string{loaded.String()}, "data": base58.Encode([]byte{1, 2}),
+	}, rows[1])
+
+	// an account index past the loaded accounts is an error.
+	meta.InnerInstructions[0].Instructions[0].Accounts = []byte{4}
+	_, err = instructionRows(10, "sig", tx, meta)
+	require.Error(t, err)
+
+	// the unchanged balance of account 0 is left out; account 1 was closed and account 3 (loaded) created.
+	changes := tokenBalanceChangeRows(10, "sig", tx, meta)
+	require.Len(t, changes, 2)
+	require.Equal(t, keys[1].String(), changes[0]["account"])
+	require.Equal(t, "b", changes[0]["owner"])
+	require.Equal(t, "7", changes[0]["pre_amount"])
+	require.Equal(t, "0", changes[0]["post_amount"])
+	require.Equal(t, loaded.String(), changes[1]["account"])
+	require.Equal(t, uint64(3), changes[1]["account_index"])
+	require.Equal(t, "0", changes[1]["pre_amount"])
+	require.Equal(t, "5", changes[1]["post_amount"])
+	require.Equal(t, uint64(6), changes[1]["decimals"])
+}
@@ -0,0 +1,122 @@
+// Package carloader loads the blocks of epoch CARs into normalized tables (blocks,
+// transactions, instructions, token balance changes) of an analytics database:
+// ClickHouse (over its HTTP interface) or BigQuery (over its REST API).
+package carloader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ColumnType is the type of a column; each sink maps it to a type of its database.
+type ColumnType int
+
+const (
+	UInt64 ColumnType = iota
+	Int64
+	String
+	Bool
+	StringArray
+)
+
+type Column struct {
+	Name     string
+	Type     ColumnType
+	Nullable bool
+}
+
+// Table is the schema of a table.
+type Table struct {
+	Name    string
+	Columns []Column
+	// Key are the columns that identify a row. Rows loaded again (after a resumed load)
+	// have the same key as the ones loaded before, so that the sinks can deduplicate them.
+	Key []string
+}
+
+// Row is a row of a table, by column name.
+type Row map[string]any
+
+// rowID is the key of the row as a string.
+func (t Table) rowID(row Row) string {
+	parts := make([]string, len(t.Key))
+	for i, column := range t.Key {
+		parts[i] = fmt.Sprint(row[column])
+	}
+	return strings.Join(parts, ":")
+}
+
+var (
+	BlocksTable = Table{
+		Name: "blocks",
+		Columns: []Column{
+			{Name: "slot", Type: UInt64},
+			{Name: "parent_slot", Type: UInt64},
+			{Name: "block_time", Type: Int64},
+			{Name: "block_height", Type: UInt64, Nullable: true},
+			{Name: "blockhash", Type: String},
+			{Name: "num_entries", Type: UInt64},
+			{Name: "num_transactions", Type: UInt64},
+		},
+		Key: []string{"slot"},
+	}
+	TransactionsTable = Table{
+		Name: "transactions",
+		Columns: []Column{
+			{Name: "slot", Type: UInt64},
+			{Name: "block_time", Type: Int64},
+			// position is the index of the transaction in the block.
+			{Name: "position", Type: UInt64},
+			{Name: "signature", Type: String},
+			{Name: "signer", Type: String},
+			// version is "legacy" or "0".
+			{Name: "version", Type: String},
+			{Name: "recent_blockhash", Type: String},
+			{Name: "num_instructions", Type: UInt64},
+			{Name: "has_meta", Type: Bool},
+			{Name: "failed", Type: Bool},
+			// err is the error of a failed transaction, as JSON (like in the RPC responses).
+			{Name: "err", Type: String, Nullable: true},
+			{Name: "fee", Type: UInt64, Nullable: true},
+			{Name: "compute_units_consumed", Type: UInt64, Nullable: true},
+		},
+		Key: []string{"slot", "signature"},
+	}
+	InstructionsTable = Table{
+		Name: "instructions",
+		Columns: []Column{
+			{Name: "slot", Type: UInt64},
+			{Name: "signature", Type: String},
+			// index is the index of the (outer) instruction in the transaction, and
+			// inner_index the index of an inner instruction in it, or -1 for the outer instruction.
+			{Name: "index", Type: UInt64},
+			{Name: "inner_index", Type: Int64},
+			{Name: "stack_height", Type: UInt64, Nullable: true},
+			{Name: "program_id", Type: String},
+			{Name: "accounts", Type: StringArray},
+			// data is base58-encoded, like in the RPC responses.
+			{Name: "data", Type: String},
+		},
+		Key: []string{"slot", "signature", "index", "inner_index"},
+	}
+	TokenBalanceChangesTable = Table{
+		Name: "token_balance_changes",
+		Columns: []Column{
+			{Name: "slot", Type: UInt64},
+			{Name: "signature", Type: String},
+			{Name: "account_index", Type: UInt64},
+			{Name: "account", Type: String},
+			{Name: "mint", Type: String},
+			{Name: "owner", Type: String},
+			{Name: "program_id", Type: String},
+			{Name: "decimals", Type: UInt64},
+			// the amounts are in base units, as decimal strings (they don't fit in 64 bits).
+			{Name: "pre_amount", Type: String},
+			{Name: "post_amount", Type: String},
+		},
+		Key: []string{"slot", "signature", "account_index"},
+	}
+)
+
+// Tables are the tables that are loaded, in the order they are inserted into.
+var Tables = []Table{BlocksTable, TransactionsTable, InstructionsTable, TokenBalanceChangesTable}
@@ -0,0 +1,297 @@
+package bigtablesource
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/mr-tron/base58"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// Compression methods of the cells written by the solana-storage-bigtable crate:
+// the value is prefixed by the method, a bincode-encoded enum (u32 little endian).
+const (
+	compressionNone  = 0
+	compressionBzip2 = 1
+	compressionGzip  = 2
+	compressionZstd  = 3
+)
+
+func decompress(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, errors.New("cell is too short to contain the compression method")
+	}
+	method, payload := binary.LittleEndian.Uint32(data[:4]), data[4:]
+	switch method {
+	case compressionNone:
+		return payload, nil
+	case compressionBzip2:
+		return io.ReadAll(bzip2.NewReader(bytes.NewReader(payload)))
+	case compressionGzip:
+		rd, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer rd.Close()
+		return io.ReadAll(rd)
+	case compressionZstd:
+		return tooling.DecompressZstd(payload)
+	default:
+		return nil, fmt.Errorf("unknown compression method %d", method)
+	}
+}
+
+// entry is a row of the "entries" table (solana.storage.entries.Entry).
+type entry struct {
+	index                    uint32
+	numHashes                uint64
+	hash                     []byte
+	numTransactions          uint64
+	startingTransactionIndex uint32
+}
+
+// parseEntries decodes a solana.storage.entries.Entries protobuf.
+func parseEntries(data []byte) ([]entry, error) {
+	var entries []entry
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		if num != 1 || typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+		msg, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		e, err := parseEntry(msg)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func parseEntry(data []byte) (entry, error) {
+	var e entry
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return e, protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch {
+		case typ == protowire.VarintType && num != 3:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return e, protowire.ParseError(n)
+			}
+			data = data[n:]
+			switch num {
+			case 1:
+				e.index = uint32(v)
+			case 2:
+				e.numHashes = v
+			case 4:
+				e.numTransactions = v
+			case 5:
+				e.startingTransactionIndex = uint32(v)
+			}
+		case typ == protowire.BytesType && num == 3:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return e, protowire.ParseError(n)
+			}
+			data = data[n:]
+			e.hash = v
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return e, protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+	return e, nil
+}
+
+// toCarBlock converts a block of the "blocks" table and (if available) its
+// entries to the CAR representation.
+//
+// Without entries, all the transactions are put in a single entry whose hash
+// is the blockhash (which is the hash of the last entry of the block) when
+// synthesizeEntries is set; the PoH entries of the block are lost in that case.
+func toCarBlock(slot uint64, block *confirmed_block.ConfirmedBlock, entries []entry, synthesizeEntries bool) (*carwriter.Block, error) {
+	out := &carwriter.Block{
+		Slot:       slot,
+		ParentSlot: block.ParentSlot,
+	}
+	if block.BlockTime != nil {
+		out.BlockTime = block.BlockTime.Timestamp
+	}
+	if block.BlockHeight != nil {
+		height := block.BlockHeight.BlockHeight
+		out.BlockHeight = &height
+	}
+
+	transactions := make([]carwriter.Transaction, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		converted, err := toCarTransaction(tx)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		transactions[i] = *converted
+	}
+
+	switch {
+	case entries != nil:
+		for i, e := range entries {
+			start := uint64(e.startingTransactionIndex)
+			end := start + e.numTransactions
+			if end > uint64(len(transactions)) {
+				return nil, fmt.Errorf("entry %d refers to transactions %d-%d, but the block has %d", i, start, end, len(transactions))
+			}
+			if len(e.hash) != solana.PublicKeyLength {
+				return nil, fmt.Errorf("entry %d has a hash of %d bytes", i, len(e.hash))
+			}
+			converted := carwriter.Entry{
+				NumHashes:    e.numHashes,
+				Transactions: transactions[start:end],
+			}
+			copy(converted.Hash[:], e.hash)
+			out.Entries = append(out.Entries, converted)
+		}
+	case synthesizeEntries:
+		blockhash, err := base58.Decode(block.Blockhash)
+		if err != nil || len(blockhash) != solana.PublicKeyLength {
+			return nil, fmt.Errorf("invalid blockhash %q", block.Blockhash)
+		}
+		synthesized := carwriter.Entry{Transactions: transactions}
+		copy(synthesized.Hash[:], blockhash)
+		out.Entries = []carwriter.Entry{synthesized}
+	default:
+		return nil, errors.New("the entries of the block are not in bigtable")
+	}
+
+	if len(block.Rewards) > 0 {
+		buf, err := proto.Marshal(&confirmed_block.Rewards{Rewards: block.Rewards})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode rewards: %w", err)
+		}
+		out.Rewards, err = tooling.CompressZstd(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress rewards: %w", err)
+		}
+	}
+	return out, nil
+}
+
+func toCarTransaction(tx *confirmed_block.ConfirmedTransaction) (*carwriter.Transaction, error) {
+	if tx.Transaction == nil || tx.Transaction.Message == nil {
+		return nil, errors.New("missing transaction")
+	}
+	data, err := encodeTransaction(tx.Transaction)
+	if err != nil {
+		return nil, err
+	}
+	out := &carwriter.Transaction{Data: data}
+	if tx.Meta != nil {
+		buf, err := proto.Marshal(tx.Meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode meta: %w", err)
+		}
+		out.Metadata, err = tooling.CompressZstd(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress meta: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// encodeTransaction serializes the transaction in the wire format.
+func encodeTransaction(tx *confirmed_block.Transaction) ([]byte, error) {
+	var buf bytes.Buffer
+	putCompactU16(&buf, len(tx.Signatures))
+	for _, sig := range tx.Signatures {
+		if len(sig) != solana.SignatureLength {
+			return nil, fmt.Errorf("signature of %d bytes", len(sig))
+		}
+		buf.Write(sig)
+	}
+
+	msg := tx.Message
+	if msg.Versioned {
+		// only v0 messages exist.
+		buf.WriteByte(0x80)
+	}
+	if msg.Header == nil {
+		return nil, errors.New("missing message header")
+	}
+	buf.WriteByte(byte(msg.Header.NumRequiredSignatures))
+	buf.WriteByte(byte(msg.Header.NumReadonlySignedAccounts))
+	buf.WriteByte(byte(msg.Header.NumReadonlyUnsignedAccounts))
+	putCompactU16(&buf, len(msg.AccountKeys))
+	for _, key := range msg.AccountKeys {
+		if len(key) != solana.PublicKeyLength {
+			return nil, fmt.Errorf("account key of %d bytes", len(key))
+		}
+		buf.Write(key)
+	}
+	if len(msg.RecentBlockhash) != solana.PublicKeyLength {
+		return nil, fmt.Errorf("recent blockhash of %d bytes", len(msg.RecentBlockhash))
+	}
+	buf.Write(msg.RecentBlockhash)
+	putCompactU16(&buf, len(msg.Instructions))
+	for _, ix := range msg.Instructions {
+		buf.WriteByte(byte(ix.ProgramIdIndex))
+		putCompactU16(&buf, len(ix.Accounts))
+		buf.Write(ix.Accounts)
+		putCompactU16(&buf, len(ix.Data))
+		buf.Write(ix.Data)
+	}
+	if msg.Versioned {
+		putCompactU16(&buf, len(msg.AddressTableLookups))
+		for _, lookup := range msg.AddressTableLookups {
+			if len(lookup.AccountKey) != solana.PublicKeyLength {
+				return nil, fmt.Errorf("address table key of %d bytes", len(lookup.AccountKey))
+			}
+			buf.Write(lookup.AccountKey)
+			putCompactU16(&buf, len(lookup.WritableIndexes))
+			buf.Write(lookup.WritableIndexes)
+			putCompactU16(&buf, len(lookup.ReadonlyIndexes))
+			buf.Write(lookup.ReadonlyIndexes)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func putCompactU16(buf *bytes.Buffer, v int) {
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			buf.WriteByte(b)
+			return
+		}
+		buf.WriteByte(b | 0x80)
+	}
+}
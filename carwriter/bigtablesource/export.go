@@ -0,0 +1,166 @@
+package bigtablesource
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	solanablockrewards "github.com/rpcpool/yellowstone-faithful/solana-block-rewards"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// Blockhash returns the blockhash of a block, which is the hash of its last entry.
+func Blockhash(block *carwriter.Block) (solana.Hash, error) {
+	if len(block.Entries) == 0 {
+		return solana.Hash{}, errors.New("the block has no entries")
+	}
+	return block.Entries[len(block.Entries)-1].Hash, nil
+}
+
+// ExportBlock converts a block of a CAR to the cells of its rows in the "blocks" table (a
+// ConfirmedBlock protobuf) and in the "entries" table, compressed like the
+// solana-storage-bigtable crate does. previousBlockhash is the blockhash of the parent
+// block; it is left empty when zero (when the parent block is not available).
+func ExportBlock(block *carwriter.Block, previousBlockhash solana.Hash) (blockCell []byte, entriesCell []byte, _ error) {
+	confirmed, err := toConfirmedBlock(block, previousBlockhash)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err := proto.Marshal(confirmed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode block: %w", err)
+	}
+	if blockCell, err = compressCell(raw); err != nil {
+		return nil, nil, err
+	}
+	if entriesCell, err = compressCell(encodeEntries(toEntries(block))); err != nil {
+		return nil, nil, err
+	}
+	return blockCell, entriesCell, nil
+}
+
+func compressCell(payload []byte) ([]byte, error) {
+	compressed, err := tooling.CompressZstd(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress cell: %w", err)
+	}
+	return append(binary.LittleEndian.AppendUint32(nil, compressionZstd), compressed...), nil
+}
+
+// toConfirmedBlock is the reverse of toCarBlock.
+func toConfirmedBlock(block *carwriter.Block, previousBlockhash solana.Hash) (*confirmed_block.ConfirmedBlock, error) {
+	blockhash, err := Blockhash(block)
+	if err != nil {
+		return nil, err
+	}
+	out := &confirmed_block.ConfirmedBlock{
+		Blockhash:  blockhash.String(),
+		ParentSlot: block.ParentSlot,
+		BlockTime:  &confirmed_block.UnixTimestamp{Timestamp: block.BlockTime},
+	}
+	if !previousBlockhash.IsZero() {
+		out.PreviousBlockhash = previousBlockhash.String()
+	}
+	if block.BlockHeight != nil {
+		out.BlockHeight = &confirmed_block.BlockHeight{BlockHeight: *block.BlockHeight}
+	}
+	for _, e := range block.Entries {
+		for _, tx := range e.Transactions {
+			converted, err := toConfirmedTransaction(&tx)
+			if err != nil {
+				return nil, fmt.Errorf("transaction %d: %w", len(out.Transactions), err)
+			}
+			out.Transactions = append(out.Transactions, converted)
+		}
+	}
+	if len(block.Rewards) > 0 {
+		buf, err := tooling.DecompressZstd(block.Rewards)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress rewards: %w", err)
+		}
+		rewards, err := solanablockrewards.ParseRewards(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode rewards: %w", err)
+		}
+		out.Rewards = rewards.Rewards
+	}
+	return out, nil
+}
+
+func toConfirmedTransaction(tx *carwriter.Transaction) (*confirmed_block.ConfirmedTransaction, error) {
+	decoded, err := decodeTransaction(tx.Data)
+	if err != nil {
+		return nil, err
+	}
+	out := &confirmed_block.ConfirmedTransaction{Transaction: decoded}
+	if len(tx.Metadata) > 0 {
+		buf, err := tooling.DecompressZstd(tx.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress meta: %w", err)
+		}
+		meta, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode meta: %w", err)
+		}
+		// the blocks table only has protobuf metas; the legacy ones are upgraded.
+		if out.Meta, err = meta.ToProtobuf(); err != nil {
+			return nil, fmt.Errorf("failed to convert meta: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// decodeTransaction is the reverse of encodeTransaction.
+func decodeTransaction(data []byte) (*confirmed_block.Transaction, error) {
+	tx, err := solana.TransactionFromDecoder(bin.NewBinDecoder(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	return nodetools.TransactionToProtobuf(tx), nil
+}
+
+// toEntries is the reverse of the entries of toCarBlock.
+func toEntries(block *carwriter.Block) []entry {
+	entries := make([]entry, len(block.Entries))
+	var numTransactions uint32
+	for i, e := range block.Entries {
+		entries[i] = entry{
+			index:                    uint32(i),
+			numHashes:                e.NumHashes,
+			hash:                     block.Entries[i].Hash[:],
+			numTransactions:          uint64(len(e.Transactions)),
+			startingTransactionIndex: numTransactions,
+		}
+		numTransactions += uint32(len(e.Transactions))
+	}
+	return entries
+}
+
+// encodeEntries encodes a solana.storage.entries.Entries protobuf.
+func encodeEntries(entries []entry) []byte {
+	var out []byte
+	for _, e := range entries {
+		var msg []byte
+		msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(e.index))
+		msg = protowire.AppendTag(msg, 2, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, e.numHashes)
+		msg = protowire.AppendTag(msg, 3, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, e.hash)
+		msg = protowire.AppendTag(msg, 4, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, e.numTransactions)
+		msg = protowire.AppendTag(msg, 5, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(e.startingTransactionIndex))
+		out = protowire.AppendTag(out, 1, protowire.BytesType)
+		out = protowire.AppendBytes(out, msg)
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package bigtablesource
+
+import (
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExportBlock(t *testing.T) {
+	want := testBlock(100)
+	want.PreviousBlockhash = solana.HashFromBytes(key(7)).String()
+	entries := []entry{
+		{index: 0, numHashes: 10, hash: key(0x10), numTransactions: 1, startingTransactionIndex: 0},
+		{index: 1, numHashes: 20, hash: key(0x11), numTransactions: 0, startingTransactionIndex: 1},
+		{index: 2, numHashes: 30, hash: key(8), numTransactions: 1, startingTransactionIndex: 1},
+	}
+	block, err := toCarBlock(100, want, entries, false)
+	require.NoError(t, err)
+
+	blockhash, err := Blockhash(block)
+	require.NoError(t, err)
+	require.Equal(t, want.Blockhash, blockhash.String())
+
+	blockCell, entriesCell, err := ExportBlock(block, solana.HashFromBytes(key(7)))
+	require.NoError(t, err)
+
+	// the cells are read back as the source reads them.
+	raw, err := decompress(blockCell)
+	require.NoError(t, err)
+	var got confirmed_block.ConfirmedBlock
+	require.NoError(t, proto.Unmarshal(raw, &got))
+	require.True(t, proto.Equal(want, &got), "got %v", &got)
+
+	raw, err = decompress(entriesCell)
+	require.NoError(t, err)
+	gotEntries, err := parseEntries(raw)
+	require.NoError(t, err)
+	require.Equal(t, entries, gotEntries)
+
+	// without the parent block, the previous blockhash is left empty.
+	blockCell, _, err = ExportBlock(block, solana.Hash{})
+	require.NoError(t, err)
+	raw, err = decompress(blockCell)
+	require.NoError(t, err)
+	require.NoError(t, proto.Unmarshal(raw, &got))
+	require.Empty(t, got.PreviousBlockhash)
+}
@@ -0,0 +1,354 @@
+// Package bigtablesource reads blocks from a Solana warehouse Bigtable instance
+// (the tables written by the solana-storage-bigtable crate) as a carwriter.BlockSource.
+//
+// Blocks are read from the "blocks" table (ConfirmedBlock protobufs), and their
+// PoH entries from the "entries" table, which is only populated by recent
+// validator versions; see Config.SynthesizeEntries for older slots.
+package bigtablesource
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/gcpauth"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	btpb "google.golang.org/genproto/googleapis/bigtable/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+)
+
+const (
+	DefaultEndpoint = "bigtable.googleapis.com:443"
+
+	blocksTable  = "blocks"
+	entriesTable = "entries"
+	maxRetries   = 5
+
+	readOnlyScope = "https://www.googleapis.com/auth/bigtable.data.readonly"
+)
+
+type Config struct {
+	Project  string
+	Instance string
+	// AppProfile is the app profile to use (optional).
+	AppProfile string
+	// Endpoint defaults to DefaultEndpoint, or to $BIGTABLE_EMULATOR_HOST when set
+	// (without TLS nor authentication).
+	Endpoint string
+	// AccessToken is an OAuth2 access token to use as is.
+	AccessToken string
+	// CredentialsFile is a service account key file; defaults to $GOOGLE_APPLICATION_CREDENTIALS.
+	// Without a token nor a credentials file, tokens are requested from the GCE metadata server.
+	CredentialsFile string
+	// SynthesizeEntries makes blocks without a row in the entries table have a
+	// single entry with all the transactions and the blockhash as hash, instead of failing.
+	SynthesizeEntries bool
+}
+
+// Source reads blocks from Bigtable.
+type Source struct {
+	conn   *grpc.ClientConn
+	client btpb.BigtableClient
+	config Config
+}
+
+var _ carwriter.BlockSource = (*Source)(nil)
+
+// New connects to the Bigtable instance.
+func New(config Config) (*Source, error) {
+	if config.Project == "" || config.Instance == "" {
+		return nil, errors.New("the bigtable project and instance are required")
+	}
+	var opts []grpc.DialOption
+	endpoint := config.Endpoint
+	if emulator := os.Getenv("BIGTABLE_EMULATOR_HOST"); endpoint == "" && emulator != "" {
+		endpoint = emulator
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		if endpoint == "" {
+			endpoint = DefaultEndpoint
+		}
+		tokens, err := gcpauth.New(config.AccessToken, config.CredentialsFile, readOnlyScope)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts,
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})),
+			grpc.WithPerRPCCredentials(bearerCredentials{tokens: gcpauth.NewCache(tokens)}),
+		)
+	}
+	opts = append(opts, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(256<<20)))
+	conn, err := grpc.NewClient(endpoint, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", endpoint, err)
+	}
+	return newSource(conn, config), nil
+}
+
+func newSource(conn *grpc.ClientConn, config Config) *Source {
+	return &Source{
+		conn:   conn,
+		client: btpb.NewBigtableClient(conn),
+		config: config,
+	}
+}
+
+// bearerCredentials implements credentials.PerRPCCredentials.
+type bearerCredentials struct {
+	tokens *gcpauth.Cache
+}
+
+func (c bearerCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	tok, err := c.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + tok}, nil
+}
+
+func (c bearerCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+func (s *Source) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Source) tableName(table string) string {
+	return fmt.Sprintf("projects/%s/instances/%s/tables/%s", s.config.Project, s.config.Instance, table)
+}
+
+// SlotKey is the row key of a slot: 16 lowercase hex digits.
+func SlotKey(slot uint64) string {
+	return fmt.Sprintf("%016x", slot)
+}
+
+func (s *Source) Blocks(ctx context.Context, from, to uint64, fn func(*carwriter.Block) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the entries are streamed alongside the blocks; both are in ascending slot order.
+	entryRows := make(chan row, 64)
+	entriesErr := make(chan error, 1)
+	go func() {
+		defer close(entryRows)
+		err := s.readRows(ctx, entriesTable, from, to, func(r row) error {
+			select {
+			case entryRows <- r:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if status.Code(err) == codes.NotFound {
+			// instances written by older validators have no entries table.
+			klog.Warningf("Bigtable table %s not found: %s", entriesTable, err)
+			err = nil
+		}
+		entriesErr <- err
+	}()
+	var (
+		pendingEntries   *row
+		entriesExhausted bool
+	)
+	entriesFor := func(slot uint64) ([]entry, error) {
+		for {
+			if pendingEntries == nil {
+				if entriesExhausted {
+					return nil, nil
+				}
+				r, ok := <-entryRows
+				if !ok {
+					entriesExhausted = true
+					if err := <-entriesErr; err != nil {
+						return nil, fmt.Errorf("failed to read entries: %w", err)
+					}
+					return nil, nil
+				}
+				pendingEntries = &r
+			}
+			if pendingEntries.slot > slot {
+				return nil, nil
+			}
+			r := pendingEntries
+			pendingEntries = nil
+			if r.slot == slot {
+				data, ok := r.cells["proto"]
+				if !ok {
+					return nil, fmt.Errorf("entries of slot %d: no proto cell", slot)
+				}
+				raw, err := decompress(data)
+				if err != nil {
+					return nil, fmt.Errorf("entries of slot %d: %w", slot, err)
+				}
+				entries, err := parseEntries(raw)
+				if err != nil {
+					return nil, fmt.Errorf("entries of slot %d: %w", slot, err)
+				}
+				return entries, nil
+			}
+		}
+	}
+
+	return s.readRows(ctx, blocksTable, from, to, func(r row) error {
+		data, ok := r.cells["proto"]
+		if !ok {
+			if _, ok := r.cells["bin"]; ok {
+				return fmt.Errorf("block %d is bincode-encoded, which is not supported", r.slot)
+			}
+			return fmt.Errorf("block %d: no proto cell", r.slot)
+		}
+		raw, err := decompress(data)
+		if err != nil {
+			return fmt.Errorf("block %d: %w", r.slot, err)
+		}
+		var block confirmed_block.ConfirmedBlock
+		if err := proto.Unmarshal(raw, &block); err != nil {
+			return fmt.Errorf("block %d: failed to decode: %w", r.slot, err)
+		}
+		entries, err := entriesFor(r.slot)
+		if err != nil {
+			return err
+		}
+		converted, err := toCarBlock(r.slot, &block, entries, s.config.SynthesizeEntries)
+		if err != nil {
+			return fmt.Errorf("block %d: %w", r.slot, err)
+		}
+		return fn(converted)
+	})
+}
+
+// row is a committed Bigtable row: the latest value of each column, by qualifier.
+type row struct {
+	slot  uint64
+	cells map[string][]byte
+}
+
+// readRows streams the rows of the slot range [from, to], retrying transient
+// errors from the row after the last one delivered.
+func (s *Source) readRows(ctx context.Context, table string, from, to uint64, fn func(row) error) error {
+	next := from
+	done := false
+	for attempt := 0; ; attempt++ {
+		err := s.readRowsOnce(ctx, table, next, to, func(r row) error {
+			if err := fn(r); err != nil {
+				return err
+			}
+			if r.slot == to {
+				done = true
+			}
+			next = r.slot + 1
+			return nil
+		})
+		if err == nil || done {
+			return nil
+		}
+		if code := status.Code(err); (code != codes.Unavailable && code != codes.DeadlineExceeded && code != codes.Aborted) || attempt >= maxRetries {
+			return err
+		}
+		klog.Warningf("Reading bigtable table %s from slot %d failed (%s); retrying", table, next, err)
+		select {
+		case <-time.After(time.Duration(attempt+1) * time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Source) readRowsOnce(ctx context.Context, table string, from, to uint64, fn func(row) error) error {
+	stream, err := s.client.ReadRows(ctx, &btpb.ReadRowsRequest{
+		TableName:    s.tableName(table),
+		AppProfileId: s.config.AppProfile,
+		Rows: &btpb.RowSet{RowRanges: []*btpb.RowRange{{
+			StartKey: &btpb.RowRange_StartKeyClosed{StartKeyClosed: []byte(SlotKey(from))},
+			EndKey:   &btpb.RowRange_EndKeyClosed{EndKeyClosed: []byte(SlotKey(to))},
+		}}},
+		Filter: &btpb.RowFilter{Filter: &btpb.RowFilter_CellsPerColumnLimitFilter{CellsPerColumnLimitFilter: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	var merger chunkMerger
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			if merger.inProgress() {
+				return errors.New("the stream ended in the middle of a row")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, chunk := range resp.Chunks {
+			r, err := merger.add(chunk)
+			if err != nil {
+				return err
+			}
+			if r != nil {
+				if err := fn(*r); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// chunkMerger reassembles rows from the cell chunks of a ReadRows stream.
+type chunkMerger struct {
+	current   *row
+	qualifier string
+	value     []byte
+}
+
+func (m *chunkMerger) inProgress() bool {
+	return m.current != nil
+}
+
+func (m *chunkMerger) add(chunk *btpb.ReadRowsResponse_CellChunk) (*row, error) {
+	if len(chunk.RowKey) > 0 {
+		slot, err := strconv.ParseUint(string(chunk.RowKey), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row key %q", chunk.RowKey)
+		}
+		if m.current == nil || m.current.slot != slot {
+			m.current = &row{slot: slot, cells: make(map[string][]byte)}
+		}
+	}
+	if m.current == nil {
+		return nil, errors.New("cell chunk without a row key")
+	}
+	if chunk.Qualifier != nil {
+		m.qualifier = string(chunk.Qualifier.Value)
+		m.value = nil
+	}
+	m.value = append(m.value, chunk.Value...)
+	if chunk.ValueSize == 0 {
+		if _, ok := m.current.cells[m.qualifier]; !ok {
+			m.current.cells[m.qualifier] = m.value
+		}
+		m.value = nil
+	}
+	if chunk.GetResetRow() {
+		m.current = nil
+		return nil, nil
+	}
+	if chunk.GetCommitRow() {
+		r := m.current
+		m.current = nil
+		return r, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,284 @@
+package bigtablesource
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/stretchr/testify/require"
+	btpb "google.golang.org/genproto/googleapis/bigtable/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// fakeBigtable serves rows (slot -> proto cell) of its tables, splitting
+// values in small chunks; it fails the first stream of failOnce after one row.
+type fakeBigtable struct {
+	btpb.UnimplementedBigtableServer
+	tables map[string]map[uint64][]byte
+
+	mu       sync.Mutex
+	failOnce map[string]bool
+}
+
+func (f *fakeBigtable) ReadRows(req *btpb.ReadRowsRequest, stream btpb.Bigtable_ReadRowsServer) error {
+	name := req.TableName[strings.LastIndex(req.TableName, "/")+1:]
+	table, ok := f.tables[name]
+	if !ok {
+		return status.Error(codes.NotFound, "table not found")
+	}
+	rng := req.Rows.RowRanges[0]
+	start := string(rng.StartKey.(*btpb.RowRange_StartKeyClosed).StartKeyClosed)
+	end := string(rng.EndKey.(*btpb.RowRange_EndKeyClosed).EndKeyClosed)
+	var slots []uint64
+	for slot := range table {
+		if key := SlotKey(slot); key >= start && key <= end {
+			slots = append(slots, slot)
+		}
+	}
+	slices.Sort(slots)
+	for i, slot := range slots {
+		f.mu.Lock()
+		fail := f.failOnce[name] && i == 1
+		if fail {
+			f.failOnce[name] = false
+		}
+		f.mu.Unlock()
+		if fail {
+			return status.Error(codes.Unavailable, "try again")
+		}
+		value := table[slot]
+		var chunks []*btpb.ReadRowsResponse_CellChunk
+		for offset := 0; offset < len(value) || offset == 0; offset += 100 {
+			chunk := &btpb.ReadRowsResponse_CellChunk{Value: value[offset:min(offset+100, len(value))]}
+			if offset == 0 {
+				chunk.RowKey = []byte(SlotKey(slot))
+				chunk.FamilyName = wrapperspb.String("x")
+				chunk.Qualifier = wrapperspb.Bytes([]byte("proto"))
+			}
+			if offset+100 < len(value) {
+				chunk.ValueSize = int32(len(value))
+			} else {
+				chunk.RowStatus = &btpb.ReadRowsResponse_CellChunk_CommitRow{CommitRow: true}
+			}
+			chunks = append(chunks, chunk)
+		}
+		if err := stream.Send(&btpb.ReadRowsResponse{Chunks: chunks}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startFake(t *testing.T, fake *fakeBigtable, config Config) *Source {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	btpb.RegisterBigtableServer(srv, fake)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	src := newSource(conn, config)
+	t.Cleanup(func() { src.Close() })
+	return src
+}
+
+func withCompression(method uint32, payload []byte) []byte {
+	out := binary.LittleEndian.AppendUint32(nil, method)
+	return append(out, payload...)
+}
+
+func zstdCell(t *testing.T, payload []byte) []byte {
+	compressed, err := tooling.CompressZstd(payload)
+	require.NoError(t, err)
+	return withCompression(compressionZstd, compressed)
+}
+
+func gzipCell(t *testing.T, payload []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return withCompression(compressionGzip, buf.Bytes())
+}
+
+func key(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func testBlock(slot uint64) *confirmed_block.ConfirmedBlock {
+	legacy := &confirmed_block.ConfirmedTransaction{
+		Transaction: &confirmed_block.Transaction{
+			Signatures: [][]byte{bytes.Repeat([]byte{byte(slot)}, 64)},
+			Message: &confirmed_block.Message{
+				Header:          &confirmed_block.MessageHeader{NumRequiredSignatures: 1, NumReadonlyUnsignedAccounts: 1},
+				AccountKeys:     [][]byte{key(1), key(2), key(3)},
+				RecentBlockhash: key(9),
+				Instructions: []*confirmed_block.CompiledInstruction{
+					{ProgramIdIndex: 2, Accounts: []byte{0, 1}, Data: bytes.Repeat([]byte{7}, 200)},
+				},
+			},
+		},
+		Meta: &confirmed_block.TransactionStatusMeta{Fee: 5000, LogMessages: []string{"Program log: hello"}},
+	}
+	v0 := &confirmed_block.ConfirmedTransaction{
+		Transaction: &confirmed_block.Transaction{
+			Signatures: [][]byte{bytes.Repeat([]byte{byte(slot + 1)}, 64)},
+			Message: &confirmed_block.Message{
+				Header:          &confirmed_block.MessageHeader{NumRequiredSignatures: 1},
+				AccountKeys:     [][]byte{key(4), key(5)},
+				RecentBlockhash: key(9),
+				Instructions: []*confirmed_block.CompiledInstruction{
+					{ProgramIdIndex: 1, Accounts: []byte{0, 2}, Data: []byte{1, 2, 3}},
+				},
+				Versioned: true,
+				AddressTableLookups: []*confirmed_block.MessageAddressTableLookup{
+					{AccountKey: key(6), WritableIndexes: []byte{3}, ReadonlyIndexes: []byte{}},
+				},
+			},
+		},
+		Meta: &confirmed_block.TransactionStatusMeta{Fee: 10000},
+	}
+	return &confirmed_block.ConfirmedBlock{
+		Blockhash:    solana.HashFromBytes(key(8)).String(),
+		ParentSlot:   slot - 1,
+		Transactions: []*confirmed_block.ConfirmedTransaction{legacy, v0},
+		Rewards:      []*confirmed_block.Reward{{Pubkey: solana.PublicKeyFromBytes(key(1)).String(), Lamports: 42}},
+		BlockTime:    &confirmed_block.UnixTimestamp{Timestamp: 1700000000 + int64(slot)},
+		BlockHeight:  &confirmed_block.BlockHeight{BlockHeight: slot - 10},
+	}
+}
+
+func readAll(t *testing.T, src carwriter.BlockSource, from, to uint64) ([]*carwriter.Block, error) {
+	var blocks []*carwriter.Block
+	err := src.Blocks(context.Background(), from, to, func(b *carwriter.Block) error {
+		blocks = append(blocks, b)
+		return nil
+	})
+	return blocks, err
+}
+
+func TestEncodeTransaction(t *testing.T) {
+	for _, tx := range testBlock(100).Transactions {
+		data, err := encodeTransaction(tx.Transaction)
+		require.NoError(t, err)
+		decoded, err := solana.TransactionFromDecoder(bin.NewBinDecoder(data))
+		require.NoError(t, err)
+		require.Equal(t, tx.Transaction.Signatures[0], decoded.Signatures[0][:])
+		require.Equal(t, tx.Transaction.Message.Versioned, decoded.Message.IsVersioned())
+		require.Len(t, decoded.Message.AccountKeys, len(tx.Transaction.Message.AccountKeys))
+		require.Equal(t, []byte(tx.Transaction.Message.Instructions[0].Data), []byte(decoded.Message.Instructions[0].Data))
+		reencoded, err := decoded.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, data, reencoded)
+	}
+}
+
+func TestSourceBlocks(t *testing.T) {
+	blocks := make(map[uint64][]byte)
+	for _, slot := range []uint64{100, 101, 103} {
+		raw, err := proto.Marshal(testBlock(slot))
+		require.NoError(t, err)
+		blocks[slot] = zstdCell(t, raw)
+	}
+	entries := map[uint64][]byte{
+		100: gzipCell(t, encodeEntries([]entry{
+			{index: 0, numHashes: 10, hash: key(0x10), numTransactions: 1, startingTransactionIndex: 0},
+			{index: 1, numHashes: 20, hash: key(0x11), numTransactions: 0, startingTransactionIndex: 1},
+			{index: 2, numHashes: 30, hash: key(8), numTransactions: 1, startingTransactionIndex: 1},
+		})),
+		103: withCompression(compressionNone, encodeEntries([]entry{
+			{index: 0, numHashes: 5, hash: key(8), numTransactions: 2, startingTransactionIndex: 0},
+		})),
+	}
+	fake := &fakeBigtable{
+		tables:   map[string]map[uint64][]byte{blocksTable: blocks, entriesTable: entries},
+		failOnce: map[string]bool{blocksTable: true},
+	}
+	src := startFake(t, fake, Config{Project: "p", Instance: "i"})
+
+	// slot 101 has no entries.
+	_, err := readAll(t, src, 100, 103)
+	require.ErrorContains(t, err, "block 101: the entries of the block are not in bigtable")
+
+	src.config.SynthesizeEntries = true
+	got, err := readAll(t, src, 100, 103)
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+
+	require.Equal(t, uint64(100), got[0].Slot)
+	require.Equal(t, uint64(99), got[0].ParentSlot)
+	require.Equal(t, int64(1700000100), got[0].BlockTime)
+	require.Equal(t, uint64(90), *got[0].BlockHeight)
+	require.Len(t, got[0].Entries, 3)
+	require.Equal(t, uint64(20), got[0].Entries[1].NumHashes)
+	require.Len(t, got[0].Entries[0].Transactions, 1)
+	require.Empty(t, got[0].Entries[1].Transactions)
+	require.Len(t, got[0].Entries[2].Transactions, 1)
+
+	require.Len(t, got[1].Entries, 1)
+	require.Equal(t, solana.HashFromBytes(key(8)), got[1].Entries[0].Hash)
+	require.Len(t, got[1].Entries[0].Transactions, 2)
+
+	metaBuf, err := tooling.DecompressZstd(got[2].Entries[0].Transactions[0].Metadata)
+	require.NoError(t, err)
+	var meta confirmed_block.TransactionStatusMeta
+	require.NoError(t, proto.Unmarshal(metaBuf, &meta))
+	require.Equal(t, uint64(5000), meta.Fee)
+	require.Equal(t, []string{"Program log: hello"}, meta.LogMessages)
+
+	rewardsBuf, err := tooling.DecompressZstd(got[2].Rewards)
+	require.NoError(t, err)
+	var rewards confirmed_block.Rewards
+	require.NoError(t, proto.Unmarshal(rewardsBuf, &rewards))
+	require.Equal(t, int64(42), rewards.Rewards[0].Lamports)
+
+	// The blocks make a valid CAR.
+	out := filepath.Join(t.TempDir(), "epoch-0.car")
+	w, err := carwriter.Create(out, 0, 0)
+	require.NoError(t, err)
+	for _, b := range got {
+		_, err := w.WriteBlock(b)
+		require.NoError(t, err)
+	}
+	_, err = w.Finish()
+	require.NoError(t, err)
+	stats, err := carwriter.Validate(out)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), stats.Blocks)
+	require.Equal(t, uint64(6), stats.Transactions)
+}
+
+func TestSourceWithoutEntriesTable(t *testing.T) {
+	raw, err := proto.Marshal(testBlock(100))
+	require.NoError(t, err)
+	fake := &fakeBigtable{tables: map[string]map[uint64][]byte{blocksTable: {100: zstdCell(t, raw)}}}
+	src := startFake(t, fake, Config{Project: "p", Instance: "i", SynthesizeEntries: true})
+	got, err := readAll(t, src, 0, 1000)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Len(t, got[0].Entries, 1)
+}
@@ -0,0 +1,124 @@
+package carwriter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ipfs/go-cid"
+)
+
+// Checkpoint is the state of a Writer at a point where the CAR file can be
+// truncated and writing resumed.
+type Checkpoint struct {
+	Epoch           uint64 `json:"epoch"`
+	MaxSubsetBlocks int    `json:"max_subset_blocks"`
+	// Offset is the size of the CAR file at the checkpoint.
+	Offset uint64 `json:"offset"`
+	// LastSlot is the slot of the last block written before the checkpoint (if any).
+	LastSlot *uint64 `json:"last_slot,omitempty"`
+	// Subsets are the subset nodes written so far.
+	Subsets []string `json:"subsets"`
+	// SubsetFirstSlot and SubsetBlocks describe the subset being filled.
+	SubsetFirstSlot uint64   `json:"subset_first_slot,omitempty"`
+	SubsetBlocks    []string `json:"subset_blocks,omitempty"`
+}
+
+// Checkpoint flushes everything written so far to disk and returns the state
+// needed to resume writing.
+func (w *Writer) Checkpoint() (*Checkpoint, error) {
+	if err := w.bw.Flush(); err != nil {
+		return nil, err
+	}
+	if err := w.file.Sync(); err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{
+		Epoch:           w.epoch,
+		MaxSubsetBlocks: w.maxSubsetBlocks,
+		Offset:          w.offset,
+		Subsets:         cidsToStrings(w.subsets),
+	}
+	if w.lastWrittenSlot != nil {
+		slot := *w.lastWrittenSlot
+		cp.LastSlot = &slot
+	}
+	if w.subset != nil {
+		cp.SubsetFirstSlot = w.subset.first
+		cp.SubsetBlocks = cidsToStrings(w.subset.blocks)
+	}
+	return cp, nil
+}
+
+func (w *Writer) restore(cp *Checkpoint) error {
+	var err error
+	w.offset = cp.Offset
+	if cp.LastSlot != nil {
+		slot := *cp.LastSlot
+		w.lastWrittenSlot = &slot
+	}
+	if w.subsets, err = stringsToCids(cp.Subsets); err != nil {
+		return fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	if len(cp.SubsetBlocks) > 0 {
+		if cp.LastSlot == nil {
+			return fmt.Errorf("invalid checkpoint: subset blocks without a last slot")
+		}
+		blocks, err := stringsToCids(cp.SubsetBlocks)
+		if err != nil {
+			return fmt.Errorf("invalid checkpoint: %w", err)
+		}
+		w.subset = &subsetState{
+			first:  cp.SubsetFirstSlot,
+			last:   *cp.LastSlot,
+			blocks: blocks,
+		}
+	}
+	return nil
+}
+
+// LoadCheckpoint reads a checkpoint file.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint %q: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// Save atomically writes the checkpoint to path.
+func (cp *Checkpoint) Save(path string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func cidsToStrings(cids []cid.Cid) []string {
+	out := make([]string, len(cids))
+	for i, c := range cids {
+		out[i] = c.String()
+	}
+	return out
+}
+
+func stringsToCids(strs []string) ([]cid.Cid, error) {
+	out := make([]cid.Cid, len(strs))
+	for i, s := range strs {
+		c, err := cid.Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = c
+	}
+	return out, nil
+}
@@ -0,0 +1,82 @@
+package carwriter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/ipfs/go-cid"
+	carv1 "github.com/ipld/go-car"
+)
+
+// MaxMetaOverlayNodeSize is the maximum size of the payloads (transaction and metadata)
+// of a node of a meta overlay; the size of a node must fit in the 3 bytes of the index.
+const MaxMetaOverlayNodeSize = 16<<20 - 1<<10
+
+// ErrMetaOverlayNodeTooLarge is returned when a transaction doesn't fit in a meta overlay node.
+var ErrMetaOverlayNodeTooLarge = errors.New("transaction is too large for a meta overlay")
+
+// MetaOverlayWriter writes a meta overlay of an epoch: a CAR with a transaction node for
+// each transaction whose metadata is missing from the epoch CAR, with the metadata
+// fetched from another source. The root of the CAR is the root of the epoch CAR.
+//
+// The payloads are never split in data frames, so a node can be read on its own
+// with its offset and size (see indexes.SigToOffsetAndSize_Writer).
+type MetaOverlayWriter struct {
+	w *Writer
+}
+
+// CreateMetaOverlay creates (or truncates) the meta overlay file at path, for the epoch CAR with the given root.
+func CreateMetaOverlay(path string, epochRoot cid.Cid) (*MetaOverlayWriter, error) {
+	if !epochRoot.Defined() {
+		return nil, errors.New("the root of the epoch CAR is undefined")
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := newWriter(file, 0, 0)
+	w.maxDataFrameSize = math.MaxInt
+	var header bytes.Buffer
+	if err := carv1.WriteHeader(&carv1.CarHeader{Roots: []cid.Cid{epochRoot}, Version: 1}, &header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to encode header: %w", err)
+	}
+	if err := w.write(header.Bytes()); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	return &MetaOverlayWriter{w: w}, nil
+}
+
+// WriteTransaction writes the transaction node of the transaction at the given slot and index
+// in the block, and returns the offset and the size of its section in the CAR.
+func (o *MetaOverlayWriter) WriteTransaction(slot uint64, index int, tx *Transaction) (cid.Cid, uint64, uint64, error) {
+	if len(tx.Metadata) == 0 {
+		return cid.Undef, 0, 0, errors.New("transaction has no metadata")
+	}
+	if len(tx.Data)+len(tx.Metadata) > MaxMetaOverlayNodeSize {
+		return cid.Undef, 0, 0, ErrMetaOverlayNodeTooLarge
+	}
+	offset := o.w.offset
+	c, err := o.w.writeTransaction(slot, index, tx)
+	if err != nil {
+		return cid.Undef, 0, 0, err
+	}
+	return c, offset, o.w.offset - offset, nil
+}
+
+// Finish flushes and closes the file.
+func (o *MetaOverlayWriter) Finish() error {
+	if err := o.w.bw.Flush(); err != nil {
+		o.w.file.Close()
+		return err
+	}
+	if err := o.w.file.Sync(); err != nil {
+		o.w.file.Close()
+		return err
+	}
+	return o.w.file.Close()
+}
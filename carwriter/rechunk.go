@@ -0,0 +1,337 @@
+package carwriter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ipfs/go-cid"
+	carv1 "github.com/ipld/go-car"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/schema"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+)
+
+// RechunkStats summarizes a re-chunked CAR.
+type RechunkStats struct {
+	Root cid.Cid
+	// Nodes is the number of nodes written; Changed is the number of them whose CID changed.
+	Nodes   int
+	Changed int
+	// FramesBefore and FramesAfter are the number of standalone DataFrame nodes in the input and the output.
+	FramesBefore int
+	FramesAfter  int
+}
+
+// Rechunk streams the CAR at inputPath to outputPath, splitting the data of the transactions and
+// rewards again in data frames of frameSize bytes (MaxDataFrameSize if zero), encoded like Writer
+// does. Different producers split large payloads differently; once re-chunked, CARs with the same
+// content have the same CIDs.
+//
+// The transaction and rewards nodes are re-encoded with their new frames, and the links of the
+// entries, blocks, subsets and epoch to the nodes whose CID changed are replaced; all the other
+// nodes are copied as they are, in the same order. The data frames of a block must all be linked by its
+// transactions or rewards. The root must be the only one of the header, and a dag-cbor, sha2-256
+// CIDv1 like the new one, since the header is rewritten in place at the end.
+func Rechunk(inputPath string, outputPath string, frameSize int) (*RechunkStats, error) {
+	if frameSize <= 0 {
+		frameSize = MaxDataFrameSize
+	}
+	in, err := os.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	rd, err := carreader.New(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CAR %q: %w", inputPath, err)
+	}
+	if len(rd.Header.Roots) != 1 {
+		return nil, fmt.Errorf("expected 1 root, got %d", len(rd.Header.Roots))
+	}
+	originalRoot := rd.Header.Roots[0]
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	w := &Writer{
+		file:             file,
+		bw:               bufio.NewWriterSize(file, 4<<20),
+		maxDataFrameSize: frameSize,
+	}
+	var header bytes.Buffer
+	if err := carv1.WriteHeader(rd.Header, &header); err != nil {
+		return nil, fmt.Errorf("failed to encode header: %w", err)
+	}
+	if err := w.write(header.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+
+	r := &rechunker{
+		w:        w,
+		stats:    &RechunkStats{Root: originalRoot},
+		replaced: make(map[cid.Cid]cid.Cid),
+		frames:   make(map[cid.Cid][]byte),
+	}
+	for {
+		c, _, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := r.node(c, data); err != nil {
+			return nil, fmt.Errorf("node %s: %w", c, err)
+		}
+	}
+	if len(r.frames) > 0 {
+		return nil, fmt.Errorf("%d data frames after the last block are not linked", len(r.frames))
+	}
+	if err := w.bw.Flush(); err != nil {
+		return nil, err
+	}
+
+	if newRoot, ok := r.replaced[originalRoot]; ok {
+		var newHeader bytes.Buffer
+		if err := carv1.WriteHeader(&carv1.CarHeader{Roots: []cid.Cid{newRoot}, Version: rd.Header.Version}, &newHeader); err != nil {
+			return nil, err
+		}
+		if newHeader.Len() != header.Len() {
+			return nil, fmt.Errorf("the new header has a different size (%d) than the original one (%d)", newHeader.Len(), header.Len())
+		}
+		if _, err := file.WriteAt(newHeader.Bytes(), 0); err != nil {
+			return nil, fmt.Errorf("failed to rewrite header: %w", err)
+		}
+		r.stats.Root = newRoot
+	} else if len(r.replaced) > 0 {
+		return nil, fmt.Errorf("nodes were re-encoded, but the root %s does not link to them", originalRoot)
+	}
+	return r.stats, file.Sync()
+}
+
+type rechunker struct {
+	w     *Writer
+	stats *RechunkStats
+	// replaced maps the CIDs of the nodes that changed to the new ones.
+	replaced map[cid.Cid]cid.Cid
+	// frames are the standalone data frames since the last block.
+	frames map[cid.Cid][]byte
+}
+
+func (r *rechunker) node(c cid.Cid, data []byte) error {
+	switch iplddecoders.Kind(data[1]) {
+	case iplddecoders.KindDataFrame:
+		r.frames[c] = data
+		r.stats.FramesBefore++
+		return nil
+	case iplddecoders.KindTransaction:
+		tx, err := iplddecoders.DecodeTransaction(data)
+		if err != nil {
+			return err
+		}
+		txData, err := r.payload(&tx.Data)
+		if err != nil {
+			return fmt.Errorf("transaction data: %w", err)
+		}
+		metadata, err := r.payload(&tx.Metadata)
+		if err != nil {
+			return fmt.Errorf("transaction metadata: %w", err)
+		}
+		dataFrame, err := r.writeDataFrames(txData)
+		if err != nil {
+			return err
+		}
+		metadataFrame, err := r.writeDataFrames(metadata)
+		if err != nil {
+			return err
+		}
+		return r.reencoded(c, &transaction{
+			Kind:     tx.Kind,
+			Data:     *dataFrame,
+			Metadata: *metadataFrame,
+			Slot:     tx.Slot,
+			Index:    tx.Index,
+		}, ipldbindcode.Prototypes.Transaction)
+	case iplddecoders.KindRewards:
+		rw, err := iplddecoders.DecodeRewards(data)
+		if err != nil {
+			return err
+		}
+		payload, err := r.payload(&rw.Data)
+		if err != nil {
+			return fmt.Errorf("rewards: %w", err)
+		}
+		frame, err := r.writeDataFrames(payload)
+		if err != nil {
+			return err
+		}
+		return r.reencoded(c, &rewards{
+			Kind: rw.Kind,
+			Slot: rw.Slot,
+			Data: *frame,
+		}, ipldbindcode.Prototypes.Rewards)
+	case iplddecoders.KindBlock:
+		if len(r.frames) > 0 {
+			return fmt.Errorf("%d data frames of the block are not linked", len(r.frames))
+		}
+		return r.relinked(c, data)
+	case iplddecoders.KindEntry, iplddecoders.KindSubset, iplddecoders.KindEpoch:
+		return r.relinked(c, data)
+	default:
+		return r.copy(c, data)
+	}
+}
+
+// payload returns the data of the frame and of the frames it links to, which are then
+// no longer pending.
+func (r *rechunker) payload(frame *ipldbindcode.DataFrame) ([]byte, error) {
+	return tooling.LoadDataFromDataFrames(frame, func(_ context.Context, c cid.Cid) (*ipldbindcode.DataFrame, error) {
+		data, ok := r.frames[c]
+		if !ok {
+			return nil, fmt.Errorf("data frame %s not found before the node that links to it", c)
+		}
+		delete(r.frames, c)
+		return iplddecoders.DecodeDataFrame(data)
+	})
+}
+
+func (r *rechunker) writeDataFrames(payload []byte) (*dataFrame, error) {
+	frame, err := r.w.writeDataFrames(payload)
+	if err != nil {
+		return nil, err
+	}
+	if frame.Next != nil {
+		r.stats.Nodes += len(**frame.Next)
+		r.stats.FramesAfter += len(**frame.Next)
+	}
+	return frame, nil
+}
+
+// relinked writes the node with its links to the nodes that changed replaced. The node is
+// re-encoded from the generic data model, so that all its other fields stay exactly the same
+// (e.g. a null block height is not dropped); it is copied as it is if no link changed.
+func (r *rechunker) relinked(c cid.Cid, data []byte) error {
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := dagcbor.Decode(nb, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	relinked := basicnode.Prototype.Any.NewBuilder()
+	changed, err := r.relink(nb.Build(), relinked)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return r.copy(c, data)
+	}
+	var buf bytes.Buffer
+	if err := dagcbor.Encode(relinked.Build(), &buf); err != nil {
+		return err
+	}
+	newCid, err := r.w.writeSection(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	r.stats.Nodes++
+	r.stats.Changed++
+	r.replaced[c] = newCid
+	return nil
+}
+
+// relink assembles a copy of the node with the links replaced, and returns true if there was any.
+func (r *rechunker) relink(node datamodel.Node, na datamodel.NodeAssembler) (bool, error) {
+	switch node.Kind() {
+	case datamodel.Kind_Link:
+		link, err := node.AsLink()
+		if err != nil {
+			return false, err
+		}
+		if cl, ok := link.(cidlink.Link); ok {
+			if newCid, ok := r.replaced[cl.Cid]; ok {
+				return true, na.AssignLink(cidlink.Link{Cid: newCid})
+			}
+		}
+		return false, na.AssignLink(link)
+	case datamodel.Kind_List:
+		la, err := na.BeginList(node.Length())
+		if err != nil {
+			return false, err
+		}
+		changed := false
+		for it := node.ListIterator(); !it.Done(); {
+			_, value, err := it.Next()
+			if err != nil {
+				return false, err
+			}
+			c, err := r.relink(value, la.AssembleValue())
+			if err != nil {
+				return false, err
+			}
+			changed = changed || c
+		}
+		return changed, la.Finish()
+	case datamodel.Kind_Map:
+		ma, err := na.BeginMap(node.Length())
+		if err != nil {
+			return false, err
+		}
+		changed := false
+		for it := node.MapIterator(); !it.Done(); {
+			key, value, err := it.Next()
+			if err != nil {
+				return false, err
+			}
+			if err := ma.AssembleKey().AssignNode(key); err != nil {
+				return false, err
+			}
+			c, err := r.relink(value, ma.AssembleValue())
+			if err != nil {
+				return false, err
+			}
+			changed = changed || c
+		}
+		return changed, ma.Finish()
+	default:
+		return false, na.AssignNode(node)
+	}
+}
+
+// reencoded writes the new encoding of the node, and records its new CID if it changed.
+func (r *rechunker) reencoded(original cid.Cid, value any, proto schema.TypedPrototype) error {
+	c, err := r.w.writeNode(value, proto)
+	if err != nil {
+		return err
+	}
+	r.stats.Nodes++
+	if !c.Equals(original) {
+		r.replaced[original] = c
+		r.stats.Changed++
+	}
+	return nil
+}
+
+// copy writes the node as it is.
+func (r *rechunker) copy(c cid.Cid, data []byte) error {
+	cidBytes := c.Bytes()
+	var sizeBuf [10]byte
+	n := putUvarint(sizeBuf[:], uint64(len(cidBytes)+len(data)))
+	for _, b := range [][]byte{sizeBuf[:n], cidBytes, data} {
+		if err := r.w.write(b); err != nil {
+			return err
+		}
+	}
+	r.stats.Nodes++
+	return nil
+}
@@ -0,0 +1,62 @@
+package carwriter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRechunk(t *testing.T) {
+	dir := t.TempDir()
+	fixture, err := os.ReadFile(fixtureCar)
+	require.NoError(t, err)
+	fixtureRoot := rootOf(t, fixtureCar)
+
+	// the fixture is already in the canonical frame size.
+	same := filepath.Join(dir, "same.car")
+	stats, err := Rechunk(fixtureCar, same, 0)
+	require.NoError(t, err)
+	require.Zero(t, stats.Changed)
+	require.Equal(t, fixtureRoot, stats.Root)
+	got, err := os.ReadFile(same)
+	require.NoError(t, err)
+	require.Equal(t, fixture, got)
+
+	// small frames, like another producer could write.
+	small := filepath.Join(dir, "small.car")
+	stats, err = Rechunk(fixtureCar, small, 64)
+	require.NoError(t, err)
+	require.NotZero(t, stats.Changed)
+	require.Zero(t, stats.FramesBefore)
+	require.NotZero(t, stats.FramesAfter)
+	require.NotEqual(t, fixtureRoot, stats.Root)
+	require.Equal(t, stats.Root, rootOf(t, small))
+	require.Equal(t, readBlocks(t, NewCarSource(fixtureCar), 0, 100), readBlocks(t, NewCarSource(small), 0, 100))
+	require.NotEqual(t, nodesByKind(t, fixtureCar, iplddecoders.KindTransaction), nodesByKind(t, small, iplddecoders.KindTransaction))
+
+	// re-chunked back to the canonical size, it is the fixture again.
+	back := filepath.Join(dir, "back.car")
+	stats, err = Rechunk(small, back, MaxDataFrameSize)
+	require.NoError(t, err)
+	require.Equal(t, fixtureRoot, stats.Root)
+	require.Equal(t, len(nodesByKind(t, small, iplddecoders.KindDataFrame)), stats.FramesBefore)
+	require.Zero(t, stats.FramesAfter)
+	got, err = os.ReadFile(back)
+	require.NoError(t, err)
+	require.Equal(t, fixture, got)
+}
+
+func rootOf(t *testing.T, path string) cid.Cid {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	rd, err := carreader.New(file)
+	require.NoError(t, err)
+	require.Len(t, rd.Header.Roots, 1)
+	return rd.Header.Roots[0]
+}
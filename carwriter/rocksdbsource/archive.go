@@ -0,0 +1,130 @@
+package rocksdbsource
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rpcpool/yellowstone-faithful/radiance/archiveutil"
+	"k8s.io/klog/v2"
+)
+
+// extractedMarker is written in the directory of an extracted archive once it is complete,
+// so that it is reused by the next runs.
+const extractedMarker = ".extracted"
+
+// extractArchive extracts a compressed ledger archive to a directory of dir named after the
+// archive, and returns the directory of its database.
+func extractArchive(archive, dir string) (string, error) {
+	name := filepath.Base(archive)
+	if i := strings.Index(name, ".tar"); i > 0 {
+		name = name[:i]
+	}
+	dest := filepath.Join(dir, name)
+	if _, err := os.Stat(filepath.Join(dest, extractedMarker)); err == nil {
+		klog.Infof("Using %s, extracted from %s", dest, archive)
+		return findDatabase(dest)
+	}
+	// a partial extraction from an interrupted run.
+	if err := os.RemoveAll(dest); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return "", err
+	}
+	klog.Infof("Extracting %s to %s", archive, dest)
+	f, err := os.Open(archive)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	rd, err := archiveutil.OpenTar(f)
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive %q: %w", archive, err)
+	}
+	if err := extractTar(rd, dest); err != nil {
+		return "", fmt.Errorf("failed to extract %q: %w", archive, err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, extractedMarker), nil, 0o644); err != nil {
+		return "", err
+	}
+	return findDatabase(dest)
+}
+
+func extractTar(rd *tar.Reader, dest string) error {
+	for {
+		hdr, err := rd.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid path %q", hdr.Name)
+		}
+		target := filepath.Join(dest, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0o755)
+		case tar.TypeReg:
+			err = extractFile(rd, target)
+		case tar.TypeLink:
+			// rocksdb checkpoints hard link the tables of the database.
+			linkName := filepath.Clean(filepath.FromSlash(hdr.Linkname))
+			if !filepath.IsLocal(linkName) {
+				return fmt.Errorf("invalid link %q", hdr.Linkname)
+			}
+			if err = os.MkdirAll(filepath.Dir(target), 0o755); err == nil {
+				err = os.Link(filepath.Join(dest, linkName), target)
+			}
+		default:
+			klog.Warningf("Skipping %q of the archive (type %q)", hdr.Name, hdr.Typeflag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractFile(rd io.Reader, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rd); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// findDatabase returns the directory of the database (the one with a CURRENT file) under dir.
+func findDatabase(dir string) (string, error) {
+	var found []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == "CURRENT" {
+			found = append(found, filepath.Dir(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(found) != 1 {
+		return "", fmt.Errorf("%d rocksdb databases in %s, expected one", len(found), dir)
+	}
+	return found[0], nil
+}
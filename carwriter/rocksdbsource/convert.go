@@ -0,0 +1,104 @@
+package rocksdbsource
+
+import (
+	"fmt"
+	"strconv"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"google.golang.org/protobuf/proto"
+)
+
+// rewardsProtobuf returns the rewards of a slot as a protobuf. Validators store them as a
+// protobuf, and stored them with bincode (a Vec<StoredExtendedReward>) before; like the
+// validator, the protobuf is tried first.
+func rewardsProtobuf(data []byte) ([]byte, error) {
+	var rewards confirmed_block.Rewards
+	if err := proto.Unmarshal(data, &rewards); err == nil {
+		return data, nil
+	}
+	converted, err := decodeBincodeRewards(data)
+	if err != nil {
+		return nil, fmt.Errorf("the rewards are neither a protobuf nor bincode: %w", err)
+	}
+	return proto.Marshal(converted)
+}
+
+// decodeBincodeRewards decodes a bincode Vec<StoredExtendedReward>. The fields after the
+// lamports were added later: they default to zero when the data ends before them.
+func decodeBincodeRewards(data []byte) (*confirmed_block.Rewards, error) {
+	dec := bin.NewBinDecoder(data)
+	numRewards, err := dec.ReadUint64(bin.LE)
+	if err != nil {
+		return nil, err
+	}
+	// a reward takes at least 16 bytes.
+	if numRewards > uint64(dec.Remaining())/16 {
+		return nil, fmt.Errorf("%d rewards in %d bytes", numRewards, dec.Remaining())
+	}
+	rewards := &confirmed_block.Rewards{Rewards: make([]*confirmed_block.Reward, numRewards)}
+	for i := range rewards.Rewards {
+		if rewards.Rewards[i], err = decodeBincodeReward(dec); err != nil {
+			return nil, fmt.Errorf("reward %d: %w", i, err)
+		}
+	}
+	return rewards, nil
+}
+
+func decodeBincodeReward(dec *bin.Decoder) (*confirmed_block.Reward, error) {
+	reward := &confirmed_block.Reward{}
+	pubkeyLen, err := dec.ReadUint64(bin.LE)
+	if err != nil {
+		return nil, err
+	}
+	if pubkeyLen > uint64(dec.Remaining()) {
+		return nil, fmt.Errorf("pubkey of %d bytes", pubkeyLen)
+	}
+	pubkey, err := dec.ReadNBytes(int(pubkeyLen))
+	if err != nil {
+		return nil, err
+	}
+	reward.Pubkey = string(pubkey)
+	if reward.Lamports, err = dec.ReadInt64(bin.LE); err != nil {
+		return nil, err
+	}
+	if !dec.HasRemaining() {
+		return reward, nil
+	}
+	if reward.PostBalance, err = dec.ReadUint64(bin.LE); err != nil {
+		return nil, err
+	}
+	if !dec.HasRemaining() {
+		return reward, nil
+	}
+	hasType, err := dec.ReadBool()
+	if err != nil {
+		return nil, err
+	}
+	if hasType {
+		variant, err := dec.ReadUint32(bin.LE)
+		if err != nil {
+			return nil, err
+		}
+		// Fee, Rent, Staking and Voting come after Unspecified in the protobuf enum.
+		if variant > 3 {
+			return nil, fmt.Errorf("unknown reward type %d", variant)
+		}
+		reward.RewardType = confirmed_block.RewardType(variant + 1)
+	}
+	if !dec.HasRemaining() {
+		return reward, nil
+	}
+	hasCommission, err := dec.ReadBool()
+	if err != nil {
+		return nil, err
+	}
+	if hasCommission {
+		commission, err := dec.ReadUint8()
+		if err != nil {
+			return nil, err
+		}
+		reward.Commission = strconv.Itoa(int(commission))
+	}
+	return reward, nil
+}
@@ -0,0 +1,184 @@
+package rocksdbsource
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+)
+
+// Layout of a data shred: the common header (signature, variant, slot, index, version and
+// FEC set index), then the data header (parent offset, flags and size), then the data.
+const (
+	shredVariantOffset      = 64
+	shredSlotOffset         = 65
+	shredIndexOffset        = 73
+	shredParentOffsetOffset = 83
+	shredFlagsOffset        = 85
+	shredSizeOffset         = 86
+	shredHeadersSize        = 88
+)
+
+// Shred variants: the legacy data shred, and the high nibble of the merkle data shreds
+// (the low nibble is the size of the merkle proof).
+const (
+	variantLegacyData             = 0xa5
+	variantMerkleData             = 0x80
+	variantMerkleDataChained      = 0x90
+	variantMerkleDataChainedResig = 0xb0
+)
+
+// Flags of a data shred.
+const (
+	// flagDataComplete ends a batch of entries.
+	flagDataComplete = 0x40
+	// flagLastInSlot ends the slot (and its last batch).
+	flagLastInSlot = 0xc0
+)
+
+// shred is a data shred.
+type shred struct {
+	slot   uint64
+	index  uint32
+	parent uint64
+	flags  byte
+	data   []byte
+}
+
+func parseShred(payload []byte) (*shred, error) {
+	if len(payload) < shredHeadersSize {
+		return nil, fmt.Errorf("shred of %d bytes", len(payload))
+	}
+	switch variant := payload[shredVariantOffset]; {
+	case variant == variantLegacyData:
+	case variant&0xf0 == variantMerkleData, variant&0xf0 == variantMerkleDataChained, variant&0xf0 == variantMerkleDataChainedResig:
+	default:
+		return nil, fmt.Errorf("not a data shred (variant %#x)", variant)
+	}
+	s := &shred{
+		slot:  binary.LittleEndian.Uint64(payload[shredSlotOffset:]),
+		index: binary.LittleEndian.Uint32(payload[shredIndexOffset:]),
+		flags: payload[shredFlagsOffset],
+	}
+	parentOffset := uint64(binary.LittleEndian.Uint16(payload[shredParentOffsetOffset:]))
+	if parentOffset > s.slot || (parentOffset == 0 && s.slot != 0) {
+		return nil, fmt.Errorf("invalid parent offset %d of slot %d", parentOffset, s.slot)
+	}
+	s.parent = s.slot - parentOffset
+	// the size includes the headers; merkle shreds have their proof after the data.
+	size := int(binary.LittleEndian.Uint16(payload[shredSizeOffset:]))
+	if size < shredHeadersSize || size > len(payload) {
+		return nil, fmt.Errorf("invalid data size %d of a shred of %d bytes", size, len(payload))
+	}
+	s.data = payload[shredHeadersSize:size]
+	return s, nil
+}
+
+// deshredder rebuilds the entries of a slot from its data shreds, given in index order.
+type deshredder struct {
+	slot      uint64
+	parent    uint64
+	entries   []carwriter.Entry
+	shredding []carwriter.Shredding
+	// batch is the data of the shreds since the end of the last batch.
+	batch []byte
+	next  uint32
+	done  bool
+}
+
+func (d *deshredder) add(s *shred) error {
+	switch {
+	case s.slot != d.slot:
+		return fmt.Errorf("shred %d is of slot %d", s.index, s.slot)
+	case d.done:
+		return fmt.Errorf("shred %d is after the last shred of the slot", s.index)
+	case s.index != d.next:
+		return fmt.Errorf("missing shred %d", d.next)
+	case s.index > 0 && s.parent != d.parent:
+		return fmt.Errorf("shred %d has parent %d instead of %d", s.index, s.parent, d.parent)
+	}
+	d.parent = s.parent
+	d.next++
+	d.batch = append(d.batch, s.data...)
+	if s.flags&flagDataComplete == 0 {
+		return nil
+	}
+	entries, err := decodeEntries(d.batch)
+	if err != nil {
+		return fmt.Errorf("entries ending at shred %d: %w", s.index, err)
+	}
+	// as radiance does, the shred index is only known for the last entry of a batch.
+	for i := range entries {
+		shredEndIdx := -1
+		if i == len(entries)-1 {
+			shredEndIdx = int(s.index)
+		}
+		d.shredding = append(d.shredding, carwriter.Shredding{EntryEndIdx: len(d.entries) + i, ShredEndIdx: shredEndIdx})
+	}
+	d.entries = append(d.entries, entries...)
+	// the transactions point into the batch.
+	d.batch = nil
+	d.done = s.flags&flagLastInSlot == flagLastInSlot
+	return nil
+}
+
+// complete tells whether all the shreds of the slot were added.
+func (d *deshredder) complete() bool {
+	return d.done
+}
+
+// decodeEntries decodes a batch of entries (a bincode Vec<Entry>); the transactions are
+// kept in their wire encoding.
+func decodeEntries(data []byte) ([]carwriter.Entry, error) {
+	dec := bin.NewBinDecoder(data)
+	numEntries, err := dec.ReadUint64(bin.LE)
+	if err != nil {
+		return nil, err
+	}
+	// an entry takes at least 48 bytes.
+	if numEntries > uint64(len(data))/48 {
+		return nil, fmt.Errorf("%d entries in %d bytes", numEntries, len(data))
+	}
+	entries := make([]carwriter.Entry, numEntries)
+	for i := range entries {
+		entry := &entries[i]
+		if entry.NumHashes, err = dec.ReadUint64(bin.LE); err != nil {
+			return nil, err
+		}
+		hash, err := dec.ReadNBytes(32)
+		if err != nil {
+			return nil, err
+		}
+		copy(entry.Hash[:], hash)
+		numTransactions, err := dec.ReadUint64(bin.LE)
+		if err != nil {
+			return nil, err
+		}
+		if numTransactions > uint64(dec.Remaining()) {
+			return nil, fmt.Errorf("%d transactions in %d bytes", numTransactions, dec.Remaining())
+		}
+		for j := uint64(0); j < numTransactions; j++ {
+			start := dec.Position()
+			if _, err := solana.TransactionFromDecoder(dec); err != nil {
+				return nil, fmt.Errorf("entry %d: transaction %d: %w", i, j, err)
+			}
+			entry.Transactions = append(entry.Transactions, carwriter.Transaction{Data: data[start:dec.Position()]})
+		}
+	}
+	return entries, nil
+}
+
+// firstSignature returns the signature of a wire-encoded transaction.
+func firstSignature(data []byte) (solana.Signature, error) {
+	numSignatures, n, err := bin.DecodeCompactU16(data)
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	if numSignatures == 0 || len(data) < n+64 {
+		return solana.Signature{}, errors.New("transaction without signature")
+	}
+	return solana.SignatureFromBytes(data[n : n+64]), nil
+}
@@ -0,0 +1,304 @@
+// Package rocksdbsource reads blocks from Solana ledgers (the rocksdb databases of the
+// validators, or archives of them) as a carwriter.BlockSource.
+//
+// The blocks are the rooted slots of the "root" column family. Their entries are deshredded
+// from the "data_shred" column family, and their transaction metas, rewards, block time and
+// block height come from the "transaction_status", "rewards", "blocktime" and
+// "block_height" column families.
+package rocksdbsource
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/rocksdb"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"k8s.io/klog/v2"
+)
+
+// Column families of a ledger.
+const (
+	cfRoot              = "root"
+	cfDataShred         = "data_shred"
+	cfTransactionStatus = "transaction_status"
+	cfRewards           = "rewards"
+	cfBlockTime         = "blocktime"
+	cfBlockHeight       = "block_height"
+)
+
+type Config struct {
+	// Ledgers are the ledgers to read: ledger or rocksdb directories, uncompressed tar
+	// archives (read in place), or compressed tar archives (zstd, gzip or bzip2), which are
+	// extracted first. A slot is read from the first ledger that has all its shreds.
+	Ledgers []string
+	// ExtractDir is where the compressed archives are extracted; an extraction is reused by
+	// the next runs. By default, they are extracted to a temporary directory removed by Close.
+	ExtractDir string
+}
+
+// Source reads blocks from ledgers.
+type Source struct {
+	ledgers []*ledger
+	// tempDir is the default extraction directory.
+	tempDir string
+}
+
+var _ carwriter.BlockSource = (*Source)(nil)
+
+type ledger struct {
+	path string
+	db   *rocksdb.DB
+}
+
+// New opens the ledgers, extracting the compressed archives.
+func New(config Config) (*Source, error) {
+	if len(config.Ledgers) == 0 {
+		return nil, errors.New("no ledger to read")
+	}
+	s := &Source{}
+	for _, path := range config.Ledgers {
+		db, err := rocksdb.Open(path)
+		if errors.Is(err, rocksdb.ErrCompressedArchive) {
+			dir := config.ExtractDir
+			if dir == "" {
+				if s.tempDir == "" {
+					if s.tempDir, err = os.MkdirTemp("", "ledgers-"); err != nil {
+						s.Close()
+						return nil, err
+					}
+				}
+				dir = s.tempDir
+			}
+			var extracted string
+			extracted, err = extractArchive(path, dir)
+			if err == nil {
+				db, err = rocksdb.Open(extracted)
+			}
+		}
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.ledgers = append(s.ledgers, &ledger{path: path, db: db})
+		for _, cf := range []string{cfRoot, cfDataShred} {
+			if !db.HasColumnFamily(cf) {
+				s.Close()
+				return nil, fmt.Errorf("%s is not a ledger: no %s column family", path, cf)
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *Source) Close() error {
+	var errs []error
+	for _, l := range s.ledgers {
+		errs = append(errs, l.db.Close())
+	}
+	if s.tempDir != "" {
+		errs = append(errs, os.RemoveAll(s.tempDir))
+	}
+	return errors.Join(errs...)
+}
+
+func slotKey(slot uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, slot)
+}
+
+func (s *Source) Blocks(ctx context.Context, from, to uint64, fn func(*carwriter.Block) error) error {
+	slots, err := s.rootedSlots(from, to)
+	if err != nil {
+		return err
+	}
+	shreds := make([]*rocksdb.Iterator, len(s.ledgers))
+	for i, l := range s.ledgers {
+		if shreds[i], err = l.db.NewIterator(cfDataShred); err != nil {
+			return err
+		}
+		defer shreds[i].Close()
+	}
+	var previous *carwriter.Block
+	for _, slot := range slots {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var block *carwriter.Block
+		for i, l := range s.ledgers {
+			block, err = l.block(shreds[i], slot)
+			if err != nil {
+				return fmt.Errorf("slot %d of %s: %w", slot, l.path, err)
+			}
+			if block != nil {
+				break
+			}
+		}
+		if block == nil {
+			return fmt.Errorf("slot %d is rooted, but no ledger has all its shreds", slot)
+		}
+		if previous != nil && block.ParentSlot != previous.Slot {
+			return fmt.Errorf("the parent of slot %d is %d, not the previous rooted slot %d", slot, block.ParentSlot, previous.Slot)
+		}
+		if err := fn(block); err != nil {
+			return err
+		}
+		previous = block
+	}
+	return nil
+}
+
+// rootedSlots returns the rooted slots in [from, to] of all the ledgers, sorted.
+func (s *Source) rootedSlots(from, to uint64) ([]uint64, error) {
+	seen := make(map[uint64]bool)
+	for _, l := range s.ledgers {
+		it, err := l.db.NewIterator(cfRoot)
+		if err != nil {
+			return nil, err
+		}
+		for it.Seek(slotKey(from)); it.Valid(); it.Next() {
+			if len(it.Key()) != 8 {
+				it.Close()
+				return nil, fmt.Errorf("invalid key %x in the roots of %s", it.Key(), l.path)
+			}
+			slot := binary.BigEndian.Uint64(it.Key())
+			if slot > to {
+				break
+			}
+			seen[slot] = true
+		}
+		err = it.Err()
+		it.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the roots of %s: %w", l.path, err)
+		}
+	}
+	slots := make([]uint64, 0, len(seen))
+	for slot := range seen {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	return slots, nil
+}
+
+// block returns the block of a slot, or nil if the ledger doesn't have all its shreds.
+func (l *ledger) block(shreds *rocksdb.Iterator, slot uint64) (*carwriter.Block, error) {
+	d := &deshredder{slot: slot}
+	prefix := slotKey(slot)
+	for shreds.Seek(prefix); shreds.Valid() && bytes.HasPrefix(shreds.Key(), prefix) && !d.complete(); shreds.Next() {
+		if len(shreds.Key()) != 16 {
+			return nil, fmt.Errorf("invalid shred key %x", shreds.Key())
+		}
+		if binary.BigEndian.Uint64(shreds.Key()[8:]) != uint64(d.next) {
+			// a missing shred.
+			klog.V(3).Infof("Slot %d of %s is missing shred %d", slot, l.path, d.next)
+			return nil, nil
+		}
+		s, err := parseShred(shreds.Value())
+		if err != nil {
+			return nil, fmt.Errorf("shred %d: %w", d.next, err)
+		}
+		if err := d.add(s); err != nil {
+			return nil, err
+		}
+	}
+	if err := shreds.Err(); err != nil {
+		return nil, err
+	}
+	if !d.complete() {
+		return nil, nil
+	}
+	block := &carwriter.Block{
+		Slot:       slot,
+		ParentSlot: d.parent,
+		Entries:    d.entries,
+		Shredding:  d.shredding,
+	}
+	for i := range block.Entries {
+		for j := range block.Entries[i].Transactions {
+			tx := &block.Entries[i].Transactions[j]
+			meta, err := l.transactionStatus(tx.Data, slot)
+			if err != nil {
+				return nil, fmt.Errorf("meta of transaction %d of entry %d: %w", j, i, err)
+			}
+			if meta != nil {
+				if tx.Metadata, err = tooling.CompressZstd(meta); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	rewards, err := l.get(cfRewards, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("rewards: %w", err)
+	}
+	if rewards != nil {
+		raw, err := rewardsProtobuf(rewards)
+		if err != nil {
+			return nil, err
+		}
+		if block.Rewards, err = tooling.CompressZstd(raw); err != nil {
+			return nil, err
+		}
+	}
+	blockTime, err := l.get(cfBlockTime, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("block time: %w", err)
+	}
+	if blockTime != nil {
+		if len(blockTime) != 8 {
+			return nil, fmt.Errorf("invalid block time %x", blockTime)
+		}
+		block.BlockTime = int64(binary.LittleEndian.Uint64(blockTime))
+	}
+	height, err := l.get(cfBlockHeight, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("block height: %w", err)
+	}
+	if height != nil {
+		if len(height) != 8 {
+			return nil, fmt.Errorf("invalid block height %x", height)
+		}
+		h := binary.LittleEndian.Uint64(height)
+		block.BlockHeight = &h
+	}
+	return block, nil
+}
+
+// get returns the value of a key, or nil if the key or the column family doesn't exist.
+func (l *ledger) get(cf string, key []byte) ([]byte, error) {
+	if !l.db.HasColumnFamily(cf) {
+		return nil, nil
+	}
+	value, err := l.db.Get(cf, key)
+	if errors.Is(err, rocksdb.ErrNotFound) {
+		return nil, nil
+	}
+	return value, err
+}
+
+// transactionStatus returns the meta of a transaction, as stored: a protobuf, or bincode in
+// older ledgers. The key is the signature and the slot, prefixed by the primary index (0 or
+// 1) in the ledgers of validators before 1.18.
+func (l *ledger) transactionStatus(tx []byte, slot uint64) ([]byte, error) {
+	signature, err := firstSignature(tx)
+	if err != nil {
+		return nil, err
+	}
+	key := binary.BigEndian.AppendUint64(append([]byte(nil), signature[:]...), slot)
+	meta, err := l.get(cfTransactionStatus, key)
+	if meta != nil || err != nil {
+		return meta, err
+	}
+	for index := uint64(0); index < 2; index++ {
+		meta, err := l.get(cfTransactionStatus, append(binary.BigEndian.AppendUint64(nil, index), key...))
+		if meta != nil || err != nil {
+			return meta, err
+		}
+	}
+	return nil, nil
+}
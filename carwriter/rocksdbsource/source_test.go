@@ -0,0 +1,382 @@
+package rocksdbsource
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/rocksdb/rocksdbtest"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// shredDataSize is the size of the data of the test shreds, so that the entries of a batch
+// span several shreds.
+const shredDataSize = 300
+
+// ledgerBlock is a block of a test ledger.
+type ledgerBlock struct {
+	*carwriter.Block
+	rooted bool
+	// batchSize is the number of entries per batch of shreds.
+	batchSize int
+	merkle    bool
+	// missingShred, if positive, is a shred left out of the ledger.
+	missingShred int
+	// legacyKeys stores the transaction metas with the keys of the ledgers before 1.18.
+	legacyKeys bool
+	// bincodeRewards stores the rewards with bincode.
+	bincodeRewards bool
+}
+
+// shredBlock returns the data shreds of a block, and the shredding the source must report.
+func shredBlock(t *testing.T, b ledgerBlock) ([][]byte, []carwriter.Shredding) {
+	var shreds [][]byte
+	var shredding []carwriter.Shredding
+	for start := 0; start < len(b.Entries); start += b.batchSize {
+		batch := b.Entries[start:min(start+b.batchSize, len(b.Entries))]
+		data := binary.LittleEndian.AppendUint64(nil, uint64(len(batch)))
+		for _, entry := range batch {
+			data = binary.LittleEndian.AppendUint64(data, entry.NumHashes)
+			data = append(data, entry.Hash[:]...)
+			data = binary.LittleEndian.AppendUint64(data, uint64(len(entry.Transactions)))
+			for _, tx := range entry.Transactions {
+				data = append(data, tx.Data...)
+			}
+		}
+		for len(data) > 0 {
+			chunk := data[:min(shredDataSize, len(data))]
+			data = data[len(chunk):]
+			var flags byte
+			if len(data) == 0 {
+				flags = flagDataComplete
+				if start+b.batchSize >= len(b.Entries) {
+					flags = flagLastInSlot
+				}
+			}
+			shred := make([]byte, shredHeadersSize, shredHeadersSize+len(chunk)+32)
+			shred[shredVariantOffset] = variantLegacyData
+			if b.merkle {
+				shred[shredVariantOffset] = variantMerkleDataChained | 1
+			}
+			binary.LittleEndian.PutUint64(shred[shredSlotOffset:], b.Slot)
+			binary.LittleEndian.PutUint32(shred[shredIndexOffset:], uint32(len(shreds)))
+			binary.LittleEndian.PutUint16(shred[shredParentOffsetOffset:], uint16(b.Slot-b.ParentSlot))
+			shred[shredFlagsOffset] = flags
+			binary.LittleEndian.PutUint16(shred[shredSizeOffset:], uint16(shredHeadersSize+len(chunk)))
+			shred = append(shred, chunk...)
+			if b.merkle {
+				// the merkle proof.
+				shred = append(shred, make([]byte, 32)...)
+			}
+			shreds = append(shreds, shred)
+		}
+		for i := range batch {
+			shredding = append(shredding, carwriter.Shredding{EntryEndIdx: start + i, ShredEndIdx: -1})
+		}
+		shredding[len(shredding)-1].ShredEndIdx = len(shreds) - 1
+	}
+	return shreds, shredding
+}
+
+func encodeBincodeRewards(t *testing.T, compressed []byte) []byte {
+	raw, err := tooling.DecompressZstd(compressed)
+	require.NoError(t, err)
+	var rewards confirmed_block.Rewards
+	require.NoError(t, proto.Unmarshal(raw, &rewards))
+	data := binary.LittleEndian.AppendUint64(nil, uint64(len(rewards.Rewards)))
+	for _, reward := range rewards.Rewards {
+		data = binary.LittleEndian.AppendUint64(data, uint64(len(reward.Pubkey)))
+		data = append(data, reward.Pubkey...)
+		data = binary.LittleEndian.AppendUint64(data, uint64(reward.Lamports))
+		data = binary.LittleEndian.AppendUint64(data, reward.PostBalance)
+		data = append(data, 1)
+		data = binary.LittleEndian.AppendUint32(data, uint32(reward.RewardType-1))
+		require.Equal(t, "10", reward.Commission)
+		data = append(data, 1, 10)
+	}
+	return data
+}
+
+// writeLedger writes a ledger with the blocks to dir/rocksdb, and returns the shredding of
+// the blocks.
+func writeLedger(t *testing.T, dir string, blocks []ledgerBlock) map[uint64][]carwriter.Shredding {
+	b, err := rocksdbtest.NewBuilder(filepath.Join(dir, "rocksdb"), cfRoot, cfDataShred, cfTransactionStatus, cfRewards, cfBlockTime, cfBlockHeight)
+	require.NoError(t, err)
+	entries := make(map[string][]rocksdbtest.Entry)
+	add := func(cf string, key, value []byte) {
+		entries[cf] = append(entries[cf], rocksdbtest.Entry{Key: key, Seq: uint64(len(entries[cf]) + 1), Type: rocksdbtest.TypeValue, Value: value})
+	}
+	shredding := make(map[uint64][]carwriter.Shredding)
+	for _, block := range blocks {
+		key := slotKey(block.Slot)
+		if block.rooted {
+			add(cfRoot, key, []byte{1})
+		}
+		shreds, sh := shredBlock(t, block)
+		shredding[block.Slot] = sh
+		for i, shred := range shreds {
+			if block.missingShred > 0 && i == block.missingShred {
+				continue
+			}
+			add(cfDataShred, binary.BigEndian.AppendUint64(slotKey(block.Slot), uint64(i)), shred)
+		}
+		for _, entry := range block.Entries {
+			for _, tx := range entry.Transactions {
+				if tx.Metadata == nil {
+					continue
+				}
+				meta, err := tooling.DecompressZstd(tx.Metadata)
+				require.NoError(t, err)
+				signature, err := firstSignature(tx.Data)
+				require.NoError(t, err)
+				txKey := binary.BigEndian.AppendUint64(append([]byte(nil), signature[:]...), block.Slot)
+				if block.legacyKeys {
+					txKey = append(binary.BigEndian.AppendUint64(nil, 1), txKey...)
+				}
+				add(cfTransactionStatus, txKey, meta)
+			}
+		}
+		if block.Rewards != nil {
+			rewards, err := tooling.DecompressZstd(block.Rewards)
+			require.NoError(t, err)
+			if block.bincodeRewards {
+				rewards = encodeBincodeRewards(t, block.Rewards)
+			}
+			add(cfRewards, key, rewards)
+		}
+		add(cfBlockTime, key, binary.LittleEndian.AppendUint64(nil, uint64(block.BlockTime)))
+		add(cfBlockHeight, key, binary.LittleEndian.AppendUint64(nil, *block.BlockHeight))
+	}
+	for cf, cfEntries := range entries {
+		_, err := b.AddTable(cf, 1, cfEntries, rocksdbtest.DefaultTableOptions)
+		require.NoError(t, err)
+	}
+	require.NoError(t, b.Finish())
+	return shredding
+}
+
+func readAll(t *testing.T, src *Source, from, to uint64) ([]*carwriter.Block, error) {
+	var blocks []*carwriter.Block
+	err := src.Blocks(context.Background(), from, to, func(block *carwriter.Block) error {
+		blocks = append(blocks, block)
+		return nil
+	})
+	return blocks, err
+}
+
+// requireBlocks checks that the blocks read are the ones of the fixture.
+func requireBlocks(t *testing.T, want []*carwriter.Block, shredding map[uint64][]carwriter.Shredding, got []*carwriter.Block) {
+	require.Len(t, got, len(want))
+	for i, block := range got {
+		require.Equal(t, shredding[block.Slot], block.Shredding, "slot %d", block.Slot)
+		withoutShredding := *block
+		withoutShredding.Shredding = nil
+		require.Equal(t, want[i], &withoutShredding, "slot %d", block.Slot)
+	}
+}
+
+func fixtureBlocks(t *testing.T) []*carwriter.Block {
+	fixture, err := carfixture.Generate(filepath.Join(t.TempDir(), "epoch-0.car"), carfixture.DefaultBlocks())
+	require.NoError(t, err)
+	return fixture.Blocks
+}
+
+func TestSourceBlocks(t *testing.T) {
+	blocks := fixtureBlocks(t)
+	dir := t.TempDir()
+	ledger := []ledgerBlock{
+		{Block: blocks[0], rooted: true, batchSize: 2, bincodeRewards: true},
+		{Block: blocks[1], rooted: true, batchSize: 1, merkle: true, legacyKeys: true},
+		{Block: blocks[2], rooted: true, batchSize: 1},
+		// a fork that was not rooted.
+		{Block: &carwriter.Block{Slot: 3, ParentSlot: 2, BlockHeight: new(uint64), Entries: blocks[2].Entries}, batchSize: 1},
+		{Block: blocks[3], rooted: true, batchSize: 1, merkle: true},
+		{Block: blocks[4], rooted: true, batchSize: 1},
+	}
+	shredding := writeLedger(t, dir, ledger)
+	src, err := New(Config{Ledgers: []string{dir}})
+	require.NoError(t, err)
+	defer src.Close()
+
+	got, err := readAll(t, src, 0, 431999)
+	require.NoError(t, err)
+	requireBlocks(t, blocks, shredding, got)
+
+	got, err = readAll(t, src, 2, 4)
+	require.NoError(t, err)
+	requireBlocks(t, blocks[2:4], shredding, got)
+
+	// The blocks make a valid CAR.
+	out := filepath.Join(t.TempDir(), "epoch-0.car")
+	w, err := carwriter.Create(out, 0, 0)
+	require.NoError(t, err)
+	got, err = readAll(t, src, 0, 431999)
+	require.NoError(t, err)
+	for _, b := range got {
+		_, err := w.WriteBlock(b)
+		require.NoError(t, err)
+	}
+	_, err = w.Finish()
+	require.NoError(t, err)
+	_, err = carwriter.ValidateCanonical(out)
+	require.NoError(t, err)
+}
+
+func TestSourceErrors(t *testing.T) {
+	blocks := fixtureBlocks(t)
+
+	// slot 2 is not rooted: slot 4 isn't a child of the previous rooted slot.
+	dir := t.TempDir()
+	writeLedger(t, dir, []ledgerBlock{
+		{Block: blocks[1], rooted: true, batchSize: 1},
+		{Block: blocks[2], batchSize: 1},
+		{Block: blocks[3], rooted: true, batchSize: 1},
+	})
+	src, err := New(Config{Ledgers: []string{dir}})
+	require.NoError(t, err)
+	_, err = readAll(t, src, 0, 10)
+	require.ErrorContains(t, err, "the parent of slot 4 is 2, not the previous rooted slot 1")
+	require.NoError(t, src.Close())
+
+	// a missing shred.
+	dir = t.TempDir()
+	writeLedger(t, dir, []ledgerBlock{{Block: blocks[0], rooted: true, batchSize: 1, missingShred: 1}})
+	src, err = New(Config{Ledgers: []string{dir}})
+	require.NoError(t, err)
+	_, err = readAll(t, src, 0, 10)
+	require.ErrorContains(t, err, "slot 0 is rooted, but no ledger has all its shreds")
+	require.NoError(t, src.Close())
+
+	_, err = New(Config{Ledgers: []string{t.TempDir()}})
+	require.ErrorContains(t, err, "not a rocksdb database")
+}
+
+// writeTar writes the files of dir to a tar archive, compressed with zstd or not.
+func writeTar(t *testing.T, dir, archive string, compress bool) {
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+	defer f.Close()
+	var w io.Writer = f
+	if compress {
+		zw, err := zstd.NewWriter(f)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, zw.Close()) }()
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: filepath.ToSlash(name), Mode: 0o644, Size: int64(len(data))}); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+}
+
+func TestSourceArchives(t *testing.T) {
+	blocks := fixtureBlocks(t)
+	// the first ledger lacks a shred of slot 2, which comes from the second one.
+	first, second := t.TempDir(), t.TempDir()
+	shredding := writeLedger(t, first, []ledgerBlock{
+		{Block: blocks[0], rooted: true, batchSize: 1},
+		{Block: blocks[1], rooted: true, batchSize: 1},
+		{Block: blocks[2], rooted: true, batchSize: 1, missingShred: 1},
+	})
+	secondShredding := writeLedger(t, second, []ledgerBlock{
+		{Block: blocks[2], rooted: true, batchSize: 1},
+		{Block: blocks[3], rooted: true, batchSize: 1},
+		{Block: blocks[4], rooted: true, batchSize: 1},
+	})
+	for slot, sh := range secondShredding {
+		shredding[slot] = sh
+	}
+	archives := t.TempDir()
+	firstArchive := filepath.Join(archives, "first.tar")
+	writeTar(t, first, firstArchive, false)
+	secondArchive := filepath.Join(archives, "second.tar.zst")
+	writeTar(t, second, secondArchive, true)
+
+	extractDir := t.TempDir()
+	for run := 0; run < 2; run++ {
+		src, err := New(Config{Ledgers: []string{firstArchive, secondArchive}, ExtractDir: extractDir})
+		require.NoError(t, err)
+		got, err := readAll(t, src, 0, 431999)
+		require.NoError(t, err)
+		requireBlocks(t, blocks, shredding, got)
+		require.NoError(t, src.Close())
+		// the extraction is kept, and reused by the next run.
+		require.FileExists(t, filepath.Join(extractDir, "second", extractedMarker))
+		require.FileExists(t, filepath.Join(extractDir, "second", "rocksdb", "CURRENT"))
+	}
+
+	// by default, the archives are extracted to a temporary directory.
+	src, err := New(Config{Ledgers: []string{secondArchive}})
+	require.NoError(t, err)
+	tempDir := src.tempDir
+	require.DirExists(t, tempDir)
+	require.NoError(t, src.Close())
+	_, err = os.Stat(tempDir)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestDecodeBincodeRewards(t *testing.T) {
+	reward := func(fields ...[]byte) []byte {
+		data := binary.LittleEndian.AppendUint64(nil, 3)
+		data = append(data, "abc"...)
+		data = binary.LittleEndian.AppendUint64(data, uint64(42))
+		for _, field := range fields {
+			data = append(data, field...)
+		}
+		return data
+	}
+	postBalance := binary.LittleEndian.AppendUint64(nil, 100)
+	voting := binary.LittleEndian.AppendUint32([]byte{1}, 3)
+
+	// the fields after the lamports default when the data ends before them.
+	data := binary.LittleEndian.AppendUint64(nil, 2)
+	data = append(data, reward(postBalance, voting, []byte{0})...)
+	data = append(data, reward(postBalance)...)
+	rewards, err := decodeBincodeRewards(data)
+	require.NoError(t, err)
+	require.Len(t, rewards.Rewards, 2)
+	require.Equal(t, "abc", rewards.Rewards[0].Pubkey)
+	require.Equal(t, int64(42), rewards.Rewards[0].Lamports)
+	require.Equal(t, uint64(100), rewards.Rewards[0].PostBalance)
+	require.Equal(t, confirmed_block.RewardType_Voting, rewards.Rewards[0].RewardType)
+	require.Empty(t, rewards.Rewards[0].Commission)
+	require.Equal(t, uint64(100), rewards.Rewards[1].PostBalance)
+	require.Equal(t, confirmed_block.RewardType_Unspecified, rewards.Rewards[1].RewardType)
+
+	// a missing reward.
+	data = binary.LittleEndian.AppendUint64(nil, 2)
+	data = append(data, reward()...)
+	_, err = decodeBincodeRewards(data)
+	require.Error(t, err)
+
+	_, err = decodeBincodeRewards(binary.LittleEndian.AppendUint64(nil, 1<<40))
+	require.Error(t, err)
+}
@@ -0,0 +1,251 @@
+package carwriter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+)
+
+// BlockSource is where the blocks of an epoch come from: an existing CAR (CarSource), Solana
+// ledgers (rocksdbsource) or a warehouse Bigtable instance (bigtablesource).
+type BlockSource interface {
+	// Blocks calls fn for every block in the slot range [from, to] (inclusive),
+	// in ascending slot order; skipped slots are not reported.
+	Blocks(ctx context.Context, from, to uint64, fn func(*Block) error) error
+	Close() error
+}
+
+// CarSource reads blocks from an existing CAR file (a whole epoch or a split piece).
+type CarSource struct {
+	path string
+}
+
+var _ BlockSource = (*CarSource)(nil)
+
+func NewCarSource(path string) *CarSource {
+	return &CarSource{path: path}
+}
+
+func (s *CarSource) Close() error {
+	return nil
+}
+
+func (s *CarSource) Blocks(ctx context.Context, from, to uint64, fn func(*Block) error) error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	rd, err := carreader.New(file)
+	if err != nil {
+		return fmt.Errorf("failed to open CAR %q: %w", s.path, err)
+	}
+	// nodes written since the last block: they are the DAG of the next block.
+	pending := make(map[cid.Cid][]byte)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c, _, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if iplddecoders.Kind(data[1]) != iplddecoders.KindBlock {
+			pending[c] = data
+			continue
+		}
+		node, err := iplddecoders.DecodeBlock(data)
+		if err != nil {
+			return fmt.Errorf("failed to decode block %s: %w", c, err)
+		}
+		slot := uint64(node.Slot)
+		if slot > to {
+			return nil
+		}
+		if slot >= from {
+			block, err := blockFromNodes(node, pending)
+			if err != nil {
+				return fmt.Errorf("slot %d: %w", slot, err)
+			}
+			if err := fn(block); err != nil {
+				return err
+			}
+		}
+		clear(pending)
+	}
+}
+
+// BlockPosition is where a block is in a CAR file. The nodes of the DAG of a block are written
+// right before the block node, so the block and its DAG are the bytes [Offset, Offset+Length).
+type BlockPosition struct {
+	Slot   uint64
+	Cid    cid.Cid
+	Offset uint64
+	Length uint64
+}
+
+// Positions calls fn with the position of every block of the CAR, in file order,
+// without decoding the DAGs of the blocks.
+func (s *CarSource) Positions(ctx context.Context, fn func(BlockPosition) error) error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	rd, err := carreader.New(file)
+	if err != nil {
+		return fmt.Errorf("failed to open CAR %q: %w", s.path, err)
+	}
+	offset, err := rd.HeaderSize()
+	if err != nil {
+		return err
+	}
+	// start of the DAG of the next block.
+	start := offset
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c, sectionLen, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		offset += sectionLen
+		if iplddecoders.Kind(data[1]) != iplddecoders.KindBlock {
+			continue
+		}
+		node, err := iplddecoders.DecodeBlock(data)
+		if err != nil {
+			return fmt.Errorf("failed to decode block %s: %w", c, err)
+		}
+		if err := fn(BlockPosition{Slot: uint64(node.Slot), Cid: c, Offset: start, Length: offset - start}); err != nil {
+			return err
+		}
+		start = offset
+	}
+}
+
+// ReadBlock reads the block at a position returned by Positions.
+func (s *CarSource) ReadBlock(pos BlockPosition) (*Block, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	br := bufio.NewReader(io.NewSectionReader(file, int64(pos.Offset), int64(pos.Length)))
+	nodes := make(map[cid.Cid][]byte)
+	for {
+		c, _, data, err := carreader.ReadNodeInfoWithData(br)
+		if err != nil {
+			return nil, fmt.Errorf("slot %d: %w", pos.Slot, err)
+		}
+		if !c.Equals(pos.Cid) {
+			nodes[c] = data
+			continue
+		}
+		node, err := iplddecoders.DecodeBlock(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode block %s: %w", c, err)
+		}
+		block, err := blockFromNodes(node, nodes)
+		if err != nil {
+			return nil, fmt.Errorf("slot %d: %w", pos.Slot, err)
+		}
+		return block, nil
+	}
+}
+
+func blockFromNodes(node *ipldbindcode.Block, nodes map[cid.Cid][]byte) (*Block, error) {
+	get := func(link any) ([]byte, error) {
+		c := link.(cidlink.Link).Cid
+		data, ok := nodes[c]
+		if !ok {
+			return nil, fmt.Errorf("node %s not found before the block", c)
+		}
+		return data, nil
+	}
+	dataFrameGetter := func(_ context.Context, c cid.Cid) (*ipldbindcode.DataFrame, error) {
+		data, err := get(cidlink.Link{Cid: c})
+		if err != nil {
+			return nil, err
+		}
+		return iplddecoders.DecodeDataFrame(data)
+	}
+
+	block := &Block{
+		Slot:       uint64(node.Slot),
+		ParentSlot: uint64(node.Meta.Parent_slot),
+		BlockTime:  int64(node.Meta.Blocktime),
+		Shredding:  make([]Shredding, len(node.Shredding)),
+	}
+	if node.Meta.Block_height != nil && *node.Meta.Block_height != nil {
+		height := uint64(**node.Meta.Block_height)
+		block.BlockHeight = &height
+	}
+	for i, sh := range node.Shredding {
+		block.Shredding[i] = Shredding(sh)
+	}
+	for _, entryLink := range node.Entries {
+		raw, err := get(entryLink)
+		if err != nil {
+			return nil, err
+		}
+		entryNode, err := iplddecoders.DecodeEntry(raw)
+		if err != nil {
+			return nil, err
+		}
+		entry := Entry{NumHashes: uint64(entryNode.NumHashes)}
+		copy(entry.Hash[:], entryNode.Hash)
+		for _, txLink := range entryNode.Transactions {
+			raw, err := get(txLink)
+			if err != nil {
+				return nil, err
+			}
+			txNode, err := iplddecoders.DecodeTransaction(raw)
+			if err != nil {
+				return nil, err
+			}
+			data, err := tooling.LoadDataFromDataFrames(&txNode.Data, dataFrameGetter)
+			if err != nil {
+				return nil, fmt.Errorf("transaction data: %w", err)
+			}
+			metadata, err := tooling.LoadDataFromDataFrames(&txNode.Metadata, dataFrameGetter)
+			if err != nil {
+				return nil, fmt.Errorf("transaction metadata: %w", err)
+			}
+			entry.Transactions = append(entry.Transactions, Transaction{Data: data, Metadata: metadata})
+		}
+		block.Entries = append(block.Entries, entry)
+	}
+	if rewardsCid := node.Rewards.(cidlink.Link).Cid; !rewardsCid.Equals(DummyCID) {
+		raw, err := get(node.Rewards)
+		if err != nil {
+			return nil, err
+		}
+		rewardsNode, err := iplddecoders.DecodeRewards(raw)
+		if err != nil {
+			return nil, err
+		}
+		block.Rewards, err = tooling.LoadDataFromDataFrames(&rewardsNode.Data, dataFrameGetter)
+		if err != nil {
+			return nil, fmt.Errorf("rewards: %w", err)
+		}
+	}
+	return block, nil
+}
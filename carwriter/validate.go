@@ -0,0 +1,300 @@
+package carwriter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+)
+
+// ValidationStats summarizes a validated epoch CAR.
+type ValidationStats struct {
+	Root         cid.Cid
+	Epoch        uint64
+	Nodes        uint64
+	Blocks       uint64
+	Transactions uint64
+	Subsets      uint64
+	FirstSlot    uint64
+	LastSlot     uint64
+}
+
+// Validate checks that the CAR file is a complete epoch CAR in the layout written
+// by Writer: every node matches its CID (a dag-cbor, sha2-256 CIDv1) and decodes, every link points to a node
+// written before it in the expected place, slots are ascending and within the
+// epoch, and the root is the epoch node, which is the last node of the file.
+//
+// It streams the file and only keeps the nodes of the current block in memory.
+func Validate(path string) (*ValidationStats, error) {
+	return validate(path, false)
+}
+
+// ValidateCanonical is like Validate, and also checks that the CAR is in the canonical layout
+// (see the package documentation): the nodes of every block are exactly the ones Writer writes
+// for its content, in the same order.
+func ValidateCanonical(path string) (*ValidationStats, error) {
+	return validate(path, true)
+}
+
+func validate(path string, canonical bool) (*ValidationStats, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rd, err := carreader.New(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(rd.Header.Roots) != 1 {
+		return nil, fmt.Errorf("expected 1 root, got %d", len(rd.Header.Roots))
+	}
+	stats := &ValidationStats{Root: rd.Header.Roots[0]}
+
+	var (
+		// nodes since the last block, by kind.
+		pending = make(map[cid.Cid]iplddecoders.Kind)
+		// blocks since the last subset, and their slots.
+		subsetBlocks []cid.Cid
+		blockSlots   = make(map[cid.Cid]uint64)
+		subsets      []cid.Cid
+		lastSlot     *uint64
+		txSlots      []uint64
+		epochNode    *ipldbindcode.Epoch
+		epochCid     cid.Cid
+		// with canonical, the nodes since the last block, in order.
+		blockNodes    []cid.Cid
+		blockNodeData = make(map[cid.Cid][]byte)
+	)
+	requireKind := func(link datamodel.Link, kind iplddecoders.Kind) error {
+		c := link.(cidlink.Link).Cid
+		got, ok := pending[c]
+		if !ok {
+			return fmt.Errorf("linked %s node %s was not written before its parent", kind, c)
+		}
+		if got != kind {
+			return fmt.Errorf("linked node %s is a %s, expected a %s", c, got, kind)
+		}
+		return nil
+	}
+	requireFrames := func(frame ipldbindcode.DataFrame) error {
+		next, ok := frame.GetNext()
+		if !ok {
+			return nil
+		}
+		for _, link := range next {
+			if err := requireKind(link, iplddecoders.KindDataFrame); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for {
+		c, _, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if epochNode != nil {
+			return nil, fmt.Errorf("node %s found after the epoch node", c)
+		}
+		stats.Nodes++
+		if err := iplddecoders.CheckCid(c, data, iplddecoders.StrictHash); err != nil {
+			return nil, err
+		}
+		kind := iplddecoders.Kind(data[1])
+		if canonical && kind != iplddecoders.KindSubset && kind != iplddecoders.KindEpoch {
+			blockNodes = append(blockNodes, c)
+			blockNodeData[c] = data
+		}
+		switch kind {
+		case iplddecoders.KindTransaction:
+			tx, err := iplddecoders.DecodeTransaction(data)
+			if err != nil {
+				return nil, fmt.Errorf("node %s: %w", c, err)
+			}
+			if err := requireFrames(tx.Data); err != nil {
+				return nil, err
+			}
+			if err := requireFrames(tx.Metadata); err != nil {
+				return nil, err
+			}
+			txSlots = append(txSlots, uint64(tx.Slot))
+			stats.Transactions++
+		case iplddecoders.KindEntry:
+			entry, err := iplddecoders.DecodeEntry(data)
+			if err != nil {
+				return nil, fmt.Errorf("node %s: %w", c, err)
+			}
+			for _, link := range entry.Transactions {
+				if err := requireKind(link, iplddecoders.KindTransaction); err != nil {
+					return nil, err
+				}
+			}
+		case iplddecoders.KindRewards:
+			rewards, err := iplddecoders.DecodeRewards(data)
+			if err != nil {
+				return nil, fmt.Errorf("node %s: %w", c, err)
+			}
+			if err := requireFrames(rewards.Data); err != nil {
+				return nil, err
+			}
+		case iplddecoders.KindDataFrame:
+			if _, err := iplddecoders.DecodeDataFrame(data); err != nil {
+				return nil, fmt.Errorf("node %s: %w", c, err)
+			}
+		case iplddecoders.KindBlock:
+			block, err := iplddecoders.DecodeBlock(data)
+			if err != nil {
+				return nil, fmt.Errorf("node %s: %w", c, err)
+			}
+			slot := uint64(block.Slot)
+			if lastSlot != nil && slot <= *lastSlot {
+				return nil, fmt.Errorf("block %d is not after the previous block %d", slot, *lastSlot)
+			}
+			for _, link := range block.Entries {
+				if err := requireKind(link, iplddecoders.KindEntry); err != nil {
+					return nil, fmt.Errorf("block %d: %w", slot, err)
+				}
+			}
+			if !block.Rewards.(cidlink.Link).Cid.Equals(DummyCID) {
+				if err := requireKind(block.Rewards, iplddecoders.KindRewards); err != nil {
+					return nil, fmt.Errorf("block %d: %w", slot, err)
+				}
+			}
+			for _, txSlot := range txSlots {
+				if txSlot != slot {
+					return nil, fmt.Errorf("block %d contains a transaction of slot %d", slot, txSlot)
+				}
+			}
+			if canonical {
+				if err := checkCanonicalBlock(block, blockNodes, blockNodeData); err != nil {
+					return nil, fmt.Errorf("block %d is not canonical: %w", slot, err)
+				}
+				blockNodes = blockNodes[:0]
+				clear(blockNodeData)
+			}
+			if lastSlot == nil {
+				stats.FirstSlot = slot
+			}
+			lastSlot = &slot
+			stats.LastSlot = slot
+			stats.Blocks++
+			txSlots = txSlots[:0]
+			clear(pending)
+			subsetBlocks = append(subsetBlocks, c)
+			blockSlots[c] = slot
+			continue
+		case iplddecoders.KindSubset:
+			subset, err := iplddecoders.DecodeSubset(data)
+			if err != nil {
+				return nil, fmt.Errorf("node %s: %w", c, err)
+			}
+			if len(pending) > 0 {
+				return nil, fmt.Errorf("subset %s: %d nodes are not linked by any block", c, len(pending))
+			}
+			if err := requireLinks(subset.Blocks, subsetBlocks); err != nil {
+				return nil, fmt.Errorf("subset %s: %w", c, err)
+			}
+			if len(subsetBlocks) == 0 ||
+				uint64(subset.First) != blockSlots[subsetBlocks[0]] ||
+				uint64(subset.Last) != blockSlots[subsetBlocks[len(subsetBlocks)-1]] {
+				return nil, fmt.Errorf("subset %s: slot range %d-%d does not match its blocks", c, subset.First, subset.Last)
+			}
+			subsets = append(subsets, c)
+			subsetBlocks = subsetBlocks[:0]
+			clear(blockSlots)
+			stats.Subsets++
+			continue
+		case iplddecoders.KindEpoch:
+			epochNode, err = iplddecoders.DecodeEpoch(data)
+			if err != nil {
+				return nil, fmt.Errorf("node %s: %w", c, err)
+			}
+			if len(subsetBlocks) > 0 {
+				return nil, fmt.Errorf("%d blocks are not linked by any subset", len(subsetBlocks))
+			}
+			if err := requireLinks(epochNode.Subsets, subsets); err != nil {
+				return nil, fmt.Errorf("epoch node: %w", err)
+			}
+			epochCid = c
+			continue
+		default:
+			return nil, fmt.Errorf("node %s: unknown kind %d", c, kind)
+		}
+		pending[c] = kind
+	}
+
+	if epochNode == nil {
+		return nil, errors.New("the CAR has no epoch node")
+	}
+	if !epochCid.Equals(stats.Root) {
+		return nil, fmt.Errorf("the root %s is not the epoch node %s", stats.Root, epochCid)
+	}
+	stats.Epoch = uint64(epochNode.Epoch)
+	if stats.Blocks > 0 {
+		first, last := slottools.CalcEpochLimits(stats.Epoch)
+		if stats.FirstSlot < first || stats.LastSlot > last {
+			return nil, fmt.Errorf("blocks %d-%d are not all in epoch %d", stats.FirstSlot, stats.LastSlot, stats.Epoch)
+		}
+	}
+	return stats, nil
+}
+
+// checkCanonicalBlock checks that the nodes of the block (the block node last) are the ones
+// Writer writes for its content.
+func checkCanonicalBlock(block *ipldbindcode.Block, nodes []cid.Cid, data map[cid.Cid][]byte) error {
+	content, err := blockFromNodes(block, data)
+	if err != nil {
+		return err
+	}
+	var want []cid.Cid
+	w := &Writer{
+		bw:               bufio.NewWriter(io.Discard),
+		firstSlot:        content.Slot,
+		lastSlot:         content.Slot,
+		maxSubsetBlocks:  math.MaxInt,
+		maxDataFrameSize: MaxDataFrameSize,
+		written:          &want,
+	}
+	if _, err := w.WriteBlock(content); err != nil {
+		return err
+	}
+	for i := range want {
+		if i >= len(nodes) {
+			return fmt.Errorf("%d nodes, expected %d", len(nodes), len(want))
+		}
+		if !nodes[i].Equals(want[i]) {
+			return fmt.Errorf("node %d is %s (a %s), expected %s", i, nodes[i], iplddecoders.Kind(data[nodes[i]][1]), want[i])
+		}
+	}
+	if len(nodes) != len(want) {
+		return fmt.Errorf("%d nodes, expected %d", len(nodes), len(want))
+	}
+	return nil
+}
+
+func requireLinks(links ipldbindcode.List__Link, want []cid.Cid) error {
+	if len(links) != len(want) {
+		return fmt.Errorf("links %d nodes, expected %d", len(links), len(want))
+	}
+	for i, link := range links {
+		if !link.(cidlink.Link).Cid.Equals(want[i]) {
+			return fmt.Errorf("link %d is %s, expected %s", i, link, want[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,519 @@
+// Package carwriter writes the old-faithful epoch CAR layout from a stream of blocks.
+//
+// The nodes of a block (transactions, entries, rewards, data frames) are written
+// before the block node; a subset node is written after the blocks it links to,
+// and the epoch node is the last node of the CAR. The root of the CAR is the epoch
+// node; since it is only known at the end, the header is written with a placeholder
+// root of the same size and rewritten when the CAR is finished.
+//
+// # Canonical layout
+//
+// The CARs written by Writer are canonical: two of them with the same blocks are
+// byte for byte the same. Within a block, the nodes are written in this order:
+//
+//  1. for each entry, in order: for each of its transactions, in order, the data
+//     frames of the transaction data after the first one, then those of the
+//     metadata, then the transaction node; then the entry node;
+//  2. the data frames of the rewards after the first one, then the rewards node
+//     (if the block has rewards);
+//  3. the block node.
+//
+// Every node is a dag-cbor, sha2-256 CIDv1. A payload of up to MaxDataFrameSize bytes
+// is a single bare frame embedded in its parent (no hash, index, total or next);
+// larger ones are split in frames of MaxDataFrameSize bytes, with the CRC64 (ISO) of
+// the payload as hash. An unknown block height is null, and every transaction has
+// its index in the block. ValidateCanonical checks that a CAR follows this layout.
+package carwriter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"os"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	carv1 "github.com/ipld/go-car"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/bindnode"
+	"github.com/ipld/go-ipld-prime/schema"
+	"github.com/multiformats/go-multicodec"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+)
+
+const (
+	// DefaultMaxSubsetBlocks is the default number of blocks linked by a subset node.
+	DefaultMaxSubsetBlocks = 10_000
+	// MaxDataFrameSize is the maximum size of the data of a single data frame;
+	// larger payloads are split across multiple frames.
+	MaxDataFrameSize = 1 << 20
+)
+
+// DummyCID is the "zero-length identity multihash with raw codec" used as the
+// rewards link of blocks without rewards.
+var DummyCID = cid.MustParse("bafkqaaa")
+
+// Block is the content of a single block, as stored in the CAR.
+type Block struct {
+	Slot        uint64
+	ParentSlot  uint64
+	BlockTime   int64
+	BlockHeight *uint64
+	Entries     []Entry
+	// Shredding is the position of each entry in the shreds of the block.
+	// When nil, the shred end index of every entry is recorded as unknown (-1).
+	Shredding []Shredding
+	// Rewards is the rewards data as stored in the CAR (zstd-compressed protobuf);
+	// nil means the block has no rewards node.
+	Rewards []byte
+}
+
+// Entry is a PoH entry and its transactions.
+type Entry struct {
+	NumHashes    uint64
+	Hash         solana.Hash
+	Transactions []Transaction
+}
+
+// Transaction is a transaction and its status meta.
+type Transaction struct {
+	// Data is the wire-encoded transaction.
+	Data []byte
+	// Metadata is the transaction status meta as stored in the CAR
+	// (zstd-compressed protobuf, or legacy bincode).
+	Metadata []byte
+}
+
+type Shredding struct {
+	EntryEndIdx int
+	ShredEndIdx int
+}
+
+// Writer writes the blocks of one epoch to a CAR file.
+type Writer struct {
+	file            *os.File
+	bw              *bufio.Writer
+	offset          uint64
+	epoch           uint64
+	firstSlot       uint64
+	lastSlot        uint64
+	maxSubsetBlocks int
+	// maxDataFrameSize is the size payloads are split at (MaxDataFrameSize, except when re-chunking).
+	maxDataFrameSize int
+	// written, if set, records the CIDs of the nodes written.
+	written *[]cid.Cid
+
+	lastWrittenSlot *uint64
+	subsets         []cid.Cid
+	subset          *subsetState
+}
+
+type subsetState struct {
+	first  uint64
+	last   uint64
+	blocks []cid.Cid
+}
+
+// Create creates (or truncates) the CAR file at path and writes its header.
+func Create(path string, epoch uint64, maxSubsetBlocks int) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := newWriter(file, epoch, maxSubsetBlocks)
+	var header bytes.Buffer
+	if err := carv1.WriteHeader(&carv1.CarHeader{Roots: []cid.Cid{placeholderRoot()}, Version: 1}, &header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to encode header: %w", err)
+	}
+	if err := w.write(header.Bytes()); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	return w, nil
+}
+
+// Resume reopens a partially written CAR file, discarding whatever was written
+// after the checkpoint, and restores the state of the writer.
+func Resume(path string, cp *Checkpoint) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if uint64(stat.Size()) < cp.Offset {
+		file.Close()
+		return nil, fmt.Errorf("CAR file is shorter (%d bytes) than the checkpoint offset (%d)", stat.Size(), cp.Offset)
+	}
+	if err := file.Truncate(int64(cp.Offset)); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to truncate CAR file: %w", err)
+	}
+	if _, err := file.Seek(int64(cp.Offset), io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	w := newWriter(file, cp.Epoch, cp.MaxSubsetBlocks)
+	if err := w.restore(cp); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func newWriter(file *os.File, epoch uint64, maxSubsetBlocks int) *Writer {
+	if maxSubsetBlocks <= 0 {
+		maxSubsetBlocks = DefaultMaxSubsetBlocks
+	}
+	firstSlot, lastSlot := slottools.CalcEpochLimits(epoch)
+	return &Writer{
+		file:             file,
+		bw:               bufio.NewWriterSize(file, 4<<20),
+		epoch:            epoch,
+		firstSlot:        firstSlot,
+		lastSlot:         lastSlot,
+		maxSubsetBlocks:  maxSubsetBlocks,
+		maxDataFrameSize: MaxDataFrameSize,
+	}
+}
+
+// placeholderRoot returns a CID with the same encoded size as the CID of the epoch node.
+func placeholderRoot() cid.Cid {
+	c, _ := cid.V1Builder{MhLength: -1, MhType: uint64(multicodec.Sha2_256), Codec: uint64(multicodec.DagCbor)}.Sum(nil)
+	return c
+}
+
+// NextSlot returns the first slot that has not been written yet.
+func (w *Writer) NextSlot() uint64 {
+	if w.lastWrittenSlot == nil {
+		return w.firstSlot
+	}
+	return *w.lastWrittenSlot + 1
+}
+
+// WriteBlock writes the DAG of the block; blocks must be written in ascending slot order.
+func (w *Writer) WriteBlock(block *Block) (cid.Cid, error) {
+	if block.Slot < w.firstSlot || block.Slot > w.lastSlot {
+		return cid.Undef, fmt.Errorf("slot %d is not in epoch %d", block.Slot, w.epoch)
+	}
+	if w.lastWrittenSlot != nil && block.Slot <= *w.lastWrittenSlot {
+		return cid.Undef, fmt.Errorf("slot %d is not after the last written slot %d", block.Slot, *w.lastWrittenSlot)
+	}
+	if block.Shredding != nil && len(block.Shredding) != len(block.Entries) {
+		return cid.Undef, fmt.Errorf("slot %d: %d shredding records for %d entries", block.Slot, len(block.Shredding), len(block.Entries))
+	}
+
+	entryLinks := make(ipldbindcode.List__Link, 0, len(block.Entries))
+	txIndex := 0
+	for _, entry := range block.Entries {
+		txLinks := make(ipldbindcode.List__Link, 0, len(entry.Transactions))
+		for _, tx := range entry.Transactions {
+			txCid, err := w.writeTransaction(block.Slot, txIndex, &tx)
+			if err != nil {
+				return cid.Undef, fmt.Errorf("slot %d: failed to write transaction %d: %w", block.Slot, txIndex, err)
+			}
+			txLinks = append(txLinks, cidlink.Link{Cid: txCid})
+			txIndex++
+		}
+		entryCid, err := w.writeNode(&ipldbindcode.Entry{
+			Kind:         int(iplddecoders.KindEntry),
+			NumHashes:    int(entry.NumHashes),
+			Hash:         entry.Hash[:],
+			Transactions: txLinks,
+		}, ipldbindcode.Prototypes.Entry)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("slot %d: failed to write entry: %w", block.Slot, err)
+		}
+		entryLinks = append(entryLinks, cidlink.Link{Cid: entryCid})
+	}
+
+	rewardsCid := DummyCID
+	if block.Rewards != nil {
+		frame, err := w.writeDataFrames(block.Rewards)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("slot %d: failed to write rewards: %w", block.Slot, err)
+		}
+		rewardsCid, err = w.writeNode(&rewards{
+			Kind: int(iplddecoders.KindRewards),
+			Slot: int(block.Slot),
+			Data: *frame,
+		}, ipldbindcode.Prototypes.Rewards)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("slot %d: failed to write rewards: %w", block.Slot, err)
+		}
+	}
+
+	shredding := make(ipldbindcode.List__Shredding, len(block.Entries))
+	for i := range block.Entries {
+		if block.Shredding != nil {
+			shredding[i] = ipldbindcode.Shredding(block.Shredding[i])
+		} else {
+			shredding[i] = ipldbindcode.Shredding{EntryEndIdx: i, ShredEndIdx: -1}
+		}
+	}
+	meta := ipldbindcode.SlotMeta{
+		Parent_slot: int(block.ParentSlot),
+		Blocktime:   int(block.BlockTime),
+		// an unknown block height is encoded as null, like radiance does.
+		Block_height: new(*int),
+	}
+	if block.BlockHeight != nil {
+		meta.Block_height = optionalInt(int(*block.BlockHeight))
+	}
+	blockCid, err := w.writeNode(&ipldbindcode.Block{
+		Kind:      int(iplddecoders.KindBlock),
+		Slot:      int(block.Slot),
+		Shredding: shredding,
+		Entries:   entryLinks,
+		Meta:      meta,
+		Rewards:   cidlink.Link{Cid: rewardsCid},
+	}, ipldbindcode.Prototypes.Block)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("slot %d: failed to write block: %w", block.Slot, err)
+	}
+
+	if w.subset == nil {
+		w.subset = &subsetState{first: block.Slot}
+	}
+	w.subset.last = block.Slot
+	w.subset.blocks = append(w.subset.blocks, blockCid)
+	slot := block.Slot
+	w.lastWrittenSlot = &slot
+	if len(w.subset.blocks) >= w.maxSubsetBlocks {
+		if err := w.closeSubset(); err != nil {
+			return cid.Undef, err
+		}
+	}
+	return blockCid, nil
+}
+
+// The nodes that embed a data frame are mirrored here with an unsigned hash:
+// the hash is a CRC64, and it is encoded as a CBOR unsigned integer like in the
+// CARs written by radiance (the ipldbindcode types would encode hashes above
+// math.MaxInt64 as negative integers).
+type (
+	dataFrame struct {
+		Kind  int
+		Hash  **uint64
+		Index **int
+		Total **int
+		Data  ipldbindcode.Buffer
+		Next  **ipldbindcode.List__Link
+	}
+	transaction struct {
+		Kind     int
+		Data     dataFrame
+		Metadata dataFrame
+		Slot     int
+		Index    **int
+	}
+	rewards struct {
+		Kind int
+		Slot int
+		Data dataFrame
+	}
+)
+
+func (w *Writer) writeTransaction(slot uint64, index int, tx *Transaction) (cid.Cid, error) {
+	data, err := w.writeDataFrames(tx.Data)
+	if err != nil {
+		return cid.Undef, err
+	}
+	metadata, err := w.writeDataFrames(tx.Metadata)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return w.writeNode(&transaction{
+		Kind:     int(iplddecoders.KindTransaction),
+		Data:     *data,
+		Metadata: *metadata,
+		Slot:     int(slot),
+		Index:    optionalInt(index),
+	}, ipldbindcode.Prototypes.Transaction)
+}
+
+// writeDataFrames writes all but the first frame of the payload as separate
+// DataFrame nodes, and returns the first frame, which is embedded in its parent node.
+// A payload that fits in a single frame gets a bare frame (no hash, index, total or next).
+func (w *Writer) writeDataFrames(payload []byte) (*dataFrame, error) {
+	if len(payload) <= w.maxDataFrameSize {
+		return &dataFrame{
+			Kind: int(iplddecoders.KindDataFrame),
+			Data: payload,
+		}, nil
+	}
+	chunks := splitPayload(payload, w.maxDataFrameSize)
+	checksum := crc64.Checksum(payload, crc64.MakeTable(crc64.ISO))
+	checksumPtr := &checksum
+	hash := &checksumPtr
+	total := optionalInt(len(chunks))
+	next := make(ipldbindcode.List__Link, 0, len(chunks)-1)
+	for i := 1; i < len(chunks); i++ {
+		frameCid, err := w.writeNode(&dataFrame{
+			Kind:  int(iplddecoders.KindDataFrame),
+			Hash:  hash,
+			Index: optionalInt(i),
+			Total: total,
+			Data:  chunks[i],
+		}, ipldbindcode.Prototypes.DataFrame)
+		if err != nil {
+			return nil, err
+		}
+		next = append(next, cidlink.Link{Cid: frameCid})
+	}
+	nextPtr := &next
+	return &dataFrame{
+		Kind:  int(iplddecoders.KindDataFrame),
+		Hash:  hash,
+		Index: optionalInt(0),
+		Total: total,
+		Data:  chunks[0],
+		Next:  &nextPtr,
+	}, nil
+}
+
+func splitPayload(payload []byte, size int) [][]byte {
+	var chunks [][]byte
+	for len(payload) > size {
+		chunks = append(chunks, payload[:size])
+		payload = payload[size:]
+	}
+	return append(chunks, payload)
+}
+
+func optionalInt(v int) **int {
+	p := &v
+	return &p
+}
+
+func (w *Writer) closeSubset() error {
+	if w.subset == nil {
+		return nil
+	}
+	blocks := make(ipldbindcode.List__Link, len(w.subset.blocks))
+	for i, c := range w.subset.blocks {
+		blocks[i] = cidlink.Link{Cid: c}
+	}
+	subsetCid, err := w.writeNode(&ipldbindcode.Subset{
+		Kind:   int(iplddecoders.KindSubset),
+		First:  int(w.subset.first),
+		Last:   int(w.subset.last),
+		Blocks: blocks,
+	}, ipldbindcode.Prototypes.Subset)
+	if err != nil {
+		return fmt.Errorf("failed to write subset %d-%d: %w", w.subset.first, w.subset.last, err)
+	}
+	w.subsets = append(w.subsets, subsetCid)
+	w.subset = nil
+	return nil
+}
+
+// Finish writes the last subset and the epoch node, points the CAR header
+// at the epoch node, and closes the file.
+func (w *Writer) Finish() (cid.Cid, error) {
+	root, err := w.finish()
+	if closeErr := w.file.Close(); err == nil && closeErr != nil {
+		return cid.Undef, closeErr
+	}
+	return root, err
+}
+
+func (w *Writer) finish() (cid.Cid, error) {
+	if err := w.closeSubset(); err != nil {
+		return cid.Undef, err
+	}
+	if len(w.subsets) == 0 {
+		return cid.Undef, errors.New("no blocks were written")
+	}
+	subsets := make(ipldbindcode.List__Link, len(w.subsets))
+	for i, c := range w.subsets {
+		subsets[i] = cidlink.Link{Cid: c}
+	}
+	root, err := w.writeNode(&ipldbindcode.Epoch{
+		Kind:    int(iplddecoders.KindEpoch),
+		Epoch:   int(w.epoch),
+		Subsets: subsets,
+	}, ipldbindcode.Prototypes.Epoch)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("failed to write epoch node: %w", err)
+	}
+	if err := w.bw.Flush(); err != nil {
+		return cid.Undef, err
+	}
+	var header bytes.Buffer
+	if err := carv1.WriteHeader(&carv1.CarHeader{Roots: []cid.Cid{root}, Version: 1}, &header); err != nil {
+		return cid.Undef, err
+	}
+	if _, err := w.file.WriteAt(header.Bytes(), 0); err != nil {
+		return cid.Undef, fmt.Errorf("failed to rewrite header: %w", err)
+	}
+	return root, w.file.Sync()
+}
+
+// Close flushes and closes the file without finishing the CAR (e.g. to resume later).
+func (w *Writer) Close() error {
+	if err := w.bw.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+func (w *Writer) writeNode(value any, proto schema.TypedPrototype) (cid.Cid, error) {
+	node := bindnode.Wrap(value, proto.Type())
+	var buf bytes.Buffer
+	if err := dagcbor.Encode(node.Representation(), &buf); err != nil {
+		return cid.Undef, err
+	}
+	return w.writeSection(buf.Bytes())
+}
+
+func (w *Writer) writeSection(data []byte) (cid.Cid, error) {
+	c, err := cid.V1Builder{MhLength: -1, MhType: uint64(multicodec.Sha2_256), Codec: uint64(multicodec.DagCbor)}.Sum(data)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if w.written != nil {
+		*w.written = append(*w.written, c)
+	}
+	cidBytes := c.Bytes()
+	var sizeBuf [10]byte
+	n := putUvarint(sizeBuf[:], uint64(len(cidBytes)+len(data)))
+	if err := w.write(sizeBuf[:n]); err != nil {
+		return cid.Undef, err
+	}
+	if err := w.write(cidBytes); err != nil {
+		return cid.Undef, err
+	}
+	if err := w.write(data); err != nil {
+		return cid.Undef, err
+	}
+	return c, nil
+}
+
+func putUvarint(buf []byte, v uint64) int {
+	i := 0
+	for v >= 0x80 {
+		buf[i] = byte(v) | 0x80
+		v >>= 7
+		i++
+	}
+	buf[i] = byte(v)
+	return i + 1
+}
+
+func (w *Writer) write(data []byte) error {
+	n, err := w.bw.Write(data)
+	w.offset += uint64(n)
+	return err
+}
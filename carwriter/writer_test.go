@@ -0,0 +1,213 @@
+package carwriter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/stretchr/testify/require"
+)
+
+const fixtureCar = "../fixtures/epoch-0-1.car"
+
+func readBlocks(t *testing.T, src BlockSource, from, to uint64) []*Block {
+	var blocks []*Block
+	require.NoError(t, src.Blocks(context.Background(), from, to, func(b *Block) error {
+		blocks = append(blocks, b)
+		return nil
+	}))
+	return blocks
+}
+
+// nodesByKind returns the CIDs of the nodes of the given kind, in file order.
+func nodesByKind(t *testing.T, path string, kind iplddecoders.Kind) []cid.Cid {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	rd, err := carreader.New(file)
+	require.NoError(t, err)
+	var out []cid.Cid
+	for {
+		c, _, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		require.NoError(t, err)
+		if iplddecoders.Kind(data[1]) == kind {
+			out = append(out, c)
+		}
+	}
+}
+
+func writeCar(t *testing.T, path string, blocks []*Block, maxSubsetBlocks int) cid.Cid {
+	w, err := Create(path, 0, maxSubsetBlocks)
+	require.NoError(t, err)
+	for _, b := range blocks {
+		_, err := w.WriteBlock(b)
+		require.NoError(t, err)
+	}
+	root, err := w.Finish()
+	require.NoError(t, err)
+	return root
+}
+
+func TestWriterReproducesBlocks(t *testing.T) {
+	blocks := readBlocks(t, NewCarSource(fixtureCar), 0, 100)
+	require.Len(t, blocks, 10)
+
+	out := filepath.Join(t.TempDir(), "epoch-0.car")
+	root := writeCar(t, out, blocks, 4)
+
+	stats, err := Validate(out)
+	require.NoError(t, err)
+	require.Equal(t, root, stats.Root)
+	require.Equal(t, uint64(10), stats.Blocks)
+	require.Equal(t, uint64(34), stats.Transactions)
+	require.Equal(t, uint64(3), stats.Subsets)
+	require.Equal(t, uint64(0), stats.FirstSlot)
+	require.Equal(t, uint64(9), stats.LastSlot)
+
+	// The DAGs of the blocks are the same as the ones written by radiance.
+	require.Equal(t, nodesByKind(t, fixtureCar, iplddecoders.KindBlock), nodesByKind(t, out, iplddecoders.KindBlock))
+	require.Equal(t, nodesByKind(t, fixtureCar, iplddecoders.KindTransaction), nodesByKind(t, out, iplddecoders.KindTransaction))
+}
+
+func TestValidateCanonical(t *testing.T) {
+	dir := t.TempDir()
+	canonical := filepath.Join(dir, "canonical.car")
+	root := writeCar(t, canonical, readBlocks(t, NewCarSource(fixtureCar), 0, 100), 4)
+	stats, err := ValidateCanonical(canonical)
+	require.NoError(t, err)
+	require.Equal(t, root, stats.Root)
+
+	// the same blocks, with the data split in small frames: valid, but not canonical.
+	small := filepath.Join(dir, "small.car")
+	_, err = Rechunk(canonical, small, 64)
+	require.NoError(t, err)
+	_, err = Validate(small)
+	require.NoError(t, err)
+	_, err = ValidateCanonical(small)
+	require.ErrorContains(t, err, "is not canonical")
+
+	back := filepath.Join(dir, "back.car")
+	_, err = Rechunk(small, back, 0)
+	require.NoError(t, err)
+	stats, err = ValidateCanonical(back)
+	require.NoError(t, err)
+	require.Equal(t, root, stats.Root)
+}
+
+func TestWriterResume(t *testing.T) {
+	blocks := readBlocks(t, NewCarSource(fixtureCar), 0, 100)
+	dir := t.TempDir()
+
+	full := filepath.Join(dir, "full.car")
+	wantRoot := writeCar(t, full, blocks, 4)
+
+	resumed := filepath.Join(dir, "resumed.car")
+	w, err := Create(resumed, 0, 4)
+	require.NoError(t, err)
+	for _, b := range blocks[:6] {
+		_, err := w.WriteBlock(b)
+		require.NoError(t, err)
+	}
+	cp, err := w.Checkpoint()
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), *cp.LastSlot)
+	require.Len(t, cp.Subsets, 1)
+	require.Len(t, cp.SubsetBlocks, 2)
+	// Written after the checkpoint, then lost.
+	_, err = w.WriteBlock(blocks[6])
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	cpPath := filepath.Join(dir, "resumed.car.checkpoint")
+	require.NoError(t, cp.Save(cpPath))
+	cp, err = LoadCheckpoint(cpPath)
+	require.NoError(t, err)
+
+	w, err = Resume(resumed, cp)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), w.NextSlot())
+	for _, b := range blocks[6:] {
+		_, err := w.WriteBlock(b)
+		require.NoError(t, err)
+	}
+	root, err := w.Finish()
+	require.NoError(t, err)
+	require.Equal(t, wantRoot, root)
+
+	fullData, err := os.ReadFile(full)
+	require.NoError(t, err)
+	resumedData, err := os.ReadFile(resumed)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(fullData, resumedData))
+}
+
+func TestWriterSplitsLargePayloads(t *testing.T) {
+	metadata := bytes.Repeat([]byte{0xab, 0xcd, 0xef}, MaxDataFrameSize)
+	rewards := bytes.Repeat([]byte{0x01}, MaxDataFrameSize+1)
+	block := &Block{
+		Slot:       7,
+		ParentSlot: 6,
+		BlockTime:  1700000000,
+		Entries: []Entry{{
+			NumHashes:    12500,
+			Transactions: []Transaction{{Data: []byte("tx"), Metadata: metadata}},
+		}},
+		Rewards: rewards,
+	}
+	out := filepath.Join(t.TempDir(), "epoch-0.car")
+	writeCar(t, out, []*Block{block}, 0)
+
+	_, err := ValidateCanonical(out)
+	require.NoError(t, err)
+	require.Len(t, nodesByKind(t, out, iplddecoders.KindDataFrame), 2+1)
+
+	got := readBlocks(t, NewCarSource(out), 0, 10)
+	require.Len(t, got, 1)
+	require.Equal(t, metadata, got[0].Entries[0].Transactions[0].Metadata)
+	require.Equal(t, rewards, got[0].Rewards)
+	require.Equal(t, []Shredding{{EntryEndIdx: 0, ShredEndIdx: -1}}, got[0].Shredding)
+}
+
+func TestWriterRejectsOutOfOrderSlots(t *testing.T) {
+	w, err := Create(filepath.Join(t.TempDir(), "epoch-0.car"), 0, 0)
+	require.NoError(t, err)
+	defer w.Close()
+	_, err = w.WriteBlock(&Block{Slot: 5})
+	require.NoError(t, err)
+	_, err = w.WriteBlock(&Block{Slot: 5})
+	require.ErrorContains(t, err, "not after the last written slot")
+	_, err = w.WriteBlock(&Block{Slot: 432000})
+	require.ErrorContains(t, err, "not in epoch 0")
+}
+
+func TestCarSourcePositions(t *testing.T) {
+	src := NewCarSource(fixtureCar)
+	var positions []BlockPosition
+	require.NoError(t, src.Positions(context.Background(), func(pos BlockPosition) error {
+		positions = append(positions, pos)
+		return nil
+	}))
+	require.Len(t, positions, 10)
+	require.Equal(t, nodesByKind(t, fixtureCar, iplddecoders.KindBlock)[0], positions[0].Cid)
+
+	blocks := readBlocks(t, src, 0, 100)
+	for i, pos := range positions {
+		if i > 0 {
+			// the DAG of a block starts where the previous block ends.
+			require.Equal(t, positions[i-1].Offset+positions[i-1].Length, pos.Offset)
+		}
+		block, err := src.ReadBlock(pos)
+		require.NoError(t, err)
+		require.Equal(t, blocks[i], block)
+	}
+}
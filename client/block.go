@@ -0,0 +1,251 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car/util"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+)
+
+// dummyCID is the placeholder used for blocks without rewards.
+var dummyCID = cid.MustParse("bafkqaaa")
+
+// Block is a reassembled block.
+type Block struct {
+	Slot       uint64
+	ParentSlot uint64
+	// Blockhash is the hash of the last entry of the block.
+	Blockhash solana.Hash
+	// Blocktime is 0 if unknown.
+	Blocktime int64
+	// BlockHeight is nil if unknown.
+	BlockHeight *uint64
+	// Transactions are in the order they appear in the block.
+	Transactions []*Transaction
+	// Rewards are the (uncompressed, protobuf-encoded) rewards of the block; nil if there are none.
+	// Use solanablockrewards.ParseRewards to decode them.
+	Rewards []byte
+}
+
+// GetBlock returns the block at the given slot; the error wraps ErrNotFound if the slot was skipped
+// or is not in this epoch.
+func (e *Epoch) GetBlock(ctx context.Context, slot uint64) (*Block, error) {
+	if e.slotToCid == nil {
+		return nil, errors.New("the slot-to-cid index is required to get blocks")
+	}
+	blockCid, err := e.slotToCid.Get(slot)
+	if err != nil {
+		if errors.Is(err, compactindexsized.ErrNotFound) {
+			return nil, fmt.Errorf("block %d: %w", slot, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to find block %d in the index: %w", slot, err)
+	}
+	return e.GetBlockByCid(ctx, blockCid)
+}
+
+// GetBlockByCid returns the block with the given CID.
+func (e *Epoch) GetBlockByCid(ctx context.Context, blockCid cid.Cid) (*Block, error) {
+	return e.getBlockByCid(ctx, blockCid, IterateOptions{})
+}
+
+// GetBlockhash returns the blockhash of the block at the given slot (the hash of its last entry),
+// without loading its transactions; the error wraps ErrNotFound like for GetBlock.
+func (e *Epoch) GetBlockhash(ctx context.Context, slot uint64) (solana.Hash, error) {
+	if e.slotToCid == nil {
+		return solana.Hash{}, errors.New("the slot-to-cid index is required to get blocks")
+	}
+	blockCid, err := e.slotToCid.Get(slot)
+	if err != nil {
+		if errors.Is(err, compactindexsized.ErrNotFound) {
+			return solana.Hash{}, fmt.Errorf("block %d: %w", slot, ErrNotFound)
+		}
+		return solana.Hash{}, fmt.Errorf("failed to find block %d in the index: %w", slot, err)
+	}
+	blockNode, err := e.GetNode(ctx, blockCid)
+	if err != nil {
+		return solana.Hash{}, err
+	}
+	block, err := iplddecoders.DecodeBlock(blockNode)
+	if err != nil {
+		return solana.Hash{}, fmt.Errorf("failed to decode block %s: %w", blockCid, err)
+	}
+	if len(block.Entries) == 0 {
+		return solana.Hash{}, fmt.Errorf("block %d has no entries", slot)
+	}
+	entryCid := block.Entries[len(block.Entries)-1].(cidlink.Link).Cid
+	entryNode, err := e.GetNode(ctx, entryCid)
+	if err != nil {
+		return solana.Hash{}, err
+	}
+	entry, err := iplddecoders.DecodeEntry(entryNode)
+	if err != nil {
+		return solana.Hash{}, fmt.Errorf("failed to decode entry %s: %w", entryCid, err)
+	}
+	return solana.HashFromBytes(entry.Hash), nil
+}
+
+func (e *Epoch) getBlockByCid(ctx context.Context, blockCid cid.Cid, opts IterateOptions) (*Block, error) {
+	blockNode, err := e.GetNode(ctx, blockCid)
+	if err != nil {
+		return nil, err
+	}
+	block, err := iplddecoders.DecodeBlock(blockNode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode block %s: %w", blockCid, err)
+	}
+	out := &Block{
+		Slot:       uint64(block.Slot),
+		ParentSlot: uint64(block.Meta.Parent_slot),
+		Blocktime:  int64(block.Meta.Blocktime),
+	}
+	if height, ok := block.GetBlockHeight(); ok {
+		out.BlockHeight = &height
+	}
+	for _, entryLink := range block.Entries {
+		entryCid := entryLink.(cidlink.Link).Cid
+		entryNode, err := e.GetNode(ctx, entryCid)
+		if err != nil {
+			return nil, err
+		}
+		entry, err := iplddecoders.DecodeEntry(entryNode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode entry %s: %w", entryCid, err)
+		}
+		out.Blockhash = solana.HashFromBytes(entry.Hash)
+		for _, txLink := range entry.Transactions {
+			tx, err := e.getTransactionByCid(ctx, txLink.(cidlink.Link).Cid, opts)
+			if err != nil {
+				return nil, err
+			}
+			if tx == nil {
+				continue
+			}
+			out.Transactions = append(out.Transactions, tx)
+		}
+	}
+	if rewardsCid := block.Rewards.(cidlink.Link).Cid; !rewardsCid.Equals(dummyCID) {
+		rewardsNode, err := e.GetNode(ctx, rewardsCid)
+		if err != nil {
+			return nil, err
+		}
+		rewards, err := iplddecoders.DecodeRewards(rewardsNode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode rewards %s: %w", rewardsCid, err)
+		}
+		out.Rewards, err = e.loadDataFrames(&rewards.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load rewards: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// IterateOptions are the options of IterateBlocksWithOptions and IterateTransactions.
+type IterateOptions struct {
+	// ExcludeVotes leaves out simple vote transactions. They are detected from the raw
+	// transaction bytes, so they are neither decoded nor is their metadata loaded and parsed:
+	// this is much faster on epochs where most transactions are votes.
+	ExcludeVotes bool
+}
+
+// IterateBlocks calls fn for each block in [from, to] (inclusive), in slot order; skipped slots are skipped.
+// Iteration stops at the first error returned by fn, which is returned.
+func (e *Epoch) IterateBlocks(ctx context.Context, from, to uint64, fn func(*Block) error) error {
+	return e.IterateBlocksWithOptions(ctx, from, to, IterateOptions{}, fn)
+}
+
+// IterateBlocksWithOptions is IterateBlocks, with the transactions of the blocks filtered by the options.
+func (e *Epoch) IterateBlocksWithOptions(ctx context.Context, from, to uint64, opts IterateOptions, fn func(*Block) error) error {
+	if e.slotToCid == nil {
+		return errors.New("the slot-to-cid index is required to iterate blocks")
+	}
+	for slot := from; slot <= to; slot++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		blockCid, err := e.slotToCid.Get(slot)
+		switch {
+		case errors.Is(err, compactindexsized.ErrNotFound):
+		case err != nil:
+			return fmt.Errorf("failed to find block %d in the index: %w", slot, err)
+		default:
+			block, err := e.getBlockByCid(ctx, blockCid, opts)
+			if err != nil {
+				return err
+			}
+			if err := fn(block); err != nil {
+				return err
+			}
+		}
+		if slot == to {
+			// avoid overflow when to is the max uint64.
+			break
+		}
+	}
+	return nil
+}
+
+// IterateTransactions calls fn for each transaction of the blocks in [from, to] (inclusive),
+// in slot order and in the order they appear in each block.
+// Iteration stops at the first error returned by fn, which is returned.
+func (e *Epoch) IterateTransactions(ctx context.Context, from, to uint64, opts IterateOptions, fn func(*Transaction) error) error {
+	return e.IterateBlocksWithOptions(ctx, from, to, opts, func(block *Block) error {
+		for _, tx := range block.Transactions {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// loadDataFrames loads the complete data that starts with the given data frame,
+// fetching the next frames (for data that was split across multiple frames); compressed data
+// is decompressed.
+func (e *Epoch) loadDataFrames(first *ipldbindcode.DataFrame) ([]byte, error) {
+	chunks, err := tooling.LoadChunksFromDataFrames(first, e.getDataFrame)
+	if err != nil {
+		return nil, err
+	}
+	if chunks.Len() == 0 {
+		return nil, nil
+	}
+	return tooling.DecompressZstdChunks(chunks)
+}
+
+func (e *Epoch) getDataFrame(ctx context.Context, c cid.Cid) (*ipldbindcode.DataFrame, error) {
+	node, err := e.GetNode(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return iplddecoders.DecodeDataFrame(node)
+}
+
+func parseNodeFromSection(section []byte, wantedCid cid.Cid) ([]byte, error) {
+	gotLen, usize := binary.Uvarint(section)
+	if usize <= 0 {
+		return nil, fmt.Errorf("failed to decode uvarint")
+	}
+	if gotLen > uint64(util.MaxAllowedSectionSize) { // Don't OOM
+		return nil, errors.New("malformed car; header is bigger than util.MaxAllowedSectionSize")
+	}
+	data := section[usize:]
+	cidLen, gotCid, err := cid.CidFromReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cid: %w", err)
+	}
+	if !gotCid.Equals(wantedCid) {
+		return nil, fmt.Errorf("CID mismatch: expected %s, got %s", wantedCid, gotCid)
+	}
+	return data[cidLen:], nil
+}
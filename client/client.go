@@ -0,0 +1,165 @@
+// Package client is a library to read old-faithful archives (an epoch CAR file and its indexes)
+// directly from Go programs, without running the RPC server.
+//
+// The CAR file and the indexes can be local files or HTTP(S) URLs (which must support range requests):
+//
+//	epoch, err := client.Open(ctx, client.Config{
+//		Car:                "https://files.old-faithful.net/0/epoch-0.car",
+//		CidToOffsetAndSize: "/data/epoch-0-...-mainnet-cid-to-offset-and-size.index",
+//		SlotToCid:          "/data/epoch-0-...-mainnet-slot-to-cid.index",
+//		SigToCid:           "/data/epoch-0-...-mainnet-sig-to-cid.index",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer epoch.Close()
+//	block, err := epoch.GetBlock(ctx, 123)
+//
+// Everything in this package is safe for concurrent use.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	splitcarfetcher "github.com/rpcpool/yellowstone-faithful/split-car-fetcher"
+	"golang.org/x/exp/mmap"
+)
+
+// ErrNotFound is returned when a slot (skipped, or not in the epoch) or a transaction is not found.
+var ErrNotFound = errors.New("not found")
+
+// Config are the locations of the files of an epoch; each one can be a local path or an HTTP(S) URL.
+type Config struct {
+	// Car is the epoch CAR file (required).
+	Car string
+	// CidToOffsetAndSize is the cid-to-offset-and-size index (required).
+	CidToOffsetAndSize string
+	// SlotToCid is the slot-to-cid index (required for GetBlock and IterateBlocks).
+	SlotToCid string
+	// SigToCid is the sig-to-cid index (required for GetTransaction).
+	SigToCid string
+}
+
+type readerAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// Epoch reads the blocks and transactions of one epoch.
+type Epoch struct {
+	car       readerAtCloser
+	cidToOas  *indexes.CidToOffsetAndSize_Reader
+	slotToCid *indexes.SlotToCid_Reader
+	sigToCid  *indexes.SigToCid_Reader
+	closers   []io.Closer
+}
+
+// Open opens the files of an epoch.
+func Open(ctx context.Context, config Config) (_ *Epoch, err error) {
+	if config.Car == "" {
+		return nil, errors.New("config.Car must be set")
+	}
+	if config.CidToOffsetAndSize == "" {
+		return nil, errors.New("config.CidToOffsetAndSize must be set")
+	}
+	e := &Epoch{}
+	defer func() {
+		if err != nil {
+			e.Close()
+		}
+	}()
+
+	e.car, err = e.open(ctx, config.Car)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CAR file: %w", err)
+	}
+	{
+		rac, err := e.open(ctx, config.CidToOffsetAndSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cid-to-offset-and-size index: %w", err)
+		}
+		e.cidToOas, err = indexes.OpenWithReader_CidToOffsetAndSize(rac)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cid-to-offset-and-size index: %w", err)
+		}
+	}
+	if config.SlotToCid != "" {
+		rac, err := e.open(ctx, config.SlotToCid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open slot-to-cid index: %w", err)
+		}
+		e.slotToCid, err = indexes.OpenWithReader_SlotToCid(rac)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open slot-to-cid index: %w", err)
+		}
+	}
+	if config.SigToCid != "" {
+		rac, err := e.open(ctx, config.SigToCid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sig-to-cid index: %w", err)
+		}
+		e.sigToCid, err = indexes.OpenWithReader_SigToCid(rac)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sig-to-cid index: %w", err)
+		}
+	}
+	return e, nil
+}
+
+func (e *Epoch) open(ctx context.Context, where string) (readerAtCloser, error) {
+	where = strings.TrimSpace(where)
+	var rac readerAtCloser
+	if strings.HasPrefix(where, "http://") || strings.HasPrefix(where, "https://") {
+		remote, _, err := splitcarfetcher.NewRemoteHTTPFileAsIoReaderAt(ctx, where)
+		if err != nil {
+			return nil, err
+		}
+		rac = remote
+	} else {
+		local, err := mmap.Open(where)
+		if err != nil {
+			return nil, err
+		}
+		rac = local
+	}
+	e.closers = append(e.closers, rac)
+	return rac, nil
+}
+
+// Close closes all the files of the epoch.
+func (e *Epoch) Close() error {
+	var errs []error
+	for _, c := range e.closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	e.closers = nil
+	return errors.Join(errs...)
+}
+
+// GetNode returns the raw (CBOR) data of the node with the given CID.
+func (e *Epoch) GetNode(ctx context.Context, c cid.Cid) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	oas, err := e.cidToOas.Get(c)
+	if err != nil {
+		if errors.Is(err, compactindexsized.ErrNotFound) {
+			return nil, fmt.Errorf("node %s: %w", c, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to find node %s in the index: %w", c, err)
+	}
+	section := make([]byte, oas.Size)
+	if _, err := e.car.ReadAt(section, int64(oas.Offset)); err != nil {
+		return nil, fmt.Errorf("failed to read node %s from the CAR file: %w", c, err)
+	}
+	return parseNodeFromSection(section, c)
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	"github.com/stretchr/testify/require"
+)
+
+// buildIndexes creates the indexes for a CAR file, and returns the config to open it,
+// the slots of the blocks, and the signatures of the transactions.
+func buildIndexes(t *testing.T, carPath string) (Config, []uint64, []solana.Signature) {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	file, err := os.Open(carPath)
+	require.NoError(t, err)
+	defer file.Close()
+	rd, err := carreader.New(file)
+	require.NoError(t, err)
+	rootCid := rd.Header.Roots[0]
+
+	c2o, err := indexes.NewWriter_CidToOffsetAndSize(0, rootCid, indexes.NetworkMainnet, t.TempDir(), 1000)
+	require.NoError(t, err)
+	s2c, err := indexes.NewWriter_SlotToCid(0, rootCid, indexes.NetworkMainnet, t.TempDir(), 1000)
+	require.NoError(t, err)
+	sig2c, err := indexes.NewWriter_SigToCid(0, rootCid, indexes.NetworkMainnet, t.TempDir(), 1000)
+	require.NoError(t, err)
+
+	var slots []uint64
+	var sigs []solana.Signature
+	offset, err := rd.HeaderSize()
+	require.NoError(t, err)
+	for {
+		c, sectionLength, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.NoError(t, c2o.Put(c, offset, sectionLength))
+		offset += sectionLength
+
+		switch iplddecoders.Kind(data[1]) {
+		case iplddecoders.KindBlock:
+			block, err := iplddecoders.DecodeBlock(data)
+			require.NoError(t, err)
+			require.NoError(t, s2c.Put(uint64(block.Slot), c))
+			slots = append(slots, uint64(block.Slot))
+		case iplddecoders.KindTransaction:
+			tx, err := iplddecoders.DecodeTransaction(data)
+			require.NoError(t, err)
+			sig, err := tx.Signature()
+			require.NoError(t, err)
+			require.NoError(t, sig2c.Put(sig, c))
+			sigs = append(sigs, sig)
+		}
+	}
+	require.NoError(t, c2o.Seal(ctx, dir))
+	require.NoError(t, s2c.Seal(ctx, dir))
+	require.NoError(t, sig2c.Seal(ctx, dir))
+	return Config{
+		Car:                carPath,
+		CidToOffsetAndSize: c2o.GetFilepath(),
+		SlotToCid:          s2c.GetFilepath(),
+		SigToCid:           sig2c.GetFilepath(),
+	}, slots, sigs
+}
+
+func TestEpoch(t *testing.T) {
+	ctx := context.Background()
+	config, slots, sigs := buildIndexes(t, filepath.Join("..", "fixtures", "epoch-0-1.car"))
+	require.NotEmpty(t, slots)
+	require.NotEmpty(t, sigs)
+
+	epoch, err := Open(ctx, config)
+	require.NoError(t, err)
+	defer epoch.Close()
+
+	t.Run("GetBlock", func(t *testing.T) {
+		numTransactions := 0
+		for _, slot := range slots {
+			block, err := epoch.GetBlock(ctx, slot)
+			require.NoError(t, err)
+			require.Equal(t, slot, block.Slot)
+			require.False(t, block.Blockhash.IsZero())
+			for _, tx := range block.Transactions {
+				require.Equal(t, slot, tx.Slot)
+			}
+			numTransactions += len(block.Transactions)
+		}
+		require.Equal(t, len(sigs), numTransactions)
+
+		_, err := epoch.GetBlock(ctx, 1_000_000)
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("GetTransaction", func(t *testing.T) {
+		for _, sig := range sigs {
+			tx, err := epoch.GetTransaction(ctx, sig)
+			require.NoError(t, err)
+			require.Equal(t, sig, tx.Signature())
+		}
+		_, err := epoch.GetTransaction(ctx, solana.Signature{1, 2, 3})
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("IterateBlocks", func(t *testing.T) {
+		var got []uint64
+		err := epoch.IterateBlocks(ctx, 0, slots[len(slots)-1], func(block *Block) error {
+			got = append(got, block.Slot)
+			return nil
+		})
+		require.NoError(t, err)
+		require.ElementsMatch(t, slots, got)
+	})
+
+	t.Run("IterateTransactions", func(t *testing.T) {
+		var all, nonVotes int
+		err := epoch.IterateTransactions(ctx, 0, slots[len(slots)-1], IterateOptions{}, func(tx *Transaction) error {
+			all++
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, len(sigs), all)
+		// the fixture only has votes.
+		err = epoch.IterateTransactions(ctx, 0, slots[len(slots)-1], IterateOptions{ExcludeVotes: true}, func(tx *Transaction) error {
+			require.False(t, nodetools.IsSimpleVoteTransaction(&tx.Transaction))
+			nonVotes++
+			return nil
+		})
+		require.NoError(t, err)
+		require.Zero(t, nonVotes)
+	})
+}
+
+func TestToProtobuf(t *testing.T) {
+	ctx := context.Background()
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+	config, slots, _ := buildIndexes(t, carPath)
+	epoch, err := Open(ctx, config)
+	require.NoError(t, err)
+	defer epoch.Close()
+
+	var previousBlockhash solana.Hash
+	for i, slot := range slots {
+		block, err := epoch.GetBlock(ctx, slot)
+		require.NoError(t, err)
+		blockhash, err := epoch.GetBlockhash(ctx, slot)
+		require.NoError(t, err)
+		require.Equal(t, block.Blockhash, blockhash)
+
+		got, err := block.ToProtobuf(previousBlockhash)
+		require.NoError(t, err)
+		require.Equal(t, block.Blockhash.String(), got.Blockhash)
+		require.Equal(t, block.ParentSlot, got.ParentSlot)
+		require.Equal(t, block.Blocktime, got.BlockTime.Timestamp)
+		if i == 0 {
+			require.Empty(t, got.PreviousBlockhash)
+		} else {
+			require.Equal(t, previousBlockhash.String(), got.PreviousBlockhash)
+		}
+		require.Equal(t, fixture.Blocks[i].Rewards != nil, len(got.Rewards) > 0)
+		require.Len(t, got.Transactions, len(block.Transactions))
+		for j, tx := range block.Transactions {
+			converted := got.Transactions[j]
+			sig := tx.Signature()
+			require.Equal(t, sig[:], converted.Transaction.Signatures[0])
+			require.Equal(t, tx.Transaction.Message.IsVersioned(), converted.Transaction.Message.Versioned)
+			if tx.RawMeta == nil {
+				require.Nil(t, converted.Meta)
+			} else {
+				// the legacy metas are upgraded too.
+				require.Equal(t, uint64(carfixture.Fee), converted.Meta.Fee)
+			}
+		}
+		previousBlockhash = blockhash
+	}
+
+	_, err = epoch.GetBlockhash(ctx, 3)
+	require.ErrorIs(t, err, ErrNotFound)
+}
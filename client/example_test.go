@@ -0,0 +1,57 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/client"
+)
+
+func Example() {
+	ctx := context.Background()
+	epoch, err := client.Open(ctx, client.Config{
+		Car:                "/data/epoch-0.car",
+		CidToOffsetAndSize: "/data/epoch-0-bafy...-mainnet-cid-to-offset-and-size.index",
+		SlotToCid:          "/data/epoch-0-bafy...-mainnet-slot-to-cid.index",
+		SigToCid:           "/data/epoch-0-bafy...-mainnet-sig-to-cid.index",
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer epoch.Close()
+
+	block, err := epoch.GetBlock(ctx, 1)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(block.Blockhash, len(block.Transactions))
+
+	var sig solana.Signature // the signature of the transaction to look up
+	tx, err := epoch.GetTransaction(ctx, sig)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(tx.Slot, tx.Meta != nil)
+}
+
+func ExampleEpoch_IterateBlocks() {
+	ctx := context.Background()
+	epoch, err := client.Open(ctx, client.Config{
+		Car:                "https://files.old-faithful.net/0/epoch-0.car",
+		CidToOffsetAndSize: "/data/epoch-0-bafy...-mainnet-cid-to-offset-and-size.index",
+		SlotToCid:          "/data/epoch-0-bafy...-mainnet-slot-to-cid.index",
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer epoch.Close()
+
+	err = epoch.IterateBlocks(ctx, 0, 1000, func(block *client.Block) error {
+		fmt.Println(block.Slot, block.Blocktime, len(block.Transactions))
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	solanablockrewards "github.com/rpcpool/yellowstone-faithful/solana-block-rewards"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+)
+
+// ToProtobuf converts the transaction to the ConfirmedTransaction protobuf of the
+// solana-storage-proto crate; legacy (bincode) metas are upgraded to protobuf.
+func (tx *Transaction) ToProtobuf() (*confirmed_block.ConfirmedTransaction, error) {
+	out := &confirmed_block.ConfirmedTransaction{
+		Transaction: nodetools.TransactionToProtobuf(&tx.Transaction),
+	}
+	if len(tx.RawMeta) > 0 {
+		meta, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(tx.RawMeta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the meta of transaction %s: %w", tx.Signature(), err)
+		}
+		if out.Meta, err = meta.ToProtobuf(); err != nil {
+			return nil, fmt.Errorf("failed to convert the meta of transaction %s: %w", tx.Signature(), err)
+		}
+	}
+	return out, nil
+}
+
+// ToProtobuf converts the block to the ConfirmedBlock protobuf of the solana-storage-proto crate.
+// previousBlockhash is the blockhash of the parent block (see GetBlockhash); it is left empty when zero.
+func (b *Block) ToProtobuf(previousBlockhash solana.Hash) (*confirmed_block.ConfirmedBlock, error) {
+	out := &confirmed_block.ConfirmedBlock{
+		Blockhash:    b.Blockhash.String(),
+		ParentSlot:   b.ParentSlot,
+		Transactions: make([]*confirmed_block.ConfirmedTransaction, 0, len(b.Transactions)),
+		BlockTime:    &confirmed_block.UnixTimestamp{Timestamp: b.Blocktime},
+	}
+	if !previousBlockhash.IsZero() {
+		out.PreviousBlockhash = previousBlockhash.String()
+	}
+	if b.BlockHeight != nil {
+		out.BlockHeight = &confirmed_block.BlockHeight{BlockHeight: *b.BlockHeight}
+	}
+	for _, tx := range b.Transactions {
+		converted, err := tx.ToProtobuf()
+		if err != nil {
+			return nil, err
+		}
+		out.Transactions = append(out.Transactions, converted)
+	}
+	if len(b.Rewards) > 0 {
+		rewards, err := solanablockrewards.ParseRewards(b.Rewards)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the rewards of block %d: %w", b.Slot, err)
+		}
+		out.Rewards = rewards.Rewards
+	}
+	return out, nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+)
+
+// Transaction is a reassembled transaction with its metadata.
+type Transaction struct {
+	Slot uint64
+	// Position is the index of the transaction in the block; -1 if unknown (old CAR files).
+	Position    int
+	Transaction solana.Transaction
+	// Meta is the parsed transaction status meta, which is one of the types
+	// returned by solanatxmetaparsers.ParseAnyTransactionStatusMeta; nil if there is no metadata.
+	Meta any
+	// RawMeta is the uncompressed metadata, as stored in the CAR file.
+	RawMeta []byte
+}
+
+// Signature returns the first signature of the transaction.
+func (tx *Transaction) Signature() solana.Signature {
+	return tx.Transaction.Signatures[0]
+}
+
+// GetTransaction returns the transaction with the given signature; the error wraps ErrNotFound
+// if the transaction is not in this epoch.
+func (e *Epoch) GetTransaction(ctx context.Context, sig solana.Signature) (*Transaction, error) {
+	if e.sigToCid == nil {
+		return nil, errors.New("the sig-to-cid index is required to get transactions")
+	}
+	txCid, err := e.sigToCid.Get(sig)
+	if err != nil {
+		if errors.Is(err, compactindexsized.ErrNotFound) {
+			return nil, fmt.Errorf("transaction %s: %w", sig, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to find transaction %s in the index: %w", sig, err)
+	}
+	tx, err := e.GetTransactionByCid(ctx, txCid)
+	if err != nil {
+		return nil, err
+	}
+	if tx.Signature() != sig {
+		// the index is probabilistic for keys that are not in it.
+		return nil, fmt.Errorf("transaction %s: %w", sig, ErrNotFound)
+	}
+	return tx, nil
+}
+
+// GetTransactionByCid returns the transaction with the given CID.
+func (e *Epoch) GetTransactionByCid(ctx context.Context, txCid cid.Cid) (*Transaction, error) {
+	return e.getTransactionByCid(ctx, txCid, IterateOptions{})
+}
+
+// getTransactionByCid returns nil if the transaction is excluded by the options; the
+// exclusion is checked on the raw transaction, before it's decoded and its meta is loaded.
+func (e *Epoch) getTransactionByCid(ctx context.Context, txCid cid.Cid, opts IterateOptions) (*Transaction, error) {
+	node, err := e.GetNode(ctx, txCid)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := iplddecoders.DecodeTransaction(node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction %s: %w", txCid, err)
+	}
+	out := &Transaction{
+		Slot:     uint64(decoded.Slot),
+		Position: -1,
+	}
+	if pos, ok := decoded.GetPositionIndex(); ok {
+		out.Position = pos
+	}
+	{
+		txBuf, err := tooling.LoadDataFromDataFrames(&decoded.Data, e.getDataFrame)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load transaction %s: %w", txCid, err)
+		}
+		if opts.ExcludeVotes && nodetools.IsSimpleVoteTransactionBytes(txBuf) {
+			return nil, nil
+		}
+		if err := bin.UnmarshalBin(&out.Transaction, txBuf); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal transaction %s: %w", txCid, err)
+		}
+		if len(out.Transaction.Signatures) == 0 {
+			return nil, fmt.Errorf("transaction %s has no signatures", txCid)
+		}
+	}
+	{
+		out.RawMeta, err = e.loadDataFrames(&decoded.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load metadata of transaction %s: %w", txCid, err)
+		}
+		if len(out.RawMeta) > 0 {
+			out.Meta, err = solanatxmetaparsers.ParseAnyTransactionStatusMeta(out.RawMeta)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse metadata of transaction %s: %w", txCid, err)
+			}
+		}
+	}
+	return out, nil
+}
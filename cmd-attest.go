@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/attestation"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	splitcarfetcher "github.com/rpcpool/yellowstone-faithful/split-car-fetcher"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_Attest() *cli.Command {
+	var keypairPath string
+	var outPath string
+	return &cli.Command{
+		Name:        "attest",
+		Usage:       "Generate a signed manifest of the files of an epoch.",
+		Description: "Computes the SHA256 of the CAR file and of the indexes of the epoch described by the config file (they must be local files), and signs them, together with the root CID and the piece CIDs, with the given keypair.",
+		ArgsUsage:   "<epoch config file>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "keypair",
+				Usage:       "Path to the (solana-keygen JSON) keypair used to sign the manifest",
+				Required:    true,
+				Destination: &keypairPath,
+			},
+			&cli.StringFlag{
+				Name:        "out",
+				Usage:       "Path of the attestation file to write",
+				Required:    true,
+				Destination: &outPath,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() != 1 {
+				return cli.Exit("expected exactly one epoch config file", 1)
+			}
+			config, err := loadEpochConfig(c.Args().First())
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			key, err := solana.PrivateKeyFromSolanaKeygenFile(keypairPath)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("failed to load keypair: %s", err), 1)
+			}
+			manifest, err := newEpochManifest(config)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			att, err := attestation.Sign(manifest, key)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			if err := att.WriteFile(outPath); err != nil {
+				return cli.Exit(fmt.Sprintf("failed to write attestation: %s", err), 1)
+			}
+			klog.Infof("Wrote attestation for epoch %d signed by %s to %s", manifest.Epoch, att.Signer, outPath)
+			return nil
+		},
+	}
+}
+
+func newCmd_VerifyAttestation() *cli.Command {
+	var signers cli.StringSlice
+	return &cli.Command{
+		Name:        "verify-attestation",
+		Usage:       "Verify the files of an epoch against a signed manifest.",
+		Description: "Checks that the attestation was signed by one of the trusted signers, and, if an epoch config file is given, that the local CAR file and indexes it points to match the manifest.",
+		ArgsUsage:   "<attestation file> [<epoch config file>]",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:        "signer",
+				Usage:       "Public key (base58) of a trusted signer; can be specified multiple times",
+				Required:    true,
+				Destination: &signers,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() < 1 || c.Args().Len() > 2 {
+				return cli.Exit("expected an attestation file and an optional epoch config file", 1)
+			}
+			var trusted []solana.PublicKey
+			for _, signer := range signers.Value() {
+				key, err := solana.PublicKeyFromBase58(signer)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("invalid signer %q: %s", signer, err), 1)
+				}
+				trusted = append(trusted, key)
+			}
+			att, err := attestation.ReadFile(c.Args().Get(0))
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			if err := att.Verify(trusted...); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			klog.Infof("[OK] Attestation for epoch %d is signed by %s", att.Manifest.Epoch, att.Signer)
+			if c.Args().Len() == 1 {
+				return nil
+			}
+			config, err := loadEpochConfig(c.Args().Get(1))
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			if err := verifyEpochAgainstManifest(config, &att.Manifest); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			klog.Infof("[OK] Files of epoch %d match the attestation", att.Manifest.Epoch)
+			return nil
+		},
+	}
+}
+
+// loadEpochConfig loads a config file without validating it: attestations only need
+// the epoch and the locations of the files that are present.
+func loadEpochConfig(configFilepath string) (*Config, error) {
+	config, err := LoadConfig(configFilepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file %q: %w", configFilepath, err)
+	}
+	if config.Epoch == nil {
+		return nil, fmt.Errorf("config file %q: epoch must be set", configFilepath)
+	}
+	if config.Data.Car == nil && !config.IsFilecoinMode() {
+		return nil, fmt.Errorf("config file %q: data.car must be set", configFilepath)
+	}
+	return config, nil
+}
+
+type configIndex struct {
+	kind string
+	uri  URI
+}
+
+// configIndexes returns the indexes that are set in the config.
+func configIndexes(config *Config) []configIndex {
+	all := []configIndex{
+		{"cid_to_offset_and_size", config.Indexes.CidToOffsetAndSize.URI},
+		{"cid_to_offset", config.Indexes.CidToOffset.URI},
+		{"slot_to_cid", config.Indexes.SlotToCid.URI},
+		{"sig_to_cid", config.Indexes.SigToCid.URI},
+		{"gsfa", config.Indexes.Gsfa.URI},
+		{"gpfa", config.Indexes.Gpfa.URI},
+		{"token_balances", config.Indexes.TokenBalances.URI},
+		{"sig_exists", config.Indexes.SigExists.URI},
+		{"slot_to_blocktime", config.Indexes.SlotToBlocktime.URI},
+	}
+	var out []configIndex
+	for _, index := range all {
+		if !index.uri.IsZero() {
+			out = append(out, index)
+		}
+	}
+	return out
+}
+
+func localPath(uri URI) (string, error) {
+	if !uri.IsLocal() {
+		return "", fmt.Errorf("%q is not a local file", uri)
+	}
+	return strings.TrimPrefix(uri.String(), "file://"), nil
+}
+
+// newEpochManifest computes the manifest of the epoch described by the config.
+func newEpochManifest(config *Config) (*attestation.Manifest, error) {
+	rootCid, err := epochRootCid(config)
+	if err != nil {
+		return nil, err
+	}
+	manifest := attestation.NewManifest(*config.Epoch, rootCid)
+	if config.IsCarFromPieces() {
+		metadata, err := splitcarfetcher.MetadataFromYaml(string(config.Data.Car.FromPieces.Metadata.URI))
+		if err != nil {
+			return nil, err
+		}
+		for _, piece := range metadata.CarPieces.CarPieces {
+			manifest.Pieces = append(manifest.Pieces, piece.CommP.String())
+		}
+	} else if config.Data.Car != nil {
+		path, err := localPath(config.Data.Car.URI)
+		if err != nil {
+			return nil, fmt.Errorf("CAR file: %w", err)
+		}
+		klog.Infof("Hashing CAR file %s ...", path)
+		manifest.Car, err = attestation.DigestPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash CAR file: %w", err)
+		}
+	}
+	for _, index := range configIndexes(config) {
+		path, err := localPath(index.uri)
+		if err != nil {
+			return nil, fmt.Errorf("%s index: %w", index.kind, err)
+		}
+		klog.Infof("Hashing %s index %s ...", index.kind, path)
+		digest, err := attestation.DigestPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s index: %w", index.kind, err)
+		}
+		manifest.Indexes = append(manifest.Indexes, attestation.IndexDigest{Kind: index.kind, FileDigest: *digest})
+	}
+	return manifest, nil
+}
+
+// epochRootCid returns the root CID of the epoch, from the CAR file, the pieces metadata, or the filecoin config.
+func epochRootCid(config *Config) (string, error) {
+	if config.IsFilecoinMode() {
+		return config.Data.Filecoin.RootCID.String(), nil
+	}
+	if config.IsCarFromPieces() {
+		metadata, err := splitcarfetcher.MetadataFromYaml(string(config.Data.Car.FromPieces.Metadata.URI))
+		if err != nil {
+			return "", err
+		}
+		header, err := base64.StdEncoding.DecodeString(metadata.CarPieces.OriginalCarHeader)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode original CAR header: %w", err)
+		}
+		// the original header is stored without its size prefix.
+		section := binary.AppendUvarint(nil, uint64(len(header)))
+		return rootCidFromCarHeader(bytes.NewReader(append(section, header...)))
+	}
+	path, err := localPath(config.Data.Car.URI)
+	if err != nil {
+		return "", fmt.Errorf("CAR file: %w", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return rootCidFromCarHeader(file)
+}
+
+func rootCidFromCarHeader(r io.Reader) (string, error) {
+	rd, err := carreader.New(io.NopCloser(r))
+	if err != nil {
+		return "", fmt.Errorf("failed to read CAR header: %w", err)
+	}
+	if len(rd.Header.Roots) != 1 {
+		return "", fmt.Errorf("expected 1 root CID, got %d", len(rd.Header.Roots))
+	}
+	return rd.Header.Roots[0].String(), nil
+}
+
+// verifyEpochAgainstManifest checks the local files of the epoch against the manifest.
+func verifyEpochAgainstManifest(config *Config, manifest *attestation.Manifest) error {
+	if *config.Epoch != manifest.Epoch {
+		return fmt.Errorf("epoch mismatch: config is for epoch %d, attestation for epoch %d", *config.Epoch, manifest.Epoch)
+	}
+	rootCid, err := epochRootCid(config)
+	if err != nil {
+		return err
+	}
+	if rootCid != manifest.RootCid {
+		return fmt.Errorf("root CID mismatch: expected %s, got %s", manifest.RootCid, rootCid)
+	}
+	var errs []error
+	if config.Data.Car != nil && config.Data.Car.URI.IsLocal() {
+		if manifest.Car == nil {
+			errs = append(errs, errors.New("the attestation has no CAR file digest"))
+		} else {
+			path, _ := localPath(config.Data.Car.URI)
+			klog.Infof("Checking CAR file %s ...", path)
+			if err := manifest.Car.Check(path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for _, index := range configIndexes(config) {
+		if !index.uri.IsLocal() {
+			klog.Warningf("Skipping %s index %s: not a local file", index.kind, index.uri)
+			continue
+		}
+		digest, ok := manifest.Index(index.kind)
+		if !ok {
+			errs = append(errs, fmt.Errorf("the attestation has no %s index digest", index.kind))
+			continue
+		}
+		path, _ := localPath(index.uri)
+		klog.Infof("Checking %s index %s ...", index.kind, path)
+		if err := digest.Check(path); err != nil {
+			errs = append(errs, fmt.Errorf("%s index: %w", index.kind, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/carwriter/bigtablesource"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_BackfillTxMeta() *cli.Command {
+	var (
+		epoch       uint64
+		network     indexes.Network
+		outputDir   string
+		tmpDir      string
+		rpcURL      string
+		bigtable    bigtablesource.Config
+		concurrency int
+	)
+	return &cli.Command{
+		Name:      "backfill-tx-meta",
+		Usage:     "Fetch the transaction metadata missing from a CAR from another source.",
+		ArgsUsage: "<car-path>",
+		Description: "Finds the transactions of an epoch CAR that have no metadata, fetches their metadata from an archival Solana RPC (--rpc) " +
+			"or from a Solana warehouse Bigtable instance (--bigtable-project and --bigtable-instance), and writes a meta overlay: " +
+			"a CAR with the transactions and the fetched metadata (<car>.meta-overlay.car), and its sig-to-offset-and-size index (<car>.meta-overlay.index). " +
+			"The RPC server returns the metadata of the overlay (meta_overlay in the epoch config) for the transactions that have none in the CAR.",
+		Before: func(c *cli.Context) error {
+			if network == "" {
+				network = indexes.NetworkMainnet
+			}
+			return nil
+		},
+		Flags: []cli.Flag{
+			&cli.Uint64Flag{
+				Name:        "epoch",
+				Usage:       "Epoch of the CAR",
+				Required:    true,
+				Destination: &epoch,
+			},
+			&cli.StringFlag{
+				Name:        "network",
+				Usage:       "Network of the CAR",
+				Destination: (*string)(&network),
+				Action: func(c *cli.Context, v string) error {
+					if !indexes.IsValidNetwork(indexes.Network(v)) {
+						return fmt.Errorf("invalid network: %s", v)
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:        "output-dir",
+				Usage:       "Destination directory for the meta overlay (default: the directory of the CAR)",
+				Destination: &outputDir,
+			},
+			&cli.StringFlag{
+				Name:        "tmp-dir",
+				Usage:       "Temporary directory for the index",
+				Value:       os.TempDir(),
+				Destination: &tmpDir,
+			},
+			&cli.StringFlag{
+				Name:        "rpc",
+				Usage:       "Fetch the metadata from this Solana RPC endpoint (with getTransaction)",
+				Destination: &rpcURL,
+			},
+			&cli.StringFlag{
+				Name:        "bigtable-project",
+				Usage:       "Fetch the metadata from the Bigtable instance in this GCP project",
+				Destination: &bigtable.Project,
+			},
+			&cli.StringFlag{
+				Name:        "bigtable-instance",
+				Usage:       "Bigtable instance (e.g. solana-ledger)",
+				Destination: &bigtable.Instance,
+			},
+			&cli.StringFlag{
+				Name:        "bigtable-app-profile",
+				Usage:       "Bigtable app profile",
+				Destination: &bigtable.AppProfile,
+			},
+			&cli.StringFlag{
+				Name:        "bigtable-endpoint",
+				Usage:       "Bigtable endpoint (default: " + bigtablesource.DefaultEndpoint + ", or $BIGTABLE_EMULATOR_HOST)",
+				Destination: &bigtable.Endpoint,
+			},
+			&cli.StringFlag{
+				Name:        "bigtable-credentials",
+				Usage:       "Service account key file (default: $GOOGLE_APPLICATION_CREDENTIALS, or the GCE metadata server)",
+				Destination: &bigtable.CredentialsFile,
+			},
+			&cli.StringFlag{
+				Name:        "bigtable-access-token",
+				Usage:       "OAuth2 access token to use instead of the credentials",
+				EnvVars:     []string{"BIGTABLE_ACCESS_TOKEN"},
+				Destination: &bigtable.AccessToken,
+			},
+			&cli.IntFlag{
+				Name:        "concurrency",
+				Usage:       "Number of slots to fetch the metadata of concurrently",
+				Value:       8,
+				Destination: &concurrency,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			carPath := c.Args().First()
+			if carPath == "" {
+				return cli.Exit("the path of the CAR is required", 1)
+			}
+			var source txMetaSource
+			switch {
+			case rpcURL != "" && bigtable.Project != "":
+				return cli.Exit("--rpc and --bigtable-project are mutually exclusive", 1)
+			case rpcURL != "":
+				source = newRPCTxMetaSource(rpcURL)
+			case bigtable.Project != "":
+				var err error
+				source, err = newBigtableTxMetaSource(bigtable)
+				if err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+			default:
+				return cli.Exit("a metadata source is required (--rpc or --bigtable-project)", 1)
+			}
+			defer source.Close()
+
+			if outputDir == "" {
+				outputDir = filepath.Dir(carPath)
+			} else if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+				return cli.Exit(fmt.Sprintf("failed to create output directory: %s", err), 1)
+			}
+			startedAt := time.Now()
+			stats, err := backfillTxMeta(c.Context, source, backfillTxMetaOptions{
+				carPath:     carPath,
+				epoch:       epoch,
+				network:     network,
+				outputDir:   outputDir,
+				tmpDir:      tmpDir,
+				concurrency: concurrency,
+			})
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			klog.Infof(
+				"Checked %s transactions in %s: %s without metadata, %s backfilled, %s not found in the source, %s too large",
+				humanize.Comma(int64(stats.transactions)),
+				time.Since(startedAt).Truncate(time.Second),
+				humanize.Comma(int64(stats.missing)),
+				humanize.Comma(int64(stats.backfilled.Load())),
+				humanize.Comma(int64(stats.notFound.Load())),
+				humanize.Comma(int64(stats.tooLarge)),
+			)
+			if stats.overlayCar != "" {
+				klog.Infof("Meta overlay: %s (index: %s)", stats.overlayCar, stats.overlayIndex)
+			}
+			return nil
+		},
+	}
+}
+
+type backfillTxMetaOptions struct {
+	carPath     string
+	epoch       uint64
+	network     indexes.Network
+	outputDir   string
+	tmpDir      string
+	concurrency int
+}
+
+type backfillTxMetaStats struct {
+	transactions uint64
+	missing      uint64
+	backfilled   atomic.Uint64
+	notFound     atomic.Uint64
+	tooLarge     uint64
+	// the paths of the meta overlay; empty if no metadata was backfilled.
+	overlayCar   string
+	overlayIndex string
+}
+
+// missingTxMeta is a transaction of the CAR without metadata.
+type missingTxMeta struct {
+	sig   solana.Signature
+	index int
+	data  []byte
+}
+
+// missingTxMetaSlot is the transactions of a block without metadata.
+type missingTxMetaSlot struct {
+	slot uint64
+	txs  []missingTxMeta
+}
+
+type backfilledTxMeta struct {
+	slot uint64
+	tx   missingTxMeta
+	meta []byte
+}
+
+// backfillTxMeta fetches the metadata of the transactions of the CAR that have none from the source,
+// and writes them to a meta overlay in the output directory.
+func backfillTxMeta(ctx context.Context, source txMetaSource, opts backfillTxMetaOptions) (*backfillTxMetaStats, error) {
+	if opts.concurrency <= 0 {
+		opts.concurrency = 1
+	}
+	root, err := readCarRoot(opts.carPath)
+	if err != nil {
+		return nil, err
+	}
+	stats := &backfillTxMetaStats{}
+	overlayPath := filepath.Join(opts.outputDir, filepath.Base(opts.carPath)+".meta-overlay.car")
+	overlay, err := carwriter.CreateMetaOverlay(overlayPath, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meta overlay: %w", err)
+	}
+	type indexEntry struct {
+		sig          solana.Signature
+		offset, size uint64
+	}
+	var written []indexEntry
+
+	g, gctx := errgroup.WithContext(ctx)
+	slots := make(chan missingTxMetaSlot, opts.concurrency)
+	backfilled := make(chan backfilledTxMeta, opts.concurrency)
+	g.Go(func() error {
+		defer close(slots)
+		return scanMissingTxMeta(opts.carPath, stats, func(slot missingTxMetaSlot) error {
+			select {
+			case slots <- slot:
+				return nil
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+		})
+	})
+	var fetchers sync.WaitGroup
+	for i := 0; i < opts.concurrency; i++ {
+		fetchers.Add(1)
+		g.Go(func() error {
+			defer fetchers.Done()
+			for slot := range slots {
+				sigs := make([]solana.Signature, len(slot.txs))
+				for i := range slot.txs {
+					sigs[i] = slot.txs[i].sig
+				}
+				metas, err := source.FetchMetas(gctx, slot.slot, sigs)
+				if err != nil {
+					return fmt.Errorf("failed to fetch the metadata of slot %d: %w", slot.slot, err)
+				}
+				for _, tx := range slot.txs {
+					meta, ok := metas[tx.sig]
+					if !ok {
+						stats.notFound.Add(1)
+						continue
+					}
+					select {
+					case backfilled <- backfilledTxMeta{slot: slot.slot, tx: tx, meta: meta}:
+					case <-gctx.Done():
+						return gctx.Err()
+					}
+				}
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		fetchers.Wait()
+		close(backfilled)
+		return nil
+	})
+	g.Go(func() error {
+		for b := range backfilled {
+			_, offset, size, err := overlay.WriteTransaction(b.slot, b.tx.index, &carwriter.Transaction{
+				Data:     b.tx.data,
+				Metadata: b.meta,
+			})
+			if errors.Is(err, carwriter.ErrMetaOverlayNodeTooLarge) {
+				klog.Warningf("The metadata of transaction %s is too large for the meta overlay", b.tx.sig)
+				stats.tooLarge++
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write transaction %s to the meta overlay: %w", b.tx.sig, err)
+			}
+			written = append(written, indexEntry{sig: b.tx.sig, offset: offset, size: size})
+			stats.backfilled.Add(1)
+		}
+		return nil
+	})
+	err = g.Wait()
+	if finishErr := overlay.Finish(); err == nil && finishErr != nil {
+		err = fmt.Errorf("failed to finish meta overlay: %w", finishErr)
+	}
+	if err == nil && len(written) == 0 {
+		klog.Infof("No metadata was backfilled: not writing a meta overlay")
+		err = os.Remove(overlayPath)
+		return stats, err
+	}
+	if err != nil {
+		os.Remove(overlayPath)
+		return nil, err
+	}
+
+	indexPath := filepath.Join(opts.outputDir, filepath.Base(opts.carPath)+".meta-overlay.index")
+	index, err := indexes.NewWriter_SigToOffsetAndSize(opts.epoch, root, opts.network, opts.tmpDir, uint64(len(written)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meta overlay index: %w", err)
+	}
+	for _, entry := range written {
+		if err := index.Put(entry.sig, entry.offset, entry.size); err != nil {
+			index.Close()
+			return nil, fmt.Errorf("failed to index transaction %s: %w", entry.sig, err)
+		}
+	}
+	if err := index.SealWithFilename(ctx, indexPath); err != nil {
+		return nil, fmt.Errorf("failed to seal meta overlay index: %w", err)
+	}
+	if err := index.Close(); err != nil {
+		return nil, err
+	}
+	stats.overlayCar, stats.overlayIndex = overlayPath, indexPath
+	return stats, nil
+}
+
+func readCarRoot(carPath string) (cid.Cid, error) {
+	file, err := os.Open(carPath)
+	if err != nil {
+		return cid.Undef, err
+	}
+	defer file.Close()
+	header, err := carreader.ReadHeader(file)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("failed to read CAR header: %w", err)
+	}
+	if len(header.Roots) != 1 {
+		return cid.Undef, fmt.Errorf("expected 1 root CID, got %d", len(header.Roots))
+	}
+	return header.Roots[0], nil
+}
+
+// scanMissingTxMeta calls fn with the transactions without metadata of each block of the CAR.
+func scanMissingTxMeta(carPath string, stats *backfillTxMetaStats, fn func(missingTxMetaSlot) error) error {
+	file, err := os.Open(carPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	rd, err := carreader.New(file)
+	if err != nil {
+		return fmt.Errorf("failed to open CAR: %w", err)
+	}
+	var pending *missingTxMetaSlot
+	for {
+		_, _, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(data) < 2 {
+			continue
+		}
+		switch iplddecoders.Kind(data[1]) {
+		case iplddecoders.KindTransaction:
+			node, err := iplddecoders.DecodeTransaction(data)
+			if err != nil {
+				return fmt.Errorf("failed to decode transaction: %w", err)
+			}
+			stats.transactions++
+			if total, ok := node.Metadata.GetTotal(); (ok && total > 1) || len(node.Metadata.Bytes()) > 0 {
+				continue
+			}
+			tx, err := node.GetSolanaTransaction()
+			if err != nil {
+				return fmt.Errorf("failed to decode transaction at slot %d: %w", node.Slot, err)
+			}
+			stats.missing++
+			slot := uint64(node.Slot)
+			if pending != nil && pending.slot != slot {
+				if err := fn(*pending); err != nil {
+					return err
+				}
+				pending = nil
+			}
+			if pending == nil {
+				pending = &missingTxMetaSlot{slot: slot}
+			}
+			index, _ := node.GetPositionIndex()
+			pending.txs = append(pending.txs, missingTxMeta{
+				sig:   tx.Signatures[0],
+				index: index,
+				data:  node.Data.Bytes(),
+			})
+		case iplddecoders.KindBlock:
+			if pending != nil {
+				if err := fn(*pending); err != nil {
+					return err
+				}
+				pending = nil
+			}
+		}
+	}
+	if pending != nil {
+		return fn(*pending)
+	}
+	return nil
+}
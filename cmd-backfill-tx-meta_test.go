@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// fakeArchivalRPC answers getTransaction with the metas of the map, and null for the other signatures.
+func fakeArchivalRPC(t *testing.T, slots map[solana.Signature]uint64, metas map[solana.Signature]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params []any           `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "getTransaction" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		sig := solana.MustSignatureFromBase58(req.Params[0].(string))
+		result := "null"
+		if meta, ok := metas[sig]; ok {
+			result = fmt.Sprintf(`{"slot":%d,"meta":%s}`, slots[sig], meta)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(req.ID) + `,"result":` + result + `}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBackfillTxMeta(t *testing.T) {
+	dir := t.TempDir()
+	carPath := filepath.Join(dir, "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+
+	slots := make(map[solana.Signature]uint64)
+	var missing []solana.Signature
+	for _, tx := range fixture.Transactions {
+		slots[tx.Signature] = tx.Slot
+		if tx.Spec.Meta == carfixture.MetaNone {
+			missing = append(missing, tx.Signature)
+		}
+	}
+	require.Len(t, missing, 2)
+	backfilled, unavailable := missing[0], missing[1]
+	srv := fakeArchivalRPC(t, slots, map[solana.Signature]string{
+		backfilled: `{
+			"err": {"InstructionError": [0, {"Custom": 1}]},
+			"fee": 5000,
+			"preBalances": [1000000000, 1],
+			"postBalances": [999995000, 1],
+			"innerInstructions": [{"index": 0, "instructions": [{"programIdIndex": 1, "accounts": [0], "data": "3Bxs4h24hBtQy9rw", "stackHeight": 2}]}],
+			"logMessages": ["Program 11111111111111111111111111111111 invoke [1]"],
+			"preTokenBalances": [],
+			"postTokenBalances": [],
+			"rewards": [],
+			"loadedAddresses": {"writable": [], "readonly": []},
+			"computeUnitsConsumed": 150
+		}`,
+	})
+
+	stats, err := backfillTxMeta(context.Background(), newRPCTxMetaSource(srv.URL), backfillTxMetaOptions{
+		carPath:     carPath,
+		epoch:       0,
+		network:     "mainnet",
+		outputDir:   dir,
+		tmpDir:      t.TempDir(),
+		concurrency: 2,
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(fixture.Transactions)), stats.transactions)
+	require.Equal(t, uint64(2), stats.missing)
+	require.Equal(t, uint64(1), stats.backfilled.Load())
+	require.Equal(t, uint64(1), stats.notFound.Load())
+	require.Equal(t, carPath+".meta-overlay.car", stats.overlayCar)
+
+	paths, _, _, err := buildTestEpochIndexes(carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+	config := testEpochConfig(t, carPath, paths)
+	config.MetaOverlay.Car.URI = URI(stats.overlayCar)
+	config.MetaOverlay.Index.URI = URI(stats.overlayIndex)
+	require.NoError(t, config.Validate())
+
+	epoch := openTestEpoch(t, config)
+	rootCid := epoch.rootCid
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+	getMeta := func(sig solana.Signature) map[string]any {
+		var req fasthttp.Request
+		req.SetRequestURI("/api/v1/tx/" + sig.String() + "?encoding=json&maxSupportedTransactionVersion=0")
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		multi.apiHandler(&reqCtx)
+		require.Equal(t, fasthttp.StatusOK, reqCtx.Response.StatusCode(), "%s", reqCtx.Response.Body())
+		var got struct {
+			Meta map[string]any `json:"meta"`
+		}
+		require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &got))
+		return got.Meta
+	}
+
+	meta := getMeta(backfilled)
+	require.NotNil(t, meta)
+	require.Equal(t, 5000.0, meta["fee"])
+	require.Equal(t, []any{"Program 11111111111111111111111111111111 invoke [1]"}, meta["logMessages"])
+	require.Equal(t, map[string]any{"InstructionError": []any{0.0, map[string]any{"Custom": 1.0}}}, meta["err"])
+	require.Len(t, meta["innerInstructions"], 1)
+	require.Equal(t, 150.0, meta["computeUnitsConsumed"])
+	require.Nil(t, getMeta(unavailable))
+
+	// the overlay must be for the epoch of the config.
+	_, err = openMetaOverlay(context.Background(), config, nil, 1, rootCid)
+	require.ErrorContains(t, err, "epoch mismatch in meta overlay index")
+
+	config = writeTestConfig(t, dir, "half.yml", `epoch: 0
+version: 1
+genesis:
+  hash: 5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d
+  creation_time: 1584368940
+data:
+  car:
+    uri: /tmp/epoch-0.car
+indexes:
+  slot_to_blocktime:
+    uri: /tmp/epoch-0-slot-to-blocktime.index
+  server:
+    uri: http://localhost:8890
+meta_overlay:
+  car:
+    uri: /tmp/epoch-0.car.meta-overlay.car
+`)
+	require.ErrorContains(t, config.Validate(), "meta_overlay.car.uri and meta_overlay.index.uri must be set together")
+}
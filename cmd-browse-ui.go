@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"golang.org/x/term"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+type browseKeyCode int
+
+const (
+	keyRune browseKeyCode = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyCtrlC
+)
+
+type browseKey struct {
+	code browseKeyCode
+	r    rune // for keyRune
+}
+
+// parseKeys parses the keys of the bytes read from a terminal in raw mode.
+func parseKeys(b []byte) []browseKey {
+	escapes := map[string]browseKeyCode{
+		"\x1b[A": keyUp, "\x1bOA": keyUp,
+		"\x1b[B": keyDown, "\x1bOB": keyDown,
+		"\x1b[5~": keyPageUp,
+		"\x1b[6~": keyPageDown,
+		"\x1b[H":  keyHome, "\x1bOH": keyHome, "\x1b[1~": keyHome,
+		"\x1b[F": keyEnd, "\x1bOF": keyEnd, "\x1b[4~": keyEnd,
+	}
+	var keys []browseKey
+	for len(b) > 0 {
+		if b[0] == 0x1b {
+			found := false
+			for seq, code := range escapes {
+				if strings.HasPrefix(string(b), seq) {
+					keys = append(keys, browseKey{code: code})
+					b = b[len(seq):]
+					found = true
+					break
+				}
+			}
+			if found {
+				continue
+			}
+			if len(b) > 1 && (b[1] == '[' || b[1] == 'O') {
+				// an unknown sequence: skip it up to its final byte.
+				end := 2
+				for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+					end++
+				}
+				b = b[min(end+1, len(b)):]
+				continue
+			}
+			keys = append(keys, browseKey{code: keyEscape})
+			b = b[1:]
+			continue
+		}
+		switch b[0] {
+		case '\r', '\n':
+			keys = append(keys, browseKey{code: keyEnter})
+		case 0x7f, 0x08:
+			keys = append(keys, browseKey{code: keyBackspace})
+		case 0x03:
+			keys = append(keys, browseKey{code: keyCtrlC})
+		default:
+			if b[0] >= 0x20 {
+				keys = append(keys, browseKey{code: keyRune, r: rune(b[0])})
+			}
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// browseScreen is a screen of the browser: a list of items, or of lines of text.
+type browseScreen struct {
+	title  []string
+	count  int
+	item   func(i int) string
+	cursor int
+	top    int
+	// open returns the screen of an item (on Enter); nil for text screens.
+	open func(i int) (*browseScreen, error)
+	// keys are the actions of the screen; an action returns the screen that replaces it.
+	keys map[rune]func() (*browseScreen, error)
+	help string
+}
+
+func textScreen(title []string, text string) *browseScreen {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	return &browseScreen{
+		title: title,
+		count: len(lines),
+		item:  func(i int) string { return lines[i] },
+		help:  "↑/↓ scroll  Esc back  q quit",
+	}
+}
+
+type browseUI struct {
+	browser *carBrowser
+	width   int
+	height  int
+	// screens is the stack of screens, the current one last.
+	screens []*browseScreen
+	// prompt is the jump target being typed, if promptActive.
+	prompt       string
+	promptActive bool
+	status       string
+	quit         bool
+}
+
+func newBrowseUI(browser *carBrowser, width, height int) *browseUI {
+	ui := &browseUI{browser: browser, width: width, height: height}
+	ui.screens = []*browseScreen{ui.slotsScreen()}
+	return ui
+}
+
+func (ui *browseUI) current() *browseScreen {
+	return ui.screens[len(ui.screens)-1]
+}
+
+// pageSize is the number of items shown, below the title and above the status and help lines.
+func (ui *browseUI) pageSize() int {
+	return max(ui.height-len(ui.current().title)-2, 1)
+}
+
+func (ui *browseUI) handle(key browseKey) {
+	if ui.promptActive {
+		ui.handlePrompt(key)
+		return
+	}
+	ui.status = ""
+	screen := ui.current()
+	switch key.code {
+	case keyCtrlC:
+		ui.quit = true
+	case keyUp:
+		ui.move(-1)
+	case keyDown:
+		ui.move(1)
+	case keyPageUp:
+		ui.move(-ui.pageSize())
+	case keyPageDown:
+		ui.move(ui.pageSize())
+	case keyHome:
+		ui.move(-screen.count)
+	case keyEnd:
+		ui.move(screen.count)
+	case keyEscape, keyBackspace:
+		ui.back()
+	case keyEnter:
+		if screen.open != nil && screen.count > 0 {
+			next, err := screen.open(screen.cursor)
+			ui.push(next, err)
+		}
+	case keyRune:
+		switch key.r {
+		case 'q':
+			ui.quit = true
+		case 'k':
+			ui.move(-1)
+		case 'j':
+			ui.move(1)
+		case 'g':
+			ui.move(-screen.count)
+		case 'G':
+			ui.move(screen.count)
+		case '/':
+			ui.promptActive = true
+			ui.prompt = ""
+		default:
+			if action, ok := screen.keys[key.r]; ok {
+				next, err := action()
+				if err != nil {
+					ui.status = err.Error()
+				} else if next != nil {
+					ui.screens[len(ui.screens)-1] = next
+				}
+			}
+		}
+	}
+}
+
+func (ui *browseUI) handlePrompt(key browseKey) {
+	switch key.code {
+	case keyEnter:
+		ui.promptActive = false
+		ui.jump(strings.TrimSpace(ui.prompt))
+	case keyEscape, keyCtrlC:
+		ui.promptActive = false
+	case keyBackspace:
+		if len(ui.prompt) > 0 {
+			ui.prompt = ui.prompt[:len(ui.prompt)-1]
+		}
+	case keyRune:
+		ui.prompt += string(key.r)
+	}
+}
+
+func (ui *browseUI) push(next *browseScreen, err error) {
+	if err != nil {
+		ui.status = err.Error()
+		return
+	}
+	ui.screens = append(ui.screens, next)
+}
+
+func (ui *browseUI) back() {
+	if len(ui.screens) > 1 {
+		ui.screens = ui.screens[:len(ui.screens)-1]
+	}
+}
+
+// move moves the cursor of a list, or scrolls a text.
+func (ui *browseUI) move(delta int) {
+	screen := ui.current()
+	if screen.open == nil {
+		screen.top = max(min(screen.top+delta, screen.count-ui.pageSize()), 0)
+		return
+	}
+	screen.cursor = max(min(screen.cursor+delta, screen.count-1), 0)
+	if screen.cursor < screen.top {
+		screen.top = screen.cursor
+	}
+	if screen.cursor >= screen.top+ui.pageSize() {
+		screen.top = screen.cursor - ui.pageSize() + 1
+	}
+}
+
+// jump opens the block of a slot, or the transaction of a signature.
+func (ui *browseUI) jump(target string) {
+	if target == "" {
+		return
+	}
+	slots := ui.screens[0]
+	if slot, err := strconv.ParseUint(target, 10, 64); err == nil {
+		i, ok := ui.browser.findSlot(slot)
+		if !ok {
+			ui.status = fmt.Sprintf("slot %d is after the last block of the CAR", slot)
+			return
+		}
+		block, err := ui.blockScreen(i)
+		if err != nil {
+			ui.status = err.Error()
+			return
+		}
+		ui.screens = []*browseScreen{slots, block}
+		ui.selectItem(slots, i)
+		if ui.browser.positions[i].Slot != slot {
+			ui.status = fmt.Sprintf("slot %d was skipped; showing the next block", slot)
+		}
+		return
+	}
+	sig, err := solana.SignatureFromBase58(target)
+	if err != nil {
+		ui.status = fmt.Sprintf("%q is neither a slot nor a signature", target)
+		return
+	}
+	i, txIndex, err := ui.browser.findSignature(context.Background(), sig)
+	if err != nil {
+		ui.status = err.Error()
+		return
+	}
+	block, err := ui.blockScreen(i)
+	if err != nil {
+		ui.status = err.Error()
+		return
+	}
+	tx, err := block.open(txIndex)
+	if err != nil {
+		ui.status = err.Error()
+		return
+	}
+	ui.screens = []*browseScreen{slots, block, tx}
+	ui.selectItem(slots, i)
+	ui.selectItem(block, txIndex)
+}
+
+func (ui *browseUI) selectItem(screen *browseScreen, i int) {
+	screen.cursor = i
+	screen.top = max(i-ui.pageSize()/2, 0)
+}
+
+func (ui *browseUI) slotsScreen() *browseScreen {
+	positions := ui.browser.positions
+	return &browseScreen{
+		title: []string{fmt.Sprintf("%d blocks, slots %d to %d", len(positions), positions[0].Slot, positions[len(positions)-1].Slot)},
+		count: len(positions),
+		item: func(i int) string {
+			return fmt.Sprintf("%12d  %s", positions[i].Slot, positions[i].Cid)
+		},
+		open: ui.blockScreen,
+		help: "↑/↓ select  Enter open  / go to slot or signature  q quit",
+	}
+}
+
+func (ui *browseUI) blockScreen(i int) (*browseScreen, error) {
+	block, err := ui.browser.loadBlock(i)
+	if err != nil {
+		return nil, err
+	}
+	title := []string{
+		fmt.Sprintf("Slot %d  parent %d  %s", block.Slot, block.ParentSlot, ui.browser.positions[i].Cid),
+		fmt.Sprintf("%s  %d entries  %d transactions  %s", formatBlockTime(block.BlockTime, block.BlockHeight), len(block.Entries), len(block.transactions), formatRewardsSummary(block)),
+	}
+	screen := &browseScreen{
+		title: title,
+		count: len(block.transactions),
+		item: func(j int) string {
+			tx := block.transactions[j]
+			status := "ok    "
+			fee := ""
+			switch {
+			case tx.metaErr != nil:
+				status = "meta? "
+			case tx.meta == nil:
+				status = "      "
+			case tx.meta.Failed():
+				status = "failed"
+			}
+			if tx.meta != nil {
+				fee = fmt.Sprintf("fee %d", tx.meta.Fee())
+			}
+			vote := ""
+			if tx.vote {
+				vote = "vote"
+			}
+			return fmt.Sprintf("%5d  %-88s  %s  %-4s  %s", j, tx.tx.Signatures[0], status, vote, fee)
+		},
+		open: func(j int) (*browseScreen, error) {
+			return transactionScreen(block, j), nil
+		},
+		keys: map[rune]func() (*browseScreen, error){
+			'n': func() (*browseScreen, error) { return ui.adjacentBlock(i + 1) },
+			'p': func() (*browseScreen, error) { return ui.adjacentBlock(i - 1) },
+		},
+		help: "↑/↓ select  Enter open  r rewards  n/p next/previous block  Esc back  q quit",
+	}
+	screen.keys['r'] = func() (*browseScreen, error) {
+		ui.push(rewardsScreen(block), nil)
+		return nil, nil
+	}
+	return screen, nil
+}
+
+func (ui *browseUI) adjacentBlock(i int) (*browseScreen, error) {
+	if i < 0 || i >= len(ui.browser.positions) {
+		return nil, fmt.Errorf("no more blocks")
+	}
+	ui.selectItem(ui.screens[0], i)
+	return ui.blockScreen(i)
+}
+
+func formatBlockTime(blockTime int64, blockHeight *uint64) string {
+	out := "time " + time.Unix(blockTime, 0).UTC().Format(time.RFC3339)
+	if blockHeight != nil {
+		out += fmt.Sprintf("  height %d", *blockHeight)
+	}
+	return out
+}
+
+func formatRewardsSummary(block *browsedBlock) string {
+	switch {
+	case block.rewardsErr != nil:
+		return "invalid rewards"
+	case block.numRewardPartitions != nil:
+		return fmt.Sprintf("%d rewards (%d partitions)", len(block.rewards), *block.numRewardPartitions)
+	default:
+		return fmt.Sprintf("%d rewards", len(block.rewards))
+	}
+}
+
+func transactionScreen(block *browsedBlock, j int) *browseScreen {
+	tx := block.transactions[j]
+	title := []string{fmt.Sprintf("Slot %d  transaction %d (entry %d)  %s", block.Slot, j, tx.entry, tx.tx.Signatures[0])}
+	var text strings.Builder
+	text.WriteString(tx.tx.String())
+	text.WriteString("\nMeta:\n")
+	switch {
+	case tx.metaErr != nil:
+		fmt.Fprintf(&text, "  invalid meta: %s\n", tx.metaErr)
+	case tx.meta == nil:
+		text.WriteString("  none\n")
+	default:
+		fmt.Fprintf(&text, "  failed: %t  fee: %d", tx.meta.Failed(), tx.meta.Fee())
+		if units, ok := tx.meta.ComputeUnitsConsumed(); ok {
+			fmt.Fprintf(&text, "  compute units: %d", units)
+		}
+		text.WriteString("\n")
+		text.WriteString(formatMeta(tx.meta))
+	}
+	return textScreen(title, text.String())
+}
+
+func formatMeta(meta *solanatxmetaparsers.TransactionStatusMetaContainer) string {
+	var (
+		out []byte
+		err error
+	)
+	switch {
+	case meta.IsProtobuf():
+		out, err = protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(meta.GetProtobuf())
+	case meta.IsSerdeLatest():
+		out, err = json.MarshalIndent(meta.GetSerdeLatest(), "", "  ")
+	default:
+		out, err = json.MarshalIndent(meta.GetSerdeOldest(), "", "  ")
+	}
+	if err != nil {
+		return fmt.Sprintf("failed to format meta: %s", err)
+	}
+	return string(out)
+}
+
+func rewardsScreen(block *browsedBlock) *browseScreen {
+	title := []string{fmt.Sprintf("Slot %d  %s", block.Slot, formatRewardsSummary(block))}
+	if block.rewardsErr != nil {
+		return textScreen(title, block.rewardsErr.Error())
+	}
+	if len(block.rewards) == 0 {
+		return textScreen(title, "no rewards")
+	}
+	lines := make([]string, len(block.rewards))
+	for i, reward := range block.rewards {
+		commission := ""
+		if reward.Commission != "" {
+			commission = "commission " + reward.Commission
+		}
+		lines[i] = fmt.Sprintf("%-44s  %-8s  %15d  post balance %d  %s",
+			reward.Pubkey, reward.RewardType, reward.Lamports, reward.PostBalance, commission)
+	}
+	return textScreen(title, strings.Join(lines, "\n"))
+}
+
+// render returns the lines of the screen.
+func (ui *browseUI) render() []string {
+	screen := ui.current()
+	var lines []string
+	for _, line := range screen.title {
+		lines = append(lines, "\x1b[1m"+fit(line, ui.width)+"\x1b[0m")
+	}
+	for i := screen.top; i < screen.top+ui.pageSize(); i++ {
+		if i >= screen.count {
+			lines = append(lines, "")
+			continue
+		}
+		line := fit(screen.item(i), ui.width)
+		if screen.open != nil && i == screen.cursor {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, fit(ui.status, ui.width))
+	if ui.promptActive {
+		lines = append(lines, fit("Go to slot or signature: "+ui.prompt, ui.width))
+	} else {
+		lines = append(lines, "\x1b[2m"+fit(screen.help, ui.width)+"\x1b[0m")
+	}
+	return lines
+}
+
+// fit truncates a line to the width of the terminal.
+func fit(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return line
+}
+
+// runBrowseUI runs the browser in the terminal until the user quits.
+func runBrowseUI(browser *carBrowser, in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set the terminal to raw mode: %w", err)
+	}
+	defer term.Restore(fd, state)
+	// the alternate screen, without cursor.
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return err
+	}
+	ui := newBrowseUI(browser, width, height)
+	buf := make([]byte, 64)
+	for !ui.quit {
+		if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			ui.width, ui.height = width, height
+		}
+		fmt.Fprint(out, "\x1b[H\x1b[2J"+strings.Join(ui.render(), "\r\n"))
+		n, err := in.Read(buf)
+		if err != nil {
+			return err
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			ui.handle(key)
+		}
+	}
+	return nil
+}
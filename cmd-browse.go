@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/text"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	solanablockrewards "github.com/rpcpool/yellowstone-faithful/solana-block-rewards"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_Browse() *cli.Command {
+	var (
+		carPath  string
+		indexDir string
+	)
+	return &cli.Command{
+		Name:  "browse",
+		Usage: "Browse the blocks, transactions and rewards of a CAR file in the terminal.",
+		Description: "Lists the blocks of the CAR; a block shows its transactions and rewards, and a transaction its decoded message and meta. " +
+			"'/' jumps to a slot or to a transaction signature. " +
+			"The CAR is scanned once at startup to find the blocks. The signatures are looked up in the sig-to-cid and " +
+			"cid-to-offset-and-size indexes of the CAR, if they are in --index-dir; otherwise the CAR is scanned.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "car",
+				Usage:       "Epoch CAR file (or split CAR piece)",
+				Required:    true,
+				Destination: &carPath,
+			},
+			&cli.StringFlag{
+				Name:        "index-dir",
+				Usage:       "Directory of the indexes of the CAR (default: the directory of the CAR)",
+				Destination: &indexDir,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+				return cli.Exit("browse must be run in a terminal", 1)
+			}
+			// the transactions are shown with their colors stripped, to fit the lines to the terminal.
+			text.DisableColors = true
+			if indexDir == "" {
+				indexDir = filepath.Dir(carPath)
+			}
+			klog.Infof("Scanning %s...", carPath)
+			browser, err := openCarBrowser(c.Context, carPath, indexDir)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			defer browser.Close()
+			klog.Infof("Found %d blocks", len(browser.positions))
+			if err := runBrowseUI(browser, os.Stdin, os.Stdout); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			return nil
+		},
+	}
+}
+
+// carBrowser reads the blocks of a CAR file for the browse command.
+type carBrowser struct {
+	source *carwriter.CarSource
+	// positions are the blocks of the CAR, in file (and slot) order.
+	positions []carwriter.BlockPosition
+	// the indexes of the CAR found in the index directory, or nil.
+	sigToCid    *indexes.SigToCid_Reader
+	cidToOffset *indexes.CidToOffsetAndSize_Reader
+}
+
+func openCarBrowser(ctx context.Context, carPath string, indexDir string) (*carBrowser, error) {
+	rootCid, err := carRootCid(carPath)
+	if err != nil {
+		return nil, err
+	}
+	b := &carBrowser{source: carwriter.NewCarSource(carPath)}
+	err = b.source.Positions(ctx, func(pos carwriter.BlockPosition) error {
+		b.positions = append(b.positions, pos)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", carPath, err)
+	}
+	if len(b.positions) == 0 {
+		return nil, fmt.Errorf("no blocks in %s", carPath)
+	}
+
+	// the index files are named after the root CID of the CAR.
+	if path := findIndexFile(indexDir, rootCid, "sig-to-cid"); path != "" {
+		if b.sigToCid, err = indexes.Open_SigToCid(path); err != nil {
+			return nil, fmt.Errorf("failed to open index %s: %w", path, err)
+		}
+	}
+	if path := findIndexFile(indexDir, rootCid, "cid-to-offset-and-size"); path != "" {
+		if b.cidToOffset, err = indexes.Open_CidToOffsetAndSize(path); err != nil {
+			b.Close()
+			return nil, fmt.Errorf("failed to open index %s: %w", path, err)
+		}
+	}
+	return b, nil
+}
+
+func carRootCid(carPath string) (cid.Cid, error) {
+	file, err := os.Open(carPath)
+	if err != nil {
+		return cid.Undef, err
+	}
+	defer file.Close()
+	rd, err := carreader.New(file)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("failed to open CAR %s: %w", carPath, err)
+	}
+	return rd.Header.Roots[0], nil
+}
+
+// findIndexFile returns the path of the index of the given kind of the CAR with the root CID
+// in dir, or "" if there is none.
+func findIndexFile(dir string, rootCid cid.Cid, kind string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, fmt.Sprintf("epoch-*-%s-*-%s.index", rootCid, kind)))
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[0]
+}
+
+func (b *carBrowser) Close() error {
+	if b.sigToCid != nil {
+		b.sigToCid.Close()
+	}
+	if b.cidToOffset != nil {
+		b.cidToOffset.Close()
+	}
+	return b.source.Close()
+}
+
+// findSlot returns the index of the block of the slot, or of the next block if the slot was skipped.
+func (b *carBrowser) findSlot(slot uint64) (int, bool) {
+	i := sort.Search(len(b.positions), func(i int) bool { return b.positions[i].Slot >= slot })
+	return i, i < len(b.positions)
+}
+
+var errSignatureNotFound = errors.New("signature not found in the CAR")
+
+// findSignature returns the index of the block of the transaction with the signature, and the
+// index of the transaction in the block.
+func (b *carBrowser) findSignature(ctx context.Context, sig solana.Signature) (int, int, error) {
+	if b.sigToCid != nil {
+		txCid, err := b.sigToCid.Get(sig)
+		if err != nil {
+			return 0, 0, errSignatureNotFound
+		}
+		if b.cidToOffset != nil {
+			offsetAndSize, err := b.cidToOffset.Get(txCid)
+			if err != nil {
+				return 0, 0, errSignatureNotFound
+			}
+			i := sort.Search(len(b.positions), func(i int) bool {
+				return b.positions[i].Offset+b.positions[i].Length > offsetAndSize.Offset
+			})
+			if i == len(b.positions) {
+				return 0, 0, errSignatureNotFound
+			}
+			block, err := b.loadBlock(i)
+			if err != nil {
+				return 0, 0, err
+			}
+			// the index can return a CID for a signature it doesn't have.
+			for j, tx := range block.transactions {
+				if len(tx.tx.Signatures) > 0 && tx.tx.Signatures[0] == sig {
+					return i, j, nil
+				}
+			}
+			return 0, 0, errSignatureNotFound
+		}
+	}
+
+	errFound := errors.New("found")
+	blockIndex, txIndex := 0, 0
+	err := b.source.Blocks(ctx, 0, math.MaxUint64, func(block *carwriter.Block) error {
+		txIndex = 0
+		for _, entry := range block.Entries {
+			for _, tx := range entry.Transactions {
+				if first, err := readFirstSignature(tx.Data); err == nil && first == sig {
+					return errFound
+				}
+				txIndex++
+			}
+		}
+		blockIndex++
+		return nil
+	})
+	if errors.Is(err, errFound) {
+		return blockIndex, txIndex, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return 0, 0, errSignatureNotFound
+}
+
+// browsedBlock is a block of the CAR, with its transactions and rewards decoded.
+type browsedBlock struct {
+	*carwriter.Block
+	transactions []browsedTransaction
+	rewards      []*confirmed_block.Reward
+	// numRewardPartitions is set if the rewards of the epoch are partitioned.
+	numRewardPartitions *uint64
+	rewardsErr          error
+}
+
+type browsedTransaction struct {
+	entry int
+	tx    *solana.Transaction
+	vote  bool
+	// meta is nil if the transaction has no meta or if it can't be parsed (metaErr).
+	meta    *solanatxmetaparsers.TransactionStatusMetaContainer
+	metaErr error
+}
+
+func (b *carBrowser) loadBlock(i int) (*browsedBlock, error) {
+	block, err := b.source.ReadBlock(b.positions[i])
+	if err != nil {
+		return nil, err
+	}
+	browsed := &browsedBlock{Block: block}
+	for entryIndex, entry := range block.Entries {
+		for _, tx := range entry.Transactions {
+			var decoded solana.Transaction
+			if err := decoded.UnmarshalWithDecoder(bin.NewBinDecoder(tx.Data)); err != nil {
+				return nil, fmt.Errorf("slot %d: failed to decode transaction %d: %w", block.Slot, len(browsed.transactions), err)
+			}
+			btx := browsedTransaction{
+				entry: entryIndex,
+				tx:    &decoded,
+				vote:  nodetools.IsSimpleVoteTransaction(&decoded),
+			}
+			if len(tx.Metadata) > 0 {
+				btx.meta, btx.metaErr = parseBrowsedMeta(tx.Metadata)
+			}
+			browsed.transactions = append(browsed.transactions, btx)
+		}
+	}
+	if len(block.Rewards) > 0 {
+		browsed.rewards, browsed.numRewardPartitions, browsed.rewardsErr = parseBrowsedRewards(block.Rewards)
+	}
+	return browsed, nil
+}
+
+func parseBrowsedMeta(data []byte) (*solanatxmetaparsers.TransactionStatusMetaContainer, error) {
+	uncompressed, err := tooling.DecompressZstd(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress meta: %w", err)
+	}
+	return solanatxmetaparsers.ParseTransactionStatusMetaContainer(uncompressed)
+}
+
+func parseBrowsedRewards(data []byte) ([]*confirmed_block.Reward, *uint64, error) {
+	uncompressed, err := tooling.DecompressZstd(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress rewards: %w", err)
+	}
+	rewards, err := solanablockrewards.ParseRewards(uncompressed)
+	if err != nil {
+		return nil, nil, err
+	}
+	numPartitions, ok, err := solanablockrewards.NumPartitions(uncompressed)
+	if err != nil || !ok {
+		return rewards.GetRewards(), nil, err
+	}
+	return rewards.GetRewards(), &numPartitions, nil
+}
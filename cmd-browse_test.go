@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeys(t *testing.T) {
+	require.Equal(t, []browseKey{
+		{code: keyUp},
+		{code: keyDown},
+		{code: keyPageDown},
+		{code: keyRune, r: 'q'},
+		{code: keyEnter},
+		{code: keyEscape},
+		{code: keyBackspace},
+	}, parseKeys([]byte("\x1b[A\x1bOB\x1b[6~q\r\x1b\x7f")))
+	// unknown sequences are skipped.
+	require.Equal(t, []browseKey{{code: keyRune, r: 'j'}}, parseKeys([]byte("\x1b[1;5Cj")))
+}
+
+func TestCarBrowserFindSignature(t *testing.T) {
+	_, paths, slots, sigs := buildTestEpoch(t)
+	carPath := filepath.Join("fixtures", "epoch-0-1.car")
+
+	withIndexes, err := openCarBrowser(context.Background(), carPath, filepath.Dir(paths.SignatureToCid))
+	require.NoError(t, err)
+	defer withIndexes.Close()
+	require.NotNil(t, withIndexes.sigToCid)
+	require.NotNil(t, withIndexes.cidToOffset)
+
+	// without the indexes, the CAR is scanned.
+	withoutIndexes, err := openCarBrowser(context.Background(), carPath, t.TempDir())
+	require.NoError(t, err)
+	defer withoutIndexes.Close()
+	require.Nil(t, withoutIndexes.sigToCid)
+
+	for _, browser := range []*carBrowser{withIndexes, withoutIndexes} {
+		require.Len(t, browser.positions, len(slots))
+		for _, sig := range sigs {
+			i, j, err := browser.findSignature(context.Background(), sig)
+			require.NoError(t, err)
+			block, err := browser.loadBlock(i)
+			require.NoError(t, err)
+			require.Equal(t, sig, block.transactions[j].tx.Signatures[0])
+		}
+		_, _, err := browser.findSignature(context.Background(), solana.Signature{1})
+		require.ErrorIs(t, err, errSignatureNotFound)
+	}
+
+	i, ok := withIndexes.findSlot(slots[3])
+	require.True(t, ok)
+	require.Equal(t, 3, i)
+	_, ok = withIndexes.findSlot(slots[len(slots)-1] + 1)
+	require.False(t, ok)
+}
+
+func TestBrowseUI(t *testing.T) {
+	_, paths, slots, sigs := buildTestEpoch(t)
+	browser, err := openCarBrowser(context.Background(), filepath.Join("fixtures", "epoch-0-1.car"), filepath.Dir(paths.SignatureToCid))
+	require.NoError(t, err)
+	defer browser.Close()
+
+	ui := newBrowseUI(browser, 200, 20)
+	screen := func() string { return strings.Join(ui.render(), "\n") }
+	press := func(b string) {
+		for _, key := range parseKeys([]byte(b)) {
+			ui.handle(key)
+		}
+	}
+	require.Contains(t, screen(), "10 blocks")
+
+	// open the second block, then the next one.
+	press("j\r")
+	require.Contains(t, ui.current().title[0], "Slot "+strconv.FormatUint(slots[1], 10)+" ")
+	press("n")
+	require.Contains(t, ui.current().title[0], "Slot "+strconv.FormatUint(slots[2], 10)+" ")
+	require.Equal(t, 2, ui.screens[0].cursor)
+	press("r")
+	require.Contains(t, screen(), "rewards")
+	press("\x1b\x1b")
+	require.Len(t, ui.screens, 1)
+
+	// jump to a transaction.
+	press("/" + sigs[5].String() + "\r")
+	require.Empty(t, ui.status)
+	require.Len(t, ui.screens, 3)
+	require.Contains(t, screen(), sigs[5].String())
+	tx := ui.current()
+	var lines []string
+	for i := 0; i < tx.count; i++ {
+		lines = append(lines, tx.item(i))
+	}
+	require.Contains(t, lines, "Meta:")
+	press("G")
+	require.Equal(t, tx.count-ui.pageSize(), tx.top)
+
+	press("/notaslot\r")
+	require.Contains(t, ui.status, "neither a slot nor a signature")
+	press("/" + strconv.FormatUint(slots[len(slots)-1]+1, 10) + "\r")
+	require.Contains(t, ui.status, "after the last block")
+
+	press("q")
+	require.True(t, ui.quit)
+}
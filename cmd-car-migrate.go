@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/urfave/cli/v2"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_CarMigrate() *cli.Command {
+	var outputFile string
+	var reportFile string
+	var firstBlockHeight int64
+	return &cli.Command{
+		Name:  "car-migrate",
+		Usage: "Upgrade an epoch CAR file written with an older layout.",
+		Description: "Older epochs were produced with earlier schema quirks. This reads the blocks of an old CAR file and writes them to a new one in the canonical layout of create-car, normalizing the fields that can be derived: " +
+			"the missing block heights are filled from the heights of the other blocks of the CAR (they grow by one from block to block, so the CAR must have all the blocks of its range), " +
+			"and the legacy (serde) transaction metas are converted to protobuf. A legacy meta with inner instructions is kept as it is. " +
+			"What changed is recorded in a JSON migration report. The new CAR is validated, and its indexes need to be re-generated.",
+		ArgsUsage: "<car>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output-file",
+				Aliases:     []string{"o"},
+				Usage:       "Output file name",
+				Required:    true,
+				Destination: &outputFile,
+			},
+			&cli.StringFlag{
+				Name:        "report",
+				Usage:       "Write the JSON migration report to this file (default: the output file name with a .migration.json suffix)",
+				Destination: &reportFile,
+			},
+			&cli.Int64Flag{
+				Name:        "first-block-height",
+				Usage:       "Block height of the first block of the CAR, if it has none; needed when no block of the CAR has a height",
+				Value:       -1,
+				Destination: &firstBlockHeight,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() != 1 {
+				return cli.Exit("expected exactly one CAR file", 1)
+			}
+			if reportFile == "" {
+				reportFile = outputFile + ".migration.json"
+			}
+			var firstHeight *uint64
+			if firstBlockHeight >= 0 {
+				h := uint64(firstBlockHeight)
+				firstHeight = &h
+			}
+			report, err := migrateCar(c.Context, c.Args().First(), outputFile, firstHeight)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			encoded, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(reportFile, append(encoded, '\n'), 0o644); err != nil {
+				return cli.Exit(fmt.Sprintf("failed to write report: %s", err), 1)
+			}
+			stats, err := carwriter.ValidateCanonical(outputFile)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("the migrated CAR is not valid: %s", err), 1)
+			}
+			klog.Infof("Wrote %s: %d blocks, %d transactions; root %s (was %s)", outputFile, stats.Blocks, stats.Transactions, report.OutputRoot, report.InputRoot)
+			for _, change := range report.Changes {
+				klog.Infof("%s: %d (slots %d-%d)", change.Kind, change.Count, change.FirstSlot, change.LastSlot)
+			}
+			klog.Infof("Wrote the migration report to %s", reportFile)
+			return nil
+		},
+	}
+}
+
+// The kinds of the changes of a migration.
+const (
+	migrationBlockHeightFromFlag     = "block_height_from_flag"
+	migrationBlockHeightFilled       = "block_height_filled"
+	migrationBlockHeightNotDerivable = "block_height_not_derivable"
+	migrationLegacyMetaUpgraded      = "legacy_meta_upgraded"
+	migrationLegacyMetaKept          = "legacy_meta_kept"
+	migrationMetaNotDecodable        = "meta_not_decodable"
+)
+
+// migrationKinds is the order of the changes in the report.
+var migrationKinds = []string{
+	migrationBlockHeightFromFlag,
+	migrationBlockHeightFilled,
+	migrationBlockHeightNotDerivable,
+	migrationLegacyMetaUpgraded,
+	migrationLegacyMetaKept,
+	migrationMetaNotDecodable,
+}
+
+// migrationReport is the machine-readable result of migrateCar.
+type migrationReport struct {
+	Input        string            `json:"input"`
+	Output       string            `json:"output"`
+	InputRoot    string            `json:"input_root"`
+	OutputRoot   string            `json:"output_root"`
+	Epoch        uint64            `json:"epoch"`
+	Blocks       int               `json:"blocks"`
+	Transactions int               `json:"transactions"`
+	Changes      []migrationChange `json:"changes"`
+}
+
+// migrationChange counts the blocks (for the block heights) or transactions (for the metas)
+// with a kind of change.
+type migrationChange struct {
+	Kind      string `json:"kind"`
+	Count     int    `json:"count"`
+	FirstSlot uint64 `json:"first_slot"`
+	LastSlot  uint64 `json:"last_slot"`
+}
+
+func (r *migrationReport) add(kind string, slot uint64) {
+	for i := range r.Changes {
+		if r.Changes[i].Kind == kind {
+			r.Changes[i].Count++
+			r.Changes[i].LastSlot = slot
+			return
+		}
+	}
+	r.Changes = append(r.Changes, migrationChange{Kind: kind, Count: 1, FirstSlot: slot, LastSlot: slot})
+	sort.SliceStable(r.Changes, func(i, j int) bool {
+		return migrationKindOrder(r.Changes[i].Kind) < migrationKindOrder(r.Changes[j].Kind)
+	})
+}
+
+func migrationKindOrder(kind string) int {
+	for i, k := range migrationKinds {
+		if k == kind {
+			return i
+		}
+	}
+	return len(migrationKinds)
+}
+
+// count returns the number of changes of the kind.
+func (r *migrationReport) count(kind string) int {
+	for _, change := range r.Changes {
+		if change.Kind == kind {
+			return change.Count
+		}
+	}
+	return 0
+}
+
+// knownHeight is the block height of the block at an index of the CAR (in file order).
+type knownHeight struct {
+	index  int
+	height uint64
+}
+
+// blockHeights derives the missing block heights of a CAR from the known ones.
+type blockHeights struct {
+	// known is sorted by index.
+	known []knownHeight
+}
+
+// derive returns the height of the block at the index, from the closest known heights: counting
+// from the previous one, or back from the next one. Between two known heights, it is only derived
+// if they are consistent (as many blocks as the difference of their heights).
+func (h *blockHeights) derive(index int) (uint64, bool) {
+	next := sort.Search(len(h.known), func(i int) bool { return h.known[i].index >= index })
+	if next < len(h.known) && h.known[next].index == index {
+		return h.known[next].height, true
+	}
+	switch {
+	case next > 0 && next < len(h.known):
+		p, n := h.known[next-1], h.known[next]
+		if n.height < p.height || n.height-p.height != uint64(n.index-p.index) {
+			return 0, false
+		}
+		return p.height + uint64(index-p.index), true
+	case next > 0:
+		p := h.known[next-1]
+		return p.height + uint64(index-p.index), true
+	case next < len(h.known):
+		n := h.known[next]
+		if n.height < uint64(n.index-index) {
+			return 0, false
+		}
+		return n.height - uint64(n.index-index), true
+	}
+	return 0, false
+}
+
+// scanBlockHeights reads the block heights of the blocks of the CAR, without decoding their DAGs.
+func scanBlockHeights(ctx context.Context, path string) (*blockHeights, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rd, err := carreader.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CAR %q: %w", path, err)
+	}
+	heights := &blockHeights{}
+	index := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		c, _, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			return heights, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if iplddecoders.Kind(data[1]) != iplddecoders.KindBlock {
+			continue
+		}
+		block, err := iplddecoders.DecodeBlock(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode block %s: %w", c, err)
+		}
+		if block.Meta.Block_height != nil && *block.Meta.Block_height != nil {
+			heights.known = append(heights.known, knownHeight{index: index, height: uint64(**block.Meta.Block_height)})
+		}
+		index++
+	}
+}
+
+// migrateCar writes the blocks of the CAR at inputPath to a new CAR at outputPath, with the missing
+// block heights filled (see blockHeights) and the legacy metas converted to protobuf. firstHeight,
+// if not nil, is the height of the first block if it has none.
+func migrateCar(ctx context.Context, inputPath string, outputPath string, firstHeight *uint64) (*migrationReport, error) {
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	rd, err := carreader.New(input)
+	input.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CAR %q: %w", inputPath, err)
+	}
+	if len(rd.Header.Roots) != 1 {
+		return nil, fmt.Errorf("expected 1 root, got %d", len(rd.Header.Roots))
+	}
+	report := &migrationReport{
+		Input:     inputPath,
+		Output:    outputPath,
+		InputRoot: rd.Header.Roots[0].String(),
+		Changes:   []migrationChange{},
+	}
+
+	heights, err := scanBlockHeights(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if firstHeight != nil && (len(heights.known) == 0 || heights.known[0].index > 0) {
+		heights.known = append([]knownHeight{{index: 0, height: *firstHeight}}, heights.known...)
+	}
+
+	var w *carwriter.Writer
+	index := 0
+	err = carwriter.NewCarSource(inputPath).Blocks(ctx, 0, math.MaxUint64, func(block *carwriter.Block) error {
+		if w == nil {
+			report.Epoch = slottools.CalcEpochForSlot(block.Slot)
+			created, err := carwriter.Create(outputPath, report.Epoch, carwriter.DefaultMaxSubsetBlocks)
+			if err != nil {
+				return err
+			}
+			w = created
+		}
+		if block.BlockHeight == nil {
+			if height, ok := heights.derive(index); ok {
+				block.BlockHeight = &height
+				if index == 0 && firstHeight != nil {
+					report.add(migrationBlockHeightFromFlag, block.Slot)
+				} else {
+					report.add(migrationBlockHeightFilled, block.Slot)
+				}
+			} else {
+				report.add(migrationBlockHeightNotDerivable, block.Slot)
+			}
+		}
+		for i := range block.Entries {
+			for j := range block.Entries[i].Transactions {
+				tx := &block.Entries[i].Transactions[j]
+				metadata, kind, err := migrateMeta(tx.Metadata)
+				if err != nil {
+					return fmt.Errorf("slot %d: %w", block.Slot, err)
+				}
+				tx.Metadata = metadata
+				if kind != "" {
+					report.add(kind, block.Slot)
+				}
+				report.Transactions++
+			}
+		}
+		if _, err := w.WriteBlock(block); err != nil {
+			return err
+		}
+		report.Blocks++
+		index++
+		return nil
+	})
+	if err != nil {
+		if w != nil {
+			w.Close()
+		}
+		return nil, err
+	}
+	if w == nil {
+		return nil, fmt.Errorf("%s has no blocks", inputPath)
+	}
+	root, err := w.Finish()
+	if err != nil {
+		return nil, err
+	}
+	report.OutputRoot = root.String()
+	return report, nil
+}
+
+// migrateMeta returns the meta (as stored in the CAR) in the protobuf format, and the kind of the
+// change; a meta that is already protobuf (or missing) is returned as it is, without a change.
+func migrateMeta(metadata []byte) ([]byte, string, error) {
+	if len(metadata) == 0 {
+		return metadata, "", nil
+	}
+	raw, err := tooling.DecompressZstd(metadata)
+	if err != nil {
+		return metadata, migrationMetaNotDecodable, nil
+	}
+	container, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(raw)
+	if err != nil {
+		return metadata, migrationMetaNotDecodable, nil
+	}
+	if container.IsProtobuf() {
+		return metadata, "", nil
+	}
+	meta, err := container.ToProtobuf()
+	if errors.Is(err, solanatxmetaparsers.ErrNotUpgradable) {
+		return metadata, migrationLegacyMetaKept, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	encoded, err := proto.Marshal(meta)
+	if err != nil {
+		return nil, "", err
+	}
+	compressed, err := tooling.CompressZstd(encoded)
+	if err != nil {
+		return nil, "", err
+	}
+	return compressed, migrationLegacyMetaUpgraded, nil
+}
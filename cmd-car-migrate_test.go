@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockHeightsDerive(t *testing.T) {
+	heights := &blockHeights{known: []knownHeight{{index: 2, height: 12}, {index: 5, height: 15}, {index: 7, height: 20}}}
+	for _, tc := range []struct {
+		index  int
+		height uint64
+		ok     bool
+	}{
+		{0, 10, true},
+		{2, 12, true},
+		{3, 13, true},
+		{6, 0, false}, // 15 and 20 are 5 apart, but only 2 blocks apart.
+		{9, 22, true},
+	} {
+		height, ok := heights.derive(tc.index)
+		require.Equal(t, tc.ok, ok, "index %d", tc.index)
+		require.Equal(t, tc.height, height, "index %d", tc.index)
+	}
+	_, ok := (&blockHeights{known: []knownHeight{{index: 3, height: 1}}}).derive(0)
+	require.False(t, ok)
+	_, ok = (&blockHeights{}).derive(0)
+	require.False(t, ok)
+}
+
+func readMigratedBlocks(t *testing.T, path string) []*carwriter.Block {
+	t.Helper()
+	var blocks []*carwriter.Block
+	require.NoError(t, carwriter.NewCarSource(path).Blocks(context.Background(), 0, math.MaxUint64, func(block *carwriter.Block) error {
+		blocks = append(blocks, block)
+		return nil
+	}))
+	return blocks
+}
+
+func TestMigrateCar(t *testing.T) {
+	dir := t.TempDir()
+	fixture, err := carfixture.Generate(filepath.Join(dir, "fixture.car"), carfixture.DefaultBlocks())
+	require.NoError(t, err)
+
+	// an old CAR: some blocks without a height.
+	old := filepath.Join(dir, "old.car")
+	w, err := carwriter.Create(old, fixture.Epoch, carwriter.DefaultMaxSubsetBlocks)
+	require.NoError(t, err)
+	for i, block := range readMigratedBlocks(t, fixture.Path) {
+		if i == 0 || i == 2 || i == 4 {
+			block.BlockHeight = nil
+		}
+		_, err := w.WriteBlock(block)
+		require.NoError(t, err)
+	}
+	_, err = w.Finish()
+	require.NoError(t, err)
+
+	migrated := filepath.Join(dir, "migrated.car")
+	report, err := migrateCar(context.Background(), old, migrated, nil)
+	require.NoError(t, err)
+	_, err = carwriter.ValidateCanonical(migrated)
+	require.NoError(t, err)
+	require.Equal(t, len(fixture.Blocks), report.Blocks)
+	require.Equal(t, len(fixture.Transactions), report.Transactions)
+	require.Equal(t, 3, report.count(migrationBlockHeightFilled))
+	require.Equal(t, 3, report.count(migrationLegacyMetaUpgraded))
+	require.Zero(t, report.count(migrationBlockHeightNotDerivable))
+	require.Zero(t, report.count(migrationLegacyMetaKept))
+	require.Equal(t, []string{migrationBlockHeightFilled, migrationLegacyMetaUpgraded}, []string{report.Changes[0].Kind, report.Changes[1].Kind})
+	require.Equal(t, uint64(0), report.Changes[0].FirstSlot)
+	require.Equal(t, uint64(5), report.Changes[0].LastSlot)
+
+	blocks := readMigratedBlocks(t, migrated)
+	require.Len(t, blocks, len(fixture.Blocks))
+	for i, block := range blocks {
+		original := fixture.Blocks[i]
+		require.Equal(t, original.Slot, block.Slot)
+		require.Equal(t, *original.BlockHeight, *block.BlockHeight)
+		require.Equal(t, original.Rewards, block.Rewards)
+		for j, entry := range block.Entries {
+			for k, tx := range entry.Transactions {
+				originalTx := original.Entries[j].Transactions[k]
+				require.Equal(t, originalTx.Data, tx.Data)
+				if len(originalTx.Metadata) == 0 {
+					require.Empty(t, tx.Metadata)
+					continue
+				}
+				raw, err := tooling.DecompressZstd(tx.Metadata)
+				require.NoError(t, err)
+				meta, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(raw)
+				require.NoError(t, err)
+				require.True(t, meta.IsProtobuf())
+
+				raw, err = tooling.DecompressZstd(originalTx.Metadata)
+				require.NoError(t, err)
+				originalMeta, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(raw)
+				require.NoError(t, err)
+				require.Equal(t, originalMeta.Fee(), meta.Fee())
+				require.Equal(t, originalMeta.Failed(), meta.Failed())
+				if originalMeta.IsProtobuf() {
+					require.Equal(t, originalTx.Metadata, tx.Metadata)
+				} else {
+					require.Equal(t, originalMeta.GetSerdeLatest().PostBalances, meta.GetProtobuf().PostBalances)
+					require.True(t, meta.GetProtobuf().LogMessagesNone)
+				}
+			}
+		}
+	}
+
+	// migrating again changes nothing.
+	again := filepath.Join(dir, "again.car")
+	report2, err := migrateCar(context.Background(), migrated, again, nil)
+	require.NoError(t, err)
+	require.Empty(t, report2.Changes)
+	require.Equal(t, report.OutputRoot, report2.InputRoot)
+	require.Equal(t, report.OutputRoot, report2.OutputRoot)
+
+	// without any height, only with --first-block-height.
+	noHeights := filepath.Join(dir, "no-heights.car")
+	w, err = carwriter.Create(noHeights, fixture.Epoch, carwriter.DefaultMaxSubsetBlocks)
+	require.NoError(t, err)
+	for _, block := range readMigratedBlocks(t, fixture.Path) {
+		block.BlockHeight = nil
+		_, err := w.WriteBlock(block)
+		require.NoError(t, err)
+	}
+	_, err = w.Finish()
+	require.NoError(t, err)
+	report, err = migrateCar(context.Background(), noHeights, filepath.Join(dir, "no-heights-migrated.car"), nil)
+	require.NoError(t, err)
+	require.Equal(t, len(fixture.Blocks), report.count(migrationBlockHeightNotDerivable))
+	firstHeight := uint64(100)
+	withFlag := filepath.Join(dir, "with-flag.car")
+	report, err = migrateCar(context.Background(), noHeights, withFlag, &firstHeight)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.count(migrationBlockHeightFromFlag))
+	require.Equal(t, len(fixture.Blocks)-1, report.count(migrationBlockHeightFilled))
+	for i, block := range readMigratedBlocks(t, withFlag) {
+		require.Equal(t, firstHeight+uint64(i), *block.BlockHeight)
+	}
+}
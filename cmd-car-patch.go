@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/ipfs/go-cid"
+	carv1 "github.com/ipld/go-car"
+	"github.com/ipld/go-car/util"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_CarPatch() *cli.Command {
+	var outputFile string
+	var strictFlag string
+	var overlay bool
+	var network indexes.Network
+	var tmpDir string
+	return &cli.Command{
+		Name:  "car-patch",
+		Usage: "Replace some blocks of an epoch CAR file with corrected ones.",
+		Description: "Streams the original epoch CAR file to a new one, replacing the DAG of each block that has a patch (a CAR file with the corrected DAG of a single block: the block node and all the nodes it links to). All the other nodes are copied as they are; the subset and epoch nodes that link to a replaced block are re-encoded. " +
+			"With --overlay, the original CAR is left as it is: the patches are written to an overlay CAR and its cid-to-offset-and-size index (<output file>.index), which the RPC server reads before the epoch CAR (overlay in the epoch config).",
+		Before: func(c *cli.Context) error {
+			if network == "" {
+				network = indexes.NetworkMainnet
+			}
+			return nil
+		},
+		ArgsUsage: "<original epoch car> <patch car>...",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output-file",
+				Aliases:     []string{"o"},
+				Usage:       "Output file name",
+				Required:    true,
+				Destination: &outputFile,
+			},
+			&cli.StringFlag{
+				Name:        "strict",
+				Usage:       "check the CID of every node of the original CAR: 'codec' (dag-cbor, sha2-256 CIDv1) or 'hash' (also recompute the hash); the nodes of the patches are always fully checked",
+				Destination: &strictFlag,
+			},
+			&cli.BoolFlag{
+				Name:        "overlay",
+				Usage:       "write an overlay of the epoch CAR with the patches, instead of a patched copy",
+				Destination: &overlay,
+			},
+			&cli.StringFlag{
+				Name:        "network",
+				Usage:       "network of the CAR (for the index of the overlay)",
+				Destination: (*string)(&network),
+				Action: func(c *cli.Context, v string) error {
+					if !indexes.IsValidNetwork(indexes.Network(v)) {
+						return fmt.Errorf("invalid network: %s", v)
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:        "tmp-dir",
+				Usage:       "temporary directory for the index of the overlay",
+				Value:       os.TempDir(),
+				Destination: &tmpDir,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() < 2 {
+				return cli.Exit("expected the original CAR file and at least one patch CAR file", 1)
+			}
+			strictness, err := iplddecoders.ParseStrictness(strictFlag)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			patches, err := loadBlockPatches(c.Args().Slice()[1:])
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			if overlay {
+				stats, err := writeCarOverlay(c.Context, c.Args().First(), patches, outputFile, network, tmpDir)
+				if err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+				klog.Infof("Wrote the overlay %s (index: %s): %d blocks, %d nodes", outputFile, stats.indexPath, stats.blocks, stats.nodes)
+				return nil
+			}
+			stats, err := patchCar(c.Args().First(), patches, outputFile, strictness)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			klog.Infof(
+				"Wrote %s: %d blocks replaced, %d nodes copied, %d nodes re-encoded; new root %s",
+				outputFile,
+				stats.blocksReplaced,
+				stats.nodesCopied,
+				stats.nodesReencoded,
+				stats.root,
+			)
+			return nil
+		},
+	}
+}
+
+type carNode struct {
+	cid  cid.Cid
+	data []byte
+}
+
+// blockPatch is the corrected DAG of a single block.
+type blockPatch struct {
+	slot     uint64
+	blockCid cid.Cid
+	// nodes in the order they appear in the patch CAR (the block node must be after the nodes it links to).
+	nodes []carNode
+}
+
+// loadBlockPatches reads the patch CAR files; each one must contain exactly one block node.
+func loadBlockPatches(paths []string) (map[uint64]*blockPatch, error) {
+	patches := make(map[uint64]*blockPatch)
+	for _, path := range paths {
+		patch, err := loadBlockPatch(path)
+		if err != nil {
+			return nil, fmt.Errorf("patch %q: %w", path, err)
+		}
+		if _, ok := patches[patch.slot]; ok {
+			return nil, fmt.Errorf("patch %q: slot %d is patched more than once", path, patch.slot)
+		}
+		patches[patch.slot] = patch
+	}
+	return patches, nil
+}
+
+func loadBlockPatch(path string) (*blockPatch, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rd, err := carreader.New(file)
+	if err != nil {
+		return nil, err
+	}
+	var patch *blockPatch
+	var nodes []carNode
+	for {
+		c, _, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// A patch is small, and is usually made by hand or by a new tool: check it fully.
+		if err := iplddecoders.CheckCid(c, data, iplddecoders.StrictHash); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, carNode{cid: c, data: data})
+		switch iplddecoders.Kind(data[1]) {
+		case iplddecoders.KindBlock:
+			if patch != nil {
+				return nil, errors.New("contains more than one block")
+			}
+			block, err := iplddecoders.DecodeBlock(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode block %s: %w", c, err)
+			}
+			patch = &blockPatch{slot: uint64(block.Slot), blockCid: c}
+		case iplddecoders.KindSubset, iplddecoders.KindEpoch:
+			return nil, errors.New("must only contain the DAG of a block (found a subset or epoch node)")
+		}
+	}
+	if patch == nil {
+		return nil, errors.New("contains no block")
+	}
+	if !nodes[len(nodes)-1].cid.Equals(patch.blockCid) {
+		return nil, errors.New("the block node must be the last node")
+	}
+	patch.nodes = nodes
+	return patch, nil
+}
+
+type carPatchStats struct {
+	blocksReplaced int
+	nodesCopied    int
+	nodesReencoded int
+	root           cid.Cid
+}
+
+// patchCar streams the original CAR file to outputPath, replacing the patched blocks.
+//
+// In an epoch CAR the nodes of a block (transactions, entries, rewards, data frames) are
+// written before the block node, so the nodes since the previous block are buffered until the
+// block node is reached, and then either copied or replaced by the patch.
+// Subset and epoch nodes are re-encoded if they link to a replaced block (or subset).
+// The root CID changes if any block is replaced; since the new root CID has the same length
+// as the original one, the header is rewritten in place at the end.
+// The CIDs of the original nodes are checked as much as strictness asks for.
+func patchCar(originalPath string, patches map[uint64]*blockPatch, outputPath string, strictness iplddecoders.Strictness) (*carPatchStats, error) {
+	in, err := os.Open(originalPath)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	rd, err := carreader.New(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open original CAR: %w", err)
+	}
+	if len(rd.Header.Roots) != 1 {
+		return nil, fmt.Errorf("expected 1 root CID, got %d", len(rd.Header.Roots))
+	}
+	originalRoot := rd.Header.Roots[0]
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+	w := bufio.NewWriterSize(out, 1<<20)
+	if err := carv1.WriteHeader(rd.Header, w); err != nil {
+		return nil, err
+	}
+
+	stats := &carPatchStats{root: originalRoot}
+	// replaced maps the CIDs of replaced nodes (blocks, subsets, epoch) to the new ones.
+	replaced := make(map[cid.Cid]cid.Cid)
+	applied := make(map[uint64]bool)
+	var pending []carNode
+	flush := func() error {
+		for _, node := range pending {
+			if err := util.LdWrite(w, node.cid.Bytes(), node.data); err != nil {
+				return err
+			}
+			stats.nodesCopied++
+		}
+		pending = pending[:0]
+		return nil
+	}
+	remap := func(links ipldbindcode.List__Link) ([]datamodel.Link, bool) {
+		changed := false
+		out := make([]datamodel.Link, len(links))
+		for i, link := range links {
+			out[i] = link
+			if newCid, ok := replaced[link.(cidlink.Link).Cid]; ok {
+				out[i] = cidlink.Link{Cid: newCid}
+				changed = true
+			}
+		}
+		return out, changed
+	}
+
+	for {
+		c, _, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := iplddecoders.CheckCid(c, data, strictness); err != nil {
+			return nil, err
+		}
+		node := carNode{cid: c, data: data}
+		switch iplddecoders.Kind(data[1]) {
+		case iplddecoders.KindBlock:
+			block, err := iplddecoders.DecodeBlock(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode block %s: %w", c, err)
+			}
+			patch, ok := patches[uint64(block.Slot)]
+			if !ok {
+				pending = append(pending, node)
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			// drop the original DAG of the block, and write the patch instead.
+			pending = pending[:0]
+			for _, node := range patch.nodes {
+				if err := util.LdWrite(w, node.cid.Bytes(), node.data); err != nil {
+					return nil, err
+				}
+			}
+			if !patch.blockCid.Equals(c) {
+				replaced[c] = patch.blockCid
+			}
+			applied[patch.slot] = true
+			stats.blocksReplaced++
+			klog.Infof("Replaced block %d: %s -> %s", patch.slot, c, patch.blockCid)
+		case iplddecoders.KindSubset:
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			subset, err := iplddecoders.DecodeSubset(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode subset %s: %w", c, err)
+			}
+			blocks, changed := remap(subset.Blocks)
+			if !changed {
+				pending = append(pending, node)
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			link, err := writeSubsetNode(subsetInfo{
+				firstSlot:  subset.First,
+				lastSlot:   subset.Last,
+				blockLinks: blocks,
+			}, w)
+			if err != nil {
+				return nil, err
+			}
+			replaced[c] = link.(cidlink.Link).Cid
+			stats.nodesReencoded++
+		case iplddecoders.KindEpoch:
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			epoch, err := iplddecoders.DecodeEpoch(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode epoch %s: %w", c, err)
+			}
+			subsets, changed := remap(epoch.Subsets)
+			if !changed {
+				pending = append(pending, node)
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			link, err := writeEpochNode(uint64(epoch.Epoch), subsets, w)
+			if err != nil {
+				return nil, err
+			}
+			replaced[c] = link.(cidlink.Link).Cid
+			stats.nodesReencoded++
+		default:
+			pending = append(pending, node)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	for slot := range patches {
+		if !applied[slot] {
+			return nil, fmt.Errorf("block %d is not in the original CAR file (only existing blocks can be patched)", slot)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	if newRoot, ok := replaced[originalRoot]; ok {
+		if err := rewriteCarRoot(out, rd.Header, newRoot); err != nil {
+			return nil, err
+		}
+		stats.root = newRoot
+	} else if len(replaced) > 0 {
+		return nil, fmt.Errorf("blocks were replaced, but the root %s does not link to them", originalRoot)
+	}
+	return stats, nil
+}
+
+type carOverlayStats struct {
+	blocks    int
+	nodes     int
+	indexPath string
+}
+
+// writeCarOverlay writes the patches to an overlay of the original CAR: a CAR with the DAGs of the
+// patched blocks (in slot order) and the root of the original CAR, and its cid-to-offset-and-size index.
+// The original CAR is not read beyond its header: its blocks are replaced when the overlay is served.
+func writeCarOverlay(ctx context.Context, originalPath string, patches map[uint64]*blockPatch, outputPath string, network indexes.Network, tmpDir string) (*carOverlayStats, error) {
+	if len(patches) == 0 {
+		return nil, errors.New("no patches")
+	}
+	root, err := readCarRoot(originalPath)
+	if err != nil {
+		return nil, err
+	}
+	slots := make([]uint64, 0, len(patches))
+	for slot := range patches {
+		slots = append(slots, slot)
+	}
+	slices.Sort(slots)
+	epoch := slottools.CalcEpochForSlot(slots[0])
+	if last := slottools.CalcEpochForSlot(slots[len(slots)-1]); last != epoch {
+		return nil, fmt.Errorf("the patched blocks are in different epochs (%d and %d)", epoch, last)
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+	w := bufio.NewWriterSize(out, 1<<20)
+	var header bytes.Buffer
+	if err := carv1.WriteHeader(&carv1.CarHeader{Roots: []cid.Cid{root}, Version: 1}, &header); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return nil, err
+	}
+	offset := uint64(header.Len())
+	// the patches can have nodes in common (e.g. the same data frame): each node is written once.
+	written := make(map[cid.Cid]indexes.OffsetAndSize)
+	var order []cid.Cid
+	for _, slot := range slots {
+		for _, node := range patches[slot].nodes {
+			if _, ok := written[node.cid]; ok {
+				continue
+			}
+			var section bytes.Buffer
+			if err := util.LdWrite(&section, node.cid.Bytes(), node.data); err != nil {
+				return nil, err
+			}
+			if _, err := w.Write(section.Bytes()); err != nil {
+				return nil, err
+			}
+			written[node.cid] = indexes.OffsetAndSize{Offset: offset, Size: uint64(section.Len())}
+			order = append(order, node.cid)
+			offset += uint64(section.Len())
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := out.Sync(); err != nil {
+		return nil, err
+	}
+
+	index, err := indexes.NewWriter_CidToOffsetAndSize(epoch, root, network, tmpDir, uint64(len(order)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the index of the overlay: %w", err)
+	}
+	for _, c := range order {
+		oas := written[c]
+		if err := index.Put(c, oas.Offset, oas.Size); err != nil {
+			return nil, fmt.Errorf("failed to index node %s: %w", c, err)
+		}
+	}
+	indexPath := outputPath + ".index"
+	if err := index.SealWithFilename(ctx, indexPath); err != nil {
+		return nil, fmt.Errorf("failed to seal the index of the overlay: %w", err)
+	}
+	if err := index.Close(); err != nil {
+		return nil, err
+	}
+	return &carOverlayStats{blocks: len(slots), nodes: len(order), indexPath: indexPath}, nil
+}
+
+// rewriteCarRoot overwrites the header of the CAR file with one that has the given root,
+// which must have the same size.
+func rewriteCarRoot(file *os.File, original *carv1.CarHeader, newRoot cid.Cid) error {
+	var originalHeader, newHeader bytes.Buffer
+	if err := carv1.WriteHeader(original, &originalHeader); err != nil {
+		return err
+	}
+	if err := carv1.WriteHeader(&carv1.CarHeader{Roots: []cid.Cid{newRoot}, Version: original.Version}, &newHeader); err != nil {
+		return err
+	}
+	if originalHeader.Len() != newHeader.Len() {
+		return fmt.Errorf("the new header has a different size (%d) than the original one (%d)", newHeader.Len(), originalHeader.Len())
+	}
+	_, err := file.WriteAt(newHeader.Bytes(), 0)
+	return err
+}
+
+func writeEpochNode(epoch uint64, subsetLinks []datamodel.Link, writer io.Writer) (datamodel.Link, error) {
+	epochNode, err := qp.BuildMap(ipldbindcode.Prototypes.Epoch, -1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "kind", qp.Int(int64(iplddecoders.KindEpoch)))
+		qp.MapEntry(ma, "epoch", qp.Int(int64(epoch)))
+		qp.MapEntry(ma, "subsets",
+			qp.List(-1, func(la datamodel.ListAssembler) {
+				for _, sl := range subsetLinks {
+					qp.ListEntry(la, qp.Link(sl))
+				}
+			}))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write an epochNode: %w", err)
+	}
+
+	cid, err := writeNode(epochNode, writer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write an epochNode: %w", err)
+	}
+
+	return cidlink.Link{Cid: cid}, nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	carv1 "github.com/ipld/go-car"
+	"github.com/ipld/go-car/util"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/bindnode"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/stretchr/testify/require"
+)
+
+func readCarNodes(t *testing.T, path string) (cid.Cid, []carNode) {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	rd, err := carreader.New(file)
+	require.NoError(t, err)
+	var nodes []carNode
+	for {
+		c, _, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		nodes = append(nodes, carNode{cid: c, data: data})
+	}
+	return rd.Header.Roots[0], nodes
+}
+
+// blockDag returns the nodes of the block at the given slot (the nodes after the previous block, and the block).
+func blockDag(t *testing.T, nodes []carNode, slot uint64) ([]carNode, *ipldbindcode.Block) {
+	t.Helper()
+	start := 0
+	for i, node := range nodes {
+		if iplddecoders.Kind(node.data[1]) != iplddecoders.KindBlock {
+			continue
+		}
+		block, err := iplddecoders.DecodeBlock(node.data)
+		require.NoError(t, err)
+		if uint64(block.Slot) == slot {
+			return append([]carNode(nil), nodes[start:i+1]...), block
+		}
+		start = i + 1
+	}
+	t.Fatalf("block %d not found", slot)
+	return nil, nil
+}
+
+func writePatchCar(t *testing.T, path string, nodes []carNode) {
+	t.Helper()
+	var buf bytes.Buffer
+	root := nodes[len(nodes)-1].cid
+	require.NoError(t, carv1.WriteHeader(&carv1.CarHeader{Roots: []cid.Cid{root}, Version: 1}, &buf))
+	for _, node := range nodes {
+		require.NoError(t, util.LdWrite(&buf, node.cid.Bytes(), node.data))
+	}
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+}
+
+// setBlocktime changes the blocktime of the block (the last node of its DAG), and returns the new CID of the block.
+func setBlocktime(t *testing.T, dag []carNode, block *ipldbindcode.Block, blocktime int) cid.Cid {
+	t.Helper()
+	block.Meta.Blocktime = blocktime
+	var buf bytes.Buffer
+	newBlockCid, err := writeNode(bindnode.Wrap(block, ipldbindcode.Prototypes.Block.Type()), &buf)
+	require.NoError(t, err)
+	// writeNode wrote a whole section; keep the node data.
+	_, _, data, err := carreader.ReadNodeInfoWithData(bufio.NewReader(&buf))
+	require.NoError(t, err)
+	dag[len(dag)-1] = carNode{cid: newBlockCid, data: data}
+	return newBlockCid
+}
+
+func TestCarPatch(t *testing.T) {
+	dir := t.TempDir()
+	originalPath := filepath.Join("fixtures", "epoch-0-1.car")
+	originalRoot, originalNodes := readCarNodes(t, originalPath)
+	const patchedSlot = 5
+
+	t.Run("identical patch", func(t *testing.T) {
+		dag, _ := blockDag(t, originalNodes, patchedSlot)
+		patchPath := filepath.Join(dir, "same.car")
+		writePatchCar(t, patchPath, dag)
+		patches, err := loadBlockPatches([]string{patchPath})
+		require.NoError(t, err)
+
+		outPath := filepath.Join(dir, "same-out.car")
+		stats, err := patchCar(originalPath, patches, outPath, iplddecoders.StrictHash)
+		require.NoError(t, err)
+		require.Equal(t, 1, stats.blocksReplaced)
+		require.Equal(t, originalRoot, stats.root)
+
+		original, err := os.ReadFile(originalPath)
+		require.NoError(t, err)
+		got, err := os.ReadFile(outPath)
+		require.NoError(t, err)
+		require.Equal(t, original, got)
+	})
+
+	t.Run("corrected block", func(t *testing.T) {
+		dag, block := blockDag(t, originalNodes, patchedSlot)
+		oldBlockCid := dag[len(dag)-1].cid
+		newBlockCid := setBlocktime(t, dag, block, 1234567)
+		require.NotEqual(t, oldBlockCid, newBlockCid)
+
+		patchPath := filepath.Join(dir, "fixed.car")
+		writePatchCar(t, patchPath, dag)
+		patches, err := loadBlockPatches([]string{patchPath})
+		require.NoError(t, err)
+
+		outPath := filepath.Join(dir, "fixed-out.car")
+		stats, err := patchCar(originalPath, patches, outPath, iplddecoders.StrictHash)
+		require.NoError(t, err)
+		require.Equal(t, 1, stats.blocksReplaced)
+		require.Equal(t, 1, stats.nodesReencoded) // the subset (root of this CAR)
+		require.NotEqual(t, originalRoot, stats.root)
+
+		root, nodes := readCarNodes(t, outPath)
+		require.Equal(t, stats.root, root)
+		require.Len(t, nodes, len(originalNodes))
+		for i, node := range nodes {
+			if node.cid.Equals(newBlockCid) {
+				decoded, err := iplddecoders.DecodeBlock(node.data)
+				require.NoError(t, err)
+				require.Equal(t, 1234567, decoded.Meta.Blocktime)
+				continue
+			}
+			if node.cid.Equals(root) {
+				subset, err := iplddecoders.DecodeSubset(node.data)
+				require.NoError(t, err)
+				require.Contains(t, subset.Blocks, cidlink.Link{Cid: newBlockCid})
+				require.NotContains(t, subset.Blocks, cidlink.Link{Cid: oldBlockCid})
+				continue
+			}
+			require.Equal(t, originalNodes[i], node)
+		}
+	})
+
+	t.Run("wrong codec", func(t *testing.T) {
+		dag, _ := blockDag(t, originalNodes, patchedSlot)
+		// The same node, with a raw CID instead of a dag-cbor one.
+		first := dag[0]
+		dag[0] = carNode{cid: cid.NewCidV1(cid.Raw, first.cid.Hash()), data: first.data}
+		patchPath := filepath.Join(dir, "wrong-codec.car")
+		writePatchCar(t, patchPath, dag)
+		_, err := loadBlockPatches([]string{patchPath})
+		require.ErrorContains(t, err, "expected dag-cbor")
+	})
+
+	t.Run("missing block", func(t *testing.T) {
+		dag, _ := blockDag(t, originalNodes, patchedSlot)
+		patches := map[uint64]*blockPatch{
+			1_000_000: {slot: 1_000_000, blockCid: dag[len(dag)-1].cid, nodes: dag},
+		}
+		_, err := patchCar(originalPath, patches, filepath.Join(dir, "missing-out.car"), iplddecoders.StrictHash)
+		require.Error(t, err)
+	})
+}
+
+func TestCarPatch_Overlay(t *testing.T) {
+	dir := t.TempDir()
+	config, _, slots, _ := buildTestEpoch(t)
+	originalPath := string(config.Data.Car.URI)
+	_, originalNodes := readCarNodes(t, originalPath)
+	const patchedSlot = 5
+
+	dag, block := blockDag(t, originalNodes, patchedSlot)
+	setBlocktime(t, dag, block, 1234567)
+	patchPath := filepath.Join(dir, "fixed.car")
+	writePatchCar(t, patchPath, dag)
+	patches, err := loadBlockPatches([]string{patchPath})
+	require.NoError(t, err)
+
+	overlayPath := filepath.Join(dir, "epoch-0.overlay.car")
+	stats, err := writeCarOverlay(context.Background(), originalPath, patches, overlayPath, indexes.NetworkMainnet, t.TempDir())
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.blocks)
+	require.Equal(t, len(dag), stats.nodes)
+	// the original CAR is left as it is.
+	_, nodes := readCarNodes(t, originalPath)
+	require.Equal(t, originalNodes, nodes)
+
+	config.Overlay.Car.URI = URI(overlayPath)
+	config.Overlay.Index.URI = URI(stats.indexPath)
+	require.NoError(t, config.Validate())
+	epoch := openTestEpoch(t, config)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+
+	for _, slot := range slots {
+		got, _, err := epoch.GetBlock(context.Background(), slot)
+		require.NoError(t, err)
+		_, want := blockDag(t, originalNodes, slot)
+		if slot == patchedSlot {
+			want = block
+		}
+		require.Equal(t, want.Meta.Blocktime, got.Meta.Blocktime, "slot %d", slot)
+
+		// gRPC getBlock reads the whole DAG of the block at once from the CAR, unless the block or its parent is in the overlay.
+		resp, err := multi.GetBlock(context.Background(), &old_faithful_grpc.BlockRequest{Slot: slot})
+		require.NoError(t, err)
+		if slot != 0 { // the blocktime of block 0 is the creation time of the genesis.
+			require.Equal(t, int64(want.Meta.Blocktime), resp.BlockTime, "slot %d", slot)
+		}
+	}
+
+	// the overlay must be for the epoch CAR.
+	_, err = openCarOverlay(context.Background(), config, nil, 1, epoch.rootCid)
+	require.ErrorContains(t, err, "epoch mismatch in overlay index")
+	config.Overlay.Index.URI = ""
+	require.ErrorContains(t, config.Validate(), "overlay.car.uri and overlay.index.uri must be set together")
+}
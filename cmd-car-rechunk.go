@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_CarRechunk() *cli.Command {
+	var outputFile string
+	var frameSize int
+	return &cli.Command{
+		Name:        "car-rechunk",
+		Usage:       "Rewrite a CAR file with all the data frames split at the canonical size.",
+		Description: "Different producers split the large transactions, metas and rewards in data frames of different sizes, so CARs with the same content have different CIDs. This streams the CAR file to a new one where all of them are split like create-car does (see carwriter.Rechunk); the transaction and rewards nodes are re-encoded, and the links of the entries, blocks, subsets and epoch are updated to the new CIDs. The CIDs of two re-chunked CARs can then be compared.",
+		ArgsUsage:   "<car>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output-file",
+				Aliases:     []string{"o"},
+				Usage:       "Output file name",
+				Required:    true,
+				Destination: &outputFile,
+			},
+			&cli.IntFlag{
+				Name:        "frame-size",
+				Usage:       "Maximum size of the data of a data frame",
+				Value:       carwriter.MaxDataFrameSize,
+				Destination: &frameSize,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() != 1 {
+				return cli.Exit("expected exactly one CAR file", 1)
+			}
+			if frameSize <= 0 {
+				return cli.Exit("--frame-size must be positive", 1)
+			}
+			stats, err := carwriter.Rechunk(c.Args().First(), outputFile, frameSize)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			klog.Infof(
+				"Wrote %s: %d nodes, %d with a new CID, %d data frame nodes (was %d); root %s",
+				outputFile,
+				stats.Nodes,
+				stats.Changed,
+				stats.FramesAfter,
+				stats.FramesBefore,
+				stats.Root,
+			)
+			return nil
+		},
+	}
+}
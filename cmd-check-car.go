@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_CheckCar() *cli.Command {
+	var canonical bool
+	return &cli.Command{
+		Name:  "check-car",
+		Usage: "Validate an epoch CAR file.",
+		Description: "Checks that the CAR file is a complete epoch CAR: every node matches its CID and decodes, every link points to a node of the expected kind, slots are ascending and within the epoch, and the root is the epoch node. " +
+			"With --canonical, also checks that it is in the canonical layout written by create-car (the order of the nodes of each block and the size of the data frames, see the carwriter package), so that CARs with the same blocks are byte for byte the same.",
+		ArgsUsage: "<car>",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "canonical",
+				Usage:       "Also check that the CAR is in the canonical layout",
+				Destination: &canonical,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() != 1 {
+				return cli.Exit("expected exactly one CAR file", 1)
+			}
+			carPath := c.Args().First()
+			validate := carwriter.Validate
+			if canonical {
+				validate = carwriter.ValidateCanonical
+			}
+			startedAt := time.Now()
+			stats, err := validate(carPath)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("%s is not valid: %s", carPath, err), 1)
+			}
+			klog.Infof(
+				"Validated %s in %s: epoch %d, %d nodes, %d blocks (slots %d-%d), %d transactions, %d subsets; root %s",
+				carPath,
+				time.Since(startedAt).Truncate(time.Millisecond),
+				stats.Epoch,
+				stats.Nodes,
+				stats.Blocks,
+				stats.FirstSlot,
+				stats.LastSlot,
+				stats.Transactions,
+				stats.Subsets,
+				stats.Root,
+			)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/carwriter/bigtablesource"
+	"github.com/rpcpool/yellowstone-faithful/carwriter/rocksdbsource"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_CreateCar() *cli.Command {
+	var (
+		epoch           uint64
+		outputFile      string
+		checkpointFile  string
+		checkpointEvery int
+		maxSubsetBlocks int
+		sourceCar       string
+		bigtable        bigtablesource.Config
+		ledgers         cli.StringSlice
+		extractDir      string
+		validate        bool
+	)
+	return &cli.Command{
+		Name:  "create-car",
+		Usage: "Create an epoch CAR file from a block source.",
+		Description: "Writes the blocks of an epoch in the old-faithful CAR layout (transactions, entries, rewards, blocks, subsets and the epoch node). " +
+			"Progress is checkpointed next to the output file: if the command is interrupted, running it again with the same arguments resumes from the last checkpoint. " +
+			"Blocks are read from an existing CAR (--source-car, a whole epoch or split pieces), from Solana ledgers (--rocksdb: the rocksdb databases of validators, or archives of them), or from a Solana warehouse Bigtable instance (--bigtable-project and --bigtable-instance).",
+		Flags: []cli.Flag{
+			&cli.Uint64Flag{
+				Name:        "epoch",
+				Usage:       "Epoch to create the CAR for",
+				Required:    true,
+				Destination: &epoch,
+			},
+			&cli.StringFlag{
+				Name:        "output-file",
+				Aliases:     []string{"o"},
+				Usage:       "Output file name (default: epoch-<epoch>.car)",
+				Destination: &outputFile,
+			},
+			&cli.StringFlag{
+				Name:        "checkpoint-file",
+				Usage:       "Checkpoint file name (default: <output file>.checkpoint)",
+				Destination: &checkpointFile,
+			},
+			&cli.IntFlag{
+				Name:        "checkpoint-every",
+				Usage:       "Save a checkpoint every this many blocks",
+				Value:       1000,
+				Destination: &checkpointEvery,
+			},
+			&cli.IntFlag{
+				Name:        "max-subset-blocks",
+				Usage:       "Maximum number of blocks per subset",
+				Value:       carwriter.DefaultMaxSubsetBlocks,
+				Destination: &maxSubsetBlocks,
+			},
+			&cli.StringFlag{
+				Name:        "source-car",
+				Usage:       "Read the blocks from this CAR file",
+				Destination: &sourceCar,
+			},
+			&cli.StringSliceFlag{
+				Name:        "rocksdb",
+				Usage:       "Read the blocks from this Solana ledger: a ledger or rocksdb directory, or a tar archive of one (compressed archives are extracted first); repeat the flag to read the slots missing from a ledger in the next ones",
+				Destination: &ledgers,
+			},
+			&cli.StringFlag{
+				Name:        "rocksdb-extract-dir",
+				Usage:       "Extract the compressed ledger archives to this directory, where the next runs reuse them (default: a temporary directory, removed on exit)",
+				Destination: &extractDir,
+			},
+			&cli.StringFlag{
+				Name:        "bigtable-project",
+				Usage:       "Read the blocks from Bigtable: GCP project of the instance",
+				Destination: &bigtable.Project,
+			},
+			&cli.StringFlag{
+				Name:        "bigtable-instance",
+				Usage:       "Bigtable instance",
+				Destination: &bigtable.Instance,
+			},
+			&cli.StringFlag{
+				Name:        "bigtable-app-profile",
+				Usage:       "Bigtable app profile",
+				Destination: &bigtable.AppProfile,
+			},
+			&cli.StringFlag{
+				Name:        "bigtable-endpoint",
+				Usage:       "Bigtable endpoint (default: " + bigtablesource.DefaultEndpoint + ", or $BIGTABLE_EMULATOR_HOST)",
+				Destination: &bigtable.Endpoint,
+			},
+			&cli.StringFlag{
+				Name:        "bigtable-credentials",
+				Usage:       "Service account key file (default: $GOOGLE_APPLICATION_CREDENTIALS, then the GCE metadata server)",
+				Destination: &bigtable.CredentialsFile,
+			},
+			&cli.StringFlag{
+				Name:        "bigtable-access-token",
+				Usage:       "OAuth2 access token to use instead of credentials (e.g. from `gcloud auth print-access-token`)",
+				EnvVars:     []string{"BIGTABLE_ACCESS_TOKEN"},
+				Destination: &bigtable.AccessToken,
+			},
+			&cli.BoolFlag{
+				Name:        "bigtable-synthesize-entries",
+				Usage:       "For blocks without entries in Bigtable (older slots), write a single entry with all the transactions and the blockhash instead of failing; the PoH entries of those blocks are lost",
+				Destination: &bigtable.SynthesizeEntries,
+			},
+			&cli.BoolFlag{
+				Name:        "validate",
+				Usage:       "Validate the CAR file once it is written",
+				Value:       true,
+				Destination: &validate,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if outputFile == "" {
+				outputFile = fmt.Sprintf("epoch-%d.car", epoch)
+			}
+			if checkpointFile == "" {
+				checkpointFile = outputFile + ".checkpoint"
+			}
+			numSources := 0
+			for _, set := range []bool{sourceCar != "", len(ledgers.Value()) > 0, bigtable.Project != ""} {
+				if set {
+					numSources++
+				}
+			}
+			var source carwriter.BlockSource
+			switch {
+			case numSources > 1:
+				return cli.Exit("--source-car, --rocksdb and --bigtable-project are mutually exclusive", 1)
+			case sourceCar != "":
+				if err := checkSourceCarPath(sourceCar); err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+				source = carwriter.NewCarSource(sourceCar)
+			case len(ledgers.Value()) > 0:
+				var err error
+				source, err = rocksdbsource.New(rocksdbsource.Config{Ledgers: ledgers.Value(), ExtractDir: extractDir})
+				if err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+			case bigtable.Project != "":
+				var err error
+				source, err = bigtablesource.New(bigtable)
+				if err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+			default:
+				return cli.Exit("a block source is required (--source-car, --rocksdb or --bigtable-project)", 1)
+			}
+			defer source.Close()
+
+			err := createCar(c.Context, source, epoch, outputFile, checkpointFile, checkpointEvery, maxSubsetBlocks)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			if validate {
+				startedAt := time.Now()
+				stats, err := carwriter.ValidateCanonical(outputFile)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("validation of %s failed: %s", outputFile, err), 1)
+				}
+				klog.Infof(
+					"Validated %s in %s: %d nodes, %d blocks (slots %d-%d), %d transactions, %d subsets",
+					outputFile,
+					time.Since(startedAt).Truncate(time.Millisecond),
+					stats.Nodes,
+					stats.Blocks,
+					stats.FirstSlot,
+					stats.LastSlot,
+					stats.Transactions,
+					stats.Subsets,
+				)
+			}
+			return nil
+		},
+	}
+}
+
+// checkSourceCarPath rejects the rocksdb ledgers and ledger archives given as --source-car
+// instead of --rocksdb, which would otherwise fail with a CAR header error.
+func checkSourceCarPath(path string) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		if _, err := os.Stat(filepath.Join(path, "CURRENT")); err == nil {
+			return fmt.Errorf("%s is a rocksdb ledger: read it with --rocksdb", path)
+		}
+		return fmt.Errorf("%s is a directory, not a CAR file", path)
+	}
+	if strings.Contains(filepath.Base(path), ".tar") {
+		return fmt.Errorf("%s looks like a ledger archive: read it with --rocksdb", path)
+	}
+	return nil
+}
+
+func createCar(
+	ctx context.Context,
+	source carwriter.BlockSource,
+	epoch uint64,
+	outputFile string,
+	checkpointFile string,
+	checkpointEvery int,
+	maxSubsetBlocks int,
+) error {
+	var w *carwriter.Writer
+	cp, err := carwriter.LoadCheckpoint(checkpointFile)
+	switch {
+	case err == nil:
+		if cp.Epoch != epoch {
+			return fmt.Errorf("checkpoint %q is for epoch %d, not %d", checkpointFile, cp.Epoch, epoch)
+		}
+		w, err = carwriter.Resume(outputFile, cp)
+		if err != nil {
+			return fmt.Errorf("failed to resume from checkpoint %q: %w", checkpointFile, err)
+		}
+		klog.Infof("Resuming %s from slot %d", outputFile, w.NextSlot())
+	case errors.Is(err, os.ErrNotExist):
+		w, err = carwriter.Create(outputFile, epoch, maxSubsetBlocks)
+		if err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	saveCheckpoint := func() error {
+		cp, err := w.Checkpoint()
+		if err != nil {
+			return fmt.Errorf("failed to checkpoint: %w", err)
+		}
+		return cp.Save(checkpointFile)
+	}
+
+	_, lastSlot := slottools.CalcEpochLimits(epoch)
+	startedAt := time.Now()
+	numBlocks := 0
+	err = source.Blocks(ctx, w.NextSlot(), lastSlot, func(block *carwriter.Block) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := w.WriteBlock(block); err != nil {
+			return err
+		}
+		numBlocks++
+		if checkpointEvery > 0 && numBlocks%checkpointEvery == 0 {
+			if err := saveCheckpoint(); err != nil {
+				return err
+			}
+			klog.Infof("Wrote %d blocks (up to slot %d) in %s", numBlocks, block.Slot, time.Since(startedAt).Truncate(time.Second))
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			// interrupted between two blocks: everything written so far can be kept.
+			if err := saveCheckpoint(); err != nil {
+				w.Close()
+				return err
+			}
+			w.Close()
+			return fmt.Errorf("interrupted at slot %d; run the command again to resume", w.NextSlot())
+		}
+		w.Close()
+		return err
+	}
+	root, err := w.Finish()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(checkpointFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	klog.Infof("Wrote %s: %d blocks in %s; root %s", outputFile, numBlocks, time.Since(startedAt).Truncate(time.Second), root)
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSourceCarPath(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, checkSourceCarPath(filepath.Join("fixtures", "epoch-0-1.car")))
+
+	require.ErrorIs(t, checkSourceCarPath(filepath.Join(dir, "missing.car")), os.ErrNotExist)
+
+	require.ErrorContains(t, checkSourceCarPath(dir), "not a CAR file")
+
+	ledger := filepath.Join(dir, "rocksdb")
+	require.NoError(t, os.Mkdir(ledger, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(ledger, "CURRENT"), []byte("MANIFEST-000001\n"), 0o644))
+	require.ErrorContains(t, checkSourceCarPath(ledger), "is a rocksdb ledger: read it with --rocksdb")
+
+	archive := filepath.Join(dir, "rocksdb.tar.zst")
+	require.NoError(t, os.WriteFile(archive, nil, 0o644))
+	require.ErrorContains(t, checkSourceCarPath(archive), "ledger archive: read it with --rocksdb")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+)
+
+var blockSummaryCSVHeader = []string{
+	"slot",
+	"blocktime",
+	"transactions",
+	"vote_transactions",
+	"failed_transactions",
+	"total_fees",
+	"compute_units",
+}
+
+// blockSummary is the per-block row of dump-car --summary-csv.
+type blockSummary struct {
+	slot               uint64
+	blockTime          int64
+	transactions       int
+	voteTransactions   int
+	failedTransactions int
+	totalFees          uint64
+	computeUnits       uint64
+	// computeUnitsKnown is false if the meta of a transaction of the block
+	// doesn't record its compute units (older blocks).
+	computeUnitsKnown bool
+}
+
+func (s *blockSummary) csvRecord() []string {
+	computeUnits := ""
+	if s.computeUnitsKnown {
+		computeUnits = strconv.FormatUint(s.computeUnits, 10)
+	}
+	return []string{
+		strconv.FormatUint(s.slot, 10),
+		strconv.FormatInt(s.blockTime, 10),
+		strconv.Itoa(s.transactions),
+		strconv.Itoa(s.voteTransactions),
+		strconv.Itoa(s.failedTransactions),
+		strconv.FormatUint(s.totalFees, 10),
+		computeUnits,
+	}
+}
+
+func summarizeBlock(block *carwriter.Block) (*blockSummary, error) {
+	summary := &blockSummary{
+		slot:              block.Slot,
+		blockTime:         block.BlockTime,
+		computeUnitsKnown: true,
+	}
+	for _, entry := range block.Entries {
+		for _, tx := range entry.Transactions {
+			summary.transactions++
+			var decoded solana.Transaction
+			if err := decoded.UnmarshalWithDecoder(bin.NewBinDecoder(tx.Data)); err != nil {
+				return nil, fmt.Errorf("slot %d: failed to decode transaction: %w", block.Slot, err)
+			}
+			if nodetools.IsSimpleVoteTransaction(&decoded) {
+				summary.voteTransactions++
+			}
+			if len(tx.Metadata) == 0 {
+				summary.computeUnitsKnown = false
+				continue
+			}
+			uncompressedMeta, err := tooling.DecompressZstd(tx.Metadata)
+			if err != nil {
+				return nil, fmt.Errorf("slot %d, transaction %s: failed to decompress metadata: %w", block.Slot, decoded.Signatures[0], err)
+			}
+			meta, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(uncompressedMeta)
+			if err != nil {
+				return nil, fmt.Errorf("slot %d, transaction %s: %w", block.Slot, decoded.Signatures[0], err)
+			}
+			summary.totalFees += meta.Fee()
+			if meta.Failed() {
+				summary.failedTransactions++
+			}
+			if units, ok := meta.ComputeUnitsConsumed(); ok {
+				summary.computeUnits += units
+			} else {
+				summary.computeUnitsKnown = false
+			}
+		}
+	}
+	return summary, nil
+}
+
+// writeBlockSummaryCSV writes one CSV row per block of the CAR files, computed on the fly.
+func writeBlockSummaryCSV(ctx context.Context, out io.Writer, carPaths []string) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(blockSummaryCSVHeader); err != nil {
+		return err
+	}
+	for _, carPath := range carPaths {
+		if carPath == "-" {
+			return errors.New("--summary-csv can't read from stdin")
+		}
+		err := carwriter.NewCarSource(carPath).Blocks(ctx, 0, math.MaxUint64, func(block *carwriter.Block) error {
+			summary, err := summarizeBlock(block)
+			if err != nil {
+				return err
+			}
+			return w.Write(summary.csvRecord())
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", carPath, err)
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBlockSummaryCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeBlockSummaryCSV(context.Background(), &buf, []string{"fixtures/epoch-0-1.car"}))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, blockSummaryCSVHeader, records[0])
+	require.Len(t, records, 11)
+
+	totalTransactions := 0
+	for _, record := range records[1:] {
+		transactions, err := strconv.Atoi(record[2])
+		require.NoError(t, err)
+		votes, err := strconv.Atoi(record[3])
+		require.NoError(t, err)
+		require.LessOrEqual(t, votes, transactions)
+		totalTransactions += transactions
+	}
+	require.NotZero(t, totalTransactions)
+	// the first blocks of mainnet only have votes.
+	require.Equal(t, []string{"1", "0", "4", "4", "0", "0", ""}, records[2])
+}
@@ -65,10 +65,12 @@ func newCmd_DumpCar() *cli.Command {
 	var printID bool
 	var prettyPrintTransactions bool
 	var limit int
+	var summaryCSV bool
+	var strictFlag string
 	return &cli.Command{
 		Name:        "dump-car",
 		Description: "Dump the contents of a CAR file",
-		ArgsUsage:   "<car-path>",
+		ArgsUsage:   "<car-path or template>",
 		Before: func(c *cli.Context) error {
 			return nil
 		},
@@ -97,8 +99,25 @@ func newCmd_DumpCar() *cli.Command {
 				Usage:       "limit the number of nodes to print",
 				Destination: &limit,
 			},
+
+			&cli.BoolFlag{
+				Name:        "summary-csv",
+				Usage:       "instead of the nodes, print one CSV row per block: slot, blocktime, transactions, vote transactions, failed transactions, total fees, compute units",
+				Destination: &summaryCSV,
+			},
+
+			&cli.StringFlag{
+				Name:        "strict",
+				Usage:       "check the CID of every node: 'codec' (the CID must be a dag-cbor, sha2-256 CIDv1, like every old-faithful node) or 'hash' (also recompute the hash of the node); stops at the first bad node",
+				Destination: &strictFlag,
+			},
+			FlagEpochs,
 		},
 		Action: func(c *cli.Context) error {
+			strictness, err := iplddecoders.ParseStrictness(strictFlag)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
 			filter := make(intSlice, 0)
 			if flagPrintFilter != "" {
 				for _, v := range strings.Split(flagPrintFilter, ",") {
@@ -115,236 +134,264 @@ func newCmd_DumpCar() *cli.Command {
 				}
 			}
 
-			carPath := c.Args().First()
-			var file fs.File
-			var err error
-			if carPath == "-" {
-				file = os.Stdin
-			} else {
-				file, err = os.Open(carPath)
-				if err != nil {
-					klog.Exit(err.Error())
-				}
-				defer file.Close()
-			}
-
-			cachingReader, err := readahead.NewCachingReaderFromReader(file, readahead.DefaultChunkSize)
-			if err != nil {
-				klog.Exitf("Failed to create caching reader: %s", err)
-			}
-
-			rd, err := car.NewCarReader(cachingReader)
-			if err != nil {
-				klog.Exitf("Failed to open CAR: %s", err)
-			}
-			{
-				// print roots:
-				roots := rd.Header.Roots
-				klog.Infof("Roots: %d", len(roots))
-				for i, root := range roots {
-					if i == 0 && len(roots) == 1 {
-						klog.Infof("- %s (Epoch CID)", root.String())
-					} else {
-						klog.Infof("- %s", root.String())
+			dumpCar := func(carPath string) error {
+				var file fs.File
+				var err error
+				if carPath == "-" {
+					file = os.Stdin
+				} else {
+					file, err = os.Open(carPath)
+					if err != nil {
+						klog.Exit(err.Error())
 					}
+					defer file.Close()
 				}
-			}
 
-			startedAt := time.Now()
-			numNodesSeen := 0
-			numNodesPrinted := 0
-			defer func() {
-				klog.Infof("Finished in %s", time.Since(startedAt))
-				klog.Infof("Read %d nodes from CAR file", numNodesSeen)
-			}()
-			dotEvery := 100_000
-			klog.Infof("A dot is printed every %d nodes", dotEvery)
-			if filter.empty() {
-				klog.Info("Will print all nodes of all kinds")
-			} else {
-				klog.Info("Will print only nodes of these kinds: ")
-				for _, v := range filter {
-					klog.Infof("- %s", iplddecoders.Kind(v).String())
+				cachingReader, err := readahead.NewCachingReaderFromReader(file, readahead.DefaultChunkSize)
+				if err != nil {
+					klog.Exitf("Failed to create caching reader: %s", err)
 				}
-			}
-			if limit > 0 {
-				klog.Infof("Will print only %d nodes", limit)
-			}
 
-			for {
-				if c.Context.Err() != nil {
-					return c.Context.Err()
-				}
-				block, err := rd.Next()
+				rd, err := car.NewCarReader(cachingReader)
 				if err != nil {
-					if errors.Is(err, io.EOF) {
-						fmt.Println("EOF")
-						break
-					}
-					panic(err)
+					klog.Exitf("Failed to open CAR: %s", err)
 				}
-				numNodesSeen++
-				if numNodesSeen%dotEvery == 0 {
-					fmt.Print(".")
-				}
-				if limit > 0 && numNodesPrinted >= limit {
-					break
+				{
+					// print roots:
+					roots := rd.Header.Roots
+					klog.Infof("Roots: %d", len(roots))
+					for i, root := range roots {
+						if i == 0 && len(roots) == 1 {
+							klog.Infof("- %s (Epoch CID)", root.String())
+						} else {
+							klog.Infof("- %s", root.String())
+						}
+					}
 				}
-				kind := iplddecoders.Kind(block.RawData()[1])
 
-				doPrint := filter.has(int(kind)) || filter.empty()
-				if doPrint {
-					fmt.Printf("\nCID=%s Multicodec=%#x Kind=%s\n", block.Cid(), block.Cid().Type(), kind)
+				startedAt := time.Now()
+				numNodesSeen := 0
+				numNodesPrinted := 0
+				defer func() {
+					klog.Infof("Finished in %s", time.Since(startedAt))
+					klog.Infof("Read %d nodes from CAR file", numNodesSeen)
+				}()
+				dotEvery := 100_000
+				klog.Infof("A dot is printed every %d nodes", dotEvery)
+				if filter.empty() {
+					klog.Info("Will print all nodes of all kinds")
 				} else {
-					continue
+					klog.Info("Will print only nodes of these kinds: ")
+					for _, v := range filter {
+						klog.Infof("- %s", iplddecoders.Kind(v).String())
+					}
+				}
+				if limit > 0 {
+					klog.Infof("Will print only %d nodes", limit)
 				}
 
-				switch kind {
-				case iplddecoders.KindTransaction:
-					decoded, err := iplddecoders.DecodeTransaction(block.RawData())
+				for {
+					if c.Context.Err() != nil {
+						return c.Context.Err()
+					}
+					block, err := rd.Next()
 					if err != nil {
+						if errors.Is(err, io.EOF) {
+							fmt.Println("EOF")
+							break
+						}
 						panic(err)
 					}
-					{
-						if total, ok := decoded.Data.GetTotal(); !ok || total == 1 {
-							completeData := decoded.Data.Bytes()
-							{
-								// verify hash (if present)
-								if ha, ok := decoded.Data.GetHash(); ok {
-									err := ipldbindcode.VerifyHash(completeData, ha)
-									if err != nil {
-										panic(err)
+					numNodesSeen++
+					if numNodesSeen%dotEvery == 0 {
+						fmt.Print(".")
+					}
+					if err := iplddecoders.CheckCid(block.Cid(), block.RawData(), strictness); err != nil {
+						return fmt.Errorf("node #%d: %w", numNodesSeen, err)
+					}
+					if limit > 0 && numNodesPrinted >= limit {
+						break
+					}
+					kind := iplddecoders.Kind(block.RawData()[1])
+
+					doPrint := filter.has(int(kind)) || filter.empty()
+					if doPrint {
+						fmt.Printf("\nCID=%s Multicodec=%#x Kind=%s\n", block.Cid(), block.Cid().Type(), kind)
+					} else {
+						continue
+					}
+
+					switch kind {
+					case iplddecoders.KindTransaction:
+						decoded, err := iplddecoders.DecodeTransaction(block.RawData())
+						if err != nil {
+							panic(err)
+						}
+						{
+							if total, ok := decoded.Data.GetTotal(); !ok || total == 1 {
+								completeData := decoded.Data.Bytes()
+								{
+									// verify hash (if present)
+									if ha, ok := decoded.Data.GetHash(); ok {
+										err := ipldbindcode.VerifyHash(completeData, ha)
+										if err != nil {
+											panic(err)
+										}
 									}
 								}
-							}
-							var tx solana.Transaction
-							if err := bin.UnmarshalBin(&tx, completeData); err != nil {
-								panic(err)
-							} else if len(tx.Signatures) == 0 {
-								panic("no signatures")
-							}
-							if doPrint {
-								fmt.Println("sig=" + tx.Signatures[0].String())
-								spew.Dump(decoded)
-								if prettyPrintTransactions {
-									fmt.Println(tx.String())
-								}
-								numNodesPrinted++
-							}
-						} else {
-							if doPrint {
-								fmt.Println("transaction data is split into multiple objects; skipping printing")
-							}
-						}
-						if total, ok := decoded.Metadata.GetTotal(); !ok || total == 1 {
-							completeBuffer := decoded.Metadata.Bytes()
-							if ha, ok := decoded.Metadata.GetHash(); ok {
-								err := ipldbindcode.VerifyHash(completeBuffer, ha)
-								if err != nil {
+								var tx solana.Transaction
+								if err := bin.UnmarshalBin(&tx, completeData); err != nil {
 									panic(err)
+								} else if len(tx.Signatures) == 0 {
+									panic("no signatures")
+								}
+								if doPrint {
+									if pos, ok := decoded.GetPositionIndex(); ok {
+										fmt.Printf("sig=%s position=%d\n", tx.Signatures[0], pos)
+									} else {
+										fmt.Println("sig=" + tx.Signatures[0].String())
+									}
+									spew.Dump(decoded)
+									if prettyPrintTransactions {
+										fmt.Println(tx.String())
+									}
+									numNodesPrinted++
+								}
+							} else {
+								if doPrint {
+									fmt.Println("transaction data is split into multiple objects; skipping printing")
 								}
 							}
-							if len(completeBuffer) > 0 {
-								uncompressedMeta, err := tooling.DecompressZstd(completeBuffer)
-								if err != nil {
-									panic(err)
+							if total, ok := decoded.Metadata.GetTotal(); !ok || total == 1 {
+								completeBuffer := decoded.Metadata.Bytes()
+								if ha, ok := decoded.Metadata.GetHash(); ok {
+									err := ipldbindcode.VerifyHash(completeBuffer, ha)
+									if err != nil {
+										panic(err)
+									}
 								}
-								status, err := solanatxmetaparsers.ParseAnyTransactionStatusMeta(uncompressedMeta)
-								if err != nil {
-									panic(err)
+								if len(completeBuffer) > 0 {
+									uncompressedMeta, err := tooling.DecompressZstd(completeBuffer)
+									if err != nil {
+										panic(err)
+									}
+									status, err := solanatxmetaparsers.ParseAnyTransactionStatusMeta(uncompressedMeta)
+									if err != nil {
+										panic(err)
+									}
+									if doPrint {
+										spew.Dump(status)
+									}
 								}
+							} else {
 								if doPrint {
-									spew.Dump(status)
+									fmt.Println("transaction metadata is split into multiple objects; skipping printing")
 								}
 							}
-						} else {
-							if doPrint {
-								fmt.Println("transaction metadata is split into multiple objects; skipping printing")
-							}
 						}
-					}
-				case iplddecoders.KindEntry:
-					decoded, err := iplddecoders.DecodeEntry(block.RawData())
-					if err != nil {
-						panic(err)
-					}
-					if doPrint {
-						spew.Dump(decoded)
-						numNodesPrinted++
-					}
-				case iplddecoders.KindBlock:
-					decoded, err := iplddecoders.DecodeBlock(block.RawData())
-					if err != nil {
-						panic(err)
-					}
-					if doPrint {
-						spew.Dump(decoded)
-						numNodesPrinted++
-					}
-				case iplddecoders.KindSubset:
-					decoded, err := iplddecoders.DecodeSubset(block.RawData())
-					if err != nil {
-						panic(err)
-					}
-					if doPrint {
-						spew.Dump(decoded)
-						numNodesPrinted++
-					}
-				case iplddecoders.KindEpoch:
-					decoded, err := iplddecoders.DecodeEpoch(block.RawData())
-					if err != nil {
-						panic(err)
-					}
-					if doPrint {
-						spew.Dump(decoded)
-						numNodesPrinted++
-					}
-				case iplddecoders.KindRewards:
-					decoded, err := iplddecoders.DecodeRewards(block.RawData())
-					if err != nil {
-						panic(err)
-					}
-					if doPrint {
-						spew.Dump(decoded)
-						numNodesPrinted++
+					case iplddecoders.KindEntry:
+						decoded, err := iplddecoders.DecodeEntry(block.RawData())
+						if err != nil {
+							panic(err)
+						}
+						if doPrint {
+							spew.Dump(decoded)
+							numNodesPrinted++
+						}
+					case iplddecoders.KindBlock:
+						decoded, err := iplddecoders.DecodeBlock(block.RawData())
+						if err != nil {
+							panic(err)
+						}
+						if doPrint {
+							spew.Dump(decoded)
+							numNodesPrinted++
+						}
+					case iplddecoders.KindSubset:
+						decoded, err := iplddecoders.DecodeSubset(block.RawData())
+						if err != nil {
+							panic(err)
+						}
+						if doPrint {
+							spew.Dump(decoded)
+							numNodesPrinted++
+						}
+					case iplddecoders.KindEpoch:
+						decoded, err := iplddecoders.DecodeEpoch(block.RawData())
+						if err != nil {
+							panic(err)
+						}
+						if doPrint {
+							spew.Dump(decoded)
+							numNodesPrinted++
+						}
+					case iplddecoders.KindRewards:
+						decoded, err := iplddecoders.DecodeRewards(block.RawData())
+						if err != nil {
+							panic(err)
+						}
+						if doPrint {
+							spew.Dump(decoded)
+							numNodesPrinted++
 
-						if total, ok := decoded.Data.GetTotal(); !ok || total == 1 {
-							completeBuffer := decoded.Data.Bytes()
-							if ha, ok := decoded.Data.GetHash(); ok {
-								err := ipldbindcode.VerifyHash(completeBuffer, ha)
-								if err != nil {
-									panic(err)
-								}
-							}
-							if len(completeBuffer) > 0 {
-								uncompressedRewards, err := tooling.DecompressZstd(completeBuffer)
-								if err != nil {
-									panic(err)
+							if total, ok := decoded.Data.GetTotal(); !ok || total == 1 {
+								completeBuffer := decoded.Data.Bytes()
+								if ha, ok := decoded.Data.GetHash(); ok {
+									err := ipldbindcode.VerifyHash(completeBuffer, ha)
+									if err != nil {
+										panic(err)
+									}
 								}
-								// try decoding as protobuf
-								parsed, err := solanablockrewards.ParseRewards(uncompressedRewards)
-								if err != nil {
-									// TODO: add support for legacy rewards format
-									fmt.Println("Rewards are not protobuf: " + err.Error())
-								} else {
-									spew.Dump(parsed)
+								if len(completeBuffer) > 0 {
+									uncompressedRewards, err := tooling.DecompressZstd(completeBuffer)
+									if err != nil {
+										panic(err)
+									}
+									// try decoding as protobuf
+									parsed, err := solanablockrewards.ParseRewards(uncompressedRewards)
+									if err != nil {
+										// TODO: add support for legacy rewards format
+										fmt.Println("Rewards are not protobuf: " + err.Error())
+									} else {
+										spew.Dump(parsed)
+										if numPartitions, ok, err := solanablockrewards.NumPartitions(uncompressedRewards); err == nil && ok {
+											fmt.Printf("num reward partitions: %d\n", numPartitions)
+										}
+									}
 								}
+							} else {
+								fmt.Println("rewards data is split into multiple objects; skipping printing")
 							}
-						} else {
-							fmt.Println("rewards data is split into multiple objects; skipping printing")
 						}
+					case iplddecoders.KindDataFrame:
+						decoded, err := iplddecoders.DecodeDataFrame(block.RawData())
+						if err != nil {
+							panic(err)
+						}
+						spew.Dump(decoded)
+					default:
+						panic("unknown kind: " + kind.String())
 					}
-				case iplddecoders.KindDataFrame:
-					decoded, err := iplddecoders.DecodeDataFrame(block.RawData())
-					if err != nil {
-						panic(err)
-					}
-					spew.Dump(decoded)
-				default:
-					panic("unknown kind: " + kind.String())
+				}
+				klog.Infof("CAR file traversed successfully")
+				return nil
+			}
+
+			carPaths, err := expandEpochsArg(c, c.Args().First())
+			if err != nil {
+				return err
+			}
+			if summaryCSV {
+				return writeBlockSummaryCSV(c.Context, os.Stdout, carPaths)
+			}
+			for _, carPath := range carPaths {
+				if len(carPaths) > 1 {
+					klog.Infof("Dumping %s", carPath)
+				}
+				if err := dumpCar(carPath); err != nil {
+					return err
 				}
 			}
-			klog.Infof("CAR file traversed successfully")
 			return nil
 		},
 	}
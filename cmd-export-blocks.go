@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/carwriter/bigtablesource"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_ExportBlocks() *cli.Command {
+	var (
+		carPath   string
+		slotRange string
+		outputDir string
+	)
+	return &cli.Command{
+		Name:  "export-blocks",
+		Usage: "Export a range of slots of an epoch CAR as the rows of the Solana warehouse Bigtable (ConfirmedBlock protobufs).",
+		Description: "Writes the blocks of the slot range as the cells of the \"blocks\" table (ConfirmedBlock protobufs, with the transactions, " +
+			"their metas and the rewards) and of the \"entries\" table (the PoH entries), to <out>/blocks/<row key> and <out>/entries/<row key>, " +
+			"where the row key is the slot as 16 hex digits, compressed as the solana-storage-bigtable crate does. " +
+			"They can be loaded into a Bigtable instance (or emulator) for the validator's and ledger-tool's bigtable commands, " +
+			"or decoded directly for debugging. Legacy (bincode) metas are converted to protobuf. " +
+			"A RocksDB blockstore can't be rebuilt from a CAR: it stores the shreds of the blocks, which are signed by their leader, and the signatures of the shreds are not archived.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "car",
+				Usage:       "Source epoch CAR file",
+				Required:    true,
+				Destination: &carPath,
+			},
+			&cli.StringFlag{
+				Name:        "slots",
+				Usage:       "Slot range to export, inclusive (e.g. 1000-1999)",
+				Required:    true,
+				Destination: &slotRange,
+			},
+			&cli.StringFlag{
+				Name:        "out",
+				Aliases:     []string{"o"},
+				Usage:       "Output directory",
+				Required:    true,
+				Destination: &outputDir,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			from, to, err := parseSlotRange(slotRange)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("invalid --slots: %s", err), 1)
+			}
+			startedAt := time.Now()
+			stats, err := exportBlocks(c.Context, carPath, from, to, outputDir)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			klog.Infof(
+				"Exported %d blocks (slots %d-%d) to %s in %s",
+				stats.blocks,
+				stats.firstSlot,
+				stats.lastSlot,
+				outputDir,
+				time.Since(startedAt).Truncate(time.Millisecond),
+			)
+			return nil
+		},
+	}
+}
+
+type exportBlocksStats struct {
+	blocks    int
+	firstSlot uint64
+	lastSlot  uint64
+}
+
+// errPositionsDone stops the listing of the blocks of a CAR.
+var errPositionsDone = errors.New("done")
+
+// exportBlocks writes the blocks of the slot range [from, to] of the CAR at carPath to outputDir,
+// as the cells of their rows in the "blocks" and "entries" tables of the Solana warehouse Bigtable.
+func exportBlocks(ctx context.Context, carPath string, from, to uint64, outputDir string) (*exportBlocksStats, error) {
+	source := carwriter.NewCarSource(carPath)
+	defer source.Close()
+
+	// the blocks of the range, and the one before it, whose blockhash is the previous blockhash
+	// of the first block.
+	var (
+		positions []carwriter.BlockPosition
+		previous  *carwriter.BlockPosition
+	)
+	err := source.Positions(ctx, func(pos carwriter.BlockPosition) error {
+		switch {
+		case pos.Slot < from:
+			previous = &pos
+		case pos.Slot <= to:
+			positions = append(positions, pos)
+		default:
+			return errPositionsDone
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPositionsDone) {
+		return nil, fmt.Errorf("failed to list the blocks of %s: %w", carPath, err)
+	}
+	if len(positions) == 0 {
+		return nil, errors.New("no blocks in the slot range")
+	}
+
+	for _, table := range []string{"blocks", "entries"} {
+		if err := os.MkdirAll(filepath.Join(outputDir, table), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	var previousBlockhash solana.Hash
+	var previousSlot uint64
+	if previous != nil {
+		block, err := source.ReadBlock(*previous)
+		if err != nil {
+			return nil, err
+		}
+		if previousBlockhash, err = bigtablesource.Blockhash(block); err != nil {
+			return nil, fmt.Errorf("slot %d: %w", block.Slot, err)
+		}
+		previousSlot = block.Slot
+	}
+	stats := &exportBlocksStats{firstSlot: positions[0].Slot, lastSlot: positions[len(positions)-1].Slot}
+	for _, pos := range positions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		block, err := source.ReadBlock(pos)
+		if err != nil {
+			return nil, err
+		}
+		if block.ParentSlot != previousSlot {
+			previousBlockhash = solana.Hash{}
+		}
+		if previousBlockhash.IsZero() {
+			klog.Warningf("The parent (slot %d) of slot %d is not in the CAR; its previous blockhash is left empty", block.ParentSlot, block.Slot)
+		}
+		blockCell, entriesCell, err := bigtablesource.ExportBlock(block, previousBlockhash)
+		if err != nil {
+			return nil, fmt.Errorf("slot %d: %w", block.Slot, err)
+		}
+		key := bigtablesource.SlotKey(block.Slot)
+		if err := os.WriteFile(filepath.Join(outputDir, "blocks", key), blockCell, 0o644); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(outputDir, "entries", key), entriesCell, 0o644); err != nil {
+			return nil, err
+		}
+		if previousBlockhash, err = bigtablesource.Blockhash(block); err != nil {
+			return nil, fmt.Errorf("slot %d: %w", block.Slot, err)
+		}
+		previousSlot = block.Slot
+		stats.blocks++
+	}
+	return stats, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/rpcpool/yellowstone-faithful/carwriter/bigtablesource"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExportBlocks(t *testing.T) {
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+
+	outputDir := t.TempDir()
+	stats, err := exportBlocks(context.Background(), carPath, 1, 5, outputDir)
+	require.NoError(t, err)
+	require.Equal(t, 4, stats.blocks)
+	require.Equal(t, uint64(1), stats.firstSlot)
+	require.Equal(t, uint64(5), stats.lastSlot)
+
+	readBlock := func(t *testing.T, slot uint64) *confirmed_block.ConfirmedBlock {
+		cell, err := os.ReadFile(filepath.Join(outputDir, "blocks", bigtablesource.SlotKey(slot)))
+		require.NoError(t, err)
+		// a zstd cell: the compression method, then the compressed protobuf.
+		raw, err := tooling.DecompressZstd(cell[4:])
+		require.NoError(t, err)
+		var block confirmed_block.ConfirmedBlock
+		require.NoError(t, proto.Unmarshal(raw, &block))
+		return &block
+	}
+	blocks := make(map[uint64]*confirmed_block.ConfirmedBlock)
+	for _, slot := range []uint64{1, 2, 4, 5} {
+		blocks[slot] = readBlock(t, slot)
+		_, err := os.Stat(filepath.Join(outputDir, "entries", bigtablesource.SlotKey(slot)))
+		require.NoError(t, err)
+	}
+	_, err = os.Stat(filepath.Join(outputDir, "blocks", bigtablesource.SlotKey(3)))
+	require.True(t, os.IsNotExist(err))
+
+	// the blocks are chained by their blockhashes, across the skipped slot 3; the parent of
+	// slot 1 is read from the CAR although it is not exported.
+	require.NotEmpty(t, blocks[1].PreviousBlockhash)
+	require.Equal(t, blocks[1].Blockhash, blocks[2].PreviousBlockhash)
+	require.Equal(t, uint64(2), blocks[4].ParentSlot)
+	require.Equal(t, blocks[2].Blockhash, blocks[4].PreviousBlockhash)
+	require.Equal(t, blocks[4].Blockhash, blocks[5].PreviousBlockhash)
+
+	for _, tx := range fixture.Transactions {
+		block, ok := blocks[tx.Slot]
+		if !ok {
+			continue
+		}
+		var found *confirmed_block.ConfirmedTransaction
+		for _, got := range block.Transactions {
+			if string(got.Transaction.Signatures[0]) == string(tx.Signature[:]) {
+				found = got
+			}
+		}
+		require.NotNil(t, found, "transaction %s of slot %d", tx.Signature, tx.Slot)
+		// the metas are all protobuf, the legacy ones converted.
+		require.Equal(t, tx.Spec.Meta != carfixture.MetaNone, found.Meta != nil, "transaction %s of slot %d", tx.Signature, tx.Slot)
+		if found.Meta != nil {
+			require.Equal(t, tx.Spec.Failed, found.Meta.Err != nil, "transaction %s of slot %d", tx.Signature, tx.Slot)
+		}
+	}
+
+	_, err = exportBlocks(context.Background(), carPath, 3, 3, t.TempDir())
+	require.ErrorContains(t, err, "no blocks in the slot range")
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/accum"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_Extract() *cli.Command {
+	var (
+		carPath    string
+		slotRange  string
+		outputFile string
+		validate   bool
+		accounts   cli.StringSlice
+		programs   cli.StringSlice
+		keepEmpty  bool
+	)
+	return &cli.Command{
+		Name:  "extract",
+		Usage: "Extract a range of slots of an epoch CAR into a standalone CAR file.",
+		Description: "Writes the blocks of the slot range (with their entries, transactions, metas and rewards) to a new CAR, " +
+			"with its own subset and epoch nodes, e.g. to share a repro case or to build a test fixture. " +
+			"The nodes of the blocks are re-encoded identically, so they have the same CIDs as in the source CAR. " +
+			"With --include-account or --include-program, only the matching transactions are kept (so the filtered " +
+			"blocks get new CIDs), and the blocks left without transactions are dropped unless --keep-empty is set.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "car",
+				Usage:       "Source epoch CAR file",
+				Required:    true,
+				Destination: &carPath,
+			},
+			&cli.StringFlag{
+				Name:        "slots",
+				Usage:       "Slot range to extract, inclusive (e.g. 1000-1999); both slots must be in the same epoch",
+				Required:    true,
+				Destination: &slotRange,
+			},
+			&cli.StringFlag{
+				Name:        "out",
+				Aliases:     []string{"o"},
+				Usage:       "Output CAR file",
+				Required:    true,
+				Destination: &outputFile,
+			},
+			&cli.BoolFlag{
+				Name:        "validate",
+				Usage:       "Validate the CAR file once it is written",
+				Value:       true,
+				Destination: &validate,
+			},
+			&cli.StringSliceFlag{
+				Name:        "include-account",
+				Usage:       "Keep only the transactions that reference this account (base58), including through address lookup tables; can be specified multiple times",
+				Destination: &accounts,
+			},
+			&cli.StringSliceFlag{
+				Name:        "include-program",
+				Usage:       "Keep only the transactions that invoke this program (base58), including in inner instructions; can be specified multiple times",
+				Destination: &programs,
+			},
+			&cli.BoolFlag{
+				Name:        "keep-empty",
+				Usage:       "With --include-account or --include-program, keep the blocks without matching transactions",
+				Destination: &keepEmpty,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			from, to, err := parseSlotRange(slotRange)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("invalid --slots: %s", err), 1)
+			}
+			filter, err := newExtractFilter(accounts.Value(), programs.Value(), keepEmpty)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			startedAt := time.Now()
+			stats, err := extractCar(c.Context, carPath, from, to, outputFile, filter)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			klog.Infof(
+				"Extracted %d blocks (%d transactions, slots %d-%d) to %s in %s; root %s",
+				stats.blocks,
+				stats.transactions,
+				stats.firstSlot,
+				stats.lastSlot,
+				outputFile,
+				time.Since(startedAt).Truncate(time.Millisecond),
+				stats.root,
+			)
+			if validate {
+				if _, err := carwriter.ValidateCanonical(outputFile); err != nil {
+					return cli.Exit(fmt.Sprintf("validation of %s failed: %s", outputFile, err), 1)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// parseSlotRange parses an inclusive slot range in the form "A-B".
+func parseSlotRange(s string) (uint64, uint64, error) {
+	fromStr, toStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected a range like 1000-1999, got %q", s)
+	}
+	from, err := strconv.ParseUint(strings.TrimSpace(fromStr), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid first slot: %w", err)
+	}
+	to, err := strconv.ParseUint(strings.TrimSpace(toStr), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid last slot: %w", err)
+	}
+	if to < from {
+		return 0, 0, fmt.Errorf("last slot %d is before first slot %d", to, from)
+	}
+	return from, to, nil
+}
+
+// extractFilter keeps the transactions that reference one of the accounts or invoke one of the programs.
+type extractFilter struct {
+	accounts  map[solana.PublicKey]struct{}
+	programs  map[solana.PublicKey]struct{}
+	keepEmpty bool
+}
+
+// newExtractFilter returns nil (keep everything) if no accounts and no programs are given.
+func newExtractFilter(accounts, programs []string, keepEmpty bool) (*extractFilter, error) {
+	if len(accounts) == 0 && len(programs) == 0 {
+		return nil, nil
+	}
+	filter := &extractFilter{
+		accounts:  make(map[solana.PublicKey]struct{}, len(accounts)),
+		programs:  make(map[solana.PublicKey]struct{}, len(programs)),
+		keepEmpty: keepEmpty,
+	}
+	for _, account := range accounts {
+		key, err := solana.PublicKeyFromBase58(account)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --include-account %q: %w", account, err)
+		}
+		filter.accounts[key] = struct{}{}
+	}
+	for _, program := range programs {
+		key, err := solana.PublicKeyFromBase58(program)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --include-program %q: %w", program, err)
+		}
+		filter.programs[key] = struct{}{}
+	}
+	return filter, nil
+}
+
+func (f *extractFilter) matches(tx *accum.TransactionWithSlot) bool {
+	if len(f.accounts) > 0 {
+		for _, key := range accountKeysOfTransaction(tx) {
+			if _, ok := f.accounts[key]; ok {
+				return true
+			}
+		}
+	}
+	if len(f.programs) == 0 {
+		return false
+	}
+	for _, program := range invokedProgramsOfTransaction(tx) {
+		if _, ok := f.programs[program]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// apply removes the transactions of the block that don't match, and reports whether the block is kept.
+// The entries are kept, even if they are left without transactions.
+func (f *extractFilter) apply(block *carwriter.Block) (bool, error) {
+	kept := 0
+	for i := range block.Entries {
+		entry := &block.Entries[i]
+		transactions := entry.Transactions[:0]
+		for _, tx := range entry.Transactions {
+			decoded := &accum.TransactionWithSlot{Slot: block.Slot}
+			if err := decoded.Transaction.UnmarshalWithDecoder(bin.NewBinDecoder(tx.Data)); err != nil {
+				return false, fmt.Errorf("slot %d: failed to decode transaction: %w", block.Slot, err)
+			}
+			if len(tx.Metadata) > 0 {
+				// without a meta, only the static accounts and the top-level instructions are matched.
+				decoded.Metadata, _ = parseBrowsedMeta(tx.Metadata)
+			}
+			if f.matches(decoded) {
+				transactions = append(transactions, tx)
+			}
+		}
+		entry.Transactions = transactions
+		kept += len(transactions)
+	}
+	return kept > 0 || f.keepEmpty, nil
+}
+
+type extractStats struct {
+	root         cid.Cid
+	blocks       int
+	transactions int
+	firstSlot    uint64
+	lastSlot     uint64
+}
+
+// extractCar writes the blocks of the slot range [from, to] of the CAR at carPath to a new CAR at outputFile,
+// keeping only the transactions that match the filter, if any.
+// On error, the output file is removed.
+func extractCar(ctx context.Context, carPath string, from, to uint64, outputFile string, filter *extractFilter) (*extractStats, error) {
+	epoch := slottools.CalcEpochForSlot(from)
+	if slottools.CalcEpochForSlot(to) != epoch {
+		return nil, fmt.Errorf("slots %d and %d are not in the same epoch", from, to)
+	}
+	w, err := carwriter.Create(outputFile, epoch, carwriter.DefaultMaxSubsetBlocks)
+	if err != nil {
+		return nil, err
+	}
+	stats := &extractStats{}
+	fail := func(err error) (*extractStats, error) {
+		w.Close()
+		os.Remove(outputFile)
+		return nil, err
+	}
+
+	source := carwriter.NewCarSource(carPath)
+	defer source.Close()
+	err = source.Blocks(ctx, from, to, func(block *carwriter.Block) error {
+		if filter != nil {
+			keep, err := filter.apply(block)
+			if err != nil || !keep {
+				return err
+			}
+		}
+		if _, err := w.WriteBlock(block); err != nil {
+			return err
+		}
+		if stats.blocks == 0 {
+			stats.firstSlot = block.Slot
+		}
+		stats.lastSlot = block.Slot
+		stats.blocks++
+		for _, entry := range block.Entries {
+			stats.transactions += len(entry.Transactions)
+		}
+		return nil
+	})
+	if err != nil {
+		return fail(fmt.Errorf("failed to extract blocks from %s: %w", carPath, err))
+	}
+	if stats.blocks == 0 {
+		if filter != nil {
+			return fail(errors.New("no transactions in the slot range match the filter"))
+		}
+		return fail(errors.New("no blocks in the slot range"))
+	}
+	stats.root, err = w.Finish()
+	if err != nil {
+		return fail(err)
+	}
+	return stats, nil
+}
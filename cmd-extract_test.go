@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSlotRange(t *testing.T) {
+	from, to, err := parseSlotRange("1000-1999")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1000), from)
+	require.Equal(t, uint64(1999), to)
+
+	from, to, err = parseSlotRange("7-7")
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), from)
+	require.Equal(t, uint64(7), to)
+
+	for _, bad := range []string{"", "1000", "a-2", "1-b", "5-4", "-1-2"} {
+		_, _, err := parseSlotRange(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestExtractCar(t *testing.T) {
+	ctx := context.Background()
+	originalPath := filepath.Join("fixtures", "epoch-0-1.car")
+	_, originalNodes := readCarNodes(t, originalPath)
+	outPath := filepath.Join(t.TempDir(), "subset.car")
+
+	stats, err := extractCar(ctx, originalPath, 3, 6, outPath, nil)
+	require.NoError(t, err)
+	require.Equal(t, 4, stats.blocks)
+	require.Equal(t, uint64(3), stats.firstSlot)
+	require.Equal(t, uint64(6), stats.lastSlot)
+
+	validation, err := carwriter.Validate(outPath)
+	require.NoError(t, err)
+	require.Equal(t, stats.root, validation.Root)
+	require.Equal(t, uint64(4), validation.Blocks)
+	require.Equal(t, uint64(3), validation.FirstSlot)
+	require.Equal(t, uint64(6), validation.LastSlot)
+
+	// The DAGs of the extracted blocks are the same as in the source CAR.
+	_, nodes := readCarNodes(t, outPath)
+	original := make(map[cid.Cid][]byte, len(originalNodes))
+	for _, node := range originalNodes {
+		original[node.cid] = node.data
+	}
+	for _, node := range nodes {
+		switch iplddecoders.Kind(node.data[1]) {
+		case iplddecoders.KindSubset, iplddecoders.KindEpoch:
+			continue
+		}
+		require.Equal(t, original[node.cid], node.data, "node %s", node.cid)
+	}
+	for slot := uint64(3); slot <= 6; slot++ {
+		dag, _ := blockDag(t, nodes, slot)
+		originalDag, _ := blockDag(t, originalNodes, slot)
+		require.Equal(t, originalDag[len(originalDag)-1].cid, dag[len(dag)-1].cid)
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		emptyPath := filepath.Join(t.TempDir(), "empty.car")
+		_, err := extractCar(ctx, originalPath, 1000, 2000, emptyPath, nil)
+		require.Error(t, err)
+		_, statErr := os.Stat(emptyPath)
+		require.ErrorIs(t, statErr, os.ErrNotExist)
+
+		_, err = extractCar(ctx, originalPath, 431999, 432000, emptyPath, nil)
+		require.ErrorContains(t, err, "same epoch")
+	})
+}
+
+func TestExtractCarFilter(t *testing.T) {
+	ctx := context.Background()
+	originalPath := filepath.Join("fixtures", "epoch-0-1.car")
+	var blocks []*carwriter.Block
+	source := carwriter.NewCarSource(originalPath)
+	require.NoError(t, source.Blocks(ctx, 0, 10, func(block *carwriter.Block) error {
+		blocks = append(blocks, block)
+		return nil
+	}))
+	require.NoError(t, source.Close())
+	countTransactions := func(blocks []*carwriter.Block) (n int) {
+		for _, block := range blocks {
+			for _, entry := range block.Entries {
+				n += len(entry.Transactions)
+			}
+		}
+		return n
+	}
+	// the fee payer of the first transaction of the range.
+	var payer solana.PublicKey
+	withTransactions := 0
+	for _, block := range blocks {
+		if countTransactions([]*carwriter.Block{block}) > 0 {
+			withTransactions++
+		}
+		for _, entry := range block.Entries {
+			for _, tx := range entry.Transactions {
+				var decoded solana.Transaction
+				require.NoError(t, decoded.UnmarshalWithDecoder(bin.NewBinDecoder(tx.Data)))
+				if payer.IsZero() {
+					payer = decoded.Message.AccountKeys[0]
+				}
+			}
+		}
+	}
+	require.False(t, payer.IsZero())
+
+	extract := func(t *testing.T, accounts, programs []string, keepEmpty bool) (*extractStats, []*carwriter.Block) {
+		t.Helper()
+		filter, err := newExtractFilter(accounts, programs, keepEmpty)
+		require.NoError(t, err)
+		outPath := filepath.Join(t.TempDir(), "filtered.car")
+		stats, err := extractCar(ctx, originalPath, 0, 10, outPath, filter)
+		if err != nil {
+			return nil, nil
+		}
+		_, err = carwriter.Validate(outPath)
+		require.NoError(t, err)
+		var got []*carwriter.Block
+		out := carwriter.NewCarSource(outPath)
+		defer out.Close()
+		require.NoError(t, out.Blocks(ctx, 0, 10, func(block *carwriter.Block) error {
+			got = append(got, block)
+			return nil
+		}))
+		return stats, got
+	}
+
+	t.Run("program", func(t *testing.T) {
+		// all the transactions of the first mainnet blocks are votes; the blocks without transactions are dropped.
+		stats, got := extract(t, nil, []string{solana.VoteProgramID.String()}, false)
+		require.NotNil(t, stats)
+		require.Less(t, withTransactions, len(blocks))
+		require.Equal(t, withTransactions, stats.blocks)
+		require.Equal(t, countTransactions(blocks), stats.transactions)
+		require.Equal(t, countTransactions(blocks), countTransactions(got))
+	})
+	t.Run("account", func(t *testing.T) {
+		stats, got := extract(t, []string{payer.String()}, nil, false)
+		require.NotNil(t, stats)
+		require.NotEmpty(t, got)
+		require.Less(t, stats.transactions, countTransactions(blocks))
+		require.Equal(t, stats.transactions, countTransactions(got))
+		for _, block := range got {
+			require.NotZero(t, countTransactions([]*carwriter.Block{block}), "slot %d", block.Slot)
+			for _, entry := range block.Entries {
+				for _, tx := range entry.Transactions {
+					var decoded solana.Transaction
+					require.NoError(t, decoded.UnmarshalWithDecoder(bin.NewBinDecoder(tx.Data)))
+					require.Contains(t, decoded.Message.AccountKeys, payer)
+				}
+			}
+		}
+	})
+	t.Run("keep-empty", func(t *testing.T) {
+		other := solana.NewWallet().PublicKey().String()
+		stats, _ := extract(t, []string{other}, nil, false)
+		require.Nil(t, stats, "no block matches")
+		stats, got := extract(t, []string{other}, []string{other}, true)
+		require.NotNil(t, stats)
+		require.Equal(t, len(blocks), stats.blocks)
+		require.Zero(t, stats.transactions)
+		require.Zero(t, countTransactions(got))
+		for i, block := range got {
+			require.Equal(t, blocks[i].Slot, block.Slot)
+			require.Equal(t, len(blocks[i].Entries), len(block.Entries))
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := newExtractFilter([]string{"not-an-account"}, nil, false)
+		require.ErrorContains(t, err, "--include-account")
+		filter, err := newExtractFilter(nil, nil, true)
+		require.NoError(t, err)
+		require.Nil(t, filter)
+	})
+}
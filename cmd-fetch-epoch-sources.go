@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/ipfsgateway"
+	"github.com/rpcpool/yellowstone-faithful/torrent"
+	"github.com/rpcpool/yellowstone-faithful/uri"
+)
+
+// defaultIPFSGateway is the trustless gateway used by fetch-epoch --source=ipfs.
+const defaultIPFSGateway = "https://trustless-gateway.link"
+
+// epochFileSource is where the published files of an epoch are downloaded from. Whatever the
+// source, the files are checked against the manifest once downloaded.
+type epochFileSource interface {
+	// fetch downloads the file name to part, resuming after what part already has.
+	fetch(ctx context.Context, name string, part string) error
+	String() string
+}
+
+// newFileSource returns the source of the files of the epoch selected by opts.source.
+func (d *epochDownloader) newFileSource(ctx context.Context, epoch uint64, manifestURL string, opts *fetchEpochOptions) (epochFileSource, error) {
+	// the URL of the torrent (or of the IPFS CID), next to the attestation by default.
+	locate := func(tmpl uri.Template, name string) (string, error) {
+		if tmpl == "" {
+			return fileURL(manifestURL, name), nil
+		}
+		return tmpl.ForEpoch(epoch)
+	}
+	switch opts.source {
+	case "", "http":
+		return &httpSource{d: d, manifestURL: manifestURL}, nil
+	case "torrent":
+		torrentURL, err := locate(opts.torrentURL, fmt.Sprintf("epoch-%d.torrent", epoch))
+		if err != nil {
+			return nil, err
+		}
+		data, err := d.get(ctx, torrentURL, 64<<20)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the torrent: %w", err)
+		}
+		mi, err := torrent.ParseMetaInfo(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the torrent at %s: %w", torrentURL, err)
+		}
+		return &torrentSource{d: d, mi: mi}, nil
+	case "ipfs":
+		cidURL, err := locate(opts.ipfsCidURL, fmt.Sprintf("epoch-%d.ipfs.cid", epoch))
+		if err != nil {
+			return nil, err
+		}
+		data, err := d.get(ctx, cidURL, 1<<10)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the IPFS CID of the epoch: %w", err)
+		}
+		root, err := cid.Decode(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CID at %s: %w", cidURL, err)
+		}
+		gateway := opts.ipfsGateway
+		if gateway == "" {
+			gateway = defaultIPFSGateway
+		}
+		return &ipfsSource{d: d, gateway: gateway, root: root}, nil
+	default:
+		return nil, fmt.Errorf("unknown source %q", opts.source)
+	}
+}
+
+// httpSource downloads the files from the directory of the attestation.
+type httpSource struct {
+	d           *epochDownloader
+	manifestURL string
+}
+
+func (s *httpSource) fetch(ctx context.Context, name string, part string) error {
+	return s.d.downloadOnce(ctx, fileURL(s.manifestURL, name), part)
+}
+
+func (s *httpSource) String() string {
+	return fileURL(s.manifestURL, "")
+}
+
+// ipfsSource downloads the files from an IPFS trustless gateway: they are the files of a UnixFS
+// directory, and every block is checked against its CID.
+type ipfsSource struct {
+	d       *epochDownloader
+	gateway string
+	root    cid.Cid
+}
+
+func (s *ipfsSource) fetch(ctx context.Context, name string, part string) error {
+	file, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	err = ipfsgateway.FetchFile(ctx, s.d.client, s.gateway, s.root, name, info.Size(), &limitedWriter{ctx: ctx, w: file, limiter: s.d.limiter})
+	var statusErr *ipfsgateway.StatusError
+	if errors.As(err, &statusErr) {
+		return &httpStatusError{url: statusErr.URL, code: statusErr.Code}
+	}
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func (s *ipfsSource) String() string {
+	return s.gateway + "/ipfs/" + s.root.String()
+}
+
+// torrentSource downloads the files from the peers (and the web seeds) of the torrent of the
+// epoch; its files are the files of the epoch, at the top level of the torrent.
+type torrentSource struct {
+	d  *epochDownloader
+	mi *torrent.MetaInfo
+}
+
+func (s *torrentSource) fetch(ctx context.Context, name string, part string) error {
+	// the torrent is downloaded to the file itself (it checks and keeps the pieces that are
+	// already there), which is then handed over as part.
+	path := filepath.Join(filepath.Dir(part), name)
+	if _, err := os.Stat(part); err == nil {
+		if err := os.Rename(part, path); err != nil {
+			return err
+		}
+	}
+	err := torrent.Download(ctx, s.mi, filepath.Dir(part), &torrent.Options{
+		HTTPClient: s.d.client,
+		Files:      []string{name},
+		WrapReader: func(r io.Reader) io.Reader {
+			return &limitedReader{ctx: ctx, r: r, limiter: s.d.limiter}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return os.Rename(path, part)
+}
+
+func (s *torrentSource) String() string {
+	return fmt.Sprintf("torrent %s (%x)", s.mi.Name, s.mi.InfoHash)
+}
+
+type limitedWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *bandwidthLimiter
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if err := w.limiter.wait(w.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
@@ -0,0 +1,794 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/bzip2"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/attestation"
+	splitcarfetcher "github.com/rpcpool/yellowstone-faithful/split-car-fetcher"
+	"github.com/rpcpool/yellowstone-faithful/uri"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog/v2"
+)
+
+// defaultEpochManifestURL is where the attestations of the epochs are published; the other
+// files of an epoch are next to it.
+const defaultEpochManifestURL = "https://files.old-faithful.net/{{.epoch}}/epoch-{{.epoch}}.attestation.json"
+
+// directoryIndexKinds are the indexes that are directories; they are published as
+// <name>.tar.bz2 archives.
+var directoryIndexKinds = map[string]bool{
+	"gsfa":           true,
+	"gpfa":           true,
+	"token_balances": true,
+}
+
+func newCmd_FetchEpoch() *cli.Command {
+	var epochs string
+	var dest string
+	var manifestURL string
+	var signers cli.StringSlice
+	var parallel int
+	var bandwidthLimit float64
+	var retries int
+	var genesisPath string
+	var source string
+	var torrentURL string
+	var ipfsGateway string
+	var ipfsCidURL string
+	return &cli.Command{
+		Name:  "fetch-epoch",
+		Usage: "Download the CAR file and the indexes of published epochs, ready to serve.",
+		Description: "Reads the attestation (signed manifest, see attest) of each epoch, downloads the CAR file (or its pieces, which are then reassembled) and all the indexes it lists next to it, " +
+			"and checks them against the SHA256 (and piece CIDs) of the manifest. The files of epoch N go to <dest>/N/, and the epoch config file to <dest>/epoch-N.yaml. " +
+			"Interrupted downloads are resumed, and the files that are already there and match the manifest are not downloaded again. " +
+			"The files can also be downloaded with BitTorrent (--source=torrent) or from an IPFS trustless gateway (--source=ipfs); the attestation is always read from --manifest-url.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "epoch",
+				Usage:       "Epoch to download; also a list or ranges of epochs (e.g. 0-10,12)",
+				Required:    true,
+				Destination: &epochs,
+			},
+			&cli.StringFlag{
+				Name:        "dest",
+				Usage:       "Directory to download the epochs to",
+				Required:    true,
+				Destination: &dest,
+			},
+			&cli.StringFlag{
+				Name:        "manifest-url",
+				Usage:       "URL of the attestation of an epoch, with {{.epoch}} placeholders; the files of the epoch are downloaded from the same directory",
+				Value:       defaultEpochManifestURL,
+				Destination: &manifestURL,
+			},
+			&cli.StringSliceFlag{
+				Name:        "signer",
+				Usage:       "Public key (base58) of a trusted signer of the attestations; can be specified multiple times. If not set, the signatures are not checked",
+				Destination: &signers,
+			},
+			&cli.IntFlag{
+				Name:        "parallel",
+				Usage:       "Number of files downloaded at the same time (across all the epochs)",
+				Value:       4,
+				Destination: &parallel,
+			},
+			&cli.Float64Flag{
+				Name:        "bandwidth-limit",
+				Usage:       "Maximum total download speed, in MB/s; 0 means no limit",
+				Destination: &bandwidthLimit,
+			},
+			&cli.StringFlag{
+				Name:        "genesis",
+				Usage:       "Path of the genesis file of the cluster (genesis.tar.bz2), set in the config file of epoch 0; required to download epoch 0",
+				TakesFile:   true,
+				Destination: &genesisPath,
+			},
+			&cli.IntFlag{
+				Name:        "retries",
+				Usage:       "Number of times a failed download is retried (resuming where it stopped)",
+				Value:       5,
+				Destination: &retries,
+			},
+			&cli.StringFlag{
+				Name:        "source",
+				Usage:       "Where to download the files of the epochs from: http (next to the attestation), torrent, or ipfs",
+				Value:       "http",
+				Destination: &source,
+			},
+			&cli.StringFlag{
+				Name:        "torrent-url",
+				Usage:       "URL of the .torrent file of an epoch, with {{.epoch}} placeholders, for --source=torrent; by default epoch-N.torrent next to the attestation",
+				Destination: &torrentURL,
+			},
+			&cli.StringFlag{
+				Name:        "ipfs-gateway",
+				Usage:       "URL of the IPFS trustless gateway, for --source=ipfs",
+				Value:       defaultIPFSGateway,
+				Destination: &ipfsGateway,
+			},
+			&cli.StringFlag{
+				Name:        "ipfs-cid-url",
+				Usage:       "URL of the file with the CID of the IPFS directory of an epoch, with {{.epoch}} placeholders, for --source=ipfs; by default epoch-N.ipfs.cid next to the attestation",
+				Destination: &ipfsCidURL,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			epochList, err := uri.ParseEpochs(epochs)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			tmpl := uri.Template(manifestURL)
+			if err := tmpl.Validate(); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			var trusted []solana.PublicKey
+			for _, signer := range signers.Value() {
+				key, err := solana.PublicKeyFromBase58(signer)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("invalid signer %q: %s", signer, err), 1)
+				}
+				trusted = append(trusted, key)
+			}
+			if len(trusted) == 0 {
+				klog.Warning("No --signer: the signatures of the attestations are not checked")
+			}
+			if epochList[0] == 0 && genesisPath == "" {
+				return cli.Exit("--genesis is required to serve epoch 0", 1)
+			}
+			if parallel < 1 {
+				return cli.Exit("--parallel must be at least 1", 1)
+			}
+			if !slices.Contains([]string{"http", "torrent", "ipfs"}, source) {
+				return cli.Exit(fmt.Sprintf("invalid --source %q: must be http, torrent or ipfs", source), 1)
+			}
+			for _, tmpl := range []uri.Template{uri.Template(torrentURL), uri.Template(ipfsCidURL)} {
+				if tmpl == "" {
+					continue
+				}
+				if err := tmpl.Validate(); err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+			}
+			if err := os.MkdirAll(dest, 0o755); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			d := newEpochDownloader(parallel, int64(bandwidthLimit*1e6), retries)
+			opts := &fetchEpochOptions{
+				dest:        dest,
+				trusted:     trusted,
+				source:      source,
+				torrentURL:  uri.Template(torrentURL),
+				ipfsGateway: ipfsGateway,
+				ipfsCidURL:  uri.Template(ipfsCidURL),
+			}
+			if genesisPath != "" {
+				if opts.genesisPath, err = filepath.Abs(genesisPath); err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+			}
+			wg, ctx := errgroup.WithContext(c.Context)
+			for _, epoch := range epochList {
+				epoch := epoch
+				wg.Go(func() error {
+					manifestURL, err := tmpl.ForEpoch(epoch)
+					if err != nil {
+						return err
+					}
+					configPath, err := d.fetchEpoch(ctx, epoch, manifestURL, opts)
+					if err != nil {
+						return fmt.Errorf("epoch %d: %w", epoch, err)
+					}
+					klog.Infof("[OK] Epoch %d is ready: %s", epoch, configPath)
+					return nil
+				})
+			}
+			if err := wg.Wait(); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			return nil
+		},
+	}
+}
+
+// epochDownloader downloads the files of epochs, with a limit on the number of concurrent
+// downloads and on the total bandwidth.
+type epochDownloader struct {
+	client     *http.Client
+	limiter    *bandwidthLimiter
+	slots      chan struct{}
+	retries    int
+	retryDelay time.Duration
+}
+
+func newEpochDownloader(parallel int, bytesPerSecond int64, retries int) *epochDownloader {
+	return &epochDownloader{
+		// no timeout: the CAR files are hundreds of GB.
+		client:     &http.Client{Transport: splitcarfetcher.NewHTTPTransport()},
+		limiter:    newBandwidthLimiter(bytesPerSecond),
+		slots:      make(chan struct{}, parallel),
+		retries:    retries,
+		retryDelay: time.Second,
+	}
+}
+
+type fetchEpochOptions struct {
+	// dest is the directory of the epochs.
+	dest string
+	// trusted are the signers of the attestations; if empty, the signatures are not checked.
+	trusted []solana.PublicKey
+	// genesisPath is the (absolute) path of the genesis file, for epoch 0.
+	genesisPath string
+	// source is where the files are downloaded from: http (the default), torrent or ipfs.
+	source string
+	// torrentURL and ipfsCidURL are the URLs of the torrent and of the IPFS CID of an epoch;
+	// by default, they are next to the attestation.
+	torrentURL  uri.Template
+	ipfsGateway string
+	ipfsCidURL  uri.Template
+}
+
+// fetchEpoch downloads the files of the epoch listed in the attestation at manifestURL, and
+// returns the path of the epoch config file it wrote.
+func (d *epochDownloader) fetchEpoch(ctx context.Context, epoch uint64, manifestURL string, opts *fetchEpochOptions) (string, error) {
+	att, err := d.fetchAttestation(ctx, manifestURL)
+	if err != nil {
+		return "", err
+	}
+	if len(opts.trusted) > 0 {
+		if err := att.Verify(opts.trusted...); err != nil {
+			return "", err
+		}
+	}
+	manifest := &att.Manifest
+	if manifest.Epoch != epoch {
+		return "", fmt.Errorf("the attestation at %s is for epoch %d", manifestURL, manifest.Epoch)
+	}
+	if err := checkManifestNames(manifest); err != nil {
+		return "", err
+	}
+	src, err := d.newFileSource(ctx, epoch, manifestURL, opts)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.Abs(filepath.Join(opts.dest, strconv.FormatUint(epoch, 10)))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	wg, ctx := errgroup.WithContext(ctx)
+	var carPath string
+	switch {
+	case manifest.Car != nil:
+		carPath = filepath.Join(dir, manifest.Car.Name)
+		wg.Go(func() error {
+			return d.download(ctx, src, manifest.Car.Name, carPath, manifest.Car.Check)
+		})
+	case len(manifest.Pieces) > 0:
+		carPath = filepath.Join(dir, fmt.Sprintf("epoch-%d.car", epoch))
+		wg.Go(func() error {
+			return d.fetchPieces(ctx, src, manifest, dir, carPath)
+		})
+	default:
+		return "", errors.New("the attestation has neither a CAR file nor pieces")
+	}
+	indexPaths := make([]configIndex, 0, len(manifest.Indexes))
+	for _, index := range manifest.Indexes {
+		index := index
+		path := filepath.Join(dir, index.Name)
+		indexPaths = append(indexPaths, configIndex{kind: index.Kind, uri: URI(path)})
+		if directoryIndexKinds[index.Kind] {
+			wg.Go(func() error {
+				return d.fetchDirectory(ctx, src, path, &index.FileDigest)
+			})
+			continue
+		}
+		wg.Go(func() error {
+			return d.download(ctx, src, index.Name, path, index.Check)
+		})
+	}
+	if err := wg.Wait(); err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(carPath)
+	if err != nil {
+		return "", err
+	}
+	rootCid, err := rootCidFromCarHeader(file)
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+	if rootCid != manifest.RootCid {
+		return "", fmt.Errorf("root CID mismatch: expected %s, got %s", manifest.RootCid, rootCid)
+	}
+	var carSha256 string
+	if manifest.Car != nil {
+		carSha256 = manifest.Car.Sha256
+	}
+	var genesisPath string
+	if epoch == 0 {
+		genesisPath = opts.genesisPath
+	}
+	configPath := filepath.Join(opts.dest, fmt.Sprintf("epoch-%d.yaml", epoch))
+	if err := os.WriteFile(configPath, []byte(epochConfigYaml(epoch, carPath, carSha256, indexPaths, genesisPath)), 0o644); err != nil {
+		return "", err
+	}
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		return "", err
+	}
+	if err := config.Validate(); err != nil {
+		return "", fmt.Errorf("the epoch config file %s is not valid: %w", configPath, err)
+	}
+	return configPath, nil
+}
+
+// checkManifestNames checks that the names of the files of the manifest are plain file
+// names, so that they can't be written outside of the directory of the epoch, and that the
+// index kinds are config keys.
+func checkManifestNames(manifest *attestation.Manifest) error {
+	names := make([]string, 0, len(manifest.Indexes)+1)
+	if manifest.Car != nil {
+		names = append(names, manifest.Car.Name)
+	}
+	for _, index := range manifest.Indexes {
+		if index.Kind == "" || strings.Trim(index.Kind, "abcdefghijklmnopqrstuvwxyz_") != "" {
+			return fmt.Errorf("invalid index kind %q", index.Kind)
+		}
+		names = append(names, index.Name)
+	}
+	for _, name := range names {
+		if err := checkFileName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkFileName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	return nil
+}
+
+// fileURL returns the URL of a file published next to the attestation.
+func fileURL(manifestURL string, name string) string {
+	u, err := url.Parse(manifestURL)
+	if err != nil {
+		return manifestURL
+	}
+	u.Path = path.Join(path.Dir(u.Path), name)
+	u.RawPath = ""
+	u.RawQuery = ""
+	return u.String()
+}
+
+func (d *epochDownloader) fetchAttestation(ctx context.Context, manifestURL string) (*attestation.Attestation, error) {
+	data, err := d.get(ctx, manifestURL, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the attestation: %w", err)
+	}
+	var att attestation.Attestation
+	if err := json.Unmarshal(data, &att); err != nil {
+		return nil, fmt.Errorf("failed to parse the attestation at %s: %w", manifestURL, err)
+	}
+	return &att, nil
+}
+
+// get returns the content of a small file (at most maxSize bytes).
+func (d *epochDownloader) get(ctx context.Context, src string, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{url: src, code: resp.StatusCode}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", src, maxSize)
+	}
+	return data, nil
+}
+
+// fetchPieces downloads the pieces of the CAR file of the epoch (see split-car), checks their
+// piece CIDs, and reassembles the CAR file at carPath.
+func (d *epochDownloader) fetchPieces(ctx context.Context, src epochFileSource, manifest *attestation.Manifest, dir string, carPath string) error {
+	// the pieces and their metadata are removed once the CAR file is assembled (the metadata
+	// file would be taken for an epoch config file by the rpc command).
+	piecesDir := filepath.Join(dir, "pieces")
+	if err := os.MkdirAll(piecesDir, 0o755); err != nil {
+		return err
+	}
+	metadataName := fmt.Sprintf("epoch-%d-metadata.yaml", manifest.Epoch)
+	metadataPath := filepath.Join(piecesDir, metadataName)
+	if err := d.download(ctx, src, metadataName, metadataPath, nil); err != nil {
+		return err
+	}
+	metadata, err := splitcarfetcher.MetadataFromYaml(metadataPath)
+	if err != nil {
+		return err
+	}
+	if metadata.CarPieces == nil {
+		return fmt.Errorf("%s has no pieces", metadataName)
+	}
+	pieces := metadata.CarPieces.CarPieces
+	if len(pieces) != len(manifest.Pieces) {
+		return fmt.Errorf("%s has %d pieces, the attestation %d", metadataName, len(pieces), len(manifest.Pieces))
+	}
+	header, err := base64.StdEncoding.DecodeString(metadata.CarPieces.OriginalCarHeader)
+	if err != nil {
+		return fmt.Errorf("failed to decode original CAR header: %w", err)
+	}
+	// the original header is stored without its size prefix.
+	header = append(binary.AppendUvarint(nil, uint64(len(header))), header...)
+	carSize := int64(len(header))
+	for i, piece := range pieces {
+		if err := checkFileName(piece.Name); err != nil {
+			return fmt.Errorf("piece %d of %s: %w", i, metadataName, err)
+		}
+		if piece.CommP.String() != manifest.Pieces[i] {
+			return fmt.Errorf("piece %d of %s is %s, the attestation has %s", i, metadataName, piece.CommP, manifest.Pieces[i])
+		}
+		carSize += int64(piece.ContentSize)
+	}
+	if info, err := os.Stat(carPath); err == nil && info.Size() == carSize {
+		klog.Infof("%s is already assembled", carPath)
+		return os.RemoveAll(piecesDir)
+	}
+
+	wg, ctx := errgroup.WithContext(ctx)
+	for _, piece := range pieces {
+		piece := piece
+		wg.Go(func() error {
+			return d.download(ctx, src, piece.Name, filepath.Join(piecesDir, piece.Name), func(path string) error {
+				got, _, err := calcCommP(path)
+				if err != nil {
+					return err
+				}
+				if !got.Equals(piece.CommP) {
+					return fmt.Errorf("%s: piece CID mismatch: expected %s, got %s", path, piece.CommP, got)
+				}
+				return nil
+			})
+		})
+	}
+	if err := wg.Wait(); err != nil {
+		return err
+	}
+
+	klog.Infof("Assembling %s from %d pieces ...", carPath, len(pieces))
+	part := carPath + ".part"
+	out, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+	for _, piece := range pieces {
+		file, err := os.Open(filepath.Join(piecesDir, piece.Name))
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(out, io.NewSectionReader(file, int64(piece.HeaderSize), int64(piece.ContentSize)))
+		file.Close()
+		if err != nil {
+			return err
+		}
+		if n != int64(piece.ContentSize) {
+			return fmt.Errorf("piece %s is truncated: expected %d bytes of content, got %d", piece.Name, piece.ContentSize, n)
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(part, carPath); err != nil {
+		return err
+	}
+	return os.RemoveAll(piecesDir)
+}
+
+// fetchDirectory downloads and extracts the archive of a directory index (<name>.tar.bz2) to path.
+func (d *epochDownloader) fetchDirectory(ctx context.Context, src epochFileSource, path string, digest *attestation.FileDigest) error {
+	if _, err := os.Stat(path); err == nil && digest.Check(path) == nil {
+		klog.Infof("%s is already downloaded", path)
+		return nil
+	}
+	archive := path + ".tar.bz2"
+	if err := d.download(ctx, src, filepath.Base(archive), archive, nil); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	klog.Infof("Extracting %s ...", archive)
+	err = extractTar(bzip2.NewReader(file), filepath.Dir(path), filepath.Base(path))
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", archive, err)
+	}
+	if err := digest.Check(path); err != nil {
+		return err
+	}
+	return os.Remove(archive)
+}
+
+// extractTar extracts the regular files of a tar archive of the directory name into dir.
+// The entries that are not in a name/ directory are put in it.
+func extractTar(r io.Reader, dir string, name string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		rel := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+			return fmt.Errorf("invalid path in archive: %q", hdr.Name)
+		}
+		if !strings.HasPrefix(rel, name+"/") {
+			rel = path.Join(name, rel)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		file, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(file, tr)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// download downloads the file name from src to path, unless path already exists and passes
+// verify (if not nil). An interrupted download is kept in path.part, and resumed from there.
+func (d *epochDownloader) download(ctx context.Context, src epochFileSource, name string, path string, verify func(path string) error) error {
+	if verify != nil {
+		if _, err := os.Stat(path); err == nil {
+			if err := verify(path); err == nil {
+				klog.Infof("%s is already downloaded", path)
+				return nil
+			}
+			klog.Warningf("%s does not match the manifest, downloading it again", path)
+		}
+	}
+	select {
+	case d.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-d.slots }()
+
+	part := path + ".part"
+	started := time.Now()
+	klog.Infof("Downloading %s from %s ...", name, src)
+	delay := d.retryDelay
+	for attempt := 0; ; attempt++ {
+		err := src.fetch(ctx, name, part)
+		if err == nil {
+			break
+		}
+		var statusErr *httpStatusError
+		if attempt >= d.retries || ctx.Err() != nil || (errors.As(err, &statusErr) && !statusErr.temporary()) {
+			return err
+		}
+		klog.Warningf("Download of %s failed (retrying in %s): %s", name, delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+			delay *= 2
+		}
+	}
+	if err := os.Rename(part, path); err != nil {
+		return err
+	}
+	if verify != nil {
+		if err := verify(path); err != nil {
+			os.Remove(path)
+			return err
+		}
+	}
+	if info, err := os.Stat(path); err == nil {
+		elapsed := time.Since(started)
+		klog.Infof("Downloaded %s (%s in %s, %s/s)", path, humanize.Bytes(uint64(info.Size())), elapsed.Round(time.Second), humanize.Bytes(uint64(float64(info.Size())/max(elapsed.Seconds(), 1e-3))))
+	}
+	return nil
+}
+
+// downloadOnce downloads src to part, starting after what part already has.
+func (d *epochDownloader) downloadOnce(ctx context.Context, src string, part string) error {
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// the server sends the whole file.
+		offset = 0
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		if offset > 0 {
+			// part already has the whole file.
+			return nil
+		}
+		fallthrough
+	default:
+		return &httpStatusError{url: src, code: resp.StatusCode}
+	}
+	file, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := file.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, &limitedReader{ctx: ctx, r: resp.Body, limiter: d.limiter}); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+type httpStatusError struct {
+	url  string
+	code int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.url, http.StatusText(e.code))
+}
+
+// temporary returns true if the request can be retried.
+func (e *httpStatusError) temporary() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+// bandwidthLimiter spreads the reads of all the downloads so that they don't exceed
+// bytesPerSecond in total.
+type bandwidthLimiter struct {
+	mu             sync.Mutex
+	bytesPerSecond float64
+	next           time.Time
+}
+
+// newBandwidthLimiter returns nil (no limit) if bytesPerSecond is not positive.
+func newBandwidthLimiter(bytesPerSecond int64) *bandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &bandwidthLimiter{bytesPerSecond: float64(bytesPerSecond)}
+}
+
+// wait accounts for n bytes read, and waits until the reads are back under the limit.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.bytesPerSecond * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.limiter != nil && len(p) > 64<<10 {
+		p = p[:64<<10]
+	}
+	n, err := r.r.Read(p)
+	if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
+}
+
+// epochConfigYaml returns an epoch config file for the downloaded files.
+func epochConfigYaml(epoch uint64, carPath string, carSha256 string, indexes []configIndex, genesisPath string) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "epoch: %d\n", epoch)
+	fmt.Fprintf(&builder, "version: %d\n", ConfigVersion)
+	builder.WriteString("data:\n  car:\n    uri: ")
+	builder.WriteString(quoteSingle(carPath))
+	builder.WriteString("\n")
+	if carSha256 != "" {
+		builder.WriteString("    sha256: ")
+		builder.WriteString(quoteSingle(carSha256))
+		builder.WriteString("\n")
+	}
+	builder.WriteString("indexes:\n")
+	for _, index := range indexes {
+		builder.WriteString("  " + index.kind + ":\n    uri: ")
+		builder.WriteString(quoteSingle(index.uri.String()))
+		builder.WriteString("\n")
+	}
+	if genesisPath != "" {
+		builder.WriteString("genesis:\n  uri: ")
+		builder.WriteString(quoteSingle(genesisPath))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
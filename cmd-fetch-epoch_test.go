@@ -0,0 +1,281 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anjor/carlet"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/attestation"
+	splitcarfetcher "github.com/rpcpool/yellowstone-faithful/split-car-fetcher"
+	"github.com/rpcpool/yellowstone-faithful/torrent"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// newFetchEpochServer serves the files of dir, and counts the requests.
+func newFetchEpochServer(t *testing.T, dir string) (*httptest.Server, *atomic.Int64) {
+	var requests atomic.Int64
+	files := http.FileServer(http.Dir(dir))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func writeRandomFile(t *testing.T, path string, size int) {
+	data := make([]byte, size)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+}
+
+// publishEpoch signs the manifest and writes the attestation of epoch 0 in dir.
+func publishEpoch(t *testing.T, dir string, manifest *attestation.Manifest) solana.PrivateKey {
+	key, err := solana.NewRandomPrivateKey()
+	require.NoError(t, err)
+	att, err := attestation.Sign(manifest, key)
+	require.NoError(t, err)
+	require.NoError(t, att.WriteFile(filepath.Join(dir, "epoch-0.attestation.json")))
+	return key
+}
+
+// publishIndexes writes random index files in dir, and adds them to the manifest.
+func publishIndexes(t *testing.T, dir string, manifest *attestation.Manifest) {
+	for _, kind := range []string{"cid_to_offset_and_size", "slot_to_cid", "sig_to_cid", "sig_exists", "slot_to_blocktime"} {
+		path := filepath.Join(dir, "epoch-0-"+kind+".index")
+		writeRandomFile(t, path, 1000)
+		digest, err := attestation.DigestPath(path)
+		require.NoError(t, err)
+		manifest.Indexes = append(manifest.Indexes, attestation.IndexDigest{Kind: kind, FileDigest: *digest})
+	}
+}
+
+func fixtureRootCid(t *testing.T, carPath string) string {
+	file, err := os.Open(carPath)
+	require.NoError(t, err)
+	defer file.Close()
+	rootCid, err := rootCidFromCarHeader(file)
+	require.NoError(t, err)
+	return rootCid
+}
+
+func TestFetchEpoch(t *testing.T) {
+	published := t.TempDir()
+	carData, err := os.ReadFile("fixtures/epoch-0-1.car")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(published, "epoch-0.car"), carData, 0o644))
+	manifest := attestation.NewManifest(0, fixtureRootCid(t, "fixtures/epoch-0-1.car"))
+	manifest.Car, err = attestation.DigestPath(filepath.Join(published, "epoch-0.car"))
+	require.NoError(t, err)
+	publishIndexes(t, published, manifest)
+	key := publishEpoch(t, published, manifest)
+	server, requests := newFetchEpochServer(t, published)
+	manifestURL := server.URL + "/epoch-0.attestation.json"
+
+	dest := t.TempDir()
+	genesisPath := filepath.Join(dest, "genesis.tar.bz2")
+	d := newEpochDownloader(2, 0, 0)
+	configPath, err := d.fetchEpoch(context.Background(), 0, manifestURL, &fetchEpochOptions{dest: dest, trusted: []solana.PublicKey{key.PublicKey()}, genesisPath: genesisPath})
+	require.NoError(t, err)
+	config, err := LoadConfig(configPath)
+	require.NoError(t, err)
+	require.NoError(t, config.Validate())
+	require.NoError(t, verifyEpochAgainstManifest(config, manifest))
+	require.Equal(t, manifest.Car.Sha256, config.Data.Car.Sha256)
+	require.Equal(t, filepath.Join(dest, "0", "epoch-0-slot_to_cid.index"), config.Indexes.SlotToCid.URI.String())
+
+	// the files that are there are not downloaded again.
+	requests.Store(0)
+	_, err = d.fetchEpoch(context.Background(), 0, manifestURL, &fetchEpochOptions{dest: dest, genesisPath: genesisPath})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), requests.Load())
+
+	// a corrupted file is.
+	require.NoError(t, os.WriteFile(config.Indexes.SlotToCid.URI.String(), []byte("corrupted"), 0o644))
+	_, err = d.fetchEpoch(context.Background(), 0, manifestURL, &fetchEpochOptions{dest: dest, genesisPath: genesisPath})
+	require.NoError(t, err)
+	require.NoError(t, verifyEpochAgainstManifest(config, manifest))
+
+	other, err := solana.NewRandomPrivateKey()
+	require.NoError(t, err)
+	_, err = d.fetchEpoch(context.Background(), 0, manifestURL, &fetchEpochOptions{dest: dest, trusted: []solana.PublicKey{other.PublicKey()}})
+	require.ErrorIs(t, err, attestation.ErrUntrustedSigner)
+	_, err = d.fetchEpoch(context.Background(), 1, manifestURL, &fetchEpochOptions{dest: dest, genesisPath: genesisPath})
+	require.ErrorContains(t, err, "is for epoch 0")
+}
+
+func TestFetchEpoch_Pieces(t *testing.T) {
+	published := t.TempDir()
+	carData, err := os.ReadFile("fixtures/epoch-0-1.car")
+	require.NoError(t, err)
+	size, n := binary.Uvarint(carData)
+	headerSize := n + int(size)
+	headerBytes := carData[n:headerSize]
+
+	// two pieces, each with a header of its own.
+	content := carData[headerSize:]
+	meta := &carlet.CarPiecesAndMetadata{
+		OriginalCarHeader:     base64.StdEncoding.EncodeToString(headerBytes),
+		OriginalCarHeaderSize: uint64(headerSize),
+	}
+	manifest := attestation.NewManifest(0, fixtureRootCid(t, "fixtures/epoch-0-1.car"))
+	publishIndexes(t, published, manifest)
+	for i, part := range [][]byte{content[:len(content)/2], content[len(content)/2:]} {
+		name := []string{"epoch-0-1.car", "epoch-0-2.car"}[i]
+		pieceHeader := bytes.Repeat([]byte{byte(i + 1)}, 40)
+		require.NoError(t, os.WriteFile(filepath.Join(published, name), append(pieceHeader, part...), 0o644))
+		commP, _, err := calcCommP(filepath.Join(published, name))
+		require.NoError(t, err)
+		meta.CarPieces = append(meta.CarPieces, carlet.CarFile{Name: name, CommP: commP, HeaderSize: uint64(len(pieceHeader)), ContentSize: uint64(len(part))})
+		manifest.Pieces = append(manifest.Pieces, commP.String())
+	}
+	encoded, err := yaml.Marshal(&splitcarfetcher.Metadata{CarPieces: meta})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(published, "epoch-0-metadata.yaml"), encoded, 0o644))
+	publishEpoch(t, published, manifest)
+	server, _ := newFetchEpochServer(t, published)
+
+	dest := t.TempDir()
+	genesisPath := filepath.Join(dest, "genesis.tar.bz2")
+	configPath, err := newEpochDownloader(2, 0, 0).fetchEpoch(context.Background(), 0, server.URL+"/epoch-0.attestation.json", &fetchEpochOptions{dest: dest, genesisPath: genesisPath})
+	require.NoError(t, err)
+	config, err := LoadConfig(configPath)
+	require.NoError(t, err)
+	assembled, err := os.ReadFile(config.Data.Car.URI.String())
+	require.NoError(t, err)
+	require.Equal(t, carData, assembled)
+	require.NoDirExists(t, filepath.Join(dest, "0", "pieces"))
+
+	// a piece that doesn't match its piece CID.
+	require.NoError(t, os.WriteFile(filepath.Join(published, "epoch-0-2.car"), bytes.Repeat([]byte{7}, 200), 0o644))
+	_, err = newEpochDownloader(2, 0, 0).fetchEpoch(context.Background(), 0, server.URL+"/epoch-0.attestation.json", &fetchEpochOptions{dest: t.TempDir(), genesisPath: genesisPath})
+	require.ErrorContains(t, err, "piece CID mismatch")
+}
+
+func TestFetchEpoch_Torrent(t *testing.T) {
+	// the files of the epoch are in the epoch-0 directory of the torrent, served by a web seed.
+	root := t.TempDir()
+	published := filepath.Join(root, "epoch-0")
+	require.NoError(t, os.Mkdir(published, 0o755))
+	carData, err := os.ReadFile("fixtures/epoch-0-1.car")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(published, "epoch-0.car"), carData, 0o644))
+	manifest := attestation.NewManifest(0, fixtureRootCid(t, "fixtures/epoch-0-1.car"))
+	manifest.Car, err = attestation.DigestPath(filepath.Join(published, "epoch-0.car"))
+	require.NoError(t, err)
+	publishIndexes(t, published, manifest)
+	server, _ := newFetchEpochServer(t, root)
+	data, err := torrent.Create(published, "epoch-0", 16<<10, nil, []string{server.URL + "/"})
+	require.NoError(t, err)
+	// the attestation and the torrent are published elsewhere.
+	other := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(other, "epoch-0.torrent"), data, 0o644))
+	publishEpoch(t, other, manifest)
+	otherServer, _ := newFetchEpochServer(t, other)
+
+	dest := t.TempDir()
+	genesisPath := filepath.Join(dest, "genesis.tar.bz2")
+	configPath, err := newEpochDownloader(2, 0, 0).fetchEpoch(context.Background(), 0, otherServer.URL+"/epoch-0.attestation.json", &fetchEpochOptions{dest: dest, genesisPath: genesisPath, source: "torrent"})
+	require.NoError(t, err)
+	config, err := LoadConfig(configPath)
+	require.NoError(t, err)
+	require.NoError(t, verifyEpochAgainstManifest(config, manifest))
+
+	// a corrupted file is repaired.
+	corrupted := append([]byte{}, carData...)
+	corrupted[len(corrupted)/2]++
+	require.NoError(t, os.WriteFile(config.Data.Car.URI.String(), corrupted, 0o644))
+	_, err = newEpochDownloader(2, 0, 0).fetchEpoch(context.Background(), 0, otherServer.URL+"/epoch-0.attestation.json", &fetchEpochOptions{dest: dest, genesisPath: genesisPath, source: "torrent"})
+	require.NoError(t, err)
+	require.NoError(t, verifyEpochAgainstManifest(config, manifest))
+}
+
+func TestEpochDownloader_Resume(t *testing.T) {
+	published := t.TempDir()
+	writeRandomFile(t, filepath.Join(published, "file"), 10000)
+	data, err := os.ReadFile(filepath.Join(published, "file"))
+	require.NoError(t, err)
+	var ranges []string
+	var failures atomic.Int64
+	failures.Store(2)
+	files := http.FileServer(http.Dir(published))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	dest := t.TempDir()
+	path := filepath.Join(dest, "file")
+	require.NoError(t, os.WriteFile(path+".part", data[:4000], 0o644))
+	d := newEpochDownloader(1, 0, 2)
+	d.retryDelay = time.Millisecond
+	src := &httpSource{d: d, manifestURL: server.URL + "/epoch-0.attestation.json"}
+	require.NoError(t, d.download(context.Background(), src, "file", path, nil))
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+	require.Equal(t, []string{"bytes=4000-", "bytes=4000-", "bytes=4000-"}, ranges)
+	require.NoFileExists(t, path+".part")
+
+	// the missing files are not retried.
+	ranges = nil
+	err = d.download(context.Background(), src, "missing", filepath.Join(dest, "missing"), nil)
+	require.ErrorContains(t, err, "Not Found")
+	require.Len(t, ranges, 1)
+}
+
+func TestExtractTar(t *testing.T) {
+	archive := func(names ...string) *bytes.Buffer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, name := range names {
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(name)), Typeflag: tar.TypeReg}))
+			_, err := tw.Write([]byte(name))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		return &buf
+	}
+	dir := t.TempDir()
+	require.NoError(t, extractTar(archive("gsfa.index/a", "./gsfa.index/sub/b", "c"), dir, "gsfa.index"))
+	for name, content := range map[string]string{"a": "gsfa.index/a", "sub/b": "./gsfa.index/sub/b", "c": "c"} {
+		got, err := os.ReadFile(filepath.Join(dir, "gsfa.index", name))
+		require.NoError(t, err)
+		require.Equal(t, content, string(got))
+	}
+	require.ErrorContains(t, extractTar(archive("../evil"), dir, "gsfa.index"), "invalid path")
+
+	require.Error(t, checkFileName("../epoch-0.car"))
+	require.Error(t, checkFileName(".."))
+	require.NoError(t, checkFileName("epoch-0.car"))
+}
+
+func TestBandwidthLimiter(t *testing.T) {
+	require.Nil(t, newBandwidthLimiter(0))
+	limiter := newBandwidthLimiter(100_000)
+	started := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, limiter.wait(context.Background(), 10_000))
+	}
+	// 30 KB at 100 KB/s.
+	require.GreaterOrEqual(t, time.Since(started), 250*time.Millisecond)
+}
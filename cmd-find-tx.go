@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/gagliardetto/solana-go"
+	jsoniter "github.com/json-iterator/go"
+	hugecache "github.com/rpcpool/yellowstone-faithful/huge-cache"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	splitcarfetcher "github.com/rpcpool/yellowstone-faithful/split-car-fetcher"
+	"github.com/urfave/cli/v2"
+	"github.com/ybbus/jsonrpc/v3"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_FindTx() *cli.Command {
+	var (
+		epochsDir            string
+		encoding             string
+		epochLoadConcurrency int
+		lotusAPIAddress      string
+	)
+	return &cli.Command{
+		Name:      "find-tx",
+		Usage:     "Find a transaction by signature in the epochs of a directory of configs, without an RPC server.",
+		ArgsUsage: "<signature>",
+		Description: "Loads the epochs of the config files in --epochs-dir (like the rpc command), finds the epoch of the signature " +
+			"with their sig-exists and sig-to-cid indexes, and prints the transaction as getTransaction would return it.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "epochs-dir",
+				Usage:       "Directory of the epoch config files (searched recursively)",
+				Required:    true,
+				Destination: &epochsDir,
+			},
+			&cli.StringFlag{
+				Name:        "encoding",
+				Usage:       "Encoding of the transaction: json, jsonParsed, base58, base64 or base64+zstd",
+				Value:       string(solana.EncodingJSON),
+				Destination: &encoding,
+			},
+			&cli.IntFlag{
+				Name:        "epoch-load-concurrency",
+				Usage:       "How many epochs to load in parallel",
+				Value:       runtime.NumCPU(),
+				Destination: &epochLoadConcurrency,
+			},
+			&cli.StringFlag{
+				Name:        "filecoin-api-address",
+				Usage:       "Address of the filecoin API to find provider info",
+				Value:       defaultLotusAPIAddress,
+				Destination: &lotusAPIAddress,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() != 1 {
+				return cli.Exit("expected one signature", 1)
+			}
+			sig, err := solana.SignatureFromBase58(c.Args().First())
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("invalid signature: %s", err), 1)
+			}
+			params := &GetTransactionRequest{Signature: sig}
+			encodingType := solana.EncodingType(encoding)
+			params.Options.Encoding = &encodingType
+			if err := params.Validate(); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+
+			configFiles, err := GetListOfConfigFiles([]string{epochsDir}, nil, nil)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			minerInfo := splitcarfetcher.NewMinerInfo(jsonrpc.NewClient(lotusAPIAddress), 24*time.Hour, 5*time.Second)
+			multi, err := loadEpochsForLookup(c, configFiles, epochLoadConcurrency, minerInfo)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			defer multi.Close()
+
+			result, err := findTransaction(c.Context, multi, params)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			out, err := jsoniter.ConfigCompatibleWithStandardLibrary.MarshalIndent(result, "", "  ")
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			fmt.Fprintln(os.Stdout, string(out))
+			return nil
+		},
+	}
+}
+
+// loadEpochsForLookup loads the epochs of the config files; the epochs that fail to load are
+// logged and skipped.
+func loadEpochsForLookup(c *cli.Context, configFiles []string, concurrency int, minerInfo *splitcarfetcher.MinerInfoCache) (*MultiEpoch, error) {
+	configs := make(ConfigSlice, 0, len(configFiles))
+	for _, configFile := range configFiles {
+		config, err := LoadConfig(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file %q: %w", configFile, err)
+		}
+		configs = append(configs, config)
+	}
+	if len(configs) == 0 {
+		return nil, errors.New("no epoch config files found")
+	}
+	if err := configs.Validate(); err != nil {
+		return nil, fmt.Errorf("error validating configs: %w", err)
+	}
+	if networks := configs.Networks(); len(networks) > 1 {
+		return nil, fmt.Errorf("the configs are of several networks %q; look up one network at a time", networks)
+	}
+	configs.SortByEpoch()
+
+	conf := bigcache.DefaultConfig(5 * time.Minute)
+	conf.MaxEntriesInWindow = 1000 // a few lookups; the default preallocates hundreds of MB
+	allCache, err := hugecache.NewWithConfig(c.Context, conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache: %w", err)
+	}
+
+	multi := NewMultiEpoch(&Options{EpochSearchConcurrency: concurrency})
+	startedAt := time.Now()
+	wg := new(errgroup.Group)
+	wg.SetLimit(max(concurrency, 1))
+	for _, config := range configs {
+		config := config
+		wg.Go(func() error {
+			epoch, err := NewEpochFromConfig(config, c, allCache, minerInfo)
+			if err != nil {
+				klog.Errorf("failed to create epoch from config %q: %s", config.ConfigFilepath(), err)
+				return nil
+			}
+			if err := multi.AddEpoch(epoch.Epoch(), epoch); err != nil {
+				epoch.Close()
+				klog.Errorf("failed to add epoch %d: %s", epoch.Epoch(), err)
+			}
+			return nil
+		})
+	}
+	wg.Wait()
+	if multi.CountEpochs() == 0 {
+		multi.Close()
+		return nil, errors.New("no epoch could be loaded")
+	}
+	klog.Infof("Loaded %d/%d epochs in %s", multi.CountEpochs(), len(configs), time.Since(startedAt).Truncate(time.Millisecond))
+	return multi, nil
+}
+
+// findTransaction returns the getTransaction result of the signature.
+func findTransaction(ctx context.Context, multi *MultiEpoch, params *GetTransactionRequest) (any, error) {
+	response, transactionCid, _, err := multi.getTransaction(ctx, params)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("transaction %s not found in the %d epochs", params.Signature, multi.CountEpochs())
+		}
+		return nil, err
+	}
+	klog.Infof("Found %s in epoch %d, slot %d (CID %s)", params.Signature, slottools.CalcEpochForSlot(*response.Slot), *response.Slot, transactionCid)
+	return toCamelCaseResult(response, adaptTransactionMetaToExpectedOutput)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestFindTransaction(t *testing.T) {
+	config, _, slots, sigs := buildTestEpoch(t)
+	c := cli.NewContext(cli.NewApp(), nil, nil)
+	c.Context = context.Background()
+
+	configFiles, err := GetListOfConfigFiles([]string{filepath.Dir(config.ConfigFilepath())}, nil, nil)
+	require.NoError(t, err)
+	multi, err := loadEpochsForLookup(c, configFiles, 2, nil)
+	require.NoError(t, err)
+	defer multi.Close()
+	require.Equal(t, []uint64{0}, multi.GetEpochNumbers())
+
+	encoding := solana.EncodingJSON
+	params := &GetTransactionRequest{Signature: sigs[3]}
+	params.Options.Encoding = &encoding
+	result, err := findTransaction(context.Background(), multi, params)
+	require.NoError(t, err)
+	tx := result.(map[string]any)
+	require.Contains(t, slots, uint64(tx["slot"].(float64)))
+	require.Equal(t, sigs[3].String(), tx["transaction"].(map[string]any)["signatures"].([]any)[0])
+
+	params.Signature = solana.Signature{1}
+	_, err = findTransaction(context.Background(), multi, params)
+	require.ErrorContains(t, err, "not found")
+
+	_, err = loadEpochsForLookup(c, nil, 2, nil)
+	require.ErrorContains(t, err, "no epoch config files")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/libp2p/go-reuseport"
+	"github.com/urfave/cli/v2"
+	"github.com/valyala/fasthttp"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_IndexServer() *cli.Command {
+	var listenOn string
+	var includePatterns cli.StringSlice
+	var excludePatterns cli.StringSlice
+	return &cli.Command{
+		Name:  "index-server",
+		Usage: "Start a server that only serves the index lookups of epochs.",
+		Description: "Opens only the lookup indexes (cid-to-offset-and-size, slot-to-cid, sig-to-cid, sig-exists) of the given epoch configs, and serves them over HTTP under " + indexServerPathPrefix + ". " +
+			"RPC frontends whose epoch configs set indexes.server.uri to this server do their lookups here instead of opening the index files, " +
+			"so that many stateless frontends can share a few index servers with big page caches.",
+		ArgsUsage: "<one or more config files or directories containing config files (nested is fine)>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "listen",
+				Usage:       "Listen address",
+				Value:       ":8890",
+				Destination: &listenOn,
+			},
+			&cli.StringSliceFlag{
+				Name:        "include",
+				Usage:       "Include files or dirs matching the given glob patterns",
+				Value:       cli.NewStringSlice(),
+				Destination: &includePatterns,
+			},
+			&cli.StringSliceFlag{
+				Name:        "exclude",
+				Usage:       "Exclude files or dirs matching the given glob patterns",
+				Value:       cli.NewStringSlice(".git"),
+				Destination: &excludePatterns,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			configFiles, err := GetListOfConfigFiles(c.Args().Slice(), includePatterns.Value(), excludePatterns.Value())
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			configs := make(ConfigSlice, 0, len(configFiles))
+			for _, configFile := range configFiles {
+				config, err := LoadConfig(configFile)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("failed to load config file %q: %s", configFile, err.Error()), 1)
+				}
+				configs = append(configs, config)
+			}
+			if err := configs.Validate(); err != nil {
+				return cli.Exit(fmt.Sprintf("error validating configs: %s", err.Error()), 1)
+			}
+			if networks := configs.Networks(); len(networks) > 1 {
+				return cli.Exit(fmt.Sprintf("the configs are of several networks %q; an index server serves one", networks), 1)
+			}
+			configs.SortByEpoch()
+
+			server := &indexServer{epochs: make(map[uint64]*indexServerEpoch)}
+			defer func() {
+				for _, ep := range server.epochs {
+					if err := ep.Close(); err != nil {
+						klog.Errorf("error closing the indexes of epoch %d: %s", ep.epoch, err)
+					}
+				}
+			}()
+			for _, config := range configs {
+				ep, err := openIndexServerEpoch(c.Context, config)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("failed to open the indexes of config %q: %s", config.ConfigFilepath(), err), 1)
+				}
+				server.epochs[ep.epoch] = ep
+				klog.Infof("Serving the indexes of epoch %d (root %s)", ep.epoch, ep.rootCid)
+			}
+			return server.ListenAndServe(c.Context, listenOn)
+		},
+	}
+}
+
+func (s *indexServer) ListenAndServe(ctx context.Context, listenOn string) error {
+	srv := &fasthttp.Server{
+		Handler: func(reqCtx *fasthttp.RequestCtx) {
+			if string(reqCtx.Path()) == "/health" && reqCtx.IsGet() {
+				reqCtx.SetStatusCode(http.StatusOK)
+				return
+			}
+			s.handler(reqCtx)
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		klog.Info("Index server shutting down...")
+		if err := srv.ShutdownWithContext(ctx); err != nil {
+			klog.Errorf("Error while shutting down index server: %s", err)
+		}
+	}()
+	ln, err := reuseport.Listen("tcp4", listenOn)
+	if err != nil {
+		return fmt.Errorf("error in reuseport listener: %w", err)
+	}
+	klog.Infof("Index server listening on %s", listenOn)
+	return srv.Serve(ln)
+}
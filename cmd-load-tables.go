@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rpcpool/yellowstone-faithful/carloader"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/gcpauth"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_LoadTables() *cli.Command {
+	var (
+		carPath        string
+		slotRange      string
+		batchBlocks    int
+		checkpointFile string
+		clickhouse     carloader.ClickHouse
+		bigquery       carloader.BigQuery
+		bigqueryToken  string
+		bigqueryCreds  string
+	)
+	return &cli.Command{
+		Name:  "load-tables",
+		Usage: "Load the blocks of an epoch CAR into ClickHouse or BigQuery tables.",
+		Description: "Loads the blocks, transactions, instructions (outer and inner) and token balance changes of an epoch CAR " +
+			"into the normalized tables blocks, transactions, instructions and token_balance_changes. " +
+			"The tables are created if they don't exist, and the columns added by newer versions are added to existing tables. " +
+			"The rows are inserted in batches of blocks; with --checkpoint, the progress is saved after every batch and " +
+			"an interrupted load resumes after the last batch inserted. The rows of a batch interrupted midway are inserted again: " +
+			"ClickHouse deduplicates them when merging (the tables are ReplacingMergeTrees ordered by their key; query with FINAL for exact counts), " +
+			"and BigQuery by their insertId.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "car",
+				Usage:       "Source epoch CAR file",
+				Required:    true,
+				Destination: &carPath,
+			},
+			&cli.StringFlag{
+				Name:        "slots",
+				Usage:       "Slot range to load, inclusive (e.g. 1000-1999; default: the whole CAR)",
+				Destination: &slotRange,
+			},
+			&cli.IntFlag{
+				Name:        "batch-blocks",
+				Usage:       "Number of blocks whose rows are inserted together",
+				Value:       100,
+				Destination: &batchBlocks,
+			},
+			&cli.StringFlag{
+				Name:        "checkpoint",
+				Usage:       "File to save the progress to, and to resume from",
+				Destination: &checkpointFile,
+			},
+			&cli.StringFlag{
+				Name:        "clickhouse-url",
+				Usage:       "Load into ClickHouse: URL of its HTTP interface (e.g. http://localhost:8123)",
+				Destination: &clickhouse.URL,
+			},
+			&cli.StringFlag{
+				Name:        "clickhouse-database",
+				Usage:       "ClickHouse database",
+				Value:       "faithful",
+				Destination: &clickhouse.Database,
+			},
+			&cli.StringFlag{
+				Name:        "clickhouse-user",
+				Usage:       "ClickHouse user",
+				Destination: &clickhouse.User,
+			},
+			&cli.StringFlag{
+				Name:        "clickhouse-password",
+				Usage:       "ClickHouse password",
+				EnvVars:     []string{"CLICKHOUSE_PASSWORD"},
+				Destination: &clickhouse.Password,
+			},
+			&cli.StringFlag{
+				Name:        "bigquery-project",
+				Usage:       "Load into BigQuery: GCP project of the dataset",
+				Destination: &bigquery.Project,
+			},
+			&cli.StringFlag{
+				Name:        "bigquery-dataset",
+				Usage:       "BigQuery dataset (created if it doesn't exist)",
+				Value:       "faithful",
+				Destination: &bigquery.Dataset,
+			},
+			&cli.StringFlag{
+				Name:        "bigquery-credentials",
+				Usage:       "Service account key file (default: $GOOGLE_APPLICATION_CREDENTIALS, then the GCE metadata server)",
+				Destination: &bigqueryCreds,
+			},
+			&cli.StringFlag{
+				Name:        "bigquery-access-token",
+				Usage:       "OAuth2 access token to use instead of credentials (e.g. from `gcloud auth print-access-token`)",
+				EnvVars:     []string{"BIGQUERY_ACCESS_TOKEN"},
+				Destination: &bigqueryToken,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			var (
+				sink        carloader.Sink
+				destination string
+			)
+			switch {
+			case clickhouse.URL != "" && bigquery.Project != "":
+				return cli.Exit("--clickhouse-url and --bigquery-project are mutually exclusive", 1)
+			case clickhouse.URL != "":
+				sink = &clickhouse
+				destination = fmt.Sprintf("ClickHouse database %s", clickhouse.Database)
+			case bigquery.Project != "":
+				tokens, err := gcpauth.New(bigqueryToken, bigqueryCreds, carloader.BigQueryScope)
+				if err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+				bigquery.Tokens = gcpauth.NewCache(tokens)
+				sink = &bigquery
+				destination = fmt.Sprintf("BigQuery dataset %s.%s", bigquery.Project, bigquery.Dataset)
+			default:
+				return cli.Exit("one of --clickhouse-url or --bigquery-project is required", 1)
+			}
+			from, to := uint64(0), uint64(math.MaxUint64)
+			if slotRange != "" {
+				var err error
+				if from, to, err = parseSlotRange(slotRange); err != nil {
+					return cli.Exit(fmt.Sprintf("invalid --slots: %s", err), 1)
+				}
+			}
+
+			source := carwriter.NewCarSource(carPath)
+			defer source.Close()
+			startedAt := time.Now()
+			stats, err := carloader.Load(c.Context, source, from, to, sink, carloader.Options{
+				BatchBlocks:    batchBlocks,
+				CheckpointFile: checkpointFile,
+			})
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			if stats.ResumedAfter != nil {
+				klog.Infof("Resumed after slot %d", *stats.ResumedAfter)
+			}
+			klog.Infof(
+				"Loaded %d blocks (%d transactions, %d instructions, %d token balance changes) into %s in %s",
+				stats.Blocks,
+				stats.Rows[carloader.TransactionsTable.Name],
+				stats.Rows[carloader.InstructionsTable.Name],
+				stats.Rows[carloader.TokenBalanceChangesTable.Name],
+				destination,
+				time.Since(startedAt).Truncate(time.Millisecond),
+			)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/dustin/go-humanize"
+	hugecache "github.com/rpcpool/yellowstone-faithful/huge-cache"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	splitcarfetcher "github.com/rpcpool/yellowstone-faithful/split-car-fetcher"
+	"github.com/urfave/cli/v2"
+	"github.com/ybbus/jsonrpc/v3"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_Report() *cli.Command {
+	var (
+		epochsDirs      cli.StringSlice
+		format          string
+		output          string
+		withSlots       bool
+		concurrency     int
+		lotusAPIAddress string
+	)
+	return &cli.Command{
+		Name:  "report",
+		Usage: "Report the coverage of the epochs of a directory of configs, as JSON or HTML.",
+		Description: "For each epoch config in --epochs-dir: which indexes are configured, the size of the CAR, whether the CAR, " +
+			"the indexes and the overlays are reachable, and (with --slots) the first and last slots of the epoch; " +
+			"for each network: the epochs that are missing between the first and the last one. Useful for fleet audits " +
+			"and archive status pages.",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:        "epochs-dir",
+				Usage:       "Directory of the epoch config files (searched recursively); can be repeated",
+				Required:    true,
+				Destination: &epochsDirs,
+			},
+			&cli.StringFlag{
+				Name:        "format",
+				Usage:       "Format of the report: json or html",
+				Value:       "json",
+				Destination: &format,
+				Action: func(c *cli.Context, s string) error {
+					if s != "json" && s != "html" {
+						return fmt.Errorf("invalid --format: %q (must be json or html)", s)
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Usage:       "File to write the report to; - for stdout",
+				Value:       "-",
+				Destination: &output,
+			},
+			&cli.BoolFlag{
+				Name:        "slots",
+				Usage:       "Open the epochs to report their first and last slots (reads their indexes and CAR)",
+				Value:       true,
+				Destination: &withSlots,
+			},
+			&cli.IntFlag{
+				Name:        "concurrency",
+				Usage:       "How many epochs to check in parallel",
+				Value:       runtime.NumCPU(),
+				Destination: &concurrency,
+			},
+			&cli.StringFlag{
+				Name:        "filecoin-api-address",
+				Usage:       "Address of the filecoin API to find provider info",
+				Value:       defaultLotusAPIAddress,
+				Destination: &lotusAPIAddress,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			configFiles, err := GetListOfConfigFiles(epochsDirs.Value(), nil, nil)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			var opener epochOpener
+			if withSlots {
+				conf := bigcache.DefaultConfig(5 * time.Minute)
+				conf.MaxEntriesInWindow = 1000 // a few lookups per epoch; the default preallocates hundreds of MB
+				allCache, err := hugecache.NewWithConfig(c.Context, conf)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("failed to create cache: %s", err), 1)
+				}
+				minerInfo := splitcarfetcher.NewMinerInfo(jsonrpc.NewClient(lotusAPIAddress), 24*time.Hour, 5*time.Second)
+				opener = func(config *Config) (*Epoch, error) {
+					return NewEpochFromConfig(config, c, allCache, minerInfo)
+				}
+			}
+			report := buildEpochsReport(c.Context, configFiles, opener, concurrency)
+
+			out := io.Writer(os.Stdout)
+			if output != "-" {
+				file, err := os.Create(output)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("failed to create %q: %s", output, err), 1)
+				}
+				defer file.Close()
+				out = file
+			}
+			if format == "html" {
+				err = report.writeHTML(out)
+			} else {
+				err = report.writeJSON(out)
+			}
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("failed to write the report: %s", err), 1)
+			}
+			klog.Infof("Reported %d epochs of %d config files", report.countEpochs(), len(configFiles))
+			return nil
+		},
+	}
+}
+
+// epochOpener opens the epoch of a config, to read its first and last slots.
+type epochOpener func(config *Config) (*Epoch, error)
+
+// EpochsReport is the coverage report of the epochs of a directory of configs.
+type EpochsReport struct {
+	GeneratedAt time.Time        `json:"generatedAt"`
+	Networks    []*NetworkReport `json:"networks"`
+	// Invalid are the config files that can't be loaded or are not valid.
+	Invalid []InvalidConfigReport `json:"invalid,omitempty"`
+}
+
+type InvalidConfigReport struct {
+	Config string `json:"config"`
+	Error  string `json:"error"`
+}
+
+// NetworkReport is the coverage of the epochs of a network; the epochs without a network are in
+// the default ("") network.
+type NetworkReport struct {
+	Network    string `json:"network"`
+	FirstEpoch uint64 `json:"firstEpoch"`
+	LastEpoch  uint64 `json:"lastEpoch"`
+	// MissingEpochs are the epochs between the first and the last one that have no config.
+	MissingEpochs []EpochRange `json:"missingEpochs"`
+	// Healthy is the number of epochs that have no error.
+	Healthy  int            `json:"healthy"`
+	CarBytes int64          `json:"carBytes"`
+	Epochs   []*EpochReport `json:"epochs"`
+}
+
+// EpochRange is a range of epochs (inclusive).
+type EpochRange struct {
+	First uint64 `json:"first"`
+	Last  uint64 `json:"last"`
+}
+
+func (r EpochRange) String() string {
+	if r.First == r.Last {
+		return fmt.Sprintf("%d", r.First)
+	}
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+type EpochReport struct {
+	Epoch  uint64 `json:"epoch"`
+	Config string `json:"config"`
+	// Mode is where the CAR is read from: car (a local or remote file), pieces (split CAR pieces) or filecoin.
+	Mode string `json:"mode"`
+	// Car is the CAR file, or the metadata of its pieces; nil in filecoin mode.
+	Car      *BackendReport  `json:"car,omitempty"`
+	Indexes  []BackendReport `json:"indexes"`
+	Overlays []BackendReport `json:"overlays,omitempty"`
+	// IndexServer is the index server that answers the lookups of the epoch, if any.
+	IndexServer string `json:"indexServer,omitempty"`
+	// FirstSlot and LastSlot are the slots of the first and last blocks of the epoch, if it was opened.
+	FirstSlot *uint64 `json:"firstSlot,omitempty"`
+	LastSlot  *uint64 `json:"lastSlot,omitempty"`
+	// Errors are what's wrong with the epoch: unreachable backends, failure to open it, etc.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// BackendReport is a file of an epoch (CAR, index, overlay) and whether it's reachable.
+type BackendReport struct {
+	Name      string `json:"name"`
+	URI       string `json:"uri"`
+	Reachable bool   `json:"reachable"`
+	Size      int64  `json:"size,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (r *EpochsReport) countEpochs() int {
+	n := 0
+	for _, network := range r.Networks {
+		n += len(network.Epochs)
+	}
+	return n
+}
+
+// buildEpochsReport checks the epochs of the config files (concurrency at a time); if open is not
+// nil, the epochs are opened to read their first and last slots.
+func buildEpochsReport(ctx context.Context, configFiles []string, open epochOpener, concurrency int) *EpochsReport {
+	report := &EpochsReport{GeneratedAt: time.Now().UTC()}
+	var configs []*Config
+	for _, configFile := range configFiles {
+		config, err := LoadConfig(configFile)
+		if err == nil {
+			err = config.Validate()
+		}
+		if err != nil {
+			report.Invalid = append(report.Invalid, InvalidConfigReport{Config: configFile, Error: err.Error()})
+			continue
+		}
+		configs = append(configs, config)
+	}
+
+	client := splitcarfetcher.NewHTTPClient()
+	epochs := make([]*EpochReport, len(configs))
+	wg := new(errgroup.Group)
+	wg.SetLimit(max(concurrency, 1))
+	for i, config := range configs {
+		i, config := i, config
+		wg.Go(func() error {
+			epochs[i] = checkEpochConfig(ctx, client, config, open)
+			return nil
+		})
+	}
+	wg.Wait()
+
+	byNetwork := make(map[string]*NetworkReport)
+	for i, config := range configs {
+		network, ok := byNetwork[config.Network]
+		if !ok {
+			network = &NetworkReport{Network: config.Network}
+			byNetwork[config.Network] = network
+			report.Networks = append(report.Networks, network)
+		}
+		network.Epochs = append(network.Epochs, epochs[i])
+	}
+	sort.Slice(report.Networks, func(i, j int) bool { return report.Networks[i].Network < report.Networks[j].Network })
+	for _, network := range report.Networks {
+		network.summarize()
+	}
+	return report
+}
+
+// summarize computes the totals and the missing epochs of the network.
+func (n *NetworkReport) summarize() {
+	sort.SliceStable(n.Epochs, func(i, j int) bool { return n.Epochs[i].Epoch < n.Epochs[j].Epoch })
+	n.FirstEpoch = n.Epochs[0].Epoch
+	n.LastEpoch = n.Epochs[len(n.Epochs)-1].Epoch
+	n.MissingEpochs = make([]EpochRange, 0)
+	for i, epoch := range n.Epochs {
+		if len(epoch.Errors) == 0 {
+			n.Healthy++
+		}
+		if epoch.Car != nil {
+			n.CarBytes += epoch.Car.Size
+		}
+		if i > 0 && epoch.Epoch > n.Epochs[i-1].Epoch+1 {
+			n.MissingEpochs = append(n.MissingEpochs, EpochRange{First: n.Epochs[i-1].Epoch + 1, Last: epoch.Epoch - 1})
+		}
+	}
+}
+
+// checkEpochConfig checks the backends of the epoch, and opens it if open is not nil.
+func checkEpochConfig(ctx context.Context, client *http.Client, config *Config, open epochOpener) *EpochReport {
+	report := &EpochReport{
+		Epoch:   *config.Epoch,
+		Config:  config.ConfigFilepath(),
+		Indexes: make([]BackendReport, 0),
+	}
+	check := func(name string, uri URI) BackendReport {
+		backend := probeBackend(client, name, uri)
+		if !backend.Reachable {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", name, backend.Error))
+		}
+		return backend
+	}
+
+	switch {
+	case config.IsFilecoinMode():
+		report.Mode = "filecoin"
+	case config.IsCarFromPieces():
+		report.Mode = "pieces"
+		car := check("car", config.Data.Car.FromPieces.Metadata.URI)
+		if car.Reachable {
+			// the size of the CAR, not of its metadata.
+			car.Size = 0
+			metadata, err := splitcarfetcher.MetadataFromYaml(string(config.Data.Car.FromPieces.Metadata.URI))
+			if err != nil {
+				car.Reachable = false
+				car.Error = err.Error()
+				report.Errors = append(report.Errors, fmt.Sprintf("car: %s", err))
+			} else if metadata.CarPieces != nil {
+				car.Size = int64(metadata.CarPieces.OriginalCarHeaderSize)
+				for _, piece := range metadata.CarPieces.CarPieces {
+					car.Size += int64(piece.ContentSize)
+				}
+			}
+		}
+		report.Car = &car
+	default:
+		report.Mode = "car"
+		car := check("car", config.Data.Car.URI)
+		report.Car = &car
+	}
+
+	if config.UsesIndexServer() {
+		report.IndexServer = config.Indexes.Server.URI.String()
+	}
+	indexes := configIndexes(config)
+	if !config.Indexes.BlockManifest.URI.IsZero() {
+		indexes = append(indexes, configIndex{"block_manifest", config.Indexes.BlockManifest.URI})
+	}
+	if !config.Indexes.LookupTables.URI.IsZero() {
+		indexes = append(indexes, configIndex{"lookup_tables", config.Indexes.LookupTables.URI})
+	}
+	for _, index := range indexes {
+		report.Indexes = append(report.Indexes, check(index.kind, index.uri))
+	}
+	for _, overlay := range []struct {
+		name string
+		uri  URI
+	}{
+		{"meta_overlay.car", config.MetaOverlay.Car.URI},
+		{"meta_overlay.index", config.MetaOverlay.Index.URI},
+		{"overlay.car", config.Overlay.Car.URI},
+		{"overlay.index", config.Overlay.Index.URI},
+	} {
+		if !overlay.uri.IsZero() {
+			report.Overlays = append(report.Overlays, check(overlay.name, overlay.uri))
+		}
+	}
+
+	if open == nil || report.Mode == "filecoin" {
+		return report
+	}
+	epoch, err := open(config)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to open the epoch: %s", err))
+		return report
+	}
+	defer epoch.Close()
+	first, err := epoch.GetFirstAvailableBlock(ctx)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to get the first block: %s", err))
+		return report
+	}
+	last, err := epoch.GetMostRecentAvailableBlock(ctx)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to get the last block: %s", err))
+		return report
+	}
+	firstSlot, lastSlot := uint64(first.Slot), uint64(last.Slot)
+	report.FirstSlot, report.LastSlot = &firstSlot, &lastSlot
+	if epochFirst, epochLast := slottools.CalcEpochLimits(report.Epoch); firstSlot < epochFirst || lastSlot > epochLast {
+		report.Errors = append(report.Errors, fmt.Sprintf("the blocks (slots %d-%d) are not all in the epoch (slots %d-%d)", firstSlot, lastSlot, epochFirst, epochLast))
+	}
+	return report
+}
+
+// probeBackend tells whether the file at uri is reachable, and its size: local files (and
+// directories, i.e. gsfa) are stat'ed, remote files are asked for their size.
+func probeBackend(client *http.Client, name string, uri URI) BackendReport {
+	backend := BackendReport{Name: name, URI: uri.String()}
+	var err error
+	switch {
+	case uri.IsRemoteWeb():
+		var info *splitcarfetcher.RemoteFileInfo
+		info, err = splitcarfetcher.GetRemoteFileInfo(client, uri.String())
+		if err == nil {
+			backend.Size = info.Size
+		}
+	case uri.IsLocal():
+		backend.Size, err = localSize(uri)
+	default:
+		err = errors.New("only local and HTTP(S) URIs can be checked")
+	}
+	if err != nil {
+		backend.Error = err.Error()
+	} else {
+		backend.Reachable = true
+	}
+	return backend
+}
+
+// localSize returns the size of the local file, or of the files in the directory.
+func localSize(uri URI) (int64, error) {
+	path, err := localPath(uri)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+	var size int64
+	err = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+func (r *EpochsReport) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r *EpochsReport) writeHTML(w io.Writer) error {
+	return epochsReportTemplate.Execute(w, r)
+}
+
+var epochsReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bytes": func(n int64) string { return humanize.IBytes(uint64(n)) },
+	"network": func(name string) string {
+		if name == "" {
+			return "default"
+		}
+		return name
+	},
+	"reachable": func(backends []BackendReport) int {
+		n := 0
+		for _, backend := range backends {
+			if backend.Reachable {
+				n++
+			}
+		}
+		return n
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Epochs report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+.ok { color: #080; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Epochs report</h1>
+<p>Generated at {{.GeneratedAt.Format "2006-01-02 15:04:05 UTC"}}.</p>
+{{range .Networks}}
+<h2>Network {{network .Network}}</h2>
+<p>Epochs {{.FirstEpoch}} to {{.LastEpoch}}: {{len .Epochs}} configured, {{.Healthy}} healthy, {{bytes .CarBytes}} of CARs.
+{{if .MissingEpochs}}<span class="error">Missing epochs: {{range $i, $r := .MissingEpochs}}{{if $i}}, {{end}}{{$r}}{{end}}.</span>{{else}}<span class="ok">No missing epochs.</span>{{end}}</p>
+<table>
+<tr><th>Epoch</th><th>Mode</th><th>CAR</th><th>Indexes</th><th>First slot</th><th>Last slot</th><th>Status</th></tr>
+{{range .Epochs}}<tr>
+<td>{{.Epoch}}</td>
+<td>{{.Mode}}</td>
+<td>{{with .Car}}{{if .Reachable}}{{bytes .Size}}{{else}}<span class="error">unreachable</span>{{end}}{{end}}</td>
+<td>{{reachable .Indexes}}/{{len .Indexes}}: {{range $i, $index := .Indexes}}{{if $i}}, {{end}}<span class="{{if $index.Reachable}}ok{{else}}error{{end}}">{{$index.Name}}</span>{{end}}{{with .IndexServer}} (index server {{.}}){{end}}</td>
+<td>{{with .FirstSlot}}{{.}}{{end}}</td>
+<td>{{with .LastSlot}}{{.}}{{end}}</td>
+<td>{{if .Errors}}<span class="error">{{range .Errors}}{{.}}<br>{{end}}</span>{{else}}<span class="ok">OK</span>{{end}}</td>
+</tr>
+{{end}}</table>
+{{end}}
+{{if .Invalid}}
+<h2>Invalid configs</h2>
+<table>
+<tr><th>Config</th><th>Error</th></tr>
+{{range .Invalid}}<tr><td>{{.Config}}</td><td class="error">{{.Error}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestEpochsReport(t *testing.T) {
+	// the generated CAR has an epoch node as its root, which the first and last blocks are found from.
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+	slots := fixture.Slots()
+	paths, _, _, err := buildTestEpochIndexes(carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, carPath)
+	}))
+	defer server.Close()
+	carInfo, err := os.Stat(carPath)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	writeConfig := func(name string, body string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644))
+	}
+	epochConfig := func(epoch int, network string, carURI string) string {
+		return fmt.Sprintf(`epoch: %d
+version: 1
+network: %q
+genesis:
+  hash: 5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d
+  creation_time: 1584368940
+data:
+  car:
+    uri: %q
+indexes:
+%s`, epoch, network, carURI, paths.String())
+	}
+	writeConfig("epoch-0.yml", epochConfig(0, "", carPath))
+	// epoch 1 is missing, and the CAR of epoch 2 is not there.
+	writeConfig("epoch-2.yml", epochConfig(2, "", filepath.Join(dir, "epoch-2.car")))
+	writeConfig("testnet/epoch-0.yml", epochConfig(0, "testnet", server.URL+"/epoch-0.car"))
+	writeConfig("invalid.yml", "epoch: 3\nversion: 1\n")
+
+	configFiles, err := GetListOfConfigFiles([]string{dir}, nil, nil)
+	require.NoError(t, err)
+	open := func(config *Config) (*Epoch, error) {
+		return openTestEpoch(t, config), nil
+	}
+	report := buildEpochsReport(context.Background(), configFiles, func(config *Config) (*Epoch, error) {
+		if *config.Epoch != 0 {
+			return nil, fmt.Errorf("no CAR")
+		}
+		return open(config)
+	}, 2)
+
+	require.Len(t, report.Invalid, 1)
+	require.Equal(t, filepath.Join(dir, "invalid.yml"), report.Invalid[0].Config)
+	require.Len(t, report.Networks, 2)
+
+	mainnet := report.Networks[0]
+	require.Equal(t, "", mainnet.Network)
+	require.Equal(t, uint64(0), mainnet.FirstEpoch)
+	require.Equal(t, uint64(2), mainnet.LastEpoch)
+	require.Equal(t, []EpochRange{{First: 1, Last: 1}}, mainnet.MissingEpochs)
+	require.Equal(t, 1, mainnet.Healthy)
+	require.Equal(t, carInfo.Size(), mainnet.CarBytes)
+	require.Len(t, mainnet.Epochs, 2)
+
+	healthy := mainnet.Epochs[0]
+	require.Empty(t, healthy.Errors)
+	require.Equal(t, "car", healthy.Mode)
+	require.True(t, healthy.Car.Reachable)
+	require.Len(t, healthy.Indexes, 5)
+	for _, index := range healthy.Indexes {
+		require.True(t, index.Reachable, index.Name)
+		require.Positive(t, index.Size, index.Name)
+	}
+	require.Equal(t, slots[0], *healthy.FirstSlot)
+	require.Equal(t, slots[len(slots)-1], *healthy.LastSlot)
+
+	broken := mainnet.Epochs[1]
+	require.False(t, broken.Car.Reachable)
+	require.Len(t, broken.Errors, 2)
+	require.Contains(t, broken.Errors[0], "car: ")
+	require.Contains(t, broken.Errors[1], "failed to open the epoch")
+	require.Nil(t, broken.FirstSlot)
+
+	testnet := report.Networks[1]
+	require.Equal(t, "testnet", testnet.Network)
+	require.Empty(t, testnet.MissingEpochs)
+	require.Equal(t, 1, testnet.Healthy)
+	require.Equal(t, carInfo.Size(), testnet.Epochs[0].Car.Size)
+	require.Equal(t, slots[len(slots)-1], *testnet.Epochs[0].LastSlot)
+
+	t.Run("command", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "report.html")
+		var out bytes.Buffer
+		app := &cli.App{
+			Commands:       []*cli.Command{newCmd_Report()},
+			Writer:         &out,
+			ErrWriter:      &out,
+			ExitErrHandler: func(*cli.Context, error) {},
+		}
+		require.NoError(t, app.Run([]string{"faithful-cli", "report", "--epochs-dir", dir, "--slots=false", "--format", "html", "--output", output}))
+		html, err := os.ReadFile(output)
+		require.NoError(t, err)
+		require.Contains(t, string(html), "<h2>Network default</h2>")
+		require.Contains(t, string(html), "<h2>Network testnet</h2>")
+		require.Contains(t, string(html), "Missing epochs: 1.")
+		require.Contains(t, string(html), "<h2>Invalid configs</h2>")
+
+		err = app.Run([]string{"faithful-cli", "report", "--epochs-dir", dir, "--format", "xml"})
+		require.ErrorContains(t, err, "invalid --format")
+	})
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rpcpool/yellowstone-faithful/canonicalize"
+	rpcsanitycheck "github.com/rpcpool/yellowstone-faithful/rpc-sanity-check"
+	"github.com/urfave/cli/v2"
+	"github.com/ybbus/jsonrpc/v3"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_RpcSanityCheck() *cli.Command {
+	var (
+		refURL        string
+		targetURL     string
+		firstSlot     uint64
+		lastSlot      uint64
+		samples       int
+		soak          bool
+		duration      time.Duration
+		sloFlags      cli.StringSlice
+		gsfa          bool
+		gsfaAddresses int
+		gsfaPageSize  int
+		gsfaPages     int
+		rulesVersion  int
+		refIsFaithful bool
+	)
+	return &cli.Command{
+		Name:  "rpc-sanity-check",
+		Usage: "Compare the responses of a faithful RPC server with the ones of a reference RPC.",
+		Description: "Picks random slots in --first-slot..--last-slot and compares getBlock, and getTransaction of a random transaction of the block, between --ref and --target (after canonicalization, see the canonicalize package). " +
+			"With --soak, runs continuously for --duration, tracks the latency percentiles, error and mismatch rates of the target per method, and exits non-zero if an --slo is violated, so it can be used as a release gate. " +
+			"With --gsfa, also compares the getSignaturesForAddress pages of the most active addresses of the sampled blocks. " +
+			"If --ref is another faithful instance (--ref-is-faithful, detected with getVersion if not set), e.g. to validate a re-generated epoch, the responses must be exactly the same, and the slots --ref doesn't have must be missing from --target too.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "ref",
+				Usage:       "Reference RPC endpoint",
+				Required:    true,
+				Destination: &refURL,
+			},
+			&cli.StringFlag{
+				Name:        "target",
+				Usage:       "Target RPC endpoint",
+				Required:    true,
+				Destination: &targetURL,
+			},
+			&cli.Uint64Flag{
+				Name:        "first-slot",
+				Usage:       "First slot of the range to sample from",
+				Required:    true,
+				Destination: &firstSlot,
+			},
+			&cli.Uint64Flag{
+				Name:        "last-slot",
+				Usage:       "Last slot of the range to sample from",
+				Required:    true,
+				Destination: &lastSlot,
+			},
+			&cli.IntFlag{
+				Name:        "samples",
+				Usage:       "Number of blocks to compare (without --soak)",
+				Value:       100,
+				Destination: &samples,
+			},
+			&cli.BoolFlag{
+				Name:        "soak",
+				Usage:       "Compare continuously for --duration",
+				Destination: &soak,
+			},
+			&cli.DurationFlag{
+				Name:        "duration",
+				Usage:       "Duration of the soak run",
+				Value:       time.Hour,
+				Destination: &duration,
+			},
+			&cli.StringSliceFlag{
+				Name:        "slo",
+				Usage:       "Objective that must be met, e.g. 'getTransaction:p99<300ms', '*:mismatches<0.01%', 'getBlock:errors<0.1%' (repeatable)",
+				Destination: &sloFlags,
+			},
+			&cli.BoolFlag{
+				Name:        "gsfa",
+				Usage:       "Also compare getSignaturesForAddress for active addresses of the sampled blocks",
+				Destination: &gsfa,
+			},
+			&cli.IntFlag{
+				Name:        "gsfa-addresses",
+				Usage:       "Number of addresses to compare with --gsfa",
+				Value:       20,
+				Destination: &gsfaAddresses,
+			},
+			&cli.IntFlag{
+				Name:        "gsfa-page-size",
+				Usage:       "Limit of each getSignaturesForAddress request",
+				Value:       1000,
+				Destination: &gsfaPageSize,
+			},
+			&cli.IntFlag{
+				Name:        "gsfa-pages",
+				Usage:       "Number of getSignaturesForAddress pages to compare per address",
+				Value:       3,
+				Destination: &gsfaPages,
+			},
+			&cli.IntFlag{
+				Name:        "rules-version",
+				Usage:       "Version of the canonicalization rules",
+				Value:       canonicalize.Latest,
+				Destination: &rulesVersion,
+			},
+			&cli.BoolFlag{
+				Name:        "ref-is-faithful",
+				Usage:       "The reference is another faithful instance: compare without canonicalization (detected with getVersion if not set)",
+				Destination: &refIsFaithful,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if _, err := canonicalize.Rules(rulesVersion); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			var slos []rpcsanitycheck.SLO
+			for _, s := range sloFlags.Value() {
+				slo, err := rpcsanitycheck.ParseSLO(s)
+				if err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+				slos = append(slos, slo)
+			}
+			ref := jsonrpc.NewClient(refURL)
+			target := jsonrpc.NewClient(targetURL)
+			if !c.IsSet("ref-is-faithful") {
+				isFaithful, err := rpcsanitycheck.IsFaithful(c.Context, ref)
+				if err != nil {
+					klog.Warningf("Failed to detect if the reference is a faithful instance: %s", err)
+				}
+				refIsFaithful = isFaithful
+			}
+			if refIsFaithful {
+				klog.Infof("The reference is a faithful instance: the responses must match exactly")
+			}
+
+			opts := rpcsanitycheck.SoakOptions{
+				FirstSlot:     firstSlot,
+				LastSlot:      lastSlot,
+				RulesVersion:  rulesVersion,
+				RefIsFaithful: refIsFaithful,
+				OnMismatch: func(method, param string) {
+					klog.Warningf("Mismatch: %s %s", method, param)
+				},
+			}
+			if soak {
+				opts.Duration = duration
+			} else {
+				opts.Iterations = samples
+			}
+			report, err := rpcsanitycheck.Soak(c.Context, ref, target, opts)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			klog.Infof("Compared %d blocks (rules version %d)", report.Iterations, rulesVersion)
+			for _, name := range report.MethodNames() {
+				stats := report.Methods[name]
+				klog.Infof(
+					"%s: %d calls, %d errors, %d mismatches, p50 %s, p90 %s, p99 %s",
+					name,
+					stats.Calls,
+					stats.Errors,
+					stats.Mismatches,
+					stats.Percentile(50),
+					stats.Percentile(90),
+					stats.Percentile(99),
+				)
+			}
+
+			gsfaDivergences := 0
+			if gsfa {
+				gsfaReport, err := rpcsanitycheck.CheckSignaturesForAddress(c.Context, ref, target, report.Slots, gsfaAddresses, rpcsanitycheck.GsfaOptions{
+					PageSize:      gsfaPageSize,
+					MaxPages:      gsfaPages,
+					RulesVersion:  rulesVersion,
+					RefIsFaithful: refIsFaithful,
+				})
+				if err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+				for _, d := range gsfaReport.Divergences {
+					klog.Warningf("getSignaturesForAddress: %s", d)
+				}
+				klog.Infof(
+					"getSignaturesForAddress: compared %d signatures of %d addresses, %d divergences",
+					gsfaReport.Signatures,
+					len(gsfaReport.Addresses),
+					len(gsfaReport.Divergences),
+				)
+				gsfaDivergences = len(gsfaReport.Divergences)
+			}
+
+			violations := report.Evaluate(slos)
+			for _, v := range violations {
+				klog.Errorf("SLO violated: %s", v)
+			}
+			if len(violations) > 0 || gsfaDivergences > 0 {
+				return cli.Exit(fmt.Sprintf("%d SLOs violated, %d getSignaturesForAddress divergences", len(violations), gsfaDivergences), 1)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_rpcWarmup() *cli.Command {
+	var serverURL string
+	var epoch uint64
+	var full bool
+	return &cli.Command{
+		Name:  "warmup",
+		Usage: "Warm up an epoch of a running RPC server.",
+		Description: "Asks a running RPC server (via POST /api/v1/warmup/{epoch}) to pre-touch the index pages of the epoch, " +
+			"read the first bytes of every split CAR piece, and prefetch the CAR header, " +
+			"so that the first user queries after loading the epoch don't see cold latencies. Waits until the warmup is done.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "server",
+				Usage:       "URL of the RPC server",
+				Value:       "http://localhost:8888",
+				Destination: &serverURL,
+			},
+			&cli.Uint64Flag{
+				Name:        "epoch",
+				Usage:       "Epoch to warm up",
+				Required:    true,
+				Destination: &epoch,
+			},
+			&cli.BoolFlag{
+				Name:        "full",
+				Usage:       "Read the whole indexes instead of only the pages every lookup goes through",
+				Destination: &full,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			url := fmt.Sprintf("%s/api/v1/warmup/%d", strings.TrimRight(serverURL, "/"), epoch)
+			if full {
+				url += "?full=true"
+			}
+			req, err := http.NewRequestWithContext(c.Context, http.MethodPost, url, nil)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("failed to reach the RPC server: %s", err), 1)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("failed to read the response: %s", err), 1)
+			}
+			if resp.StatusCode != http.StatusOK {
+				return cli.Exit(fmt.Sprintf("warmup of epoch %d failed: status %d: %s", epoch, resp.StatusCode, strings.TrimSpace(string(body))), 1)
+			}
+			var stats EpochWarmupStats
+			if err := json.Unmarshal(body, &stats); err != nil {
+				return cli.Exit(fmt.Sprintf("invalid response: %s", err), 1)
+			}
+			klog.Infof("Warmed up epoch %d in %s: %d index bytes, %d pieces", stats.Epoch, stats.Took, stats.IndexBytes, stats.Pieces)
+			return nil
+		},
+	}
+}
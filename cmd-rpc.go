@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -14,9 +15,13 @@ import (
 
 	"github.com/allegro/bigcache/v3"
 	"github.com/fsnotify/fsnotify"
+	diskcache "github.com/rpcpool/yellowstone-faithful/disk-cache"
 	hugecache "github.com/rpcpool/yellowstone-faithful/huge-cache"
 	"github.com/rpcpool/yellowstone-faithful/metrics"
+	pagecache "github.com/rpcpool/yellowstone-faithful/page-cache"
+	"github.com/rpcpool/yellowstone-faithful/readahead"
 	splitcarfetcher "github.com/rpcpool/yellowstone-faithful/split-car-fetcher"
+	"github.com/rpcpool/yellowstone-faithful/tracing"
 	"github.com/ryanuber/go-glob"
 	"github.com/urfave/cli/v2"
 	"github.com/ybbus/jsonrpc/v3"
@@ -36,15 +41,82 @@ func newCmd_rpc() *cli.Command {
 	var maxCacheSizeMB int
 	var grpcListenOn string
 	var lotusAPIAddress string
+	var slowQueryThreshold time.Duration
+	var compressionMinSize int
+	var disableCompression bool
+	var corsAllowedOrigins cli.StringSlice
+	var corsAllowedHeaders cli.StringSlice
+	var corsMaxAge time.Duration
+	var shadowConfig ShadowConfig
+	var diskCacheDir string
+	var diskCacheMaxSizeMB int64
+	var indexCacheDir string
+	var indexCacheMaxSizeMB int64
+	var indexCachePageSizeKB int64
+	var blockMemoryBudgetMB int64
+	var blockMemoryWait time.Duration
+	var grpcMaxStreamsPerClient int
+	var grpcTrustedProxies cli.StringSlice
+	var grpcStreamBytesPerSecond int64
+	var grpcStreamsBytesPerSecond int64
+	var grpcStreamMemoryMB int64
+	var hedgeDelay time.Duration
+	var sigNegativeCacheTTL time.Duration
+	var sigSummaryBitsPerKey uint
+	var defaultNetwork string
+	var profiling ProfilingConfig
+	var memoryLimitMB int64
+	var gcPercent int
+	var heapProfileThresholdMB uint64
+	var serverConfigPath string
+	var printConfig bool
+	var epochsDirs cli.StringSlice
+	var discoverSources cli.StringSlice
+	var discoverDir string
+	var discoverGenesis string
+	var discoverInterval time.Duration
+	var shutdownDelay time.Duration
+	var shutdownTimeout time.Duration
+	var maxOpenEpochs int
+	var epochIdleTimeout time.Duration
 	return &cli.Command{
 		Name:        "rpc",
 		Usage:       "Start a Solana JSON RPC server.",
 		Description: "Provide multiple epoch config files, and start a Solana JSON RPC that exposes getTransaction, getBlock, and (optionally) getSignaturesForAddress",
 		ArgsUsage:   "<one or more config files or directories containing config files (nested is fine)>",
 		Before: func(c *cli.Context) error {
+			if serverConfigPath == "" {
+				return nil
+			}
+			values, err := loadServerConfigFile(serverConfigPath)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			if err := applyServerConfig(c, values); err != nil {
+				return cli.Exit(fmt.Sprintf("invalid config file %q: %s", serverConfigPath, err.Error()), 1)
+			}
 			return nil
 		},
-		Flags: append(lassieFetchFlags,
+		Subcommands: []*cli.Command{
+			newCmd_rpcWarmup(),
+		},
+		Flags: append(lassieFetchFlags, withFlagEnvVars(rpcEnvPrefix, []cli.Flag{
+			&cli.StringFlag{
+				Name:        "config",
+				Usage:       "Server config file (TOML, YAML or JSON) with the values of the flags of this command, by name (e.g. listen = ':8899'); the flags and environment variables take precedence",
+				TakesFile:   true,
+				Destination: &serverConfigPath,
+			},
+			&cli.BoolFlag{
+				Name:        "print-effective-config",
+				Usage:       "Print the settings resulting from the flags, the environment variables, the config file and the defaults (in the format of the config file), and exit",
+				Destination: &printConfig,
+			},
+			&cli.StringSliceFlag{
+				Name:        "epochs-dir",
+				Usage:       "Epoch config files or directories containing config files, in addition to the arguments",
+				Destination: &epochsDirs,
+			},
 			&cli.StringFlag{
 				Name:        "listen",
 				Usage:       "Listen address",
@@ -81,6 +153,27 @@ func newCmd_rpc() *cli.Command {
 				Value:       false,
 				Destination: &watch,
 			},
+			&cli.StringSliceFlag{
+				Name:        "discover",
+				Usage:       "Discover the epochs from S3 buckets (s3://bucket/prefix) or HTTP directory listings with files named epoch-N.car and epoch-N-<root CID>-<network>-<kind>.index, and generate their configs into --discover-dir",
+				Destination: &discoverSources,
+			},
+			&cli.StringFlag{
+				Name:        "discover-dir",
+				Usage:       "Directory of the epoch config files generated by --discover; it is loaded with the other epochs directories",
+				Destination: &discoverDir,
+			},
+			&cli.StringFlag{
+				Name:        "discover-genesis",
+				Usage:       "Local genesis archive for the epoch 0 found by --discover (without it, epoch 0 is skipped)",
+				TakesFile:   true,
+				Destination: &discoverGenesis,
+			},
+			&cli.DurationFlag{
+				Name:        "discover-interval",
+				Usage:       "How often to list the --discover sources again to add, update and remove epochs (0 to list them only at startup)",
+				Destination: &discoverInterval,
+			},
 			&cli.StringFlag{
 				Name:        "proxy",
 				Usage:       "Path to a config file that will be used to proxy unknown RPC methods",
@@ -111,12 +204,306 @@ func newCmd_rpc() *cli.Command {
 				Value:       defaultLotusAPIAddress,
 				Destination: &lotusAPIAddress,
 			},
-		),
+			&cli.DurationFlag{
+				Name:        "slow-query-threshold",
+				Usage:       "Log requests that take longer than this, with their timing breakdown (0 to disable)",
+				Value:       0,
+				Destination: &slowQueryThreshold,
+			},
+			&cli.IntFlag{
+				Name:        "compression-min-size",
+				Usage:       "Minimum size (in bytes) of the responses to compress with zstd, gzip or deflate, as negotiated with the client",
+				Value:       DefaultCompressionMinSize,
+				Destination: &compressionMinSize,
+			},
+			&cli.BoolFlag{
+				Name:        "disable-compression",
+				Usage:       "Disable the compression of the JSON RPC responses",
+				Value:       false,
+				Destination: &disableCompression,
+			},
+			&cli.StringSliceFlag{
+				Name:        "cors-allowed-origins",
+				Usage:       "Origins allowed to call the JSON RPC and REST endpoints from a browser (e.g. '*', 'https://explorer.example.com' or 'https://*.example.com'); if empty, CORS is disabled",
+				Destination: &corsAllowedOrigins,
+			},
+			&cli.StringSliceFlag{
+				Name:        "cors-allowed-headers",
+				Usage:       "Request headers allowed in CORS requests",
+				Value:       cli.NewStringSlice(DefaultCORSAllowedHeaders...),
+				Destination: &corsAllowedHeaders,
+			},
+			&cli.DurationFlag{
+				Name:        "cors-max-age",
+				Usage:       "How long browsers can cache the result of a CORS preflight request",
+				Value:       10 * time.Minute,
+				Destination: &corsMaxAge,
+			},
+			&cli.StringFlag{
+				Name:        "shadow-target",
+				Usage:       "URL of a JSON RPC endpoint (e.g. a canary running a new version) to which a sample of the requests is also sent, to compare its responses with the local ones; if empty, shadowing is disabled",
+				Value:       "",
+				Destination: &shadowConfig.Target,
+			},
+			&cli.Float64Flag{
+				Name:        "shadow-percent",
+				Usage:       "Percentage of the JSON RPC requests to shadow to --shadow-target",
+				Value:       1,
+				Destination: &shadowConfig.Percent,
+			},
+			&cli.DurationFlag{
+				Name:        "shadow-timeout",
+				Usage:       "How long to wait for the response of --shadow-target",
+				Value:       30 * time.Second,
+				Destination: &shadowConfig.Timeout,
+			},
+			&cli.IntFlag{
+				Name:        "shadow-max-in-flight",
+				Usage:       "How many shadowed requests can wait for --shadow-target at the same time; beyond that, requests are not shadowed",
+				Value:       64,
+				Destination: &shadowConfig.MaxInFlight,
+			},
+			&cli.StringFlag{
+				Name:        "disk-cache-dir",
+				Usage:       "Directory where to persist the nodes fetched from remote sources (lassie, remote CAR files); if empty, the disk cache is disabled",
+				Value:       "",
+				Destination: &diskCacheDir,
+			},
+			&cli.Int64Flag{
+				Name:        "disk-cache-max-size",
+				Usage:       "Maximum size of the disk cache in MB",
+				Value:       10 * 1024,
+				Destination: &diskCacheMaxSizeMB,
+			},
+			&cli.StringFlag{
+				Name:        "index-cache-dir",
+				Usage:       "Directory where to persist the pages read from remote index files, shared by all the epochs; if empty, the index page cache is disabled",
+				Value:       "",
+				Destination: &indexCacheDir,
+			},
+			&cli.Int64Flag{
+				Name:        "index-cache-max-size",
+				Usage:       "Maximum size of the index page cache in MB",
+				Value:       10 * 1024,
+				Destination: &indexCacheMaxSizeMB,
+			},
+			&cli.Int64Flag{
+				Name:        "index-cache-page-size",
+				Usage:       "Size of the pages of the index page cache in KB",
+				Value:       pagecache.DefaultPageSize / 1024,
+				Destination: &indexCachePageSizeKB,
+			},
+			&cli.Int64Flag{
+				Name:        "block-memory-budget",
+				Usage:       "Memory (in MB) that the blocks being assembled concurrently by getBlock can use; further requests wait for room (0 for no limit)",
+				Value:       0,
+				Destination: &blockMemoryBudgetMB,
+			},
+			&cli.DurationFlag{
+				Name:        "block-memory-wait",
+				Usage:       "How long a getBlock request waits for room in the block memory budget before it's rejected (0 to wait until the request is canceled)",
+				Value:       10 * time.Second,
+				Destination: &blockMemoryWait,
+			},
+			&cli.IntFlag{
+				Name:        "grpc-max-streams-per-client",
+				Usage:       "How many gRPC streams a client (IP address, or x-token metadata behind a --grpc-trusted-proxy) can have open at once (0 for no limit)",
+				Value:       0,
+				Destination: &grpcMaxStreamsPerClient,
+			},
+			&cli.StringSliceFlag{
+				Name:        "grpc-trusted-proxy",
+				Usage:       "IP address or CIDR range of a proxy that authenticates the gRPC clients: the x-token (or else x-forwarded-for) metadata of its streams identifies the client; can be specified multiple times",
+				Destination: &grpcTrustedProxies,
+				Action: func(c *cli.Context, values []string) error {
+					if _, err := parseTrustedProxies(values); err != nil {
+						return fmt.Errorf("invalid --grpc-trusted-proxy: %w", err)
+					}
+					return nil
+				},
+			},
+			&cli.Int64Flag{
+				Name:        "grpc-stream-max-bytes-per-second",
+				Usage:       "Maximum rate (in bytes per second) at which each gRPC stream sends (0 for no limit)",
+				Value:       0,
+				Destination: &grpcStreamBytesPerSecond,
+			},
+			&cli.Int64Flag{
+				Name:        "grpc-streams-max-bytes-per-second",
+				Usage:       "Maximum rate (in bytes per second) at which all the gRPC streams send together, split evenly between the clients with open streams (0 for no limit)",
+				Value:       0,
+				Destination: &grpcStreamsBytesPerSecond,
+			},
+			&cli.Int64Flag{
+				Name:        "grpc-stream-memory",
+				Usage:       "Block memory (in MB) that the gRPC streams can use together, so that the rest of --block-memory-budget stays available to the other requests (0 for no limit)",
+				Value:       0,
+				Destination: &grpcStreamMemoryMB,
+			},
+			&cli.DurationFlag{
+				Name:        "hedge-delay",
+				Usage:       "How long a query of a remote epoch runs before a second attempt is started, when a request spans several epochs (0 to disable)",
+				Value:       0,
+				Destination: &hedgeDelay,
+			},
+			&cli.DurationFlag{
+				Name:        "sig-negative-cache-ttl",
+				Usage:       "How long the signatures that weren't found in any epoch are remembered, to answer the repeated lookups without searching the epochs (the cache is cleared when the epochs change; 0 to disable)",
+				Value:       time.Minute,
+				Destination: &sigNegativeCacheTTL,
+			},
+			&cli.UintFlag{
+				Name:        "sig-summary-bits-per-key",
+				Usage:       "Memory (in bits per signature) of the in-memory summaries of the sig-exists indexes, which let the signature lookups skip the epochs that don't have the signature; 10 gives about 1% false positives (0 to disable)",
+				Value:       0,
+				Destination: &sigSummaryBitsPerKey,
+			},
+			&cli.DurationFlag{
+				Name:        "shutdown-delay",
+				Usage:       "On SIGTERM/SIGINT, how long to keep accepting connections (while /health fails) before draining, so that the load balancers stop sending requests first",
+				Value:       0,
+				Destination: &shutdownDelay,
+			},
+			&cli.DurationFlag{
+				Name:        "shutdown-timeout",
+				Usage:       "On SIGTERM/SIGINT, how long to wait for the requests and streams in flight to finish before canceling them",
+				Value:       30 * time.Second,
+				Destination: &shutdownTimeout,
+			},
+			&cli.IntFlag{
+				Name:        "max-open-epochs",
+				Usage:       "How many epochs can be open at once; the epochs are opened on first use, and the least recently used ones are unloaded past it (0 for no limit)",
+				Value:       0,
+				Destination: &maxOpenEpochs,
+			},
+			&cli.DurationFlag{
+				Name:        "epoch-idle-timeout",
+				Usage:       "Unload the epochs unused for this long, at least 1m; the epochs are opened on first use (0 to keep them open)",
+				Value:       0,
+				Destination: &epochIdleTimeout,
+				Action: func(c *cli.Context, d time.Duration) error {
+					if d > 0 && d < epochUnloadGrace {
+						return fmt.Errorf("invalid --epoch-idle-timeout: %s (must be 0 or at least %s)", d, epochUnloadGrace)
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:        "default-network",
+				Usage:       "Network of the requests that don't select one (with a /<network>/ path prefix or the X-Faithful-Network header); the epoch configs without a network are in it",
+				Value:       "",
+				Destination: &defaultNetwork,
+			},
+			&cli.StringFlag{
+				Name:        "pprof-listen",
+				Usage:       "Listen address of the pprof and expvar endpoints (/debug/pprof/ and /debug/vars), separate from the RPC API; if empty, they are disabled",
+				Value:       "",
+				Destination: &profiling.PprofListenOn,
+			},
+			&cli.IntFlag{
+				Name:        "mutex-profile-fraction",
+				Usage:       "Report 1 out of this many mutex contention events in the mutex profile (0 to disable it)",
+				Value:       0,
+				Destination: &profiling.MutexProfileFraction,
+			},
+			&cli.IntFlag{
+				Name:        "block-profile-rate",
+				Usage:       "Sample one blocking event per this many nanoseconds blocked in the block profile (0 to disable it)",
+				Value:       0,
+				Destination: &profiling.BlockProfileRate,
+			},
+			&cli.Int64Flag{
+				Name:        "memory-limit",
+				Usage:       "Soft memory limit of the Go runtime in MB, like GOMEMLIMIT (0 to leave it unchanged)",
+				Value:       0,
+				Destination: &memoryLimitMB,
+			},
+			&cli.IntFlag{
+				Name:        "gc-percent",
+				Usage:       "GC target percentage, like GOGC (a negative value disables the GC); unchanged if not set",
+				Destination: &gcPercent,
+			},
+			&cli.StringFlag{
+				Name:        "heap-profile-dir",
+				Usage:       "Directory where to write a heap profile when the heap in use goes over --heap-profile-threshold (at most one every 10 minutes; the last 10 are kept); if empty, it's disabled",
+				Value:       "",
+				Destination: &profiling.HeapProfileDir,
+			},
+			&cli.Uint64Flag{
+				Name:        "heap-profile-threshold",
+				Usage:       "Heap in use (in MB) over which a heap profile is written in --heap-profile-dir",
+				Value:       0,
+				Destination: &heapProfileThresholdMB,
+			},
+			&cli.StringFlag{
+				Name:  "car-hash-mismatch",
+				Usage: "What to do when the CAR hash recorded in the indexes of an epoch doesn't match data.car.sha256 (or the indexes disagree): refuse to load the epoch, or warn",
+				Value: string(carHashMismatchRefuse),
+				Action: func(c *cli.Context, s string) error {
+					if policy := carHashMismatchPolicy(s); policy != carHashMismatchRefuse && policy != carHashMismatchWarn {
+						return fmt.Errorf("invalid --car-hash-mismatch: %q (must be %q or %q)", s, carHashMismatchRefuse, carHashMismatchWarn)
+					}
+					return nil
+				},
+			},
+			&cli.BoolFlag{
+				Name:  "car-read-scheduler",
+				Usage: "Schedule the reads of the remote CAR files by access pattern: read ahead of the sequential streams (i.e. block replay), and cache aligned blocks for the random reads (i.e. RPC)",
+				Value: false,
+			},
+			&cli.Int64Flag{
+				Name:  "car-readahead-size",
+				Usage: "Size (in MB) of the windows read ahead of a sequential stream of a remote CAR, with --car-read-scheduler; each remote CAR can have 16 windows in memory (2 for each of 8 streams)",
+				Value: readahead.DefaultReadAheadSize / readahead.MiB,
+			},
+			&cli.Int64Flag{
+				Name:  "car-read-cache-size",
+				Usage: "Memory (in MB) of the blocks cached for the random reads of each remote CAR, with --car-read-scheduler",
+				Value: readahead.DefaultCacheSize / readahead.MiB,
+			},
+		})...),
 		Action: func(c *cli.Context) error {
 			if listenOn == "" && grpcListenOn == "" {
 				return cli.Exit("either --listen or --grpc-listen must be provided (or both)", 1)
 			}
-			src := c.Args().Slice()
+			if printConfig {
+				return printEffectiveConfig(c.App.Writer, c)
+			}
+			profiling.MemoryLimit = memoryLimitMB * 1024 * 1024
+			profiling.HeapProfileThreshold = heapProfileThresholdMB * 1024 * 1024
+			if c.IsSet("gc-percent") {
+				profiling.GCPercent = &gcPercent
+			}
+			if err := profiling.Validate(); err != nil {
+				return cli.Exit(fmt.Sprintf("invalid profiling config: %s", err.Error()), 1)
+			}
+			profiling.Apply()
+			shutdownTracing, err := tracing.Init(c.Context)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			defer func() {
+				// flushes the spans of the last requests.
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				if err := shutdownTracing(ctx); err != nil {
+					klog.Errorf("error shutting down tracing: %s", err.Error())
+				}
+			}()
+			src := append(c.Args().Slice(), epochsDirs.Value()...)
+			var discovery *epochDiscovery
+			if len(discoverSources.Value()) > 0 {
+				discovery, err = newEpochDiscovery(discoverSources.Value(), discoverDir, discoverGenesis)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("invalid --discover: %s", err.Error()), 1)
+				}
+				result, err := discovery.Run(c.Context)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("failed to discover the epochs: %s", err.Error()), 1)
+				}
+				klog.Infof("Discovered %d epochs (%d configs written, %d removed) into %q", result.Epochs, len(result.Written), len(result.Removed), discoverDir)
+				src = append(src, discoverDir)
+			}
 			configFiles, err := GetListOfConfigFiles(
 				src,
 				includePatterns.Value(),
@@ -125,6 +512,12 @@ func newCmd_rpc() *cli.Command {
 			if err != nil {
 				return cli.Exit(err.Error(), 1)
 			}
+			if serverConfigPath != "" {
+				// the server config file is not an epoch config file, even if it's in an epochs directory.
+				configFiles = slices.DeleteFunc(configFiles, func(path string) bool {
+					return sameFile(path, serverConfigPath)
+				})
+			}
 			klog.Infof("Found %d config files:", len(configFiles))
 			for _, configFile := range configFiles {
 				klog.V(3).Infof("  - %s", configFile)
@@ -136,6 +529,22 @@ func newCmd_rpc() *cli.Command {
 			if err != nil {
 				return fmt.Errorf("failed to create cache: %w", err)
 			}
+			if diskCacheDir != "" {
+				diskCache, err := diskcache.New(diskCacheDir, diskCacheMaxSizeMB*1024*1024)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("failed to open disk cache: %s", err.Error()), 1)
+				}
+				klog.Infof("Disk cache at %q: %d objects, %d bytes", diskCacheDir, diskCache.Len(), diskCache.Size())
+				allCache.SetDiskCache(diskCache)
+			}
+			if indexCacheDir != "" {
+				indexPages, err := pagecache.New(indexCacheDir, indexCacheMaxSizeMB*1024*1024, indexCachePageSizeKB*1024)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("failed to open index page cache: %s", err.Error()), 1)
+				}
+				klog.Infof("Index page cache at %q: %d pages, %d bytes", indexCacheDir, indexPages.Len(), indexPages.Size())
+				allCache.SetIndexPageCache(indexPages)
+			}
 
 			// Load configs:
 			configs := make(ConfigSlice, 0)
@@ -146,6 +555,16 @@ func newCmd_rpc() *cli.Command {
 				}
 				configs = append(configs, config)
 			}
+			if defaultNetwork != "" {
+				if err := validateNetworkName(defaultNetwork); err != nil {
+					return cli.Exit(fmt.Sprintf("invalid default network: %s", err.Error()), 1)
+				}
+				for _, config := range configs {
+					if config.Network == "" {
+						config.Network = defaultNetwork
+					}
+				}
+			}
 			// Validate configs:
 			if err := configs.Validate(); err != nil {
 				return cli.Exit(fmt.Sprintf("error validating configs: %s", err.Error()), 1)
@@ -161,16 +580,72 @@ func newCmd_rpc() *cli.Command {
 				5*time.Second,
 			)
 
-			multi := NewMultiEpoch(&Options{
-				GsfaOnlySignatures:     gsfaOnlySignatures,
-				EpochSearchConcurrency: epochSearchConcurrency,
-			})
+			trustedProxies, err := parseTrustedProxies(grpcTrustedProxies.Value())
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("invalid --grpc-trusted-proxy: %s", err.Error()), 1)
+			}
+			namespaces := NewNamespaces(&Options{
+				GsfaOnlySignatures:        gsfaOnlySignatures,
+				EpochSearchConcurrency:    epochSearchConcurrency,
+				SlowQueryThreshold:        slowQueryThreshold,
+				CompressionMinSize:        compressionMinSize,
+				DisableCompression:        disableCompression,
+				BlockMemoryBudget:         blockMemoryBudgetMB * 1024 * 1024,
+				BlockMemoryWait:           blockMemoryWait,
+				HedgeDelay:                hedgeDelay,
+				SigNegativeCacheTTL:       sigNegativeCacheTTL,
+				SigSummaryBitsPerKey:      sigSummaryBitsPerKey,
+				ShutdownDelay:             shutdownDelay,
+				ShutdownTimeout:           shutdownTimeout,
+				MaxOpenEpochs:             maxOpenEpochs,
+				EpochIdleTimeout:          epochIdleTimeout,
+				GRPCMaxStreamsPerClient:   grpcMaxStreamsPerClient,
+				GRPCTrustedProxies:        trustedProxies,
+				GRPCStreamBytesPerSecond:  grpcStreamBytesPerSecond,
+				GRPCStreamsBytesPerSecond: grpcStreamsBytesPerSecond,
+				GRPCStreamMemory:          grpcStreamMemoryMB * 1024 * 1024,
+			}, defaultNetwork)
+			for _, config := range configs {
+				namespaces.GetOrCreate(config.Network)
+			}
+			if networks := namespaces.Networks(); len(networks) > 1 {
+				klog.Infof("Serving %d networks: %q (default %q)", len(networks), networks, defaultNetwork)
+			}
 			defer func() {
-				if err := multi.Close(); err != nil {
+				if err := namespaces.Close(); err != nil {
 					klog.Errorf("error closing multi-epoch: %s", err.Error())
 				}
 			}()
 
+			// addEpoch opens the epoch of the config and adds it (replacing the one with the same
+			// number if replace), or, if the epochs are opened on first use, just adds it.
+			addEpoch := func(config *Config, replace bool) (uint64, error) {
+				multi := namespaces.GetOrCreate(config.Network)
+				if multi.OpensEpochsLazily() {
+					open := func() (*Epoch, error) {
+						return NewEpochFromConfig(config, c, allCache, minerInfo)
+					}
+					if replace {
+						return *config.Epoch, multi.ReplaceOrAddLazyEpoch(config, open)
+					}
+					return *config.Epoch, multi.AddLazyEpoch(config, open)
+				}
+				epoch, err := NewEpochFromConfig(config, c, allCache, minerInfo)
+				if err != nil {
+					return 0, fmt.Errorf("failed to create epoch from config %q: %s", config.ConfigFilepath(), err.Error())
+				}
+				if replace {
+					err = multi.ReplaceOrAddEpoch(epoch.Epoch(), epoch)
+				} else {
+					err = multi.AddEpoch(epoch.Epoch(), epoch)
+				}
+				if err != nil {
+					epoch.Close()
+					return 0, fmt.Errorf("failed to add epoch %d: %s", epoch.Epoch(), err.Error())
+				}
+				return epoch.Epoch(), nil
+			}
+
 			startedInitiatingEpochsAt := time.Now()
 			go func() {
 				// Sort epochs by epoch number:
@@ -186,30 +661,19 @@ func newCmd_rpc() *cli.Command {
 				for confIndex := range configs {
 					config := configs[confIndex]
 					wg.Go(func() error {
-						epochNum := *config.Epoch
-						err := func() error {
-							epoch, err := NewEpochFromConfig(
-								config,
-								c,
-								allCache,
-								minerInfo,
-							)
-							if err != nil {
-								return fmt.Errorf("failed to create epoch from config %q: %s", config.ConfigFilepath(), err.Error())
-							}
-							if err := multi.AddEpoch(epoch.Epoch(), epoch); err != nil {
-								return fmt.Errorf("failed to add epoch %d: %s", epoch.Epoch(), err.Error())
-							}
+						if c.Context.Err() != nil {
+							// shutting down.
 							return nil
-						}()
-						if err != nil {
-							metrics.EpochsAvailable.WithLabelValues(fmt.Sprintf("%d", epochNum)).Set(0)
+						}
+						epochNum := *config.Epoch
+						if _, err := addEpoch(config, false); err != nil {
+							metrics.EpochsAvailable.WithLabelValues(fmt.Sprintf("%d", epochNum), config.Network).Set(0)
 							klog.Error(err)
 							numFailed.Add(1)
 							// NOTE: DO NOT return the error here, as we want to continue loading other epochs
 							return nil
 						}
-						metrics.EpochsAvailable.WithLabelValues(fmt.Sprintf("%d", epochNum)).Set(1)
+						metrics.EpochsAvailable.WithLabelValues(fmt.Sprintf("%d", epochNum), config.Network).Set(1)
 						numSucceeded.Add(1)
 						return nil
 					})
@@ -249,7 +713,7 @@ func newCmd_rpc() *cli.Command {
 						}
 						klog.V(3).Infof("File event: name=%q, op=%q", event.Name, event.Op)
 
-						if event.Op != fsnotify.Remove && multi.HasEpochWithSameHashAsFile(event.Name) {
+						if event.Op != fsnotify.Remove && namespaces.HasEpochWithSameHashAsFile(event.Name) {
 							klog.V(3).Infof("Epoch with same hash as file %q is already loaded; do nothing", event.Name)
 							return
 						}
@@ -265,18 +729,16 @@ func newCmd_rpc() *cli.Command {
 									klog.Errorf("error loading config file %q: %s", event.Name, err.Error())
 									return
 								}
-								epoch, err := NewEpochFromConfig(config, c, allCache, minerInfo)
-								if err != nil {
-									klog.Errorf("error creating epoch from config file %q: %s", event.Name, err.Error())
-									return
+								if config.Network == "" {
+									config.Network = defaultNetwork
 								}
-								err = multi.ReplaceOrAddEpoch(epoch.Epoch(), epoch)
+								epochNum, err := addEpoch(config, true)
 								if err != nil {
-									klog.Errorf("error replacing epoch %d: %s", epoch.Epoch(), err.Error())
+									klog.Errorf("error replacing epoch from config file %q: %s", event.Name, err.Error())
 									return
 								}
-								klog.V(2).Infof("Epoch %d added/replaced in %s", epoch.Epoch(), time.Since(startedAt))
-								metrics.EpochsAvailable.WithLabelValues(fmt.Sprintf("%d", epoch.Epoch())).Set(1)
+								klog.V(2).Infof("Epoch %d added/replaced in %s", epochNum, time.Since(startedAt))
+								metrics.EpochsAvailable.WithLabelValues(fmt.Sprintf("%d", epochNum), config.Network).Set(1)
 							}
 						case fsnotify.Create:
 							{
@@ -288,30 +750,28 @@ func newCmd_rpc() *cli.Command {
 									klog.Errorf("error loading config file %q: %s", event.Name, err.Error())
 									return
 								}
-								epoch, err := NewEpochFromConfig(config, c, allCache, minerInfo)
-								if err != nil {
-									klog.Errorf("error creating epoch from config file %q: %s", event.Name, err.Error())
-									return
+								if config.Network == "" {
+									config.Network = defaultNetwork
 								}
-								err = multi.AddEpoch(epoch.Epoch(), epoch)
+								epochNum, err := addEpoch(config, false)
 								if err != nil {
-									klog.Errorf("error adding epoch %d: %s", epoch.Epoch(), err.Error())
+									klog.Errorf("error adding epoch from config file %q: %s", event.Name, err.Error())
 									return
 								}
-								klog.V(2).Infof("Epoch %d added in %s", epoch.Epoch(), time.Since(startedAt))
-								metrics.EpochsAvailable.WithLabelValues(fmt.Sprintf("%d", epoch.Epoch())).Set(1)
+								klog.V(2).Infof("Epoch %d added in %s", epochNum, time.Since(startedAt))
+								metrics.EpochsAvailable.WithLabelValues(fmt.Sprintf("%d", epochNum), config.Network).Set(1)
 							}
 						case fsnotify.Remove:
 							{
 								startedAt := time.Now()
 								klog.V(3).Infof("File %q was removed; processing...", event.Name)
 								// find the epoch that corresponds to this file, and remove it (if any)
-								epNumber, err := multi.RemoveEpochByConfigFilepath(event.Name)
+								network, epNumber, err := namespaces.RemoveEpochByConfigFilepath(event.Name)
 								if err != nil {
 									klog.Errorf("error removing epoch for config file %q: %s", event.Name, err.Error())
 								}
 								klog.V(2).Infof("Epoch %d removed in %s", epNumber, time.Since(startedAt))
-								metrics.EpochsAvailable.WithLabelValues(fmt.Sprintf("%d", epNumber)).Set(0)
+								metrics.EpochsAvailable.WithLabelValues(fmt.Sprintf("%d", epNumber), network).Set(0)
 							}
 						case fsnotify.Rename:
 							klog.V(3).Infof("File %q was renamed; do nothing", event.Name)
@@ -326,21 +786,90 @@ func newCmd_rpc() *cli.Command {
 				}
 			}
 
-			var listenerConfig *ListenerConfig
+			if discovery != nil && discoverInterval > 0 {
+				go func() {
+					ticker := time.NewTicker(discoverInterval)
+					defer ticker.Stop()
+					for {
+						select {
+						case <-c.Context.Done():
+							return
+						case <-ticker.C:
+						}
+						result, err := discovery.Run(c.Context)
+						if err != nil {
+							klog.Errorf("error discovering the epochs: %s", err.Error())
+						}
+						if result == nil || watch {
+							// with --watch, the changed config files are (re)loaded by the watcher.
+							continue
+						}
+						for _, configFile := range result.Written {
+							config, err := LoadConfig(configFile)
+							if err != nil {
+								klog.Errorf("error loading config file %q: %s", configFile, err.Error())
+								continue
+							}
+							if config.Network == "" {
+								config.Network = defaultNetwork
+							}
+							epochNum, err := addEpoch(config, true)
+							if err != nil {
+								klog.Errorf("error replacing epoch from config file %q: %s", configFile, err.Error())
+								continue
+							}
+							klog.V(2).Infof("Discovered epoch %d added/replaced", epochNum)
+							metrics.EpochsAvailable.WithLabelValues(fmt.Sprintf("%d", epochNum), config.Network).Set(1)
+						}
+						for _, configFile := range result.Removed {
+							network, epNumber, err := namespaces.RemoveEpochByConfigFilepath(configFile)
+							if err != nil {
+								klog.Errorf("error removing epoch for config file %q: %s", configFile, err.Error())
+								continue
+							}
+							klog.V(2).Infof("Epoch %d removed, as it is no longer discovered", epNumber)
+							metrics.EpochsAvailable.WithLabelValues(fmt.Sprintf("%d", epNumber), network).Set(0)
+						}
+					}
+				}()
+			}
+
+			listenerConfig := &ListenerConfig{}
 			if pathForProxyForUnknownRpcMethods != "" {
 				proxyConfig, err := LoadProxyConfig(pathForProxyForUnknownRpcMethods)
 				if err != nil {
 					return cli.Exit(fmt.Sprintf("failed to load proxy config file %q: %s", pathForProxyForUnknownRpcMethods, err.Error()), 1)
 				}
-				listenerConfig = &ListenerConfig{
-					ProxyConfig: proxyConfig,
+				listenerConfig.ProxyConfig = proxyConfig
+			}
+			if len(corsAllowedOrigins.Value()) > 0 {
+				corsConfig := &CORSConfig{
+					AllowedOrigins: corsAllowedOrigins.Value(),
+					AllowedHeaders: corsAllowedHeaders.Value(),
+					MaxAge:         corsMaxAge,
 				}
+				if err := corsConfig.Validate(); err != nil {
+					return cli.Exit(fmt.Sprintf("invalid CORS config: %s", err.Error()), 1)
+				}
+				listenerConfig.CORS = corsConfig
+			}
+			if shadowConfig.Target != "" {
+				if err := shadowConfig.Validate(); err != nil {
+					return cli.Exit(fmt.Sprintf("invalid shadow config: %s", err.Error()), 1)
+				}
+				listenerConfig.Shadow = &shadowConfig
 			}
 			allListeners := new(errgroup.Group)
+			allListeners.Go(func() error {
+				if err := profiling.Run(c.Context); err != nil {
+					return fmt.Errorf("failed to start profiling: %w", err)
+				}
+				return nil
+			})
 
 			if grpcListenOn != "" {
 				allListeners.Go(func() error {
-					err := multi.ListenAndServeGRPC(c.Context, grpcListenOn)
+					err := namespaces.ListenAndServeGRPC(c.Context, grpcListenOn)
 					if err != nil {
 						return fmt.Errorf("failed to start gRPC server: %w", err)
 					}
@@ -349,7 +878,7 @@ func newCmd_rpc() *cli.Command {
 			}
 			if listenOn != "" {
 				allListeners.Go(func() error {
-					err := multi.ListenAndServe(c.Context, listenOn, listenerConfig)
+					err := namespaces.ListenAndServe(c.Context, listenOn, listenerConfig)
 					if err != nil {
 						return fmt.Errorf("failed to start JSON RPC server: %w", err)
 					}
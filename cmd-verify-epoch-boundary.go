@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/rpcpool/yellowstone-faithful/blockmanifest"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/poh"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_VerifyEpochBoundary() *cli.Command {
+	var output string
+	return &cli.Command{
+		Name:  "verify-epoch-boundary",
+		Usage: "Verify the continuity of the chain across the boundary of two adjacent epochs.",
+		Description: "Takes the CARs (or the block manifests) of epoch N and N+1 and checks that the chain continues across the boundary: " +
+			"the first block of epoch N+1 has the last block of epoch N as parent, its first entry chains from the blockhash of that block (only if epoch N+1 is a CAR), " +
+			"the block heights increase by one from block to block, and every slot is covered at most once, by the CAR of its epoch. " +
+			"The result is written as a JSON report; the command fails if a check fails.",
+		ArgsUsage: "<epoch N car|manifest> <epoch N+1 car|manifest>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "Write the JSON report to this file instead of stdout",
+				Destination: &output,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() != 2 {
+				return cli.Exit("expected the CARs (or manifests) of two adjacent epochs", 1)
+			}
+			report, err := verifyEpochBoundary(c.Context, c.Args().Get(0), c.Args().Get(1))
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			encoded, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			encoded = append(encoded, '\n')
+			if output != "" {
+				if err := os.WriteFile(output, encoded, 0o644); err != nil {
+					return cli.Exit(fmt.Sprintf("failed to write report: %s", err), 1)
+				}
+			} else {
+				os.Stdout.Write(encoded)
+			}
+			if !report.OK {
+				return cli.Exit(fmt.Sprintf("epoch boundary %d/%d: %d checks failed", report.Previous.Epoch, report.Next.Epoch, report.failed()), 1)
+			}
+			klog.Infof("Epoch boundary %d/%d is continuous", report.Previous.Epoch, report.Next.Epoch)
+			return nil
+		},
+	}
+}
+
+const (
+	boundaryCheckOK      = "ok"
+	boundaryCheckFailed  = "failed"
+	boundaryCheckSkipped = "skipped"
+)
+
+// epochBoundaryReport is the machine-readable result of verifyEpochBoundary.
+type epochBoundaryReport struct {
+	Previous epochBoundarySide `json:"previous"`
+	Next     epochBoundarySide `json:"next"`
+	Checks   []boundaryCheck   `json:"checks"`
+	OK       bool              `json:"ok"`
+}
+
+// epochBoundarySide describes one of the two epochs.
+type epochBoundarySide struct {
+	Path string `json:"path"`
+	// Format is "car" or "manifest".
+	Format    string `json:"format"`
+	Epoch     uint64 `json:"epoch"`
+	Blocks    int    `json:"blocks"`
+	FirstSlot uint64 `json:"first_slot"`
+	LastSlot  uint64 `json:"last_slot"`
+	// Blockhash is the blockhash of the last block.
+	Blockhash string `json:"last_blockhash"`
+}
+
+type boundaryCheck struct {
+	Name string `json:"name"`
+	// Status is "ok", "failed" or "skipped".
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (r *epochBoundaryReport) add(name string, status string, format string, args ...any) {
+	r.Checks = append(r.Checks, boundaryCheck{
+		Name:   name,
+		Status: status,
+		Detail: fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *epochBoundaryReport) failed() int {
+	failed := 0
+	for _, check := range r.Checks {
+		if check.Status == boundaryCheckFailed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// boundaryBlock is what the boundary checks need from a block.
+type boundaryBlock struct {
+	Slot       uint64
+	ParentSlot uint64
+	Blockhash  solana.Hash
+	// Height is nil if unknown (manifests don't have it, and old blocks don't record it).
+	Height *uint64
+}
+
+type boundaryEpoch struct {
+	side   epochBoundarySide
+	blocks []boundaryBlock
+}
+
+func (e *boundaryEpoch) isCar() bool {
+	return e.side.Format == "car"
+}
+
+// verifyEpochBoundary checks the continuity of the chain between the epochs in prevPath and nextPath
+// (each a CAR or a block manifest). Failed checks are recorded in the report;
+// only a failure to read the inputs is an error.
+func verifyEpochBoundary(ctx context.Context, prevPath string, nextPath string) (*epochBoundaryReport, error) {
+	prev, err := loadBoundaryEpoch(ctx, prevPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", prevPath, err)
+	}
+	next, err := loadBoundaryEpoch(ctx, nextPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", nextPath, err)
+	}
+	report := &epochBoundaryReport{
+		Previous: prev.side,
+		Next:     next.side,
+	}
+
+	if next.side.Epoch == prev.side.Epoch+1 {
+		report.add("adjacent-epochs", boundaryCheckOK, "")
+	} else {
+		report.add("adjacent-epochs", boundaryCheckFailed, "epoch %d is not followed by epoch %d", prev.side.Epoch, next.side.Epoch)
+	}
+
+	// Every slot must be covered once, by the CAR of its epoch.
+	var coverage []string
+	for _, epoch := range []*boundaryEpoch{prev, next} {
+		coverage = append(coverage, checkBoundarySlots(epoch)...)
+	}
+	if next.side.FirstSlot <= prev.side.LastSlot {
+		coverage = append(coverage, fmt.Sprintf("the slots %d-%d are covered by both epochs", next.side.FirstSlot, min(prev.side.LastSlot, next.side.LastSlot)))
+	}
+	if len(coverage) == 0 {
+		report.add("slot-coverage", boundaryCheckOK, "")
+	} else {
+		report.add("slot-coverage", boundaryCheckFailed, "%s", joinDetails(coverage))
+	}
+
+	last := prev.blocks[len(prev.blocks)-1]
+	first := next.blocks[0]
+	parentOK := first.ParentSlot == last.Slot
+	if parentOK {
+		report.add("parent-slot", boundaryCheckOK, "")
+	} else {
+		report.add("parent-slot", boundaryCheckFailed, "the parent of slot %d is slot %d, but the last block of epoch %d is slot %d", first.Slot, first.ParentSlot, prev.side.Epoch, last.Slot)
+	}
+
+	switch {
+	case !next.isCar():
+		report.add("blockhash", boundaryCheckSkipped, "the entries of epoch %d are not in a manifest", next.side.Epoch)
+	case !parentOK:
+		report.add("blockhash", boundaryCheckSkipped, "slot %d is not the child of slot %d", first.Slot, last.Slot)
+	default:
+		verifyErr, err := verifyBoundaryBlockhash(ctx, nextPath, first.Slot, last)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", nextPath, err)
+		}
+		if verifyErr != nil {
+			report.add("blockhash", boundaryCheckFailed, "slot %d does not chain from the blockhash %s of slot %d: %s", first.Slot, last.Blockhash, last.Slot, verifyErr)
+		} else {
+			report.add("blockhash", boundaryCheckOK, "")
+		}
+	}
+
+	if !prev.isCar() || !next.isCar() {
+		report.add("block-height", boundaryCheckSkipped, "block heights are not in manifests")
+	} else if heights, known := checkBoundaryHeights(prev, next); !known {
+		report.add("block-height", boundaryCheckSkipped, "the blocks at the boundary don't have a block height")
+	} else if len(heights) > 0 {
+		report.add("block-height", boundaryCheckFailed, "%s", joinDetails(heights))
+	} else {
+		report.add("block-height", boundaryCheckOK, "")
+	}
+
+	report.OK = report.failed() == 0
+	return report, nil
+}
+
+// maxBoundaryDetails caps the number of problems listed in the detail of a check.
+const maxBoundaryDetails = 10
+
+func joinDetails(details []string) string {
+	if len(details) > maxBoundaryDetails {
+		return strings.Join(details[:maxBoundaryDetails], "; ") + fmt.Sprintf("; and %d more", len(details)-maxBoundaryDetails)
+	}
+	return strings.Join(details, "; ")
+}
+
+// checkBoundarySlots checks that the slots of the epoch are ascending, without duplicates, and in the epoch.
+func checkBoundarySlots(epoch *boundaryEpoch) []string {
+	var problems []string
+	start, end := slottools.CalcEpochLimits(epoch.side.Epoch)
+	for i, block := range epoch.blocks {
+		if block.Slot < start || block.Slot > end {
+			problems = append(problems, fmt.Sprintf("slot %d is not in epoch %d", block.Slot, epoch.side.Epoch))
+		}
+		if i > 0 && block.Slot <= epoch.blocks[i-1].Slot {
+			problems = append(problems, fmt.Sprintf("slot %d comes after slot %d in epoch %d", block.Slot, epoch.blocks[i-1].Slot, epoch.side.Epoch))
+		}
+	}
+	return problems
+}
+
+// checkBoundaryHeights checks that the block height increases by one from block to block,
+// within the epochs and across the boundary; known is false if the blocks at the boundary don't have a height.
+func checkBoundaryHeights(prev, next *boundaryEpoch) (problems []string, known bool) {
+	last := prev.blocks[len(prev.blocks)-1]
+	first := next.blocks[0]
+	if last.Height == nil || first.Height == nil {
+		return nil, false
+	}
+	if *first.Height != *last.Height+1 {
+		problems = append(problems, fmt.Sprintf("slot %d has height %d, but slot %d has height %d", first.Slot, *first.Height, last.Slot, *last.Height))
+	}
+	for _, epoch := range []*boundaryEpoch{prev, next} {
+		for i := 1; i < len(epoch.blocks); i++ {
+			before, block := epoch.blocks[i-1], epoch.blocks[i]
+			if before.Height == nil || block.Height == nil {
+				continue
+			}
+			if *block.Height != *before.Height+1 {
+				problems = append(problems, fmt.Sprintf("slot %d has height %d, but slot %d has height %d", block.Slot, *block.Height, before.Slot, *before.Height))
+			}
+		}
+	}
+	return problems, true
+}
+
+// verifyBoundaryBlockhash verifies the entries of the block in the slot (the first block of the CAR)
+// chained from the blockhash of its parent; verifyErr is the verification failure, err a failure to read the block.
+func verifyBoundaryBlockhash(ctx context.Context, carPath string, slot uint64, parent boundaryBlock) (verifyErr error, err error) {
+	var v poh.Verifier
+	v.SetParent(parent.Slot, parent.Blockhash)
+	source := carwriter.NewCarSource(carPath)
+	defer source.Close()
+	found := false
+	err = source.Blocks(ctx, slot, slot, func(block *carwriter.Block) error {
+		found = true
+		entries, err := poh.BlockEntries(block)
+		if err != nil {
+			verifyErr = err
+			return nil
+		}
+		_, verifyErr = v.VerifyBlock(block.Slot, block.ParentSlot, entries)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("slot %d not found", slot)
+	}
+	return verifyErr, nil
+}
+
+// loadBoundaryEpoch reads the blocks of the epoch from a block manifest or a CAR.
+func loadBoundaryEpoch(ctx context.Context, path string) (*boundaryEpoch, error) {
+	isManifest, err := blockmanifest.IsManifestFile(path)
+	if err != nil {
+		return nil, err
+	}
+	epoch := &boundaryEpoch{side: epochBoundarySide{Path: path}}
+	if isManifest {
+		manifest, err := blockmanifest.FromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		epoch.side.Format = "manifest"
+		epoch.side.Epoch = manifest.Epoch()
+		for _, entry := range manifest.Entries() {
+			epoch.blocks = append(epoch.blocks, boundaryBlock{
+				Slot:       entry.Slot,
+				ParentSlot: entry.ParentSlot,
+				Blockhash:  entry.Blockhash,
+			})
+		}
+	} else {
+		epoch.side.Format = "car"
+		epoch.blocks, err = scanBoundaryBlocks(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		if len(epoch.blocks) > 0 {
+			epoch.side.Epoch = slottools.CalcEpochForSlot(epoch.blocks[0].Slot)
+		}
+	}
+	if len(epoch.blocks) == 0 {
+		return nil, fmt.Errorf("no blocks")
+	}
+	epoch.side.Blocks = len(epoch.blocks)
+	epoch.side.FirstSlot = epoch.blocks[0].Slot
+	epoch.side.LastSlot = epoch.blocks[len(epoch.blocks)-1].Slot
+	epoch.side.Blockhash = epoch.blocks[len(epoch.blocks)-1].Blockhash.String()
+	return epoch, nil
+}
+
+// scanBoundaryBlocks returns the blocks of the CAR, in the order they are written;
+// only the block and entry nodes are decoded.
+func scanBoundaryBlocks(ctx context.Context, carPath string) ([]boundaryBlock, error) {
+	file, err := os.Open(carPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rd, err := carreader.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CAR: %w", err)
+	}
+	var blocks []boundaryBlock
+	// the hashes of the entries written since the last block: they are the entries of the next block.
+	entryHashes := make(map[cid.Cid]solana.Hash)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		c, _, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch iplddecoders.Kind(data[1]) {
+		case iplddecoders.KindEntry:
+			entry, err := iplddecoders.DecodeEntry(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode entry %s: %w", c, err)
+			}
+			entryHashes[c] = solana.HashFromBytes(entry.Hash)
+		case iplddecoders.KindBlock:
+			node, err := iplddecoders.DecodeBlock(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode block %s: %w", c, err)
+			}
+			block := boundaryBlock{
+				Slot:       uint64(node.Slot),
+				ParentSlot: uint64(node.Meta.Parent_slot),
+			}
+			if height, ok := node.GetBlockHeight(); ok {
+				block.Height = &height
+			}
+			if len(node.Entries) > 0 {
+				lastEntryCid := node.Entries[len(node.Entries)-1].(cidlink.Link).Cid
+				hash, ok := entryHashes[lastEntryCid]
+				if !ok {
+					return nil, fmt.Errorf("block %d: last entry %s not found before the block", block.Slot, lastEntryCid)
+				}
+				block.Blockhash = hash
+			}
+			blocks = append(blocks, block)
+			clear(entryHashes)
+		}
+	}
+}
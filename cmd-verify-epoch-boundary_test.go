@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/blockmanifest"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/poh"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/stretchr/testify/require"
+)
+
+type boundaryTestBlock struct {
+	slot   uint64
+	height uint64
+}
+
+// writeBoundaryCar writes tick-only blocks whose entries are a valid PoH chain starting from parentHash;
+// it returns the blockhash of the last block.
+func writeBoundaryCar(t *testing.T, path string, parentSlot uint64, parentHash solana.Hash, blocks []boundaryTestBlock) solana.Hash {
+	w, err := carwriter.Create(path, slottools.CalcEpochForSlot(blocks[0].slot), carwriter.DefaultMaxSubsetBlocks)
+	require.NoError(t, err)
+	hash := parentHash
+	for _, b := range blocks {
+		block := &carwriter.Block{
+			Slot:        b.slot,
+			ParentSlot:  parentSlot,
+			BlockHeight: &b.height,
+		}
+		for i := 0; i < 2; i++ {
+			hash = poh.NextHash(hash, 4, nil)
+			block.Entries = append(block.Entries, carwriter.Entry{NumHashes: 4, Hash: hash})
+		}
+		_, err := w.WriteBlock(block)
+		require.NoError(t, err)
+		parentSlot = b.slot
+	}
+	_, err = w.Finish()
+	require.NoError(t, err)
+	return hash
+}
+
+func writeBoundaryManifest(t *testing.T, path string, carPath string) {
+	blocks, err := scanBoundaryBlocks(context.Background(), carPath)
+	require.NoError(t, err)
+	manifest := blockmanifest.New(slottools.CalcEpochForSlot(blocks[0].Slot))
+	for _, block := range blocks {
+		manifest.Add(blockmanifest.Entry{Slot: block.Slot, ParentSlot: block.ParentSlot, Blockhash: block.Blockhash})
+	}
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = manifest.WriteTo(file)
+	require.NoError(t, err)
+}
+
+func boundaryCheckStatuses(report *epochBoundaryReport) map[string]string {
+	statuses := make(map[string]string)
+	for _, check := range report.Checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+func TestVerifyEpochBoundary(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	genesis := solana.Hash{7}
+	prevCar := filepath.Join(dir, "epoch-0.car")
+	last := writeBoundaryCar(t, prevCar, 431996, genesis, []boundaryTestBlock{{431997, 100}, {431999, 101}})
+	nextCar := filepath.Join(dir, "epoch-1.car")
+	writeBoundaryCar(t, nextCar, 431999, last, []boundaryTestBlock{{432000, 102}, {432002, 103}})
+
+	t.Run("continuous", func(t *testing.T) {
+		report, err := verifyEpochBoundary(ctx, prevCar, nextCar)
+		require.NoError(t, err)
+		require.True(t, report.OK, report.Checks)
+		require.Equal(t, map[string]string{
+			"adjacent-epochs": boundaryCheckOK,
+			"slot-coverage":   boundaryCheckOK,
+			"parent-slot":     boundaryCheckOK,
+			"blockhash":       boundaryCheckOK,
+			"block-height":    boundaryCheckOK,
+		}, boundaryCheckStatuses(report))
+		require.Equal(t, uint64(0), report.Previous.Epoch)
+		require.Equal(t, uint64(431999), report.Previous.LastSlot)
+		require.Equal(t, last.String(), report.Previous.Blockhash)
+		require.Equal(t, uint64(1), report.Next.Epoch)
+		require.Equal(t, 2, report.Next.Blocks)
+	})
+	t.Run("manifests", func(t *testing.T) {
+		prevManifest := filepath.Join(dir, "epoch-0.manifest")
+		nextManifest := filepath.Join(dir, "epoch-1.manifest")
+		writeBoundaryManifest(t, prevManifest, prevCar)
+		writeBoundaryManifest(t, nextManifest, nextCar)
+
+		report, err := verifyEpochBoundary(ctx, prevManifest, nextManifest)
+		require.NoError(t, err)
+		require.True(t, report.OK, report.Checks)
+		require.Equal(t, "manifest", report.Previous.Format)
+		statuses := boundaryCheckStatuses(report)
+		require.Equal(t, boundaryCheckSkipped, statuses["blockhash"])
+		require.Equal(t, boundaryCheckSkipped, statuses["block-height"])
+
+		// The blockhash of the manifest is enough to verify the next CAR.
+		report, err = verifyEpochBoundary(ctx, prevManifest, nextCar)
+		require.NoError(t, err)
+		require.Equal(t, boundaryCheckOK, boundaryCheckStatuses(report)["blockhash"])
+	})
+	t.Run("wrong blockhash", func(t *testing.T) {
+		otherCar := filepath.Join(dir, "epoch-1-other.car")
+		writeBoundaryCar(t, otherCar, 431999, solana.Hash{8}, []boundaryTestBlock{{432000, 102}})
+		report, err := verifyEpochBoundary(ctx, prevCar, otherCar)
+		require.NoError(t, err)
+		require.False(t, report.OK)
+		statuses := boundaryCheckStatuses(report)
+		require.Equal(t, boundaryCheckFailed, statuses["blockhash"])
+		require.Equal(t, boundaryCheckOK, statuses["parent-slot"])
+	})
+	t.Run("wrong parent and height", func(t *testing.T) {
+		otherCar := filepath.Join(dir, "epoch-1-parent.car")
+		writeBoundaryCar(t, otherCar, 431998, last, []boundaryTestBlock{{432001, 105}})
+		report, err := verifyEpochBoundary(ctx, prevCar, otherCar)
+		require.NoError(t, err)
+		require.False(t, report.OK)
+		require.Equal(t, map[string]string{
+			"adjacent-epochs": boundaryCheckOK,
+			"slot-coverage":   boundaryCheckOK,
+			"parent-slot":     boundaryCheckFailed,
+			"blockhash":       boundaryCheckSkipped,
+			"block-height":    boundaryCheckFailed,
+		}, boundaryCheckStatuses(report))
+	})
+	t.Run("not adjacent", func(t *testing.T) {
+		report, err := verifyEpochBoundary(ctx, nextCar, prevCar)
+		require.NoError(t, err)
+		require.False(t, report.OK)
+		statuses := boundaryCheckStatuses(report)
+		require.Equal(t, boundaryCheckFailed, statuses["adjacent-epochs"])
+		require.Equal(t, boundaryCheckFailed, statuses["slot-coverage"])
+	})
+	t.Run("slot in the wrong epoch", func(t *testing.T) {
+		// the manifest of epoch 1 starts with a slot of epoch 0, that is also covered by the CAR of epoch 0.
+		manifest := blockmanifest.New(1)
+		manifest.Add(blockmanifest.Entry{Slot: 431999, ParentSlot: 431997})
+		manifest.Add(blockmanifest.Entry{Slot: 432000, ParentSlot: 431999})
+		path := filepath.Join(dir, "epoch-1-overlap.manifest")
+		file, err := os.Create(path)
+		require.NoError(t, err)
+		_, err = manifest.WriteTo(file)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		report, err := verifyEpochBoundary(ctx, prevCar, path)
+		require.NoError(t, err)
+		require.False(t, report.OK)
+		require.Equal(t, boundaryCheckFailed, boundaryCheckStatuses(report)["slot-coverage"])
+		require.Contains(t, report.Checks[1].Detail, "slot 431999 is not in epoch 1")
+		require.Contains(t, report.Checks[1].Detail, "the slots 431999-431999 are covered by both epochs")
+	})
+	t.Run("not a CAR", func(t *testing.T) {
+		empty := filepath.Join(dir, "empty")
+		require.NoError(t, os.WriteFile(empty, nil, 0o644))
+		_, err := verifyEpochBoundary(ctx, prevCar, empty)
+		require.Error(t, err)
+	})
+}
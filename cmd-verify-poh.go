@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	genesisblock "github.com/rpcpool/yellowstone-faithful/genesis-block"
+	"github.com/rpcpool/yellowstone-faithful/poh"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_VerifyPoh() *cli.Command {
+	var (
+		parentBlockhash string
+		genesisFile     string
+		from            uint64
+		to              uint64
+	)
+	return &cli.Command{
+		Name:  "verify-poh",
+		Usage: "Verify the proof-of-history hash chain of the blocks of a CAR file.",
+		Description: "Recomputes the hash of every entry from the previous one (the PoH ticks and the mixin of the transaction signatures) and checks that it is the recorded one, so that the blockhash of every block is verified. " +
+			"This catches corruption that CID checks can't, because it happened before the CAR was created. " +
+			"Each block is chained from the blockhash of its parent; the first entry of the first block is only verified if the blockhash of its parent is given (--parent-blockhash, or --genesis for slot 0).",
+		ArgsUsage: "<car>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "parent-blockhash",
+				Usage:       "Blockhash of the parent of the first block (e.g. the last block of the previous epoch)",
+				Destination: &parentBlockhash,
+			},
+			&cli.StringFlag{
+				Name:        "genesis",
+				Usage:       "Genesis file (genesis.tar.bz2); the genesis hash is the parent blockhash of slot 0",
+				Destination: &genesisFile,
+			},
+			&cli.Uint64Flag{
+				Name:        "from",
+				Usage:       "First slot to verify",
+				Destination: &from,
+			},
+			&cli.Uint64Flag{
+				Name:        "to",
+				Usage:       "Last slot to verify (default: the last slot of the CAR)",
+				Value:       math.MaxUint64,
+				Destination: &to,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() != 1 {
+				return cli.Exit("expected exactly one CAR file", 1)
+			}
+			var parent *solana.Hash
+			switch {
+			case parentBlockhash != "" && genesisFile != "":
+				return cli.Exit("--parent-blockhash and --genesis are mutually exclusive", 1)
+			case parentBlockhash != "":
+				hash, err := solana.HashFromBase58(parentBlockhash)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("invalid --parent-blockhash: %s", err), 1)
+				}
+				parent = &hash
+			case genesisFile != "":
+				constants, err := genesisblock.FromFile(genesisFile)
+				if err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+				parent = &constants.Hash
+			}
+
+			source := carwriter.NewCarSource(c.Args().First())
+			defer source.Close()
+			startedAt := time.Now()
+			stats, err := verifyPoh(c.Context, source, from, to, parent)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			klog.Infof(
+				"Verified %d blocks (slots %d-%d) in %s: %d entries, %d not chained from their parent, %d failed",
+				stats.blocks,
+				stats.firstSlot,
+				stats.lastSlot,
+				time.Since(startedAt).Truncate(time.Millisecond),
+				stats.entries,
+				stats.unchained,
+				len(stats.failed),
+			)
+			if len(stats.failed) > 0 {
+				return cli.Exit(fmt.Sprintf("%d blocks failed verification: first failure at slot %d", len(stats.failed), stats.failed[0]), 1)
+			}
+			return nil
+		},
+	}
+}
+
+type verifyPohStats struct {
+	blocks    int
+	entries   int
+	unchained int
+	firstSlot uint64
+	lastSlot  uint64
+	// failed are the slots of the blocks whose entries don't verify.
+	failed []uint64
+}
+
+// verifyPoh verifies the entries of the blocks of the source in [from, to]; parent is
+// the blockhash of the parent of the first block, if known. Blocks that don't verify
+// are logged and recorded in the stats; only a failure to read the blocks is an error.
+func verifyPoh(ctx context.Context, source carwriter.BlockSource, from, to uint64, parent *solana.Hash) (*verifyPohStats, error) {
+	stats := &verifyPohStats{}
+	var v poh.Verifier
+	err := source.Blocks(ctx, from, to, func(block *carwriter.Block) error {
+		if stats.blocks == 0 {
+			stats.firstSlot = block.Slot
+			if parent != nil {
+				v.SetParent(block.ParentSlot, *parent)
+			}
+		}
+		stats.blocks++
+		stats.lastSlot = block.Slot
+		stats.entries += len(block.Entries)
+
+		entries, err := poh.BlockEntries(block)
+		if err != nil {
+			return fmt.Errorf("slot %d: %w", block.Slot, err)
+		}
+		res, err := v.VerifyBlock(block.Slot, block.ParentSlot, entries)
+		if err != nil {
+			klog.Errorf("Slot %d failed verification: %s", block.Slot, err)
+			stats.failed = append(stats.failed, block.Slot)
+			return nil
+		}
+		if !res.Chained {
+			stats.unchained++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stats.blocks == 0 {
+		return nil, fmt.Errorf("no blocks in slot range %d-%d", from, to)
+	}
+	return stats, nil
+}
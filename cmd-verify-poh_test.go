@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPoh(t *testing.T) {
+	source := carwriter.NewCarSource("fixtures/epoch-0-1.car")
+	genesisHash := solana.MustHashFromBase58("5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d")
+
+	stats, err := verifyPoh(context.Background(), source, 0, math.MaxUint64, &genesisHash)
+	require.NoError(t, err)
+	require.Equal(t, 10, stats.blocks)
+	require.Zero(t, stats.unchained)
+	require.Empty(t, stats.failed)
+
+	stats, err = verifyPoh(context.Background(), source, 0, math.MaxUint64, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.unchained)
+
+	wrong := solana.Hash{1}
+	stats, err = verifyPoh(context.Background(), source, 0, math.MaxUint64, &wrong)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{stats.firstSlot}, stats.failed)
+	// the blocks after a failed one can't be chained.
+	require.Equal(t, 1, stats.unchained)
+
+	_, err = verifyPoh(context.Background(), source, math.MaxUint64-1, math.MaxUint64, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,619 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/libp2p/go-reuseport"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	hugecache "github.com/rpcpool/yellowstone-faithful/huge-cache"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/metrics"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	splitcarfetcher "github.com/rpcpool/yellowstone-faithful/split-car-fetcher"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/urfave/cli/v2"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"github.com/ybbus/jsonrpc/v3"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_Verify() *cli.Command {
+	var (
+		serve           bool
+		listenOn        string
+		samples         int
+		interval        time.Duration
+		concurrency     int
+		includePatterns cli.StringSlice
+		excludePatterns cli.StringSlice
+		lotusAPIAddress string
+	)
+	return &cli.Command{
+		Name:  "verify",
+		Usage: "Verify random slots of epochs: that their indexes agree with their CAR, and that their nodes decode.",
+		Description: "For random slots of each epoch: the block that slot-to-cid gives is the block of the slot, the nodes read from the CAR " +
+			"at the offsets of cid-to-offset-and-size match their CIDs (sha256) and decode, and a random transaction of the block " +
+			"(with its data frames and meta) decodes and is found by sig-to-cid, sig-exists and the status indexes. " +
+			"Without --serve, one round of --samples slots per epoch is verified, the results are printed as JSON, and the command fails if a check failed. " +
+			"With --serve, a round runs every --interval and the results are served as Prometheus metrics (/metrics), " +
+			"a health check that fails once a mismatch was found (/health) and JSON (/status), " +
+			"to catch the silent corruption of the disks of long-running archive nodes.",
+		ArgsUsage: "<one or more config files or directories containing config files (nested is fine)>",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "serve",
+				Usage:       "Keep verifying every --interval, and serve the results on --listen",
+				Destination: &serve,
+			},
+			&cli.StringFlag{
+				Name:        "listen",
+				Usage:       "Listen address of --serve",
+				Value:       ":8891",
+				Destination: &listenOn,
+			},
+			&cli.IntFlag{
+				Name:        "samples",
+				Usage:       "How many random slots to verify per epoch per round",
+				Value:       10,
+				Destination: &samples,
+			},
+			&cli.DurationFlag{
+				Name:        "interval",
+				Usage:       "Time between the rounds of --serve",
+				Value:       time.Minute,
+				Destination: &interval,
+			},
+			&cli.IntFlag{
+				Name:        "concurrency",
+				Usage:       "How many epochs to verify in parallel",
+				Value:       runtime.NumCPU(),
+				Destination: &concurrency,
+			},
+			&cli.StringSliceFlag{
+				Name:        "include",
+				Usage:       "Include files or dirs matching the given glob patterns",
+				Value:       cli.NewStringSlice(),
+				Destination: &includePatterns,
+			},
+			&cli.StringSliceFlag{
+				Name:        "exclude",
+				Usage:       "Exclude files or dirs matching the given glob patterns",
+				Value:       cli.NewStringSlice(".git"),
+				Destination: &excludePatterns,
+			},
+			&cli.StringFlag{
+				Name:        "filecoin-api-address",
+				Usage:       "Address of the filecoin API to find provider info",
+				Value:       defaultLotusAPIAddress,
+				Destination: &lotusAPIAddress,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if samples < 1 {
+				return cli.Exit("--samples must be at least 1", 1)
+			}
+			configFiles, err := GetListOfConfigFiles(c.Args().Slice(), includePatterns.Value(), excludePatterns.Value())
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			configs := make(ConfigSlice, 0, len(configFiles))
+			for _, configFile := range configFiles {
+				config, err := LoadConfig(configFile)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("failed to load config file %q: %s", configFile, err.Error()), 1)
+				}
+				configs = append(configs, config)
+			}
+			if err := configs.Validate(); err != nil {
+				return cli.Exit(fmt.Sprintf("error validating configs: %s", err.Error()), 1)
+			}
+			if len(configs) == 0 {
+				return cli.Exit("no epoch configs found", 1)
+			}
+			configs.SortByEpoch()
+
+			conf := bigcache.DefaultConfig(5 * time.Minute)
+			conf.MaxEntriesInWindow = 1000 // the verifier reads around the caches
+			allCache, err := hugecache.NewWithConfig(c.Context, conf)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("failed to create cache: %s", err), 1)
+			}
+			minerInfo := splitcarfetcher.NewMinerInfo(jsonrpc.NewClient(lotusAPIAddress), 24*time.Hour, 5*time.Second)
+
+			verifiers := make([]*epochVerifier, 0, len(configs))
+			defer func() {
+				for _, v := range verifiers {
+					v.epoch.Close()
+				}
+			}()
+			for _, config := range configs {
+				epoch, err := NewEpochFromConfig(config, c, allCache, minerInfo)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("failed to open the epoch of config %q: %s", config.ConfigFilepath(), err), 1)
+				}
+				verifiers = append(verifiers, newEpochVerifier(epoch))
+			}
+
+			if !serve {
+				runVerifyRound(c.Context, verifiers, samples, concurrency)
+				statuses := verifyStatuses(verifiers)
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(statuses); err != nil {
+					return cli.Exit(fmt.Sprintf("failed to write the results: %s", err), 1)
+				}
+				var mismatches, errors uint64
+				for _, status := range statuses {
+					mismatches += status.Mismatches
+					errors += status.Errors
+				}
+				if mismatches > 0 || errors > 0 {
+					return cli.Exit(fmt.Sprintf("verification failed: %d mismatches, %d read errors", mismatches, errors), 1)
+				}
+				return nil
+			}
+
+			go func() {
+				for {
+					runVerifyRound(c.Context, verifiers, samples, concurrency)
+					select {
+					case <-c.Context.Done():
+						return
+					case <-time.After(interval):
+					}
+				}
+			}()
+			return listenAndServeVerifier(c.Context, listenOn, verifiers)
+		},
+	}
+}
+
+// runVerifyRound verifies random slots of every epoch.
+func runVerifyRound(ctx context.Context, verifiers []*epochVerifier, samples int, concurrency int) {
+	startedAt := time.Now()
+	wg := new(errgroup.Group)
+	wg.SetLimit(max(concurrency, 1))
+	for _, v := range verifiers {
+		v := v
+		wg.Go(func() error {
+			v.verifyRandomSlots(ctx, samples)
+			return nil
+		})
+	}
+	wg.Wait()
+	klog.Infof("Verified %d slots of each of %d epochs in %s", samples, len(verifiers), time.Since(startedAt))
+}
+
+func verifyStatuses(verifiers []*epochVerifier) []EpochVerifyStatus {
+	statuses := make([]EpochVerifyStatus, 0, len(verifiers))
+	for _, v := range verifiers {
+		statuses = append(statuses, v.snapshot())
+	}
+	return statuses
+}
+
+func listenAndServeVerifier(ctx context.Context, listenOn string, verifiers []*epochVerifier) error {
+	srv := &fasthttp.Server{
+		Handler: newVerifierHandler(verifiers),
+	}
+	go func() {
+		<-ctx.Done()
+		klog.Info("Verifier shutting down...")
+		if err := srv.ShutdownWithContext(ctx); err != nil {
+			klog.Errorf("Error while shutting down verifier: %s", err)
+		}
+	}()
+	ln, err := reuseport.Listen("tcp4", listenOn)
+	if err != nil {
+		return fmt.Errorf("error in reuseport listener: %w", err)
+	}
+	klog.Infof("Verifier listening on %s", listenOn)
+	return srv.Serve(ln)
+}
+
+// newVerifierHandler serves the metrics (/metrics), the health (/health) and the results (/status)
+// of the verifiers.
+func newVerifierHandler(verifiers []*epochVerifier) fasthttp.RequestHandler {
+	metricsHandler := fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())
+	return func(reqCtx *fasthttp.RequestCtx) {
+		switch string(reqCtx.Path()) {
+		case "/metrics":
+			metricsHandler(reqCtx)
+		case "/health":
+			var unhealthy []string
+			for _, status := range verifyStatuses(verifiers) {
+				if status.Mismatches > 0 {
+					unhealthy = append(unhealthy, fmt.Sprintf("epoch %d (%d mismatches)", status.Epoch, status.Mismatches))
+				}
+			}
+			if len(unhealthy) > 0 {
+				reqCtx.SetStatusCode(http.StatusServiceUnavailable)
+				reqCtx.SetBodyString("mismatches found in " + strings.Join(unhealthy, ", "))
+				return
+			}
+			reqCtx.SetStatusCode(http.StatusOK)
+			reqCtx.SetBodyString("ok")
+		case "/status":
+			body, err := json.Marshal(verifyStatuses(verifiers))
+			if err != nil {
+				reqCtx.Error(err.Error(), http.StatusInternalServerError)
+				return
+			}
+			reqCtx.SetContentType("application/json")
+			reqCtx.SetBody(body)
+		default:
+			reqCtx.Error("not found", http.StatusNotFound)
+		}
+	}
+}
+
+// The outcomes of a check.
+const (
+	verifyOk = "ok"
+	// verifyMismatch is data that is wrong: a node that doesn't match its CID or doesn't decode,
+	// or an index that disagrees with the CAR.
+	verifyMismatch = "mismatch"
+	// verifyError is data that couldn't be read, e.g. from an unreachable remote file.
+	verifyError = "error"
+)
+
+// maxVerifyFailures is how many of the last failures of an epoch are kept for /status.
+const maxVerifyFailures = 20
+
+// maxVerifyEntries is how many entries of a block are read to find a transaction to verify.
+const maxVerifyEntries = 8
+
+// maxVerifyTries is how many random slots are tried for a sample before giving up on
+// finding one with a block.
+const maxVerifyTries = 10
+
+type verifyResult struct {
+	check   string
+	outcome string
+	err     error
+}
+
+type verifyResults []verifyResult
+
+func (r *verifyResults) add(check string, outcome string, err error) {
+	*r = append(*r, verifyResult{check: check, outcome: outcome, err: err})
+}
+
+// VerifyFailure is a failed check of a sampled slot.
+type VerifyFailure struct {
+	Time    time.Time `json:"time"`
+	Slot    uint64    `json:"slot"`
+	Check   string    `json:"check"`
+	Outcome string    `json:"outcome"`
+	Error   string    `json:"error"`
+}
+
+// EpochVerifyStatus is the results of the checks of an epoch since the verifier started.
+type EpochVerifyStatus struct {
+	Epoch   uint64 `json:"epoch"`
+	Network string `json:"network"`
+	Config  string `json:"config"`
+	// SampledSlots is how many slots were verified; SkippedSlots is how many of the
+	// random slots had no block (skipped by the leader, or outside of the CAR).
+	SampledSlots uint64 `json:"sampledSlots"`
+	SkippedSlots uint64 `json:"skippedSlots"`
+	Checks       uint64 `json:"checks"`
+	Mismatches   uint64 `json:"mismatches"`
+	Errors       uint64 `json:"errors"`
+	// LastFailures are the most recent failed checks, oldest first.
+	LastFailures []VerifyFailure `json:"lastFailures,omitempty"`
+}
+
+// epochVerifier verifies random slots of an epoch, reading the CAR around the node caches.
+type epochVerifier struct {
+	epoch      *Epoch
+	epochLabel string
+
+	mu     sync.Mutex
+	status EpochVerifyStatus
+}
+
+func newEpochVerifier(epoch *Epoch) *epochVerifier {
+	config := epoch.Config()
+	v := &epochVerifier{
+		epoch:      epoch,
+		epochLabel: strconv.FormatUint(epoch.Epoch(), 10),
+		status: EpochVerifyStatus{
+			Epoch:   epoch.Epoch(),
+			Network: string(config.Network),
+			Config:  config.ConfigFilepath(),
+		},
+	}
+	metrics.VerifyEpochHealthy.WithLabelValues(v.status.Network, v.epochLabel).Set(1)
+	return v
+}
+
+func (v *epochVerifier) snapshot() EpochVerifyStatus {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	status := v.status
+	status.LastFailures = append([]VerifyFailure(nil), v.status.LastFailures...)
+	return status
+}
+
+// verifyRandomSlots verifies n random slots of the epoch that have a block.
+func (v *epochVerifier) verifyRandomSlots(ctx context.Context, n int) {
+	first, last := slottools.CalcEpochLimits(v.epoch.Epoch())
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		for try := 0; try < maxVerifyTries && ctx.Err() == nil; try++ {
+			slot := first + uint64(rand.Int63n(int64(last-first+1)))
+			results, skipped := v.verifySlot(ctx, slot)
+			v.record(slot, results, skipped)
+			if !skipped {
+				break
+			}
+		}
+	}
+}
+
+func (v *epochVerifier) record(slot uint64, results verifyResults, skipped bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.status.SampledSlots++
+	if skipped {
+		v.status.SkippedSlots++
+	}
+	for _, result := range results {
+		v.status.Checks++
+		metrics.VerifyChecks.WithLabelValues(v.status.Network, v.epochLabel, result.check, result.outcome).Inc()
+		switch result.outcome {
+		case verifyOk:
+			continue
+		case verifyMismatch:
+			v.status.Mismatches++
+			metrics.VerifyEpochHealthy.WithLabelValues(v.status.Network, v.epochLabel).Set(0)
+			klog.Errorf("epoch %d, slot %d: %s mismatch: %s", v.status.Epoch, slot, result.check, result.err)
+		case verifyError:
+			v.status.Errors++
+			klog.Warningf("epoch %d, slot %d: failed to verify %s: %s", v.status.Epoch, slot, result.check, result.err)
+		}
+		v.status.LastFailures = append(v.status.LastFailures, VerifyFailure{
+			Time:    time.Now(),
+			Slot:    slot,
+			Check:   result.check,
+			Outcome: result.outcome,
+			Error:   result.err.Error(),
+		})
+		if len(v.status.LastFailures) > maxVerifyFailures {
+			v.status.LastFailures = v.status.LastFailures[len(v.status.LastFailures)-maxVerifyFailures:]
+		}
+	}
+}
+
+// verifySlot verifies the block of the slot and a random transaction of it;
+// skipped is whether the slot has no block.
+func (v *epochVerifier) verifySlot(ctx context.Context, slot uint64) (results verifyResults, skipped bool) {
+	blockCid, err := v.epoch.FindCidFromSlot(ctx, slot)
+	if err != nil {
+		if compactindexsized.IsNotFound(err) {
+			return nil, true
+		}
+		results.add("slot_to_cid", verifyError, err)
+		return results, false
+	}
+	block, ok := verifyNode(ctx, v, &results, blockCid, iplddecoders.DecodeBlock)
+	if !ok {
+		return results, false
+	}
+	if uint64(block.Slot) != slot {
+		results.add("slot_to_cid", verifyMismatch, fmt.Errorf("slot %d maps to block %s of slot %d", slot, blockCid, block.Slot))
+		return results, false
+	}
+	results.add("slot_to_cid", verifyOk, nil)
+
+	// The first transaction found in (at most maxVerifyEntries) random entries of the block.
+	entries := rand.Perm(len(block.Entries))
+	if len(entries) > maxVerifyEntries {
+		entries = entries[:maxVerifyEntries]
+	}
+	for _, i := range entries {
+		entryCid := block.Entries[i].(cidlink.Link).Cid
+		entry, ok := verifyNode(ctx, v, &results, entryCid, iplddecoders.DecodeEntry)
+		if !ok {
+			return results, false
+		}
+		if len(entry.Transactions) == 0 {
+			continue
+		}
+		txCid := entry.Transactions[rand.Intn(len(entry.Transactions))].(cidlink.Link).Cid
+		v.verifyTransaction(ctx, &results, slot, txCid)
+		break
+	}
+	return results, false
+}
+
+func (v *epochVerifier) verifyTransaction(ctx context.Context, results *verifyResults, slot uint64, txCid cid.Cid) {
+	txNode, ok := verifyNode(ctx, v, results, txCid, iplddecoders.DecodeTransaction)
+	if !ok {
+		return
+	}
+	if uint64(txNode.Slot) != slot {
+		results.add("transaction", verifyMismatch, fmt.Errorf("transaction %s of the block of slot %d has slot %d", txCid, slot, txNode.Slot))
+		return
+	}
+	// The data frames of large transactions and metas are verified like the other nodes,
+	// and reported by verifyNode.
+	var frameFailed bool
+	getDataFrame := func(ctx context.Context, c cid.Cid) (*ipldbindcode.DataFrame, error) {
+		frame, ok := verifyNode(ctx, v, results, c, iplddecoders.DecodeDataFrame)
+		if !ok {
+			frameFailed = true
+			return nil, fmt.Errorf("data frame %s failed verification", c)
+		}
+		return frame, nil
+	}
+	tx, err := decodeTransactionFromNode(txNode, getDataFrame)
+	if frameFailed {
+		return
+	}
+	if err != nil || len(tx.Signatures) == 0 {
+		results.add("transaction", verifyMismatch, fmt.Errorf("transaction %s doesn't decode: %v", txCid, err))
+		return
+	}
+	var meta *solanatxmetaparsers.TransactionStatusMetaContainer
+	metaChunks, err := tooling.LoadChunksFromDataFrames(&txNode.Metadata, getDataFrame)
+	if frameFailed {
+		return
+	}
+	if err == nil && len(metaChunks) > 0 {
+		var uncompressed []byte
+		uncompressed, err = tooling.DecompressZstdChunks(metaChunks)
+		if err == nil {
+			meta, err = solanatxmetaparsers.ParseTransactionStatusMetaContainer(uncompressed)
+		}
+	}
+	if err != nil {
+		results.add("transaction", verifyMismatch, fmt.Errorf("meta of transaction %s doesn't decode: %w", txCid, err))
+		return
+	}
+	results.add("transaction", verifyOk, nil)
+
+	v.verifySignature(ctx, results, slot, txCid, tx.Signatures[0], meta)
+}
+
+// verifySignature checks that the signature indexes agree with the transaction.
+func (v *epochVerifier) verifySignature(
+	ctx context.Context,
+	results *verifyResults,
+	slot uint64,
+	txCid cid.Cid,
+	sig solana.Signature,
+	meta *solanatxmetaparsers.TransactionStatusMetaContainer,
+) {
+	gotCid, err := v.epoch.FindCidFromSignature(ctx, sig)
+	switch {
+	case compactindexsized.IsNotFound(err):
+		results.add("sig_to_cid", verifyMismatch, fmt.Errorf("no entry for signature %s of transaction %s", sig, txCid))
+	case err != nil:
+		results.add("sig_to_cid", verifyError, err)
+	case !gotCid.Equals(txCid):
+		results.add("sig_to_cid", verifyMismatch, fmt.Errorf("signature %s maps to %s instead of transaction %s", sig, gotCid, txCid))
+	default:
+		results.add("sig_to_cid", verifyOk, nil)
+	}
+
+	if v.epoch.sigExists != nil {
+		has, err := v.epoch.sigExists.Has(sig)
+		switch {
+		case err != nil:
+			results.add("sig_exists", verifyError, err)
+		case !has:
+			results.add("sig_exists", verifyMismatch, fmt.Errorf("signature %s of transaction %s is not in the index", sig, txCid))
+		default:
+			results.add("sig_exists", verifyOk, nil)
+		}
+	}
+
+	status, known, err := v.epoch.FindSigStatus(ctx, sig)
+	switch {
+	case compactindexsized.IsNotFound(err):
+		results.add("sig_status", verifyMismatch, fmt.Errorf("no status for signature %s of transaction %s", sig, txCid))
+	case err != nil:
+		results.add("sig_status", verifyError, err)
+	case !known:
+		// no status index
+	case status.Slot != slot:
+		results.add("sig_status", verifyMismatch, fmt.Errorf("signature %s has slot %d instead of %d", sig, status.Slot, slot))
+	case status.MetaMissing() != (meta == nil):
+		results.add("sig_status", verifyMismatch, fmt.Errorf("signature %s: the index says meta missing=%t, the CAR says %t", sig, status.MetaMissing(), meta == nil))
+	case meta != nil && status.Failed() != meta.Failed():
+		results.add("sig_status", verifyMismatch, fmt.Errorf("signature %s: the index says failed=%t, the meta says %t", sig, status.Failed(), meta.Failed()))
+	default:
+		results.add("sig_status", verifyOk, nil)
+	}
+}
+
+// verifyNode reads the node of the CID, checks that it is the node of the CID and decodes it.
+func verifyNode[T any](
+	ctx context.Context,
+	v *epochVerifier,
+	results *verifyResults,
+	c cid.Cid,
+	decode func([]byte) (T, error),
+) (T, bool) {
+	var zero T
+	raw, ok := v.readNode(ctx, results, c)
+	if !ok {
+		return zero, false
+	}
+	if err := iplddecoders.CheckCid(c, raw, iplddecoders.StrictHash); err != nil {
+		results.add("hash", verifyMismatch, err)
+		return zero, false
+	}
+	results.add("hash", verifyOk, nil)
+	node, err := decode(raw)
+	if err != nil {
+		results.add("decode", verifyMismatch, fmt.Errorf("node %s doesn't decode: %w", c, err))
+		return zero, false
+	}
+	results.add("decode", verifyOk, nil)
+	return node, true
+}
+
+// readNode reads the raw node of the CID from the CAR, without the node cache, so that
+// what is verified is what is on disk.
+func (v *epochVerifier) readNode(ctx context.Context, results *verifyResults, c cid.Cid) ([]byte, bool) {
+	if v.epoch.IsFilecoinMode() {
+		raw, err := v.epoch.GetNodeByCid(ctx, c)
+		if err != nil {
+			results.add("car", verifyError, err)
+			return nil, false
+		}
+		return raw, true
+	}
+	if v.epoch.overlay != nil {
+		raw, ok, err := v.epoch.overlay.getNode(c)
+		if err != nil {
+			results.add("car", verifyError, err)
+			return nil, false
+		}
+		if ok {
+			return raw, true
+		}
+	}
+	oas, err := v.epoch.FindOffsetAndSizeFromCid(ctx, c)
+	if err != nil {
+		if compactindexsized.IsNotFound(err) {
+			results.add("cid_to_offset_and_size", verifyMismatch, fmt.Errorf("no entry for node %s", c))
+		} else {
+			results.add("cid_to_offset_and_size", verifyError, err)
+		}
+		return nil, false
+	}
+	section, err := v.epoch.ReadAtFromCar(ctx, oas.Offset, oas.Size)
+	if err != nil {
+		results.add("car", verifyError, err)
+		return nil, false
+	}
+	raw, err := parseNodeFromSection(section, &c)
+	if err != nil {
+		results.add("cid_to_offset_and_size", verifyMismatch, fmt.Errorf("node %s is not at offset %d (size %d): %w", c, oas.Offset, oas.Size, err))
+		return nil, false
+	}
+	results.add("cid_to_offset_and_size", verifyOk, nil)
+	return raw, true
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestVerifySlots(t *testing.T) {
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+	paths, _, _, err := buildTestEpochIndexes(carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+
+	getHealth := func(t *testing.T, v *epochVerifier) (int, []EpochVerifyStatus) {
+		handler := newVerifierHandler([]*epochVerifier{v})
+		var req fasthttp.Request
+		req.SetRequestURI("/health")
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		handler(&reqCtx)
+		code := reqCtx.Response.StatusCode()
+
+		req.SetRequestURI("/status")
+		reqCtx.Init(&req, nil, nil)
+		handler(&reqCtx)
+		var statuses []EpochVerifyStatus
+		require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &statuses), "%s", reqCtx.Response.Body())
+		return code, statuses
+	}
+
+	healthy := newEpochVerifier(openTestEpoch(t, testEpochConfig(t, carPath, paths)))
+	for slot := uint64(0); slot <= 5; slot++ {
+		results, skipped := healthy.verifySlot(context.Background(), slot)
+		healthy.record(slot, results, skipped)
+		require.Equal(t, slot == 3, skipped, "slot %d", slot)
+		checks := make(map[string]bool)
+		for _, result := range results {
+			require.Equal(t, verifyOk, result.outcome, "slot %d: %s: %v", slot, result.check, result.err)
+			checks[result.check] = true
+		}
+		if slot == 4 {
+			// the transaction of the slot, and its signature.
+			for _, check := range []string{"slot_to_cid", "cid_to_offset_and_size", "hash", "decode", "transaction", "sig_to_cid", "sig_exists"} {
+				require.True(t, checks[check], "check %s", check)
+			}
+		}
+	}
+	code, statuses := getHealth(t, healthy)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, statuses, 1)
+	require.Equal(t, uint64(6), statuses[0].SampledSlots)
+	require.Equal(t, uint64(1), statuses[0].SkippedSlots)
+	require.Zero(t, statuses[0].Mismatches)
+	require.Zero(t, statuses[0].Errors)
+	require.NotZero(t, statuses[0].Checks)
+
+	// A flipped bit in each transaction of slot 4, in a copy of the CAR that the same indexes point into.
+	car, err := os.ReadFile(carPath)
+	require.NoError(t, err)
+	var numCorrupted int
+	for _, tx := range fixture.Transactions {
+		if tx.Slot == 4 {
+			at := bytes.Index(car, tx.Signature[:])
+			require.Positive(t, at)
+			car[at] ^= 1
+			numCorrupted++
+		}
+	}
+	require.NotZero(t, numCorrupted)
+	corruptedCarPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	require.NoError(t, os.WriteFile(corruptedCarPath, car, 0o644))
+
+	corrupted := newEpochVerifier(openTestEpoch(t, testEpochConfig(t, corruptedCarPath, paths)))
+	results, skipped := corrupted.verifySlot(context.Background(), 4)
+	require.False(t, skipped)
+	corrupted.record(4, results, skipped)
+	code, statuses = getHealth(t, corrupted)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, uint64(1), statuses[0].Mismatches)
+	require.Len(t, statuses[0].LastFailures, 1)
+	require.Equal(t, "hash", statuses[0].LastFailures[0].Check)
+	require.Equal(t, verifyMismatch, statuses[0].LastFailures[0].Outcome)
+}
@@ -27,6 +27,7 @@ import (
 
 func newCmd_Index_all() *cli.Command {
 	var verify bool
+	var v2 bool
 	var network indexes.Network
 	return &cli.Command{
 		Name:        "all",
@@ -45,6 +46,11 @@ func newCmd_Index_all() *cli.Command {
 				Usage:       "verify the indexes after creating them",
 				Destination: &verify,
 			},
+			&cli.BoolFlag{
+				Name:        "v2",
+				Usage:       "create the v2 sig_to_cid index, which also stores the slot of each transaction and whether it failed",
+				Destination: &v2,
+			},
 			&cli.StringFlag{
 				Name:  "tmp-dir",
 				Usage: "temporary directory to use for storing intermediate files",
@@ -94,6 +100,7 @@ func newCmd_Index_all() *cli.Command {
 					tmpDir,
 					carPath,
 					indexDir,
+					v2,
 				)
 				if err != nil {
 					return err
@@ -121,6 +128,7 @@ func createAllIndexes(
 	tmpDir string,
 	carPath string,
 	indexDir string,
+	sigToCidV2 bool,
 ) (*IndexPaths, uint64, error) {
 	// Check if the CAR file exists:
 	exists, err := fileExists(carPath)
@@ -137,7 +145,9 @@ func createAllIndexes(
 	}
 	defer carFile.Close()
 
-	rd, err := carreader.New(carFile)
+	// Hash the CAR while indexing it, to record it in the provenance of the indexes.
+	hashingCarFile := newHashingReadCloser(carFile)
+	rd, err := carreader.New(hashingCarFile)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to create car reader: %w", err)
 	}
@@ -211,11 +221,13 @@ func createAllIndexes(
 		network,
 		tmpDir,
 		numItems[byte(iplddecoders.KindTransaction)],
+		sigToCidV2,
 	)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to create sig_to_cid index: %w", err)
 	}
 	defer sig_to_cid.Close()
+	statuses := newSigStatusTracker()
 
 	sigExistsFilepath := formatSigExistsIndexFilePath(indexDir, epoch, rootCID, network)
 	sig_exists, err := bucketteer.NewWriter(
@@ -293,7 +305,11 @@ func createAllIndexes(
 					return nil, 0, fmt.Errorf("failed to read signature: %w", err)
 				}
 
-				err = sig_to_cid.Put(sig, _cid)
+				if sigToCidV2 {
+					err = sig_to_cid.PutWithStatus(sig, _cid, statuses.status(txNode))
+				} else {
+					err = sig_to_cid.Put(sig, _cid)
+				}
 				if err != nil {
 					return nil, 0, fmt.Errorf("failed to index signature to cid: %w", err)
 				}
@@ -302,6 +318,14 @@ func createAllIndexes(
 
 				numIndexedTransactions++
 			}
+		case iplddecoders.KindDataFrame:
+			if sigToCidV2 {
+				frame, err := iplddecoders.DecodeDataFrame(block.RawData())
+				if err != nil {
+					return nil, 0, fmt.Errorf("failed to decode data frame: %w", err)
+				}
+				statuses.addDataFrame(_cid, frame)
+			}
 		}
 
 		totalOffset += sectionLength
@@ -345,6 +369,18 @@ func createAllIndexes(
 
 	klog.Infof("Preparing to seal indexes (DO NOT EXIT)...")
 
+	provenance := newIndexProvenance(hashingCarFile.Sum())
+	klog.Infof("CAR file SHA256: %x", provenance.CarSha256)
+	if err := cid_to_offset_and_size.SetProvenance(provenance); err != nil {
+		return nil, 0, fmt.Errorf("failed to set cid_to_offset_and_size index provenance: %w", err)
+	}
+	if err := slot_to_cid.SetProvenance(provenance); err != nil {
+		return nil, 0, fmt.Errorf("failed to set slot_to_cid index provenance: %w", err)
+	}
+	if err := sig_to_cid.SetProvenance(provenance); err != nil {
+		return nil, 0, fmt.Errorf("failed to set sig_to_cid index provenance: %w", err)
+	}
+
 	paths := &IndexPaths{}
 	paths.SignatureExists = sigExistsFilepath
 
@@ -397,6 +433,9 @@ func createAllIndexes(
 			if err := meta.AddString(indexmeta.MetadataKey_Network, string(network)); err != nil {
 				return fmt.Errorf("failed to add network to sig_exists index metadata: %w", err)
 			}
+			if err := provenance.AddTo(&meta); err != nil {
+				return fmt.Errorf("failed to add provenance to sig_exists index metadata: %w", err)
+			}
 			if _, err = sig_exists.Seal(meta); err != nil {
 				return fmt.Errorf("failed to seal sig_exists index: %w", err)
 			}
@@ -501,12 +540,17 @@ func NewBuilder_SignatureToCid(
 	network indexes.Network,
 	tmpDir string,
 	numItems uint64,
+	withStatus bool,
 ) (*indexes.SigToCid_Writer, error) {
 	tmpDir = filepath.Join(tmpDir, "index-sig-to-cid-"+time.Now().Format("20060102-150405.000000000")+fmt.Sprintf("-%d", rand.Int63()))
 	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
 		return nil, fmt.Errorf("failed to create sig_to_cid tmp dir: %w", err)
 	}
-	index, err := indexes.NewWriter_SigToCid(
+	newWriter := indexes.NewWriter_SigToCid
+	if withStatus {
+		newWriter = indexes.NewWriter_SigToCidV2
+	}
+	index, err := newWriter(
 		epoch,
 		rootCid,
 		network,
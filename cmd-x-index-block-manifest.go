@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car"
+	carv2 "github.com/ipld/go-car/v2"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/rpcpool/yellowstone-faithful/blockmanifest"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_Index_blockManifest() *cli.Command {
+	var epoch uint64
+	var network indexes.Network
+	return &cli.Command{
+		Name:        "block-manifest",
+		Description: "Given a CAR file containing a Solana epoch, create a manifest of all its blocks: slot, block CID, parent CID, and blockhash.",
+		ArgsUsage:   "<car-path> <index-dir>",
+		Before: func(c *cli.Context) error {
+			if network == "" {
+				network = indexes.NetworkMainnet
+			}
+			return nil
+		},
+		Flags: []cli.Flag{
+			&cli.Uint64Flag{
+				Name:        "epoch",
+				Usage:       "the epoch of the CAR file",
+				Destination: &epoch,
+				Required:    true,
+			},
+			&cli.StringFlag{
+				Name:  "network",
+				Usage: "the cluster of the epoch; one of: mainnet, testnet, devnet",
+				Action: func(c *cli.Context, s string) error {
+					network = indexes.Network(s)
+					if !indexes.IsValidNetwork(network) {
+						return fmt.Errorf("invalid network: %q", network)
+					}
+					return nil
+				},
+			},
+		},
+		Subcommands: []*cli.Command{},
+		Action: func(c *cli.Context) error {
+			carPath := c.Args().Get(0)
+			indexDir := c.Args().Get(1)
+
+			startedAt := time.Now()
+			defer func() {
+				klog.Infof("Finished in %s", time.Since(startedAt))
+			}()
+			klog.Infof("Creating block manifest for %s", carPath)
+			indexFilepath, err := CreateIndex_blockManifest(
+				c.Context,
+				epoch,
+				network,
+				carPath,
+				indexDir,
+			)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			klog.Info("block manifest created at ", indexFilepath)
+			return nil
+		},
+	}
+}
+
+// CreateIndex_blockManifest creates a manifest file with the slot, CID, parent CID and blockhash of every block in the CAR file.
+func CreateIndex_blockManifest(
+	ctx context.Context,
+	epoch uint64,
+	network indexes.Network,
+	carPath string,
+	indexDir string,
+) (string, error) {
+	// Check if the CAR file exists:
+	exists, err := fileExists(carPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if CAR file exists: %w", err)
+	}
+	if !exists {
+		return "", fmt.Errorf("CAR file %q does not exist", carPath)
+	}
+
+	cr, err := carv2.OpenReader(carPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open CAR file: %w", err)
+	}
+	defer cr.Close()
+
+	// check it has 1 root
+	roots, err := cr.Roots()
+	if err != nil {
+		return "", fmt.Errorf("failed to get roots: %w", err)
+	}
+	// There should be only one root CID in the CAR file.
+	if len(roots) != 1 {
+		return "", fmt.Errorf("CAR file has %d roots, expected 1", len(roots))
+	}
+	rootCid := roots[0]
+
+	dr, err := cr.DataReader()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data reader: %w", err)
+	}
+
+	klog.Infof("Indexing...")
+	manifest, err := buildBlockManifest(ctx, epoch, dr)
+	if err != nil {
+		return "", fmt.Errorf("failed to index: %w", err)
+	}
+
+	indexFilePath := filepath.Join(indexDir, blockmanifest.FormatFilename(epoch, rootCid, network))
+	file, err := os.Create(indexFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create block manifest file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := manifest.WriteTo(file); err != nil {
+		return "", fmt.Errorf("failed to write block manifest: %w", err)
+	}
+	klog.Infof("Index created at %s; %d blocks indexed", indexFilePath, manifest.Len())
+	return indexFilePath, nil
+}
+
+// buildBlockManifest reads all the objects in the CAR in one pass.
+// It relies on the CAR layout: the entries of a block are written before the block itself,
+// and blocks are written in slot order, so the parent of a block (if in the same epoch) was already seen.
+func buildBlockManifest(ctx context.Context, epoch uint64, sectionReader carv2.SectionReader) (*blockmanifest.Manifest, error) {
+	rd, err := car.NewCarReader(sectionReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create car reader: %w", err)
+	}
+	manifest := blockmanifest.New(epoch)
+	entryHashes := make(map[cid.Cid]solana.Hash)
+	slotToCid := make(map[uint64]cid.Cid)
+	for {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		block, err := rd.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		switch iplddecoders.Kind(block.RawData()[1]) {
+		case iplddecoders.KindEntry:
+			entry, err := iplddecoders.DecodeEntry(block.RawData())
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode entry %s: %w", block.Cid(), err)
+			}
+			entryHashes[block.Cid()] = solana.HashFromBytes(entry.Hash)
+		case iplddecoders.KindBlock:
+			decoded, err := iplddecoders.DecodeBlock(block.RawData())
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode block %s: %w", block.Cid(), err)
+			}
+			slot := uint64(decoded.Slot)
+			parentSlot := uint64(decoded.Meta.Parent_slot)
+			item := blockmanifest.Entry{
+				Slot:       slot,
+				Cid:        block.Cid(),
+				ParentSlot: parentSlot,
+			}
+			if len(decoded.Entries) > 0 {
+				lastEntryCid := decoded.Entries[len(decoded.Entries)-1].(cidlink.Link).Cid
+				hash, ok := entryHashes[lastEntryCid]
+				if !ok {
+					return nil, fmt.Errorf("block %d: last entry %s not found before the block", slot, lastEntryCid)
+				}
+				item.Blockhash = hash
+			}
+			if slottools.CalcEpochForSlot(parentSlot) == epoch && slot != 0 {
+				if parentCid, ok := slotToCid[parentSlot]; ok {
+					item.ParentCid = parentCid
+				}
+			}
+			manifest.Add(item)
+			slotToCid[slot] = block.Cid()
+			// The entries of the next block come after this block.
+			clear(entryHashes)
+			if manifest.Len()%1_000 == 0 {
+				printToStderr(".")
+			}
+		}
+	}
+	return manifest, nil
+}
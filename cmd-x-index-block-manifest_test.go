@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/blockmanifest"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+)
+
+func TestCreateIndex_blockManifest(t *testing.T) {
+	indexDir := t.TempDir()
+	indexFilepath, err := CreateIndex_blockManifest(
+		context.Background(),
+		0,
+		indexes.NetworkMainnet,
+		filepath.Join("fixtures", "epoch-0-1.car"),
+		indexDir,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	manifest, err := blockmanifest.FromFile(indexFilepath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if manifest.Len() != 10 {
+		t.Fatalf("expected 10 blocks, got %d", manifest.Len())
+	}
+	entries := manifest.Entries()
+	if entries[0].Slot != 0 {
+		t.Fatalf("expected first slot to be 0, got %d", entries[0].Slot)
+	}
+	for i, entry := range entries {
+		if entry.Blockhash.IsZero() {
+			t.Fatalf("block %d has no blockhash", entry.Slot)
+		}
+		if i > 0 && !entry.ParentCid.Equals(entries[i-1].Cid) {
+			t.Fatalf("block %d: expected parent CID %s, got %s", entry.Slot, entries[i-1].Cid, entry.ParentCid)
+		}
+	}
+	if errs := manifest.CheckContinuity(); len(errs) != 0 {
+		t.Fatalf("unexpected discontinuities: %v", errs)
+	}
+}
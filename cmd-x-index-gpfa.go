@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/accum"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	"github.com/urfave/cli/v2"
+)
+
+// newCmd_Index_gpfa creates the gPFA index (getSignaturesForProgram): like the gsfa index, but the
+// keys are the programs invoked by the transactions (including by inner instructions).
+func newCmd_Index_gpfa() *cli.Command {
+	return newCmd_Index_signaturesBy(
+		"gpfa",
+		"Create gPFA index (the transactions of each invoked program) from a CAR file",
+		"gPFA",
+		formatIndexDirname_gpfa,
+		invokedProgramsOfTransaction,
+	)
+}
+
+// invokedProgramsOfTransaction returns the programs invoked by the instructions of the
+// transaction and, if the meta has them, by its inner instructions.
+func invokedProgramsOfTransaction(tx *accum.TransactionWithSlot) solana.PublicKeySlice {
+	instructions, err := nodetools.FlattenInstructionsOfMeta(&tx.Transaction, tx.Metadata)
+	if err != nil {
+		return invokedProgramsOfLegacyTransaction(tx)
+	}
+	programs := make(solana.PublicKeySlice, 0, len(instructions))
+	for _, instruction := range instructions {
+		programs = append(programs, instruction.ProgramID)
+	}
+	return programs.Dedupe()
+}
+
+// invokedProgramsOfLegacyTransaction is invokedProgramsOfTransaction for the transactions that
+// can't be flattened: those whose legacy meta has inner instructions (of which only the program
+// index is used), and those with indexes out of range (which are skipped).
+func invokedProgramsOfLegacyTransaction(tx *accum.TransactionWithSlot) solana.PublicKeySlice {
+	// the indexes of the instructions are in the static accounts, then in the loaded writable
+	// ones, then in the loaded readonly ones.
+	accountKeys := append(solana.PublicKeySlice{}, tx.Transaction.Message.AccountKeys...)
+	var innerProgramIndexes []uint32
+	if tx.Metadata != nil {
+		switch {
+		case tx.Metadata.IsProtobuf():
+			meta := tx.Metadata.GetProtobuf()
+			accountKeys = append(accountKeys, byteSlicesToKeySlice(meta.LoadedWritableAddresses)...)
+			accountKeys = append(accountKeys, byteSlicesToKeySlice(meta.LoadedReadonlyAddresses)...)
+			for _, inner := range meta.InnerInstructions {
+				for _, instruction := range inner.Instructions {
+					innerProgramIndexes = append(innerProgramIndexes, instruction.ProgramIdIndex)
+				}
+			}
+		case tx.Metadata.IsSerdeLatest():
+			meta := tx.Metadata.GetSerdeLatest()
+			if meta.InnerInstructions != nil {
+				for _, inner := range *meta.InnerInstructions {
+					for _, instruction := range inner.Instructions {
+						innerProgramIndexes = append(innerProgramIndexes, uint32(instruction.ProgramIdIndex))
+					}
+				}
+			}
+		}
+	}
+
+	programs := make(solana.PublicKeySlice, 0, len(tx.Transaction.Message.Instructions)+len(innerProgramIndexes))
+	for _, instruction := range tx.Transaction.Message.Instructions {
+		if int(instruction.ProgramIDIndex) < len(accountKeys) {
+			programs = append(programs, accountKeys[instruction.ProgramIDIndex])
+		}
+	}
+	for _, index := range innerProgramIndexes {
+		if int(index) < len(accountKeys) {
+			programs = append(programs, accountKeys[index])
+		}
+	}
+	return programs.Dedupe()
+}
+
+func formatIndexDirname_gpfa(epoch uint64, rootCid cid.Cid, network indexes.Network) string {
+	return fmt.Sprintf(
+		"epoch-%d-%s-%s-%s",
+		epoch,
+		rootCid.String(),
+		network,
+		"gpfa.indexdir",
+	)
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/dustin/go-humanize"
+	"github.com/gagliardetto/solana-go"
 	"github.com/ipfs/go-cid"
 	"github.com/rpcpool/yellowstone-faithful/accum"
 	"github.com/rpcpool/yellowstone-faithful/carreader"
@@ -26,11 +27,31 @@ import (
 )
 
 func newCmd_Index_gsfa() *cli.Command {
+	return newCmd_Index_signaturesBy(
+		"gsfa",
+		"Create GSFA index from a CAR file",
+		"gSFA",
+		formatIndexDirname_gsfa,
+		accountKeysOfTransaction,
+	)
+}
+
+// newCmd_Index_signaturesBy returns the command that creates an index in the gsfa format (the
+// transactions of each key, from the most recent to the oldest), with the keys of each transaction
+// returned by keysOf.
+func newCmd_Index_signaturesBy(
+	name string,
+	description string,
+	label string,
+	formatDirname func(epoch uint64, rootCid cid.Cid, network indexes.Network) string,
+	keysOf func(tx *accum.TransactionWithSlot) solana.PublicKeySlice,
+) *cli.Command {
 	var epoch uint64
 	var network indexes.Network
+	var memoryBudgetMB int64
 	return &cli.Command{
-		Name:        "gsfa",
-		Description: "Create GSFA index from a CAR file",
+		Name:        name,
+		Description: description,
 		ArgsUsage:   "<car-path> <index-dir>",
 		Before: func(c *cli.Context) error {
 			if network == "" {
@@ -73,6 +94,12 @@ func newCmd_Index_gsfa() *cli.Command {
 				Usage: "temporary directory to use for storing intermediate files; WILL BE DELETED",
 				Value: os.TempDir(),
 			},
+			&cli.Int64Flag{
+				Name:        "memory-budget",
+				Usage:       "memory (in MB) used to accumulate the transactions of the accounts; when it's full, they are spilled to sorted runs in the tmp-dir, which are merged at the end",
+				Value:       4096,
+				Destination: &memoryBudgetMB,
+			},
 		},
 		Action: func(c *cli.Context) error {
 			carPath := c.Args().First()
@@ -114,13 +141,13 @@ func newCmd_Index_gsfa() *cli.Command {
 
 			rootCID := rd.Header.Roots[0]
 
-			// Use the car file name and root CID to name the gsfa index dir:
-			gsfaIndexDir := filepath.Join(indexDir, formatIndexDirname_gsfa(
+			// Use the car file name and root CID to name the index dir:
+			gsfaIndexDir := filepath.Join(indexDir, formatDirname(
 				epoch,
 				rootCID,
 				network,
 			))
-			klog.Infof("Creating gsfa index dir at %s", gsfaIndexDir)
+			klog.Infof("Creating %s index dir at %s", name, gsfaIndexDir)
 			err = os.Mkdir(gsfaIndexDir, 0o755)
 			if err != nil {
 				return fmt.Errorf("failed to create index dir: %w", err)
@@ -128,29 +155,30 @@ func newCmd_Index_gsfa() *cli.Command {
 
 			meta := indexmeta.Meta{}
 			if err := meta.AddUint64(indexmeta.MetadataKey_Epoch, epoch); err != nil {
-				return fmt.Errorf("failed to add epoch to sig_exists index metadata: %w", err)
+				return fmt.Errorf("failed to add epoch to %s index metadata: %w", name, err)
 			}
 			if err := meta.AddCid(indexmeta.MetadataKey_RootCid, rootCID); err != nil {
-				return fmt.Errorf("failed to add root cid to sig_exists index metadata: %w", err)
+				return fmt.Errorf("failed to add root cid to %s index metadata: %w", name, err)
 			}
 			if err := meta.AddString(indexmeta.MetadataKey_Network, string(network)); err != nil {
-				return fmt.Errorf("failed to add network to sig_exists index metadata: %w", err)
+				return fmt.Errorf("failed to add network to %s index metadata: %w", name, err)
 			}
 			tmpDir := c.String("tmp-dir")
-			tmpDir = filepath.Join(tmpDir, fmt.Sprintf("yellowstone-faithful-gsfa-%d", time.Now().UnixNano()))
+			tmpDir = filepath.Join(tmpDir, fmt.Sprintf("yellowstone-faithful-%s-%d", name, time.Now().UnixNano()))
 			if err := os.MkdirAll(tmpDir, 0o755); err != nil {
 				return fmt.Errorf("failed to create tmp dir: %w", err)
 			}
-			indexW, err := gsfa.NewGsfaWriter(
+			indexW, err := gsfa.NewBoundedGsfaWriter(
 				gsfaIndexDir,
 				meta,
 				epoch,
 				rootCID,
 				network,
 				tmpDir,
+				memoryBudgetMB*1024*1024,
 			)
 			if err != nil {
-				return fmt.Errorf("error while opening gsfa index writer: %w", err)
+				return fmt.Errorf("error while opening %s index writer: %w", name, err)
 			}
 			numProcessedTransactions := new(atomic.Int64)
 			startedAt := time.Now()
@@ -161,7 +189,7 @@ func newCmd_Index_gsfa() *cli.Command {
 				if err := indexW.Close(); err != nil {
 					klog.Errorf("Error while closing: %s", err)
 				}
-				klog.Infof("Success: gSFA index created at %s with %d transactions", gsfaIndexDir, numProcessedTransactions.Load())
+				klog.Infof("Success: %s index created at %s with %d transactions", label, gsfaIndexDir, numProcessedTransactions.Load())
 				klog.Infof("Finished in %s", time.Since(startedAt))
 			}()
 
@@ -224,27 +252,22 @@ func newCmd_Index_gsfa() *cli.Command {
 					for ii := range transactions {
 						txWithInfo := transactions[ii]
 						numProcessedTransactions.Add(1)
-						accountKeys := txWithInfo.Transaction.Message.AccountKeys
-						if txWithInfo.Metadata != nil && txWithInfo.Metadata.IsProtobuf() {
-							meta := txWithInfo.Metadata.GetProtobuf()
-							accountKeys = append(accountKeys, byteSlicesToKeySlice(meta.LoadedReadonlyAddresses)...)
-							accountKeys = append(accountKeys, byteSlicesToKeySlice(meta.LoadedWritableAddresses)...)
-						}
 						err = indexW.Push(
 							txWithInfo.Offset,
 							txWithInfo.Length,
 							txWithInfo.Slot,
-							accountKeys,
+							keysOf(txWithInfo),
 						)
 						if err != nil {
-							klog.Exitf("Error while pushing to gsfa index: %s", err)
+							klog.Exitf("Error while pushing to %s index: %s", name, err)
 						}
 
 						if time.Since(lastPrintedAt) > time.Millisecond*500 {
 							percentDone := float64(txWithInfo.Slot-epochStart) / float64(epochEnd-epochStart) * 100
 							// clear line, then print progress
 							msg := fmt.Sprintf(
-								"\rCreating gSFA index for epoch %d - %s | %s | %.2f%% | slot %s | tx %s",
+								"\rCreating %s index for epoch %d - %s | %s | %.2f%% | slot %s | tx %s",
+								label,
 								epoch,
 								time.Now().Format("2006-01-02 15:04:05"),
 								time.Since(startedAt).Truncate(time.Second),
@@ -284,3 +307,15 @@ func formatIndexDirname_gsfa(epoch uint64, rootCid cid.Cid, network indexes.Netw
 		"gsfa.indexdir",
 	)
 }
+
+// accountKeysOfTransaction returns the accounts of the transaction, including the ones loaded
+// from address lookup tables.
+func accountKeysOfTransaction(tx *accum.TransactionWithSlot) solana.PublicKeySlice {
+	accountKeys := tx.Transaction.Message.AccountKeys
+	if tx.Metadata != nil && tx.Metadata.IsProtobuf() {
+		meta := tx.Metadata.GetProtobuf()
+		accountKeys = append(accountKeys, byteSlicesToKeySlice(meta.LoadedReadonlyAddresses)...)
+		accountKeys = append(accountKeys, byteSlicesToKeySlice(meta.LoadedWritableAddresses)...)
+	}
+	return accountKeys
+}
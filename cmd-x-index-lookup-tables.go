@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/lookuptables"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_Index_lookupTables() *cli.Command {
+	var epoch uint64
+	var network indexes.Network
+	var bases cli.StringSlice
+	return &cli.Command{
+		Name: "lookup-tables",
+		Description: "Given a CAR file containing a Solana epoch, create a snapshot of the address lookup tables used in it, " +
+			"learned from the accounts that the transactions with a protobuf meta loaded from them. " +
+			"It's used by the RPC server (indexes.lookup_tables) to resolve, for the jsonParsed encoding, the v0 transactions whose meta doesn't have the loaded accounts. " +
+			"With --base, the snapshots of other epochs (e.g. the previous one) are merged in, for the tables used in this epoch only by such transactions.",
+		ArgsUsage: "<car-path> <index-dir>",
+		Before: func(c *cli.Context) error {
+			if network == "" {
+				network = indexes.NetworkMainnet
+			}
+			return nil
+		},
+		Flags: []cli.Flag{
+			&cli.Uint64Flag{
+				Name:        "epoch",
+				Usage:       "the epoch of the CAR file",
+				Destination: &epoch,
+				Required:    true,
+			},
+			&cli.StringFlag{
+				Name:  "network",
+				Usage: "the cluster of the epoch; one of: mainnet, testnet, devnet",
+				Action: func(c *cli.Context, s string) error {
+					network = indexes.Network(s)
+					if !indexes.IsValidNetwork(network) {
+						return fmt.Errorf("invalid network: %q", network)
+					}
+					return nil
+				},
+			},
+			&cli.StringSliceFlag{
+				Name:        "base",
+				Usage:       "lookup tables snapshot of another epoch to merge in (can be repeated)",
+				Destination: &bases,
+			},
+		},
+		Subcommands: []*cli.Command{},
+		Action: func(c *cli.Context) error {
+			carPath := c.Args().Get(0)
+			indexDir := c.Args().Get(1)
+
+			startedAt := time.Now()
+			defer func() {
+				klog.Infof("Finished in %s", time.Since(startedAt))
+			}()
+			klog.Infof("Creating lookup tables snapshot for %s", carPath)
+			indexFilepath, err := CreateIndex_lookupTables(
+				c.Context,
+				epoch,
+				network,
+				carPath,
+				indexDir,
+				bases.Value(),
+			)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			klog.Info("lookup tables snapshot created at ", indexFilepath)
+			return nil
+		},
+	}
+}
+
+// CreateIndex_lookupTables creates a snapshot of the address lookup tables used by the transactions of the CAR
+// file, merged with the given snapshots (of other epochs).
+func CreateIndex_lookupTables(
+	ctx context.Context,
+	epoch uint64,
+	network indexes.Network,
+	carPath string,
+	indexDir string,
+	bases []string,
+) (string, error) {
+	exists, err := fileExists(carPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if CAR file exists: %w", err)
+	}
+	if !exists {
+		return "", fmt.Errorf("CAR file %q does not exist", carPath)
+	}
+	cr, err := carv2.OpenReader(carPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open CAR file: %w", err)
+	}
+	roots, err := cr.Roots()
+	cr.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to get roots: %w", err)
+	}
+	if len(roots) != 1 {
+		return "", fmt.Errorf("CAR file has %d roots, expected 1", len(roots))
+	}
+	rootCid := roots[0]
+
+	klog.Infof("Indexing...")
+	snapshot := lookuptables.NewSnapshot(epoch)
+	source := carwriter.NewCarSource(carPath)
+	defer source.Close()
+	var numLearned, numSkipped uint64
+	err = source.Blocks(ctx, 0, math.MaxUint64, func(block *carwriter.Block) error {
+		for _, entry := range block.Entries {
+			for i := range entry.Transactions {
+				hasLookups, learned, err := learnLookupTables(snapshot, &entry.Transactions[i])
+				if err != nil {
+					return fmt.Errorf("slot %d: %w", block.Slot, err)
+				}
+				switch {
+				case learned:
+					numLearned++
+				case hasLookups:
+					numSkipped++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to index: %w", err)
+	}
+	klog.Infof("Learned the tables of %d transactions; %d transactions with lookups have no loaded accounts in their meta", numLearned, numSkipped)
+	for _, base := range bases {
+		other, err := lookuptables.FromFile(base)
+		if err != nil {
+			return "", fmt.Errorf("failed to read base snapshot: %w", err)
+		}
+		snapshot.Merge(other)
+	}
+
+	indexFilePath := filepath.Join(indexDir, lookuptables.FormatFilename(epoch, rootCid, network))
+	file, err := os.Create(indexFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create lookup tables snapshot file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := snapshot.WriteTo(file); err != nil {
+		return "", fmt.Errorf("failed to write lookup tables snapshot: %w", err)
+	}
+	klog.Infof("Index created at %s; %d tables indexed", indexFilePath, snapshot.Len())
+	return indexFilePath, nil
+}
+
+// learnLookupTables records the lookup table entries loaded by a transaction in the snapshot, if it has
+// lookups and its meta has the loaded accounts.
+func learnLookupTables(snapshot *lookuptables.Snapshot, carTx *carwriter.Transaction) (hasLookups bool, learned bool, err error) {
+	tx, err := solana.TransactionFromDecoder(bin.NewBinDecoder(carTx.Data))
+	if err != nil {
+		return false, false, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	if len(tx.Message.AddressTableLookups) == 0 {
+		return false, false, nil
+	}
+	if len(carTx.Metadata) == 0 {
+		return true, false, nil
+	}
+	buf, err := tooling.DecompressZstd(carTx.Metadata)
+	if err != nil {
+		return true, false, fmt.Errorf("failed to decompress meta of %s: %w", tx.Signatures[0], err)
+	}
+	container, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(buf)
+	if err != nil {
+		return true, false, fmt.Errorf("failed to decode meta of %s: %w", tx.Signatures[0], err)
+	}
+	// only the protobuf metas have the loaded accounts.
+	meta := container.GetProtobuf()
+	if meta == nil || len(meta.LoadedWritableAddresses)+len(meta.LoadedReadonlyAddresses) == 0 {
+		return true, false, nil
+	}
+	if err := snapshot.Learn(tx, meta); err != nil {
+		klog.Warningf("transaction %s: %s", tx.Signatures[0], err)
+		return true, false, nil
+	}
+	return true, true, nil
+}
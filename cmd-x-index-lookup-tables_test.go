@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/lookuptables"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateIndex_lookupTables(t *testing.T) {
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+
+	indexFilepath, err := CreateIndex_lookupTables(context.Background(), 0, indexes.NetworkMainnet, carPath, t.TempDir(), nil)
+	require.NoError(t, err)
+	require.Equal(t, lookuptables.FormatFilename(0, fixture.Root, indexes.NetworkMainnet), filepath.Base(indexFilepath))
+	file, err := os.Open(indexFilepath)
+	require.NoError(t, err)
+	defer file.Close()
+	reader, err := lookuptables.NewReader(file)
+	require.NoError(t, err)
+	require.Equal(t, 1, reader.Len())
+	resolver := lookuptables.NewResolver(reader, 16)
+
+	var generated *carfixture.Transaction
+	for i := range fixture.Transactions {
+		if fixture.Transactions[i].Spec.AddressLookups {
+			generated = &fixture.Transactions[i]
+		}
+	}
+	require.NotNil(t, generated)
+	decode := func() *solana.Transaction {
+		tx, err := solana.TransactionFromDecoder(bin.NewBinDecoder(generated.Data))
+		require.NoError(t, err)
+		return tx
+	}
+
+	// without a meta, the loaded accounts are resolved from the snapshot.
+	tx := decode()
+	require.NoError(t, resolveAddressTables(tx, nil, resolver))
+	require.Equal(t, generated.Accounts, []solana.PublicKey(tx.Message.AccountKeys))
+
+	// with a protobuf meta, they're taken from it.
+	tx = decode()
+	meta := &confirmed_block.TransactionStatusMeta{
+		LoadedWritableAddresses: [][]byte{generated.Accounts[3].Bytes()},
+		LoadedReadonlyAddresses: [][]byte{generated.Accounts[4].Bytes()},
+	}
+	require.NoError(t, resolveAddressTables(tx, meta, nil))
+	require.Equal(t, generated.Accounts, []solana.PublicKey(tx.Message.AccountKeys))
+
+	// neither: the transaction is left unresolved.
+	tx = decode()
+	require.NoError(t, resolveAddressTables(tx, &confirmed_block.TransactionStatusMeta{}, nil))
+	require.Len(t, tx.Message.AccountKeys, 3)
+
+	// a snapshot of another epoch is merged in.
+	mergedPath, err := CreateIndex_lookupTables(context.Background(), 1, indexes.NetworkMainnet, carPath, t.TempDir(), []string{indexFilepath})
+	require.NoError(t, err)
+	merged, err := lookuptables.FromFile(mergedPath)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), merged.Epoch())
+	require.Equal(t, 1, merged.Len())
+}
@@ -93,7 +93,9 @@ func newCmd_Index_sigExists() *cli.Command {
 				defer file.Close()
 			}
 
-			cachingReader, err := readahead.NewCachingReaderFromReader(file, readahead.DefaultChunkSize)
+			// Hash the CAR while indexing it, to record it in the index provenance.
+			hashingFile := newHashingReadCloser(file)
+			cachingReader, err := readahead.NewCachingReaderFromReader(hashingFile, readahead.DefaultChunkSize)
 			if err != nil {
 				klog.Exitf("Failed to create caching reader: %s", err)
 			}
@@ -238,6 +240,9 @@ func newCmd_Index_sigExists() *cli.Command {
 			if err := meta.AddString(indexmeta.MetadataKey_Network, string(network)); err != nil {
 				return fmt.Errorf("failed to add network to sig_exists index metadata: %w", err)
 			}
+			if err := newIndexProvenance(hashingFile.Sum()).AddTo(&meta); err != nil {
+				return fmt.Errorf("failed to add provenance to sig_exists index metadata: %w", err)
+			}
 			_, err = index.Seal(meta)
 			if err != nil {
 				return fmt.Errorf("error while sealing index: %w", err)
@@ -13,6 +13,7 @@ import (
 
 func newCmd_Index_sig2cid() *cli.Command {
 	var verify bool
+	var v2 bool
 	var epoch uint64
 	var network indexes.Network
 	return &cli.Command{
@@ -31,6 +32,11 @@ func newCmd_Index_sig2cid() *cli.Command {
 				Usage:       "verify the index after creating it",
 				Destination: &verify,
 			},
+			&cli.BoolFlag{
+				Name:        "v2",
+				Usage:       "create the v2 index, which also stores the slot of each transaction and whether it failed (to serve statuses without reading the transactions)",
+				Destination: &v2,
+			},
 			&cli.StringFlag{
 				Name:  "tmp-dir",
 				Usage: "temporary directory to use for storing intermediate files",
@@ -79,6 +85,7 @@ func newCmd_Index_sig2cid() *cli.Command {
 					tmpDir,
 					carPath,
 					indexDir,
+					v2,
 				)
 				if err != nil {
 					panic(err)
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/accum"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/urfave/cli/v2"
+)
+
+// newCmd_Index_tokenBalances creates the token balances index: like the gsfa index, but the keys
+// are the (owner, mint) pairs (see tokenBalanceKey) whose token balance changed in the transactions.
+func newCmd_Index_tokenBalances() *cli.Command {
+	return newCmd_Index_signaturesBy(
+		"token-balances",
+		"Create the token balances index (the transactions that changed the balance of each owner and mint) from a CAR file",
+		"token balances",
+		formatIndexDirname_tokenBalances,
+		tokenBalanceKeysOfTransaction,
+	)
+}
+
+// tokenBalanceKey is the key of the transactions of an owner and a mint in the token balances index.
+func tokenBalanceKey(owner solana.PublicKey, mint solana.PublicKey) solana.PublicKey {
+	return sha256.Sum256(append(owner[:], mint[:]...))
+}
+
+// tokenBalanceKeysOfTransaction returns the keys of the (owner, mint) pairs whose token balance
+// changed in the transaction, from the pre and post token balances of its meta (which only the
+// protobuf meta has).
+func tokenBalanceKeysOfTransaction(tx *accum.TransactionWithSlot) solana.PublicKeySlice {
+	if tx.Metadata == nil || !tx.Metadata.IsProtobuf() {
+		return nil
+	}
+	meta := tx.Metadata.GetProtobuf()
+	pre := make(map[uint32]*confirmed_block.TokenBalance, len(meta.PreTokenBalances))
+	for _, balance := range meta.PreTokenBalances {
+		pre[balance.AccountIndex] = balance
+	}
+	post := make(map[uint32]*confirmed_block.TokenBalance, len(meta.PostTokenBalances))
+	for _, balance := range meta.PostTokenBalances {
+		post[balance.AccountIndex] = balance
+	}
+
+	var keys solana.PublicKeySlice
+	add := func(balance *confirmed_block.TokenBalance) {
+		owner, err := solana.PublicKeyFromBase58(balance.Owner)
+		if err != nil {
+			// the old metas don't have the owner.
+			return
+		}
+		mint, err := solana.PublicKeyFromBase58(balance.Mint)
+		if err != nil {
+			return
+		}
+		keys = append(keys, tokenBalanceKey(owner, mint))
+	}
+	for accountIndex, before := range pre {
+		after, ok := post[accountIndex]
+		if ok && tokenAmount(before) == tokenAmount(after) && before.Owner == after.Owner && before.Mint == after.Mint {
+			continue
+		}
+		add(before)
+		if ok {
+			add(after)
+		}
+	}
+	for accountIndex, after := range post {
+		if _, ok := pre[accountIndex]; !ok {
+			add(after)
+		}
+	}
+	return keys.Dedupe()
+}
+
+func tokenAmount(balance *confirmed_block.TokenBalance) string {
+	if balance.UiTokenAmount == nil {
+		return ""
+	}
+	return balance.UiTokenAmount.Amount
+}
+
+func formatIndexDirname_tokenBalances(epoch uint64, rootCid cid.Cid, network indexes.Network) string {
+	return fmt.Sprintf(
+		"epoch-%d-%s-%s-%s",
+		epoch,
+		rootCid.String(),
+		network,
+		"token-balances.indexdir",
+	)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+func newCmd_Index_upgrade() *cli.Command {
+	var outPath string
+	return &cli.Command{
+		Name:        "upgrade",
+		Description: "Upgrade a compactindex file (cid-to-offset-and-size, slot-to-cid, sig-to-cid) to the latest format version. By default the header is rewritten in place; with --out, an upgraded copy is written instead.",
+		ArgsUsage:   "<index-path>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "out",
+				Usage:       "write the upgraded index to this path instead of upgrading it in place",
+				Destination: &outPath,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			indexPath := c.Args().Get(0)
+			if indexPath == "" {
+				return cli.Exit("missing index path", 1)
+			}
+			var from uint8
+			var err error
+			if outPath == "" {
+				from, err = upgradeIndexInPlace(indexPath)
+			} else {
+				from, err = upgradeIndexTo(indexPath, outPath)
+				indexPath = outPath
+			}
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("failed to upgrade index: %s", err), 1)
+			}
+			if from == compactindexsized.LatestVersion && outPath == "" {
+				klog.Infof("Index %s is already at version %d", indexPath, from)
+				return nil
+			}
+			klog.Infof("Upgraded index %s from version %d to version %d", indexPath, from, compactindexsized.LatestVersion)
+			return nil
+		},
+	}
+}
+
+func upgradeIndexInPlace(indexPath string) (uint8, error) {
+	file, err := os.OpenFile(indexPath, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return compactindexsized.UpgradeInPlace(file)
+}
+
+func upgradeIndexTo(indexPath string, outPath string) (uint8, error) {
+	src, err := os.Open(indexPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := os.Create(outPath)
+	if err != nil {
+		return 0, err
+	}
+	from, err := compactindexsized.Upgrade(dst, src)
+	if err != nil {
+		dst.Close()
+		os.Remove(outPath)
+		return from, err
+	}
+	return from, dst.Close()
+}
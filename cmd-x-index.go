@@ -19,8 +19,13 @@ func newCmd_Index() *cli.Command {
 			newCmd_Index_sig2cid(),
 			newCmd_Index_all(), // NOTE: not actually all.
 			newCmd_Index_gsfa(),
+			newCmd_Index_gpfa(),
+			newCmd_Index_tokenBalances(),
 			newCmd_Index_sigExists(),
 			newCmd_Index_slot2blocktime(),
+			newCmd_Index_blockManifest(),
+			newCmd_Index_lookupTables(),
+			newCmd_Index_upgrade(),
 		},
 	}
 }
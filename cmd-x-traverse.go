@@ -113,12 +113,12 @@ func newCmd_XTraverse() *cli.Command {
 										fmt.Println(transaction.String())
 									}
 									{
-										metaBuffer, err := tooling.LoadDataFromDataFrames(&tx.Metadata, simpleIter.GetDataFrame)
+										metaChunks, err := tooling.LoadChunksFromDataFrames(&tx.Metadata, simpleIter.GetDataFrame)
 										if err != nil {
 											panic(err)
 										}
-										if len(metaBuffer) > 0 {
-											uncompressedMeta, err := tooling.DecompressZstd(metaBuffer)
+										if metaChunks.Len() > 0 {
+											uncompressedMeta, err := tooling.DecompressZstdChunks(metaChunks)
 											if err != nil {
 												panic(err)
 											}
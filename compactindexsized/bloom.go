@@ -0,0 +1,147 @@
+package compactindexsized
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// FlagBloomFilter is the header flag of the indexes that have a bloom filter section.
+//
+// The section is between the bucket header table and the bucket entries, which are
+// located by their absolute file offsets. Flags are only encoded in Version2 headers (see
+// Header.encodingVersion), and readers that only know Version 1 reject any other version:
+// an index with a bloom filter needs a reader that knows Version2.
+//
+//	blocks_per_bucket: u32
+//	num_hashes:        u8
+//	padding:           [u8; 3]
+//	blocks:            [[u8; 64]; num_buckets * blocks_per_bucket]
+//
+// Each bucket has its own blocks_per_bucket blocks of 512 bits, and each key sets
+// num_hashes bits of a single block of its bucket, so that checking a key takes one
+// 64-byte read, before (and instead of, for most absent keys) the reads of the bucket.
+const FlagBloomFilter = uint32(1 << 0)
+
+const (
+	// DefaultBloomFilterBitsPerKey gives a false positive rate of about 1%.
+	DefaultBloomFilterBitsPerKey = 10
+	// bloomBlockSize is the size of a block of the filter, in bytes.
+	bloomBlockSize = 64
+	// bloomFilterHeaderLen is the size of the header of the filter section.
+	bloomFilterHeaderLen = 8
+	// bloomHashDomain is the hash domain of the filter; the bucket hash domains are
+	// the first ones (see mine).
+	bloomHashDomain = math.MaxUint32
+)
+
+// bloomFilter describes the bloom filter section of an index.
+type bloomFilter struct {
+	// offset is the file offset of the section.
+	offset          int64
+	blocksPerBucket uint32
+	numHashes       uint8
+}
+
+// newBloomFilter returns the parameters of the filter of an index with the given number
+// of items and buckets.
+func newBloomFilter(numItems uint, numBuckets uint32, bitsPerKey uint) *bloomFilter {
+	itemsPerBucket := (uint64(numItems) + uint64(numBuckets) - 1) / uint64(numBuckets)
+	blocks := (itemsPerBucket*uint64(bitsPerKey) + bloomBlockSize*8 - 1) / (bloomBlockSize * 8)
+	numHashes := math.Round(float64(bitsPerKey) * math.Ln2)
+	return &bloomFilter{
+		blocksPerBucket: uint32(max(blocks, 1)),
+		numHashes:       uint8(min(max(numHashes, 1), 16)),
+	}
+}
+
+func (f *bloomFilter) sectionLen(numBuckets uint32) int64 {
+	return bloomFilterHeaderLen + int64(numBuckets)*int64(f.blocksPerBucket)*bloomBlockSize
+}
+
+func (f *bloomFilter) header() []byte {
+	buf := make([]byte, bloomFilterHeaderLen)
+	binary.LittleEndian.PutUint32(buf[0:4], f.blocksPerBucket)
+	buf[4] = f.numHashes
+	return buf
+}
+
+func loadBloomFilter(rd io.ReaderAt, offset int64) (*bloomFilter, error) {
+	var buf [bloomFilterHeaderLen]byte
+	n, err := rd.ReadAt(buf[:], offset)
+	if n < len(buf) {
+		return nil, fmt.Errorf("failed to read the bloom filter header: %w", err)
+	}
+	f := &bloomFilter{
+		offset:          offset,
+		blocksPerBucket: binary.LittleEndian.Uint32(buf[0:4]),
+		numHashes:       buf[4],
+	}
+	if f.blocksPerBucket == 0 || f.numHashes == 0 {
+		return nil, fmt.Errorf("invalid bloom filter header")
+	}
+	return f, nil
+}
+
+// blockOffset returns the file offset of a block of the bucket.
+func (f *bloomFilter) blockOffset(bucket uint, block uint32) int64 {
+	return f.offset + bloomFilterHeaderLen + (int64(bucket)*int64(f.blocksPerBucket)+int64(block))*bloomBlockSize
+}
+
+// keyBits returns the block (in its bucket) of a key, and the bits it sets in the block.
+func (f *bloomFilter) keyBits(key []byte) (block uint32, bits [bloomBlockSize]byte) {
+	h := EntryHash64(bloomHashDomain, key)
+	block = uint32((uint64(uint32(h)) * uint64(f.blocksPerBucket)) >> 32)
+	h1 := uint32(h >> 32)
+	h2 := uint32(hashUint64(h)) | 1
+	for i := uint32(0); i < uint32(f.numHashes); i++ {
+		bit := (h1 + i*h2) % (bloomBlockSize * 8)
+		bits[bit/8] |= 1 << (bit % 8)
+	}
+	return block, bits
+}
+
+// mayContain checks the key against the filter of its bucket.
+func (f *bloomFilter) mayContain(rd io.ReaderAt, bucket uint, key []byte) (bool, error) {
+	block, bits := f.keyBits(key)
+	var buf [bloomBlockSize]byte
+	n, err := rd.ReadAt(buf[:], f.blockOffset(bucket, block))
+	if n < len(buf) {
+		return false, fmt.Errorf("failed to read the bloom filter: %w", err)
+	}
+	for i := range bits {
+		if buf[i]&bits[i] != bits[i] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// bucketBlocks returns the blocks of the filter of a bucket with the keys of the temporary
+// bucket file.
+func (f *bloomFilter) bucketBlocks(b *tempBucket) ([]byte, error) {
+	blocks := make([]byte, int(f.blocksPerBucket)*bloomBlockSize)
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	rd := bufio.NewReader(b.file)
+	static := make([]byte, 2+b.valueSize)
+	for i := uint(0); i < b.records; i++ {
+		// Read next key from file (as defined by writeTuple)
+		if _, err := io.ReadFull(rd, static); err != nil {
+			return nil, err
+		}
+		key := make([]byte, binary.LittleEndian.Uint16(static[0:2]))
+		if _, err := io.ReadFull(rd, key); err != nil {
+			return nil, err
+		}
+		block, bits := f.keyBits(key)
+		dst := blocks[int(block)*bloomBlockSize:][:bloomBlockSize]
+		for j := range bits {
+			dst[j] |= bits[j]
+		}
+	}
+	return blocks, nil
+}
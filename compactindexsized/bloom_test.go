@@ -0,0 +1,137 @@
+package compactindexsized
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingReader counts the reads of the index.
+type countingReader struct {
+	io.ReaderAt
+	reads int
+}
+
+func (r *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	r.reads++
+	return r.ReaderAt.ReadAt(p, off)
+}
+
+func TestBloomFilter(t *testing.T) {
+	const numKeys = 50_000
+	key := func(i uint64) []byte {
+		var buf [32]byte
+		binary.LittleEndian.PutUint64(buf[:], i)
+		return buf[:]
+	}
+	value := func(i uint64) []byte {
+		var buf [36]byte
+		binary.LittleEndian.PutUint64(buf[4:], i)
+		return buf[:]
+	}
+	build := func(bloom bool) string {
+		builder, err := NewBuilderSized("", numKeys, 36)
+		require.NoError(t, err)
+		defer builder.Close()
+		if bloom {
+			require.NoError(t, builder.EnableBloomFilter(0))
+		}
+		for i := uint64(0); i < numKeys; i++ {
+			require.NoError(t, builder.Insert(key(i), value(i)))
+		}
+		path := filepath.Join(t.TempDir(), "test.index")
+		file, err := os.Create(path)
+		require.NoError(t, err)
+		defer file.Close()
+		require.NoError(t, builder.Seal(context.TODO(), file))
+		return path
+	}
+	plain, filtered := build(false), build(true)
+
+	open := func(path string) (*DB, *countingReader) {
+		file, err := os.Open(path)
+		require.NoError(t, err)
+		t.Cleanup(func() { file.Close() })
+		reader := &countingReader{ReaderAt: file}
+		db, err := Open(reader)
+		require.NoError(t, err)
+		return db, reader
+	}
+	plainDB, _ := open(plain)
+	require.False(t, plainDB.HasBloomFilter())
+	require.Zero(t, plainDB.Header.Flags)
+	db, reader := open(filtered)
+	require.True(t, db.HasBloomFilter())
+	require.Equal(t, FlagBloomFilter, db.Header.Flags)
+	require.Equal(t, Version2, db.Header.Version)
+	require.Greater(t, db.Header.NumBuckets, uint32(1))
+
+	for i := uint64(0); i < numKeys; i++ {
+		got, err := db.Lookup(key(i))
+		require.NoError(t, err)
+		require.Equal(t, value(i), got)
+	}
+
+	// absent keys: most of them take the read of the filter only.
+	const numAbsent = 20_000
+	falsePositives := 0
+	for i := uint64(numKeys); i < numKeys+numAbsent; i++ {
+		ok, err := db.MayContain(key(i))
+		require.NoError(t, err)
+		if ok {
+			falsePositives++
+		}
+	}
+	require.Less(t, float64(falsePositives)/numAbsent, 0.02)
+	reader.reads = 0
+	for i := uint64(numKeys); i < numKeys+numAbsent; i++ {
+		_, err := db.Lookup(key(i))
+		require.ErrorIs(t, err, ErrNotFound)
+	}
+	require.Less(t, reader.reads, numAbsent+falsePositives*20)
+
+	// a Version2 reader that ignores the filter still finds the keys (the buckets have
+	// absolute offsets).
+	db.filter = nil
+	for i := uint64(0); i < numKeys; i += 97 {
+		got, err := db.Lookup(key(i))
+		require.NoError(t, err)
+		require.Equal(t, value(i), got)
+	}
+
+	// a reader that only knows Version 1 rejects the index instead of misreading it.
+	data, err := os.ReadFile(filtered)
+	require.NoError(t, err)
+	require.ErrorContains(t, loadHeaderV1(data), "unsupported index version: want 1, got 2")
+	data, err = os.ReadFile(plain)
+	require.NoError(t, err)
+	require.NoError(t, loadHeaderV1(data))
+
+	builder, err := NewBuilderSized("", 1, 8)
+	require.NoError(t, err)
+	defer builder.Close()
+	require.Error(t, builder.EnableBloomFilter(65))
+}
+
+// loadHeaderV1 checks a header like the readers that predate Version2 do.
+func loadHeaderV1(buf []byte) error {
+	if *(*[8]byte)(buf[:8]) != Magic {
+		return fmt.Errorf("not a radiance compactindex file")
+	}
+	if binary.LittleEndian.Uint32(buf[8:12]) < 12 {
+		return fmt.Errorf("invalid header length")
+	}
+	if buf[24] != Version {
+		return fmt.Errorf("unsupported index version: want %d, got %d", Version, buf[24])
+	}
+	if binary.LittleEndian.Uint64(buf[12:20]) == 0 {
+		return fmt.Errorf("value size not set")
+	}
+	return nil
+}
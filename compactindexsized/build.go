@@ -27,6 +27,8 @@ type Builder struct {
 	headerSize int64
 	closers    []io.Closer
 	buckets    []tempBucket
+	numItems   uint
+	filter     *bloomFilter
 }
 
 // NewBuilderSized creates a new index builder.
@@ -35,7 +37,8 @@ type Builder struct {
 //
 // numItems refers to the number of items in the index.
 //
-// valueSize is the size of each value in bytes. It must be > 0 and <= 256.
+// valueSize is the size of each value in bytes. It must be > 0 and <= MaxValueSize;
+// indexes with values larger than MaxValueSizeV1 are written in the Version2 format.
 // All values must be of the same size.
 func NewBuilderSized(
 	tmpDir string,
@@ -52,8 +55,8 @@ func NewBuilderSized(
 	if valueSizeBytes == 0 {
 		return nil, fmt.Errorf("valueSizeBytes must be > 0")
 	}
-	if valueSizeBytes > 255 {
-		return nil, fmt.Errorf("valueSizeBytes must be <= 255")
+	if valueSizeBytes > MaxValueSize {
+		return nil, fmt.Errorf("valueSizeBytes must be <= %d", MaxValueSize)
 	}
 	if numItems == 0 {
 		return nil, fmt.Errorf("numItems must be > 0")
@@ -83,12 +86,29 @@ func NewBuilderSized(
 			NumBuckets: uint32(numBuckets),
 			Metadata:   &indexmeta.Meta{},
 		},
-		closers: closers,
-		buckets: buckets,
-		tmpDir:  tmpDir,
+		closers:  closers,
+		buckets:  buckets,
+		tmpDir:   tmpDir,
+		numItems: numItems,
 	}, nil
 }
 
+// EnableBloomFilter adds a bloom filter of the keys to the index (see FlagBloomFilter), with
+// the given number of bits per key (DefaultBloomFilterBitsPerKey if zero). Lookups of absent
+// keys then take a single small read for most keys, instead of the reads of a bucket.
+// It must be called before Seal.
+func (b *Builder) EnableBloomFilter(bitsPerKey uint) error {
+	if bitsPerKey == 0 {
+		bitsPerKey = DefaultBloomFilterBitsPerKey
+	}
+	if bitsPerKey > 64 {
+		return fmt.Errorf("bitsPerKey must be <= 64")
+	}
+	b.filter = newBloomFilter(b.numItems, b.Header.NumBuckets, bitsPerKey)
+	b.Header.Flags |= FlagBloomFilter
+	return nil
+}
+
 // SetKind sets the kind of the index.
 // If the kind is already set, it is overwritten.
 func (b *Builder) SetKind(kind []byte) error {
@@ -149,12 +169,17 @@ func (b *Builder) Seal(ctx context.Context, file *os.File) (err error) {
 		return fmt.Errorf("failed to write header: wrote %d bytes, expected %d", numWroteHeader, len(headerBuf))
 	}
 	b.headerSize = headerSize
-	// Create hole to leave space for bucket header table.
+	// Create hole to leave space for bucket header table (and the bloom filter).
 	bucketTableLen := int64(b.Header.NumBuckets) * bucketHdrLen
-	err = fallocate(file, headerSize, bucketTableLen)
+	reservedLen := bucketTableLen
+	if b.filter != nil {
+		b.filter.offset = headerSize + bucketTableLen
+		reservedLen += b.filter.sectionLen(b.Header.NumBuckets)
+	}
+	err = fallocate(file, headerSize, reservedLen)
 	if errors.Is(err, syscall.EOPNOTSUPP) {
 		// The underlying file system may not support fallocate
-		err = fake_fallocate(file, headerSize, bucketTableLen)
+		err = fake_fallocate(file, headerSize, reservedLen)
 		if err != nil {
 			return fmt.Errorf("failed to fake fallocate() bucket table: %w", err)
 		}
@@ -162,6 +187,11 @@ func (b *Builder) Seal(ctx context.Context, file *os.File) (err error) {
 	if err != nil {
 		return fmt.Errorf("failed to fallocate() bucket table: %w", err)
 	}
+	if b.filter != nil {
+		if _, err := file.WriteAt(b.filter.header(), b.filter.offset); err != nil {
+			return fmt.Errorf("failed to write bloom filter header: %w", err)
+		}
+	}
 	// Seal each bucket.
 	for i := range b.buckets {
 		if err := b.sealBucket(ctx, i, file); err != nil {
@@ -200,7 +230,7 @@ func (b *Builder) sealBucket(ctx context.Context, i int, f *os.File) error {
 			FileOffset: uint64(offset),
 		},
 		Stride:      b.getEntryStride(),
-		OffsetWidth: uint8(b.getValueSize()),
+		OffsetWidth: uint16(b.getValueSize()),
 	}
 	desc.BucketHeader.headerSize = b.headerSize
 	// Write entries to file.
@@ -219,12 +249,21 @@ func (b *Builder) sealBucket(ctx context.Context, i int, f *os.File) error {
 	if err := desc.BucketHeader.writeTo(f, uint(i)); err != nil {
 		return fmt.Errorf("failed to write bucket header %d: %w", i, err)
 	}
+	if b.filter != nil {
+		blocks, err := b.filter.bucketBlocks(bucket)
+		if err != nil {
+			return fmt.Errorf("failed to build bloom filter of bucket %d: %w", i, err)
+		}
+		if _, err := f.WriteAt(blocks, b.filter.blockOffset(uint(i), 0)); err != nil {
+			return fmt.Errorf("failed to write bloom filter of bucket %d: %w", i, err)
+		}
+	}
 	return nil
 }
 
-func (b *Builder) getEntryStride() uint8 {
+func (b *Builder) getEntryStride() uint16 {
 	offsetSize := b.getValueSize()
-	return uint8(HashSize) + uint8(offsetSize)
+	return uint16(HashSize) + uint16(offsetSize)
 }
 
 func (b *Builder) Close() error {
@@ -319,6 +319,7 @@ func TestBuilder36(t *testing.T) {
 	assert.Equal(t, &Header{
 		ValueSize:  valueSize,
 		NumBuckets: numBuckets,
+		Version:    Version,
 		Metadata: &indexmeta.Meta{
 			KeyVals: []indexmeta.KV{
 				{
@@ -371,7 +372,7 @@ func TestBuilder36(t *testing.T) {
 		headerSize: 53,
 	}, buckets[2].BucketHeader)
 
-	assert.Equal(t, uint8(3+valueSize), buckets[2].Stride)
+	assert.Equal(t, uint16(3+valueSize), buckets[2].Stride)
 	// Test lookups.
 	entries, err := buckets[2].Load( /*batchSize*/ 3)
 	require.NoError(t, err)
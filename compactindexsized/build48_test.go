@@ -280,6 +280,7 @@ func TestBuilder48(t *testing.T) {
 	assert.Equal(t, &Header{
 		ValueSize:  valueSize,
 		NumBuckets: numBuckets,
+		Version:    Version,
 		Metadata: &indexmeta.Meta{
 			KeyVals: []indexmeta.KV{
 				{
@@ -328,7 +329,7 @@ func TestBuilder48(t *testing.T) {
 		headerSize: 43,
 	}, buckets[2].BucketHeader)
 
-	assert.Equal(t, uint8(3+valueSize), buckets[2].Stride)
+	assert.Equal(t, uint16(3+valueSize), buckets[2].Stride)
 	// Test lookups.
 	entries, err := buckets[2].Load( /*batchSize*/ 3)
 	require.NoError(t, err)
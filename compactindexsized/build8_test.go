@@ -143,6 +143,7 @@ func TestBuilder8(t *testing.T) {
 	assert.Equal(t, &Header{
 		ValueSize:  valueSize,
 		NumBuckets: numBuckets,
+		Version:    Version,
 		Metadata:   &indexmeta.Meta{},
 	}, db.Header)
 
@@ -195,6 +196,14 @@ func TestBuilder8(t *testing.T) {
 			Value: itob(3),
 		},
 	}, entries)
+
+	// Warmup reads the file header and the bucket headers, or the whole file.
+	warmed, err := db.Warmup(false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(74), warmed)
+	warmed, err = db.Warmup(true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(actual)), warmed)
 }
 
 func TestBuilder8_Random(t *testing.T) {
@@ -98,16 +98,38 @@ import (
 // Magic are the first eight bytes of an index.
 var Magic = [8]byte{'c', 'o', 'm', 'p', 'i', 's', 'z', 'd'}
 
-const Version = uint8(1)
+const (
+	// Version is the original format: values of up to MaxValueSizeV1 bytes, and no flags.
+	Version = uint8(1)
+	// Version2 allows values of up to MaxValueSize bytes, and header flags.
+	// The header has the same size as the Version 1 header (the 8-byte value size is split
+	// into a 4-byte value size and 4-byte flags), so indexes can be upgraded in place.
+	Version2 = uint8(2)
+	// LatestVersion is the latest format version that this package can read and write.
+	LatestVersion = Version2
+)
+
+const (
+	// MaxValueSizeV1 is the largest value size of a Version 1 index (the entry stride is one byte).
+	MaxValueSizeV1 = math.MaxUint8 - HashSize
+	// MaxValueSize is the largest value size of a Version2 index.
+	MaxValueSize = math.MaxUint16 - HashSize
+)
 
 // Header occurs once at the beginning of the index.
 type Header struct {
 	ValueSize  uint64
 	NumBuckets uint32
-	Metadata   *indexmeta.Meta
+	// Flags are index-specific flags (only in Version2 indexes).
+	Flags uint32
+	// Version is the format version of the index. When encoding, the lowest version that can
+	// represent the header is used if Version is lower (e.g. zero).
+	Version  uint8
+	Metadata *indexmeta.Meta
 }
 
 // Load checks the Magic sequence and loads the header fields.
+// Both Version 1 and Version2 headers are supported.
 func (h *Header) Load(buf []byte) error {
 	// Use a magic byte sequence to bail fast when user passes a corrupted/unrelated stream.
 	if *(*[8]byte)(buf[:8]) != Magic {
@@ -123,13 +145,19 @@ func (h *Header) Load(buf []byte) error {
 	}
 	// read the rest of the header
 	*h = Header{
-		ValueSize:  binary.LittleEndian.Uint64(buf[12:20]),
 		NumBuckets: binary.LittleEndian.Uint32(buf[20:24]),
+		Version:    buf[24],
 		Metadata:   new(indexmeta.Meta),
 	}
 	// Check version.
-	if buf[24] != Version {
-		return fmt.Errorf("unsupported index version: want %d, got %d", Version, buf[20])
+	switch h.Version {
+	case Version:
+		h.ValueSize = binary.LittleEndian.Uint64(buf[12:20])
+	case Version2:
+		h.ValueSize = uint64(binary.LittleEndian.Uint32(buf[12:16]))
+		h.Flags = binary.LittleEndian.Uint32(buf[16:20])
+	default:
+		return fmt.Errorf("unsupported index version: want at most %d, got %d", LatestVersion, h.Version)
 	}
 	// read key-value pairs
 	if err := h.Metadata.UnmarshalBinary(buf[25:]); err != nil {
@@ -138,21 +166,39 @@ func (h *Header) Load(buf []byte) error {
 	if h.ValueSize == 0 {
 		return fmt.Errorf("value size not set")
 	}
+	if h.Version == Version2 && h.ValueSize > MaxValueSize {
+		return fmt.Errorf("value size %d is too large for index version %d", h.ValueSize, h.Version)
+	}
 	if h.NumBuckets == 0 {
 		return fmt.Errorf("number of buckets not set")
 	}
 	return nil
 }
 
+// encodingVersion returns the version the header is encoded with.
+func (h *Header) encodingVersion() uint8 {
+	if h.Version >= Version2 || h.Flags != 0 || h.ValueSize > MaxValueSizeV1 {
+		return Version2
+	}
+	return Version
+}
+
 func (h *Header) Bytes() []byte {
+	version := h.encodingVersion()
 	buf := new(bytes.Buffer)
 	{
-		// value size
-		binary.Write(buf, binary.LittleEndian, h.ValueSize)
+		if version == Version2 {
+			// value size and flags
+			binary.Write(buf, binary.LittleEndian, uint32(h.ValueSize))
+			binary.Write(buf, binary.LittleEndian, h.Flags)
+		} else {
+			// value size
+			binary.Write(buf, binary.LittleEndian, h.ValueSize)
+		}
 		// number of buckets
 		binary.Write(buf, binary.LittleEndian, h.NumBuckets)
 		// version
-		buf.WriteByte(Version)
+		buf.WriteByte(version)
 		// key-value pairs
 		if h.Metadata == nil {
 			h.Metadata = new(indexmeta.Meta)
@@ -229,14 +275,14 @@ func (b *BucketHeader) Hash(key []byte) uint64 {
 
 type BucketDescriptor struct {
 	BucketHeader
-	Stride      uint8 // size of one entry in bucket
-	OffsetWidth uint8 // with of offset field in bucket
+	Stride      uint16 // size of one entry in bucket
+	OffsetWidth uint16 // with of offset field in bucket
 }
 
 func (b *BucketDescriptor) unmarshalEntry(buf []byte) (e Entry) {
 	e.Hash = uintLe(buf[0:b.HashLen])
 	e.Value = make([]byte, b.OffsetWidth)
-	copy(e.Value[:], buf[b.HashLen:b.HashLen+b.OffsetWidth])
+	copy(e.Value[:], buf[uint16(b.HashLen):uint16(b.HashLen)+b.OffsetWidth])
 	return
 }
 
@@ -245,7 +291,7 @@ func (b *BucketDescriptor) marshalEntry(buf []byte, e Entry) {
 		panic("serializeEntry: buf too small")
 	}
 	putUintLe(buf[0:b.HashLen], e.Hash)
-	copy(buf[b.HashLen:b.HashLen+b.OffsetWidth], e.Value[:])
+	copy(buf[uint16(b.HashLen):uint16(b.HashLen)+b.OffsetWidth], e.Value[:])
 }
 
 // SearchSortedEntries performs an in-memory binary search for a given hash.
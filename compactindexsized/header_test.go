@@ -29,3 +29,38 @@ func TestHeader(t *testing.T) {
 		require.Equal(t, mustBeEncoded, encoded)
 	}
 }
+
+func TestHeaderV2(t *testing.T) {
+	header := Header{
+		ValueSize:  300,
+		NumBuckets: 43,
+		Flags:      0x0102,
+	}
+
+	encoded := header.Bytes()
+	require.Equal(t, concatBytes(
+		// magic
+		Magic[:],
+		// header size
+		i32tob(14),
+		// value size
+		[]byte{0x2c, 0x01, 0, 0},
+		// flags
+		[]byte{0x02, 0x01, 0, 0},
+		// num buckets
+		[]byte{43, 0, 0, 0},
+		[]byte{2}, // version
+		[]byte{0}, // how many kv pairs
+	), encoded)
+
+	var decoded Header
+	require.NoError(t, decoded.Load(encoded))
+	require.Equal(t, uint64(300), decoded.ValueSize)
+	require.Equal(t, uint32(43), decoded.NumBuckets)
+	require.Equal(t, uint32(0x0102), decoded.Flags)
+	require.Equal(t, Version2, decoded.Version)
+
+	// Unknown versions are rejected.
+	encoded[24] = 3
+	require.Error(t, decoded.Load(encoded))
+}
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 
 	"github.com/rpcpool/yellowstone-faithful/indexmeta"
 )
@@ -21,6 +22,7 @@ type DB struct {
 	headerSize int64
 	Stream     io.ReaderAt
 	prefetch   bool
+	filter     *bloomFilter
 }
 
 var ErrInvalidMagic = errors.New("invalid magic")
@@ -57,6 +59,13 @@ func Open(stream io.ReaderAt) (*DB, error) {
 	}
 	db.headerSize = int64(8 + 4 + size)
 	db.Stream = stream
+	if db.Header.Flags&FlagBloomFilter != 0 {
+		filter, err := loadBloomFilter(stream, bucketOffset(db.headerSize, uint(db.Header.NumBuckets)))
+		if err != nil {
+			return nil, err
+		}
+		db.filter = filter
+	}
 	return db, nil
 }
 
@@ -83,10 +92,30 @@ func (db *DB) GetValueSize() uint64 {
 	return value
 }
 
+// HasBloomFilter returns whether the index has a bloom filter (see FlagBloomFilter).
+func (db *DB) HasBloomFilter() bool {
+	return db.filter != nil
+}
+
+// MayContain returns false if the key is certainly not in the index, according to its bloom
+// filter; it is always true for indexes without a bloom filter.
+func (db *DB) MayContain(key []byte) (bool, error) {
+	if db.filter == nil {
+		return true, nil
+	}
+	return db.filter.mayContain(db.Stream, db.Header.BucketHash(key), key)
+}
+
 // Lookup queries for a key in the index and returns the value (offset), if any.
+// The bloom filter, if the index has one, is checked first.
 //
 // Returns ErrNotFound if the key is unknown.
 func (db *DB) Lookup(key []byte) ([]byte, error) {
+	if ok, err := db.MayContain(key); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrNotFound
+	}
 	bucket, err := db.LookupBucket(key)
 	if err != nil {
 		return nil, err
@@ -109,7 +138,7 @@ func (db *DB) GetBucket(i uint) (*Bucket, error) {
 	bucket := &Bucket{
 		BucketDescriptor: BucketDescriptor{
 			Stride:      db.entryStride(),
-			OffsetWidth: uint8(db.GetValueSize()),
+			OffsetWidth: uint16(db.GetValueSize()),
 		},
 	}
 	bucket.BucketHeader.headerSize = db.headerSize
@@ -132,6 +161,38 @@ func (db *DB) GetBucket(i uint) (*Bucket, error) {
 	return bucket, nil
 }
 
+// Warmup reads the bucket header table, which every lookup touches, so that the first lookups
+// don't pay for the page faults (or the remote reads). If full is true, the whole index is read.
+// It returns the number of bytes read.
+func (db *DB) Warmup(full bool) (int64, error) {
+	end := bucketOffset(db.headerSize, uint(db.Header.NumBuckets))
+	if full {
+		end = math.MaxInt64
+	}
+	return readRange(db.Stream, 0, end)
+}
+
+const warmupChunkSize = 1 << 20
+
+// readRange reads the stream from start to end (or EOF) in chunks, and returns the number of bytes read.
+func readRange(stream io.ReaderAt, start, end int64) (int64, error) {
+	buf := make([]byte, warmupChunkSize)
+	var total int64
+	for off := start; off < end; {
+		size := minInt64(int64(len(buf)), end-off)
+		n, err := stream.ReadAt(buf[:size], off)
+		total += int64(n)
+		off += int64(n)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
 func minInt64(a, b int64) int64 {
 	if a < b {
 		return a
@@ -141,9 +202,9 @@ func minInt64(a, b int64) int64 {
 
 const HashSize = 3
 
-func (db *DB) entryStride() uint8 {
+func (db *DB) entryStride() uint16 {
 	offsetSize := db.GetValueSize()
-	return uint8(HashSize) + uint8(offsetSize)
+	return uint16(HashSize) + uint16(offsetSize)
 }
 
 func bucketOffset(headerSize int64, i uint) int64 {
@@ -79,6 +79,7 @@ func TestOpen_HeaderOnly(t *testing.T) {
 	assert.Equal(t, &Header{
 		ValueSize:  0x1337,
 		NumBuckets: 0x42,
+		Version:    Version,
 		Metadata: &indexmeta.Meta{
 			KeyVals: []indexmeta.KV{
 				{
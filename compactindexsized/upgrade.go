@@ -0,0 +1,70 @@
+package compactindexsized
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// readHeader reads and loads the header of the index, and returns its encoded size.
+func readHeader(src io.ReaderAt) (*Header, int64, error) {
+	db, err := Open(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	return db.Header, db.headerSize, nil
+}
+
+// upgradedHeader returns the Version2 encoding of the header.
+// It has the same size as the Version 1 encoding, so the rest of the index is unchanged.
+func upgradedHeader(header *Header, headerSize int64) ([]byte, error) {
+	upgraded := *header
+	upgraded.Version = Version2
+	buf := upgraded.Bytes()
+	if int64(len(buf)) != headerSize {
+		return nil, fmt.Errorf("upgraded header size mismatch: %d != %d", len(buf), headerSize)
+	}
+	return buf, nil
+}
+
+// UpgradeInPlace upgrades the index file to the latest version by rewriting its header,
+// and returns the version the index had before.
+// Indexes that are already at the latest version are left untouched.
+func UpgradeInPlace(file *os.File) (uint8, error) {
+	header, headerSize, err := readHeader(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read header: %w", err)
+	}
+	if header.Version == LatestVersion {
+		return header.Version, nil
+	}
+	buf, err := upgradedHeader(header, headerSize)
+	if err != nil {
+		return header.Version, err
+	}
+	if _, err := file.WriteAt(buf, 0); err != nil {
+		return header.Version, fmt.Errorf("failed to write header: %w", err)
+	}
+	return header.Version, file.Sync()
+}
+
+// Upgrade writes a copy of the index src, upgraded to the latest version, to dst,
+// and returns the version src has.
+func Upgrade(dst io.Writer, src io.ReaderAt) (uint8, error) {
+	header, headerSize, err := readHeader(src)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read header: %w", err)
+	}
+	buf, err := upgradedHeader(header, headerSize)
+	if err != nil {
+		return header.Version, err
+	}
+	if _, err := dst.Write(buf); err != nil {
+		return header.Version, fmt.Errorf("failed to write header: %w", err)
+	}
+	if _, err := io.Copy(dst, io.NewSectionReader(src, headerSize, math.MaxInt64-headerSize)); err != nil {
+		return header.Version, fmt.Errorf("failed to copy index: %w", err)
+	}
+	return header.Version, nil
+}
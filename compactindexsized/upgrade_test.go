@@ -0,0 +1,101 @@
+package compactindexsized
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func buildTestIndex(t *testing.T, valueSize uint, values map[string][]byte) string {
+	t.Helper()
+	builder, err := NewBuilderSized("", uint(len(values)), valueSize)
+	require.NoError(t, err)
+	defer builder.Close()
+	for key, value := range values {
+		require.NoError(t, builder.Insert([]byte(key), value))
+	}
+	path := filepath.Join(t.TempDir(), "test.index")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	require.NoError(t, builder.Seal(context.TODO(), file))
+	return path
+}
+
+func requireLookups(t *testing.T, path string, version uint8, values map[string][]byte) {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	db, err := Open(file)
+	require.NoError(t, err)
+	require.Equal(t, version, db.Header.Version)
+	for key, value := range values {
+		got, err := db.Lookup([]byte(key))
+		require.NoError(t, err)
+		require.Equal(t, value, got)
+	}
+	_, err = db.Lookup([]byte("missing"))
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestBuilderWideValues(t *testing.T) {
+	const valueSize = 300
+	values := map[string][]byte{
+		"hello": bytes.Repeat([]byte{1}, valueSize),
+		"world": bytes.Repeat([]byte{2}, valueSize),
+		"blub":  bytes.Repeat([]byte{3}, valueSize),
+	}
+	path := buildTestIndex(t, valueSize, values)
+	requireLookups(t, path, Version2, values)
+
+	_, err := NewBuilderSized("", 1, MaxValueSize+1)
+	require.Error(t, err)
+}
+
+func TestUpgrade(t *testing.T) {
+	values := map[string][]byte{
+		"hello": itob(1),
+		"world": itob(2),
+		"blub":  itob(3),
+	}
+
+	t.Run("in place", func(t *testing.T) {
+		path := buildTestIndex(t, 8, values)
+		requireLookups(t, path, Version, values)
+
+		file, err := os.OpenFile(path, os.O_RDWR, 0)
+		require.NoError(t, err)
+		defer file.Close()
+		from, err := UpgradeInPlace(file)
+		require.NoError(t, err)
+		require.Equal(t, Version, from)
+		requireLookups(t, path, Version2, values)
+
+		// Upgrading again is a no-op.
+		from, err = UpgradeInPlace(file)
+		require.NoError(t, err)
+		require.Equal(t, Version2, from)
+		requireLookups(t, path, Version2, values)
+	})
+
+	t.Run("rewrite", func(t *testing.T) {
+		path := buildTestIndex(t, 8, values)
+		original, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		var upgraded bytes.Buffer
+		from, err := Upgrade(&upgraded, bytes.NewReader(original))
+		require.NoError(t, err)
+		require.Equal(t, Version, from)
+		require.Equal(t, len(original), upgraded.Len())
+
+		upgradedPath := filepath.Join(t.TempDir(), "upgraded.index")
+		require.NoError(t, os.WriteFile(upgradedPath, upgraded.Bytes(), 0o644))
+		requireLookups(t, upgradedPath, Version2, values)
+	})
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"sort"
 
+	"github.com/gagliardetto/solana-go"
 	"github.com/ipfs/go-cid"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
@@ -21,6 +23,14 @@ func (u URI) String() string {
 	return string(u)
 }
 
+func urisToStrings(uris []URI) []string {
+	out := make([]string, len(uris))
+	for i, u := range uris {
+		out[i] = u.String()
+	}
+	return out
+}
+
 // IsZero returns true if the URI is empty.
 func (u URI) IsZero() bool {
 	return u == ""
@@ -101,7 +111,8 @@ func hashFileSha256(filePath string) (string, error) {
 }
 
 type PieceURLInfo struct {
-	URI URI `json:"uri" yaml:"uri"` // URL to the piece.
+	URI     URI   `json:"uri" yaml:"uri"`         // URL to the piece.
+	Mirrors []URI `json:"mirrors" yaml:"mirrors"` // Other URLs serving the same piece, used when URI fails.
 }
 
 type Config struct {
@@ -109,9 +120,16 @@ type Config struct {
 	hashOfConfigFile string
 	Epoch            *uint64 `json:"epoch" yaml:"epoch"`
 	Version          *uint64 `json:"version" yaml:"version"`
-	Data             struct {
+	// Network (optional) is the namespace of the epoch (e.g. mainnet, testnet), to serve several
+	// archives from one server; the epochs without a network are in the default namespace.
+	Network string `json:"network" yaml:"network"`
+	Data    struct {
 		Car *struct {
-			URI        URI `json:"uri" yaml:"uri"`
+			URI URI `json:"uri" yaml:"uri"`
+			// Mirrors are other URLs serving the same (remote) CAR file, used when URI fails.
+			Mirrors []URI `json:"mirrors" yaml:"mirrors"`
+			// Sha256 is the (hex) SHA256 of the CAR file; if set, the indexes must have been generated from it.
+			Sha256     string `json:"sha256" yaml:"sha256"`
 			FromPieces *struct {
 				Metadata struct {
 					URI URI `json:"uri" yaml:"uri"` // Local path to the metadata file.
@@ -145,16 +163,85 @@ type Config struct {
 		Gsfa struct {
 			URI URI `json:"uri" yaml:"uri"`
 		} `json:"gsfa" yaml:"gsfa"`
+		// Gpfa (optional) is the index of the transactions of each invoked program (faithful-cli index gpfa);
+		// if set, the faithful_getSignaturesForProgram method is enabled.
+		Gpfa struct {
+			URI URI `json:"uri" yaml:"uri"`
+		} `json:"gpfa" yaml:"gpfa"`
+		// TokenBalances (optional) is the index of the transactions that changed the token balance of
+		// each owner and mint (faithful-cli index token-balances); if set, the
+		// faithful_getSignaturesForTokenOwner method is enabled.
+		TokenBalances struct {
+			URI URI `json:"uri" yaml:"uri"`
+		} `json:"token_balances" yaml:"token_balances"`
 		SigExists struct {
 			URI URI `json:"uri" yaml:"uri"`
 		} `json:"sig_exists" yaml:"sig_exists"`
 		SlotToBlocktime struct {
 			URI URI `json:"uri" yaml:"uri"`
 		} `json:"slot_to_blocktime" yaml:"slot_to_blocktime"`
+		// BlockManifest (optional) lists the blocks of the epoch (faithful-cli index block-manifest);
+		// if set, it's used to answer getBlocks and getBlocksWithLimit.
+		BlockManifest struct {
+			URI URI `json:"uri" yaml:"uri"`
+		} `json:"block_manifest" yaml:"block_manifest"`
+		// LookupTables (optional) is a snapshot of the address lookup tables used in the epoch
+		// (faithful-cli index lookup-tables); if set, it's used to resolve the accounts loaded by
+		// the v0 transactions whose meta doesn't have them, for the jsonParsed encoding.
+		LookupTables struct {
+			URI URI `json:"uri" yaml:"uri"`
+		} `json:"lookup_tables" yaml:"lookup_tables"`
+		// Server is an index server (faithful-cli index-server) that answers the cid_to_offset_and_size,
+		// slot_to_cid, sig_to_cid and sig_exists lookups of this epoch; those index files are then not opened.
+		Server struct {
+			URI URI `json:"uri" yaml:"uri"`
+		} `json:"server" yaml:"server"`
 	} `json:"indexes" yaml:"indexes"`
 	Genesis struct {
 		URI URI `json:"uri" yaml:"uri"`
+		// Hash and CreationTime (unix seconds) can be used instead of URI
+		// when the genesis file of the cluster is not available.
+		Hash         string `json:"hash" yaml:"hash"`
+		CreationTime *int64 `json:"creation_time" yaml:"creation_time"`
 	} `json:"genesis" yaml:"genesis"`
+	// LeaderSchedule (optional) is the leader schedule of the epoch, used to return the leader of
+	// the blocks: either a file (URI) in the format of the getLeaderSchedule RPC method, or the
+	// stakes (in lamports) of the validator identities, from which it's computed like Solana does.
+	LeaderSchedule struct {
+		URI    URI               `json:"uri" yaml:"uri"`
+		Stakes map[string]uint64 `json:"stakes" yaml:"stakes"`
+	} `json:"leader_schedule" yaml:"leader_schedule"`
+	// MetaOverlay (optional) is the metadata of transactions that is missing from the CAR, fetched from
+	// another source (faithful-cli backfill-tx-meta): a CAR with the transactions and their metadata, and
+	// its index by signature; getTransaction returns that metadata for the transactions that have none in the CAR.
+	MetaOverlay struct {
+		Car struct {
+			URI URI `json:"uri" yaml:"uri"`
+		} `json:"car" yaml:"car"`
+		Index struct {
+			URI URI `json:"uri" yaml:"uri"`
+		} `json:"index" yaml:"index"`
+	} `json:"meta_overlay" yaml:"meta_overlay"`
+	// Overlay (optional) is a CAR with corrected blocks of the epoch (faithful-cli car-patch --overlay),
+	// and its cid-to-offset-and-size index; its nodes take precedence over the ones of the epoch CAR.
+	Overlay struct {
+		Car struct {
+			URI URI `json:"uri" yaml:"uri"`
+		} `json:"car" yaml:"car"`
+		Index struct {
+			URI URI `json:"uri" yaml:"uri"`
+		} `json:"index" yaml:"index"`
+	} `json:"overlay" yaml:"overlay"`
+}
+
+// HasMetaOverlay returns true if the epoch has a meta overlay.
+func (c *Config) HasMetaOverlay() bool {
+	return !c.MetaOverlay.Car.URI.IsZero()
+}
+
+// HasOverlay returns true if the epoch has an overlay of corrected blocks.
+func (c *Config) HasOverlay() bool {
+	return !c.Overlay.Car.URI.IsZero()
 }
 
 // IsDeprecatedIndexes returns true if the config is using the deprecated indexes version.
@@ -163,6 +250,11 @@ func (c *Config) IsDeprecatedIndexes() bool {
 	return c.Indexes.CidToOffsetAndSize.URI.IsZero() && !c.Indexes.CidToOffset.URI.IsZero()
 }
 
+// UsesIndexServer returns true if the lookup indexes of the epoch are served by an index server.
+func (c *Config) UsesIndexServer() bool {
+	return !c.Indexes.Server.URI.IsZero()
+}
+
 func (c *Config) ConfigFilepath() string {
 	return c.originalFilepath
 }
@@ -206,15 +298,25 @@ func (c ConfigSlice) Validate() error {
 		}
 	}
 	{
-		// Check that all epochs are unique.
-		epochs := make(map[uint64][]string)
+		// Check that all epochs are unique (in each network).
+		type networkEpoch struct {
+			network string
+			epoch   uint64
+		}
+		epochs := make(map[networkEpoch][]string)
 		for _, config := range c {
-			epochs[*config.Epoch] = append(epochs[*config.Epoch], config.originalFilepath)
+			key := networkEpoch{config.Network, *config.Epoch}
+			epochs[key] = append(epochs[key], config.originalFilepath)
 		}
 		multiErrors := make([]error, 0)
-		for epoch, configFiles := range epochs {
-			if len(configFiles) > 1 {
-				multiErrors = append(multiErrors, fmt.Errorf("epoch %d is defined in multiple config files: %v", epoch, configFiles))
+		for key, configFiles := range epochs {
+			if len(configFiles) <= 1 {
+				continue
+			}
+			if key.network == "" {
+				multiErrors = append(multiErrors, fmt.Errorf("epoch %d is defined in multiple config files: %v", key.epoch, configFiles))
+			} else {
+				multiErrors = append(multiErrors, fmt.Errorf("epoch %d of network %q is defined in multiple config files: %v", key.epoch, key.network, configFiles))
 			}
 		}
 		if len(multiErrors) > 0 {
@@ -224,6 +326,20 @@ func (c ConfigSlice) Validate() error {
 	return nil
 }
 
+// Networks returns the distinct networks of the configs, sorted.
+func (c ConfigSlice) Networks() []string {
+	seen := make(map[string]struct{})
+	networks := make([]string, 0)
+	for _, config := range c {
+		if _, ok := seen[config.Network]; !ok {
+			seen[config.Network] = struct{}{}
+			networks = append(networks, config.Network)
+		}
+	}
+	sort.Strings(networks)
+	return networks
+}
+
 func (c ConfigSlice) SortByEpoch() {
 	sort.Slice(c, func(i, j int) bool {
 		return *c[i].Epoch < *c[j].Epoch
@@ -238,6 +354,15 @@ func isSupportedURI(uri URI, path string) error {
 	return nil
 }
 
+func areRemoteWebMirrors(mirrors []URI, path string) error {
+	for i, mirror := range mirrors {
+		if !mirror.IsRemoteWeb() {
+			return fmt.Errorf("%s[%d] must be a remote web URI", path, i)
+		}
+	}
+	return nil
+}
+
 // Validate checks the config for errors.
 func (c *Config) Validate() error {
 	if c.Epoch == nil {
@@ -249,11 +374,33 @@ func (c *Config) Validate() error {
 	if *c.Version != ConfigVersion {
 		return fmt.Errorf("version must be %d", ConfigVersion)
 	}
+	if c.Network != "" {
+		if err := validateNetworkName(c.Network); err != nil {
+			return fmt.Errorf("network: %w", err)
+		}
+	}
 	// Distinguish between CAR-mode and Filecoin-mode.
 	// In CAR-mode, the data is fetched from a CAR file (local or remote).
 	// In Filecoin-mode, the data is fetched from Filecoin directly (by CID via Lassie).
 	isFilecoinMode := c.IsFilecoinMode()
 	isCarMode := !isFilecoinMode
+	usesIndexServer := c.UsesIndexServer()
+	if usesIndexServer {
+		if !c.Indexes.Server.URI.IsRemoteWeb() {
+			return fmt.Errorf("indexes.server.uri must be a remote web URI")
+		}
+		for path, uri := range map[string]URI{
+			"indexes.cid_to_offset_and_size.uri": c.Indexes.CidToOffsetAndSize.URI,
+			"indexes.cid_to_offset.uri":          c.Indexes.CidToOffset.URI,
+			"indexes.slot_to_cid.uri":            c.Indexes.SlotToCid.URI,
+			"indexes.sig_to_cid.uri":             c.Indexes.SigToCid.URI,
+			"indexes.sig_exists.uri":             c.Indexes.SigExists.URI,
+		} {
+			if !uri.IsZero() {
+				return fmt.Errorf("%s cannot be set when indexes.server.uri is set", path)
+			}
+		}
+	}
 	if isCarMode {
 		if c.Data.Car == nil {
 			return fmt.Errorf("car-mode=true; data.car must be set")
@@ -266,6 +413,19 @@ func (c *Config) Validate() error {
 				return err
 			}
 		}
+		if c.Data.Car.Sha256 != "" {
+			if sum, err := hex.DecodeString(c.Data.Car.Sha256); err != nil || len(sum) != sha256.Size {
+				return fmt.Errorf("data.car.sha256 must be a hex-encoded SHA256")
+			}
+		}
+		if len(c.Data.Car.Mirrors) > 0 {
+			if !c.Data.Car.URI.IsRemoteWeb() {
+				return fmt.Errorf("data.car.mirrors can only be set if data.car.uri is a remote web URI")
+			}
+			if err := areRemoteWebMirrors(c.Data.Car.Mirrors, "data.car.mirrors"); err != nil {
+				return err
+			}
+		}
 		// can't have both:
 		if !c.Data.Car.URI.IsZero() && c.Data.Car.FromPieces != nil {
 			return fmt.Errorf("data.car.uri and data.car.from_pieces cannot both be set")
@@ -300,6 +460,9 @@ func (c *Config) Validate() error {
 						if !uri.URI.IsRemoteWeb() {
 							return fmt.Errorf("data.car.from_pieces.piece_to_uri[%s].uri must be a remote web URI", pieceCID)
 						}
+						if err := areRemoteWebMirrors(uri.Mirrors, fmt.Sprintf("data.car.from_pieces.piece_to_uri[%s].mirrors", pieceCID)); err != nil {
+							return err
+						}
 					}
 				}
 			}
@@ -308,7 +471,7 @@ func (c *Config) Validate() error {
 		if !c.Indexes.CidToOffsetAndSize.URI.IsZero() && !c.Indexes.CidToOffset.URI.IsZero() {
 			return fmt.Errorf("indexes.cid_to_offset_and_size.uri and indexes.cid_to_offset.uri cannot both be set")
 		}
-		if c.Indexes.CidToOffsetAndSize.URI.IsZero() && c.Indexes.CidToOffset.URI.IsZero() {
+		if c.Indexes.CidToOffsetAndSize.URI.IsZero() && c.Indexes.CidToOffset.URI.IsZero() && !usesIndexServer {
 			return fmt.Errorf("indexes.cid_to_offset_and_size.uri and indexes.cid_to_offset.uri cannot both be unset")
 		}
 		// validate CidToOffsetAndSize URI:
@@ -343,7 +506,7 @@ func (c *Config) Validate() error {
 
 	}
 
-	{
+	if !usesIndexServer {
 		{
 			if c.Indexes.SlotToCid.URI.IsZero() {
 				return fmt.Errorf("indexes.slot_to_cid.uri must be set")
@@ -368,13 +531,13 @@ func (c *Config) Validate() error {
 				return err
 			}
 		}
-		{
-			if c.Indexes.SlotToBlocktime.URI.IsZero() {
-				return fmt.Errorf("indexes.slot_to_blocktime.uri must be set")
-			}
-			if err := isSupportedURI(c.Indexes.SlotToBlocktime.URI, "indexes.slot_to_blocktime.uri"); err != nil {
-				return err
-			}
+	}
+	{
+		if c.Indexes.SlotToBlocktime.URI.IsZero() {
+			return fmt.Errorf("indexes.slot_to_blocktime.uri must be set")
+		}
+		if err := isSupportedURI(c.Indexes.SlotToBlocktime.URI, "indexes.slot_to_blocktime.uri"); err != nil {
+			return err
 		}
 	}
 	{
@@ -399,14 +562,20 @@ func (c *Config) Validate() error {
 				}
 			}
 		}
-		if !c.Indexes.SlotToCid.URI.IsValid() {
-			return fmt.Errorf("indexes.slot_to_cid.uri is invalid")
-		}
-		if !c.Indexes.SigToCid.URI.IsValid() {
-			return fmt.Errorf("indexes.sig_to_cid.uri is invalid")
-		}
-		if !c.Indexes.SigExists.URI.IsValid() {
-			return fmt.Errorf("indexes.sig_exists.uri is invalid")
+		if usesIndexServer {
+			if !c.Indexes.Server.URI.IsValid() {
+				return fmt.Errorf("indexes.server.uri is invalid")
+			}
+		} else {
+			if !c.Indexes.SlotToCid.URI.IsValid() {
+				return fmt.Errorf("indexes.slot_to_cid.uri is invalid")
+			}
+			if !c.Indexes.SigToCid.URI.IsValid() {
+				return fmt.Errorf("indexes.sig_to_cid.uri is invalid")
+			}
+			if !c.Indexes.SigExists.URI.IsValid() {
+				return fmt.Errorf("indexes.sig_exists.uri is invalid")
+			}
 		}
 		{
 			if !c.Indexes.Gsfa.URI.IsZero() && !c.Indexes.Gsfa.URI.IsValid() {
@@ -417,22 +586,105 @@ func (c *Config) Validate() error {
 				return fmt.Errorf("indexes.gsfa.uri must be a local directory")
 			}
 		}
+		{
+			if !c.Indexes.Gpfa.URI.IsZero() && !c.Indexes.Gpfa.URI.IsValid() {
+				return fmt.Errorf("indexes.gpfa.uri is invalid")
+			}
+			// gpfa index (optional), if set, must be a local directory:
+			if !c.Indexes.Gpfa.URI.IsZero() && !c.Indexes.Gpfa.URI.IsLocal() {
+				return fmt.Errorf("indexes.gpfa.uri must be a local directory")
+			}
+		}
+		{
+			if !c.Indexes.TokenBalances.URI.IsZero() && !c.Indexes.TokenBalances.URI.IsValid() {
+				return fmt.Errorf("indexes.token_balances.uri is invalid")
+			}
+			// token balances index (optional), if set, must be a local directory:
+			if !c.Indexes.TokenBalances.URI.IsZero() && !c.Indexes.TokenBalances.URI.IsLocal() {
+				return fmt.Errorf("indexes.token_balances.uri must be a local directory")
+			}
+		}
 		if !c.Indexes.SlotToBlocktime.URI.IsValid() {
 			return fmt.Errorf("indexes.slot_to_blocktime.uri is invalid")
 		}
+		if !c.Indexes.BlockManifest.URI.IsZero() && !c.Indexes.BlockManifest.URI.IsValid() {
+			return fmt.Errorf("indexes.block_manifest.uri is invalid")
+		}
+		if !c.Indexes.LookupTables.URI.IsZero() && !c.Indexes.LookupTables.URI.IsValid() {
+			return fmt.Errorf("indexes.lookup_tables.uri is invalid")
+		}
+	}
+	{
+		// the meta overlay (optional) is a CAR and its index; both or neither must be set:
+		if c.MetaOverlay.Car.URI.IsZero() != c.MetaOverlay.Index.URI.IsZero() {
+			return fmt.Errorf("meta_overlay.car.uri and meta_overlay.index.uri must be set together")
+		}
+		if c.HasMetaOverlay() {
+			if err := isSupportedURI(c.MetaOverlay.Car.URI, "meta_overlay.car.uri"); err != nil {
+				return err
+			}
+			if err := isSupportedURI(c.MetaOverlay.Index.URI, "meta_overlay.index.uri"); err != nil {
+				return err
+			}
+		}
+	}
+	{
+		// the overlay (optional) is a CAR and its index; both or neither must be set:
+		if c.Overlay.Car.URI.IsZero() != c.Overlay.Index.URI.IsZero() {
+			return fmt.Errorf("overlay.car.uri and overlay.index.uri must be set together")
+		}
+		if c.HasOverlay() {
+			if err := isSupportedURI(c.Overlay.Car.URI, "overlay.car.uri"); err != nil {
+				return err
+			}
+			if err := isSupportedURI(c.Overlay.Index.URI, "overlay.index.uri"); err != nil {
+				return err
+			}
+		}
 	}
 	{
 		// if epoch is 0, then the genesis URI must be set:
 		if *c.Epoch == 0 {
-			if c.Genesis.URI.IsZero() {
-				return fmt.Errorf("epoch is 0, but genesis.uri is not set")
+			hasConstants := c.Genesis.Hash != "" || c.Genesis.CreationTime != nil
+			if hasConstants {
+				if !c.Genesis.URI.IsZero() {
+					return fmt.Errorf("genesis.uri and genesis.hash/genesis.creation_time cannot both be set")
+				}
+				if c.Genesis.Hash == "" || c.Genesis.CreationTime == nil {
+					return fmt.Errorf("genesis.hash and genesis.creation_time must be set together")
+				}
+				if _, err := solana.HashFromBase58(c.Genesis.Hash); err != nil {
+					return fmt.Errorf("genesis.hash is invalid: %w", err)
+				}
+			} else {
+				if c.Genesis.URI.IsZero() {
+					return fmt.Errorf("epoch is 0, but genesis.uri (or genesis.hash and genesis.creation_time) is not set")
+				}
+				if !c.Genesis.URI.IsValid() {
+					return fmt.Errorf("genesis.uri is invalid")
+				}
+				// only support local genesis files for now:
+				if !c.Genesis.URI.IsLocal() {
+					return fmt.Errorf("genesis.uri must be a local file")
+				}
 			}
-			if !c.Genesis.URI.IsValid() {
-				return fmt.Errorf("genesis.uri is invalid")
+		}
+	}
+	{
+		if !c.LeaderSchedule.URI.IsZero() {
+			if len(c.LeaderSchedule.Stakes) > 0 {
+				return fmt.Errorf("leader_schedule.uri and leader_schedule.stakes cannot both be set")
 			}
-			// only support local genesis files for now:
-			if !c.Genesis.URI.IsLocal() {
-				return fmt.Errorf("genesis.uri must be a local file")
+			if !c.LeaderSchedule.URI.IsValid() {
+				return fmt.Errorf("leader_schedule.uri is invalid")
+			}
+			if !c.LeaderSchedule.URI.IsLocal() {
+				return fmt.Errorf("leader_schedule.uri must be a local file")
+			}
+		}
+		for identity := range c.LeaderSchedule.Stakes {
+			if _, err := solana.PublicKeyFromBase58(identity); err != nil {
+				return fmt.Errorf("leader_schedule.stakes has an invalid identity %q: %w", identity, err)
 			}
 		}
 	}
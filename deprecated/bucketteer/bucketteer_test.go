@@ -3,9 +3,11 @@ package bucketteer
 import (
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	bin "github.com/gagliardetto/binary"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/exp/mmap"
 )
@@ -186,3 +188,66 @@ func getFizeSize(path string) (int64, error) {
 	}
 	return info.Size(), nil
 }
+
+func TestReaderHasBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test-bucketteer")
+	wr, err := NewWriter(path)
+	require.NoError(t, err)
+	var present, absent [][64]byte
+	for i := 0; i < 3000; i++ {
+		// one bucket bigger than what HasBatch reads at once, and a few small ones.
+		sig := [64]byte{1, 2, byte(i), byte(i >> 8)}
+		if i%100 == 0 {
+			sig[0] = byte(10 + i/100)
+		}
+		if i%3 == 0 {
+			absent = append(absent, sig)
+			continue
+		}
+		wr.Put(sig)
+		present = append(present, sig)
+	}
+	absent = append(absent, [64]byte{200, 1})
+	_, err = wr.Seal(map[string]string{"epoch": "test"})
+	require.NoError(t, err)
+	require.NoError(t, wr.Close())
+
+	mmr, err := mmap.Open(path)
+	require.NoError(t, err)
+	defer mmr.Close()
+	reader, err := NewReader(mmr)
+	require.NoError(t, err)
+
+	sigs := append(append([][64]byte{}, absent...), present...)
+	found, err := reader.HasBatch(sigs)
+	require.NoError(t, err)
+	for i, sig := range sigs {
+		require.Equal(t, i >= len(absent), found[i], i)
+		has, err := reader.Has(sig)
+		require.NoError(t, err)
+		require.Equal(t, found[i], has, i)
+	}
+	found, err = reader.HasBatch(nil)
+	require.NoError(t, err)
+	require.Empty(t, found)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, sig := range present {
+				has, err := reader.Has(sig)
+				if !assert.NoError(t, err) || !assert.True(t, has) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		reader.Has(present[0])
+	})
+	require.Zero(t, allocs)
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"sync"
 
 	bin "github.com/gagliardetto/binary"
 	"golang.org/x/exp/mmap"
@@ -187,61 +189,140 @@ func readHeader(reader io.ReaderAt) (map[[2]byte]uint64, map[string]string, int6
 	return prefixToOffset, nil, headerSize + 4, err
 }
 
+// Has tells if the signature is in the index. It's safe for concurrent use, and doesn't
+// allocate once warmed up (the read buffers are pooled).
 func (r *Reader) Has(sig [64]byte) (bool, error) {
-	prefix := [2]byte{sig[0], sig[1]}
-	offset, ok := r.prefixToOffset[prefix]
+	offset, ok := r.prefixToOffset[[2]byte{sig[0], sig[1]}]
 	if !ok {
 		return false, nil
 	}
-	// numHashes:
-	numHashesBuf := make([]byte, 4)
-	_, err := r.contentReader.ReadAt(numHashesBuf, int64(offset))
+	s := getScratch()
+	defer putScratch(s)
+	numHashes, err := r.readNumHashes(s, offset)
 	if err != nil {
 		return false, err
 	}
-	numHashes := binary.LittleEndian.Uint32(numHashesBuf)
-	bucketReader := io.NewSectionReader(r.contentReader, int64(offset)+4, int64(numHashes*8))
+	return r.searchBucket(s, offset, numHashes, nil, Hash(sig))
+}
+
+// batchBucketPrefixHashes is how many hashes from the start of a bucket HasBatch reads at once
+// for the signatures of the batch in that bucket: the top levels of the Eytzinger layout, which
+// every search in the bucket goes through. The buckets that small are searched in memory.
+const batchBucketPrefixHashes = 511
 
-	// hashes:
-	wantedHash := Hash(sig)
-	got, err := searchEytzinger(0, int(numHashes), wantedHash, func(index int) (uint64, error) {
-		pos := int64(index * 8)
-		return readUint64Le(bucketReader, pos)
+// HasBatch tells, for each of the signatures, if it is in the index. The signatures are grouped
+// by bucket: the count and the top levels of a bucket are read once for all of its signatures,
+// so a batch costs much fewer reads than a Has per signature. It's safe for concurrent use.
+func (r *Reader) HasBatch(sigs [][64]byte) ([]bool, error) {
+	found := make([]bool, len(sigs))
+	order := make([]int, 0, len(sigs))
+	for i := range sigs {
+		if _, ok := r.prefixToOffset[[2]byte{sigs[i][0], sigs[i][1]}]; ok {
+			order = append(order, i)
+		}
+	}
+	if len(order) == 0 {
+		return found, nil
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return bytes.Compare(sigs[order[a]][:2], sigs[order[b]][:2]) < 0
 	})
-	if err != nil {
-		if err == ErrNotFound {
-			return false, nil
+	s := getScratch()
+	defer putScratch(s)
+	for start := 0; start < len(order); {
+		prefix := [2]byte{sigs[order[start]][0], sigs[order[start]][1]}
+		end := start + 1
+		for end < len(order) && sigs[order[end]][0] == prefix[0] && sigs[order[end]][1] == prefix[1] {
+			end++
 		}
-		return false, err
+		offset := r.prefixToOffset[prefix]
+		top, numHashes, err := r.readBucketPrefix(s, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range order[start:end] {
+			found[i], err = r.searchBucket(s, offset, numHashes, top, Hash(sigs[i]))
+			if err != nil {
+				return nil, err
+			}
+		}
+		start = end
 	}
-	return got == wantedHash, nil
+	return found, nil
 }
 
-func searchEytzinger(min int, max int, x uint64, getter func(int) (uint64, error)) (uint64, error) {
-	var index int
-	for index < max {
-		k, err := getter(index)
-		if err != nil {
-			return 0, err
+// scratch holds the read buffers of a lookup.
+type scratch struct {
+	word [8]byte
+	top  []byte
+}
+
+var scratchPool = sync.Pool{
+	New: func() any {
+		return &scratch{top: make([]byte, 4+8*batchBucketPrefixHashes)}
+	},
+}
+
+func getScratch() *scratch {
+	return scratchPool.Get().(*scratch)
+}
+
+func putScratch(s *scratch) {
+	scratchPool.Put(s)
+}
+
+func (r *Reader) readNumHashes(s *scratch, offset uint64) (uint32, error) {
+	if _, err := r.contentReader.ReadAt(s.word[:4], int64(offset)); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(s.word[:4]), nil
+}
+
+// readBucketPrefix reads the count and the first hashes (up to batchBucketPrefixHashes) of the
+// bucket at offset into s.top, and returns the hashes read.
+func (r *Reader) readBucketPrefix(s *scratch, offset uint64) ([]byte, uint32, error) {
+	n, err := r.contentReader.ReadAt(s.top, int64(offset))
+	if n < 4 {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
 		}
-		if k == x {
-			return k, nil
+		return nil, 0, err
+	}
+	numHashes := binary.LittleEndian.Uint32(s.top[:4])
+	want := 4 + 8*int(min(numHashes, batchBucketPrefixHashes))
+	if n < want {
+		// the bucket is cut short.
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	return s.top[4:want], numHashes, nil
+}
+
+// searchBucket searches the hash in the Eytzinger layout of the bucket at offset; top holds the
+// first hashes of the bucket (or none), the others are read.
+func (r *Reader) searchBucket(s *scratch, offset uint64, numHashes uint32, top []byte, wanted uint64) (bool, error) {
+	base := int64(offset) + 4
+	for index := 0; index < int(numHashes); {
+		var k uint64
+		if pos := index * 8; pos < len(top) {
+			k = binary.LittleEndian.Uint64(top[pos:])
+		} else {
+			if _, err := r.contentReader.ReadAt(s.word[:], base+int64(pos)); err != nil {
+				return false, err
+			}
+			k = binary.LittleEndian.Uint64(s.word[:])
+		}
+		if k == wanted {
+			return true, nil
 		}
 		index = index<<1 | 1
-		if k < x {
+		if k < wanted {
 			index++
 		}
 	}
-	return 0, ErrNotFound
+	return false, nil
 }
 
 var ErrNotFound = fmt.Errorf("not found")
-
-func readUint64Le(reader io.ReaderAt, pos int64) (uint64, error) {
-	buf := make([]byte, 8)
-	_, err := reader.ReadAt(buf, pos)
-	if err != nil {
-		return 0, err
-	}
-	return binary.LittleEndian.Uint64(buf), nil
-}
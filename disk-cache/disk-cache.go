@@ -0,0 +1,250 @@
+// Package diskcache is a bounded, content-addressed on-disk cache of raw CAR objects.
+//
+// Objects are stored as one file per CID under <dir>/<xx>/<cid>, where <xx> are the last
+// two characters of the CID (to keep directories small). Writes are atomic (tmp file + rename),
+// and objects are verified against their CID when read, so a corrupted or truncated file
+// is dropped instead of being served. When the total size exceeds the limit, the least
+// recently used objects are evicted.
+package diskcache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"k8s.io/klog/v2"
+)
+
+const tmpSuffix = ".tmp"
+
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // front is the most recently used
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key  string
+	size int64
+}
+
+// New opens (or creates) a disk cache in the given directory, limited to maxSize bytes.
+// Objects already in the directory are kept, oldest-modified first in line for eviction.
+func New(dir string, maxSize int64) (*Cache, error) {
+	if dir == "" {
+		return nil, errors.New("dir must not be empty")
+	}
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("max size must be positive, got %d", maxSize)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir %q: %w", dir, err)
+	}
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	if err := c.load(); err != nil {
+		return nil, fmt.Errorf("failed to load cache dir %q: %w", dir, err)
+	}
+	c.mu.Lock()
+	c.evictLocked()
+	c.mu.Unlock()
+	return c, nil
+}
+
+func (c *Cache) load() error {
+	type found struct {
+		key     string
+		size    int64
+		modTime int64
+	}
+	var all []found
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if strings.HasSuffix(path, tmpSuffix) {
+			// leftover of an interrupted write.
+			os.Remove(path)
+			return nil
+		}
+		key := d.Name()
+		if _, err := cid.Decode(key); err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		all = append(all, found{key: key, size: info.Size(), modTime: info.ModTime().UnixNano()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// newest first, so that the oldest end up at the back of the LRU list.
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].modTime > all[j].modTime
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range all {
+		c.entries[f.key] = c.lru.PushBack(&entry{key: f.key, size: f.size})
+		c.size += f.size
+	}
+	return nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[len(key)-2:], key)
+}
+
+// Get returns the object for the given CID, if it's in the cache.
+func (c *Cache) Get(k cid.Cid) ([]byte, bool, error) {
+	key := k.String()
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if ok {
+		c.lru.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			c.remove(key)
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if !verify(k, data) {
+		klog.Warningf("disk cache: object %s is corrupted; removing it", key)
+		c.remove(key)
+		return nil, false, nil
+	}
+	return data, true, nil
+}
+
+// Has returns true if the object for the given CID is in the cache.
+func (c *Cache) Has(k cid.Cid) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[k.String()]
+	return ok
+}
+
+// Put stores the object for the given CID; data that doesn't match the CID is rejected.
+func (c *Cache) Put(k cid.Cid, data []byte) error {
+	if int64(len(data)) > c.maxSize {
+		return nil
+	}
+	if !verify(k, data) {
+		return fmt.Errorf("data does not match CID %s", k)
+	}
+	key := k.String()
+	if c.Has(k) {
+		return nil
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+"-*"+tmpSuffix)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		// stored concurrently by someone else; same content.
+		c.lru.MoveToFront(el)
+		return nil
+	}
+	c.entries[key] = c.lru.PushFront(&entry{key: key, size: int64(len(data))})
+	c.size += int64(len(data))
+	c.evictLocked()
+	return nil
+}
+
+func (c *Cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.removeLocked(el)
+	}
+}
+
+func (c *Cache) removeLocked(el *list.Element) {
+	e := el.Value.(*entry)
+	c.lru.Remove(el)
+	delete(c.entries, e.key)
+	c.size -= e.size
+	if err := os.Remove(c.path(e.key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		klog.Warningf("disk cache: failed to remove %s: %s", e.key, err)
+	}
+}
+
+func (c *Cache) evictLocked() {
+	for c.size > c.maxSize {
+		el := c.lru.Back()
+		if el == nil {
+			return
+		}
+		c.removeLocked(el)
+	}
+}
+
+// Size returns the total size in bytes of the objects in the cache.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Len returns the number of objects in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func verify(k cid.Cid, data []byte) bool {
+	got, err := k.Prefix().Sum(data)
+	if err != nil {
+		return false
+	}
+	return got.Equals(k)
+}
@@ -0,0 +1,62 @@
+package diskcache
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func cidOf(t *testing.T, data []byte) cid.Cid {
+	t.Helper()
+	c, err := cid.V1Builder{Codec: cid.DagCBOR, MhType: multihash.SHA2_256}.Sum(data)
+	require.NoError(t, err)
+	return c
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 10)
+	require.NoError(t, err)
+
+	a, b, d := []byte("aaaa"), []byte("bbbb"), []byte("dddd")
+	ca, cb, cd := cidOf(t, a), cidOf(t, b), cidOf(t, d)
+
+	require.NoError(t, c.Put(ca, a))
+	require.NoError(t, c.Put(cb, b))
+	{
+		got, ok, err := c.Get(ca)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, a, got)
+	}
+	// ca was used more recently than cb, so cb is evicted.
+	require.NoError(t, c.Put(cd, d))
+	require.True(t, c.Has(ca))
+	require.False(t, c.Has(cb))
+	require.True(t, c.Has(cd))
+	require.Equal(t, int64(8), c.Size())
+
+	// data that doesn't match the CID is rejected.
+	require.Error(t, c.Put(cb, a))
+
+	t.Run("reopen", func(t *testing.T) {
+		reopened, err := New(dir, 10)
+		require.NoError(t, err)
+		require.Equal(t, 2, reopened.Len())
+		got, ok, err := reopened.Get(cd)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, d, got)
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(c.path(ca.String()), []byte("xxxx"), 0o644))
+		_, ok, err := c.Get(ca)
+		require.NoError(t, err)
+		require.False(t, ok)
+		require.False(t, c.Has(ca))
+	})
+}
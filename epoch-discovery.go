@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	splitcarfetcher "github.com/rpcpool/yellowstone-faithful/split-car-fetcher"
+	"k8s.io/klog/v2"
+)
+
+// maxDiscoveryDepth is how many levels of subdirectories of an HTML listing are followed.
+const maxDiscoveryDepth = 2
+
+// discoveredHeader starts the first line of the config files written by the discovery, which
+// is followed by the source they were discovered from; only those files are removed when their
+// epoch disappears from the listing.
+const discoveredHeader = "# Generated by the epoch discovery from "
+
+var (
+	discoveredCarName   = regexp.MustCompile(`^epoch-(\d+)\.car$`)
+	discoveredIndexName = regexp.MustCompile(`^epoch-(\d+)-([a-z0-9]+)-([a-z0-9]+)-([a-z-]+)\.index$`)
+	discoveredConfig    = regexp.MustCompile(`^epoch-\d+\.yaml$`)
+	htmlHref            = regexp.MustCompile(`(?i)href\s*=\s*["']([^"']+)["']`)
+)
+
+// discoveredIndexKinds maps the kind in the index file names to the config key of the index,
+// for the indexes of a standard layout; the ones marked required must all be present.
+var discoveredIndexKinds = []struct {
+	kind     string
+	key      string
+	required bool
+}{
+	{"cid-to-offset-and-size", "cid_to_offset_and_size", true},
+	{"slot-to-cid", "slot_to_cid", true},
+	{"sig-to-cid", "sig_to_cid", true},
+	{"sig-exists", "sig_exists", true},
+	{"slot-to-blocktime", "slot_to_blocktime", true},
+	{"block-manifest", "block_manifest", false},
+	{"lookup-tables", "lookup_tables", false},
+}
+
+// epochDiscovery lists S3 buckets or HTTP directories for the files of epochs named after the
+// standard layout (epoch-N.car and epoch-N-<root CID>-<network>-<kind>.index) and writes an
+// epoch config file for each complete epoch into dir.
+type epochDiscovery struct {
+	sources []string
+	dir     string
+	// genesisPath is the local genesis archive used for epoch 0, which is skipped without it.
+	genesisPath string
+	client      *http.Client
+}
+
+// discoveryResult is what a discovery run changed in the config directory.
+type discoveryResult struct {
+	Epochs  int      // the epochs discovered.
+	Written []string // the config files created or changed.
+	Removed []string // the config files of epochs that are no longer listed.
+}
+
+func newEpochDiscovery(sources []string, dir string, genesisPath string) (*epochDiscovery, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("the directory of the discovered epoch configs is not set")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if genesisPath != "" {
+		abs, err := filepath.Abs(genesisPath)
+		if err != nil {
+			return nil, err
+		}
+		genesisPath = abs
+	}
+	return &epochDiscovery{
+		sources:     sources,
+		dir:         dir,
+		genesisPath: genesisPath,
+		client:      splitcarfetcher.NewHTTPClient(),
+	}, nil
+}
+
+// Run lists the sources and updates the config files. The configs of a source that can't be
+// listed are left as they are, and the listing errors are returned with the result.
+func (d *epochDiscovery) Run(ctx context.Context) (*discoveryResult, error) {
+	configs := make(map[string]string) // config file name -> content
+	var failed []string
+	var errs []error
+	for _, source := range d.sources {
+		objects, err := d.list(ctx, source)
+		if err != nil {
+			failed = append(failed, source)
+			errs = append(errs, fmt.Errorf("failed to list %q: %w", source, err))
+			continue
+		}
+		for epoch, content := range d.epochConfigs(source, objects) {
+			name := fmt.Sprintf("epoch-%d.yaml", epoch)
+			if _, ok := configs[name]; ok {
+				klog.Warningf("Epoch %d is also in %q; using the first source that has it", epoch, source)
+				continue
+			}
+			configs[name] = content
+		}
+	}
+
+	result := &discoveryResult{Epochs: len(configs)}
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		configPath := filepath.Join(d.dir, name)
+		current, err := os.ReadFile(configPath)
+		if err == nil && string(current) == configs[name] {
+			continue
+		}
+		if err := os.WriteFile(configPath, []byte(configs[name]), 0o644); err != nil {
+			return result, err
+		}
+		result.Written = append(result.Written, configPath)
+	}
+
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return result, err
+	}
+	for _, entry := range entries {
+		if _, ok := configs[entry.Name()]; ok || entry.IsDir() || !discoveredConfig.MatchString(entry.Name()) {
+			continue
+		}
+		configPath := filepath.Join(d.dir, entry.Name())
+		source, ok := discoveredSource(configPath)
+		if !ok || slices.Contains(failed, source) {
+			// not written by the discovery, or its source could not be listed this time.
+			continue
+		}
+		if err := os.Remove(configPath); err != nil {
+			return result, err
+		}
+		result.Removed = append(result.Removed, configPath)
+	}
+	return result, errors.Join(errs...)
+}
+
+// discoveredSource returns the source of a config file written by the discovery.
+func discoveredSource(configPath string) (string, bool) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+	buf := make([]byte, 4096)
+	n, _ := io.ReadFull(file, buf)
+	line, _, _ := strings.Cut(string(buf[:n]), "\n")
+	return strings.CutPrefix(line, discoveredHeader)
+}
+
+// epochConfigs returns the config files of the complete epochs among the objects (file name
+// -> URL) of the source.
+func (d *epochDiscovery) epochConfigs(source string, objects map[string]string) map[uint64]string {
+	cars := make(map[uint64]string)
+	// epoch -> root CID and network -> kind -> URL
+	indexes := make(map[uint64]map[string]map[string]string)
+	for name, objectURL := range objects {
+		if match := discoveredCarName.FindStringSubmatch(name); match != nil {
+			epoch, err := strconv.ParseUint(match[1], 10, 64)
+			if err == nil {
+				cars[epoch] = objectURL
+			}
+			continue
+		}
+		match := discoveredIndexName.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		epoch, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if indexes[epoch] == nil {
+			indexes[epoch] = make(map[string]map[string]string)
+		}
+		set := match[2] + "-" + match[3]
+		if indexes[epoch][set] == nil {
+			indexes[epoch][set] = make(map[string]string)
+		}
+		indexes[epoch][set][match[4]] = objectURL
+	}
+
+	configs := make(map[uint64]string)
+	for epoch, carURL := range cars {
+		// the indexes of an older CAR of the epoch may still be there; only a complete set is used.
+		var complete []map[string]string
+		for _, kinds := range indexes[epoch] {
+			if hasRequiredIndexes(kinds) {
+				complete = append(complete, kinds)
+			}
+		}
+		switch {
+		case len(complete) == 0:
+			klog.Warningf("Skipping epoch %d of %q: the indexes are missing", epoch, source)
+			continue
+		case len(complete) > 1:
+			klog.Warningf("Skipping epoch %d of %q: there are indexes for %d different CARs", epoch, source, len(complete))
+			continue
+		}
+		var genesisPath string
+		if epoch == 0 {
+			if d.genesisPath == "" {
+				klog.Warningf("Skipping epoch 0 of %q: it needs the genesis (--discover-genesis)", source)
+				continue
+			}
+			genesisPath = d.genesisPath
+		}
+		var configIndexes []configIndex
+		for _, kind := range discoveredIndexKinds {
+			if indexURL, ok := complete[0][kind.kind]; ok {
+				configIndexes = append(configIndexes, configIndex{kind: kind.key, uri: URI(indexURL)})
+			}
+		}
+		configs[epoch] = discoveredHeader + source + "\n" + epochConfigYaml(epoch, carURL, "", configIndexes, genesisPath)
+	}
+	return configs
+}
+
+func hasRequiredIndexes(kinds map[string]string) bool {
+	for _, kind := range discoveredIndexKinds {
+		if _, ok := kinds[kind.kind]; kind.required && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// list returns the objects (file name -> URL) of the source, which is an s3://bucket/prefix
+// URL (of a public bucket), an S3 ListObjectsV2 URL, or the URL of an HTML directory listing.
+func (d *epochDiscovery) list(ctx context.Context, source string) (map[string]string, error) {
+	listURL, err := discoveryListURL(source)
+	if err != nil {
+		return nil, err
+	}
+	objects := make(map[string]string)
+	return objects, d.listURL(ctx, listURL, 0, objects)
+}
+
+// discoveryListURL returns the URL to list the source from.
+func discoveryListURL(source string) (*url.URL, error) {
+	parsed, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+		return parsed, nil
+	case "s3":
+		prefix := strings.TrimPrefix(parsed.Path, "/")
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		query := url.Values{"list-type": {"2"}}
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		return &url.URL{
+			Scheme:   "https",
+			Host:     parsed.Host + ".s3.amazonaws.com",
+			Path:     "/",
+			RawQuery: query.Encode(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q (expected http, https or s3)", parsed.Scheme)
+	}
+}
+
+func (d *epochDiscovery) listURL(ctx context.Context, listURL *url.URL, depth int, objects map[string]string) error {
+	body, err := d.get(ctx, listURL.String())
+	if err != nil {
+		return err
+	}
+	if isS3Listing(body) {
+		return d.listS3(ctx, listURL, body, objects)
+	}
+	dir := *listURL
+	dir.RawQuery = ""
+	dir.Fragment = ""
+	if !strings.HasSuffix(dir.Path, "/") {
+		dir.Path += "/"
+	}
+	for _, match := range htmlHref.FindAllSubmatch(body, -1) {
+		ref, err := url.Parse(string(match[1]))
+		if err != nil {
+			continue
+		}
+		link := dir.ResolveReference(ref)
+		link.Fragment = ""
+		// only the links to the directory itself, not to parents, other hosts or sort orders.
+		if link.Host != dir.Host || link.RawQuery != "" || link.Path == dir.Path || !strings.HasPrefix(link.Path, dir.Path) {
+			continue
+		}
+		if strings.HasSuffix(link.Path, "/") {
+			if depth < maxDiscoveryDepth {
+				if err := d.listURL(ctx, link, depth+1, objects); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if _, ok := objects[path.Base(link.Path)]; !ok {
+			objects[path.Base(link.Path)] = link.String()
+		}
+	}
+	return nil
+}
+
+type s3Listing struct {
+	XMLName  xml.Name `xml:"ListBucketResult"`
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func isS3Listing(body []byte) bool {
+	head := body[:min(len(body), 512)]
+	return bytes.Contains(head, []byte("<ListBucketResult"))
+}
+
+// listS3 adds the objects of the S3 listing and of its next pages.
+func (d *epochDiscovery) listS3(ctx context.Context, listURL *url.URL, body []byte, objects map[string]string) error {
+	base := *listURL
+	base.RawQuery = ""
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	for {
+		var listing s3Listing
+		if err := xml.Unmarshal(body, &listing); err != nil {
+			return fmt.Errorf("invalid S3 listing: %w", err)
+		}
+		for _, object := range listing.Contents {
+			objectURL := base
+			objectURL.Path += object.Key
+			name := path.Base(object.Key)
+			if _, ok := objects[name]; !ok {
+				objects[name] = objectURL.String()
+			}
+		}
+		if !listing.IsTruncated || listing.NextContinuationToken == "" {
+			return nil
+		}
+		next := *listURL
+		query := next.Query()
+		query.Set("continuation-token", listing.NextContinuationToken)
+		next.RawQuery = query.Encode()
+		var err error
+		body, err = d.get(ctx, next.String())
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (d *epochDiscovery) get(ctx context.Context, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, target)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+}
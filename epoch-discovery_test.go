@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEpochDiscovery(t *testing.T) {
+	const rootCid = "bafyreigq7lrk6mnvxg3xvwgpy4kcyhtjnbrqzbsflbaqkpwzppnv3vrgeq"
+	indexNames := func(epoch int, rootCid string) []string {
+		var names []string
+		for _, kind := range []string{"cid-to-offset-and-size", "slot-to-cid", "sig-to-cid", "sig-exists", "slot-to-blocktime"} {
+			names = append(names, fmt.Sprintf("epoch-%d-%s-mainnet-%s.index", epoch, rootCid, kind))
+		}
+		return names
+	}
+	var htmlFiles atomic.Value
+	// epoch 1 is complete, with its indexes in a subdirectory; epoch 2 has no indexes; epoch 0 needs the genesis.
+	htmlFiles.Store(map[string][]string{
+		"/epochs/":   {"epoch-0.car", "epoch-1.car", "epoch-2.car", "1/"},
+		"/epochs/1/": append(indexNames(1, rootCid), indexNames(0, rootCid)...),
+	})
+	s3Keys := append([]string{"mainnet/epoch-3.car"}, indexNames(3, rootCid)...)
+	s3Keys = append(s3Keys, "mainnet/epoch-3-bafyold-mainnet-slot-to-cid.index", "mainnet/epoch-1.car")
+	var s3Fails atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bucket/" {
+			if s3Fails.Load() {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			// two pages.
+			keys, truncated := s3Keys[:3], "<IsTruncated>true</IsTruncated><NextContinuationToken>page-2</NextContinuationToken>"
+			if r.URL.Query().Get("continuation-token") == "page-2" {
+				keys, truncated = s3Keys[3:], "<IsTruncated>false</IsTruncated>"
+			}
+			var contents strings.Builder
+			for _, key := range keys {
+				if !strings.Contains(key, "/") {
+					key = "mainnet/" + key
+				}
+				fmt.Fprintf(&contents, "<Contents><Key>%s</Key><Size>1</Size></Contents>", key)
+			}
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">%s%s</ListBucketResult>`, contents.String(), truncated)
+			return
+		}
+		if r.URL.Path == "/epochs" {
+			http.Redirect(w, r, "/epochs/", http.StatusMovedPermanently)
+			return
+		}
+		files, ok := htmlFiles.Load().(map[string][]string)[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<html><body><a href="../">Parent</a><a href="?C=N;O=D">Name</a><a href="http://example.com/epoch-9.car">elsewhere</a>`)
+		for _, file := range files {
+			fmt.Fprintf(w, `<a href="%s">%s</a>`, file, file)
+		}
+		fmt.Fprint(w, `</body></html>`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	// a config that was not generated is never removed.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "epoch-7.yaml"), []byte("epoch: 7\n"), 0o644))
+	s3Source := server.URL + "/bucket/?list-type=2&prefix=mainnet/"
+	discovery, err := newEpochDiscovery([]string{server.URL + "/epochs", s3Source}, dir, "")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	result, err := discovery.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Epochs)
+	require.Equal(t, []string{filepath.Join(dir, "epoch-1.yaml"), filepath.Join(dir, "epoch-3.yaml")}, result.Written)
+	require.Empty(t, result.Removed)
+
+	config, err := LoadConfig(filepath.Join(dir, "epoch-1.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, config.Validate())
+	require.Equal(t, uint64(1), *config.Epoch)
+	// the epoch 1 of the bucket comes after the one of the first source.
+	require.Equal(t, URI(server.URL+"/epochs/epoch-1.car"), config.Data.Car.URI)
+	require.Equal(t, URI(server.URL+"/epochs/1/"+indexNames(1, rootCid)[1]), config.Indexes.SlotToCid.URI)
+	config, err = LoadConfig(filepath.Join(dir, "epoch-3.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, config.Validate())
+	require.Equal(t, URI(server.URL+"/bucket/mainnet/epoch-3.car"), config.Data.Car.URI)
+	require.Equal(t, URI(server.URL+"/bucket/mainnet/"+indexNames(3, rootCid)[4]), config.Indexes.SlotToBlocktime.URI)
+
+	// nothing changed.
+	result, err = discovery.Run(ctx)
+	require.NoError(t, err)
+	require.Empty(t, result.Written)
+	require.Empty(t, result.Removed)
+
+	// the epochs of a source that can't be listed are kept, the ones no longer listed are removed.
+	s3Fails.Store(true)
+	htmlFiles.Store(map[string][]string{"/epochs/": {"epoch-2.car"}})
+	result, err = discovery.Run(ctx)
+	require.ErrorContains(t, err, "503")
+	require.Equal(t, []string{filepath.Join(dir, "epoch-1.yaml")}, result.Removed)
+	require.FileExists(t, filepath.Join(dir, "epoch-3.yaml"))
+	require.FileExists(t, filepath.Join(dir, "epoch-7.yaml"))
+
+	t.Run("genesis", func(t *testing.T) {
+		htmlFiles.Store(map[string][]string{
+			"/epochs/":   {"epoch-0.car", "1/"},
+			"/epochs/1/": indexNames(0, rootCid),
+		})
+		genesis := filepath.Join(t.TempDir(), "genesis.tar.bz2")
+		require.NoError(t, os.WriteFile(genesis, nil, 0o644))
+		discovery, err := newEpochDiscovery([]string{server.URL + "/epochs/"}, t.TempDir(), genesis)
+		require.NoError(t, err)
+		result, err := discovery.Run(ctx)
+		require.NoError(t, err)
+		require.Len(t, result.Written, 1)
+		config, err := LoadConfig(result.Written[0])
+		require.NoError(t, err)
+		require.NoError(t, config.Validate())
+		require.Equal(t, URI(genesis), config.Genesis.URI)
+	})
+}
+
+func TestDiscoveryListURL(t *testing.T) {
+	listURL, err := discoveryListURL("s3://my-bucket/solana/mainnet")
+	require.NoError(t, err)
+	require.Equal(t, "https://my-bucket.s3.amazonaws.com/?list-type=2&prefix=solana%2Fmainnet%2F", listURL.String())
+	listURL, err = discoveryListURL("s3://my-bucket")
+	require.NoError(t, err)
+	require.Equal(t, "https://my-bucket.s3.amazonaws.com/?list-type=2", listURL.String())
+	_, err = discoveryListURL("ftp://host/epochs/")
+	require.ErrorContains(t, err, "unsupported scheme")
+}
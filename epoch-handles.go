@@ -0,0 +1,342 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rpcpool/yellowstone-faithful/metrics"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// epochUnloadGrace is how long an epoch stays open after its last use, whatever the budget, so
+	// that an epoch used now and then isn't reopened on every use. The epochs in use are never
+	// unloaded (see epochHandle.get); this is also the lowest idle timeout.
+	epochUnloadGrace = time.Minute
+	// epochJanitorInterval is how often the idle epochs, and the epochs over the budget, are unloaded.
+	epochJanitorInterval = 10 * time.Second
+)
+
+// epochHandle is an epoch of a MultiEpoch. A lazy handle (with an opener) opens its epoch on first
+// use, and can be unloaded when unused (see epochHandles), to be opened again on the next use.
+// The other handles are added open, and stay open until they are closed.
+//
+// The users of the epoch hold a reference to it, from get to release: the epoch is only closed
+// (unloaded, or closed when the handle is removed) once they have all released it.
+type epochHandle struct {
+	number  uint64
+	network string
+	config  *Config
+	// opener opens the epoch; nil if the handle isn't lazy.
+	opener func() (*Epoch, error)
+	// mu serializes the opening, the unloading and the closing of the epoch, and guards refs and closed.
+	mu    sync.Mutex
+	epoch atomic.Pointer[Epoch]
+	// refs is the number of references to the epoch (gets not released yet).
+	refs int
+	// closed is set by close: the epoch can't be used anymore, and is closed by the last release.
+	closed bool
+	// lastUsed is the time (in unix nanoseconds) of the last get or release.
+	lastUsed atomic.Int64
+	// sigExists is the sig-exists index of the epoch, which takes a reference to the epoch (and
+	// opens it, if the handle is lazy) when used.
+	sigExists *lazySigExistsIndex
+}
+
+// newOpenEpochHandle returns the handle of an epoch that is already open.
+func newOpenEpochHandle(number uint64, ep *Epoch) *epochHandle {
+	h := &epochHandle{number: number}
+	if ep != nil {
+		h.config = ep.config
+	}
+	h.epoch.Store(ep)
+	h.lastUsed.Store(time.Now().UnixNano())
+	h.sigExists = &lazySigExistsIndex{h: h}
+	return h
+}
+
+// newLazyEpochHandle returns the handle of an epoch that opener opens on first use.
+func newLazyEpochHandle(number uint64, network string, config *Config, opener func() (*Epoch, error)) *epochHandle {
+	h := &epochHandle{
+		number:  number,
+		network: network,
+		config:  config,
+		opener:  opener,
+	}
+	h.sigExists = &lazySigExistsIndex{h: h}
+	return h
+}
+
+func (h *epochHandle) isLazy() bool {
+	return h.opener != nil
+}
+
+// get returns the epoch, which is opened if needed, and takes a reference to it: the epoch stays
+// open until the reference is released with release.
+func (h *epochHandle) get() (*Epoch, error) {
+	h.lastUsed.Store(time.Now().UnixNano())
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, fmt.Errorf("epoch %d was removed", h.number)
+	}
+	if h.epoch.Load() == nil && h.isLazy() {
+		startedAt := time.Now()
+		ep, err := h.opener()
+		metrics.EpochOpenSeconds.WithLabelValues(h.network).Observe(time.Since(startedAt).Seconds())
+		if err != nil {
+			metrics.EpochOpens.WithLabelValues(h.network, "error").Inc()
+			return nil, fmt.Errorf("failed to open epoch %d: %w", h.number, err)
+		}
+		metrics.EpochOpens.WithLabelValues(h.network, "ok").Inc()
+		metrics.EpochsOpen.WithLabelValues(h.network).Inc()
+		klog.V(2).Infof("Opened epoch %d in %s", h.number, time.Since(startedAt))
+		h.epoch.Store(ep)
+	}
+	h.refs++
+	return h.epoch.Load(), nil
+}
+
+// release releases a reference taken by get.
+func (h *epochHandle) release() {
+	h.lastUsed.Store(time.Now().UnixNano())
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.refs--
+	if h.refs < 0 {
+		panic(fmt.Sprintf("epoch %d released more times than it was got", h.number))
+	}
+	if h.refs == 0 && h.closed {
+		if err := h.closeEpoch(); err != nil {
+			klog.Errorf("error closing epoch %d: %s", h.number, err.Error())
+		}
+	}
+}
+
+// isOpen returns true if the epoch is open.
+func (h *epochHandle) isOpen() bool {
+	return h.epoch.Load() != nil
+}
+
+// idleFor returns how long ago the epoch was last used.
+func (h *epochHandle) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, h.lastUsed.Load()))
+}
+
+// unload closes the epoch of a lazy handle if it isn't in use and has been unused for at least
+// minIdle at now, so that the next get opens it again; it returns true if the epoch was closed.
+func (h *epochHandle) unload(now time.Time, minIdle time.Duration, reason string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.isLazy() || h.closed || h.refs > 0 || h.idleFor(now) < minIdle || !h.isOpen() {
+		return false
+	}
+	if err := h.closeEpoch(); err != nil {
+		klog.Errorf("error closing epoch %d: %s", h.number, err.Error())
+	}
+	metrics.EpochUnloads.WithLabelValues(h.network, reason).Inc()
+	klog.V(2).Infof("Unloaded epoch %d (%s)", h.number, reason)
+	return true
+}
+
+// close closes the epoch, if it's open: right away if it isn't in use, or else on its last release.
+// The handle can't be used anymore.
+func (h *epochHandle) close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	if h.refs > 0 {
+		return nil
+	}
+	return h.closeEpoch()
+}
+
+// closeEpoch closes the epoch, if it's open; h.mu must be held.
+func (h *epochHandle) closeEpoch() error {
+	ep := h.epoch.Swap(nil)
+	if ep == nil {
+		return nil
+	}
+	if h.isLazy() {
+		metrics.EpochsOpen.WithLabelValues(h.network).Dec()
+	}
+	return ep.Close()
+}
+
+// epochHandles manages the lazy epoch handles: it unloads the epochs that have been unused for
+// longer than the idle timeout, and the least recently used ones when more than maxOpen epochs are
+// open. An epoch in use, or used in the last epochUnloadGrace, is never unloaded, so maxOpen can be
+// exceeded for a while. The handles are shared by all the networks of a server.
+type epochHandles struct {
+	maxOpen     int
+	idleTimeout time.Duration
+
+	mu      sync.Mutex
+	handles map[*epochHandle]struct{}
+	// running is true while the janitor runs; it stops when there are no handles left.
+	running bool
+}
+
+// newEpochHandles returns the manager of the lazy epoch handles; maxOpen and idleTimeout are
+// disabled if zero. It returns nil (the epochs are opened when added, and stay open) if both are.
+func newEpochHandles(maxOpen int, idleTimeout time.Duration) *epochHandles {
+	if maxOpen <= 0 && idleTimeout <= 0 {
+		return nil
+	}
+	return &epochHandles{
+		maxOpen:     maxOpen,
+		idleTimeout: idleTimeout,
+		handles:     make(map[*epochHandle]struct{}),
+	}
+}
+
+// isLazy returns true if the epochs are opened on first use.
+func (m *epochHandles) isLazy() bool {
+	return m != nil
+}
+
+func (m *epochHandles) add(h *epochHandle) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handles[h] = struct{}{}
+	if !m.running {
+		m.running = true
+		go m.run()
+	}
+}
+
+func (m *epochHandles) remove(h *epochHandle) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.handles, h)
+}
+
+func (m *epochHandles) run() {
+	ticker := time.NewTicker(epochJanitorInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !m.unloadUnused(time.Now()) {
+			return
+		}
+	}
+}
+
+// unloadUnused unloads the idle epochs, and then the least recently used ones over the budget. It
+// returns false (and the janitor stops) if there are no handles left.
+func (m *epochHandles) unloadUnused(now time.Time) bool {
+	m.mu.Lock()
+	if len(m.handles) == 0 {
+		m.running = false
+		m.mu.Unlock()
+		return false
+	}
+	open := make([]*epochHandle, 0, len(m.handles))
+	for h := range m.handles {
+		if h.isOpen() {
+			open = append(open, h)
+		}
+	}
+	m.mu.Unlock()
+
+	// least recently used first.
+	sort.Slice(open, func(i, j int) bool {
+		return open[i].lastUsed.Load() < open[j].lastUsed.Load()
+	})
+	numOpen := len(open)
+	idleTimeout := max(m.idleTimeout, epochUnloadGrace)
+	for _, h := range open {
+		idleFor := h.idleFor(now)
+		switch {
+		case m.idleTimeout > 0 && idleFor >= idleTimeout:
+			if h.unload(now, idleTimeout, "idle") {
+				numOpen--
+			}
+		case m.maxOpen > 0 && numOpen > m.maxOpen && idleFor >= epochUnloadGrace:
+			if h.unload(now, epochUnloadGrace, "lru") {
+				numOpen--
+			}
+		}
+	}
+	return true
+}
+
+// lazySigExistsIndex is the sig-exists index of an epoch handle: it takes a reference to the epoch
+// (opening it if needed) when used. It stays the same while the epoch is unloaded and opened again,
+// so that its summary is kept.
+type lazySigExistsIndex struct {
+	h *epochHandle
+}
+
+// errSigHashesUnsupported is returned when listing the hashes of a sig-exists index that can't be summarized.
+var errSigHashesUnsupported = errors.New("the sig-exists index can't list its hashes")
+
+// index returns the index of the epoch, and the function that releases the epoch once the
+// index is no longer used.
+func (l *lazySigExistsIndex) index() (SigExistsIndex, func(), error) {
+	ep, err := l.h.get()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ep == nil || ep.sigExists == nil {
+		l.h.release()
+		return nil, nil, fmt.Errorf("epoch %d has no sig-exists index", l.h.number)
+	}
+	return ep.sigExists, l.h.release, nil
+}
+
+func (l *lazySigExistsIndex) Has(sig [64]byte) (bool, error) {
+	index, release, err := l.index()
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	return index.Has(sig)
+}
+
+func (l *lazySigExistsIndex) HasBatch(sigs [][64]byte) ([]bool, error) {
+	index, release, err := l.index()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if batcher, ok := index.(sigBatchIndex); ok {
+		return batcher.HasBatch(sigs)
+	}
+	found := make([]bool, len(sigs))
+	for i := range sigs {
+		if found[i], err = index.Has(sigs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+func (l *lazySigExistsIndex) NumHashes() (uint64, error) {
+	index, release, err := l.index()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	lister, ok := index.(sigHashLister)
+	if !ok {
+		return 0, errSigHashesUnsupported
+	}
+	return lister.NumHashes()
+}
+
+func (l *lazySigExistsIndex) ForEachHash(fn func(hash uint64) error) error {
+	index, release, err := l.index()
+	if err != nil {
+		return err
+	}
+	defer release()
+	lister, ok := index.(sigHashLister)
+	if !ok {
+		return errSigHashesUnsupported
+	}
+	return lister.ForEachHash(fn)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rpcpool/yellowstone-faithful/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+// lazyTestEpochs counts the opens and the closes of the epochs of lazy handles.
+type lazyTestEpochs struct {
+	opens  map[uint64]int
+	closes map[uint64]int
+	index  SigExistsIndex
+}
+
+func (l *lazyTestEpochs) opener(number uint64) func() (*Epoch, error) {
+	return func() (*Epoch, error) {
+		l.opens[number]++
+		return &Epoch{
+			epoch:     number,
+			sigExists: l.index,
+			onClose: []func() error{func() error {
+				l.closes[number]++
+				return nil
+			}},
+		}, nil
+	}
+}
+
+func newLazyTestMultiEpoch(t *testing.T, maxOpen int, idleTimeout time.Duration, numbers ...uint64) (*MultiEpoch, *lazyTestEpochs) {
+	multi := NewMultiEpoch(&Options{MaxOpenEpochs: maxOpen, EpochIdleTimeout: idleTimeout})
+	multi.network = t.Name()
+	require.True(t, multi.OpensEpochsLazily())
+	epochs := &lazyTestEpochs{opens: make(map[uint64]int), closes: make(map[uint64]int)}
+	for _, number := range numbers {
+		number := number
+		require.NoError(t, multi.AddLazyEpoch(&Config{Epoch: &number}, epochs.opener(number)))
+	}
+	return multi, epochs
+}
+
+func TestEpochHandles_OpenOnFirstUse(t *testing.T) {
+	require.False(t, NewMultiEpoch(&Options{}).OpensEpochsLazily())
+
+	multi, epochs := newLazyTestMultiEpoch(t, 2, 0, 1, 2)
+	require.Empty(t, epochs.opens)
+	require.Equal(t, 2, multi.CountEpochs())
+
+	ep, release, err := multi.GetEpoch(1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), ep.Epoch())
+	release()
+	again, release, err := multi.GetEpoch(1)
+	require.NoError(t, err)
+	require.Same(t, ep, again)
+	release()
+	require.Equal(t, map[uint64]int{1: 1}, epochs.opens)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.EpochsOpen.WithLabelValues(t.Name())))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.EpochOpens.WithLabelValues(t.Name(), "ok")))
+
+	failing := uint64(3)
+	require.NoError(t, multi.AddLazyEpoch(&Config{Epoch: &failing}, func() (*Epoch, error) {
+		return nil, errors.New("no such file")
+	}))
+	_, _, err = multi.GetEpoch(3)
+	require.ErrorContains(t, err, "failed to open epoch 3: no such file")
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.EpochOpens.WithLabelValues(t.Name(), "error")))
+	// the epochs that fail to open are skipped.
+	opened, release := multi.openEpochsInEpochDescendingOrder(nil)
+	require.Len(t, opened, 2)
+	release()
+
+	require.NoError(t, multi.Close())
+	require.Equal(t, map[uint64]int{1: 1, 2: 1}, epochs.closes)
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.EpochsOpen.WithLabelValues(t.Name())))
+}
+
+func TestEpochHandles_UnloadUnused(t *testing.T) {
+	multi, epochs := newLazyTestMultiEpoch(t, 2, 0, 1, 2, 3)
+	for _, number := range []uint64{1, 2, 3} {
+		_, release, err := multi.GetEpoch(number)
+		require.NoError(t, err)
+		release()
+	}
+	// the epochs used recently are not unloaded, even over the budget.
+	multi.handles.unloadUnused(time.Now())
+	require.Empty(t, epochs.closes)
+
+	// the least recently used epoch is unloaded.
+	multi.epochs[2].lastUsed.Add(int64(time.Second))
+	multi.epochs[3].lastUsed.Add(int64(2 * time.Second))
+	multi.handles.unloadUnused(time.Now().Add(epochUnloadGrace + time.Millisecond))
+	require.Equal(t, map[uint64]int{1: 1}, epochs.closes)
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.EpochsOpen.WithLabelValues(t.Name())))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.EpochUnloads.WithLabelValues(t.Name(), "lru")))
+
+	// and opened again on the next use.
+	ep, release, err := multi.GetEpoch(1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), ep.Epoch())
+	release()
+	require.Equal(t, 2, epochs.opens[1])
+	require.NoError(t, multi.Close())
+}
+
+func TestEpochHandles_IdleTimeout(t *testing.T) {
+	multi, epochs := newLazyTestMultiEpoch(t, 0, 5*time.Minute, 1, 2)
+	for _, number := range []uint64{1, 2} {
+		_, release, err := multi.GetEpoch(number)
+		require.NoError(t, err)
+		release()
+	}
+	multi.epochs[2].lastUsed.Add(int64(time.Minute))
+	multi.handles.unloadUnused(time.Now().Add(5*time.Minute + time.Second))
+	require.Equal(t, map[uint64]int{1: 1}, epochs.closes)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.EpochUnloads.WithLabelValues(t.Name(), "idle")))
+	require.NoError(t, multi.Close())
+}
+
+func TestEpochHandles_IdleTimeoutBelowGrace(t *testing.T) {
+	multi, epochs := newLazyTestMultiEpoch(t, 0, time.Second, 1)
+	_, release, err := multi.GetEpoch(1)
+	require.NoError(t, err)
+	release()
+	// the epochs used in the last epochUnloadGrace are not unloaded, whatever the idle timeout.
+	multi.handles.unloadUnused(time.Now().Add(epochUnloadGrace / 2))
+	require.Empty(t, epochs.closes)
+	multi.handles.unloadUnused(time.Now().Add(epochUnloadGrace + time.Second))
+	require.Equal(t, map[uint64]int{1: 1}, epochs.closes)
+	require.NoError(t, multi.Close())
+}
+
+func TestEpochHandles_InUse(t *testing.T) {
+	multi, epochs := newLazyTestMultiEpoch(t, 1, time.Minute, 1, 2)
+	ep, release, err := multi.GetEpoch(1)
+	require.NoError(t, err)
+	_, releaseOther, err := multi.GetEpoch(2)
+	require.NoError(t, err)
+	releaseOther()
+
+	// an epoch in use is not unloaded, even when idle or over the budget.
+	multi.epochs[2].lastUsed.Add(int64(time.Second))
+	multi.handles.unloadUnused(time.Now().Add(time.Hour))
+	require.Equal(t, map[uint64]int{2: 1}, epochs.closes)
+	require.True(t, multi.epochs[1].isOpen())
+
+	// nor closed when its handle is replaced: the last release closes it.
+	handle := multi.epochs[1]
+	require.NoError(t, multi.ReplaceOrAddEpoch(1, &Epoch{epoch: 1}))
+	require.Equal(t, map[uint64]int{2: 1}, epochs.closes)
+	require.Equal(t, uint64(1), ep.Epoch())
+	release()
+	require.Equal(t, map[uint64]int{1: 1, 2: 1}, epochs.closes)
+	_, err = handle.get()
+	require.ErrorContains(t, err, "epoch 1 was removed")
+
+	require.Panics(t, release)
+	require.NoError(t, multi.Close())
+}
+
+func TestEpochHandles_LazySigExistsIndex(t *testing.T) {
+	sig := testSig(1)
+	multi, epochs := newLazyTestMultiEpoch(t, 0, time.Minute, 7)
+	epochs.index = &fakeListedSigIndex{newFakeSigIndex(sig)}
+
+	// probing the index opens the epoch.
+	_, candidates := multi.sigEpochCandidates()
+	require.Len(t, candidates, 1)
+	has, err := candidates[0].index.Has(sig)
+	require.NoError(t, err)
+	require.True(t, has)
+	require.Equal(t, 1, epochs.opens[7])
+	numHashes, err := candidates[0].index.(sigHashLister).NumHashes()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), numHashes)
+
+	// the index can't be summarized if the index of the epoch can't.
+	epochs.index = newFakeSigIndex(sig)
+	multi.handles.unloadUnused(time.Now().Add(time.Minute + time.Second))
+	_, err = candidates[0].index.(sigHashLister).NumHashes()
+	require.ErrorIs(t, err, errSigHashesUnsupported)
+	require.Equal(t, 2, epochs.opens[7])
+	require.NoError(t, multi.Close())
+}
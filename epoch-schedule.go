@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rpcpool/yellowstone-faithful/radiance/genesis"
+	"github.com/rpcpool/yellowstone-faithful/radiance/runtime"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+)
+
+// FlagEpochSchedule sets how slots are divided into epochs, for all the commands.
+var FlagEpochSchedule = &cli.StringFlag{
+	Name:  "epoch-schedule",
+	Usage: "epoch schedule of the cluster: mainnet, devnet, <slots_per_epoch>[,warmup], or genesis:<path to genesis.tar.bz2>",
+	Value: "mainnet",
+}
+
+// parseEpochSchedule parses the value of --epoch-schedule.
+func parseEpochSchedule(value string) (slottools.EpochSchedule, error) {
+	if path, ok := strings.CutPrefix(value, "genesis:"); ok {
+		genesisConfig, _, err := genesis.ReadGenesisFromFile(path)
+		if err != nil {
+			return slottools.EpochSchedule{}, fmt.Errorf("failed to read genesis: %w", err)
+		}
+		schedule := epochScheduleFromGenesis(genesisConfig.EpochSchedule)
+		if err := schedule.Validate(); err != nil {
+			return slottools.EpochSchedule{}, fmt.Errorf("invalid epoch schedule in genesis: %w", err)
+		}
+		return schedule, nil
+	}
+	return slottools.ParseEpochSchedule(value)
+}
+
+func epochScheduleFromGenesis(s runtime.EpochSchedule) slottools.EpochSchedule {
+	return slottools.EpochSchedule{
+		SlotsPerEpoch:            s.SlotPerEpoch,
+		LeaderScheduleSlotOffset: s.LeaderScheduleSlotOffset,
+		Warmup:                   s.Warmup,
+		FirstNormalEpoch:         s.FirstNormalEpoch,
+		FirstNormalSlot:          s.FirstNormalSlot,
+	}
+}
+
+// setEpochScheduleFromFlag sets the default epoch schedule from --epoch-schedule.
+func setEpochScheduleFromFlag(c *cli.Context) error {
+	schedule, err := parseEpochSchedule(c.String(FlagEpochSchedule.Name))
+	if err != nil {
+		return err
+	}
+	if schedule != slottools.MainnetEpochSchedule {
+		klog.Infof("Using epoch schedule: %d slots per epoch, warmup=%v (first normal epoch %d, first normal slot %d)",
+			schedule.SlotsPerEpoch, schedule.Warmup, schedule.FirstNormalEpoch, schedule.FirstNormalSlot)
+	}
+	slottools.SetDefaultEpochSchedule(schedule)
+	return nil
+}
+
+// checkGenesisEpochSchedule warns if the schedule in the genesis differs from the one in use.
+func checkGenesisEpochSchedule(genesisConfig *genesis.Genesis) {
+	fromGenesis := epochScheduleFromGenesis(genesisConfig.EpochSchedule)
+	inUse := slottools.DefaultEpochSchedule()
+	if fromGenesis.SlotsPerEpoch != inUse.SlotsPerEpoch || fromGenesis.Warmup != inUse.Warmup {
+		klog.Warningf(
+			"the genesis has an epoch schedule of %d slots per epoch (warmup=%v), but %d (warmup=%v) is in use; epoch numbers will be wrong (see --%s)",
+			fromGenesis.SlotsPerEpoch, fromGenesis.Warmup, inUse.SlotsPerEpoch, inUse.Warmup, FlagEpochSchedule.Name,
+		)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rpcpool/yellowstone-faithful/bucketteer"
+	"github.com/rpcpool/yellowstone-faithful/readahead"
+	splitcarfetcher "github.com/rpcpool/yellowstone-faithful/split-car-fetcher"
+	"k8s.io/klog/v2"
+)
+
+// warmupPieceBytes is how much of the start of every split CAR piece is read by a warmup.
+const warmupPieceBytes = 64 * 1024
+
+// EpochWarmupStats are the results of an epoch warmup.
+type EpochWarmupStats struct {
+	Epoch uint64 `json:"epoch"`
+	// IndexBytes is how many bytes of the indexes were read.
+	IndexBytes int64 `json:"indexBytes"`
+	// Pieces is how many split CAR pieces were read.
+	Pieces int    `json:"pieces"`
+	Took   string `json:"took"`
+}
+
+// Warmup pre-touches the pages of the indexes of the epoch that every lookup goes through (the
+// whole indexes if full is true), reads the first bytes of every split CAR piece, and the header
+// of a remote CAR file, so that the first queries after loading the epoch don't see cold latencies.
+func (e *Epoch) Warmup(ctx context.Context, full bool) (*EpochWarmupStats, error) {
+	startedAt := time.Now()
+	stats := &EpochWarmupStats{Epoch: e.epoch}
+	type warmer interface {
+		Warmup(full bool) (int64, error)
+	}
+	warm := func(name string, index warmer) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := index.Warmup(full)
+		stats.IndexBytes += n
+		if err != nil {
+			return fmt.Errorf("failed to warm up %s index: %w", name, err)
+		}
+		return nil
+	}
+	if e.cidToOffsetAndSizeIndex != nil {
+		if err := warm("cid-to-offset-and-size", e.cidToOffsetAndSizeIndex); err != nil {
+			return nil, err
+		}
+	}
+	if e.slotToCidIndex != nil {
+		if err := warm("slot-to-cid", e.slotToCidIndex); err != nil {
+			return nil, err
+		}
+	}
+	if e.sigToCidIndex != nil {
+		if err := warm("sig-to-cid", e.sigToCidIndex); err != nil {
+			return nil, err
+		}
+	}
+	// The sig-exists index is warmed up with one read per bucket, which is only cheap for local files.
+	if sigExists, ok := e.sigExists.(*bucketteer.Reader); ok && (full || e.config.Indexes.SigExists.URI.IsLocal()) {
+		if err := warm("sig-exists", sigExists); err != nil {
+			return nil, err
+		}
+	}
+	if e.gsfaReader != nil {
+		if err := warm("gsfa", e.gsfaReader); err != nil {
+			return nil, err
+		}
+	}
+	if e.gpfaReader != nil {
+		if err := warm("gpfa", e.gpfaReader); err != nil {
+			return nil, err
+		}
+	}
+	if e.tokenBalancesReader != nil {
+		if err := warm("token-balances", e.tokenBalancesReader); err != nil {
+			return nil, err
+		}
+	}
+	if e.indexServer != nil {
+		if _, _, err := e.indexServer.Info(ctx); err != nil {
+			return nil, fmt.Errorf("failed to reach index server: %w", err)
+		}
+	}
+
+	car := e.remoteCarReader
+	if scheduler, ok := car.(*readahead.Scheduler); ok {
+		// the pieces are warmed up, not the read scheduler.
+		car = scheduler.Unwrap()
+	}
+	switch car := car.(type) {
+	case nil:
+	case *splitcarfetcher.SplitCarReader:
+		n, err := car.Warmup(ctx, warmupPieceBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to warm up CAR pieces: %w", err)
+		}
+		stats.Pieces = n
+	default:
+		if _, err := readSectionFromReaderAt(car, 0, e.carHeaderSize); err != nil {
+			return nil, fmt.Errorf("failed to read CAR header: %w", err)
+		}
+	}
+	stats.Took = time.Since(startedAt).Truncate(time.Millisecond).String()
+	klog.Infof("Warmed up epoch %d in %s: %d index bytes, %d pieces", e.epoch, stats.Took, stats.IndexBytes, stats.Pieces)
+	return stats, nil
+}
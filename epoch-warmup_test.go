@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestEpochWarmup(t *testing.T) {
+	config, _, slots, _ := buildTestEpoch(t)
+	epoch := openTestEpoch(t, config)
+
+	stats, err := epoch.Warmup(context.Background(), false)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), stats.Epoch)
+	require.Positive(t, stats.IndexBytes)
+	require.Zero(t, stats.Pieces)
+
+	full, err := epoch.Warmup(context.Background(), true)
+	require.NoError(t, err)
+	require.Greater(t, full.IndexBytes, stats.IndexBytes)
+
+	// the epoch still works after a warmup:
+	_, _, err = epoch.GetBlock(context.Background(), slots[0])
+	require.NoError(t, err)
+
+	t.Run("api", func(t *testing.T) {
+		multi := NewMultiEpoch(&Options{})
+		require.NoError(t, multi.AddEpoch(0, epoch))
+		request := func(method string, path string) *fasthttp.RequestCtx {
+			var reqCtx fasthttp.RequestCtx
+			reqCtx.Request.Header.SetMethod(method)
+			reqCtx.Request.SetRequestURI(path)
+			multi.apiHandler(&reqCtx)
+			return &reqCtx
+		}
+
+		reqCtx := request(fasthttp.MethodPost, "/api/v1/warmup/0?full=true")
+		require.Equal(t, fasthttp.StatusOK, reqCtx.Response.StatusCode())
+		var got EpochWarmupStats
+		require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &got))
+		require.Equal(t, full.IndexBytes, got.IndexBytes)
+
+		require.Equal(t, fasthttp.StatusMethodNotAllowed, request(fasthttp.MethodGet, "/api/v1/warmup/0").Response.StatusCode())
+		require.Equal(t, fasthttp.StatusBadRequest, request(fasthttp.MethodPost, "/api/v1/warmup/abc").Response.StatusCode())
+		require.Equal(t, fasthttp.StatusNotFound, request(fasthttp.MethodPost, "/api/v1/warmup/1").Response.StatusCode())
+	})
+}
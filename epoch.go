@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"time"
 
 	"github.com/multiformats/go-multiaddr"
@@ -22,19 +23,26 @@ import (
 	carv2 "github.com/ipld/go-car/v2"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/rpcpool/yellowstone-faithful/blockmanifest"
 	"github.com/rpcpool/yellowstone-faithful/blocktimeindex"
 	"github.com/rpcpool/yellowstone-faithful/bucketteer"
 	"github.com/rpcpool/yellowstone-faithful/carreader"
 	deprecatedbucketter "github.com/rpcpool/yellowstone-faithful/deprecated/bucketteer"
+	genesisblock "github.com/rpcpool/yellowstone-faithful/genesis-block"
 	"github.com/rpcpool/yellowstone-faithful/gsfa"
 	hugecache "github.com/rpcpool/yellowstone-faithful/huge-cache"
 	"github.com/rpcpool/yellowstone-faithful/indexes"
 	"github.com/rpcpool/yellowstone-faithful/indexmeta"
 	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
 	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
-	"github.com/rpcpool/yellowstone-faithful/radiance/genesis"
+	"github.com/rpcpool/yellowstone-faithful/leaderschedule"
+	"github.com/rpcpool/yellowstone-faithful/lookuptables"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
 	splitcarfetcher "github.com/rpcpool/yellowstone-faithful/split-car-fetcher"
+	"github.com/rpcpool/yellowstone-faithful/tracing"
 	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
 	"k8s.io/klog/v2"
 )
 
@@ -43,7 +51,7 @@ type Epoch struct {
 	isFilecoinMode bool // true if the epoch is in Filecoin mode (i.e. Lassie mode)
 	config         *Config
 	// genesis:
-	genesis *GenesisContainer
+	genesis *genesisblock.Constants
 	// contains indexes and block data for the epoch
 	lassieFetcher               *lassieWrapper
 	localCarReader              *carv2.Reader
@@ -55,7 +63,15 @@ type Epoch struct {
 	slotToCidIndex              *indexes.SlotToCid_Reader
 	sigToCidIndex               *indexes.SigToCid_Reader
 	sigExists                   SigExistsIndex
+	indexServer                 *indexServerClient // if set, the lookup indexes above are not opened
 	gsfaReader                  *gsfa.GsfaReader
+	gpfaReader                  *gsfa.GsfaReader
+	tokenBalancesReader         *gsfa.GsfaReader
+	blockManifest               *blockmanifest.Manifest
+	lookupTables                *lookuptables.Resolver   // optional
+	metaOverlay                 *metaOverlay             // optional
+	overlay                     *carOverlay              // optional
+	leaderSchedule              *leaderschedule.Schedule // optional
 	blocktimeindex              *blocktimeindex.Index
 	onClose                     []func() error
 	allCache                    *hugecache.Cache
@@ -101,7 +117,7 @@ func (e *Epoch) Close() error {
 	return errors.Join(multiErr...)
 }
 
-func (e *Epoch) GetGenesis() *GenesisContainer {
+func (e *Epoch) GetGenesis() *genesisblock.Constants {
 	return e.genesis
 }
 
@@ -125,25 +141,47 @@ func NewEpochFromConfig(
 		allCache:       allCache,
 	}
 	var lastRootCid cid.Cid
+	// The CAR hashes recorded in the indexes, by index name.
+	carHashes := make(map[string][]byte)
 	{
 		// if epoch is 0, then try loading the genesis from the config:
 		if *config.Epoch == 0 {
-			genesisConfig, ha, err := genesis.ReadGenesisFromFile(string(config.Genesis.URI))
+			genesisConstants, err := loadGenesisConstants(config)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read genesis: %w", err)
 			}
-			ep.genesis = &GenesisContainer{
-				Hash:   solana.HashFromBytes(ha[:]),
-				Config: genesisConfig,
+			if genesisConstants.Config != nil {
+				checkGenesisEpochSchedule(genesisConstants.Config)
 			}
+			ep.genesis = genesisConstants
 		}
 	}
-	if isCarMode {
+	if !config.LeaderSchedule.URI.IsZero() || len(config.LeaderSchedule.Stakes) > 0 {
+		schedule, err := loadLeaderSchedule(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the leader schedule: %w", err)
+		}
+		ep.leaderSchedule = schedule
+	}
+	if config.UsesIndexServer() {
+		ep.indexServer = newIndexServerClient(string(config.Indexes.Server.URI), ep.Epoch())
+		gotEpoch, rootCid, err := ep.indexServer.Info(c.Context)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the indexes of epoch %d from the index server: %w", ep.Epoch(), err)
+		}
+		if gotEpoch != ep.Epoch() {
+			return nil, fmt.Errorf("epoch mismatch in index server: expected %d, got %d", ep.Epoch(), gotEpoch)
+		}
+		lastRootCid = rootCid
+		ep.sigExists = ep.indexServer
+	}
+	if isCarMode && !config.UsesIndexServer() {
 		if config.IsDeprecatedIndexes() {
 			// The CAR-mode requires a cid-to-offset index.
 			cidToOffsetIndexFile, err := openIndexStorage(
 				c.Context,
 				string(config.Indexes.CidToOffset.URI),
+				allCache.IndexPageCache(),
 			)
 			if err != nil {
 				return nil, fmt.Errorf("failed to open cid-to-offset index file: %w", err)
@@ -163,6 +201,7 @@ func NewEpochFromConfig(
 			cidToOffsetAndSizeIndexFile, err := openIndexStorage(
 				c.Context,
 				string(config.Indexes.CidToOffsetAndSize.URI),
+				allCache.IndexPageCache(),
 			)
 			if err != nil {
 				return nil, fmt.Errorf("failed to open cid-to-offset index file: %w", err)
@@ -182,13 +221,15 @@ func NewEpochFromConfig(
 				return nil, fmt.Errorf("epoch mismatch in cid-to-offset-and-size index: expected %d, got %d", ep.Epoch(), cidToOffsetAndSizeIndex.Meta().Epoch)
 			}
 			lastRootCid = cidToOffsetAndSizeIndex.Meta().RootCid
+			carHashes["cid-to-offset-and-size"] = cidToOffsetAndSizeIndex.Meta().Provenance.CarSha256
 		}
 	}
 
-	{
+	if !config.UsesIndexServer() {
 		slotToCidIndexFile, err := openIndexStorage(
 			c.Context,
 			string(config.Indexes.SlotToCid.URI),
+			allCache.IndexPageCache(),
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open slot-to-cid index file: %w", err)
@@ -212,13 +253,15 @@ func NewEpochFromConfig(
 				return nil, fmt.Errorf("root CID mismatch in slot-to-cid index: expected %s, got %s", lastRootCid, slotToCidIndex.Meta().RootCid)
 			}
 			lastRootCid = slotToCidIndex.Meta().RootCid
+			carHashes["slot-to-cid"] = slotToCidIndex.Meta().Provenance.CarSha256
 		}
 	}
 
-	{
+	if !config.UsesIndexServer() {
 		sigToCidIndexFile, err := openIndexStorage(
 			c.Context,
 			string(config.Indexes.SigToCid.URI),
+			allCache.IndexPageCache(),
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open sig-to-cid index file: %w", err)
@@ -241,36 +284,33 @@ func NewEpochFromConfig(
 			if !lastRootCid.Equals(sigToCidIndex.Meta().RootCid) {
 				return nil, fmt.Errorf("root CID mismatch in sig-to-cid index: expected %s, got %s", lastRootCid, sigToCidIndex.Meta().RootCid)
 			}
+			carHashes["sig-to-cid"] = sigToCidIndex.Meta().Provenance.CarSha256
 		}
 	}
 
-	{
-		if !config.Indexes.Gsfa.URI.IsZero() {
-			gsfaIndex, err := gsfa.NewGsfaReader(string(config.Indexes.Gsfa.URI))
-			if err != nil {
-				return nil, fmt.Errorf("failed to open gsfa index: %w", err)
-			}
-			ep.onClose = append(ep.onClose, gsfaIndex.Close)
-			ep.gsfaReader = gsfaIndex
-
-			if gsfaIndex.Version() >= 2 {
-				gotIndexEpoch, ok := gsfaIndex.Meta().GetUint64(indexmeta.MetadataKey_Epoch)
-				if !ok {
-					return nil, fmt.Errorf("the gsfa index does not have the epoch metadata")
-				}
-				if ep.Epoch() != gotIndexEpoch {
-					return nil, fmt.Errorf("epoch mismatch in gsfa index: expected %d, got %d", ep.Epoch(), gotIndexEpoch)
-				}
-
-				gotRootCid, ok := gsfaIndex.Meta().GetCid(indexmeta.MetadataKey_RootCid)
-				if !ok {
-					return nil, fmt.Errorf("the gsfa index does not have the root CID metadata")
-				}
-				if !lastRootCid.Equals(gotRootCid) {
-					return nil, fmt.Errorf("root CID mismatch in gsfa index: expected %s, got %s", lastRootCid, gotRootCid)
-				}
-			}
+	if !config.Indexes.Gsfa.URI.IsZero() {
+		gsfaIndex, err := openGsfaFormatIndex("gsfa", config.Indexes.Gsfa.URI, ep.Epoch(), lastRootCid)
+		if err != nil {
+			return nil, err
+		}
+		ep.onClose = append(ep.onClose, gsfaIndex.Close)
+		ep.gsfaReader = gsfaIndex
+	}
+	if !config.Indexes.Gpfa.URI.IsZero() {
+		gpfaIndex, err := openGsfaFormatIndex("gpfa", config.Indexes.Gpfa.URI, ep.Epoch(), lastRootCid)
+		if err != nil {
+			return nil, err
 		}
+		ep.onClose = append(ep.onClose, gpfaIndex.Close)
+		ep.gpfaReader = gpfaIndex
+	}
+	if !config.Indexes.TokenBalances.URI.IsZero() {
+		tokenBalancesIndex, err := openGsfaFormatIndex("token_balances", config.Indexes.TokenBalances.URI, ep.Epoch(), lastRootCid)
+		if err != nil {
+			return nil, err
+		}
+		ep.onClose = append(ep.onClose, tokenBalancesIndex.Close)
+		ep.tokenBalancesReader = tokenBalancesIndex
 	}
 
 	if isLassieMode {
@@ -384,6 +424,7 @@ func NewEpochFromConfig(
 							rfspc, _, err := splitcarfetcher.NewRemoteHTTPFileAsIoReaderAt(
 								c.Context,
 								formattedURL,
+								urisToStrings(pieceURL.Mirrors)...,
 							)
 							if err != nil {
 								return nil, fmt.Errorf("failed to create remote file split car reader from %q: %w", formattedURL, err)
@@ -403,11 +444,14 @@ func NewEpochFromConfig(
 				remoteCarReader = scrFromURLs
 			}
 		} else {
-			localCarReader, remoteCarReader, err = openCarStorage(c.Context, string(config.Data.Car.URI))
+			localCarReader, remoteCarReader, err = openCarStorage(c.Context, string(config.Data.Car.URI), urisToStrings(config.Data.Car.Mirrors)...)
 			if err != nil {
 				return nil, fmt.Errorf("failed to open CAR file: %w", err)
 			}
 		}
+		if remoteCarReader != nil {
+			remoteCarReader = scheduleCarReads(c, remoteCarReader)
+		}
 		if localCarReader != nil {
 			ep.onClose = append(ep.onClose, localCarReader.Close)
 		}
@@ -450,10 +494,11 @@ func NewEpochFromConfig(
 			return nil, fmt.Errorf("no CAR reader available")
 		}
 	}
-	{
+	if !config.UsesIndexServer() {
 		sigExistsFile, err := openIndexStorage(
 			c.Context,
 			string(config.Indexes.SigExists.URI),
+			allCache.IndexPageCache(),
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open sig-exists index file: %w", err)
@@ -491,17 +536,19 @@ func NewEpochFromConfig(
 			if !lastRootCid.Equals(gotRootCid) {
 				return nil, fmt.Errorf("root CID mismatch in sig-exists index: expected %s, got %s", lastRootCid, gotRootCid)
 			}
+			carHashes["sig-exists"] = indexes.ProvenanceFromMeta(sigExists.Meta()).CarSha256
 		}
 	}
 	{
 		slotToBlocktimeFile, err := openIndexStorage(
 			c.Context,
 			string(config.Indexes.SlotToBlocktime.URI),
+			allCache.IndexPageCache(),
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open slot-to-blocktime index file: %w", err)
 		}
-		buf, err := ReadAllFromReaderAt(slotToBlocktimeFile, uint64(blocktimeindex.DefaultIndexByteSize))
+		buf, err := ReadAllFromReaderAt(slotToBlocktimeFile, uint64(blocktimeindex.DefaultIndexByteSize(ep.Epoch())))
 		if err != nil {
 			return nil, fmt.Errorf("failed to read slot-to-blocktime index: %w", err)
 		}
@@ -519,12 +566,140 @@ func NewEpochFromConfig(
 		}
 		ep.blocktimeindex = blocktimeIndex
 	}
+	if !config.Indexes.BlockManifest.URI.IsZero() {
+		blockManifestFile, err := openIndexStorage(
+			c.Context,
+			string(config.Indexes.BlockManifest.URI),
+			allCache.IndexPageCache(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open block manifest file: %w", err)
+		}
+		manifest, err := blockmanifest.FromReader(io.NewSectionReader(blockManifestFile, 0, math.MaxInt64))
+		blockManifestFile.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block manifest: %w", err)
+		}
+		if manifest.Epoch() != ep.Epoch() {
+			return nil, fmt.Errorf("epoch mismatch in block manifest: expected %d, got %d", ep.Epoch(), manifest.Epoch())
+		}
+		ep.blockManifest = manifest
+	}
+	if !config.Indexes.LookupTables.URI.IsZero() {
+		lookupTablesFile, err := openIndexStorage(
+			c.Context,
+			string(config.Indexes.LookupTables.URI),
+			allCache.IndexPageCache(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open lookup tables snapshot: %w", err)
+		}
+		ep.onClose = append(ep.onClose, lookupTablesFile.Close)
+
+		snapshot, err := lookuptables.NewReader(lookupTablesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lookup tables snapshot: %w", err)
+		}
+		if snapshot.Epoch() != ep.Epoch() {
+			return nil, fmt.Errorf("epoch mismatch in lookup tables snapshot: expected %d, got %d", ep.Epoch(), snapshot.Epoch())
+		}
+		ep.lookupTables = lookuptables.NewResolver(snapshot, lookupTablesCacheSize)
+	}
+	if config.HasMetaOverlay() {
+		overlay, err := openMetaOverlay(c.Context, config, allCache.IndexPageCache(), ep.Epoch(), lastRootCid)
+		if err != nil {
+			return nil, err
+		}
+		ep.onClose = append(ep.onClose, overlay.Close)
+		ep.metaOverlay = overlay
+	}
+	if config.HasOverlay() {
+		overlay, err := openCarOverlay(c.Context, config, allCache.IndexPageCache(), ep.Epoch(), lastRootCid)
+		if err != nil {
+			return nil, err
+		}
+		klog.Infof("Epoch %d: %d blocks are replaced by the overlay %s", ep.Epoch(), len(overlay.slots), config.Overlay.Car.URI)
+		ep.onClose = append(ep.onClose, overlay.Close)
+		ep.overlay = overlay
+	}
+
+	policy := carHashMismatchPolicy(c.String("car-hash-mismatch"))
+	if err := checkIndexCarHashes(config, carHashes, policy); err != nil {
+		return nil, err
+	}
 
 	ep.rootCid = lastRootCid
 
 	return ep, nil
 }
 
+// loadLeaderSchedule loads the leader schedule file of the epoch, or computes the schedule from the stakes.
+func loadLeaderSchedule(config *Config) (*leaderschedule.Schedule, error) {
+	epochSchedule := slottools.DefaultEpochSchedule()
+	epoch := *config.Epoch
+	firstSlot, slotsInEpoch := epochSchedule.FirstSlotInEpoch(epoch), epochSchedule.SlotsInEpoch(epoch)
+	if !config.LeaderSchedule.URI.IsZero() {
+		return leaderschedule.LoadFile(string(config.LeaderSchedule.URI), firstSlot, slotsInEpoch)
+	}
+	stakes := make(map[solana.PublicKey]uint64, len(config.LeaderSchedule.Stakes))
+	for identity, stake := range config.LeaderSchedule.Stakes {
+		pk, err := solana.PublicKeyFromBase58(identity)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity %q: %w", identity, err)
+		}
+		stakes[pk] = stake
+	}
+	return leaderschedule.FromStakes(epoch, firstSlot, slotsInEpoch, stakes)
+}
+
+// GetLeader returns the leader of the slot, if the epoch has a leader schedule.
+func (e *Epoch) GetLeader(slot uint64) (solana.PublicKey, bool) {
+	if e.leaderSchedule == nil {
+		return solana.PublicKey{}, false
+	}
+	return e.leaderSchedule.Leader(slot)
+}
+
+// lookupTablesCacheSize is the number of address lookup tables read from the snapshot that are
+// kept in memory (a table has at most 256 addresses).
+const lookupTablesCacheSize = 4096
+
+// LookupTables returns the resolver of the lookup tables snapshot of the epoch, or nil if it has none.
+func (e *Epoch) LookupTables() *lookuptables.Resolver {
+	return e.lookupTables
+}
+
+// openGsfaFormatIndex opens an index in the gsfa format (the gsfa, gpfa and token balances indexes), and checks
+// that it's for the epoch and the CAR (with the root CID).
+func openGsfaFormatIndex(name string, uri URI, epoch uint64, rootCid cid.Cid) (*gsfa.GsfaReader, error) {
+	index, err := gsfa.NewGsfaReader(string(uri))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s index: %w", name, err)
+	}
+	if index.Version() >= 2 {
+		gotIndexEpoch, ok := index.Meta().GetUint64(indexmeta.MetadataKey_Epoch)
+		if !ok {
+			index.Close()
+			return nil, fmt.Errorf("the %s index does not have the epoch metadata", name)
+		}
+		if epoch != gotIndexEpoch {
+			index.Close()
+			return nil, fmt.Errorf("epoch mismatch in %s index: expected %d, got %d", name, epoch, gotIndexEpoch)
+		}
+
+		gotRootCid, ok := index.Meta().GetCid(indexmeta.MetadataKey_RootCid)
+		if !ok {
+			index.Close()
+			return nil, fmt.Errorf("the %s index does not have the root CID metadata", name)
+		}
+		if !rootCid.Equals(gotRootCid) {
+			index.Close()
+			return nil, fmt.Errorf("root CID mismatch in %s index: expected %s, got %s", name, rootCid, gotRootCid)
+		}
+	}
+	return index, nil
+}
+
 func ReadAllFromReaderAt(reader io.ReaderAt, size uint64) ([]byte, error) {
 	buf := make([]byte, size)
 	n, err := reader.ReadAt(buf, 0)
@@ -639,7 +814,7 @@ func (s *Epoch) prefetchSubgraph(ctx context.Context, wantedCid cid.Cid) error {
 		if err == nil {
 			// put in cache
 			return sub.Each(ctx, func(c cid.Cid, data []byte) error {
-				s.GetCache().PutRawCarObject(c, data)
+				s.cacheNode(c, data)
 				return nil
 			})
 		}
@@ -648,6 +823,27 @@ func (s *Epoch) prefetchSubgraph(ctx context.Context, wantedCid cid.Cid) error {
 	return nil
 }
 
+// isRemote returns true if the nodes of this epoch are fetched from a remote source
+// (lassie, or a remote CAR file).
+func (s *Epoch) isRemote() bool {
+	return s.lassieFetcher != nil || (s.localCarReader == nil && s.remoteCarReader != nil)
+}
+
+// cacheNode puts a node in the cache; nodes that came from a remote source
+// are also persisted to the disk cache (if any), so that they survive memory eviction and restarts.
+func (s *Epoch) cacheNode(c cid.Cid, data []byte) {
+	if !s.isRemote() {
+		s.GetCache().PutRawCarObject(c, data)
+		return
+	}
+	if err := s.GetCache().PersistRawCarObject(c, data); err != nil {
+		klog.Warningf("failed to persist node %s: %s", c, err)
+	}
+}
+
+// nodeFetches coalesces identical concurrent fetches of the same node.
+var nodeFetches singleflight.Group
+
 func (s *Epoch) GetNodeByCid(ctx context.Context, wantedCid cid.Cid) ([]byte, error) {
 	{
 		// try from cache
@@ -659,15 +855,45 @@ func (s *Epoch) GetNodeByCid(ctx context.Context, wantedCid cid.Cid) ([]byte, er
 			return data, nil
 		}
 	}
+	// The fetch is not bound to the context of the first caller, so that if that caller goes away
+	// the other callers waiting for the same node don't fail with it.
+	fetchCtx := context.WithoutCancel(ctx)
+	ch := nodeFetches.DoChan(wantedCid.KeyString(), func() (any, error) {
+		data, err := s.fetchNodeByCid(fetchCtx, wantedCid)
+		if err != nil {
+			return nil, err
+		}
+		s.cacheNode(wantedCid, data)
+		return data, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]byte), nil
+	}
+}
+
+func (s *Epoch) fetchNodeByCid(ctx context.Context, wantedCid cid.Cid) ([]byte, error) {
 	if s.lassieFetcher != nil {
 		// Fetch the node from lassie.
 		data, err := s.lassieFetcher.GetNodeByCid(ctx, wantedCid)
-		if err == nil {
-			// put in cache
-			s.GetCache().PutRawCarObject(wantedCid, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get node from lassie for CID %s: %w", wantedCid, err)
+		}
+		return data, nil
+	}
+	if s.overlay != nil {
+		data, ok, err := s.overlay.getNode(wantedCid)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
 			return data, nil
 		}
-		return nil, fmt.Errorf("failed to get node from lassie for CID %s: %w", wantedCid, err)
 	}
 	// Find CAR file oas for CID in index.
 	oas, err := s.FindOffsetAndSizeFromCid(ctx, wantedCid)
@@ -678,12 +904,22 @@ func (s *Epoch) GetNodeByCid(ctx context.Context, wantedCid cid.Cid) ([]byte, er
 	return s.GetNodeByOffsetAndSize(ctx, &wantedCid, oas)
 }
 
-func (s *Epoch) ReadAtFromCar(ctx context.Context, offset uint64, length uint64) ([]byte, error) {
+func (s *Epoch) ReadAtFromCar(ctx context.Context, offset uint64, length uint64) (_ []byte, err error) {
+	ctx, span := tracing.Start(ctx, "car.read",
+		attribute.String("car.source", s.carSourceName()),
+		attribute.Int64("car.offset", int64(offset)),
+		attribute.Int64("car.length", int64(length)),
+	)
+	defer func() { tracing.End(span, err) }()
 	if s.localCarReader == nil {
 		// try remote reader
 		if s.remoteCarReader == nil {
 			return nil, fmt.Errorf("no CAR reader available")
 		}
+		// the remote reads are not cancellable: don't start one for a cancelled request.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		return readSectionFromReaderAt(s.remoteCarReader, offset, length)
 	}
 	// Get reader and seek to offset, then read node.
@@ -700,10 +936,16 @@ func (s *Epoch) ReadAtFromCar(ctx context.Context, offset uint64, length uint64)
 	return data, nil
 }
 
-func (s *Epoch) GetNodeByOffsetAndSize(ctx context.Context, wantedCid *cid.Cid, offsetAndSize *indexes.OffsetAndSize) ([]byte, error) {
+func (s *Epoch) GetNodeByOffsetAndSize(ctx context.Context, wantedCid *cid.Cid, offsetAndSize *indexes.OffsetAndSize) (_ []byte, err error) {
 	if offsetAndSize == nil {
 		return nil, fmt.Errorf("offsetAndSize must not be nil")
 	}
+	ctx, span := tracing.Start(ctx, "car.read",
+		attribute.String("car.source", s.carSourceName()),
+		attribute.Int64("car.offset", int64(offsetAndSize.Offset)),
+		attribute.Int64("car.length", int64(offsetAndSize.Size)),
+	)
+	defer func() { tracing.End(span, err) }()
 	if offsetAndSize.Size == 0 {
 		return nil, fmt.Errorf("offsetAndSize.Size must not be 0")
 	}
@@ -714,6 +956,9 @@ func (s *Epoch) GetNodeByOffsetAndSize(ctx context.Context, wantedCid *cid.Cid,
 		if s.remoteCarReader == nil {
 			return nil, fmt.Errorf("no CAR reader available")
 		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		return readNodeFromReaderAtWithOffsetAndSize(s.remoteCarReader, wantedCid, offset, length)
 	}
 	// Get reader and seek to offset, then read node.
@@ -727,6 +972,18 @@ func (s *Epoch) GetNodeByOffsetAndSize(ctx context.Context, wantedCid *cid.Cid,
 	return readNodeWithKnownSize(br, wantedCid, length)
 }
 
+// carSourceName tells where CAR sections are read from: "local", "remote", or "none".
+func (s *Epoch) carSourceName() string {
+	switch {
+	case s.localCarReader != nil:
+		return "local"
+	case s.remoteCarReader != nil:
+		return "remote"
+	default:
+		return "none"
+	}
+}
+
 func (s *Epoch) getNodeSize(ctx context.Context, offset uint64) (uint64, error) {
 	if s.localCarReader == nil {
 		// try remote reader
@@ -790,17 +1047,33 @@ func parseNodeFromSection(section []byte, wantedCid *cid.Cid) ([]byte, error) {
 }
 
 func (ser *Epoch) FindCidFromSlot(ctx context.Context, slot uint64) (o cid.Cid, e error) {
+	ctx, span := tracing.Start(ctx, "index.slot_to_cid", attribute.Int64("slot", int64(slot)), attribute.Int64("epoch", int64(ser.Epoch())))
+	defer func() { tracing.End(span, e) }()
 	startedAt := time.Now()
 	defer func() {
 		klog.V(4).Infof("Found CID for slot %d in %s: %s", slot, time.Since(startedAt), o)
 	}()
 
+	if ser.overlay != nil {
+		if c, ok := ser.overlay.slots[slot]; ok {
+			return c, nil
+		}
+	}
 	// try from cache
 	if c, err, has := ser.GetCache().GetSlotToCid(slot); err != nil {
 		return cid.Undef, err
 	} else if has {
 		return c, nil
 	}
+	if ser.indexServer != nil {
+		found, oas, err := ser.indexServer.SlotToCid(ctx, slot)
+		if err != nil {
+			return cid.Undef, err
+		}
+		ser.GetCache().PutSlotToCid(slot, found)
+		ser.GetCache().PutCidToOffsetAndSize(found, oas)
+		return found, nil
+	}
 	found, err := ser.slotToCidIndex.Get(slot)
 	if err != nil {
 		return cid.Undef, err
@@ -810,14 +1083,52 @@ func (ser *Epoch) FindCidFromSlot(ctx context.Context, slot uint64) (o cid.Cid,
 }
 
 func (ser *Epoch) FindCidFromSignature(ctx context.Context, sig solana.Signature) (o cid.Cid, e error) {
+	ctx, span := tracing.Start(ctx, "index.sig_to_cid", attribute.String("signature", sig.String()), attribute.Int64("epoch", int64(ser.Epoch())))
+	defer func() { tracing.End(span, e) }()
 	startedAt := time.Now()
 	defer func() {
 		klog.V(4).Infof("Found CID for signature %s in %s: %s", sig, time.Since(startedAt), o)
 	}()
+	if ser.overlay != nil {
+		if c, ok := ser.overlay.sigs[sig]; ok {
+			return c, nil
+		}
+	}
+	if ser.indexServer != nil {
+		found, oas, err := ser.indexServer.SigToCid(ctx, sig)
+		if err != nil {
+			return cid.Undef, err
+		}
+		ser.GetCache().PutCidToOffsetAndSize(found, oas)
+		return found, nil
+	}
 	return ser.sigToCidIndex.Get(sig)
 }
 
+// FindSigStatus returns the slot and status of a transaction from the sig-to-cid index of the
+// epoch if it's a v2 index, and false if it isn't.
+// The transactions of the overlay are not in the indexes.
+func (ser *Epoch) FindSigStatus(ctx context.Context, sig solana.Signature) (_ indexes.SigStatus, _ bool, e error) {
+	_, span := tracing.Start(ctx, "index.sig_to_cid.status", attribute.String("signature", sig.String()), attribute.Int64("epoch", int64(ser.Epoch())))
+	defer func() { tracing.End(span, e) }()
+	if ser.overlay != nil {
+		if _, ok := ser.overlay.sigs[sig]; ok {
+			return indexes.SigStatus{}, false, nil
+		}
+	}
+	if ser.indexServer == nil && ser.sigToCidIndex != nil && ser.sigToCidIndex.HasStatus() {
+		_, status, err := ser.sigToCidIndex.GetWithStatus(sig)
+		if err != nil {
+			return indexes.SigStatus{}, false, err
+		}
+		return status, true, nil
+	}
+	return indexes.SigStatus{}, false, nil
+}
+
 func (ser *Epoch) FindOffsetAndSizeFromCid(ctx context.Context, cid cid.Cid) (os *indexes.OffsetAndSize, e error) {
+	ctx, span := tracing.Start(ctx, "index.cid_to_offset_and_size", attribute.String("cid", cid.String()), attribute.Int64("epoch", int64(ser.Epoch())))
+	defer func() { tracing.End(span, e) }()
 	startedAt := time.Now()
 	defer func() {
 		if os != nil {
@@ -857,7 +1168,13 @@ func (ser *Epoch) FindOffsetAndSizeFromCid(ctx context.Context, cid cid.Cid) (os
 		return found, nil
 	}
 
-	found, err := ser.cidToOffsetAndSizeIndex.Get(cid)
+	var found *indexes.OffsetAndSize
+	var err error
+	if ser.indexServer != nil {
+		found, err = ser.indexServer.CidToOffsetAndSize(ctx, cid)
+	} else {
+		found, err = ser.cidToOffsetAndSizeIndex.Get(cid)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -966,5 +1283,10 @@ func (ser *Epoch) GetTransaction(ctx context.Context, sig solana.Signature) (*ip
 	if err != nil {
 		return nil, cid.Cid{}, fmt.Errorf("failed to decode transaction with CID %s: %w", wantedCid, err)
 	}
+	if ser.metaOverlay != nil && isMetaMissing(decoded) {
+		if err := ser.metaOverlay.fill(sig, decoded); err != nil {
+			return nil, cid.Cid{}, err
+		}
+	}
 	return decoded, wantedCid, nil
 }
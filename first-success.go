@@ -35,7 +35,7 @@ func FirstSuccess[T comparable](
 				results <- result{empty, ctx.Err()} // TODO: is this OK?
 				return nil
 			}
-			val, err := fn(ctx)
+			val, err := runJob(ctx, fn)
 			select {
 			case results <- result{val, err}:
 			case <-ctx.Done():
@@ -61,6 +61,17 @@ func FirstSuccess[T comparable](
 	return *new(T), errs
 }
 
+// runJob runs fn, returning a panic of fn as an error: the job runs in its own goroutine,
+// where a panic would crash the process instead of failing the request.
+func runJob[T comparable](ctx context.Context, fn JobFunc[T]) (val T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoveredPanic("job", "FirstSuccess", r)
+		}
+	}()
+	return fn(ctx)
+}
+
 func IsErrorSlice(err error) bool {
 	_, ok := err.(ErrorSlice)
 	return ok
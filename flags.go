@@ -2,6 +2,7 @@ package main
 
 // CREDIT: from https://github.com/filecoin-project/lassie/blob/main/cmd/lassie/flags.go
 import (
+	"fmt"
 	"os"
 	"strings"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/ipfs/go-log"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multicodec"
+	"github.com/rpcpool/yellowstone-faithful/uri"
 	"github.com/urfave/cli/v2"
 )
 
@@ -227,3 +229,24 @@ func ResetGlobalFlags() {
 	protocols = make([]multicodec.Code, 0)
 	providerBlockList = make(map[peer.ID]bool)
 }
+
+// FlagEpochs makes a command run once per epoch, with the path/URL argument
+// used as a template (see the uri package), e.g. `--epochs=0-10 'https://host/{{.epoch}}/epoch-{{.epoch}}.car'`.
+var FlagEpochs = &cli.StringFlag{
+	Name:  "epochs",
+	Usage: "list or ranges of epochs (e.g. 0-10,12); the path argument is then a template with {{.epoch}} placeholders",
+}
+
+// expandEpochsArg returns the paths for the given argument: the argument itself if --epochs is not set,
+// or the expansion of the argument (as a template) for each epoch.
+func expandEpochsArg(c *cli.Context, arg string) ([]string, error) {
+	epochs := c.String(FlagEpochs.Name)
+	if epochs == "" {
+		return []string{arg}, nil
+	}
+	tmpl := uri.Template(arg)
+	if !tmpl.IsTemplate() {
+		return nil, fmt.Errorf("--%s is set, but %q has no {{.epoch}} placeholder", FlagEpochs.Name, arg)
+	}
+	return uri.Expand(tmpl, epochs)
+}
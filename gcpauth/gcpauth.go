@@ -0,0 +1,194 @@
+// Package gcpauth gets OAuth2 access tokens for the Google Cloud APIs: a token obtained
+// elsewhere, a service account key, or the default service account of the GCE metadata server.
+package gcpauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource returns OAuth2 access tokens and when they expire.
+type TokenSource interface {
+	Token(ctx context.Context) (string, time.Time, error)
+}
+
+// New returns the token source of the given access token if it is not empty, else of the
+// service account key in credentialsFile (which defaults to $GOOGLE_APPLICATION_CREDENTIALS),
+// else of the GCE metadata server; the tokens are requested for scope.
+func New(accessToken string, credentialsFile string, scope string) (TokenSource, error) {
+	if accessToken != "" {
+		return staticToken(accessToken), nil
+	}
+	path := credentialsFile
+	if path == "" {
+		path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if path != "" {
+		return newServiceAccountToken(path, scope)
+	}
+	return metadataToken{scope: scope}, nil
+}
+
+// staticToken is an access token obtained elsewhere (e.g. `gcloud auth print-access-token`).
+type staticToken string
+
+func (t staticToken) Token(context.Context) (string, time.Time, error) {
+	return string(t), time.Time{}, nil
+}
+
+// metadataToken gets tokens of the default service account from the GCE metadata server.
+type metadataToken struct {
+	scope string
+}
+
+func (m metadataToken) Token(ctx context.Context) (string, time.Time, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		"http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token?scopes="+url.QueryEscape(m.scope),
+		nil,
+	)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return doTokenRequest(req)
+}
+
+// serviceAccountToken exchanges a JWT signed with a service account key for access tokens.
+type serviceAccountToken struct {
+	email    string
+	tokenURI string
+	scope    string
+	key      *rsa.PrivateKey
+}
+
+func newServiceAccountToken(path string, scope string) (*serviceAccountToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode credentials file %q: %w", path, err)
+	}
+	if file.Type != "service_account" {
+		return nil, fmt.Errorf("credentials file %q: unsupported type %q (only service account keys are supported)", path, file.Type)
+	}
+	block, _ := pem.Decode([]byte(file.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("credentials file %q: invalid private key", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("credentials file %q: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("credentials file %q: the private key is not an RSA key", path)
+	}
+	if file.TokenURI == "" {
+		file.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &serviceAccountToken{email: file.ClientEmail, tokenURI: file.TokenURI, scope: scope, key: key}, nil
+}
+
+func (s *serviceAccountToken) Token(ctx context.Context) (string, time.Time, error) {
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iss":   s.email,
+		"scope": s.scope,
+		"aud":   s.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doTokenRequest(req)
+}
+
+func doTokenRequest(req *http.Request) (string, time.Time, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("token request to %s failed: %s", req.URL.Host, resp.Status)
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", time.Time{}, err
+	}
+	if body.AccessToken == "" {
+		return "", time.Time{}, errors.New("token response without an access token")
+	}
+	return body.AccessToken, time.Now().Add(time.Duration(body.ExpiresIn) * time.Second), nil
+}
+
+// Cache caches the token of a source until shortly before it expires.
+type Cache struct {
+	source TokenSource
+
+	mu      sync.Mutex
+	current string
+	expiry  time.Time
+}
+
+func NewCache(source TokenSource) *Cache {
+	return &Cache{source: source}
+}
+
+// Token returns the current token, getting a new one if it is about to expire.
+func (c *Cache) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current == "" || (!c.expiry.IsZero() && time.Until(c.expiry) < time.Minute) {
+		tok, expiry, err := c.source.Token(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to get an access token: %w", err)
+		}
+		c.current, c.expiry = tok, expiry
+	}
+	return c.current, nil
+}
@@ -0,0 +1,87 @@
+// Package genesisblock holds the per-cluster genesis constants, and the special-casing of block 0
+// that is shared by the JSON-RPC and gRPC servers and by the tools that walk the blocks of a CAR file.
+//
+// Block 0 is not like the other blocks: its blocktime is the creation time of the genesis
+// (it's not in the CAR file), it has no parent, and (like solana RPC does) its previous blockhash
+// is its own blockhash.
+package genesisblock
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/radiance/genesis"
+)
+
+// Constants are the genesis values of a cluster.
+type Constants struct {
+	// Hash is the genesis hash.
+	Hash solana.Hash
+	// CreationTime is the creation time of the genesis; it's the blocktime of block 0.
+	CreationTime time.Time
+	// Config is the full genesis config; it's nil if the constants were not loaded from a genesis file.
+	Config *genesis.Genesis
+}
+
+// New creates the constants from known values (e.g. from a config file).
+func New(hash solana.Hash, creationTime time.Time) *Constants {
+	return &Constants{
+		Hash:         hash,
+		CreationTime: creationTime,
+	}
+}
+
+// FromGenesis creates the constants from a parsed genesis config and its hash.
+func FromGenesis(g *genesis.Genesis, hash [32]byte) *Constants {
+	return &Constants{
+		Hash:         solana.HashFromBytes(hash[:]),
+		CreationTime: g.CreationTime,
+		Config:       g,
+	}
+}
+
+// FromFile reads the constants from a genesis tarball (genesis.tar.bz2).
+func FromFile(path string) (*Constants, error) {
+	g, hash, err := genesis.ReadGenesisFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis from %q: %w", path, err)
+	}
+	return FromGenesis(g, *hash), nil
+}
+
+// Blocktime returns the blocktime of block 0 (unix seconds).
+func (c *Constants) Blocktime() int64 {
+	return c.CreationTime.Unix()
+}
+
+// BlockZero are the values that must be returned for block 0 instead of the ones derived from the CAR file.
+type BlockZero struct {
+	// Blocktime is only valid if HasBlocktime is true (i.e. if the genesis constants are known).
+	Blocktime         int64
+	HasBlocktime      bool
+	ParentSlot        uint64
+	BlockHeight       uint64
+	PreviousBlockhash solana.Hash
+}
+
+// IsBlockZero returns true if the slot needs the block 0 special-casing.
+func IsBlockZero(slot uint64) bool {
+	return slot == 0
+}
+
+// ForBlockZero returns the values for block 0, given its blockhash (the hash of its last entry).
+// The constants can be nil, in which case the blocktime is unknown.
+func ForBlockZero(c *Constants, blockhash solana.Hash) BlockZero {
+	bz := BlockZero{
+		ParentSlot:  0,
+		BlockHeight: 0,
+		// NOTE: this is what solana RPC does. Should it be nil instead? Or should it be the genesis hash?
+		PreviousBlockhash: blockhash,
+	}
+	if c != nil {
+		bz.Blocktime = c.Blocktime()
+		bz.HasBlocktime = true
+	}
+	return bz
+}
@@ -0,0 +1,28 @@
+package genesisblock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForBlockZero(t *testing.T) {
+	blockhash := solana.MustHashFromBase58("4sGjMW1sUnHzSxGspuhpqLDx6wiyjNtZAMdL4VZHirAn")
+	{
+		bz := ForBlockZero(nil, blockhash)
+		require.False(t, bz.HasBlocktime)
+		require.Equal(t, blockhash, bz.PreviousBlockhash)
+		require.Equal(t, uint64(0), bz.ParentSlot)
+		require.Equal(t, uint64(0), bz.BlockHeight)
+	}
+	{
+		c := New(solana.Hash{1}, time.Unix(1584368940, 0))
+		bz := ForBlockZero(c, blockhash)
+		require.True(t, bz.HasBlocktime)
+		require.Equal(t, int64(1584368940), bz.Blocktime)
+	}
+	require.True(t, IsBlockZero(0))
+	require.False(t, IsBlockZero(1))
+}
@@ -1,12 +1,22 @@
 package main
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/gagliardetto/solana-go"
-	"github.com/rpcpool/yellowstone-faithful/radiance/genesis"
+	genesisblock "github.com/rpcpool/yellowstone-faithful/genesis-block"
 )
 
-type GenesisContainer struct {
-	Hash solana.Hash
-	// The genesis config.
-	Config *genesis.Genesis
+// loadGenesisConstants loads the genesis constants of the cluster from the genesis file in the config,
+// or from the hash and creation time set in the config.
+func loadGenesisConstants(config *Config) (*genesisblock.Constants, error) {
+	if config.Genesis.Hash != "" && config.Genesis.CreationTime != nil {
+		hash, err := solana.HashFromBase58(config.Genesis.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid genesis hash: %w", err)
+		}
+		return genesisblock.New(hash, time.Unix(*config.Genesis.CreationTime, 0)), nil
+	}
+	return genesisblock.FromFile(string(config.Genesis.URI))
 }
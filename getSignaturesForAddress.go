@@ -36,32 +36,8 @@ func parseGetSignaturesForAddressParams(raw *json.RawMessage) (*GetSignaturesFor
 	out.Address = pk
 
 	if len(params) > 1 {
-		// the second param should be a map[string]interface{}
-		// with the optional params
-		if m, ok := params[1].(map[string]interface{}); ok {
-			if limit, ok := m["limit"]; ok {
-				if limit, ok := limit.(float64); ok {
-					out.Limit = int(limit)
-				}
-			}
-			if before, ok := m["before"]; ok {
-				if before, ok := before.(string); ok {
-					sig, err := solana.SignatureFromBase58(before)
-					if err != nil {
-						return nil, fmt.Errorf("failed to parse signature from base58: %w", err)
-					}
-					out.Before = &sig
-				}
-			}
-			if after, ok := m["until"]; ok {
-				if after, ok := after.(string); ok {
-					sig, err := solana.SignatureFromBase58(after)
-					if err != nil {
-						return nil, fmt.Errorf("failed to parse signature from base58: %w", err)
-					}
-					out.Until = &sig
-				}
-			}
+		if err := parseSignaturesOptions(params[1], out); err != nil {
+			return nil, err
 		}
 	}
 	if out.Limit <= 0 || out.Limit > 1000 {
@@ -71,6 +47,38 @@ func parseGetSignaturesForAddressParams(raw *json.RawMessage) (*GetSignaturesFor
 	return out, nil
 }
 
+// parseSignaturesOptions parses the optional params of getSignaturesForAddress (limit, before and until).
+func parseSignaturesOptions(param any, out *GetSignaturesForAddressParams) error {
+	// the param should be a map[string]interface{}
+	// with the optional params
+	if m, ok := param.(map[string]interface{}); ok {
+		if limit, ok := m["limit"]; ok {
+			if limit, ok := limit.(float64); ok {
+				out.Limit = int(limit)
+			}
+		}
+		if before, ok := m["before"]; ok {
+			if before, ok := before.(string); ok {
+				sig, err := solana.SignatureFromBase58(before)
+				if err != nil {
+					return fmt.Errorf("failed to parse signature from base58: %w", err)
+				}
+				out.Before = &sig
+			}
+		}
+		if after, ok := m["until"]; ok {
+			if after, ok := after.(string); ok {
+				sig, err := solana.SignatureFromBase58(after)
+				if err != nil {
+					return fmt.Errorf("failed to parse signature from base58: %w", err)
+				}
+				out.Until = &sig
+			}
+		}
+	}
+	return nil
+}
+
 func getMemoInstructionDataFromTransaction(tx *solana.Transaction) []byte {
 	for _, instruction := range tx.Message.Instructions {
 		prog, err := tx.ResolveProgramIDIndex(instruction.ProgramIDIndex)
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+
+	"github.com/rpcpool/yellowstone-faithful/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMetadataCarrier adapts incoming gRPC metadata to the OTEL propagation.TextMapCarrier interface.
+type grpcMetadataCarrier metadata.MD
+
+func (c grpcMetadataCarrier) Get(key string) string {
+	vals := metadata.MD(c).Get(key)
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
+
+func (c grpcMetadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c grpcMetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func startGRPCSpan(ctx context.Context, fullMethod string) (context.Context, func(error)) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = tracing.Extract(ctx, grpcMetadataCarrier(md))
+	}
+	ctx, span := tracing.Start(ctx, "grpc"+fullMethod,
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.method", fullMethod),
+	)
+	return ctx, func(err error) {
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", status.Code(err).String()))
+		tracing.End(span, err)
+	}
+}
+
+func tracingUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, end := startGRPCSpan(ctx, info.FullMethod)
+	resp, err := handler(ctx, req)
+	end(err)
+	return resp, err
+}
+
+// tracedServerStream overrides the context of a stream, so that the stream handlers
+// (which call ser.Context()) see the span.
+type tracedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func tracingStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, end := startGRPCSpan(ss.Context(), info.FullMethod)
+	err := handler(srv, &tracedServerStream{ServerStream: ss, ctx: ctx})
+	end(err)
+	return err
+}
+
+// recoveryUnaryInterceptor turns a panic of the handler into an Internal error.
+func recoveryUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			recoveredPanic("grpc", info.FullMethod, r)
+			resp, err = nil, status.Error(codes.Internal, errInternal)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// recoveryStreamInterceptor turns a panic of the handler into an Internal error that ends the stream.
+func recoveryStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			recoveredPanic("grpc", info.FullMethod, r)
+			err = status.Error(codes.Internal, errInternal)
+		}
+	}()
+	return handler(srv, ss)
+}
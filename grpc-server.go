@@ -1,29 +1,26 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"runtime"
-	"sort"
 	"sync"
 	"time"
 
 	bin "github.com/gagliardetto/binary"
 	"github.com/gagliardetto/solana-go"
-	"github.com/ipfs/go-cid"
-	"github.com/ipld/go-car/util"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	genesisblock "github.com/rpcpool/yellowstone-faithful/genesis-block"
 	"github.com/rpcpool/yellowstone-faithful/gsfa"
 	"github.com/rpcpool/yellowstone-faithful/gsfa/linkedlog"
 	"github.com/rpcpool/yellowstone-faithful/indexes"
 	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
 	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
 	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
 	"github.com/rpcpool/yellowstone-faithful/slottools"
 	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
@@ -38,26 +35,74 @@ import (
 
 const maxSlotsToStream uint64 = 100
 
-// ListeAndServe starts listening on the configured address and serves the RPC API.
-func (me *MultiEpoch) ListenAndServeGRPC(ctx context.Context, listenOn string) error {
+// listenAndServeGRPC starts listening on the configured address and serves the gRPC API, until ctx
+// is done; the shutdown then follows the delay and the timeout of the options.
+func listenAndServeGRPC(ctx context.Context, listenOn string, server old_faithful_grpc.OldFaithfulServer, drain *serverDrain, options *Options) error {
 	lis, err := net.Listen("tcp", listenOn)
 	if err != nil {
 		return fmt.Errorf("failed to create listener for gRPC server: %w", err)
 	}
 
-	grpcServer := grpc.NewServer()
-	old_faithful_grpc.RegisterOldFaithfulServer(grpcServer, me)
+	grpcServer := grpc.NewServer(
+		// The recovery interceptors are inside the tracing ones, so that the span records the Internal error.
+		grpc.ChainUnaryInterceptor(tracingUnaryInterceptor, recoveryUnaryInterceptor),
+		grpc.ChainStreamInterceptor(tracingStreamInterceptor, recoveryStreamInterceptor, newGRPCStreamLimits(options).interceptor),
+	)
+	old_faithful_grpc.RegisterOldFaithfulServer(grpcServer, server)
 
+	shutDown := make(chan struct{})
+	go func() {
+		defer close(shutDown)
+		<-ctx.Done()
+		shutdownGRPC(grpcServer, drain, options)
+	}()
 	if err := grpcServer.Serve(lis); err != nil {
 		return fmt.Errorf("failed to serve gRPC server: %w", err)
 	}
+	// Serve returns as soon as the shutdown starts; wait for the calls in flight.
+	<-shutDown
 	return nil
 }
 
+// shutdownGRPC stops the gRPC server gracefully (after the shutdown delay): it stops accepting
+// calls, and waits for the calls in flight until the shutdown timeout, then cancels them.
+func shutdownGRPC(grpcServer *grpc.Server, drain *serverDrain, options *Options) {
+	delay, timeout := options.shutdownDelays()
+	klog.Infof("gRPC server shutting down (delay %s, timeout %s)...", delay, timeout)
+	defer klog.Info("gRPC server shut down")
+	if drain != nil {
+		drain.start()
+	}
+	time.Sleep(delay)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		grpcServer.GracefulStop()
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		klog.Warningf("gRPC server: calls still running after %s; canceling them", timeout)
+		// this cancels the calls still running, and makes GracefulStop return.
+		grpcServer.Stop()
+		<-stopped
+	}
+}
+
+// grpcFeatures are the optional features of the gRPC API that the server supports, reported by
+// GetVersion; a name is never reused for another feature.
+var grpcFeatures = []string{
+	"get_transactions_batch",
+	"stream_entries",
+	"stream_progress",
+	"stream_signatures_only",
+	"stream_report_gaps",
+	"stream_transaction_kind_filter",
+	"streams_across_epochs",
+	"epochs_info",
+}
+
 func (me *MultiEpoch) GetVersion(context.Context, *old_faithful_grpc.VersionRequest) (*old_faithful_grpc.VersionResponse, error) {
-	// faithfulVersion["version"] = GitTag
-	// faithfulVersion["commit"] = GitCommit
-	// faithfulVersion["epochs"] = me.GetEpochNumbers()
 	resp := &old_faithful_grpc.VersionResponse{
 		Version: func() string {
 			if GitTag == "" {
@@ -65,6 +110,26 @@ func (me *MultiEpoch) GetVersion(context.Context, *old_faithful_grpc.VersionRequ
 			}
 			return GitTag
 		}(),
+		Commit:   GitCommit,
+		Features: grpcFeatures,
+	}
+	return resp, nil
+}
+
+// GetEpochsInfo returns the epochs served, in ascending order, with the slot range of each (from the
+// epoch schedule, so that the epochs opened lazily are not opened).
+func (me *MultiEpoch) GetEpochsInfo(context.Context, *old_faithful_grpc.EpochsInfoRequest) (*old_faithful_grpc.EpochsInfoResponse, error) {
+	numbers := me.GetEpochNumbers()
+	resp := &old_faithful_grpc.EpochsInfoResponse{
+		Epochs: make([]*old_faithful_grpc.EpochInfo, 0, len(numbers)),
+	}
+	for i := len(numbers) - 1; i >= 0; i-- {
+		firstSlot, lastSlot := slottools.CalcEpochLimits(numbers[i])
+		resp.Epochs = append(resp.Epochs, &old_faithful_grpc.EpochInfo{
+			Epoch:     numbers[i],
+			FirstSlot: firstSlot,
+			LastSlot:  lastSlot,
+		})
 	}
 	return resp, nil
 }
@@ -73,10 +138,11 @@ func (multi *MultiEpoch) GetBlock(ctx context.Context, params *old_faithful_grpc
 	// find the epoch that contains the requested slot
 	slot := params.Slot
 	epochNumber := slottools.CalcEpochForSlot(slot)
-	epochHandler, err := multi.GetEpoch(epochNumber)
+	epochHandler, releaseEpoch, err := multi.GetEpoch(epochNumber)
 	if err != nil {
 		return nil, status.Errorf(codes.NotFound, "Epoch %d is not available", epochNumber)
 	}
+	defer releaseEpoch()
 
 	block, _, err := epochHandler.GetBlock(WithSubrapghPrefetch(ctx, true), slot)
 	if err != nil {
@@ -87,128 +153,32 @@ func (multi *MultiEpoch) GetBlock(ctx context.Context, params *old_faithful_grpc
 		}
 	}
 
-	tim := newTimer(getRequestIDFromContext(ctx))
-	tim.time("GetBlock")
-	{
-		prefetcherFromCar := func() error {
-			parentIsInPreviousEpoch := slottools.CalcEpochForSlot(uint64(block.Meta.Parent_slot)) != slottools.CalcEpochForSlot(slot)
-			if slot == 0 {
-				parentIsInPreviousEpoch = true
-			}
-			if slot > 1 && block.Meta.Parent_slot == 0 {
-				parentIsInPreviousEpoch = true
-			}
-
-			var blockCid, parentBlockCid cid.Cid
-			wg := new(errgroup.Group)
-			wg.Go(func() (err error) {
-				blockCid, err = epochHandler.FindCidFromSlot(ctx, slot)
-				if err != nil {
-					return err
-				}
-				return nil
-			})
-			wg.Go(func() (err error) {
-				if parentIsInPreviousEpoch {
-					return nil
-				}
-				parentBlockCid, err = epochHandler.FindCidFromSlot(ctx, uint64(block.Meta.Parent_slot))
-				if err != nil {
-					return err
-				}
-				return nil
-			})
-			err = wg.Wait()
-			if err != nil {
-				return err
-			}
-			if slot == 0 {
-				klog.V(4).Infof("car start to slot(0)::%s", blockCid)
-			} else {
-				klog.V(4).Infof(
-					"slot(%d)::%s to slot(%d)::%s",
-					uint64(block.Meta.Parent_slot),
-					parentBlockCid,
-					slot,
-					blockCid,
-				)
-			}
-			{
-				var blockOffset, parentOffset uint64
-				wg := new(errgroup.Group)
-				wg.Go(func() (err error) {
-					offsetAndSize, err := epochHandler.FindOffsetAndSizeFromCid(ctx, blockCid)
-					if err != nil {
-						return err
-					}
-					blockOffset = offsetAndSize.Offset
-					return nil
-				})
-				wg.Go(func() (err error) {
-					if parentIsInPreviousEpoch {
-						// get car file header size
-						parentOffset = epochHandler.carHeaderSize
-						return nil
-					}
-					offsetAndSize, err := epochHandler.FindOffsetAndSizeFromCid(ctx, parentBlockCid)
-					if err != nil {
-						return err
-					}
-					parentOffset = offsetAndSize.Offset
-					return nil
-				})
-				err = wg.Wait()
-				if err != nil {
-					return err
-				}
-
-				length := blockOffset - parentOffset
-				MiB := uint64(1024 * 1024)
-				maxPrefetchSize := MiB * 10 // let's cap prefetching size
-				if length > maxPrefetchSize {
-					length = maxPrefetchSize
-				}
-
-				start := parentOffset
-
-				klog.V(4).Infof("prefetching CAR: start=%d length=%d (parent_offset=%d)", start, length, parentOffset)
-				carSection, err := epochHandler.ReadAtFromCar(ctx, start, length)
-				if err != nil {
-					return err
-				}
-				dr := bytes.NewReader(carSection)
-				br := bufio.NewReader(dr)
-
-				gotCid, data, err := util.ReadNode(br)
-				if err != nil {
-					return fmt.Errorf("failed to read first node: %w", err)
-				}
-				if !parentIsInPreviousEpoch && !gotCid.Equals(parentBlockCid) {
-					return fmt.Errorf("CID mismatch: expected %s, got %s", parentBlockCid, gotCid)
-				}
-				epochHandler.GetCache().PutRawCarObject(gotCid, data)
-
-				for {
-					gotCid, data, err = util.ReadNode(br)
-					if err != nil {
-						if errors.Is(err, io.EOF) {
-							break
-						}
-						return fmt.Errorf("failed to read node: %w", err)
-					}
-					if gotCid.Equals(blockCid) {
-						break
-					}
-					epochHandler.GetCache().PutRawCarObject(gotCid, data)
-				}
-			}
-			return nil
+	reqLog := rpcRequestLogFromContext(ctx)
+	reqLog.Step("GetBlock")
+	var section *blockCarSection
+	if epochHandler.lassieFetcher == nil {
+		section, err = epochHandler.findBlockCarSection(ctx, slot, uint64(block.Meta.Parent_slot))
+		if err != nil {
+			klog.Errorf("failed to find block in car: %v", err)
 		}
-		if epochHandler.lassieFetcher == nil {
-			err := prefetcherFromCar()
-			if err != nil {
-				klog.Errorf("failed to prefetch from car: %v", err)
-			}
+	}
+	// the blocks of the streams are also limited by the memory of the streams (see grpcStreamLimits).
+	releaseStream, err := reserveStreamMemory(ctx, blockMemoryEstimate(section))
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	defer releaseStream()
+	release, err := multi.blockMemory.reserve(ctx, blockMemoryEstimate(section))
+	if err != nil {
+		if errors.Is(err, ErrBlockMemoryBudgetExceeded) {
+			return nil, status.Errorf(codes.ResourceExhausted, "Failed to get block: %v", err)
+		}
+		return nil, status.FromContextError(err).Err()
+	}
+	defer release()
+	if section != nil {
+		if err := epochHandler.prefetchBlockCarSection(ctx, section); err != nil {
+			klog.Errorf("failed to prefetch from car: %v", err)
 		}
 	}
 
@@ -263,7 +233,7 @@ func (multi *MultiEpoch) GetBlock(ctx context.Context, params *old_faithful_grpc
 			return nil, status.Errorf(codes.Internal, "Failed to get entries: %v", err)
 		}
 	}
-	tim.time("get entries")
+	reqLog.Step("get entries")
 
 	resp := &old_faithful_grpc.BlockResponse{
 		Slot: uint64(block.Slot),
@@ -276,20 +246,21 @@ func (multi *MultiEpoch) GetBlock(ctx context.Context, params *old_faithful_grpc
 		if err != nil {
 			return nil, status.Errorf(codes.Internal, "Failed to get Rewards: %v", err)
 		}
-		rewardsBuf, err := tooling.LoadDataFromDataFrames(&rewardsNode.Data, epochHandler.GetDataFrameByCid)
+		rewardsChunks, err := tooling.LoadChunksFromDataFrames(&rewardsNode.Data, epochHandler.GetDataFrameByCid)
 		if err != nil {
 			return nil, status.Errorf(codes.Internal, "Failed to load Rewards dataFrames: %v", err)
 		}
 
-		uncompressedRawRewards, err := tooling.DecompressZstd(rewardsBuf)
+		uncompressedRawRewards, err := tooling.DecompressZstdChunks(rewardsChunks)
 		if err != nil {
 			return nil, status.Errorf(codes.Internal, "Failed to decompress Rewards: %v", err)
 		}
 		resp.Rewards = uncompressedRawRewards
 	}
-	tim.time("get rewards")
+	reqLog.Step("get rewards")
 	{
-		for _, transactionNode := range mergeTxNodeSlices(allTransactionNodes) {
+		for _, positioned := range nodetools.SortedTransactions(mergeTxNodeSlices(allTransactionNodes)) {
+			transactionNode := positioned.Node
 			txResp := new(old_faithful_grpc.Transaction)
 
 			// response.Slot = uint64(transactionNode.Slot)
@@ -298,10 +269,7 @@ func (multi *MultiEpoch) GetBlock(ctx context.Context, params *old_faithful_grpc
 			// }
 
 			{
-				pos, ok := transactionNode.GetPositionIndex()
-				if ok {
-					txResp.Index = ptrToUint64(uint64(pos))
-				}
+				txResp.Index = ptrToUint64(positioned.Position)
 				txResp.Transaction, txResp.Meta, err = getTransactionAndMetaFromNode(transactionNode, epochHandler.GetDataFrameByCid)
 				if err != nil {
 					return nil, status.Errorf(codes.Internal, "Failed to get transaction: %v", err)
@@ -312,13 +280,7 @@ func (multi *MultiEpoch) GetBlock(ctx context.Context, params *old_faithful_grpc
 		}
 	}
 
-	sort.Slice(allTransactions, func(i, j int) bool {
-		if allTransactions[i].Index == nil || allTransactions[j].Index == nil {
-			return false
-		}
-		return *allTransactions[i].Index < *allTransactions[j].Index
-	})
-	tim.time("get transactions")
+	reqLog.Step("get transactions")
 	resp.Transactions = allTransactions
 	blocktime := uint64(block.Meta.Blocktime)
 	if blocktime != 0 {
@@ -327,19 +289,14 @@ func (multi *MultiEpoch) GetBlock(ctx context.Context, params *old_faithful_grpc
 	resp.Blockhash = lastEntryHash[:]
 	resp.ParentSlot = uint64(block.Meta.Parent_slot)
 
-	if slot == 0 {
-		genesis := epochHandler.GetGenesis()
-		if genesis != nil {
-			blockZeroBlocktime := uint64(genesis.Config.CreationTime.Unix())
-			resp.BlockTime = int64(blockZeroBlocktime)
+	if genesisblock.IsBlockZero(slot) {
+		blockZero := genesisblock.ForBlockZero(epochHandler.GetGenesis(), lastEntryHash)
+		if blockZero.HasBlocktime {
+			resp.BlockTime = blockZero.Blocktime
 		}
-		resp.ParentSlot = uint64(0)
-
-		zeroBlockHeight := uint64(0)
-		resp.BlockHeight = zeroBlockHeight
-
-		blockZeroBlockHash := lastEntryHash
-		resp.PreviousBlockhash = blockZeroBlockHash[:] // NOTE: this is what solana RPC does. Should it be nil instead? Or should it be the genesis hash?
+		resp.ParentSlot = blockZero.ParentSlot
+		resp.BlockHeight = blockZero.BlockHeight
+		resp.PreviousBlockhash = blockZero.PreviousBlockhash[:]
 	}
 
 	{
@@ -351,30 +308,37 @@ func (multi *MultiEpoch) GetBlock(ctx context.Context, params *old_faithful_grpc
 	{
 		// get parent slot
 		parentSlot := uint64(block.Meta.Parent_slot)
-		if (parentSlot != 0 || slot == 1) && slottools.CalcEpochForSlot(parentSlot) == epochNumber {
-			// NOTE: if the parent is in the same epoch, we can get it from the same epoch handler as the block;
-			// otherwise, we need to get it from the previous epoch (TODO: implement this)
-			parentBlock, _, err := epochHandler.GetBlock(WithSubrapghPrefetch(ctx, false), parentSlot)
+		// the parent of the first block of an epoch is in the previous epoch, which is read from its own
+		// handler (if it's served), so that the blocks streamed across epochs all have their previousBlockhash.
+		parentHandler := epochHandler
+		if parentEpoch := slottools.CalcEpochForSlot(parentSlot); parentEpoch != epochNumber {
+			var releaseParent func()
+			parentHandler, releaseParent, err = multi.GetEpoch(parentEpoch)
+			if err != nil {
+				klog.V(4).Infof("parent slot %d is in epoch %d, which is not available (can't get previousBlockhash)", parentSlot, parentEpoch)
+				parentHandler = nil
+			} else {
+				defer releaseParent()
+			}
+		}
+		if (parentSlot != 0 || slot == 1) && parentHandler != nil {
+			parentBlock, _, err := parentHandler.GetBlock(WithSubrapghPrefetch(ctx, false), parentSlot)
 			if err != nil {
 				return nil, status.Errorf(codes.Internal, "Failed to get parent block: %v", err)
 			}
 
 			if len(parentBlock.Entries) > 0 {
 				lastEntryCidOfParent := parentBlock.Entries[len(parentBlock.Entries)-1]
-				parentEntryNode, err := epochHandler.GetEntryByCid(ctx, lastEntryCidOfParent.(cidlink.Link).Cid)
+				parentEntryNode, err := parentHandler.GetEntryByCid(ctx, lastEntryCidOfParent.(cidlink.Link).Cid)
 				if err != nil {
 					return nil, status.Errorf(codes.Internal, "Failed to get parent entry: %v", err)
 				}
 				parentEntryHash := solana.HashFromBytes(parentEntryNode.Hash)
 				resp.PreviousBlockhash = parentEntryHash[:]
 			}
-		} else {
-			if slot != 0 {
-				klog.V(4).Infof("parent slot is in a different epoch, not implemented yet (can't get previousBlockhash)")
-			}
 		}
 	}
-	tim.time("get parent block")
+	reqLog.Step("get parent block")
 
 	return resp, nil
 }
@@ -397,10 +361,11 @@ func (multi *MultiEpoch) GetTransaction(ctx context.Context, params *old_faithfu
 	}
 	klog.V(4).Infof("Found signature %s in epoch %d in %s", sig, epochNumber, time.Since(startedEpochLookupAt))
 
-	epochHandler, err := multi.GetEpoch(uint64(epochNumber))
+	epochHandler, release, err := multi.GetEpoch(uint64(epochNumber))
 	if err != nil {
 		return nil, status.Errorf(codes.NotFound, "Epoch %d is not available from this RPC", epochNumber)
 	}
+	defer release()
 
 	transactionNode, _, err := epochHandler.GetTransaction(WithSubrapghPrefetch(ctx, true), sig)
 	if err != nil {
@@ -610,10 +575,11 @@ func (multi *MultiEpoch) Get(ser old_faithful_grpc.OldFaithful_GetServer) error
 func (multi *MultiEpoch) GetBlockTime(ctx context.Context, params *old_faithful_grpc.BlockTimeRequest) (*old_faithful_grpc.BlockTimeResponse, error) {
 	slot := params.Slot
 	epochNumber := slottools.CalcEpochForSlot(slot)
-	epochHandler, err := multi.GetEpoch(epochNumber)
+	epochHandler, release, err := multi.GetEpoch(epochNumber)
 	if err != nil {
 		return nil, status.Errorf(codes.NotFound, "Epoch %d is not available", epochNumber)
 	}
+	defer release()
 
 	blocktimeIndex := epochHandler.GetBlocktimeIndex()
 	if blocktimeIndex != nil {
@@ -621,6 +587,9 @@ func (multi *MultiEpoch) GetBlockTime(ctx context.Context, params *old_faithful_
 		if err != nil {
 			return nil, status.Errorf(codes.Internal, "Failed to get block: %v", err)
 		}
+		if genesis := epochHandler.GetGenesis(); genesisblock.IsBlockZero(slot) && genesis != nil {
+			blocktime = genesis.Blocktime()
+		}
 		return &old_faithful_grpc.BlockTimeResponse{
 			BlockTime: blocktime,
 		}, nil
@@ -646,6 +615,13 @@ func (multi *MultiEpoch) StreamBlocks(params *old_faithful_grpc.StreamBlocksRequ
 
 		return blockContainsAccounts(block, params.Filter.AccountInclude)
 	}
+	progress := newStreamProgress(startSlot, endSlot, params.ProgressIntervalMs)
+	sendProgress := func(slot uint64, done bool) error {
+		if frame := progress.next(slot, done); frame != nil {
+			return ser.Send(&old_faithful_grpc.BlockResponse{Progress: frame})
+		}
+		return nil
+	}
 
 	for slot := startSlot; slot <= endSlot; slot++ {
 		select {
@@ -654,9 +630,24 @@ func (multi *MultiEpoch) StreamBlocks(params *old_faithful_grpc.StreamBlocksRequ
 		default:
 		}
 
+		if last, ok := multi.unavailableUntil(slot, endSlot); ok {
+			// the epochs that are not served are skipped at once; the stream goes on with the next one.
+			if err := sendProgress(last, false); err != nil {
+				return err
+			}
+			if last == endSlot {
+				break
+			}
+			slot = last
+			continue
+		}
+
 		block, err := multi.GetBlock(ctx, &old_faithful_grpc.BlockRequest{Slot: slot})
 		if err != nil {
 			if status.Code(err) == codes.NotFound {
+				if err := sendProgress(slot, false); err != nil {
+					return err
+				}
 				continue // is this the right thing to do?
 			}
 			return err
@@ -666,10 +657,14 @@ func (multi *MultiEpoch) StreamBlocks(params *old_faithful_grpc.StreamBlocksRequ
 			if err := ser.Send(block); err != nil {
 				return err
 			}
+			progress.recordSent(block)
+		}
+		if err := sendProgress(slot, false); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	return sendProgress(endSlot, true)
 }
 
 func blockContainsAccounts(block *old_faithful_grpc.BlockResponse, accounts []string) bool {
@@ -710,8 +705,34 @@ func (multi *MultiEpoch) StreamTransactions(params *old_faithful_grpc.StreamTran
 	if params.EndSlot != nil {
 		endSlot = *params.EndSlot
 	}
-	gsfaReader, _ := multi.getGsfaReadersInEpochDescendingOrderForSlotRange(ctx, startSlot, endSlot)
+	filter, err := newStreamTransactionsFilter(params.Filter)
+	if err != nil {
+		return err
+	}
+	gsfaReader, _, releaseEpochs := multi.getGsfaReadersInEpochDescendingOrderForSlotRange(ctx, startSlot, endSlot)
+	defer releaseEpochs()
+	progress := newStreamProgress(startSlot, endSlot, params.ProgressIntervalMs)
+	gaps := newStreamGaps(params.GetReportGaps(), func(gap *old_faithful_grpc.StreamGap) error {
+		return ser.Send(&old_faithful_grpc.TransactionResponse{Gap: gap})
+	})
+	sendProgress := func(slot uint64, done bool) error {
+		if frame := progress.next(slot, done); frame != nil {
+			// the gaps before the slot are reported before the client's cursor moves past them.
+			if err := gaps.flush(); err != nil {
+				return err
+			}
+			return ser.Send(&old_faithful_grpc.TransactionResponse{Progress: frame})
+		}
+		return nil
+	}
+	var txSer old_faithful_grpc.OldFaithful_StreamTransactionsServer = ser
+	if progress != nil {
+		txSer = &progressTransactionsServer{OldFaithful_StreamTransactionsServer: ser, progress: progress}
+	}
+	txSer = &orderedTransactionsServer{OldFaithful_StreamTransactionsServer: txSer, gaps: gaps}
 
+	// The slots are processed one after the other, so the transactions are sent in slot order,
+	// also across epochs.
 	for slot := startSlot; slot <= endSlot; slot++ {
 		select {
 		case <-ctx.Done():
@@ -719,88 +740,76 @@ func (multi *MultiEpoch) StreamTransactions(params *old_faithful_grpc.StreamTran
 		default:
 		}
 
-		if err := multi.processSlotTransactions(ctx, ser, slot, params.Filter, gsfaReader); err != nil {
+		if last, ok := multi.unavailableUntil(slot, endSlot); ok {
+			// the epochs that are not served are skipped at once, and reported as one gap.
+			if err := gaps.addRange(slot, last, true); err != nil {
+				return err
+			}
+			if err := sendProgress(last, false); err != nil {
+				return err
+			}
+			if last == endSlot {
+				break
+			}
+			slot = last
+			continue
+		}
+
+		hasBlock, err := multi.processSlotTransactions(ctx, txSer, slot, filter, gsfaReader)
+		if err != nil {
+			return err
+		}
+		if !hasBlock {
+			if err := gaps.add(slot, !multi.HasEpoch(slottools.CalcEpochForSlot(slot))); err != nil {
+				return err
+			}
+		}
+		if err := sendProgress(slot, false); err != nil {
 			return err
 		}
 	}
-	return nil
+	if err := gaps.flush(); err != nil {
+		return err
+	}
+	return sendProgress(endSlot, true)
 }
 
+// processSlotTransactions sends the transactions of the slot that pass the filter, in the order of
+// the block; it returns false if the slot has no block (or its epoch is not available).
 func (multi *MultiEpoch) processSlotTransactions(
 	ctx context.Context,
 	ser old_faithful_grpc.OldFaithful_StreamTransactionsServer,
-	slot uint64, filter *old_faithful_grpc.StreamTransactionsFilter,
+	slot uint64, filter *streamTransactionsFilter,
 	gsfaReader *gsfa.GsfaReaderMultiepoch,
-) error {
-
-	filterOutTxn := func(tx solana.Transaction, meta any) bool {
-		if filter == nil {
-			return true
-		}
-
-		if !(*filter.Vote) && IsSimpleVoteTransaction(&tx) { // If vote is false, we should filter out vote transactions
-			return false
-		}
-
-		if !(*filter.Failed) { // If failed is false, we should filter out failed transactions
-			err := getErr(meta)
-			if err != nil {
-				return false
-			}
-		}
-
-		// AccountInclude is handled in the main function
-
-		for _, acc := range filter.AccountExclude {
-			pkey := solana.MustPublicKeyFromBase58(acc)
-			ok, err := tx.HasAccount(pkey)
-			if err != nil {
-				klog.Errorf("Failed to check if transaction %v has account %s", tx, acc)
-				return false
-			}
-			if ok { // If any excluded account is present, filter out the transaction
-				return false
-			}
-		}
-
-		for _, acc := range filter.AccountRequired {
-			pkey := solana.MustPublicKeyFromBase58(acc)
-			ok, err := tx.HasAccount(pkey)
-			if err != nil {
-				klog.Errorf("Failed to check if transaction %v has account %s", tx, acc)
-				return false
-			}
-			if !ok { // If any required account is missing, filter out the transaction
-				return false
-			}
-		}
+) (bool, error) {
 
-		return true
-	}
-
-	if filter == nil || len(filter.AccountInclude) == 0 {
+	if len(filter.accountInclude) == 0 {
 
 		block, err := multi.GetBlock(ctx, &old_faithful_grpc.BlockRequest{Slot: slot})
 		if err != nil {
 			if status.Code(err) == codes.NotFound {
-				return nil
+				return false, nil
 			}
-			return err
+			return false, err
 		}
 
 		for _, tx := range block.Transactions {
+			if filter.vote != kindInclude && !passesKindFilter(filter.vote, nodetools.IsSimpleVoteTransactionBytes(tx.Transaction)) {
+				// skip decoding the transaction and parsing its meta.
+				continue
+			}
 			decoder := bin.NewBinDecoder(tx.Transaction)
 			txn, err := solana.TransactionFromDecoder(decoder)
 			if err != nil {
-				return status.Errorf(codes.Internal, "Failed to decode transaction: %v", err)
+				return false, status.Errorf(codes.Internal, "Failed to decode transaction: %v", err)
 			}
 
 			meta, err := solanatxmetaparsers.ParseAnyTransactionStatusMeta(tx.Meta)
 			if err != nil {
-				return status.Errorf(codes.Internal, "Failed to parse transaction meta: %v", err)
+				return false, status.Errorf(codes.Internal, "Failed to parse transaction meta: %v", err)
 			}
 
-			if !filterOutTxn(*txn, meta) {
+			if filter.includes(txn, meta) {
 
 				txResp := new(old_faithful_grpc.TransactionResponse)
 				txResp.Transaction = new(old_faithful_grpc.Transaction)
@@ -809,41 +818,62 @@ func (multi *MultiEpoch) processSlotTransactions(
 					txResp.Transaction.Transaction = tx.Transaction
 					txResp.Transaction.Meta = tx.Meta
 					txResp.Transaction.Index = tx.Index
+					txResp.Index = tx.Index
+					txResp.Slot = slot
 
 					epochNumber := slottools.CalcEpochForSlot(slot)
-					epochHandler, err := multi.GetEpoch(epochNumber)
+					epochHandler, release, err := multi.GetEpoch(epochNumber)
 					if err != nil {
-						return status.Errorf(codes.NotFound, "Epoch %d is not available", epochNumber)
+						return false, status.Errorf(codes.NotFound, "Epoch %d is not available", epochNumber)
 					}
 					blocktimeIndex := epochHandler.GetBlocktimeIndex()
-					if blocktimeIndex != nil {
-						blocktime, err := blocktimeIndex.Get(uint64(slot))
-						if err != nil {
-							return status.Errorf(codes.Internal, "Failed to get blocktime: %v", err)
-						}
-						txResp.BlockTime = int64(blocktime)
-					} else {
-						return status.Errorf(codes.Internal, "Failed to get blocktime: blocktime index is nil")
+					if blocktimeIndex == nil {
+						release()
+						return false, status.Errorf(codes.Internal, "Failed to get blocktime: blocktime index is nil")
+					}
+					blocktime, err := blocktimeIndex.Get(uint64(slot))
+					// released for each transaction of the slot, rather than when all are sent.
+					release()
+					if err != nil {
+						return false, status.Errorf(codes.Internal, "Failed to get blocktime: %v", err)
 					}
+					txResp.BlockTime = int64(blocktime)
+				}
+				if filter.signaturesOnly {
+					txResp = toSignatureOnlyResponse(txResp, txn, meta)
 				}
 
 				if err := ser.Send(txResp); err != nil {
-					return err
+					return false, err
 				}
 			}
 		}
 	} else {
-		for _, account := range filter.AccountInclude {
-			pKey := solana.MustPublicKeyFromBase58(account)
+		epochHandler, release, err := multi.GetEpoch(slottools.CalcEpochForSlot(slot))
+		if err != nil {
+			return false, nil
+		}
+		defer release()
+		if _, err := epochHandler.FindCidFromSlot(ctx, slot); err != nil {
+			if errors.Is(err, compactindexsized.ErrNotFound) {
+				return false, nil
+			}
+			return false, status.Errorf(codes.Internal, "Failed to find slot %d: %v", slot, err)
+		}
+
+		// the transactions of the included accounts, which can have some in common.
+		var found []slotTransaction
+		for _, pKey := range filter.accountInclude {
 			epochToTxns, err := gsfaReader.Get(
 				ctx,
 				pKey,
 				1000,
 				func(epochNum uint64, oas linkedlog.OffsetAndSizeAndSlot) (*ipldbindcode.Transaction, error) {
-					epoch, err := multi.GetEpoch(epochNum)
+					epoch, release, err := multi.GetEpoch(epochNum)
 					if err != nil {
 						return nil, fmt.Errorf("failed to get epoch %d: %w", epochNum, err)
 					}
+					defer release()
 					raw, err := epoch.GetNodeByOffsetAndSize(ctx, nil, &indexes.OffsetAndSize{
 						Offset: oas.Offset,
 						Size:   oas.Size,
@@ -859,58 +889,81 @@ func (multi *MultiEpoch) processSlotTransactions(
 				},
 			)
 			if err != nil {
-				return err
+				return false, err
 			}
 
 			for epochNumber, txns := range epochToTxns {
-				epochHandler, err := multi.GetEpoch(epochNumber)
+				epochHandler, release, err := multi.GetEpoch(epochNumber)
 				if err != nil {
-					return status.Errorf(codes.NotFound, "Epoch %d is not available", epochNumber)
+					return false, status.Errorf(codes.NotFound, "Epoch %d is not available", epochNumber)
 				}
+				defer release()
 				for _, txn := range txns {
 					if slot != uint64(txn.Slot) { // If the transaction is not in the requested slot, skip
 						continue
 					}
-					tx, meta, err := parseTransactionAndMetaFromNode(txn, epochHandler.GetDataFrameByCid)
+					tx, meta, err := parseTransactionAndMetaFromNode(ctx, txn, epochHandler.GetDataFrameByCid)
 					if err != nil {
-						return status.Errorf(codes.Internal, "Failed to parse transaction from node: %v", err)
+						return false, status.Errorf(codes.Internal, "Failed to parse transaction from node: %v", err)
 					}
+					found = append(found, slotTransaction{epoch: epochHandler, node: txn, tx: tx, meta: meta})
+				}
+			}
+		}
 
-					if !filterOutTxn(tx, meta) {
-
-						txResp := new(old_faithful_grpc.TransactionResponse)
-						txResp.Transaction = new(old_faithful_grpc.Transaction)
-						{
-							pos, ok := txn.GetPositionIndex()
-							if ok {
-								txResp.Index = ptrToUint64(uint64(pos))
-								txResp.Transaction.Index = ptrToUint64(uint64(pos))
-							}
-							txResp.Transaction.Transaction, txResp.Transaction.Meta, err = getTransactionAndMetaFromNode(txn, epochHandler.GetDataFrameByCid)
-							if err != nil {
-								return status.Errorf(codes.Internal, "Failed to get transaction: %v", err)
-							}
-							txResp.Slot = uint64(txn.Slot)
-
-							blocktimeIndex := epochHandler.GetBlocktimeIndex()
-							if blocktimeIndex != nil {
-								blocktime, err := blocktimeIndex.Get(uint64(txn.Slot))
-								if err != nil {
-									return status.Errorf(codes.Internal, "Failed to get blocktime: %v", err)
-								}
-								txResp.BlockTime = int64(blocktime)
-							} else {
-								return status.Errorf(codes.Internal, "Failed to get blocktime: blocktime index is nil")
-							}
-						}
+		for _, slotTx := range orderSlotTransactions(found) {
+			txn, tx, meta, epochHandler := slotTx.node, slotTx.tx, slotTx.meta, slotTx.epoch
+			if filter.includes(&tx, meta) {
 
-						if err := ser.Send(txResp); err != nil {
-							return err
+				txResp := new(old_faithful_grpc.TransactionResponse)
+				txResp.Transaction = new(old_faithful_grpc.Transaction)
+				{
+					pos, ok := txn.GetPositionIndex()
+					if ok {
+						txResp.Index = ptrToUint64(uint64(pos))
+						txResp.Transaction.Index = ptrToUint64(uint64(pos))
+					}
+					txResp.Transaction.Transaction, txResp.Transaction.Meta, err = getTransactionAndMetaFromNode(txn, epochHandler.GetDataFrameByCid)
+					if err != nil {
+						return false, status.Errorf(codes.Internal, "Failed to get transaction: %v", err)
+					}
+					txResp.Slot = uint64(txn.Slot)
+
+					blocktimeIndex := epochHandler.GetBlocktimeIndex()
+					if blocktimeIndex != nil {
+						blocktime, err := blocktimeIndex.Get(uint64(txn.Slot))
+						if err != nil {
+							return false, status.Errorf(codes.Internal, "Failed to get blocktime: %v", err)
 						}
+						txResp.BlockTime = int64(blocktime)
+					} else {
+						return false, status.Errorf(codes.Internal, "Failed to get blocktime: blocktime index is nil")
 					}
 				}
+				if filter.signaturesOnly {
+					txResp = toSignatureOnlyResponse(txResp, &tx, meta)
+				}
+
+				if err := ser.Send(txResp); err != nil {
+					return false, err
+				}
 			}
 		}
 	}
-	return nil
+	return true, nil
+}
+
+// toSignatureOnlyResponse strips the transaction and meta from a response,
+// keeping only the slot, signature, failed flag, block time and index.
+func toSignatureOnlyResponse(txResp *old_faithful_grpc.TransactionResponse, tx *solana.Transaction, meta any) *old_faithful_grpc.TransactionResponse {
+	out := &old_faithful_grpc.TransactionResponse{
+		Slot:      txResp.Slot,
+		BlockTime: txResp.BlockTime,
+		Index:     txResp.Index,
+		Failed:    ptrToBool(getErr(meta) != nil),
+	}
+	if len(tx.Signatures) > 0 {
+		out.Signature = tx.Signatures[0][:]
+	}
+	return out
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StreamEntries streams the entries (the PoH data, without the transactions) of the blocks
+// in the slot range, in slot order and in the order of the entries in each block;
+// skipped slots are not reported.
+func (multi *MultiEpoch) StreamEntries(params *old_faithful_grpc.StreamEntriesRequest, ser old_faithful_grpc.OldFaithful_StreamEntriesServer) error {
+	ctx := ser.Context()
+
+	startSlot := params.StartSlot
+	endSlot := startSlot + maxSlotsToStream
+
+	if params.EndSlot != nil {
+		endSlot = *params.EndSlot
+	}
+	progress := newStreamProgress(startSlot, endSlot, params.ProgressIntervalMs)
+	sendProgress := func(slot uint64, done bool) error {
+		if frame := progress.next(slot, done); frame != nil {
+			return ser.Send(&old_faithful_grpc.EntryResponse{Progress: frame})
+		}
+		return nil
+	}
+
+	for slot := startSlot; slot <= endSlot; slot++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if last, ok := multi.unavailableUntil(slot, endSlot); ok {
+			// the epochs that are not served are skipped at once; the stream goes on with the next one.
+			if err := sendProgress(last, false); err != nil {
+				return err
+			}
+			if last == endSlot {
+				break
+			}
+			slot = last
+			continue
+		}
+
+		entries, err := multi.getBlockEntries(ctx, slot)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				if err := sendProgress(slot, false); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		for _, entry := range entries {
+			if err := ser.Send(entry); err != nil {
+				return err
+			}
+			progress.recordSent(entry)
+		}
+		if err := sendProgress(slot, false); err != nil {
+			return err
+		}
+	}
+	return sendProgress(endSlot, true)
+}
+
+// getBlockEntries returns the entries of the block in the slot; the error is a gRPC status
+// (NotFound if the slot is skipped or its epoch is not available).
+func (multi *MultiEpoch) getBlockEntries(ctx context.Context, slot uint64) ([]*old_faithful_grpc.EntryResponse, error) {
+	epochNumber := slottools.CalcEpochForSlot(slot)
+	epochHandler, release, err := multi.GetEpoch(epochNumber)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Epoch %d is not available", epochNumber)
+	}
+	defer release()
+
+	block, _, err := epochHandler.GetBlock(ctx, slot)
+	if err != nil {
+		if errors.Is(err, compactindexsized.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "Slot %d was skipped, or missing in long-term storage", slot)
+		}
+		return nil, status.Errorf(codes.Internal, "Failed to get block: %v", err)
+	}
+
+	entries := make([]*old_faithful_grpc.EntryResponse, len(block.Entries))
+	var transactionIndex uint64
+	for i, link := range block.Entries {
+		entryNode, err := epochHandler.GetEntryByCid(ctx, link.(cidlink.Link).Cid)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to get entry %d of slot %d: %v", i, slot, err)
+		}
+		summary := nodetools.SummarizeEntry(entryNode)
+		entries[i] = &old_faithful_grpc.EntryResponse{
+			Slot:                     slot,
+			ParentSlot:               uint64(block.Meta.Parent_slot),
+			Index:                    uint32(i),
+			NumHashes:                summary.NumHashes,
+			Hash:                     summary.Hash[:],
+			NumTransactions:          uint64(summary.TransactionCount),
+			StartingTransactionIndex: transactionIndex,
+		}
+		transactionIndex += uint64(summary.TransactionCount)
+	}
+	return entries, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// testEntriesServer collects the messages of a StreamEntries stream.
+type testEntriesServer struct {
+	grpc.ServerStream
+	ctx     context.Context
+	entries []*old_faithful_grpc.EntryResponse
+}
+
+func (s *testEntriesServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testEntriesServer) Send(resp *old_faithful_grpc.EntryResponse) error {
+	s.entries = append(s.entries, resp)
+	return nil
+}
+
+func TestStreamEntries(t *testing.T) {
+	config, _, slots, _ := buildTestEpoch(t)
+	epoch := openTestEpoch(t, config)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+	ctx := context.Background()
+
+	var want []*old_faithful_grpc.EntryResponse
+	source := carwriter.NewCarSource(string(config.Data.Car.URI))
+	defer source.Close()
+	err := source.Blocks(ctx, slots[0], slots[3], func(block *carwriter.Block) error {
+		var transactionIndex uint64
+		for i, entry := range block.Entries {
+			hash := entry.Hash
+			want = append(want, &old_faithful_grpc.EntryResponse{
+				Slot:                     block.Slot,
+				ParentSlot:               block.ParentSlot,
+				Index:                    uint32(i),
+				NumHashes:                entry.NumHashes,
+				Hash:                     hash[:],
+				NumTransactions:          uint64(len(entry.Transactions)),
+				StartingTransactionIndex: transactionIndex,
+			})
+			transactionIndex += uint64(len(entry.Transactions))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// the skipped slots between the blocks are not reported.
+	ser := &testEntriesServer{ctx: ctx}
+	require.NoError(t, multi.StreamEntries(&old_faithful_grpc.StreamEntriesRequest{StartSlot: slots[0], EndSlot: &slots[3]}, ser))
+	require.Equal(t, len(want), len(ser.entries))
+	for i := range want {
+		require.Equal(t, want[i].String(), ser.entries[i].String(), "entry %d", i)
+	}
+
+	interval := uint32(60_000)
+	ser = &testEntriesServer{ctx: ctx}
+	require.NoError(t, multi.StreamEntries(&old_faithful_grpc.StreamEntriesRequest{StartSlot: slots[0], EndSlot: &slots[0], ProgressIntervalMs: &interval}, ser))
+	last := ser.entries[len(ser.entries)-1]
+	require.NotNil(t, last.Progress)
+	require.True(t, last.Progress.Done)
+	require.Equal(t, uint64(len(ser.entries)-1), last.Progress.MessagesSent)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = multi.StreamEntries(&old_faithful_grpc.StreamEntriesRequest{StartSlot: slots[0]}, &testEntriesServer{ctx: canceled})
+	require.ErrorIs(t, err, context.Canceled)
+}
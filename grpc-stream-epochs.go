@@ -0,0 +1,29 @@
+package main
+
+import "github.com/rpcpool/yellowstone-faithful/slottools"
+
+// The streams (StreamBlocks, StreamTransactions and StreamEntries) go through their slot range in
+// order, reading each slot from the epoch that contains it, so a range can span several epochs.
+
+// unavailableUntil returns the last slot, up to endSlot, of the run of epochs that are not available
+// starting with the epoch of the slot, so that a stream can skip them at once; it returns false if
+// the epoch of the slot is available.
+func (m *MultiEpoch) unavailableUntil(slot, endSlot uint64) (uint64, bool) {
+	epoch := slottools.CalcEpochForSlot(slot)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.epochs[epoch]; ok {
+		return 0, false
+	}
+	next, found := uint64(0), false
+	for number := range m.epochs {
+		if number > epoch && (!found || number < next) {
+			next, found = number, true
+		}
+	}
+	if !found {
+		return endSlot, true
+	}
+	firstSlot, _ := slottools.CalcEpochLimits(next)
+	return min(firstSlot-1, endSlot), true
+}
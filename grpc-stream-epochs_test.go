@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// testBlocksServer collects the messages of a StreamBlocks stream.
+type testBlocksServer struct {
+	grpc.ServerStream
+	ctx  context.Context
+	msgs []*old_faithful_grpc.BlockResponse
+}
+
+func (s *testBlocksServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testBlocksServer) Send(resp *old_faithful_grpc.BlockResponse) error {
+	s.msgs = append(s.msgs, resp)
+	return nil
+}
+
+// addFixtureEpoch generates a CAR with blocks in the slots (of the same epoch), and serves it.
+func addFixtureEpoch(t *testing.T, multi *MultiEpoch, slots ...uint64) *carfixture.Fixture {
+	t.Helper()
+	var specs []carfixture.BlockSpec
+	for _, slot := range slots {
+		specs = append(specs, carfixture.BlockSpec{
+			Slot:    slot,
+			Entries: [][]carfixture.TransactionSpec{{{Meta: carfixture.MetaProtobuf}, {Meta: carfixture.MetaSerde}}},
+		})
+	}
+	epoch := slottools.CalcEpochForSlot(slots[0])
+	carPath := filepath.Join(t.TempDir(), fmt.Sprintf("epoch-%d.car", epoch))
+	fixture, err := carfixture.Generate(carPath, specs)
+	require.NoError(t, err)
+	paths, _, _, err := buildTestEpochIndexesFor(epoch, carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+	require.NoError(t, multi.AddEpoch(epoch, openTestEpoch(t, testEpochConfigFor(t, epoch, carPath, paths))))
+	return fixture
+}
+
+func TestStreamsAcrossEpochs(t *testing.T) {
+	_, last0 := slottools.CalcEpochLimits(0)
+	first1, last1 := slottools.CalcEpochLimits(1)
+	first2, last2 := slottools.CalcEpochLimits(2)
+	first3, _ := slottools.CalcEpochLimits(3)
+	// epoch 2 is not served.
+	multi := NewMultiEpoch(&Options{})
+	fixtures := []*carfixture.Fixture{
+		addFixtureEpoch(t, multi, 0, last0-1, last0),
+		addFixtureEpoch(t, multi, first1, first1+1, last1-1),
+		addFixtureEpoch(t, multi, first3, first3+2),
+	}
+	var fixtureSlots []uint64
+	for _, fixture := range fixtures {
+		fixtureSlots = append(fixtureSlots, fixture.Slots()...)
+	}
+	ctx := context.Background()
+
+	t.Run("unavailableUntil", func(t *testing.T) {
+		_, ok := multi.unavailableUntil(last0, last1)
+		require.False(t, ok)
+		last, ok := multi.unavailableUntil(first2+5, first3+10)
+		require.True(t, ok)
+		require.Equal(t, last2, last)
+		last, ok = multi.unavailableUntil(first2, first2+10)
+		require.True(t, ok)
+		require.Equal(t, first2+10, last)
+		_, last3 := slottools.CalcEpochLimits(3)
+		last, ok = multi.unavailableUntil(last3+1, last3+100)
+		require.True(t, ok)
+		require.Equal(t, last3+100, last)
+	})
+
+	// the blocks of the two ranges: across adjacent epochs, and across an epoch that is not served.
+	ranges := [][2]uint64{{last0 - 2, first1 + 1}, {last1 - 2, first3 + 5}}
+
+	t.Run("blocks", func(t *testing.T) {
+		for _, r := range ranges {
+			ser := &testBlocksServer{ctx: ctx}
+			require.NoError(t, multi.StreamBlocks(&old_faithful_grpc.StreamBlocksRequest{StartSlot: r[0], EndSlot: &r[1]}, ser))
+			var want, got []uint64
+			for _, slot := range fixtureSlots {
+				if slot >= r[0] && slot <= r[1] {
+					want = append(want, slot)
+				}
+			}
+			for i, block := range ser.msgs {
+				got = append(got, block.Slot)
+				if block.Slot == first1 {
+					require.NotEmpty(t, block.PreviousBlockhash)
+				}
+				if i > 0 && block.ParentSlot == ser.msgs[i-1].Slot {
+					// also the first block of epoch 1, whose parent is read from epoch 0.
+					require.Equal(t, ser.msgs[i-1].Blockhash, block.PreviousBlockhash, "slot %d", block.Slot)
+				}
+			}
+			require.Equal(t, want, got)
+		}
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		reportGaps, signaturesOnly := true, true
+		start, end := ranges[1][0], ranges[1][1]
+		ser := &testTransactionsServer{ctx: ctx}
+		require.NoError(t, multi.StreamTransactions(&old_faithful_grpc.StreamTransactionsRequest{StartSlot: start, EndSlot: &end, ReportGaps: &reportGaps, Filter: &old_faithful_grpc.StreamTransactionsFilter{SignaturesOnly: &signaturesOnly}}, ser))
+		var sigs []solana.Signature
+		var gaps []*old_faithful_grpc.StreamGap
+		for _, msg := range ser.msgs {
+			if msg.Gap != nil {
+				gaps = append(gaps, msg.Gap)
+				continue
+			}
+			sigs = append(sigs, solana.SignatureFromBytes(msg.Signature))
+		}
+		var want []solana.Signature
+		for _, fixture := range fixtures {
+			for _, tx := range fixture.Transactions {
+				if tx.Slot >= start && tx.Slot <= end {
+					want = append(want, tx.Signature)
+				}
+			}
+		}
+		require.Equal(t, want, sigs)
+		// the whole epoch that is not served is one gap.
+		require.Equal(t, []*old_faithful_grpc.StreamGap{
+			{FirstSlot: last1 - 2, LastSlot: last1 - 2},
+			{FirstSlot: last1, LastSlot: last1},
+			{FirstSlot: first2, LastSlot: last2, EpochUnavailable: true},
+			{FirstSlot: first3 + 1, LastSlot: first3 + 1},
+			{FirstSlot: first3 + 3, LastSlot: first3 + 5},
+		}, gaps)
+	})
+}
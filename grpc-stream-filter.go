@@ -0,0 +1,138 @@
+package main
+
+import (
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+const (
+	kindUnspecified = old_faithful_grpc.TransactionKindFilter_TRANSACTION_KIND_FILTER_UNSPECIFIED
+	kindInclude     = old_faithful_grpc.TransactionKindFilter_TRANSACTION_KIND_FILTER_INCLUDE
+	kindExclude     = old_faithful_grpc.TransactionKindFilter_TRANSACTION_KIND_FILTER_EXCLUDE
+	kindOnly        = old_faithful_grpc.TransactionKindFilter_TRANSACTION_KIND_FILTER_ONLY
+)
+
+// streamTransactionsFilter is the filter of a StreamTransactions request, validated: the vote
+// and failed filters are resolved (never unspecified) and the accounts are parsed.
+type streamTransactionsFilter struct {
+	vote            old_faithful_grpc.TransactionKindFilter
+	failed          old_faithful_grpc.TransactionKindFilter
+	accountInclude  []solana.PublicKey
+	accountExclude  []solana.PublicKey
+	accountRequired []solana.PublicKey
+	signaturesOnly  bool
+}
+
+// newStreamTransactionsFilter validates the filter of a request; without a filter, every
+// transaction is streamed. The errors are InvalidArgument statuses.
+func newStreamTransactionsFilter(filter *old_faithful_grpc.StreamTransactionsFilter) (*streamTransactionsFilter, error) {
+	out := &streamTransactionsFilter{
+		signaturesOnly: filter.GetSignaturesOnly(),
+	}
+	var err error
+	var vote, failed *bool
+	if filter != nil {
+		vote, failed = filter.Vote, filter.Failed
+	}
+	if out.vote, err = resolveKindFilter("vote", filter.GetVoteFilter(), vote); err != nil {
+		return nil, err
+	}
+	if out.failed, err = resolveKindFilter("failed", filter.GetFailedFilter(), failed); err != nil {
+		return nil, err
+	}
+	for _, accounts := range []struct {
+		name   string
+		in     []string
+		parsed *[]solana.PublicKey
+	}{
+		{"account_include", filter.GetAccountInclude(), &out.accountInclude},
+		{"account_exclude", filter.GetAccountExclude(), &out.accountExclude},
+		{"account_required", filter.GetAccountRequired(), &out.accountRequired},
+	} {
+		for _, account := range accounts.in {
+			pubkey, err := solana.PublicKeyFromBase58(account)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "%s: invalid account %q: %v", accounts.name, account, err)
+			}
+			*accounts.parsed = append(*accounts.parsed, pubkey)
+		}
+	}
+	return out, nil
+}
+
+// resolveKindFilter returns the filter of a kind of transactions from its enum field, or else
+// from its legacy bool field: unset or true is include, false is exclude. When both are set, they
+// must agree: false only goes with exclude, and true with include or only (so that a client can
+// also set true for the servers that don't know the enum).
+func resolveKindFilter(name string, kind old_faithful_grpc.TransactionKindFilter, legacy *bool) (old_faithful_grpc.TransactionKindFilter, error) {
+	if _, ok := old_faithful_grpc.TransactionKindFilter_name[int32(kind)]; !ok {
+		return kindUnspecified, status.Errorf(codes.InvalidArgument, "%s_filter: unknown value %d", name, kind)
+	}
+	if legacy == nil {
+		if kind == kindUnspecified {
+			return kindInclude, nil
+		}
+		return kind, nil
+	}
+	legacyKind := kindInclude
+	if !*legacy {
+		legacyKind = kindExclude
+	}
+	switch {
+	case kind == kindUnspecified:
+		return legacyKind, nil
+	case (legacyKind == kindExclude) != (kind == kindExclude):
+		return kindUnspecified, status.Errorf(codes.InvalidArgument, "%s=%t conflicts with %s_filter=%s", name, *legacy, name, kind)
+	default:
+		return kind, nil
+	}
+}
+
+// passesKindFilter returns whether a transaction, of the kind or not, passes the filter of the kind.
+func passesKindFilter(kind old_faithful_grpc.TransactionKindFilter, isOfKind bool) bool {
+	switch kind {
+	case kindExclude:
+		return !isOfKind
+	case kindOnly:
+		return isOfKind
+	default:
+		return true
+	}
+}
+
+// includes returns whether the transaction passes the filter, apart from account_include (whose
+// transactions are looked up in the gsfa index).
+func (f *streamTransactionsFilter) includes(tx *solana.Transaction, meta any) bool {
+	if f.vote != kindInclude && !passesKindFilter(f.vote, nodetools.IsSimpleVoteTransaction(tx)) {
+		return false
+	}
+	// the error is only decoded if the failed transactions are filtered.
+	if f.failed != kindInclude && !passesKindFilter(f.failed, getErr(meta) != nil) {
+		return false
+	}
+	for _, account := range f.accountExclude {
+		ok, err := tx.HasAccount(account)
+		if err != nil {
+			klog.Errorf("Failed to check if transaction %s has account %s: %v", tx.Signatures[0], account, err)
+			return false
+		}
+		if ok { // If any excluded account is present, filter out the transaction
+			return false
+		}
+	}
+	for _, account := range f.accountRequired {
+		ok, err := tx.HasAccount(account)
+		if err != nil {
+			klog.Errorf("Failed to check if transaction %s has account %s: %v", tx.Signatures[0], account, err)
+			return false
+		}
+		if !ok { // If any required account is missing, filter out the transaction
+			return false
+		}
+	}
+	return true
+}
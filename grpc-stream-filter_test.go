@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestResolveKindFilter(t *testing.T) {
+	yes, no := true, false
+	for _, tc := range []struct {
+		legacy *bool
+		kind   old_faithful_grpc.TransactionKindFilter
+		want   old_faithful_grpc.TransactionKindFilter
+		err    bool
+	}{
+		{nil, kindUnspecified, kindInclude, false},
+		{&yes, kindUnspecified, kindInclude, false},
+		{&no, kindUnspecified, kindExclude, false},
+		{nil, kindInclude, kindInclude, false},
+		{nil, kindExclude, kindExclude, false},
+		{nil, kindOnly, kindOnly, false},
+		{&yes, kindInclude, kindInclude, false},
+		{&yes, kindOnly, kindOnly, false},
+		{&yes, kindExclude, 0, true},
+		{&no, kindExclude, kindExclude, false},
+		{&no, kindInclude, 0, true},
+		{&no, kindOnly, 0, true},
+		{nil, 7, 0, true},
+	} {
+		name := "unset"
+		if tc.legacy != nil {
+			name = fmt.Sprint(*tc.legacy)
+		}
+		t.Run(fmt.Sprintf("%s/%s", name, tc.kind), func(t *testing.T) {
+			got, err := resolveKindFilter("vote", tc.kind, tc.legacy)
+			if tc.err {
+				require.Equal(t, codes.InvalidArgument, status.Code(err), "%v", err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err := newStreamTransactionsFilter(&old_faithful_grpc.StreamTransactionsFilter{AccountExclude: []string{"not-an-account"}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.ErrorContains(t, err, "account_exclude")
+	filter, err := newStreamTransactionsFilter(nil)
+	require.NoError(t, err)
+	require.Equal(t, &streamTransactionsFilter{vote: kindInclude, failed: kindInclude}, filter)
+}
+
+// streamSignatures returns the signatures and failed flags of the transactions streamed with the filter.
+func streamSignatures(t *testing.T, multi *MultiEpoch, start, end uint64, filter *old_faithful_grpc.StreamTransactionsFilter) ([]solana.Signature, []bool, error) {
+	t.Helper()
+	signaturesOnly := true
+	filter.SignaturesOnly = &signaturesOnly
+	ser := &testTransactionsServer{ctx: context.Background()}
+	err := multi.StreamTransactions(&old_faithful_grpc.StreamTransactionsRequest{StartSlot: start, EndSlot: &end, Filter: filter}, ser)
+	var sigs []solana.Signature
+	var failed []bool
+	for _, msg := range ser.msgs {
+		sigs = append(sigs, solana.SignatureFromBytes(msg.Signature))
+		failed = append(failed, msg.GetFailed())
+	}
+	return sigs, failed, err
+}
+
+func TestStreamTransactionsKindFilters(t *testing.T) {
+	// the default blocks, and a block of vote transactions: one that succeeded, one that failed,
+	// and one without a meta.
+	specs := append(carfixture.DefaultBlocks(), carfixture.BlockSpec{
+		Slot: 6,
+		Entries: [][]carfixture.TransactionSpec{
+			{{Meta: carfixture.MetaProtobuf, Vote: true}, {Meta: carfixture.MetaProtobuf, Vote: true, Failed: true}},
+			{{Meta: carfixture.MetaNone, Vote: true}, {Meta: carfixture.MetaSerde}},
+		},
+	})
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, specs)
+	require.NoError(t, err)
+	paths, _, _, err := buildTestEpochIndexes(carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, openTestEpoch(t, testEpochConfig(t, carPath, paths))))
+
+	kinds := []old_faithful_grpc.TransactionKindFilter{kindInclude, kindExclude, kindOnly}
+	for _, vote := range kinds {
+		for _, failed := range kinds {
+			t.Run(fmt.Sprintf("%s/%s", vote, failed), func(t *testing.T) {
+				var want []solana.Signature
+				var wantFailed []bool
+				for _, tx := range fixture.Transactions {
+					// only the protobuf metas record the failure.
+					isFailed := tx.Spec.Failed && tx.Spec.Meta == carfixture.MetaProtobuf
+					if passesKindFilter(vote, tx.Spec.Vote) && passesKindFilter(failed, isFailed) {
+						want = append(want, tx.Signature)
+						wantFailed = append(wantFailed, isFailed)
+					}
+				}
+				got, gotFailed, err := streamSignatures(t, multi, 0, 6, &old_faithful_grpc.StreamTransactionsFilter{VoteFilter: vote, FailedFilter: failed})
+				require.NoError(t, err)
+				require.Equal(t, want, got)
+				require.Equal(t, wantFailed, gotFailed)
+			})
+		}
+	}
+
+	// the legacy flags are include (true) or exclude (false), and apply when the enums are unset.
+	yes, no := true, false
+	for _, tc := range []struct {
+		legacy *old_faithful_grpc.StreamTransactionsFilter
+		same   *old_faithful_grpc.StreamTransactionsFilter
+	}{
+		{&old_faithful_grpc.StreamTransactionsFilter{}, &old_faithful_grpc.StreamTransactionsFilter{VoteFilter: kindInclude, FailedFilter: kindInclude}},
+		{&old_faithful_grpc.StreamTransactionsFilter{Vote: &no}, &old_faithful_grpc.StreamTransactionsFilter{VoteFilter: kindExclude}},
+		{&old_faithful_grpc.StreamTransactionsFilter{Failed: &no}, &old_faithful_grpc.StreamTransactionsFilter{FailedFilter: kindExclude}},
+		{&old_faithful_grpc.StreamTransactionsFilter{Vote: &yes, Failed: &yes}, &old_faithful_grpc.StreamTransactionsFilter{}},
+		{&old_faithful_grpc.StreamTransactionsFilter{Vote: &yes, VoteFilter: kindOnly}, &old_faithful_grpc.StreamTransactionsFilter{VoteFilter: kindOnly}},
+	} {
+		legacy, _, err := streamSignatures(t, multi, 0, 6, tc.legacy)
+		require.NoError(t, err)
+		same, _, err := streamSignatures(t, multi, 0, 6, tc.same)
+		require.NoError(t, err)
+		require.Equal(t, same, legacy)
+	}
+
+	// conflicting values are rejected before anything is streamed.
+	_, _, err = streamSignatures(t, multi, 0, 6, &old_faithful_grpc.StreamTransactionsFilter{Failed: &no, FailedFilter: kindOnly})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestStreamTransactionsVoteFilterMainnet(t *testing.T) {
+	// the transactions of the first mainnet blocks are all votes, without metas.
+	config, _, slots, sigs := buildTestEpoch(t)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, openTestEpoch(t, config)))
+	start, end := slots[0], slots[len(slots)-1]
+
+	got, failed, err := streamSignatures(t, multi, start, end, &old_faithful_grpc.StreamTransactionsFilter{VoteFilter: kindOnly})
+	require.NoError(t, err)
+	require.ElementsMatch(t, sigs, got)
+	require.NotContains(t, failed, true)
+	got, _, err = streamSignatures(t, multi, start, end, &old_faithful_grpc.StreamTransactionsFilter{VoteFilter: kindExclude})
+	require.NoError(t, err)
+	require.Empty(t, got)
+	// a transaction without a meta is not failed.
+	got, _, err = streamSignatures(t, multi, start, end, &old_faithful_grpc.StreamTransactionsFilter{FailedFilter: kindOnly})
+	require.NoError(t, err)
+	require.Empty(t, got)
+	got, _, err = streamSignatures(t, multi, start, end, &old_faithful_grpc.StreamTransactionsFilter{FailedFilter: kindExclude})
+	require.NoError(t, err)
+	require.ElementsMatch(t, sigs, got)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// streamGaps coalesces the consecutive slots without a block of a StreamTransactions stream
+// into gap frames.
+type streamGaps struct {
+	send    func(*old_faithful_grpc.StreamGap) error
+	pending *old_faithful_grpc.StreamGap
+}
+
+// newStreamGaps returns nil if the client did not ask for gap frames.
+func newStreamGaps(report bool, send func(*old_faithful_grpc.StreamGap) error) *streamGaps {
+	if !report {
+		return nil
+	}
+	return &streamGaps{send: send}
+}
+
+// add records a slot without a block; the slots must be added in order.
+func (g *streamGaps) add(slot uint64, epochUnavailable bool) error {
+	return g.addRange(slot, slot, epochUnavailable)
+}
+
+// addRange records the slots from first to last (included) as without a block.
+func (g *streamGaps) addRange(first, last uint64, epochUnavailable bool) error {
+	if g == nil {
+		return nil
+	}
+	if p := g.pending; p != nil && p.LastSlot+1 == first && p.EpochUnavailable == epochUnavailable {
+		p.LastSlot = last
+		return nil
+	}
+	if err := g.flush(); err != nil {
+		return err
+	}
+	g.pending = &old_faithful_grpc.StreamGap{FirstSlot: first, LastSlot: last, EpochUnavailable: epochUnavailable}
+	return nil
+}
+
+// flush sends the pending gap frame, if any.
+func (g *streamGaps) flush() error {
+	if g == nil || g.pending == nil {
+		return nil
+	}
+	gap := g.pending
+	g.pending = nil
+	return g.send(gap)
+}
+
+// orderedTransactionsServer enforces the order of a StreamTransactions stream: by slot, and by
+// position in the block within a slot (when known). It sends the pending gap frame before
+// the transactions of the later slots.
+type orderedTransactionsServer struct {
+	old_faithful_grpc.OldFaithful_StreamTransactionsServer
+	gaps *streamGaps
+	// last is the last transaction sent, if any.
+	last *old_faithful_grpc.TransactionResponse
+}
+
+func (s *orderedTransactionsServer) Send(resp *old_faithful_grpc.TransactionResponse) error {
+	if last := s.last; last != nil {
+		if resp.Slot < last.Slot || (resp.Slot == last.Slot && resp.Index != nil && last.Index != nil && *resp.Index <= *last.Index) {
+			return status.Errorf(codes.Internal, "transaction of slot %d (index %d) sent after slot %d (index %d)", resp.Slot, resp.GetIndex(), last.Slot, last.GetIndex())
+		}
+	}
+	if err := s.gaps.flush(); err != nil {
+		return err
+	}
+	if err := s.OldFaithful_StreamTransactionsServer.Send(resp); err != nil {
+		return err
+	}
+	s.last = resp
+	return nil
+}
+
+// slotTransaction is a transaction of a slot found with the gsfa indexes.
+type slotTransaction struct {
+	epoch *Epoch
+	node  *ipldbindcode.Transaction
+	tx    solana.Transaction
+	meta  any
+}
+
+// orderSlotTransactions sorts the transactions of a slot found with the gsfa indexes (of several
+// accounts, from the most recent) by their position in the block, and drops the duplicates.
+// The transactions without a position (old CARs) are kept last, by signature.
+func orderSlotTransactions(txns []slotTransaction) []slotTransaction {
+	seen := make(map[string]bool, len(txns))
+	ordered := make([]slotTransaction, 0, len(txns))
+	for _, txn := range txns {
+		sig := string(signatureOf(txn.tx))
+		if seen[sig] {
+			continue
+		}
+		seen[sig] = true
+		ordered = append(ordered, txn)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		pi, iok := ordered[i].node.GetPositionIndex()
+		pj, jok := ordered[j].node.GetPositionIndex()
+		switch {
+		case iok && jok:
+			return pi < pj
+		case iok != jok:
+			return iok
+		default:
+			return bytes.Compare(signatureOf(ordered[i].tx), signatureOf(ordered[j].tx)) < 0
+		}
+	})
+	return ordered
+}
+
+func signatureOf(tx solana.Transaction) []byte {
+	if len(tx.Signatures) == 0 {
+		return nil
+	}
+	return tx.Signatures[0][:]
+}
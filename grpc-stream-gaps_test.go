@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testTransactionsServer collects the messages of a StreamTransactions stream.
+type testTransactionsServer struct {
+	grpc.ServerStream
+	ctx  context.Context
+	msgs []*old_faithful_grpc.TransactionResponse
+}
+
+func (s *testTransactionsServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testTransactionsServer) Send(resp *old_faithful_grpc.TransactionResponse) error {
+	s.msgs = append(s.msgs, resp)
+	return nil
+}
+
+func TestStreamTransactionsGaps(t *testing.T) {
+	config, _, slots, _ := buildTestEpoch(t)
+	epoch := openTestEpoch(t, config)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+	ctx := context.Background()
+	reportGaps := true
+
+	// the range ends past the last block of the CAR.
+	end := slots[len(slots)-1] + 3
+	ser := &testTransactionsServer{ctx: ctx}
+	require.NoError(t, multi.StreamTransactions(&old_faithful_grpc.StreamTransactionsRequest{StartSlot: slots[0], EndSlot: &end, ReportGaps: &reportGaps}, ser))
+	// the slots of the range without a block are reported in order, between the transactions.
+	var wantGaps []*old_faithful_grpc.StreamGap
+	for slot := slots[0]; slot <= end; slot++ {
+		if slices.Contains(slots, slot) {
+			continue
+		}
+		if n := len(wantGaps); n > 0 && wantGaps[n-1].LastSlot+1 == slot {
+			wantGaps[n-1].LastSlot = slot
+			continue
+		}
+		wantGaps = append(wantGaps, &old_faithful_grpc.StreamGap{FirstSlot: slot, LastSlot: slot})
+	}
+	require.NotEmpty(t, wantGaps)
+	var gotGaps []*old_faithful_grpc.StreamGap
+	var last *old_faithful_grpc.TransactionResponse
+	var lastSlot uint64
+	for _, msg := range ser.msgs {
+		if gap := msg.Gap; gap != nil {
+			require.Greater(t, gap.FirstSlot, lastSlot)
+			gotGaps = append(gotGaps, gap)
+			lastSlot = gap.LastSlot
+			continue
+		}
+		require.Contains(t, slots, msg.Slot)
+		require.GreaterOrEqual(t, msg.Slot, lastSlot)
+		if last != nil && last.Slot == msg.Slot {
+			require.Greater(t, msg.GetIndex(), last.GetIndex())
+		}
+		last, lastSlot = msg, msg.Slot
+	}
+	require.Equal(t, wantGaps, gotGaps)
+
+	// without report_gaps, only the transactions.
+	plain := &testTransactionsServer{ctx: ctx}
+	require.NoError(t, multi.StreamTransactions(&old_faithful_grpc.StreamTransactionsRequest{StartSlot: slots[0], EndSlot: &end}, plain))
+	for _, msg := range plain.msgs {
+		require.Nil(t, msg.Gap)
+	}
+	require.Len(t, ser.msgs, len(plain.msgs)+countGaps(ser.msgs))
+
+	// the slots of an epoch that is not served.
+	start, _ := slottools.CalcEpochLimits(5)
+	end = start + 9
+	ser = &testTransactionsServer{ctx: ctx}
+	require.NoError(t, multi.StreamTransactions(&old_faithful_grpc.StreamTransactionsRequest{StartSlot: start, EndSlot: &end, ReportGaps: &reportGaps}, ser))
+	require.Len(t, ser.msgs, 1)
+	require.Equal(t, &old_faithful_grpc.StreamGap{FirstSlot: start, LastSlot: end, EpochUnavailable: true}, ser.msgs[0].Gap)
+}
+
+func countGaps(msgs []*old_faithful_grpc.TransactionResponse) int {
+	n := 0
+	for _, msg := range msgs {
+		if msg.Gap != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func TestStreamGaps(t *testing.T) {
+	var sent []*old_faithful_grpc.StreamGap
+	gaps := newStreamGaps(true, func(gap *old_faithful_grpc.StreamGap) error {
+		sent = append(sent, gap)
+		return nil
+	})
+	for _, slot := range []uint64{3, 4, 5} {
+		require.NoError(t, gaps.add(slot, false))
+	}
+	require.NoError(t, gaps.add(6, true))
+	require.NoError(t, gaps.add(8, true))
+	require.NoError(t, gaps.flush())
+	require.NoError(t, gaps.flush())
+	require.Equal(t, []*old_faithful_grpc.StreamGap{
+		{FirstSlot: 3, LastSlot: 5},
+		{FirstSlot: 6, LastSlot: 6, EpochUnavailable: true},
+		{FirstSlot: 8, LastSlot: 8, EpochUnavailable: true},
+	}, sent)
+
+	var disabled *streamGaps
+	require.Nil(t, newStreamGaps(false, nil))
+	require.NoError(t, disabled.add(1, false))
+	require.NoError(t, disabled.flush())
+
+	// the ordered server sends the pending gap first, and rejects the transactions out of order.
+	sent = nil
+	ser := &orderedTransactionsServer{OldFaithful_StreamTransactionsServer: &testTransactionsServer{ctx: context.Background()}, gaps: gaps}
+	require.NoError(t, gaps.add(9, false))
+	require.NoError(t, ser.Send(&old_faithful_grpc.TransactionResponse{Slot: 10, Index: ptrToUint64(0)}))
+	require.Len(t, sent, 1)
+	require.NoError(t, ser.Send(&old_faithful_grpc.TransactionResponse{Slot: 10, Index: ptrToUint64(1)}))
+	err := ser.Send(&old_faithful_grpc.TransactionResponse{Slot: 10, Index: ptrToUint64(1)})
+	require.Equal(t, codes.Internal, status.Code(err))
+	err = ser.Send(&old_faithful_grpc.TransactionResponse{Slot: 9})
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.NoError(t, ser.Send(&old_faithful_grpc.TransactionResponse{Slot: 11}))
+}
+
+func TestOrderSlotTransactions(t *testing.T) {
+	tx := func(sig byte, position int, hasPosition bool) slotTransaction {
+		node := &ipldbindcode.Transaction{}
+		if hasPosition {
+			index := &position
+			node.Index = &index
+		}
+		return slotTransaction{node: node, tx: solana.Transaction{Signatures: []solana.Signature{{sig}}}}
+	}
+	// the gsfa indexes return the transactions from the most recent, and the accounts can share some.
+	ordered := orderSlotTransactions([]slotTransaction{
+		tx(3, 7, true), tx(1, 2, true),
+		tx(9, 0, false), tx(8, 0, false),
+		tx(2, 5, true), tx(1, 2, true),
+	})
+	var sigs []byte
+	for _, txn := range ordered {
+		sigs = append(sigs, txn.tx.Signatures[0][0])
+	}
+	require.Equal(t, []byte{1, 2, 3, 8, 9}, sigs)
+}
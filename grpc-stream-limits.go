@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rpcpool/yellowstone-faithful/metrics"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+)
+
+// grpcTokenHeader is the metadata key of the token of a gRPC client, and grpcForwardedForHeader
+// the one of the address of a client behind a proxy. They only identify the clients of the trusted
+// proxies, which authenticate them; the other clients are told apart by their IP address.
+const (
+	grpcTokenHeader        = "x-token"
+	grpcForwardedForHeader = "x-forwarded-for"
+)
+
+// grpcStreamLimits limits the resources of the gRPC streams (StreamBlocks, StreamTransactions and Get),
+// so that a few replay clients can't starve the interactive requests:
+//   - the number of concurrent streams of a client (see clientKey),
+//   - the bytes per second sent by each stream,
+//   - the bytes per second sent by all the streams together, split evenly between the clients with
+//     open streams (whatever their number of streams), so that a client can't take the bandwidth
+//     of the others by opening more streams,
+//   - the block memory used by all the streams together: the blocks assembled by streams reserve it
+//     before the block memory budget, so the rest of the budget stays available to the other requests.
+//     It's first come, first served.
+//
+// The limits that are zero are not enforced; the streams are accounted for anyway (metrics and logs).
+type grpcStreamLimits struct {
+	maxStreamsPerClient int
+	bytesPerSecond      int64
+	totalBytesPerSecond int64
+	// memory is nil if the memory of the streams is not limited apart from the block memory budget.
+	memory         *semaphore.Weighted
+	memorySize     int64
+	trustedProxies []*net.IPNet
+
+	mu      sync.Mutex
+	clients map[string]*grpcStreamClient
+}
+
+// grpcStreamClient is a client with open streams.
+type grpcStreamClient struct {
+	streams int
+	// limiter is the share of the client of the bandwidth of all the streams; nil if it's not limited.
+	limiter *rate.Limiter
+}
+
+func newGRPCStreamLimits(options *Options) *grpcStreamLimits {
+	l := &grpcStreamLimits{clients: make(map[string]*grpcStreamClient)}
+	if options == nil {
+		return l
+	}
+	l.maxStreamsPerClient = options.GRPCMaxStreamsPerClient
+	l.bytesPerSecond = options.GRPCStreamBytesPerSecond
+	l.totalBytesPerSecond = options.GRPCStreamsBytesPerSecond
+	if options.GRPCStreamMemory > 0 {
+		l.memory = semaphore.NewWeighted(options.GRPCStreamMemory)
+		l.memorySize = options.GRPCStreamMemory
+	}
+	l.trustedProxies = options.GRPCTrustedProxies
+	return l
+}
+
+// parseTrustedProxies parses the IP addresses and the CIDR ranges of the trusted proxies.
+func parseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	proxies := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, ipNet)
+	}
+	return proxies, nil
+}
+
+// clientKey returns the key of the client of a stream: its IP address, or, if it comes through a
+// trusted proxy, its token, or else the address forwarded by the proxy. The token is not
+// validated here: an untrusted client could otherwise get as many streams as it makes up tokens.
+func (l *grpcStreamLimits) clientKey(ctx context.Context) string {
+	ip := grpcPeerIP(ctx)
+	if ip == nil {
+		return "unknown"
+	}
+	if l.isTrustedProxy(ip) {
+		if values := metadata.ValueFromIncomingContext(ctx, grpcTokenHeader); len(values) > 0 && values[0] != "" {
+			return "token:" + values[0]
+		}
+		if values := metadata.ValueFromIncomingContext(ctx, grpcForwardedForHeader); len(values) > 0 {
+			// the client is the first address of the chain of proxies.
+			first, _, _ := strings.Cut(values[0], ",")
+			if forwarded := net.ParseIP(strings.TrimSpace(first)); forwarded != nil {
+				return "ip:" + forwarded.String()
+			}
+		}
+	}
+	return "ip:" + ip.String()
+}
+
+func (l *grpcStreamLimits) isTrustedProxy(ip net.IP) bool {
+	for _, proxy := range l.trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// grpcPeerIP returns the IP address of the peer of a stream, or nil if it's unknown.
+func grpcPeerIP(ctx context.Context) net.IP {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return nil
+	}
+	if addr, ok := p.Addr.(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return net.ParseIP(host)
+}
+
+// acquire counts a new stream of the client; it returns nil if the client has too many streams.
+func (l *grpcStreamLimits) acquire(key string) *grpcStreamClient {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	client, ok := l.clients[key]
+	if !ok {
+		client = &grpcStreamClient{}
+		if l.totalBytesPerSecond > 0 {
+			// the burst stays a second's worth of the whole bandwidth (only the rate is shared).
+			client.limiter = rate.NewLimiter(0, int(l.totalBytesPerSecond))
+		}
+		l.clients[key] = client
+		l.shareBandwidth()
+	}
+	if l.maxStreamsPerClient > 0 && client.streams >= l.maxStreamsPerClient {
+		return nil
+	}
+	client.streams++
+	return client
+}
+
+func (l *grpcStreamLimits) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	client := l.clients[key]
+	client.streams--
+	if client.streams <= 0 {
+		delete(l.clients, key)
+		l.shareBandwidth()
+	}
+}
+
+// shareBandwidth splits the bandwidth of all the streams evenly between the clients; l.mu must be held.
+func (l *grpcStreamLimits) shareBandwidth() {
+	if l.totalBytesPerSecond <= 0 || len(l.clients) == 0 {
+		return
+	}
+	share := rate.Limit(l.totalBytesPerSecond) / rate.Limit(len(l.clients))
+	for _, client := range l.clients {
+		client.limiter.SetLimit(share)
+	}
+}
+
+// interceptor enforces the limits on the streams, and accounts for their resources.
+func (l *grpcStreamLimits) interceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	key := l.clientKey(ss.Context())
+	client := l.acquire(key)
+	if client == nil {
+		metrics.GRPCStreamsRejected.WithLabelValues(info.FullMethod).Inc()
+		return status.Errorf(codes.ResourceExhausted, "too many concurrent streams (max %d per client)", l.maxStreamsPerClient)
+	}
+	defer l.release(key)
+	metrics.GRPCStreamsActive.WithLabelValues(info.FullMethod).Inc()
+	defer metrics.GRPCStreamsActive.WithLabelValues(info.FullMethod).Dec()
+
+	account := &grpcStreamAccount{limits: l, started: time.Now()}
+	if l.bytesPerSecond > 0 {
+		account.limiters = append(account.limiters, rate.NewLimiter(rate.Limit(l.bytesPerSecond), int(l.bytesPerSecond)))
+	}
+	if client.limiter != nil {
+		account.limiters = append(account.limiters, client.limiter)
+	}
+	err := handler(srv, &accountedServerStream{
+		ServerStream: ss,
+		ctx:          context.WithValue(ss.Context(), grpcStreamAccountKey{}, account),
+		account:      account,
+		method:       info.FullMethod,
+	})
+	klog.V(2).Infof("gRPC stream %s of %s ended after %s: %d messages, %d bytes sent, %d bytes of block memory at most, %s throttled",
+		info.FullMethod, key, time.Since(account.started).Truncate(time.Millisecond),
+		account.messagesSent, account.bytesSent, account.peakMemory, account.throttled.Truncate(time.Millisecond))
+	return err
+}
+
+type grpcStreamAccountKey struct{}
+
+// grpcStreamAccount is the resources used by a stream.
+type grpcStreamAccount struct {
+	limits *grpcStreamLimits
+	// limiters are the bandwidth of the stream, and the share of its client of the bandwidth of
+	// all the streams (if they're limited).
+	limiters []*rate.Limiter
+	started  time.Time
+
+	mu           sync.Mutex
+	messagesSent uint64
+	bytesSent    uint64
+	throttled    time.Duration
+	memory       int64
+	peakMemory   int64
+}
+
+// throttle waits until the stream can send n more bytes.
+func (a *grpcStreamAccount) throttle(ctx context.Context, n int) error {
+	if len(a.limiters) == 0 {
+		return nil
+	}
+	startedAt := time.Now()
+	defer func() {
+		a.mu.Lock()
+		a.throttled += time.Since(startedAt)
+		a.mu.Unlock()
+	}()
+	for _, limiter := range a.limiters {
+		if err := waitBytes(ctx, limiter, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitBytes waits until the limiter allows n bytes; more than a second's worth of bytes (the burst)
+// is waited for in bursts.
+func waitBytes(ctx context.Context, limiter *rate.Limiter, n int) error {
+	for burst := limiter.Burst(); n > 0; n -= burst {
+		if err := limiter.WaitN(ctx, min(n, burst)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *grpcStreamAccount) recordSent(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.messagesSent++
+	a.bytesSent += uint64(n)
+}
+
+// reserveMemory reserves n bytes of the memory of the streams (waiting until the other streams
+// release enough of it); the returned function releases them.
+func (a *grpcStreamAccount) reserveMemory(ctx context.Context, n int64) (func(), error) {
+	memory := a.limits.memory
+	if memory != nil {
+		n = min(n, a.limits.memorySize)
+		if err := memory.Acquire(ctx, n); err != nil {
+			return nil, err
+		}
+	}
+	a.mu.Lock()
+	a.memory += n
+	a.peakMemory = max(a.peakMemory, a.memory)
+	a.mu.Unlock()
+	metrics.GRPCStreamMemoryReserved.Add(float64(n))
+	return func() {
+		metrics.GRPCStreamMemoryReserved.Sub(float64(n))
+		a.mu.Lock()
+		a.memory -= n
+		a.mu.Unlock()
+		if memory != nil {
+			memory.Release(n)
+		}
+	}, nil
+}
+
+// reserveStreamMemory reserves n bytes of the memory of the streams if ctx is the context of a stream;
+// otherwise it does nothing.
+func reserveStreamMemory(ctx context.Context, n int64) (func(), error) {
+	account, ok := ctx.Value(grpcStreamAccountKey{}).(*grpcStreamAccount)
+	if !ok || n <= 0 {
+		return func() {}, nil
+	}
+	return account.reserveMemory(ctx, n)
+}
+
+// accountedServerStream throttles and accounts for the messages sent on a stream.
+type accountedServerStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	account *grpcStreamAccount
+	method  string
+}
+
+func (s *accountedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *accountedServerStream) SendMsg(m any) error {
+	n := 0
+	if msg, ok := m.(proto.Message); ok {
+		n = proto.Size(msg)
+	}
+	if err := s.account.throttle(s.ctx, n); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+	s.account.recordSent(n)
+	metrics.GRPCStreamBytesSent.WithLabelValues(s.method).Add(float64(n))
+	return nil
+}
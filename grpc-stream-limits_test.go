@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// testServerStream records the messages sent on a stream.
+type testServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []any
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testServerStream) SendMsg(m any) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+// streamOf returns a stream of the client at ip, with the metadata key/value pairs.
+func streamOf(ip string, kv ...string) *testServerStream {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+	return &testServerStream{ctx: metadata.NewIncomingContext(ctx, metadata.Pairs(kv...))}
+}
+
+var testStreamInfo = &grpc.StreamServerInfo{FullMethod: "/OldFaithful/StreamBlocks", IsServerStream: true}
+
+func TestGRPCStreamLimits_StreamsPerClient(t *testing.T) {
+	limits := newGRPCStreamLimits(&Options{GRPCMaxStreamsPerClient: 1})
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- limits.interceptor(nil, streamOf("10.0.0.1"), testStreamInfo, func(srv any, ss grpc.ServerStream) error {
+			close(started)
+			<-finish
+			return nil
+		})
+	}()
+	<-started
+
+	noop := func(srv any, ss grpc.ServerStream) error { return nil }
+	err := limits.interceptor(nil, streamOf("10.0.0.1"), testStreamInfo, noop)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	// another client is not limited by the streams of the first one.
+	require.NoError(t, limits.interceptor(nil, streamOf("10.0.0.2"), testStreamInfo, noop))
+
+	close(finish)
+	require.NoError(t, <-done)
+	require.NoError(t, limits.interceptor(nil, streamOf("10.0.0.1"), testStreamInfo, noop))
+}
+
+func TestGRPCStreamLimits_ClientKey(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"192.168.0.0/16", "10.0.0.1", "::1"})
+	require.NoError(t, err)
+	limits := newGRPCStreamLimits(&Options{GRPCTrustedProxies: proxies})
+	for _, tt := range []struct {
+		stream *testServerStream
+		key    string
+	}{
+		// the token and the forwarded address of an untrusted client are ignored.
+		{streamOf("10.0.0.2", grpcTokenHeader, "a"), "ip:10.0.0.2"},
+		{streamOf("10.0.0.2", grpcForwardedForHeader, "1.2.3.4"), "ip:10.0.0.2"},
+		{streamOf("10.0.0.1", grpcTokenHeader, "a", grpcForwardedForHeader, "1.2.3.4"), "token:a"},
+		{streamOf("192.168.1.1", grpcForwardedForHeader, "1.2.3.4, 192.168.1.2"), "ip:1.2.3.4"},
+		{streamOf("::1", grpcForwardedForHeader, "not an ip"), "ip:::1"},
+		{streamOf("192.168.1.1"), "ip:192.168.1.1"},
+		{&testServerStream{ctx: context.Background()}, "unknown"},
+	} {
+		require.Equal(t, tt.key, limits.clientKey(tt.stream.ctx))
+	}
+
+	_, err = parseTrustedProxies([]string{"10.0.0.256"})
+	require.ErrorContains(t, err, `invalid IP address "10.0.0.256"`)
+	_, err = parseTrustedProxies([]string{"10.0.0.0/33"})
+	require.Error(t, err)
+}
+
+func TestGRPCStreamLimits_SharedBandwidth(t *testing.T) {
+	limits := newGRPCStreamLimits(&Options{GRPCStreamsBytesPerSecond: 10_000})
+	a := limits.acquire("ip:10.0.0.1")
+	require.Equal(t, rate.Limit(10_000), a.limiter.Limit())
+	// the bandwidth is split evenly between the clients, whatever their number of streams.
+	b := limits.acquire("ip:10.0.0.2")
+	require.Same(t, a, limits.acquire("ip:10.0.0.1"))
+	for _, client := range []*grpcStreamClient{a, b} {
+		require.Equal(t, rate.Limit(5_000), client.limiter.Limit())
+	}
+	limits.release("ip:10.0.0.2")
+	require.Equal(t, rate.Limit(10_000), a.limiter.Limit())
+
+	// the streams of a client share its bandwidth.
+	for i := 0; i < 2; i++ {
+		limits.release("ip:10.0.0.1")
+	}
+	require.Empty(t, limits.clients)
+	msg := &old_faithful_grpc.BlockResponse{Blockhash: make([]byte, 2_500)}
+	startedAt := time.Now()
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			done <- limits.interceptor(nil, streamOf("10.0.0.1"), testStreamInfo, func(srv any, ss grpc.ServerStream) error {
+				// the first second's worth of bytes goes at once, the rest at 10_000 bytes per second.
+				for i := 0; i < 4; i++ {
+					if err := ss.SendMsg(msg); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-done)
+	}
+	require.GreaterOrEqual(t, time.Since(startedAt), 900*time.Millisecond)
+}
+
+func TestGRPCStreamLimits_Bandwidth(t *testing.T) {
+	const rate = 10_000
+	limits := newGRPCStreamLimits(&Options{GRPCStreamBytesPerSecond: rate})
+	msg := &old_faithful_grpc.BlockResponse{Blockhash: make([]byte, rate/2)}
+	ss := streamOf("10.0.0.1")
+	startedAt := time.Now()
+	err := limits.interceptor(nil, ss, testStreamInfo, func(srv any, ss grpc.ServerStream) error {
+		// the first second's worth of bytes goes at once; the third message waits for half a second.
+		for i := 0; i < 3; i++ {
+			if err := ss.SendMsg(msg); err != nil {
+				return err
+			}
+		}
+		// a message larger than the burst goes too.
+		return ss.SendMsg(&old_faithful_grpc.BlockResponse{Blockhash: make([]byte, rate+1)})
+	})
+	require.NoError(t, err)
+	require.Len(t, ss.sent, 4)
+	require.GreaterOrEqual(t, time.Since(startedAt), 1400*time.Millisecond)
+
+	// a canceled stream stops waiting.
+	ctx, cancel := context.WithCancel(ss.ctx)
+	cancel()
+	err = limits.interceptor(nil, &testServerStream{ctx: ctx}, testStreamInfo, func(srv any, ss grpc.ServerStream) error {
+		for {
+			if err := ss.SendMsg(msg); err != nil {
+				return err
+			}
+		}
+	})
+	require.Equal(t, codes.Canceled, status.Code(err))
+}
+
+func TestGRPCStreamLimits_Memory(t *testing.T) {
+	limits := newGRPCStreamLimits(&Options{GRPCStreamMemory: 100})
+
+	// outside a stream, nothing is reserved.
+	release, err := reserveStreamMemory(context.Background(), 1000)
+	require.NoError(t, err)
+	release()
+
+	err = limits.interceptor(nil, streamOf("10.0.0.1"), testStreamInfo, func(srv any, ss grpc.ServerStream) error {
+		ctx := ss.Context()
+		release, err := reserveStreamMemory(ctx, 80)
+		require.NoError(t, err)
+		// the memory of the streams is full: the next block waits.
+		waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err = reserveStreamMemory(waitCtx, 80)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		release()
+		// a block larger than the whole limit reserves the whole limit.
+		release, err = reserveStreamMemory(ctx, 1000)
+		require.NoError(t, err)
+		release()
+		require.Equal(t, int64(100), ctx.Value(grpcStreamAccountKey{}).(*grpcStreamAccount).peakMemory)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestGRPCStreamLimits_GetBlock(t *testing.T) {
+	config, _, slots, _ := buildTestEpoch(t)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, openTestEpoch(t, config)))
+	limits := newGRPCStreamLimits(&Options{GRPCStreamMemory: 1 << 30})
+
+	// the blocks of a stream reserve the memory of the streams.
+	err := limits.interceptor(nil, streamOf("10.0.0.1"), testStreamInfo, func(srv any, ss grpc.ServerStream) error {
+		_, err := multi.GetBlock(ss.Context(), &old_faithful_grpc.BlockRequest{Slot: slots[1]})
+		require.NoError(t, err)
+		account := ss.Context().Value(grpcStreamAccountKey{}).(*grpcStreamAccount)
+		require.Positive(t, account.peakMemory)
+		require.Zero(t, account.memory)
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"time"
+
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// minProgressInterval is the minimum interval between two progress frames.
+const minProgressInterval = 100 * time.Millisecond
+
+// streamProgress tracks the progress and throughput of a StreamBlocks/StreamTransactions stream.
+type streamProgress struct {
+	startSlot uint64
+	endSlot   uint64
+	interval  time.Duration
+	started   time.Time
+	lastFrame time.Time
+
+	messagesSent uint64
+	bytesSent    uint64
+}
+
+// newStreamProgress returns nil if the client did not ask for progress frames.
+func newStreamProgress(startSlot, endSlot uint64, intervalMs *uint32) *streamProgress {
+	if intervalMs == nil {
+		return nil
+	}
+	interval := time.Duration(*intervalMs) * time.Millisecond
+	if interval < minProgressInterval {
+		interval = minProgressInterval
+	}
+	now := time.Now()
+	return &streamProgress{
+		startSlot: startSlot,
+		endSlot:   endSlot,
+		interval:  interval,
+		started:   now,
+		lastFrame: now,
+	}
+}
+
+// recordSent records a message sent to the client.
+func (p *streamProgress) recordSent(msg proto.Message) {
+	if p == nil {
+		return
+	}
+	p.messagesSent++
+	p.bytesSent += uint64(proto.Size(msg))
+}
+
+// next returns a progress frame if one is due (or if done is true), or nil.
+func (p *streamProgress) next(currentSlot uint64, done bool) *old_faithful_grpc.StreamProgress {
+	if p == nil {
+		return nil
+	}
+	now := time.Now()
+	if !done && now.Sub(p.lastFrame) < p.interval {
+		return nil
+	}
+	p.lastFrame = now
+
+	frame := &old_faithful_grpc.StreamProgress{
+		CurrentSlot:  currentSlot,
+		StartSlot:    p.startSlot,
+		EndSlot:      p.endSlot,
+		MessagesSent: p.messagesSent,
+		BytesSent:    p.bytesSent,
+		Done:         done,
+	}
+	slotsProcessed := uint64(0)
+	if currentSlot >= p.startSlot {
+		slotsProcessed = currentSlot - p.startSlot + 1
+	}
+	if p.endSlot >= p.startSlot {
+		frame.Percent = float64(slotsProcessed) / float64(p.endSlot-p.startSlot+1) * 100
+	}
+	if elapsed := now.Sub(p.started).Seconds(); elapsed > 0 {
+		frame.SlotsPerSecond = float64(slotsProcessed) / elapsed
+		frame.MessagesPerSecond = float64(p.messagesSent) / elapsed
+		frame.BytesPerSecond = float64(p.bytesSent) / elapsed
+	}
+	return frame
+}
+
+// progressTransactionsServer counts the transactions sent to the client.
+type progressTransactionsServer struct {
+	old_faithful_grpc.OldFaithful_StreamTransactionsServer
+	progress *streamProgress
+}
+
+func (s *progressTransactionsServer) Send(resp *old_faithful_grpc.TransactionResponse) error {
+	if err := s.OldFaithful_StreamTransactionsServer.Send(resp); err != nil {
+		return err
+	}
+	s.progress.recordSent(resp)
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamProgress(t *testing.T) {
+	require.Nil(t, newStreamProgress(10, 19, nil))
+	var disabled *streamProgress
+	disabled.recordSent(&old_faithful_grpc.BlockResponse{})
+	require.Nil(t, disabled.next(10, true))
+
+	interval := uint32(60_000)
+	progress := newStreamProgress(10, 19, &interval)
+	progress.recordSent(&old_faithful_grpc.BlockResponse{Slot: 10})
+	progress.recordSent(&old_faithful_grpc.BlockResponse{Slot: 11})
+
+	// not due yet.
+	require.Nil(t, progress.next(14, false))
+
+	frame := progress.next(14, true)
+	require.NotNil(t, frame)
+	require.True(t, frame.Done)
+	require.Equal(t, uint64(14), frame.CurrentSlot)
+	require.Equal(t, uint64(10), frame.StartSlot)
+	require.Equal(t, uint64(19), frame.EndSlot)
+	require.InDelta(t, 50.0, frame.Percent, 0.001)
+	require.Equal(t, uint64(2), frame.MessagesSent)
+	require.Positive(t, frame.BytesSent)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+
+	"github.com/gagliardetto/solana-go"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxTransactionsBatchSize is the maximum number of signatures in a GetTransactionsBatch request.
+	maxTransactionsBatchSize = 100
+	// transactionsBatchConcurrency is the number of transactions of a batch that are looked up in parallel.
+	transactionsBatchConcurrency = 16
+)
+
+// GetTransactionsBatch returns the transactions with the given signatures; a transaction that can't be
+// returned has an error result (NOT_FOUND or INTERNAL) and doesn't fail the whole batch.
+func (multi *MultiEpoch) GetTransactionsBatch(ctx context.Context, params *old_faithful_grpc.TransactionsBatchRequest) (*old_faithful_grpc.TransactionsBatchResponse, error) {
+	if len(params.Signatures) > maxTransactionsBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "too many signatures: %d (max %d)", len(params.Signatures), maxTransactionsBatchSize)
+	}
+	for i, sig := range params.Signatures {
+		if len(sig) != solana.SignatureLength {
+			return nil, status.Errorf(codes.InvalidArgument, "signature %d: invalid length %d", i, len(sig))
+		}
+	}
+
+	results := make([]*old_faithful_grpc.TransactionsBatchResult, len(params.Signatures))
+	wg, wgCtx := errgroup.WithContext(ctx)
+	wg.SetLimit(transactionsBatchConcurrency)
+	for i, sig := range params.Signatures {
+		i, sig := i, sig
+		wg.Go(func() error {
+			resp, err := multi.GetTransaction(wgCtx, &old_faithful_grpc.TransactionRequest{Signature: sig})
+			if err != nil {
+				if wgCtx.Err() != nil {
+					return wgCtx.Err()
+				}
+				results[i] = &old_faithful_grpc.TransactionsBatchResult{
+					Result: &old_faithful_grpc.TransactionsBatchResult_Error{Error: toGetResponseError(err)},
+				}
+				return nil
+			}
+			results[i] = &old_faithful_grpc.TransactionsBatchResult{
+				Result: &old_faithful_grpc.TransactionsBatchResult_Transaction{Transaction: resp},
+			}
+			return nil
+		})
+	}
+	if err := wg.Wait(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return &old_faithful_grpc.TransactionsBatchResponse{Results: results}, nil
+}
+
+// toGetResponseError converts an error returned by a gRPC handler to a GetResponseError.
+func toGetResponseError(err error) *old_faithful_grpc.GetResponseError {
+	gerr := status.Convert(err)
+	code := old_faithful_grpc.GetResponseErrorCode_INTERNAL
+	if gerr.Code() == codes.NotFound {
+		code = old_faithful_grpc.GetResponseErrorCode_NOT_FOUND
+	}
+	return &old_faithful_grpc.GetResponseError{
+		Code:    code,
+		Message: gerr.Message(),
+	}
+}
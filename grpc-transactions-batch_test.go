@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetTransactionsBatch(t *testing.T) {
+	ctx := context.Background()
+	multi := NewMultiEpoch(&Options{})
+
+	{
+		_, err := multi.GetTransactionsBatch(ctx, &old_faithful_grpc.TransactionsBatchRequest{
+			Signatures: make([][]byte, maxTransactionsBatchSize+1),
+		})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+	{
+		_, err := multi.GetTransactionsBatch(ctx, &old_faithful_grpc.TransactionsBatchRequest{
+			Signatures: [][]byte{make([]byte, 10)},
+		})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+	{
+		// no epochs: every lookup fails, but the batch doesn't.
+		resp, err := multi.GetTransactionsBatch(ctx, &old_faithful_grpc.TransactionsBatchRequest{
+			Signatures: [][]byte{make([]byte, 64), make([]byte, 64)},
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Results, 2)
+		for _, result := range resp.Results {
+			require.NotNil(t, result.GetError())
+			require.Equal(t, old_faithful_grpc.GetResponseErrorCode_INTERNAL, result.GetError().Code)
+		}
+	}
+}
+
+func TestToGetResponseError(t *testing.T) {
+	require.Equal(t, old_faithful_grpc.GetResponseErrorCode_NOT_FOUND, toGetResponseError(status.Error(codes.NotFound, "nope")).Code)
+	require.Equal(t, old_faithful_grpc.GetResponseErrorCode_INTERNAL, toGetResponseError(status.Error(codes.Unavailable, "nope")).Code)
+	require.Equal(t, "nope", toGetResponseError(status.Error(codes.Unavailable, "nope")).Message)
+}
@@ -0,0 +1,205 @@
+// Package grpcclient is a client of the old-faithful gRPC API, for Go programs that consume its streams.
+//
+// The streams are reopened where they stopped when the connection fails, they buffer a bounded
+// number of messages ahead of the consumer (a slow consumer slows the server down instead of
+// growing the buffer), and the package has builders for the stream filters and helpers to decode
+// the messages into solana-go types:
+//
+//	c, err := grpcclient.Dial("localhost:8889", grpcclient.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	stream := c.StreamTransactions(ctx, &old_faithful_grpc.StreamTransactionsRequest{
+//		StartSlot: 1000,
+//		EndSlot:   proto.Uint64(2000),
+//		Filter:    grpcclient.NewTransactionsFilter().Vote(false).Include(account).Build(),
+//	})
+//	defer stream.Close()
+//	for {
+//		resp, err := stream.Recv()
+//		if err == io.EOF {
+//			break
+//		}
+//		if err != nil {
+//			return err
+//		}
+//		tx, err := grpcclient.DecodeTransaction(resp.Transaction)
+//		...
+//	}
+package grpcclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// DefaultStreamSlots is the number of slots of a stream without an end slot (like the server).
+const DefaultStreamSlots = 100
+
+// Options are the options of a Client; the zero value uses the defaults.
+type Options struct {
+	// MaxRetries is how many times in a row a failed stream is reopened before its error is
+	// returned (default 5); negative to never reopen the streams.
+	MaxRetries int
+	// RetryBackoff is the delay before reopening a stream (default 500ms); it doubles after each
+	// failed attempt, up to MaxRetryBackoff (default 10s).
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// BufferSize is the number of messages of a stream read ahead of the consumer (default 64).
+	// When the buffer is full, the client stops reading and gRPC flow control pauses the server.
+	BufferSize int
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxRetries == 0 {
+		o.MaxRetries = 5
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = 500 * time.Millisecond
+	}
+	if o.MaxRetryBackoff <= 0 {
+		o.MaxRetryBackoff = 10 * time.Second
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = 64
+	}
+	return o
+}
+
+// Client is a client of the old-faithful gRPC API. It's safe for concurrent use.
+type Client struct {
+	api  old_faithful_grpc.OldFaithfulClient
+	conn *grpc.ClientConn // only if the client owns the connection
+	opts Options
+}
+
+// New returns a client that uses the connection; closing the client doesn't close it.
+func New(conn grpc.ClientConnInterface, opts Options) *Client {
+	return &Client{
+		api:  old_faithful_grpc.NewOldFaithfulClient(conn),
+		opts: opts.withDefaults(),
+	}
+}
+
+// Dial returns a client connected to the server at target (host:port). Without dial options,
+// the connection is not encrypted; pass grpc.WithTransportCredentials for TLS.
+func Dial(target string, opts Options, dialOptions ...grpc.DialOption) (*Client, error) {
+	if len(dialOptions) == 0 {
+		dialOptions = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	conn, err := grpc.NewClient(target, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", target, err)
+	}
+	c := New(conn, opts)
+	c.conn = conn
+	return c, nil
+}
+
+// Close closes the connection, if the client opened it.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// API returns the generated client, for the unary methods.
+func (c *Client) API() old_faithful_grpc.OldFaithfulClient {
+	return c.api
+}
+
+// StreamBlocks streams the blocks of the slot range of the request.
+func (c *Client) StreamBlocks(ctx context.Context, req *old_faithful_grpc.StreamBlocksRequest, opts ...StreamOption) *Stream[*old_faithful_grpc.BlockResponse] {
+	if req == nil {
+		req = &old_faithful_grpc.StreamBlocksRequest{}
+	}
+	end := streamEnd(req.StartSlot, req.EndSlot)
+	so := applyStreamOptions(req.StartSlot, opts)
+	return newStream(ctx, c.opts, so.cursor, end, streamKind[*old_faithful_grpc.BlockResponse]{
+		open: func(ctx context.Context, start uint64) (func() (*old_faithful_grpc.BlockResponse, error), error) {
+			resumed := &old_faithful_grpc.StreamBlocksRequest{
+				StartSlot:          start,
+				EndSlot:            &end,
+				Filter:             req.Filter,
+				ProgressIntervalMs: req.ProgressIntervalMs,
+			}
+			stream, err := c.api.StreamBlocks(ctx, resumed, so.callOptions...)
+			if err != nil {
+				return nil, err
+			}
+			return stream.Recv, nil
+		},
+		slot:      (*old_faithful_grpc.BlockResponse).GetSlot,
+		progress:  (*old_faithful_grpc.BlockResponse).GetProgress,
+		wholeSlot: true,
+	})
+}
+
+// StreamTransactions streams the transactions of the slot range of the request, in slot order
+// (and in block order within a slot). With ReportGaps, the gap frames (Gap set) report the slots
+// without a block.
+func (c *Client) StreamTransactions(ctx context.Context, req *old_faithful_grpc.StreamTransactionsRequest, opts ...StreamOption) *Stream[*old_faithful_grpc.TransactionResponse] {
+	if req == nil {
+		req = &old_faithful_grpc.StreamTransactionsRequest{}
+	}
+	end := streamEnd(req.StartSlot, req.EndSlot)
+	so := applyStreamOptions(req.StartSlot, opts)
+	return newStream(ctx, c.opts, so.cursor, end, streamKind[*old_faithful_grpc.TransactionResponse]{
+		open: func(ctx context.Context, start uint64) (func() (*old_faithful_grpc.TransactionResponse, error), error) {
+			resumed := &old_faithful_grpc.StreamTransactionsRequest{
+				StartSlot:          start,
+				EndSlot:            &end,
+				Filter:             req.Filter,
+				ProgressIntervalMs: req.ProgressIntervalMs,
+				ReportGaps:         req.ReportGaps,
+			}
+			stream, err := c.api.StreamTransactions(ctx, resumed, so.callOptions...)
+			if err != nil {
+				return nil, err
+			}
+			return stream.Recv, nil
+		},
+		slot:     (*old_faithful_grpc.TransactionResponse).GetSlot,
+		progress: (*old_faithful_grpc.TransactionResponse).GetProgress,
+		gap:      (*old_faithful_grpc.TransactionResponse).GetGap,
+	})
+}
+
+// StreamEntries streams the entries of the blocks of the slot range of the request.
+func (c *Client) StreamEntries(ctx context.Context, req *old_faithful_grpc.StreamEntriesRequest, opts ...StreamOption) *Stream[*old_faithful_grpc.EntryResponse] {
+	if req == nil {
+		req = &old_faithful_grpc.StreamEntriesRequest{}
+	}
+	end := streamEnd(req.StartSlot, req.EndSlot)
+	so := applyStreamOptions(req.StartSlot, opts)
+	return newStream(ctx, c.opts, so.cursor, end, streamKind[*old_faithful_grpc.EntryResponse]{
+		open: func(ctx context.Context, start uint64) (func() (*old_faithful_grpc.EntryResponse, error), error) {
+			resumed := &old_faithful_grpc.StreamEntriesRequest{
+				StartSlot:          start,
+				EndSlot:            &end,
+				ProgressIntervalMs: req.ProgressIntervalMs,
+			}
+			stream, err := c.api.StreamEntries(ctx, resumed, so.callOptions...)
+			if err != nil {
+				return nil, err
+			}
+			return stream.Recv, nil
+		},
+		slot:     (*old_faithful_grpc.EntryResponse).GetSlot,
+		progress: (*old_faithful_grpc.EntryResponse).GetProgress,
+	})
+}
+
+func streamEnd(start uint64, end *uint64) uint64 {
+	if end != nil {
+		return *end
+	}
+	return start + DefaultStreamSlots
+}
@@ -0,0 +1,290 @@
+package grpcclient
+
+import (
+	"context"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fixtureServer streams fixed blocks (slots 10-19 without 15, with slot%3+1 transactions each),
+// and fails the streams after a number of messages.
+type fixtureServer struct {
+	old_faithful_grpc.UnimplementedOldFaithfulServer
+
+	mu sync.Mutex
+	// failures are the number of messages after which the successive streams fail; a stream
+	// past the list doesn't fail.
+	failures []int
+	failCode codes.Code
+	starts   []uint64 // start slot of each stream
+}
+
+var fixtureSlots = []uint64{10, 11, 12, 13, 14, 16, 17, 18, 19}
+
+func fixtureTransactions(slot uint64) int {
+	return int(slot%3) + 1
+}
+
+// open records a stream, and returns after how many messages it fails (-1 if it doesn't).
+func (s *fixtureServer) open(start uint64) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.starts = append(s.starts, start)
+	if len(s.failures) == 0 {
+		return -1
+	}
+	failAfter := s.failures[0]
+	s.failures = s.failures[1:]
+	return failAfter
+}
+
+func (s *fixtureServer) fail(sent *int, failAfter int) error {
+	if *sent == failAfter {
+		code := s.failCode
+		if code == codes.OK {
+			code = codes.Unavailable
+		}
+		return status.Error(code, "injected failure")
+	}
+	*sent++
+	return nil
+}
+
+func (s *fixtureServer) StreamBlocks(req *old_faithful_grpc.StreamBlocksRequest, ser old_faithful_grpc.OldFaithful_StreamBlocksServer) error {
+	failAfter := s.open(req.StartSlot)
+	sent := 0
+	for _, slot := range fixtureSlots {
+		if slot < req.StartSlot || slot > req.GetEndSlot() {
+			continue
+		}
+		if err := s.fail(&sent, failAfter); err != nil {
+			return err
+		}
+		if err := ser.Send(&old_faithful_grpc.BlockResponse{Slot: slot, ParentSlot: slot - 1}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fixtureServer) StreamTransactions(req *old_faithful_grpc.StreamTransactionsRequest, ser old_faithful_grpc.OldFaithful_StreamTransactionsServer) error {
+	failAfter := s.open(req.StartSlot)
+	sent := 0
+	for slot := req.StartSlot; slot <= req.GetEndSlot(); slot++ {
+		if !slices.Contains(fixtureSlots, slot) {
+			if req.GetReportGaps() {
+				if err := ser.Send(&old_faithful_grpc.TransactionResponse{Gap: &old_faithful_grpc.StreamGap{FirstSlot: slot, LastSlot: slot}}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		for i := 0; i < fixtureTransactions(slot); i++ {
+			if err := s.fail(&sent, failAfter); err != nil {
+				return err
+			}
+			index := uint64(i)
+			if err := ser.Send(&old_faithful_grpc.TransactionResponse{Slot: slot, Index: &index}); err != nil {
+				return err
+			}
+		}
+		if req.ProgressIntervalMs != nil {
+			if err := ser.Send(&old_faithful_grpc.TransactionResponse{Progress: &old_faithful_grpc.StreamProgress{CurrentSlot: slot}}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func startFixtureServer(t *testing.T, server *fixtureServer, opts Options) *Client {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	old_faithful_grpc.RegisterOldFaithfulServer(grpcServer, server)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = time.Millisecond
+	}
+	return New(conn, opts)
+}
+
+func readAll[T any](t *testing.T, stream *Stream[T]) ([]T, error) {
+	t.Helper()
+	var msgs []T
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				return msgs, nil
+			}
+			return msgs, err
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestStreamBlocksResume(t *testing.T) {
+	server := &fixtureServer{failures: []int{3, 0, 2}}
+	client := startFixtureServer(t, server, Options{})
+	end := uint64(18)
+	stream := client.StreamBlocks(context.Background(), &old_faithful_grpc.StreamBlocksRequest{StartSlot: 10, EndSlot: &end})
+	defer stream.Close()
+
+	blocks, err := readAll(t, stream)
+	require.NoError(t, err)
+	var slots []uint64
+	for _, block := range blocks {
+		slots = append(slots, block.Slot)
+	}
+	require.Equal(t, []uint64{10, 11, 12, 13, 14, 16, 17, 18}, slots)
+	// reopened after the last block received each time.
+	require.Equal(t, []uint64{10, 13, 13, 15}, server.starts)
+	require.Equal(t, Cursor{Slot: 18, SlotDone: true}, stream.Cursor())
+}
+
+func TestStreamTransactionsResumeInSlot(t *testing.T) {
+	// slot 10 has 2 transactions, slot 11 has 3: the stream fails after the second transaction of slot 11.
+	server := &fixtureServer{failures: []int{4}}
+	client := startFixtureServer(t, server, Options{})
+	end := uint64(12)
+	stream := client.StreamTransactions(context.Background(), &old_faithful_grpc.StreamTransactionsRequest{StartSlot: 10, EndSlot: &end})
+	defer stream.Close()
+
+	type position struct{ slot, index uint64 }
+	var got []position
+	var cursors []Cursor
+	for {
+		tx, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, position{tx.Slot, tx.GetIndex()})
+		cursors = append(cursors, stream.Cursor())
+	}
+	require.Equal(t, []position{{10, 0}, {10, 1}, {11, 0}, {11, 1}, {11, 2}, {12, 0}}, got)
+	require.Equal(t, []uint64{10, 11}, server.starts)
+	require.Equal(t, Cursor{Slot: 11, Received: 2}, cursors[3])
+	require.Equal(t, Cursor{Slot: 12, Received: 1}, cursors[5])
+}
+
+func TestStreamResumeFrom(t *testing.T) {
+	server := &fixtureServer{}
+	client := startFixtureServer(t, server, Options{})
+	end := uint64(13)
+	req := &old_faithful_grpc.StreamTransactionsRequest{StartSlot: 10, EndSlot: &end, ProgressIntervalMs: new(uint32)}
+
+	// the progress frames complete the slots.
+	stream := client.StreamTransactions(context.Background(), req, ResumeFrom(Cursor{Slot: 11, Received: 2}))
+	msgs, err := readAll(t, stream)
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), msgs[0].Slot)
+	require.Equal(t, uint64(2), msgs[0].GetIndex())
+	require.Equal(t, uint64(11), msgs[1].GetProgress().GetCurrentSlot())
+	require.Equal(t, Cursor{Slot: 13, SlotDone: true}, stream.Cursor())
+
+	stream = client.StreamTransactions(context.Background(), req, ResumeFrom(Cursor{Slot: 12, SlotDone: true}))
+	msgs, err = readAll(t, stream)
+	require.NoError(t, err)
+	require.Equal(t, uint64(13), msgs[0].Slot)
+	require.Equal(t, []uint64{11, 13}, server.starts)
+}
+
+func TestStreamTransactionsGaps(t *testing.T) {
+	// the stream fails after the gap frame of slot 15.
+	server := &fixtureServer{failures: []int{3}}
+	client := startFixtureServer(t, server, Options{})
+	end := uint64(16)
+	reportGaps := true
+	stream := client.StreamTransactions(context.Background(), &old_faithful_grpc.StreamTransactionsRequest{StartSlot: 14, EndSlot: &end, ReportGaps: &reportGaps})
+	defer stream.Close()
+
+	msgs, err := readAll(t, stream)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3+1+2)
+	gap := msgs[3].GetGap()
+	require.NotNil(t, gap)
+	require.Equal(t, uint64(15), gap.FirstSlot)
+	require.Equal(t, uint64(15), gap.LastSlot)
+	require.Equal(t, uint64(16), msgs[4].Slot)
+	// reopened after the gap.
+	require.Equal(t, []uint64{14, 16}, server.starts)
+}
+
+func TestStreamErrors(t *testing.T) {
+	t.Run("not transient", func(t *testing.T) {
+		server := &fixtureServer{failures: []int{1}, failCode: codes.InvalidArgument}
+		client := startFixtureServer(t, server, Options{})
+		stream := client.StreamBlocks(context.Background(), &old_faithful_grpc.StreamBlocksRequest{StartSlot: 10})
+		msgs, err := readAll(t, stream)
+		require.Len(t, msgs, 1)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		require.Len(t, server.starts, 1)
+	})
+	t.Run("too many retries", func(t *testing.T) {
+		server := &fixtureServer{failures: []int{0, 0, 0, 0}}
+		client := startFixtureServer(t, server, Options{MaxRetries: 2})
+		stream := client.StreamBlocks(context.Background(), &old_faithful_grpc.StreamBlocksRequest{StartSlot: 10})
+		_, err := readAll(t, stream)
+		require.Equal(t, codes.Unavailable, status.Code(err))
+		require.Len(t, server.starts, 3)
+	})
+	t.Run("no retries", func(t *testing.T) {
+		server := &fixtureServer{failures: []int{1}}
+		client := startFixtureServer(t, server, Options{MaxRetries: -1})
+		stream := client.StreamBlocks(context.Background(), &old_faithful_grpc.StreamBlocksRequest{StartSlot: 10})
+		_, err := readAll(t, stream)
+		require.Equal(t, codes.Unavailable, status.Code(err))
+		require.Len(t, server.starts, 1)
+	})
+}
+
+func TestStreamBackpressure(t *testing.T) {
+	var mu sync.Mutex
+	read := 0
+	stream := newStream(context.Background(), Options{BufferSize: 2}.withDefaults(), Cursor{}, 1000, streamKind[*old_faithful_grpc.BlockResponse]{
+		open: func(ctx context.Context, start uint64) (func() (*old_faithful_grpc.BlockResponse, error), error) {
+			return func() (*old_faithful_grpc.BlockResponse, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				read++
+				return &old_faithful_grpc.BlockResponse{Slot: uint64(read)}, nil
+			}, nil
+		},
+		slot:      (*old_faithful_grpc.BlockResponse).GetSlot,
+		progress:  (*old_faithful_grpc.BlockResponse).GetProgress,
+		wholeSlot: true,
+	})
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	// the buffer, and the message waiting for room in it.
+	require.Equal(t, 3, read)
+	mu.Unlock()
+
+	block, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), block.Slot)
+	stream.Close()
+}
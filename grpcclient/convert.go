@@ -0,0 +1,104 @@
+package grpcclient
+
+import (
+	"fmt"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	solanablockrewards "github.com/rpcpool/yellowstone-faithful/solana-block-rewards"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+)
+
+// DecodeTransaction decodes the transaction of a block or of a TransactionResponse.
+func DecodeTransaction(tx *old_faithful_grpc.Transaction) (*solana.Transaction, error) {
+	if tx == nil || len(tx.Transaction) == 0 {
+		return nil, fmt.Errorf("no transaction")
+	}
+	decoded, err := solana.TransactionFromDecoder(bin.NewBinDecoder(tx.Transaction))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	return decoded, nil
+}
+
+// DecodeTransactionMeta parses the meta of a transaction (protobuf, or bincode for the oldest
+// epochs): it's one of the types returned by solanatxmetaparsers.ParseAnyTransactionStatusMeta,
+// or nil if the transaction has no meta.
+func DecodeTransactionMeta(tx *old_faithful_grpc.Transaction) (any, error) {
+	if tx == nil || len(tx.Meta) == 0 {
+		return nil, nil
+	}
+	meta, err := solanatxmetaparsers.ParseAnyTransactionStatusMeta(tx.Meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse transaction meta: %w", err)
+	}
+	return meta, nil
+}
+
+// DecodeBlockTransactions decodes the transactions of a block, in order.
+func DecodeBlockTransactions(block *old_faithful_grpc.BlockResponse) ([]*solana.Transaction, error) {
+	transactions := make([]*solana.Transaction, len(block.GetTransactions()))
+	for i, tx := range block.GetTransactions() {
+		decoded, err := DecodeTransaction(tx)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		transactions[i] = decoded
+	}
+	return transactions, nil
+}
+
+// DecodeBlockRewards parses the rewards of a block; nil if the block has none.
+func DecodeBlockRewards(block *old_faithful_grpc.BlockResponse) (*confirmed_block.Rewards, error) {
+	if len(block.GetRewards()) == 0 {
+		return nil, nil
+	}
+	rewards, err := solanablockrewards.ParseRewards(block.Rewards)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rewards: %w", err)
+	}
+	return rewards, nil
+}
+
+// TransactionSignature returns the signature of a TransactionResponse, with or without the
+// signatures-only filter.
+func TransactionSignature(resp *old_faithful_grpc.TransactionResponse) (solana.Signature, error) {
+	if len(resp.GetSignature()) > 0 {
+		if len(resp.Signature) != solana.SignatureLength {
+			return solana.Signature{}, fmt.Errorf("invalid signature length %d", len(resp.Signature))
+		}
+		return solana.SignatureFromBytes(resp.Signature), nil
+	}
+	tx, err := DecodeTransaction(resp.GetTransaction())
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	if len(tx.Signatures) == 0 {
+		return solana.Signature{}, fmt.Errorf("transaction without signatures")
+	}
+	return tx.Signatures[0], nil
+}
+
+// Blockhash returns the blockhash of a block.
+func Blockhash(block *old_faithful_grpc.BlockResponse) (solana.Hash, error) {
+	return toHash(block.GetBlockhash())
+}
+
+// PreviousBlockhash returns the blockhash of the parent of a block.
+func PreviousBlockhash(block *old_faithful_grpc.BlockResponse) (solana.Hash, error) {
+	return toHash(block.GetPreviousBlockhash())
+}
+
+// EntryHash returns the PoH hash of an entry.
+func EntryHash(entry *old_faithful_grpc.EntryResponse) (solana.Hash, error) {
+	return toHash(entry.GetHash())
+}
+
+func toHash(b []byte) (solana.Hash, error) {
+	if len(b) != solana.PublicKeyLength {
+		return solana.Hash{}, fmt.Errorf("invalid hash length %d", len(b))
+	}
+	return solana.HashFromBytes(b), nil
+}
@@ -0,0 +1,133 @@
+package grpcclient
+
+import (
+	"github.com/gagliardetto/solana-go"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+)
+
+// BlocksFilter builds the filter of StreamBlocks.
+type BlocksFilter struct {
+	accountInclude []string
+}
+
+// NewBlocksFilter returns a filter that lets every block through.
+func NewBlocksFilter() *BlocksFilter {
+	return &BlocksFilter{}
+}
+
+// Include keeps only the blocks with a transaction that mentions one of the accounts.
+func (f *BlocksFilter) Include(accounts ...solana.PublicKey) *BlocksFilter {
+	f.accountInclude = appendAccounts(f.accountInclude, accounts)
+	return f
+}
+
+// Build returns the filter of the request.
+func (f *BlocksFilter) Build() *old_faithful_grpc.StreamBlocksFilter {
+	return &old_faithful_grpc.StreamBlocksFilter{
+		AccountInclude: append([]string(nil), f.accountInclude...),
+	}
+}
+
+// TransactionsFilter builds the filter of StreamTransactions.
+type TransactionsFilter struct {
+	vote            old_faithful_grpc.TransactionKindFilter
+	failed          old_faithful_grpc.TransactionKindFilter
+	signaturesOnly  bool
+	accountInclude  []string
+	accountExclude  []string
+	accountRequired []string
+}
+
+// NewTransactionsFilter returns a filter that lets every transaction through, vote and failed ones included.
+func NewTransactionsFilter() *TransactionsFilter {
+	return &TransactionsFilter{
+		vote:   old_faithful_grpc.TransactionKindFilter_TRANSACTION_KIND_FILTER_INCLUDE,
+		failed: old_faithful_grpc.TransactionKindFilter_TRANSACTION_KIND_FILTER_INCLUDE,
+	}
+}
+
+// Vote sets whether the vote transactions are streamed (with the others) or excluded.
+func (f *TransactionsFilter) Vote(vote bool) *TransactionsFilter {
+	return f.VoteFilter(includeOrExclude(vote))
+}
+
+// Failed sets whether the failed transactions are streamed (with the others) or excluded.
+func (f *TransactionsFilter) Failed(failed bool) *TransactionsFilter {
+	return f.FailedFilter(includeOrExclude(failed))
+}
+
+// VoteFilter sets which vote transactions are streamed; TRANSACTION_KIND_FILTER_ONLY streams
+// only the vote transactions.
+func (f *TransactionsFilter) VoteFilter(kind old_faithful_grpc.TransactionKindFilter) *TransactionsFilter {
+	f.vote = kind
+	return f
+}
+
+// FailedFilter sets which failed transactions are streamed; TRANSACTION_KIND_FILTER_ONLY streams
+// only the failed transactions.
+func (f *TransactionsFilter) FailedFilter(kind old_faithful_grpc.TransactionKindFilter) *TransactionsFilter {
+	f.failed = kind
+	return f
+}
+
+func includeOrExclude(include bool) old_faithful_grpc.TransactionKindFilter {
+	if include {
+		return old_faithful_grpc.TransactionKindFilter_TRANSACTION_KIND_FILTER_INCLUDE
+	}
+	return old_faithful_grpc.TransactionKindFilter_TRANSACTION_KIND_FILTER_EXCLUDE
+}
+
+// Include keeps only the transactions that mention one of the accounts (looked up in the
+// getSignaturesForAddress index of the server).
+func (f *TransactionsFilter) Include(accounts ...solana.PublicKey) *TransactionsFilter {
+	f.accountInclude = appendAccounts(f.accountInclude, accounts)
+	return f
+}
+
+// Exclude drops the transactions that mention one of the accounts.
+func (f *TransactionsFilter) Exclude(accounts ...solana.PublicKey) *TransactionsFilter {
+	f.accountExclude = appendAccounts(f.accountExclude, accounts)
+	return f
+}
+
+// Require keeps only the transactions that mention all the accounts.
+func (f *TransactionsFilter) Require(accounts ...solana.PublicKey) *TransactionsFilter {
+	f.accountRequired = appendAccounts(f.accountRequired, accounts)
+	return f
+}
+
+// SignaturesOnly makes the server send only the slot, signature, failed flag, block time and index
+// of each transaction.
+func (f *TransactionsFilter) SignaturesOnly() *TransactionsFilter {
+	f.signaturesOnly = true
+	return f
+}
+
+// Build returns the filter of the request. The legacy vote and failed flags are set too, for the
+// servers that don't know the vote_filter and failed_filter fields (which then don't apply
+// TRANSACTION_KIND_FILTER_ONLY).
+func (f *TransactionsFilter) Build() *old_faithful_grpc.StreamTransactionsFilter {
+	vote := f.vote != old_faithful_grpc.TransactionKindFilter_TRANSACTION_KIND_FILTER_EXCLUDE
+	failed := f.failed != old_faithful_grpc.TransactionKindFilter_TRANSACTION_KIND_FILTER_EXCLUDE
+	filter := &old_faithful_grpc.StreamTransactionsFilter{
+		Vote:            &vote,
+		Failed:          &failed,
+		VoteFilter:      f.vote,
+		FailedFilter:    f.failed,
+		AccountInclude:  append([]string(nil), f.accountInclude...),
+		AccountExclude:  append([]string(nil), f.accountExclude...),
+		AccountRequired: append([]string(nil), f.accountRequired...),
+	}
+	if f.signaturesOnly {
+		signaturesOnly := true
+		filter.SignaturesOnly = &signaturesOnly
+	}
+	return filter
+}
+
+func appendAccounts(dst []string, accounts []solana.PublicKey) []string {
+	for _, account := range accounts {
+		dst = append(dst, account.String())
+	}
+	return dst
+}
@@ -0,0 +1,237 @@
+package grpcclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Cursor is the position of a stream: the messages up to it have been received.
+// It's a plain value, that can be saved to resume the stream later with ResumeFrom.
+//
+// The server sends the messages of a slot in the same order every time, so a stream that stopped
+// in the middle of a slot is resumed by streaming the slot again and skipping the messages that
+// were already received.
+type Cursor struct {
+	// Slot is the slot of the last message (or progress frame) received.
+	Slot uint64 `json:"slot"`
+	// SlotDone is true if all the messages of Slot were received.
+	SlotDone bool `json:"slotDone"`
+	// Received is the number of messages of Slot received, if SlotDone is false.
+	Received int `json:"received"`
+}
+
+// start returns the slot to restart the stream from.
+func (c Cursor) start() uint64 {
+	if c.SlotDone {
+		return c.Slot + 1
+	}
+	return c.Slot
+}
+
+// StreamOption is an option of a stream.
+type StreamOption func(*streamOptions)
+
+type streamOptions struct {
+	cursor      Cursor
+	callOptions []grpc.CallOption
+}
+
+func applyStreamOptions(start uint64, opts []StreamOption) streamOptions {
+	so := streamOptions{cursor: Cursor{Slot: start}}
+	for _, opt := range opts {
+		opt(&so)
+	}
+	return so
+}
+
+// ResumeFrom starts the stream after the cursor of a previous stream of the same request
+// (e.g. saved before the consumer restarted), instead of at the start slot of the request.
+func ResumeFrom(cursor Cursor) StreamOption {
+	return func(o *streamOptions) {
+		o.cursor = cursor
+	}
+}
+
+// WithCallOptions sets the gRPC call options used to open the stream (and to reopen it).
+func WithCallOptions(callOptions ...grpc.CallOption) StreamOption {
+	return func(o *streamOptions) {
+		o.callOptions = append(o.callOptions, callOptions...)
+	}
+}
+
+// streamKind is how to open and follow a kind of stream.
+type streamKind[T any] struct {
+	// open opens the stream of the request from the start slot, and returns its Recv function.
+	open     func(ctx context.Context, start uint64) (func() (T, error), error)
+	slot     func(T) uint64
+	progress func(T) *old_faithful_grpc.StreamProgress
+	// gap returns the gap of a gap frame; nil for the streams without gap frames.
+	gap func(T) *old_faithful_grpc.StreamGap
+	// wholeSlot is true if a message is all the slot (blocks).
+	wholeSlot bool
+}
+
+func (k streamKind[T]) gapOf(msg T) *old_faithful_grpc.StreamGap {
+	if k.gap == nil {
+		return nil
+	}
+	return k.gap(msg)
+}
+
+type streamItem[T any] struct {
+	msg    T
+	cursor Cursor
+}
+
+// Stream is a server stream that is reopened from its cursor when it fails with a transient error
+// (Unavailable, ResourceExhausted, Aborted), up to Options.MaxRetries times in a row.
+// The progress frames of a reopened stream are relative to the remaining slot range.
+type Stream[T any] struct {
+	items  chan streamItem[T]
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error // set before items is closed
+	cursor Cursor
+}
+
+func newStream[T any](ctx context.Context, opts Options, cursor Cursor, end uint64, kind streamKind[T]) *Stream[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Stream[T]{
+		items:  make(chan streamItem[T], opts.BufferSize),
+		cancel: cancel,
+		done:   make(chan struct{}),
+		cursor: cursor,
+	}
+	go func() {
+		defer close(s.done)
+		defer close(s.items)
+		s.err = s.run(ctx, opts, end, kind, cursor)
+	}()
+	return s
+}
+
+// Recv returns the next message of the stream; the error is io.EOF at the end of the stream.
+func (s *Stream[T]) Recv() (T, error) {
+	item, ok := <-s.items
+	if !ok {
+		var zero T
+		return zero, s.err
+	}
+	s.cursor = item.cursor
+	return item.msg, nil
+}
+
+// Cursor returns the position of the stream after the last message returned by Recv.
+func (s *Stream[T]) Cursor() Cursor {
+	return s.cursor
+}
+
+// Close stops the stream; it must be called if the stream is not read until the end.
+func (s *Stream[T]) Close() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *Stream[T]) run(ctx context.Context, opts Options, end uint64, kind streamKind[T], cursor Cursor) error {
+	backoff := opts.RetryBackoff
+	failures := 0
+	for {
+		if cursor.SlotDone && cursor.Slot >= end {
+			return io.EOF
+		}
+		received, err := s.follow(ctx, end, kind, &cursor)
+		if err == io.EOF {
+			return io.EOF
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if received {
+			failures = 0
+			backoff = opts.RetryBackoff
+		}
+		failures++
+		if opts.MaxRetries < 0 || failures > opts.MaxRetries || !isTransient(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, opts.MaxRetryBackoff)
+	}
+}
+
+// follow opens the stream at the cursor and forwards its messages until it ends or fails;
+// it returns whether it received any message.
+func (s *Stream[T]) follow(ctx context.Context, end uint64, kind streamKind[T], cursor *Cursor) (bool, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	recv, err := kind.open(streamCtx, cursor.start())
+	if err != nil {
+		return false, err
+	}
+	// the messages of the cursor slot that were already received are sent again.
+	skipSlot, skip := cursor.Slot, 0
+	if !cursor.SlotDone {
+		skip = cursor.Received
+	}
+	received := false
+	for {
+		msg, err := recv()
+		if err != nil {
+			return received, err
+		}
+		received = true
+		if progress := kind.progress(msg); progress != nil {
+			if progress.CurrentSlot >= cursor.Slot {
+				*cursor = Cursor{Slot: progress.CurrentSlot, SlotDone: true}
+			}
+		} else if gap := kind.gapOf(msg); gap != nil {
+			if gap.LastSlot >= cursor.Slot {
+				*cursor = Cursor{Slot: gap.LastSlot, SlotDone: true}
+			}
+		} else {
+			slot := kind.slot(msg)
+			if skip > 0 && slot == skipSlot {
+				skip--
+				continue
+			}
+			skip = 0
+			switch {
+			case kind.wholeSlot:
+				*cursor = Cursor{Slot: slot, SlotDone: true}
+			case slot == cursor.Slot && !cursor.SlotDone:
+				cursor.Received++
+			default:
+				*cursor = Cursor{Slot: slot, Received: 1}
+			}
+		}
+		select {
+		case s.items <- streamItem[T]{msg: msg, cursor: *cursor}:
+		case <-ctx.Done():
+			return received, ctx.Err()
+		}
+	}
+}
+
+// isTransient returns whether the stream can be reopened after the error.
+func isTransient(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
@@ -4,10 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/gagliardetto/solana-go"
-	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
 	"github.com/rpcpool/yellowstone-faithful/gsfa/linkedlog"
 	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
 	"k8s.io/klog/v2"
@@ -140,62 +138,38 @@ epochLoop:
 			return nil, fmt.Errorf("epoch is not set for the #%d provided gsfa reader", readerIndex)
 		}
 
-		locsStartedAt := time.Now()
-		locs, err := index.offsets.Get(pk)
+		it, err := index.Iterator(ctx, pk)
 		if err != nil {
-			if compactindexsized.IsNotFound(err) {
-				continue epochLoop
-			}
-			return nil, fmt.Errorf("error while getting initial offset: %w", err)
+			return nil, err
 		}
-		klog.V(5).Infof("locs.OffsetToFirst took %s", time.Since(locsStartedAt))
-		debugln("locs.OffsetToFirst:", locs)
-
-		next := locs // Start from the latest, and go back in time.
-
-		for {
-			if next == nil || next.IsZero() { // no previous.
-				continue epochLoop
-			}
-			if limit > 0 && transactions.Count() >= limit {
-				break epochLoop
+		for it.Next() {
+			txLoc := it.At()
+			tx, err := fetcher(epochNum, txLoc)
+			if err != nil {
+				return nil, fmt.Errorf("error while getting signature at index=%v: %w", txLoc, err)
 			}
-			startedReadAt := time.Now()
-			locations, newNext, err := index.ll.ReadWithSize(next.Offset, next.Size)
+			sig, err := tx.Signature()
 			if err != nil {
-				return nil, fmt.Errorf("error while reading linked log with next=%v: %w", next, err)
+				return nil, fmt.Errorf("error while getting signature: %w", err)
+			}
+			klog.V(5).Infoln("sig:", sig, "epoch:", epochNum)
+			if !reachedBefore && sig == *before {
+				reachedBefore = true
+				continue
 			}
-			klog.V(5).Infof("ReadWithSize took %s to get %d locs", time.Since(startedReadAt), len(locations))
-			if len(locations) == 0 {
-				continue epochLoop
+			if !reachedBefore {
+				continue
 			}
-			debugln("sigIndexes:", locations, "newNext:", newNext)
-			next = &newNext
-			for locIndex, txLoc := range locations {
-				tx, err := fetcher(epochNum, txLoc)
-				if err != nil {
-					return nil, fmt.Errorf("error while getting signature at index=%v: %w", txLoc, err)
-				}
-				sig, err := tx.Signature()
-				if err != nil {
-					return nil, fmt.Errorf("error while getting signature: %w", err)
-				}
-				klog.V(5).Infoln(locIndex, "sig:", sig, "epoch:", epochNum)
-				if !reachedBefore && sig == *before {
-					reachedBefore = true
-					continue
-				}
-				if !reachedBefore {
-					continue
-				}
-				if limit > 0 && transactions.Count() >= limit {
-					break epochLoop
-				}
-				transactions[epochNum] = append(transactions[epochNum], tx)
-				if until != nil && sig == *until {
-					break epochLoop
-				}
+			transactions[epochNum] = append(transactions[epochNum], tx)
+			if until != nil && sig == *until {
+				break epochLoop
 			}
+			if transactions.Count() >= limit {
+				break epochLoop
+			}
+		}
+		if err := it.Err(); err != nil {
+			return nil, err
 		}
 	}
 	return transactions, nil
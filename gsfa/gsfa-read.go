@@ -13,6 +13,7 @@ import (
 	"github.com/rpcpool/yellowstone-faithful/gsfa/manifest"
 	"github.com/rpcpool/yellowstone-faithful/indexes"
 	"github.com/rpcpool/yellowstone-faithful/indexmeta"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
 )
 
 type GsfaReader struct {
@@ -85,6 +86,12 @@ func (index *GsfaReader) Meta() indexmeta.Meta {
 	return index.man.Meta()
 }
 
+// Warmup pre-touches the pubkey-to-offset index, and returns the number of bytes read.
+// The linked log is not warmed up: it is too big, and its reads are scattered.
+func (index *GsfaReader) Warmup(full bool) (int64, error) {
+	return index.offsets.Warmup(full)
+}
+
 func (index *GsfaReader) Version() uint64 {
 	return index.man.Version()
 }
@@ -97,39 +104,18 @@ func (index *GsfaReader) Get(
 	if limit <= 0 {
 		return []linkedlog.OffsetAndSizeAndSlot{}, nil
 	}
-	lastOffset, err := index.offsets.Get(pk)
+	it, err := index.Iterator(ctx, pk)
 	if err != nil {
-		if compactindexsized.IsNotFound(err) {
-			return nil, fmt.Errorf("pubkey %s not found: %w", pk, err)
-		}
-		return nil, fmt.Errorf("error while getting initial offset: %w", err)
+		return nil, err
+	}
+	if !it.found {
+		return nil, fmt.Errorf("pubkey %s not found: %w", pk, compactindexsized.ErrNotFound)
 	}
-	debugln("locs.OffsetToFirst:", lastOffset)
-
 	var allTransactionLocations []linkedlog.OffsetAndSizeAndSlot
-	next := lastOffset // Start from the latest, and go back in time.
-
-	for {
-		if next == nil || next.IsZero() { // no previous.
-			break
-		}
-		if limit > 0 && len(allTransactionLocations) >= limit {
-			break
-		}
-		locations, newNext, err := index.ll.ReadWithSize(next.Offset, next.Size)
-		if err != nil {
-			return nil, fmt.Errorf("error while reading linked log with next=%d: %w", next, err)
-		}
-		debugln("sigIndexes:", locations, "newNext:", newNext)
-		next = &newNext
-		for _, sigIndex := range locations {
-			if limit > 0 && len(allTransactionLocations) >= limit {
-				break
-			}
-			allTransactionLocations = append(allTransactionLocations, sigIndex)
-		}
+	for len(allTransactionLocations) < limit && it.Next() {
+		allTransactionLocations = append(allTransactionLocations, it.At())
 	}
-	return allTransactionLocations, nil
+	return allTransactionLocations, it.Err()
 }
 
 func (index *GsfaReader) GetBeforeUntil(
@@ -143,57 +129,110 @@ func (index *GsfaReader) GetBeforeUntil(
 	if limit <= 0 {
 		return []linkedlog.OffsetAndSizeAndSlot{}, nil
 	}
-	locs, err := index.offsets.Get(pk)
+	it, err := index.Iterator(ctx, pk)
 	if err != nil {
-		if compactindexsized.IsNotFound(err) {
-			return nil, fmt.Errorf("pubkey %s not found: %w", pk, err)
-		}
-		return nil, fmt.Errorf("error while getting initial offset: %w", err)
+		return nil, err
+	}
+	if !it.found {
+		return nil, fmt.Errorf("pubkey %s not found: %w", pk, compactindexsized.ErrNotFound)
 	}
-	debugln("locs.OffsetToFirst:", locs)
 
 	var allTransactionLocations []linkedlog.OffsetAndSizeAndSlot
-	next := locs // Start from the latest, and go back in time.
-
-	reachedBefore := false
-	if before == nil {
-		reachedBefore = true
+	reachedBefore := before == nil
+	for len(allTransactionLocations) < limit && it.Next() {
+		txLoc := it.At()
+		sig, err := fetcher(txLoc)
+		if err != nil {
+			return nil, fmt.Errorf("error while getting signature at index=%v: %w", txLoc, err)
+		}
+		if !reachedBefore {
+			reachedBefore = sig == *before
+			continue
+		}
+		allTransactionLocations = append(allTransactionLocations, txLoc)
+		if until != nil && sig == *until {
+			break
+		}
 	}
+	return allTransactionLocations, it.Err()
+}
 
-bigLoop:
-	for {
-		if next == nil || next.IsZero() { // no previous.
-			break
+// SignatureAtSlot is a signature and the slot of its transaction, which is used to seek
+// the iterators to it.
+type SignatureAtSlot struct {
+	Signature solana.Signature
+	Slot      uint64
+}
+
+// EpochTransactions are the transactions of a public key found in one epoch by GetTransactionsBeforeUntil.
+type EpochTransactions struct {
+	Transactions []*ipldbindcode.Transaction
+	// ReachedBefore is false if there was a before signature and it's not in the epoch.
+	ReachedBefore bool
+	// ReachedUntil is true if the until signature (or its slot) was reached in the epoch.
+	ReachedUntil bool
+}
+
+// GetTransactionsBeforeUntil gets the transactions of the public key in this epoch, from the most
+// recent to the oldest; it's the single-epoch version of GsfaReaderMultiepoch.GetBeforeUntil,
+// so that the epochs can be queried concurrently. The transactions after the slot of before are
+// skipped without being fetched, and the iteration stops at the slot of until.
+func (index *GsfaReader) GetTransactionsBeforeUntil(
+	ctx context.Context,
+	pk solana.PublicKey,
+	limit int,
+	before *SignatureAtSlot, // Before this signature, exclusive (i.e. get signatures older than this signature, excluding it).
+	until *SignatureAtSlot, // Until this signature, inclusive (i.e. stop at this signature, including it).
+	fetcher func(linkedlog.OffsetAndSizeAndSlot) (*ipldbindcode.Transaction, error),
+) (*EpochTransactions, error) {
+	result := &EpochTransactions{ReachedBefore: before == nil}
+	if limit <= 0 {
+		return result, nil
+	}
+	it, err := index.Iterator(ctx, pk)
+	if err != nil {
+		return nil, err
+	}
+	if before != nil {
+		found, err := it.SeekSignature(before.Signature, before.Slot, func(txLoc linkedlog.OffsetAndSizeAndSlot) (solana.Signature, error) {
+			tx, err := fetcher(txLoc)
+			if err != nil {
+				return solana.Signature{}, err
+			}
+			return tx.Signature()
+		})
+		if err != nil {
+			return nil, err
 		}
-		if limit > 0 && len(allTransactionLocations) >= limit {
-			break
+		if !found {
+			return result, nil
 		}
-		locations, newNext, err := index.ll.ReadWithSize(next.Offset, next.Size)
+		result.ReachedBefore = true
+	}
+	for it.Next() {
+		txLoc := it.At()
+		if until != nil && txLoc.Slot < until.Slot {
+			result.ReachedUntil = true
+			return result, nil
+		}
+		tx, err := fetcher(txLoc)
 		if err != nil {
-			return nil, fmt.Errorf("error while reading linked log with next=%v: %w", next, err)
+			return nil, fmt.Errorf("error while getting signature at index=%v: %w", txLoc, err)
 		}
-		debugln("sigIndexes:", locations, "newNext:", newNext)
-		next = &newNext
-		for _, txLoc := range locations {
-			sig, err := fetcher(txLoc)
+		result.Transactions = append(result.Transactions, tx)
+		if until != nil {
+			sig, err := tx.Signature()
 			if err != nil {
-				return nil, fmt.Errorf("error while getting signature at index=%v: %w", txLoc, err)
-			}
-			if !reachedBefore && sig == *before {
-				reachedBefore = true
-				continue
+				return nil, fmt.Errorf("error while getting signature: %w", err)
 			}
-			if !reachedBefore {
-				continue
-			}
-			if limit > 0 && len(allTransactionLocations) >= limit {
-				break
-			}
-			allTransactionLocations = append(allTransactionLocations, txLoc)
-			if until != nil && sig == *until {
-				break bigLoop
+			if sig == until.Signature {
+				result.ReachedUntil = true
+				return result, nil
 			}
 		}
+		if len(result.Transactions) >= limit {
+			return result, nil
+		}
 	}
-	return allTransactionLocations, nil
+	return result, it.Err()
 }
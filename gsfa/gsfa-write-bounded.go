@@ -0,0 +1,540 @@
+package gsfa
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/gsfa/linkedlog"
+	"github.com/rpcpool/yellowstone-faithful/gsfa/manifest"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/indexmeta"
+	"k8s.io/klog/v2"
+)
+
+// BoundedGsfaWriter builds a gsfa index (the same files as GsfaWriter) with bounded memory:
+// the pushed transactions are accumulated by account until the memory budget is reached,
+// then spilled to a run file sorted by account; on Close, the runs are merged, and the lists of
+// each account are written to the linked log one account at a time.
+type BoundedGsfaWriter struct {
+	mu            sync.Mutex
+	indexRootDir  string
+	runsDir       string
+	memoryBudget  int64
+	ll            *linkedlog.LinkedLog
+	man           *manifest.Manifest
+	offsetsWriter *indexes.PubkeyToOffsetAndSize_Writer
+
+	accum        map[solana.PublicKey][]linkedlog.OffsetAndSizeAndSlot
+	accumSize    int64
+	runs         []string
+	numPushed    uint64
+	numPostings  uint64
+	numSpilled   uint64
+	spilledBytes int64
+}
+
+const (
+	// postingMemorySize is the memory used by a transaction in the list of an account.
+	postingMemorySize = 24
+	// accountMemorySize is the memory used by an account (key, slice header and map overhead).
+	accountMemorySize = 32 + 24 + 48
+	// maxMergeFanIn is the maximum number of runs merged at once; when there are more, the
+	// oldest ones are first merged into one run.
+	maxMergeFanIn = 128
+	// runBufferSize is the size of the read and write buffers of the run files.
+	runBufferSize = 256 * 1024
+)
+
+// NewBoundedGsfaWriter creates a gsfa index in indexRootDir; memoryBudget is the memory (in bytes)
+// used to accumulate the transactions before they are spilled to tmpDir.
+func NewBoundedGsfaWriter(
+	indexRootDir string,
+	meta indexmeta.Meta,
+	epoch uint64,
+	rootCid cid.Cid,
+	network indexes.Network,
+	tmpDir string,
+	memoryBudget int64,
+) (*BoundedGsfaWriter, error) {
+	if memoryBudget <= 0 {
+		return nil, fmt.Errorf("memory budget must be positive, got %d", memoryBudget)
+	}
+	if err := openIndexRootDir(indexRootDir); err != nil {
+		return nil, err
+	}
+	runsDir, err := os.MkdirTemp(tmpDir, "gsfa-runs-")
+	if err != nil {
+		return nil, fmt.Errorf("error while creating runs dir: %w", err)
+	}
+	index := &BoundedGsfaWriter{
+		indexRootDir: indexRootDir,
+		runsDir:      runsDir,
+		memoryBudget: memoryBudget,
+		accum:        make(map[solana.PublicKey][]linkedlog.OffsetAndSizeAndSlot),
+	}
+	{
+		ll, err := linkedlog.NewLinkedLog(filepath.Join(indexRootDir, "linked-log"))
+		if err != nil {
+			return nil, fmt.Errorf("error while opening linked log: %w", err)
+		}
+		index.ll = ll
+	}
+	{
+		man, err := manifest.NewManifest(filepath.Join(indexRootDir, "manifest"), meta)
+		if err != nil {
+			return nil, fmt.Errorf("error while opening manifest: %w", err)
+		}
+		index.man = man
+	}
+	{
+		offsetsWriter, err := indexes.NewWriter_PubkeyToOffsetAndSize(
+			epoch,
+			rootCid,
+			network,
+			tmpDir,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error while opening pubkey-to-offset-and-size writer: %w", err)
+		}
+		index.offsetsWriter = offsetsWriter
+	}
+	return index, nil
+}
+
+// Push adds the transaction (at offset, of the given length, in slot) to the lists of its accounts.
+// The transactions must be pushed in the order of the epoch.
+func (a *BoundedGsfaWriter) Push(
+	offset uint64,
+	length uint64,
+	slot uint64,
+	publicKeys solana.PublicKeySlice,
+) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	oas := linkedlog.OffsetAndSizeAndSlot{
+		Offset: offset,
+		Size:   length,
+		Slot:   slot,
+	}
+	publicKeys = publicKeys.Dedupe()
+	for _, publicKey := range publicKeys {
+		current, ok := a.accum[publicKey]
+		if !ok {
+			a.accumSize += accountMemorySize
+		}
+		oldCap := cap(current)
+		current = append(current, oas)
+		a.accumSize += int64(cap(current)-oldCap) * postingMemorySize
+		a.accum[publicKey] = current
+	}
+	a.numPushed++
+	a.numPostings += uint64(len(publicKeys))
+	if a.accumSize >= a.memoryBudget {
+		return a.spill()
+	}
+	return nil
+}
+
+// spill writes the accumulated lists to a new run, sorted by account.
+func (a *BoundedGsfaWriter) spill() error {
+	if len(a.accum) == 0 {
+		return nil
+	}
+	startedAt := time.Now()
+	keys := make(solana.PublicKeySlice, 0, len(a.accum))
+	for key := range a.accum {
+		keys = append(keys, key)
+	}
+	keys.Sort()
+
+	path := filepath.Join(a.runsDir, fmt.Sprintf("run-%06d", len(a.runs)))
+	w, err := newRunWriter(path)
+	if err != nil {
+		return err
+	}
+	var numPostings uint64
+	for _, key := range keys {
+		postings := a.accum[key]
+		numPostings += uint64(len(postings))
+		if err := w.writeGroup(key, postings); err != nil {
+			w.close()
+			return err
+		}
+	}
+	size, err := w.close()
+	if err != nil {
+		return err
+	}
+	a.runs = append(a.runs, path)
+	a.numSpilled += numPostings
+	a.spilledBytes += size
+	klog.Infof(
+		"Spilled run %d: %s accounts, %s transaction entries, %s in %s",
+		len(a.runs), humanize.Comma(int64(len(keys))), humanize.Comma(int64(numPostings)), humanize.Bytes(uint64(size)), time.Since(startedAt).Truncate(time.Millisecond),
+	)
+	a.accum = make(map[solana.PublicKey][]linkedlog.OffsetAndSizeAndSlot)
+	a.accumSize = 0
+	return nil
+}
+
+// Close merges the runs into the index, and removes them.
+func (a *BoundedGsfaWriter) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	defer os.RemoveAll(a.runsDir)
+	if err := a.spill(); err != nil {
+		return err
+	}
+	klog.Infof(
+		"Pushed %s transactions (%s entries); merging %d runs (%s)...",
+		humanize.Comma(int64(a.numPushed)), humanize.Comma(int64(a.numPostings)), len(a.runs), humanize.Bytes(uint64(a.spilledBytes)),
+	)
+	// Merge the oldest runs first, so that the runs stay in the order of the epoch.
+	for len(a.runs) > maxMergeFanIn {
+		merged := filepath.Join(a.runsDir, fmt.Sprintf("merged-%06d", len(a.runs)))
+		if err := mergeRunsToRun(a.runs[:maxMergeFanIn], merged); err != nil {
+			return err
+		}
+		a.runs = append([]string{merged}, a.runs[maxMergeFanIn:]...)
+	}
+
+	startedAt := time.Now()
+	var numAccounts uint64
+	if err := a.mergeToIndex(func() { numAccounts++ }); err != nil {
+		return err
+	}
+	klog.Infof("Wrote the transaction lists of %s accounts in %s", humanize.Comma(int64(numAccounts)), time.Since(startedAt).Truncate(time.Second))
+
+	offsetsIndex := filepath.Join(a.indexRootDir, string(indexes.Kind_PubkeyToOffsetAndSize)+".index")
+	klog.Info("Sealing pubkey-to-offset-and-size writer...")
+	if err := a.offsetsWriter.SealWithFilename(context.Background(), offsetsIndex); err != nil {
+		return fmt.Errorf("error while sealing pubkey-to-offset-and-size writer: %w", err)
+	}
+	return errors.Join(
+		a.offsetsWriter.Close(),
+		a.ll.Close(),
+		a.man.Close(),
+	)
+}
+
+// mergeToIndex merges the runs, and writes the list of each account to the linked log, in batches
+// of itemsPerBatch (each one pointing to the previous one), and its last batch to the offsets index.
+func (a *BoundedGsfaWriter) mergeToIndex(onAccount func()) error {
+	var (
+		currentKey solana.PublicKey
+		hasKey     bool
+		last       indexes.OffsetAndSize
+		batch      = make([]*linkedlog.OffsetAndSizeAndSlot, 0, itemsPerBatch)
+	)
+	flushBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		previous := last
+		_, err := a.ll.Put(
+			func(solana.PublicKey) (indexes.OffsetAndSize, error) {
+				return previous, nil
+			},
+			func(_ solana.PublicKey, offset uint64, ln uint32) error {
+				last = indexes.OffsetAndSize{Offset: offset, Size: uint64(ln)}
+				return nil
+			},
+			linkedlog.KeyToOffsetAndSizeAndBlocktime{Key: currentKey, Values: batch},
+		)
+		if err != nil {
+			return fmt.Errorf("error while writing account lists batch to linked log: %w", err)
+		}
+		batch = make([]*linkedlog.OffsetAndSizeAndSlot, 0, itemsPerBatch)
+		return nil
+	}
+	finishKey := func() error {
+		if !hasKey {
+			return nil
+		}
+		if err := flushBatch(); err != nil {
+			return err
+		}
+		if err := a.offsetsWriter.Put(currentKey, last.Offset, last.Size); err != nil {
+			return fmt.Errorf("error while writing pubkey-to-offset-and-size: %w", err)
+		}
+		onAccount()
+		return nil
+	}
+
+	progress := newMergeProgress(a.spilledBytes)
+	err := mergeRuns(a.runs, progress, func(key solana.PublicKey, postings []linkedlog.OffsetAndSizeAndSlot) error {
+		if !hasKey || key != currentKey {
+			if err := finishKey(); err != nil {
+				return err
+			}
+			currentKey = key
+			hasKey = true
+			last = indexes.OffsetAndSize{}
+		}
+		for i := range postings {
+			posting := postings[i]
+			batch = append(batch, &posting)
+			if len(batch) == itemsPerBatch {
+				if err := flushBatch(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return finishKey()
+}
+
+// mergeRunsToRun merges runs into one run at path.
+func mergeRunsToRun(runs []string, path string) error {
+	startedAt := time.Now()
+	w, err := newRunWriter(path)
+	if err != nil {
+		return err
+	}
+	err = mergeRuns(runs, nil, w.writeGroup)
+	size, closeErr := w.close()
+	if err := errors.Join(err, closeErr); err != nil {
+		return err
+	}
+	for _, run := range runs {
+		os.Remove(run)
+	}
+	klog.Infof("Merged %d runs into one (%s) in %s", len(runs), humanize.Bytes(uint64(size)), time.Since(startedAt).Truncate(time.Second))
+	return nil
+}
+
+// mergeRuns calls fn with the groups of the runs, by account; the groups of an account are in
+// the order of the runs (so that its transactions are in the order they were pushed).
+func mergeRuns(runs []string, progress *mergeProgress, fn func(solana.PublicKey, []linkedlog.OffsetAndSizeAndSlot) error) error {
+	readers := make(runHeap, 0, len(runs))
+	defer func() {
+		for _, r := range readers {
+			r.file.Close()
+		}
+	}()
+	for i, path := range runs {
+		r, err := openRun(path, i, progress)
+		if err != nil {
+			return err
+		}
+		ok, err := r.next()
+		if err != nil {
+			r.file.Close()
+			return err
+		}
+		if !ok {
+			r.file.Close()
+			continue
+		}
+		readers = append(readers, r)
+	}
+	heap.Init(&readers)
+	for readers.Len() > 0 {
+		r := readers[0]
+		if err := fn(r.key, r.postings); err != nil {
+			return err
+		}
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&readers, 0)
+		} else {
+			r.file.Close()
+			heap.Pop(&readers)
+		}
+		progress.maybeLog()
+	}
+	return nil
+}
+
+// A run is a sequence of groups sorted by account: the account (32 bytes), the number of
+// transactions (uvarint), and the offset, size and slot (uvarints) of each transaction.
+type runWriter struct {
+	file *os.File
+	buf  *bufio.Writer
+	size int64
+}
+
+func newRunWriter(path string) (*runWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating run: %w", err)
+	}
+	return &runWriter{file: file, buf: bufio.NewWriterSize(file, runBufferSize)}, nil
+}
+
+func (w *runWriter) writeGroup(key solana.PublicKey, postings []linkedlog.OffsetAndSizeAndSlot) error {
+	var scratch [binary.MaxVarintLen64 * 3]byte
+	n, err := w.buf.Write(key[:])
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+	n, err = w.buf.Write(binary.AppendUvarint(scratch[:0], uint64(len(postings))))
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+	for _, posting := range postings {
+		encoded := binary.AppendUvarint(scratch[:0], posting.Offset)
+		encoded = binary.AppendUvarint(encoded, posting.Size)
+		encoded = binary.AppendUvarint(encoded, posting.Slot)
+		n, err := w.buf.Write(encoded)
+		w.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *runWriter) close() (int64, error) {
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return 0, err
+	}
+	return w.size, w.file.Close()
+}
+
+type runReader struct {
+	index    int
+	file     *os.File
+	buf      *bufio.Reader
+	progress *mergeProgress
+	key      solana.PublicKey
+	postings []linkedlog.OffsetAndSizeAndSlot
+}
+
+func openRun(path string, index int, progress *mergeProgress) (*runReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening run: %w", err)
+	}
+	return &runReader{
+		index:    index,
+		file:     file,
+		buf:      bufio.NewReaderSize(file, runBufferSize),
+		progress: progress,
+	}, nil
+}
+
+// next reads the next group of the run; it returns false at the end of the run.
+func (r *runReader) next() (bool, error) {
+	n, err := io.ReadFull(r.buf, r.key[:])
+	r.progress.add(n)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error while reading run %s: %w", r.file.Name(), err)
+	}
+	count, err := r.readUvarint()
+	if err != nil {
+		return false, err
+	}
+	r.postings = r.postings[:0]
+	for i := uint64(0); i < count; i++ {
+		var posting linkedlog.OffsetAndSizeAndSlot
+		if posting.Offset, err = r.readUvarint(); err != nil {
+			return false, err
+		}
+		if posting.Size, err = r.readUvarint(); err != nil {
+			return false, err
+		}
+		if posting.Slot, err = r.readUvarint(); err != nil {
+			return false, err
+		}
+		r.postings = append(r.postings, posting)
+	}
+	return true, nil
+}
+
+func (r *runReader) readUvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(byteCounter{r})
+	if err != nil {
+		return 0, fmt.Errorf("error while reading run %s: %w", r.file.Name(), err)
+	}
+	return v, nil
+}
+
+// byteCounter counts the bytes read from the run for the progress.
+type byteCounter struct {
+	r *runReader
+}
+
+func (c byteCounter) ReadByte() (byte, error) {
+	b, err := c.r.buf.ReadByte()
+	if err == nil {
+		c.r.progress.add(1)
+	}
+	return b, err
+}
+
+// runHeap orders the runs by their current account, then by their index.
+type runHeap []*runReader
+
+func (h runHeap) Len() int { return len(h) }
+
+func (h runHeap) Less(i, j int) bool {
+	if c := bytes.Compare(h[i].key[:], h[j].key[:]); c != 0 {
+		return c < 0
+	}
+	return h[i].index < h[j].index
+}
+
+func (h runHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *runHeap) Push(x any) { *h = append(*h, x.(*runReader)) }
+
+func (h *runHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// mergeProgress logs the progress of the merge (the bytes read from the runs), every few seconds.
+type mergeProgress struct {
+	total     int64
+	read      int64
+	lastLogAt time.Time
+}
+
+func newMergeProgress(total int64) *mergeProgress {
+	return &mergeProgress{total: total, lastLogAt: time.Now()}
+}
+
+func (p *mergeProgress) add(n int) {
+	if p != nil {
+		p.read += int64(n)
+	}
+}
+
+func (p *mergeProgress) maybeLog() {
+	if p == nil || p.total == 0 || time.Since(p.lastLogAt) < 5*time.Second {
+		return
+	}
+	p.lastLogAt = time.Now()
+	klog.Infof("Merging runs: %s/%s (%.1f%%)", humanize.Bytes(uint64(p.read)), humanize.Bytes(uint64(p.total)), float64(p.read)/float64(p.total)*100)
+}
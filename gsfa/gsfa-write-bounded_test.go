@@ -0,0 +1,72 @@
+package gsfa
+
+import (
+	"context"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/gsfa/linkedlog"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/indexmeta"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundedGsfaWriter(t *testing.T) {
+	rootCid := cid.MustParse("bafyreifljyxj55v6jycjf2y7tdibwwwqx75eqf5mn2thip2sswyc536zqq")
+	for _, tc := range []struct {
+		name         string
+		memoryBudget int64
+	}{
+		// everything fits in one run.
+		{"one run", 1 << 30},
+		// more runs than maxMergeFanIn, so that some are merged before the index is written.
+		{"many runs", 2000},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			indexDir := filepath.Join(t.TempDir(), "gsfa")
+			tmpDir := t.TempDir()
+			w, err := NewBoundedGsfaWriter(indexDir, indexmeta.Meta{}, 0, rootCid, indexes.NetworkMainnet, tmpDir, tc.memoryBudget)
+			require.NoError(t, err)
+
+			rng := rand.New(rand.NewSource(1))
+			accounts := make([]solana.PublicKey, 50)
+			for i := range accounts {
+				rng.Read(accounts[i][:])
+			}
+			// the first account is in all the transactions, so its list spans several batches.
+			expected := make(map[solana.PublicKey][]linkedlog.OffsetAndSizeAndSlot)
+			var offset uint64
+			for i := 0; i < 2500; i++ {
+				slot := uint64(i / 10)
+				size := uint64(100 + rng.Intn(1000))
+				keys := solana.PublicKeySlice{accounts[0]}
+				for j := rng.Intn(4); j > 0; j-- {
+					keys = append(keys, accounts[rng.Intn(len(accounts))])
+				}
+				require.NoError(t, w.Push(offset, size, slot, keys))
+				for _, key := range keys.Dedupe() {
+					expected[key] = append([]linkedlog.OffsetAndSizeAndSlot{{Offset: offset, Size: size, Slot: slot}}, expected[key]...)
+				}
+				offset += size
+			}
+			if tc.memoryBudget < 1<<20 {
+				require.Greater(t, len(w.runs), maxMergeFanIn)
+			}
+			require.NoError(t, w.Close())
+
+			require.NoDirExists(t, w.runsDir)
+
+			r, err := NewGsfaReader(indexDir)
+			require.NoError(t, err)
+			defer r.Close()
+			for key, want := range expected {
+				got, err := r.Get(context.Background(), key, 10_000)
+				require.NoError(t, err)
+				require.Equal(t, want, got, key.String())
+			}
+		})
+	}
+}
@@ -45,19 +45,8 @@ func NewGsfaWriter(
 	network indexes.Network,
 	tmpDir string,
 ) (*GsfaWriter, error) {
-	// if exists and is dir, open.
-	// if exists and is not dir, error.
-	// if not exists, create.
-	if ok, err := isDir(indexRootDir); err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			if err := os.MkdirAll(indexRootDir, 0o755); err != nil {
-				return nil, err
-			}
-		} else {
-			return nil, err
-		}
-	} else if !ok {
-		return nil, fmt.Errorf("provided path is not a directory: %s", indexRootDir)
+	if err := openIndexRootDir(indexRootDir); err != nil {
+		return nil, err
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	index := &GsfaWriter{
@@ -101,6 +90,22 @@ func NewGsfaWriter(
 	return index, nil
 }
 
+// openIndexRootDir creates the index dir if it doesn't exist.
+func openIndexRootDir(indexRootDir string) error {
+	// if exists and is dir, open.
+	// if exists and is not dir, error.
+	// if not exists, create.
+	if ok, err := isDir(indexRootDir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return os.MkdirAll(indexRootDir, 0o755)
+		}
+		return err
+	} else if !ok {
+		return fmt.Errorf("provided path is not a directory: %s", indexRootDir)
+	}
+	return nil
+}
+
 func (a *GsfaWriter) fullBufferWriter() {
 	numReadFromChan := uint64(0)
 	howManyBuffersToFlushConcurrently := 256
@@ -0,0 +1,126 @@
+package gsfa
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	"github.com/rpcpool/yellowstone-faithful/gsfa/linkedlog"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+)
+
+// Iterator iterates over the transactions of a public key in one epoch, from the most recent to
+// the oldest; the batches of the linked log are read as they are reached.
+type Iterator struct {
+	ctx   context.Context
+	ll    *linkedlog.LinkedLog
+	next  indexes.OffsetAndSize // the next (older) batch; zero if none.
+	batch []linkedlog.OffsetAndSizeAndSlot
+	pos   int // the current transaction in batch; -1 before the first one.
+	err   error
+	found bool // whether the public key is in the index.
+}
+
+// Iterator returns an iterator over the transactions of the public key; it's empty if the public
+// key has no transactions in the epoch.
+func (index *GsfaReader) Iterator(ctx context.Context, pk solana.PublicKey) (*Iterator, error) {
+	it := &Iterator{ctx: ctx, ll: index.ll, pos: -1}
+	first, err := index.offsets.Get(pk)
+	if err != nil {
+		if compactindexsized.IsNotFound(err) {
+			return it, nil
+		}
+		return nil, fmt.Errorf("error while getting initial offset: %w", err)
+	}
+	it.next, it.found = *first, true
+	return it, nil
+}
+
+// Next moves to the next (older) transaction; it returns false at the end or on error (see Err).
+func (it *Iterator) Next() bool {
+	if _, ok := it.peek(); !ok {
+		return false
+	}
+	it.pos++
+	return true
+}
+
+// At returns the location of the current transaction.
+func (it *Iterator) At() linkedlog.OffsetAndSizeAndSlot {
+	return it.batch[it.pos]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// SeekSlot skips the transactions of the slots after slot (more recent), without reading them:
+// the next call to Next moves to the first transaction in slot or before.
+func (it *Iterator) SeekSlot(slot uint64) {
+	for {
+		loc, ok := it.peek()
+		if !ok || loc.Slot <= slot {
+			return
+		}
+		if last := it.batch[len(it.batch)-1]; last.Slot > slot {
+			// the whole batch is more recent.
+			it.pos = len(it.batch) - 1
+			continue
+		}
+		it.pos++
+	}
+}
+
+// SeekSignature skips the transactions up to the one with the signature, in slot: the next call
+// to Next moves to the transaction before it. Only the signatures of the transactions in slot are
+// read, with signatureOf. It returns false if the signature is not a transaction of the public key;
+// the next call to Next then moves to the first transaction before slot.
+func (it *Iterator) SeekSignature(
+	sig solana.Signature,
+	slot uint64,
+	signatureOf func(linkedlog.OffsetAndSizeAndSlot) (solana.Signature, error),
+) (bool, error) {
+	it.SeekSlot(slot)
+	for {
+		loc, ok := it.peek()
+		if !ok || loc.Slot < slot {
+			return false, it.err
+		}
+		got, err := signatureOf(loc)
+		if err != nil {
+			return false, fmt.Errorf("error while getting signature at index=%v: %w", loc, err)
+		}
+		it.pos++
+		if got == sig {
+			return true, nil
+		}
+	}
+}
+
+// peek returns the next transaction without moving to it, reading the next batch if needed.
+func (it *Iterator) peek() (linkedlog.OffsetAndSizeAndSlot, bool) {
+	for it.pos+1 >= len(it.batch) {
+		if it.err != nil || it.next.IsZero() {
+			return linkedlog.OffsetAndSizeAndSlot{}, false
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			return linkedlog.OffsetAndSizeAndSlot{}, false
+		}
+		locations, next, err := it.ll.ReadWithSize(it.next.Offset, it.next.Size)
+		if err != nil {
+			it.err = fmt.Errorf("error while reading linked log with next=%v: %w", it.next, err)
+			return linkedlog.OffsetAndSizeAndSlot{}, false
+		}
+		debugln("sigIndexes:", locations, "newNext:", next)
+		if len(locations) == 0 {
+			it.next = indexes.OffsetAndSize{}
+		} else {
+			it.next = next
+		}
+		it.batch, it.pos = locations, -1
+	}
+	return it.batch[it.pos+1], true
+}
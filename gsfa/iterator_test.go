@@ -0,0 +1,93 @@
+package gsfa
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/gsfa/linkedlog"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/indexmeta"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIterator(t *testing.T) {
+	rootCid := cid.MustParse("bafyreifljyxj55v6jycjf2y7tdibwwwqx75eqf5mn2thip2sswyc536zqq")
+	indexDir := filepath.Join(t.TempDir(), "gsfa")
+	w, err := NewBoundedGsfaWriter(indexDir, indexmeta.Meta{}, 0, rootCid, indexes.NetworkMainnet, t.TempDir(), 1<<30)
+	require.NoError(t, err)
+
+	// 3 transactions per slot, so that the list spans several batches.
+	account := solana.PublicKey{1}
+	var all []linkedlog.OffsetAndSizeAndSlot // most recent first.
+	for i := uint64(0); i < 3*itemsPerBatch; i++ {
+		loc := linkedlog.OffsetAndSizeAndSlot{Offset: i * 100, Size: 100, Slot: i / 3}
+		require.NoError(t, w.Push(loc.Offset, loc.Size, loc.Slot, solana.PublicKeySlice{account}))
+		all = append([]linkedlog.OffsetAndSizeAndSlot{loc}, all...)
+	}
+	require.NoError(t, w.Close())
+
+	r, err := NewGsfaReader(indexDir)
+	require.NoError(t, err)
+	defer r.Close()
+	ctx := context.Background()
+	// the signature of a transaction is its offset.
+	var fetched int
+	signatureOf := func(loc linkedlog.OffsetAndSizeAndSlot) (solana.Signature, error) {
+		fetched++
+		var sig solana.Signature
+		binary.LittleEndian.PutUint64(sig[:], loc.Offset)
+		return sig, nil
+	}
+	rest := func(it *Iterator) []linkedlog.OffsetAndSizeAndSlot {
+		locs := []linkedlog.OffsetAndSizeAndSlot{}
+		for it.Next() {
+			locs = append(locs, it.At())
+		}
+		require.NoError(t, it.Err())
+		return locs
+	}
+
+	it, err := r.Iterator(ctx, account)
+	require.NoError(t, err)
+	require.Equal(t, all, rest(it))
+
+	it, err = r.Iterator(ctx, solana.PublicKey{2})
+	require.NoError(t, err)
+	require.Empty(t, rest(it))
+
+	for _, index := range []int{0, 1, 2, 200, len(all) - 1} {
+		t.Run(fmt.Sprintf("seek %d", index), func(t *testing.T) {
+			target := all[index]
+
+			it, err := r.Iterator(ctx, account)
+			require.NoError(t, err)
+			it.SeekSlot(target.Slot)
+			require.Equal(t, all[index-index%3:], rest(it))
+
+			it, err = r.Iterator(ctx, account)
+			require.NoError(t, err)
+			fetched = 0
+			sig, _ := signatureOf(target)
+			found, err := it.SeekSignature(sig, target.Slot, signatureOf)
+			require.NoError(t, err)
+			require.True(t, found)
+			// only the signatures in the slot are read.
+			require.LessOrEqual(t, fetched, 1+3)
+			require.Equal(t, all[index+1:], rest(it))
+		})
+	}
+
+	t.Run("seek missing signature", func(t *testing.T) {
+		it, err := r.Iterator(ctx, account)
+		require.NoError(t, err)
+		found, err := it.SeekSignature(solana.Signature{0xff}, all[200].Slot, signatureOf)
+		require.NoError(t, err)
+		require.False(t, found)
+		require.Equal(t, all[200-200%3+3:], rest(it))
+	})
+}
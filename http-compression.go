@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/valyala/fasthttp"
+	"k8s.io/klog/v2"
+)
+
+// DefaultCompressionMinSize is the default size (in bytes) below which responses are not compressed:
+// for small responses, the compression overhead is bigger than the saved bandwidth.
+const DefaultCompressionMinSize = 1024
+
+// Supported response encodings, in order of preference (for equal q-values).
+const (
+	encodingZstd    = "zstd"
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+var (
+	gzipWriterPool = sync.Pool{
+		New: func() any {
+			w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+			return w
+		},
+	}
+	flateWriterPool = sync.Pool{
+		New: func() any {
+			w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+			return w
+		},
+	}
+	compressBufferPool = sync.Pool{
+		New: func() any {
+			return new(bytes.Buffer)
+		},
+	}
+)
+
+// compressionHandler returns a handler that compresses the responses of h with the best encoding
+// accepted by the client (zstd, gzip or deflate), if they are at least minSize bytes.
+// Streamed responses, and responses that are already encoded (e.g. /metrics), are left untouched.
+func compressionHandler(h fasthttp.RequestHandler, minSize int) fasthttp.RequestHandler {
+	return func(reqCtx *fasthttp.RequestCtx) {
+		h(reqCtx)
+		resp := &reqCtx.Response
+		if resp.IsBodyStream() || len(resp.Header.Peek(fasthttp.HeaderContentEncoding)) > 0 {
+			// Streams (e.g. server-sent events) must not be buffered.
+			return
+		}
+		body := resp.Body()
+		if len(body) < minSize || len(body) == 0 {
+			return
+		}
+		encoding := negotiateEncoding(string(reqCtx.Request.Header.Peek(fasthttp.HeaderAcceptEncoding)))
+		if encoding == "" {
+			return
+		}
+		compressed := compressBufferPool.Get().(*bytes.Buffer)
+		defer compressBufferPool.Put(compressed)
+		compressed.Reset()
+		if err := compressBody(compressed, encoding, body); err != nil {
+			klog.Errorf("failed to compress response with %s: %s", encoding, err)
+			return
+		}
+		resp.SetBody(compressed.Bytes())
+		resp.Header.Set(fasthttp.HeaderContentEncoding, encoding)
+		resp.Header.Add(fasthttp.HeaderVary, fasthttp.HeaderAcceptEncoding)
+	}
+}
+
+func compressBody(dst *bytes.Buffer, encoding string, body []byte) error {
+	switch encoding {
+	case encodingZstd:
+		enc, err := zstdEncoderPool.Get(nil)
+		if err != nil {
+			return err
+		}
+		defer zstdEncoderPool.Put(enc)
+		dst.Write(enc.EncodeAll(body, dst.AvailableBuffer()))
+		return nil
+	case encodingGzip:
+		w := gzipWriterPool.Get().(*gzip.Writer)
+		defer gzipWriterPool.Put(w)
+		w.Reset(dst)
+		if _, err := w.Write(body); err != nil {
+			return err
+		}
+		return w.Close()
+	case encodingDeflate:
+		w := flateWriterPool.Get().(*flate.Writer)
+		defer flateWriterPool.Put(w)
+		w.Reset(dst)
+		if _, err := w.Write(body); err != nil {
+			return err
+		}
+		return w.Close()
+	default:
+		panic("unsupported encoding " + encoding)
+	}
+}
+
+// negotiateEncoding returns the supported encoding with the highest q-value in the Accept-Encoding
+// header (preferring zstd, then gzip, then deflate), or "" if none is accepted.
+func negotiateEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qValues := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if params != "" {
+			key, value, ok := strings.Cut(strings.TrimSpace(params), "=")
+			if ok && strings.TrimSpace(key) == "q" {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil {
+					continue
+				}
+				q = parsed
+			}
+		}
+		qValues[name] = q
+	}
+	best := ""
+	bestQ := 0.0
+	for _, encoding := range []string{encodingZstd, encodingGzip, encodingDeflate} {
+		q, ok := qValues[encoding]
+		if !ok {
+			q, ok = qValues["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
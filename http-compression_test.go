@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                            "",
+		"identity":                    "",
+		"gzip":                        "gzip",
+		"deflate, gzip":               "gzip",
+		"gzip, deflate, br, zstd":     "zstd",
+		"ZSTD":                        "zstd",
+		"zstd;q=0.5, gzip":            "gzip",
+		"zstd;q=0, gzip;q=0, deflate": "deflate",
+		"*":                           "zstd",
+		"*;q=0.1, gzip;q=0.5":         "gzip",
+		"gzip;q=bogus":                "",
+	}
+	for acceptEncoding, expected := range cases {
+		require.Equal(t, expected, negotiateEncoding(acceptEncoding), acceptEncoding)
+	}
+}
+
+func TestCompressionHandler(t *testing.T) {
+	large := []byte(`{"jsonrpc":"2.0","result":"` + strings.Repeat("a", 2048) + `","id":1}`)
+	small := []byte(`{"jsonrpc":"2.0","result":null,"id":1}`)
+	handler := compressionHandler(func(reqCtx *fasthttp.RequestCtx) {
+		if string(reqCtx.Path()) == "/small" {
+			reqCtx.SetBody(small)
+			return
+		}
+		reqCtx.SetBody(large)
+	}, 1024)
+	serve := func(path, acceptEncoding string) *fasthttp.Response {
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Request.SetRequestURI(path)
+		reqCtx.Request.Header.Set(fasthttp.HeaderAcceptEncoding, acceptEncoding)
+		handler(&reqCtx)
+		return &reqCtx.Response
+	}
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"deflate": func(r io.Reader) (io.Reader, error) {
+			return flate.NewReader(r), nil
+		},
+	}
+	for encoding, newDecoder := range decoders {
+		t.Run(encoding, func(t *testing.T) {
+			resp := serve("/", encoding)
+			require.Equal(t, encoding, string(resp.Header.Peek(fasthttp.HeaderContentEncoding)))
+			require.Equal(t, fasthttp.HeaderAcceptEncoding, string(resp.Header.Peek(fasthttp.HeaderVary)))
+			require.Less(t, len(resp.Body()), len(large))
+			r, err := newDecoder(bytes.NewReader(resp.Body()))
+			require.NoError(t, err)
+			got, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, large, got)
+		})
+	}
+
+	t.Run("below threshold", func(t *testing.T) {
+		resp := serve("/small", "gzip")
+		require.Empty(t, resp.Header.Peek(fasthttp.HeaderContentEncoding))
+		require.Equal(t, small, resp.Body())
+	})
+	t.Run("not accepted", func(t *testing.T) {
+		resp := serve("/", "br")
+		require.Empty(t, resp.Header.Peek(fasthttp.HeaderContentEncoding))
+		require.Equal(t, large, resp.Body())
+	})
+	t.Run("already encoded", func(t *testing.T) {
+		encoded := compressionHandler(func(reqCtx *fasthttp.RequestCtx) {
+			reqCtx.Response.Header.Set(fasthttp.HeaderContentEncoding, "gzip")
+			reqCtx.SetBody(large)
+		}, 0)
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Request.Header.Set(fasthttp.HeaderAcceptEncoding, "zstd")
+		encoded(&reqCtx)
+		require.Equal(t, "gzip", string(reqCtx.Response.Header.Peek(fasthttp.HeaderContentEncoding)))
+		require.Equal(t, large, reqCtx.Response.Body())
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// corsAllowedMethods are the methods of the JSON RPC (POST) and REST (GET) endpoints.
+const corsAllowedMethods = "GET, POST, OPTIONS"
+
+// DefaultCORSAllowedHeaders are the request headers allowed by default: the ones sent by the Solana web3.js client.
+var DefaultCORSAllowedHeaders = []string{"Content-Type", "Solana-Client"}
+
+// CORSConfig is the CORS configuration of the JSON RPC server.
+type CORSConfig struct {
+	// AllowedOrigins are the origins that can call the server from a browser:
+	// "*" allows any origin, and "https://*.example.com" allows any subdomain of example.com.
+	AllowedOrigins []string
+	// AllowedHeaders are the request headers that browsers are allowed to send.
+	AllowedHeaders []string
+	// MaxAge is how long browsers can cache the result of a preflight request.
+	MaxAge time.Duration
+}
+
+func (c *CORSConfig) Validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "" {
+			return fmt.Errorf("empty CORS origin")
+		}
+		if strings.Count(origin, "*") > 1 {
+			return fmt.Errorf("invalid CORS origin %q: at most one wildcard is allowed", origin)
+		}
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("CORS max-age must not be negative")
+	}
+	return nil
+}
+
+// isOriginAllowed returns whether the origin matches one of the allowed origins.
+func (c *CORSConfig) isOriginAllowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		prefix, suffix, ok := strings.Cut(allowed, "*")
+		if ok && len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsHandler returns a handler that answers the OPTIONS (and CORS preflight) requests,
+// and adds the CORS headers to the responses of h for the allowed origins.
+// If conf is nil or has no allowed origins, no CORS headers are added.
+func corsHandler(h fasthttp.RequestHandler, conf *CORSConfig) fasthttp.RequestHandler {
+	return func(reqCtx *fasthttp.RequestCtx) {
+		origin := string(reqCtx.Request.Header.Peek(fasthttp.HeaderOrigin))
+		allowed := origin != "" && conf != nil && conf.isOriginAllowed(origin)
+		if reqCtx.IsOptions() {
+			reqCtx.Response.Header.Set(fasthttp.HeaderAllow, corsAllowedMethods)
+			if allowed {
+				setCORSHeaders(reqCtx, conf, origin)
+				if len(reqCtx.Request.Header.Peek(fasthttp.HeaderAccessControlRequestMethod)) > 0 {
+					reqCtx.Response.Header.Set(fasthttp.HeaderAccessControlAllowMethods, corsAllowedMethods)
+					if len(conf.AllowedHeaders) > 0 {
+						reqCtx.Response.Header.Set(fasthttp.HeaderAccessControlAllowHeaders, strings.Join(conf.AllowedHeaders, ", "))
+					}
+					if conf.MaxAge > 0 {
+						reqCtx.Response.Header.Set(fasthttp.HeaderAccessControlMaxAge, strconv.Itoa(int(conf.MaxAge.Seconds())))
+					}
+				}
+			}
+			reqCtx.SetStatusCode(fasthttp.StatusNoContent)
+			return
+		}
+		h(reqCtx)
+		if allowed {
+			setCORSHeaders(reqCtx, conf, origin)
+		}
+	}
+}
+
+func setCORSHeaders(reqCtx *fasthttp.RequestCtx, conf *CORSConfig, origin string) {
+	if len(conf.AllowedOrigins) == 1 && conf.AllowedOrigins[0] == "*" {
+		reqCtx.Response.Header.Set(fasthttp.HeaderAccessControlAllowOrigin, "*")
+	} else {
+		reqCtx.Response.Header.Set(fasthttp.HeaderAccessControlAllowOrigin, origin)
+		reqCtx.Response.Header.Add(fasthttp.HeaderVary, fasthttp.HeaderOrigin)
+	}
+	reqCtx.Response.Header.Set(fasthttp.HeaderAccessControlExposeHeaders, "X-Request-ID")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestCORSHandler(t *testing.T) {
+	conf := &CORSConfig{
+		AllowedOrigins: []string{"https://explorer.example.com", "https://*.example.org"},
+		AllowedHeaders: DefaultCORSAllowedHeaders,
+		MaxAge:         10 * time.Minute,
+	}
+	require.NoError(t, conf.Validate())
+	called := false
+	handler := corsHandler(func(reqCtx *fasthttp.RequestCtx) {
+		called = true
+		reqCtx.SetBodyString(`{"jsonrpc":"2.0","result":null,"id":1}`)
+	}, conf)
+	serve := func(method, origin string, preflight bool) *fasthttp.Response {
+		called = false
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Request.Header.SetMethod(method)
+		if origin != "" {
+			reqCtx.Request.Header.Set(fasthttp.HeaderOrigin, origin)
+		}
+		if preflight {
+			reqCtx.Request.Header.Set(fasthttp.HeaderAccessControlRequestMethod, "POST")
+		}
+		handler(&reqCtx)
+		return &reqCtx.Response
+	}
+
+	t.Run("preflight", func(t *testing.T) {
+		resp := serve(fasthttp.MethodOptions, "https://explorer.example.com", true)
+		require.False(t, called)
+		require.Equal(t, fasthttp.StatusNoContent, resp.StatusCode())
+		require.Equal(t, "https://explorer.example.com", string(resp.Header.Peek(fasthttp.HeaderAccessControlAllowOrigin)))
+		require.Equal(t, corsAllowedMethods, string(resp.Header.Peek(fasthttp.HeaderAccessControlAllowMethods)))
+		require.Equal(t, "Content-Type, Solana-Client", string(resp.Header.Peek(fasthttp.HeaderAccessControlAllowHeaders)))
+		require.Equal(t, "600", string(resp.Header.Peek(fasthttp.HeaderAccessControlMaxAge)))
+	})
+	t.Run("wildcard subdomain", func(t *testing.T) {
+		resp := serve(fasthttp.MethodPost, "https://app.example.org", false)
+		require.True(t, called)
+		require.Equal(t, "https://app.example.org", string(resp.Header.Peek(fasthttp.HeaderAccessControlAllowOrigin)))
+		require.Equal(t, "X-Request-ID", string(resp.Header.Peek(fasthttp.HeaderAccessControlExposeHeaders)))
+		require.Equal(t, fasthttp.HeaderOrigin, string(resp.Header.Peek(fasthttp.HeaderVary)))
+	})
+	t.Run("origin not allowed", func(t *testing.T) {
+		resp := serve(fasthttp.MethodPost, "https://evil.example.com", false)
+		require.True(t, called)
+		require.Empty(t, resp.Header.Peek(fasthttp.HeaderAccessControlAllowOrigin))
+
+		resp = serve(fasthttp.MethodOptions, "https://example.org", true)
+		require.Equal(t, fasthttp.StatusNoContent, resp.StatusCode())
+		require.Empty(t, resp.Header.Peek(fasthttp.HeaderAccessControlAllowOrigin))
+		require.Empty(t, resp.Header.Peek(fasthttp.HeaderAccessControlAllowMethods))
+	})
+	t.Run("plain OPTIONS", func(t *testing.T) {
+		resp := serve(fasthttp.MethodOptions, "", false)
+		require.False(t, called)
+		require.Equal(t, fasthttp.StatusNoContent, resp.StatusCode())
+		require.Equal(t, corsAllowedMethods, string(resp.Header.Peek(fasthttp.HeaderAllow)))
+	})
+	t.Run("any origin", func(t *testing.T) {
+		handler := corsHandler(func(reqCtx *fasthttp.RequestCtx) {}, &CORSConfig{AllowedOrigins: []string{"*"}})
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Request.Header.SetMethod(fasthttp.MethodGet)
+		reqCtx.Request.Header.Set(fasthttp.HeaderOrigin, "https://anything.example")
+		handler(&reqCtx)
+		require.Equal(t, "*", string(reqCtx.Response.Header.Peek(fasthttp.HeaderAccessControlAllowOrigin)))
+		require.Empty(t, reqCtx.Response.Header.Peek(fasthttp.HeaderVary))
+	})
+	t.Run("disabled", func(t *testing.T) {
+		handler := corsHandler(func(reqCtx *fasthttp.RequestCtx) {}, nil)
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Request.Header.SetMethod(fasthttp.MethodPost)
+		reqCtx.Request.Header.Set(fasthttp.HeaderOrigin, "https://explorer.example.com")
+		handler(&reqCtx)
+		require.Empty(t, reqCtx.Response.Header.Peek(fasthttp.HeaderAccessControlAllowOrigin))
+	})
+
+	require.Error(t, (&CORSConfig{AllowedOrigins: []string{"https://*.*.example.com"}}).Validate())
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/goware/urlx"
+	"github.com/rpcpool/yellowstone-faithful/metrics"
+	"github.com/valyala/fasthttp"
+)
+
+// ShadowConfig is the configuration of the request shadowing: a sample of the JSON RPC requests
+// handled locally is also sent to another endpoint (e.g. a canary running a new version), and its
+// responses are compared with the local ones. The client only gets the local response.
+type ShadowConfig struct {
+	// Target is the URL of the JSON RPC endpoint the requests are shadowed to.
+	Target string
+	// Percent is the percentage of the requests that are shadowed, in (0, 100].
+	Percent float64
+	// Timeout is how long to wait for the response of the target.
+	Timeout time.Duration
+	// MaxInFlight is how many shadowed requests can be waiting for the target; when that many are,
+	// the requests are not shadowed (and counted as dropped).
+	MaxInFlight int
+}
+
+func (c *ShadowConfig) Validate() error {
+	if c.Target == "" {
+		return fmt.Errorf("shadow target must not be empty")
+	}
+	if _, err := urlx.Parse(c.Target); err != nil {
+		return fmt.Errorf("invalid shadow target URL %q: %w", c.Target, err)
+	}
+	if c.Percent <= 0 || c.Percent > 100 {
+		return fmt.Errorf("shadow percent must be in (0, 100], got %v", c.Percent)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("shadow timeout must be positive")
+	}
+	if c.MaxInFlight <= 0 {
+		return fmt.Errorf("shadow max in-flight must be positive")
+	}
+	return nil
+}
+
+type shadower struct {
+	config   *ShadowConfig
+	client   *fasthttp.Client
+	inFlight chan struct{}
+	// sample returns true for the requests to shadow.
+	sample func() bool
+}
+
+func newShadower(config *ShadowConfig) *shadower {
+	return &shadower{
+		config:   config,
+		client:   &fasthttp.Client{ReadTimeout: config.Timeout, WriteTimeout: config.Timeout},
+		inFlight: make(chan struct{}, config.MaxInFlight),
+		sample:   func() bool { return rand.Float64()*100 < config.Percent },
+	}
+}
+
+// maybeShadow shadows a sample of the requests: the request body is sent to the target in the
+// background, and its response is compared with the local one. The arguments are copied.
+func (s *shadower) maybeShadow(method string, reqID string, body []byte, localStatus int, localBody []byte, localLatency time.Duration) {
+	if !s.sample() {
+		return
+	}
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		metrics.ShadowRequests.WithLabelValues(sanitizeMethod(method), "dropped").Inc()
+		return
+	}
+	body = append([]byte(nil), body...)
+	localBody = append([]byte(nil), localBody...)
+	go func() {
+		defer func() { <-s.inFlight }()
+		outcome := s.shadow(method, reqID, body, localStatus, localBody, localLatency)
+		metrics.ShadowRequests.WithLabelValues(sanitizeMethod(method), outcome).Inc()
+	}()
+}
+
+// shadow sends the request to the target and compares the responses; it returns the outcome:
+// "match", "mismatch" or "error".
+func (s *shadower) shadow(method string, reqID string, body []byte, localStatus int, localBody []byte, localLatency time.Duration) string {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	req.Header.SetMethod(http.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.Header.Set("X-Shadow-Request-ID", reqID)
+	req.SetRequestURI(s.config.Target)
+	req.SetBody(body)
+
+	startedAt := time.Now()
+	err := s.client.DoTimeout(req, resp, s.config.Timeout)
+	latency := time.Since(startedAt)
+	if err != nil {
+		rpcLogger.Warn("shadow request failed", "request_id", reqID, "method", method, "target", s.config.Target, "error", err)
+		return "error"
+	}
+	delta := latency - localLatency
+	metrics.ShadowLatencyDelta.WithLabelValues(sanitizeMethod(method)).Observe(delta.Seconds())
+
+	if resp.StatusCode() != localStatus {
+		rpcLogger.Warn("shadow response mismatch", "request_id", reqID, "method", method,
+			"diff", fmt.Sprintf("status: local %d, shadow %d", localStatus, resp.StatusCode()), "latency_delta", delta)
+		return "mismatch"
+	}
+	diff, err := diffJSONRPCResponses(localBody, resp.Body())
+	if err != nil {
+		rpcLogger.Warn("failed to compare shadow response", "request_id", reqID, "method", method, "error", err)
+		return "error"
+	}
+	if diff != "" {
+		rpcLogger.Warn("shadow response mismatch", "request_id", reqID, "method", method, "diff", diff, "latency_delta", delta)
+		return "mismatch"
+	}
+	rpcLogger.Debug("shadow response match", "request_id", reqID, "method", method, "latency_delta", delta)
+	return "match"
+}
+
+// diffJSONRPCResponses compares the result and error of two JSON RPC responses, and returns
+// where they first differ, or "" if they are the same.
+func diffJSONRPCResponses(local, shadow []byte) (string, error) {
+	var localResp, shadowResp struct {
+		Result any `json:"result"`
+		Error  any `json:"error"`
+	}
+	if err := fasterJson.Unmarshal(local, &localResp); err != nil {
+		return "", fmt.Errorf("failed to parse the local response: %w", err)
+	}
+	if err := fasterJson.Unmarshal(shadow, &shadowResp); err != nil {
+		return "", fmt.Errorf("failed to parse the shadow response: %w", err)
+	}
+	if diff := diffJSON("error", localResp.Error, shadowResp.Error); diff != "" {
+		return diff, nil
+	}
+	return diffJSON("result", localResp.Result, shadowResp.Result), nil
+}
+
+// diffJSON returns the path of the first difference between two decoded JSON values, and the
+// two values there, or "" if they are the same.
+func diffJSON(path string, a, b any) string {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for k := range a {
+			keys = append(keys, k)
+		}
+		for k := range b {
+			if _, ok := a[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if diff := diffJSON(path+"."+k, a[k], b[k]); diff != "" {
+				return diff
+			}
+		}
+		return ""
+	case []any:
+		b, ok := b.([]any)
+		if !ok {
+			break
+		}
+		if len(a) != len(b) {
+			return fmt.Sprintf("%s: local has %d items, shadow %d", path, len(a), len(b))
+		}
+		for i := range a {
+			if diff := diffJSON(path+"["+strconv.Itoa(i)+"]", a[i], b[i]); diff != "" {
+				return diff
+			}
+		}
+		return ""
+	}
+	if reflect.DeepEqual(a, b) {
+		return ""
+	}
+	return fmt.Sprintf("%s: local %s, shadow %s", path, shortJSON(a), shortJSON(b))
+}
+
+// shortJSON encodes a value for a log line, truncated.
+func shortJSON(v any) string {
+	const maxLen = 100
+	buf, err := fasterJson.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if len(buf) > maxLen {
+		return string(buf[:maxLen]) + "..."
+	}
+	return string(buf)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rpcpool/yellowstone-faithful/metrics"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestDiffJSONRPCResponses(t *testing.T) {
+	diff, err := diffJSONRPCResponses(
+		[]byte(`{"jsonrpc":"2.0","id":1,"result":{"a":[1,2],"b":"x"}}`),
+		[]byte(`{"jsonrpc":"2.0","id":1,"result":{"b":"x","a":[1,2]}}`),
+	)
+	require.NoError(t, err)
+	require.Empty(t, diff)
+
+	diff, err = diffJSONRPCResponses(
+		[]byte(`{"result":{"a":[1,2],"b":"x"}}`),
+		[]byte(`{"result":{"a":[1,3],"b":"x"}}`),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "result.a[1]: local 2, shadow 3", diff)
+
+	diff, err = diffJSONRPCResponses([]byte(`{"result":[1]}`), []byte(`{"result":[1,2]}`))
+	require.NoError(t, err)
+	require.Equal(t, "result: local has 1 items, shadow 2", diff)
+
+	diff, err = diffJSONRPCResponses([]byte(`{"result":null}`), []byte(`{"error":{"code":-32603}}`))
+	require.NoError(t, err)
+	require.Equal(t, `error: local null, shadow {"code":-32603}`, diff)
+
+	_, err = diffJSONRPCResponses([]byte(`{"result":1}`), []byte(`<html>`))
+	require.Error(t, err)
+}
+
+func TestShadowConfigValidate(t *testing.T) {
+	valid := ShadowConfig{Target: "http://localhost:8899", Percent: 10, Timeout: time.Second, MaxInFlight: 1}
+	require.NoError(t, valid.Validate())
+	for _, invalid := range []func(c *ShadowConfig){
+		func(c *ShadowConfig) { c.Target = "" },
+		func(c *ShadowConfig) { c.Percent = 0 },
+		func(c *ShadowConfig) { c.Percent = 101 },
+		func(c *ShadowConfig) { c.Timeout = 0 },
+		func(c *ShadowConfig) { c.MaxInFlight = 0 },
+	} {
+		config := valid
+		invalid(&config)
+		require.Error(t, config.Validate())
+	}
+}
+
+func TestShadowRequests(t *testing.T) {
+	config, _, slots, _ := buildTestEpoch(t)
+	epoch := openTestEpoch(t, config)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+
+	// the shadow target answers with the body it's given.
+	var shadowBody string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NotEmpty(t, r.Header.Get("X-Shadow-Request-ID"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(shadowBody))
+	}))
+	defer target.Close()
+
+	handler := newMultiEpochHandler(multi, &ListenerConfig{Shadow: &ShadowConfig{
+		Target:      target.URL,
+		Percent:     100,
+		Timeout:     10 * time.Second,
+		MaxInFlight: 1,
+	}})
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	server := &fasthttp.Server{Handler: handler}
+	go server.Serve(ln)
+	defer server.Shutdown()
+	client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+
+	call := func() string {
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+		req.SetRequestURI("http://faithful/")
+		req.Header.SetMethod(http.MethodPost)
+		req.SetBody([]byte(`{"jsonrpc":"2.0","id":1,"method":"getBlocks","params":[` + strconv.FormatUint(slots[0], 10) + `,` + strconv.FormatUint(slots[5], 10) + `]}`))
+		require.NoError(t, client.Do(req, resp))
+		require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+		return string(resp.Body())
+	}
+	counter := func(outcome string) float64 {
+		return testutil.ToFloat64(metrics.ShadowRequests.WithLabelValues("getBlocks", outcome))
+	}
+	waitFor := func(outcome string, want float64) {
+		require.Eventually(t, func() bool { return counter(outcome) == want }, 10*time.Second, 10*time.Millisecond, outcome)
+	}
+
+	matches, mismatches, errors := counter("match"), counter("mismatch"), counter("error")
+	// an invalid response of the target is an error.
+	shadowBody = `<html>`
+	local := call()
+	require.Contains(t, local, `"result":[`)
+	waitFor("error", errors+1)
+
+	shadowBody = local
+	require.Equal(t, local, call())
+	waitFor("match", matches+1)
+
+	// a different result is a mismatch; the client still gets the local response.
+	shadowBody = `{"jsonrpc":"2.0","id":1,"result":[1]}`
+	require.Equal(t, local, call())
+	waitFor("mismatch", mismatches+1)
+}
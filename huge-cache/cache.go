@@ -7,11 +7,17 @@ import (
 
 	"github.com/allegro/bigcache/v3"
 	"github.com/ipfs/go-cid"
+	diskcache "github.com/rpcpool/yellowstone-faithful/disk-cache"
 	"github.com/rpcpool/yellowstone-faithful/indexes"
+	pagecache "github.com/rpcpool/yellowstone-faithful/page-cache"
 )
 
 type Cache struct {
 	cache *bigcache.BigCache
+	// disk is an optional second tier for raw CAR objects fetched from remote sources.
+	disk *diskcache.Cache
+	// indexPages is an optional on-disk cache of the pages of remote index files, shared by the epochs.
+	indexPages *pagecache.Cache
 }
 
 func NewWithConfig(ctx context.Context, config bigcache.Config) (*Cache, error) {
@@ -36,21 +42,58 @@ func formatOffsetAndSizeKey(c cid.Cid) string {
 	return "o&s-" + c.String()
 }
 
+// SetDiskCache sets the on-disk cache used as a second tier for raw CAR objects.
+func (r *Cache) SetDiskCache(disk *diskcache.Cache) {
+	r.disk = disk
+}
+
+// SetIndexPageCache sets the on-disk cache of the pages of remote index files.
+func (r *Cache) SetIndexPageCache(pages *pagecache.Cache) {
+	r.indexPages = pages
+}
+
+// IndexPageCache returns the on-disk cache of the pages of remote index files, or nil.
+func (r *Cache) IndexPageCache() *pagecache.Cache {
+	if r == nil {
+		return nil
+	}
+	return r.indexPages
+}
+
 // PutRawCarObject stores the raw CAR object data.
 func (r *Cache) PutRawCarObject(c cid.Cid, data []byte) error {
 	return r.cache.Set(formatRawCarObjectKey(c), data)
 }
 
+// PersistRawCarObject stores the raw CAR object data in memory and, if configured, on disk.
+// Use it for objects that are expensive to fetch again (i.e. from remote sources).
+func (r *Cache) PersistRawCarObject(c cid.Cid, data []byte) error {
+	if err := r.PutRawCarObject(c, data); err != nil {
+		return err
+	}
+	if r.disk == nil {
+		return nil
+	}
+	return r.disk.Put(c, data)
+}
+
 // GetRawCarObject returns the raw CAR object data from the cache if it exists.
+// Objects found only on disk are promoted to memory.
 func (r *Cache) GetRawCarObject(c cid.Cid) (v []byte, err error, has bool) {
 	if v, err := r.cache.Get(formatRawCarObjectKey(c)); err == nil {
 		return v, nil, true
-	} else {
-		if errors.Is(err, bigcache.ErrEntryNotFound) {
-			return nil, nil, false
-		}
+	} else if !errors.Is(err, bigcache.ErrEntryNotFound) {
+		return nil, err, false
+	}
+	if r.disk == nil {
+		return nil, nil, false
+	}
+	v, has, err = r.disk.Get(c)
+	if err != nil || !has {
 		return nil, err, false
 	}
+	r.PutRawCarObject(c, v)
+	return v, nil, true
 }
 
 // PutSlotToCid stores the CID for the given slot.
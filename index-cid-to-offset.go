@@ -44,7 +44,9 @@ func CreateIndex_cid2offset(
 	}
 	defer carFile.Close()
 
-	rd, err := carreader.New(carFile)
+	// Hash the CAR while indexing it, to record it in the index provenance.
+	hashingCarFile := newHashingReadCloser(carFile)
+	rd, err := carreader.New(hashingCarFile)
 	if err != nil {
 		return "", fmt.Errorf("failed to create car reader: %w", err)
 	}
@@ -119,6 +121,10 @@ func CreateIndex_cid2offset(
 		}
 	}
 
+	if err = c2o.SetProvenance(newIndexProvenance(hashingCarFile.Sum())); err != nil {
+		return "", fmt.Errorf("failed to set index provenance: %w", err)
+	}
+
 	klog.Infof("Sealing index...")
 	if err = c2o.Seal(ctx, indexDir); err != nil {
 		return "", fmt.Errorf("failed to seal index: %w", err)
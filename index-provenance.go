@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"k8s.io/klog/v2"
+)
+
+// generatorVersion is the version recorded in the provenance of the indexes generated by this binary.
+func generatorVersion() string {
+	version := "faithful-cli"
+	if GitTag != "" {
+		version += " " + GitTag
+	}
+	if GitCommit != "" {
+		version += " (" + GitCommit + ")"
+	}
+	return version
+}
+
+// newIndexProvenance returns the provenance of an index generated now from the CAR with the given SHA256.
+func newIndexProvenance(carSha256 []byte) indexes.Provenance {
+	return indexes.Provenance{
+		CarSha256:        carSha256,
+		GeneratorVersion: generatorVersion(),
+		CreatedAt:        time.Now().UTC(),
+	}
+}
+
+// carFileSha256 returns the SHA256 of the file.
+func carFileSha256(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// hashingReadCloser computes the SHA256 of everything that is read through it,
+// so that the CAR can be hashed while it's being indexed.
+type hashingReadCloser struct {
+	io.Reader
+	io.Closer
+	hash hash.Hash
+}
+
+func newHashingReadCloser(rc io.ReadCloser) *hashingReadCloser {
+	h := sha256.New()
+	return &hashingReadCloser{
+		Reader: io.TeeReader(rc, h),
+		Closer: rc,
+		hash:   h,
+	}
+}
+
+// Sum returns the SHA256 of what was read so far.
+func (h *hashingReadCloser) Sum() []byte {
+	return h.hash.Sum(nil)
+}
+
+// carHashMismatchPolicy is what the RPC server does when the CAR hash recorded in the indexes of an epoch
+// doesn't match the configured CAR (or the indexes disagree between them).
+type carHashMismatchPolicy string
+
+const (
+	carHashMismatchRefuse carHashMismatchPolicy = "refuse"
+	carHashMismatchWarn   carHashMismatchPolicy = "warn"
+)
+
+// checkIndexCarHashes checks that the CAR hashes recorded in the indexes (by index name) agree with each
+// other and with the CAR hash of the config, if set. Indexes without a recorded CAR hash are not checked.
+// If the policy is to warn, mismatches are logged instead of returned.
+func checkIndexCarHashes(config *Config, carHashes map[string][]byte, policy carHashMismatchPolicy) error {
+	var expected []byte
+	expectedFrom := "data.car.sha256"
+	if config.Data.Car != nil && config.Data.Car.Sha256 != "" {
+		sum, err := hex.DecodeString(config.Data.Car.Sha256)
+		if err != nil {
+			return fmt.Errorf("invalid data.car.sha256: %w", err)
+		}
+		expected = sum
+	}
+	names := make([]string, 0, len(carHashes))
+	for name := range carHashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		got := carHashes[name]
+		if len(got) == 0 {
+			continue
+		}
+		if expected == nil {
+			expected = got
+			expectedFrom = name + " index"
+			continue
+		}
+		if bytes.Equal(expected, got) {
+			continue
+		}
+		err := fmt.Errorf("CAR hash mismatch in %s index: expected %x (from %s), got %x", name, expected, expectedFrom, got)
+		if policy == carHashMismatchWarn {
+			klog.Warningf("epoch %d: %s", *config.Epoch, err)
+			continue
+		}
+		return err
+	}
+	return nil
+}
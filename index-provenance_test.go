@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestCheckIndexCarHashes(t *testing.T) {
+	config := writeTestConfig(t, t.TempDir(), "epoch-0.yml", `epoch: 0
+version: 1
+data:
+  car:
+    uri: /tmp/epoch-0.car
+`)
+	a := bytes.Repeat([]byte{0xaa}, 32)
+	b := bytes.Repeat([]byte{0xbb}, 32)
+
+	// No hashes recorded: nothing to check.
+	require.NoError(t, checkIndexCarHashes(config, map[string][]byte{"slot-to-cid": nil}, carHashMismatchRefuse))
+	// The indexes agree.
+	require.NoError(t, checkIndexCarHashes(config, map[string][]byte{"slot-to-cid": a, "sig-to-cid": a, "sig-exists": nil}, carHashMismatchRefuse))
+	// The indexes disagree.
+	mismatch := map[string][]byte{"slot-to-cid": a, "sig-to-cid": b}
+	require.ErrorContains(t, checkIndexCarHashes(config, mismatch, carHashMismatchRefuse), "CAR hash mismatch in slot-to-cid index")
+	require.NoError(t, checkIndexCarHashes(config, mismatch, carHashMismatchWarn))
+
+	// The configured CAR hash wins.
+	config = writeTestConfig(t, t.TempDir(), "epoch-0.yml", `epoch: 0
+version: 1
+data:
+  car:
+    uri: /tmp/epoch-0.car
+    sha256: `+hex.EncodeToString(b)+`
+`)
+	require.NoError(t, checkIndexCarHashes(config, map[string][]byte{"sig-to-cid": b}, carHashMismatchRefuse))
+	require.ErrorContains(t, checkIndexCarHashes(config, map[string][]byte{"slot-to-cid": a}, carHashMismatchRefuse), "(from data.car.sha256)")
+}
+
+func TestEpochCarHash(t *testing.T) {
+	config, paths, _, _ := buildTestEpoch(t)
+	carSha256, err := carFileSha256(string(config.Data.Car.URI))
+	require.NoError(t, err)
+
+	slotToCid, err := indexes.Open_SlotToCid(paths.SlotToCid)
+	require.NoError(t, err)
+	defer slotToCid.Close()
+	provenance := slotToCid.Meta().Provenance
+	require.Equal(t, carSha256, provenance.CarSha256)
+	require.Equal(t, generatorVersion(), provenance.GeneratorVersion)
+	require.False(t, provenance.CreatedAt.IsZero())
+
+	config.Data.Car.Sha256 = hex.EncodeToString(carSha256)
+	require.NoError(t, config.Validate())
+	openTestEpoch(t, config)
+
+	config.Data.Car.Sha256 = hex.EncodeToString(bytes.Repeat([]byte{0xaa}, 32))
+	c := cli.NewContext(cli.NewApp(), nil, nil)
+	c.Context = context.Background()
+	_, err = NewEpochFromConfig(config, c, nil, nil)
+	require.ErrorContains(t, err, "CAR hash mismatch in cid-to-offset-and-size index")
+}
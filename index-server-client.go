@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+)
+
+// indexServerClient does the index lookups of one epoch on an index server.
+// A key that is not in the index is reported as compactindexsized.ErrNotFound,
+// like the local index readers do.
+type indexServerClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+var _ SigExistsIndex = (*indexServerClient)(nil)
+
+func newIndexServerClient(serverURL string, epoch uint64) *indexServerClient {
+	return &indexServerClient{
+		baseURL: strings.TrimRight(serverURL, "/") + indexServerPathPrefix + strconv.FormatUint(epoch, 10),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: 100,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+}
+
+func (c *indexServerClient) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("index server: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("index server: failed to decode response of %s: %w", path, err)
+		}
+		return nil
+	case http.StatusNotFound:
+		io.Copy(io.Discard, resp.Body)
+		return compactindexsized.ErrNotFound
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("index server: %s: status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+}
+
+// Info returns the epoch and the root CID of the indexes served for the epoch.
+func (c *indexServerClient) Info(ctx context.Context) (uint64, cid.Cid, error) {
+	var info indexServerInfo
+	if err := c.get(ctx, "/info", &info); err != nil {
+		return 0, cid.Undef, err
+	}
+	rootCid, err := cid.Parse(info.RootCid)
+	if err != nil {
+		return 0, cid.Undef, fmt.Errorf("index server: invalid root CID %q: %w", info.RootCid, err)
+	}
+	return info.Epoch, rootCid, nil
+}
+
+func (c *indexServerClient) getCidAndOffset(ctx context.Context, path string) (cid.Cid, *indexes.OffsetAndSize, error) {
+	var res indexServerLookup
+	if err := c.get(ctx, path, &res); err != nil {
+		return cid.Undef, nil, err
+	}
+	found, err := cid.Parse(res.Cid)
+	if err != nil {
+		return cid.Undef, nil, fmt.Errorf("index server: invalid CID %q: %w", res.Cid, err)
+	}
+	return found, &indexes.OffsetAndSize{Offset: res.Offset, Size: res.Size}, nil
+}
+
+// SlotToCid returns the CID of the block at the slot, and its offset and size in the CAR.
+func (c *indexServerClient) SlotToCid(ctx context.Context, slot uint64) (cid.Cid, *indexes.OffsetAndSize, error) {
+	return c.getCidAndOffset(ctx, "/slot/"+strconv.FormatUint(slot, 10))
+}
+
+// SigToCid returns the CID of the transaction, and its offset and size in the CAR.
+func (c *indexServerClient) SigToCid(ctx context.Context, sig solana.Signature) (cid.Cid, *indexes.OffsetAndSize, error) {
+	return c.getCidAndOffset(ctx, "/sig/"+sig.String())
+}
+
+// CidToOffsetAndSize returns the offset and size of the node in the CAR.
+func (c *indexServerClient) CidToOffsetAndSize(ctx context.Context, wanted cid.Cid) (*indexes.OffsetAndSize, error) {
+	var res indexServerLookup
+	if err := c.get(ctx, "/cid/"+wanted.String(), &res); err != nil {
+		return nil, err
+	}
+	return &indexes.OffsetAndSize{Offset: res.Offset, Size: res.Size}, nil
+}
+
+// Has implements SigExistsIndex.
+func (c *indexServerClient) Has(sig [64]byte) (bool, error) {
+	var res indexServerSigExists
+	if err := c.get(context.Background(), "/sig-exists/"+solana.Signature(sig).String(), &res); err != nil {
+		return false, err
+	}
+	return res.Exists, nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/bucketteer"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/indexmeta"
+	"github.com/valyala/fasthttp"
+	"k8s.io/klog/v2"
+)
+
+// The index server serves the lookup indexes of epochs (cid-to-offset-and-size, slot-to-cid,
+// sig-to-cid, sig-exists) over HTTP, so that many stateless RPC frontends (configured with
+// indexes.server.uri) can share a few index servers with big page caches.
+//
+// Endpoints (all GET, JSON responses, 404 if the key is not in the index):
+//
+//	/index/v1/{epoch}/info              -> {"epoch": N, "root_cid": "..."}
+//	/index/v1/{epoch}/slot/{slot}       -> {"cid": "...", "offset": N, "size": N}
+//	/index/v1/{epoch}/sig/{signature}   -> {"cid": "...", "offset": N, "size": N}
+//	/index/v1/{epoch}/cid/{cid}         -> {"offset": N, "size": N}
+//	/index/v1/{epoch}/sig-exists/{sig}  -> {"exists": true|false}
+//
+// The slot and sig lookups also resolve the offset and size of the CID, so that a frontend
+// needs a single round trip to find a block or a transaction in the CAR.
+const indexServerPathPrefix = "/index/v1/"
+
+type indexServerInfo struct {
+	Epoch   uint64 `json:"epoch"`
+	RootCid string `json:"root_cid"`
+}
+
+type indexServerLookup struct {
+	Cid    string `json:"cid,omitempty"`
+	Offset uint64 `json:"offset"`
+	Size   uint64 `json:"size"`
+}
+
+type indexServerSigExists struct {
+	Exists bool `json:"exists"`
+}
+
+// indexServerEpoch are the lookup indexes of one epoch.
+type indexServerEpoch struct {
+	epoch              uint64
+	rootCid            cid.Cid
+	cidToOffsetAndSize *indexes.CidToOffsetAndSize_Reader
+	slotToCid          *indexes.SlotToCid_Reader
+	sigToCid           *indexes.SigToCid_Reader
+	sigExists          *bucketteer.Reader
+	onClose            []func() error
+}
+
+// openIndexServerEpoch opens the lookup indexes of an epoch config; the CAR file and the
+// other indexes are not opened. Only the current index versions are supported.
+func openIndexServerEpoch(ctx context.Context, config *Config) (_ *indexServerEpoch, err error) {
+	if config.UsesIndexServer() {
+		return nil, errors.New("the epoch config uses an index server itself (indexes.server.uri)")
+	}
+	if config.IsDeprecatedIndexes() {
+		return nil, errors.New("deprecated indexes (indexes.cid_to_offset) are not supported by the index server")
+	}
+	ep := &indexServerEpoch{epoch: *config.Epoch}
+	defer func() {
+		if err != nil {
+			ep.Close()
+		}
+	}()
+	open := func(name string, uri URI) (ReaderAtCloser, error) {
+		file, err := openIndexStorage(ctx, string(uri), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s index file: %w", name, err)
+		}
+		ep.onClose = append(ep.onClose, file.Close)
+		return file, nil
+	}
+	checkMeta := func(name string, epoch uint64, rootCid cid.Cid) error {
+		if epoch != ep.epoch {
+			return fmt.Errorf("epoch mismatch in %s index: expected %d, got %d", name, ep.epoch, epoch)
+		}
+		if ep.rootCid == cid.Undef {
+			ep.rootCid = rootCid
+		} else if !ep.rootCid.Equals(rootCid) {
+			return fmt.Errorf("root CID mismatch in %s index: expected %s, got %s", name, ep.rootCid, rootCid)
+		}
+		return nil
+	}
+	{
+		file, err := open("cid-to-offset-and-size", config.Indexes.CidToOffsetAndSize.URI)
+		if err != nil {
+			return nil, err
+		}
+		ep.cidToOffsetAndSize, err = indexes.OpenWithReader_CidToOffsetAndSize(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cid-to-offset-and-size index: %w", err)
+		}
+		if err := checkMeta("cid-to-offset-and-size", ep.cidToOffsetAndSize.Meta().Epoch, ep.cidToOffsetAndSize.Meta().RootCid); err != nil {
+			return nil, err
+		}
+	}
+	{
+		file, err := open("slot-to-cid", config.Indexes.SlotToCid.URI)
+		if err != nil {
+			return nil, err
+		}
+		ep.slotToCid, err = indexes.OpenWithReader_SlotToCid(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open slot-to-cid index: %w", err)
+		}
+		if ep.slotToCid.IsDeprecatedOldVersion() {
+			return nil, errors.New("deprecated slot-to-cid index is not supported by the index server")
+		}
+		if err := checkMeta("slot-to-cid", ep.slotToCid.Meta().Epoch, ep.slotToCid.Meta().RootCid); err != nil {
+			return nil, err
+		}
+	}
+	{
+		file, err := open("sig-to-cid", config.Indexes.SigToCid.URI)
+		if err != nil {
+			return nil, err
+		}
+		ep.sigToCid, err = indexes.OpenWithReader_SigToCid(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sig-to-cid index: %w", err)
+		}
+		if ep.sigToCid.IsDeprecatedOldVersion() {
+			return nil, errors.New("deprecated sig-to-cid index is not supported by the index server")
+		}
+		if err := checkMeta("sig-to-cid", ep.sigToCid.Meta().Epoch, ep.sigToCid.Meta().RootCid); err != nil {
+			return nil, err
+		}
+	}
+	{
+		file, err := open("sig-exists", config.Indexes.SigExists.URI)
+		if err != nil {
+			return nil, err
+		}
+		ep.sigExists, err = bucketteer.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sig-exists index: %w", err)
+		}
+		ep.onClose = append(ep.onClose, ep.sigExists.Close)
+		gotEpoch, ok := ep.sigExists.Meta().GetUint64(indexmeta.MetadataKey_Epoch)
+		if !ok {
+			return nil, errors.New("the sig-exists index does not have the epoch metadata")
+		}
+		gotRootCid, ok := ep.sigExists.Meta().GetCid(indexmeta.MetadataKey_RootCid)
+		if !ok {
+			return nil, errors.New("the sig-exists index does not have the root CID metadata")
+		}
+		if err := checkMeta("sig-exists", gotEpoch, gotRootCid); err != nil {
+			return nil, err
+		}
+	}
+	return ep, nil
+}
+
+func (ep *indexServerEpoch) Close() error {
+	var errs []error
+	for i := len(ep.onClose) - 1; i >= 0; i-- {
+		if err := ep.onClose[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lookupCid resolves the offset and size of a CID found by the slot or sig index.
+func (ep *indexServerEpoch) lookupCid(c cid.Cid) (*indexServerLookup, error) {
+	oas, err := ep.cidToOffsetAndSize.Get(c)
+	if err != nil {
+		return nil, err
+	}
+	return &indexServerLookup{Cid: c.String(), Offset: oas.Offset, Size: oas.Size}, nil
+}
+
+// indexServer serves the lookups of the epochs it has loaded.
+type indexServer struct {
+	epochs map[uint64]*indexServerEpoch
+}
+
+func (s *indexServer) handler(reqCtx *fasthttp.RequestCtx) {
+	if !reqCtx.IsGet() {
+		reqCtx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		return
+	}
+	path := string(reqCtx.Path())
+	if !strings.HasPrefix(path, indexServerPathPrefix) {
+		reqCtx.SetStatusCode(fasthttp.StatusNotFound)
+		return
+	}
+	parts := strings.Split(strings.Trim(path[len(indexServerPathPrefix):], "/"), "/")
+	epochNumber, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		replyJSON(reqCtx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid epoch"})
+		return
+	}
+	ep, ok := s.epochs[epochNumber]
+	if !ok {
+		replyJSON(reqCtx, fasthttp.StatusNotFound, map[string]string{"error": "epoch not available"})
+		return
+	}
+	if len(parts) == 2 && parts[1] == "info" {
+		replyJSON(reqCtx, fasthttp.StatusOK, indexServerInfo{Epoch: ep.epoch, RootCid: ep.rootCid.String()})
+		return
+	}
+	if len(parts) != 3 {
+		reqCtx.SetStatusCode(fasthttp.StatusNotFound)
+		return
+	}
+	result, err := ep.lookup(parts[1], parts[2])
+	switch {
+	case errors.Is(err, errIndexServerBadKey):
+		replyJSON(reqCtx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, compactindexsized.ErrNotFound):
+		replyJSON(reqCtx, fasthttp.StatusNotFound, map[string]string{"error": "not found"})
+	case err != nil:
+		klog.Errorf("index server: %s: %v", path, err)
+		replyJSON(reqCtx, fasthttp.StatusInternalServerError, map[string]string{"error": "internal error"})
+	default:
+		replyJSON(reqCtx, fasthttp.StatusOK, result)
+	}
+}
+
+var errIndexServerBadKey = errors.New("invalid lookup")
+
+func (ep *indexServerEpoch) lookup(kind string, key string) (any, error) {
+	switch kind {
+	case "slot":
+		slot, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid slot", errIndexServerBadKey)
+		}
+		c, err := ep.slotToCid.Get(slot)
+		if err != nil {
+			return nil, err
+		}
+		return ep.lookupCid(c)
+	case "sig":
+		sig, err := solana.SignatureFromBase58(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid signature", errIndexServerBadKey)
+		}
+		c, err := ep.sigToCid.Get(sig)
+		if err != nil {
+			return nil, err
+		}
+		return ep.lookupCid(c)
+	case "cid":
+		c, err := cid.Parse(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid CID", errIndexServerBadKey)
+		}
+		oas, err := ep.cidToOffsetAndSize.Get(c)
+		if err != nil {
+			return nil, err
+		}
+		return &indexServerLookup{Offset: oas.Offset, Size: oas.Size}, nil
+	case "sig-exists":
+		sig, err := solana.SignatureFromBase58(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid signature", errIndexServerBadKey)
+		}
+		exists, err := ep.sigExists.Has(sig)
+		if err != nil {
+			return nil, err
+		}
+		return indexServerSigExists{Exists: exists}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown lookup %q", errIndexServerBadKey, kind)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/bucketteer"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	hugecache "github.com/rpcpool/yellowstone-faithful/huge-cache"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/indexmeta"
+	"github.com/rpcpool/yellowstone-faithful/poh"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func writeTestConfig(t testing.TB, dir string, name string, body string) *Config {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(body), 0o644))
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	return config
+}
+
+// The indexes of the fixture CAR are built once per test binary: the sig-exists writer
+// preallocates gigabytes, and a second one in the same process thrashes small machines.
+var testEpochIndexes struct {
+	once  sync.Once
+	paths *IndexPaths
+	slots []uint64
+	sigs  []solana.Signature
+	err   error
+}
+
+func buildTestEpochIndexes(carPath string) (*IndexPaths, []uint64, []solana.Signature, error) {
+	return buildTestEpochIndexesFor(0, carPath)
+}
+
+// buildTestEpochIndexesFor builds the indexes of the CAR of the epoch; it returns their paths, the
+// slots of the blocks and the signatures of the transactions.
+func buildTestEpochIndexesFor(epoch uint64, carPath string) (*IndexPaths, []uint64, []solana.Signature, error) {
+	ctx := context.Background()
+	indexDir, err := os.MkdirTemp("", "faithful-test-indexes-")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	paths := &IndexPaths{}
+	if paths.CidToOffsetAndSize, err = CreateIndex_cid2offset(ctx, epoch, indexes.NetworkMainnet, indexDir, carPath, indexDir); err != nil {
+		return nil, nil, nil, err
+	}
+	if paths.SlotToCid, err = CreateIndex_slot2cid(ctx, epoch, indexes.NetworkMainnet, indexDir, carPath, indexDir); err != nil {
+		return nil, nil, nil, err
+	}
+	if paths.SignatureToCid, err = CreateIndex_sig2cid(ctx, epoch, indexes.NetworkMainnet, indexDir, carPath, indexDir, false); err != nil {
+		return nil, nil, nil, err
+	}
+	if paths.SlotToBlocktime, err = CreateIndex_slot2blocktime(ctx, epoch, indexes.NetworkMainnet, carPath, indexDir); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var slots []uint64
+	var sigs []solana.Signature
+	source := carwriter.NewCarSource(carPath)
+	defer source.Close()
+	firstSlot, lastSlot := slottools.CalcEpochLimits(epoch)
+	err = source.Blocks(ctx, firstSlot, lastSlot, func(block *carwriter.Block) error {
+		slots = append(slots, block.Slot)
+		for _, entry := range block.Entries {
+			for _, tx := range entry.Transactions {
+				txSigs, err := poh.Signatures(tx.Data)
+				if err != nil {
+					return err
+				}
+				sigs = append(sigs, txSigs[0])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	slotToCid, err := indexes.Open_SlotToCid(paths.SlotToCid)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rootCid := slotToCid.Meta().RootCid
+	slotToCid.Close()
+
+	paths.SignatureExists = filepath.Join(indexDir, "sig-exists.index")
+	sigExists, err := bucketteer.NewWriter(paths.SignatureExists)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer sigExists.Close()
+	for _, sig := range sigs {
+		sigExists.Put(sig)
+	}
+	meta := indexmeta.Meta{}
+	if err := meta.AddUint64(indexmeta.MetadataKey_Epoch, epoch); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := meta.AddCid(indexmeta.MetadataKey_RootCid, rootCid); err != nil {
+		return nil, nil, nil, err
+	}
+	if _, err := sigExists.Seal(meta); err != nil {
+		return nil, nil, nil, err
+	}
+	return paths, slots, sigs, nil
+}
+
+// buildTestEpoch returns the config of the fixture epoch (with local indexes), the paths of
+// its indexes, the slots, and the signatures of the transactions.
+func buildTestEpoch(t testing.TB) (*Config, *IndexPaths, []uint64, []solana.Signature) {
+	t.Helper()
+	carPath, err := filepath.Abs(filepath.Join("fixtures", "epoch-0-1.car"))
+	require.NoError(t, err)
+	built := &testEpochIndexes
+	built.once.Do(func() {
+		built.paths, built.slots, built.sigs, built.err = buildTestEpochIndexes(carPath)
+	})
+	require.NoError(t, built.err)
+	require.NotEmpty(t, built.sigs)
+	return testEpochConfig(t, carPath, built.paths), built.paths, built.slots, built.sigs
+}
+
+// testEpochConfig returns the config of the epoch-0 CAR at carPath, with the given local indexes.
+func testEpochConfig(t testing.TB, carPath string, paths *IndexPaths) *Config {
+	t.Helper()
+	return testEpochConfigFor(t, 0, carPath, paths)
+}
+
+// testEpochConfigFor returns the config of the CAR of the epoch at carPath, with the given local indexes.
+func testEpochConfigFor(t testing.TB, epoch uint64, carPath string, paths *IndexPaths) *Config {
+	t.Helper()
+	config := writeTestConfig(t, t.TempDir(), fmt.Sprintf("epoch-%d.yml", epoch), fmt.Sprintf(`epoch: %d
+version: 1
+genesis:
+  hash: 5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d
+  creation_time: 1584368940
+data:
+  car:
+    uri: %q
+indexes:
+%s`, epoch, carPath, paths.String()))
+	require.NoError(t, config.Validate())
+	return config
+}
+
+func TestIndexServer(t *testing.T) {
+	ctx := context.Background()
+	backend, paths, slots, sigs := buildTestEpoch(t)
+	carPath := string(backend.Data.Car.URI)
+	configDir := t.TempDir()
+	ep, err := openIndexServerEpoch(ctx, backend)
+	require.NoError(t, err)
+	defer ep.Close()
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &indexServer{epochs: map[uint64]*indexServerEpoch{0: ep}}
+	srv := &fasthttp.Server{Handler: server.handler}
+	go srv.Serve(ln)
+	defer srv.Shutdown()
+	serverURL := "http://" + ln.Addr().String()
+
+	t.Run("client", func(t *testing.T) {
+		client := newIndexServerClient(serverURL, 0)
+		epoch, rootCid, err := client.Info(ctx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(0), epoch)
+		require.Equal(t, ep.rootCid, rootCid)
+
+		for _, slot := range slots {
+			c, oas, err := client.SlotToCid(ctx, slot)
+			require.NoError(t, err)
+			want, err := ep.slotToCid.Get(slot)
+			require.NoError(t, err)
+			require.Equal(t, want, c)
+			got, err := client.CidToOffsetAndSize(ctx, c)
+			require.NoError(t, err)
+			require.Equal(t, oas, got)
+		}
+		_, _, err = client.SlotToCid(ctx, 1_000_000)
+		require.ErrorIs(t, err, compactindexsized.ErrNotFound)
+
+		for _, sig := range sigs {
+			c, _, err := client.SigToCid(ctx, sig)
+			require.NoError(t, err)
+			want, err := ep.sigToCid.Get(sig)
+			require.NoError(t, err)
+			require.Equal(t, want, c)
+			exists, err := client.Has(sig)
+			require.NoError(t, err)
+			require.True(t, exists)
+		}
+		exists, err := client.Has(solana.Signature{1, 2, 3})
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		_, _, err = newIndexServerClient(serverURL, 1).Info(ctx)
+		require.ErrorIs(t, err, compactindexsized.ErrNotFound)
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		for path, status := range map[string]int{
+			"/index/v1/0/slot/abc":     fasthttp.StatusBadRequest,
+			"/index/v1/0/sig/abc":      fasthttp.StatusBadRequest,
+			"/index/v1/0/unknown/1":    fasthttp.StatusBadRequest,
+			"/index/v1/abc/slot/1":     fasthttp.StatusBadRequest,
+			"/index/v1/0/slot/1/extra": fasthttp.StatusNotFound,
+			"/other":                   fasthttp.StatusNotFound,
+		} {
+			code, _, err := fasthttp.Get(nil, serverURL+path)
+			require.NoError(t, err)
+			require.Equal(t, status, code, path)
+		}
+	})
+
+	t.Run("frontend", func(t *testing.T) {
+		frontend := writeTestConfig(t, configDir, "frontend.yml", fmt.Sprintf(`epoch: 0
+version: 1
+genesis:
+  hash: 5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d
+  creation_time: 1584368940
+data:
+  car:
+    uri: %q
+indexes:
+  slot_to_blocktime:
+    uri: %q
+  server:
+    uri: %q
+`, carPath, paths.SlotToBlocktime, serverURL))
+		require.NoError(t, frontend.Validate())
+
+		epoch := openTestEpoch(t, frontend)
+
+		for _, slot := range slots {
+			block, _, err := epoch.GetBlock(ctx, slot)
+			require.NoError(t, err)
+			require.Equal(t, slot, uint64(block.Slot))
+		}
+		for _, sig := range sigs {
+			tx, _, err := epoch.GetTransaction(ctx, sig)
+			require.NoError(t, err)
+			require.NotNil(t, tx)
+		}
+	})
+}
+
+// openTestEpoch opens the epoch of the config, like the rpc command does.
+func openTestEpoch(t testing.TB, config *Config) *Epoch {
+	t.Helper()
+	ctx := context.Background()
+	conf := bigcache.DefaultConfig(time.Minute)
+	conf.MaxEntriesInWindow = 1000 // the default preallocates hundreds of MB
+	conf.Verbose = false           // its logs would be mixed with the output of the benchmarks
+	allCache, err := hugecache.NewWithConfig(ctx, conf)
+	require.NoError(t, err)
+	c := cli.NewContext(cli.NewApp(), nil, nil)
+	c.Context = ctx
+	epoch, err := NewEpochFromConfig(config, c, allCache, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { epoch.Close() })
+	return epoch
+}
+
+func TestConfigValidate_IndexServer(t *testing.T) {
+	dir := t.TempDir()
+	base := `epoch: 0
+version: 1
+genesis:
+  hash: 5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d
+  creation_time: 1584368940
+data:
+  car:
+    uri: /tmp/epoch-0.car
+indexes:
+  slot_to_blocktime:
+    uri: /tmp/epoch-0-slot-to-blocktime.index
+`
+	config := writeTestConfig(t, dir, "ok.yml", base+`  server:
+    uri: http://localhost:8890
+`)
+	require.NoError(t, config.Validate())
+	require.True(t, config.UsesIndexServer())
+
+	config = writeTestConfig(t, dir, "local.yml", base+`  server:
+    uri: /tmp/index-server
+`)
+	require.ErrorContains(t, config.Validate(), "indexes.server.uri must be a remote web URI")
+
+	config = writeTestConfig(t, dir, "both.yml", base+`  server:
+    uri: http://localhost:8890
+  slot_to_cid:
+    uri: /tmp/epoch-0-slot-to-cid.index
+`)
+	require.ErrorContains(t, config.Validate(), "indexes.slot_to_cid.uri cannot be set when indexes.server.uri is set")
+}
@@ -16,10 +16,13 @@ import (
 	"github.com/rpcpool/yellowstone-faithful/indexes"
 	"github.com/rpcpool/yellowstone-faithful/indexmeta"
 	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
 	"k8s.io/klog/v2"
 )
 
 // CreateIndex_sig2cid creates an index file that maps transaction signatures to CIDs.
+// With withStatus, the v2 index is created, with the slot and status of the transactions.
 func CreateIndex_sig2cid(
 	ctx context.Context,
 	epoch uint64,
@@ -27,6 +30,7 @@ func CreateIndex_sig2cid(
 	tmpDir string,
 	carPath string,
 	indexDir string,
+	withStatus bool,
 ) (string, error) {
 	// Check if the CAR file exists:
 	exists, err := fileExists(carPath)
@@ -68,7 +72,11 @@ func CreateIndex_sig2cid(
 
 	klog.Infof("Creating builder with %d items", numItems)
 
-	sig2c, err := indexes.NewWriter_SigToCid(
+	newWriter := indexes.NewWriter_SigToCid
+	if withStatus {
+		newWriter = indexes.NewWriter_SigToCidV2
+	}
+	sig2c, err := newWriter(
 		epoch,
 		rootCid,
 		network,
@@ -90,30 +98,58 @@ func CreateIndex_sig2cid(
 
 	// Iterate over all Transactions in the CAR file and put them into the index,
 	// using the transaction signature as the key and the CID as the value.
-	err = FindTransactions(
-		ctx,
-		dr,
-		func(c cid.Cid, txNode *ipldbindcode.Transaction) error {
-			sig, err := readFirstSignature(txNode.Data.Bytes())
-			if err != nil {
-				return fmt.Errorf("failed to read signature: %w", err)
-			}
-
+	statuses := newSigStatusTracker()
+	put := func(c cid.Cid, txNode *ipldbindcode.Transaction) error {
+		sig, err := readFirstSignature(txNode.Data.Bytes())
+		if err != nil {
+			return fmt.Errorf("failed to read signature: %w", err)
+		}
+
+		if withStatus {
+			err = sig2c.PutWithStatus(sig, c, statuses.status(txNode))
+		} else {
 			err = sig2c.Put(sig, c)
-			if err != nil {
-				return fmt.Errorf("failed to put cid to offset: %w", err)
-			}
-
-			numItemsIndexed++
-			if numItemsIndexed%100_000 == 0 {
-				printToStderr(".")
-			}
-			return nil
-		})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to put cid to offset: %w", err)
+		}
+
+		numItemsIndexed++
+		if numItemsIndexed%100_000 == 0 {
+			printToStderr(".")
+		}
+		return nil
+	}
+	if withStatus {
+		// The status of a transaction is in its metadata, whose data frames come before it.
+		err = FindAny(
+			ctx,
+			dr,
+			func(c cid.Cid, node any) error {
+				switch node := node.(type) {
+				case *ipldbindcode.DataFrame:
+					statuses.addDataFrame(c, node)
+				case *ipldbindcode.Transaction:
+					return put(c, node)
+				}
+				return nil
+			})
+	} else {
+		err = FindTransactions(ctx, dr, put)
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to index; error while iterating over blocks: %w", err)
 	}
 
+	klog.Infof("Hashing CAR file...")
+	carSha256, err := carFileSha256(carPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash CAR file: %w", err)
+	}
+	if err = sig2c.SetProvenance(newIndexProvenance(carSha256)); err != nil {
+		return "", fmt.Errorf("failed to set index provenance: %w", err)
+	}
+
 	klog.Infof("Sealing index...")
 	if err = sig2c.Seal(ctx, indexDir); err != nil {
 		return "", fmt.Errorf("failed to seal index: %w", err)
@@ -308,3 +344,58 @@ func readFirstSignature(buf []byte) (solana.Signature, error) {
 	}
 	return sig, nil
 }
+
+// sigStatusTracker computes the status of the transactions of a CAR read in order: the data
+// frames of the metadata of a transaction are written before the transaction.
+type sigStatusTracker struct {
+	dataFrames map[cid.Cid]*ipldbindcode.DataFrame
+}
+
+func newSigStatusTracker() *sigStatusTracker {
+	return &sigStatusTracker{
+		dataFrames: make(map[cid.Cid]*ipldbindcode.DataFrame),
+	}
+}
+
+func (t *sigStatusTracker) addDataFrame(c cid.Cid, frame *ipldbindcode.DataFrame) {
+	t.dataFrames[c] = frame
+}
+
+// status returns the status of the transaction, and forgets the data frames read before it.
+func (t *sigStatusTracker) status(txNode *ipldbindcode.Transaction) indexes.SigStatus {
+	getDataFrame := func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error) {
+		if frame, ok := t.dataFrames[wantedCid]; ok {
+			return frame, nil
+		}
+		return nil, fmt.Errorf("data frame %s not found before the transaction", wantedCid)
+	}
+	status := indexes.SigStatus{Slot: uint64(txNode.Slot)}
+	if failed, ok := transactionFailed(txNode, getDataFrame); !ok {
+		status.Flags |= indexes.SigStatusMetaMissing
+	} else if failed {
+		status.Flags |= indexes.SigStatusFailed
+	}
+	clear(t.dataFrames)
+	return status
+}
+
+// transactionFailed returns whether the transaction failed, and false if its metadata is
+// missing or can't be parsed.
+func transactionFailed(
+	txNode *ipldbindcode.Transaction,
+	dataFrameGetter func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error),
+) (failed bool, ok bool) {
+	metaChunks, err := tooling.LoadChunksFromDataFrames(&txNode.Metadata, dataFrameGetter)
+	if err != nil || metaChunks.Len() == 0 {
+		return false, false
+	}
+	uncompressedMeta, err := tooling.DecompressZstdChunks(metaChunks)
+	if err != nil {
+		return false, false
+	}
+	meta, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(uncompressedMeta)
+	if err != nil {
+		return false, false
+	}
+	return meta.Failed(), true
+}
@@ -54,7 +54,7 @@ func CreateIndex_slot2cid(
 		return "", fmt.Errorf("failed to create tmp dir: %w", err)
 	}
 
-	numItems := uint64(slottools.EpochLen)
+	numItems := slottools.DefaultEpochSchedule().SlotsInEpoch(epoch)
 
 	klog.Infof("Creating builder with %d items", numItems)
 	sl2c, err := indexes.NewWriter_SlotToCid(
@@ -102,6 +102,15 @@ func CreateIndex_slot2cid(
 
 	// Use the car file name and root CID to name the index file:
 
+	klog.Infof("Hashing CAR file...")
+	carSha256, err := carFileSha256(carPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash CAR file: %w", err)
+	}
+	if err = sl2c.SetProvenance(newIndexProvenance(carSha256)); err != nil {
+		return "", fmt.Errorf("failed to set index provenance: %w", err)
+	}
+
 	klog.Infof("Sealing index...")
 	if err = sl2c.Seal(ctx, indexDir); err != nil {
 		return "", fmt.Errorf("failed to seal index: %w", err)
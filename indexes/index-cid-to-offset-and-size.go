@@ -89,12 +89,30 @@ func (w *CidToOffsetAndSize_Writer) Put(cid_ cid.Cid, offset uint64, size uint64
 	return w.index.Insert(key, value)
 }
 
+// SetProvenance records the provenance of the index in its metadata.
+// It must be called before Seal.
+func (w *CidToOffsetAndSize_Writer) SetProvenance(p Provenance) error {
+	if w.sealed {
+		return fmt.Errorf("already sealed")
+	}
+	return setProvenance(w.index, w.meta, p)
+}
+
 func (w *CidToOffsetAndSize_Writer) Seal(ctx context.Context, dstDir string) error {
 	if w.sealed {
 		return fmt.Errorf("already sealed")
 	}
 
 	filepath := filepath.Join(dstDir, formatFilename_CidToOffsetAndSize(w.meta.Epoch, w.meta.RootCid, w.meta.Network))
+	return w.SealWithFilename(ctx, filepath)
+}
+
+func (w *CidToOffsetAndSize_Writer) SealWithFilename(ctx context.Context, dstFilepath string) error {
+	if w.sealed {
+		return fmt.Errorf("already sealed")
+	}
+
+	filepath := dstFilepath
 	w.finalPath = filepath
 
 	file, err := os.Create(filepath)
@@ -190,3 +208,8 @@ func (r *CidToOffsetAndSize_Reader) Meta() *Metadata {
 func (r *CidToOffsetAndSize_Reader) Prefetch(b bool) {
 	r.index.Prefetch(b)
 }
+
+// Warmup pre-touches the index (see compactindexsized.DB.Warmup), and returns the number of bytes read.
+func (r *CidToOffsetAndSize_Reader) Warmup(full bool) (int64, error) {
+	return r.index.Warmup(full)
+}
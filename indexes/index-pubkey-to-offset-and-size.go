@@ -193,3 +193,8 @@ func (r *PubkeyToOffsetAndSize_Reader) Meta() *Metadata {
 func (r *PubkeyToOffsetAndSize_Reader) Prefetch(b bool) {
 	r.index.Prefetch(b)
 }
+
+// Warmup pre-touches the index (see compactindexsized.DB.Warmup), and returns the number of bytes read.
+func (r *PubkeyToOffsetAndSize_Reader) Warmup(full bool) (int64, error) {
+	return r.index.Warmup(full)
+}
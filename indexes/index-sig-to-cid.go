@@ -14,18 +14,64 @@ import (
 )
 
 type SigToCid_Writer struct {
-	sealed    bool
-	tmpDir    string
-	finalPath string
-	meta      *Metadata
-	index     *compactindexsized.Builder
+	sealed     bool
+	tmpDir     string
+	finalPath  string
+	meta       *Metadata
+	index      *compactindexsized.Builder
+	withStatus bool
 }
 
 const (
 	// 36 bytes for cid
 	IndexValueSize_SigToCid = 36
+	// 8 bytes for the slot, 1 byte for the status flags.
+	IndexValueSize_SigStatus = 8 + 1
+	// The v2 index stores the slot and status flags of the transaction inline after its cid,
+	// to answer status lookups without reading the transaction.
+	IndexValueSize_SigToCidV2 = IndexValueSize_SigToCid + IndexValueSize_SigStatus
 )
 
+// The status flags of a transaction.
+const (
+	// SigStatusFailed is set for the transactions that failed.
+	SigStatusFailed byte = 1 << iota
+	// SigStatusMetaMissing is set for the transactions without metadata in the CAR,
+	// whose status is unknown.
+	SigStatusMetaMissing
+)
+
+// SigStatus is the status of a transaction in the sig-to-cid v2 index.
+type SigStatus struct {
+	Slot  uint64
+	Flags byte
+}
+
+// Failed returns true if the transaction failed.
+func (s SigStatus) Failed() bool {
+	return s.Flags&SigStatusFailed != 0
+}
+
+// MetaMissing returns true if the transaction has no metadata in the CAR.
+func (s SigStatus) MetaMissing() bool {
+	return s.Flags&SigStatusMetaMissing != 0
+}
+
+// Bytes returns the encoding of the status in the values of the sig-to-cid v2 index.
+func (s SigStatus) Bytes() []byte {
+	return append(Uint64tob(s.Slot), s.Flags)
+}
+
+func sigStatusFromBytes(buf []byte) (SigStatus, error) {
+	if len(buf) != IndexValueSize_SigStatus {
+		return SigStatus{}, fmt.Errorf("invalid status size: expected %d, got %d", IndexValueSize_SigStatus, len(buf))
+	}
+	return SigStatus{
+		Slot:  BtoUint64(buf[:8]),
+		Flags: buf[8],
+	}, nil
+}
+
 func formatFilename_SigToCid(epoch uint64, rootCid cid.Cid, network Network) string {
 	return fmt.Sprintf(
 		"epoch-%d-%s-%s-%s",
@@ -44,6 +90,29 @@ func NewWriter_SigToCid(
 	network Network,
 	tmpDir string, // Where to put the temporary index files; WILL BE DELETED.
 	numItems uint64,
+) (*SigToCid_Writer, error) {
+	return newWriter_SigToCid(epoch, rootCid, network, tmpDir, numItems, false)
+}
+
+// NewWriter_SigToCidV2 creates a writer of the v2 sig-to-cid index, whose values also have the
+// slot and status of the transactions (see PutWithStatus).
+func NewWriter_SigToCidV2(
+	epoch uint64,
+	rootCid cid.Cid,
+	network Network,
+	tmpDir string, // Where to put the temporary index files; WILL BE DELETED.
+	numItems uint64,
+) (*SigToCid_Writer, error) {
+	return newWriter_SigToCid(epoch, rootCid, network, tmpDir, numItems, true)
+}
+
+func newWriter_SigToCid(
+	epoch uint64,
+	rootCid cid.Cid,
+	network Network,
+	tmpDir string,
+	numItems uint64,
+	withStatus bool,
 ) (*SigToCid_Writer, error) {
 	if !IsValidNetwork(network) {
 		return nil, ErrInvalidNetwork
@@ -51,14 +120,24 @@ func NewWriter_SigToCid(
 	if rootCid == cid.Undef {
 		return nil, ErrInvalidRootCid
 	}
+	valueSize := IndexValueSize_SigToCid
+	if withStatus {
+		valueSize = IndexValueSize_SigToCidV2
+	}
 	index, err := compactindexsized.NewBuilderSized(
 		tmpDir,
 		uint(numItems),
-		IndexValueSize_SigToCid,
+		uint(valueSize),
 	)
 	if err != nil {
 		return nil, err
 	}
+	// Most lookups of a signature (e.g. getTransaction across many epochs) are for
+	// epochs that don't have it: the bloom filter answers those with a single small read.
+	if err := index.EnableBloomFilter(compactindexsized.DefaultBloomFilterBitsPerKey); err != nil {
+		index.Close()
+		return nil, err
+	}
 	meta := &Metadata{
 		Epoch:     epoch,
 		RootCid:   rootCid,
@@ -66,9 +145,10 @@ func NewWriter_SigToCid(
 		IndexKind: Kind_SigToCid,
 	}
 	return &SigToCid_Writer{
-		tmpDir: tmpDir,
-		meta:   meta,
-		index:  index,
+		tmpDir:     tmpDir,
+		meta:       meta,
+		index:      index,
+		withStatus: withStatus,
 	}, nil
 }
 
@@ -76,6 +156,9 @@ func (w *SigToCid_Writer) Put(sig solana.Signature, cid_ cid.Cid) error {
 	if w.sealed {
 		return fmt.Errorf("cannot put to sealed writer")
 	}
+	if w.withStatus {
+		return fmt.Errorf("the v2 sig-to-cid index needs the status of the transactions; use PutWithStatus")
+	}
 	if cid_ == cid.Undef {
 		return fmt.Errorf("cid is undefined")
 	}
@@ -84,6 +167,40 @@ func (w *SigToCid_Writer) Put(sig solana.Signature, cid_ cid.Cid) error {
 	return w.index.Insert(key, value)
 }
 
+// PutWithStatus puts the cid of a transaction with its slot and status (v2 index only).
+func (w *SigToCid_Writer) PutWithStatus(sig solana.Signature, cid_ cid.Cid, status SigStatus) error {
+	if w.sealed {
+		return fmt.Errorf("cannot put to sealed writer")
+	}
+	if !w.withStatus {
+		return fmt.Errorf("only the v2 sig-to-cid index has the status of the transactions")
+	}
+	if cid_ == cid.Undef {
+		return fmt.Errorf("cid is undefined")
+	}
+	cidBytes := cid_.Bytes()
+	if len(cidBytes) != IndexValueSize_SigToCid {
+		return fmt.Errorf("invalid cid size: expected %d, got %d", IndexValueSize_SigToCid, len(cidBytes))
+	}
+	key := sig[:]
+	value := append(cidBytes, status.Bytes()...)
+	return w.index.Insert(key, value)
+}
+
+// HasStatus returns true if the writer writes the v2 index.
+func (w *SigToCid_Writer) HasStatus() bool {
+	return w.withStatus
+}
+
+// SetProvenance records the provenance of the index in its metadata.
+// It must be called before Seal.
+func (w *SigToCid_Writer) SetProvenance(p Provenance) error {
+	if w.sealed {
+		return fmt.Errorf("already sealed")
+	}
+	return setProvenance(w.index, w.meta, p)
+}
+
 func (w *SigToCid_Writer) Seal(ctx context.Context, dstDir string) error {
 	if w.sealed {
 		return fmt.Errorf("already sealed")
@@ -209,13 +326,41 @@ func (r *SigToCid_Reader) Get(sig solana.Signature) (cid.Cid, error) {
 	if err != nil {
 		return cid.Undef, err
 	}
-	_, c, err := cid.CidFromBytes(value[:])
+	_, c, err := cid.CidFromBytes(value[:IndexValueSize_SigToCid])
 	if err != nil {
 		return cid.Undef, err
 	}
 	return c, nil
 }
 
+// HasStatus returns true if the index is a v2 index, with the slot and status of the transactions.
+func (r *SigToCid_Reader) HasStatus() bool {
+	return !r.IsDeprecatedOldVersion() && r.index.GetValueSize() == IndexValueSize_SigToCidV2
+}
+
+// GetWithStatus returns the cid of the transaction with its slot and status (v2 index only; see HasStatus).
+func (r *SigToCid_Reader) GetWithStatus(sig solana.Signature) (cid.Cid, SigStatus, error) {
+	if sig.IsZero() {
+		return cid.Undef, SigStatus{}, fmt.Errorf("sig is undefined")
+	}
+	if !r.HasStatus() {
+		return cid.Undef, SigStatus{}, fmt.Errorf("the sig-to-cid index has no status (v1)")
+	}
+	value, err := r.index.Lookup(sig[:])
+	if err != nil {
+		return cid.Undef, SigStatus{}, err
+	}
+	_, c, err := cid.CidFromBytes(value[:IndexValueSize_SigToCid])
+	if err != nil {
+		return cid.Undef, SigStatus{}, err
+	}
+	status, err := sigStatusFromBytes(value[IndexValueSize_SigToCid:])
+	if err != nil {
+		return cid.Undef, SigStatus{}, err
+	}
+	return c, status, nil
+}
+
 func (r *SigToCid_Reader) Close() error {
 	return r.file.Close()
 }
@@ -232,3 +377,12 @@ func (r *SigToCid_Reader) Prefetch(b bool) {
 	}
 	r.index.Prefetch(b)
 }
+
+// Warmup pre-touches the index (see compactindexsized.DB.Warmup), and returns the number of bytes read.
+// The deprecated index version is not warmed up.
+func (r *SigToCid_Reader) Warmup(full bool) (int64, error) {
+	if r.IsDeprecatedOldVersion() {
+		return 0, nil
+	}
+	return r.index.Warmup(full)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
 	"github.com/rpcpool/yellowstone-faithful/indexes"
 	"github.com/stretchr/testify/require"
 )
@@ -108,6 +109,10 @@ func TestSigToCid(t *testing.T) {
 		cid_, err = reader.Get(sig3)
 		require.NoError(t, err)
 		require.Equal(t, cid3_, cid_)
+
+		// absent signatures are not found.
+		_, err = reader.Get(newRandomSignature())
+		require.True(t, compactindexsized.IsNotFound(err))
 	}
 
 	// check metadata
@@ -122,6 +127,81 @@ func TestSigToCid(t *testing.T) {
 	}
 }
 
+func TestSigToCidV2(t *testing.T) {
+	epoch := uint64(123)
+	rootCid, err := cid.Parse("bafyreids2hw6eynl4vag3cdp535sxz6zp6tedhuv6xu3k3rze3fskqy4yy")
+	require.NoError(t, err)
+	cid1_, err := cid.Parse("bafyreibwvjchy4qq6tqeqg4olawpzs3cphr7nqp5gz2ch5bnttt2ajg6p4")
+	require.NoError(t, err)
+	cid2_, err := cid.Parse("bafyreibqlzq4vrezlbgn7qqgz36tx5itaelyxw4v2xyjho5fqqlrslf2vq")
+	require.NoError(t, err)
+	numItems := uint64(1000)
+
+	writer, err := indexes.NewWriter_SigToCidV2(epoch, rootCid, indexes.NetworkMainnet, "", numItems)
+	require.NoError(t, err)
+	require.True(t, writer.HasStatus())
+	// the v2 index needs the status of every transaction.
+	require.Error(t, writer.Put(newRandomSignature(), cid1_))
+
+	ok := newRandomSignature()
+	require.NoError(t, writer.PutWithStatus(ok, cid1_, indexes.SigStatus{Slot: 53_136_000}))
+	failed := newRandomSignature()
+	require.NoError(t, writer.PutWithStatus(failed, cid2_, indexes.SigStatus{Slot: 53_567_999, Flags: indexes.SigStatusFailed}))
+	noMeta := newRandomSignature()
+	require.NoError(t, writer.PutWithStatus(noMeta, cid1_, indexes.SigStatus{Slot: 53_136_001, Flags: indexes.SigStatusMetaMissing}))
+	for i := uint64(0); i < numItems-3; i++ {
+		require.NoError(t, writer.PutWithStatus(newRandomSignature(), cid1_, indexes.SigStatus{Slot: 53_136_000 + i}))
+	}
+	require.NoError(t, writer.Seal(context.TODO(), t.TempDir()))
+	require.NoError(t, writer.Close())
+
+	reader, err := indexes.Open_SigToCid(writer.GetFilepath())
+	require.NoError(t, err)
+	defer reader.Close()
+	require.True(t, reader.HasStatus())
+	require.Equal(t, indexes.Kind_SigToCid, reader.Meta().IndexKind)
+
+	// Get works with both versions.
+	got, err := reader.Get(failed)
+	require.NoError(t, err)
+	require.Equal(t, cid2_, got)
+
+	got, status, err := reader.GetWithStatus(ok)
+	require.NoError(t, err)
+	require.Equal(t, cid1_, got)
+	require.Equal(t, uint64(53_136_000), status.Slot)
+	require.False(t, status.Failed())
+
+	got, status, err = reader.GetWithStatus(failed)
+	require.NoError(t, err)
+	require.Equal(t, cid2_, got)
+	require.Equal(t, uint64(53_567_999), status.Slot)
+	require.True(t, status.Failed())
+
+	_, status, err = reader.GetWithStatus(noMeta)
+	require.NoError(t, err)
+	require.True(t, status.MetaMissing())
+	require.False(t, status.Failed())
+
+	_, _, err = reader.GetWithStatus(newRandomSignature())
+	require.True(t, compactindexsized.IsNotFound(err))
+
+	// the v1 index has no status.
+	writerV1, err := indexes.NewWriter_SigToCid(epoch, rootCid, indexes.NetworkMainnet, "", 1)
+	require.NoError(t, err)
+	require.False(t, writerV1.HasStatus())
+	require.Error(t, writerV1.PutWithStatus(ok, cid1_, indexes.SigStatus{Slot: 53_136_000}))
+	require.NoError(t, writerV1.Put(ok, cid1_))
+	require.NoError(t, writerV1.Seal(context.TODO(), t.TempDir()))
+	require.NoError(t, writerV1.Close())
+	readerV1, err := indexes.Open_SigToCid(writerV1.GetFilepath())
+	require.NoError(t, err)
+	defer readerV1.Close()
+	require.False(t, readerV1.HasStatus())
+	_, _, err = readerV1.GetWithStatus(ok)
+	require.Error(t, err)
+}
+
 func newRandomSignature() solana.Signature {
 	var sig solana.Signature
 	rand.Read(sig[:])
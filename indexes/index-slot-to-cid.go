@@ -86,6 +86,15 @@ func (w *SlotToCid_Writer) Put(slot uint64, cid_ cid.Cid) error {
 	return w.index.Insert(key, value)
 }
 
+// SetProvenance records the provenance of the index in its metadata.
+// It must be called before Seal.
+func (w *SlotToCid_Writer) SetProvenance(p Provenance) error {
+	if w.sealed {
+		return fmt.Errorf("already sealed")
+	}
+	return setProvenance(w.index, w.meta, p)
+}
+
 func (w *SlotToCid_Writer) Seal(ctx context.Context, dstDir string) error {
 	if w.sealed {
 		return fmt.Errorf("already sealed")
@@ -223,3 +232,12 @@ func (r *SlotToCid_Reader) Prefetch(b bool) {
 	}
 	r.index.Prefetch(b)
 }
+
+// Warmup pre-touches the index (see compactindexsized.DB.Warmup), and returns the number of bytes read.
+// The deprecated index version is not warmed up.
+func (r *SlotToCid_Reader) Warmup(full bool) (int64, error) {
+	if r.IsDeprecatedOldVersion() {
+		return 0, nil
+	}
+	return r.index.Warmup(full)
+}
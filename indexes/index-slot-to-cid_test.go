@@ -1,11 +1,13 @@
 package indexes_test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/rpcpool/yellowstone-faithful/indexes"
@@ -55,6 +57,13 @@ func TestSlotToCid(t *testing.T) {
 		require.Error(t, writer.Close())
 	}
 
+	provenance := indexes.Provenance{
+		CarSha256:        bytes.Repeat([]byte{0xab}, 32),
+		GeneratorVersion: "faithful-cli test",
+		CreatedAt:        time.Unix(1700000000, 0).UTC(),
+	}
+	require.NoError(t, writer.SetProvenance(provenance))
+
 	// seal the index
 	require.NoError(t, writer.Seal(context.TODO(), dstDir))
 	t.Log(writer.GetFilepath())
@@ -113,5 +122,6 @@ func TestSlotToCid(t *testing.T) {
 		require.Equal(t, rootCid, metadata.RootCid)
 		require.Equal(t, indexes.NetworkMainnet, metadata.Network)
 		require.Equal(t, indexes.Kind_SlotToCid, metadata.IndexKind)
+		require.Equal(t, provenance, metadata.Provenance)
 	}
 }
@@ -3,6 +3,7 @@ package indexes
 import (
 	"bytes"
 	"fmt"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
@@ -14,6 +15,62 @@ type Metadata struct {
 	RootCid   cid.Cid
 	Network   Network
 	IndexKind []byte
+	// Provenance is only set for indexes that record it.
+	Provenance Provenance
+}
+
+// Provenance describes where an index comes from.
+type Provenance struct {
+	// CarSha256 is the SHA256 of the CAR file the index was generated from.
+	CarSha256 []byte
+	// GeneratorVersion is the version of the program that generated the index.
+	GeneratorVersion string
+	CreatedAt        time.Time
+}
+
+// AddTo records the provenance in the metadata, replacing any previous provenance.
+func (p Provenance) AddTo(meta *indexmeta.Meta) error {
+	meta.Remove(indexmeta.MetadataKey_CarSha256)
+	meta.Remove(indexmeta.MetadataKey_GeneratorVersion)
+	meta.Remove(indexmeta.MetadataKey_CreatedAt)
+	if len(p.CarSha256) > 0 {
+		if err := meta.Add(indexmeta.MetadataKey_CarSha256, p.CarSha256); err != nil {
+			return err
+		}
+	}
+	if p.GeneratorVersion != "" {
+		if err := meta.AddString(indexmeta.MetadataKey_GeneratorVersion, p.GeneratorVersion); err != nil {
+			return err
+		}
+	}
+	if !p.CreatedAt.IsZero() {
+		if err := meta.AddUint64(indexmeta.MetadataKey_CreatedAt, uint64(p.CreatedAt.Unix())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProvenanceFromMeta reads the provenance recorded in the metadata; the fields
+// that are not recorded are left empty.
+func ProvenanceFromMeta(meta *indexmeta.Meta) Provenance {
+	var p Provenance
+	if sum, ok := meta.Get(indexmeta.MetadataKey_CarSha256); ok {
+		p.CarSha256 = sum
+	}
+	p.GeneratorVersion, _ = meta.GetString(indexmeta.MetadataKey_GeneratorVersion)
+	if createdAt, ok := meta.GetUint64(indexmeta.MetadataKey_CreatedAt); ok {
+		p.CreatedAt = time.Unix(int64(createdAt), 0).UTC()
+	}
+	return p
+}
+
+func setProvenance(index *compactindexsized.Builder, metadata *Metadata, p Provenance) error {
+	if err := p.AddTo(index.Metadata()); err != nil {
+		return err
+	}
+	metadata.Provenance = p
+	return nil
 }
 
 // Assert Epoch is x.
@@ -119,5 +176,7 @@ func getDefaultMetadata(index *compactindexsized.DB) (*Metadata, error) {
 		return nil, fmt.Errorf("metadata.network is empty")
 	}
 
+	out.Provenance = ProvenanceFromMeta(meta)
+
 	return out, nil
 }
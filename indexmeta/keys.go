@@ -6,3 +6,10 @@ var (
 	MetadataKey_RootCid = []byte("rootCid")
 	MetadataKey_Network = []byte("network")
 )
+
+// Provenance of an index (optional; older indexes don't have them).
+var (
+	MetadataKey_CarSha256        = []byte("carSha256")
+	MetadataKey_GeneratorVersion = []byte("generatorVersion")
+	MetadataKey_CreatedAt        = []byte("createdAt")
+)
@@ -0,0 +1,232 @@
+// Package ipfsgateway downloads the files of a UnixFS directory from an IPFS trustless gateway
+// (https://specs.ipfs.tech/http-gateways/trustless-gateway/): the gateway sends the blocks as a
+// CAR, and every block is checked against its CID before its data is used.
+package ipfsgateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-unixfsnode/data"
+	"github.com/ipld/go-car/v2"
+	dagpb "github.com/ipld/go-codec-dagpb"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+)
+
+// carContentType asks for the blocks in depth-first order, with the duplicates: the blocks of the
+// file can then be written as they arrive.
+const carContentType = "application/vnd.ipld.car;version=1;order=dfs;dups=y"
+
+// ErrUnexpectedBlock is returned when the gateway sends a block that is not the next one of the file.
+var ErrUnexpectedBlock = errors.New("unexpected block")
+
+// FetchFile writes the content of the file name of the directory root to w, from offset.
+func FetchFile(ctx context.Context, client *http.Client, gateway string, root cid.Cid, name string, offset int64, w io.Writer) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	u := strings.TrimSuffix(gateway, "/") + "/ipfs/" + root.String() + "/" + url.PathEscape(name) + "?dag-scope=entity"
+	if offset > 0 {
+		u += fmt.Sprintf("&entity-bytes=%d:*", offset)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", carContentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{URL: u, Code: resp.StatusCode}
+	}
+	blocks, err := car.NewBlockReader(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", u, err)
+	}
+	next := func(want cid.Cid) ([]byte, error) {
+		if want.Prefix().MhType == multihash.IDENTITY {
+			// the data of an identity CID is in the CID itself: the gateway doesn't send it.
+			decoded, err := multihash.Decode(want.Hash())
+			if err != nil {
+				return nil, err
+			}
+			return decoded.Digest, nil
+		}
+		block, err := blocks.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("%s: missing block %s", u, want)
+			}
+			return nil, err
+		}
+		if !block.Cid().Equals(want) {
+			return nil, fmt.Errorf("%w %s, expected %s", ErrUnexpectedBlock, block.Cid(), want)
+		}
+		return block.RawData(), nil
+	}
+
+	dir, err := next(root)
+	if err != nil {
+		return err
+	}
+	fileCid, err := directoryEntry(root, dir, name)
+	if err != nil {
+		return err
+	}
+	return writeFile(fileCid, offset, next, w)
+}
+
+// StatusError is the status of a response that is not 200.
+type StatusError struct {
+	URL  string
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.URL, e.Code, http.StatusText(e.Code))
+}
+
+// directoryEntry returns the CID of the file name in the (not sharded) directory.
+func directoryEntry(root cid.Cid, block []byte, name string) (cid.Cid, error) {
+	if multicodec.Code(root.Prefix().Codec) != multicodec.DagPb {
+		return cid.Undef, fmt.Errorf("%s is not a directory", root)
+	}
+	node, err := decodePBNode(block)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if !node.FieldData().Exists() {
+		return cid.Undef, fmt.Errorf("%s is not a directory", root)
+	}
+	ufs, err := data.DecodeUnixFSData(node.FieldData().Must().Bytes())
+	if err != nil {
+		return cid.Undef, err
+	}
+	if ufs.FieldDataType().Int() != data.Data_Directory {
+		return cid.Undef, fmt.Errorf("%s is a %s, not a directory", root, data.DataTypeNames[ufs.FieldDataType().Int()])
+	}
+	links := node.FieldLinks().Iterator()
+	for !links.Done() {
+		_, link := links.Next()
+		if link.FieldName().Exists() && link.FieldName().Must().String() == name {
+			return link.FieldHash().Link().(cidlink.Link).Cid, nil
+		}
+	}
+	return cid.Undef, fmt.Errorf("%s has no file %q", root, name)
+}
+
+// fileNode is a node of the DAG of a file, and the range of the file it covers.
+type fileNode struct {
+	cid   cid.Cid
+	start int64
+}
+
+// writeFile walks the DAG of the file depth first, in the order of the blocks sent by the
+// gateway, and writes the data from offset. The nodes that end before offset are skipped,
+// as the gateway doesn't send them.
+func writeFile(root cid.Cid, offset int64, next func(cid.Cid) ([]byte, error), w io.Writer) error {
+	write := func(start int64, b []byte) error {
+		if end := start + int64(len(b)); end <= offset {
+			return nil
+		}
+		if start < offset {
+			b = b[offset-start:]
+		}
+		_, err := w.Write(b)
+		return err
+	}
+	stack := []fileNode{{cid: root}}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		block, err := next(n.cid)
+		if err != nil {
+			return err
+		}
+		switch multicodec.Code(n.cid.Prefix().Codec) {
+		case multicodec.Raw:
+			if err := write(n.start, block); err != nil {
+				return err
+			}
+		case multicodec.DagPb:
+			children, inline, err := decodeFileNode(n.cid, block)
+			if err != nil {
+				return err
+			}
+			if err := write(n.start, inline); err != nil {
+				return err
+			}
+			start := n.start + int64(len(inline))
+			var keep []fileNode
+			for _, child := range children {
+				if start+child.size > offset {
+					keep = append(keep, fileNode{cid: child.cid, start: start})
+				}
+				start += child.size
+			}
+			for i := len(keep) - 1; i >= 0; i-- {
+				stack = append(stack, keep[i])
+			}
+		default:
+			return fmt.Errorf("unsupported codec of %s", n.cid)
+		}
+	}
+	return nil
+}
+
+type fileChild struct {
+	cid  cid.Cid
+	size int64
+}
+
+// decodeFileNode returns the children of a UnixFS file node with their sizes, and its inline data.
+func decodeFileNode(c cid.Cid, block []byte) ([]fileChild, []byte, error) {
+	node, err := decodePBNode(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !node.FieldData().Exists() {
+		return nil, nil, fmt.Errorf("%s is not a UnixFS node", c)
+	}
+	ufs, err := data.DecodeUnixFSData(node.FieldData().Must().Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", c, err)
+	}
+	if t := ufs.FieldDataType().Int(); t != data.Data_File && t != data.Data_Raw {
+		return nil, nil, fmt.Errorf("%s is a %s, not a file", c, data.DataTypeNames[t])
+	}
+	var inline []byte
+	if ufs.FieldData().Exists() {
+		inline = ufs.FieldData().Must().Bytes()
+	}
+	if ufs.FieldBlockSizes().Length() != node.FieldLinks().Length() {
+		return nil, nil, fmt.Errorf("%s has %d links and %d block sizes", c, node.FieldLinks().Length(), ufs.FieldBlockSizes().Length())
+	}
+	var children []fileChild
+	links, sizes := node.FieldLinks().Iterator(), ufs.FieldBlockSizes().Iterator()
+	for !links.Done() {
+		_, link := links.Next()
+		_, size := sizes.Next()
+		children = append(children, fileChild{cid: link.FieldHash().Link().(cidlink.Link).Cid, size: size.Int()})
+	}
+	return children, inline, nil
+}
+
+func decodePBNode(block []byte) (dagpb.PBNode, error) {
+	nb := dagpb.Type.PBNode.NewBuilder()
+	if err := dagpb.DecodeBytes(nb, block); err != nil {
+		return nil, err
+	}
+	return nb.Build().(dagpb.PBNode), nil
+}
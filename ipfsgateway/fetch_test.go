@@ -0,0 +1,131 @@
+package ipfsgateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-unixfsnode/data/builder"
+	carv1 "github.com/ipld/go-car"
+	"github.com/ipld/go-car/util"
+	dagpb "github.com/ipld/go-codec-dagpb"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/storage/memstore"
+	"github.com/multiformats/go-multicodec"
+	"github.com/stretchr/testify/require"
+)
+
+// newGateway serves the blocks of the store like a trustless gateway: the directory block, then
+// the blocks of the file that cover the requested range, depth first.
+func newGateway(t *testing.T, store *memstore.Store, corrupt cid.Cid) *httptest.Server {
+	get := func(c cid.Cid) []byte {
+		block, err := store.Get(context.Background(), cidlink.Link{Cid: c}.Binary())
+		require.NoError(t, err)
+		if c.Equals(corrupt) {
+			block = append([]byte{}, block...)
+			block[len(block)-1]++
+		}
+		return block
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, carContentType, r.Header.Get("Accept"))
+		require.Equal(t, "entity", r.URL.Query().Get("dag-scope"))
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/ipfs/"), "/")
+		root, err := cid.Decode(parts[0])
+		require.NoError(t, err)
+		var offset int64
+		if entityBytes := r.URL.Query().Get("entity-bytes"); entityBytes != "" {
+			offset, err = strconv.ParseInt(strings.TrimSuffix(entityBytes, ":*"), 10, 64)
+			require.NoError(t, err)
+		}
+		dir := get(root)
+		fileCid, err := directoryEntry(root, dir, parts[1])
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		require.NoError(t, carv1.WriteHeader(&carv1.CarHeader{Roots: []cid.Cid{root}, Version: 1}, w))
+		require.NoError(t, util.LdWrite(w, root.Bytes(), dir))
+		var walk func(c cid.Cid, start int64)
+		walk = func(c cid.Cid, start int64) {
+			block := get(c)
+			require.NoError(t, util.LdWrite(w, c.Bytes(), block))
+			if multicodec.Code(c.Prefix().Codec) != multicodec.DagPb {
+				return
+			}
+			children, inline, err := decodeFileNode(c, block)
+			require.NoError(t, err)
+			start += int64(len(inline))
+			for _, child := range children {
+				if start+child.size > offset {
+					walk(child.cid, start)
+				}
+				start += child.size
+			}
+		}
+		walk(fileCid, 0)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchFile(t *testing.T) {
+	// small chunks, so that the file has more than one level of nodes.
+	content := make([]byte, 300_000)
+	_, err := rand.Read(content)
+	require.NoError(t, err)
+	small := []byte("small")
+	store := &memstore.Store{}
+	ls := cidlink.DefaultLinkSystem()
+	ls.SetReadStorage(store)
+	ls.SetWriteStorage(store)
+	fileLink, size, err := builder.BuildUnixFSFile(bytes.NewReader(content), "size-128", &ls)
+	require.NoError(t, err)
+	smallLink, smallSize, err := builder.BuildUnixFSFile(bytes.NewReader(small), "", &ls)
+	require.NoError(t, err)
+	carEntry, err := builder.BuildUnixFSDirectoryEntry("epoch-0.car", int64(size), fileLink)
+	require.NoError(t, err)
+	smallEntry, err := builder.BuildUnixFSDirectoryEntry("epoch-0.cid", int64(smallSize), smallLink)
+	require.NoError(t, err)
+	rootLink, _, err := builder.BuildUnixFSDirectory([]dagpb.PBLink{carEntry, smallEntry}, &ls)
+	require.NoError(t, err)
+	root := rootLink.(cidlink.Link).Cid
+	gateway := newGateway(t, store, cid.Undef)
+
+	for _, offset := range []int64{0, 1, 127, 128, 150_000, 299_999} {
+		var buf bytes.Buffer
+		require.NoError(t, FetchFile(context.Background(), nil, gateway.URL, root, "epoch-0.car", offset, &buf))
+		require.Equal(t, content[offset:], buf.Bytes(), offset)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, FetchFile(context.Background(), nil, gateway.URL+"/", root, "epoch-0.cid", 0, &buf))
+	require.Equal(t, small, buf.Bytes())
+
+	var status *StatusError
+	require.ErrorAs(t, FetchFile(context.Background(), nil, gateway.URL, root, "missing", 0, &buf), &status)
+	require.Equal(t, http.StatusNotFound, status.Code)
+
+	// a block that doesn't match its CID.
+	leaf := firstLeaf(t, store, fileLink.(cidlink.Link).Cid)
+	corrupted := newGateway(t, store, leaf)
+	err = FetchFile(context.Background(), nil, corrupted.URL, root, "epoch-0.car", 0, &buf)
+	require.ErrorContains(t, err, "mismatch in content integrity")
+}
+
+// firstLeaf returns the first raw block of the file.
+func firstLeaf(t *testing.T, store *memstore.Store, c cid.Cid) cid.Cid {
+	for multicodec.Code(c.Prefix().Codec) == multicodec.DagPb {
+		block, err := store.Get(context.Background(), cidlink.Link{Cid: c}.Binary())
+		require.NoError(t, err)
+		children, _, err := decodeFileNode(c, block)
+		require.NoError(t, err)
+		c = children[0].cid
+	}
+	return c
+}
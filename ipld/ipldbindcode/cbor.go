@@ -52,8 +52,17 @@ func decodeCborLinkListFromAny(maybeList any) (List__Link, error) {
 	return list, nil
 }
 
+// recoverMalformed turns a panic while decoding a malformed node (e.g. a field of the wrong type)
+// into an error, so that corrupt bytes on disk can't crash the process.
+func recoverMalformed(kind string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("malformed %s node: %v", kind, r)
+	}
+}
+
 // implement the BinaryUnmarshaler interface for EpochFast
-func (x *Epoch) UnmarshalCBOR(data []byte) error {
+func (x *Epoch) UnmarshalCBOR(data []byte) (err error) {
+	defer recoverMalformed("Epoch", &err)
 	dec := cbor.NewDecoder(bytes.NewReader(data))
 	var arr _array
 	if err := dec.Decode(&arr); err != nil {
@@ -88,7 +97,8 @@ func (x *Epoch) UnmarshalCBOR(data []byte) error {
 	return nil
 }
 
-func (x *Subset) UnmarshalCBOR(data []byte) error {
+func (x *Subset) UnmarshalCBOR(data []byte) (err error) {
+	defer recoverMalformed("Subset", &err)
 	dec := cbor.NewDecoder(bytes.NewReader(data))
 	var arr _array
 	if err := dec.Decode(&arr); err != nil {
@@ -129,7 +139,8 @@ func (x *Subset) UnmarshalCBOR(data []byte) error {
 	return nil
 }
 
-func (x *Block) UnmarshalCBOR(data []byte) error {
+func (x *Block) UnmarshalCBOR(data []byte) (err error) {
+	defer recoverMalformed("Block", &err)
 	dec := cbor.NewDecoder(bytes.NewReader(data))
 	var arr _array
 	if err := dec.Decode(&arr); err != nil {
@@ -252,7 +263,8 @@ func (x *Block) UnmarshalCBOR(data []byte) error {
 	return nil
 }
 
-func (x *Rewards) UnmarshalCBOR(data []byte) error {
+func (x *Rewards) UnmarshalCBOR(data []byte) (err error) {
+	defer recoverMalformed("Rewards", &err)
 	dec := cbor.NewDecoder(bytes.NewReader(data))
 	var arr _array
 	if err := dec.Decode(&arr); err != nil {
@@ -313,6 +325,15 @@ func (x *Rewards) UnmarshalCBOR(data []byte) error {
 		} else {
 			return fmt.Errorf("expected data to be present")
 		}
+		// sixth is the next []cid.Cid, represented as []any
+		if next, ok := dataArr.Get(5); ok {
+			next, err := decodeCborLinkListFromAny(next)
+			if err != nil {
+				return fmt.Errorf("failed to decode next: %w", err)
+			}
+			next_ptr := &next
+			d.Next = &next_ptr
+		}
 		x.Data = d
 	} else {
 		return fmt.Errorf("expected data to be present")
@@ -321,7 +342,8 @@ func (x *Rewards) UnmarshalCBOR(data []byte) error {
 	return nil
 }
 
-func (x *Entry) UnmarshalCBOR(data []byte) error {
+func (x *Entry) UnmarshalCBOR(data []byte) (err error) {
+	defer recoverMalformed("Entry", &err)
 	dec := cbor.NewDecoder(bytes.NewReader(data))
 	var arr _array
 	if err := dec.Decode(&arr); err != nil {
@@ -363,7 +385,8 @@ func (x *Entry) UnmarshalCBOR(data []byte) error {
 	return nil
 }
 
-func (x *Transaction) UnmarshalCBOR(data []byte) error {
+func (x *Transaction) UnmarshalCBOR(data []byte) (err error) {
+	defer recoverMalformed("Transaction", &err)
 	dec := cbor.NewDecoder(bytes.NewReader(data))
 	var arr _array
 	if err := dec.Decode(&arr); err != nil {
@@ -418,6 +441,15 @@ func (x *Transaction) UnmarshalCBOR(data []byte) error {
 		} else {
 			return fmt.Errorf("expected data to be present")
 		}
+		// sixth is the next []cid.Cid, represented as []any
+		if next, ok := dataArr.Get(5); ok {
+			next, err := decodeCborLinkListFromAny(next)
+			if err != nil {
+				return fmt.Errorf("failed to decode next: %w", err)
+			}
+			next_ptr := &next
+			d.Next = &next_ptr
+		}
 		x.Data = d
 	} else {
 		return fmt.Errorf("expected data to be present")
@@ -461,6 +493,15 @@ func (x *Transaction) UnmarshalCBOR(data []byte) error {
 		} else {
 			return fmt.Errorf("expected data to be present")
 		}
+		// sixth is the next []cid.Cid, represented as []any
+		if next, ok := metaArr.Get(5); ok {
+			next, err := decodeCborLinkListFromAny(next)
+			if err != nil {
+				return fmt.Errorf("failed to decode next: %w", err)
+			}
+			next_ptr := &next
+			m.Next = &next_ptr
+		}
 		x.Metadata = m
 	} else {
 		return fmt.Errorf("expected metadata to be present")
@@ -498,7 +539,8 @@ func tryInterfaceToUint64OrInt64(i interface{}) (int, error) {
 	return 0, fmt.Errorf("expected uint64 or int64, got %T", i)
 }
 
-func (x *DataFrame) UnmarshalCBOR(data []byte) error {
+func (x *DataFrame) UnmarshalCBOR(data []byte) (err error) {
+	defer recoverMalformed("DataFrame", &err)
 	dec := cbor.NewDecoder(bytes.NewReader(data))
 	var arr _array
 	if err := dec.Decode(&arr); err != nil {
@@ -0,0 +1,81 @@
+package ipldbindcode
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/bindnode"
+	"github.com/ipld/go-ipld-prime/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeDagCbor(t *testing.T, value any, proto schema.TypedPrototype) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, dagcbor.Encode(bindnode.Wrap(value, proto.Type()).Representation(), &buf))
+	return buf.Bytes()
+}
+
+// testDataFrame returns the first frame of a payload split in total frames.
+func testDataFrame(t *testing.T, total int, data []byte) DataFrame {
+	t.Helper()
+	df := DataFrame{Kind: 6, Data: data}
+	if total <= 1 {
+		return df
+	}
+	hash, index := 456, 0
+	hashPtr, indexPtr, totalPtr := &hash, &index, &total
+	df.Hash, df.Index, df.Total = &hashPtr, &indexPtr, &totalPtr
+	next := make(List__Link, 0, total-1)
+	for i := 1; i < total; i++ {
+		c, err := cid.V1Builder{Codec: cid.DagCBOR, MhType: 0x12, MhLength: -1}.Sum([]byte{byte(i)})
+		require.NoError(t, err)
+		next = append(next, cidlink.Link{Cid: c})
+	}
+	nextPtr := &next
+	df.Next = &nextPtr
+	return df
+}
+
+func TestTransactionUnmarshalCBORNext(t *testing.T) {
+	for _, tc := range []struct {
+		name                 string
+		dataTotal, metaTotal int
+	}{
+		{"single", 1, 1},
+		{"multi-data", 3, 1},
+		{"multi-metadata", 1, 4},
+		{"multi-both", 2, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			index := 7
+			indexPtr := &index
+			tx := Transaction{
+				Kind:     0,
+				Data:     testDataFrame(t, tc.dataTotal, []byte{1, 2, 3}),
+				Metadata: testDataFrame(t, tc.metaTotal, []byte{4, 5}),
+				Slot:     42,
+				Index:    &indexPtr,
+			}
+			var got Transaction
+			require.NoError(t, got.UnmarshalCBOR(encodeDagCbor(t, &tx, Prototypes.Transaction)))
+			require.Equal(t, tx, got)
+		})
+	}
+}
+
+func TestRewardsUnmarshalCBORNext(t *testing.T) {
+	for _, total := range []int{1, 3} {
+		rewards := Rewards{
+			Kind: 5,
+			Slot: 42,
+			Data: testDataFrame(t, total, []byte{1, 2, 3}),
+		}
+		var got Rewards
+		require.NoError(t, got.UnmarshalCBOR(encodeDagCbor(t, &rewards, Prototypes.Rewards)))
+		require.Equal(t, rewards, got, "total %d", total)
+	}
+}
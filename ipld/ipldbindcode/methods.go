@@ -79,25 +79,38 @@ func (n DataFrame) GetNext() (List__Link, bool) {
 
 // checksumFnv is the legacy checksum function, used in the first version of the radiance
 // car creator. Some old cars still use this function.
-func checksumFnv(data []byte) uint64 {
+func checksumFnv(chunks ...[]byte) uint64 {
 	h := fnv.New64a()
-	h.Write(data)
+	for _, chunk := range chunks {
+		h.Write(chunk)
+	}
 	return h.Sum64()
 }
 
 // checksumCrc64 returns the hash of the provided buffer.
 // It is used in the latest version of the radiance car creator.
-func checksumCrc64(buf []byte) uint64 {
-	return crc64.Checksum(buf, crc64.MakeTable(crc64.ISO))
+func checksumCrc64(chunks ...[]byte) uint64 {
+	table := crc64.MakeTable(crc64.ISO)
+	var crc uint64
+	for _, chunk := range chunks {
+		crc = crc64.Update(crc, table, chunk)
+	}
+	return crc
 }
 
 // VerifyHash verifies that the provided data matches the provided hash.
 // In case of DataFrames, the hash is stored in the 'Hash' field, and
 // it is the hash of the concatenated 'Data' fields of all the DataFrames.
 func VerifyHash(data []byte, hash uint64) error {
-	if checksumCrc64(data) != (hash) {
+	return VerifyHashOfChunks([][]byte{data}, hash)
+}
+
+// VerifyHashOfChunks is like VerifyHash for data split into chunks (e.g. the 'Data' fields of the DataFrames),
+// without concatenating them.
+func VerifyHashOfChunks(chunks [][]byte, hash uint64) error {
+	if checksumCrc64(chunks...) != (hash) {
 		// Maybe it's the legacy checksum function?
-		if checksumFnv(data) != (hash) {
+		if checksumFnv(chunks...) != (hash) {
 			return fmt.Errorf("data hash mismatch")
 		}
 	}
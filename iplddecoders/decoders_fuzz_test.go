@@ -0,0 +1,55 @@
+package iplddecoders
+
+import (
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+)
+
+// FuzzDecodeAny checks that malformed nodes are reported as errors instead of panics.
+// Crashers found by the fuzzer are kept in testdata/fuzz/FuzzDecodeAny and replayed by go test.
+func FuzzDecodeAny(f *testing.F) {
+	for _, seed := range [][]byte{
+		epoch_raw0, epoch_raw1,
+		subset_raw0, subset_raw1,
+		block_raw0, block_raw1,
+		rewards_raw0, rewards_raw1,
+		entry_raw0, entry_raw1, entry_raw2, entry_raw3,
+		transaction_raw0, transaction_raw1, transaction_raw2, transaction_raw3, transaction_raw4, transaction_raw5,
+		dataFrame_raw0, dataFrame_raw1, dataFrame_raw2,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoded, err := DecodeAny(data)
+		if err != nil {
+			return
+		}
+		kind, err := GetKind(data)
+		if err != nil {
+			t.Fatalf("decoded a node of unknown kind: %v", err)
+		}
+		var decodedKind int
+		switch node := decoded.(type) {
+		case *ipldbindcode.Epoch:
+			decodedKind = node.Kind
+		case *ipldbindcode.Subset:
+			decodedKind = node.Kind
+		case *ipldbindcode.Block:
+			decodedKind = node.Kind
+		case *ipldbindcode.Rewards:
+			decodedKind = node.Kind
+		case *ipldbindcode.Entry:
+			decodedKind = node.Kind
+		case *ipldbindcode.Transaction:
+			decodedKind = node.Kind
+		case *ipldbindcode.DataFrame:
+			decodedKind = node.Kind
+		default:
+			t.Fatalf("unexpected node type %T", decoded)
+		}
+		if decodedKind != int(kind) {
+			t.Fatalf("decoded a %s node as %s", kind, Kind(decodedKind))
+		}
+	})
+}
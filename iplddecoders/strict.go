@@ -0,0 +1,101 @@
+package iplddecoders
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+// Strictness is how much of the CID of a node is checked when decoding it in strict mode.
+type Strictness int
+
+const (
+	// StrictNone doesn't check the CID.
+	StrictNone Strictness = iota
+	// StrictCodec checks that the CID has the version, codec and hash function of the old-faithful nodes.
+	StrictCodec
+	// StrictHash also recomputes the hash of the node.
+	StrictHash
+)
+
+func (s Strictness) String() string {
+	switch s {
+	case StrictNone:
+		return "none"
+	case StrictCodec:
+		return "codec"
+	case StrictHash:
+		return "hash"
+	default:
+		return fmt.Sprintf("Strictness(%d)", int(s))
+	}
+}
+
+// ParseStrictness parses the name of a Strictness ("none", "codec" or "hash"); the empty string is "none".
+func ParseStrictness(s string) (Strictness, error) {
+	switch s {
+	case "", "none":
+		return StrictNone, nil
+	case "codec":
+		return StrictCodec, nil
+	case "hash":
+		return StrictHash, nil
+	default:
+		return StrictNone, fmt.Errorf("invalid strictness %q (must be none, codec or hash)", s)
+	}
+}
+
+// NodeCidPrefix is the prefix of the CID of every node of the old-faithful schema, whatever its kind:
+// CIDv1, dag-cbor, sha2-256.
+var NodeCidPrefix = cid.Prefix{
+	Version:  1,
+	Codec:    cid.DagCBOR,
+	MhType:   multihash.SHA2_256,
+	MhLength: 32,
+}
+
+// CheckCid checks the CID of the raw node, as much as the strictness asks for.
+// It catches the nodes written with a wrong codec or hash function by a buggy producer,
+// which the decoders (that only look at the data) would accept.
+func CheckCid(c cid.Cid, raw []byte, strictness Strictness) error {
+	if strictness == StrictNone {
+		return nil
+	}
+	if !c.Defined() {
+		return fmt.Errorf("undefined CID")
+	}
+	kind, err := GetKind(raw)
+	if err != nil {
+		return fmt.Errorf("node %s: %w", c, err)
+	}
+	prefix := c.Prefix()
+	if prefix.Version != NodeCidPrefix.Version {
+		return fmt.Errorf("%s node %s: CID version %d, expected %d", kind, c, prefix.Version, NodeCidPrefix.Version)
+	}
+	if prefix.Codec != NodeCidPrefix.Codec {
+		return fmt.Errorf("%s node %s: codec %#x, expected dag-cbor (%#x)", kind, c, prefix.Codec, NodeCidPrefix.Codec)
+	}
+	if prefix.MhType != NodeCidPrefix.MhType || prefix.MhLength != NodeCidPrefix.MhLength {
+		return fmt.Errorf("%s node %s: multihash %#x (length %d), expected sha2-256 (%#x)", kind, c, prefix.MhType, prefix.MhLength, NodeCidPrefix.MhType)
+	}
+	if strictness < StrictHash {
+		return nil
+	}
+	sum, err := prefix.Sum(raw)
+	if err != nil {
+		return fmt.Errorf("%s node %s: failed to hash: %w", kind, c, err)
+	}
+	if !sum.Equals(c) {
+		return fmt.Errorf("%s node %s: data does not match the CID (hash is %s)", kind, c, sum)
+	}
+	return nil
+}
+
+// DecodeAnyStrict is DecodeAny for a node whose CID is checked first.
+func DecodeAnyStrict(c cid.Cid, raw []byte, strictness Strictness) (any, error) {
+	if err := CheckCid(c, raw, strictness); err != nil {
+		return nil, err
+	}
+	return DecodeAny(raw)
+}
@@ -0,0 +1,54 @@
+package iplddecoders
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCid(t *testing.T) {
+	raw := []byte{0x85, byte(KindDataFrame), 0xf6, 0xf6, 0xf6}
+	good, err := NodeCidPrefix.Sum(raw)
+	require.NoError(t, err)
+	for _, strictness := range []Strictness{StrictNone, StrictCodec, StrictHash} {
+		require.NoError(t, CheckCid(good, raw, strictness), strictness)
+	}
+
+	// The CID of other data: only the hash check catches it.
+	other, err := NodeCidPrefix.Sum([]byte("other"))
+	require.NoError(t, err)
+	require.NoError(t, CheckCid(other, raw, StrictCodec))
+	require.ErrorContains(t, CheckCid(other, raw, StrictHash), "data does not match the CID")
+
+	wrongCodec := cid.NewCidV1(cid.Raw, good.Hash())
+	require.NoError(t, CheckCid(wrongCodec, raw, StrictNone))
+	require.ErrorContains(t, CheckCid(wrongCodec, raw, StrictCodec), "DataFrame node")
+	require.ErrorContains(t, CheckCid(wrongCodec, raw, StrictCodec), "expected dag-cbor")
+
+	blake, err := cid.Prefix{Version: 1, Codec: cid.DagCBOR, MhType: multihash.BLAKE2B_MIN + 31, MhLength: -1}.Sum(raw)
+	require.NoError(t, err)
+	require.ErrorContains(t, CheckCid(blake, raw, StrictCodec), "expected sha2-256")
+
+	v0 := cid.NewCidV0(good.Hash())
+	require.ErrorContains(t, CheckCid(v0, raw, StrictCodec), "CID version 0")
+
+	require.ErrorContains(t, CheckCid(good, raw[:1], StrictCodec), "not enough bytes")
+
+	_, err = DecodeAnyStrict(wrongCodec, raw, StrictCodec)
+	require.Error(t, err)
+}
+
+func TestParseStrictness(t *testing.T) {
+	for _, strictness := range []Strictness{StrictNone, StrictCodec, StrictHash} {
+		parsed, err := ParseStrictness(strictness.String())
+		require.NoError(t, err)
+		require.Equal(t, strictness, parsed)
+	}
+	parsed, err := ParseStrictness("")
+	require.NoError(t, err)
+	require.Equal(t, StrictNone, parsed)
+	_, err = ParseStrictness("strict")
+	require.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"slices"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// jsonFieldOrder is the order of the fields of a JSON object of a response, as the Solana RPC
+// server (agave) writes them: some clients compare the raw JSON. The fields that are not in keys
+// follow, sorted. fields has the order of the objects in the fields (or of the elements of the
+// arrays in the fields).
+type jsonFieldOrder struct {
+	keys   []string
+	fields map[string]*jsonFieldOrder
+}
+
+var (
+	// UiTokenAmount
+	uiTokenAmountFieldOrder = &jsonFieldOrder{
+		keys: []string{"uiAmount", "decimals", "amount", "uiAmountString"},
+	}
+	// UiTransactionTokenBalance
+	tokenBalanceFieldOrder = &jsonFieldOrder{
+		keys:   []string{"accountIndex", "mint", "uiTokenAmount", "owner", "programId"},
+		fields: map[string]*jsonFieldOrder{"uiTokenAmount": uiTokenAmountFieldOrder},
+	}
+	// Reward
+	rewardFieldOrder = &jsonFieldOrder{
+		keys: []string{"pubkey", "lamports", "postBalance", "rewardType", "commission"},
+	}
+	// UiCompiledInstruction (and UiParsedInstruction)
+	instructionFieldOrder = &jsonFieldOrder{
+		keys: []string{"programIdIndex", "program", "programId", "accounts", "data", "parsed", "stackHeight"},
+	}
+	// UiTransactionStatusMeta
+	metaFieldOrder = &jsonFieldOrder{
+		keys: []string{
+			"err",
+			"status",
+			"fee",
+			"preBalances",
+			"postBalances",
+			"innerInstructions",
+			"logMessages",
+			"preTokenBalances",
+			"postTokenBalances",
+			"rewards",
+			"loadedAddresses",
+			"returnData",
+			"computeUnitsConsumed",
+			"costUnits",
+		},
+		fields: map[string]*jsonFieldOrder{
+			"innerInstructions": {
+				keys:   []string{"index", "instructions"},
+				fields: map[string]*jsonFieldOrder{"instructions": instructionFieldOrder},
+			},
+			"preTokenBalances":  tokenBalanceFieldOrder,
+			"postTokenBalances": tokenBalanceFieldOrder,
+			"rewards":           rewardFieldOrder,
+			"loadedAddresses":   {keys: []string{"writable", "readonly"}},
+			"returnData":        {keys: []string{"programId", "data"}},
+		},
+	}
+	// UiTransaction
+	uiTransactionFieldOrder = &jsonFieldOrder{
+		keys: []string{"signatures", "message"},
+		fields: map[string]*jsonFieldOrder{
+			// UiRawMessage (and UiParsedMessage)
+			"message": {
+				keys: []string{"header", "accountKeys", "recentBlockhash", "instructions", "addressTableLookups"},
+				fields: map[string]*jsonFieldOrder{
+					"header": {
+						keys: []string{"numRequiredSignatures", "numReadonlySignedAccounts", "numReadonlyUnsignedAccounts"},
+					},
+					"accountKeys":  {keys: []string{"pubkey", "writable", "signer", "source"}},
+					"instructions": instructionFieldOrder,
+					"addressTableLookups": {
+						keys: []string{"accountKey", "writableIndexes", "readonlyIndexes"},
+					},
+				},
+			},
+		},
+	}
+	// EncodedTransactionWithStatusMeta
+	transactionWithMetaFieldOrder = &jsonFieldOrder{
+		keys: []string{"transaction", "meta", "version"},
+		fields: map[string]*jsonFieldOrder{
+			"transaction": uiTransactionFieldOrder,
+			"meta":        metaFieldOrder,
+		},
+	}
+	// getTransactionFieldOrder is the order of the result of getTransaction (EncodedConfirmedTransactionWithStatusMeta).
+	getTransactionFieldOrder = &jsonFieldOrder{
+		keys:   []string{"slot", "transaction", "meta", "version", "blockTime"},
+		fields: transactionWithMetaFieldOrder.fields,
+	}
+	// getBlockFieldOrder is the order of the result of getBlock (UiConfirmedBlock).
+	getBlockFieldOrder = &jsonFieldOrder{
+		keys: []string{
+			"previousBlockhash",
+			"blockhash",
+			"parentSlot",
+			"transactions",
+			"signatures",
+			"rewards",
+			"numRewardPartitions",
+			"blockTime",
+			"blockHeight",
+		},
+		fields: map[string]*jsonFieldOrder{
+			"transactions": transactionWithMetaFieldOrder,
+			"rewards":      rewardFieldOrder,
+		},
+	}
+)
+
+// marshalOrdered encodes v (made of map[string]any and []any, like the results of toCamelCaseResult)
+// with the fields of the objects in the order; if the order is nil, the fields are sorted.
+func marshalOrdered(v any, order *jsonFieldOrder) ([]byte, error) {
+	return appendOrdered(nil, v, order)
+}
+
+func appendOrdered(dst []byte, v any, order *jsonFieldOrder) ([]byte, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		if order != nil {
+			ordered := make([]string, 0, len(keys))
+			for _, key := range order.keys {
+				if _, ok := v[key]; ok {
+					ordered = append(ordered, key)
+				}
+			}
+			for _, key := range keys {
+				if !slices.Contains(order.keys, key) {
+					ordered = append(ordered, key)
+				}
+			}
+			keys = ordered
+		}
+		dst = append(dst, '{')
+		for i, key := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			encodedKey, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			dst = append(append(dst, encodedKey...), ':')
+			var fieldOrder *jsonFieldOrder
+			if order != nil {
+				fieldOrder = order.fields[key]
+			}
+			if dst, err = appendOrdered(dst, v[key], fieldOrder); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, '}'), nil
+	case []any:
+		dst = append(dst, '[')
+		for i, elem := range v {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			var err error
+			if dst, err = appendOrdered(dst, elem, order); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, ']'), nil
+	default:
+		encoded, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return append(dst, encoded...), nil
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// jsonObjectKeys returns the keys of the JSON object, in the order they are written.
+func jsonObjectKeys(t *testing.T, raw json.RawMessage) []string {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	tok, err := dec.Token()
+	require.NoError(t, err)
+	require.Equal(t, json.Delim('{'), tok)
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		require.NoError(t, err)
+		keys = append(keys, tok.(string))
+		var value json.RawMessage
+		require.NoError(t, dec.Decode(&value))
+	}
+	return keys
+}
+
+func TestMarshalOrdered(t *testing.T) {
+	order := &jsonFieldOrder{
+		keys:   []string{"b", "a", "missing"},
+		fields: map[string]*jsonFieldOrder{"list": {keys: []string{"y", "x"}}},
+	}
+	got, err := marshalOrdered(map[string]any{
+		"a":     1,
+		"z":     "<z>",
+		"b":     nil,
+		"c":     map[string]any{"q": 1, "p": 2},
+		"list":  []any{map[string]any{"x": 1, "y": 2}, map[string]any{"x": 3}},
+		"empty": []any{},
+	}, order)
+	require.NoError(t, err)
+	require.Equal(t, `{"b":null,"a":1,"c":{"p":2,"q":1},"empty":[],"list":[{"y":2,"x":1},{"x":3}],"z":"\u003cz\u003e"}`, string(got))
+
+	// without an order, the fields are sorted, like the standard library does.
+	value := map[string]any{"b": []any{map[string]any{"d": 1, "c": 2}}, "a": "x"}
+	got, err = marshalOrdered(value, nil)
+	require.NoError(t, err)
+	want, err := json.Marshal(value)
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got))
+}
+
+func TestGetBlockFieldOrder(t *testing.T) {
+	// the generated CAR has metas with inner instructions, token balances, etc.
+	dir := t.TempDir()
+	carPath := filepath.Join(dir, "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+	paths, _, _, err := buildTestEpochIndexes(carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, openTestEpoch(t, testEpochConfig(t, carPath, paths))))
+	get := func(path string) json.RawMessage {
+		var req fasthttp.Request
+		req.SetRequestURI(path)
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		multi.apiHandler(&reqCtx)
+		require.Equal(t, fasthttp.StatusOK, reqCtx.Response.StatusCode(), "%s", reqCtx.Response.Body())
+		return append(json.RawMessage(nil), reqCtx.Response.Body()...)
+	}
+
+	metas := 0
+	for _, slot := range fixture.Slots() {
+		if slot == 0 {
+			continue
+		}
+		raw := get("/api/v1/block/" + strconv.FormatUint(slot, 10) + "?encoding=json&maxSupportedTransactionVersion=0")
+		require.Equal(t, []string{"previousBlockhash", "blockhash", "parentSlot", "transactions", "rewards", "blockTime", "blockHeight"}, jsonObjectKeys(t, raw), "slot %d", slot)
+
+		var block struct {
+			Transactions []json.RawMessage `json:"transactions"`
+		}
+		require.NoError(t, json.Unmarshal(raw, &block))
+		for _, rawTx := range block.Transactions {
+			require.Equal(t, []string{"transaction", "meta", "version"}, jsonObjectKeys(t, rawTx)[:3])
+			var tx struct {
+				Transaction json.RawMessage `json:"transaction"`
+				Meta        json.RawMessage `json:"meta"`
+			}
+			require.NoError(t, json.Unmarshal(rawTx, &tx))
+			require.Equal(t, []string{"signatures", "message"}, jsonObjectKeys(t, tx.Transaction))
+			var message struct {
+				Message json.RawMessage `json:"message"`
+			}
+			require.NoError(t, json.Unmarshal(tx.Transaction, &message))
+			require.Equal(t, []string{"header", "accountKeys", "recentBlockhash", "instructions"}, jsonObjectKeys(t, message.Message)[:4])
+
+			if string(tx.Meta) == "null" {
+				continue
+			}
+			metas++
+			metaKeys := jsonObjectKeys(t, tx.Meta)
+			require.Equal(t, []string{"err", "status", "fee", "preBalances", "postBalances"}, metaKeys[:5])
+			require.Less(t, indexOf(metaKeys, "logMessages"), indexOf(metaKeys, "loadedAddresses"))
+		}
+	}
+	require.NotZero(t, metas)
+
+	raw := get("/api/v1/tx/" + fixture.Transactions[0].Signature.String() + "?encoding=json&maxSupportedTransactionVersion=0")
+	require.Equal(t, []string{"slot", "transaction", "meta", "version", "blockTime"}, jsonObjectKeys(t, raw)[:5])
+}
+
+func indexOf(keys []string, key string) int {
+	for i, k := range keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
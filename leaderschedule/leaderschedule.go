@@ -0,0 +1,200 @@
+// Package leaderschedule has the leader schedule of an epoch (the identity of the
+// validator that produces each slot), either loaded from a file in the format of
+// the getLeaderSchedule RPC method, or computed from the stakes of the validators
+// like Solana does.
+package leaderschedule
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"sort"
+
+	"github.com/gagliardetto/solana-go"
+	"golang.org/x/crypto/chacha20"
+)
+
+// NumConsecutiveLeaderSlots is the number of consecutive slots of each leader.
+const NumConsecutiveLeaderSlots = 4
+
+// noLeader marks the slots without a leader in a schedule loaded from a file.
+const noLeader = ^uint32(0)
+
+// Schedule is the leader schedule of an epoch.
+type Schedule struct {
+	firstSlot  uint64
+	identities []solana.PublicKey
+	// leaders has the index in identities of the leader of each slot of the epoch.
+	leaders []uint32
+}
+
+// Leader returns the leader of the slot; false if the slot is not in the epoch, or (for a
+// schedule loaded from a file) if it has no leader.
+func (s *Schedule) Leader(slot uint64) (solana.PublicKey, bool) {
+	if slot < s.firstSlot || slot-s.firstSlot >= uint64(len(s.leaders)) {
+		return solana.PublicKey{}, false
+	}
+	index := s.leaders[slot-s.firstSlot]
+	if index == noLeader {
+		return solana.PublicKey{}, false
+	}
+	return s.identities[index], true
+}
+
+// Load reads a leader schedule in the format of the getLeaderSchedule RPC method: an object with the
+// identities as keys and the indexes of their slots in the epoch (which starts at firstSlot) as values.
+func Load(r io.Reader, firstSlot uint64, slotsInEpoch uint64) (*Schedule, error) {
+	var raw map[string][]uint64
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode the leader schedule: %w", err)
+	}
+	s := &Schedule{
+		firstSlot: firstSlot,
+		leaders:   make([]uint32, slotsInEpoch),
+	}
+	for i := range s.leaders {
+		s.leaders[i] = noLeader
+	}
+	for identity, slotIndexes := range raw {
+		pk, err := solana.PublicKeyFromBase58(identity)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity %q in the leader schedule: %w", identity, err)
+		}
+		index := uint32(len(s.identities))
+		s.identities = append(s.identities, pk)
+		for _, slotIndex := range slotIndexes {
+			if slotIndex >= slotsInEpoch {
+				return nil, fmt.Errorf("slot index %d of %s is not in the epoch (%d slots)", slotIndex, identity, slotsInEpoch)
+			}
+			if s.leaders[slotIndex] != noLeader {
+				return nil, fmt.Errorf("slot index %d has two leaders: %s and %s", slotIndex, s.identities[s.leaders[slotIndex]], identity)
+			}
+			s.leaders[slotIndex] = index
+		}
+	}
+	return s, nil
+}
+
+// LoadFile reads a leader schedule file (see Load).
+func LoadFile(path string, firstSlot uint64, slotsInEpoch uint64) (*Schedule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Load(file, firstSlot, slotsInEpoch)
+}
+
+// FromStakes computes the leader schedule of the epoch from the stakes (in lamports) of the
+// identities of the validators, like Solana: each run of NumConsecutiveLeaderSlots slots gets a
+// leader sampled with a probability proportional to its stake, with a ChaCha20 RNG seeded with the
+// epoch. The stakes are the ones the cluster used for the epoch (those of the epoch before it).
+func FromStakes(epoch uint64, firstSlot uint64, slotsInEpoch uint64, stakes map[solana.PublicKey]uint64) (*Schedule, error) {
+	type identityStake struct {
+		identity solana.PublicKey
+		stake    uint64
+	}
+	sorted := make([]identityStake, 0, len(stakes))
+	for identity, stake := range stakes {
+		if stake > 0 {
+			sorted = append(sorted, identityStake{identity, stake})
+		}
+	}
+	if len(sorted) == 0 {
+		return nil, fmt.Errorf("no staked identities")
+	}
+	// by stake, then by identity, descending.
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].stake != sorted[j].stake {
+			return sorted[i].stake > sorted[j].stake
+		}
+		return bytesGreater(sorted[i].identity, sorted[j].identity)
+	})
+
+	s := &Schedule{
+		firstSlot:  firstSlot,
+		identities: make([]solana.PublicKey, len(sorted)),
+		leaders:    make([]uint32, slotsInEpoch),
+	}
+	// cumulative[i] is the sum of the stakes of the identities before i+1 (like rand's WeightedIndex).
+	cumulative := make([]uint64, 0, len(sorted)-1)
+	var total uint64
+	for i, entry := range sorted {
+		s.identities[i] = entry.identity
+		if i > 0 {
+			cumulative = append(cumulative, total)
+		}
+		sum, carry := bits.Add64(total, entry.stake, 0)
+		if carry != 0 {
+			return nil, fmt.Errorf("the total stake overflows")
+		}
+		total = sum
+	}
+
+	var seed [32]byte
+	binary.LittleEndian.PutUint64(seed[:8], epoch)
+	rng, err := newChaCha20Rng(seed)
+	if err != nil {
+		return nil, err
+	}
+	var current uint32
+	for i := range s.leaders {
+		if i%NumConsecutiveLeaderSlots == 0 {
+			chosen := rng.uniform(total)
+			// the number of cumulative weights <= chosen.
+			current = uint32(sort.Search(len(cumulative), func(j int) bool { return cumulative[j] > chosen }))
+		}
+		s.leaders[i] = current
+	}
+	return s, nil
+}
+
+func bytesGreater(a, b solana.PublicKey) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
+
+// chaCha20Rng generates the same numbers as the ChaCha20Rng of the rand_chacha crate.
+type chaCha20Rng struct {
+	cipher *chacha20.Cipher
+	block  [64]byte
+	pos    int
+}
+
+func newChaCha20Rng(seed [32]byte) (*chaCha20Rng, error) {
+	cipher, err := chacha20.NewUnauthenticatedCipher(seed[:], make([]byte, chacha20.NonceSize))
+	if err != nil {
+		return nil, err
+	}
+	return &chaCha20Rng{cipher: cipher, pos: len(chaCha20Rng{}.block)}, nil
+}
+
+// nextUint64 returns the next two words of the key stream (little endian), the low one first.
+func (r *chaCha20Rng) nextUint64() uint64 {
+	if r.pos == len(r.block) {
+		clear(r.block[:])
+		r.cipher.XORKeyStream(r.block[:], r.block[:])
+		r.pos = 0
+	}
+	v := binary.LittleEndian.Uint64(r.block[r.pos:])
+	r.pos += 8
+	return v
+}
+
+// uniform returns a number in [0, n), like rand's Uniform for u64 (widening multiply with rejection).
+func (r *chaCha20Rng) uniform(n uint64) uint64 {
+	zone := ^uint64(0) - (^uint64(0)-n+1)%n
+	for {
+		hi, lo := bits.Mul64(r.nextUint64(), n)
+		if lo <= zone {
+			return hi
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package leaderschedule
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaCha20Rng(t *testing.T) {
+	// the first words of the key stream of ChaCha20 with a zero key (as in the tests of rand_chacha).
+	rng, err := newChaCha20Rng([32]byte{})
+	require.NoError(t, err)
+	want := []uint32{
+		0xade0b876, 0x903df1a0, 0xe56a5d40, 0x28bd8653,
+		0xb819d2bd, 0x1aed8da0, 0xccef36a8, 0xc70d778b,
+		0x7c5941da, 0x8d485751, 0x3fe02477, 0x374ad8b8,
+		0xf4b8436a, 0x1ca11815, 0x69b687c3, 0x8665eeb2,
+	}
+	for i := 0; i < len(want); i += 2 {
+		require.Equal(t, uint64(want[i+1])<<32|uint64(want[i]), rng.nextUint64())
+	}
+	// the next block.
+	require.NotZero(t, rng.nextUint64())
+}
+
+func TestFromStakes(t *testing.T) {
+	a, b, c := solana.PublicKey{1}, solana.PublicKey{2}, solana.PublicKey{3}
+	const firstSlot, slotsInEpoch = 432_000, 432_000
+
+	only, err := FromStakes(1, firstSlot, slotsInEpoch, map[solana.PublicKey]uint64{a: 10, b: 0})
+	require.NoError(t, err)
+	for _, slot := range []uint64{firstSlot, firstSlot + 1, firstSlot + slotsInEpoch - 1} {
+		leader, ok := only.Leader(slot)
+		require.True(t, ok)
+		require.Equal(t, a, leader)
+	}
+	_, ok := only.Leader(firstSlot - 1)
+	require.False(t, ok)
+	_, ok = only.Leader(firstSlot + slotsInEpoch)
+	require.False(t, ok)
+
+	_, err = FromStakes(1, firstSlot, slotsInEpoch, map[solana.PublicKey]uint64{a: 0})
+	require.Error(t, err)
+
+	stakes := map[solana.PublicKey]uint64{a: 100, b: 300, c: 600}
+	s, err := FromStakes(1, firstSlot, slotsInEpoch, stakes)
+	require.NoError(t, err)
+	// sorted by stake, descending.
+	require.Equal(t, []solana.PublicKey{c, b, a}, s.identities)
+	counts := make(map[solana.PublicKey]int)
+	for slot := uint64(firstSlot); slot < firstSlot+slotsInEpoch; slot++ {
+		leader, ok := s.Leader(slot)
+		require.True(t, ok)
+		counts[leader]++
+		if (slot-firstSlot)%NumConsecutiveLeaderSlots != 0 {
+			previous, _ := s.Leader(slot - 1)
+			require.Equal(t, previous, leader)
+		}
+	}
+	// proportional to the stakes.
+	require.InDelta(t, 0.1, float64(counts[a])/slotsInEpoch, 0.01)
+	require.InDelta(t, 0.3, float64(counts[b])/slotsInEpoch, 0.01)
+	require.InDelta(t, 0.6, float64(counts[c])/slotsInEpoch, 0.01)
+
+	// deterministic for an epoch.
+	again, err := FromStakes(1, firstSlot, slotsInEpoch, stakes)
+	require.NoError(t, err)
+	require.Equal(t, s.leaders, again.leaders)
+	other, err := FromStakes(2, firstSlot, slotsInEpoch, stakes)
+	require.NoError(t, err)
+	require.NotEqual(t, s.leaders, other.leaders)
+}
+
+func TestLoad(t *testing.T) {
+	a, b := solana.PublicKey{1}, solana.PublicKey{2}
+	s, err := Load(strings.NewReader(`{"`+a.String()+`": [0, 1, 2, 3], "`+b.String()+`": [4, 5]}`), 100, 8)
+	require.NoError(t, err)
+	leader, ok := s.Leader(103)
+	require.True(t, ok)
+	require.Equal(t, a, leader)
+	leader, ok = s.Leader(105)
+	require.True(t, ok)
+	require.Equal(t, b, leader)
+	_, ok = s.Leader(106)
+	require.False(t, ok)
+	_, ok = s.Leader(108)
+	require.False(t, ok)
+
+	_, err = Load(strings.NewReader(`{"`+a.String()+`": [8]}`), 100, 8)
+	require.Error(t, err)
+	_, err = Load(strings.NewReader(`{"`+a.String()+`": [1], "`+b.String()+`": [1]}`), 100, 8)
+	require.Error(t, err)
+	_, err = Load(strings.NewReader(`{"not a key": [1]}`), 100, 8)
+	require.Error(t, err)
+}
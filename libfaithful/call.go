@@ -0,0 +1,59 @@
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+*/
+import "C"
+
+import "unsafe"
+
+// The tests can't use cgo; these wrappers call the exported functions the way a C caller does,
+// and return the documents as Go strings (after releasing them with faithful_free).
+
+func takeString(s *C.char) string {
+	defer faithful_free(s)
+	return C.GoString(s)
+}
+
+func callOpen(configJSON string) string {
+	config := C.CString(configJSON)
+	defer C.free(unsafe.Pointer(config))
+	return takeString(faithful_open(config))
+}
+
+func callClose(handle int64) {
+	faithful_close(C.int64_t(handle))
+}
+
+func callGetBlock(handle int64, slot uint64) string {
+	return takeString(faithful_get_block(C.int64_t(handle), C.uint64_t(slot)))
+}
+
+func callGetTransaction(handle int64, signature string) string {
+	sig := C.CString(signature)
+	defer C.free(unsafe.Pointer(sig))
+	return takeString(faithful_get_transaction(C.int64_t(handle), sig))
+}
+
+// takeBytes returns the bytes of a _pb function (after releasing them with faithful_free).
+func takeBytes(out *C.char, outLen C.size_t) []byte {
+	defer faithful_free(out)
+	return C.GoBytes(unsafe.Pointer(out), C.int(outLen))
+}
+
+func callGetBlockPb(handle int64, slot uint64) (int, []byte) {
+	var out *C.char
+	var outLen C.size_t
+	status := faithful_get_block_pb(C.int64_t(handle), C.uint64_t(slot), &out, &outLen)
+	return int(status), takeBytes(out, outLen)
+}
+
+func callGetTransactionPb(handle int64, signature string) (int, []byte) {
+	sig := C.CString(signature)
+	defer C.free(unsafe.Pointer(sig))
+	var out *C.char
+	var outLen C.size_t
+	status := faithful_get_transaction_pb(C.int64_t(handle), sig, &out, &outLen)
+	return int(status), takeBytes(out, outLen)
+}
@@ -0,0 +1,305 @@
+// Command libfaithful is a C-shared library exposing the read path of the client package
+// (open an epoch, get a block by slot, get a transaction by signature), for use from other languages
+// (see python/faithful.py).
+//
+// Build it with:
+//
+//	go build -buildmode=c-shared -o libfaithful.so ./libfaithful
+//
+// All the functions that return a string return a JSON document, allocated with malloc;
+// the caller must release it with faithful_free. On error the document is {"error": "..."}.
+//
+// The _pb variants return the block or transaction as the protobufs of the solana-storage-proto
+// crate (ConfirmedBlock and ConfirmedTransaction, the format of the warehouse Bigtable) instead:
+// they return a status code, and the bytes through out parameters.
+package main
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+
+// Status codes of the _pb functions.
+enum {
+	FAITHFUL_OK = 0,
+	FAITHFUL_ERROR = 1,
+	FAITHFUL_NOT_FOUND = 2,
+};
+*/
+import "C"
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+	"unsafe"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/client"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	mu         sync.Mutex
+	nextHandle int64 = 1
+	epochs           = make(map[int64]*client.Epoch)
+)
+
+func getEpoch(handle C.int64_t) (*client.Epoch, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	epoch, ok := epochs[int64(handle)]
+	if !ok {
+		return nil, errors.New("invalid handle")
+	}
+	return epoch, nil
+}
+
+func toCString(v any) *C.char {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return errorToCString(err)
+	}
+	return C.CString(string(buf))
+}
+
+func errorToCString(err error) *C.char {
+	return C.CString(errorDocument(err))
+}
+
+func errorDocument(err error) string {
+	buf, _ := json.Marshal(map[string]any{
+		"error":     err.Error(),
+		"not_found": errors.Is(err, client.ErrNotFound),
+	})
+	return string(buf)
+}
+
+// The status codes of the _pb functions.
+const (
+	statusOK       = C.FAITHFUL_OK
+	statusError    = C.FAITHFUL_ERROR
+	statusNotFound = C.FAITHFUL_NOT_FOUND
+)
+
+// returnProtobuf sets the out parameters of a _pb function to the encoded message, or to the
+// error document if err is not nil, and returns the status code.
+func returnProtobuf(msg proto.Message, err error, out **C.char, outLen *C.size_t) C.int {
+	if err == nil {
+		var buf []byte
+		if buf, err = proto.Marshal(msg); err == nil {
+			*out = (*C.char)(C.CBytes(buf))
+			*outLen = C.size_t(len(buf))
+			return statusOK
+		}
+	}
+	doc := errorDocument(err)
+	*out = C.CString(doc)
+	*outLen = C.size_t(len(doc))
+	if errors.Is(err, client.ErrNotFound) {
+		return statusNotFound
+	}
+	return statusError
+}
+
+// faithful_open opens an epoch; configJSON has the fields of client.Config
+// ({"car": ..., "cid_to_offset_and_size": ..., "slot_to_cid": ..., "sig_to_cid": ...}).
+// It returns {"handle": <handle>}.
+//
+//export faithful_open
+func faithful_open(configJSON *C.char) *C.char {
+	var config struct {
+		Car                string `json:"car"`
+		CidToOffsetAndSize string `json:"cid_to_offset_and_size"`
+		SlotToCid          string `json:"slot_to_cid"`
+		SigToCid           string `json:"sig_to_cid"`
+	}
+	if err := json.Unmarshal([]byte(C.GoString(configJSON)), &config); err != nil {
+		return errorToCString(err)
+	}
+	epoch, err := client.Open(context.Background(), client.Config{
+		Car:                config.Car,
+		CidToOffsetAndSize: config.CidToOffsetAndSize,
+		SlotToCid:          config.SlotToCid,
+		SigToCid:           config.SigToCid,
+	})
+	if err != nil {
+		return errorToCString(err)
+	}
+	mu.Lock()
+	handle := nextHandle
+	nextHandle++
+	epochs[handle] = epoch
+	mu.Unlock()
+	return toCString(map[string]any{"handle": handle})
+}
+
+// faithful_close closes an epoch opened with faithful_open.
+//
+//export faithful_close
+func faithful_close(handle C.int64_t) {
+	mu.Lock()
+	epoch, ok := epochs[int64(handle)]
+	delete(epochs, int64(handle))
+	mu.Unlock()
+	if ok {
+		epoch.Close()
+	}
+}
+
+// faithful_free releases a string returned by the library.
+//
+//export faithful_free
+func faithful_free(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+type transactionJSON struct {
+	Signature string `json:"signature"`
+	Slot      uint64 `json:"slot"`
+	Position  int    `json:"position"`
+	// TransactionBase64 is the transaction in wire format.
+	TransactionBase64 string             `json:"transaction_base64"`
+	Transaction       solana.Transaction `json:"transaction"`
+	Meta              any                `json:"meta"`
+}
+
+func newTransactionJSON(tx *client.Transaction) (*transactionJSON, error) {
+	wire, err := tx.Transaction.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &transactionJSON{
+		Signature:         tx.Signature().String(),
+		Slot:              tx.Slot,
+		Position:          tx.Position,
+		TransactionBase64: base64.StdEncoding.EncodeToString(wire),
+		Transaction:       tx.Transaction,
+		Meta:              tx.Meta,
+	}, nil
+}
+
+// faithful_get_block returns the block at the given slot.
+//
+//export faithful_get_block
+func faithful_get_block(handle C.int64_t, slot C.uint64_t) *C.char {
+	epoch, err := getEpoch(handle)
+	if err != nil {
+		return errorToCString(err)
+	}
+	block, err := epoch.GetBlock(context.Background(), uint64(slot))
+	if err != nil {
+		return errorToCString(err)
+	}
+	out := struct {
+		Slot         uint64             `json:"slot"`
+		ParentSlot   uint64             `json:"parent_slot"`
+		Blockhash    string             `json:"blockhash"`
+		BlockTime    int64              `json:"block_time"`
+		BlockHeight  *uint64            `json:"block_height"`
+		Transactions []*transactionJSON `json:"transactions"`
+		// RewardsBase64 are the protobuf-encoded rewards.
+		RewardsBase64 string `json:"rewards_base64"`
+	}{
+		Slot:          block.Slot,
+		ParentSlot:    block.ParentSlot,
+		Blockhash:     block.Blockhash.String(),
+		BlockTime:     block.Blocktime,
+		BlockHeight:   block.BlockHeight,
+		Transactions:  make([]*transactionJSON, 0, len(block.Transactions)),
+		RewardsBase64: base64.StdEncoding.EncodeToString(block.Rewards),
+	}
+	for _, tx := range block.Transactions {
+		txJSON, err := newTransactionJSON(tx)
+		if err != nil {
+			return errorToCString(err)
+		}
+		out.Transactions = append(out.Transactions, txJSON)
+	}
+	return toCString(out)
+}
+
+// faithful_get_transaction returns the transaction with the given (base58) signature.
+//
+//export faithful_get_transaction
+func faithful_get_transaction(handle C.int64_t, signature *C.char) *C.char {
+	epoch, err := getEpoch(handle)
+	if err != nil {
+		return errorToCString(err)
+	}
+	sig, err := solana.SignatureFromBase58(C.GoString(signature))
+	if err != nil {
+		return errorToCString(err)
+	}
+	tx, err := epoch.GetTransaction(context.Background(), sig)
+	if err != nil {
+		return errorToCString(err)
+	}
+	txJSON, err := newTransactionJSON(tx)
+	if err != nil {
+		return errorToCString(err)
+	}
+	return toCString(txJSON)
+}
+
+// faithful_get_block_pb returns the block at the given slot as a ConfirmedBlock protobuf.
+// On success it returns FAITHFUL_OK, and *out points to the *outLen bytes of the protobuf;
+// otherwise it returns FAITHFUL_NOT_FOUND or FAITHFUL_ERROR, and *out is the JSON error document
+// (NUL-terminated, of length *outLen). In both cases *out must be released with faithful_free.
+//
+//export faithful_get_block_pb
+func faithful_get_block_pb(handle C.int64_t, slot C.uint64_t, out **C.char, outLen *C.size_t) C.int {
+	block, err := getBlockProtobuf(handle, uint64(slot))
+	return returnProtobuf(block, err, out, outLen)
+}
+
+func getBlockProtobuf(handle C.int64_t, slot uint64) (*confirmed_block.ConfirmedBlock, error) {
+	epoch, err := getEpoch(handle)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	block, err := epoch.GetBlock(ctx, slot)
+	if err != nil {
+		return nil, err
+	}
+	// the previous blockhash is left empty if the parent is not in this epoch.
+	var previousBlockhash solana.Hash
+	if block.ParentSlot != block.Slot {
+		previousBlockhash, err = epoch.GetBlockhash(ctx, block.ParentSlot)
+		if err != nil && !errors.Is(err, client.ErrNotFound) {
+			return nil, err
+		}
+	}
+	return block.ToProtobuf(previousBlockhash)
+}
+
+// faithful_get_transaction_pb returns the transaction with the given (base58) signature as a
+// ConfirmedTransaction protobuf; the status code and the out parameters are like for
+// faithful_get_block_pb.
+//
+//export faithful_get_transaction_pb
+func faithful_get_transaction_pb(handle C.int64_t, signature *C.char, out **C.char, outLen *C.size_t) C.int {
+	tx, err := getTransactionProtobuf(handle, C.GoString(signature))
+	return returnProtobuf(tx, err, out, outLen)
+}
+
+func getTransactionProtobuf(handle C.int64_t, signature string) (*confirmed_block.ConfirmedTransaction, error) {
+	epoch, err := getEpoch(handle)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := solana.SignatureFromBase58(signature)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := epoch.GetTransaction(context.Background(), sig)
+	if err != nil {
+		return nil, err
+	}
+	return tx.ToProtobuf()
+}
+
+func main() {}
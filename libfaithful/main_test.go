@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// buildIndexes creates the indexes of a CAR file, and returns the config document for faithful_open.
+func buildIndexes(t *testing.T, carPath string) string {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	file, err := os.Open(carPath)
+	require.NoError(t, err)
+	defer file.Close()
+	rd, err := carreader.New(file)
+	require.NoError(t, err)
+	rootCid := rd.Header.Roots[0]
+
+	c2o, err := indexes.NewWriter_CidToOffsetAndSize(0, rootCid, indexes.NetworkMainnet, t.TempDir(), 1000)
+	require.NoError(t, err)
+	s2c, err := indexes.NewWriter_SlotToCid(0, rootCid, indexes.NetworkMainnet, t.TempDir(), 1000)
+	require.NoError(t, err)
+	sig2c, err := indexes.NewWriter_SigToCid(0, rootCid, indexes.NetworkMainnet, t.TempDir(), 1000)
+	require.NoError(t, err)
+
+	offset, err := rd.HeaderSize()
+	require.NoError(t, err)
+	for {
+		c, sectionLength, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.NoError(t, c2o.Put(c, offset, sectionLength))
+		offset += sectionLength
+
+		switch iplddecoders.Kind(data[1]) {
+		case iplddecoders.KindBlock:
+			block, err := iplddecoders.DecodeBlock(data)
+			require.NoError(t, err)
+			require.NoError(t, s2c.Put(uint64(block.Slot), c))
+		case iplddecoders.KindTransaction:
+			tx, err := iplddecoders.DecodeTransaction(data)
+			require.NoError(t, err)
+			sig, err := tx.Signature()
+			require.NoError(t, err)
+			require.NoError(t, sig2c.Put(sig, c))
+		}
+	}
+	require.NoError(t, c2o.Seal(ctx, dir))
+	require.NoError(t, s2c.Seal(ctx, dir))
+	require.NoError(t, sig2c.Seal(ctx, dir))
+
+	config, err := json.Marshal(map[string]string{
+		"car":                    carPath,
+		"cid_to_offset_and_size": c2o.GetFilepath(),
+		"slot_to_cid":            s2c.GetFilepath(),
+		"sig_to_cid":             sig2c.GetFilepath(),
+	})
+	require.NoError(t, err)
+	return string(config)
+}
+
+// errorReply is the JSON document returned on error.
+type errorReply struct {
+	Error    string `json:"error"`
+	NotFound bool   `json:"not_found"`
+}
+
+func requireError(t *testing.T, doc string, notFound bool) errorReply {
+	t.Helper()
+	var got errorReply
+	require.NoError(t, json.Unmarshal([]byte(doc), &got), doc)
+	require.NotEmpty(t, got.Error, doc)
+	require.Equal(t, notFound, got.NotFound, doc)
+	return got
+}
+
+func TestExportedFunctions(t *testing.T) {
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+	config := buildIndexes(t, carPath)
+
+	var opened struct {
+		Handle int64 `json:"handle"`
+	}
+	doc := callOpen(config)
+	require.NoError(t, json.Unmarshal([]byte(doc), &opened), doc)
+	require.NotZero(t, opened.Handle, doc)
+	handle := opened.Handle
+	defer callClose(handle)
+
+	type transaction struct {
+		Signature         string          `json:"signature"`
+		Slot              uint64          `json:"slot"`
+		Position          int             `json:"position"`
+		TransactionBase64 string          `json:"transaction_base64"`
+		Meta              json.RawMessage `json:"meta"`
+	}
+	requireTransaction := func(t *testing.T, want carfixture.Transaction, got transaction) {
+		t.Helper()
+		require.Equal(t, want.Signature.String(), got.Signature)
+		require.Equal(t, want.Slot, got.Slot)
+		require.Equal(t, want.Position, got.Position)
+		require.Equal(t, base64.StdEncoding.EncodeToString(want.Data), got.TransactionBase64)
+		if want.Spec.Meta == carfixture.MetaNone {
+			require.Equal(t, "null", string(got.Meta))
+		} else {
+			require.NotEqual(t, "null", string(got.Meta))
+		}
+	}
+
+	t.Run("faithful_get_block", func(t *testing.T) {
+		for _, block := range fixture.Blocks {
+			doc := callGetBlock(handle, block.Slot)
+			var got struct {
+				Slot          uint64        `json:"slot"`
+				ParentSlot    uint64        `json:"parent_slot"`
+				Blockhash     string        `json:"blockhash"`
+				BlockTime     int64         `json:"block_time"`
+				BlockHeight   *uint64       `json:"block_height"`
+				Transactions  []transaction `json:"transactions"`
+				RewardsBase64 string        `json:"rewards_base64"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(doc), &got), doc)
+			require.Equal(t, block.Slot, got.Slot)
+			require.Equal(t, block.ParentSlot, got.ParentSlot)
+			require.Equal(t, block.Entries[len(block.Entries)-1].Hash.String(), got.Blockhash)
+			require.Equal(t, block.BlockTime, got.BlockTime)
+			require.Equal(t, block.BlockHeight, got.BlockHeight)
+			var want []carfixture.Transaction
+			for _, tx := range fixture.Transactions {
+				if tx.Slot == block.Slot {
+					want = append(want, tx)
+				}
+			}
+			require.Len(t, got.Transactions, len(want), doc)
+			for i := range want {
+				requireTransaction(t, want[i], got.Transactions[i])
+			}
+			require.Equal(t, block.Rewards == nil, got.RewardsBase64 == "")
+		}
+		// slot 3 is skipped.
+		requireError(t, callGetBlock(handle, 3), true)
+		requireError(t, callGetBlock(handle, 1_000_000), true)
+	})
+
+	t.Run("faithful_get_transaction", func(t *testing.T) {
+		for _, tx := range fixture.Transactions {
+			doc := callGetTransaction(handle, tx.Signature.String())
+			var got transaction
+			require.NoError(t, json.Unmarshal([]byte(doc), &got), doc)
+			requireTransaction(t, tx, got)
+		}
+		requireError(t, callGetTransaction(handle, solana.Signature{1, 2, 3}.String()), true)
+		requireError(t, callGetTransaction(handle, "not-a-signature"), false)
+	})
+
+	requireProtobufTransaction := func(t *testing.T, want carfixture.Transaction, got *confirmed_block.ConfirmedTransaction) {
+		t.Helper()
+		require.Equal(t, want.Signature[:], got.Transaction.Signatures[0])
+		var tx solana.Transaction
+		require.NoError(t, tx.UnmarshalWithDecoder(bin.NewBinDecoder(want.Data)))
+		require.True(t, proto.Equal(nodetools.TransactionToProtobuf(&tx), got.Transaction))
+		if want.Spec.Meta == carfixture.MetaNone {
+			require.Nil(t, got.Meta)
+		} else {
+			// the legacy metas are upgraded to protobuf too.
+			require.Equal(t, uint64(carfixture.Fee), got.Meta.Fee)
+		}
+	}
+
+	t.Run("faithful_get_block_pb", func(t *testing.T) {
+		for i, block := range fixture.Blocks {
+			status, buf := callGetBlockPb(handle, block.Slot)
+			require.Equal(t, statusOK, status, string(buf))
+			var got confirmed_block.ConfirmedBlock
+			require.NoError(t, proto.Unmarshal(buf, &got))
+			require.Equal(t, block.ParentSlot, got.ParentSlot)
+			require.Equal(t, block.Entries[len(block.Entries)-1].Hash.String(), got.Blockhash)
+			if i == 0 {
+				require.Empty(t, got.PreviousBlockhash)
+			} else {
+				parent := fixture.Blocks[i-1]
+				require.Equal(t, parent.Entries[len(parent.Entries)-1].Hash.String(), got.PreviousBlockhash)
+			}
+			require.Equal(t, block.BlockTime, got.BlockTime.GetTimestamp())
+			require.Equal(t, block.Rewards != nil, len(got.Rewards) > 0)
+			var want []carfixture.Transaction
+			for _, tx := range fixture.Transactions {
+				if tx.Slot == block.Slot {
+					want = append(want, tx)
+				}
+			}
+			require.Len(t, got.Transactions, len(want))
+			for i := range want {
+				requireProtobufTransaction(t, want[i], got.Transactions[i])
+			}
+		}
+		status, buf := callGetBlockPb(handle, 3)
+		require.Equal(t, statusNotFound, status)
+		requireError(t, string(buf), true)
+		status, buf = callGetBlockPb(handle+1, 0)
+		require.Equal(t, statusError, status)
+		require.Equal(t, "invalid handle", requireError(t, string(buf), false).Error)
+	})
+
+	t.Run("faithful_get_transaction_pb", func(t *testing.T) {
+		for _, tx := range fixture.Transactions {
+			status, buf := callGetTransactionPb(handle, tx.Signature.String())
+			require.Equal(t, statusOK, status, string(buf))
+			var got confirmed_block.ConfirmedTransaction
+			require.NoError(t, proto.Unmarshal(buf, &got))
+			requireProtobufTransaction(t, tx, &got)
+		}
+		status, buf := callGetTransactionPb(handle, solana.Signature{1, 2, 3}.String())
+		require.Equal(t, statusNotFound, status)
+		requireError(t, string(buf), true)
+		status, buf = callGetTransactionPb(handle, "not-a-signature")
+		require.Equal(t, statusError, status)
+		requireError(t, string(buf), false)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		got := requireError(t, callGetBlock(handle+1, 0), false)
+		require.Equal(t, "invalid handle", got.Error)
+		got = requireError(t, callGetTransaction(handle+1, fixture.Transactions[0].Signature.String()), false)
+		require.Equal(t, "invalid handle", got.Error)
+		requireError(t, callOpen("{"), false)
+		requireError(t, callOpen(`{"car": "`+filepath.Join(t.TempDir(), "missing.car")+`"}`), false)
+	})
+
+	t.Run("faithful_close", func(t *testing.T) {
+		handle := opened.Handle
+		doc := callOpen(config)
+		require.NoError(t, json.Unmarshal([]byte(doc), &opened), doc)
+		require.NotEqual(t, handle, opened.Handle)
+		callClose(opened.Handle)
+		got := requireError(t, callGetBlock(opened.Handle, 0), false)
+		require.Equal(t, "invalid handle", got.Error)
+		// closing twice is a no-op.
+		callClose(opened.Handle)
+	})
+}
@@ -0,0 +1,131 @@
+"""Thin ctypes wrapper around libfaithful (see ../main.go).
+
+Build the shared library with `make libfaithful`, then:
+
+    from faithful import Epoch
+
+    with Epoch(
+        car="/data/epoch-0.car",
+        cid_to_offset_and_size="/data/epoch-0-...-mainnet-cid-to-offset-and-size.index",
+        slot_to_cid="/data/epoch-0-...-mainnet-slot-to-cid.index",
+        sig_to_cid="/data/epoch-0-...-mainnet-sig-to-cid.index",
+    ) as epoch:
+        block = epoch.get_block(1)
+        tx = epoch.get_transaction(block["transactions"][0]["signature"])
+        raw = epoch.get_block_pb(1)  # ConfirmedBlock protobuf bytes
+
+The library path defaults to libfaithful.so next to this file, and can be
+overridden with the LIBFAITHFUL_PATH environment variable.
+"""
+
+import ctypes
+import json
+import os
+
+_DEFAULT_LIB_PATH = os.path.join(os.path.dirname(os.path.abspath(__file__)), "libfaithful.so")
+
+_lib = ctypes.CDLL(os.environ.get("LIBFAITHFUL_PATH", _DEFAULT_LIB_PATH))
+
+# Returned strings are declared as void pointers so that they can be released with faithful_free.
+_lib.faithful_open.argtypes = [ctypes.c_char_p]
+_lib.faithful_open.restype = ctypes.c_void_p
+_lib.faithful_close.argtypes = [ctypes.c_int64]
+_lib.faithful_close.restype = None
+_lib.faithful_free.argtypes = [ctypes.c_void_p]
+_lib.faithful_free.restype = None
+_lib.faithful_get_block.argtypes = [ctypes.c_int64, ctypes.c_uint64]
+_lib.faithful_get_block.restype = ctypes.c_void_p
+_lib.faithful_get_transaction.argtypes = [ctypes.c_int64, ctypes.c_char_p]
+_lib.faithful_get_transaction.restype = ctypes.c_void_p
+_lib.faithful_get_block_pb.argtypes = [
+    ctypes.c_int64,
+    ctypes.c_uint64,
+    ctypes.POINTER(ctypes.c_void_p),
+    ctypes.POINTER(ctypes.c_size_t),
+]
+_lib.faithful_get_block_pb.restype = ctypes.c_int
+_lib.faithful_get_transaction_pb.argtypes = [
+    ctypes.c_int64,
+    ctypes.c_char_p,
+    ctypes.POINTER(ctypes.c_void_p),
+    ctypes.POINTER(ctypes.c_size_t),
+]
+_lib.faithful_get_transaction_pb.restype = ctypes.c_int
+
+# Status codes of the _pb functions.
+_OK = 0
+_NOT_FOUND = 2
+
+
+class FaithfulError(Exception):
+    pass
+
+
+class NotFoundError(FaithfulError):
+    """Raised when a slot (skipped, or not in the epoch) or a transaction is not found."""
+
+
+def _call(ptr):
+    try:
+        out = json.loads(ctypes.string_at(ptr).decode("utf-8"))
+    finally:
+        _lib.faithful_free(ptr)
+    if isinstance(out, dict) and "error" in out:
+        if out.get("not_found"):
+            raise NotFoundError(out["error"])
+        raise FaithfulError(out["error"])
+    return out
+
+
+def _call_pb(fn, *args):
+    out = ctypes.c_void_p()
+    out_len = ctypes.c_size_t()
+    status = fn(*args, ctypes.byref(out), ctypes.byref(out_len))
+    try:
+        buf = ctypes.string_at(out, out_len.value)
+    finally:
+        _lib.faithful_free(out)
+    if status == _OK:
+        return buf
+    error = json.loads(buf.decode("utf-8"))["error"]
+    if status == _NOT_FOUND:
+        raise NotFoundError(error)
+    raise FaithfulError(error)
+
+
+class Epoch:
+    """An epoch CAR file and its indexes; each location can be a local path or an HTTP(S) URL."""
+
+    def __init__(self, car, cid_to_offset_and_size, slot_to_cid="", sig_to_cid=""):
+        config = {
+            "car": car,
+            "cid_to_offset_and_size": cid_to_offset_and_size,
+            "slot_to_cid": slot_to_cid,
+            "sig_to_cid": sig_to_cid,
+        }
+        self._handle = _call(_lib.faithful_open(json.dumps(config).encode("utf-8")))["handle"]
+
+    def get_block(self, slot):
+        return _call(_lib.faithful_get_block(self._handle, slot))
+
+    def get_transaction(self, signature):
+        return _call(_lib.faithful_get_transaction(self._handle, signature.encode("utf-8")))
+
+    def get_block_pb(self, slot):
+        """Returns the block as the bytes of a ConfirmedBlock protobuf (solana-storage-proto)."""
+        return _call_pb(_lib.faithful_get_block_pb, self._handle, slot)
+
+    def get_transaction_pb(self, signature):
+        """Returns the transaction as the bytes of a ConfirmedTransaction protobuf (solana-storage-proto)."""
+        return _call_pb(_lib.faithful_get_transaction_pb, self._handle, signature.encode("utf-8"))
+
+    def close(self):
+        if self._handle is not None:
+            _lib.faithful_close(self._handle)
+            self._handle = None
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *exc):
+        self.close()
@@ -0,0 +1,277 @@
+// Package lookuptables resolves the accounts that v0 transactions load from address lookup
+// tables when their meta doesn't have them (the meta is missing, or legacy), from a snapshot
+// of the tables: a sidecar file of an epoch with the addresses of the tables used in it.
+//
+// The addresses of a lookup table are only ever appended (a table can be extended, but its
+// existing entries can't be changed), so a snapshot taken at any point after a transaction
+// resolves it. The snapshots built from the CARs (see Snapshot.Learn) are sparse: they only
+// have the entries of the tables that were loaded by transactions with a protobuf meta.
+package lookuptables
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+)
+
+var magic = []byte("lookuptables")
+
+const Version = uint64(1)
+
+// headerSize is the size of the magic, the version, the epoch and the number of tables.
+var headerSize = int64(len(magic) + 8 + 8 + 8)
+
+// indexEntrySize is the size of the entry of a table in the index of a snapshot file: the
+// table key, the offset of its addresses (from the end of the index), and their number.
+const indexEntrySize = solana.PublicKeyLength + 8 + 4
+
+// Snapshot is a set of lookup tables, being built. An unknown entry of a table is the zero key.
+type Snapshot struct {
+	epoch  uint64
+	tables map[solana.PublicKey][]solana.PublicKey
+}
+
+// NewSnapshot creates an empty snapshot for the given epoch.
+func NewSnapshot(epoch uint64) *Snapshot {
+	return &Snapshot{
+		epoch:  epoch,
+		tables: make(map[solana.PublicKey][]solana.PublicKey),
+	}
+}
+
+// Epoch returns the epoch of the snapshot.
+func (s *Snapshot) Epoch() uint64 {
+	return s.epoch
+}
+
+// Len returns the number of tables in the snapshot.
+func (s *Snapshot) Len() int {
+	return len(s.tables)
+}
+
+// Table returns the addresses of a table.
+func (s *Snapshot) Table(key solana.PublicKey) ([]solana.PublicKey, bool) {
+	addresses, ok := s.tables[key]
+	return addresses, ok
+}
+
+// Set sets the address at an index of a table.
+func (s *Snapshot) Set(table solana.PublicKey, index int, address solana.PublicKey) {
+	addresses := s.tables[table]
+	if index >= len(addresses) {
+		addresses = append(addresses, make([]solana.PublicKey, index+1-len(addresses))...)
+		s.tables[table] = addresses
+	}
+	addresses[index] = address
+}
+
+// Merge adds the entries of another snapshot (e.g. of an earlier epoch) that this one doesn't have.
+func (s *Snapshot) Merge(other *Snapshot) {
+	for table, addresses := range other.tables {
+		for index, address := range addresses {
+			if address.IsZero() {
+				continue
+			}
+			if known, ok := s.tables[table]; ok && index < len(known) && !known[index].IsZero() {
+				continue
+			}
+			s.Set(table, index, address)
+		}
+	}
+}
+
+// Learn records the entries of the lookup tables loaded by a transaction, from the accounts
+// that its (protobuf) meta says were loaded: the writable ones of all its lookups, in order,
+// then the readonly ones.
+func (s *Snapshot) Learn(tx *solana.Transaction, meta *confirmed_block.TransactionStatusMeta) error {
+	lookups := tx.Message.AddressTableLookups
+	if len(lookups) == 0 || meta == nil {
+		return nil
+	}
+	if len(meta.LoadedWritableAddresses) != lookups.NumWritableLookups() ||
+		len(meta.LoadedReadonlyAddresses) != lookups.NumLookups()-lookups.NumWritableLookups() {
+		return fmt.Errorf(
+			"the meta has %d writable and %d readonly loaded accounts, but the transaction loads %d and %d",
+			len(meta.LoadedWritableAddresses), len(meta.LoadedReadonlyAddresses),
+			lookups.NumWritableLookups(), lookups.NumLookups()-lookups.NumWritableLookups(),
+		)
+	}
+	writable, readonly := meta.LoadedWritableAddresses, meta.LoadedReadonlyAddresses
+	for _, lookup := range lookups {
+		for _, index := range lookup.WritableIndexes {
+			s.Set(lookup.AccountKey, int(index), solana.PublicKeyFromBytes(writable[0]))
+			writable = writable[1:]
+		}
+		for _, index := range lookup.ReadonlyIndexes {
+			s.Set(lookup.AccountKey, int(index), solana.PublicKeyFromBytes(readonly[0]))
+			readonly = readonly[1:]
+		}
+	}
+	return nil
+}
+
+var _ io.WriterTo = (*Snapshot)(nil)
+
+// WriteTo writes the snapshot in its binary format: a header, the index of the tables sorted
+// by key (so that a table is found with a binary search, without reading the whole file), and
+// the addresses of the tables.
+func (s *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	keys := make([]solana.PublicKey, 0, len(s.tables))
+	for key := range s.tables {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i][:], keys[j][:]) < 0
+	})
+	bw := bufio.NewWriter(w)
+	var written int64
+	write := func(b []byte) {
+		n, _ := bw.Write(b)
+		written += int64(n)
+	}
+	write(magic)
+	write(binary.LittleEndian.AppendUint64(nil, Version))
+	write(binary.LittleEndian.AppendUint64(nil, s.epoch))
+	write(binary.LittleEndian.AppendUint64(nil, uint64(len(keys))))
+	var offset uint64
+	for i := range keys {
+		entry := append([]byte{}, keys[i][:]...)
+		entry = binary.LittleEndian.AppendUint64(entry, offset)
+		entry = binary.LittleEndian.AppendUint32(entry, uint32(len(s.tables[keys[i]])))
+		write(entry)
+		offset += uint64(len(s.tables[keys[i]])) * solana.PublicKeyLength
+	}
+	for _, key := range keys {
+		addresses := s.tables[key]
+		for i := range addresses {
+			write(addresses[i][:])
+		}
+	}
+	return written, bw.Flush()
+}
+
+// Reader reads the tables of a snapshot file.
+type Reader struct {
+	r         io.ReaderAt
+	epoch     uint64
+	numTables uint64
+}
+
+// NewReader checks the header of a snapshot file.
+func NewReader(r io.ReaderAt) (*Reader, error) {
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if !bytes.Equal(header[:len(magic)], magic) {
+		return nil, fmt.Errorf("invalid magic: %q", header[:len(magic)])
+	}
+	header = header[len(magic):]
+	if version := binary.LittleEndian.Uint64(header); version != Version {
+		return nil, fmt.Errorf("unsupported version: %d", version)
+	}
+	return &Reader{
+		r:         r,
+		epoch:     binary.LittleEndian.Uint64(header[8:]),
+		numTables: binary.LittleEndian.Uint64(header[16:]),
+	}, nil
+}
+
+// Epoch returns the epoch of the snapshot.
+func (r *Reader) Epoch() uint64 {
+	return r.epoch
+}
+
+// Len returns the number of tables in the snapshot.
+func (r *Reader) Len() int {
+	return int(r.numTables)
+}
+
+// Table returns the addresses of a table; the unknown ones are the zero key.
+func (r *Reader) Table(key solana.PublicKey) ([]solana.PublicKey, bool, error) {
+	entry := make([]byte, indexEntrySize)
+	var readErr error
+	i := sort.Search(int(r.numTables), func(i int) bool {
+		if readErr != nil {
+			return true
+		}
+		if _, err := r.r.ReadAt(entry, headerSize+int64(i)*indexEntrySize); err != nil {
+			readErr = fmt.Errorf("failed to read the index of the tables: %w", err)
+			return true
+		}
+		return bytes.Compare(entry[:solana.PublicKeyLength], key[:]) >= 0
+	})
+	if readErr != nil {
+		return nil, false, readErr
+	}
+	if i == int(r.numTables) {
+		return nil, false, nil
+	}
+	if _, err := r.r.ReadAt(entry, headerSize+int64(i)*indexEntrySize); err != nil {
+		return nil, false, fmt.Errorf("failed to read the index of the tables: %w", err)
+	}
+	if !bytes.Equal(entry[:solana.PublicKeyLength], key[:]) {
+		return nil, false, nil
+	}
+	offset := binary.LittleEndian.Uint64(entry[solana.PublicKeyLength:])
+	count := binary.LittleEndian.Uint32(entry[solana.PublicKeyLength+8:])
+	buf := make([]byte, int(count)*solana.PublicKeyLength)
+	dataStart := headerSize + int64(r.numTables)*indexEntrySize
+	if _, err := r.r.ReadAt(buf, dataStart+int64(offset)); err != nil {
+		return nil, false, fmt.Errorf("failed to read the addresses of table %s: %w", key, err)
+	}
+	addresses := make([]solana.PublicKey, count)
+	for j := range addresses {
+		addresses[j] = solana.PublicKeyFromBytes(buf[j*solana.PublicKeyLength:])
+	}
+	return addresses, true, nil
+}
+
+// FromFile reads a whole snapshot file, e.g. to merge it into a new snapshot.
+func FromFile(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	s := NewSnapshot(r.Epoch())
+	for i := 0; i < r.Len(); i++ {
+		offset := headerSize + int64(i)*indexEntrySize
+		if offset+indexEntrySize > int64(len(data)) {
+			return nil, fmt.Errorf("%s: %w", path, io.ErrUnexpectedEOF)
+		}
+		key := solana.PublicKeyFromBytes(data[offset:])
+		addresses, _, err := r.Table(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		s.tables[key] = addresses
+	}
+	return s, nil
+}
+
+// ErrUnresolved is returned when the accounts of a lookup are not in the snapshot.
+var ErrUnresolved = errors.New("the lookup table entry is not in the snapshot")
+
+func FormatFilename(epoch uint64, rootCid cid.Cid, network indexes.Network) string {
+	return fmt.Sprintf(
+		"epoch-%d-%s-%s-%s",
+		epoch,
+		rootCid.String(),
+		network,
+		"lookup-tables.index",
+	)
+}
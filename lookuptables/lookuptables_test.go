@@ -0,0 +1,150 @@
+package lookuptables
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/stretchr/testify/require"
+)
+
+func lookupTransaction(lookups ...solana.MessageAddressTableLookup) *solana.Transaction {
+	tx := &solana.Transaction{
+		Signatures: []solana.Signature{{1}},
+		Message: solana.Message{
+			AccountKeys: solana.PublicKeySlice{{1}, solana.SystemProgramID},
+		},
+	}
+	tx.Message.SetAddressTableLookups(lookups)
+	return tx
+}
+
+func TestLearnWriteRead(t *testing.T) {
+	tableA, tableB := solana.PublicKey{0xa}, solana.PublicKey{0xb}
+	tx := lookupTransaction(
+		solana.MessageAddressTableLookup{AccountKey: tableB, WritableIndexes: []uint8{2}, ReadonlyIndexes: []uint8{0}},
+		solana.MessageAddressTableLookup{AccountKey: tableA, WritableIndexes: []uint8{1}, ReadonlyIndexes: []uint8{}},
+	)
+	s := NewSnapshot(7)
+	// the writable accounts of all the lookups come first.
+	require.NoError(t, s.Learn(tx, &confirmed_block.TransactionStatusMeta{
+		LoadedWritableAddresses: [][]byte{{0xb2}, {0xa1}},
+		LoadedReadonlyAddresses: [][]byte{{0xb0}},
+	}))
+	err := s.Learn(tx, &confirmed_block.TransactionStatusMeta{LoadedWritableAddresses: [][]byte{{0xb2}}})
+	require.ErrorContains(t, err, "the meta has 1 writable and 0 readonly loaded accounts, but the transaction loads 2 and 1")
+
+	table, ok := s.Table(tableB)
+	require.True(t, ok)
+	require.Equal(t, []solana.PublicKey{{0xb0}, {}, {0xb2}}, table)
+
+	buf := new(bytes.Buffer)
+	_, err = s.WriteTo(buf)
+	require.NoError(t, err)
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), r.Epoch())
+	require.Equal(t, 2, r.Len())
+	for _, key := range []solana.PublicKey{tableA, tableB} {
+		expected, _ := s.Table(key)
+		got, ok, err := r.Table(key)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, expected, got)
+	}
+	_, ok, err = r.Table(solana.PublicKey{0xc})
+	require.NoError(t, err)
+	require.False(t, ok)
+	_, ok, err = r.Table(solana.PublicKey{})
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = NewReader(bytes.NewReader(bytes.Repeat([]byte("not a snapshot "), 4)))
+	require.ErrorContains(t, err, "invalid magic")
+}
+
+func TestMergeAndFromFile(t *testing.T) {
+	table := solana.PublicKey{0xa}
+	older := NewSnapshot(6)
+	older.Set(table, 0, solana.PublicKey{1})
+	older.Set(table, 3, solana.PublicKey{3})
+	older.Set(solana.PublicKey{0xb}, 0, solana.PublicKey{4})
+	path := filepath.Join(t.TempDir(), "older.index")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	_, err = older.WriteTo(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	fromFile, err := FromFile(path)
+	require.NoError(t, err)
+	require.Equal(t, older, fromFile)
+
+	s := NewSnapshot(7)
+	s.Set(table, 1, solana.PublicKey{2})
+	s.Merge(fromFile)
+	addresses, _ := s.Table(table)
+	require.Equal(t, []solana.PublicKey{{1}, {2}, {}, {3}}, addresses)
+	require.Equal(t, 2, s.Len())
+	require.Equal(t, uint64(7), s.Epoch())
+}
+
+type countingReaderAt struct {
+	r     io.ReaderAt
+	reads atomic.Int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.reads.Add(1)
+	return c.r.ReadAt(p, off)
+}
+
+func TestResolver(t *testing.T) {
+	tableA, tableB := solana.PublicKey{0xa}, solana.PublicKey{0xb}
+	s := NewSnapshot(7)
+	s.Set(tableA, 0, solana.PublicKey{0xa0})
+	s.Set(tableA, 2, solana.PublicKey{0xa2})
+	s.Set(tableB, 0, solana.PublicKey{0xb0})
+	buf := new(bytes.Buffer)
+	_, err := s.WriteTo(buf)
+	require.NoError(t, err)
+	file := &countingReaderAt{r: bytes.NewReader(buf.Bytes())}
+	reader, err := NewReader(file)
+	require.NoError(t, err)
+	resolver := NewResolver(reader, 1)
+
+	tx := lookupTransaction(solana.MessageAddressTableLookup{AccountKey: tableA, WritableIndexes: []uint8{2}, ReadonlyIndexes: []uint8{0}})
+	tables, err := resolver.Resolve(tx)
+	require.NoError(t, err)
+	require.Equal(t, map[solana.PublicKey]solana.PublicKeySlice{tableA: {{0xa0}, {}, {0xa2}}}, tables)
+	require.NoError(t, tx.Message.SetAddressTables(tables))
+	require.NoError(t, tx.Message.ResolveLookups())
+	require.Equal(t, []solana.PublicKey{{1}, solana.SystemProgramID, {0xa2}, {0xa0}}, []solana.PublicKey(tx.Message.AccountKeys))
+
+	// the table is cached.
+	reads := file.reads.Load()
+	_, err = resolver.Resolve(lookupTransaction(solana.MessageAddressTableLookup{AccountKey: tableA, ReadonlyIndexes: []uint8{2}}))
+	require.NoError(t, err)
+	require.Equal(t, reads, file.reads.Load())
+
+	// an entry, or a table, that is not in the snapshot.
+	_, err = resolver.Resolve(lookupTransaction(solana.MessageAddressTableLookup{AccountKey: tableA, WritableIndexes: []uint8{1}}))
+	require.ErrorIs(t, err, ErrUnresolved)
+	_, err = resolver.Resolve(lookupTransaction(solana.MessageAddressTableLookup{AccountKey: tableA, WritableIndexes: []uint8{3}}))
+	require.ErrorIs(t, err, ErrUnresolved)
+	_, err = resolver.Resolve(lookupTransaction(solana.MessageAddressTableLookup{AccountKey: solana.PublicKey{0xc}, WritableIndexes: []uint8{0}}))
+	require.ErrorIs(t, err, ErrUnresolved)
+
+	// with room for one table, reading another one evicts the first.
+	_, err = resolver.Resolve(lookupTransaction(solana.MessageAddressTableLookup{AccountKey: tableB, WritableIndexes: []uint8{0}}))
+	require.NoError(t, err)
+	reads = file.reads.Load()
+	_, err = resolver.Resolve(lookupTransaction(solana.MessageAddressTableLookup{AccountKey: tableA, WritableIndexes: []uint8{0}}))
+	require.NoError(t, err)
+	require.Greater(t, file.reads.Load(), reads)
+}
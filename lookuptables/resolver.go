@@ -0,0 +1,100 @@
+package lookuptables
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// Resolver resolves the lookups of transactions from a snapshot file, with an LRU cache of
+// the tables read from it (a few tables are used by most of the v0 transactions). The
+// tables that are not in the snapshot are cached too.
+type Resolver struct {
+	reader    *Reader
+	maxTables int
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[solana.PublicKey]*list.Element
+}
+
+type cachedTable struct {
+	key       solana.PublicKey
+	addresses []solana.PublicKey
+	found     bool
+}
+
+// NewResolver creates a resolver that caches up to maxTables tables.
+func NewResolver(reader *Reader, maxTables int) *Resolver {
+	if maxTables <= 0 {
+		maxTables = 1
+	}
+	return &Resolver{
+		reader:    reader,
+		maxTables: maxTables,
+		lru:       list.New(),
+		entries:   make(map[solana.PublicKey]*list.Element),
+	}
+}
+
+// Epoch returns the epoch of the snapshot.
+func (r *Resolver) Epoch() uint64 {
+	return r.reader.Epoch()
+}
+
+func (r *Resolver) table(key solana.PublicKey) (*cachedTable, error) {
+	r.mu.Lock()
+	if elem, ok := r.entries[key]; ok {
+		r.lru.MoveToFront(elem)
+		r.mu.Unlock()
+		return elem.Value.(*cachedTable), nil
+	}
+	r.mu.Unlock()
+
+	addresses, found, err := r.reader.Table(key)
+	if err != nil {
+		return nil, err
+	}
+	table := &cachedTable{key: key, addresses: addresses, found: found}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if elem, ok := r.entries[key]; ok {
+		r.lru.MoveToFront(elem)
+		return elem.Value.(*cachedTable), nil
+	}
+	r.entries[key] = r.lru.PushFront(table)
+	for r.lru.Len() > r.maxTables {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		delete(r.entries, oldest.Value.(*cachedTable).key)
+	}
+	return table, nil
+}
+
+// Resolve returns the tables loaded by a transaction, as expected by
+// solana.Message.SetAddressTables. Every entry of a table that the transaction references must
+// be in the snapshot, otherwise the error wraps ErrUnresolved.
+func (r *Resolver) Resolve(tx *solana.Transaction) (map[solana.PublicKey]solana.PublicKeySlice, error) {
+	tables := make(map[solana.PublicKey]solana.PublicKeySlice, len(tx.Message.AddressTableLookups))
+	for _, lookup := range tx.Message.AddressTableLookups {
+		table, err := r.table(lookup.AccountKey)
+		if err != nil {
+			return nil, err
+		}
+		if !table.found {
+			return nil, fmt.Errorf("table %s: %w", lookup.AccountKey, ErrUnresolved)
+		}
+		for _, indexes := range [][]uint8{lookup.WritableIndexes, lookup.ReadonlyIndexes} {
+			for _, index := range indexes {
+				if int(index) >= len(table.addresses) || table.addresses[index].IsZero() {
+					return nil, fmt.Errorf("table %s, index %d: %w", lookup.AccountKey, index, ErrUnresolved)
+				}
+			}
+		}
+		tables[lookup.AccountKey] = table.addresses
+	}
+	return tables, nil
+}
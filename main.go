@@ -43,23 +43,43 @@ func main() {
 		Name:        "faithful CLI",
 		Version:     gitCommitSHA,
 		Description: "CLI to get, manage and interact with the Solana blockchain data stored in a CAR file or on Filecoin/IPFS.",
-		Flags:       NewKlogFlagSet(),
+		Flags:       append(NewKlogFlagSet(), FlagEpochSchedule),
 		Before: func(cctx *cli.Context) error {
-			return nil
+			return setEpochScheduleFromFlag(cctx)
 		},
 		Action: nil,
 		Commands: []*cli.Command{
 			newCmd_DumpCar(),
 			fetchCmd,
+			newCmd_FetchEpoch(),
 			newCmd_Index(),
 			newCmd_VerifyIndex(),
 			newCmd_XTraverse(),
 			newCmd_Version(),
 			newCmd_rpc(),
+			newCmd_IndexServer(),
 			newCmd_check_deals(),
 			newCmd_MergeCars(),
 			newCmd_SplitCar(),
+			newCmd_CarPatch(),
+			newCmd_CarRechunk(),
+			newCmd_CarMigrate(),
+			newCmd_CreateCar(),
+			newCmd_CheckCar(),
+			newCmd_Extract(),
+			newCmd_ExportBlocks(),
+			newCmd_LoadTables(),
 			newCmd_find_missing_tx_metadata(),
+			newCmd_BackfillTxMeta(),
+			newCmd_Attest(),
+			newCmd_VerifyAttestation(),
+			newCmd_VerifyPoh(),
+			newCmd_VerifyEpochBoundary(),
+			newCmd_RpcSanityCheck(),
+			newCmd_Browse(),
+			newCmd_FindTx(),
+			newCmd_Report(),
+			newCmd_Verify(),
 		},
 	}
 
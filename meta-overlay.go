@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	pagecache "github.com/rpcpool/yellowstone-faithful/page-cache"
+	"k8s.io/klog/v2"
+)
+
+// metaOverlay is the metadata of the transactions that have none in the CAR of an epoch,
+// fetched from another source (see faithful-cli backfill-tx-meta).
+type metaOverlay struct {
+	car   ReaderAtCloser
+	index *indexes.SigToOffsetAndSize_Reader
+}
+
+// openMetaOverlay opens the meta overlay of the config, and checks that it's for the epoch and the CAR (with the root CID).
+func openMetaOverlay(ctx context.Context, config *Config, pages *pagecache.Cache, epoch uint64, rootCid cid.Cid) (*metaOverlay, error) {
+	indexFile, err := openIndexStorage(ctx, string(config.MetaOverlay.Index.URI), pages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open meta overlay index file: %w", err)
+	}
+	index, err := indexes.OpenWithReader_SigToOffsetAndSize(indexFile)
+	if err != nil {
+		indexFile.Close()
+		return nil, fmt.Errorf("failed to open meta overlay index: %w", err)
+	}
+	if index.Meta().Epoch != epoch {
+		index.Close()
+		return nil, fmt.Errorf("epoch mismatch in meta overlay index: expected %d, got %d", epoch, index.Meta().Epoch)
+	}
+	if !rootCid.Equals(index.Meta().RootCid) {
+		index.Close()
+		return nil, fmt.Errorf("root CID mismatch in meta overlay index: expected %s, got %s", rootCid, index.Meta().RootCid)
+	}
+	if config.MetaOverlay.Index.URI.IsRemoteWeb() {
+		index.Prefetch(true)
+	}
+	carFile, err := openIndexStorage(ctx, string(config.MetaOverlay.Car.URI), pages)
+	if err != nil {
+		index.Close()
+		return nil, fmt.Errorf("failed to open meta overlay CAR: %w", err)
+	}
+	return &metaOverlay{car: carFile, index: index}, nil
+}
+
+// fill sets the metadata of the transaction node from the overlay, if the overlay has it.
+func (o *metaOverlay) fill(sig solana.Signature, node *ipldbindcode.Transaction) error {
+	oas, err := o.index.Get(sig)
+	if err != nil {
+		if errors.Is(err, compactindexsized.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to look up transaction %s in the meta overlay: %w", sig, err)
+	}
+	data, err := readNodeFromReaderAtWithOffsetAndSize(o.car, nil, oas.Offset, oas.Size)
+	if err != nil {
+		return fmt.Errorf("failed to read transaction %s from the meta overlay: %w", sig, err)
+	}
+	decoded, err := iplddecoders.DecodeTransaction(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode transaction %s from the meta overlay: %w", sig, err)
+	}
+	if decoded.Slot != node.Slot || (!node.Data.HasNext() && !bytes.Equal(decoded.Data.Bytes(), node.Data.Bytes())) {
+		return fmt.Errorf("transaction %s of the meta overlay is not the transaction of the CAR", sig)
+	}
+	klog.V(4).Infof("Using the metadata of transaction %s from the meta overlay", sig)
+	node.Metadata = decoded.Metadata
+	return nil
+}
+
+func (o *metaOverlay) Close() error {
+	return errors.Join(o.index.Close(), o.car.Close())
+}
+
+// isMetaMissing returns true if the transaction node has no metadata.
+func isMetaMissing(node *ipldbindcode.Transaction) bool {
+	return len(node.Metadata.Bytes()) == 0 && !node.Metadata.HasNext()
+}
@@ -1,3 +1,5 @@
+// Package metrics defines the Prometheus metrics of the server. The request and epoch metrics
+// have a network label: the namespace of the epochs (empty for the default one).
 package metrics
 
 import (
@@ -10,7 +12,7 @@ var RpcRequestByMethod = promauto.NewCounterVec(
 		Name: "rpc_requests_by_method",
 		Help: "RPC requests by method",
 	},
-	[]string{"method"},
+	[]string{"method", "network"},
 )
 
 var EpochsAvailable = promauto.NewGaugeVec(
@@ -18,7 +20,7 @@ var EpochsAvailable = promauto.NewGaugeVec(
 		Name: "epoch_available",
 		Help: "Epochs available",
 	},
-	[]string{"epoch"},
+	[]string{"epoch", "network"},
 )
 
 var StatusCode = promauto.NewCounterVec(
@@ -26,7 +28,7 @@ var StatusCode = promauto.NewCounterVec(
 		Name: "status_code",
 		Help: "Status code",
 	},
-	[]string{"code"},
+	[]string{"code", "network"},
 )
 
 var MethodToCode = promauto.NewCounterVec(
@@ -34,7 +36,7 @@ var MethodToCode = promauto.NewCounterVec(
 		Name: "method_to_code",
 		Help: "Method to code",
 	},
-	[]string{"method", "code"},
+	[]string{"method", "code", "network"},
 )
 
 var MethodToSuccessOrFailure = promauto.NewCounterVec(
@@ -42,7 +44,7 @@ var MethodToSuccessOrFailure = promauto.NewCounterVec(
 		Name: "method_to_success_or_failure",
 		Help: "Method to success or failure",
 	},
-	[]string{"method", "status"},
+	[]string{"method", "status", "network"},
 )
 
 var MethodToNumProxied = promauto.NewCounterVec(
@@ -50,7 +52,7 @@ var MethodToNumProxied = promauto.NewCounterVec(
 		Name: "method_to_num_proxied",
 		Help: "Method to num proxied",
 	},
-	[]string{"method"},
+	[]string{"method", "network"},
 )
 
 // - Version information of this binary
@@ -68,7 +70,7 @@ var RpcResponseLatencyHistogram = promauto.NewHistogramVec(
 		Help:    "RPC response latency histogram",
 		Buckets: latencyBuckets,
 	},
-	[]string{"rpc_method"},
+	[]string{"rpc_method", "network"},
 )
 
 var IndexLookupHistogram = promauto.NewHistogramVec(
@@ -99,3 +101,144 @@ var latencyBuckets = []float64{
 	// then 10-60 with increments of 5
 	15, 20, 25, 30, 35, 40, 45, 50, 55, 60,
 }
+
+var PanicsRecovered = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "panics_recovered",
+		Help: "Panics recovered while serving a request, by component and method",
+	},
+	[]string{"component", "method"},
+)
+
+var BlockMemoryReserved = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "block_memory_reserved_bytes",
+		Help: "Memory reserved by the blocks being assembled, out of the block memory budget",
+	},
+)
+
+var BlockMemoryWaits = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Name: "block_memory_waits",
+		Help: "Block requests that waited for room in the block memory budget",
+	},
+)
+
+var BlockMemoryRejections = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Name: "block_memory_rejections",
+		Help: "Block requests rejected because the block memory budget stayed full",
+	},
+)
+
+var GRPCStreamsActive = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "grpc_streams_active",
+		Help: "gRPC streams being served, by method",
+	},
+	[]string{"method"},
+)
+
+var GRPCStreamsRejected = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "grpc_streams_rejected",
+		Help: "gRPC streams rejected because the client had too many concurrent streams, by method",
+	},
+	[]string{"method"},
+)
+
+var GRPCStreamBytesSent = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "grpc_stream_bytes_sent",
+		Help: "Bytes of the messages sent on gRPC streams, by method",
+	},
+	[]string{"method"},
+)
+
+var GRPCStreamMemoryReserved = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "grpc_stream_memory_reserved_bytes",
+		Help: "Block memory reserved by the gRPC streams, out of the stream memory limit",
+	},
+)
+
+var HedgedEpochQueries = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Name: "hedged_epoch_queries",
+		Help: "Queries of remote epochs that were slow enough to start a second, hedged attempt",
+	},
+)
+
+var ShadowRequests = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "shadow_requests",
+		Help: "Requests shadowed to the comparison target, by method and outcome (match, mismatch, error, dropped)",
+	},
+	[]string{"method", "outcome"},
+)
+
+var ShadowLatencyDelta = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "shadow_latency_delta_seconds",
+		Help:    "Latency of the shadow target minus the local latency, for the shadowed requests",
+		Buckets: []float64{-5, -2, -1, -0.5, -0.25, -0.1, -0.05, -0.01, 0, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+	},
+	[]string{"method"},
+)
+
+var EpochsOpen = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "epochs_open",
+		Help: "Epochs opened on first use that are open (see --max-open-epochs and --epoch-idle-timeout)",
+	},
+	[]string{"network"},
+)
+
+var EpochOpens = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "epoch_opens",
+		Help: "Openings of the epochs opened on first use, by outcome (ok, error)",
+	},
+	[]string{"network", "outcome"},
+)
+
+var EpochUnloads = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "epoch_unloads",
+		Help: "Epochs unloaded, by reason (idle, lru)",
+	},
+	[]string{"network", "reason"},
+)
+
+var EpochOpenSeconds = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "epoch_open_seconds",
+		Help:    "Time to open an epoch on first use",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	},
+	[]string{"network"},
+)
+
+var CarScheduledReads = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "car_scheduled_reads",
+		Help: "Reads of remote CAR files by the read scheduler, by access pattern and whether they were served from memory (read ahead or cached)",
+	},
+	[]string{"pattern", "from_memory"},
+)
+
+var VerifyChecks = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "verify_checks",
+		Help: "Checks of the sampled slots by faithful-cli verify, by check and outcome (ok, mismatch, error)",
+	},
+	[]string{"network", "epoch", "check", "outcome"},
+)
+
+var VerifyEpochHealthy = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "verify_epoch_healthy",
+		Help: "Whether faithful-cli verify has found no mismatch in the epoch since it started (1) or has found one (0)",
+	},
+	[]string{"network", "epoch"},
+)
@@ -1,29 +1,27 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"io"
+	"math"
 	"runtime"
-	"sort"
 	"sync"
 
 	"github.com/gagliardetto/solana-go"
-	"github.com/ipfs/go-cid"
-	"github.com/ipld/go-car/util"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	genesisblock "github.com/rpcpool/yellowstone-faithful/genesis-block"
 	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
 	"github.com/rpcpool/yellowstone-faithful/slottools"
 	solanablockrewards "github.com/rpcpool/yellowstone-faithful/solana-block-rewards"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
 	"github.com/rpcpool/yellowstone-faithful/tooling"
 	"github.com/sourcegraph/jsonrpc2"
 	"golang.org/x/sync/errgroup"
-	"k8s.io/klog/v2"
 )
 
 var fasterJson = jsoniter.ConfigCompatibleWithStandardLibrary
@@ -45,7 +43,7 @@ func getRequestIDFromContext(ctx context.Context) string {
 }
 
 func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (*jsonrpc2.Error, error) {
-	tim := newTimer(getRequestIDFromContext(ctx))
+	reqLog := rpcRequestLogFromContext(ctx)
 	params, err := parseGetBlockRequest(req.Params)
 	if err != nil {
 		return &jsonrpc2.Error{
@@ -59,26 +57,23 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 			Message: err.Error(),
 		}, fmt.Errorf("failed to validate params: %w", err)
 	}
-	tim.time("parseGetBlockRequest")
+	reqLog.Step("parseGetBlockRequest")
 	slot := params.Slot
+	reqLog.SetSlot(slot)
 
 	// find the epoch that contains the requested slot
 	epochNumber := slottools.CalcEpochForSlot(slot)
-	epochHandler, err := multi.GetEpoch(epochNumber)
+	reqLog.SetEpoch(epochNumber)
+	epochHandler, releaseEpoch, err := multi.GetEpoch(epochNumber)
 	if err != nil {
-		return &jsonrpc2.Error{
-			Code:    CodeNotFound,
-			Message: fmt.Sprintf("Epoch %d is not available", epochNumber),
-		}, fmt.Errorf("failed to get epoch %d: %w", epochNumber, err)
+		return multi.errorForMissingSlot(ctx, slot), fmt.Errorf("failed to get epoch %d: %w", epochNumber, err)
 	}
+	defer releaseEpoch()
 
 	block, blockCid, err := epochHandler.GetBlock(WithSubrapghPrefetch(ctx, true), slot)
 	if err != nil {
 		if errors.Is(err, compactindexsized.ErrNotFound) {
-			return &jsonrpc2.Error{
-				Code:    CodeNotFound,
-				Message: fmt.Sprintf("Slot %d was skipped, or missing in long-term storage", slot),
-			}, err
+			return rpcerrors.NewSlotSkipped(slot), err
 		} else {
 			return &jsonrpc2.Error{
 				Code:    jsonrpc2.CodeInternalError,
@@ -91,132 +86,40 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 		conn.ctx.Response.Header.Set("DAG-Root-CID", blockCid.String())
 	}
 
-	tim.time("GetBlock")
-	{
-		prefetcherFromCar := func() error {
-			parentIsInPreviousEpoch := slottools.CalcEpochForSlot(uint64(block.Meta.Parent_slot)) != slottools.CalcEpochForSlot(slot)
-			if slot == 0 {
-				parentIsInPreviousEpoch = true
-			}
-			if slot > 1 && block.Meta.Parent_slot == 0 {
-				parentIsInPreviousEpoch = true
-			}
-
-			var blockCid, parentBlockCid cid.Cid
-			wg := new(errgroup.Group)
-			wg.Go(func() (err error) {
-				blockCid, err = epochHandler.FindCidFromSlot(ctx, slot)
-				if err != nil {
-					return err
-				}
-				return nil
-			})
-			wg.Go(func() (err error) {
-				if parentIsInPreviousEpoch {
-					return nil
-				}
-				parentBlockCid, err = epochHandler.FindCidFromSlot(ctx, uint64(block.Meta.Parent_slot))
-				if err != nil {
-					return err
-				}
-				return nil
-			})
-			err = wg.Wait()
-			if err != nil {
-				return err
-			}
-			if slot == 0 {
-				klog.V(4).Infof("car start to slot(0)::%s", blockCid)
-			} else {
-				klog.V(4).Infof(
-					"slot(%d)::%s to slot(%d)::%s",
-					uint64(block.Meta.Parent_slot),
-					parentBlockCid,
-					slot,
-					blockCid,
-				)
-			}
-			{
-				var blockOffset, parentOffset uint64
-				wg := new(errgroup.Group)
-				wg.Go(func() (err error) {
-					offsetAndSize, err := epochHandler.FindOffsetAndSizeFromCid(ctx, blockCid)
-					if err != nil {
-						return err
-					}
-					blockOffset = offsetAndSize.Offset
-					return nil
-				})
-				wg.Go(func() (err error) {
-					if parentIsInPreviousEpoch {
-						// get car file header size
-						parentOffset = epochHandler.carHeaderSize
-						return nil
-					}
-					offsetAndSize, err := epochHandler.FindOffsetAndSizeFromCid(ctx, parentBlockCid)
-					if err != nil {
-						return err
-					}
-					parentOffset = offsetAndSize.Offset
-					return nil
-				})
-				err = wg.Wait()
-				if err != nil {
-					return err
-				}
-
-				length := blockOffset - parentOffset
-				MiB := uint64(1024 * 1024)
-				maxPrefetchSize := MiB * 10 // let's cap prefetching size
-				if length > maxPrefetchSize {
-					length = maxPrefetchSize
-				}
-
-				start := parentOffset
-
-				klog.V(4).Infof("prefetching CAR: start=%d length=%d (parent_offset=%d)", start, length, parentOffset)
-				carSection, err := epochHandler.ReadAtFromCar(ctx, start, length)
-				if err != nil {
-					return err
-				}
-				dr := bytes.NewReader(carSection)
-				br := bufio.NewReader(dr)
-
-				gotCid, data, err := util.ReadNode(br)
-				if err != nil {
-					return fmt.Errorf("failed to read first node: %w", err)
-				}
-				if !parentIsInPreviousEpoch && !gotCid.Equals(parentBlockCid) {
-					return fmt.Errorf("CID mismatch: expected %s, got %s", parentBlockCid, gotCid)
-				}
-				epochHandler.GetCache().PutRawCarObject(gotCid, data)
-
-				for {
-					gotCid, data, err = util.ReadNode(br)
-					if err != nil {
-						if errors.Is(err, io.EOF) {
-							break
-						}
-						return fmt.Errorf("failed to read node: %w", err)
-					}
-					if gotCid.Equals(blockCid) {
-						break
-					}
-					epochHandler.GetCache().PutRawCarObject(gotCid, data)
-				}
-			}
-			return nil
+	reqLog.Step("GetBlock")
+	var section *blockCarSection
+	if epochHandler.lassieFetcher == nil {
+		section, err = epochHandler.findBlockCarSection(ctx, slot, uint64(block.Meta.Parent_slot))
+		if err != nil {
+			reqLog.Logger().Error("failed to find block in car", "error", err)
 		}
-		if epochHandler.lassieFetcher == nil {
-			err := prefetcherFromCar()
-			if err != nil {
-				klog.Errorf("failed to prefetch from car: %v", err)
-			}
+	}
+	release, err := multi.blockMemory.reserve(ctx, blockMemoryEstimate(section))
+	if err != nil {
+		return rpcerrors.NewNodeBusy(), fmt.Errorf("failed to reserve memory for block: %w", err)
+	}
+	defer release()
+	reqLog.Step("reserveBlockMemory")
+	if section != nil {
+		reqLog.Logger().Debug(
+			"prefetch range",
+			"parent_slot", uint64(block.Meta.Parent_slot),
+			"parent_cid", section.parentCid,
+			"cid", section.blockCid,
+			"start", section.start,
+			"end", section.end,
+		)
+		if err := epochHandler.prefetchBlockCarSection(ctx, section); err != nil {
+			reqLog.Logger().Error("failed to prefetch from car", "error", err)
 		}
 	}
 	blocktime := uint64(block.Meta.Blocktime)
 
 	allTransactionNodes := make([][]*ipldbindcode.Transaction, len(block.Entries))
+	var entrySummaries []nodetools.EntrySummary
+	if *params.Options.ShowEntries {
+		entrySummaries = make([]nodetools.EntrySummary, len(block.Entries))
+	}
 	mu := &sync.Mutex{}
 	var lastEntryHash solana.Hash
 	{
@@ -230,13 +133,16 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 				// get the entry by CID
 				entryNode, err := epochHandler.GetEntryByCid(ctx, entryCid)
 				if err != nil {
-					klog.Errorf("failed to decode Entry: %v", err)
+					reqLog.Logger().Error("failed to decode Entry", "error", err)
 					return err
 				}
 
 				if entryIndex == len(block.Entries)-1 {
 					lastEntryHash = solana.HashFromBytes(entryNode.Hash)
 				}
+				if entrySummaries != nil {
+					entrySummaries[entryIndex] = nodetools.SummarizeEntry(entryNode)
+				}
 
 				twg := new(errgroup.Group)
 				twg.SetLimit(runtime.NumCPU())
@@ -250,7 +156,7 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 						tcid := tx.(cidlink.Link).Cid
 						txNode, err := epochHandler.GetTransactionByCid(ctx, tcid)
 						if err != nil {
-							klog.Errorf("failed to decode Transaction %s: %v", tcid, err)
+							reqLog.Logger().Error("failed to decode Transaction", "cid", tcid, "error", err)
 							return nil
 						}
 						mu.Lock()
@@ -270,10 +176,12 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 			}, fmt.Errorf("failed to get entries: %v", err)
 		}
 	}
-	tim.time("get entries")
+	reqLog.Step("get entries")
 
 	var allTransactions []GetTransactionResponse
 	var rewards any
+	var numRewardPartitions *uint64
+	var rewardsTotal *int
 	hasRewards := !block.Rewards.(cidlink.Link).Cid.Equals(DummyCID)
 	if *params.Options.Rewards && hasRewards {
 		rewardsNode, err := epochHandler.GetRewardsByCid(ctx, block.Rewards.(cidlink.Link).Cid)
@@ -283,7 +191,7 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 				Message: "Internal error",
 			}, fmt.Errorf("failed to decode Rewards: %v", err)
 		}
-		rewardsBuf, err := tooling.LoadDataFromDataFrames(&rewardsNode.Data, epochHandler.GetDataFrameByCid)
+		rewardsChunks, err := tooling.LoadChunksFromDataFrames(&rewardsNode.Data, epochHandler.GetDataFrameByCid)
 		if err != nil {
 			return &jsonrpc2.Error{
 				Code:    jsonrpc2.CodeInternalError,
@@ -291,19 +199,42 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 			}, fmt.Errorf("failed to load Rewards dataFrames: %v", err)
 		}
 
-		uncompressedRewards, err := tooling.DecompressZstd(rewardsBuf)
+		uncompressedRewards, err := tooling.DecompressZstdChunks(rewardsChunks)
 		if err != nil {
 			return &jsonrpc2.Error{
 				Code:    jsonrpc2.CodeInternalError,
 				Message: "Internal error",
 			}, fmt.Errorf("failed to decompress Rewards: %v", err)
 		}
-		// try decoding as protobuf
-		actualRewards, err := solanablockrewards.ParseRewards(uncompressedRewards)
+		// try decoding as protobuf; only the requested page of the rewards is decoded.
+		var offset, limit int
+		if params.Options.RewardsOffset != nil {
+			offset = int(min(*params.Options.RewardsOffset, math.MaxInt32))
+		}
+		if params.Options.RewardsLimit != nil {
+			limit = int(min(*params.Options.RewardsLimit, math.MaxInt32))
+		}
+		rewardsPage, totalRewards, err := solanablockrewards.ParseRewardsRange(uncompressedRewards, offset, limit)
 		if err != nil {
 			// TODO: add support for legacy rewards format
 			fmt.Println("Rewards are not protobuf: " + err.Error())
 		} else {
+			// the first block of an epoch with partitioned rewards has the number of partitions
+			// in which the staking rewards are paid by the following blocks.
+			numPartitions, partitioned, err := solanablockrewards.NumPartitions(uncompressedRewards)
+			if err != nil {
+				return &jsonrpc2.Error{
+					Code:    jsonrpc2.CodeInternalError,
+					Message: "Internal error",
+				}, fmt.Errorf("failed to decode the number of reward partitions: %v", err)
+			}
+			if partitioned {
+				numRewardPartitions = &numPartitions
+			}
+			if params.Options.RewardsOffset != nil || params.Options.RewardsLimit != nil {
+				rewardsTotal = &totalRewards
+			}
+			actualRewards := &confirmed_block.Rewards{Rewards: rewardsPage}
 			{
 				// encode rewards as JSON, then decode it as a map
 				buf, err := fasterJson.Marshal(actualRewards)
@@ -363,7 +294,7 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 					// 	return bytes.Compare(solana.MPK(rewardsAsArray[i].(map[string]any)["pubkey"].(string)).Bytes(), solana.MPK(rewardsAsArray[j].(map[string]any)["pubkey"].(string)).Bytes()) < 0
 					// })
 				} else {
-					klog.Errorf("did not find rewards field in rewards")
+					// no rewards in the list (e.g. only the number of partitions).
 					rewards = make([]any, 0)
 				}
 			}
@@ -371,9 +302,10 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 	} else {
 		rewards = make([]any, 0)
 	}
-	tim.time("get rewards")
+	reqLog.Step("get rewards")
 	{
-		for _, transactionNode := range mergeTxNodeSlices(allTransactionNodes) {
+		for _, positioned := range nodetools.SortedTransactions(mergeTxNodeSlices(allTransactionNodes)) {
+			transactionNode := positioned.Node
 			var txResp GetTransactionResponse
 
 			// response.Slot = uint64(transactionNode.Slot)
@@ -382,11 +314,8 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 			// }
 
 			{
-				pos, ok := transactionNode.GetPositionIndex()
-				if ok {
-					txResp.Position = uint64(pos)
-				}
-				tx, meta, err := parseTransactionAndMetaFromNode(transactionNode, epochHandler.GetDataFrameByCid)
+				txResp.Position = positioned.Position
+				tx, meta, err := parseTransactionAndMetaFromNode(ctx, transactionNode, epochHandler.GetDataFrameByCid)
 				if err != nil {
 					return &jsonrpc2.Error{
 						Code:    jsonrpc2.CodeInternalError,
@@ -400,7 +329,7 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 					txResp.Version = "legacy"
 				}
 
-				encodedTx, encodedMeta, err := encodeTransactionResponseBasedOnWantedEncoding(*params.Options.Encoding, tx, meta)
+				encodedTx, encodedMeta, err := encodeTransactionResponseBasedOnWantedEncoding(ctx, *params.Options.Encoding, tx, meta, epochHandler.LookupTables())
 				if err != nil {
 					return &jsonrpc2.Error{
 						Code:    jsonrpc2.CodeInternalError,
@@ -415,10 +344,7 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 		}
 	}
 
-	sort.Slice(allTransactions, func(i, j int) bool {
-		return allTransactions[i].Position < allTransactions[j].Position
-	})
-	tim.time("get transactions")
+	reqLog.Step("get transactions")
 	var blockResp GetBlockResponse
 	blockResp.Transactions = allTransactions
 	if blocktime != 0 {
@@ -427,20 +353,25 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 	blockResp.Blockhash = lastEntryHash.String()
 	blockResp.ParentSlot = uint64(block.Meta.Parent_slot)
 	blockResp.Rewards = rewards
+	blockResp.NumRewardPartitions = numRewardPartitions
+	blockResp.RewardsTotal = rewardsTotal
+	blockResp.Entries = entrySummaries
+	if leader, ok := epochHandler.GetLeader(slot); ok {
+		leaderStr := leader.String()
+		blockResp.Leader = &leaderStr
+	}
 
-	if slot == 0 {
-		genesis := epochHandler.GetGenesis()
-		if genesis != nil {
-			blockZeroBlocktime := uint64(genesis.Config.CreationTime.Unix())
+	if genesisblock.IsBlockZero(slot) {
+		blockZero := genesisblock.ForBlockZero(epochHandler.GetGenesis(), lastEntryHash)
+		if blockZero.HasBlocktime {
+			blockZeroBlocktime := uint64(blockZero.Blocktime)
 			blockResp.BlockTime = &blockZeroBlocktime
 		}
-		blockResp.ParentSlot = uint64(0)
-
-		zeroBlockHeight := uint64(0)
-		blockResp.BlockHeight = &zeroBlockHeight
+		blockResp.ParentSlot = blockZero.ParentSlot
+		blockResp.BlockHeight = &blockZero.BlockHeight
 
-		blockZeroBlockHash := lastEntryHash.String()
-		blockResp.PreviousBlockhash = &blockZeroBlockHash // NOTE: this is what solana RPC does. Should it be nil instead? Or should it be the genesis hash?
+		blockZeroPreviousBlockhash := blockZero.PreviousBlockhash.String()
+		blockResp.PreviousBlockhash = &blockZeroPreviousBlockhash
 	}
 
 	{
@@ -477,11 +408,11 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 			}
 		} else {
 			if slot != 0 {
-				klog.V(4).Infof("parent slot is in a different epoch, not implemented yet (can't get previousBlockhash)")
+				reqLog.Logger().Debug("parent slot is in a different epoch, not implemented yet (can't get previousBlockhash)")
 			}
 		}
 	}
-	tim.time("get parent block")
+	reqLog.Step("get parent block")
 
 	{
 		if len(blockResp.Transactions) == 0 {
@@ -511,8 +442,9 @@ func (multi *MultiEpoch) handleGetBlock(ctx context.Context, conn *requestContex
 
 			return m
 		},
+		getBlockFieldOrder,
 	)
-	tim.time("reply")
+	reqLog.Step("reply")
 	if err != nil {
 		return nil, fmt.Errorf("failed to reply: %w", err)
 	}
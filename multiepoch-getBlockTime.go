@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	genesisblock "github.com/rpcpool/yellowstone-faithful/genesis-block"
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
 	"github.com/rpcpool/yellowstone-faithful/slottools"
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -19,22 +21,20 @@ func (multi *MultiEpoch) handleGetBlockTime(ctx context.Context, conn *requestCo
 
 	// find the epoch that contains the requested slot
 	epochNumber := slottools.CalcEpochForSlot(blockNum)
-	epochHandler, err := multi.GetEpoch(epochNumber)
+	epochHandler, release, err := multi.GetEpoch(epochNumber)
 	if err != nil {
-		return &jsonrpc2.Error{
-			Code:    CodeNotFound,
-			Message: fmt.Sprintf("Epoch %d is not available", epochNumber),
-		}, fmt.Errorf("failed to get epoch %d: %w", epochNumber, err)
+		return multi.errorForMissingSlot(ctx, blockNum), fmt.Errorf("failed to get epoch %d: %w", epochNumber, err)
 	}
+	defer release()
 	{
 		blocktimeIndex := epochHandler.GetBlocktimeIndex()
 		if blocktimeIndex != nil {
 			blockTime, err := blocktimeIndex.Get(blockNum)
 			if err != nil {
-				return &jsonrpc2.Error{
-					Code:    CodeNotFound,
-					Message: fmt.Sprintf("Slot %d was skipped, or missing in long-term storage", blockNum),
-				}, fmt.Errorf("failed to get blocktime: %w", err)
+				return rpcerrors.NewSlotSkipped(blockNum), fmt.Errorf("failed to get blocktime: %w", err)
+			}
+			if genesis := epochHandler.GetGenesis(); genesisblock.IsBlockZero(blockNum) && genesis != nil {
+				blockTime = genesis.Blocktime()
 			}
 			err = conn.ReplyRaw(
 				ctx,
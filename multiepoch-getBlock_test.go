@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/leaderschedule"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/rpcpool/yellowstone-faithful/poh"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// TestGetBlockTransactionOrder checks that getBlock (JSON-RPC/REST and gRPC) returns the transactions
+// in the order of the entries of the block, as written in the CAR.
+func TestGetBlockTransactionOrder(t *testing.T) {
+	config, _, _, _ := buildTestEpoch(t)
+	epoch := openTestEpoch(t, config)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+	ctx := context.Background()
+
+	firstSignature := func(data []byte) solana.Signature {
+		sigs, err := poh.Signatures(data)
+		require.NoError(t, err)
+		return sigs[0]
+	}
+
+	source := carwriter.NewCarSource(string(config.Data.Car.URI))
+	defer source.Close()
+	numTransactions := 0
+	err := source.Blocks(ctx, 0, 9, func(block *carwriter.Block) error {
+		var want []solana.Signature
+		for _, entry := range block.Entries {
+			for _, tx := range entry.Transactions {
+				want = append(want, firstSignature(tx.Data))
+			}
+		}
+		numTransactions += len(want)
+
+		grpcBlock, err := multi.GetBlock(ctx, &old_faithful_grpc.BlockRequest{Slot: block.Slot})
+		require.NoError(t, err)
+		var got []solana.Signature
+		for i, tx := range grpcBlock.Transactions {
+			require.NotNil(t, tx.Index)
+			require.Equal(t, uint64(i), *tx.Index)
+			got = append(got, firstSignature(tx.Transaction))
+		}
+		require.Equal(t, want, got, "gRPC slot %d", block.Slot)
+
+		var req fasthttp.Request
+		req.SetRequestURI("/api/v1/block/" + strconv.FormatUint(block.Slot, 10) + "?encoding=base64&rewards=false")
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		multi.apiHandler(&reqCtx)
+		require.Equal(t, fasthttp.StatusOK, reqCtx.Response.StatusCode(), string(reqCtx.Response.Body()))
+		var restBlock struct {
+			Transactions []struct {
+				Transaction []string `json:"transaction"`
+			} `json:"transactions"`
+		}
+		require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &restBlock))
+		got = got[:0]
+		for _, tx := range restBlock.Transactions {
+			data, err := base64.StdEncoding.DecodeString(tx.Transaction[0])
+			require.NoError(t, err)
+			got = append(got, firstSignature(data))
+		}
+		require.Equal(t, want, got, "REST slot %d", block.Slot)
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotZero(t, numTransactions)
+}
+
+func TestGetBlockShowEntries(t *testing.T) {
+	config, _, slots, _ := buildTestEpoch(t)
+	epoch := openTestEpoch(t, config)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+
+	type restBlock struct {
+		Blockhash string `json:"blockhash"`
+		Entries   []struct {
+			NumHashes        uint64 `json:"numHashes"`
+			Hash             string `json:"hash"`
+			TransactionCount int    `json:"transactionCount"`
+		} `json:"entries"`
+	}
+	get := func(slot uint64, query string) restBlock {
+		var req fasthttp.Request
+		req.SetRequestURI("/api/v1/block/" + strconv.FormatUint(slot, 10) + query)
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		multi.apiHandler(&reqCtx)
+		require.Equal(t, fasthttp.StatusOK, reqCtx.Response.StatusCode(), string(reqCtx.Response.Body()))
+		var block restBlock
+		require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &block))
+		return block
+	}
+
+	source := carwriter.NewCarSource(string(config.Data.Car.URI))
+	defer source.Close()
+	err := source.Blocks(context.Background(), slots[0], slots[2], func(block *carwriter.Block) error {
+		got := get(block.Slot, "?rewards=false&showEntries=true")
+		require.Len(t, got.Entries, len(block.Entries))
+		for i, entry := range block.Entries {
+			require.Equal(t, entry.NumHashes, got.Entries[i].NumHashes)
+			require.Equal(t, entry.Hash.String(), got.Entries[i].Hash)
+			require.Equal(t, len(entry.Transactions), got.Entries[i].TransactionCount)
+		}
+		require.Equal(t, got.Blockhash, got.Entries[len(got.Entries)-1].Hash)
+
+		// the entries are only returned on request.
+		require.Nil(t, get(block.Slot, "?rewards=false").Entries)
+		return nil
+	})
+	require.NoError(t, err)
+
+	raw := json.RawMessage(`[1, {"showEntries": "yes"}]`)
+	_, err = parseGetBlockRequest(&raw)
+	require.ErrorContains(t, err, "showEntries must be a boolean")
+}
+
+func TestGetBlockLeader(t *testing.T) {
+	config, _, slots, _ := buildTestEpoch(t)
+	leaderOf := func(epoch *Epoch, slot uint64) *string {
+		multi := NewMultiEpoch(&Options{})
+		require.NoError(t, multi.AddEpoch(0, epoch))
+		var req fasthttp.Request
+		req.SetRequestURI("/api/v1/block/" + strconv.FormatUint(slot, 10) + "?rewards=false")
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		multi.apiHandler(&reqCtx)
+		require.Equal(t, fasthttp.StatusOK, reqCtx.Response.StatusCode(), string(reqCtx.Response.Body()))
+		var block struct {
+			Leader *string `json:"leader"`
+		}
+		require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &block))
+		return block.Leader
+	}
+
+	// without a leader schedule.
+	require.Nil(t, leaderOf(openTestEpoch(t, config), slots[1]))
+
+	a, b := solana.PublicKey{1}, solana.PublicKey{2}
+	t.Run("stakes", func(t *testing.T) {
+		withStakes := *config
+		withStakes.LeaderSchedule.Stakes = map[string]uint64{a.String(): 1, b.String(): 3}
+		require.NoError(t, withStakes.Validate())
+		want, err := leaderschedule.FromStakes(0, 0, 432_000, map[solana.PublicKey]uint64{a: 1, b: 3})
+		require.NoError(t, err)
+		epoch := openTestEpoch(t, &withStakes)
+		for _, slot := range slots[1:4] {
+			leader, ok := want.Leader(slot)
+			require.True(t, ok)
+			got := leaderOf(epoch, slot)
+			require.NotNil(t, got)
+			require.Equal(t, leader.String(), *got)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "leader-schedule.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"`+a.String()+`": [`+strconv.FormatUint(slots[1], 10)+`]}`), 0o644))
+		withFile := *config
+		withFile.LeaderSchedule.URI = URI(path)
+		require.NoError(t, withFile.Validate())
+		withFile.LeaderSchedule.Stakes = map[string]uint64{a.String(): 1}
+		require.Error(t, withFile.Validate())
+		withFile.LeaderSchedule.Stakes = nil
+
+		epoch := openTestEpoch(t, &withFile)
+		got := leaderOf(epoch, slots[1])
+		require.NotNil(t, got)
+		require.Equal(t, a.String(), *got)
+		require.Nil(t, leaderOf(epoch, slots[2]))
+	})
+}
+
+func TestGetBlockRewardPartitions(t *testing.T) {
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	_, err := carfixture.Generate(carPath, []carfixture.BlockSpec{
+		{Slot: 0, Entries: [][]carfixture.TransactionSpec{{}}, NumRewards: 3, NumRewardPartitions: 4},
+		{Slot: 1, Entries: [][]carfixture.TransactionSpec{{}}, NumRewards: 2},
+		{Slot: 2, Entries: [][]carfixture.TransactionSpec{{}}, NumRewardPartitions: 4},
+	})
+	require.NoError(t, err)
+	paths, _, _, err := buildTestEpochIndexes(carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, openTestEpoch(t, testEpochConfig(t, carPath, paths))))
+
+	get := func(slot uint64, query string) map[string]any {
+		var req fasthttp.Request
+		req.SetRequestURI("/api/v1/block/" + strconv.FormatUint(slot, 10) + query)
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		multi.apiHandler(&reqCtx)
+		require.Equal(t, fasthttp.StatusOK, reqCtx.Response.StatusCode(), string(reqCtx.Response.Body()))
+		var block map[string]any
+		require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &block))
+		return block
+	}
+
+	block := get(0, "?rewards=true")
+	require.Equal(t, 4.0, block["numRewardPartitions"])
+	require.Len(t, block["rewards"], 3)
+
+	block = get(1, "?rewards=true")
+	require.NotContains(t, block, "numRewardPartitions")
+	require.Len(t, block["rewards"], 2)
+
+	// only the number of partitions, without rewards.
+	block = get(2, "?rewards=true")
+	require.Equal(t, 4.0, block["numRewardPartitions"])
+	require.Equal(t, []any{}, block["rewards"])
+
+	require.NotContains(t, get(0, "?rewards=false"), "numRewardPartitions")
+}
+
+func TestGetBlockRewardsPage(t *testing.T) {
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	_, err := carfixture.Generate(carPath, []carfixture.BlockSpec{
+		{Slot: 0, Entries: [][]carfixture.TransactionSpec{{}}, NumRewards: 10},
+	})
+	require.NoError(t, err)
+	paths, _, _, err := buildTestEpochIndexes(carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, openTestEpoch(t, testEpochConfig(t, carPath, paths))))
+
+	type restBlock struct {
+		Rewards []struct {
+			Pubkey string `json:"pubkey"`
+		} `json:"rewards"`
+		RewardsTotal *int `json:"rewardsTotal"`
+	}
+	get := func(query string) (int, restBlock) {
+		var req fasthttp.Request
+		req.SetRequestURI("/api/v1/block/0" + query)
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		multi.apiHandler(&reqCtx)
+		var block restBlock
+		if reqCtx.Response.StatusCode() == fasthttp.StatusOK {
+			require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &block))
+		}
+		return reqCtx.Response.StatusCode(), block
+	}
+
+	status, all := get("")
+	require.Equal(t, fasthttp.StatusOK, status)
+	require.Len(t, all.Rewards, 10)
+	require.Nil(t, all.RewardsTotal)
+
+	status, page := get("?rewardsOffset=3&rewardsLimit=4")
+	require.Equal(t, fasthttp.StatusOK, status)
+	require.Equal(t, all.Rewards[3:7], page.Rewards)
+	require.Equal(t, 10, *page.RewardsTotal)
+
+	status, page = get("?rewardsOffset=8")
+	require.Equal(t, fasthttp.StatusOK, status)
+	require.Equal(t, all.Rewards[8:], page.Rewards)
+
+	status, page = get("?rewardsOffset=20&rewardsLimit=5")
+	require.Equal(t, fasthttp.StatusOK, status)
+	require.Empty(t, page.Rewards)
+	require.Equal(t, 10, *page.RewardsTotal)
+
+	status, _ = get("?rewardsLimit=-1")
+	require.Equal(t, fasthttp.StatusBadRequest, status)
+}
+
+// BenchmarkGetBlock serves the blocks of the fixture epoch with the REST API, end to end: index
+// lookups, DAG assembly, meta decoding and the encoding of the response. The nodes read from the
+// CAR are cached in memory after the first iterations, as they are on a busy server.
+func BenchmarkGetBlock(b *testing.B) {
+	config, _, slots, _ := buildTestEpoch(b)
+	epoch := openTestEpoch(b, config)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(b, multi.AddEpoch(0, epoch))
+
+	for _, encoding := range []string{"json", "base64"} {
+		b.Run(encoding, func(b *testing.B) {
+			var req fasthttp.Request
+			var reqCtx fasthttp.RequestCtx
+			for i := 0; i < b.N; i++ {
+				req.SetRequestURI("/api/v1/block/" + strconv.FormatUint(slots[i%len(slots)], 10) + "?encoding=" + encoding)
+				reqCtx.Init(&req, nil, nil)
+				multi.apiHandler(&reqCtx)
+				if reqCtx.Response.StatusCode() != fasthttp.StatusOK {
+					b.Fatalf("status %d: %s", reqCtx.Response.StatusCode(), reqCtx.Response.Body())
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGetBlockEncoding encodes the getBlock response of the fixture block with the most
+// transactions, as Reply does.
+func BenchmarkGetBlockEncoding(b *testing.B) {
+	config, _, slots, _ := buildTestEpoch(b)
+	epoch := openTestEpoch(b, config)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(b, multi.AddEpoch(0, epoch))
+
+	var result map[string]any
+	for _, slot := range slots {
+		var req fasthttp.Request
+		req.SetRequestURI("/api/v1/block/" + strconv.FormatUint(slot, 10))
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		multi.apiHandler(&reqCtx)
+		require.Equal(b, fasthttp.StatusOK, reqCtx.Response.StatusCode(), string(reqCtx.Response.Body()))
+		var block map[string]any
+		require.NoError(b, json.Unmarshal(reqCtx.Response.Body(), &block))
+		if result == nil || len(block["transactions"].([]any)) > len(result["transactions"].([]any)) {
+			result = block
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		camel, err := toCamelCaseResult(result, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := fasterJson.Marshal(camel); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// maxGetBlocksRange is the maximum slot range of getBlocks, and the maximum limit of getBlocksWithLimit
+// (same as Solana's MAX_GET_CONFIRMED_BLOCKS_RANGE).
+const maxGetBlocksRange = 500_000
+
+func (multi *MultiEpoch) handleGetBlocks(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (*jsonrpc2.Error, error) {
+	startSlot, endSlot, err := parseGetBlocksRequest(req.Params)
+	if err != nil {
+		return &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: "Invalid params",
+		}, fmt.Errorf("failed to parse params: %w", err)
+	}
+	highestSlot, err := multi.highestServedSlot()
+	if err != nil {
+		return rpcerrors.NewInternal(), err
+	}
+	end := startSlot + maxGetBlocksRange
+	if endSlot != nil {
+		end = *endSlot
+	}
+	end = min(end, highestSlot)
+	slots := make([]uint64, 0)
+	if end >= startSlot {
+		if end-startSlot > maxGetBlocksRange {
+			return rpcerrors.NewInvalidParams(fmt.Sprintf("Slot range too large; max %d", maxGetBlocksRange)), fmt.Errorf("slot range too large: %d-%d", startSlot, end)
+		}
+		slots, err = multi.GetBlockSlots(ctx, startSlot, end, 0)
+		if err != nil {
+			return rpcerrors.NewInternal(), fmt.Errorf("failed to list blocks: %w", err)
+		}
+	}
+	if err := conn.ReplyRaw(ctx, req.ID, slots); err != nil {
+		return nil, fmt.Errorf("failed to reply: %w", err)
+	}
+	return nil, nil
+}
+
+func (multi *MultiEpoch) handleGetBlocksWithLimit(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (*jsonrpc2.Error, error) {
+	startSlot, limit, err := parseGetBlocksWithLimitRequest(req.Params)
+	if err != nil {
+		return &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: "Invalid params",
+		}, fmt.Errorf("failed to parse params: %w", err)
+	}
+	if limit > maxGetBlocksRange {
+		return rpcerrors.NewInvalidParams(fmt.Sprintf("Limit too large; max %d", maxGetBlocksRange)), fmt.Errorf("limit too large: %d", limit)
+	}
+	slots := make([]uint64, 0)
+	if limit > 0 {
+		highestSlot, err := multi.highestServedSlot()
+		if err != nil {
+			return rpcerrors.NewInternal(), err
+		}
+		if highestSlot >= startSlot {
+			slots, err = multi.GetBlockSlots(ctx, startSlot, highestSlot, int(limit))
+			if err != nil {
+				return rpcerrors.NewInternal(), fmt.Errorf("failed to list blocks: %w", err)
+			}
+		}
+	}
+	if err := conn.ReplyRaw(ctx, req.ID, slots); err != nil {
+		return nil, fmt.Errorf("failed to reply: %w", err)
+	}
+	return nil, nil
+}
+
+// highestServedSlot returns the last slot of the most recent epoch served.
+func (multi *MultiEpoch) highestServedSlot() (uint64, error) {
+	epochNumber, err := multi.GetMostRecentAvailableEpochNumber()
+	if err != nil {
+		return 0, err
+	}
+	_, last := slottools.CalcEpochLimits(epochNumber)
+	return last, nil
+}
+
+// GetBlockSlots returns the slots of the blocks between start and end (inclusive) in ascending
+// order, across the epochs that are served; the slots of the epochs that are not served are skipped.
+// If limit is positive, at most limit slots are returned.
+//
+// The epochs are queried concurrently; once limit slots are found, the queries of the later epochs
+// are canceled.
+func (multi *MultiEpoch) GetBlockSlots(ctx context.Context, start, end uint64, limit int) ([]uint64, error) {
+	epochNumbers := multi.GetEpochNumbers()
+	sort.Slice(epochNumbers, func(i, j int) bool { return epochNumbers[i] < epochNumbers[j] })
+	startEpoch := slottools.CalcEpochForSlot(start)
+	endEpoch := slottools.CalcEpochForSlot(end)
+	tasks := make([]queryTask[[]uint64], 0)
+	for _, epochNumber := range epochNumbers {
+		if epochNumber < startEpoch || epochNumber > endEpoch {
+			continue
+		}
+		epochHandler, release, err := multi.GetEpoch(epochNumber)
+		if err != nil {
+			// removed in the meantime.
+			continue
+		}
+		defer release()
+		first, last := slottools.CalcEpochLimits(epochNumber)
+		tasks = append(tasks, queryTask[[]uint64]{
+			epoch:  epochNumber,
+			remote: epochHandler.isRemote(),
+			run: func(ctx context.Context) ([]uint64, error) {
+				return epochHandler.GetBlockSlots(ctx, max(start, first), min(end, last), limit)
+			},
+		})
+	}
+	slots := make([]uint64, 0)
+	err := runQueryPlan(ctx, multi.queryPlanOptions(), tasks, func(epochSlots []uint64) bool {
+		slots = append(slots, epochSlots...)
+		if limit > 0 && len(slots) >= limit {
+			slots = slots[:limit]
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slots, nil
+}
+
+// GetBlockSlots returns the slots of the blocks of the epoch between start and end (inclusive),
+// in ascending order. If limit is positive, at most limit slots are returned.
+//
+// If the epoch has a block manifest, the slots are read from it. Otherwise, slots with a blocktime
+// are blocks, and the other slots are looked up in the slot-to-cid index; as the index can return
+// false positives for keys it doesn't have, those blocks are read to check their slot.
+func (e *Epoch) GetBlockSlots(ctx context.Context, start, end uint64, limit int) ([]uint64, error) {
+	if e.blockManifest != nil {
+		return e.blockManifest.Slots(start, end, limit), nil
+	}
+	slots := make([]uint64, 0)
+	for slot := start; slot <= end; slot++ {
+		if limit > 0 && len(slots) >= limit {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		has, err := e.hasBlock(ctx, slot)
+		if err != nil {
+			return nil, fmt.Errorf("slot %d: %w", slot, err)
+		}
+		if has {
+			slots = append(slots, slot)
+		}
+		if slot == end {
+			// avoid overflow
+			break
+		}
+	}
+	return slots, nil
+}
+
+func (e *Epoch) hasBlock(ctx context.Context, slot uint64) (bool, error) {
+	if e.blocktimeindex != nil {
+		blocktime, err := e.blocktimeindex.Get(slot)
+		if err != nil {
+			return false, err
+		}
+		if blocktime != 0 {
+			return true, nil
+		}
+	}
+	block, _, err := e.GetBlock(ctx, slot)
+	if err != nil {
+		if errors.Is(err, compactindexsized.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return uint64(block.Slot) == slot, nil
+}
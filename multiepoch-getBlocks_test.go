@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBlockSlots(t *testing.T) {
+	ctx := context.Background()
+	config, _, slots, _ := buildTestEpoch(t)
+
+	check := func(t *testing.T, epoch *Epoch) {
+		multi := NewMultiEpoch(&Options{})
+		require.NoError(t, multi.AddEpoch(0, epoch))
+
+		got, err := multi.GetBlockSlots(ctx, 0, 1000, 0)
+		require.NoError(t, err)
+		require.Equal(t, slots, got)
+
+		got, err = multi.GetBlockSlots(ctx, slots[2], slots[5], 0)
+		require.NoError(t, err)
+		require.Equal(t, slots[2:6], got)
+
+		got, err = multi.GetBlockSlots(ctx, slots[1], 1000, 3)
+		require.NoError(t, err)
+		require.Equal(t, slots[1:4], got)
+
+		// epochs that are not served are skipped:
+		got, err = multi.GetBlockSlots(ctx, slots[8], 432_000*2, 0)
+		require.NoError(t, err)
+		require.Equal(t, slots[8:], got)
+
+		highest, err := multi.highestServedSlot()
+		require.NoError(t, err)
+		require.Equal(t, uint64(431_999), highest)
+	}
+
+	t.Run("indexes", func(t *testing.T) {
+		check(t, openTestEpoch(t, config))
+	})
+
+	t.Run("block manifest", func(t *testing.T) {
+		manifestPath, err := CreateIndex_blockManifest(ctx, 0, indexes.NetworkMainnet, string(config.Data.Car.URI), t.TempDir())
+		require.NoError(t, err)
+		config.Indexes.BlockManifest.URI = URI(manifestPath)
+		require.NoError(t, config.Validate())
+		epoch := openTestEpoch(t, config)
+		require.NotNil(t, epoch.blockManifest)
+		check(t, epoch)
+	})
+}
+
+func TestParseGetBlocksRequest(t *testing.T) {
+	parse := func(params string) (uint64, *uint64, error) {
+		raw := json.RawMessage(params)
+		return parseGetBlocksRequest(&raw)
+	}
+	start, end, err := parse(`[5, 10]`)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), start)
+	require.Equal(t, uint64(10), *end)
+
+	start, end, err = parse(`[5, {"commitment": "finalized"}]`)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), start)
+	require.Nil(t, end)
+
+	_, _, err = parse(`[]`)
+	require.Error(t, err)
+	_, _, err = parse(`[5, "10"]`)
+	require.Error(t, err)
+
+	raw := json.RawMessage(`[5, 3]`)
+	start, limit, err := parseGetBlocksWithLimitRequest(&raw)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), start)
+	require.Equal(t, uint64(3), limit)
+	raw = json.RawMessage(`[5]`)
+	_, _, err = parseGetBlocksWithLimitRequest(&raw)
+	require.Error(t, err)
+}
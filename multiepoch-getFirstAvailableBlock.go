@@ -4,16 +4,14 @@ import (
 	"context"
 	"fmt"
 
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
 func (multi *MultiEpoch) handleGetFirstAvailableBlock(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (*jsonrpc2.Error, error) {
 	firstBlock, err := multi.GetFirstAvailableBlock(ctx)
 	if err != nil {
-		return &jsonrpc2.Error{
-			Code:    CodeNotFound,
-			Message: "Internal error",
-		}, fmt.Errorf("failed to get first available block: %w", err)
+		return rpcerrors.NewInternal(), fmt.Errorf("failed to get first available block: %w", err)
 	}
 
 	slotNumber := uint64(firstBlock.Slot)
@@ -4,27 +4,23 @@ import (
 	"context"
 	"fmt"
 
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
 func (multi *MultiEpoch) handleGetGenesisHash(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (*jsonrpc2.Error, error) {
 	// Epoch 0 contains the genesis config.
 	epochNumber := uint64(0)
-	epochHandler, err := multi.GetEpoch(epochNumber)
+	epochHandler, release, err := multi.GetEpoch(epochNumber)
 	if err != nil {
 		// If epoch 0 is not available, then the genesis config is not available.
-		return &jsonrpc2.Error{
-			Code:    CodeNotFound,
-			Message: fmt.Sprintf("Epoch %d is not available", epochNumber),
-		}, fmt.Errorf("failed to get epoch %d: %w", epochNumber, err)
+		return rpcerrors.NewBlockNotAvailable(0), fmt.Errorf("failed to get epoch %d: %w", epochNumber, err)
 	}
+	defer release()
 
 	genesis := epochHandler.GetGenesis()
 	if genesis == nil {
-		return &jsonrpc2.Error{
-			Code:    CodeNotFound,
-			Message: "Genesis is not available",
-		}, fmt.Errorf("genesis is nil")
+		return rpcerrors.NewInternal(), fmt.Errorf("genesis is nil")
 	}
 
 	genesisHash := genesis.Hash
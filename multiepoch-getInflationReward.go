@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/gagliardetto/solana-go"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	solanablockrewards "github.com/rpcpool/yellowstone-faithful/solana-block-rewards"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// maxInflationRewardAddresses is the maximum number of addresses of getInflationReward
+// (same as Solana's MAX_MULTIPLE_ACCOUNTS).
+const maxInflationRewardAddresses = 100
+
+// InflationReward is the reward of an address for an epoch, as returned by getInflationReward.
+type InflationReward struct {
+	Epoch         uint64 `json:"epoch"`
+	EffectiveSlot uint64 `json:"effectiveSlot"`
+	Amount        uint64 `json:"amount"`
+	PostBalance   uint64 `json:"postBalance"`
+	Commission    *uint8 `json:"commission"`
+}
+
+// handleGetInflationReward returns the inflation rewards of the addresses for an epoch.
+// The rewards of epoch N are paid at the start of epoch N+1: the voting rewards (and, before
+// partitioned rewards, the staking rewards) are in the first block of epoch N+1; with partitioned
+// rewards, the staking reward of an address is in the block of its partition, among the blocks
+// following the first one.
+func (multi *MultiEpoch) handleGetInflationReward(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (*jsonrpc2.Error, error) {
+	params, err := parseGetInflationRewardRequest(req.Params)
+	if err != nil {
+		return &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: "Invalid params",
+		}, fmt.Errorf("failed to parse params: %w", err)
+	}
+	if len(params.Addresses) > maxInflationRewardAddresses {
+		return rpcerrors.NewInvalidParams(fmt.Sprintf("Too many inputs provided; max %d", maxInflationRewardAddresses)), fmt.Errorf("too many addresses: %d", len(params.Addresses))
+	}
+	var epoch uint64
+	if params.Epoch != nil {
+		epoch = *params.Epoch
+	} else {
+		// the last epoch whose rewards are served.
+		mostRecent, err := multi.GetMostRecentAvailableEpochNumber()
+		if err != nil {
+			return rpcerrors.NewInternal(), err
+		}
+		if mostRecent == 0 {
+			return rpcerrors.NewInvalidParams("No epoch with rewards available"), fmt.Errorf("only epoch 0 is served")
+		}
+		epoch = mostRecent - 1
+	}
+	rewards, jerr, err := multi.getInflationRewards(ctx, epoch, params.Addresses)
+	if err != nil {
+		return jerr, err
+	}
+	if err := conn.ReplyRaw(ctx, req.ID, rewards); err != nil {
+		return nil, fmt.Errorf("failed to reply: %w", err)
+	}
+	return nil, nil
+}
+
+// getInflationRewards returns the rewards of the addresses for the epoch, in the same order;
+// the addresses without a reward have a nil reward.
+func (multi *MultiEpoch) getInflationRewards(ctx context.Context, epoch uint64, addresses []solana.PublicKey) ([]*InflationReward, *jsonrpc2.Error, error) {
+	results := make([]*InflationReward, len(addresses))
+	if len(addresses) == 0 {
+		return results, nil, nil
+	}
+	payoutEpoch := epoch + 1
+	firstSlot, lastSlot := slottools.CalcEpochLimits(payoutEpoch)
+	epochHandler, release, err := multi.GetEpoch(payoutEpoch)
+	if err != nil {
+		return nil, multi.errorForMissingSlot(ctx, firstSlot), fmt.Errorf("failed to get epoch %d: %w", payoutEpoch, err)
+	}
+	defer release()
+	firstBlockSlots, err := epochHandler.GetBlockSlots(ctx, firstSlot, lastSlot, 1)
+	if err != nil {
+		return nil, rpcerrors.NewInternal(), fmt.Errorf("failed to list blocks of epoch %d: %w", payoutEpoch, err)
+	}
+	if len(firstBlockSlots) == 0 {
+		return nil, rpcerrors.NewBlockNotAvailable(firstSlot), fmt.Errorf("epoch %d has no blocks", payoutEpoch)
+	}
+	firstBlock, err := getBlockForRewards(ctx, epochHandler, firstBlockSlots[0])
+	if err != nil {
+		return nil, rpcerrors.NewInternal(), err
+	}
+	rawRewards, err := epochHandler.GetBlockRewards(ctx, firstBlock)
+	if err != nil {
+		return nil, rpcerrors.NewInternal(), fmt.Errorf("failed to get rewards of slot %d: %w", firstBlockSlots[0], err)
+	}
+	numPartitions, partitioned, err := solanablockrewards.NumPartitions(rawRewards)
+	if err != nil {
+		return nil, rpcerrors.NewInternal(), fmt.Errorf("failed to read the partitions of slot %d: %w", firstBlockSlots[0], err)
+	}
+	firstBlockRewards, err := solanablockrewards.ParseRewards(rawRewards)
+	if err != nil {
+		return nil, rpcerrors.NewInternal(), fmt.Errorf("failed to parse rewards of slot %d: %w", firstBlockSlots[0], err)
+	}
+	fillInflationRewards(results, addresses, epoch, firstBlockSlots[0], firstBlockRewards, func(rewardType confirmed_block.RewardType) bool {
+		return rewardType == confirmed_block.RewardType_Voting || (!partitioned && rewardType == confirmed_block.RewardType_Staking)
+	})
+	if !partitioned || numPartitions == 0 {
+		return results, nil, nil
+	}
+
+	// the staking rewards are in the partition blocks.
+	parentSlot := uint64(firstBlock.Meta.Parent_slot)
+	parentBlockhash, err := multi.getBlockhash(ctx, parentSlot)
+	if err != nil {
+		return nil, multi.errorForMissingSlot(ctx, parentSlot), fmt.Errorf("failed to get the blockhash of the parent slot %d: %w", parentSlot, err)
+	}
+	addressesByPartition := make(map[uint64][]int)
+	for i, address := range addresses {
+		if results[i] != nil {
+			// an address has either a voting or a staking reward.
+			continue
+		}
+		partition := solanablockrewards.PartitionForAddress(numPartitions, parentBlockhash, address)
+		addressesByPartition[partition] = append(addressesByPartition[partition], i)
+	}
+	if len(addressesByPartition) == 0 {
+		return results, nil, nil
+	}
+	partitionSlots, err := epochHandler.GetBlockSlots(ctx, firstBlockSlots[0]+1, lastSlot, int(numPartitions))
+	if err != nil {
+		return nil, rpcerrors.NewInternal(), fmt.Errorf("failed to list the partition blocks of epoch %d: %w", payoutEpoch, err)
+	}
+	for partition, indexes := range addressesByPartition {
+		if partition >= uint64(len(partitionSlots)) {
+			return nil, rpcerrors.NewBlockNotAvailable(lastSlot), fmt.Errorf("the block of partition %d of epoch %d is not available", partition, payoutEpoch)
+		}
+		slot := partitionSlots[partition]
+		block, err := getBlockForRewards(ctx, epochHandler, slot)
+		if err != nil {
+			return nil, rpcerrors.NewInternal(), err
+		}
+		rawRewards, err := epochHandler.GetBlockRewards(ctx, block)
+		if err != nil {
+			return nil, rpcerrors.NewInternal(), fmt.Errorf("failed to get rewards of slot %d: %w", slot, err)
+		}
+		blockRewards, err := solanablockrewards.ParseRewards(rawRewards)
+		if err != nil {
+			return nil, rpcerrors.NewInternal(), fmt.Errorf("failed to parse rewards of slot %d: %w", slot, err)
+		}
+		partitionAddresses := make([]solana.PublicKey, len(indexes))
+		partitionResults := make([]*InflationReward, len(indexes))
+		for i, index := range indexes {
+			partitionAddresses[i] = addresses[index]
+		}
+		fillInflationRewards(partitionResults, partitionAddresses, epoch, slot, blockRewards, func(rewardType confirmed_block.RewardType) bool {
+			return rewardType == confirmed_block.RewardType_Staking
+		})
+		for i, index := range indexes {
+			results[index] = partitionResults[i]
+		}
+	}
+	return results, nil, nil
+}
+
+// fillInflationRewards sets the results of the addresses that have a reward of a wanted type in the rewards of the block.
+func fillInflationRewards(
+	results []*InflationReward,
+	addresses []solana.PublicKey,
+	epoch uint64,
+	slot uint64,
+	rewards *confirmed_block.Rewards,
+	wanted func(confirmed_block.RewardType) bool,
+) {
+	byAddress := make(map[string]*confirmed_block.Reward)
+	for _, reward := range rewards.GetRewards() {
+		if wanted(reward.RewardType) {
+			byAddress[reward.Pubkey] = reward
+		}
+	}
+	for i, address := range addresses {
+		reward, ok := byAddress[address.String()]
+		if !ok {
+			continue
+		}
+		amount := reward.Lamports
+		if amount < 0 {
+			amount = -amount
+		}
+		results[i] = &InflationReward{
+			Epoch:         epoch,
+			EffectiveSlot: slot,
+			Amount:        uint64(amount),
+			PostBalance:   reward.PostBalance,
+		}
+		if commission, err := strconv.ParseUint(reward.Commission, 10, 8); err == nil {
+			c := uint8(commission)
+			results[i].Commission = &c
+		}
+	}
+}
+
+func getBlockForRewards(ctx context.Context, epochHandler *Epoch, slot uint64) (*ipldbindcode.Block, error) {
+	block, _, err := epochHandler.GetBlock(ctx, slot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block %d: %w", slot, err)
+	}
+	return block, nil
+}
+
+// getBlockhash returns the blockhash of the block at the slot, i.e. the hash of its last entry.
+func (multi *MultiEpoch) getBlockhash(ctx context.Context, slot uint64) (solana.Hash, error) {
+	epochHandler, release, err := multi.GetEpoch(slottools.CalcEpochForSlot(slot))
+	if err != nil {
+		return solana.Hash{}, err
+	}
+	defer release()
+	block, err := getBlockForRewards(ctx, epochHandler, slot)
+	if err != nil {
+		return solana.Hash{}, err
+	}
+	if len(block.Entries) == 0 {
+		return solana.Hash{}, fmt.Errorf("block %d has no entries", slot)
+	}
+	lastEntry, err := epochHandler.GetEntryByCid(ctx, block.Entries[len(block.Entries)-1].(cidlink.Link).Cid)
+	if err != nil {
+		return solana.Hash{}, fmt.Errorf("failed to get the last entry of block %d: %w", slot, err)
+	}
+	return solana.HashFromBytes(lastEntry.Hash), nil
+}
+
+// GetBlockRewards returns the uncompressed rewards of the block, or nil if the block has no rewards.
+func (ser *Epoch) GetBlockRewards(ctx context.Context, block *ipldbindcode.Block) ([]byte, error) {
+	rewardsCid := block.Rewards.(cidlink.Link).Cid
+	if rewardsCid.Equals(DummyCID) {
+		return nil, nil
+	}
+	rewardsNode, err := ser.GetRewardsByCid(ctx, rewardsCid)
+	if err != nil {
+		return nil, err
+	}
+	rewardsChunks, err := tooling.LoadChunksFromDataFrames(&rewardsNode.Data, ser.GetDataFrameByCid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Rewards dataFrames: %w", err)
+	}
+	uncompressed, err := tooling.DecompressZstdChunks(rewardsChunks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress Rewards: %w", err)
+	}
+	return uncompressed, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGetInflationRewardRequest(t *testing.T) {
+	parse := func(params string) (*GetInflationRewardRequest, error) {
+		raw := json.RawMessage(params)
+		return parseGetInflationRewardRequest(&raw)
+	}
+	address := solana.SystemProgramID.String()
+	got, err := parse(`[["` + address + `"], {"epoch": 7, "commitment": "finalized"}]`)
+	require.NoError(t, err)
+	require.Equal(t, []solana.PublicKey{solana.SystemProgramID}, got.Addresses)
+	require.Equal(t, uint64(7), *got.Epoch)
+
+	got, err = parse(`[[]]`)
+	require.NoError(t, err)
+	require.Empty(t, got.Addresses)
+	require.Nil(t, got.Epoch)
+
+	for _, params := range []string{`[]`, `["` + address + `"]`, `[["not-a-pubkey"]]`, `[[1]]`, `[[], {"epoch": "7"}]`} {
+		_, err := parse(params)
+		require.Error(t, err, params)
+	}
+}
+
+func TestFillInflationRewards(t *testing.T) {
+	staker := solana.PublicKey{1}
+	voter := solana.PublicKey{2}
+	other := solana.PublicKey{3}
+	rewards := &confirmed_block.Rewards{
+		Rewards: []*confirmed_block.Reward{
+			{Pubkey: staker.String(), Lamports: 10, PostBalance: 110, RewardType: confirmed_block.RewardType_Staking},
+			{Pubkey: voter.String(), Lamports: 20, PostBalance: 220, RewardType: confirmed_block.RewardType_Voting, Commission: "5"},
+			{Pubkey: other.String(), Lamports: -30, PostBalance: 330, RewardType: confirmed_block.RewardType_Rent},
+		},
+	}
+	addresses := []solana.PublicKey{staker, voter, other}
+	results := make([]*InflationReward, len(addresses))
+	fillInflationRewards(results, addresses, 4, 432_000*5, rewards, func(rewardType confirmed_block.RewardType) bool {
+		return rewardType == confirmed_block.RewardType_Voting
+	})
+	require.Nil(t, results[0])
+	commission := uint8(5)
+	require.Equal(t, &InflationReward{Epoch: 4, EffectiveSlot: 432_000 * 5, Amount: 20, PostBalance: 220, Commission: &commission}, results[1])
+	require.Nil(t, results[2])
+
+	fillInflationRewards(results, addresses, 4, 432_001*5, rewards, func(rewardType confirmed_block.RewardType) bool {
+		return rewardType != confirmed_block.RewardType_Voting
+	})
+	require.Equal(t, &InflationReward{Epoch: 4, EffectiveSlot: 432_001 * 5, Amount: 10, PostBalance: 110}, results[0])
+	require.Equal(t, uint64(30), results[2].Amount)
+}
+
+func TestGetInflationRewards(t *testing.T) {
+	ctx := context.Background()
+	config, _, slots, _ := buildTestEpoch(t)
+	epoch := openTestEpoch(t, config)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+
+	source := carwriter.NewCarSource(string(config.Data.Car.URI))
+	defer source.Close()
+	err := source.Blocks(ctx, slots[1], slots[1], func(block *carwriter.Block) error {
+		blockhash, err := multi.getBlockhash(ctx, block.Slot)
+		require.NoError(t, err)
+		require.Equal(t, block.Entries[len(block.Entries)-1].Hash, blockhash)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// the rewards of epoch 0 are paid in epoch 1, which is not served.
+	_, jerr, err := multi.getInflationRewards(ctx, 0, []solana.PublicKey{solana.SystemProgramID})
+	require.Error(t, err)
+	require.NotNil(t, jerr)
+
+	results, jerr, err := multi.getInflationRewards(ctx, 0, nil)
+	require.NoError(t, err)
+	require.Nil(t, jerr)
+	require.Empty(t, results)
+}
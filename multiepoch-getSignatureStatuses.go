@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxSignatureStatuses is the maximum number of signatures of a request, as in solana.
+const maxSignatureStatuses = 256
+
+// SignatureStatus is the status of a transaction, with the fields of solana's getSignatureStatuses in
+// their order; the transactions of the archive are all finalized.
+type SignatureStatus struct {
+	Slot               uint64  `json:"slot"`
+	Confirmations      *uint64 `json:"confirmations"`
+	Status             any     `json:"status"`
+	Err                any     `json:"err"`
+	ConfirmationStatus string  `json:"confirmationStatus"`
+}
+
+func newSignatureStatus(slot uint64, txErr any) *SignatureStatus {
+	status := &SignatureStatus{
+		Slot:               slot,
+		Status:             map[string]any{"Ok": nil},
+		Err:                txErr,
+		ConfirmationStatus: "finalized",
+	}
+	if txErr != nil {
+		status.Status = map[string]any{"Err": txErr}
+	}
+	return status
+}
+
+// handleGetSignatureStatuses handles faithful_getSignatureStatuses, an extension that answers like
+// getSignatureStatuses for the historical transactions: the status of each signature (null if not
+// found), from the v2 sig-to-cid indexes of the epochs when they have one, so that the transactions
+// are only read for the ones that failed (for their error) or have no meta in the CAR.
+func (multi *MultiEpoch) handleGetSignatureStatuses(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (*jsonrpc2.Error, error) {
+	sigs, err := parseGetSignatureStatusesRequest(req.Params)
+	if err != nil {
+		return &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: "Invalid params",
+		}, fmt.Errorf("failed to parse params: %w", err)
+	}
+	if len(sigs) > maxSignatureStatuses {
+		return rpcerrors.NewInvalidParams(fmt.Sprintf("Too many inputs provided; max %d", maxSignatureStatuses)), fmt.Errorf("too many signatures: %d", len(sigs))
+	}
+	contextSlot, err := multi.highestServedSlot()
+	if err != nil {
+		return rpcerrors.NewInternal(), err
+	}
+	statuses, err := multi.GetSignatureStatuses(ctx, sigs)
+	if err != nil {
+		return rpcerrors.NewInternal(), fmt.Errorf("failed to get signature statuses: %w", err)
+	}
+	err = conn.ReplyRaw(
+		ctx,
+		req.ID,
+		map[string]any{
+			"context": map[string]any{"slot": contextSlot},
+			"value":   statuses,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reply: %w", err)
+	}
+	return nil, nil
+}
+
+// parseGetSignatureStatusesRequest parses the signatures of the params; the options (e.g.
+// searchTransactionHistory) are accepted and ignored, as the history is always searched.
+func parseGetSignatureStatusesRequest(raw *json.RawMessage) ([]solana.Signature, error) {
+	if raw == nil {
+		return nil, fmt.Errorf("params are required")
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(*raw, &params); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal params: %w", err)
+	}
+	if len(params) < 1 {
+		return nil, fmt.Errorf("params must have at least one argument")
+	}
+	var sigStrings []string
+	if err := json.Unmarshal(params[0], &sigStrings); err != nil {
+		return nil, fmt.Errorf("first argument must be an array of signatures: %w", err)
+	}
+	sigs := make([]solana.Signature, len(sigStrings))
+	for i, sigString := range sigStrings {
+		sig, err := solana.SignatureFromBase58(sigString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature %q: %w", sigString, err)
+		}
+		sigs[i] = sig
+	}
+	return sigs, nil
+}
+
+// GetSignatureStatuses returns the statuses of the signatures, in the same order, with nil for the
+// signatures that are not found.
+func (multi *MultiEpoch) GetSignatureStatuses(ctx context.Context, sigs []solana.Signature) ([]*SignatureStatus, error) {
+	statuses := make([]*SignatureStatus, len(sigs))
+	concurrency := 1
+	if multi.options != nil && multi.options.EpochSearchConcurrency > 0 {
+		concurrency = multi.options.EpochSearchConcurrency
+	}
+	wg, ctx := errgroup.WithContext(ctx)
+	wg.SetLimit(concurrency)
+	for i, sig := range sigs {
+		i, sig := i, sig
+		wg.Go(func() error {
+			status, err := multi.getSignatureStatus(ctx, sig)
+			if err != nil {
+				if errors.Is(err, ErrNotFound) {
+					return nil
+				}
+				return fmt.Errorf("signature %s: %w", sig, err)
+			}
+			statuses[i] = status
+			return nil
+		})
+	}
+	if err := wg.Wait(); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+func (multi *MultiEpoch) getSignatureStatus(ctx context.Context, sig solana.Signature) (*SignatureStatus, error) {
+	epochNumber, err := multi.findEpochNumberFromSignature(ctx, sig)
+	if err != nil {
+		return nil, err
+	}
+	epoch, release, err := multi.GetEpoch(epochNumber)
+	if err != nil {
+		return nil, fmt.Errorf("epoch %d is no longer served: %w: %w", epochNumber, ErrNotFound, err)
+	}
+	defer release()
+	status, ok, err := epoch.FindSigStatus(ctx, sig)
+	if err != nil {
+		if compactindexsized.IsNotFound(err) {
+			return nil, fmt.Errorf("not in the status index of epoch %d: %w", epochNumber, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to look up the status index of epoch %d: %w", epochNumber, err)
+	}
+	if ok && !status.Failed() && !status.MetaMissing() {
+		return newSignatureStatus(status.Slot, nil), nil
+	}
+	// the error of a failed transaction is only in its metadata, and the metadata of the
+	// transactions without one in the CAR may be in the meta overlay.
+	transactionNode, _, err := epoch.GetTransaction(ctx, sig)
+	if err != nil {
+		if errors.Is(err, compactindexsized.ErrNotFound) {
+			return nil, fmt.Errorf("transaction not found: %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+	_, meta, err := parseTransactionAndMetaFromNode(ctx, transactionNode, epoch.GetDataFrameByCid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	return newSignatureStatus(uint64(transactionNode.Slot), getErr(meta)), nil
+}
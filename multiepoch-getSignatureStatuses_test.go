@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestGetSignatureStatuses(t *testing.T) {
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+	paths, _, _, err := buildTestEpochIndexes(carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+
+	sigToCidV2Path, err := CreateIndex_sig2cid(context.Background(), 0, indexes.NetworkMainnet, t.TempDir(), carPath, t.TempDir(), true)
+	require.NoError(t, err)
+	index, err := indexes.Open_SigToCid(sigToCidV2Path)
+	require.NoError(t, err)
+	var sigs []string
+	for _, tx := range fixture.Transactions {
+		_, status, err := index.GetWithStatus(tx.Signature)
+		require.NoError(t, err)
+		require.Equal(t, tx.Slot, status.Slot)
+		require.Equal(t, tx.Spec.Failed, status.Failed(), "transaction %d of slot %d", tx.Position, tx.Slot)
+		require.Equal(t, tx.Spec.Meta == carfixture.MetaNone, status.MetaMissing(), "transaction %d of slot %d", tx.Position, tx.Slot)
+		sigs = append(sigs, `"`+tx.Signature.String()+`"`)
+	}
+	require.NoError(t, index.Close())
+	sigs = append(sigs, `"`+solana.Signature{1}.String()+`"`)
+	params := `[[` + strings.Join(sigs, ",") + `], {"searchTransactionHistory": true}]`
+
+	call := func(t *testing.T, multi *MultiEpoch, params string) (json.RawMessage, json.RawMessage) {
+		var req fasthttp.Request
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetRequestURI("/")
+		req.SetBodyString(`{"jsonrpc":"2.0","id":1,"method":"faithful_getSignatureStatuses","params":` + params + `}`)
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		newMultiEpochHandler(multi, nil)(&reqCtx)
+		var resp struct {
+			Result json.RawMessage `json:"result"`
+			Error  json.RawMessage `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &resp), "%s", reqCtx.Response.Body())
+		return resp.Result, resp.Error
+	}
+
+	var results []string
+	for _, statusIndex := range []string{"sig-to-cid v2", "none"} {
+		config := testEpochConfig(t, carPath, paths)
+		if statusIndex == "sig-to-cid v2" {
+			config.Indexes.SigToCid.URI = URI(sigToCidV2Path)
+		}
+		epoch := openTestEpoch(t, config)
+		_, hasStatus, err := epoch.FindSigStatus(context.Background(), fixture.Transactions[0].Signature)
+		require.NoError(t, err)
+		require.Equal(t, statusIndex != "none", hasStatus, statusIndex)
+		multi := NewMultiEpoch(&Options{EpochSearchConcurrency: 4})
+		require.NoError(t, multi.AddEpoch(0, epoch))
+
+		result, respErr := call(t, multi, params)
+		require.Empty(t, respErr)
+		var got struct {
+			Context struct {
+				Slot uint64 `json:"slot"`
+			} `json:"context"`
+			Value []json.RawMessage `json:"value"`
+		}
+		require.NoError(t, json.Unmarshal(result, &got))
+		require.Equal(t, uint64(431_999), got.Context.Slot)
+		require.Len(t, got.Value, len(fixture.Transactions)+1)
+		for i, tx := range fixture.Transactions {
+			switch {
+			case tx.Spec.Meta == carfixture.MetaNone:
+				// as in getSignaturesForAddress, the error of a transaction without meta is unknown.
+				require.Equal(t, fmt.Sprintf(`{"slot":%d,"confirmations":null,"status":{"Err":{"unknown":[]}},"err":{"unknown":[]},"confirmationStatus":"finalized"}`, tx.Slot), string(got.Value[i]))
+			case tx.Spec.Failed:
+				require.Regexp(t, fmt.Sprintf(`^\{"slot":%d,"confirmations":null,"status":\{"Err":\{.+\}\},"err":\{.+\},"confirmationStatus":"finalized"\}$`, tx.Slot), string(got.Value[i]))
+			default:
+				require.Equal(t, fmt.Sprintf(`{"slot":%d,"confirmations":null,"status":{"Ok":null},"err":null,"confirmationStatus":"finalized"}`, tx.Slot), string(got.Value[i]))
+			}
+		}
+		require.Equal(t, "null", string(got.Value[len(got.Value)-1]))
+		results = append(results, string(result))
+
+		tooMany := `[[` + strings.Repeat(sigs[0]+",", maxSignatureStatuses) + sigs[0] + `]]`
+		_, respErr = call(t, multi, tooMany)
+		require.Contains(t, string(respErr), "Too many inputs provided; max 256")
+		_, respErr = call(t, multi, `[["not a signature"]]`)
+		require.Contains(t, string(respErr), "Invalid params")
+	}
+	// the index gives the same answers as the transactions.
+	require.Equal(t, results[1], results[0])
+}
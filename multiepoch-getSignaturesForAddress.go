@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sort"
+	"math"
 	"sync"
 
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
 	"github.com/rpcpool/yellowstone-faithful/gsfa"
 	"github.com/rpcpool/yellowstone-faithful/gsfa/linkedlog"
 	"github.com/rpcpool/yellowstone-faithful/indexes"
@@ -17,50 +20,47 @@ import (
 )
 
 // getGsfaReadersInEpochDescendingOrder returns a list of gsfa readers in epoch order (from most recent to oldest).
-func (ser *MultiEpoch) getGsfaReadersInEpochDescendingOrder() ([]*gsfa.GsfaReader, []uint64) {
-	ser.mu.RLock()
-	defer ser.mu.RUnlock()
+func (ser *MultiEpoch) getGsfaReadersInEpochDescendingOrder() ([]*gsfa.GsfaReader, []uint64, func()) {
+	return ser.getReadersInEpochDescendingOrder(func(epoch *Epoch) *gsfa.GsfaReader { return epoch.gsfaReader })
+}
 
-	epochs := make([]*Epoch, 0, len(ser.epochs))
-	for _, epoch := range ser.epochs {
-		epochs = append(epochs, epoch)
-	}
+// getGpfaReadersInEpochDescendingOrder returns a list of gpfa readers in epoch order (from most recent to oldest).
+func (ser *MultiEpoch) getGpfaReadersInEpochDescendingOrder() ([]*gsfa.GsfaReader, []uint64, func()) {
+	return ser.getReadersInEpochDescendingOrder(func(epoch *Epoch) *gsfa.GsfaReader { return epoch.gpfaReader })
+}
 
-	// sort epochs by epoch number (from biggest to smallest):
-	sort.Slice(epochs, func(i, j int) bool {
-		return epochs[i].epoch > epochs[j].epoch
-	})
+// getTokenBalancesReadersInEpochDescendingOrder returns a list of token balances readers in epoch order (from most recent to oldest).
+func (ser *MultiEpoch) getTokenBalancesReadersInEpochDescendingOrder() ([]*gsfa.GsfaReader, []uint64, func()) {
+	return ser.getReadersInEpochDescendingOrder(func(epoch *Epoch) *gsfa.GsfaReader { return epoch.tokenBalancesReader })
+}
 
-	gsfaReaders := make([]*gsfa.GsfaReader, 0, len(epochs))
+// getReadersInEpochDescendingOrder returns the readers of the indexes in the gsfa format (returned
+// by readerOf, nil if the epoch doesn't have one) in epoch order (from most recent to oldest), and
+// the function that releases their epochs once the readers are no longer used.
+func (ser *MultiEpoch) getReadersInEpochDescendingOrder(readerOf func(*Epoch) *gsfa.GsfaReader) ([]*gsfa.GsfaReader, []uint64, func()) {
+	epochs, release := ser.openEpochsInEpochDescendingOrder(nil)
+
+	readers := make([]*gsfa.GsfaReader, 0, len(epochs))
 	epochNums := make([]uint64, 0, len(epochs))
 	for _, epoch := range epochs {
-		if epoch.gsfaReader != nil {
-			epoch.gsfaReader.SetEpoch(epoch.Epoch())
-			gsfaReaders = append(gsfaReaders, epoch.gsfaReader)
+		if reader := readerOf(epoch); reader != nil {
+			reader.SetEpoch(epoch.Epoch())
+			readers = append(readers, reader)
 			epochNums = append(epochNums, epoch.Epoch())
 		}
 	}
-	return gsfaReaders, epochNums
+	return readers, epochNums, release
 }
 
-// getGsfaReadersInEpochDescendingOrder returns a list of gsfa readers in epoch order (from most recent to oldest).
-func (ser *MultiEpoch) getGsfaReadersInEpochDescendingOrderForSlotRange(ctx context.Context, startSlot, endSlot uint64) (*gsfa.GsfaReaderMultiepoch, []uint64) {
-	ser.mu.RLock()
-	defer ser.mu.RUnlock()
-
+// getGsfaReadersInEpochDescendingOrderForSlotRange returns the gsfa readers of the epochs of a
+// slot range in epoch order (from most recent to oldest), and the function that releases the
+// epochs once the readers are no longer used.
+func (ser *MultiEpoch) getGsfaReadersInEpochDescendingOrderForSlotRange(ctx context.Context, startSlot, endSlot uint64) (*gsfa.GsfaReaderMultiepoch, []uint64, func()) {
 	startEpoch := slottools.CalcEpochForSlot(startSlot)
 	endEpoch := slottools.CalcEpochForSlot(endSlot)
 
-	epochs := make([]*Epoch, 0, endEpoch-startEpoch+1)
-	for _, epoch := range ser.epochs {
-		if epoch.Epoch() >= startEpoch && epoch.Epoch() <= endEpoch {
-			epochs = append(epochs, epoch)
-		}
-	}
-
-	// sort epochs by epoch number (from biggest to smallest):
-	sort.Slice(epochs, func(i, j int) bool {
-		return epochs[i].epoch > epochs[j].epoch
+	epochs, release := ser.openEpochsInEpochDescendingOrder(func(h *epochHandle) bool {
+		return h.number >= startEpoch && h.number <= endEpoch
 	})
 
 	gsfaReaders := make([]*gsfa.GsfaReader, 0, len(epochs))
@@ -76,10 +76,11 @@ func (ser *MultiEpoch) getGsfaReadersInEpochDescendingOrderForSlotRange(ctx cont
 	gsfaReaderMultiEpoch, err := gsfa.NewGsfaReaderMultiepoch(gsfaReaders)
 	if err != nil {
 		klog.Errorf("failed to construct gsfaReaderMultiEpoch: %w", err)
-		return nil, nil
+		release()
+		return nil, nil, func() {}
 	}
 
-	return gsfaReaderMultiEpoch, epochNums
+	return gsfaReaderMultiEpoch, epochNums, release
 }
 
 func countTransactions(v gsfa.EpochToTransactionObjects) int {
@@ -95,8 +96,6 @@ func (multi *MultiEpoch) handleGetSignaturesForAddress(ctx context.Context, conn
 	// - get list of epochs (from most recent to oldest)
 	// - iterate until we find the requested number of signatures
 	// - expand the signatures with tx data
-	signaturesOnly := multi.options.GsfaOnlySignatures
-
 	params, err := parseGetSignaturesForAddressParams(req.Params)
 	if err != nil {
 		return &jsonrpc2.Error{
@@ -104,24 +103,29 @@ func (multi *MultiEpoch) handleGetSignaturesForAddress(ctx context.Context, conn
 			Message: "Invalid params",
 		}, fmt.Errorf("failed to parse params: %v", err)
 	}
-	pk := params.Address
-	limit := params.Limit
 
-	gsfaIndexes, _ := multi.getGsfaReadersInEpochDescendingOrder()
+	gsfaIndexes, epochNumbers, release := multi.getGsfaReadersInEpochDescendingOrder()
+	defer release()
 	if len(gsfaIndexes) == 0 {
 		return &jsonrpc2.Error{
 			Code:    jsonrpc2.CodeInternalError,
 			Message: "getSignaturesForAddress method is not enabled",
 		}, fmt.Errorf("no gsfa indexes found")
 	}
+	return multi.replySignatures(ctx, conn, req, gsfaIndexes, epochNumbers, params)
+}
 
-	gsfaMulti, err := gsfa.NewGsfaReaderMultiepoch(gsfaIndexes)
-	if err != nil {
-		return &jsonrpc2.Error{
-			Code:    jsonrpc2.CodeInternalError,
-			Message: "Internal error",
-		}, fmt.Errorf("failed to create gsfa multiepoch reader: %w", err)
-	}
+// replySignatures replies with the transactions of params.Address in the indexes (in the gsfa
+// format), in the format of getSignaturesForAddress.
+func (multi *MultiEpoch) replySignatures(
+	ctx context.Context,
+	conn *requestContext,
+	req *jsonrpc2.Request,
+	gsfaIndexes []*gsfa.GsfaReader,
+	epochNumbers []uint64,
+	params *GetSignaturesForAddressParams,
+) (*jsonrpc2.Error, error) {
+	signaturesOnly := multi.options.GsfaOnlySignatures
 
 	var blockTimeCache struct {
 		m  map[uint64]int64
@@ -138,7 +142,7 @@ func (multi *MultiEpoch) handleGetSignaturesForAddress(ctx context.Context, conn
 		if blocktimeIndex != nil {
 			blocktime, err := blocktimeIndex.Get(slot)
 			if err != nil {
-				klog.Errorf("failed to get block time for slot %d: %v", slot, err)
+				rpcRequestLogFromContext(ctx).Logger().Error("failed to get block time", "slot", slot, "error", err)
 				return 0
 			}
 			blockTimeCache.m[slot] = blocktime
@@ -148,31 +152,7 @@ func (multi *MultiEpoch) handleGetSignaturesForAddress(ctx context.Context, conn
 	}
 
 	// Get the transactions:
-	foundTransactions, err := gsfaMulti.GetBeforeUntil(
-		ctx,
-		pk,
-		limit,
-		params.Before,
-		params.Until,
-		func(epochNum uint64, oas linkedlog.OffsetAndSizeAndSlot) (*ipldbindcode.Transaction, error) {
-			epoch, err := multi.GetEpoch(epochNum)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get epoch %d: %w", epochNum, err)
-			}
-			raw, err := epoch.GetNodeByOffsetAndSize(ctx, nil, &indexes.OffsetAndSize{
-				Offset: oas.Offset,
-				Size:   oas.Size,
-			})
-			if err != nil {
-				return nil, fmt.Errorf("failed to get signature: %w", err)
-			}
-			decoded, err := iplddecoders.DecodeTransaction(raw)
-			if err != nil {
-				return nil, fmt.Errorf("error while decoding transaction from nodex at offset %d: %w", oas.Offset, err)
-			}
-			return decoded, nil
-		},
-	)
+	foundTransactions, foundEpochs, err := multi.getSignaturesForAddress(ctx, gsfaIndexes, epochNumbers, params)
 	if err != nil {
 		return &jsonrpc2.Error{
 			Code:    jsonrpc2.CodeInternalError,
@@ -195,24 +175,24 @@ func (multi *MultiEpoch) handleGetSignaturesForAddress(ctx context.Context, conn
 	// The response is an array of objects: [{signature: string}]
 	response := make([]map[string]any, countTransactions(foundTransactions))
 	numBefore := 0
-	for ei := range foundTransactions {
-		epoch := ei
-		ser, err := multi.GetEpoch(epoch)
+	for _, epoch := range foundEpochs {
+		ser, release, err := multi.GetEpoch(epoch)
 		if err != nil {
 			return &jsonrpc2.Error{
 				Code:    jsonrpc2.CodeInternalError,
 				Message: "Internal error",
 			}, fmt.Errorf("failed to get epoch %d: %w", epoch, err)
 		}
+		defer release()
 
-		sigs := foundTransactions[ei]
+		sigs := foundTransactions[epoch]
 		for i := range sigs {
 			ii := numBefore + i
 			transactionNode := sigs[i]
 			err := func() error {
 				sig, err := transactionNode.Signature()
 				if err != nil {
-					klog.Errorf("failed to get signature: %v", err)
+					rpcRequestLogFromContext(ctx).Logger().Error("failed to get signature", "error", err)
 					return nil
 				}
 				response[ii] = map[string]any{
@@ -224,16 +204,34 @@ func (multi *MultiEpoch) handleGetSignaturesForAddress(ctx context.Context, conn
 
 				{
 					{
-						tx, meta, err := parseTransactionAndMetaFromNode(transactionNode, ser.GetDataFrameByCid)
-						if err == nil {
-							response[ii]["err"] = getErr(meta)
-
-							memoData := getMemoInstructionDataFromTransaction(&tx)
-							if memoData != nil {
-								response[ii]["memo"] = string(memoData)
+						// The transactions that succeeded have no err: with their status in the indexes,
+						// their meta is not decoded.
+						status, known, err := ser.FindSigStatus(ctx, sig)
+						if err != nil {
+							rpcRequestLogFromContext(ctx).Logger().Debug("failed to find signature status", "signature", sig, "error", err)
+						}
+						if known && !status.Failed() && !status.MetaMissing() {
+							tx, err := parseTransactionFromNode(ctx, transactionNode, ser.GetDataFrameByCid)
+							if err == nil {
+								memoData := getMemoInstructionDataFromTransaction(&tx)
+								if memoData != nil {
+									response[ii]["memo"] = string(memoData)
+								}
+							} else {
+								rpcRequestLogFromContext(ctx).Logger().Error("failed to parse transaction", "signature", sig, "error", err)
 							}
 						} else {
-							klog.Errorf("failed to parse transaction and meta for signature %s: %v", sig, err)
+							tx, meta, err := parseTransactionAndMetaFromNode(ctx, transactionNode, ser.GetDataFrameByCid)
+							if err == nil {
+								response[ii]["err"] = getErr(meta)
+
+								memoData := getMemoInstructionDataFromTransaction(&tx)
+								if memoData != nil {
+									response[ii]["memo"] = string(memoData)
+								}
+							} else {
+								rpcRequestLogFromContext(ctx).Logger().Error("failed to parse transaction and meta", "signature", sig, "error", err)
+							}
 						}
 
 						if _, ok := response[ii]["memo"]; !ok {
@@ -275,3 +273,128 @@ func (multi *MultiEpoch) handleGetSignaturesForAddress(ctx context.Context, conn
 
 	return nil, nil
 }
+
+// getSignaturesForAddress gets the transactions of the address from the gsfa indexes (in epoch
+// descending order), and the epochs that have transactions, from the most recent to the oldest.
+//
+// The epochs are queried concurrently with the query planner: the epoch of the before signature
+// (and the ones before it) and the epoch of the until signature (and the ones after it) bound the
+// epochs that are queried, and the queries still running are canceled once the limit is reached.
+func (multi *MultiEpoch) getSignaturesForAddress(
+	ctx context.Context,
+	gsfaIndexes []*gsfa.GsfaReader,
+	epochNumbers []uint64,
+	params *GetSignaturesForAddressParams,
+) (gsfa.EpochToTransactionObjects, []uint64, error) {
+	newest, oldest := uint64(math.MaxUint64), uint64(0)
+	var before, until *gsfa.SignatureAtSlot
+	if params.Before != nil {
+		epochNumber, sigAtSlot, err := multi.signatureAtSlot(ctx, *params.Before)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				// no signature is before a signature that doesn't exist.
+				return make(gsfa.EpochToTransactionObjects), nil, nil
+			}
+			return nil, nil, fmt.Errorf("failed to find the epoch of the before signature: %w", err)
+		}
+		newest, before = epochNumber, sigAtSlot
+	}
+	if params.Until != nil {
+		epochNumber, sigAtSlot, err := multi.signatureAtSlot(ctx, *params.Until)
+		if err == nil {
+			oldest, until = epochNumber, sigAtSlot
+		} else if !errors.Is(err, ErrNotFound) {
+			return nil, nil, fmt.Errorf("failed to find the epoch of the until signature: %w", err)
+		}
+	}
+
+	tasks := make([]queryTask[*gsfa.EpochTransactions], 0, len(gsfaIndexes))
+	for i, index := range gsfaIndexes {
+		epochNumber := epochNumbers[i]
+		if epochNumber > newest || epochNumber < oldest {
+			continue
+		}
+		epoch, release, err := multi.GetEpoch(epochNumber)
+		if err != nil {
+			// removed in the meantime.
+			continue
+		}
+		defer release()
+		var epochBefore *gsfa.SignatureAtSlot
+		if epochNumber == newest {
+			epochBefore = before
+		}
+		index := index
+		tasks = append(tasks, queryTask[*gsfa.EpochTransactions]{
+			epoch:  epochNumber,
+			remote: epoch.isRemote(),
+			run: func(ctx context.Context) (*gsfa.EpochTransactions, error) {
+				return index.GetTransactionsBeforeUntil(ctx, params.Address, params.Limit, epochBefore, until,
+					func(oas linkedlog.OffsetAndSizeAndSlot) (*ipldbindcode.Transaction, error) {
+						raw, err := epoch.GetNodeByOffsetAndSize(ctx, nil, &indexes.OffsetAndSize{
+							Offset: oas.Offset,
+							Size:   oas.Size,
+						})
+						if err != nil {
+							return nil, fmt.Errorf("failed to get signature: %w", err)
+						}
+						decoded, err := iplddecoders.DecodeTransaction(raw)
+						if err != nil {
+							return nil, fmt.Errorf("error while decoding transaction from nodex at offset %d: %w", oas.Offset, err)
+						}
+						return decoded, nil
+					},
+				)
+			},
+		})
+	}
+
+	transactions := make(gsfa.EpochToTransactionObjects)
+	foundEpochs := make([]uint64, 0)
+	count := 0
+	i := 0
+	err := runQueryPlan(ctx, multi.queryPlanOptions(), tasks, func(found *gsfa.EpochTransactions) bool {
+		epochNumber := tasks[i].epoch
+		i++
+		if !found.ReachedBefore {
+			// the before signature is not a transaction of the address.
+			return true
+		}
+		txs := found.Transactions
+		if len(txs) > params.Limit-count {
+			txs = txs[:params.Limit-count]
+		}
+		if len(txs) > 0 {
+			transactions[epochNumber] = txs
+			foundEpochs = append(foundEpochs, epochNumber)
+			count += len(txs)
+		}
+		return found.ReachedUntil || count >= params.Limit
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return transactions, foundEpochs, nil
+}
+
+// signatureAtSlot finds the epoch and the slot of the transaction of the signature, so that the
+// gsfa iterators can seek to it; the error is ErrNotFound if there's no such transaction.
+func (multi *MultiEpoch) signatureAtSlot(ctx context.Context, sig solana.Signature) (uint64, *gsfa.SignatureAtSlot, error) {
+	epochNumber, err := multi.findEpochNumberFromSignature(ctx, sig)
+	if err != nil {
+		return 0, nil, err
+	}
+	epoch, release, err := multi.GetEpoch(epochNumber)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get handler for epoch %d: %w: %w", epochNumber, ErrNotFound, err)
+	}
+	defer release()
+	tx, _, err := epoch.GetTransaction(ctx, sig)
+	if err != nil {
+		if errors.Is(err, compactindexsized.ErrNotFound) {
+			return 0, nil, fmt.Errorf("transaction %s not found: %w", sig, ErrNotFound)
+		}
+		return 0, nil, fmt.Errorf("failed to get transaction %s: %w", sig, err)
+	}
+	return epochNumber, &gsfa.SignatureAtSlot{Signature: sig, Slot: uint64(tx.Slot)}, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func TestGetSignaturesForAddressWithStatusIndex(t *testing.T) {
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+	paths, _, _, err := buildTestEpochIndexes(carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+	sigToCidV2Path, err := CreateIndex_sig2cid(context.Background(), 0, indexes.NetworkMainnet, t.TempDir(), carPath, t.TempDir(), true)
+	require.NoError(t, err)
+
+	indexDir := t.TempDir()
+	app := &cli.App{Commands: []*cli.Command{newCmd_Index_gsfa()}}
+	require.NoError(t, app.Run([]string{"faithful-cli", "gsfa", "--epoch=0", "--tmp-dir=" + t.TempDir(), carPath, indexDir}))
+	gsfaDirs, err := filepath.Glob(filepath.Join(indexDir, "*-gsfa.indexdir"))
+	require.NoError(t, err)
+	require.Len(t, gsfaDirs, 1)
+
+	// the account of the most transactions, which include failed ones and ones without meta.
+	numTransactions := make(map[solana.PublicKey]int)
+	var account solana.PublicKey
+	for _, tx := range fixture.Transactions {
+		for _, key := range tx.Accounts {
+			numTransactions[key]++
+			if numTransactions[key] > numTransactions[account] {
+				account = key
+			}
+		}
+	}
+
+	getSignatures := func(t *testing.T, sigToCidPath string) []map[string]any {
+		config := testEpochConfig(t, carPath, paths)
+		config.Indexes.SigToCid.URI = URI(sigToCidPath)
+		config.Indexes.Gsfa.URI = URI(gsfaDirs[0])
+		multi := NewMultiEpoch(&Options{EpochSearchConcurrency: 4})
+		require.NoError(t, multi.AddEpoch(0, openTestEpoch(t, config)))
+
+		var req fasthttp.Request
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetRequestURI("/")
+		req.SetBodyString(`{"jsonrpc":"2.0","id":1,"method":"getSignaturesForAddress","params":["` + account.String() + `"]}`)
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		newMultiEpochHandler(multi, nil)(&reqCtx)
+		var resp struct {
+			Result []map[string]any `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &resp), "%s", reqCtx.Response.Body())
+		return resp.Result
+	}
+
+	// without the status, every meta is decoded.
+	want := getSignatures(t, paths.SignatureToCid)
+	require.Len(t, want, numTransactions[account])
+	var numFailed int
+	for _, tx := range fixture.Transactions {
+		for _, got := range want {
+			if got["signature"] == tx.Signature.String() && tx.Spec.Failed {
+				require.NotNil(t, got["err"])
+				numFailed++
+			}
+		}
+	}
+	require.NotZero(t, numFailed)
+
+	// with the v2 sig-to-cid index, the errs are the same.
+	require.Equal(t, want, getSignatures(t, sigToCidV2Path))
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// handleGetSignaturesForProgram handles faithful_getSignaturesForProgram, an extension method: it
+// has the params and the response of getSignaturesForAddress, but the address is a program and
+// the result is the transactions that invoked it (from the gpfa indexes).
+func (multi *MultiEpoch) handleGetSignaturesForProgram(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (*jsonrpc2.Error, error) {
+	params, err := parseGetSignaturesForAddressParams(req.Params)
+	if err != nil {
+		return &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: "Invalid params",
+		}, fmt.Errorf("failed to parse params: %v", err)
+	}
+
+	gpfaIndexes, epochNumbers, release := multi.getGpfaReadersInEpochDescendingOrder()
+	defer release()
+	if len(gpfaIndexes) == 0 {
+		return &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInternalError,
+			Message: "faithful_getSignaturesForProgram method is not enabled",
+		}, fmt.Errorf("no gpfa indexes found")
+	}
+	return multi.replySignatures(ctx, conn, req, gpfaIndexes, epochNumbers, params)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/accum"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestInvokedProgramsOfTransaction(t *testing.T) {
+	payer, programA, programB, account := solana.PublicKey{1}, solana.PublicKey{2}, solana.PublicKey{3}, solana.PublicKey{4}
+	tx := &accum.TransactionWithSlot{}
+	tx.Transaction.Message.AccountKeys = solana.PublicKeySlice{payer, account, programA, programB}
+	tx.Transaction.Message.Instructions = []solana.CompiledInstruction{
+		{ProgramIDIndex: 2, Accounts: []uint16{1}},
+		{ProgramIDIndex: 2},
+		{ProgramIDIndex: 3},
+		{ProgramIDIndex: 9}, // out of range.
+	}
+	require.Equal(t, solana.PublicKeySlice{programA, programB}.Dedupe(), invokedProgramsOfTransaction(tx))
+}
+
+func TestGetSignaturesForProgram(t *testing.T) {
+	ctx := context.Background()
+	config, _, _, _ := buildTestEpoch(t)
+	carPath := string(config.Data.Car.URI)
+
+	// the transactions of each program, from the most recent to the oldest.
+	byProgram := make(map[solana.PublicKey][]string)
+	source := carwriter.NewCarSource(carPath)
+	defer source.Close()
+	require.NoError(t, source.Blocks(ctx, 0, 9, func(block *carwriter.Block) error {
+		for _, entry := range block.Entries {
+			for _, raw := range entry.Transactions {
+				var tx solana.Transaction
+				require.NoError(t, tx.UnmarshalWithDecoder(bin.NewBinDecoder(raw.Data)))
+				withSlot := &accum.TransactionWithSlot{Transaction: tx}
+				for _, program := range invokedProgramsOfTransaction(withSlot) {
+					byProgram[program] = append([]string{tx.Signatures[0].String()}, byProgram[program]...)
+				}
+			}
+		}
+		return nil
+	}))
+	require.NotEmpty(t, byProgram)
+
+	indexDir := t.TempDir()
+	app := &cli.App{Commands: []*cli.Command{newCmd_Index_gpfa()}}
+	require.NoError(t, app.Run([]string{"faithful-cli", "gpfa", "--epoch=0", "--tmp-dir=" + t.TempDir(), carPath, indexDir}))
+	matches, err := filepath.Glob(filepath.Join(indexDir, "*-gpfa.indexdir"))
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Contains(t, matches[0], string(indexes.NetworkMainnet))
+
+	call := func(t *testing.T, multi *MultiEpoch, params string) json.RawMessage {
+		ln := fasthttputil.NewInmemoryListener()
+		defer ln.Close()
+		server := &fasthttp.Server{Handler: newMultiEpochHandler(multi, nil)}
+		go server.Serve(ln)
+		client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+		req.SetRequestURI("http://faithful/")
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetBody([]byte(`{"jsonrpc":"2.0","id":1,"method":"faithful_getSignaturesForProgram","params":` + params + `}`))
+		require.NoError(t, client.Do(req, resp))
+		var out struct {
+			Result json.RawMessage `json:"result"`
+			Error  *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(resp.Body(), &out), string(resp.Body()))
+		if out.Error != nil {
+			return json.RawMessage(`"` + out.Error.Message + `"`)
+		}
+		return out.Result
+	}
+	signatures := func(t *testing.T, result json.RawMessage) []string {
+		var txs []struct {
+			Signature string `json:"signature"`
+		}
+		require.NoError(t, json.Unmarshal(result, &txs), string(result))
+		sigs := make([]string, 0, len(txs))
+		for _, tx := range txs {
+			sigs = append(sigs, tx.Signature)
+		}
+		return sigs
+	}
+
+	t.Run("not enabled", func(t *testing.T) {
+		multi := NewMultiEpoch(&Options{GsfaOnlySignatures: true})
+		require.NoError(t, multi.AddEpoch(0, openTestEpoch(t, config)))
+		require.JSONEq(t, `"faithful_getSignaturesForProgram method is not enabled"`, string(call(t, multi, `["Vote111111111111111111111111111111111111111"]`)))
+	})
+
+	withGpfa := *config
+	withGpfa.Indexes.Gpfa.URI = URI(matches[0])
+	require.NoError(t, withGpfa.Validate())
+	multi := NewMultiEpoch(&Options{GsfaOnlySignatures: true})
+	require.NoError(t, multi.AddEpoch(0, openTestEpoch(t, &withGpfa)))
+
+	for program, want := range byProgram {
+		require.Equal(t, want, signatures(t, call(t, multi, `["`+program.String()+`"]`)), program.String())
+		if len(want) > 2 {
+			got := signatures(t, call(t, multi, `["`+program.String()+`", {"limit": 1, "before": "`+want[0]+`"}]`))
+			require.Equal(t, want[1:2], got)
+		}
+	}
+	require.Empty(t, signatures(t, call(t, multi, `["`+solana.PublicKey{9}.String()+`"]`)))
+}
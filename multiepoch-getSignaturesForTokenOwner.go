@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// handleGetSignaturesForTokenOwner handles faithful_getSignaturesForTokenOwner, an extension
+// method: the params are an owner, a mint and the optional params of getSignaturesForAddress, and
+// the result (in the format of getSignaturesForAddress) is the transactions that changed the
+// token balance of the owner for the mint (from the token balances indexes).
+func (multi *MultiEpoch) handleGetSignaturesForTokenOwner(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (*jsonrpc2.Error, error) {
+	params, err := parseGetSignaturesForTokenOwnerParams(req.Params)
+	if err != nil {
+		return &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: "Invalid params",
+		}, fmt.Errorf("failed to parse params: %v", err)
+	}
+
+	tokenBalancesIndexes, epochNumbers, release := multi.getTokenBalancesReadersInEpochDescendingOrder()
+	defer release()
+	if len(tokenBalancesIndexes) == 0 {
+		return &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInternalError,
+			Message: "faithful_getSignaturesForTokenOwner method is not enabled",
+		}, fmt.Errorf("no token balances indexes found")
+	}
+	return multi.replySignatures(ctx, conn, req, tokenBalancesIndexes, epochNumbers, params)
+}
+
+// parseGetSignaturesForTokenOwnerParams parses [owner, mint, {limit, before, until}]; the address
+// of the result is the key of the owner and the mint in the token balances index.
+func parseGetSignaturesForTokenOwnerParams(raw *json.RawMessage) (*GetSignaturesForAddressParams, error) {
+	var params []any
+	if err := fasterJson.Unmarshal(*raw, &params); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal params: %w", err)
+	}
+	if len(params) < 2 {
+		return nil, fmt.Errorf("expected at least 2 params")
+	}
+	var keys [2]solana.PublicKey
+	for i, name := range []string{"owner", "mint"} {
+		s, ok := params[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", name)
+		}
+		pk, err := solana.PublicKeyFromBase58(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s from base58: %w", name, err)
+		}
+		keys[i] = pk
+	}
+
+	out := &GetSignaturesForAddressParams{
+		Address: tokenBalanceKey(keys[0], keys[1]),
+	}
+	if len(params) > 2 {
+		if err := parseSignaturesOptions(params[2], out); err != nil {
+			return nil, err
+		}
+	}
+	if out.Limit <= 0 || out.Limit > 1000 {
+		// default limit
+		out.Limit = 1000
+	}
+	return out, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/accum"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTokenBalanceKeysOfTransaction(t *testing.T) {
+	alice, bob, carol := solana.PublicKey{1}, solana.PublicKey{2}, solana.PublicKey{3}
+	usdc, bonk := solana.PublicKey{10}, solana.PublicKey{11}
+	balance := func(accountIndex uint32, owner, mint solana.PublicKey, amount string) *confirmed_block.TokenBalance {
+		return &confirmed_block.TokenBalance{
+			AccountIndex:  accountIndex,
+			Owner:         owner.String(),
+			Mint:          mint.String(),
+			UiTokenAmount: &confirmed_block.UiTokenAmount{Amount: amount},
+		}
+	}
+	buf, err := proto.Marshal(&confirmed_block.TransactionStatusMeta{
+		PreTokenBalances: []*confirmed_block.TokenBalance{
+			balance(1, alice, usdc, "100"),
+			balance(2, bob, usdc, "5"),
+			balance(3, carol, bonk, "7"), // unchanged.
+			balance(4, carol, usdc, "1"), // closed.
+			{AccountIndex: 5, Mint: usdc.String(), UiTokenAmount: &confirmed_block.UiTokenAmount{Amount: "1"}}, // no owner.
+		},
+		PostTokenBalances: []*confirmed_block.TokenBalance{
+			balance(1, alice, usdc, "90"),
+			balance(2, bob, usdc, "15"),
+			balance(3, carol, bonk, "7"),
+			balance(6, bob, bonk, "3"), // created.
+			{AccountIndex: 5, Mint: usdc.String(), UiTokenAmount: &confirmed_block.UiTokenAmount{Amount: "2"}},
+		},
+	})
+	require.NoError(t, err)
+	meta, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(buf)
+	require.NoError(t, err)
+	require.True(t, meta.IsProtobuf())
+
+	got := tokenBalanceKeysOfTransaction(&accum.TransactionWithSlot{Metadata: meta})
+	want := solana.PublicKeySlice{
+		tokenBalanceKey(alice, usdc),
+		tokenBalanceKey(bob, usdc),
+		tokenBalanceKey(carol, usdc),
+		tokenBalanceKey(bob, bonk),
+	}.Dedupe()
+	require.Equal(t, want, got)
+
+	require.Empty(t, tokenBalanceKeysOfTransaction(&accum.TransactionWithSlot{}))
+}
+
+func TestParseGetSignaturesForTokenOwnerParams(t *testing.T) {
+	owner, mint := solana.PublicKey{1}, solana.PublicKey{2}
+	parse := func(params string) (*GetSignaturesForAddressParams, error) {
+		raw := json.RawMessage(params)
+		return parseGetSignaturesForTokenOwnerParams(&raw)
+	}
+	before := solana.Signature{3}
+	got, err := parse(`["` + owner.String() + `", "` + mint.String() + `", {"limit": 5, "before": "` + before.String() + `"}]`)
+	require.NoError(t, err)
+	require.Equal(t, tokenBalanceKey(owner, mint), got.Address)
+	require.Equal(t, 5, got.Limit)
+	require.Equal(t, before, *got.Before)
+	require.Nil(t, got.Until)
+	require.NotEqual(t, tokenBalanceKey(mint, owner), got.Address)
+
+	got, err = parse(`["` + owner.String() + `", "` + mint.String() + `"]`)
+	require.NoError(t, err)
+	require.Equal(t, 1000, got.Limit)
+
+	_, err = parse(`["` + owner.String() + `"]`)
+	require.Error(t, err)
+	_, err = parse(`["` + owner.String() + `", "not a mint"]`)
+	require.Error(t, err)
+}
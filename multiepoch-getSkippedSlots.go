@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// handleGetSkippedSlots handles faithful_getSkippedSlots, an extension that returns the skipped slots
+// (the slots without a block) between a start and an end slot; it takes the params of getBlocks.
+func (multi *MultiEpoch) handleGetSkippedSlots(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (*jsonrpc2.Error, error) {
+	startSlot, endSlot, err := parseGetBlocksRequest(req.Params)
+	if err != nil {
+		return &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: "Invalid params",
+		}, fmt.Errorf("failed to parse params: %w", err)
+	}
+	highestSlot, err := multi.highestServedSlot()
+	if err != nil {
+		return rpcerrors.NewInternal(), err
+	}
+	end := startSlot + maxGetBlocksRange
+	if endSlot != nil {
+		end = *endSlot
+	}
+	end = min(end, highestSlot)
+	slots := make([]uint64, 0)
+	if end >= startSlot {
+		if end-startSlot > maxGetBlocksRange {
+			return rpcerrors.NewInvalidParams(fmt.Sprintf("Slot range too large; max %d", maxGetBlocksRange)), fmt.Errorf("slot range too large: %d-%d", startSlot, end)
+		}
+		slots, err = multi.GetSkippedSlots(ctx, startSlot, end)
+		if err != nil {
+			return rpcerrors.NewInternal(), fmt.Errorf("failed to list skipped slots: %w", err)
+		}
+	}
+	if err := conn.ReplyRaw(ctx, req.ID, slots); err != nil {
+		return nil, fmt.Errorf("failed to reply: %w", err)
+	}
+	return nil, nil
+}
+
+// GetSkippedSlots returns the slots without a block between start and end (inclusive) in ascending
+// order, across the epochs that are served; the slots of the epochs that are not served are not
+// returned, as whether they have a block is unknown.
+func (multi *MultiEpoch) GetSkippedSlots(ctx context.Context, start, end uint64) ([]uint64, error) {
+	epochNumbers := multi.GetEpochNumbers()
+	sort.Slice(epochNumbers, func(i, j int) bool { return epochNumbers[i] < epochNumbers[j] })
+	startEpoch := slottools.CalcEpochForSlot(start)
+	endEpoch := slottools.CalcEpochForSlot(end)
+	tasks := make([]queryTask[[]uint64], 0)
+	for _, epochNumber := range epochNumbers {
+		if epochNumber < startEpoch || epochNumber > endEpoch {
+			continue
+		}
+		epochHandler, release, err := multi.GetEpoch(epochNumber)
+		if err != nil {
+			// removed in the meantime.
+			continue
+		}
+		defer release()
+		first, last := slottools.CalcEpochLimits(epochNumber)
+		tasks = append(tasks, queryTask[[]uint64]{
+			epoch:  epochNumber,
+			remote: epochHandler.isRemote(),
+			run: func(ctx context.Context) ([]uint64, error) {
+				return epochHandler.GetSkippedSlots(ctx, max(start, first), min(end, last))
+			},
+		})
+	}
+	slots := make([]uint64, 0)
+	err := runQueryPlan(ctx, multi.queryPlanOptions(), tasks, func(epochSlots []uint64) bool {
+		slots = append(slots, epochSlots...)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slots, nil
+}
+
+// GetSkippedSlots returns the slots of the epoch between start and end (inclusive) that have no block,
+// in ascending order; the blocks are listed like GetBlockSlots does.
+func (e *Epoch) GetSkippedSlots(ctx context.Context, start, end uint64) ([]uint64, error) {
+	blocks, err := e.GetBlockSlots(ctx, start, end, 0)
+	if err != nil {
+		return nil, err
+	}
+	skipped := make([]uint64, 0)
+	for slot := start; slot <= end; slot++ {
+		if len(blocks) > 0 && blocks[0] == slot {
+			blocks = blocks[1:]
+		} else {
+			skipped = append(skipped, slot)
+		}
+		if slot == end {
+			// avoid overflow
+			break
+		}
+	}
+	return skipped, nil
+}
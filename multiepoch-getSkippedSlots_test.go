@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	"github.com/rpcpool/yellowstone-faithful/indexes"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestGetSkippedSlots(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	carPath := filepath.Join(dir, "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+	paths, _, _, err := buildTestEpochIndexes(carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+	config := testEpochConfig(t, carPath, paths)
+
+	blocks := fixture.Slots()
+	last := blocks[len(blocks)-1] + 3
+	var want []uint64
+	for slot := uint64(0); slot <= last; slot++ {
+		if !slices.Contains(blocks, slot) {
+			want = append(want, slot)
+		}
+	}
+	require.NotEmpty(t, want)
+
+	check := func(t *testing.T, epoch *Epoch) {
+		multi := NewMultiEpoch(&Options{})
+		require.NoError(t, multi.AddEpoch(0, epoch))
+
+		got, err := multi.GetSkippedSlots(ctx, 0, last)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+
+		// epochs that are not served are left out:
+		got, err = multi.GetSkippedSlots(ctx, 431_998, 432_001)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{431_998, 431_999}, got)
+
+		handler := newMultiEpochHandler(multi, nil)
+		call := func(params string) (json.RawMessage, json.RawMessage) {
+			var req fasthttp.Request
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.SetRequestURI("/")
+			req.SetBodyString(`{"jsonrpc":"2.0","id":1,"method":"faithful_getSkippedSlots","params":` + params + `}`)
+			var reqCtx fasthttp.RequestCtx
+			reqCtx.Init(&req, nil, nil)
+			handler(&reqCtx)
+			var resp struct {
+				Result json.RawMessage `json:"result"`
+				Error  json.RawMessage `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &resp), "%s", reqCtx.Response.Body())
+			return resp.Result, resp.Error
+		}
+		result, respErr := call(`[0, ` + strconv.FormatUint(last, 10) + `]`)
+		require.Empty(t, respErr)
+		wantJSON, err := json.Marshal(want)
+		require.NoError(t, err)
+		require.JSONEq(t, string(wantJSON), string(result))
+
+		// the range is capped to the last slot served.
+		result, respErr = call(`[431998, 600000]`)
+		require.Empty(t, respErr)
+		require.JSONEq(t, `[431998, 431999]`, string(result))
+		_, respErr = call(`["0"]`)
+		require.Contains(t, string(respErr), "Invalid params")
+	}
+
+	t.Run("indexes", func(t *testing.T) {
+		check(t, openTestEpoch(t, config))
+	})
+
+	t.Run("block manifest", func(t *testing.T) {
+		manifestPath, err := CreateIndex_blockManifest(ctx, 0, indexes.NetworkMainnet, carPath, t.TempDir())
+		require.NoError(t, err)
+		config.Indexes.BlockManifest.URI = URI(manifestPath)
+		require.NoError(t, config.Validate())
+		epoch := openTestEpoch(t, config)
+		require.NotNil(t, epoch.blockManifest)
+		check(t, epoch)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
@@ -11,10 +12,7 @@ func (multi *MultiEpoch) handleGetSlot(ctx context.Context, conn *requestContext
 	// TODO: parse params?
 	lastBlock, err := multi.GetMostRecentAvailableBlock(ctx)
 	if err != nil {
-		return &jsonrpc2.Error{
-			Code:    CodeNotFound,
-			Message: "Internal error",
-		}, fmt.Errorf("failed to get first available block: %w", err)
+		return rpcerrors.NewInternal(), fmt.Errorf("failed to get first available block: %w", err)
 	}
 
 	slotNumber := uint64(lastBlock.Slot)
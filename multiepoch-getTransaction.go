@@ -5,102 +5,87 @@ import (
 	"errors"
 	"fmt"
 	"sort"
-	"time"
 
 	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
 	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
 	"github.com/sourcegraph/jsonrpc2"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"k8s.io/klog/v2"
 )
 
 type SigExistsIndex interface {
 	Has(sig [64]byte) (bool, error)
 }
 
-func (multi *MultiEpoch) getAllBucketteers() map[uint64]SigExistsIndex {
+// getSigEpochResolver returns the resolver of the signature lookups, creating it if needed.
+func (multi *MultiEpoch) getSigEpochResolver() *sigEpochResolver {
+	multi.sigResolverOnce.Do(func() {
+		options := multi.options
+		if options == nil {
+			options = &Options{}
+		}
+		multi.sigResolver = newSigEpochResolver(
+			multi.sigEpochCandidates,
+			func() uint64 {
+				multi.mu.RLock()
+				defer multi.mu.RUnlock()
+				return multi.epochsGeneration
+			},
+			options.EpochSearchConcurrency,
+			options.SigNegativeCacheTTL,
+			options.SigSummaryBitsPerKey,
+		)
+	})
+	return multi.sigResolver
+}
+
+// sigEpochCandidates returns the epochs that have a sig-exists index, from the most recent to the oldest.
+func (multi *MultiEpoch) sigEpochCandidates() (uint64, []sigEpochCandidate) {
 	multi.mu.RLock()
 	defer multi.mu.RUnlock()
-	bucketteers := make(map[uint64]SigExistsIndex)
-	for _, epoch := range multi.epochs {
-		if epoch.sigExists != nil {
-			bucketteers[epoch.Epoch()] = epoch.sigExists
+	candidates := make([]sigEpochCandidate, 0, len(multi.epochs))
+	for number, h := range multi.epochs {
+		// the index takes a reference to the epoch when it's probed (and opens a lazy epoch).
+		if epoch := h.epoch.Load(); !h.isLazy() && (epoch == nil || epoch.sigExists == nil) {
+			continue
 		}
+		index := h.sigExists
+		h := h
+		candidates = append(candidates, sigEpochCandidate{
+			number: number,
+			index:  index,
+			verify: func(ctx context.Context, sig solana.Signature) error {
+				epoch, err := h.get()
+				if err != nil {
+					return err
+				}
+				defer h.release()
+				_, err = epoch.FindCidFromSignature(ctx, sig)
+				return err
+			},
+		})
 	}
-	return bucketteers
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].number > candidates[j].number
+	})
+	return multi.epochsGeneration, candidates
 }
 
 func (multi *MultiEpoch) findEpochNumberFromSignature(ctx context.Context, sig solana.Signature) (uint64, error) {
 	// FLOW:
 	// - if one epoch, just return that epoch
-	// - if multiple epochs, use sigToEpoch to find the epoch number
-	// - if sigToEpoch is not available, linear search through all epochs
-	ttok := time.Now()
-	defer func() {
-		klog.V(4).Infof("findEpochNumberFromSignature took %s", time.Since(ttok))
-	}()
+	// - if multiple epochs, look the signature up in the sig-exists indexes of the epochs
+	//   (see sigEpochResolver), and verify the candidates with their sig-to-cid indexes
+	reqLog := rpcRequestLogFromContext(ctx)
+	defer reqLog.Step("findEpochNumberFromSignature")
 
 	if epochs := multi.GetEpochNumbers(); len(epochs) == 1 {
 		return epochs[0], nil
 	}
-
-	numbers := multi.GetEpochNumbers()
-	// sort from highest to lowest:
-	sort.Slice(numbers, func(i, j int) bool {
-		return numbers[i] > numbers[j]
-	})
-
-	buckets := multi.getAllBucketteers()
-
-	// Search all epochs in parallel:
-	jobGroup := NewJobGroup[uint64]()
-	for i := range numbers {
-		epochNumber := numbers[i]
-		jobGroup.Add(func(ctx context.Context) (uint64, error) {
-			if ctx.Err() != nil {
-				return 0, ctx.Err()
-			}
-			bucket, ok := buckets[epochNumber]
-			if !ok {
-				return 0, ErrNotFound
-			}
-			has, err := bucket.Has(sig)
-			if err != nil {
-				return 0, fmt.Errorf("failed to check if signature exists in bucket: %w", err)
-			}
-			if !has {
-				return 0, ErrNotFound
-			}
-			epoch, err := multi.GetEpoch(epochNumber)
-			if err != nil {
-				return 0, fmt.Errorf("failed to get epoch %d: %w", epochNumber, err)
-			}
-			if _, err := epoch.FindCidFromSignature(ctx, sig); err == nil {
-				return epochNumber, nil
-			}
-			// Not found in this epoch.
-			return 0, ErrNotFound
-		})
-	}
-	val, err := jobGroup.RunWithConcurrency(ctx, multi.options.EpochSearchConcurrency)
-	// val, err := jobGroup.RunWithConcurrency(ctx, multi.options.EpochSearchConcurrency)
-	if err != nil {
-		errs, ok := err.(ErrorSlice)
-		if !ok {
-			// An error occurred while searching one of the epochs.
-			return 0, err
-		}
-		// All epochs were searched, but the signature was not found.
-		if errs.All(func(err error) bool {
-			return errors.Is(err, ErrNotFound)
-		}) {
-			return 0, ErrNotFound
-		}
-		return 0, err
-	}
-	// The signature was found in one of the epochs.
-	return val, nil
+	return multi.getSigEpochResolver().find(ctx, sig)
 }
 
 func (multi *MultiEpoch) handleGetTransaction(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (*jsonrpc2.Error, error) {
@@ -125,52 +110,102 @@ func (multi *MultiEpoch) handleGetTransaction(ctx context.Context, conn *request
 		}, fmt.Errorf("failed to validate params: %w", err)
 	}
 
+	reqLog := rpcRequestLogFromContext(ctx)
+	reqLog.SetSignature(params.Signature)
+
+	response, transactionCid, jsonErr, err := multi.getTransaction(ctx, params)
+	if jsonErr != nil || err != nil {
+		return jsonErr, err
+	}
+	{
+		conn.ctx.Response.Header.Set("DAG-Root-CID", transactionCid.String())
+	}
+
+	// reply with the data
+	err = conn.Reply(
+		ctx,
+		req.ID,
+		response,
+		func(m map[string]any) map[string]any {
+			return adaptTransactionMetaToExpectedOutput(m)
+		},
+		getTransactionFieldOrder,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reply: %w", err)
+	}
+	return nil, nil
+}
+
+// getTransaction returns the transaction of the signature, looked up in all the epochs, and the CID
+// of its node; like the handlers, it returns a JSON-RPC error for the client and an error to log.
+func (multi *MultiEpoch) getTransaction(ctx context.Context, params *GetTransactionRequest) (*GetTransactionResponse, cid.Cid, *jsonrpc2.Error, error) {
 	sig := params.Signature
+	reqLog := rpcRequestLogFromContext(ctx)
+
+	if params.Options.MinContextSlot != nil {
+		// the context of an archive is the last slot it serves.
+		lastBlock, err := multi.GetMostRecentAvailableBlock(ctx)
+		if err != nil {
+			return nil, cid.Undef, rpcerrors.NewInternal(), fmt.Errorf("failed to get the most recent available block: %w", err)
+		}
+		if contextSlot := uint64(lastBlock.Slot); contextSlot < *params.Options.MinContextSlot {
+			return nil, cid.Undef, rpcerrors.NewMinContextSlotNotReached(contextSlot),
+				fmt.Errorf("minContextSlot %d is after the last slot served, %d", *params.Options.MinContextSlot, contextSlot)
+		}
+	}
 
-	startedEpochLookupAt := time.Now()
 	epochNumber, err := multi.findEpochNumberFromSignature(ctx, sig)
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
 			// solana just returns null here in case of transaction not found: {"jsonrpc":"2.0","result":null,"id":1}
-			return &jsonrpc2.Error{
-				Code:    CodeNotFound,
+			return nil, cid.Undef, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInternalError,
 				Message: "Transaction not found",
 			}, fmt.Errorf("failed to find epoch number from signature %s: %w", sig, err)
 		}
-		return &jsonrpc2.Error{
+		return nil, cid.Undef, &jsonrpc2.Error{
 			Code:    jsonrpc2.CodeInternalError,
 			Message: "Internal error",
 		}, fmt.Errorf("failed to get epoch for signature %s: %w", sig, err)
 	}
-	klog.V(4).Infof("Found signature %s in epoch %d in %s", sig, epochNumber, time.Since(startedEpochLookupAt))
+	reqLog.SetEpoch(epochNumber)
 
-	epochHandler, err := multi.GetEpoch(uint64(epochNumber))
+	epochHandler, release, err := multi.GetEpoch(uint64(epochNumber))
 	if err != nil {
-		return &jsonrpc2.Error{
-			Code:    CodeNotFound,
-			Message: fmt.Sprintf("Epoch %d is not available from this RPC", epochNumber),
-		}, fmt.Errorf("failed to get handler for epoch %d: %w", epochNumber, err)
+		// The epoch was unloaded after the signature lookup; for the client this is the same as not found.
+		return nil, cid.Undef, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInternalError,
+			Message: "Transaction not found",
+		}, fmt.Errorf("signature %s is in epoch %d, which is no longer served: %w: %w", sig, epochNumber, ErrNotFound, err)
 	}
+	defer release()
 
 	transactionNode, transactionCid, err := epochHandler.GetTransaction(WithSubrapghPrefetch(ctx, true), sig)
 	if err != nil {
 		if errors.Is(err, compactindexsized.ErrNotFound) {
 			// NOTE: solana just returns null here in case of transaction not found: {"jsonrpc":"2.0","result":null,"id":1}
-			return &jsonrpc2.Error{
-				Code:    CodeNotFound,
+			return nil, cid.Undef, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInternalError,
 				Message: "Transaction not found",
-			}, fmt.Errorf("transaction %s not found", sig)
+			}, fmt.Errorf("transaction %s not found: %w", sig, ErrNotFound)
 		}
-		return &jsonrpc2.Error{
+		return nil, cid.Undef, &jsonrpc2.Error{
 			Code:    jsonrpc2.CodeInternalError,
 			Message: "Internal error",
 		}, fmt.Errorf("failed to get Transaction: %w", err)
 	}
-	{
-		conn.ctx.Response.Header.Set("DAG-Root-CID", transactionCid.String())
+	reqLog.Step("GetTransaction")
+	if txEpoch := slottools.CalcEpochForSlot(uint64(transactionNode.Slot)); txEpoch != epochNumber {
+		// the transaction is known to the indexes of the epoch, but its slot is in an epoch that
+		// is not served under that number (e.g. a CAR configured as the wrong epoch).
+		return nil, cid.Undef, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInternalError,
+			Message: "Transaction not found",
+		}, fmt.Errorf("transaction %s is in slot %d of epoch %d, outside of the configured epoch %d: %w", sig, transactionNode.Slot, txEpoch, epochNumber, ErrNotFound)
 	}
 
-	var response GetTransactionResponse
+	response := &GetTransactionResponse{}
 
 	response.Slot = ptrToUint64(uint64(transactionNode.Slot))
 	{
@@ -178,11 +213,11 @@ func (multi *MultiEpoch) handleGetTransaction(ctx context.Context, conn *request
 		if blocktimeIndex != nil {
 			blocktime, err := blocktimeIndex.Get(uint64(transactionNode.Slot))
 			if err != nil {
-				return nil, status.Errorf(codes.Internal, "Failed to get block: %v", err)
+				return nil, cid.Undef, nil, status.Errorf(codes.Internal, "Failed to get block: %v", err)
 			}
 			response.Blocktime = &blocktime
 		} else {
-			return &jsonrpc2.Error{
+			return nil, cid.Undef, &jsonrpc2.Error{
 				Code:    jsonrpc2.CodeInternalError,
 				Message: "Internal error",
 			}, fmt.Errorf("failed to get blocktime: blocktime index is not available")
@@ -194,9 +229,9 @@ func (multi *MultiEpoch) handleGetTransaction(ctx context.Context, conn *request
 		if ok {
 			response.Position = uint64(pos)
 		}
-		tx, meta, err := parseTransactionAndMetaFromNode(transactionNode, epochHandler.GetDataFrameByCid)
+		tx, meta, err := parseTransactionAndMetaFromNode(ctx, transactionNode, epochHandler.GetDataFrameByCid)
 		if err != nil {
-			return &jsonrpc2.Error{
+			return nil, cid.Undef, &jsonrpc2.Error{
 				Code:    jsonrpc2.CodeInternalError,
 				Message: "Internal error",
 			}, fmt.Errorf("failed to decode transaction: %w", err)
@@ -208,9 +243,9 @@ func (multi *MultiEpoch) handleGetTransaction(ctx context.Context, conn *request
 			response.Version = "legacy"
 		}
 
-		encodedTx, encodedMeta, err := encodeTransactionResponseBasedOnWantedEncoding(*params.Options.Encoding, tx, meta)
+		encodedTx, encodedMeta, err := encodeTransactionResponseBasedOnWantedEncoding(ctx, *params.Options.Encoding, tx, meta, epochHandler.LookupTables())
 		if err != nil {
-			return &jsonrpc2.Error{
+			return nil, cid.Undef, &jsonrpc2.Error{
 				Code:    jsonrpc2.CodeInternalError,
 				Message: "Internal error",
 			}, fmt.Errorf("failed to encode transaction: %w", err)
@@ -218,18 +253,6 @@ func (multi *MultiEpoch) handleGetTransaction(ctx context.Context, conn *request
 		response.Transaction = encodedTx
 		response.Meta = encodedMeta
 	}
-
-	// reply with the data
-	err = conn.Reply(
-		ctx,
-		req.ID,
-		response,
-		func(m map[string]any) map[string]any {
-			return adaptTransactionMetaToExpectedOutput(m)
-		},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to reply: %w", err)
-	}
-	return nil, nil
+	reqLog.Step("encode transaction")
+	return response, transactionCid, nil, nil
 }
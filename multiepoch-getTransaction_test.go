@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestGetTransactionContext(t *testing.T) {
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+	paths, _, _, err := buildTestEpochIndexes(carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+	epoch := openTestEpoch(t, testEpochConfig(t, carPath, paths))
+	slots := fixture.Slots()
+	lastSlot := slots[len(slots)-1]
+	tx := fixture.Transactions[1]
+
+	call := func(t *testing.T, multi *MultiEpoch, params string) (json.RawMessage, json.RawMessage) {
+		var req fasthttp.Request
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetRequestURI("/")
+		req.SetBodyString(`{"jsonrpc":"2.0","id":1,"method":"getTransaction","params":` + params + `}`)
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		newMultiEpochHandler(multi, nil)(&reqCtx)
+		var resp struct {
+			Result json.RawMessage `json:"result"`
+			Error  json.RawMessage `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &resp), "%s", reqCtx.Response.Body())
+		return resp.Result, resp.Error
+	}
+	withOptions := func(sig solana.Signature, options string) string {
+		return `["` + sig.String() + `", ` + options + `]`
+	}
+
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+
+	// like solana, there's no context around the transaction.
+	result, respErr := call(t, multi, withOptions(tx.Signature, `{"commitment": "confirmed", "minContextSlot": 5}`))
+	require.Empty(t, respErr)
+	var got struct {
+		Slot    uint64          `json:"slot"`
+		Context json.RawMessage `json:"context"`
+	}
+	require.NoError(t, json.Unmarshal(result, &got))
+	require.Equal(t, tx.Slot, got.Slot)
+	require.Empty(t, got.Context)
+
+	result, respErr = call(t, multi, withOptions(solana.Signature{1}, `{}`))
+	require.Empty(t, respErr)
+	require.Equal(t, "null", string(result))
+
+	_, respErr = call(t, multi, withOptions(tx.Signature, `{"minContextSlot": 6}`))
+	require.JSONEq(t, `{"code":-32016,"message":"Minimum context slot has not been reached","data":{"contextSlot":5}}`, string(respErr))
+	require.Equal(t, uint64(5), lastSlot)
+
+	_, respErr = call(t, multi, withOptions(tx.Signature, `{"minContextSlot": -1}`))
+	require.Contains(t, string(respErr), "Invalid params")
+	_, respErr = call(t, multi, withOptions(tx.Signature, `{"commitment": "processed"}`))
+	require.Contains(t, string(respErr), "Method does not support commitment below `confirmed`")
+	_, respErr = call(t, multi, withOptions(tx.Signature, `{"commitment": "max"}`))
+	require.Contains(t, string(respErr), "unsupported commitment")
+
+	t.Run("outside of the epoch", func(t *testing.T) {
+		// the CAR of epoch 0 served as epoch 1: its transactions are not in epoch 1.
+		multi := NewMultiEpoch(&Options{})
+		require.NoError(t, multi.AddEpoch(1, epoch))
+		result, respErr := call(t, multi, withOptions(tx.Signature, `{}`))
+		require.Empty(t, respErr)
+		require.Equal(t, "null", string(result))
+	})
+}
+
+func TestErrorKind(t *testing.T) {
+	notFound := fmt.Errorf("transaction %s not found: %w", solana.Signature{1}, ErrNotFound)
+	require.Equal(t, rpcerrors.KindTransactionNotFound, errorKind(notFound))
+	require.True(t, errorKind(notFound).IsNullResult())
+	require.Equal(t, rpcerrors.KindInternal, errorKind(fmt.Errorf("failed to get Transaction: %w", os.ErrClosed)))
+	require.False(t, errorKind(nil).IsNullResult())
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"runtime/debug"
 	"sort"
@@ -13,44 +14,180 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/goware/urlx"
-	"github.com/libp2p/go-reuseport"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
 	"github.com/rpcpool/yellowstone-faithful/metrics"
 	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/rpcpool/yellowstone-faithful/tracing"
 	"github.com/sourcegraph/jsonrpc2"
 	"github.com/valyala/fasthttp"
 	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
 	"k8s.io/klog/v2"
 )
 
 type Options struct {
 	GsfaOnlySignatures     bool
 	EpochSearchConcurrency int
+	// SlowQueryThreshold enables the slow-query log: requests that take longer than this are logged
+	// with their timing breakdown. Zero disables it.
+	SlowQueryThreshold time.Duration
+	// CompressionMinSize is the minimum size of the responses that are compressed.
+	CompressionMinSize int
+	// DisableCompression disables the compression of the responses.
+	DisableCompression bool
+	// BlockMemoryBudget is the memory (in bytes) that the blocks being assembled concurrently can use;
+	// zero means no limit.
+	BlockMemoryBudget int64
+	// BlockMemoryWait is how long a block request waits for room in the block memory budget
+	// before it's rejected; zero means until the request is canceled.
+	BlockMemoryWait time.Duration
+	// HedgeDelay is how long the query of a remote epoch, in a request that spans several epochs,
+	// runs before a second attempt is started; zero disables the hedged attempts.
+	HedgeDelay time.Duration
+	// SigNegativeCacheTTL is how long the signatures that weren't found in any epoch are remembered
+	// (until the set of epochs changes); zero disables the negative cache.
+	SigNegativeCacheTTL time.Duration
+	// SigSummaryBitsPerKey is the size (in bits per signature) of the in-memory summaries of the
+	// sig-exists indexes, which let the signature lookups skip the epochs that don't have the
+	// signature; zero disables the summaries.
+	SigSummaryBitsPerKey uint
+	// ShutdownDelay is how long the server keeps accepting connections once the shutdown started
+	// (while /health fails), so that the load balancers stop sending requests first.
+	ShutdownDelay time.Duration
+	// ShutdownTimeout is how long the shutdown waits for the requests and the streams in flight to
+	// finish, before they're canceled.
+	ShutdownTimeout time.Duration
+	// MaxOpenEpochs is how many of the epochs added with AddLazyEpoch can be open at once; the least
+	// recently used ones are unloaded past it. Zero means no limit.
+	MaxOpenEpochs int
+	// EpochIdleTimeout is how long an epoch added with AddLazyEpoch stays open while unused; zero
+	// means forever.
+	EpochIdleTimeout time.Duration
+	// GRPCMaxStreamsPerClient is how many gRPC streams a client (IP address, or token behind one
+	// of the GRPCTrustedProxies) can have open at once; zero means no limit.
+	GRPCMaxStreamsPerClient int
+	// GRPCTrustedProxies are the proxies that authenticate the gRPC clients: the token (or the
+	// forwarded address) of their streams identifies the client.
+	GRPCTrustedProxies []*net.IPNet
+	// GRPCStreamBytesPerSecond is the maximum rate at which each gRPC stream sends; zero means no limit.
+	GRPCStreamBytesPerSecond int64
+	// GRPCStreamsBytesPerSecond is the maximum rate at which all the gRPC streams send together,
+	// split evenly between the clients; zero means no limit.
+	GRPCStreamsBytesPerSecond int64
+	// GRPCStreamMemory is the block memory (in bytes) that the gRPC streams can use together,
+	// out of the BlockMemoryBudget; zero means no limit apart from the budget.
+	GRPCStreamMemory int64
+}
+
+// shutdownDelays returns the ShutdownDelay and the ShutdownTimeout of the options (zero if nil).
+func (o *Options) shutdownDelays() (delay time.Duration, timeout time.Duration) {
+	if o == nil {
+		return 0, 0
+	}
+	return o.ShutdownDelay, o.ShutdownTimeout
 }
 
 type MultiEpoch struct {
 	mu      sync.RWMutex
 	options *Options
-	epochs  map[uint64]*Epoch
+	// network is the namespace of the epochs (see Namespaces); empty for the default one.
+	network string
+	epochs  map[uint64]*epochHandle
+	// epochsGeneration changes with the set of epochs.
+	epochsGeneration uint64
+	warmups          singleflight.Group
+	// blockMemory is nil if the memory of the blocks is not limited.
+	blockMemory *blockMemoryBudget
+	// drain is nil if the epochs are not served by Namespaces.
+	drain *serverDrain
+	// handles is nil if the epochs are not opened on first use.
+	handles *epochHandles
+	// closed is set by Close; the epochs added later are rejected.
+	closed bool
+	// sigResolver is created by the first signature lookup (see getSigEpochResolver).
+	sigResolver     *sigEpochResolver
+	sigResolverOnce sync.Once
 	old_faithful_grpc.UnimplementedOldFaithfulServer
 }
 
 func NewMultiEpoch(options *Options) *MultiEpoch {
-	return &MultiEpoch{
+	multi := &MultiEpoch{
 		options: options,
-		epochs:  make(map[uint64]*Epoch),
+		epochs:  make(map[uint64]*epochHandle),
+	}
+	if options != nil {
+		multi.blockMemory = newBlockMemoryBudget(options.BlockMemoryBudget, options.BlockMemoryWait)
+		multi.handles = newEpochHandles(options.MaxOpenEpochs, options.EpochIdleTimeout)
 	}
+	return multi
 }
 
-func (m *MultiEpoch) GetEpoch(epoch uint64) (*Epoch, error) {
+// GetEpoch returns the epoch, which is opened if it was added with AddLazyEpoch and isn't open,
+// and the function to call once done with it: until then, the epoch is neither unloaded nor closed.
+func (m *MultiEpoch) GetEpoch(epoch uint64) (*Epoch, func(), error) {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-	ep, ok := m.epochs[epoch]
+	h, ok := m.epochs[epoch]
+	m.mu.RUnlock()
 	if !ok {
-		return nil, fmt.Errorf("epoch %d not found", epoch)
+		return nil, nil, fmt.Errorf("epoch %d not found", epoch)
+	}
+	ep, err := h.get()
+	if err != nil {
+		return nil, nil, err
+	}
+	return ep, h.release, nil
+}
+
+// OpensEpochsLazily returns true if the epochs are meant to be added with AddLazyEpoch, i.e. if
+// their number or their idle time is limited by the options.
+func (m *MultiEpoch) OpensEpochsLazily() bool {
+	return m.handles.isLazy()
+}
+
+// handlesInEpochDescendingOrder returns the handles of the epochs that match the filter (all if nil),
+// from the most recent to the oldest.
+func (m *MultiEpoch) handlesInEpochDescendingOrder(filter func(*epochHandle) bool) []*epochHandle {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	handles := make([]*epochHandle, 0, len(m.epochs))
+	for _, h := range m.epochs {
+		if filter == nil || filter(h) {
+			handles = append(handles, h)
+		}
+	}
+	sort.Slice(handles, func(i, j int) bool {
+		return handles[i].number > handles[j].number
+	})
+	return handles
+}
+
+// openEpochsInEpochDescendingOrder returns the epochs that match the filter (all if nil), from the
+// most recent to the oldest, opening them if needed; the ones that fail to open are skipped. The
+// returned function releases them (see GetEpoch).
+func (m *MultiEpoch) openEpochsInEpochDescendingOrder(filter func(*epochHandle) bool) ([]*Epoch, func()) {
+	handles := m.handlesInEpochDescendingOrder(filter)
+	epochs := make([]*Epoch, 0, len(handles))
+	got := make([]*epochHandle, 0, len(handles))
+	for _, h := range handles {
+		ep, err := h.get()
+		if err != nil {
+			klog.Errorf("failed to get epoch %d: %s", h.number, err.Error())
+			continue
+		}
+		got = append(got, h)
+		if ep != nil {
+			epochs = append(epochs, ep)
+		}
+	}
+	return epochs, func() {
+		for _, h := range got {
+			h.release()
+		}
 	}
-	return ep, nil
 }
 
 func (m *MultiEpoch) HasEpoch(epoch uint64) bool {
@@ -60,19 +197,79 @@ func (m *MultiEpoch) HasEpoch(epoch uint64) bool {
 	return ok
 }
 
+// ErrMultiEpochClosed is returned when an epoch is added after the epochs were closed (e.g. an epoch
+// that finished loading during the shutdown); the caller must close it.
+var ErrMultiEpochClosed = errors.New("the epochs are closed")
+
 func (m *MultiEpoch) AddEpoch(epoch uint64, ep *Epoch) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if m.closed {
+		return ErrMultiEpochClosed
+	}
+	m.epochsGeneration++
 	if _, ok := m.epochs[epoch]; ok {
 		return fmt.Errorf("epoch %d already exists", epoch)
 	}
-	m.epochs[epoch] = ep
+	m.epochs[epoch] = newOpenEpochHandle(epoch, ep)
 	return nil
 }
 
+// AddLazyEpoch adds the epoch of the config, which is opened (with open) on first use, and can be
+// unloaded when unused (see Options.MaxOpenEpochs and Options.EpochIdleTimeout).
+func (m *MultiEpoch) AddLazyEpoch(config *Config, open func() (*Epoch, error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrMultiEpochClosed
+	}
+	epoch := *config.Epoch
+	m.epochsGeneration++
+	if _, ok := m.epochs[epoch]; ok {
+		return fmt.Errorf("epoch %d already exists", epoch)
+	}
+	m.addLazyHandle(newLazyEpochHandle(epoch, m.network, config, open))
+	return nil
+}
+
+// ReplaceOrAddLazyEpoch is AddLazyEpoch, but it replaces (and closes) the epoch if it already exists.
+func (m *MultiEpoch) ReplaceOrAddLazyEpoch(config *Config, open func() (*Epoch, error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrMultiEpochClosed
+	}
+	epoch := *config.Epoch
+	m.epochsGeneration++
+	if old, ok := m.epochs[epoch]; ok {
+		m.closeHandle(old)
+	}
+	m.addLazyHandle(newLazyEpochHandle(epoch, m.network, config, open))
+	return nil
+}
+
+func (m *MultiEpoch) addLazyHandle(h *epochHandle) {
+	m.epochs[h.number] = h
+	// without limits, the epochs are never unloaded.
+	if m.handles != nil {
+		m.handles.add(h)
+	}
+}
+
+// closeHandle closes the epoch of the handle, which is removed from the lazy handles.
+func (m *MultiEpoch) closeHandle(h *epochHandle) {
+	if h.isLazy() && m.handles != nil {
+		m.handles.remove(h)
+	}
+	if err := h.close(); err != nil {
+		klog.Errorf("error closing epoch %d: %s", h.number, err.Error())
+	}
+}
+
 func (m *MultiEpoch) RemoveEpoch(epoch uint64) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.epochsGeneration++
 	if _, ok := m.epochs[epoch]; !ok {
 		return fmt.Errorf("epoch %d not found", epoch)
 	}
@@ -83,9 +280,10 @@ func (m *MultiEpoch) RemoveEpoch(epoch uint64) error {
 func (m *MultiEpoch) RemoveEpochByConfigFilepath(configFilepath string) (uint64, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	for epoch, ep := range m.epochs {
-		if ep.config.ConfigFilepath() == configFilepath {
-			ep.Close()
+	m.epochsGeneration++
+	for epoch, h := range m.epochs {
+		if h.config != nil && h.config.ConfigFilepath() == configFilepath {
+			m.closeHandle(h)
 			delete(m.epochs, epoch)
 			return epoch, nil
 		}
@@ -96,29 +294,34 @@ func (m *MultiEpoch) RemoveEpochByConfigFilepath(configFilepath string) (uint64,
 func (m *MultiEpoch) ReplaceEpoch(epoch uint64, ep *Epoch) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.epochsGeneration++
 	if _, ok := m.epochs[epoch]; !ok {
 		return fmt.Errorf("epoch %d not found", epoch)
 	}
-	m.epochs[epoch] = ep
+	m.epochs[epoch] = newOpenEpochHandle(epoch, ep)
 	return nil
 }
 
 func (m *MultiEpoch) ReplaceOrAddEpoch(epoch uint64, ep *Epoch) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if m.closed {
+		return ErrMultiEpochClosed
+	}
+	m.epochsGeneration++
 	// if the epoch already exists, close it
-	if oldEp, ok := m.epochs[epoch]; ok {
-		oldEp.Close()
+	if old, ok := m.epochs[epoch]; ok {
+		m.closeHandle(old)
 	}
-	m.epochs[epoch] = ep
+	m.epochs[epoch] = newOpenEpochHandle(epoch, ep)
 	return nil
 }
 
 func (m *MultiEpoch) HasEpochWithSameHashAsFile(filepath string) bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	for _, ep := range m.epochs {
-		if ep.config.IsSameHashAsFile(filepath) {
+	for _, h := range m.epochs {
+		if h.config != nil && h.config.IsSameHashAsFile(filepath) {
 			return true
 		}
 	}
@@ -145,39 +348,65 @@ func (m *MultiEpoch) GetEpochNumbers() []uint64 {
 	return epochNumbers
 }
 
-func (m *MultiEpoch) GetMostRecentAvailableEpoch() (*Epoch, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// GetMostRecentAvailableEpoch is GetEpoch for the most recent epoch.
+func (m *MultiEpoch) GetMostRecentAvailableEpoch() (*Epoch, func(), error) {
 	numbers := m.GetEpochNumbers()
 	if len(numbers) > 0 {
-		return m.epochs[numbers[0]], nil
+		return m.GetEpoch(numbers[0])
 	}
-	return nil, fmt.Errorf("no epochs available")
+	return nil, nil, fmt.Errorf("no epochs available")
 }
 
-func (m *MultiEpoch) GetOldestAvailableEpoch() (*Epoch, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// GetOldestAvailableEpoch is GetEpoch for the oldest epoch.
+func (m *MultiEpoch) GetOldestAvailableEpoch() (*Epoch, func(), error) {
 	numbers := m.GetEpochNumbers()
 	if len(numbers) > 0 {
-		return m.epochs[numbers[len(numbers)-1]], nil
+		return m.GetEpoch(numbers[len(numbers)-1])
 	}
-	return nil, fmt.Errorf("no epochs available")
+	return nil, nil, fmt.Errorf("no epochs available")
 }
 
 func (m *MultiEpoch) GetFirstAvailableBlock(ctx context.Context) (*ipldbindcode.Block, error) {
-	oldestEpoch, err := m.GetOldestAvailableEpoch()
+	oldestEpoch, release, err := m.GetOldestAvailableEpoch()
 	if err != nil {
 		return nil, err
 	}
+	defer release()
 	return oldestEpoch.GetFirstAvailableBlock(ctx)
 }
 
+// errorForMissingSlot returns the Solana error for a slot whose block cannot be served:
+// skipped (-32007) if the epoch is loaded, cleaned up (-32001) if the slot is before
+// the first available block, or not available (-32004) otherwise.
+func (m *MultiEpoch) errorForMissingSlot(ctx context.Context, slot uint64) *jsonrpc2.Error {
+	epochLoaded := m.HasEpoch(slottools.CalcEpochForSlot(slot))
+	var firstAvailable uint64
+	var hasFirstAvailable bool
+	if !epochLoaded {
+		firstBlock, err := m.GetFirstAvailableBlock(ctx)
+		if err == nil {
+			firstAvailable = uint64(firstBlock.Slot)
+			hasFirstAvailable = true
+		}
+	}
+	kind := rpcerrors.ClassifyMissingSlot(slot, epochLoaded, firstAvailable, hasFirstAvailable)
+	return rpcerrors.ForMissingSlot(kind, slot, firstAvailable)
+}
+
+// errorKind classifies the error returned by a request handler.
+func errorKind(err error) rpcerrors.Kind {
+	if errors.Is(err, ErrNotFound) {
+		return rpcerrors.KindTransactionNotFound
+	}
+	return rpcerrors.KindInternal
+}
+
 func (m *MultiEpoch) GetMostRecentAvailableBlock(ctx context.Context) (*ipldbindcode.Block, error) {
-	mostRecentEpoch, err := m.GetMostRecentAvailableEpoch()
+	mostRecentEpoch, release, err := m.GetMostRecentAvailableEpoch()
 	if err != nil {
 		return nil, err
 	}
+	defer release()
 	return mostRecentEpoch.GetMostRecentAvailableBlock(ctx)
 }
 
@@ -192,15 +421,24 @@ func (m *MultiEpoch) GetMostRecentAvailableEpochNumber() (uint64, error) {
 func (m *MultiEpoch) Close() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
 	klog.Info("Closing all epochs...")
-	for _, ep := range m.epochs {
-		ep.Close()
+	for _, h := range m.epochs {
+		m.closeHandle(h)
 	}
+	m.epochs = make(map[uint64]*epochHandle)
+	m.epochsGeneration++
+	m.getSigEpochResolver().close()
 	return nil
 }
 
 type ListenerConfig struct {
 	ProxyConfig *ProxyConfig
+	CORS        *CORSConfig
+	Shadow      *ShadowConfig
 }
 
 type ProxyConfig struct {
@@ -226,34 +464,6 @@ func LoadProxyConfig(configFilepath string) (*ProxyConfig, error) {
 	return &proxyConfig, nil
 }
 
-// ListeAndServe starts listening on the configured address and serves the RPC API.
-func (m *MultiEpoch) ListenAndServe(ctx context.Context, listenOn string, lsConf *ListenerConfig) error {
-	handler := newMultiEpochHandler(m, lsConf)
-	handler = fasthttp.CompressHandler(handler)
-
-	klog.Infof("RPC server listening on %s", listenOn)
-
-	s := &fasthttp.Server{
-		Handler:            handler,
-		MaxRequestBodySize: 1024 * 1024,
-	}
-	go func() {
-		// listen for context cancellation
-		<-ctx.Done()
-		klog.Info("RPC server shutting down...")
-		defer klog.Info("RPC server shut down")
-		if err := s.ShutdownWithContext(ctx); err != nil {
-			klog.Errorf("Error while shutting down RPC server: %s", err)
-		}
-	}()
-	ln, err := reuseport.Listen("tcp4", listenOn)
-	if err != nil {
-		klog.Fatalf("error in reuseport listener: %v", err)
-		return err
-	}
-	return s.Serve(ln)
-}
-
 func randomRequestID() string {
 	id := uuid.New().String()
 	return id
@@ -278,19 +488,36 @@ func newMultiEpochHandler(handler *MultiEpoch, lsConf *ListenerConfig) func(ctx
 		}
 		klog.Infof("Will proxy unhandled RPC methods to %q", addr)
 	}
+	var shadow *shadower
+	if lsConf != nil && lsConf.Shadow != nil {
+		shadow = newShadower(lsConf.Shadow)
+		klog.Infof("Will shadow %v%% of the JSON RPC requests to %q", lsConf.Shadow.Percent, lsConf.Shadow.Target)
+	}
 	metricsHandler := fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())
 	return func(reqCtx *fasthttp.RequestCtx) {
-		startedAt := time.Now()
 		reqID := randomRequestID()
+		reqLog := newRPCRequestLog(reqID)
 		var method string = "<unknown>"
 		defer func() {
 			if method == "/metrics" || method == "/health" {
 				return
 			}
-			took := time.Since(startedAt)
-			klog.V(2).Infof("[%s] request %q took %s", reqID, sanitizeMethod(method), took)
-			metrics.StatusCode.WithLabelValues(fmt.Sprint(reqCtx.Response.StatusCode())).Inc()
-			metrics.RpcResponseLatencyHistogram.WithLabelValues(sanitizeMethod(method)).Observe(took.Seconds())
+			took := reqLog.Finish(handler.options.SlowQueryThreshold)
+			metrics.StatusCode.WithLabelValues(fmt.Sprint(reqCtx.Response.StatusCode()), handler.network).Inc()
+			metrics.RpcResponseLatencyHistogram.WithLabelValues(sanitizeMethod(method), handler.network).Observe(took.Seconds())
+		}()
+		defer func() {
+			// JSON-RPC methods recover in handleRequest; this catches the rest (REST API, websocket handshake, etc.).
+			if r := recover(); r != nil {
+				recoveredPanic("http", method, r)
+				reqCtx.Response.ResetBody()
+				replyJSON(reqCtx, http.StatusInternalServerError, jsonrpc2.Response{
+					Error: &jsonrpc2.Error{
+						Code:    jsonrpc2.CodeInternalError,
+						Message: errInternal,
+					},
+				})
+			}
 		}()
 		{
 			// handle the /metrics endpoint
@@ -303,10 +530,24 @@ func newMultiEpochHandler(handler *MultiEpoch, lsConf *ListenerConfig) func(ctx
 				// Handle the /health endpoint
 				if string(reqCtx.Path()) == "/health" && reqCtx.IsGet() {
 					method = "/health"
+					if handler.drain.isDraining() {
+						// tell the load balancers to stop sending requests.
+						reqCtx.SetStatusCode(http.StatusServiceUnavailable)
+						return
+					}
 					reqCtx.SetStatusCode(http.StatusOK)
 					return
 				}
 			}
+			if isWebsocketUpgrade(reqCtx) {
+				method = "websocket"
+				var corsConf *CORSConfig
+				if lsConf != nil {
+					corsConf = lsConf.CORS
+				}
+				handler.websocketHandler(reqCtx, corsConf)
+				return
+			}
 			{
 				// handle the /api/v1/* endpoint
 				if strings.HasPrefix(string(reqCtx.Path()), "/api/v1/") {
@@ -346,7 +587,7 @@ func newMultiEpochHandler(handler *MultiEpoch, lsConf *ListenerConfig) func(ctx
 		// parse request
 		var rpcRequest jsonrpc2.Request
 		if err := fasterJson.Unmarshal(body, &rpcRequest); err != nil {
-			klog.Errorf("[%s] failed to parse request body: %v", err)
+			reqLog.Logger().Error("failed to parse request body", "error", err)
 			replyJSON(reqCtx, http.StatusBadRequest, jsonrpc2.Response{
 				Error: &jsonrpc2.Error{
 					Code:    jsonrpc2.CodeParseError,
@@ -356,16 +597,25 @@ func newMultiEpochHandler(handler *MultiEpoch, lsConf *ListenerConfig) func(ctx
 			return
 		}
 		method = rpcRequest.Method
-		metrics.RpcRequestByMethod.WithLabelValues(sanitizeMethod(method)).Inc()
+		reqLog.SetMethod(method)
+		metrics.RpcRequestByMethod.WithLabelValues(sanitizeMethod(method), handler.network).Inc()
 		defer func() {
-			metrics.MethodToCode.WithLabelValues(sanitizeMethod(method), fmt.Sprint(reqCtx.Response.StatusCode())).Inc()
+			metrics.MethodToCode.WithLabelValues(sanitizeMethod(method), fmt.Sprint(reqCtx.Response.StatusCode()), handler.network).Inc()
 		}()
 
-		klog.V(2).Infof("[%s] method=%q", reqID, sanitizeMethod(method))
-		klog.V(3).Infof("[%s] received request with body: %q", reqID, strings.TrimSpace(string(body)))
+		reqLog.Logger().Info("received request")
+		reqLog.Logger().Debug("request body", "body", strings.TrimSpace(string(body)))
+
+		// getVersion differs by design (it's the version of the server).
+		if shadow != nil && isValidLocalMethod(method) && method != "getVersion" {
+			startedAt := time.Now()
+			defer func() {
+				shadow.maybeShadow(method, reqID, body, reqCtx.Response.StatusCode(), reqCtx.Response.Body(), time.Since(startedAt))
+			}()
+		}
 
 		if proxy != nil && !isValidLocalMethod(rpcRequest.Method) {
-			klog.V(2).Infof("[%s] Unhandled method %q, proxying to %q", reqID, rpcRequest.Method, proxy.Addr)
+			reqLog.Logger().Info("unhandled method, proxying", "target", proxy.Addr)
 			// proxy the request to the target
 			proxyToAlternativeRPCServer(
 				handler,
@@ -376,7 +626,7 @@ func newMultiEpochHandler(handler *MultiEpoch, lsConf *ListenerConfig) func(ctx
 				body,
 				reqID,
 			)
-			metrics.MethodToNumProxied.WithLabelValues(sanitizeMethod(method)).Inc()
+			metrics.MethodToNumProxied.WithLabelValues(sanitizeMethod(method), handler.network).Inc()
 			return
 		}
 
@@ -398,20 +648,31 @@ func newMultiEpochHandler(handler *MultiEpoch, lsConf *ListenerConfig) func(ctx
 				versionInfo,
 			)
 			if err != nil {
-				klog.Errorf("[%s] failed to reply to getVersion: %v", reqID, err)
+				reqLog.Logger().Error("failed to reply to getVersion", "error", err)
 			}
 			return
 		}
 
 		// errorResp is the error response to be sent to the client.
-		errorResp, err := handler.handleRequest(setRequestIDToContext(reqCtx, reqID), rqCtx, &rpcRequest)
+		spanCtx, span := tracing.Start(
+			tracing.Extract(withRPCRequestLog(reqCtx, reqLog), fasthttpHeaderCarrier{&reqCtx.Request.Header}),
+			"jsonrpc."+sanitizeMethod(method),
+			attribute.String("rpc.system", "jsonrpc"),
+			attribute.String("rpc.method", sanitizeMethod(method)),
+			attribute.String("request_id", reqID),
+		)
+		errorResp, err := handler.handleRequest(spanCtx, rqCtx, &rpcRequest)
 		if err != nil {
-			klog.Errorf("[%s] failed to handle %q: %v", reqID, sanitizeMethod(method), err)
+			reqLog.Logger().Error("failed to handle request", "error", err)
+		}
+		if errorResp != nil {
+			span.SetAttributes(attribute.Int64("rpc.jsonrpc.error_code", errorResp.Code))
 		}
+		tracing.End(span, err)
 		if errorResp != nil {
-			metrics.MethodToSuccessOrFailure.WithLabelValues(sanitizeMethod(method), "failure").Inc()
+			metrics.MethodToSuccessOrFailure.WithLabelValues(sanitizeMethod(method), "failure", handler.network).Inc()
 			if proxy != nil && lsConf.ProxyConfig.ProxyFailedRequests {
-				klog.Warningf("[%s] Failed local method %q, proxying to %q", reqID, rpcRequest.Method, proxy.Addr)
+				reqLog.Logger().Warn("failed local method, proxying", "target", proxy.Addr)
 				// proxy the request to the target
 				proxyToAlternativeRPCServer(
 					handler,
@@ -422,11 +683,10 @@ func newMultiEpochHandler(handler *MultiEpoch, lsConf *ListenerConfig) func(ctx
 					body,
 					reqID,
 				)
-				metrics.MethodToNumProxied.WithLabelValues(sanitizeMethod(method)).Inc()
+				metrics.MethodToNumProxied.WithLabelValues(sanitizeMethod(method), handler.network).Inc()
 				return
 			} else {
-				if errors.Is(err, ErrNotFound) {
-					// reply with null result
+				if errorKind(err).IsNullResult() {
 					rqCtx.ReplyRaw(
 						reqCtx,
 						rpcRequest.ID,
@@ -442,10 +702,31 @@ func newMultiEpochHandler(handler *MultiEpoch, lsConf *ListenerConfig) func(ctx
 			}
 			return
 		}
-		metrics.MethodToSuccessOrFailure.WithLabelValues(sanitizeMethod(method), "success").Inc()
+		metrics.MethodToSuccessOrFailure.WithLabelValues(sanitizeMethod(method), "success", handler.network).Inc()
 	}
 }
 
+// fasthttpHeaderCarrier adapts the fasthttp request headers to the OTEL propagation.TextMapCarrier interface.
+type fasthttpHeaderCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (c fasthttpHeaderCarrier) Get(key string) string {
+	return string(c.header.Peek(key))
+}
+
+func (c fasthttpHeaderCarrier) Set(key string, value string) {
+	c.header.Set(key, value)
+}
+
+func (c fasthttpHeaderCarrier) Keys() []string {
+	keys := make([]string, 0)
+	c.header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
 func proxyToAlternativeRPCServer(
 	handler *MultiEpoch,
 	lsConf *ListenerConfig,
@@ -470,7 +751,7 @@ func proxyToAlternativeRPCServer(
 	proxyResp := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseResponse(proxyResp)
 	if err := proxy.Do(proxyReq, proxyResp); err != nil {
-		klog.Errorf("[%s] failed to proxy request: %v", reqID, err)
+		rpcLogger.Error("failed to proxy request", "request_id", reqID, "error", err)
 		replyJSON(reqCtx, http.StatusInternalServerError, jsonrpc2.Response{
 			Error: &jsonrpc2.Error{
 				Code:    jsonrpc2.CodeInternalError,
@@ -484,7 +765,7 @@ func proxyToAlternativeRPCServer(
 	if rpcRequest.Method == "getVersion" {
 		enriched, err := handler.tryEnrichGetVersion(proxyResp.Body())
 		if err != nil {
-			klog.Errorf("[%s] failed to enrich getVersion response: %v", reqID, err)
+			rpcLogger.Error("failed to enrich getVersion response", "request_id", reqID, "error", err)
 			reqCtx.Response.SetBody(proxyResp.Body())
 		} else {
 			reqCtx.Response.SetBody(enriched)
@@ -492,7 +773,8 @@ func proxyToAlternativeRPCServer(
 	} else {
 		reqCtx.Response.SetBody(proxyResp.Body())
 	}
-	// TODO: handle compression.
+	// The upstream response is not compressed (Accept-Encoding is not forwarded);
+	// it's compressed by the compressionHandler like the local responses.
 }
 
 func sanitizeMethod(method string) string {
@@ -514,7 +796,7 @@ func allowOnlyAsciiPrintable(s string) string {
 
 func isValidLocalMethod(method string) bool {
 	switch method {
-	case "getBlock", "getTransaction", "getSignaturesForAddress", "getBlockTime", "getGenesisHash", "getFirstAvailableBlock", "getSlot":
+	case "getBlock", "getBlocks", "getBlocksWithLimit", "getTransaction", "getSignaturesForAddress", "getBlockTime", "getGenesisHash", "getFirstAvailableBlock", "getSlot", "getInflationReward", "faithful_getSignaturesForProgram", "faithful_getSignaturesForTokenOwner", "faithful_getSkippedSlots", "faithful_getSignatureStatuses":
 		return true
 	default:
 		return false
@@ -522,10 +804,27 @@ func isValidLocalMethod(method string) bool {
 }
 
 // jsonrpc2.RequestHandler interface
-func (ser *MultiEpoch) handleRequest(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (*jsonrpc2.Error, error) {
+//
+// A panic of the method handler is returned as an internal error.
+func (ser *MultiEpoch) handleRequest(ctx context.Context, conn *requestContext, req *jsonrpc2.Request) (errorResp *jsonrpc2.Error, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoveredPanic("jsonrpc", req.Method, r)
+			errorResp = &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInternalError,
+				Message: errInternal,
+			}
+			// Drop whatever the handler wrote before panicking.
+			conn.ctx.Response.ResetBody()
+		}
+	}()
 	switch req.Method {
 	case "getBlock":
 		return ser.handleGetBlock(ctx, conn, req)
+	case "getBlocks":
+		return ser.handleGetBlocks(ctx, conn, req)
+	case "getBlocksWithLimit":
+		return ser.handleGetBlocksWithLimit(ctx, conn, req)
 	case "getTransaction":
 		return ser.handleGetTransaction(ctx, conn, req)
 	case "getSignaturesForAddress":
@@ -538,6 +837,16 @@ func (ser *MultiEpoch) handleRequest(ctx context.Context, conn *requestContext,
 		return ser.handleGetFirstAvailableBlock(ctx, conn, req)
 	case "getSlot":
 		return ser.handleGetSlot(ctx, conn, req)
+	case "getInflationReward":
+		return ser.handleGetInflationReward(ctx, conn, req)
+	case "faithful_getSignaturesForProgram":
+		return ser.handleGetSignaturesForProgram(ctx, conn, req)
+	case "faithful_getSignaturesForTokenOwner":
+		return ser.handleGetSignaturesForTokenOwner(ctx, conn, req)
+	case "faithful_getSkippedSlots":
+		return ser.handleGetSkippedSlots(ctx, conn, req)
+	case "faithful_getSignatureStatuses":
+		return ser.handleGetSignatureStatuses(ctx, conn, req)
 	default:
 		return &jsonrpc2.Error{
 			Code:    jsonrpc2.CodeMethodNotFound,
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-reuseport"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// NetworkHeader selects the network of a request whose path has no network prefix
+// (for gRPC, it's the metadata key, in lowercase).
+const NetworkHeader = "X-Faithful-Network"
+
+// reservedNetworkNames are the first path segments of the endpoints, which can't be networks.
+var reservedNetworkNames = []string{"api", "debug", "health", "metrics"}
+
+var networkNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func validateNetworkName(name string) error {
+	if !networkNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid network name %q: must be lowercase letters, digits, '-' and '_'", name)
+	}
+	if slices.Contains(reservedNetworkNames, name) {
+		return fmt.Errorf("network name %q is reserved", name)
+	}
+	return nil
+}
+
+// Namespaces serves several archives (e.g. mainnet and testnet) from one server: the epochs of
+// each network are in their own MultiEpoch, and each request is routed to one of them by the
+// first segment of its path (/<network>/...), else by the NetworkHeader, else to the default network.
+type Namespaces struct {
+	mu             sync.RWMutex
+	options        *Options
+	defaultNetwork string
+	byNetwork      map[string]*MultiEpoch
+	// blockMemory is shared by all the networks.
+	blockMemory *blockMemoryBudget
+	// drain coordinates the graceful shutdown of the listeners.
+	drain *serverDrain
+	// handles limits the open epochs of all the networks; nil if they're not limited.
+	handles *epochHandles
+}
+
+func NewNamespaces(options *Options, defaultNetwork string) *Namespaces {
+	n := &Namespaces{
+		options:        options,
+		defaultNetwork: defaultNetwork,
+		byNetwork:      make(map[string]*MultiEpoch),
+		drain:          newServerDrain(),
+	}
+	if options != nil {
+		n.blockMemory = newBlockMemoryBudget(options.BlockMemoryBudget, options.BlockMemoryWait)
+		n.handles = newEpochHandles(options.MaxOpenEpochs, options.EpochIdleTimeout)
+	}
+	n.GetOrCreate(defaultNetwork)
+	return n
+}
+
+// Get returns the epochs of the network, or nil if there's no such network.
+func (n *Namespaces) Get(network string) *MultiEpoch {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.byNetwork[network]
+}
+
+// GetOrCreate returns the epochs of the network, which are created (empty) if needed.
+func (n *Namespaces) GetOrCreate(network string) *MultiEpoch {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if multi, ok := n.byNetwork[network]; ok {
+		return multi
+	}
+	multi := NewMultiEpoch(n.options)
+	multi.network = network
+	multi.blockMemory = n.blockMemory
+	multi.drain = n.drain
+	multi.handles = n.handles
+	n.byNetwork[network] = multi
+	return multi
+}
+
+// Default returns the epochs of the default network.
+func (n *Namespaces) Default() *MultiEpoch {
+	return n.Get(n.defaultNetwork)
+}
+
+// Networks returns the names of the networks, sorted.
+func (n *Namespaces) Networks() []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	networks := make([]string, 0, len(n.byNetwork))
+	for network := range n.byNetwork {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+	return networks
+}
+
+func (n *Namespaces) all() []*MultiEpoch {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	all := make([]*MultiEpoch, 0, len(n.byNetwork))
+	for _, multi := range n.byNetwork {
+		all = append(all, multi)
+	}
+	return all
+}
+
+func (n *Namespaces) HasEpochWithSameHashAsFile(filepath string) bool {
+	for _, multi := range n.all() {
+		if multi.HasEpochWithSameHashAsFile(filepath) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveEpochByConfigFilepath removes the epoch of the config file, in whichever network it is.
+func (n *Namespaces) RemoveEpochByConfigFilepath(configFilepath string) (string, uint64, error) {
+	for _, multi := range n.all() {
+		if epoch, err := multi.RemoveEpochByConfigFilepath(configFilepath); err == nil {
+			return multi.network, epoch, nil
+		}
+	}
+	return "", 0, fmt.Errorf("epoch not found for config file %q", configFilepath)
+}
+
+func (n *Namespaces) Close() error {
+	for _, multi := range n.all() {
+		multi.Close()
+	}
+	return nil
+}
+
+// route returns the network of the request, and whether it exists. When the network is the
+// first segment of the path, it's removed from the path.
+func (n *Namespaces) route(reqCtx *fasthttp.RequestCtx) (string, bool) {
+	if network, rest, ok := cutNetworkPrefix(string(reqCtx.Path())); ok && n.Get(network) != nil {
+		reqCtx.URI().SetPath(rest)
+		return network, true
+	}
+	if header := reqCtx.Request.Header.Peek(NetworkHeader); len(header) > 0 {
+		network := string(header)
+		return network, n.Get(network) != nil
+	}
+	return n.defaultNetwork, true
+}
+
+// cutNetworkPrefix splits /<network>/<rest> into the network and /<rest>.
+func cutNetworkPrefix(path string) (string, string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return "", "", false
+	}
+	network, rest, _ := strings.Cut(path[1:], "/")
+	if network == "" {
+		return "", "", false
+	}
+	return network, "/" + rest, true
+}
+
+// ListenAndServe starts listening on the configured address and serves the RPC API of all the networks.
+func (n *Namespaces) ListenAndServe(ctx context.Context, listenOn string, lsConf *ListenerConfig) error {
+	handler := newNamespacesHandler(n, lsConf)
+	var corsConf *CORSConfig
+	if lsConf != nil {
+		corsConf = lsConf.CORS
+	}
+	handler = corsHandler(handler, corsConf)
+	if n.options == nil || !n.options.DisableCompression {
+		minSize := DefaultCompressionMinSize
+		if n.options != nil {
+			minSize = n.options.CompressionMinSize
+		}
+		handler = compressionHandler(handler, minSize)
+	}
+
+	klog.Infof("RPC server listening on %s", listenOn)
+
+	s := &fasthttp.Server{
+		Handler:            n.drain.handler(handler),
+		MaxRequestBodySize: 1024 * 1024,
+	}
+	ln, err := reuseport.Listen("tcp4", listenOn)
+	if err != nil {
+		klog.Fatalf("error in reuseport listener: %v", err)
+		return err
+	}
+	drainingLn := &onceCloseListener{Listener: ln}
+	shutDown := make(chan struct{})
+	go func() {
+		defer close(shutDown)
+		// listen for context cancellation
+		<-ctx.Done()
+		n.shutdownHTTP(s, drainingLn)
+	}()
+	if err := s.Serve(drainingLn); err != nil {
+		return err
+	}
+	// Serve returns as soon as the listener is closed; wait for the requests in flight.
+	<-shutDown
+	return nil
+}
+
+// shutdownHTTP drains the RPC server: it stops accepting connections (after the shutdown delay),
+// waits for the requests and the streams in flight until the shutdown timeout, and then cancels
+// the ones still running.
+func (n *Namespaces) shutdownHTTP(s *fasthttp.Server, ln net.Listener) {
+	delay, timeout := n.options.shutdownDelays()
+	klog.Infof("RPC server shutting down (delay %s, timeout %s)...", delay, timeout)
+	defer klog.Info("RPC server shut down")
+	n.drain.start()
+	time.Sleep(delay)
+	if err := ln.Close(); err != nil {
+		klog.Errorf("Error while closing the RPC listener: %s", err)
+	}
+	deadline, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := n.drain.wait(deadline); err != nil {
+		klog.Warningf("RPC server: %d requests and %d streams still running after %s; canceling them",
+			n.drain.requests.Load(), n.drain.streams.Load(), timeout)
+	}
+	n.drain.stop()
+	forced, cancelForced := context.WithTimeout(context.Background(), forcedStopWait)
+	defer cancelForced()
+	// this closes the idle connections, and cancels the contexts of the requests still running.
+	if err := s.ShutdownWithContext(forced); err != nil {
+		klog.Errorf("Error while shutting down RPC server: %s", err)
+	}
+	if err := n.drain.wait(forced); err != nil {
+		klog.Errorf("RPC server: %d requests and %d streams didn't stop", n.drain.requests.Load(), n.drain.streams.Load())
+	}
+}
+
+// newNamespacesHandler routes each request to the handler of its network. The proxy and the
+// shadowing of the listener config only apply to the default network (their targets serve one network).
+func newNamespacesHandler(n *Namespaces, lsConf *ListenerConfig) fasthttp.RequestHandler {
+	var mu sync.Mutex
+	handlers := make(map[string]fasthttp.RequestHandler)
+	handlerOf := func(network string) fasthttp.RequestHandler {
+		mu.Lock()
+		defer mu.Unlock()
+		if handler, ok := handlers[network]; ok {
+			return handler
+		}
+		conf := lsConf
+		if network != n.defaultNetwork && lsConf != nil {
+			conf = &ListenerConfig{CORS: lsConf.CORS}
+		}
+		handler := newMultiEpochHandler(n.Get(network), conf)
+		handlers[network] = handler
+		return handler
+	}
+	return func(reqCtx *fasthttp.RequestCtx) {
+		network, ok := n.route(reqCtx)
+		if !ok {
+			replyJSON(reqCtx, http.StatusNotFound, jsonrpc2.Response{
+				Error: &jsonrpc2.Error{
+					Code:    jsonrpc2.CodeInvalidRequest,
+					Message: fmt.Sprintf("Unknown network %q", network),
+				},
+			})
+			return
+		}
+		handlerOf(network)(reqCtx)
+	}
+}
+
+// ListenAndServeGRPC starts listening on the configured address and serves the gRPC API of all the networks.
+func (n *Namespaces) ListenAndServeGRPC(ctx context.Context, listenOn string) error {
+	return listenAndServeGRPC(ctx, listenOn, &namespacesGRPCServer{namespaces: n}, n.drain, n.options)
+}
+
+// namespacesGRPCServer routes each call to the network in its NetworkHeader metadata, else to the default network.
+type namespacesGRPCServer struct {
+	old_faithful_grpc.UnimplementedOldFaithfulServer
+	namespaces *Namespaces
+}
+
+func (s *namespacesGRPCServer) multiOf(ctx context.Context) (*MultiEpoch, error) {
+	network := s.namespaces.defaultNetwork
+	if values := metadata.ValueFromIncomingContext(ctx, strings.ToLower(NetworkHeader)); len(values) > 0 {
+		network = values[0]
+	}
+	multi := s.namespaces.Get(network)
+	if multi == nil {
+		return nil, status.Errorf(codes.NotFound, "unknown network %q", network)
+	}
+	return multi, nil
+}
+
+func (s *namespacesGRPCServer) GetVersion(ctx context.Context, params *old_faithful_grpc.VersionRequest) (*old_faithful_grpc.VersionResponse, error) {
+	multi, err := s.multiOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return multi.GetVersion(ctx, params)
+}
+
+func (s *namespacesGRPCServer) GetEpochsInfo(ctx context.Context, params *old_faithful_grpc.EpochsInfoRequest) (*old_faithful_grpc.EpochsInfoResponse, error) {
+	multi, err := s.multiOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return multi.GetEpochsInfo(ctx, params)
+}
+
+func (s *namespacesGRPCServer) GetBlock(ctx context.Context, params *old_faithful_grpc.BlockRequest) (*old_faithful_grpc.BlockResponse, error) {
+	multi, err := s.multiOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return multi.GetBlock(ctx, params)
+}
+
+func (s *namespacesGRPCServer) GetBlockTime(ctx context.Context, params *old_faithful_grpc.BlockTimeRequest) (*old_faithful_grpc.BlockTimeResponse, error) {
+	multi, err := s.multiOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return multi.GetBlockTime(ctx, params)
+}
+
+func (s *namespacesGRPCServer) GetTransaction(ctx context.Context, params *old_faithful_grpc.TransactionRequest) (*old_faithful_grpc.TransactionResponse, error) {
+	multi, err := s.multiOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return multi.GetTransaction(ctx, params)
+}
+
+func (s *namespacesGRPCServer) GetTransactionsBatch(ctx context.Context, params *old_faithful_grpc.TransactionsBatchRequest) (*old_faithful_grpc.TransactionsBatchResponse, error) {
+	multi, err := s.multiOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return multi.GetTransactionsBatch(ctx, params)
+}
+
+func (s *namespacesGRPCServer) Get(ser old_faithful_grpc.OldFaithful_GetServer) error {
+	multi, err := s.multiOf(ser.Context())
+	if err != nil {
+		return err
+	}
+	return multi.Get(ser)
+}
+
+func (s *namespacesGRPCServer) StreamBlocks(params *old_faithful_grpc.StreamBlocksRequest, ser old_faithful_grpc.OldFaithful_StreamBlocksServer) error {
+	multi, err := s.multiOf(ser.Context())
+	if err != nil {
+		return err
+	}
+	return multi.StreamBlocks(params, ser)
+}
+
+func (s *namespacesGRPCServer) StreamTransactions(params *old_faithful_grpc.StreamTransactionsRequest, ser old_faithful_grpc.OldFaithful_StreamTransactionsServer) error {
+	multi, err := s.multiOf(ser.Context())
+	if err != nil {
+		return err
+	}
+	return multi.StreamTransactions(params, ser)
+}
+
+func (s *namespacesGRPCServer) StreamEntries(params *old_faithful_grpc.StreamEntriesRequest, ser old_faithful_grpc.OldFaithful_StreamEntriesServer) error {
+	multi, err := s.multiOf(ser.Context())
+	if err != nil {
+		return err
+	}
+	return multi.StreamEntries(params, ser)
+}
+
+var _ old_faithful_grpc.OldFaithfulServer = (*namespacesGRPCServer)(nil)
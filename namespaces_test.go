@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rpcpool/yellowstone-faithful/metrics"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/rpcpool/yellowstone-faithful/slottools"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateNetworkName(t *testing.T) {
+	require.NoError(t, validateNetworkName("mainnet"))
+	require.NoError(t, validateNetworkName("testnet-2"))
+	require.Error(t, validateNetworkName("Mainnet"))
+	require.Error(t, validateNetworkName("main/net"))
+	require.Error(t, validateNetworkName("-mainnet"))
+	require.Error(t, validateNetworkName("api"))
+}
+
+func TestConfigSliceValidateNetworks(t *testing.T) {
+	config, _, _, _ := buildTestEpoch(t)
+	other := *config
+	other.originalFilepath = "other.yml"
+	require.Error(t, ConfigSlice{config, &other}.Validate())
+
+	// the same epoch in two networks.
+	other.Network = "testnet"
+	require.NoError(t, ConfigSlice{config, &other}.Validate())
+	require.Equal(t, []string{"", "testnet"}, ConfigSlice{config, &other}.Networks())
+
+	other.Network = "Testnet"
+	require.Error(t, ConfigSlice{config, &other}.Validate())
+}
+
+func TestNamespacesHandler(t *testing.T) {
+	config, _, slots, _ := buildTestEpoch(t)
+	namespaces := NewNamespaces(&Options{}, "mainnet")
+	require.NoError(t, namespaces.Default().AddEpoch(0, openTestEpoch(t, config)))
+	namespaces.GetOrCreate("testnet")
+	require.Equal(t, []string{"mainnet", "testnet"}, namespaces.Networks())
+
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	server := &fasthttp.Server{Handler: newNamespacesHandler(namespaces, nil)}
+	go server.Serve(ln)
+	client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+
+	call := func(method string, path string, network string) (int, string) {
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+		req.SetRequestURI("http://faithful" + path)
+		req.Header.SetMethod(method)
+		if network != "" {
+			req.Header.Set(NetworkHeader, network)
+		}
+		if method == fasthttp.MethodPost {
+			req.SetBody([]byte(`{"jsonrpc":"2.0","id":1,"method":"getBlocks","params":[` + strconv.FormatUint(slots[0], 10) + `,` + strconv.FormatUint(slots[5], 10) + `]}`))
+		}
+		require.NoError(t, client.Do(req, resp))
+		return resp.StatusCode(), string(resp.Body())
+	}
+	blocks := `"result":[` + strconv.FormatUint(slots[0], 10) + `,`
+	testnetRequests := func() float64 {
+		return testutil.ToFloat64(metrics.RpcRequestByMethod.WithLabelValues("getBlocks", "testnet"))
+	}
+	before := testnetRequests()
+
+	// the default network.
+	_, body := call(fasthttp.MethodPost, "/", "")
+	require.Contains(t, body, blocks)
+	// by path prefix, which is removed for the handler of the network.
+	_, body = call(fasthttp.MethodPost, "/mainnet/", "")
+	require.Contains(t, body, blocks)
+	_, body = call(fasthttp.MethodPost, "/testnet", "")
+	require.NotContains(t, body, blocks)
+	require.Contains(t, body, `"error"`)
+	code, _ := call(fasthttp.MethodGet, "/testnet/health", "")
+	require.Equal(t, fasthttp.StatusOK, code)
+	// by header.
+	_, body = call(fasthttp.MethodPost, "/", "mainnet")
+	require.Contains(t, body, blocks)
+	_, body = call(fasthttp.MethodPost, "/", "testnet")
+	require.Contains(t, body, `"error"`)
+	code, body = call(fasthttp.MethodPost, "/", "devnet")
+	require.Equal(t, fasthttp.StatusNotFound, code)
+	require.Contains(t, body, `Unknown network \"devnet\"`)
+
+	require.Equal(t, before+2, testnetRequests())
+}
+
+func TestNamespacesGRPCVersionAndEpochs(t *testing.T) {
+	namespaces := NewNamespaces(&Options{}, "mainnet")
+	require.NoError(t, namespaces.Default().AddEpoch(3, &Epoch{epoch: 3}))
+	require.NoError(t, namespaces.Default().AddEpoch(1, &Epoch{epoch: 1}))
+	namespaces.GetOrCreate("testnet")
+	server := &namespacesGRPCServer{namespaces: namespaces}
+	withNetwork := func(network string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(NetworkHeader, network))
+	}
+
+	version, err := server.GetVersion(context.Background(), &old_faithful_grpc.VersionRequest{})
+	require.NoError(t, err)
+	require.Equal(t, GitCommit, version.Commit)
+	require.Contains(t, version.Features, "epochs_info")
+
+	info, err := server.GetEpochsInfo(context.Background(), &old_faithful_grpc.EpochsInfoRequest{})
+	require.NoError(t, err)
+	first1, last1 := slottools.CalcEpochLimits(1)
+	first3, last3 := slottools.CalcEpochLimits(3)
+	require.Equal(t, []*old_faithful_grpc.EpochInfo{
+		{Epoch: 1, FirstSlot: first1, LastSlot: last1},
+		{Epoch: 3, FirstSlot: first3, LastSlot: last3},
+	}, info.Epochs)
+
+	// the epochs of the network of the call.
+	info, err = server.GetEpochsInfo(withNetwork("testnet"), &old_faithful_grpc.EpochsInfoRequest{})
+	require.NoError(t, err)
+	require.Empty(t, info.Epochs)
+	_, err = server.GetEpochsInfo(withNetwork("devnet"), &old_faithful_grpc.EpochsInfoRequest{})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
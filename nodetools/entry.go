@@ -0,0 +1,23 @@
+package nodetools
+
+import (
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+)
+
+// EntrySummary is the PoH data of an entry, without its transactions;
+// it's what getBlock returns for each entry with the showEntries option.
+type EntrySummary struct {
+	NumHashes        uint64      `json:"numHashes"`
+	Hash             solana.Hash `json:"hash"`
+	TransactionCount int         `json:"transactionCount"`
+}
+
+// SummarizeEntry returns the summary of an entry node.
+func SummarizeEntry(entry *ipldbindcode.Entry) EntrySummary {
+	return EntrySummary{
+		NumHashes:        uint64(entry.NumHashes),
+		Hash:             solana.HashFromBytes(entry.Hash),
+		TransactionCount: len(entry.Transactions),
+	}
+}
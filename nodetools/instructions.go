@@ -0,0 +1,151 @@
+package nodetools
+
+import (
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+)
+
+// TopLevelStackHeight is the stack height of the instructions of the transaction message;
+// an instruction invoked (with CPI) by an instruction of height h has height h+1.
+const TopLevelStackHeight = 1
+
+// Instruction is an instruction of a transaction, top-level or inner, with its program and
+// accounts resolved.
+type Instruction struct {
+	ProgramID solana.PublicKey
+	Accounts  []solana.PublicKey
+	Data      []byte
+	// Compiled is the instruction as in the message or the meta: its program and accounts
+	// are indexes into the static and loaded accounts of the transaction.
+	Compiled solana.CompiledInstruction
+	// StackHeight is the stack height of the instruction. The metas written before the
+	// validators recorded it (and the legacy ones) don't have it: it's then inferred as
+	// TopLevelStackHeight+1 for all the inner instructions, which is only a lower bound,
+	// and StackHeightRecorded is false.
+	StackHeight         uint32
+	StackHeightRecorded bool
+	// OuterIndex is the index of the top-level instruction in the message (for an inner
+	// instruction, of the one it was invoked under), and InnerIndex the index of the inner
+	// instruction among those of its top-level instruction, or -1 for the top-level one.
+	OuterIndex int
+	InnerIndex int
+	// Parent is the index, in the flattened instructions, of the instruction that invoked
+	// this one, or -1 for a top-level instruction. Without recorded stack heights, the
+	// parent of an inner instruction is its top-level instruction.
+	Parent int
+}
+
+// IsTopLevel returns true if the instruction is an instruction of the transaction message.
+func (ix *Instruction) IsTopLevel() bool {
+	return ix.InnerIndex < 0
+}
+
+// FlattenInstructions returns the instructions of the transaction in execution order: each
+// top-level instruction followed by the inner instructions invoked under it. The accounts are
+// the static keys of the message followed by the writable and readonly accounts loaded from
+// address lookup tables, which are in the meta; without a meta (nil), only the top-level
+// instructions of transactions without lookups can be resolved.
+func FlattenInstructions(tx *solana.Transaction, meta *confirmed_block.TransactionStatusMeta) ([]Instruction, error) {
+	keys := tx.Message.AccountKeys
+	inner := make(map[uint32][]*confirmed_block.InnerInstruction)
+	if meta != nil {
+		keys = make([]solana.PublicKey, 0, len(tx.Message.AccountKeys)+len(meta.LoadedWritableAddresses)+len(meta.LoadedReadonlyAddresses))
+		keys = append(keys, tx.Message.AccountKeys...)
+		for _, addresses := range [][][]byte{meta.LoadedWritableAddresses, meta.LoadedReadonlyAddresses} {
+			for _, address := range addresses {
+				keys = append(keys, solana.PublicKeyFromBytes(address))
+			}
+		}
+		for _, ixs := range meta.InnerInstructions {
+			inner[ixs.Index] = append(inner[ixs.Index], ixs.Instructions...)
+		}
+	}
+	resolve := func(ix solana.CompiledInstruction) (solana.PublicKey, []solana.PublicKey, error) {
+		if int(ix.ProgramIDIndex) >= len(keys) {
+			return solana.PublicKey{}, nil, fmt.Errorf("program index %d out of range (%d accounts)", ix.ProgramIDIndex, len(keys))
+		}
+		accounts := make([]solana.PublicKey, len(ix.Accounts))
+		for i, index := range ix.Accounts {
+			if int(index) >= len(keys) {
+				return solana.PublicKey{}, nil, fmt.Errorf("account index %d out of range (%d accounts)", index, len(keys))
+			}
+			accounts[i] = keys[index]
+		}
+		return keys[ix.ProgramIDIndex], accounts, nil
+	}
+
+	var out []Instruction
+	for outerIndex, ix := range tx.Message.Instructions {
+		programID, accounts, err := resolve(ix)
+		if err != nil {
+			return nil, fmt.Errorf("instruction %d: %w", outerIndex, err)
+		}
+		outer := len(out)
+		out = append(out, Instruction{
+			ProgramID:           programID,
+			Accounts:            accounts,
+			Data:                ix.Data,
+			Compiled:            ix,
+			StackHeight:         TopLevelStackHeight,
+			StackHeightRecorded: true,
+			OuterIndex:          outerIndex,
+			InnerIndex:          -1,
+			Parent:              -1,
+		})
+		for innerIndex, innerIx := range inner[uint32(outerIndex)] {
+			compiled := solana.CompiledInstruction{
+				ProgramIDIndex: uint16(innerIx.ProgramIdIndex),
+				Accounts:       make([]uint16, len(innerIx.Accounts)),
+				Data:           innerIx.Data,
+			}
+			for i, index := range innerIx.Accounts {
+				compiled.Accounts[i] = uint16(index)
+			}
+			programID, accounts, err := resolve(compiled)
+			if err != nil {
+				return nil, fmt.Errorf("inner instruction %d of instruction %d: %w", innerIndex, outerIndex, err)
+			}
+			flat := Instruction{
+				ProgramID:   programID,
+				Accounts:    accounts,
+				Data:        innerIx.Data,
+				Compiled:    compiled,
+				StackHeight: TopLevelStackHeight + 1,
+				OuterIndex:  outerIndex,
+				InnerIndex:  innerIndex,
+				Parent:      outer,
+			}
+			if innerIx.StackHeight != nil {
+				flat.StackHeight = *innerIx.StackHeight
+				flat.StackHeightRecorded = true
+				// the parent is the closest previous instruction one level up.
+				for i := len(out) - 1; i > outer; i-- {
+					if out[i].StackHeightRecorded && out[i].StackHeight+1 == flat.StackHeight {
+						flat.Parent = i
+						break
+					}
+				}
+			}
+			out = append(out, flat)
+		}
+	}
+	return out, nil
+}
+
+// FlattenInstructionsOfMeta is FlattenInstructions with a meta as decoded from a CAR. A legacy
+// meta is converted to protobuf first: those without inner instructions flatten to the
+// top-level instructions, while for those with inner instructions, whose legacy encoding is
+// not decoded reliably, the error wraps solanatxmetaparsers.ErrNotUpgradable.
+func FlattenInstructionsOfMeta(tx *solana.Transaction, meta *solanatxmetaparsers.TransactionStatusMetaContainer) ([]Instruction, error) {
+	if meta == nil || meta.IsEmpty() {
+		return FlattenInstructions(tx, nil)
+	}
+	converted, err := meta.ToProtobuf()
+	if err != nil {
+		return nil, err
+	}
+	return FlattenInstructions(tx, converted)
+}
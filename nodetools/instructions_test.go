@@ -0,0 +1,104 @@
+package nodetools
+
+import (
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	metalatest "github.com/rpcpool/yellowstone-faithful/parse_legacy_transaction_status_meta/v-latest"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlattenInstructions(t *testing.T) {
+	payer, token, program := solana.PublicKey{1}, solana.PublicKey{2}, solana.PublicKey{3}
+	loadedWritable, loadedReadonly := solana.PublicKey{4}, solana.PublicKey{5}
+	tx := &solana.Transaction{Message: solana.Message{
+		AccountKeys: solana.PublicKeySlice{payer, token, program},
+		Instructions: []solana.CompiledInstruction{
+			{ProgramIDIndex: 2, Accounts: []uint16{0, 3}, Data: []byte{1}},
+			{ProgramIDIndex: 1, Accounts: []uint16{4}, Data: []byte{2}},
+		},
+	}}
+	height := func(h uint32) *uint32 { return &h }
+	meta := &confirmed_block.TransactionStatusMeta{
+		LoadedWritableAddresses: [][]byte{loadedWritable.Bytes()},
+		LoadedReadonlyAddresses: [][]byte{loadedReadonly.Bytes()},
+		InnerInstructions: []*confirmed_block.InnerInstructions{{
+			Index: 0,
+			Instructions: []*confirmed_block.InnerInstruction{
+				{ProgramIdIndex: 1, Accounts: []byte{3}, Data: []byte{10}, StackHeight: height(2)},
+				{ProgramIdIndex: 4, Accounts: []byte{0}, Data: []byte{11}, StackHeight: height(3)},
+				{ProgramIdIndex: 1, Accounts: []byte{}, Data: []byte{12}, StackHeight: height(2)},
+			},
+		}},
+	}
+
+	instructions, err := FlattenInstructions(tx, meta)
+	require.NoError(t, err)
+	require.Len(t, instructions, 5)
+	require.Equal(t, tx.Message.Instructions[0], instructions[0].Compiled)
+	require.Equal(t, solana.CompiledInstruction{ProgramIDIndex: 4, Accounts: []uint16{0}, Data: []byte{11}}, instructions[2].Compiled)
+	for i := range instructions {
+		instructions[i].Compiled = solana.CompiledInstruction{}
+	}
+	require.Equal(t, []Instruction{
+		{ProgramID: program, Accounts: []solana.PublicKey{payer, loadedWritable}, Data: []byte{1}, StackHeight: 1, StackHeightRecorded: true, OuterIndex: 0, InnerIndex: -1, Parent: -1},
+		{ProgramID: token, Accounts: []solana.PublicKey{loadedWritable}, Data: []byte{10}, StackHeight: 2, StackHeightRecorded: true, OuterIndex: 0, InnerIndex: 0, Parent: 0},
+		{ProgramID: loadedReadonly, Accounts: []solana.PublicKey{payer}, Data: []byte{11}, StackHeight: 3, StackHeightRecorded: true, OuterIndex: 0, InnerIndex: 1, Parent: 1},
+		{ProgramID: token, Accounts: []solana.PublicKey{}, Data: []byte{12}, StackHeight: 2, StackHeightRecorded: true, OuterIndex: 0, InnerIndex: 2, Parent: 0},
+		{ProgramID: token, Accounts: []solana.PublicKey{loadedReadonly}, Data: []byte{2}, StackHeight: 1, StackHeightRecorded: true, OuterIndex: 1, InnerIndex: -1, Parent: -1},
+	}, instructions)
+	require.True(t, instructions[0].IsTopLevel())
+	require.False(t, instructions[2].IsTopLevel())
+
+	// without recorded stack heights, the inner instructions are one level below their top-level one.
+	for _, ix := range meta.InnerInstructions[0].Instructions {
+		ix.StackHeight = nil
+	}
+	instructions, err = FlattenInstructions(tx, meta)
+	require.NoError(t, err)
+	for _, ix := range instructions[1:4] {
+		require.Equal(t, uint32(2), ix.StackHeight)
+		require.False(t, ix.StackHeightRecorded)
+		require.Equal(t, 0, ix.Parent)
+	}
+
+	// without the meta, the loaded accounts can't be resolved.
+	_, err = FlattenInstructions(tx, nil)
+	require.ErrorContains(t, err, "account index 3 out of range (3 accounts)")
+}
+
+func TestFlattenInstructionsOfMeta(t *testing.T) {
+	tx := &solana.Transaction{Message: solana.Message{
+		AccountKeys:  solana.PublicKeySlice{{1}, solana.SystemProgramID},
+		Instructions: []solana.CompiledInstruction{{ProgramIDIndex: 1, Accounts: []uint16{0}, Data: []byte{2}}},
+	}}
+	parse := func(meta *metalatest.TransactionStatusMeta) *solanatxmetaparsers.TransactionStatusMetaContainer {
+		buf, err := meta.BincodeSerialize()
+		require.NoError(t, err)
+		container, err := solanatxmetaparsers.ParseTransactionStatusMetaContainer(buf)
+		require.NoError(t, err)
+		require.True(t, container.IsSerdeLatest())
+		return container
+	}
+
+	// a legacy meta without inner instructions.
+	legacy := parse(&metalatest.TransactionStatusMeta{Status: &metalatest.Result__Ok{}, Fee: 5000, PreBalances: []uint64{1, 1}, PostBalances: []uint64{1, 1}})
+	instructions, err := FlattenInstructionsOfMeta(tx, legacy)
+	require.NoError(t, err)
+	require.Len(t, instructions, 1)
+	require.Equal(t, solana.SystemProgramID, instructions[0].ProgramID)
+	require.Equal(t, uint32(TopLevelStackHeight), instructions[0].StackHeight)
+
+	// no meta.
+	instructions, err = FlattenInstructionsOfMeta(tx, nil)
+	require.NoError(t, err)
+	require.Len(t, instructions, 1)
+
+	// a legacy meta with inner instructions.
+	inner := []metalatest.InnerInstructions{{Index: 0, Instructions: []metalatest.CompiledInstruction{{ProgramIdIndex: 1}}}}
+	legacy = parse(&metalatest.TransactionStatusMeta{Status: &metalatest.Result__Ok{}, PreBalances: []uint64{1, 1}, PostBalances: []uint64{1, 1}, InnerInstructions: &inner})
+	_, err = FlattenInstructionsOfMeta(tx, legacy)
+	require.ErrorIs(t, err, solanatxmetaparsers.ErrNotUpgradable)
+}
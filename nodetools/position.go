@@ -0,0 +1,41 @@
+package nodetools
+
+import (
+	"sort"
+
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+)
+
+// TransactionPosition returns the position (0-based) of the transaction in its block.
+// The position is the 'Index' field of the node; CAR files written before that field
+// existed don't have it, so the order of the node in the entries of the block
+// (orderInEntries) is used instead, which is the same order the validator executed them in.
+func TransactionPosition(tx *ipldbindcode.Transaction, orderInEntries int) uint64 {
+	if pos, ok := tx.GetPositionIndex(); ok {
+		return uint64(pos)
+	}
+	return uint64(orderInEntries)
+}
+
+// PositionedTransaction is a transaction node with its position in the block.
+type PositionedTransaction struct {
+	Node     *ipldbindcode.Transaction
+	Position uint64
+}
+
+// SortedTransactions returns the transactions of a block, which must be given in the order
+// of the entries of the block, sorted by their position in the block.
+// The sort is stable, so the order is deterministic even for nodes with colliding positions.
+func SortedTransactions(nodesInEntryOrder []*ipldbindcode.Transaction) []PositionedTransaction {
+	out := make([]PositionedTransaction, len(nodesInEntryOrder))
+	for i, node := range nodesInEntryOrder {
+		out[i] = PositionedTransaction{
+			Node:     node,
+			Position: TransactionPosition(node, i),
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Position < out[j].Position
+	})
+	return out
+}
@@ -0,0 +1,146 @@
+package nodetools
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/rpcpool/yellowstone-faithful/carreader"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/stretchr/testify/require"
+)
+
+func optionalIndex(index int) **int {
+	ptr := &index
+	return &ptr
+}
+
+func TestTransactionPosition(t *testing.T) {
+	require.Equal(t, uint64(3), TransactionPosition(&ipldbindcode.Transaction{}, 3))
+	require.Equal(t, uint64(7), TransactionPosition(&ipldbindcode.Transaction{Index: optionalIndex(7)}, 3))
+
+	var nilIndex *int
+	require.Equal(t, uint64(2), TransactionPosition(&ipldbindcode.Transaction{Index: &nilIndex}, 2))
+}
+
+// walkBlockTransactions calls fn with the transaction nodes of every block of the CAR,
+// in the order of the entries of the block.
+func walkBlockTransactions(t *testing.T, carPath string, fn func(slot uint64, nodesInEntryOrder []*ipldbindcode.Transaction)) {
+	file, err := os.Open(carPath)
+	require.NoError(t, err)
+	defer file.Close()
+	rd, err := carreader.New(file)
+	require.NoError(t, err)
+
+	pending := make(map[cid.Cid][]byte)
+	get := func(link any) []byte {
+		data, ok := pending[link.(cidlink.Link).Cid]
+		require.True(t, ok, "node %s not found before its block", link)
+		return data
+	}
+	for {
+		c, _, data, err := rd.NextNodeBytes()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		require.NoError(t, err)
+		if iplddecoders.Kind(data[1]) != iplddecoders.KindBlock {
+			pending[c] = data
+			continue
+		}
+		block, err := iplddecoders.DecodeBlock(data)
+		require.NoError(t, err)
+		var nodes []*ipldbindcode.Transaction
+		for _, entryLink := range block.Entries {
+			entry, err := iplddecoders.DecodeEntry(get(entryLink))
+			require.NoError(t, err)
+			for _, txLink := range entry.Transactions {
+				tx, err := iplddecoders.DecodeTransaction(get(txLink))
+				require.NoError(t, err)
+				nodes = append(nodes, tx)
+			}
+		}
+		fn(uint64(block.Slot), nodes)
+		clear(pending)
+	}
+}
+
+// TestSortedTransactionsMatchBlockOrder checks, on the sample CARs, that the transactions
+// are sorted in the order of the block recorded when the CAR was created.
+func TestSortedTransactionsMatchBlockOrder(t *testing.T) {
+	carPaths, err := filepath.Glob(filepath.Join("..", "fixtures", "*.car"))
+	require.NoError(t, err)
+	require.NotEmpty(t, carPaths)
+	for _, carPath := range carPaths {
+		t.Run(filepath.Base(carPath), func(t *testing.T) {
+			numBlocks := 0
+			walkBlockTransactions(t, carPath, func(slot uint64, nodesInEntryOrder []*ipldbindcode.Transaction) {
+				numBlocks++
+				sorted := SortedTransactions(nodesInEntryOrder)
+				require.Len(t, sorted, len(nodesInEntryOrder))
+				for i, positioned := range sorted {
+					require.Equal(t, uint64(i), positioned.Position, "slot %d", slot)
+					require.Same(t, nodesInEntryOrder[i], positioned.Node, "slot %d", slot)
+					if pos, ok := positioned.Node.GetPositionIndex(); ok {
+						require.Equal(t, i, pos, "slot %d", slot)
+					}
+				}
+
+				// Without the recorded positions, the order is the same.
+				stripped := make([]*ipldbindcode.Transaction, len(nodesInEntryOrder))
+				for i, node := range nodesInEntryOrder {
+					withoutIndex := *node
+					withoutIndex.Index = nil
+					stripped[i] = &withoutIndex
+				}
+				for i, positioned := range SortedTransactions(stripped) {
+					require.Same(t, stripped[i], positioned.Node, "slot %d", slot)
+				}
+			})
+			require.NotZero(t, numBlocks)
+		})
+	}
+}
+
+// FuzzSortedTransactions checks that SortedTransactions is a stable sort by position:
+// each byte of the input is a transaction, whose position is the byte value
+// (or, if odd, is not recorded and is the order in the entries).
+func FuzzSortedTransactions(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 2, 4, 6})
+	f.Add([]byte{6, 4, 2, 0})
+	f.Add([]byte{1, 3, 5})
+	f.Add([]byte{4, 4, 1, 0, 8, 2})
+	f.Fuzz(func(t *testing.T, positions []byte) {
+		nodes := make([]*ipldbindcode.Transaction, len(positions))
+		for i, pos := range positions {
+			// The slot field records the order in the entries, to check the stability.
+			nodes[i] = &ipldbindcode.Transaction{Slot: i}
+			if pos%2 == 0 {
+				nodes[i].Index = optionalIndex(int(pos))
+			}
+		}
+		sorted := SortedTransactions(nodes)
+		require.Len(t, sorted, len(nodes))
+		seen := make(map[*ipldbindcode.Transaction]bool, len(nodes))
+		for i, positioned := range sorted {
+			require.False(t, seen[positioned.Node], "duplicate node")
+			seen[positioned.Node] = true
+			require.Equal(t, TransactionPosition(positioned.Node, positioned.Node.Slot), positioned.Position)
+			if i == 0 {
+				continue
+			}
+			prev := sorted[i-1]
+			require.LessOrEqual(t, prev.Position, positioned.Position)
+			if prev.Position == positioned.Position {
+				// stable: colliding positions keep the order of the entries.
+				require.Less(t, prev.Node.Slot, positioned.Node.Slot)
+			}
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package nodetools
+
+import (
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+)
+
+// TransactionToProtobuf converts a transaction to the protobuf of the solana-storage-proto
+// crate (the one of the ConfirmedBlock of the warehouse Bigtable).
+func TransactionToProtobuf(tx *solana.Transaction) *confirmed_block.Transaction {
+	msg := &tx.Message
+	out := &confirmed_block.Transaction{
+		Message: &confirmed_block.Message{
+			Header: &confirmed_block.MessageHeader{
+				NumRequiredSignatures:       uint32(msg.Header.NumRequiredSignatures),
+				NumReadonlySignedAccounts:   uint32(msg.Header.NumReadonlySignedAccounts),
+				NumReadonlyUnsignedAccounts: uint32(msg.Header.NumReadonlyUnsignedAccounts),
+			},
+			RecentBlockhash: msg.RecentBlockhash[:],
+			Versioned:       msg.IsVersioned(),
+		},
+	}
+	for i := range tx.Signatures {
+		out.Signatures = append(out.Signatures, tx.Signatures[i][:])
+	}
+	for i := range msg.AccountKeys {
+		out.Message.AccountKeys = append(out.Message.AccountKeys, msg.AccountKeys[i][:])
+	}
+	for _, ix := range msg.Instructions {
+		accounts := make([]byte, len(ix.Accounts))
+		for i, account := range ix.Accounts {
+			accounts[i] = byte(account)
+		}
+		out.Message.Instructions = append(out.Message.Instructions, &confirmed_block.CompiledInstruction{
+			ProgramIdIndex: uint32(ix.ProgramIDIndex),
+			Accounts:       accounts,
+			Data:           ix.Data,
+		})
+	}
+	for i := range msg.AddressTableLookups {
+		lookup := &msg.AddressTableLookups[i]
+		out.Message.AddressTableLookups = append(out.Message.AddressTableLookups, &confirmed_block.MessageAddressTableLookup{
+			AccountKey:      lookup.AccountKey[:],
+			WritableIndexes: lookup.WritableIndexes,
+			ReadonlyIndexes: lookup.ReadonlyIndexes,
+		})
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+// Package nodetools has helpers to inspect the transactions stored in the nodes of a CAR
+// file without fully decoding them.
+package nodetools
+
+import (
+	"github.com/gagliardetto/solana-go"
+)
+
+// IsSimpleVoteTransaction checks if a transaction is a simple vote transaction.
+// A simple vote transaction meets these conditions:
+// 1. has 1 or 2 signatures
+// 2. is a legacy message
+// 3. has only one instruction
+// 4. which must be a Vote instruction
+func IsSimpleVoteTransaction(tx *solana.Transaction) bool {
+	if len(tx.Signatures) == 0 || len(tx.Signatures) > 2 {
+		return false
+	}
+	if tx.Message.IsVersioned() {
+		return false
+	}
+	instructions := tx.Message.Instructions
+	if len(instructions) != 1 {
+		return false
+	}
+	programIndex := int(instructions[0].ProgramIDIndex)
+	if programIndex >= len(tx.Message.AccountKeys) {
+		return false
+	}
+	return tx.Message.AccountKeys[programIndex].Equals(solana.VoteProgramID)
+}
+
+// IsSimpleVoteTransactionBytes is IsSimpleVoteTransaction on a wire-encoded transaction:
+// it only reads the signature count, the message header, the account keys and the first
+// instruction's program id index, so it's much cheaper than decoding the transaction.
+// It returns false if the bytes are not a valid transaction prefix.
+func IsSimpleVoteTransactionBytes(data []byte) bool {
+	numSignatures, n, ok := readCompactU16(data)
+	if !ok || numSignatures == 0 || numSignatures > 2 {
+		return false
+	}
+	offset := n + numSignatures*solana.SignatureLength
+	// message header: num_required_signatures (its high bit marks a versioned message),
+	// num_readonly_signed, num_readonly_unsigned.
+	if len(data) < offset+3 || data[offset]&0x80 != 0 {
+		return false
+	}
+	offset += 3
+	numKeys, n, ok := readCompactU16(data[offset:])
+	if !ok {
+		return false
+	}
+	keysOffset := offset + n
+	offset = keysOffset + numKeys*solana.PublicKeyLength
+	// recent blockhash.
+	offset += 32
+	if len(data) < offset {
+		return false
+	}
+	numInstructions, n, ok := readCompactU16(data[offset:])
+	if !ok || numInstructions != 1 {
+		return false
+	}
+	offset += n
+	if len(data) < offset+1 {
+		return false
+	}
+	programIndex := int(data[offset])
+	if programIndex >= numKeys {
+		return false
+	}
+	key := data[keysOffset+programIndex*solana.PublicKeyLength : keysOffset+(programIndex+1)*solana.PublicKeyLength]
+	return solana.PublicKeyFromBytes(key).Equals(solana.VoteProgramID)
+}
+
+// readCompactU16 reads a compact-u16 (shortvec) length; n is the number of bytes it takes.
+func readCompactU16(data []byte) (value int, n int, ok bool) {
+	for n < 3 {
+		if n >= len(data) {
+			return 0, 0, false
+		}
+		b := data[n]
+		value |= int(b&0x7f) << (7 * n)
+		n++
+		if b&0x80 == 0 {
+			return value, n, true
+		}
+	}
+	return 0, 0, false
+}
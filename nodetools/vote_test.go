@@ -0,0 +1,101 @@
+package nodetools
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, tx *solana.Transaction) []byte {
+	data, err := tx.MarshalBinary()
+	require.NoError(t, err)
+	return data
+}
+
+func newTransaction(t *testing.T, program solana.PublicKey, numInstructions int) *solana.Transaction {
+	payer := solana.PublicKey{9}
+	var instructions []solana.Instruction
+	for i := 0; i < numInstructions; i++ {
+		instructions = append(instructions, solana.NewInstruction(program, solana.AccountMetaSlice{solana.Meta(payer).WRITE().SIGNER()}, []byte{1, 2, 3}))
+	}
+	tx, err := solana.NewTransaction(instructions, solana.Hash{1}, solana.TransactionPayer(payer))
+	require.NoError(t, err)
+	tx.Signatures = []solana.Signature{{1}}
+	return tx
+}
+
+func TestIsSimpleVoteTransactionBytes(t *testing.T) {
+	cases := []struct {
+		name string
+		tx   *solana.Transaction
+		want bool
+	}{
+		{"vote", newTransaction(t, solana.VoteProgramID, 1), true},
+		{"two vote instructions", newTransaction(t, solana.VoteProgramID, 2), false},
+		{"other program", newTransaction(t, solana.SystemProgramID, 1), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.want, IsSimpleVoteTransaction(c.tx))
+			require.Equal(t, c.want, IsSimpleVoteTransactionBytes(encode(t, c.tx)))
+		})
+	}
+
+	t.Run("versioned", func(t *testing.T) {
+		tx := newTransaction(t, solana.VoteProgramID, 1)
+		tx.Message.SetVersion(solana.MessageVersionV0)
+		require.False(t, IsSimpleVoteTransaction(tx))
+		require.False(t, IsSimpleVoteTransactionBytes(encode(t, tx)))
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		data := encode(t, newTransaction(t, solana.VoteProgramID, 1))
+		// must not panic on any prefix.
+		for i := 0; i < len(data); i++ {
+			_ = IsSimpleVoteTransactionBytes(data[:i])
+		}
+		require.False(t, IsSimpleVoteTransactionBytes(nil))
+	})
+}
+
+func TestIsSimpleVoteTransactionBytesFixture(t *testing.T) {
+	numVotes := 0
+	err := carwriter.NewCarSource("../fixtures/epoch-0-1.car").Blocks(context.Background(), 0, math.MaxUint64, func(block *carwriter.Block) error {
+		for _, entry := range block.Entries {
+			for _, tx := range entry.Transactions {
+				var decoded solana.Transaction
+				require.NoError(t, decoded.UnmarshalWithDecoder(bin.NewBinDecoder(tx.Data)))
+				want := IsSimpleVoteTransaction(&decoded)
+				require.Equal(t, want, IsSimpleVoteTransactionBytes(tx.Data))
+				if want {
+					numVotes++
+				}
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotZero(t, numVotes)
+}
+
+func BenchmarkIsSimpleVoteTransaction(b *testing.B) {
+	tx := newTransaction(&testing.T{}, solana.VoteProgramID, 1)
+	data, _ := tx.MarshalBinary()
+	b.Run("decode", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var decoded solana.Transaction
+			_ = decoded.UnmarshalWithDecoder(bin.NewBinDecoder(data))
+			IsSimpleVoteTransaction(&decoded)
+		}
+	})
+	b.Run("bytes", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			IsSimpleVoteTransactionBytes(data)
+		}
+	})
+}
@@ -0,0 +1,11 @@
+// Package old_faithful_grpc is the Go client and server code of the old-faithful gRPC API
+// (old-faithful-proto/proto/old-faithful.proto), generated with protoc-gen-go and protoc-gen-go-grpc.
+//
+// It's a module of its own, so that clients can depend on it without depending on the
+// faithful-cli module:
+//
+//	go get github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc@latest
+//
+// Its versions are tagged as old-faithful-proto/old-faithful-grpc/vX.Y.Z; changes of the proto
+// are checked for backward compatibility with `make proto-breaking`.
+package old_faithful_grpc
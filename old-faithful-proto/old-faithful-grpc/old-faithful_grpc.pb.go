@@ -19,13 +19,16 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	OldFaithful_GetVersion_FullMethodName         = "/OldFaithful.OldFaithful/GetVersion"
-	OldFaithful_GetBlock_FullMethodName           = "/OldFaithful.OldFaithful/GetBlock"
-	OldFaithful_GetBlockTime_FullMethodName       = "/OldFaithful.OldFaithful/GetBlockTime"
-	OldFaithful_GetTransaction_FullMethodName     = "/OldFaithful.OldFaithful/GetTransaction"
-	OldFaithful_Get_FullMethodName                = "/OldFaithful.OldFaithful/Get"
-	OldFaithful_StreamBlocks_FullMethodName       = "/OldFaithful.OldFaithful/StreamBlocks"
-	OldFaithful_StreamTransactions_FullMethodName = "/OldFaithful.OldFaithful/StreamTransactions"
+	OldFaithful_GetVersion_FullMethodName           = "/OldFaithful.OldFaithful/GetVersion"
+	OldFaithful_GetBlock_FullMethodName             = "/OldFaithful.OldFaithful/GetBlock"
+	OldFaithful_GetBlockTime_FullMethodName         = "/OldFaithful.OldFaithful/GetBlockTime"
+	OldFaithful_GetTransaction_FullMethodName       = "/OldFaithful.OldFaithful/GetTransaction"
+	OldFaithful_GetTransactionsBatch_FullMethodName = "/OldFaithful.OldFaithful/GetTransactionsBatch"
+	OldFaithful_Get_FullMethodName                  = "/OldFaithful.OldFaithful/Get"
+	OldFaithful_StreamBlocks_FullMethodName         = "/OldFaithful.OldFaithful/StreamBlocks"
+	OldFaithful_StreamTransactions_FullMethodName   = "/OldFaithful.OldFaithful/StreamTransactions"
+	OldFaithful_StreamEntries_FullMethodName        = "/OldFaithful.OldFaithful/StreamEntries"
+	OldFaithful_GetEpochsInfo_FullMethodName        = "/OldFaithful.OldFaithful/GetEpochsInfo"
 )
 
 // OldFaithfulClient is the client API for OldFaithful service.
@@ -36,9 +39,15 @@ type OldFaithfulClient interface {
 	GetBlock(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*BlockResponse, error)
 	GetBlockTime(ctx context.Context, in *BlockTimeRequest, opts ...grpc.CallOption) (*BlockTimeResponse, error)
 	GetTransaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*TransactionResponse, error)
+	GetTransactionsBatch(ctx context.Context, in *TransactionsBatchRequest, opts ...grpc.CallOption) (*TransactionsBatchResponse, error)
 	Get(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[GetRequest, GetResponse], error)
 	StreamBlocks(ctx context.Context, in *StreamBlocksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BlockResponse], error)
+	// The transactions are sent in slot order, and in the order of the block within a slot
+	// (each transaction once), also when the range spans several epochs or split epochs.
 	StreamTransactions(ctx context.Context, in *StreamTransactionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TransactionResponse], error)
+	StreamEntries(ctx context.Context, in *StreamEntriesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[EntryResponse], error)
+	// The epochs served, with their slot ranges.
+	GetEpochsInfo(ctx context.Context, in *EpochsInfoRequest, opts ...grpc.CallOption) (*EpochsInfoResponse, error)
 }
 
 type oldFaithfulClient struct {
@@ -89,6 +98,16 @@ func (c *oldFaithfulClient) GetTransaction(ctx context.Context, in *TransactionR
 	return out, nil
 }
 
+func (c *oldFaithfulClient) GetTransactionsBatch(ctx context.Context, in *TransactionsBatchRequest, opts ...grpc.CallOption) (*TransactionsBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransactionsBatchResponse)
+	err := c.cc.Invoke(ctx, OldFaithful_GetTransactionsBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *oldFaithfulClient) Get(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[GetRequest, GetResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	stream, err := c.cc.NewStream(ctx, &OldFaithful_ServiceDesc.Streams[0], OldFaithful_Get_FullMethodName, cOpts...)
@@ -140,6 +159,35 @@ func (c *oldFaithfulClient) StreamTransactions(ctx context.Context, in *StreamTr
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type OldFaithful_StreamTransactionsClient = grpc.ServerStreamingClient[TransactionResponse]
 
+func (c *oldFaithfulClient) StreamEntries(ctx context.Context, in *StreamEntriesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[EntryResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OldFaithful_ServiceDesc.Streams[3], OldFaithful_StreamEntries_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamEntriesRequest, EntryResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OldFaithful_StreamEntriesClient = grpc.ServerStreamingClient[EntryResponse]
+
+func (c *oldFaithfulClient) GetEpochsInfo(ctx context.Context, in *EpochsInfoRequest, opts ...grpc.CallOption) (*EpochsInfoResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EpochsInfoResponse)
+	err := c.cc.Invoke(ctx, OldFaithful_GetEpochsInfo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // OldFaithfulServer is the server API for OldFaithful service.
 // All implementations must embed UnimplementedOldFaithfulServer
 // for forward compatibility.
@@ -148,9 +196,15 @@ type OldFaithfulServer interface {
 	GetBlock(context.Context, *BlockRequest) (*BlockResponse, error)
 	GetBlockTime(context.Context, *BlockTimeRequest) (*BlockTimeResponse, error)
 	GetTransaction(context.Context, *TransactionRequest) (*TransactionResponse, error)
+	GetTransactionsBatch(context.Context, *TransactionsBatchRequest) (*TransactionsBatchResponse, error)
 	Get(grpc.BidiStreamingServer[GetRequest, GetResponse]) error
 	StreamBlocks(*StreamBlocksRequest, grpc.ServerStreamingServer[BlockResponse]) error
+	// The transactions are sent in slot order, and in the order of the block within a slot
+	// (each transaction once), also when the range spans several epochs or split epochs.
 	StreamTransactions(*StreamTransactionsRequest, grpc.ServerStreamingServer[TransactionResponse]) error
+	StreamEntries(*StreamEntriesRequest, grpc.ServerStreamingServer[EntryResponse]) error
+	// The epochs served, with their slot ranges.
+	GetEpochsInfo(context.Context, *EpochsInfoRequest) (*EpochsInfoResponse, error)
 	mustEmbedUnimplementedOldFaithfulServer()
 }
 
@@ -173,6 +227,9 @@ func (UnimplementedOldFaithfulServer) GetBlockTime(context.Context, *BlockTimeRe
 func (UnimplementedOldFaithfulServer) GetTransaction(context.Context, *TransactionRequest) (*TransactionResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetTransaction not implemented")
 }
+func (UnimplementedOldFaithfulServer) GetTransactionsBatch(context.Context, *TransactionsBatchRequest) (*TransactionsBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransactionsBatch not implemented")
+}
 func (UnimplementedOldFaithfulServer) Get(grpc.BidiStreamingServer[GetRequest, GetResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method Get not implemented")
 }
@@ -182,6 +239,12 @@ func (UnimplementedOldFaithfulServer) StreamBlocks(*StreamBlocksRequest, grpc.Se
 func (UnimplementedOldFaithfulServer) StreamTransactions(*StreamTransactionsRequest, grpc.ServerStreamingServer[TransactionResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method StreamTransactions not implemented")
 }
+func (UnimplementedOldFaithfulServer) StreamEntries(*StreamEntriesRequest, grpc.ServerStreamingServer[EntryResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEntries not implemented")
+}
+func (UnimplementedOldFaithfulServer) GetEpochsInfo(context.Context, *EpochsInfoRequest) (*EpochsInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEpochsInfo not implemented")
+}
 func (UnimplementedOldFaithfulServer) mustEmbedUnimplementedOldFaithfulServer() {}
 func (UnimplementedOldFaithfulServer) testEmbeddedByValue()                     {}
 
@@ -275,6 +338,24 @@ func _OldFaithful_GetTransaction_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OldFaithful_GetTransactionsBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionsBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OldFaithfulServer).GetTransactionsBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OldFaithful_GetTransactionsBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OldFaithfulServer).GetTransactionsBatch(ctx, req.(*TransactionsBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _OldFaithful_Get_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(OldFaithfulServer).Get(&grpc.GenericServerStream[GetRequest, GetResponse]{ServerStream: stream})
 }
@@ -304,6 +385,35 @@ func _OldFaithful_StreamTransactions_Handler(srv interface{}, stream grpc.Server
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type OldFaithful_StreamTransactionsServer = grpc.ServerStreamingServer[TransactionResponse]
 
+func _OldFaithful_StreamEntries_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEntriesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OldFaithfulServer).StreamEntries(m, &grpc.GenericServerStream[StreamEntriesRequest, EntryResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OldFaithful_StreamEntriesServer = grpc.ServerStreamingServer[EntryResponse]
+
+func _OldFaithful_GetEpochsInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EpochsInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OldFaithfulServer).GetEpochsInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OldFaithful_GetEpochsInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OldFaithfulServer).GetEpochsInfo(ctx, req.(*EpochsInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // OldFaithful_ServiceDesc is the grpc.ServiceDesc for OldFaithful service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -327,6 +437,14 @@ var OldFaithful_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetTransaction",
 			Handler:    _OldFaithful_GetTransaction_Handler,
 		},
+		{
+			MethodName: "GetTransactionsBatch",
+			Handler:    _OldFaithful_GetTransactionsBatch_Handler,
+		},
+		{
+			MethodName: "GetEpochsInfo",
+			Handler:    _OldFaithful_GetEpochsInfo_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -345,6 +463,11 @@ var OldFaithful_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _OldFaithful_StreamTransactions_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "StreamEntries",
+			Handler:       _OldFaithful_StreamEntries_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "old-faithful.proto",
 }
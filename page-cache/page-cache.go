@@ -0,0 +1,341 @@
+// Package pagecache is a bounded on-disk cache of the pages of remote files (i.e. the indexes
+// served over HTTP), shared by all the files it wraps.
+//
+// The files are split in pages of a fixed size, and each page that was read is stored as one
+// file under <dir>/<xx>/<file>-<page size>-<page index>, where <file> is derived from the URL
+// and the size of the remote file and <xx> are its first two characters. Writes are atomic
+// (tmp file + rename), and pages whose size is not the expected one are dropped instead of
+// being served. When the total size exceeds the limit, the least recently used pages are evicted.
+package pagecache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+const tmpSuffix = ".tmp"
+
+// DefaultPageSize is the page size used if none is given.
+const DefaultPageSize = 64 * 1024
+
+type Cache struct {
+	dir      string
+	maxSize  int64
+	pageSize int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // front is the most recently used
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key  string
+	size int64
+}
+
+// New opens (or creates) a page cache in the given directory, limited to maxSize bytes.
+// Pages already in the directory are kept (if they have the same page size), oldest-modified
+// first in line for eviction.
+func New(dir string, maxSize int64, pageSize int64) (*Cache, error) {
+	if dir == "" {
+		return nil, errors.New("dir must not be empty")
+	}
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("max size must be positive, got %d", maxSize)
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir %q: %w", dir, err)
+	}
+	c := &Cache{
+		dir:      dir,
+		maxSize:  maxSize,
+		pageSize: pageSize,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+	if err := c.load(); err != nil {
+		return nil, fmt.Errorf("failed to load cache dir %q: %w", dir, err)
+	}
+	c.mu.Lock()
+	c.evictLocked()
+	c.mu.Unlock()
+	return c, nil
+}
+
+func (c *Cache) load() error {
+	type found struct {
+		key     string
+		size    int64
+		modTime int64
+	}
+	var all []found
+	pageSize := strconv.FormatInt(c.pageSize, 10)
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if strings.HasSuffix(path, tmpSuffix) {
+			// leftover of an interrupted write.
+			os.Remove(path)
+			return nil
+		}
+		key := d.Name()
+		if parts := strings.Split(key, "-"); len(parts) != 4 || parts[2] != pageSize {
+			// a page of another page size, or not a page.
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		all = append(all, found{key: key, size: info.Size(), modTime: info.ModTime().UnixNano()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// newest first, so that the oldest end up at the back of the LRU list.
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].modTime > all[j].modTime
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range all {
+		c.entries[f.key] = c.lru.PushBack(&entry{key: f.key, size: f.size})
+		c.size += f.size
+	}
+	return nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+func (c *Cache) get(key string, wantSize int64) ([]byte, bool) {
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if ok {
+		c.lru.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			klog.Warningf("page cache: failed to read page %s: %s", key, err)
+		}
+		c.remove(key)
+		return nil, false
+	}
+	if int64(len(data)) != wantSize {
+		klog.Warningf("page cache: page %s has %d bytes instead of %d; removing it", key, len(data), wantSize)
+		c.remove(key)
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *Cache) has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[key]
+	return ok
+}
+
+func (c *Cache) put(key string, data []byte) error {
+	if int64(len(data)) > c.maxSize || c.has(key) {
+		return nil
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+"-*"+tmpSuffix)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		// stored concurrently by someone else; same content.
+		c.lru.MoveToFront(el)
+		return nil
+	}
+	c.entries[key] = c.lru.PushFront(&entry{key: key, size: int64(len(data))})
+	c.size += int64(len(data))
+	c.evictLocked()
+	return nil
+}
+
+func (c *Cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.removeLocked(el)
+	}
+}
+
+func (c *Cache) removeLocked(el *list.Element) {
+	e := el.Value.(*entry)
+	c.lru.Remove(el)
+	delete(c.entries, e.key)
+	c.size -= e.size
+	if err := os.Remove(c.path(e.key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		klog.Warningf("page cache: failed to remove %s: %s", e.key, err)
+	}
+}
+
+func (c *Cache) evictLocked() {
+	for c.size > c.maxSize {
+		el := c.lru.Back()
+		if el == nil {
+			return
+		}
+		c.removeLocked(el)
+	}
+}
+
+// Size returns the total size in bytes of the pages in the cache.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Len returns the number of pages in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// ReaderAtCloser is a remote file.
+type ReaderAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// Wrap returns a reader of the remote file that reads its pages from the cache, and reads the
+// missing ones from the file (and stores them). The name (i.e. the URL) and the size identify
+// the file in the cache: a file that changed size is not mixed up with its old pages.
+func (c *Cache) Wrap(name string, size int64, file ReaderAtCloser) *ReaderAt {
+	sum := sha256.Sum256([]byte(name))
+	return &ReaderAt{
+		cache: c,
+		file:  file,
+		size:  size,
+		id:    hex.EncodeToString(sum[:16]) + "-" + strconv.FormatInt(size, 10) + "-" + strconv.FormatInt(c.pageSize, 10),
+	}
+}
+
+// ReaderAt reads a remote file through the page cache.
+type ReaderAt struct {
+	cache *Cache
+	file  ReaderAtCloser
+	size  int64
+	id    string
+}
+
+func (r *ReaderAt) pageKey(page int64) string {
+	return r.id + "-" + strconv.FormatInt(page, 10)
+}
+
+func (r *ReaderAt) pageLength(page int64) int64 {
+	return min(r.cache.pageSize, r.size-page*r.cache.pageSize)
+}
+
+// Size returns the size of the remote file.
+func (r *ReaderAt) Size() int64 {
+	return r.size
+}
+
+func (r *ReaderAt) Close() error {
+	return r.file.Close()
+}
+
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	end := min(off+int64(len(p)), r.size)
+	pageSize := r.cache.pageSize
+	n := 0
+	for page := off / pageSize; page*pageSize < end; {
+		data, ok := r.cache.get(r.pageKey(page), r.pageLength(page))
+		if ok {
+			n += r.copyPage(p, off, page, data)
+			page++
+			continue
+		}
+		// read the run of missing pages with one request.
+		last := page
+		for (last+1)*pageSize < end && !r.cache.has(r.pageKey(last+1)) {
+			last++
+		}
+		runStart := page * pageSize
+		run := make([]byte, min((last+1)*pageSize, r.size)-runStart)
+		if _, err := r.file.ReadAt(run, runStart); err != nil && !(errors.Is(err, io.EOF) && runStart+int64(len(run)) == r.size) {
+			return n, err
+		}
+		for ; page <= last; page++ {
+			from := page*pageSize - runStart
+			data := run[from : from+r.pageLength(page)]
+			if err := r.cache.put(r.pageKey(page), data); err != nil {
+				klog.Warningf("page cache: failed to store page %s: %s", r.pageKey(page), err)
+			}
+			n += r.copyPage(p, off, page, data)
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// copyPage copies the part of the page that overlaps p (which starts at off in the file).
+func (r *ReaderAt) copyPage(p []byte, off int64, page int64, data []byte) int {
+	pageStart := page * r.cache.pageSize
+	if pageStart >= off {
+		return copy(p[pageStart-off:], data)
+	}
+	return copy(p, data[off-pageStart:])
+}
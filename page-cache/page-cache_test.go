@@ -0,0 +1,84 @@
+package pagecache
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type countingFile struct {
+	*bytes.Reader
+	reads atomic.Int64
+}
+
+func (f *countingFile) ReadAt(p []byte, off int64) (int, error) {
+	f.reads.Add(1)
+	return f.Reader.ReadAt(p, off)
+}
+
+func (f *countingFile) Close() error { return nil }
+
+func TestReaderAt(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 100, 10)
+	require.NoError(t, err)
+
+	content := make([]byte, 45)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	file := &countingFile{Reader: bytes.NewReader(content)}
+	r := c.Wrap("https://example.com/index", int64(len(content)), file)
+
+	read := func(off int64, n int) ([]byte, error) {
+		buf := make([]byte, n)
+		got, err := r.ReadAt(buf, off)
+		return buf[:got], err
+	}
+
+	// pages 0 to 2 are read with one request.
+	got, err := read(5, 20)
+	require.NoError(t, err)
+	require.Equal(t, content[5:25], got)
+	require.EqualValues(t, 1, file.reads.Load())
+	require.Equal(t, 3, c.Len())
+
+	// cached pages are not read again; only the missing pages 3 and 4 (the last, short one) are.
+	got, err = read(15, 30)
+	require.NoError(t, err)
+	require.Equal(t, content[15:45], got)
+	require.EqualValues(t, 2, file.reads.Load())
+	require.Equal(t, int64(45), c.Size())
+
+	got, err = read(40, 10)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, content[40:], got)
+	_, err = read(45, 1)
+	require.ErrorIs(t, err, io.EOF)
+	require.EqualValues(t, 2, file.reads.Load())
+
+	t.Run("evict", func(t *testing.T) {
+		// another file of 80 bytes evicts the least recently used pages.
+		other := &countingFile{Reader: bytes.NewReader(make([]byte, 80))}
+		_, err := c.Wrap("https://example.com/other", 80, other).ReadAt(make([]byte, 80), 0)
+		require.NoError(t, err)
+		require.LessOrEqual(t, c.Size(), int64(100))
+		require.False(t, c.has(r.pageKey(0)))
+		require.True(t, c.has(r.pageKey(4)))
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		reopened, err := New(dir, 100, 10)
+		require.NoError(t, err)
+		require.Equal(t, c.Len(), reopened.Len())
+		require.Equal(t, c.Size(), reopened.Size())
+
+		// with another page size, the pages are ignored.
+		otherPageSize, err := New(dir, 100, 20)
+		require.NoError(t, err)
+		require.Zero(t, otherPageSize.Len())
+	})
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/rpcpool/yellowstone-faithful/metrics"
+	"k8s.io/klog/v2"
+)
+
+// errInternal is the message that clients get for a request whose handler panicked;
+// the panic itself is only logged.
+const errInternal = "Internal error"
+
+// recoveredPanic logs a panic recovered while serving a request (with the stack trace of the panicking goroutine),
+// counts it in the panics_recovered metric, and returns it as an error.
+//
+// Decoding a malformed node, a bad pool state or a cgo call must fail the request that hit it,
+// not crash the process with every other request in flight.
+func recoveredPanic(component string, method string, recovered any) error {
+	metrics.PanicsRecovered.WithLabelValues(component, sanitizeMethod(method)).Inc()
+	klog.Errorf("recovered panic in %s %s: %v\n%s", component, method, recovered, debug.Stack())
+	return fmt.Errorf("panic: %v", recovered)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rpcpool/yellowstone-faithful/metrics"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newPanickingMultiEpoch returns a MultiEpoch whose epoch 0 is nil, so that every request for it panics.
+func newPanickingMultiEpoch() *MultiEpoch {
+	multi := NewMultiEpoch(&Options{})
+	multi.epochs[0] = newOpenEpochHandle(0, nil)
+	return multi
+}
+
+func TestRecoveryInterceptors(t *testing.T) {
+	panics := func() float64 {
+		return testutil.ToFloat64(metrics.PanicsRecovered.WithLabelValues("grpc", "/OldFaithful/GetBlock"))
+	}
+	before := panics()
+	multi := newPanickingMultiEpoch()
+	resp, err := recoveryUnaryInterceptor(context.Background(), &old_faithful_grpc.BlockRequest{Slot: 1},
+		&grpc.UnaryServerInfo{FullMethod: "/OldFaithful/GetBlock"},
+		func(ctx context.Context, req any) (any, error) {
+			return multi.GetBlock(ctx, req.(*old_faithful_grpc.BlockRequest))
+		})
+	require.Nil(t, resp)
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.Equal(t, before+1, panics())
+
+	err = recoveryStreamInterceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: "/OldFaithful/StreamBlocks"},
+		func(srv any, ss grpc.ServerStream) error {
+			panic("boom")
+		})
+	require.Equal(t, codes.Internal, status.Code(err))
+
+	// Without a panic, the handler's result goes through.
+	resp, err = recoveryUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/OldFaithful/GetVersion"},
+		func(ctx context.Context, req any) (any, error) {
+			return "ok", nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, "ok", resp)
+}
+
+func TestHTTPHandlerRecovers(t *testing.T) {
+	handler := newMultiEpochHandler(newPanickingMultiEpoch(), nil)
+	do := func(method string, path string, body string) *fasthttp.Response {
+		var req fasthttp.Request
+		req.Header.SetMethod(method)
+		req.SetRequestURI(path)
+		req.SetBodyString(body)
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		handler(&reqCtx)
+		var resp fasthttp.Response
+		reqCtx.Response.CopyTo(&resp)
+		return &resp
+	}
+
+	t.Run("jsonrpc", func(t *testing.T) {
+		resp := do(fasthttp.MethodPost, "/", `{"jsonrpc":"2.0","id":7,"method":"getBlock","params":[1]}`)
+		require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+		var got struct {
+			ID    int `json:"id"`
+			Error *struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(resp.Body(), &got), string(resp.Body()))
+		require.Equal(t, 7, got.ID)
+		require.NotNil(t, got.Error)
+		require.Equal(t, -32603, got.Error.Code)
+		require.Equal(t, errInternal, got.Error.Message)
+	})
+	t.Run("rest", func(t *testing.T) {
+		resp := do(fasthttp.MethodGet, "/api/v1/block/1", "")
+		require.Equal(t, fasthttp.StatusInternalServerError, resp.StatusCode())
+		require.Contains(t, string(resp.Body()), errInternal)
+	})
+	t.Run("still serving", func(t *testing.T) {
+		resp := do(fasthttp.MethodGet, "/health", "")
+		require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+	})
+}
+
+func TestFirstSuccessRecovers(t *testing.T) {
+	jobGroup := NewJobGroup[int]()
+	jobGroup.Add(func(context.Context) (int, error) {
+		panic("boom")
+	})
+	jobGroup.Add(func(context.Context) (int, error) {
+		return 2, nil
+	})
+	val, err := jobGroup.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, val)
+
+	jobGroup = NewJobGroup[int]()
+	jobGroup.Add(func(context.Context) (int, error) {
+		panic("boom")
+	})
+	_, err = jobGroup.Run(context.Background())
+	require.ErrorContains(t, err, "panic: boom")
+}
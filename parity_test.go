@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// The parity tests compare the responses of the RPC server for the fixture CAR (mainnet epoch 0)
+// with the responses of mainnet, recorded in fixtures/parity: one file per request.
+// `make parity-record` (re)records them from PARITY_RPC.
+var parityRecord = flag.String("parity.record", "", "record the parity fixtures from this RPC endpoint, instead of checking them")
+
+const parityDir = "fixtures/parity"
+
+// parityRecording is a recorded request and the result of the reference RPC for it.
+type parityRecording struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	// Ignore is the fields that are not compared (known differences), as dot-separated
+	// paths in the result; a path goes through all the elements of the arrays on it.
+	Ignore []string        `json:"ignore,omitempty"`
+	Result json.RawMessage `json:"result"`
+}
+
+// parityCase is a request of the parity tests.
+type parityCase struct {
+	name   string
+	method string
+	params []any
+}
+
+// parityCases returns the requests of the parity tests: getBlock and getTransaction
+// with the encodings and the levels of detail of the RPC.
+func parityCases(slots []uint64, sigs []solana.Signature) []parityCase {
+	var cases []parityCase
+	// the server doesn't support the jsonParsed encoding (yet).
+	blockOptions := map[string]map[string]any{
+		"json":       {"encoding": "json", "maxSupportedTransactionVersion": 0},
+		"base64":     {"encoding": "base64", "maxSupportedTransactionVersion": 0},
+		"signatures": {"transactionDetails": "signatures", "rewards": false, "maxSupportedTransactionVersion": 0},
+		"none":       {"transactionDetails": "none", "maxSupportedTransactionVersion": 0},
+	}
+	for _, slot := range slots {
+		for name, options := range blockOptions {
+			cases = append(cases, parityCase{
+				name:   fmt.Sprintf("getBlock-%d-%s", slot, name),
+				method: "getBlock",
+				params: []any{slot, options},
+			})
+		}
+	}
+	for _, sig := range sigs {
+		for _, encoding := range []string{"json", "base64"} {
+			cases = append(cases, parityCase{
+				name:   fmt.Sprintf("getTransaction-%s-%s", sig, encoding),
+				method: "getTransaction",
+				params: []any{sig.String(), map[string]any{"encoding": encoding, "maxSupportedTransactionVersion": 0}},
+			})
+		}
+	}
+	return cases
+}
+
+func TestParity(t *testing.T) {
+	config, _, slots, sigs := buildTestEpoch(t)
+	if *parityRecord != "" {
+		recordParity(t, *parityRecord, parityDir, parityCases(slots, sigs))
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(parityDir, "*.json"))
+	require.NoError(t, err)
+	if len(files) == 0 {
+		t.Skipf("no recordings in %s; record them with `make parity-record`", parityDir)
+	}
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, openTestEpoch(t, config)))
+	checkParity(t, newMultiEpochHandler(multi, nil), files)
+}
+
+// TestParity_Harness records the responses of the server itself, and checks them.
+func TestParity_Harness(t *testing.T) {
+	config, _, slots, sigs := buildTestEpoch(t)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, openTestEpoch(t, config)))
+	handler := newMultiEpochHandler(multi, nil)
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &fasthttp.Server{Handler: handler}
+	go srv.Serve(ln)
+	defer srv.Shutdown()
+
+	dir := t.TempDir()
+	cases := parityCases(slots[1:3], sigs[:2])
+	recordParity(t, "http://"+ln.Addr().String(), dir, cases)
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, len(cases))
+	checkParity(t, handler, files)
+
+	// a missing field is a difference, unless it's ignored.
+	recorded := json.RawMessage(`{"blockTime":null,"transactions":[{"meta":{"fee":5000,"costUnits":1}},{"meta":{"fee":5000}}]}`)
+	got := json.RawMessage(`{"transactions":[{"meta":{"fee":5000}},{"meta":{"fee":5000}}],"blockTime":null}`)
+	require.NotEqual(t, canonicalParityJSON(t, recorded, nil), canonicalParityJSON(t, got, nil))
+	require.Equal(t, canonicalParityJSON(t, recorded, []string{"transactions.meta.costUnits"}), canonicalParityJSON(t, got, nil))
+	// null is not the same as a missing field.
+	require.NotEqual(t, canonicalParityJSON(t, recorded, nil), canonicalParityJSON(t, json.RawMessage(`{"transactions":[{"meta":{"fee":5000,"costUnits":1}},{"meta":{"fee":5000}}]}`), nil))
+}
+
+// checkParity sends the request of each recording to the handler, and compares the results.
+func checkParity(t *testing.T, handler fasthttp.RequestHandler, files []string) {
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			raw, err := os.ReadFile(file)
+			require.NoError(t, err)
+			var recording parityRecording
+			require.NoError(t, json.Unmarshal(raw, &recording))
+
+			body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": recording.Method, "params": recording.Params})
+			require.NoError(t, err)
+			var req fasthttp.Request
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.SetRequestURI("/")
+			req.SetBody(body)
+			var reqCtx fasthttp.RequestCtx
+			reqCtx.Init(&req, nil, nil)
+			handler(&reqCtx)
+			require.Equal(t, fasthttp.StatusOK, reqCtx.Response.StatusCode(), "%s", reqCtx.Response.Body())
+			var resp struct {
+				Result json.RawMessage `json:"result"`
+				Error  json.RawMessage `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(reqCtx.Response.Body(), &resp))
+			require.Empty(t, resp.Error)
+
+			require.Equal(t,
+				canonicalParityJSON(t, recording.Result, recording.Ignore),
+				canonicalParityJSON(t, resp.Result, recording.Ignore),
+			)
+		})
+	}
+}
+
+// canonicalParityJSON re-encodes the JSON value with sorted keys and indentation, without the ignored fields;
+// the numbers are kept as they are written.
+func canonicalParityJSON(t *testing.T, raw json.RawMessage, ignore []string) string {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var value any
+	require.NoError(t, dec.Decode(&value))
+	for _, path := range ignore {
+		dropParityField(value, strings.Split(path, "."))
+	}
+	out, err := json.MarshalIndent(value, "", "  ")
+	require.NoError(t, err)
+	return string(out)
+}
+
+func dropParityField(value any, path []string) {
+	switch v := value.(type) {
+	case []any:
+		for _, elem := range v {
+			dropParityField(elem, path)
+		}
+	case map[string]any:
+		if len(path) == 1 {
+			delete(v, path[0])
+			return
+		}
+		dropParityField(v[path[0]], path[1:])
+	}
+}
+
+// recordParity calls the reference RPC with each request, and writes the recordings;
+// the ignored fields of an existing recording are kept.
+func recordParity(t *testing.T, endpoint string, dir string, cases []parityCase) {
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	client := &http.Client{Timeout: time.Minute}
+	for _, c := range cases {
+		params, err := json.Marshal(c.params)
+		require.NoError(t, err)
+		body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": c.method, "params": json.RawMessage(params)})
+		require.NoError(t, err)
+		httpResp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		respBody, err := io.ReadAll(httpResp.Body)
+		httpResp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, httpResp.StatusCode, "%s: %s", c.name, respBody)
+		var resp struct {
+			Result json.RawMessage `json:"result"`
+			Error  json.RawMessage `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(respBody, &resp), c.name)
+		require.Empty(t, resp.Error, c.name)
+
+		recording := parityRecording{Method: c.method, Params: params, Result: resp.Result}
+		path := filepath.Join(dir, c.name+".json")
+		if existing, err := os.ReadFile(path); err == nil {
+			var previous parityRecording
+			require.NoError(t, json.Unmarshal(existing, &previous), path)
+			recording.Ignore = previous.Ignore
+		}
+		out, err := json.MarshalIndent(recording, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, append(out, '\n'), 0o644))
+		t.Logf("recorded %s", path)
+	}
+}
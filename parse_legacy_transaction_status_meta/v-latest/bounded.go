@@ -0,0 +1,34 @@
+package parse_legacy_transaction_status_meta_ce598c5c98e7384c104fe7f5121e32c2c5a2d2eb
+
+import (
+	"fmt"
+
+	"github.com/novifinancial/serde-reflection/serde-generate/runtime/golang/bincode"
+	"github.com/novifinancial/serde-reflection/serde-generate/runtime/golang/serde"
+)
+
+// boundedDeserializer is a bincode deserializer that rejects sequence lengths larger than
+// the remaining input: every element takes at least one byte, so such a length means the
+// input is corrupt, and allocating the sequence could exhaust the memory.
+type boundedDeserializer struct {
+	serde.Deserializer
+	inputLen uint64
+}
+
+func newBoundedDeserializer(input []byte) *boundedDeserializer {
+	return &boundedDeserializer{
+		Deserializer: bincode.NewDeserializer(input),
+		inputLen:     uint64(len(input)),
+	}
+}
+
+func (d *boundedDeserializer) DeserializeLen() (uint64, error) {
+	length, err := d.Deserializer.DeserializeLen()
+	if err != nil {
+		return 0, err
+	}
+	if remaining := d.inputLen - d.GetBufferOffset(); length > remaining {
+		return 0, fmt.Errorf("length %d is larger than the remaining input (%d bytes)", length, remaining)
+	}
+	return length, nil
+}
@@ -81,7 +81,7 @@ func BincodeDeserializeCompiledInstruction(input []byte) (CompiledInstruction, e
 		var obj CompiledInstruction
 		return obj, fmt.Errorf("Cannot deserialize null array")
 	}
-	deserializer := bincode.NewDeserializer(input)
+	deserializer := newBoundedDeserializer(input)
 	obj, err := DeserializeCompiledInstruction(deserializer)
 	if err == nil && deserializer.GetBufferOffset() < uint64(len(input)) {
 		return obj, fmt.Errorf("Some input bytes were not read")
@@ -143,7 +143,7 @@ func BincodeDeserializeInnerInstructions(input []byte) (InnerInstructions, error
 		var obj InnerInstructions
 		return obj, fmt.Errorf("Cannot deserialize null array")
 	}
-	deserializer := bincode.NewDeserializer(input)
+	deserializer := newBoundedDeserializer(input)
 	obj, err := DeserializeInnerInstructions(deserializer)
 	if err == nil && deserializer.GetBufferOffset() < uint64(len(input)) {
 		return obj, fmt.Errorf("Some input bytes were not read")
@@ -440,7 +440,7 @@ func BincodeDeserializeInstructionError(input []byte) (InstructionError, error)
 		var obj InstructionError
 		return obj, fmt.Errorf("Cannot deserialize null array")
 	}
-	deserializer := bincode.NewDeserializer(input)
+	deserializer := newBoundedDeserializer(input)
 	obj, err := DeserializeInstructionError(deserializer)
 	if err == nil && deserializer.GetBufferOffset() < uint64(len(input)) {
 		return obj, fmt.Errorf("Some input bytes were not read")
@@ -1747,7 +1747,7 @@ func BincodeDeserializeResult(input []byte) (Result, error) {
 		var obj Result
 		return obj, fmt.Errorf("Cannot deserialize null array")
 	}
-	deserializer := bincode.NewDeserializer(input)
+	deserializer := newBoundedDeserializer(input)
 	obj, err := DeserializeResult(deserializer)
 	if err == nil && deserializer.GetBufferOffset() < uint64(len(input)) {
 		return obj, fmt.Errorf("Some input bytes were not read")
@@ -1974,7 +1974,7 @@ func BincodeDeserializeTransactionError(input []byte) (TransactionError, error)
 		var obj TransactionError
 		return obj, fmt.Errorf("Cannot deserialize null array")
 	}
-	deserializer := bincode.NewDeserializer(input)
+	deserializer := newBoundedDeserializer(input)
 	obj, err := DeserializeTransactionError(deserializer)
 	if err == nil && deserializer.GetBufferOffset() < uint64(len(input)) {
 		return obj, fmt.Errorf("Some input bytes were not read")
@@ -2610,7 +2610,7 @@ func BincodeDeserializeTransactionStatusMeta(input []byte) (TransactionStatusMet
 		var obj TransactionStatusMeta
 		return obj, fmt.Errorf("Cannot deserialize null array")
 	}
-	deserializer := bincode.NewDeserializer(input)
+	deserializer := newBoundedDeserializer(input)
 	obj, err := DeserializeTransactionStatusMeta(deserializer)
 	if err == nil && deserializer.GetBufferOffset() < uint64(len(input)) {
 		// return obj, fmt.Errorf("Some input bytes were not read")
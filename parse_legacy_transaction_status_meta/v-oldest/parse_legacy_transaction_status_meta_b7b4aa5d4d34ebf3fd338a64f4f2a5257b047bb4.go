@@ -220,7 +220,7 @@ func BincodeDeserializeInstructionError(input []byte) (InstructionError, error)
 		var obj InstructionError
 		return obj, fmt.Errorf("Cannot deserialize null array")
 	}
-	deserializer := bincode.NewDeserializer(input)
+	deserializer := newBoundedDeserializer(input)
 	obj, err := DeserializeInstructionError(deserializer)
 	if err == nil && deserializer.GetBufferOffset() < uint64(len(input)) {
 		return obj, fmt.Errorf("Some input bytes were not read")
@@ -1164,7 +1164,7 @@ func BincodeDeserializeResult(input []byte) (Result, error) {
 		var obj Result
 		return obj, fmt.Errorf("Cannot deserialize null array")
 	}
-	deserializer := bincode.NewDeserializer(input)
+	deserializer := newBoundedDeserializer(input)
 	obj, err := DeserializeResult(deserializer)
 	if err == nil && deserializer.GetBufferOffset() < uint64(len(input)) {
 		return obj, fmt.Errorf("Some input bytes were not read")
@@ -1377,7 +1377,7 @@ func BincodeDeserializeTransactionError(input []byte) (TransactionError, error)
 		var obj TransactionError
 		return obj, fmt.Errorf("Cannot deserialize null array")
 	}
-	deserializer := bincode.NewDeserializer(input)
+	deserializer := newBoundedDeserializer(input)
 	obj, err := DeserializeTransactionError(deserializer)
 	if err == nil && deserializer.GetBufferOffset() < uint64(len(input)) {
 		return obj, fmt.Errorf("Some input bytes were not read")
@@ -1938,7 +1938,7 @@ func BincodeDeserializeTransactionStatusMeta(input []byte) (TransactionStatusMet
 		var obj TransactionStatusMeta
 		return obj, fmt.Errorf("Cannot deserialize null array")
 	}
-	deserializer := bincode.NewDeserializer(input)
+	deserializer := newBoundedDeserializer(input)
 	obj, err := DeserializeTransactionStatusMeta(deserializer)
 	if err == nil && deserializer.GetBufferOffset() < uint64(len(input)) {
 		// return obj, fmt.Errorf("Some input bytes were not read")
@@ -0,0 +1,192 @@
+// Package poh recomputes the proof-of-history hash chain of blocks from their
+// entries, which verifies that the entries (and the signatures of their
+// transactions) are the ones the cluster produced: the hash of the last entry
+// of a block is its blockhash, and the first entry of a block chains from the
+// blockhash of its parent.
+//
+// Unlike CID checks, this catches corruption that happened before the CAR was
+// created (e.g. in the ledger or the Bigtable rows it was made from).
+package poh
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+)
+
+// Entry is what the verification needs from an entry.
+type Entry struct {
+	NumHashes uint64
+	Hash      solana.Hash
+	// Signatures of all the transactions of the entry, in order.
+	Signatures []solana.Signature
+}
+
+// HashTransactions returns the root of the merkle tree of the signatures,
+// which is what an entry with transactions mixes into the PoH chain
+// (solana_entry::entry::hash_transactions).
+func HashTransactions(signatures []solana.Signature) solana.Hash {
+	if len(signatures) == 0 {
+		return solana.Hash{}
+	}
+	level := make([]solana.Hash, len(signatures))
+	for i := range signatures {
+		level[i] = hashv([]byte{0}, signatures[i][:])
+	}
+	for len(level) > 1 {
+		next := make([]solana.Hash, (len(level)+1)/2)
+		for i := range next {
+			left := level[2*i]
+			right := left
+			if 2*i+1 < len(level) {
+				right = level[2*i+1]
+			}
+			next[i] = hashv([]byte{1}, left[:], right[:])
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// NextHash returns the hash of an entry that follows start
+// (solana_entry::entry::next_hash): numHashes-1 plain hashes, then either a
+// tick (one more hash) or the mixin of its transactions.
+func NextHash(start solana.Hash, numHashes uint64, signatures []solana.Signature) solana.Hash {
+	if numHashes == 0 && len(signatures) == 0 {
+		return start
+	}
+	hash := start
+	for i := uint64(1); i < numHashes; i++ {
+		hash = sha256.Sum256(hash[:])
+	}
+	if len(signatures) == 0 {
+		return sha256.Sum256(hash[:])
+	}
+	mixin := HashTransactions(signatures)
+	return hashv(hash[:], mixin[:])
+}
+
+func hashv(parts ...[]byte) solana.Hash {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+	}
+	var out solana.Hash
+	h.Sum(out[:0])
+	return out
+}
+
+// MismatchError is returned when the recomputed hash of an entry is not the recorded one.
+type MismatchError struct {
+	Entry    int
+	Recorded solana.Hash
+	Computed solana.Hash
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("entry %d: recorded hash %s, computed %s", e.Entry, e.Recorded, e.Computed)
+}
+
+// VerifyEntries recomputes the hash chain of the entries of a block, starting
+// from the blockhash of its parent, and returns the blockhash (the hash of the
+// last entry). If start is nil, the hash of the first entry can't be verified
+// and the chain starts from it.
+func VerifyEntries(start *solana.Hash, entries []Entry) (solana.Hash, error) {
+	if len(entries) == 0 {
+		return solana.Hash{}, errors.New("no entries")
+	}
+	var hash solana.Hash
+	first := 0
+	if start != nil {
+		hash = *start
+	} else {
+		hash = entries[0].Hash
+		first = 1
+	}
+	for i := first; i < len(entries); i++ {
+		computed := NextHash(hash, entries[i].NumHashes, entries[i].Signatures)
+		if computed != entries[i].Hash {
+			return solana.Hash{}, &MismatchError{Entry: i, Recorded: entries[i].Hash, Computed: computed}
+		}
+		hash = computed
+	}
+	return hash, nil
+}
+
+// Signatures reads the signatures at the start of a serialized transaction.
+func Signatures(txData []byte) ([]solana.Signature, error) {
+	decoder := bin.NewCompactU16Decoder(txData)
+	numSigs, err := decoder.ReadCompactU16()
+	if err != nil {
+		return nil, err
+	}
+	if decoder.Remaining() < numSigs*solana.SignatureLength {
+		return nil, fmt.Errorf("not enough bytes left to read %d signatures", numSigs)
+	}
+	sigs := make([]solana.Signature, numSigs)
+	for i := range sigs {
+		if _, err := decoder.Read(sigs[i][:]); err != nil {
+			return nil, err
+		}
+	}
+	return sigs, nil
+}
+
+// Verifier verifies consecutive blocks, chaining each one from the blockhash
+// of its parent when the parent was verified before.
+type Verifier struct {
+	lastSlot uint64
+	last     *solana.Hash
+}
+
+// SetParent sets the blockhash of the block before the first one to verify
+// (e.g. the last block of the previous epoch, or the genesis hash for slot 0).
+func (v *Verifier) SetParent(slot uint64, blockhash solana.Hash) {
+	v.lastSlot = slot
+	v.last = &blockhash
+}
+
+// BlockResult is the outcome of the verification of a block.
+type BlockResult struct {
+	Blockhash solana.Hash
+	// Chained is true if the first entry was verified against the blockhash of
+	// the parent; false if the parent was unknown.
+	Chained bool
+}
+
+// VerifyBlock verifies the entries of a block; blocks must be passed in slot order.
+// If the block doesn't verify, the next block can't be chained from it.
+func (v *Verifier) VerifyBlock(slot, parentSlot uint64, entries []Entry) (*BlockResult, error) {
+	var start *solana.Hash
+	if v.last != nil && v.lastSlot == parentSlot {
+		start = v.last
+	}
+	v.last = nil
+	blockhash, err := VerifyEntries(start, entries)
+	if err != nil {
+		return nil, err
+	}
+	v.lastSlot = slot
+	v.last = &blockhash
+	return &BlockResult{Blockhash: blockhash, Chained: start != nil}, nil
+}
+
+// BlockEntries returns the entries of a block read by a carwriter.BlockSource.
+func BlockEntries(block *carwriter.Block) ([]Entry, error) {
+	entries := make([]Entry, len(block.Entries))
+	for i, entry := range block.Entries {
+		entries[i] = Entry{NumHashes: entry.NumHashes, Hash: entry.Hash}
+		for j, tx := range entry.Transactions {
+			sigs, err := Signatures(tx.Data)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d, transaction %d: %w", i, j, err)
+			}
+			entries[i].Signatures = append(entries[i].Signatures, sigs...)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,136 @@
+package poh
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/stretchr/testify/require"
+)
+
+// mainnetGenesisHash is the hash the first entry of slot 0 chains from.
+var mainnetGenesisHash = solana.MustHashFromBase58("5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d")
+
+func fixtureBlocks(t *testing.T) []*carwriter.Block {
+	var blocks []*carwriter.Block
+	err := carwriter.NewCarSource("../fixtures/epoch-0-1.car").Blocks(context.Background(), 0, math.MaxUint64, func(b *carwriter.Block) error {
+		blocks = append(blocks, b)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, blocks, 10)
+	return blocks
+}
+
+func TestVerifierFixture(t *testing.T) {
+	var v Verifier
+	v.SetParent(0, mainnetGenesisHash)
+	numTransactions := 0
+	for _, block := range fixtureBlocks(t) {
+		entries, err := BlockEntries(block)
+		require.NoError(t, err)
+		for _, e := range entries {
+			numTransactions += len(e.Signatures)
+		}
+		res, err := v.VerifyBlock(block.Slot, block.ParentSlot, entries)
+		require.NoError(t, err, "slot %d", block.Slot)
+		require.True(t, res.Chained, "slot %d", block.Slot)
+		require.Equal(t, entries[len(entries)-1].Hash, res.Blockhash)
+	}
+	require.NotZero(t, numTransactions)
+}
+
+func TestVerifierWithoutParent(t *testing.T) {
+	blocks := fixtureBlocks(t)
+	var v Verifier
+	for i, block := range blocks[3:] {
+		entries, err := BlockEntries(block)
+		require.NoError(t, err)
+		res, err := v.VerifyBlock(block.Slot, block.ParentSlot, entries)
+		require.NoError(t, err)
+		// only the first block can't be chained.
+		require.Equal(t, i > 0, res.Chained)
+	}
+}
+
+func TestVerifierDetectsCorruption(t *testing.T) {
+	blocks := fixtureBlocks(t)
+	var withTxs *carwriter.Block
+	for _, block := range blocks {
+		for _, e := range block.Entries {
+			if len(e.Transactions) > 0 {
+				withTxs = block
+			}
+		}
+	}
+	require.NotNil(t, withTxs)
+	entries, err := BlockEntries(withTxs)
+	require.NoError(t, err)
+	_, err = VerifyEntries(nil, entries)
+	require.NoError(t, err)
+
+	t.Run("signature", func(t *testing.T) {
+		corrupted := cloneEntries(entries)
+		for i := range corrupted {
+			if len(corrupted[i].Signatures) > 0 {
+				corrupted[i].Signatures[0][10] ^= 1
+				_, err := VerifyEntries(nil, corrupted)
+				var mismatch *MismatchError
+				require.True(t, errors.As(err, &mismatch))
+				require.Equal(t, i, mismatch.Entry)
+				require.Equal(t, entries[i].Hash, mismatch.Recorded)
+				return
+			}
+		}
+	})
+	t.Run("num hashes", func(t *testing.T) {
+		corrupted := cloneEntries(entries)
+		corrupted[5].NumHashes++
+		_, err := VerifyEntries(nil, corrupted)
+		var mismatch *MismatchError
+		require.True(t, errors.As(err, &mismatch))
+		require.Equal(t, 5, mismatch.Entry)
+	})
+	t.Run("parent", func(t *testing.T) {
+		wrong := solana.Hash{1}
+		_, err := VerifyEntries(&wrong, entries)
+		var mismatch *MismatchError
+		require.True(t, errors.As(err, &mismatch))
+		require.Equal(t, 0, mismatch.Entry)
+	})
+}
+
+func cloneEntries(entries []Entry) []Entry {
+	out := make([]Entry, len(entries))
+	for i, e := range entries {
+		out[i] = e
+		out[i].Signatures = append([]solana.Signature(nil), e.Signatures...)
+	}
+	return out
+}
+
+func TestHashTransactions(t *testing.T) {
+	require.Equal(t, solana.Hash{}, HashTransactions(nil))
+
+	sigs := []solana.Signature{{1}, {2}, {3}}
+	leaf := func(s solana.Signature) [32]byte { return sha256.Sum256(append([]byte{0}, s[:]...)) }
+	node := func(l, r [32]byte) [32]byte { return sha256.Sum256(append(append([]byte{1}, l[:]...), r[:]...)) }
+	require.Equal(t, solana.Hash(leaf(sigs[0])), HashTransactions(sigs[:1]))
+	// an odd node is paired with itself.
+	l0, l1, l2 := leaf(sigs[0]), leaf(sigs[1]), leaf(sigs[2])
+	require.Equal(t, solana.Hash(node(node(l0, l1), node(l2, l2))), HashTransactions(sigs))
+}
+
+func TestNextHash(t *testing.T) {
+	start := solana.Hash{7}
+	require.Equal(t, start, NextHash(start, 0, nil))
+	h1 := sha256.Sum256(start[:])
+	h2 := sha256.Sum256(h1[:])
+	require.Equal(t, solana.Hash(h2), NextHash(start, 2, nil))
+	mixin := HashTransactions([]solana.Signature{{1}})
+	require.Equal(t, solana.Hash(sha256.Sum256(append(h1[:], mixin[:]...))), NextHash(start, 2, []solana.Signature{{1}}))
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	rpprof "runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// ProfilingConfig is the profiling and runtime tuning of the rpc server.
+type ProfilingConfig struct {
+	// PprofListenOn is the address of the pprof and expvar endpoints (/debug/pprof/ and /debug/vars);
+	// empty disables them.
+	PprofListenOn string
+	// MutexProfileFraction and BlockProfileRate enable the mutex and block profiles (see
+	// runtime.SetMutexProfileFraction and runtime.SetBlockProfileRate); zero leaves them off.
+	MutexProfileFraction int
+	BlockProfileRate     int
+	// MemoryLimit is the soft memory limit of the Go runtime in bytes (like GOMEMLIMIT); zero leaves it unchanged.
+	MemoryLimit int64
+	// GCPercent is the GC target percentage (like GOGC; negative disables the GC); nil leaves it unchanged.
+	GCPercent *int
+	// HeapProfileDir is where heap profiles are written when the heap in use goes over
+	// HeapProfileThreshold bytes; empty disables them.
+	HeapProfileDir       string
+	HeapProfileThreshold uint64
+}
+
+const (
+	// heapCheckInterval is how often the heap in use is checked against the threshold.
+	heapCheckInterval = 10 * time.Second
+	// heapProfileCooldown is the minimum time between two heap profiles.
+	heapProfileCooldown = 10 * time.Minute
+	// heapProfilesKept is how many heap profiles are kept in the directory; the oldest are removed.
+	heapProfilesKept = 10
+)
+
+func (cfg *ProfilingConfig) Validate() error {
+	if cfg.MutexProfileFraction < 0 {
+		return fmt.Errorf("mutex profile fraction must not be negative, got %d", cfg.MutexProfileFraction)
+	}
+	if cfg.BlockProfileRate < 0 {
+		return fmt.Errorf("block profile rate must not be negative, got %d", cfg.BlockProfileRate)
+	}
+	if cfg.MemoryLimit < 0 {
+		return fmt.Errorf("memory limit must not be negative, got %d", cfg.MemoryLimit)
+	}
+	if cfg.HeapProfileDir != "" && cfg.HeapProfileThreshold == 0 {
+		return errors.New("a heap profile threshold is required with a heap profile directory")
+	}
+	return nil
+}
+
+// Apply sets the runtime settings of the config.
+func (cfg *ProfilingConfig) Apply() {
+	if cfg.MutexProfileFraction > 0 {
+		runtime.SetMutexProfileFraction(cfg.MutexProfileFraction)
+		klog.Infof("Mutex profile enabled (fraction 1/%d)", cfg.MutexProfileFraction)
+	}
+	if cfg.BlockProfileRate > 0 {
+		runtime.SetBlockProfileRate(cfg.BlockProfileRate)
+		klog.Infof("Block profile enabled (rate %d ns)", cfg.BlockProfileRate)
+	}
+	if cfg.MemoryLimit > 0 {
+		debug.SetMemoryLimit(cfg.MemoryLimit)
+		klog.Infof("Memory limit set to %d bytes", cfg.MemoryLimit)
+	}
+	if cfg.GCPercent != nil {
+		debug.SetGCPercent(*cfg.GCPercent)
+		klog.Infof("GC percent set to %d", *cfg.GCPercent)
+	}
+}
+
+// Run serves the pprof endpoints and watches the heap, as configured, until ctx is done.
+func (cfg *ProfilingConfig) Run(ctx context.Context) error {
+	if cfg.HeapProfileDir != "" {
+		if err := os.MkdirAll(cfg.HeapProfileDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create heap profile dir: %w", err)
+		}
+		go watchHeap(ctx, cfg.HeapProfileDir, cfg.HeapProfileThreshold, heapCheckInterval)
+	}
+	if cfg.PprofListenOn == "" {
+		return nil
+	}
+	return listenAndServePprof(ctx, cfg.PprofListenOn)
+}
+
+func newPprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	// expvar, with the pool stats.
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// listenAndServePprof serves the pprof and expvar endpoints on their own listener, so that they
+// are not exposed with the RPC API.
+func listenAndServePprof(ctx context.Context, listenOn string) error {
+	ln, err := net.Listen("tcp", listenOn)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", listenOn, err)
+	}
+	server := &http.Server{
+		Handler:           newPprofMux(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	klog.Infof("pprof endpoints listening on http://%s/debug/pprof/", ln.Addr())
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// watchHeap writes a heap profile in dir when the heap in use goes over threshold bytes,
+// at most once per heapProfileCooldown, until ctx is done.
+func watchHeap(ctx context.Context, dir string, threshold uint64, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastProfile time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.HeapInuse < threshold || time.Since(lastProfile) < heapProfileCooldown {
+			continue
+		}
+		lastProfile = time.Now()
+		path, err := writeHeapProfile(dir, lastProfile)
+		if err != nil {
+			klog.Errorf("failed to write heap profile: %s", err)
+			continue
+		}
+		klog.Warningf("Heap in use is %d bytes (threshold %d): wrote heap profile %s", stats.HeapInuse, threshold, path)
+	}
+}
+
+// writeHeapProfile writes a heap profile in dir, and removes the oldest ones beyond heapProfilesKept.
+func writeHeapProfile(dir string, now time.Time) (string, error) {
+	path := filepath.Join(dir, "heap-"+now.UTC().Format("20060102T150405Z")+".pb.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := rpprof.Lookup("heap").WriteTo(file, 0); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return path, nil
+	}
+	var profiles []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "heap-") && strings.HasSuffix(entry.Name(), ".pb.gz") {
+			profiles = append(profiles, entry.Name())
+		}
+	}
+	// the names sort by time.
+	sort.Strings(profiles)
+	for len(profiles) > heapProfilesKept {
+		os.Remove(filepath.Join(dir, profiles[0]))
+		profiles = profiles[1:]
+	}
+	return path, nil
+}
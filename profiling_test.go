@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfilingConfigValidate(t *testing.T) {
+	require.NoError(t, (&ProfilingConfig{}).Validate())
+	require.NoError(t, (&ProfilingConfig{HeapProfileDir: "/tmp", HeapProfileThreshold: 1}).Validate())
+	require.Error(t, (&ProfilingConfig{HeapProfileDir: "/tmp"}).Validate())
+	require.Error(t, (&ProfilingConfig{MutexProfileFraction: -1}).Validate())
+	require.Error(t, (&ProfilingConfig{MemoryLimit: -1}).Validate())
+}
+
+func TestPprofMux(t *testing.T) {
+	server := httptest.NewServer(newPprofMux())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/debug/pprof/goroutine?debug=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "goroutine profile")
+
+	resp, err = http.Get(server.URL + "/debug/vars")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), `"pools"`)
+}
+
+func TestWriteHeapProfile(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var paths []string
+	for i := 0; i < heapProfilesKept+2; i++ {
+		path, err := writeHeapProfile(dir, start.Add(time.Duration(i)*time.Hour))
+		require.NoError(t, err)
+		paths = append(paths, path)
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, heapProfilesKept)
+	// the oldest ones are removed.
+	require.NoFileExists(t, paths[0])
+	require.NoFileExists(t, paths[1])
+	require.FileExists(t, paths[len(paths)-1])
+}
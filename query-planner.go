@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rpcpool/yellowstone-faithful/metrics"
+)
+
+// queryTask is the part of a multi-epoch query that runs on one epoch.
+// run can be called twice concurrently when the task is hedged.
+type queryTask[T any] struct {
+	epoch  uint64
+	remote bool
+	run    func(ctx context.Context) (T, error)
+}
+
+type queryPlanOptions struct {
+	// concurrency is how many tasks run at the same time; zero or negative for no limit.
+	concurrency int
+	// hedgeDelay is how long a task of a remote epoch runs before a second attempt is started;
+	// zero disables hedging.
+	hedgeDelay time.Duration
+}
+
+func (multi *MultiEpoch) queryPlanOptions() queryPlanOptions {
+	if multi.options == nil {
+		return queryPlanOptions{}
+	}
+	return queryPlanOptions{
+		concurrency: multi.options.EpochSearchConcurrency,
+		hedgeDelay:  multi.options.HedgeDelay,
+	}
+}
+
+// runQueryPlan runs the tasks of a query that spans several epochs concurrently, starting them in
+// order, and passes their results to merge in the order of the tasks. When merge returns true,
+// the query has all its results: the tasks still running are canceled, and the others are not
+// started. The first task that fails fails the query. The tasks are all done when runQueryPlan
+// returns, so that the caller can release the epochs they use.
+func runQueryPlan[T any](ctx context.Context, opts queryPlanOptions, tasks []queryTask[T], merge func(T) bool) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	// the tasks still running are canceled, then waited for.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		index int
+		val   T
+		err   error
+	}
+	// buffered, so that the tasks finish even after the query has its results.
+	results := make(chan result, len(tasks))
+	concurrency := opts.concurrency
+	if concurrency <= 0 || concurrency > len(tasks) {
+		concurrency = len(tasks)
+	}
+	slots := make(chan struct{}, concurrency)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range tasks {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				val, err := runHedged(ctx, opts.hedgeDelay, tasks[i])
+				results <- result{index: i, val: val, err: err}
+			}(i)
+		}
+	}()
+
+	done := make([]*result, len(tasks))
+	next := 0
+	for next < len(tasks) {
+		select {
+		case res := <-results:
+			if res.err != nil {
+				return fmt.Errorf("epoch %d: %w", tasks[res.index].epoch, res.err)
+			}
+			done[res.index] = &res
+			for next < len(tasks) && done[next] != nil {
+				if merge(done[next].val) {
+					return nil
+				}
+				done[next] = nil
+				next++
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// runHedged runs a task; if the task is on a remote epoch and it takes longer than hedgeDelay,
+// a second attempt is started, and the first one to succeed wins (the other is canceled).
+func runHedged[T any](ctx context.Context, hedgeDelay time.Duration, task queryTask[T]) (T, error) {
+	if hedgeDelay <= 0 || !task.remote {
+		return runQueryTask(ctx, task)
+	}
+	// the other attempt is canceled, then waited for.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type attempt struct {
+		val T
+		err error
+	}
+	attempts := make(chan attempt, 2)
+	start := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := runQueryTask(ctx, task)
+			attempts <- attempt{val: val, err: err}
+		}()
+	}
+	start()
+	timer := time.NewTimer(hedgeDelay)
+	defer timer.Stop()
+	hedged := false
+	failed := 0
+	for {
+		select {
+		case <-timer.C:
+			hedged = true
+			metrics.HedgedEpochQueries.Inc()
+			start()
+		case a := <-attempts:
+			if a.err == nil {
+				return a.val, nil
+			}
+			// a failure is not hedged, but the other attempt (if any) can still succeed.
+			failed++
+			if !hedged || failed == 2 {
+				return a.val, a.err
+			}
+		}
+	}
+}
+
+func runQueryTask[T any](ctx context.Context, task queryTask[T]) (val T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoveredPanic("job", "queryPlan", r)
+		}
+	}()
+	return task.run(ctx)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunQueryPlanMergesInOrder(t *testing.T) {
+	var tasks []queryTask[int]
+	for i := 0; i < 5; i++ {
+		i := i
+		tasks = append(tasks, queryTask[int]{
+			epoch: uint64(i),
+			run: func(ctx context.Context) (int, error) {
+				// the first tasks finish last.
+				time.Sleep(time.Duration(5-i) * 10 * time.Millisecond)
+				return i, nil
+			},
+		})
+	}
+	var merged []int
+	err := runQueryPlan(context.Background(), queryPlanOptions{concurrency: 2}, tasks, func(v int) bool {
+		merged = append(merged, v)
+		return false
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2, 3, 4}, merged)
+}
+
+func TestRunQueryPlanCancelsLosers(t *testing.T) {
+	var canceled, started atomic.Int32
+	tasks := []queryTask[int]{
+		{epoch: 0, run: func(ctx context.Context) (int, error) { return 0, nil }},
+	}
+	for i := 1; i < 4; i++ {
+		tasks = append(tasks, queryTask[int]{
+			epoch: uint64(i),
+			run: func(ctx context.Context) (int, error) {
+				started.Add(1)
+				<-ctx.Done()
+				canceled.Add(1)
+				return 0, ctx.Err()
+			},
+		})
+	}
+	var merged []int
+	err := runQueryPlan(context.Background(), queryPlanOptions{}, tasks, func(v int) bool {
+		merged = append(merged, v)
+		return true
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{0}, merged)
+	// the tasks still running are done when the query returns.
+	require.Equal(t, started.Load(), canceled.Load())
+
+	// the first error fails the query.
+	tasks[0].run = func(ctx context.Context) (int, error) { return 0, errors.New("boom") }
+	err = runQueryPlan(context.Background(), queryPlanOptions{}, tasks, func(v int) bool { return false })
+	require.ErrorContains(t, err, "epoch 0: boom")
+
+	// so does a panic.
+	tasks[0].run = func(ctx context.Context) (int, error) { panic("boom") }
+	err = runQueryPlan(context.Background(), queryPlanOptions{}, tasks, func(v int) bool { return false })
+	require.ErrorContains(t, err, "epoch 0")
+}
+
+func TestRunQueryPlanHedgesRemoteTasks(t *testing.T) {
+	// the first attempt hangs until it's canceled; the hedged one answers.
+	newTask := func(remote bool, attempts *atomic.Int32, firstCanceled chan struct{}) queryTask[int] {
+		return queryTask[int]{
+			epoch:  7,
+			remote: remote,
+			run: func(ctx context.Context) (int, error) {
+				if attempts.Add(1) == 1 {
+					select {
+					case <-ctx.Done():
+						close(firstCanceled)
+						return 0, ctx.Err()
+					case <-time.After(time.Second):
+						return 1, nil
+					}
+				}
+				return 2, nil
+			},
+		}
+	}
+
+	var attempts atomic.Int32
+	firstCanceled := make(chan struct{})
+	var got int
+	err := runQueryPlan(context.Background(), queryPlanOptions{hedgeDelay: 10 * time.Millisecond},
+		[]queryTask[int]{newTask(true, &attempts, firstCanceled)},
+		func(v int) bool { got = v; return false })
+	require.NoError(t, err)
+	require.Equal(t, 2, got)
+	require.EqualValues(t, 2, attempts.Load())
+	// the first attempt is canceled, and done when the query returns.
+	select {
+	case <-firstCanceled:
+	default:
+		t.Fatal("the first attempt was not canceled")
+	}
+
+	// the local tasks are not hedged.
+	attempts.Store(0)
+	err = runQueryPlan(context.Background(), queryPlanOptions{hedgeDelay: 10 * time.Millisecond},
+		[]queryTask[int]{newTask(false, &attempts, make(chan struct{}))},
+		func(v int) bool { got = v; return false })
+	require.NoError(t, err)
+	require.Equal(t, 1, got)
+	require.EqualValues(t, 1, attempts.Load())
+}
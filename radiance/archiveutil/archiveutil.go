@@ -9,12 +9,13 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
+
+	"github.com/klauspost/compress/zstd"
 )
 
-// TODO: zstd support
 // TODO: xz support
 
-// OpenTar opens a `.tar`, `.tar.gz`, or `.tar.bz2` file.
+// OpenTar opens a `.tar`, `.tar.gz`, `.tar.bz2`, or `.tar.zst` file.
 //
 // Peeks the first few bytes in the given reader and auto-detects the file format.
 // Returns a tar reader spliced together with a decompressor if necessary.
@@ -36,8 +37,12 @@ func OpenTar(rawRd io.Reader) (*tar.Reader, error) {
 		}
 	} else if bytes.Equal(magicBytes[:6], []byte{0xfd, 0x37, 0x7a, 0x58, 0x5a, 0x00}) {
 		return nil, fmt.Errorf(".tar.xz not supported yet")
-	} else if bytes.Equal(magicBytes[1:4], []byte{0xb5, 0x2f, 0xfd}) {
-		return nil, fmt.Errorf(".tar.zst not supported yet")
+	} else if bytes.Equal(magicBytes[:4], []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		// A single decoding goroutine decodes synchronously, so the decoder needs no Close.
+		uncompressedRd, err = zstd.NewReader(rd, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid .tar.zst: %w", err)
+		}
 	} else {
 		// Presumed uncompressed case.
 		// Peek and see if we can find a valid tar header.
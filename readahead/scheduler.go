@@ -0,0 +1,372 @@
+package readahead
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ReaderAtCloser is a file read at offsets (i.e. a remote CAR).
+type ReaderAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// Pattern is how a Scheduler classified a read.
+type Pattern string
+
+const (
+	PatternSequential Pattern = "sequential"
+	PatternRandom     Pattern = "random"
+)
+
+const (
+	DefaultReadAheadSize   = 8 * MiB
+	DefaultBlockSize       = 64 * KiB
+	DefaultCacheSize       = 64 * MiB
+	DefaultSequentialAfter = 4
+	DefaultMaxStreams      = 8
+)
+
+// maxCachedBlocks is the number of blocks over which a random read is not cached: it's read as is.
+const maxCachedBlocks = 4
+
+// SchedulerOptions are the options of a Scheduler; the zero values are replaced by the defaults.
+type SchedulerOptions struct {
+	// ReadAheadSize is the size of the windows read ahead of a sequential stream.
+	ReadAheadSize int64
+	// BlockSize is the alignment of the random reads, and the unit of their cache.
+	BlockSize int64
+	// CacheSize is the memory of the blocks cached for the random reads.
+	CacheSize int64
+	// SequentialAfter is the number of contiguous reads after which a stream is sequential.
+	SequentialAfter int
+	// MaxStreams is the number of streams tracked at once.
+	MaxStreams int
+	// OnRead, if set, is called after each read with how it was classified, and whether it was
+	// served from memory (read ahead or cached) without waiting for the file.
+	OnRead func(pattern Pattern, fromMemory bool)
+}
+
+// Scheduler reads a file according to how it's being read, so that the sequential streams (i.e.
+// the replay of blocks) and the random reads (i.e. RPC) of the same file both perform well:
+//   - the reads are grouped in streams: a read that starts where a recent read ended (give or take
+//     a block) continues its stream, and a stream is sequential after SequentialAfter reads;
+//   - a sequential stream is served from windows of ReadAheadSize read ahead of it, the next window
+//     being read in the background while the stream consumes the current one;
+//   - a random read is aligned to BlockSize, and the blocks are cached (LRU, up to CacheSize), so
+//     that the reads of nearby nodes (i.e. the size of a node, then the node) make one request.
+//
+// The random reads only start new streams, which are evicted before the sequential streams, so the
+// streams survive the random reads interleaved with them.
+//
+// The memory of a Scheduler is bounded by CacheSize, plus the windows of the streams: a stream has
+// the windows its last read overlaps and one window ahead, i.e. 2 windows of ReadAheadSize (more
+// for the reads larger than ReadAheadSize), so MaxStreams*2*ReadAheadSize in all. The windows of an
+// evicted stream are freed once their reads in flight are done.
+type Scheduler struct {
+	file ReaderAtCloser
+	opts SchedulerOptions
+
+	mu      sync.Mutex
+	streams []*stream // most recently used first
+	blocks  map[int64]*list.Element
+	lru     *list.List // of *cachedBlock; front is the most recently used
+	cached  int64
+	closed  bool
+	// windows tracks the windows being read, which Close waits for.
+	windows sync.WaitGroup
+}
+
+type stream struct {
+	start   int64 // where the last read of the stream started
+	next    int64 // where the last read of the stream ended
+	reads   int
+	windows []*window // contiguous and in order
+}
+
+type window struct {
+	off  int64
+	size int64
+	done chan struct{}
+	// set when done is closed; data is shorter than size at the end of the file.
+	data []byte
+	err  error
+}
+
+type cachedBlock struct {
+	index int64
+	data  []byte
+}
+
+// NewScheduler returns a Scheduler that reads the file.
+func NewScheduler(file ReaderAtCloser, opts SchedulerOptions) *Scheduler {
+	if opts.ReadAheadSize <= 0 {
+		opts.ReadAheadSize = DefaultReadAheadSize
+	}
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = DefaultBlockSize
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = DefaultCacheSize
+	}
+	if opts.SequentialAfter <= 0 {
+		opts.SequentialAfter = DefaultSequentialAfter
+	}
+	if opts.MaxStreams <= 0 {
+		opts.MaxStreams = DefaultMaxStreams
+	}
+	return &Scheduler{
+		file:   file,
+		opts:   opts,
+		blocks: make(map[int64]*list.Element),
+		lru:    list.New(),
+	}
+}
+
+// Unwrap returns the file read by the scheduler.
+func (s *Scheduler) Unwrap() ReaderAtCloser {
+	return s.file
+}
+
+// Close closes the file, once the windows being read are done; the windows aren't read ahead anymore.
+func (s *Scheduler) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.streams = nil
+	s.mu.Unlock()
+	s.windows.Wait()
+	return s.file.Close()
+}
+
+func (s *Scheduler) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	end := off + int64(len(p))
+
+	s.mu.Lock()
+	st := s.streamOf(off, end)
+	var windows []*window
+	pattern := PatternRandom
+	if st.reads >= s.opts.SequentialAfter {
+		pattern = PatternSequential
+		windows = s.scheduleWindows(st, off, end)
+	}
+	s.mu.Unlock()
+
+	var n int
+	var fromMemory bool
+	var err error
+	switch {
+	case pattern == PatternSequential:
+		n, fromMemory, err = readWindows(p, off, windows)
+		if err != nil {
+			// read ahead again on the next read.
+			s.mu.Lock()
+			st.windows = nil
+			s.mu.Unlock()
+			n, err = s.file.ReadAt(p, off)
+		}
+	case int64(len(p)) > maxCachedBlocks*s.opts.BlockSize:
+		n, err = s.file.ReadAt(p, off)
+	default:
+		n, fromMemory, err = s.readBlocks(p, off)
+	}
+	if s.opts.OnRead != nil {
+		s.opts.OnRead(pattern, fromMemory)
+	}
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// streamOf returns the stream that the read continues, or a new stream.
+func (s *Scheduler) streamOf(off, end int64) *stream {
+	for i, st := range s.streams {
+		if off < st.start || off > st.next+s.opts.BlockSize {
+			continue
+		}
+		st.reads++
+		st.start = off
+		st.next = max(st.next, end)
+		copy(s.streams[1:i+1], s.streams[:i])
+		s.streams[0] = st
+		return st
+	}
+	if len(s.streams) >= s.opts.MaxStreams {
+		// evict the least recently used stream, sparing the sequential ones if possible.
+		evict := len(s.streams) - 1
+		for i := len(s.streams) - 1; i >= 0; i-- {
+			if s.streams[i].reads < s.opts.SequentialAfter {
+				evict = i
+				break
+			}
+		}
+		s.streams = append(s.streams[:evict], s.streams[evict+1:]...)
+	}
+	st := &stream{start: off, next: end, reads: 1}
+	s.streams = append([]*stream{st}, s.streams...)
+	return st
+}
+
+// scheduleWindows makes the windows of the stream cover the read and one more window ahead of it,
+// and returns the windows that the read overlaps.
+func (s *Scheduler) scheduleWindows(st *stream, off, end int64) []*window {
+	for len(st.windows) > 0 && st.windows[0].off+st.windows[0].size <= off {
+		st.windows = st.windows[1:]
+	}
+	if len(st.windows) == 0 || st.windows[0].off > off {
+		st.windows = []*window{s.startWindow(off, max(s.opts.ReadAheadSize, end-off))}
+	}
+	for {
+		last := st.windows[len(st.windows)-1]
+		if last.off+last.size >= end+s.opts.ReadAheadSize || last.atEOF() {
+			break
+		}
+		st.windows = append(st.windows, s.startWindow(last.off+last.size, s.opts.ReadAheadSize))
+	}
+	overlapping := make([]*window, 0, 2)
+	for _, w := range st.windows {
+		if w.off < end && w.off+w.size > off {
+			overlapping = append(overlapping, w)
+		}
+	}
+	return overlapping
+}
+
+// ErrClosed is the error of the windows read ahead after the Scheduler was closed.
+var ErrClosed = errors.New("scheduler closed")
+
+// startWindow reads the window in the background; s.mu must be held.
+func (s *Scheduler) startWindow(off, size int64) *window {
+	w := &window{off: off, size: size, done: make(chan struct{})}
+	if s.closed {
+		w.err = ErrClosed
+		close(w.done)
+		return w
+	}
+	s.windows.Add(1)
+	go func() {
+		defer s.windows.Done()
+		defer close(w.done)
+		data := make([]byte, size)
+		n, err := s.file.ReadAt(data, off)
+		if err != nil && !errors.Is(err, io.EOF) {
+			w.err = err
+			return
+		}
+		w.data = data[:n]
+	}()
+	return w
+}
+
+// atEOF tells whether the window was read and reached the end of the file.
+func (w *window) atEOF() bool {
+	select {
+	case <-w.done:
+		return w.err == nil && int64(len(w.data)) < w.size
+	default:
+		return false
+	}
+}
+
+func readWindows(p []byte, off int64, windows []*window) (int, bool, error) {
+	fromMemory := true
+	n := 0
+	for _, w := range windows {
+		select {
+		case <-w.done:
+		default:
+			fromMemory = false
+			<-w.done
+		}
+		if w.err != nil {
+			return 0, false, w.err
+		}
+		n += copyAt(p, off, w.off, w.data)
+	}
+	return n, fromMemory, nil
+}
+
+// readBlocks reads the aligned blocks that the read overlaps, from the cache or else from the file
+// with one request.
+func (s *Scheduler) readBlocks(p []byte, off int64) (int, bool, error) {
+	bs := s.opts.BlockSize
+	first, last := off/bs, (off+int64(len(p))-1)/bs
+	blocks := make([][]byte, 0, last-first+1)
+	s.mu.Lock()
+	for i := first; i <= last; i++ {
+		el, ok := s.blocks[i]
+		if !ok {
+			blocks = nil
+			break
+		}
+		s.lru.MoveToFront(el)
+		blocks = append(blocks, el.Value.(*cachedBlock).data)
+	}
+	s.mu.Unlock()
+	fromMemory := blocks != nil
+
+	if !fromMemory {
+		buf := make([]byte, (last-first+1)*bs)
+		n, err := s.file.ReadAt(buf, first*bs)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, false, err
+		}
+		buf = buf[:n]
+		s.mu.Lock()
+		for i := first; i <= last; i++ {
+			from := (i - first) * bs
+			if from >= int64(len(buf)) {
+				break
+			}
+			data := buf[from:min(from+bs, int64(len(buf)))]
+			blocks = append(blocks, data)
+			s.putBlock(i, data)
+		}
+		s.mu.Unlock()
+	}
+	n := 0
+	for i, data := range blocks {
+		n += copyAt(p, off, (first+int64(i))*bs, data)
+	}
+	return n, fromMemory, nil
+}
+
+func (s *Scheduler) putBlock(index int64, data []byte) {
+	if el, ok := s.blocks[index]; ok {
+		// read concurrently by another request; same content.
+		s.lru.MoveToFront(el)
+		return
+	}
+	s.blocks[index] = s.lru.PushFront(&cachedBlock{index: index, data: data})
+	s.cached += int64(len(data))
+	for s.cached > s.opts.CacheSize {
+		el := s.lru.Back()
+		block := el.Value.(*cachedBlock)
+		s.lru.Remove(el)
+		delete(s.blocks, block.index)
+		s.cached -= int64(len(block.data))
+	}
+}
+
+// copyAt copies the part of data (which starts at dataOff in the file) that overlaps p (which
+// starts at off in the file).
+func copyAt(p []byte, off int64, dataOff int64, data []byte) int {
+	if dataOff >= off {
+		if dataOff-off >= int64(len(p)) {
+			return 0
+		}
+		return copy(p[dataOff-off:], data)
+	}
+	if off-dataOff >= int64(len(data)) {
+		return 0
+	}
+	return copy(p, data[off-dataOff:])
+}
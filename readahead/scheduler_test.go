@@ -0,0 +1,224 @@
+package readahead
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingFile is an in-memory file that counts the reads.
+type countingFile struct {
+	*bytes.Reader
+	reads atomic.Int64
+	fail  atomic.Bool
+}
+
+func newCountingFile(size int) (*countingFile, []byte) {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	return &countingFile{Reader: bytes.NewReader(data)}, data
+}
+
+func (f *countingFile) ReadAt(p []byte, off int64) (int, error) {
+	f.reads.Add(1)
+	if f.fail.Load() {
+		return 0, errors.New("unavailable")
+	}
+	return f.Reader.ReadAt(p, off)
+}
+
+func (f *countingFile) Close() error {
+	return nil
+}
+
+type patternCounts struct {
+	mu     sync.Mutex
+	counts map[Pattern]int
+	memory int
+}
+
+func (c *patternCounts) onRead(pattern Pattern, fromMemory bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[pattern]++
+	if fromMemory {
+		c.memory++
+	}
+}
+
+func TestScheduler_Sequential(t *testing.T) {
+	file, data := newCountingFile(1 * MiB)
+	counts := &patternCounts{counts: make(map[Pattern]int)}
+	s := NewScheduler(file, SchedulerOptions{ReadAheadSize: 64 * KiB, BlockSize: 4 * KiB, OnRead: counts.onRead})
+
+	// read the whole file in small nodes, like a replay does.
+	var got []byte
+	buf := make([]byte, 1000)
+	off := int64(0)
+	for {
+		n, err := s.ReadAt(buf, off)
+		got = append(got, buf[:n]...)
+		off += int64(n)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+	}
+	require.Equal(t, data, got)
+	require.Equal(t, DefaultSequentialAfter-1, counts.counts[PatternRandom])
+	require.Greater(t, counts.counts[PatternSequential], 1000)
+	// the file is read in windows, not node by node.
+	require.Less(t, file.reads.Load(), int64(len(data)/(64*KiB)+10))
+	require.Greater(t, counts.memory, counts.counts[PatternSequential]*9/10)
+}
+
+func TestScheduler_Random(t *testing.T) {
+	file, data := newCountingFile(1 * MiB)
+	counts := &patternCounts{counts: make(map[Pattern]int)}
+	s := NewScheduler(file, SchedulerOptions{BlockSize: 4 * KiB, CacheSize: 16 * KiB, OnRead: counts.onRead})
+
+	read := func(off int64, size int) {
+		buf := make([]byte, size)
+		n, err := s.ReadAt(buf, off)
+		require.NoError(t, err)
+		require.Equal(t, size, n)
+		require.Equal(t, data[off:off+int64(size)], buf)
+	}
+	// the size of a node, then the node: one request for the block.
+	read(100_000, 10)
+	read(100_000, 2000)
+	require.Equal(t, int64(1), file.reads.Load())
+	require.Equal(t, 1, counts.memory)
+	// a read across blocks.
+	read(200_000-10, 100)
+	require.Equal(t, int64(2), file.reads.Load())
+	// a large read is not cached.
+	read(300_000, 100*KiB)
+	read(300_000, 100*KiB)
+	require.Equal(t, int64(4), file.reads.Load())
+	// the cache is bounded: the first blocks were evicted.
+	read(500_000, 16*KiB)
+	read(100_000, 10)
+	require.Equal(t, int64(6), file.reads.Load())
+	require.Equal(t, 7, counts.counts[PatternRandom])
+	require.Zero(t, counts.counts[PatternSequential])
+
+	// the end of the file.
+	buf := make([]byte, 100)
+	n, err := s.ReadAt(buf, int64(len(data)-10))
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, 10, n)
+	require.Equal(t, data[len(data)-10:], buf[:n])
+	_, err = s.ReadAt(buf, int64(len(data)))
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestScheduler_Colocated(t *testing.T) {
+	file, data := newCountingFile(4 * MiB)
+	counts := &patternCounts{counts: make(map[Pattern]int)}
+	s := NewScheduler(file, SchedulerOptions{ReadAheadSize: 256 * KiB, BlockSize: 4 * KiB, MaxStreams: 4, OnRead: counts.onRead})
+
+	rng := rand.New(rand.NewSource(2))
+	buf := make([]byte, 4000)
+	for off := int64(0); off+int64(len(buf)) <= int64(len(data)); off += int64(len(buf)) {
+		n, err := s.ReadAt(buf, off)
+		require.NoError(t, err)
+		require.Equal(t, data[off:off+int64(n)], buf[:n])
+		if off < int64(DefaultSequentialAfter*len(buf)) {
+			continue
+		}
+		// once the stream is sequential, random reads interleaved with it, more than the streams tracked.
+		for i := 0; i < 8; i++ {
+			randomOff := rng.Int63n(int64(len(data) - 100))
+			random := make([]byte, 100)
+			_, err := s.ReadAt(random, randomOff)
+			require.NoError(t, err)
+			require.Equal(t, data[randomOff:randomOff+100], random)
+		}
+	}
+	// the stream stayed sequential.
+	require.Greater(t, counts.counts[PatternSequential], len(data)/len(buf)-DefaultSequentialAfter-1)
+}
+
+func TestScheduler_Errors(t *testing.T) {
+	file, data := newCountingFile(1 * MiB)
+	s := NewScheduler(file, SchedulerOptions{ReadAheadSize: 64 * KiB, BlockSize: 4 * KiB})
+	buf := make([]byte, 1000)
+	off := int64(0)
+	for i := 0; i < 10; i++ {
+		_, err := s.ReadAt(buf, off)
+		require.NoError(t, err)
+		off += int64(len(buf))
+	}
+	file.fail.Store(true)
+	// the windows already read are still served; then the error of the file is returned.
+	var err error
+	for err == nil {
+		_, err = s.ReadAt(buf, off)
+		off += int64(len(buf))
+	}
+	require.ErrorContains(t, err, "unavailable")
+	file.fail.Store(false)
+	n, err := s.ReadAt(buf, off)
+	require.NoError(t, err)
+	require.Equal(t, data[off:off+int64(n)], buf)
+}
+
+// gatedFile is a file whose reads wait for the gate to be opened.
+type gatedFile struct {
+	*countingFile
+	gate     chan struct{}
+	inFlight atomic.Int64
+	// inFlightAtClose is the number of reads in flight when the file was closed.
+	inFlightAtClose atomic.Int64
+	closed          atomic.Bool
+}
+
+func (f *gatedFile) ReadAt(p []byte, off int64) (int, error) {
+	f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	<-f.gate
+	return f.countingFile.ReadAt(p, off)
+}
+
+func (f *gatedFile) Close() error {
+	f.inFlightAtClose.Store(f.inFlight.Load())
+	f.closed.Store(true)
+	return nil
+}
+
+func TestScheduler_Close(t *testing.T) {
+	file, _ := newCountingFile(1 * MiB)
+	gated := &gatedFile{countingFile: file, gate: make(chan struct{})}
+	s := NewScheduler(gated, SchedulerOptions{ReadAheadSize: 64 * KiB, BlockSize: 4 * KiB, SequentialAfter: 1})
+	// the read waits for the window it overlaps, and the window ahead of it is read in the background.
+	read := make(chan error)
+	go func() {
+		_, err := s.ReadAt(make([]byte, 1000), 0)
+		read <- err
+	}()
+	require.Eventually(t, func() bool { return gated.inFlight.Load() == 2 }, time.Second, time.Millisecond)
+
+	// the file is closed once the windows being read are done.
+	closed := make(chan error)
+	go func() { closed <- s.Close() }()
+	time.Sleep(20 * time.Millisecond)
+	require.False(t, gated.closed.Load())
+	close(gated.gate)
+	require.NoError(t, <-closed)
+	require.NoError(t, <-read)
+	require.Zero(t, gated.inFlightAtClose.Load())
+
+	// no window is read ahead after Close.
+	reads := file.reads.Load()
+	_, err := s.ReadAt(make([]byte, 1000), 1000)
+	require.NoError(t, err)
+	require.Equal(t, reads+1, file.reads.Load())
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"sort"
 	"strings"
@@ -15,18 +17,30 @@ import (
 	jsoniter "github.com/json-iterator/go"
 	"github.com/mostynb/zstdpool-freelist"
 	"github.com/mr-tron/base58"
+	"github.com/rpcpool/yellowstone-faithful/lookuptables"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
 	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/rpcpool/yellowstone-faithful/tracing"
 	"github.com/rpcpool/yellowstone-faithful/txstatus"
 	"github.com/sourcegraph/jsonrpc2"
 	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/klog/v2"
 )
 
 type requestContext struct {
 	ctx *fasthttp.RequestCtx
+	// rest is true for the REST endpoints (/api/v1/...): the result is replied
+	// as is, without the JSON-RPC envelope, and errors are mapped to HTTP status codes.
+	rest bool
 }
 
 // ReplyWithError(ctx context.Context, id ID, respErr *Error) error {
 func (c *requestContext) ReplyWithError(ctx context.Context, id jsonrpc2.ID, respErr *jsonrpc2.Error) error {
+	if c.rest {
+		replyJSON(c.ctx, restStatusCodeForError(respErr), map[string]any{"error": respErr})
+		return nil
+	}
 	resp := &jsonrpc2.Response{
 		ID:    id,
 		Error: respErr,
@@ -86,33 +100,40 @@ func toLowerCamelCase(v string) string {
 // Reply sends a response to the client with the given result.
 // The result fields keys are converted to camelCase.
 // If remapCallback is not nil, it is called with the result map[string]interface{}.
+// The fields are written in the given order (see jsonFieldOrder); if it's nil, they are sorted.
 func (c *requestContext) Reply(
 	ctx context.Context,
 	id jsonrpc2.ID,
 	result interface{},
 	remapCallback func(map[string]any) map[string]any,
+	order *jsonFieldOrder,
 ) error {
-	mm, err := toMapAny(result)
+	result, err := toCamelCaseResult(result, remapCallback)
 	if err != nil {
 		return err
 	}
+	resRaw, err := marshalOrdered(result, order)
+	if err != nil {
+		return err
+	}
+	c.replyResult(id, resRaw)
+	return nil
+}
+
+// toCamelCaseResult converts the result fields keys to camelCase, and calls remapCallback (if not nil)
+// with the result map[string]interface{}, as Reply does.
+func toCamelCaseResult(result any, remapCallback func(map[string]any) map[string]any) (any, error) {
+	mm, err := toMapAny(result)
+	if err != nil {
+		return nil, err
+	}
 	result = MapToCamelCaseAny(mm)
 	if remapCallback != nil {
 		if mp, ok := result.(map[string]any); ok {
 			result = remapCallback(mp)
 		}
 	}
-	resRaw, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(result)
-	if err != nil {
-		return err
-	}
-	raw := json.RawMessage(resRaw)
-	resp := &jsonrpc2.Response{
-		ID:     id,
-		Result: &raw,
-	}
-	replyJSON(c.ctx, http.StatusOK, resp)
-	return err
+	return result, nil
 }
 
 // ReplyRaw sends a raw response without any processing (no camelCase conversion, etc).
@@ -125,13 +146,23 @@ func (c *requestContext) ReplyRaw(
 	if err != nil {
 		return err
 	}
+	c.replyResult(id, resRaw)
+	return nil
+}
+
+func (c *requestContext) replyResult(id jsonrpc2.ID, resRaw []byte) {
+	if c.rest {
+		c.ctx.SetContentType("application/json")
+		c.ctx.SetStatusCode(http.StatusOK)
+		c.ctx.SetBody(resRaw)
+		return
+	}
 	raw := json.RawMessage(resRaw)
 	resp := &jsonrpc2.Response{
 		ID:     id,
 		Result: &raw,
 	}
 	replyJSON(c.ctx, http.StatusOK, resp)
-	return err
 }
 
 func putValueIntoContext(ctx context.Context, key, value interface{}) context.Context {
@@ -156,6 +187,12 @@ type GetBlockRequest struct {
 		MaxSupportedTransactionVersion *uint64              `json:"maxSupportedTransactionVersion,omitempty"`
 		TransactionDetails             *string              `json:"transactionDetails,omitempty"` // default: "full"
 		Rewards                        *bool                `json:"rewards,omitempty"`
+		// ShowEntries is an old-faithful extension: it adds the entries (PoH data) of the block to the response.
+		ShowEntries *bool `json:"showEntries,omitempty"`
+		// RewardsOffset and RewardsLimit are old-faithful extensions: they return a page of the rewards of the block
+		// (the rewards of an epoch boundary block can be hundreds of thousands); a limit of 0 means no limit.
+		RewardsOffset *uint64 `json:"rewardsOffset,omitempty"`
+		RewardsLimit  *uint64 `json:"rewardsLimit,omitempty"`
 	} `json:"options,omitempty"`
 }
 
@@ -248,6 +285,29 @@ func parseGetBlockRequest(raw *json.RawMessage) (*GetBlockRequest, error) {
 			rewards := true
 			out.Options.Rewards = &rewards
 		}
+		if showEntriesRaw, ok := optionsRaw["showEntries"]; ok {
+			showEntries, ok := showEntriesRaw.(bool)
+			if !ok {
+				return nil, fmt.Errorf("showEntries must be a boolean, got %T", showEntriesRaw)
+			}
+			out.Options.ShowEntries = &showEntries
+		} else {
+			showEntries := false
+			out.Options.ShowEntries = &showEntries
+		}
+		for name, dst := range map[string]**uint64{
+			"rewardsOffset": &out.Options.RewardsOffset,
+			"rewardsLimit":  &out.Options.RewardsLimit,
+		} {
+			if valueRaw, ok := optionsRaw[name]; ok {
+				value, ok := valueRaw.(float64)
+				if !ok || value < 0 || value != math.Trunc(value) {
+					return nil, fmt.Errorf("%s must be a non-negative integer, got %v", name, valueRaw)
+				}
+				valueUint64 := uint64(value)
+				*dst = &valueUint64
+			}
+		}
 	} else {
 		// set defaults:
 		commitmentType := defaultCommitment()
@@ -258,6 +318,8 @@ func parseGetBlockRequest(raw *json.RawMessage) (*GetBlockRequest, error) {
 		out.Options.TransactionDetails = &transactionDetails
 		rewards := true
 		out.Options.Rewards = &rewards
+		showEntries := false
+		out.Options.ShowEntries = &showEntries
 	}
 
 	return out, nil
@@ -281,6 +343,8 @@ type GetTransactionRequest struct {
 		Encoding                       *solana.EncodingType `json:"encoding,omitempty"` // default: "json"
 		MaxSupportedTransactionVersion *uint64              `json:"maxSupportedTransactionVersion,omitempty"`
 		Commitment                     *rpc.CommitmentType  `json:"commitment,omitempty"`
+		// MinContextSlot is the slot that the archive must have reached to answer.
+		MinContextSlot *uint64 `json:"minContextSlot,omitempty"`
 	} `json:"options,omitempty"`
 }
 
@@ -289,6 +353,16 @@ func (req *GetTransactionRequest) Validate() error {
 	if req.Signature.IsZero() {
 		return fmt.Errorf("signature is required")
 	}
+	if req.Options.Commitment != nil {
+		switch *req.Options.Commitment {
+		case rpc.CommitmentConfirmed, rpc.CommitmentFinalized:
+		case rpc.CommitmentProcessed:
+			// same as solana: the transactions of processed blocks can't be looked up.
+			return fmt.Errorf("Method does not support commitment below `confirmed`")
+		default:
+			return fmt.Errorf("unsupported commitment %q", *req.Options.Commitment)
+		}
+	}
 	if req.Options.Encoding != nil && !isAnyEncodingOf(
 		*req.Options.Encoding,
 		solana.EncodingBase58,
@@ -366,6 +440,14 @@ func parseGetTransactionRequest(raw *json.RawMessage) (*GetTransactionRequest, e
 			commitmentType := rpc.CommitmentType(commitment)
 			out.Options.Commitment = &commitmentType
 		}
+		if minContextSlotRaw, ok := optionsRaw["minContextSlot"]; ok {
+			minContextSlot, ok := minContextSlotRaw.(float64)
+			if !ok || minContextSlot < 0 || minContextSlot != math.Trunc(minContextSlot) {
+				return nil, fmt.Errorf("minContextSlot must be a non-negative integer, got %v", minContextSlotRaw)
+			}
+			minContextSlotUint64 := uint64(minContextSlot)
+			out.Options.MinContextSlot = &minContextSlotUint64
+		}
 	} else {
 		// set defaults:
 		encodingType := defaultEncoding()
@@ -381,6 +463,7 @@ func compiledInstructionsToJsonParsed(
 	tx solana.Transaction,
 	inst solana.CompiledInstruction,
 	meta any,
+	stackHeight *uint32,
 ) (json.RawMessage, error) {
 	programId, err := tx.ResolveProgramIDIndex(inst.ProgramIDIndex)
 	if err != nil {
@@ -421,10 +504,7 @@ func compiledInstructionsToJsonParsed(
 				}
 			}(),
 		},
-		StackHeight: func() *uint32 {
-			// TODO: get the stack height from somewhere
-			return nil
-		}(),
+		StackHeight: stackHeight,
 	}
 
 	parsedInstructionJSON, err := instrParams.ParseInstruction()
@@ -439,7 +519,7 @@ func compiledInstructionsToJsonParsed(
 			}(),
 			"data":        base58.Encode(inst.Data),
 			"programId":   programId.String(),
-			"stackHeight": nil,
+			"stackHeight": stackHeight,
 		}
 		asRaw, _ := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(nonParseadInstructionJSON)
 		return asRaw, nil
@@ -449,10 +529,14 @@ func compiledInstructionsToJsonParsed(
 }
 
 func encodeTransactionResponseBasedOnWantedEncoding(
+	ctx context.Context,
 	encoding solana.EncodingType,
 	tx solana.Transaction,
 	meta any,
-) (any, any, error) {
+	lookupTables *lookuptables.Resolver,
+) (_ any, _ any, err error) {
+	_, span := tracing.Start(ctx, "encode.transaction", attribute.String("encoding", string(encoding)))
+	defer func() { tracing.End(span, err) }()
 	switch encoding {
 	case solana.EncodingBase58, solana.EncodingBase64, solana.EncodingBase64Zstd:
 		txBuf, err := tx.MarshalBinary()
@@ -466,69 +550,15 @@ func encodeTransactionResponseBasedOnWantedEncoding(
 			return nil, nil, fmt.Errorf("unsupported encoding")
 		}
 
-		{
-			unwrappedMeta, ok := meta.(*confirmed_block.TransactionStatusMeta)
-			if ok {
-				{
-					tables := map[solana.PublicKey]solana.PublicKeySlice{}
-					writable := byteSlicesToKeySlice(unwrappedMeta.LoadedWritableAddresses)
-					readonly := byteSlicesToKeySlice(unwrappedMeta.LoadedReadonlyAddresses)
-					for _, addr := range tx.Message.AddressTableLookups {
-						numTakeWritable := len(addr.WritableIndexes)
-						numTakeReadonly := len(addr.ReadonlyIndexes)
-						tableKey := addr.AccountKey
-						{
-							// now need to rebuild the address table taking into account the indexes, and put the keys into the tables
-							maxIndex := 0
-							for _, indexB := range addr.WritableIndexes {
-								index := int(indexB)
-								if index > maxIndex {
-									maxIndex = index
-								}
-							}
-							for _, indexB := range addr.ReadonlyIndexes {
-								index := int(indexB)
-								if index > maxIndex {
-									maxIndex = index
-								}
-							}
-							tables[tableKey] = make([]solana.PublicKey, maxIndex+1)
-						}
-						if numTakeWritable > 0 {
-							writableForTable := writable[:numTakeWritable]
-							for i, indexB := range addr.WritableIndexes {
-								index := int(indexB)
-								tables[tableKey][index] = writableForTable[i]
-							}
-							writable = writable[numTakeWritable:]
-						}
-						if numTakeReadonly > 0 {
-							readableForTable := readonly[:numTakeReadonly]
-							for i, indexB := range addr.ReadonlyIndexes {
-								index := int(indexB)
-								tables[tableKey][index] = readableForTable[i]
-							}
-							readonly = readonly[numTakeReadonly:]
-						}
-					}
-					err := tx.Message.SetAddressTables(tables)
-					if err != nil {
-						return nil, nil, fmt.Errorf("failed to set address tables: %w", err)
-					}
-				}
-				if tx.Message.IsVersioned() {
-					err := tx.Message.ResolveLookups()
-					if err != nil {
-						panic(err)
-					}
-				}
-			}
+		if err := resolveAddressTables(&tx, meta, lookupTables); err != nil {
+			return nil, nil, err
 		}
 
 		parsedInstructions := make([]json.RawMessage, 0)
 
 		for _, inst := range tx.Message.Instructions {
-			parsedInstructionJSON, err := compiledInstructionsToJsonParsed(tx, inst, meta)
+			// like the RPC, the top-level instructions have no stack height.
+			parsedInstructionJSON, err := compiledInstructionsToJsonParsed(tx, inst, meta, nil)
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to compile instruction: %w", err)
 			}
@@ -550,33 +580,40 @@ func encodeTransactionResponseBasedOnWantedEncoding(
 				if err != nil {
 					return nil, nil, fmt.Errorf("failed to marshal meta: %w", err)
 				}
-				for innerIndex, insts := range unwrappedMeta.InnerInstructions {
-					inner := make([]solana.CompiledInstruction, len(insts.Instructions))
-					for j, inst := range insts.Instructions {
-						inner[j] = solana.CompiledInstruction{
-							ProgramIDIndex: uint16(inst.ProgramIdIndex),
-							Accounts:       byeSliceToUint16Slice(inst.Accounts),
-							Data:           clone(inst.Data),
-						}
+				instructions, err := nodetools.FlattenInstructions(&tx, unwrappedMeta)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to flatten instructions: %w", err)
+				}
+				// the position of the inner instructions of each top-level instruction in the meta.
+				groups := make(map[int]int)
+				for i, insts := range unwrappedMeta.InnerInstructions {
+					groups[int(insts.Index)] = i
+				}
+				for _, inst := range instructions {
+					if inst.IsTopLevel() {
+						continue
 					}
-					for instIndex, inst := range inner {
-						parsedInstructionJSON, err := compiledInstructionsToJsonParsed(tx, inst, unwrappedMeta)
-						if err != nil {
-							return nil, nil, fmt.Errorf("failed to compile instruction: %w", err)
-						}
-						// now replace the inner instruction with the parsed instruction:
-						{
-							if _, ok := metaJSON["inner_instructions"]; !ok {
-								metaJSON["inner_instructions"] = []any{}
-							} else {
-								innerInstructions, ok := metaJSON["inner_instructions"].([]any)
-								if ok && len(innerInstructions) > innerIndex {
-									relevantInner := innerInstructions[innerIndex].(map[string]any)
-									{
-										_, ok := relevantInner["instructions"].([]any)
-										if ok {
-											metaJSON["inner_instructions"].([]any)[innerIndex].(map[string]any)["instructions"].([]any)[instIndex] = parsedInstructionJSON
-										}
+					var stackHeight *uint32
+					if inst.StackHeightRecorded {
+						stackHeight = &inst.StackHeight
+					}
+					parsedInstructionJSON, err := compiledInstructionsToJsonParsed(tx, inst.Compiled, unwrappedMeta, stackHeight)
+					if err != nil {
+						return nil, nil, fmt.Errorf("failed to compile instruction: %w", err)
+					}
+					// now replace the inner instruction with the parsed instruction:
+					{
+						innerIndex, instIndex := groups[inst.OuterIndex], inst.InnerIndex
+						if _, ok := metaJSON["inner_instructions"]; !ok {
+							metaJSON["inner_instructions"] = []any{}
+						} else {
+							innerInstructions, ok := metaJSON["inner_instructions"].([]any)
+							if ok && len(innerInstructions) > innerIndex {
+								relevantInner := innerInstructions[innerIndex].(map[string]any)
+								{
+									_, ok := relevantInner["instructions"].([]any)
+									if ok {
+										metaJSON["inner_instructions"].([]any)[innerIndex].(map[string]any)["instructions"].([]any)[instIndex] = parsedInstructionJSON
 									}
 								}
 							}
@@ -595,6 +632,69 @@ func encodeTransactionResponseBasedOnWantedEncoding(
 	}
 }
 
+// resolveAddressTables resolves the accounts that a v0 transaction loads from address lookup
+// tables, which jsonParsed needs to resolve the accounts of its instructions. They're in a
+// protobuf meta; for the transactions whose meta doesn't have them (missing or legacy), they're
+// looked up in the lookup tables snapshot of the epoch, if there's one (lookupTables can be nil).
+// A transaction that can't be resolved is left as is.
+func resolveAddressTables(tx *solana.Transaction, meta any, lookupTables *lookuptables.Resolver) error {
+	if !tx.Message.IsVersioned() || len(tx.Message.AddressTableLookups) == 0 {
+		return nil
+	}
+	var tables map[solana.PublicKey]solana.PublicKeySlice
+	unwrappedMeta, ok := meta.(*confirmed_block.TransactionStatusMeta)
+	if ok && len(unwrappedMeta.LoadedWritableAddresses)+len(unwrappedMeta.LoadedReadonlyAddresses) == tx.Message.AddressTableLookups.NumLookups() {
+		tables = addressTablesFromMeta(tx, unwrappedMeta)
+	} else if lookupTables != nil {
+		var err error
+		tables, err = lookupTables.Resolve(tx)
+		if errors.Is(err, lookuptables.ErrUnresolved) {
+			klog.V(4).Infof("transaction %s: %s", tx.Signatures[0], err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to resolve address tables: %w", err)
+		}
+	} else {
+		return nil
+	}
+	if err := tx.Message.SetAddressTables(tables); err != nil {
+		return fmt.Errorf("failed to set address tables: %w", err)
+	}
+	if err := tx.Message.ResolveLookups(); err != nil {
+		return fmt.Errorf("failed to resolve lookups: %w", err)
+	}
+	return nil
+}
+
+// addressTablesFromMeta rebuilds the entries of the address lookup tables used by the
+// transaction from the accounts loaded from them, which are in the meta: the writable ones of
+// all the lookups, in order, then the readonly ones.
+func addressTablesFromMeta(tx *solana.Transaction, meta *confirmed_block.TransactionStatusMeta) map[solana.PublicKey]solana.PublicKeySlice {
+	tables := map[solana.PublicKey]solana.PublicKeySlice{}
+	writable := byteSlicesToKeySlice(meta.LoadedWritableAddresses)
+	readonly := byteSlicesToKeySlice(meta.LoadedReadonlyAddresses)
+	for _, addr := range tx.Message.AddressTableLookups {
+		table := tables[addr.AccountKey]
+		set := func(index int, key solana.PublicKey) {
+			if index >= len(table) {
+				table = append(table, make([]solana.PublicKey, index+1-len(table))...)
+			}
+			table[index] = key
+		}
+		for i, index := range addr.WritableIndexes {
+			set(int(index), writable[i])
+		}
+		writable = writable[len(addr.WritableIndexes):]
+		for i, index := range addr.ReadonlyIndexes {
+			set(int(index), readonly[i])
+		}
+		readonly = readonly[len(addr.ReadonlyIndexes):]
+		tables[addr.AccountKey] = table
+	}
+	return tables
+}
+
 func clone[T any](in []T) []T {
 	out := make([]T, len(in))
 	copy(out, in)
@@ -670,3 +770,103 @@ func parseGetBlockTimeRequest(raw *json.RawMessage) (uint64, error) {
 	}
 	return uint64(blockRaw), nil
 }
+
+// parseGetBlocksRequest parses the params of getBlocks: the start slot, and the (optional) end slot.
+// The second argument can also be the config object, in which case there is no end slot.
+func parseGetBlocksRequest(raw *json.RawMessage) (uint64, *uint64, error) {
+	var params []any
+	if err := fasterJson.Unmarshal(*raw, &params); err != nil {
+		return 0, nil, fmt.Errorf("failed to unmarshal params: %w", err)
+	}
+	if len(params) < 1 {
+		return 0, nil, fmt.Errorf("params must have at least one argument")
+	}
+	startRaw, ok := params[0].(float64)
+	if !ok {
+		return 0, nil, fmt.Errorf("first argument must be a number, got %T", params[0])
+	}
+	if len(params) < 2 || params[1] == nil {
+		return uint64(startRaw), nil, nil
+	}
+	switch second := params[1].(type) {
+	case float64:
+		end := uint64(second)
+		return uint64(startRaw), &end, nil
+	case map[string]any:
+		// config (commitment); ignored.
+		return uint64(startRaw), nil, nil
+	default:
+		return 0, nil, fmt.Errorf("second argument must be a number or an object, got %T", params[1])
+	}
+}
+
+// parseGetBlocksWithLimitRequest parses the params of getBlocksWithLimit: the start slot and the limit.
+func parseGetBlocksWithLimitRequest(raw *json.RawMessage) (uint64, uint64, error) {
+	var params []any
+	if err := fasterJson.Unmarshal(*raw, &params); err != nil {
+		return 0, 0, fmt.Errorf("failed to unmarshal params: %w", err)
+	}
+	if len(params) < 2 {
+		return 0, 0, fmt.Errorf("params must have at least two arguments")
+	}
+	startRaw, ok := params[0].(float64)
+	if !ok {
+		return 0, 0, fmt.Errorf("first argument must be a number, got %T", params[0])
+	}
+	limitRaw, ok := params[1].(float64)
+	if !ok {
+		return 0, 0, fmt.Errorf("second argument must be a number, got %T", params[1])
+	}
+	return uint64(startRaw), uint64(limitRaw), nil
+}
+
+type GetInflationRewardRequest struct {
+	Addresses []solana.PublicKey
+	Epoch     *uint64
+}
+
+// parseGetInflationRewardRequest parses the params of getInflationReward: the addresses, and an
+// (optional) config object with the epoch; the other fields of the config are ignored.
+func parseGetInflationRewardRequest(raw *json.RawMessage) (*GetInflationRewardRequest, error) {
+	var params []any
+	if err := fasterJson.Unmarshal(*raw, &params); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal params: %w", err)
+	}
+	if len(params) < 1 {
+		return nil, fmt.Errorf("params must have at least one argument")
+	}
+	addresses, ok := params[0].([]any)
+	if !ok {
+		return nil, fmt.Errorf("first argument must be an array, got %T", params[0])
+	}
+	out := &GetInflationRewardRequest{
+		Addresses: make([]solana.PublicKey, 0, len(addresses)),
+	}
+	for i, address := range addresses {
+		addressString, ok := address.(string)
+		if !ok {
+			return nil, fmt.Errorf("address %d must be a string, got %T", i, address)
+		}
+		pubkey, err := solana.PublicKeyFromBase58(addressString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", addressString, err)
+		}
+		out.Addresses = append(out.Addresses, pubkey)
+	}
+	if len(params) < 2 || params[1] == nil {
+		return out, nil
+	}
+	config, ok := params[1].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("second argument must be an object, got %T", params[1])
+	}
+	if epochRaw, ok := config["epoch"]; ok && epochRaw != nil {
+		epoch, ok := epochRaw.(float64)
+		if !ok {
+			return nil, fmt.Errorf("epoch must be a number, got %T", epochRaw)
+		}
+		epochNumber := uint64(epoch)
+		out.Epoch = &epochNumber
+	}
+	return out, nil
+}
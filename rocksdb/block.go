@@ -0,0 +1,208 @@
+package rocksdb
+
+import (
+	"encoding/binary"
+)
+
+// A block is a sequence of entries (shared key length, unshared key length, value length,
+// unshared key bytes, value) followed by the offsets of the restart points (the entries
+// whose key is stored whole) and their number. When the high bit of that number is set,
+// a data block hash index (one byte per bucket, then the number of buckets on 2 bytes)
+// sits between the restart points and the number.
+
+const dataBlockHashIndexFlag = 1 << 31
+
+// blockIter iterates over the entries of a block. The key is only valid until the next
+// call; the value points into the block.
+type blockIter struct {
+	data        []byte
+	restarts    []byte
+	numRestarts int
+	cmp         func(a, b []byte) int
+
+	next  int
+	key   []byte
+	value []byte
+	valid bool
+	err   error
+}
+
+func newBlockIter(block []byte, cmp func(a, b []byte) int) (*blockIter, error) {
+	if len(block) < 4 {
+		return nil, corruptionf("block of %d bytes", len(block))
+	}
+	end := len(block) - 4
+	footer := binary.LittleEndian.Uint32(block[end:])
+	numRestarts := uint64(footer)
+	if footer&dataBlockHashIndexFlag != 0 {
+		numRestarts = uint64(footer &^ dataBlockHashIndexFlag)
+		if end < 2 {
+			return nil, corruptionf("truncated data block hash index")
+		}
+		buckets := int(binary.LittleEndian.Uint16(block[end-2:]))
+		end -= 2 + buckets
+		if end < 0 {
+			return nil, corruptionf("truncated data block hash index")
+		}
+	}
+	if numRestarts == 0 || numRestarts*4 > uint64(end) {
+		return nil, corruptionf("invalid number of restart points %d", numRestarts)
+	}
+	start := end - int(numRestarts)*4
+	return &blockIter{
+		data:        block[:start],
+		restarts:    block[start:end],
+		numRestarts: int(numRestarts),
+		cmp:         cmp,
+	}, nil
+}
+
+func (it *blockIter) fail(err error) {
+	it.err = err
+	it.valid = false
+}
+
+// decodeEntry decodes the entry at offset, after the key of the previous entry.
+func (it *blockIter) decodeEntry(offset int) bool {
+	if offset >= len(it.data) {
+		it.valid = false
+		return false
+	}
+	d := decoder{buf: it.data[offset:]}
+	shared := d.uvarint32("block entry")
+	unshared := d.uvarint32("block entry")
+	valueLen := d.uvarint32("block entry")
+	key := d.bytes(uint64(unshared), "block entry key")
+	value := d.bytes(uint64(valueLen), "block entry value")
+	if d.err == nil && int(shared) > len(it.key) {
+		d.fail("block entry key")
+	}
+	if d.err != nil {
+		it.fail(d.err)
+		return false
+	}
+	it.key = append(it.key[:shared], key...)
+	it.value = value
+	it.next = len(it.data) - len(d.buf)
+	it.valid = true
+	return true
+}
+
+func (it *blockIter) restartPoint(i int) int {
+	return int(binary.LittleEndian.Uint32(it.restarts[4*i:]))
+}
+
+// restartKey decodes the (whole) key of the restart point i.
+func (it *blockIter) restartKey(i int) ([]byte, bool) {
+	offset := it.restartPoint(i)
+	if offset >= len(it.data) {
+		it.fail(corruptionf("restart point out of the block"))
+		return nil, false
+	}
+	d := decoder{buf: it.data[offset:]}
+	shared := d.uvarint32("block entry")
+	unshared := d.uvarint32("block entry")
+	d.uvarint32("block entry")
+	key := d.bytes(uint64(unshared), "block entry key")
+	if d.err == nil && shared != 0 {
+		d.fail("restart point")
+	}
+	if d.err != nil {
+		it.fail(d.err)
+		return nil, false
+	}
+	return key, true
+}
+
+func (it *blockIter) first() {
+	it.key = it.key[:0]
+	it.decodeEntry(0)
+}
+
+func (it *blockIter) nextEntry() {
+	if it.valid {
+		it.decodeEntry(it.next)
+	}
+}
+
+// seek positions the iterator at the first entry whose key is at or after target.
+func (it *blockIter) seek(target []byte) {
+	// the last restart point before target.
+	lo, hi := 0, it.numRestarts-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		key, ok := it.restartKey(mid)
+		if !ok {
+			return
+		}
+		if it.cmp(key, target) < 0 {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	it.key = it.key[:0]
+	for ok := it.decodeEntry(it.restartPoint(lo)); ok; ok = it.decodeEntry(it.next) {
+		if it.cmp(it.key, target) >= 0 {
+			return
+		}
+	}
+}
+
+// indexEntry is an entry of an index block: the block at handle has no key after key.
+type indexEntry struct {
+	key    []byte
+	handle blockHandle
+}
+
+// decodeIndexBlock returns the entries of an index block. With value delta encoding, the
+// entries have no value length, and the value of the entries that are not restart points
+// is the size difference with the previous handle (the block comes right after the
+// previous one). With a first key, every value is followed by the first key of the block.
+func decodeIndexBlock(block []byte, deltaEncoded, firstKey bool) ([]indexEntry, error) {
+	it, err := newBlockIter(block, nil)
+	if err != nil {
+		return nil, err
+	}
+	var entries []indexEntry
+	var key []byte
+	var prev blockHandle
+	for d := (decoder{buf: it.data}); len(d.buf) > 0; {
+		shared := d.uvarint32("index entry")
+		unshared := d.uvarint32("index entry")
+		var valueLen uint32
+		if !deltaEncoded {
+			valueLen = d.uvarint32("index entry")
+		}
+		if d.err == nil && int(shared) > len(key) {
+			d.fail("index entry key")
+		}
+		key = append(key[:shared], d.bytes(uint64(unshared), "index entry key")...)
+		value := d
+		if !deltaEncoded {
+			value.buf = d.bytes(uint64(valueLen), "index entry value")
+		}
+		var handle blockHandle
+		if deltaEncoded && shared != 0 {
+			delta := value.varsigned("index entry handle")
+			handle = blockHandle{offset: prev.offset + prev.size + blockTrailerSize, size: uint64(int64(prev.size) + delta)}
+		} else {
+			handle = value.blockHandle()
+		}
+		if firstKey {
+			value.lengthPrefixed("index entry first key")
+		}
+		if deltaEncoded {
+			d = value
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+		if value.err != nil {
+			return nil, value.err
+		}
+		entries = append(entries, indexEntry{key: append([]byte(nil), key...), handle: handle})
+		prev = handle
+	}
+	return entries, nil
+}
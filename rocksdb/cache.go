@@ -0,0 +1,150 @@
+package rocksdb
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+type blockKey struct {
+	file   uint64
+	offset uint64
+}
+
+type cachedBlock struct {
+	key  blockKey
+	data []byte
+}
+
+// blockCache is an LRU cache of the (decompressed) data blocks, bounded in bytes.
+type blockCache struct {
+	mu       sync.Mutex
+	capacity int
+	size     int
+	lru      *list.List
+	blocks   map[blockKey]*list.Element
+}
+
+func newBlockCache(capacity int) *blockCache {
+	return &blockCache{
+		capacity: capacity,
+		lru:      list.New(),
+		blocks:   make(map[blockKey]*list.Element),
+	}
+}
+
+func (c *blockCache) get(key blockKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.blocks[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cachedBlock).data, true
+}
+
+func (c *blockCache) add(key blockKey, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.blocks[key]; ok || len(data) > c.capacity {
+		return
+	}
+	c.blocks[key] = c.lru.PushFront(&cachedBlock{key: key, data: data})
+	c.size += len(data)
+	for c.size > c.capacity {
+		oldest := c.lru.Back()
+		block := c.lru.Remove(oldest).(*cachedBlock)
+		delete(c.blocks, block.key)
+		c.size -= len(block.data)
+	}
+}
+
+// tableCache keeps the most recently used tables open. A table in use (acquired and not
+// released yet) is never closed.
+type tableCache struct {
+	mu       sync.Mutex
+	storage  storage
+	blocks   *blockCache
+	capacity int
+	lru      *list.List
+	tables   map[uint64]*list.Element
+}
+
+func newTableCache(storage storage, blocks *blockCache, capacity int) *tableCache {
+	return &tableCache{
+		storage:  storage,
+		blocks:   blocks,
+		capacity: capacity,
+		lru:      list.New(),
+		tables:   make(map[uint64]*list.Element),
+	}
+}
+
+// tableFileNames returns the names of the file of a table: rocksdb names them .sst, and
+// leveldb named them .ldb.
+func tableFileNames(number uint64) []string {
+	return []string{fmt.Sprintf("%06d.sst", number), fmt.Sprintf("%06d.ldb", number)}
+}
+
+// acquire returns the open table of a file; it must be released.
+func (c *tableCache) acquire(meta *fileMeta) (*table, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.tables[meta.number]; ok {
+		c.lru.MoveToFront(elem)
+		t := elem.Value.(*table)
+		t.refs++
+		return t, nil
+	}
+	var f file
+	var err error
+	for _, name := range tableFileNames(meta.number) {
+		if f, err = c.storage.open(name); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("table %06d: %w", meta.number, err)
+	}
+	t, err := openTable(f, meta.number, c.blocks)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t.refs = 1
+	c.tables[meta.number] = c.lru.PushFront(t)
+	c.evict()
+	return t, nil
+}
+
+func (c *tableCache) release(t *table) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.refs--
+	c.evict()
+}
+
+// evict closes the least recently used tables not in use, down to the capacity.
+func (c *tableCache) evict() {
+	for elem := c.lru.Back(); elem != nil && c.lru.Len() > c.capacity; {
+		prev := elem.Prev()
+		if t := elem.Value.(*table); t.refs == 0 {
+			c.lru.Remove(elem)
+			delete(c.tables, t.number)
+			t.file.Close()
+		}
+		elem = prev
+	}
+}
+
+func (c *tableCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		elem.Value.(*table).file.Close()
+	}
+	c.lru.Init()
+	clear(c.tables)
+	return nil
+}
@@ -0,0 +1,261 @@
+package rocksdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/xxh3"
+)
+
+// valueType is the type of an entry: the low byte of the trailer of its internal key.
+type valueType byte
+
+const (
+	typeDeletion              valueType = 0x0
+	typeValue                 valueType = 0x1
+	typeMerge                 valueType = 0x2
+	typeSingleDeletion        valueType = 0x7
+	typeRangeDeletion         valueType = 0xf
+	typeBlobIndex             valueType = 0x11
+	typeDeletionWithTimestamp valueType = 0x14
+)
+
+// internalKeyTrailerSize is the size of the sequence number and type that end an internal key.
+const internalKeyTrailerSize = 8
+
+var errCorruption = errors.New("corruption")
+
+func corruptionf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errCorruption, fmt.Sprintf(format, args...))
+}
+
+// An internal key is the user key followed by the trailer (sequence number << 8 | type,
+// little endian). Internal keys sort by user key, then by decreasing trailer, so the
+// newest entry of a user key comes first.
+
+func makeInternalKey(dst, userKey []byte, seq uint64, typ valueType) []byte {
+	dst = append(dst, userKey...)
+	return binary.LittleEndian.AppendUint64(dst, seq<<8|uint64(typ))
+}
+
+// makeSeekKey returns the internal key that sorts before every entry of userKey.
+func makeSeekKey(userKey []byte) []byte {
+	return binary.LittleEndian.AppendUint64(append([]byte(nil), userKey...), ^uint64(0))
+}
+
+func parseInternalKey(key []byte) (userKey []byte, seq uint64, typ valueType, err error) {
+	if len(key) < internalKeyTrailerSize {
+		return nil, 0, 0, corruptionf("internal key of %d bytes", len(key))
+	}
+	n := len(key) - internalKeyTrailerSize
+	trailer := binary.LittleEndian.Uint64(key[n:])
+	return key[:n], trailer >> 8, valueType(trailer), nil
+}
+
+// userKey returns the user key of an internal key; parseInternalKey reports the invalid ones.
+func userKey(key []byte) []byte {
+	if len(key) < internalKeyTrailerSize {
+		return key
+	}
+	return key[:len(key)-internalKeyTrailerSize]
+}
+
+func trailer(key []byte) uint64 {
+	if len(key) < internalKeyTrailerSize {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(key[len(key)-internalKeyTrailerSize:])
+}
+
+func compareInternalKeys(a, b []byte) int {
+	if c := bytes.Compare(userKey(a), userKey(b)); c != 0 {
+		return c
+	}
+	ta, tb := trailer(a), trailer(b)
+	switch {
+	case ta > tb:
+		return -1
+	case ta < tb:
+		return 1
+	}
+	return 0
+}
+
+// decoder reads the varints and length-prefixed slices of the rocksdb encodings.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) fail(what string) {
+	if d.err == nil {
+		d.err = corruptionf("truncated or invalid %s", what)
+	}
+	d.buf = nil
+}
+
+func (d *decoder) uvarint(what string) uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail(what)
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) uvarint32(what string) uint32 {
+	v := d.uvarint(what)
+	if v > 1<<32-1 {
+		d.fail(what)
+		return 0
+	}
+	return uint32(v)
+}
+
+// varsigned reads a zigzag-encoded signed varint.
+func (d *decoder) varsigned(what string) int64 {
+	v := d.uvarint(what)
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (d *decoder) bytes(n uint64, what string) []byte {
+	if n > uint64(len(d.buf)) {
+		d.fail(what)
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) lengthPrefixed(what string) []byte {
+	return d.bytes(d.uvarint(what), what)
+}
+
+func (d *decoder) byte(what string) byte {
+	b := d.bytes(1, what)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+// blockHandle is the position of a block in a table, not counting its trailer.
+type blockHandle struct {
+	offset, size uint64
+}
+
+func (d *decoder) blockHandle() blockHandle {
+	offset := d.uvarint("block handle")
+	size := d.uvarint("block handle")
+	return blockHandle{offset: offset, size: size}
+}
+
+// Checksum types of the block trailers.
+const (
+	checksumNone   = 0
+	checksumCRC32c = 1
+	checksumXXHash = 2
+	// checksumXXHash64 is the lower 32 bits of the xxHash64.
+	checksumXXHash64 = 3
+	// checksumXXH3 is the lower 32 bits of the XXH3 of the data, combined with the last byte.
+	checksumXXH3 = 4
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+const crcMaskDelta = 0xa282ead8
+
+// maskCRC is how rocksdb (as leveldb) stores CRCs, so that the CRC of data containing CRCs
+// is not weak.
+func maskCRC(crc uint32) uint32 {
+	return (crc>>15 | crc<<17) + crcMaskDelta
+}
+
+func unmaskCRC(masked uint32) uint32 {
+	rot := masked - crcMaskDelta
+	return rot>>17 | rot<<15
+}
+
+// checksum computes the checksum of data, whose last byte is the compression type of a block.
+func checksum(typ byte, data []byte) (uint32, error) {
+	switch typ {
+	case checksumCRC32c:
+		return maskCRC(crc32.Checksum(data, crc32cTable)), nil
+	case checksumXXHash:
+		return xxhash32(data, 0), nil
+	case checksumXXHash64:
+		return uint32(xxhash.Sum64(data)), nil
+	case checksumXXH3:
+		if len(data) == 0 {
+			return 0, nil
+		}
+		n := len(data) - 1
+		return uint32(xxh3.Hash(data[:n])) ^ uint32(data[n])*0x6b9083d9, nil
+	}
+	return 0, fmt.Errorf("unsupported checksum type %d", typ)
+}
+
+// checksumModifier is added to the block checksums of format version 6, so that a block
+// copied to another file or offset doesn't pass the check.
+func checksumModifier(baseContext uint32, offset uint64) uint32 {
+	if baseContext == 0 {
+		return 0
+	}
+	return baseContext ^ (uint32(offset) + uint32(offset>>32))
+}
+
+const (
+	xxPrime32_1 = 2654435761
+	xxPrime32_2 = 2246822519
+	xxPrime32_3 = 3266489917
+	xxPrime32_4 = 668265263
+	xxPrime32_5 = 374761393
+)
+
+func xxh32Round(acc, input uint32) uint32 {
+	acc += input * xxPrime32_2
+	acc = acc<<13 | acc>>19
+	return acc * xxPrime32_1
+}
+
+// xxhash32 is the 32-bit xxHash, which no dependency of this module implements.
+func xxhash32(b []byte, seed uint32) uint32 {
+	n := len(b)
+	var h uint32
+	if n >= 16 {
+		v1 := seed + xxPrime32_1 + xxPrime32_2
+		v2 := seed + xxPrime32_2
+		v3 := seed
+		v4 := seed - xxPrime32_1
+		for ; len(b) >= 16; b = b[16:] {
+			v1 = xxh32Round(v1, binary.LittleEndian.Uint32(b[0:]))
+			v2 = xxh32Round(v2, binary.LittleEndian.Uint32(b[4:]))
+			v3 = xxh32Round(v3, binary.LittleEndian.Uint32(b[8:]))
+			v4 = xxh32Round(v4, binary.LittleEndian.Uint32(b[12:]))
+		}
+		h = (v1<<1 | v1>>31) + (v2<<7 | v2>>25) + (v3<<12 | v3>>20) + (v4<<18 | v4>>14)
+	} else {
+		h = seed + xxPrime32_5
+	}
+	h += uint32(n)
+	for ; len(b) >= 4; b = b[4:] {
+		h += binary.LittleEndian.Uint32(b) * xxPrime32_3
+		h = (h<<17 | h>>15) * xxPrime32_4
+	}
+	for ; len(b) > 0; b = b[1:] {
+		h += uint32(b[0]) * xxPrime32_5
+		h = (h<<11 | h>>21) * xxPrime32_1
+	}
+	h ^= h >> 15
+	h *= xxPrime32_2
+	h ^= h >> 13
+	h *= xxPrime32_3
+	h ^= h >> 16
+	return h
+}
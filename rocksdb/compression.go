@@ -0,0 +1,127 @@
+package rocksdb
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+)
+
+// Compression types of the block trailers.
+const (
+	compressionNone   = 0x0
+	compressionSnappy = 0x1
+	compressionZlib   = 0x2
+	compressionBzip2  = 0x3
+	compressionLZ4    = 0x4
+	compressionLZ4HC  = 0x5
+	compressionXpress = 0x6
+	compressionZstd   = 0x7
+	// compressionZstdNotFinal is how zstd was marked before its format was final.
+	compressionZstdNotFinal = 0x40
+)
+
+// decompress returns the contents of a block. Since format version 2, the blocks
+// compressed with anything but snappy start with their decompressed size (a varint32).
+func decompress(typ byte, data []byte, formatVersion uint32) ([]byte, error) {
+	switch typ {
+	case compressionNone:
+		return data, nil
+	case compressionSnappy:
+		return s2.Decode(nil, data)
+	case compressionXpress:
+		return nil, fmt.Errorf("xpress compression is not supported")
+	}
+	if formatVersion < 2 {
+		return nil, fmt.Errorf("compression type %#x in a table of format version %d is not supported", typ, formatVersion)
+	}
+	size, n := binary.Uvarint(data)
+	if n <= 0 || size > 1<<32-1 {
+		return nil, corruptionf("invalid decompressed size of a block")
+	}
+	data = data[n:]
+	var out []byte
+	var err error
+	switch typ {
+	case compressionZlib:
+		// rocksdb writes raw deflate streams (negative window bits).
+		out, err = io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	case compressionBzip2:
+		out, err = io.ReadAll(bzip2.NewReader(bytes.NewReader(data)))
+	case compressionLZ4, compressionLZ4HC:
+		out, err = decodeLZ4Block(data, int(size))
+	case compressionZstd, compressionZstdNotFinal:
+		out, err = tooling.DecompressZstd(data)
+	default:
+		return nil, fmt.Errorf("unknown compression type %#x", typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(out)) != size {
+		return nil, corruptionf("block decompressed to %d bytes instead of %d", len(out), size)
+	}
+	return out, nil
+}
+
+// decodeLZ4Block decodes an LZ4 block (not a frame) of size bytes.
+func decodeLZ4Block(src []byte, size int) ([]byte, error) {
+	dst := make([]byte, 0, size)
+	length := func(l int) (int, error) {
+		if l != 15 {
+			return l, nil
+		}
+		for {
+			if len(src) == 0 {
+				return 0, corruptionf("truncated lz4 block")
+			}
+			b := src[0]
+			src = src[1:]
+			l += int(b)
+			if b != 255 {
+				return l, nil
+			}
+		}
+	}
+	for len(src) > 0 {
+		token := src[0]
+		src = src[1:]
+		literals, err := length(int(token >> 4))
+		if err != nil {
+			return nil, err
+		}
+		if literals > len(src) || len(dst)+literals > size {
+			return nil, corruptionf("invalid lz4 literals")
+		}
+		dst = append(dst, src[:literals]...)
+		src = src[literals:]
+		if len(src) == 0 {
+			// the last sequence has no match.
+			break
+		}
+		if len(src) < 2 {
+			return nil, corruptionf("truncated lz4 block")
+		}
+		offset := int(binary.LittleEndian.Uint16(src))
+		src = src[2:]
+		match, err := length(int(token & 15))
+		if err != nil {
+			return nil, err
+		}
+		match += 4
+		if offset == 0 || offset > len(dst) || len(dst)+match > size {
+			return nil, corruptionf("invalid lz4 match")
+		}
+		// the match can overlap the bytes it produces.
+		start := len(dst) - offset
+		for i := 0; i < match; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+	return dst, nil
+}
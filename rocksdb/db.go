@@ -0,0 +1,234 @@
+// Package rocksdb reads rocksdb databases, such as the ledgers of the Solana validators,
+// without a binding to the rocksdb library.
+//
+// It opens a database read-only, as of the last MANIFEST and the WAL files: the
+// block-based tables (every format version up to 6, every checksum type, and the snappy,
+// zlib, bzip2, lz4 and zstd compressions) and the entries of the WAL not flushed yet.
+// The database can be a directory, or an uncompressed tar archive read in place.
+//
+// Only what a ledger uses is supported: the bytewise comparator, values, deletions and
+// range deletions. Merge operands, blob files, user-defined timestamps and wide columns
+// are reported as errors. Bloom filters are not used.
+package rocksdb
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned by Get when the key is not in the column family.
+var ErrNotFound = errors.New("not found")
+
+const (
+	// tableCacheSize is the number of tables kept open.
+	tableCacheSize = 512
+	// blockCacheSize is the size of the data blocks kept in memory.
+	blockCacheSize = 64 << 20
+)
+
+// DB is a read-only rocksdb database. It is safe for concurrent use, but its iterators
+// are not.
+type DB struct {
+	storage        storage
+	columnFamilies map[string]*columnFamily
+	tables         *tableCache
+}
+
+// Open opens the database at path: a directory with a CURRENT file, a Solana ledger
+// directory (whose database is its "rocksdb" directory), or an uncompressed tar archive of
+// either. A compressed archive is reported with ErrCompressedArchive.
+func Open(path string) (*DB, error) {
+	s, err := openStorage(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := open(s)
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to open rocksdb database %q: %w", path, err)
+	}
+	return db, nil
+}
+
+func open(s storage) (*DB, error) {
+	f, err := s.open(currentFile)
+	if err != nil {
+		return nil, err
+	}
+	current, err := io.ReadAll(io.NewSectionReader(f, 0, f.Size()))
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(string(current), "\n")
+	number, err := strconv.ParseUint(strings.TrimPrefix(name, "MANIFEST-"), 10, 64)
+	if !strings.HasPrefix(name, "MANIFEST-") || err != nil {
+		return nil, corruptionf("invalid %s file %q", currentFile, current)
+	}
+	f, err = s.open(name)
+	if err != nil {
+		return nil, err
+	}
+	v, err := readManifest(bufio.NewReader(io.NewSectionReader(f, 0, f.Size())), number)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := replayWALs(s, v); err != nil {
+		return nil, err
+	}
+	db := &DB{
+		storage:        s,
+		columnFamilies: make(map[string]*columnFamily, len(v.columnFamilies)),
+		tables:         newTableCache(s, newBlockCache(blockCacheSize), tableCacheSize),
+	}
+	for _, cf := range v.columnFamilies {
+		db.columnFamilies[cf.name] = cf
+	}
+	return db, nil
+}
+
+func (db *DB) Close() error {
+	return errors.Join(db.tables.Close(), db.storage.Close())
+}
+
+// ColumnFamilies returns the names of the column families, sorted.
+func (db *DB) ColumnFamilies() []string {
+	names := make([]string, 0, len(db.columnFamilies))
+	for name := range db.columnFamilies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasColumnFamily tells whether the database has a column family.
+func (db *DB) HasColumnFamily(name string) bool {
+	_, ok := db.columnFamilies[name]
+	return ok
+}
+
+func (db *DB) columnFamily(name string) (*columnFamily, error) {
+	cf, ok := db.columnFamilies[name]
+	if !ok {
+		return nil, fmt.Errorf("no column family %q", name)
+	}
+	return cf, nil
+}
+
+// Get returns the value of a key, or ErrNotFound.
+func (db *DB) Get(columnFamily string, key []byte) ([]byte, error) {
+	cf, err := db.columnFamily(columnFamily)
+	if err != nil {
+		return nil, err
+	}
+	seekKey := makeSeekKey(key)
+	// the newest entry of the key, in the memtable or any table.
+	var newest []byte
+	var value []byte
+	consider := func(it internalIterator) error {
+		if !it.valid() {
+			return it.error()
+		}
+		if !bytes.Equal(userKey(it.key()), key) {
+			return nil
+		}
+		if newest == nil || compareInternalKeys(it.key(), newest) < 0 {
+			newest = append(newest[:0], it.key()...)
+			value = append(value[:0], it.value()...)
+		}
+		return nil
+	}
+	mem := &memIter{mem: cf.mem}
+	mem.seek(seekKey)
+	if err := consider(mem); err != nil {
+		return nil, err
+	}
+	for level, files := range cf.levels {
+		for _, file := range overlapping(level, files, key) {
+			t, err := db.tables.acquire(file)
+			if err != nil {
+				return nil, err
+			}
+			it := &tableIter{t: t}
+			it.seek(seekKey)
+			err = consider(it)
+			db.tables.release(t)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if newest == nil {
+		return nil, ErrNotFound
+	}
+	_, seq, typ, err := parseInternalKey(newest)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case typeValue:
+	case typeDeletion, typeSingleDeletion:
+		return nil, ErrNotFound
+	default:
+		return nil, unsupportedType(typ)
+	}
+	covered, err := db.covered(cf, key, seq)
+	if err != nil {
+		return nil, err
+	}
+	if covered {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+// covered tells whether a range deletion deletes the entry of key with seq. The range
+// deletions of a table are within its key range.
+func (db *DB) covered(cf *columnFamily, key []byte, seq uint64) (bool, error) {
+	if cf.mem.covers(key, seq) {
+		return true, nil
+	}
+	for level, files := range cf.levels {
+		for _, file := range overlapping(level, files, key) {
+			t, err := db.tables.acquire(file)
+			if err != nil {
+				return false, err
+			}
+			covers := t.covers(key, seq)
+			db.tables.release(t)
+			if covers {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// overlapping returns the files of a level whose key range contains key.
+func overlapping(level int, files []*fileMeta, key []byte) []*fileMeta {
+	if level == 0 {
+		var found []*fileMeta
+		for _, file := range files {
+			if file.overlaps(key) {
+				found = append(found, file)
+			}
+		}
+		return found
+	}
+	// the files of the other levels are sorted and don't overlap.
+	i := sort.Search(len(files), func(i int) bool {
+		return bytes.Compare(userKey(files[i].largest), key) >= 0
+	})
+	j := i
+	for j < len(files) && files[j].overlaps(key) {
+		j++
+	}
+	return files[i:j]
+}
@@ -0,0 +1,320 @@
+package rocksdb
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"sort"
+)
+
+// internalIterator iterates over entries in internal key order. The key and the value are
+// only valid until the iterator moves.
+type internalIterator interface {
+	first()
+	seek(key []byte)
+	next()
+	valid() bool
+	key() []byte
+	value() []byte
+	error() error
+	close()
+}
+
+type memIter struct {
+	mem *memtable
+	i   int
+}
+
+func (it *memIter) first()          { it.i = 0 }
+func (it *memIter) seek(key []byte) { it.i = it.mem.seek(key) }
+func (it *memIter) next()           { it.i++ }
+func (it *memIter) valid() bool     { return it.i < len(it.mem.entries) }
+func (it *memIter) key() []byte     { return it.mem.entries[it.i].key }
+func (it *memIter) value() []byte   { return it.mem.entries[it.i].value }
+func (it *memIter) error() error    { return nil }
+func (it *memIter) close()          {}
+
+// levelIter iterates over the files of a level after 0, which don't overlap and are sorted.
+// It only keeps the current table acquired.
+type levelIter struct {
+	tables *tableCache
+	files  []*fileMeta
+	i      int
+	t      *table
+	iter   *tableIter
+	err    error
+}
+
+func (it *levelIter) load(i int) bool {
+	it.close()
+	it.i = i
+	if i >= len(it.files) {
+		return false
+	}
+	t, err := it.tables.acquire(it.files[i])
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.t = t
+	it.iter = &tableIter{t: t}
+	return true
+}
+
+func (it *levelIter) skipEmpty() {
+	for it.iter != nil && !it.iter.valid() {
+		if it.err = it.iter.error(); it.err != nil {
+			it.close()
+			return
+		}
+		if it.load(it.i + 1) {
+			it.iter.first()
+		}
+	}
+}
+
+func (it *levelIter) first() {
+	if it.load(0) {
+		it.iter.first()
+	}
+	it.skipEmpty()
+}
+
+func (it *levelIter) seek(key []byte) {
+	// the first file whose largest key is at or after key.
+	i := sort.Search(len(it.files), func(i int) bool {
+		return compareInternalKeys(it.files[i].largest, key) >= 0
+	})
+	if it.load(i) {
+		it.iter.seek(key)
+	}
+	it.skipEmpty()
+}
+
+func (it *levelIter) next() {
+	it.iter.next()
+	it.skipEmpty()
+}
+
+func (it *levelIter) valid() bool   { return it.err == nil && it.iter != nil && it.iter.valid() }
+func (it *levelIter) key() []byte   { return it.iter.key() }
+func (it *levelIter) value() []byte { return it.iter.value() }
+func (it *levelIter) error() error  { return it.err }
+
+func (it *levelIter) close() {
+	if it.t != nil {
+		it.tables.release(it.t)
+		it.t, it.iter = nil, nil
+	}
+}
+
+// tableIterCloser releases the table of a tableIter of a level 0 file.
+type tableIterCloser struct {
+	*tableIter
+	tables *tableCache
+}
+
+func (it *tableIterCloser) close() {
+	it.tables.release(it.t)
+}
+
+// mergingIter merges the entries of several iterators.
+type mergingIter struct {
+	children []internalIterator
+	heap     iterHeap
+	err      error
+}
+
+type iterHeap []internalIterator
+
+func (h iterHeap) Len() int           { return len(h) }
+func (h iterHeap) Less(i, j int) bool { return compareInternalKeys(h[i].key(), h[j].key()) < 0 }
+func (h iterHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *iterHeap) Push(x any)        { *h = append(*h, x.(internalIterator)) }
+func (h *iterHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+func (it *mergingIter) init() {
+	it.heap = it.heap[:0]
+	for _, child := range it.children {
+		if child.valid() {
+			it.heap = append(it.heap, child)
+		} else if err := child.error(); err != nil && it.err == nil {
+			it.err = err
+		}
+	}
+	heap.Init(&it.heap)
+}
+
+func (it *mergingIter) first() {
+	for _, child := range it.children {
+		child.first()
+	}
+	it.init()
+}
+
+func (it *mergingIter) seek(key []byte) {
+	for _, child := range it.children {
+		child.seek(key)
+	}
+	it.init()
+}
+
+func (it *mergingIter) next() {
+	top := it.heap[0]
+	top.next()
+	if top.valid() {
+		heap.Fix(&it.heap, 0)
+		return
+	}
+	if err := top.error(); err != nil && it.err == nil {
+		it.err = err
+	}
+	heap.Pop(&it.heap)
+}
+
+func (it *mergingIter) valid() bool   { return it.err == nil && len(it.heap) > 0 }
+func (it *mergingIter) key() []byte   { return it.heap[0].key() }
+func (it *mergingIter) value() []byte { return it.heap[0].value() }
+func (it *mergingIter) error() error  { return it.err }
+
+func (it *mergingIter) close() {
+	for _, child := range it.children {
+		child.close()
+	}
+}
+
+// Iterator iterates over the live keys of a column family, in ascending order.
+type Iterator struct {
+	db     *DB
+	cf     *columnFamily
+	merged *mergingIter
+	key    []byte
+	value  []byte
+	valid  bool
+	err    error
+}
+
+// NewIterator returns an iterator over a column family; it must be closed. It starts
+// unpositioned: call SeekToFirst or Seek.
+func (db *DB) NewIterator(columnFamily string) (*Iterator, error) {
+	cf, err := db.columnFamily(columnFamily)
+	if err != nil {
+		return nil, err
+	}
+	merged := &mergingIter{children: []internalIterator{&memIter{mem: cf.mem}}}
+	for level, files := range cf.levels {
+		if level > 0 {
+			merged.children = append(merged.children, &levelIter{tables: db.tables, files: files})
+			continue
+		}
+		for _, file := range files {
+			t, err := db.tables.acquire(file)
+			if err != nil {
+				merged.close()
+				return nil, err
+			}
+			merged.children = append(merged.children, &tableIterCloser{tableIter: &tableIter{t: t}, tables: db.tables})
+		}
+	}
+	return &Iterator{db: db, cf: cf, merged: merged}, nil
+}
+
+// SeekToFirst moves to the first key.
+func (it *Iterator) SeekToFirst() {
+	it.merged.first()
+	it.findNext(nil, false)
+}
+
+// Seek moves to the first key at or after key.
+func (it *Iterator) Seek(key []byte) {
+	it.merged.seek(makeSeekKey(key))
+	it.findNext(nil, false)
+}
+
+// Next moves to the next key.
+func (it *Iterator) Next() {
+	if !it.valid {
+		return
+	}
+	it.findNext(it.key, true)
+}
+
+// findNext moves to the newest visible entry of the next user key, skipping the entries
+// of skip.
+func (it *Iterator) findNext(skip []byte, skipping bool) {
+	it.valid = false
+	skip = append([]byte(nil), skip...)
+	for ; it.merged.valid(); it.merged.next() {
+		key, seq, typ, err := parseInternalKey(it.merged.key())
+		if err != nil {
+			it.err = err
+			return
+		}
+		if skipping && bytes.Equal(key, skip) {
+			continue
+		}
+		// the older entries of the key are hidden by this one.
+		skip, skipping = append(skip[:0], key...), true
+		switch typ {
+		case typeValue:
+			covered, err := it.db.covered(it.cf, key, seq)
+			if err != nil {
+				it.err = err
+				return
+			}
+			if covered {
+				continue
+			}
+			it.key = append(it.key[:0], key...)
+			it.value = append(it.value[:0], it.merged.value()...)
+			it.valid = true
+			return
+		case typeDeletion, typeSingleDeletion:
+		default:
+			it.err = unsupportedType(typ)
+			return
+		}
+	}
+	it.err = it.merged.error()
+}
+
+func unsupportedType(typ valueType) error {
+	switch typ {
+	case typeMerge:
+		return fmt.Errorf("merge operands are not supported")
+	case typeBlobIndex:
+		return fmt.Errorf("blob values are not supported")
+	case typeDeletionWithTimestamp:
+		return fmt.Errorf("user-defined timestamps are not supported")
+	}
+	return corruptionf("unknown entry type %#x", typ)
+}
+
+// Valid tells whether the iterator is at a key; it is not at the end and after an error.
+func (it *Iterator) Valid() bool {
+	return it.valid && it.err == nil
+}
+
+// Key returns the current key; it is only valid until the iterator moves.
+func (it *Iterator) Key() []byte {
+	return it.key
+}
+
+// Value returns the current value; it is only valid until the iterator moves.
+func (it *Iterator) Value() []byte {
+	return it.value
+}
+
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+func (it *Iterator) Close() error {
+	it.merged.close()
+	return nil
+}
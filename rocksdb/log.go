@@ -0,0 +1,148 @@
+package rocksdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// The MANIFEST and the WAL are logs: a sequence of 32KiB blocks of fragments, each with a
+// header (masked CRC32c of the type and the payload, payload length, type). The recyclable
+// fragment types have the number of the log in their header, so that the fragments left by
+// a previous use of the file are not read.
+
+const (
+	logBlockSize            = 32 * 1024
+	logHeaderSize           = 7
+	logRecyclableHeaderSize = 11
+)
+
+// Fragment types.
+const (
+	logZeroType   = 0
+	logFullType   = 1
+	logFirstType  = 2
+	logMiddleType = 3
+	logLastType   = 4
+	// 5 to 8 are the recyclable variants of 1 to 4.
+	logRecyclableFullType = 5
+	logRecyclableLastType = 8
+	logCompressionType    = 9
+	// the user-defined timestamp size records are metadata, not records.
+	logTimestampSizeType           = 10
+	logRecyclableTimestampSizeType = 11
+)
+
+// errTruncatedLog is returned at a partial fragment at the end of a log, which happens
+// when the writer stopped in the middle of a write.
+var errTruncatedLog = errors.New("truncated log")
+
+type logReader struct {
+	r      io.Reader
+	number uint64
+	block  []byte
+	buf    []byte
+	eof    bool
+}
+
+func newLogReader(r io.Reader, number uint64) *logReader {
+	return &logReader{r: r, number: number, block: make([]byte, logBlockSize)}
+}
+
+// next returns the next record of the log, and io.EOF at its end. The record is only valid
+// until the next call.
+func (l *logReader) next() ([]byte, error) {
+	var record []byte
+	inRecord := false
+	for {
+		fragment, typ, err := l.fragment()
+		if err != nil {
+			if errors.Is(err, io.EOF) && inRecord {
+				return nil, errTruncatedLog
+			}
+			return nil, err
+		}
+		switch typ {
+		case logFullType:
+			if inRecord {
+				return nil, corruptionf("full fragment in the middle of a record")
+			}
+			return fragment, nil
+		case logFirstType:
+			if inRecord {
+				return nil, corruptionf("first fragment in the middle of a record")
+			}
+			record = append(record[:0], fragment...)
+			inRecord = true
+		case logMiddleType, logLastType:
+			if !inRecord {
+				return nil, corruptionf("fragment out of a record")
+			}
+			record = append(record, fragment...)
+			if typ == logLastType {
+				return record, nil
+			}
+		case logTimestampSizeType, logRecyclableTimestampSizeType:
+		case logCompressionType:
+			return nil, fmt.Errorf("compressed logs are not supported")
+		default:
+			return nil, corruptionf("unknown log fragment type %d", typ)
+		}
+	}
+}
+
+func (l *logReader) fragment() ([]byte, byte, error) {
+	for {
+		if len(l.buf) < logHeaderSize {
+			// the rest of the block is padding.
+			if l.eof {
+				return nil, 0, io.EOF
+			}
+			n, err := io.ReadFull(l.r, l.block)
+			switch {
+			case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+				l.eof = true
+			case err != nil:
+				return nil, 0, err
+			}
+			l.buf = l.block[:n]
+			continue
+		}
+		length := int(binary.LittleEndian.Uint16(l.buf[4:6]))
+		typ := l.buf[6]
+		if typ == logZeroType && length == 0 {
+			// preallocated space.
+			l.buf = nil
+			continue
+		}
+		headerSize := logHeaderSize
+		recyclable := typ >= logRecyclableFullType && typ <= logRecyclableLastType || typ == logRecyclableTimestampSizeType
+		if recyclable {
+			headerSize = logRecyclableHeaderSize
+		}
+		if headerSize+length > len(l.buf) {
+			if l.eof {
+				return nil, 0, errTruncatedLog
+			}
+			return nil, 0, corruptionf("log fragment crosses a block")
+		}
+		want := unmaskCRC(binary.LittleEndian.Uint32(l.buf[0:4]))
+		if crc32.Checksum(l.buf[6:headerSize+length], crc32cTable) != want {
+			return nil, 0, corruptionf("log fragment checksum mismatch")
+		}
+		fragment := l.buf[headerSize : headerSize+length]
+		if recyclable {
+			if binary.LittleEndian.Uint32(l.buf[7:11]) != uint32(l.number) {
+				// left by a previous use of the file.
+				return nil, 0, io.EOF
+			}
+			if typ != logRecyclableTimestampSizeType {
+				typ -= logRecyclableFullType - logFullType
+			}
+		}
+		l.buf = l.buf[headerSize+length:]
+		return fragment, typ, nil
+	}
+}
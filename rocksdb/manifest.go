@@ -0,0 +1,282 @@
+package rocksdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Tags of the fields of a version edit, the records of the MANIFEST.
+const (
+	tagComparator         = 1
+	tagLogNumber          = 2
+	tagNextFileNumber     = 3
+	tagLastSequence       = 4
+	tagCompactCursor      = 5
+	tagDeletedFile        = 6
+	tagNewFile            = 7
+	tagPrevLogNumber      = 9
+	tagMinLogNumberToKeep = 10
+	tagNewFile2           = 100
+	tagNewFile3           = 102
+	tagNewFile4           = 103
+	tagColumnFamily       = 200
+	tagColumnFamilyAdd    = 201
+	tagColumnFamilyDrop   = 202
+	tagMaxColumnFamily    = 203
+	tagInAtomicGroup      = 300
+	tagBlobFileAddition   = 400
+	tagBlobFileGarbage    = 401
+	// tagSafeIgnoreMask marks the (length-prefixed) fields that older readers can skip.
+	tagSafeIgnoreMask = 1 << 13
+
+	// Tags of the custom fields of tagNewFile4.
+	newFileTagTerminate = 1
+	newFileTagPathID    = 65
+	// newFileTagNonSafeIgnoreMask marks the custom fields that a reader must understand.
+	newFileTagNonSafeIgnoreMask = 1 << 6
+)
+
+const bytewiseComparator = "leveldb.BytewiseComparator"
+
+// fileMeta is a table file of a level of a column family.
+type fileMeta struct {
+	number   uint64
+	size     uint64
+	smallest []byte
+	largest  []byte
+}
+
+// overlaps tells whether the key range of the file contains userKey.
+func (f *fileMeta) overlaps(key []byte) bool {
+	return bytes.Compare(userKey(f.smallest), key) <= 0 && bytes.Compare(key, userKey(f.largest)) <= 0
+}
+
+type columnFamily struct {
+	id   uint32
+	name string
+	// logNumber is the first WAL with entries of the column family not in its tables.
+	logNumber uint64
+	// levels are the files of each level; those of the levels after 0 sorted by key.
+	levels [][]*fileMeta
+	mem    *memtable
+}
+
+type newFile struct {
+	level int
+	meta  *fileMeta
+}
+
+type deletedFile struct {
+	level  int
+	number uint64
+}
+
+type versionEdit struct {
+	comparator     string
+	columnFamily   uint32
+	addName        string
+	add, drop      bool
+	logNumber      *uint64
+	lastSequence   *uint64
+	newFiles       []newFile
+	deletedFiles   []deletedFile
+	atomicGroup    bool
+	atomicGroupLen uint32
+}
+
+func decodeVersionEdit(record []byte) (*versionEdit, error) {
+	edit := &versionEdit{}
+	d := decoder{buf: record}
+	optional := func(v uint64) *uint64 { return &v }
+	for len(d.buf) > 0 && d.err == nil {
+		tag := d.uvarint32("version edit tag")
+		switch tag {
+		case tagComparator:
+			edit.comparator = string(d.lengthPrefixed("comparator"))
+		case tagLogNumber:
+			edit.logNumber = optional(d.uvarint("log number"))
+		case tagNextFileNumber, tagPrevLogNumber, tagMinLogNumberToKeep, tagMaxColumnFamily:
+			d.uvarint("version edit field")
+		case tagLastSequence:
+			edit.lastSequence = optional(d.uvarint("last sequence"))
+		case tagCompactCursor:
+			d.uvarint32("compact cursor")
+			d.lengthPrefixed("compact cursor")
+		case tagDeletedFile:
+			level := d.uvarint32("deleted file")
+			edit.deletedFiles = append(edit.deletedFiles, deletedFile{level: int(level), number: d.uvarint("deleted file")})
+		case tagNewFile, tagNewFile2, tagNewFile3, tagNewFile4:
+			file, err := decodeNewFile(&d, tag)
+			if err != nil {
+				return nil, err
+			}
+			edit.newFiles = append(edit.newFiles, file)
+		case tagColumnFamily:
+			edit.columnFamily = d.uvarint32("column family")
+		case tagColumnFamilyAdd:
+			edit.add = true
+			edit.addName = string(d.lengthPrefixed("column family name"))
+		case tagColumnFamilyDrop:
+			edit.drop = true
+		case tagInAtomicGroup:
+			edit.atomicGroup = true
+			edit.atomicGroupLen = d.uvarint32("atomic group")
+		case tagBlobFileAddition, tagBlobFileGarbage:
+			return nil, fmt.Errorf("blob files are not supported")
+		default:
+			if tag&tagSafeIgnoreMask == 0 {
+				return nil, fmt.Errorf("unknown version edit tag %d", tag)
+			}
+			d.lengthPrefixed("version edit field")
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return edit, nil
+}
+
+func decodeNewFile(d *decoder, tag uint32) (newFile, error) {
+	level := d.uvarint32("new file")
+	meta := &fileMeta{number: d.uvarint("new file")}
+	if tag == tagNewFile3 {
+		if pathID := d.uvarint32("new file"); pathID != 0 && d.err == nil {
+			return newFile{}, fmt.Errorf("file %d is in another path (%d), which is not supported", meta.number, pathID)
+		}
+	}
+	meta.size = d.uvarint("new file")
+	// the record is only valid until the next one is read.
+	meta.smallest = append([]byte(nil), d.lengthPrefixed("new file smallest key")...)
+	meta.largest = append([]byte(nil), d.lengthPrefixed("new file largest key")...)
+	if tag != tagNewFile {
+		d.uvarint("new file smallest sequence")
+		d.uvarint("new file largest sequence")
+	}
+	if tag == tagNewFile4 {
+		for d.err == nil {
+			field := d.uvarint32("new file field")
+			if field == newFileTagTerminate {
+				break
+			}
+			value := d.lengthPrefixed("new file field")
+			switch {
+			case field == newFileTagPathID:
+				if len(value) != 1 || value[0] != 0 {
+					return newFile{}, fmt.Errorf("file %d is in another path, which is not supported", meta.number)
+				}
+			case field&newFileTagNonSafeIgnoreMask != 0:
+				return newFile{}, fmt.Errorf("unknown field %d of file %d", field, meta.number)
+			}
+		}
+	}
+	if d.err == nil && (len(meta.smallest) < internalKeyTrailerSize || len(meta.largest) < internalKeyTrailerSize) {
+		d.fail("new file key range")
+	}
+	return newFile{level: int(level), meta: meta}, d.err
+}
+
+// version is the state of the database recorded in the MANIFEST.
+type version struct {
+	columnFamilies map[uint32]*columnFamily
+	lastSequence   uint64
+}
+
+// readManifest replays the version edits of the MANIFEST.
+func readManifest(r io.Reader, number uint64) (*version, error) {
+	v := &version{columnFamilies: map[uint32]*columnFamily{
+		0: {id: 0, name: "default"},
+	}}
+	var group []*versionEdit
+	log := newLogReader(r, number)
+	for {
+		record, err := log.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		edit, err := decodeVersionEdit(record)
+		if err != nil {
+			return nil, err
+		}
+		if !edit.atomicGroup {
+			if len(group) > 0 {
+				return nil, corruptionf("incomplete atomic group")
+			}
+			if err := v.apply(edit); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// the edits of an atomic group are applied together, once all of them are read.
+		group = append(group, edit)
+		if edit.atomicGroupLen == 0 {
+			for _, edit := range group {
+				if err := v.apply(edit); err != nil {
+					return nil, err
+				}
+			}
+			group = nil
+		}
+	}
+	for _, cf := range v.columnFamilies {
+		for level := 1; level < len(cf.levels); level++ {
+			files := cf.levels[level]
+			sort.Slice(files, func(i, j int) bool {
+				return compareInternalKeys(files[i].smallest, files[j].smallest) < 0
+			})
+		}
+	}
+	return v, nil
+}
+
+func (v *version) apply(edit *versionEdit) error {
+	if edit.comparator != "" && edit.comparator != bytewiseComparator {
+		return fmt.Errorf("comparator %q is not supported", edit.comparator)
+	}
+	if edit.lastSequence != nil {
+		v.lastSequence = *edit.lastSequence
+	}
+	if edit.add {
+		v.columnFamilies[edit.columnFamily] = &columnFamily{id: edit.columnFamily, name: edit.addName}
+	}
+	cf, ok := v.columnFamilies[edit.columnFamily]
+	if !ok {
+		return corruptionf("edit of unknown column family %d", edit.columnFamily)
+	}
+	if edit.drop {
+		delete(v.columnFamilies, edit.columnFamily)
+		return nil
+	}
+	if edit.logNumber != nil {
+		cf.logNumber = *edit.logNumber
+	}
+	for _, deleted := range edit.deletedFiles {
+		if deleted.level >= len(cf.levels) {
+			return corruptionf("deleted file %d not in level %d", deleted.number, deleted.level)
+		}
+		files := cf.levels[deleted.level]
+		found := false
+		for i, file := range files {
+			if file.number == deleted.number {
+				cf.levels[deleted.level] = append(files[:i], files[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return corruptionf("deleted file %d not in level %d", deleted.number, deleted.level)
+		}
+	}
+	for _, file := range edit.newFiles {
+		for len(cf.levels) <= file.level {
+			cf.levels = append(cf.levels, nil)
+		}
+		cf.levels[file.level] = append(cf.levels[file.level], file.meta)
+	}
+	return nil
+}
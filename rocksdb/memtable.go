@@ -0,0 +1,234 @@
+package rocksdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// A write batch (a record of the WAL) is the sequence number of its first entry, its
+// number of entries, then its records: a type, the column family for the CF types, then
+// the key and the value (or the end of the range), length-prefixed. Every entry takes
+// the next sequence number.
+const batchHeaderSize = 12
+
+// Types of the records of a write batch.
+const (
+	batchDeletion                 = 0x0
+	batchValue                    = 0x1
+	batchMerge                    = 0x2
+	batchLogData                  = 0x3
+	batchCFDeletion               = 0x4
+	batchCFValue                  = 0x5
+	batchCFMerge                  = 0x6
+	batchSingleDeletion           = 0x7
+	batchCFSingleDeletion         = 0x8
+	batchBeginPrepareXID          = 0x9
+	batchEndPrepareXID            = 0xa
+	batchCommitXID                = 0xb
+	batchRollbackXID              = 0xc
+	batchNoop                     = 0xd
+	batchCFRangeDeletion          = 0xe
+	batchRangeDeletion            = 0xf
+	batchCFBlobIndex              = 0x10
+	batchBlobIndex                = 0x11
+	batchBeginPersistedPrepareXID = 0x12
+	batchCFDeletionWithTimestamp  = 0x13
+	batchDeletionWithTimestamp    = 0x14
+	batchBeginUnprepareXID        = 0x15
+	batchCFWideColumnEntity       = 0x16
+	batchWideColumnEntity         = 0x17
+)
+
+const walExtension = ".log"
+
+type memEntry struct {
+	key   []byte
+	value []byte
+}
+
+// memtable is the part of a column family in the WAL, not flushed to a table yet.
+type memtable struct {
+	// entries are sorted by internal key.
+	entries    []memEntry
+	tombstones []tombstone
+}
+
+func (m *memtable) seek(key []byte) int {
+	return sort.Search(len(m.entries), func(i int) bool {
+		return compareInternalKeys(m.entries[i].key, key) >= 0
+	})
+}
+
+func (m *memtable) covers(key []byte, seq uint64) bool {
+	for _, ts := range m.tombstones {
+		if ts.seq > seq && bytes.Compare(ts.start, key) <= 0 && bytes.Compare(key, ts.end) < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// walNumbers returns the numbers of the WAL files, in ascending order.
+func walNumbers(names []string) []uint64 {
+	var numbers []uint64
+	for _, name := range names {
+		var number uint64
+		if n, err := fmt.Sscanf(name, "%d"+walExtension, &number); err == nil && n == 1 && name == fmt.Sprintf("%06d%s", number, walExtension) {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers
+}
+
+// replayWALs builds the memtables of the column families from the WAL files, as rocksdb
+// does when it opens a database: the write batches of a log are applied to the column
+// families whose tables don't have them yet. As with the point-in-time recovery of
+// rocksdb, the replay stops at the first corrupted or truncated batch, and at the first
+// gap in the sequence numbers.
+func replayWALs(s storage, v *version) error {
+	names, err := s.list()
+	if err != nil {
+		return err
+	}
+	minLog := uint64(1<<64 - 1)
+	for _, cf := range v.columnFamilies {
+		cf.mem = &memtable{}
+		minLog = min(minLog, cf.logNumber)
+	}
+	nextSeq := uint64(0)
+	for _, number := range walNumbers(names) {
+		if number < minLog {
+			continue
+		}
+		f, err := s.open(fmt.Sprintf("%06d%s", number, walExtension))
+		if err != nil {
+			return err
+		}
+		complete, err := replayWAL(f, number, v, &nextSeq)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("WAL %06d: %w", number, err)
+		}
+		if !complete {
+			break
+		}
+	}
+	for _, cf := range v.columnFamilies {
+		sort.Slice(cf.mem.entries, func(i, j int) bool {
+			return compareInternalKeys(cf.mem.entries[i].key, cf.mem.entries[j].key) < 0
+		})
+	}
+	return nil
+}
+
+// replayWAL applies the write batches of a WAL, and tells whether all of them were applied.
+func replayWAL(f file, number uint64, v *version, nextSeq *uint64) (bool, error) {
+	log := newLogReader(io.NewSectionReader(f, 0, f.Size()), number)
+	for {
+		record, err := log.next()
+		if errors.Is(err, io.EOF) {
+			return true, nil
+		}
+		if errors.Is(err, errCorruption) || errors.Is(err, errTruncatedLog) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if len(record) < batchHeaderSize {
+			return false, nil
+		}
+		if seq := binary.LittleEndian.Uint64(record); *nextSeq != 0 && seq != *nextSeq {
+			return false, nil
+		}
+		next, err := applyBatch(record, number, v.columnFamilies)
+		if err != nil || next == 0 {
+			return false, err
+		}
+		*nextSeq = next
+		v.lastSequence = max(v.lastSequence, next-1)
+	}
+}
+
+// applyBatch applies a write batch to the memtables, and returns the sequence number after
+// it; it returns 0 for a corrupted batch, and an error for a batch that can't be read.
+func applyBatch(record []byte, logNumber uint64, cfs map[uint32]*columnFamily) (uint64, error) {
+	seq := binary.LittleEndian.Uint64(record)
+	count := binary.LittleEndian.Uint32(record[8:])
+	// the record is only valid until the next one is read.
+	d := decoder{buf: append([]byte(nil), record[batchHeaderSize:]...)}
+	var found uint32
+	var pending []func()
+	for len(d.buf) > 0 && d.err == nil {
+		tag := d.byte("batch record")
+		var cfID uint32
+		switch tag {
+		case batchCFDeletion, batchCFValue, batchCFMerge, batchCFSingleDeletion, batchCFRangeDeletion:
+			cfID = d.uvarint32("batch column family")
+		}
+		var typ valueType
+		var key, value []byte
+		switch tag {
+		case batchValue, batchCFValue:
+			typ = typeValue
+			key, value = d.lengthPrefixed("batch key"), d.lengthPrefixed("batch value")
+		case batchMerge, batchCFMerge:
+			typ = typeMerge
+			key, value = d.lengthPrefixed("batch key"), d.lengthPrefixed("batch value")
+		case batchDeletion, batchCFDeletion:
+			typ = typeDeletion
+			key = d.lengthPrefixed("batch key")
+		case batchSingleDeletion, batchCFSingleDeletion:
+			typ = typeSingleDeletion
+			key = d.lengthPrefixed("batch key")
+		case batchRangeDeletion, batchCFRangeDeletion:
+			typ = typeRangeDeletion
+			key, value = d.lengthPrefixed("batch key"), d.lengthPrefixed("batch range end")
+		case batchLogData:
+			d.lengthPrefixed("batch log data")
+			continue
+		case batchNoop:
+			continue
+		case batchBlobIndex, batchCFBlobIndex:
+			return 0, fmt.Errorf("blob values are not supported")
+		case batchDeletionWithTimestamp, batchCFDeletionWithTimestamp:
+			return 0, fmt.Errorf("user-defined timestamps are not supported")
+		case batchWideColumnEntity, batchCFWideColumnEntity:
+			return 0, fmt.Errorf("wide column entities are not supported")
+		case batchBeginPrepareXID, batchEndPrepareXID, batchCommitXID, batchRollbackXID, batchBeginPersistedPrepareXID, batchBeginUnprepareXID:
+			return 0, fmt.Errorf("two-phase commit batches are not supported")
+		default:
+			return 0, nil
+		}
+		if d.err != nil {
+			break
+		}
+		entrySeq := seq + uint64(found)
+		found++
+		cf, ok := cfs[cfID]
+		if !ok || logNumber < cf.logNumber {
+			// a dropped column family, or entries already in the tables.
+			continue
+		}
+		pending = append(pending, func() {
+			if typ == typeRangeDeletion {
+				cf.mem.tombstones = append(cf.mem.tombstones, tombstone{start: key, end: value, seq: entrySeq})
+				return
+			}
+			cf.mem.entries = append(cf.mem.entries, memEntry{key: makeInternalKey(nil, key, entrySeq, typ), value: value})
+		})
+	}
+	if d.err != nil || found != count {
+		return 0, nil
+	}
+	// a batch is applied whole, or not at all.
+	for _, apply := range pending {
+		apply()
+	}
+	return seq + uint64(count), nil
+}
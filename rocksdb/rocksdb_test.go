@@ -0,0 +1,338 @@
+package rocksdb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/rocksdb/rocksdbtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXXHash32(t *testing.T) {
+	require.Equal(t, uint32(0x02cc5d05), xxhash32(nil, 0))
+	require.Equal(t, uint32(0x32d153ff), xxhash32([]byte("abc"), 0))
+	require.Equal(t, uint32(0xe2293b2f), xxhash32([]byte("Nobody inspects the spammish repetition"), 0))
+}
+
+func TestDecodeLZ4Block(t *testing.T) {
+	// "abc", then a match of 9 bytes at offset 3 (overlapping its output), then "X".
+	block := []byte{0x35, 'a', 'b', 'c', 3, 0, 0x10, 'X'}
+	got, err := decodeLZ4Block(block, 13)
+	require.NoError(t, err)
+	require.Equal(t, "abcabcabcabcX", string(got))
+
+	_, err = decodeLZ4Block(block, 12)
+	require.ErrorIs(t, err, errCorruption)
+	_, err = decodeLZ4Block([]byte{0x35, 'a', 'b', 'c', 0, 0, 0x10, 'X'}, 13)
+	require.ErrorIs(t, err, errCorruption)
+	_, err = decodeLZ4Block([]byte{0x35, 'a', 'b', 'c', 3}, 13)
+	require.ErrorIs(t, err, errCorruption)
+}
+
+func key(i int) []byte {
+	return []byte(fmt.Sprintf("k%03d", i))
+}
+
+// buildTestDB writes a database with the column families "a" and "b", whose "a" has
+// entries in two level 1 tables, a level 0 table and two WAL files; it returns the
+// expected contents of the column families.
+func buildTestDB(t *testing.T, dir string, opts rocksdbtest.TableOptions) map[string]map[string]string {
+	b, err := rocksdbtest.NewBuilder(dir, "a", "b")
+	require.NoError(t, err)
+	want := map[string]map[string]string{"a": {}, "b": {}, "default": {}}
+
+	for _, r := range [][2]int{{0, 200}, {200, 400}} {
+		var entries []rocksdbtest.Entry
+		for i := r[0]; i < r[1]; i++ {
+			value := fmt.Sprintf("v1-%d", i)
+			entries = append(entries, rocksdbtest.Entry{Key: key(i), Seq: uint64(i + 1), Type: rocksdbtest.TypeValue, Value: []byte(value)})
+			want["a"][string(key(i))] = value
+		}
+		_, err := b.AddTable("a", 1, entries, opts)
+		require.NoError(t, err)
+	}
+
+	var level0 []rocksdbtest.Entry
+	add := func(seq uint64, typ rocksdbtest.ValueType, k, value []byte) {
+		level0 = append(level0, rocksdbtest.Entry{Key: k, Seq: seq, Type: typ, Value: value})
+	}
+	for i := 10; i < 20; i++ {
+		add(uint64(1000+i), rocksdbtest.TypeValue, key(i), []byte("v2"))
+		want["a"][string(key(i))] = "v2"
+	}
+	for i := 20; i < 30; i++ {
+		add(uint64(1000+i), rocksdbtest.TypeDeletion, key(i), nil)
+		delete(want["a"], string(key(i)))
+	}
+	add(1030, rocksdbtest.TypeSingleDeletion, key(30), nil)
+	delete(want["a"], string(key(30)))
+	add(1100, rocksdbtest.TypeRangeDeletion, key(100), key(150))
+	for i := 100; i < 150; i++ {
+		delete(want["a"], string(key(i)))
+	}
+	// an entry of the range deletion's table newer than it.
+	add(1101, rocksdbtest.TypeValue, key(120), []byte("after the range deletion"))
+	want["a"][string(key(120))] = "after the range deletion"
+	// several versions of the same keys.
+	add(1200, rocksdbtest.TypeValue, key(50), []byte("v3"))
+	add(1201, rocksdbtest.TypeDeletion, key(50), nil)
+	delete(want["a"], string(key(50)))
+	add(1202, rocksdbtest.TypeValue, key(51), []byte("old"))
+	add(1203, rocksdbtest.TypeValue, key(51), []byte("new"))
+	want["a"][string(key(51))] = "new"
+	add(1204, rocksdbtest.TypeValue, key(400), []byte("v2"))
+	want["a"][string(key(400))] = "v2"
+	_, err = b.AddTable("a", 0, level0, opts)
+	require.NoError(t, err)
+
+	_, err = b.AddTable("b", 1, []rocksdbtest.Entry{{Key: []byte("x"), Seq: 1, Type: rocksdbtest.TypeValue, Value: []byte("1")}}, opts)
+	require.NoError(t, err)
+	want["b"]["x"] = "1"
+
+	_, err = b.AddWAL(
+		rocksdbtest.Batch{
+			{ColumnFamily: "a", Type: rocksdbtest.TypeValue, Key: key(0), Value: []byte("wal")},
+			{ColumnFamily: "a", Type: rocksdbtest.TypeDeletion, Key: key(1)},
+			{ColumnFamily: "a", Type: rocksdbtest.TypeRangeDeletion, Key: key(300), Value: key(310)},
+			{ColumnFamily: "b", Type: rocksdbtest.TypeValue, Key: []byte("y"), Value: []byte("2")},
+		},
+		rocksdbtest.Batch{
+			{ColumnFamily: "a", Type: rocksdbtest.TypeValue, Key: key(145), Value: []byte("resurrected")},
+			{ColumnFamily: "default", Type: rocksdbtest.TypeValue, Key: []byte("d"), Value: []byte("3")},
+		},
+	)
+	require.NoError(t, err)
+	want["a"][string(key(0))] = "wal"
+	delete(want["a"], string(key(1)))
+	for i := 300; i < 310; i++ {
+		delete(want["a"], string(key(i)))
+	}
+	want["b"]["y"] = "2"
+	want["a"][string(key(145))] = "resurrected"
+	want["default"]["d"] = "3"
+
+	// a record spanning several blocks of the log.
+	large := bytes.Repeat([]byte("large"), 20000)
+	_, err = b.AddWAL(rocksdbtest.Batch{{ColumnFamily: "a", Type: rocksdbtest.TypeValue, Key: key(500), Value: large}})
+	require.NoError(t, err)
+	want["a"][string(key(500))] = string(large)
+
+	require.NoError(t, b.Finish())
+	return want
+}
+
+func requireContents(t *testing.T, db *DB, want map[string]map[string]string) {
+	t.Helper()
+	var names []string
+	for name := range want {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	require.Equal(t, names, db.ColumnFamilies())
+
+	for cf, kvs := range want {
+		var keys []string
+		for k, v := range kvs {
+			keys = append(keys, k)
+			got, err := db.Get(cf, []byte(k))
+			require.NoError(t, err, "%s/%s", cf, k)
+			require.Equal(t, v, string(got), "%s/%s", cf, k)
+		}
+		sort.Strings(keys)
+
+		it, err := db.NewIterator(cf)
+		require.NoError(t, err)
+		var got []string
+		for it.SeekToFirst(); it.Valid(); it.Next() {
+			got = append(got, string(it.Key()))
+			require.Equal(t, kvs[string(it.Key())], string(it.Value()), "%s/%s", cf, it.Key())
+		}
+		require.NoError(t, it.Err())
+		require.Equal(t, keys, got, cf)
+		require.NoError(t, it.Close())
+	}
+	for _, deleted := range []int{1, 20, 30, 50, 100, 149, 305, 1000} {
+		_, err := db.Get("a", key(deleted))
+		require.ErrorIs(t, err, ErrNotFound, "%s", key(deleted))
+	}
+	_, err := db.Get("missing", key(0))
+	require.Error(t, err)
+
+	it, err := db.NewIterator("a")
+	require.NoError(t, err)
+	defer it.Close()
+	for _, seek := range []struct{ target, want string }{
+		{"k", "k000"},
+		{"k100", "k120"},
+		{"k121", "k145"},
+		{"k146", "k150"},
+		{"k300", "k310"},
+		{"k3105", "k311"},
+	} {
+		it.Seek([]byte(seek.target))
+		require.True(t, it.Valid(), seek.target)
+		require.Equal(t, seek.want, string(it.Key()), seek.target)
+	}
+	it.Seek([]byte("k501"))
+	require.False(t, it.Valid())
+	require.NoError(t, it.Err())
+}
+
+func TestDB(t *testing.T) {
+	for _, opts := range []rocksdbtest.TableOptions{
+		{FormatVersion: 0, Checksum: rocksdbtest.ChecksumCRC32c, Compression: rocksdbtest.CompressionSnappy},
+		{FormatVersion: 1, Checksum: rocksdbtest.ChecksumXXHash64, BlockSize: 256},
+		{FormatVersion: 2, Checksum: rocksdbtest.ChecksumCRC32c, Compression: rocksdbtest.CompressionZlib, BlockSize: 256},
+		{FormatVersion: 3, Checksum: rocksdbtest.ChecksumXXH3, Compression: rocksdbtest.CompressionLZ4, BlockSize: 256, RestartInterval: 4},
+		{FormatVersion: 4, Checksum: rocksdbtest.ChecksumXXH3, Compression: rocksdbtest.CompressionZstd, BlockSize: 256, IndexRestartInterval: 4},
+		{FormatVersion: 5, Checksum: rocksdbtest.ChecksumCRC32c, Compression: rocksdbtest.CompressionZstd, BlockSize: 256, PartitionedIndex: true},
+		{FormatVersion: 6, Checksum: rocksdbtest.ChecksumXXH3, Compression: rocksdbtest.CompressionZstd, BlockSize: 256, BaseContextChecksum: 0xdeadbeef},
+		rocksdbtest.DefaultTableOptions,
+	} {
+		name := fmt.Sprintf("v%d/checksum=%d/compression=%d/partitioned=%t", opts.FormatVersion, opts.Checksum, opts.Compression, opts.PartitionedIndex)
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			want := buildTestDB(t, dir, opts)
+			db, err := Open(dir)
+			require.NoError(t, err)
+			defer db.Close()
+			requireContents(t, db, want)
+		})
+	}
+}
+
+func TestTruncatedWAL(t *testing.T) {
+	dir := t.TempDir()
+	b, err := rocksdbtest.NewBuilder(dir)
+	require.NoError(t, err)
+	path, err := b.AddWAL(
+		rocksdbtest.Batch{{ColumnFamily: "default", Type: rocksdbtest.TypeValue, Key: []byte("a"), Value: []byte("1")}},
+		rocksdbtest.Batch{{ColumnFamily: "default", Type: rocksdbtest.TypeValue, Key: []byte("b"), Value: bytes.Repeat([]byte("2"), 1000)}},
+	)
+	require.NoError(t, err)
+	require.NoError(t, b.Finish())
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	// the writer stopped in the middle of the second batch.
+	require.NoError(t, os.Truncate(path, info.Size()-10))
+
+	db, err := Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+	got, err := db.Get("default", []byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, "1", string(got))
+	_, err = db.Get("default", []byte("b"))
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCorruptedBlock(t *testing.T) {
+	dir := t.TempDir()
+	b, err := rocksdbtest.NewBuilder(dir)
+	require.NoError(t, err)
+	var entries []rocksdbtest.Entry
+	for i := 0; i < 100; i++ {
+		entries = append(entries, rocksdbtest.Entry{Key: key(i), Seq: uint64(i + 1), Type: rocksdbtest.TypeValue, Value: []byte("value")})
+	}
+	path, err := b.AddTable("default", 1, entries, rocksdbtest.TableOptions{FormatVersion: 5, BlockSize: 256})
+	require.NoError(t, err)
+	require.NoError(t, b.Finish())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	// the first data block.
+	data[10] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	db, err := Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Get("default", key(0))
+	require.ErrorIs(t, err, errCorruption)
+	require.ErrorContains(t, err, "checksum mismatch")
+	got, err := db.Get("default", key(99))
+	require.NoError(t, err)
+	require.Equal(t, "value", string(got))
+
+	it, err := db.NewIterator("default")
+	require.NoError(t, err)
+	defer it.Close()
+	it.SeekToFirst()
+	require.False(t, it.Valid())
+	require.ErrorIs(t, it.Err(), errCorruption)
+}
+
+// writeTar writes the files of dir to a tar archive, under prefix.
+func writeTar(t *testing.T, w io.Writer, dir, prefix string) {
+	tw := tar.NewWriter(w)
+	require.NoError(t, filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: prefix + rel, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}))
+	require.NoError(t, tw.Close())
+}
+
+func TestOpenArchive(t *testing.T) {
+	dir := t.TempDir()
+	want := buildTestDB(t, filepath.Join(dir, "ledger", "rocksdb"), rocksdbtest.DefaultTableOptions)
+
+	t.Run("ledger directory", func(t *testing.T) {
+		db, err := Open(filepath.Join(dir, "ledger"))
+		require.NoError(t, err)
+		defer db.Close()
+		requireContents(t, db, want)
+	})
+
+	t.Run("tar", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ledger.tar")
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		writeTar(t, f, filepath.Join(dir, "ledger"), "ledger/")
+		require.NoError(t, f.Close())
+
+		db, err := Open(path)
+		require.NoError(t, err)
+		defer db.Close()
+		requireContents(t, db, want)
+	})
+
+	t.Run("compressed tar", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ledger.tar.gz")
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		zw := gzip.NewWriter(f)
+		writeTar(t, zw, filepath.Join(dir, "ledger"), "ledger/")
+		require.NoError(t, zw.Close())
+		require.NoError(t, f.Close())
+
+		_, err = Open(path)
+		require.True(t, errors.Is(err, ErrCompressedArchive), err)
+	})
+
+	t.Run("not a database", func(t *testing.T) {
+		_, err := Open(t.TempDir())
+		require.ErrorContains(t, err, "not a rocksdb database")
+	})
+}
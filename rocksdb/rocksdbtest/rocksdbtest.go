@@ -0,0 +1,677 @@
+// Package rocksdbtest writes small rocksdb databases for tests, in the formats the rocksdb
+// package reads: block-based tables of the format versions 0 to 6, a MANIFEST and WAL files.
+package rocksdbtest
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/klauspost/compress/s2"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/zeebo/xxh3"
+)
+
+// ValueType is the type of an entry.
+type ValueType byte
+
+const (
+	TypeDeletion       ValueType = 0x0
+	TypeValue          ValueType = 0x1
+	TypeMerge          ValueType = 0x2
+	TypeSingleDeletion ValueType = 0x7
+	// TypeRangeDeletion deletes the keys in [Key, Value) older than the entry.
+	TypeRangeDeletion ValueType = 0xf
+)
+
+// Entry is an entry of a table.
+type Entry struct {
+	Key   []byte
+	Seq   uint64
+	Type  ValueType
+	Value []byte
+}
+
+// BatchEntry is an entry of a write batch of the WAL.
+type BatchEntry struct {
+	ColumnFamily string
+	Type         ValueType
+	Key          []byte
+	Value        []byte
+}
+
+// Batch is a write batch: its entries take consecutive sequence numbers.
+type Batch []BatchEntry
+
+type Checksum byte
+
+const (
+	ChecksumCRC32c   Checksum = 1
+	ChecksumXXHash64 Checksum = 3
+	ChecksumXXH3     Checksum = 4
+)
+
+type Compression byte
+
+const (
+	CompressionNone   Compression = 0x0
+	CompressionSnappy Compression = 0x1
+	CompressionZlib   Compression = 0x2
+	// CompressionLZ4 writes LZ4 blocks made of literals only.
+	CompressionLZ4  Compression = 0x4
+	CompressionZstd Compression = 0x7
+)
+
+// TableOptions are the options of a table.
+type TableOptions struct {
+	// FormatVersion is the format version of the table; 0 is the legacy footer. As with
+	// rocksdb, the index has user keys since the version 3 (when no user key spans two
+	// data blocks), and delta-encoded values since the version 4.
+	FormatVersion uint32
+	Checksum      Checksum
+	// Compression is only supported by the format versions 2 and later, except snappy.
+	Compression Compression
+	// BlockSize is the size a data block is cut at (4KiB by default).
+	BlockSize int
+	// RestartInterval is the number of keys between the restart points of the data blocks
+	// (16 by default), and IndexRestartInterval that of the index blocks (1 by default).
+	RestartInterval      int
+	IndexRestartInterval int
+	// PartitionedIndex splits the index in partitions of IndexPartitionSize entries (4 by default).
+	PartitionedIndex   bool
+	IndexPartitionSize int
+	// BaseContextChecksum is the context of the block checksums of the format version 6.
+	BaseContextChecksum uint32
+}
+
+// DefaultTableOptions are the options of the tables of a recent rocksdb.
+var DefaultTableOptions = TableOptions{FormatVersion: 5, Checksum: ChecksumXXH3, Compression: CompressionZstd}
+
+type tableFile struct {
+	level              int
+	number, size       uint64
+	smallest, largest  []byte
+	smallSeq, largeSeq uint64
+}
+
+type columnFamily struct {
+	id    uint32
+	name  string
+	files []tableFile
+}
+
+// Builder writes a database to a directory: its tables and WAL files as they are added,
+// and the MANIFEST and CURRENT files by Finish.
+type Builder struct {
+	dir            string
+	columnFamilies []*columnFamily
+	nextFile       uint64
+	lastSequence   uint64
+	firstLog       uint64
+}
+
+// NewBuilder creates the directory of a database with the "default" column family and the
+// given ones.
+func NewBuilder(dir string, columnFamilies ...string) (*Builder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	b := &Builder{dir: dir, nextFile: 2, columnFamilies: []*columnFamily{{id: 0, name: "default"}}}
+	for i, name := range columnFamilies {
+		b.columnFamilies = append(b.columnFamilies, &columnFamily{id: uint32(i + 1), name: name})
+	}
+	return b, nil
+}
+
+func (b *Builder) columnFamily(name string) (*columnFamily, error) {
+	for _, cf := range b.columnFamilies {
+		if cf.name == name {
+			return cf, nil
+		}
+	}
+	return nil, fmt.Errorf("no column family %q", name)
+}
+
+// AddTable writes a table of a column family at a level, and returns its path.
+func (b *Builder) AddTable(columnFamily string, level int, entries []Entry, opts TableOptions) (string, error) {
+	cf, err := b.columnFamily(columnFamily)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("no entries")
+	}
+	number := b.nextFile
+	b.nextFile++
+	path := filepath.Join(b.dir, fmt.Sprintf("%06d.sst", number))
+	data, smallest, largest, err := buildTable(entries, opts)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	file := tableFile{level: level, number: number, size: uint64(len(data)), smallest: smallest, largest: largest, smallSeq: entries[0].Seq}
+	for _, entry := range entries {
+		file.smallSeq = min(file.smallSeq, entry.Seq)
+		file.largeSeq = max(file.largeSeq, entry.Seq)
+	}
+	b.lastSequence = max(b.lastSequence, file.largeSeq)
+	cf.files = append(cf.files, file)
+	return path, nil
+}
+
+// AddWAL writes a WAL file with the batches, and returns its path. The entries take the
+// sequence numbers after those of the tables and WAL files added before.
+func (b *Builder) AddWAL(batches ...Batch) (string, error) {
+	number := b.nextFile
+	b.nextFile++
+	if b.firstLog == 0 {
+		b.firstLog = number
+	}
+	var log logWriter
+	for _, batch := range batches {
+		record := binary.LittleEndian.AppendUint64(nil, b.lastSequence+1)
+		record = binary.LittleEndian.AppendUint32(record, uint32(len(batch)))
+		for _, entry := range batch {
+			cf, err := b.columnFamily(entry.ColumnFamily)
+			if err != nil {
+				return "", err
+			}
+			// the types of the entries of the other column families are 4 (deletion), 5
+			// (value), 6 (merge), 8 (single deletion) and 0xe (range deletion).
+			typ := byte(entry.Type)
+			if cf.id != 0 {
+				typ = map[ValueType]byte{TypeDeletion: 0x4, TypeValue: 0x5, TypeMerge: 0x6, TypeSingleDeletion: 0x8, TypeRangeDeletion: 0xe}[entry.Type]
+			}
+			record = append(record, typ)
+			if cf.id != 0 {
+				record = binary.AppendUvarint(record, uint64(cf.id))
+			}
+			record = appendLengthPrefixed(record, entry.Key)
+			if entry.Type != TypeDeletion && entry.Type != TypeSingleDeletion {
+				record = appendLengthPrefixed(record, entry.Value)
+			}
+		}
+		b.lastSequence += uint64(len(batch))
+		log.add(record)
+	}
+	path := filepath.Join(b.dir, fmt.Sprintf("%06d.log", number))
+	return path, os.WriteFile(path, log.buf.Bytes(), 0o644)
+}
+
+// Version edit tags.
+const (
+	tagComparator       = 1
+	tagLogNumber        = 2
+	tagNextFileNumber   = 3
+	tagLastSequence     = 4
+	tagNewFile4         = 103
+	tagColumnFamily     = 200
+	tagColumnFamilyAdd  = 201
+	tagMaxColumnFamily  = 203
+	tagDbID             = 1<<13 + 1
+	newFileTagTerminate = 1
+	newFileTagEpoch     = 13
+)
+
+// Finish writes the MANIFEST and the CURRENT file.
+func (b *Builder) Finish() error {
+	const manifestNumber = 1
+	logNumber := b.firstLog
+	if logNumber == 0 {
+		logNumber = b.nextFile
+	}
+	var log logWriter
+	var edit []byte
+	edit = binary.AppendUvarint(edit, tagComparator)
+	edit = appendLengthPrefixed(edit, []byte("leveldb.BytewiseComparator"))
+	// a field that readers can skip.
+	edit = binary.AppendUvarint(edit, tagDbID)
+	edit = appendLengthPrefixed(edit, []byte("rocksdbtest"))
+	log.add(edit)
+	for _, cf := range b.columnFamilies {
+		edit = binary.AppendUvarint(nil, tagColumnFamily)
+		edit = binary.AppendUvarint(edit, uint64(cf.id))
+		if cf.id != 0 {
+			edit = binary.AppendUvarint(edit, tagColumnFamilyAdd)
+			edit = appendLengthPrefixed(edit, []byte(cf.name))
+			log.add(edit)
+			edit = binary.AppendUvarint(nil, tagColumnFamily)
+			edit = binary.AppendUvarint(edit, uint64(cf.id))
+		}
+		edit = binary.AppendUvarint(edit, tagLogNumber)
+		edit = binary.AppendUvarint(edit, logNumber)
+		for _, file := range cf.files {
+			edit = binary.AppendUvarint(edit, tagNewFile4)
+			edit = binary.AppendUvarint(edit, uint64(file.level))
+			edit = binary.AppendUvarint(edit, file.number)
+			edit = binary.AppendUvarint(edit, file.size)
+			edit = appendLengthPrefixed(edit, file.smallest)
+			edit = appendLengthPrefixed(edit, file.largest)
+			edit = binary.AppendUvarint(edit, file.smallSeq)
+			edit = binary.AppendUvarint(edit, file.largeSeq)
+			edit = binary.AppendUvarint(edit, newFileTagEpoch)
+			edit = appendLengthPrefixed(edit, binary.AppendUvarint(nil, file.number))
+			edit = binary.AppendUvarint(edit, newFileTagTerminate)
+		}
+		log.add(edit)
+	}
+	edit = binary.AppendUvarint(nil, tagNextFileNumber)
+	edit = binary.AppendUvarint(edit, b.nextFile)
+	edit = binary.AppendUvarint(edit, tagLastSequence)
+	edit = binary.AppendUvarint(edit, b.lastSequence)
+	edit = binary.AppendUvarint(edit, tagMaxColumnFamily)
+	edit = binary.AppendUvarint(edit, uint64(len(b.columnFamilies)-1))
+	log.add(edit)
+
+	manifest := fmt.Sprintf("MANIFEST-%06d", manifestNumber)
+	if err := os.WriteFile(filepath.Join(b.dir, manifest), log.buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(b.dir, "CURRENT"), []byte(manifest+"\n"), 0o644)
+}
+
+func appendLengthPrefixed(dst, b []byte) []byte {
+	return append(binary.AppendUvarint(dst, uint64(len(b))), b...)
+}
+
+func internalKey(entry Entry) []byte {
+	return binary.LittleEndian.AppendUint64(append([]byte(nil), entry.Key...), entry.Seq<<8|uint64(entry.Type))
+}
+
+func compareInternalKeys(a, b []byte) int {
+	if c := bytes.Compare(a[:len(a)-8], b[:len(b)-8]); c != 0 {
+		return c
+	}
+	ta, tb := binary.LittleEndian.Uint64(a[len(a)-8:]), binary.LittleEndian.Uint64(b[len(b)-8:])
+	switch {
+	case ta > tb:
+		return -1
+	case ta < tb:
+		return 1
+	}
+	return 0
+}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func maskCRC(crc uint32) uint32 {
+	return (crc>>15 | crc<<17) + 0xa282ead8
+}
+
+// logWriter writes the records of a log in 32KiB blocks of fragments.
+type logWriter struct {
+	buf         bytes.Buffer
+	blockOffset int
+}
+
+func (w *logWriter) add(record []byte) {
+	const blockSize, headerSize = 32 * 1024, 7
+	first := true
+	for {
+		if left := blockSize - w.blockOffset; left < headerSize {
+			w.buf.Write(make([]byte, left))
+			w.blockOffset = 0
+		}
+		n := min(len(record), blockSize-w.blockOffset-headerSize)
+		last := n == len(record)
+		var typ byte
+		switch {
+		case first && last:
+			typ = 1
+		case first:
+			typ = 2
+		case last:
+			typ = 4
+		default:
+			typ = 3
+		}
+		crc := crc32.Update(crc32.Checksum([]byte{typ}, crc32cTable), crc32cTable, record[:n])
+		header := binary.LittleEndian.AppendUint32(nil, maskCRC(crc))
+		header = binary.LittleEndian.AppendUint16(header, uint16(n))
+		w.buf.Write(append(header, typ))
+		w.buf.Write(record[:n])
+		w.blockOffset += headerSize + n
+		record = record[n:]
+		first = false
+		if last {
+			return
+		}
+	}
+}
+
+// blockBuilder builds a block: entries with prefix-compressed keys, and restart points.
+type blockBuilder struct {
+	restartInterval int
+	// deltaValues writes the index format with value delta encoding: no value length.
+	deltaValues bool
+	buf         []byte
+	restarts    []uint32
+	counter     int
+	firstKey    []byte
+	lastKey     []byte
+	entries     int
+}
+
+func (b *blockBuilder) add(key, value, deltaValue []byte) {
+	shared := 0
+	if b.counter < b.restartInterval && b.entries > 0 {
+		for shared < len(key) && shared < len(b.lastKey) && key[shared] == b.lastKey[shared] {
+			shared++
+		}
+	} else {
+		b.restarts = append(b.restarts, uint32(len(b.buf)))
+		b.counter = 0
+	}
+	b.buf = binary.AppendUvarint(b.buf, uint64(shared))
+	b.buf = binary.AppendUvarint(b.buf, uint64(len(key)-shared))
+	if !b.deltaValues {
+		b.buf = binary.AppendUvarint(b.buf, uint64(len(value)))
+	}
+	b.buf = append(b.buf, key[shared:]...)
+	if b.deltaValues && shared != 0 {
+		b.buf = append(b.buf, deltaValue...)
+	} else {
+		b.buf = append(b.buf, value...)
+	}
+	if b.entries == 0 {
+		b.firstKey = append([]byte(nil), key...)
+	}
+	b.lastKey = append(b.lastKey[:0], key...)
+	b.counter++
+	b.entries++
+}
+
+func (b *blockBuilder) finish() []byte {
+	if len(b.restarts) == 0 {
+		b.restarts = []uint32{0}
+	}
+	out := b.buf
+	for _, restart := range b.restarts {
+		out = binary.LittleEndian.AppendUint32(out, restart)
+	}
+	return binary.LittleEndian.AppendUint32(out, uint32(len(b.restarts)))
+}
+
+type handle struct {
+	offset, size uint64
+}
+
+func (h handle) encode() []byte {
+	return binary.AppendUvarint(binary.AppendUvarint(nil, h.offset), h.size)
+}
+
+// tableWriter writes the blocks of a table.
+type tableWriter struct {
+	opts TableOptions
+	buf  []byte
+}
+
+func (w *tableWriter) writeBlock(data []byte, compress bool) (handle, error) {
+	typ := byte(CompressionNone)
+	if compress && w.opts.Compression != CompressionNone {
+		typ = byte(w.opts.Compression)
+		var compressed []byte
+		if w.opts.Compression != CompressionSnappy {
+			if w.opts.FormatVersion < 2 {
+				return handle{}, fmt.Errorf("compression %d needs the format version 2", w.opts.Compression)
+			}
+			compressed = binary.AppendUvarint(nil, uint64(len(data)))
+		}
+		switch w.opts.Compression {
+		case CompressionSnappy:
+			compressed = s2.EncodeSnappy(nil, data)
+		case CompressionZlib:
+			var buf bytes.Buffer
+			fw, err := flate.NewWriter(&buf, flate.BestCompression)
+			if err != nil {
+				return handle{}, err
+			}
+			fw.Write(data)
+			if err := fw.Close(); err != nil {
+				return handle{}, err
+			}
+			compressed = append(compressed, buf.Bytes()...)
+		case CompressionLZ4:
+			compressed = appendLZ4Literals(compressed, data)
+		case CompressionZstd:
+			z, err := tooling.CompressZstd(data)
+			if err != nil {
+				return handle{}, err
+			}
+			compressed = append(compressed, z...)
+		default:
+			return handle{}, fmt.Errorf("unknown compression %d", w.opts.Compression)
+		}
+		data = compressed
+	}
+	h := handle{offset: uint64(len(w.buf)), size: uint64(len(data))}
+	w.buf = append(w.buf, data...)
+	w.buf = append(w.buf, typ)
+	var sum uint32
+	switch w.opts.Checksum {
+	case ChecksumCRC32c:
+		sum = maskCRC(crc32.Checksum(w.buf[h.offset:], crc32cTable))
+	case ChecksumXXHash64:
+		sum = uint32(xxhash.Sum64(w.buf[h.offset:]))
+	case ChecksumXXH3:
+		sum = uint32(xxh3.Hash(data)) ^ uint32(typ)*0x6b9083d9
+	default:
+		return handle{}, fmt.Errorf("unknown checksum %d", w.opts.Checksum)
+	}
+	if w.opts.FormatVersion >= 6 && w.opts.BaseContextChecksum != 0 {
+		sum += w.opts.BaseContextChecksum ^ (uint32(h.offset) + uint32(h.offset>>32))
+	}
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, sum)
+	return h, nil
+}
+
+// appendLZ4Literals appends an LZ4 block of a single sequence of literals.
+func appendLZ4Literals(dst, data []byte) []byte {
+	if len(data) < 15 {
+		return append(append(dst, byte(len(data))<<4), data...)
+	}
+	dst = append(dst, 0xf0)
+	for n := len(data) - 15; ; n -= 255 {
+		if n < 255 {
+			dst = append(dst, byte(n))
+			break
+		}
+		dst = append(dst, 255)
+	}
+	return append(dst, data...)
+}
+
+type indexEntry struct {
+	firstKey, lastKey []byte
+	handle            handle
+}
+
+// buildTable returns a table with the entries, and its smallest and largest keys.
+func buildTable(entries []Entry, opts TableOptions) ([]byte, []byte, []byte, error) {
+	if opts.Checksum == 0 {
+		opts.Checksum = ChecksumCRC32c
+	}
+	if opts.BlockSize == 0 {
+		opts.BlockSize = 4096
+	}
+	if opts.RestartInterval == 0 {
+		opts.RestartInterval = 16
+	}
+	if opts.IndexRestartInterval == 0 {
+		opts.IndexRestartInterval = 1
+	}
+	if opts.IndexPartitionSize == 0 {
+		opts.IndexPartitionSize = 4
+	}
+	var points, ranges []Entry
+	for _, entry := range entries {
+		if entry.Type == TypeRangeDeletion {
+			ranges = append(ranges, entry)
+		} else {
+			points = append(points, entry)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return compareInternalKeys(internalKey(points[i]), internalKey(points[j])) < 0 })
+	sort.Slice(ranges, func(i, j int) bool { return compareInternalKeys(internalKey(ranges[i]), internalKey(ranges[j])) < 0 })
+
+	w := &tableWriter{opts: opts}
+	var index []indexEntry
+	block := &blockBuilder{restartInterval: opts.RestartInterval}
+	flush := func() error {
+		h, err := w.writeBlock(block.finish(), true)
+		if err != nil {
+			return err
+		}
+		index = append(index, indexEntry{firstKey: block.firstKey, lastKey: append([]byte(nil), block.lastKey...), handle: h})
+		block = &blockBuilder{restartInterval: opts.RestartInterval}
+		return nil
+	}
+	for _, entry := range points {
+		block.add(internalKey(entry), entry.Value, nil)
+		if len(block.buf) >= opts.BlockSize {
+			if err := flush(); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+	if block.entries > 0 {
+		if err := flush(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	// the index has user keys when no user key is in two blocks.
+	userKeys := opts.FormatVersion >= 3
+	for i := 0; i+1 < len(index); i++ {
+		last, next := index[i].lastKey, index[i+1].firstKey
+		if bytes.Equal(last[:len(last)-8], next[:len(next)-8]) {
+			userKeys = false
+		}
+	}
+	deltaEncoded := opts.FormatVersion >= 4
+	indexKey := func(key []byte) []byte {
+		if userKeys {
+			return key[:len(key)-8]
+		}
+		return key
+	}
+	writeIndexBlock := func(entries []indexEntry) (handle, error) {
+		b := &blockBuilder{restartInterval: opts.IndexRestartInterval, deltaValues: deltaEncoded}
+		var prev handle
+		for _, entry := range entries {
+			delta := binary.AppendVarint(nil, int64(entry.handle.size)-int64(prev.size))
+			b.add(indexKey(entry.lastKey), entry.handle.encode(), delta)
+			prev = entry.handle
+		}
+		return w.writeBlock(b.finish(), true)
+	}
+	var indexHandle handle
+	var err error
+	indexType := uint32(0)
+	if !opts.PartitionedIndex {
+		indexHandle, err = writeIndexBlock(index)
+	} else {
+		indexType = 2
+		var partitions []indexEntry
+		for start := 0; start < len(index) && err == nil; start += opts.IndexPartitionSize {
+			part := index[start:min(start+opts.IndexPartitionSize, len(index))]
+			var h handle
+			h, err = writeIndexBlock(part)
+			partitions = append(partitions, indexEntry{lastKey: part[len(part)-1].lastKey, handle: h})
+		}
+		if err == nil {
+			indexHandle, err = writeIndexBlock(partitions)
+		}
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	meta := map[string]handle{}
+	if len(ranges) > 0 {
+		b := &blockBuilder{restartInterval: 1}
+		for _, entry := range ranges {
+			b.add(internalKey(entry), entry.Value, nil)
+		}
+		if meta["rocksdb.range_del"], err = w.writeBlock(b.finish(), false); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	props := &blockBuilder{restartInterval: 1}
+	props.add([]byte("rocksdb.block.based.table.index.type"), binary.LittleEndian.AppendUint32(nil, indexType), nil)
+	props.add([]byte("rocksdb.comparator"), []byte("leveldb.BytewiseComparator"), nil)
+	props.add([]byte("rocksdb.index.key.is.user.key"), binary.AppendUvarint(nil, flag(userKeys)), nil)
+	props.add([]byte("rocksdb.index.value.is.delta.encoded"), binary.AppendUvarint(nil, flag(deltaEncoded)), nil)
+	if meta["rocksdb.properties"], err = w.writeBlock(props.finish(), false); err != nil {
+		return nil, nil, nil, err
+	}
+	if opts.FormatVersion >= 6 {
+		meta["rocksdb.index"] = indexHandle
+	}
+	names := make([]string, 0, len(meta))
+	for name := range meta {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metaindex := &blockBuilder{restartInterval: 1}
+	for _, name := range names {
+		metaindex.add([]byte(name), meta[name].encode(), nil)
+	}
+	metaindexHandle, err := w.writeBlock(metaindex.finish(), false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var footer []byte
+	switch {
+	case opts.FormatVersion == 0:
+		footer = append(metaindexHandle.encode(), indexHandle.encode()...)
+		footer = append(footer, make([]byte, 40-len(footer))...)
+		footer = binary.LittleEndian.AppendUint64(footer, 0xdb4775248b80fb57)
+	case opts.FormatVersion < 6:
+		footer = append([]byte{byte(opts.Checksum)}, metaindexHandle.encode()...)
+		footer = append(footer, indexHandle.encode()...)
+		footer = append(footer, make([]byte, 41-len(footer))...)
+	default:
+		footer = []byte{byte(opts.Checksum), 0x3e, 0x00, 0x7a, 0x00, 0, 0, 0, 0}
+		footer = binary.LittleEndian.AppendUint32(footer, opts.BaseContextChecksum)
+		footer = binary.LittleEndian.AppendUint32(footer, uint32(metaindexHandle.size))
+		footer = append(footer, make([]byte, 41-len(footer))...)
+	}
+	if opts.FormatVersion > 0 {
+		footer = binary.LittleEndian.AppendUint32(footer, opts.FormatVersion)
+		footer = binary.LittleEndian.AppendUint64(footer, 0x88e241b785f4cff7)
+	}
+	table := append(w.buf, footer...)
+
+	// the key range of the table includes its range deletions.
+	var smallest, largest []byte
+	if len(points) > 0 {
+		smallest, largest = internalKey(points[0]), internalKey(points[len(points)-1])
+	}
+	for _, entry := range ranges {
+		if start := internalKey(entry); smallest == nil || compareInternalKeys(start, smallest) < 0 {
+			smallest = start
+		}
+		end := internalKey(Entry{Key: entry.Value, Seq: 1<<56 - 1, Type: TypeRangeDeletion})
+		if largest == nil || compareInternalKeys(end, largest) > 0 {
+			largest = end
+		}
+	}
+	return table, smallest, largest, nil
+}
+
+func flag(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
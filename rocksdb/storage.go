@@ -0,0 +1,214 @@
+package rocksdb
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// file is a file of the database.
+type file interface {
+	io.ReaderAt
+	Size() int64
+	Close() error
+}
+
+// storage is where the files of the database are: a directory, or an uncompressed tar
+// archive read in place.
+type storage interface {
+	// list returns the names of the files of the database.
+	list() ([]string, error)
+	open(name string) (file, error)
+	Close() error
+}
+
+// currentFile names the MANIFEST of the database.
+const currentFile = "CURRENT"
+
+// openStorage opens the database at path: a directory with a CURRENT file, a Solana ledger
+// directory (the database is its "rocksdb" directory), or a tar archive of either.
+func openStorage(p string) (storage, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return openTarStorage(p)
+	}
+	for _, dir := range []string{p, filepath.Join(p, "rocksdb")} {
+		if _, err := os.Stat(filepath.Join(dir, currentFile)); err == nil {
+			return dirStorage(dir), nil
+		}
+	}
+	return nil, fmt.Errorf("%q is not a rocksdb database (no %s file)", p, currentFile)
+}
+
+type dirStorage string
+
+func (s dirStorage) list() ([]string, error) {
+	entries, err := os.ReadDir(string(s))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (s dirStorage) open(name string) (file, error) {
+	f, err := os.Open(filepath.Join(string(s), name))
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &osFile{File: f, size: info.Size()}, nil
+}
+
+func (s dirStorage) Close() error {
+	return nil
+}
+
+type osFile struct {
+	*os.File
+	size int64
+}
+
+func (f *osFile) Size() int64 {
+	return f.size
+}
+
+// tarStorage reads the files of the database in place in a tar archive; the archive is
+// scanned once for the position of the files.
+type tarStorage struct {
+	archive *os.File
+	files   map[string]*io.SectionReader
+}
+
+// Magic numbers of the compressed archives, which can't be read in place.
+var compressedMagics = map[string][]byte{
+	"zstd":  {0x28, 0xb5, 0x2f, 0xfd},
+	"gzip":  {0x1f, 0x8b},
+	"bzip2": []byte("BZh"),
+	"xz":    {0xfd, '7', 'z', 'X', 'Z', 0x00},
+	"lz4":   {0x04, 0x22, 0x4d, 0x18},
+}
+
+// ErrCompressedArchive is returned by Open for a compressed tar archive, which must be
+// extracted first.
+var ErrCompressedArchive = errors.New("compressed archive")
+
+func openTarStorage(p string) (storage, error) {
+	archive, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	s, err := scanTar(archive)
+	if err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("%q: %w", p, err)
+	}
+	return s, nil
+}
+
+func scanTar(archive *os.File) (*tarStorage, error) {
+	magic, err := bufio.NewReader(archive).Peek(6)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	for name, m := range compressedMagics {
+		if bytes.HasPrefix(magic, m) {
+			return nil, fmt.Errorf("%w (%s)", ErrCompressedArchive, name)
+		}
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	// the tar reader skips the contents of the files by seeking, and reads the headers
+	// without buffering: after Next, the file is at the start of the contents.
+	tr := tar.NewReader(archive)
+	members := make(map[string]*io.SectionReader)
+	var dirs []string
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("not a tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		for key := range header.PAXRecords {
+			if strings.HasPrefix(key, "GNU.sparse.") {
+				return nil, fmt.Errorf("sparse file %q is not supported", name)
+			}
+		}
+		offset, err := archive.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		members[name] = io.NewSectionReader(archive, offset, header.Size)
+		if path.Base(name) == currentFile {
+			dirs = append(dirs, path.Dir(name))
+		}
+	}
+	switch len(dirs) {
+	case 0:
+		return nil, fmt.Errorf("no rocksdb database (%s file) in the archive", currentFile)
+	case 1:
+	default:
+		return nil, fmt.Errorf("several rocksdb databases in the archive: %s", strings.Join(dirs, ", "))
+	}
+	s := &tarStorage{archive: archive, files: make(map[string]*io.SectionReader)}
+	for name, member := range members {
+		if path.Dir(name) == dirs[0] {
+			s.files[path.Base(name)] = member
+		}
+	}
+	return s, nil
+}
+
+func (s *tarStorage) list() ([]string, error) {
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (s *tarStorage) open(name string) (file, error) {
+	member, ok := s.files[name]
+	if !ok {
+		return nil, fmt.Errorf("open %s: %w", name, os.ErrNotExist)
+	}
+	return tarFile{member}, nil
+}
+
+func (s *tarStorage) Close() error {
+	return s.archive.Close()
+}
+
+type tarFile struct {
+	*io.SectionReader
+}
+
+func (tarFile) Close() error {
+	return nil
+}
@@ -0,0 +1,423 @@
+package rocksdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Block-based tables (the SST files) are data blocks, meta blocks, a metaindex block (the
+// handles of the meta blocks by name), an index block (the handles of the data blocks) and
+// a footer. Every block is followed by a trailer: its compression type and a checksum of
+// its (compressed) contents and type.
+
+const (
+	blockTrailerSize = 5
+
+	legacyFooterSize = 48
+	footerSize       = 53
+
+	legacyBlockBasedTableMagic = 0xdb4775248b80fb57
+	blockBasedTableMagic       = 0x88e241b785f4cff7
+	legacyPlainTableMagic      = 0x4f3418eb7a8f13b8
+	plainTableMagic            = 0x8242229663bf9564
+	cuckooTableMagic           = 0x926789d0c5f17873
+
+	maxFormatVersion = 6
+)
+
+// extendedMagic starts the footer of the format version 6.
+var extendedMagic = []byte{0x3e, 0x00, 0x7a, 0x00}
+
+// Names of the meta blocks.
+const (
+	metaProperties = "rocksdb.properties"
+	metaRangeDel   = "rocksdb.range_del"
+	// metaIndex is the handle of the index block, since the format version 6.
+	metaIndex = "rocksdb.index"
+)
+
+// Properties of the tables.
+const (
+	propComparator        = "rocksdb.comparator"
+	propIndexType         = "rocksdb.block.based.table.index.type"
+	propIndexKeyIsUserKey = "rocksdb.index.key.is.user.key"
+	propIndexDeltaEncoded = "rocksdb.index.value.is.delta.encoded"
+)
+
+// Index types: 0 (binary search) and 1 (hash search) are plain indexes.
+const (
+	indexTwoLevelSearch       = 2
+	indexBinarySearchFirstKey = 3
+)
+
+// table is an open table file.
+type table struct {
+	number        uint64
+	file          file
+	blocks        *blockCache
+	checksum      byte
+	formatVersion uint32
+	baseContext   uint32
+
+	// index are the entries of the index of the data blocks (of all its partitions).
+	index []indexEntry
+	// indexKeyIsUserKey is set when the keys of the index are user keys.
+	indexKeyIsUserKey bool
+	// tombstones are the range deletions of the table, sorted by start key.
+	tombstones []tombstone
+
+	// refs is the number of users of the table, see tableCache.
+	refs int
+}
+
+// tombstone deletes the keys in [start, end) whose sequence number is below seq.
+type tombstone struct {
+	start, end []byte
+	seq        uint64
+}
+
+func openTable(f file, number uint64, blocks *blockCache) (*table, error) {
+	t := &table{number: number, file: f, blocks: blocks}
+	if err := t.open(); err != nil {
+		return nil, fmt.Errorf("table %06d: %w", number, err)
+	}
+	return t, nil
+}
+
+func (t *table) open() error {
+	size := t.file.Size()
+	if size < legacyFooterSize {
+		return corruptionf("file of %d bytes is too short to be a table", size)
+	}
+	footer := make([]byte, min(size, footerSize))
+	if _, err := t.file.ReadAt(footer, size-int64(len(footer))); err != nil {
+		return err
+	}
+	var metaindexHandle, indexHandle blockHandle
+	var hasIndexHandle bool
+	switch magic := binary.LittleEndian.Uint64(footer[len(footer)-8:]); magic {
+	case legacyBlockBasedTableMagic:
+		t.checksum = checksumCRC32c
+		d := decoder{buf: footer[len(footer)-legacyFooterSize:]}
+		metaindexHandle, indexHandle, hasIndexHandle = d.blockHandle(), d.blockHandle(), true
+		if d.err != nil {
+			return d.err
+		}
+	case blockBasedTableMagic:
+		if len(footer) < footerSize {
+			return corruptionf("file of %d bytes is too short to be a table", size)
+		}
+		t.checksum = footer[0]
+		t.formatVersion = binary.LittleEndian.Uint32(footer[footerSize-12:])
+		if t.formatVersion > maxFormatVersion {
+			return fmt.Errorf("format version %d is not supported", t.formatVersion)
+		}
+		if t.formatVersion < 6 {
+			d := decoder{buf: footer[1:]}
+			metaindexHandle, indexHandle, hasIndexHandle = d.blockHandle(), d.blockHandle(), true
+			if d.err != nil {
+				return d.err
+			}
+			break
+		}
+		// the metaindex block is right before the footer, and the index handle is in it.
+		if !bytes.Equal(footer[1:5], extendedMagic) {
+			return corruptionf("invalid footer")
+		}
+		t.baseContext = binary.LittleEndian.Uint32(footer[9:13])
+		metaindexSize := uint64(binary.LittleEndian.Uint32(footer[13:17]))
+		end := uint64(size - footerSize)
+		if metaindexSize+blockTrailerSize > end {
+			return corruptionf("invalid metaindex size %d", metaindexSize)
+		}
+		metaindexHandle = blockHandle{offset: end - metaindexSize - blockTrailerSize, size: metaindexSize}
+	case legacyPlainTableMagic, plainTableMagic, cuckooTableMagic:
+		return fmt.Errorf("plain and cuckoo tables are not supported")
+	default:
+		return corruptionf("not a table (magic %#x)", magic)
+	}
+	if t.checksum == checksumNone || t.checksum > checksumXXH3 {
+		return fmt.Errorf("checksum type %d is not supported", t.checksum)
+	}
+
+	metaindex, err := t.readBlock(metaindexHandle)
+	if err != nil {
+		return fmt.Errorf("metaindex: %w", err)
+	}
+	meta := make(map[string]blockHandle)
+	it, err := newBlockIter(metaindex, bytes.Compare)
+	if err != nil {
+		return fmt.Errorf("metaindex: %w", err)
+	}
+	for it.first(); it.valid; it.nextEntry() {
+		d := decoder{buf: it.value}
+		meta[string(it.key)] = d.blockHandle()
+		if d.err != nil {
+			return fmt.Errorf("metaindex: %w", d.err)
+		}
+	}
+	if it.err != nil {
+		return fmt.Errorf("metaindex: %w", it.err)
+	}
+	if !hasIndexHandle {
+		if indexHandle, hasIndexHandle = meta[metaIndex]; !hasIndexHandle {
+			return corruptionf("no index block")
+		}
+	}
+
+	var indexType uint32
+	var deltaEncoded bool
+	if handle, ok := meta[metaProperties]; ok {
+		props, err := t.readProperties(handle)
+		if err != nil {
+			return fmt.Errorf("properties: %w", err)
+		}
+		if comparator, ok := props[propComparator]; ok && string(comparator) != bytewiseComparator {
+			return fmt.Errorf("comparator %q is not supported", comparator)
+		}
+		if v, ok := props[propIndexType]; ok {
+			if len(v) != 4 {
+				return corruptionf("invalid index type property")
+			}
+			indexType = binary.LittleEndian.Uint32(v)
+		}
+		t.indexKeyIsUserKey = propertyFlag(props[propIndexKeyIsUserKey])
+		deltaEncoded = propertyFlag(props[propIndexDeltaEncoded])
+	}
+	if indexType > indexBinarySearchFirstKey {
+		return fmt.Errorf("index type %d is not supported", indexType)
+	}
+
+	index, err := t.readBlock(indexHandle)
+	if err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
+	firstKey := indexType == indexBinarySearchFirstKey
+	if indexType != indexTwoLevelSearch {
+		t.index, err = decodeIndexBlock(index, deltaEncoded, firstKey)
+		if err != nil {
+			return fmt.Errorf("index: %w", err)
+		}
+	} else {
+		// the top-level index has the handles of the partitions of the index.
+		partitions, err := decodeIndexBlock(index, deltaEncoded, false)
+		if err != nil {
+			return fmt.Errorf("index: %w", err)
+		}
+		for _, partition := range partitions {
+			block, err := t.readBlock(partition.handle)
+			if err != nil {
+				return fmt.Errorf("index partition: %w", err)
+			}
+			entries, err := decodeIndexBlock(block, deltaEncoded, false)
+			if err != nil {
+				return fmt.Errorf("index partition: %w", err)
+			}
+			t.index = append(t.index, entries...)
+		}
+	}
+	if !t.indexKeyIsUserKey {
+		for _, entry := range t.index {
+			if len(entry.key) < internalKeyTrailerSize {
+				return corruptionf("invalid index key")
+			}
+		}
+	}
+
+	if handle, ok := meta[metaRangeDel]; ok {
+		if err := t.readTombstones(handle); err != nil {
+			return fmt.Errorf("range deletions: %w", err)
+		}
+	}
+	return nil
+}
+
+// propertyFlag decodes a boolean property, a varint.
+func propertyFlag(v []byte) bool {
+	n, _ := binary.Uvarint(v)
+	return n != 0
+}
+
+func (t *table) readProperties(handle blockHandle) (map[string][]byte, error) {
+	block, err := t.readBlock(handle)
+	if err != nil {
+		return nil, err
+	}
+	it, err := newBlockIter(block, bytes.Compare)
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string][]byte)
+	for it.first(); it.valid; it.nextEntry() {
+		props[string(it.key)] = it.value
+	}
+	return props, it.err
+}
+
+func (t *table) readTombstones(handle blockHandle) error {
+	block, err := t.readBlock(handle)
+	if err != nil {
+		return err
+	}
+	it, err := newBlockIter(block, compareInternalKeys)
+	if err != nil {
+		return err
+	}
+	for it.first(); it.valid; it.nextEntry() {
+		start, seq, typ, err := parseInternalKey(it.key)
+		if err != nil {
+			return err
+		}
+		if typ != typeRangeDeletion {
+			return corruptionf("entry of type %#x in the range deletions", typ)
+		}
+		t.tombstones = append(t.tombstones, tombstone{
+			start: append([]byte(nil), start...),
+			end:   append([]byte(nil), it.value...),
+			seq:   seq,
+		})
+	}
+	if it.err != nil {
+		return it.err
+	}
+	sort.Slice(t.tombstones, func(i, j int) bool {
+		return bytes.Compare(t.tombstones[i].start, t.tombstones[j].start) < 0
+	})
+	return nil
+}
+
+// readBlock reads a block, checks its checksum and decompresses it.
+func (t *table) readBlock(handle blockHandle) ([]byte, error) {
+	if handle.offset+handle.size+blockTrailerSize > uint64(t.file.Size()) {
+		return nil, corruptionf("block at %d of %d bytes is out of the file", handle.offset, handle.size)
+	}
+	buf := make([]byte, handle.size+blockTrailerSize)
+	if _, err := t.file.ReadAt(buf, int64(handle.offset)); err != nil {
+		return nil, err
+	}
+	data := buf[:handle.size+1]
+	want := binary.LittleEndian.Uint32(buf[handle.size+1:]) - checksumModifier(t.baseContext, handle.offset)
+	got, err := checksum(t.checksum, data)
+	if err != nil {
+		return nil, err
+	}
+	if got != want {
+		return nil, corruptionf("checksum mismatch of the block at %d", handle.offset)
+	}
+	return decompress(buf[handle.size], buf[:handle.size], t.formatVersion)
+}
+
+// dataBlock returns a data block, from the block cache when it is there.
+func (t *table) dataBlock(handle blockHandle) ([]byte, error) {
+	key := blockKey{file: t.number, offset: handle.offset}
+	if block, ok := t.blocks.get(key); ok {
+		return block, nil
+	}
+	block, err := t.readBlock(handle)
+	if err != nil {
+		return nil, fmt.Errorf("table %06d: %w", t.number, err)
+	}
+	t.blocks.add(key, block)
+	return block, nil
+}
+
+// compareIndexKey compares a key of the index with an internal key.
+func (t *table) compareIndexKey(indexKey, key []byte) int {
+	if t.indexKeyIsUserKey {
+		return bytes.Compare(indexKey, userKey(key))
+	}
+	return compareInternalKeys(indexKey, key)
+}
+
+// covers tells whether a range deletion of the table deletes the entry of key with seq.
+func (t *table) covers(key []byte, seq uint64) bool {
+	n := sort.Search(len(t.tombstones), func(i int) bool {
+		return bytes.Compare(t.tombstones[i].start, key) > 0
+	})
+	for _, ts := range t.tombstones[:n] {
+		if ts.seq > seq && bytes.Compare(key, ts.end) < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// tableIter iterates over the entries of a table, in internal key order.
+type tableIter struct {
+	t     *table
+	i     int
+	block *blockIter
+	err   error
+}
+
+func (it *tableIter) load(i int) bool {
+	it.i = i
+	it.block = nil
+	if i >= len(it.t.index) {
+		return false
+	}
+	data, err := it.t.dataBlock(it.t.index[i].handle)
+	if err == nil {
+		it.block, err = newBlockIter(data, compareInternalKeys)
+	}
+	if err != nil {
+		it.err = err
+		return false
+	}
+	return true
+}
+
+// skipEmpty moves to the first entry of the next blocks when the current one is exhausted.
+func (it *tableIter) skipEmpty() {
+	for it.err == nil && it.block != nil && !it.block.valid {
+		if it.block.err != nil {
+			it.err = fmt.Errorf("table %06d: %w", it.t.number, it.block.err)
+			return
+		}
+		if it.load(it.i + 1) {
+			it.block.first()
+		}
+	}
+}
+
+func (it *tableIter) first() {
+	if it.load(0) {
+		it.block.first()
+	}
+	it.skipEmpty()
+}
+
+func (it *tableIter) seek(key []byte) {
+	i := sort.Search(len(it.t.index), func(i int) bool {
+		return it.t.compareIndexKey(it.t.index[i].key, key) >= 0
+	})
+	if it.load(i) {
+		it.block.seek(key)
+	}
+	it.skipEmpty()
+}
+
+func (it *tableIter) next() {
+	it.block.nextEntry()
+	it.skipEmpty()
+}
+
+func (it *tableIter) valid() bool {
+	return it.err == nil && it.block != nil && it.block.valid
+}
+
+func (it *tableIter) key() []byte {
+	return it.block.key
+}
+
+func (it *tableIter) value() []byte {
+	return it.block.value
+}
+
+func (it *tableIter) error() error {
+	return it.err
+}
+
+func (it *tableIter) close() {}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// rpcEnvPrefix is the prefix of the environment variables of the flags of the rpc command:
+// --max-cache can be set with FAITHFUL_RPC_MAX_CACHE.
+const rpcEnvPrefix = "FAITHFUL_RPC_"
+
+// withFlagEnvVars makes each of the flags settable with the environment variable named after it
+// (see flagEnvVar), unless it already has environment variables, and returns them.
+func withFlagEnvVars(prefix string, flags []cli.Flag) []cli.Flag {
+	for _, flag := range flags {
+		field := reflect.ValueOf(flag).Elem().FieldByName("EnvVars")
+		if !field.IsValid() || field.Len() > 0 {
+			continue
+		}
+		field.Set(reflect.ValueOf([]string{flagEnvVar(prefix, flag.Names()[0])}))
+	}
+	return flags
+}
+
+func flagEnvVar(prefix string, name string) string {
+	return prefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// loadServerConfigFile reads a server config file (TOML, YAML or JSON): a flat map of flag
+// names (or the same with underscores) to their values.
+func loadServerConfigFile(path string) (map[string]any, error) {
+	unmarshal := yaml.Unmarshal
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		unmarshal = toml.Unmarshal
+	case ".yaml", ".yml", ".json":
+	default:
+		return nil, fmt.Errorf("config file %q must be TOML, YAML or JSON", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]any)
+	if err := unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %q: %w", path, err)
+	}
+	return values, nil
+}
+
+// applyServerConfig sets the flags of the command from the values of a server config file. The
+// flags set on the command line or with their environment variable take precedence.
+func applyServerConfig(c *cli.Context, values map[string]any) error {
+	flags := make(map[string]cli.Flag)
+	for _, flag := range c.Command.Flags {
+		for _, name := range flag.Names() {
+			flags[name] = flag
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		flag, ok := flags[key]
+		if !ok {
+			flag, ok = flags[strings.ReplaceAll(key, "_", "-")]
+		}
+		if !ok {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		name := flag.Names()[0]
+		if name == "config" || name == "print-effective-config" {
+			return fmt.Errorf("setting %q can't be set in the config file", key)
+		}
+		var items []any
+		switch value := values[key].(type) {
+		case []any:
+			if _, ok := flag.(*cli.StringSliceFlag); !ok {
+				return fmt.Errorf("setting %q: expected a single value, got a list", key)
+			}
+			items = value
+		case map[string]any:
+			return fmt.Errorf("setting %q: expected a value, got a table", key)
+		default:
+			items = []any{value}
+		}
+		if c.IsSet(name) {
+			continue
+		}
+		for _, item := range items {
+			if err := c.Set(name, fmt.Sprint(item)); err != nil {
+				return fmt.Errorf("setting %q: invalid value %v: %w", key, item, err)
+			}
+		}
+	}
+	return nil
+}
+
+// printEffectiveConfig writes the value of every flag of the command (from the command line, the
+// environment, the config file or the defaults) as YAML, in the format of the config file.
+func printEffectiveConfig(w io.Writer, c *cli.Context) error {
+	values := make(map[string]any)
+	for _, flag := range c.Command.Flags {
+		name := flag.Names()[0]
+		if name == "config" || name == "print-effective-config" || name == "help" {
+			continue
+		}
+		var value any
+		switch flag.(type) {
+		case *cli.StringSliceFlag:
+			value = c.StringSlice(name)
+			if value == nil {
+				value = []string{}
+			}
+		case *cli.DurationFlag:
+			value = c.Duration(name).String()
+		default:
+			value = c.Value(name)
+		}
+		if strings.Contains(name, "auth") && value != "" {
+			value = "<redacted>"
+		}
+		values[name] = value
+	}
+	encoded, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded)
+	return err
+}
+
+// sameFile returns true if the paths are the same existing file.
+func sameFile(a string, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// runRpcCommand runs the rpc command with the arguments, and returns what it printed.
+func runRpcCommand(args ...string) (string, error) {
+	var out bytes.Buffer
+	app := &cli.App{
+		Commands:       []*cli.Command{newCmd_rpc()},
+		Writer:         &out,
+		ErrWriter:      &out,
+		ExitErrHandler: func(*cli.Context, error) {},
+	}
+	err := app.Run(append([]string{"faithful-cli", "rpc"}, args...))
+	return out.String(), err
+}
+
+func TestRpcServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "server.toml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+listen = ":7999"
+grpc_listen = ":7998"
+max-cache = 100
+slow-query-threshold = "2s"
+disable-compression = true
+cors-allowed-origins = ["https://a.example.com", "https://b.example.com"]
+epochs-dir = ["/data/epochs"]
+`), 0o644))
+	t.Setenv("FAITHFUL_RPC_MAX_CACHE", "200")
+
+	out, err := runRpcCommand("--config", configPath, "--print-effective-config", "--slow-query-threshold=3s")
+	require.NoError(t, err)
+	var effective map[string]any
+	require.NoError(t, yaml.Unmarshal([]byte(out), &effective))
+	require.Equal(t, ":7999", effective["listen"])
+	require.Equal(t, ":7998", effective["grpc-listen"])
+	// the environment and the flags take precedence over the file.
+	require.Equal(t, 200, effective["max-cache"])
+	require.Equal(t, "3s", effective["slow-query-threshold"])
+	require.Equal(t, true, effective["disable-compression"])
+	require.Equal(t, []any{"https://a.example.com", "https://b.example.com"}, effective["cors-allowed-origins"])
+	require.Equal(t, []any{"/data/epochs"}, effective["epochs-dir"])
+	require.Equal(t, "10m0s", effective["cors-max-age"])
+	require.NotContains(t, effective, "config")
+
+	// the printed config is a valid config file.
+	printedPath := filepath.Join(dir, "printed.yaml")
+	require.NoError(t, os.WriteFile(printedPath, []byte(out), 0o644))
+	reprinted, err := runRpcCommand("--config", printedPath, "--print-effective-config")
+	require.NoError(t, err)
+	require.Equal(t, out, reprinted)
+
+	for content, expected := range map[string]string{
+		"listen: ':7999'\nmax-cach: 1\n":             `unknown setting "max-cach"`,
+		"listen: ':7999'\ncors-max-age: abc\n":       `setting "cors-max-age": invalid value abc`,
+		"listen: [':7999', ':7998']\n":               `setting "listen": expected a single value, got a list`,
+		"listen: ':7999'\ncar-hash-mismatch: no\n":   `invalid --car-hash-mismatch`,
+		"listen: ':7999'\nepoch-idle-timeout: 30s\n": `invalid --epoch-idle-timeout: 30s (must be 0 or at least 1m0s)`,
+		"listen: ':7999'\nconfig: other.yaml\n":      `setting "config" can't be set in the config file`,
+		"listen: ':7999'\nmax-cache:\n  size: 1\n":   `setting "max-cache": expected a value, got a table`,
+	} {
+		path := filepath.Join(dir, "invalid.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		_, err := runRpcCommand("--config", path, "--print-effective-config")
+		require.ErrorContains(t, err, expected, content)
+	}
+	_, err = runRpcCommand("--config", filepath.Join(dir, "server.ini"))
+	require.ErrorContains(t, err, "must be TOML, YAML or JSON")
+}
+
+func TestFlagEnvVar(t *testing.T) {
+	require.Equal(t, "FAITHFUL_RPC_SIG_NEGATIVE_CACHE_TTL", flagEnvVar(rpcEnvPrefix, "sig-negative-cache-ttl"))
+	flags := withFlagEnvVars(rpcEnvPrefix, []cli.Flag{
+		&cli.StringFlag{Name: "listen"},
+		&cli.StringFlag{Name: "log-dir", EnvVars: []string{"FAITHFUL_LOG_DIR"}},
+	})
+	require.Equal(t, []string{"FAITHFUL_RPC_LISTEN"}, flags[0].(*cli.StringFlag).EnvVars)
+	require.Equal(t, []string{"FAITHFUL_LOG_DIR"}, flags[1].(*cli.StringFlag).EnvVars)
+}
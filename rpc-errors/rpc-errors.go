@@ -0,0 +1,187 @@
+package rpcerrors
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Solana JSON-RPC server error codes, as defined in
+// https://github.com/anza-xyz/agave/blob/master/rpc-client-api/src/custom_error.rs
+const (
+	CodeBlockCleanedUp                           int64 = -32001
+	CodeSendTransactionPreflightFailure          int64 = -32002
+	CodeTransactionSignatureVerificationFailure  int64 = -32003
+	CodeBlockNotAvailable                        int64 = -32004
+	CodeNodeUnhealthy                            int64 = -32005
+	CodeTransactionPrecompileVerificationFailure int64 = -32006
+	CodeSlotSkipped                              int64 = -32007
+	CodeNoSnapshot                               int64 = -32008
+	CodeLongTermStorageSlotSkipped               int64 = -32009
+	CodeKeyExcludedFromSecondaryIndex            int64 = -32010
+	CodeTransactionHistoryNotAvailable           int64 = -32011
+	CodeScanError                                int64 = -32012
+	CodeTransactionSignatureLenMismatch          int64 = -32013
+	CodeBlockStatusNotAvailableYet               int64 = -32014
+	CodeUnsupportedTransactionVersion            int64 = -32015
+	CodeMinContextSlotNotReached                 int64 = -32016
+	CodeEpochRewardsPeriodActive                 int64 = -32017
+	CodeLongTermStorageUnreachable               int64 = -32019
+)
+
+// Kind classifies why a piece of data could not be served.
+type Kind int
+
+const (
+	// KindInternal is any failure that is not caused by the data being absent.
+	KindInternal Kind = iota
+	// KindSlotSkipped means the slot belongs to an epoch that is loaded,
+	// but no block was produced for it.
+	KindSlotSkipped
+	// KindBlockNotAvailable means the slot is after the first available block,
+	// but the epoch that contains it is not loaded.
+	KindBlockNotAvailable
+	// KindBlockCleanedUp means the slot is before the first available block.
+	KindBlockCleanedUp
+	// KindTransactionNotFound means the transaction is not in any loaded epoch;
+	// Solana replies with a null result in that case, not with an error.
+	KindTransactionNotFound
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindInternal:
+		return "Internal"
+	case KindSlotSkipped:
+		return "SlotSkipped"
+	case KindBlockNotAvailable:
+		return "BlockNotAvailable"
+	case KindBlockCleanedUp:
+		return "BlockCleanedUp"
+	case KindTransactionNotFound:
+		return "TransactionNotFound"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// IsNullResult returns true if Solana answers this kind of failure
+// with `"result": null` instead of an error object.
+func (k Kind) IsNullResult() bool {
+	return k == KindTransactionNotFound
+}
+
+// ClassifyMissingSlot decides which error applies to a slot whose block could not be found.
+// epochLoaded tells whether the epoch containing the slot is served by this node;
+// firstAvailable is the first slot served by this node (only meaningful if hasFirstAvailable is true).
+func ClassifyMissingSlot(slot uint64, epochLoaded bool, firstAvailable uint64, hasFirstAvailable bool) Kind {
+	if epochLoaded {
+		return KindSlotSkipped
+	}
+	if hasFirstAvailable && slot < firstAvailable {
+		return KindBlockCleanedUp
+	}
+	return KindBlockNotAvailable
+}
+
+// NewSlotSkipped returns the -32007 error for a skipped slot.
+func NewSlotSkipped(slot uint64) *jsonrpc2.Error {
+	return &jsonrpc2.Error{
+		Code:    CodeSlotSkipped,
+		Message: fmt.Sprintf("Slot %d was skipped, or missing due to ledger jump to recent snapshot", slot),
+	}
+}
+
+// NewBlockNotAvailable returns the -32004 error for a block that is not served by this node.
+func NewBlockNotAvailable(slot uint64) *jsonrpc2.Error {
+	return &jsonrpc2.Error{
+		Code:    CodeBlockNotAvailable,
+		Message: fmt.Sprintf("Block not available for slot %d", slot),
+	}
+}
+
+// BlockCleanedUpData is the context attached to a -32001 error.
+type BlockCleanedUpData struct {
+	Slot                uint64 `json:"slot"`
+	FirstAvailableBlock uint64 `json:"firstAvailableBlock"`
+}
+
+// NewBlockCleanedUp returns the -32001 error for a slot that is before the first available block.
+func NewBlockCleanedUp(slot uint64, firstAvailable uint64) *jsonrpc2.Error {
+	rpcErr := &jsonrpc2.Error{
+		Code:    CodeBlockCleanedUp,
+		Message: fmt.Sprintf("Block %d cleaned up, does not exist on node. First available block: %d", slot, firstAvailable),
+	}
+	data, err := json.Marshal(BlockCleanedUpData{
+		Slot:                slot,
+		FirstAvailableBlock: firstAvailable,
+	})
+	if err == nil {
+		raw := json.RawMessage(data)
+		rpcErr.Data = &raw
+	}
+	return rpcErr
+}
+
+// MinContextSlotNotReachedData is the context attached to a -32016 error.
+type MinContextSlotNotReachedData struct {
+	ContextSlot uint64 `json:"contextSlot"`
+}
+
+// NewMinContextSlotNotReached returns the -32016 error for a request whose minContextSlot is
+// after contextSlot, the last slot served.
+func NewMinContextSlotNotReached(contextSlot uint64) *jsonrpc2.Error {
+	rpcErr := &jsonrpc2.Error{
+		Code:    CodeMinContextSlotNotReached,
+		Message: "Minimum context slot has not been reached",
+	}
+	data, err := json.Marshal(MinContextSlotNotReachedData{ContextSlot: contextSlot})
+	if err == nil {
+		raw := json.RawMessage(data)
+		rpcErr.Data = &raw
+	}
+	return rpcErr
+}
+
+// NewInternal returns a generic -32603 error.
+func NewInternal() *jsonrpc2.Error {
+	return &jsonrpc2.Error{
+		Code:    jsonrpc2.CodeInternalError,
+		Message: "Internal error",
+	}
+}
+
+// NewNodeBusy returns a -32005 (node unhealthy) error for a request that this node is too loaded to serve now;
+// like with an unhealthy node, the client can retry later, or on another node.
+func NewNodeBusy() *jsonrpc2.Error {
+	return &jsonrpc2.Error{
+		Code:    CodeNodeUnhealthy,
+		Message: "Node is busy, try again later",
+	}
+}
+
+// NewInvalidParams returns a -32602 error with the given message.
+func NewInvalidParams(message string) *jsonrpc2.Error {
+	return &jsonrpc2.Error{
+		Code:    jsonrpc2.CodeInvalidParams,
+		Message: message,
+	}
+}
+
+// ForMissingSlot returns the error object for the given kind.
+// It returns nil for kinds that must be answered with a null result.
+func ForMissingSlot(kind Kind, slot uint64, firstAvailable uint64) *jsonrpc2.Error {
+	switch kind {
+	case KindSlotSkipped:
+		return NewSlotSkipped(slot)
+	case KindBlockNotAvailable:
+		return NewBlockNotAvailable(slot)
+	case KindBlockCleanedUp:
+		return NewBlockCleanedUp(slot, firstAvailable)
+	case KindTransactionNotFound:
+		return nil
+	default:
+		return NewInternal()
+	}
+}
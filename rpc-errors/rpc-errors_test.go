@@ -0,0 +1,67 @@
+package rpcerrors
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyMissingSlot(t *testing.T) {
+	// epoch is loaded: the slot was skipped.
+	require.Equal(t, KindSlotSkipped, ClassifyMissingSlot(500, true, 432000, true))
+	require.Equal(t, KindSlotSkipped, ClassifyMissingSlot(432001, true, 432000, true))
+	// epoch is not loaded, slot is before the first available block.
+	require.Equal(t, KindBlockCleanedUp, ClassifyMissingSlot(500, false, 432000, true))
+	// epoch is not loaded, slot is after the first available block.
+	require.Equal(t, KindBlockNotAvailable, ClassifyMissingSlot(900000, false, 432000, true))
+	// the first available block is unknown.
+	require.Equal(t, KindBlockNotAvailable, ClassifyMissingSlot(500, false, 0, false))
+}
+
+func TestForMissingSlot(t *testing.T) {
+	{
+		got := ForMissingSlot(KindSlotSkipped, 123, 0)
+		require.Equal(t, CodeSlotSkipped, got.Code)
+		require.Equal(t, int64(-32007), got.Code)
+		require.Equal(t, "Slot 123 was skipped, or missing due to ledger jump to recent snapshot", got.Message)
+		require.Nil(t, got.Data)
+	}
+	{
+		got := ForMissingSlot(KindBlockNotAvailable, 123, 0)
+		require.Equal(t, int64(-32004), got.Code)
+		require.Equal(t, "Block not available for slot 123", got.Message)
+	}
+	{
+		got := ForMissingSlot(KindBlockCleanedUp, 123, 432000)
+		require.Equal(t, int64(-32001), got.Code)
+		require.Equal(t, "Block 123 cleaned up, does not exist on node. First available block: 432000", got.Message)
+		require.NotNil(t, got.Data)
+		var data BlockCleanedUpData
+		require.NoError(t, json.Unmarshal(*got.Data, &data))
+		require.Equal(t, BlockCleanedUpData{Slot: 123, FirstAvailableBlock: 432000}, data)
+	}
+	{
+		require.Nil(t, ForMissingSlot(KindTransactionNotFound, 123, 0))
+		require.True(t, KindTransactionNotFound.IsNullResult())
+		require.False(t, KindSlotSkipped.IsNullResult())
+	}
+	{
+		got := ForMissingSlot(KindInternal, 123, 0)
+		require.Equal(t, int64(jsonrpc2.CodeInternalError), got.Code)
+	}
+}
+
+func TestNewNodeBusy(t *testing.T) {
+	got := NewNodeBusy()
+	require.Equal(t, int64(-32005), got.Code)
+	require.Equal(t, "Node is busy, try again later", got.Message)
+}
+
+func TestNewMinContextSlotNotReached(t *testing.T) {
+	got := NewMinContextSlotNotReached(431999)
+	require.Equal(t, int64(-32016), got.Code)
+	require.Equal(t, "Minimum context slot has not been reached", got.Message)
+	require.JSONEq(t, `{"contextSlot":431999}`, string(*got.Data))
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// rpcLogger is the logger used by the RPC request path.
+// It writes through klog, so the klog flags (-v, log_file, etc.) still apply:
+// Debug is logged at -v=4, Info at -v=2, Warn and Error are always logged.
+var rpcLogger = slog.New(&klogSlogHandler{})
+
+type klogSlogHandler struct {
+	attrs  []slog.Attr
+	groups []string
+}
+
+var _ slog.Handler = (*klogSlogHandler)(nil)
+
+func klogVerbosityForLevel(level slog.Level) klog.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return 4
+	case level < slog.LevelWarn:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func (h *klogSlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return klog.V(klogVerbosityForLevel(level)).Enabled()
+}
+
+func (h *klogSlogHandler) Handle(_ context.Context, rec slog.Record) error {
+	var buf strings.Builder
+	buf.WriteString(rec.Message)
+	prefix := ""
+	if len(h.groups) > 0 {
+		prefix = strings.Join(h.groups, ".") + "."
+	}
+	for _, attr := range h.attrs {
+		writeSlogAttr(&buf, "", attr)
+	}
+	rec.Attrs(func(attr slog.Attr) bool {
+		writeSlogAttr(&buf, prefix, attr)
+		return true
+	})
+	const depth = 3
+	switch {
+	case rec.Level >= slog.LevelError:
+		klog.ErrorDepth(depth, buf.String())
+	case rec.Level >= slog.LevelWarn:
+		klog.WarningDepth(depth, buf.String())
+	default:
+		klog.InfoDepth(depth, buf.String())
+	}
+	return nil
+}
+
+func writeSlogAttr(buf *strings.Builder, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix = prefix + attr.Key + "."
+		}
+		for _, sub := range attr.Value.Group() {
+			writeSlogAttr(buf, groupPrefix, sub)
+		}
+		return
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(prefix)
+	buf.WriteString(attr.Key)
+	buf.WriteByte('=')
+	val := attr.Value.String()
+	if strings.ContainsAny(val, " \t\n\"=") || val == "" {
+		fmt.Fprintf(buf, "%q", val)
+	} else {
+		buf.WriteString(val)
+	}
+}
+
+func (h *klogSlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	prefixed := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	prefixed = append(prefixed, h.attrs...)
+	for _, attr := range attrs {
+		if len(h.groups) > 0 {
+			attr = slog.Attr{Key: strings.Join(h.groups, ".") + "." + attr.Key, Value: attr.Value}
+		}
+		prefixed = append(prefixed, attr)
+	}
+	return &klogSlogHandler{attrs: prefixed, groups: h.groups}
+}
+
+func (h *klogSlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := make([]string, 0, len(h.groups)+1)
+	groups = append(groups, h.groups...)
+	groups = append(groups, name)
+	return &klogSlogHandler{attrs: h.attrs, groups: groups}
+}
+
+// rpcRequestLog carries the per-request logging state:
+// the request ID, the method, what was requested (slot/signature/epoch),
+// and the timing of each step of the request.
+type rpcRequestLog struct {
+	mu        sync.Mutex
+	id        string
+	method    string
+	startedAt time.Time
+	prev      time.Time
+	attrs     []slog.Attr
+	steps     []rpcRequestStep
+}
+
+type rpcRequestStep struct {
+	name string
+	took time.Duration
+}
+
+func newRPCRequestLog(id string) *rpcRequestLog {
+	now := time.Now()
+	return &rpcRequestLog{
+		id:        id,
+		method:    "<unknown>",
+		startedAt: now,
+		prev:      now,
+	}
+}
+
+const rpcRequestLogKey = MyContextKey("rpcRequestLog")
+
+func withRPCRequestLog(ctx context.Context, l *rpcRequestLog) context.Context {
+	ctx = setRequestIDToContext(ctx, l.id)
+	return context.WithValue(ctx, rpcRequestLogKey, l)
+}
+
+// rpcRequestLogFromContext returns the request log from the context;
+// if there is none (e.g. gRPC or tests), a fresh one is returned so that callers never need to check for nil.
+func rpcRequestLogFromContext(ctx context.Context) *rpcRequestLog {
+	l, ok := ctx.Value(rpcRequestLogKey).(*rpcRequestLog)
+	if !ok {
+		return newRPCRequestLog(getRequestIDFromContext(ctx))
+	}
+	return l
+}
+
+func (l *rpcRequestLog) SetMethod(method string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.method = method
+}
+
+func (l *rpcRequestLog) Method() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.method
+}
+
+func (l *rpcRequestLog) setAttr(attr slog.Attr) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.attrs {
+		if l.attrs[i].Key == attr.Key {
+			l.attrs[i] = attr
+			return
+		}
+	}
+	l.attrs = append(l.attrs, attr)
+}
+
+func (l *rpcRequestLog) SetSlot(slot uint64) {
+	l.setAttr(slog.Uint64("slot", slot))
+}
+
+func (l *rpcRequestLog) SetSignature(sig fmt.Stringer) {
+	l.setAttr(slog.String("signature", sig.String()))
+}
+
+func (l *rpcRequestLog) SetEpoch(epoch uint64) {
+	l.setAttr(slog.Uint64("epoch", epoch))
+}
+
+// Step records how long it took since the previous step (or the start of the request).
+func (l *rpcRequestLog) Step(name string) {
+	l.mu.Lock()
+	now := time.Now()
+	took := now.Sub(l.prev)
+	l.prev = now
+	l.steps = append(l.steps, rpcRequestStep{name: name, took: took})
+	overall := now.Sub(l.startedAt)
+	l.mu.Unlock()
+	l.Logger().Debug("step", "step", name, "took", took, "overall", overall)
+}
+
+// Logger returns a logger that has the request attributes attached.
+func (l *rpcRequestLog) Logger() *slog.Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	args := make([]any, 0, len(l.attrs)+2)
+	args = append(args, slog.String("request_id", l.id), slog.String("method", sanitizeMethod(l.method)))
+	for _, attr := range l.attrs {
+		args = append(args, attr)
+	}
+	return rpcLogger.With(args...)
+}
+
+func (l *rpcRequestLog) timingsAttr() slog.Attr {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	attrs := make([]any, 0, len(l.steps))
+	for _, step := range l.steps {
+		attrs = append(attrs, slog.Duration(step.name, step.took))
+	}
+	return slog.Group("timings", attrs...)
+}
+
+// Finish logs the completion of the request;
+// if slowThreshold is non-zero and the request took longer than that, it is also logged as a slow query
+// together with the timing breakdown.
+func (l *rpcRequestLog) Finish(slowThreshold time.Duration) time.Duration {
+	took := time.Since(l.startedAt)
+	logger := l.Logger()
+	logger.Info("request done", "took", took)
+	if slowThreshold > 0 && took >= slowThreshold {
+		logger.Warn("slow query", "took", took, "threshold", slowThreshold, l.timingsAttr())
+	}
+	return took
+}
@@ -0,0 +1,228 @@
+// Package rpcsanitycheck compares the responses of a target RPC (e.g. an old-faithful
+// server) with the ones of a reference RPC, and reports the divergences.
+package rpcsanitycheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ybbus/jsonrpc/v3"
+)
+
+// Divergence is a difference between the reference and the target responses.
+type Divergence struct {
+	Address string
+	Page    int
+	// Index is the position of the signature in the page; -1 if the divergence is about the whole page.
+	Index  int
+	Field  string
+	Ref    string
+	Target string
+}
+
+func (d Divergence) String() string {
+	return fmt.Sprintf("%s page %d index %d: %s differs: ref=%s target=%s", d.Address, d.Page, d.Index, d.Field, d.Ref, d.Target)
+}
+
+const voteProgram = "Vote111111111111111111111111111111111111111"
+
+// SampleAddresses returns up to max addresses that are the most active in the blocks at
+// the given slots (read from the reference), most active first. Vote accounts and sysvars
+// are left out: their history is mostly votes, which doesn't exercise the index much.
+func SampleAddresses(ctx context.Context, ref jsonrpc.RPCClient, slots []uint64, max int) ([]string, error) {
+	counts := make(map[string]int)
+	for _, slot := range slots {
+		var block struct {
+			Transactions []struct {
+				Transaction struct {
+					AccountKeys []accountKey `json:"accountKeys"`
+				} `json:"transaction"`
+			} `json:"transactions"`
+		}
+		err := ref.CallFor(ctx, &block, "getBlock", slot, map[string]any{
+			"encoding":                       "json",
+			"transactionDetails":             "accounts",
+			"rewards":                        false,
+			"maxSupportedTransactionVersion": 0,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get block %d: %w", slot, err)
+		}
+		for _, tx := range block.Transactions {
+			keys := tx.Transaction.AccountKeys
+			if isVote(keys) {
+				continue
+			}
+			for _, key := range keys {
+				if strings.HasPrefix(key.Pubkey, "Sysvar") {
+					continue
+				}
+				counts[key.Pubkey]++
+			}
+		}
+	}
+	addresses := make([]string, 0, len(counts))
+	for address := range counts {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		if counts[addresses[i]] != counts[addresses[j]] {
+			return counts[addresses[i]] > counts[addresses[j]]
+		}
+		return addresses[i] < addresses[j]
+	})
+	if len(addresses) > max {
+		addresses = addresses[:max]
+	}
+	return addresses, nil
+}
+
+type accountKey struct {
+	Pubkey string `json:"pubkey"`
+}
+
+func isVote(keys []accountKey) bool {
+	for _, key := range keys {
+		if key.Pubkey == voteProgram {
+			return true
+		}
+	}
+	return false
+}
+
+// GsfaOptions configures the getSignaturesForAddress cross-check.
+type GsfaOptions struct {
+	// PageSize is the limit of each request (1-1000).
+	PageSize int
+	// MaxPages is the number of pages compared per address.
+	MaxPages int
+	// RulesVersion is the version of the canonicalization rules used to compare the errors.
+	RulesVersion int
+	// RefIsFaithful is set when the reference is another old-faithful instance: the errors
+	// must then be exactly the same.
+	RefIsFaithful bool
+}
+
+type signatureInfo struct {
+	Signature string          `json:"signature"`
+	Slot      uint64          `json:"slot"`
+	Err       json.RawMessage `json:"err"`
+	Memo      *string         `json:"memo"`
+	BlockTime *int64          `json:"blockTime"`
+}
+
+// CompareSignaturesForAddress pages through the signatures of the address on both RPCs,
+// using the last signature of the reference page as the cursor of the next one, and
+// compares the pages: the order of the signatures, and their slot, err, memo and blockTime.
+// It returns the number of compared signatures and the divergences.
+func CompareSignaturesForAddress(ctx context.Context, ref, target jsonrpc.RPCClient, address string, opts GsfaOptions) (int, []Divergence, error) {
+	var (
+		compared    int
+		divergences []Divergence
+		before      string
+	)
+	for page := 0; page < opts.MaxPages; page++ {
+		params := map[string]any{"limit": opts.PageSize}
+		if before != "" {
+			params["before"] = before
+		}
+		var refPage, targetPage []signatureInfo
+		if err := ref.CallFor(ctx, &refPage, "getSignaturesForAddress", address, params); err != nil {
+			return compared, divergences, fmt.Errorf("ref: %w", err)
+		}
+		if err := target.CallFor(ctx, &targetPage, "getSignaturesForAddress", address, params); err != nil {
+			divergences = append(divergences, Divergence{Address: address, Page: page, Index: -1, Field: "error", Target: err.Error()})
+			return compared, divergences, nil
+		}
+		pageDivergences, err := comparePage(refPage, targetPage, opts)
+		if err != nil {
+			return compared, divergences, err
+		}
+		for _, d := range pageDivergences {
+			d.Address = address
+			d.Page = page
+			divergences = append(divergences, d)
+		}
+		compared += len(refPage)
+		if len(refPage) < opts.PageSize || len(refPage) == 0 {
+			break
+		}
+		before = refPage[len(refPage)-1].Signature
+	}
+	return compared, divergences, nil
+}
+
+func comparePage(ref, target []signatureInfo, opts GsfaOptions) ([]Divergence, error) {
+	var divergences []Divergence
+	if len(ref) != len(target) {
+		divergences = append(divergences, Divergence{Index: -1, Field: "length", Ref: fmt.Sprint(len(ref)), Target: fmt.Sprint(len(target))})
+	}
+	for i := 0; i < len(ref) && i < len(target); i++ {
+		r, t := ref[i], target[i]
+		if r.Signature != t.Signature {
+			// the pages are out of order: the other fields can't be compared.
+			divergences = append(divergences, Divergence{Index: i, Field: "signature", Ref: r.Signature, Target: t.Signature})
+			continue
+		}
+		if r.Slot != t.Slot {
+			divergences = append(divergences, Divergence{Index: i, Field: "slot", Ref: fmt.Sprint(r.Slot), Target: fmt.Sprint(t.Slot)})
+		}
+		equal, err := equalResults(orNull(r.Err), orNull(t.Err), opts.RulesVersion, opts.RefIsFaithful)
+		if err != nil {
+			return nil, fmt.Errorf("signature %s: failed to compare err: %w", r.Signature, err)
+		}
+		if !equal {
+			divergences = append(divergences, Divergence{Index: i, Field: "err", Ref: string(orNull(r.Err)), Target: string(orNull(t.Err))})
+		}
+		if ptrString(r.Memo) != ptrString(t.Memo) {
+			divergences = append(divergences, Divergence{Index: i, Field: "memo", Ref: ptrString(r.Memo), Target: ptrString(t.Memo)})
+		}
+		if ptrString(r.BlockTime) != ptrString(t.BlockTime) {
+			divergences = append(divergences, Divergence{Index: i, Field: "blockTime", Ref: ptrString(r.BlockTime), Target: ptrString(t.BlockTime)})
+		}
+	}
+	return divergences, nil
+}
+
+func orNull(raw json.RawMessage) []byte {
+	if len(raw) == 0 {
+		return []byte("null")
+	}
+	return raw
+}
+
+func ptrString[T any](v *T) string {
+	if v == nil {
+		return "null"
+	}
+	return fmt.Sprint(*v)
+}
+
+// GsfaReport is the outcome of a getSignaturesForAddress cross-check.
+type GsfaReport struct {
+	Addresses   []string
+	Signatures  int
+	Divergences []Divergence
+}
+
+// CheckSignaturesForAddress picks up to maxAddresses active addresses from the blocks at
+// the given slots, and compares their signatures on the reference and the target.
+func CheckSignaturesForAddress(ctx context.Context, ref, target jsonrpc.RPCClient, slots []uint64, maxAddresses int, opts GsfaOptions) (*GsfaReport, error) {
+	addresses, err := SampleAddresses(ctx, ref, slots, maxAddresses)
+	if err != nil {
+		return nil, err
+	}
+	report := &GsfaReport{Addresses: addresses}
+	for _, address := range addresses {
+		compared, divergences, err := CompareSignaturesForAddress(ctx, ref, target, address, opts)
+		if err != nil {
+			return nil, fmt.Errorf("address %s: %w", address, err)
+		}
+		report.Signatures += compared
+		report.Divergences = append(report.Divergences, divergences...)
+	}
+	return report, nil
+}
@@ -0,0 +1,125 @@
+package rpcsanitycheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/canonicalize"
+	"github.com/stretchr/testify/require"
+	"github.com/ybbus/jsonrpc/v3"
+)
+
+// fakeRPC answers JSON-RPC calls with handle(method, params).
+func fakeRPC(t *testing.T, handle func(method string, params []json.RawMessage) any) jsonrpc.RPCClient {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int               `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": handle(req.Method, req.Params)})
+	}))
+	t.Cleanup(srv.Close)
+	return jsonrpc.NewClient(srv.URL)
+}
+
+// gsfaHandler serves the signatures in pages, honoring limit and before.
+func gsfaHandler(t *testing.T, sigs []map[string]any) func(string, []json.RawMessage) any {
+	return func(method string, params []json.RawMessage) any {
+		require.Equal(t, "getSignaturesForAddress", method)
+		var opts struct {
+			Limit  int    `json:"limit"`
+			Before string `json:"before"`
+		}
+		require.NoError(t, json.Unmarshal(params[1], &opts))
+		start := 0
+		if opts.Before != "" {
+			for i, sig := range sigs {
+				if sig["signature"] == opts.Before {
+					start = i + 1
+				}
+			}
+		}
+		end := start + opts.Limit
+		if end > len(sigs) {
+			end = len(sigs)
+		}
+		return sigs[start:end]
+	}
+}
+
+func sigs(n int) []map[string]any {
+	out := make([]map[string]any, n)
+	for i := range out {
+		out[i] = map[string]any{"signature": string(rune('a' + i)), "slot": 100 - i, "err": nil, "memo": nil, "blockTime": 1000 - i}
+	}
+	return out
+}
+
+var testOpts = GsfaOptions{PageSize: 2, MaxPages: 10, RulesVersion: canonicalize.Latest}
+
+func TestCompareSignaturesForAddressEqual(t *testing.T) {
+	ref := fakeRPC(t, gsfaHandler(t, sigs(5)))
+	target := fakeRPC(t, gsfaHandler(t, sigs(5)))
+	compared, divergences, err := CompareSignaturesForAddress(context.Background(), ref, target, "addr", testOpts)
+	require.NoError(t, err)
+	require.Equal(t, 5, compared)
+	require.Empty(t, divergences)
+}
+
+func TestCompareSignaturesForAddressDivergences(t *testing.T) {
+	targetSigs := sigs(5)
+	targetSigs[1]["memo"] = "hello"
+	targetSigs[2]["err"] = map[string]any{"InstructionError": []any{0, "Custom"}}
+	targetSigs[0]["blockTime"] = nil
+	// swap the last two: the order differs, and the target has nothing before the last one.
+	targetSigs[3], targetSigs[4] = targetSigs[4], targetSigs[3]
+
+	ref := fakeRPC(t, gsfaHandler(t, sigs(5)))
+	target := fakeRPC(t, gsfaHandler(t, targetSigs))
+	_, divergences, err := CompareSignaturesForAddress(context.Background(), ref, target, "addr", testOpts)
+	require.NoError(t, err)
+	var fields []string
+	for _, d := range divergences {
+		fields = append(fields, d.Field)
+	}
+	require.Equal(t, []string{"blockTime", "memo", "err", "signature", "length"}, fields)
+	require.Equal(t, 0, divergences[1].Page)
+	require.Equal(t, 1, divergences[1].Index)
+	require.Equal(t, 1, divergences[2].Page)
+	require.Equal(t, 0, divergences[2].Index)
+}
+
+func TestCompareSignaturesForAddressMissing(t *testing.T) {
+	ref := fakeRPC(t, gsfaHandler(t, sigs(3)))
+	target := fakeRPC(t, gsfaHandler(t, sigs(1)))
+	_, divergences, err := CompareSignaturesForAddress(context.Background(), ref, target, "addr", testOpts)
+	require.NoError(t, err)
+	require.Equal(t, "length", divergences[0].Field)
+	require.Equal(t, -1, divergences[0].Index)
+}
+
+func TestSampleAddresses(t *testing.T) {
+	tx := func(keys ...string) map[string]any {
+		var accountKeys []map[string]any
+		for _, key := range keys {
+			accountKeys = append(accountKeys, map[string]any{"pubkey": key})
+		}
+		return map[string]any{"transaction": map[string]any{"accountKeys": accountKeys}}
+	}
+	ref := fakeRPC(t, func(method string, params []json.RawMessage) any {
+		require.Equal(t, "getBlock", method)
+		return map[string]any{"transactions": []any{
+			tx("payer", "program", "SysvarC1ock11111111111111111111111111111111"),
+			tx("payer", "other"),
+			tx("validator", voteProgram),
+		}}
+	})
+	addresses, err := SampleAddresses(context.Background(), ref, []uint64{1, 2}, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"payer", "other"}, addresses)
+}
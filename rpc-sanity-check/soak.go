@@ -0,0 +1,376 @@
+package rpcsanitycheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rpcpool/yellowstone-faithful/canonicalize"
+	"github.com/ybbus/jsonrpc/v3"
+)
+
+// maxTrackedLatency is the largest latency with a millisecond resolution; slower
+// calls are counted in the last bucket.
+const maxTrackedLatency = time.Minute
+
+// MethodStats are the results of the calls of one method on the target.
+type MethodStats struct {
+	Calls      int
+	Errors     int
+	Mismatches int
+	// latencies[i] is the number of calls that took i milliseconds.
+	latencies []int
+}
+
+func (s *MethodStats) record(latency time.Duration) {
+	if s.latencies == nil {
+		s.latencies = make([]int, maxTrackedLatency/time.Millisecond+1)
+	}
+	ms := int(latency / time.Millisecond)
+	if ms >= len(s.latencies) {
+		ms = len(s.latencies) - 1
+	}
+	s.latencies[ms]++
+	s.Calls++
+}
+
+// Percentile returns the latency below which p (0-100) percent of the calls completed.
+func (s *MethodStats) Percentile(p float64) time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	// nearest rank.
+	rank := int(math.Ceil(float64(s.Calls) * p / 100))
+	if rank < 1 {
+		rank = 1
+	}
+	seen := 0
+	for ms, n := range s.latencies {
+		seen += n
+		if seen >= rank {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return maxTrackedLatency
+}
+
+// ErrorRate is the fraction of calls that failed on the target but not on the reference.
+func (s *MethodStats) ErrorRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Calls)
+}
+
+// MismatchRate is the fraction of calls whose response differs from the reference.
+func (s *MethodStats) MismatchRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Mismatches) / float64(s.Calls)
+}
+
+// SLO is an objective for one method ("*" for all of them), e.g. "getTransaction:p99<300ms",
+// "*:mismatches<0.01%" or "getBlock:errors<0.1%".
+type SLO struct {
+	Method string
+	// Kind is "latency", "mismatches" or "errors".
+	Kind string
+	// Percentile and MaxLatency are set for latency objectives.
+	Percentile float64
+	MaxLatency time.Duration
+	// MaxRate is set for mismatch and error objectives (a fraction, not a percentage).
+	MaxRate float64
+	text    string
+}
+
+func (o SLO) String() string {
+	return o.text
+}
+
+// ParseSLO parses an objective: "<method>:p<percentile><<duration>", "<method>:mismatches<<percent>%"
+// or "<method>:errors<<percent>%".
+func ParseSLO(s string) (SLO, error) {
+	method, objective, ok := strings.Cut(s, ":")
+	if !ok || method == "" {
+		return SLO{}, fmt.Errorf("invalid SLO %q: expected <method>:<objective>", s)
+	}
+	metric, limit, ok := strings.Cut(objective, "<")
+	if !ok {
+		return SLO{}, fmt.Errorf("invalid SLO %q: expected <metric><<limit>", s)
+	}
+	slo := SLO{Method: method, text: s}
+	switch {
+	case metric == "mismatches" || metric == "errors":
+		slo.Kind = metric
+		percent, ok := strings.CutSuffix(limit, "%")
+		if !ok {
+			return SLO{}, fmt.Errorf("invalid SLO %q: the limit of %s must be a percentage", s, metric)
+		}
+		rate, err := strconv.ParseFloat(percent, 64)
+		if err != nil {
+			return SLO{}, fmt.Errorf("invalid SLO %q: %w", s, err)
+		}
+		slo.MaxRate = rate / 100
+	case strings.HasPrefix(metric, "p"):
+		slo.Kind = "latency"
+		percentile, err := strconv.ParseFloat(metric[1:], 64)
+		if err != nil || percentile <= 0 || percentile > 100 {
+			return SLO{}, fmt.Errorf("invalid SLO %q: invalid percentile %q", s, metric)
+		}
+		slo.Percentile = percentile
+		slo.MaxLatency, err = time.ParseDuration(limit)
+		if err != nil {
+			return SLO{}, fmt.Errorf("invalid SLO %q: %w", s, err)
+		}
+	default:
+		return SLO{}, fmt.Errorf("invalid SLO %q: unknown metric %q", s, metric)
+	}
+	return slo, nil
+}
+
+// Violation is an objective that was not met.
+type Violation struct {
+	SLO    SLO
+	Method string
+	Actual string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s is %s", v.SLO, v.Method, v.Actual)
+}
+
+// SoakReport are the per-method results of a soak run.
+type SoakReport struct {
+	Iterations int
+	// Slots are the first maxReportedSlots compared slots.
+	Slots   []uint64
+	Methods map[string]*MethodStats
+}
+
+const maxReportedSlots = 100
+
+func (r *SoakReport) method(name string) *MethodStats {
+	if r.Methods[name] == nil {
+		r.Methods[name] = &MethodStats{}
+	}
+	return r.Methods[name]
+}
+
+// MethodNames returns the names of the methods that were called, sorted.
+func (r *SoakReport) MethodNames() []string {
+	names := make([]string, 0, len(r.Methods))
+	for name := range r.Methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Evaluate returns the objectives that are not met.
+func (r *SoakReport) Evaluate(slos []SLO) []Violation {
+	var violations []Violation
+	for _, slo := range slos {
+		for _, name := range r.MethodNames() {
+			if slo.Method != "*" && slo.Method != name {
+				continue
+			}
+			stats := r.Methods[name]
+			switch slo.Kind {
+			case "latency":
+				if got := stats.Percentile(slo.Percentile); got >= slo.MaxLatency {
+					violations = append(violations, Violation{SLO: slo, Method: name, Actual: got.String()})
+				}
+			case "mismatches":
+				if got := stats.MismatchRate(); got >= slo.MaxRate {
+					violations = append(violations, Violation{SLO: slo, Method: name, Actual: formatPercent(got)})
+				}
+			case "errors":
+				if got := stats.ErrorRate(); got >= slo.MaxRate {
+					violations = append(violations, Violation{SLO: slo, Method: name, Actual: formatPercent(got)})
+				}
+			}
+		}
+	}
+	return violations
+}
+
+func formatPercent(rate float64) string {
+	return strconv.FormatFloat(rate*100, 'g', 4, 64) + "%"
+}
+
+// SoakOptions configures a soak run.
+type SoakOptions struct {
+	// FirstSlot and LastSlot are the range the blocks are picked from.
+	FirstSlot uint64
+	LastSlot  uint64
+	// The run stops after Duration, or after Iterations blocks, whichever comes first;
+	// zero means no limit (but at least one of them must be set).
+	Duration   time.Duration
+	Iterations int
+	// RulesVersion is the version of the canonicalization rules used to compare the responses.
+	RulesVersion int
+	// RefIsFaithful is set when the reference is another old-faithful instance (e.g. to validate a
+	// re-generated epoch): the responses must then be exactly the same (no canonicalization), and a
+	// slot the reference doesn't have must not be on the target either.
+	RefIsFaithful bool
+	Rand          *rand.Rand
+	// OnMismatch, if set, is called for every response of the target that differs from the reference.
+	OnMismatch func(method string, param string)
+}
+
+var blockOptions = map[string]any{
+	"encoding":                       "json",
+	"transactionDetails":             "full",
+	"rewards":                        true,
+	"maxSupportedTransactionVersion": 0,
+}
+
+var transactionOptions = map[string]any{
+	"encoding":                       "json",
+	"maxSupportedTransactionVersion": 0,
+}
+
+// Soak repeatedly picks a random slot in the range, and compares the getBlock response of
+// the target with the one of the reference, then the getTransaction response of a random
+// transaction of the block. Slots the reference doesn't have (e.g. skipped) are not counted,
+// unless opts.RefIsFaithful is set, in which case a getBlock of the target that returns them is
+// a mismatch. The latencies are the ones of the target.
+func Soak(ctx context.Context, ref, target jsonrpc.RPCClient, opts SoakOptions) (*SoakReport, error) {
+	if opts.Duration == 0 && opts.Iterations == 0 {
+		return nil, errors.New("either a duration or a number of iterations is required")
+	}
+	if opts.LastSlot < opts.FirstSlot {
+		return nil, fmt.Errorf("invalid slot range %d-%d", opts.FirstSlot, opts.LastSlot)
+	}
+	rng := opts.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+	report := &SoakReport{Methods: make(map[string]*MethodStats)}
+	for opts.Iterations == 0 || report.Iterations < opts.Iterations {
+		if ctx.Err() != nil {
+			break
+		}
+		slot := opts.FirstSlot + uint64(rng.Int63n(int64(opts.LastSlot-opts.FirstSlot+1)))
+		var refBlock json.RawMessage
+		if err := ref.CallFor(ctx, &refBlock, "getBlock", slot, blockOptions); err != nil || isNull(refBlock) {
+			if opts.RefIsFaithful {
+				compareMissing(ctx, report, target, slot, opts)
+			}
+			continue
+		}
+		report.Iterations++
+		if len(report.Slots) < maxReportedSlots {
+			report.Slots = append(report.Slots, slot)
+		}
+		if err := compareCall(ctx, report, target, refBlock, "getBlock", fmt.Sprint(slot), opts, slot, blockOptions); err != nil {
+			return nil, err
+		}
+
+		var block struct {
+			Transactions []struct {
+				Transaction struct {
+					Signatures []string `json:"signatures"`
+				} `json:"transaction"`
+			} `json:"transactions"`
+		}
+		if err := json.Unmarshal(refBlock, &block); err != nil {
+			return nil, fmt.Errorf("failed to decode block %d of the reference: %w", slot, err)
+		}
+		if len(block.Transactions) == 0 || len(block.Transactions[0].Transaction.Signatures) == 0 {
+			continue
+		}
+		signature := block.Transactions[rng.Intn(len(block.Transactions))].Transaction.Signatures[0]
+		var refTx json.RawMessage
+		if err := ref.CallFor(ctx, &refTx, "getTransaction", signature, transactionOptions); err != nil || isNull(refTx) {
+			continue
+		}
+		if err := compareCall(ctx, report, target, refTx, "getTransaction", signature, opts, signature, transactionOptions); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// compareCall calls the method on the target and compares the result with the one of the reference.
+func compareCall(ctx context.Context, report *SoakReport, target jsonrpc.RPCClient, refResult json.RawMessage, method string, param string, opts SoakOptions, params ...any) error {
+	var result json.RawMessage
+	startedAt := time.Now()
+	err := target.CallFor(ctx, &result, method, params...)
+	if ctx.Err() != nil {
+		// the run is over: the call was interrupted, it's not a failure of the target.
+		return nil
+	}
+	stats := report.method(method)
+	stats.record(time.Since(startedAt))
+	if err != nil || isNull(result) {
+		stats.Errors++
+		return nil
+	}
+	equal, err := equalResults(refResult, result, opts.RulesVersion, opts.RefIsFaithful)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, param, err)
+	}
+	if !equal {
+		stats.Mismatches++
+		if opts.OnMismatch != nil {
+			opts.OnMismatch(method, param)
+		}
+	}
+	return nil
+}
+
+// compareMissing calls getBlock on the target for a slot the reference doesn't have, and counts
+// a mismatch if the target has it.
+func compareMissing(ctx context.Context, report *SoakReport, target jsonrpc.RPCClient, slot uint64, opts SoakOptions) {
+	var result json.RawMessage
+	startedAt := time.Now()
+	err := target.CallFor(ctx, &result, "getBlock", slot, blockOptions)
+	if ctx.Err() != nil {
+		return
+	}
+	stats := report.method("getBlock")
+	stats.record(time.Since(startedAt))
+	if err == nil && !isNull(result) {
+		stats.Mismatches++
+		if opts.OnMismatch != nil {
+			opts.OnMismatch("getBlock", fmt.Sprint(slot))
+		}
+	}
+}
+
+// equalResults compares two responses, exactly if exact is set, or else after canonicalization.
+func equalResults(ref, target []byte, rulesVersion int, exact bool) (bool, error) {
+	if exact {
+		return canonicalize.EqualExact(ref, target)
+	}
+	return canonicalize.Equal(ref, target, rulesVersion)
+}
+
+// IsFaithful returns true if the RPC server is an old-faithful instance (its getVersion
+// response has a faithful field).
+func IsFaithful(ctx context.Context, client jsonrpc.RPCClient) (bool, error) {
+	var version map[string]json.RawMessage
+	if err := client.CallFor(ctx, &version, "getVersion"); err != nil {
+		return false, err
+	}
+	_, ok := version["faithful"]
+	return ok, nil
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
@@ -0,0 +1,165 @@
+package rpcsanitycheck
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/rpcpool/yellowstone-faithful/canonicalize"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSLO(t *testing.T) {
+	slo, err := ParseSLO("getTransaction:p99<300ms")
+	require.NoError(t, err)
+	require.Equal(t, "getTransaction", slo.Method)
+	require.Equal(t, "latency", slo.Kind)
+	require.Equal(t, 99.0, slo.Percentile)
+	require.Equal(t, 300*time.Millisecond, slo.MaxLatency)
+
+	slo, err = ParseSLO("*:mismatches<0.01%")
+	require.NoError(t, err)
+	require.Equal(t, "mismatches", slo.Kind)
+	require.InDelta(t, 0.0001, slo.MaxRate, 1e-12)
+
+	for _, invalid := range []string{"getBlock", "getBlock:p99", "getBlock:p0<1s", "getBlock:errors<1", "getBlock:p99<fast", "getBlock:foo<1%"} {
+		_, err := ParseSLO(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var stats MethodStats
+	require.Zero(t, stats.Percentile(99))
+	for i := 1; i <= 100; i++ {
+		stats.record(time.Duration(i) * time.Millisecond)
+	}
+	stats.record(2 * maxTrackedLatency)
+	require.Equal(t, 51*time.Millisecond, stats.Percentile(50))
+	require.Equal(t, 100*time.Millisecond, stats.Percentile(99))
+	require.Equal(t, maxTrackedLatency, stats.Percentile(100))
+}
+
+func TestEvaluate(t *testing.T) {
+	report := &SoakReport{Methods: make(map[string]*MethodStats)}
+	block := report.method("getBlock")
+	tx := report.method("getTransaction")
+	for i := 0; i < 100; i++ {
+		block.record(10 * time.Millisecond)
+		tx.record(500 * time.Millisecond)
+	}
+	block.Mismatches = 1
+
+	var slos []SLO
+	for _, s := range []string{"getTransaction:p99<300ms", "getBlock:p99<300ms", "*:mismatches<0.5%", "*:errors<1%"} {
+		slo, err := ParseSLO(s)
+		require.NoError(t, err)
+		slos = append(slos, slo)
+	}
+	violations := report.Evaluate(slos)
+	require.Len(t, violations, 2)
+	require.Equal(t, "getTransaction:p99<300ms: getTransaction is 500ms", violations[0].String())
+	require.Equal(t, "*:mismatches<0.5%: getBlock is 1%", violations[1].String())
+}
+
+func TestSoak(t *testing.T) {
+	blockFor := func(slot uint64, blockhash string) any {
+		if slot%2 == 1 {
+			// skipped slot.
+			return nil
+		}
+		return map[string]any{"blockhash": blockhash, "blockHeight": 0, "transactions": []any{
+			map[string]any{"transaction": map[string]any{"signatures": []any{"sig"}}},
+		}}
+	}
+	handler := func(blockhash string) func(string, []json.RawMessage) any {
+		return func(method string, params []json.RawMessage) any {
+			switch method {
+			case "getBlock":
+				var slot uint64
+				require.NoError(t, json.Unmarshal(params[0], &slot))
+				return blockFor(slot, blockhash)
+			case "getTransaction":
+				return map[string]any{"slot": 2, "meta": map[string]any{"logMessages": []any{"a"}}}
+			}
+			t.Fatalf("unexpected method %s", method)
+			return nil
+		}
+	}
+	ref := fakeRPC(t, handler("same"))
+	opts := SoakOptions{FirstSlot: 0, LastSlot: 9, Iterations: 20, RulesVersion: canonicalize.Latest, Rand: rand.New(rand.NewSource(1))}
+
+	report, err := Soak(context.Background(), ref, fakeRPC(t, handler("same")), opts)
+	require.NoError(t, err)
+	require.Equal(t, 20, report.Iterations)
+	require.NotEmpty(t, report.Slots)
+	require.Equal(t, 20, report.Methods["getBlock"].Calls)
+	require.Equal(t, 20, report.Methods["getTransaction"].Calls)
+	require.Zero(t, report.Methods["getBlock"].Mismatches)
+
+	var mismatches int
+	opts.OnMismatch = func(method, param string) {
+		require.Equal(t, "getBlock", method)
+		mismatches++
+	}
+	report, err = Soak(context.Background(), ref, fakeRPC(t, handler("different")), opts)
+	require.NoError(t, err)
+	require.Equal(t, 20, report.Methods["getBlock"].Mismatches)
+	require.Equal(t, 20, mismatches)
+	require.Zero(t, report.Methods["getTransaction"].Mismatches)
+
+	_, err = Soak(context.Background(), ref, ref, SoakOptions{LastSlot: 9})
+	require.Error(t, err)
+}
+
+func TestSoakRefIsFaithful(t *testing.T) {
+	handler := func(blockHeight any, hasSkipped bool) func(string, []json.RawMessage) any {
+		return func(method string, params []json.RawMessage) any {
+			switch method {
+			case "getBlock":
+				var slot uint64
+				require.NoError(t, json.Unmarshal(params[0], &slot))
+				if slot%2 == 1 && !hasSkipped {
+					return nil
+				}
+				return map[string]any{"blockhash": "same", "blockHeight": blockHeight, "transactions": []any{}}
+			case "getVersion":
+				return map[string]any{"solana-core": "1.17.0", "faithful": map[string]any{"version": "dev"}}
+			}
+			t.Fatalf("unexpected method %s", method)
+			return nil
+		}
+	}
+	ref := fakeRPC(t, handler(0, false))
+	isFaithful, err := IsFaithful(context.Background(), ref)
+	require.NoError(t, err)
+	require.True(t, isFaithful)
+
+	opts := SoakOptions{FirstSlot: 0, LastSlot: 9, Iterations: 20, RulesVersion: canonicalize.Latest, Rand: rand.New(rand.NewSource(1))}
+	// a zero and a null block height are the same after canonicalization...
+	report, err := Soak(context.Background(), ref, fakeRPC(t, handler(nil, false)), opts)
+	require.NoError(t, err)
+	require.Zero(t, report.Methods["getBlock"].Mismatches)
+	// ...but not for two faithful instances.
+	opts.RefIsFaithful = true
+	opts.Rand = rand.New(rand.NewSource(1))
+	report, err = Soak(context.Background(), ref, fakeRPC(t, handler(nil, false)), opts)
+	require.NoError(t, err)
+	require.Equal(t, 20, report.Methods["getBlock"].Mismatches)
+
+	opts.Rand = rand.New(rand.NewSource(1))
+	report, err = Soak(context.Background(), ref, fakeRPC(t, handler(0, false)), opts)
+	require.NoError(t, err)
+	require.Zero(t, report.Methods["getBlock"].Mismatches)
+	// the blocks of the skipped slots are counted as calls, not iterations.
+	require.Greater(t, report.Methods["getBlock"].Calls, 20)
+
+	// the target has blocks in slots the reference doesn't have.
+	opts.Rand = rand.New(rand.NewSource(1))
+	report, err = Soak(context.Background(), ref, fakeRPC(t, handler(0, true)), opts)
+	require.NoError(t, err)
+	require.Equal(t, report.Methods["getBlock"].Calls-20, report.Methods["getBlock"].Mismatches)
+	require.NotZero(t, report.Methods["getBlock"].Mismatches)
+}
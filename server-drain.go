@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// errShuttingDown is the cause of the cancellation of the streams stopped by the shutdown of the server.
+var errShuttingDown = errors.New("the server is shutting down")
+
+const (
+	// drainPollInterval is how often the shutdown checks whether the requests and the streams are done.
+	drainPollInterval = 50 * time.Millisecond
+	// forcedStopWait is how long the shutdown waits for the requests and the streams that were
+	// canceled at the deadline to return, before the epochs are closed anyway.
+	forcedStopWait = 5 * time.Second
+)
+
+// serverDrain coordinates the graceful shutdown of the RPC and gRPC servers. Once draining,
+// /health fails (so that the load balancers stop sending requests), the HTTP responses close their
+// connections and the websockets don't accept new subscriptions. The requests and the streams (SSE
+// and websocket replays) in flight get until the shutdown deadline to finish; the streams still
+// running then are canceled with errShuttingDown.
+type serverDrain struct {
+	startOnce sync.Once
+	started   chan struct{}
+	requests  atomic.Int64
+	streams   atomic.Int64
+	// ctx is the parent of the contexts of the streams.
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+func newServerDrain() *serverDrain {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return &serverDrain{
+		started: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// start starts draining; it's a no-op if the server is already draining.
+func (d *serverDrain) start() {
+	d.startOnce.Do(func() { close(d.started) })
+}
+
+// draining returns a channel that is closed when the server starts draining
+// (never, if d is nil).
+func (d *serverDrain) draining() <-chan struct{} {
+	if d == nil {
+		return nil
+	}
+	return d.started
+}
+
+func (d *serverDrain) isDraining() bool {
+	select {
+	case <-d.draining():
+		return true
+	default:
+		return false
+	}
+}
+
+// handler counts the requests in flight, and closes the connection after the response once draining.
+func (d *serverDrain) handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(reqCtx *fasthttp.RequestCtx) {
+		d.requests.Add(1)
+		defer d.requests.Add(-1)
+		next(reqCtx)
+		if d.isDraining() {
+			reqCtx.SetConnectionClose()
+		}
+	}
+}
+
+// stream returns the context of a new stream, which is canceled when the shutdown stops the
+// streams, and the function to call when the stream is done.
+func (d *serverDrain) stream() (context.Context, func()) {
+	if d == nil {
+		return context.WithCancel(context.Background())
+	}
+	d.streams.Add(1)
+	ctx, cancel := context.WithCancel(d.ctx)
+	return ctx, func() {
+		cancel()
+		d.streams.Add(-1)
+	}
+}
+
+// stop cancels the streams that are still running.
+func (d *serverDrain) stop() {
+	d.cancel(errShuttingDown)
+}
+
+// wait waits until no request or stream is in flight, or until ctx is done.
+func (d *serverDrain) wait(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for d.requests.Load() > 0 || d.streams.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// onceCloseListener ignores the calls to Close after the first one: the listener is closed when the
+// server starts draining, and again by the shutdown of the fasthttp server (which would fail otherwise).
+type onceCloseListener struct {
+	net.Listener
+	once sync.Once
+	err  error
+}
+
+func (l *onceCloseListener) Close() error {
+	l.once.Do(func() { l.err = l.Listener.Close() })
+	return l.err
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestShutdownHTTP(t *testing.T) {
+	n := NewNamespaces(&Options{ShutdownTimeout: 500 * time.Millisecond}, "")
+	rpcHandler := newMultiEpochHandler(n.Default(), nil)
+	health := func() int {
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Request.Header.SetMethod(fasthttp.MethodGet)
+		reqCtx.Request.SetRequestURI("/health")
+		rpcHandler(&reqCtx)
+		return reqCtx.Response.StatusCode()
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := &fasthttp.Server{Handler: n.drain.handler(func(reqCtx *fasthttp.RequestCtx) {
+		close(started)
+		<-release
+		// the request is not canceled by the shutdown.
+		if reqCtx.Err() == nil {
+			reqCtx.SetBodyString("done")
+		}
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	drainingLn := &onceCloseListener{Listener: ln}
+	go s.Serve(drainingLn)
+
+	responses := make(chan *fasthttp.Response, 1)
+	go func() {
+		resp := new(fasthttp.Response)
+		req := new(fasthttp.Request)
+		req.SetRequestURI("http://" + ln.Addr().String() + "/slow")
+		if err := fasthttp.Do(req, resp); err != nil {
+			resp.SetBodyString(err.Error())
+		}
+		responses <- resp
+	}()
+	<-started
+	// a stream that doesn't finish by itself.
+	streamCtx, streamDone := n.drain.stream()
+	go func() {
+		<-streamCtx.Done()
+		streamDone()
+	}()
+	require.Equal(t, fasthttp.StatusOK, health())
+
+	shutDown := make(chan struct{})
+	shutdownStarted := time.Now()
+	go func() {
+		defer close(shutDown)
+		n.shutdownHTTP(s, drainingLn)
+	}()
+	require.Eventually(t, func() bool { return health() == fasthttp.StatusServiceUnavailable }, time.Second, 10*time.Millisecond)
+	// no new connections.
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+
+	close(release)
+	resp := <-responses
+	require.Equal(t, "done", string(resp.Body()))
+	require.True(t, resp.ConnectionClose())
+
+	// the stream is canceled at the deadline.
+	<-streamCtx.Done()
+	require.ErrorIs(t, context.Cause(streamCtx), errShuttingDown)
+	require.GreaterOrEqual(t, time.Since(shutdownStarted), 500*time.Millisecond)
+	<-shutDown
+	require.NoError(t, n.drain.wait(context.Background()))
+}
+
+type slowVersionServer struct {
+	old_faithful_grpc.UnimplementedOldFaithfulServer
+	started chan struct{}
+}
+
+// GetVersion returns after 100ms if the call has a deadline, else when the call is canceled.
+func (s *slowVersionServer) GetVersion(ctx context.Context, req *old_faithful_grpc.VersionRequest) (*old_faithful_grpc.VersionResponse, error) {
+	s.started <- struct{}{}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return &old_faithful_grpc.VersionResponse{Version: "done"}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestShutdownGRPC(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	server := &slowVersionServer{started: make(chan struct{}, 2)}
+	drain := newServerDrain()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() {
+		served <- listenAndServeGRPC(ctx, addr, server, drain, &Options{ShutdownTimeout: time.Second})
+	}()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := old_faithful_grpc.NewOldFaithfulClient(conn)
+	type result struct {
+		resp *old_faithful_grpc.VersionResponse
+		err  error
+	}
+	finishing := make(chan result, 1)
+	stuck := make(chan result, 1)
+	go func() {
+		// the calls with a deadline finish in 100ms.
+		callCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		resp, err := client.GetVersion(callCtx, &old_faithful_grpc.VersionRequest{}, grpc.WaitForReady(true))
+		finishing <- result{resp, err}
+	}()
+	go func() {
+		resp, err := client.GetVersion(context.Background(), &old_faithful_grpc.VersionRequest{}, grpc.WaitForReady(true))
+		stuck <- result{resp, err}
+	}()
+	<-server.started
+	<-server.started
+
+	shutdownStarted := time.Now()
+	cancel()
+	finished := <-finishing
+	require.NoError(t, finished.err)
+	require.Equal(t, "done", finished.resp.Version)
+	require.True(t, drain.isDraining())
+
+	// the call still running at the deadline is canceled.
+	require.Error(t, (<-stuck).err)
+	require.NoError(t, <-served)
+	require.GreaterOrEqual(t, time.Since(shutdownStarted), time.Second)
+}
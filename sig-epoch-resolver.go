@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/bucketteer"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	"github.com/rpcpool/yellowstone-faithful/deprecated/compactindex36"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog/v2"
+)
+
+// The signature lookups (getTransaction etc.) of a server with many epochs go through a
+// sigEpochResolver, which:
+//   - batches the concurrent lookups: while all the batch slots are busy, the lookups queue
+//     up and the next batch takes them all, so each epoch's sig-exists index is probed once per
+//     batch (with the signatures sorted, for locality) instead of once per lookup;
+//   - skips the epochs whose in-memory summary (a bloom filter of the hashes of the sig-exists
+//     index) rules the signature out;
+//   - remembers, for a while, the signatures that weren't found in any epoch.
+const (
+	// sigEpochResolverMaxBatch is the maximum number of lookups of a batch.
+	sigEpochResolverMaxBatch = 256
+	// sigEpochResolverBatches is the number of batches that run at the same time.
+	sigEpochResolverBatches = 4
+	// sigNegativeCacheSize is the maximum number of signatures in the negative cache.
+	sigNegativeCacheSize = 1 << 16
+)
+
+var errSigEpochResolverClosed = errors.New("the signature resolver is closed")
+
+// sigEpochCandidate is an epoch that can have a signature.
+type sigEpochCandidate struct {
+	number uint64
+	index  SigExistsIndex
+	// verify checks that the signature is in the epoch, because the sig-exists index
+	// has false positives.
+	verify func(ctx context.Context, sig solana.Signature) error
+}
+
+type sigEpochRequest struct {
+	sig    solana.Signature
+	result chan sigEpochResult
+}
+
+type sigEpochResult struct {
+	epoch uint64
+	err   error
+}
+
+type sigEpochResolver struct {
+	// epochs returns the epochs to search (from the most recent to the oldest) and their
+	// generation, which changes with the set of epochs.
+	epochs func() (uint64, []sigEpochCandidate)
+	// generation returns the current generation of the set of epochs.
+	generation  func() uint64
+	concurrency int
+	requests    chan *sigEpochRequest
+	batches     chan struct{}
+	negative    *sigNegativeCache // nil if disabled
+	summaries   *sigSummaries     // nil if disabled
+	ctx         context.Context
+	cancel      context.CancelFunc
+}
+
+// newSigEpochResolver starts a resolver; concurrency limits the epochs probed at the same
+// time by a batch (0 for no limit), and negativeTTL and summaryBitsPerKey enable the negative
+// cache and the summaries if not zero.
+func newSigEpochResolver(
+	epochs func() (uint64, []sigEpochCandidate),
+	generation func() uint64,
+	concurrency int,
+	negativeTTL time.Duration,
+	summaryBitsPerKey uint,
+) *sigEpochResolver {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &sigEpochResolver{
+		epochs:      epochs,
+		generation:  generation,
+		concurrency: concurrency,
+		requests:    make(chan *sigEpochRequest, sigEpochResolverMaxBatch*sigEpochResolverBatches),
+		batches:     make(chan struct{}, sigEpochResolverBatches),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	if negativeTTL > 0 {
+		r.negative = newSigNegativeCache(negativeTTL, sigNegativeCacheSize)
+	}
+	if summaryBitsPerKey > 0 {
+		r.summaries = newSigSummaries(ctx, summaryBitsPerKey)
+	}
+	go r.run()
+	return r
+}
+
+// close stops the resolver; the pending lookups fail.
+func (r *sigEpochResolver) close() {
+	r.cancel()
+}
+
+// find returns the epoch of the signature, or ErrNotFound.
+func (r *sigEpochResolver) find(ctx context.Context, sig solana.Signature) (uint64, error) {
+	if r.negative != nil && r.negative.has(sig, r.generation()) {
+		return 0, ErrNotFound
+	}
+	req := &sigEpochRequest{sig: sig, result: make(chan sigEpochResult, 1)}
+	select {
+	case r.requests <- req:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-r.ctx.Done():
+		return 0, errSigEpochResolverClosed
+	}
+	select {
+	case res := <-req.result:
+		return res.epoch, res.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-r.ctx.Done():
+		return 0, errSigEpochResolverClosed
+	}
+}
+
+func (r *sigEpochResolver) run() {
+	for {
+		// take a batch slot first: while all of them are busy, the lookups queue up
+		// and the next batch takes them all.
+		select {
+		case r.batches <- struct{}{}:
+		case <-r.ctx.Done():
+			return
+		}
+		var batch []*sigEpochRequest
+		select {
+		case req := <-r.requests:
+			batch = append(batch, req)
+		case <-r.ctx.Done():
+			return
+		}
+	drain:
+		for len(batch) < sigEpochResolverMaxBatch {
+			select {
+			case req := <-r.requests:
+				batch = append(batch, req)
+			default:
+				break drain
+			}
+		}
+		go func() {
+			defer func() { <-r.batches }()
+			r.resolveBatch(batch)
+		}()
+	}
+}
+
+func (r *sigEpochResolver) resolveBatch(batch []*sigEpochRequest) {
+	waiters := make(map[solana.Signature][]*sigEpochRequest, len(batch))
+	sigs := make([]solana.Signature, 0, len(batch))
+	for _, req := range batch {
+		if _, ok := waiters[req.sig]; !ok {
+			sigs = append(sigs, req.sig)
+		}
+		waiters[req.sig] = append(waiters[req.sig], req)
+	}
+	// the sig-exists indexes are bucketed by the prefix of the signatures.
+	sort.Slice(sigs, func(i, j int) bool {
+		return bytes.Compare(sigs[i][:], sigs[j][:]) < 0
+	})
+	generation, epochs := r.epochs()
+	results := r.resolve(r.ctx, epochs, sigs)
+	for i, sig := range sigs {
+		if r.negative != nil && errors.Is(results[i].err, ErrNotFound) {
+			r.negative.add(sig, generation)
+		}
+		for _, req := range waiters[sig] {
+			req.result <- results[i]
+		}
+	}
+}
+
+// resolve finds the epochs of the signatures: it probes the sig-exists indexes of the epochs,
+// and then verifies the candidates of each signature, from the most recent epoch to the oldest.
+func (r *sigEpochResolver) resolve(ctx context.Context, epochs []sigEpochCandidate, sigs []solana.Signature) []sigEpochResult {
+	var filters []*hashBloom
+	if r.summaries != nil {
+		filters = r.summaries.filters(epochs)
+	}
+	hashes := make([]uint64, len(sigs))
+	for i := range sigs {
+		hashes[i] = bucketteer.Hash(sigs[i])
+	}
+	// hits[e][i] and probeErrs[e][i] are the results of the probe of sigs[i] in epochs[e];
+	// they are nil if no signature was probed in the epoch.
+	hits := make([][]bool, len(epochs))
+	probeErrs := make([][]error, len(epochs))
+	var probes errgroup.Group
+	if r.concurrency > 0 {
+		probes.SetLimit(r.concurrency)
+	}
+	for e := range epochs {
+		var todo []int
+		for i := range sigs {
+			if filters == nil || filters[e] == nil || filters[e].mayContain(hashes[i]) {
+				todo = append(todo, i)
+			}
+		}
+		if len(todo) == 0 {
+			continue
+		}
+		e := e
+		hits[e] = make([]bool, len(sigs))
+		probeErrs[e] = make([]error, len(sigs))
+		probes.Go(func() error {
+			if batcher, ok := epochs[e].index.(sigBatchIndex); ok {
+				if err := ctx.Err(); err != nil {
+					for _, i := range todo {
+						probeErrs[e][i] = err
+					}
+					return nil
+				}
+				batch := make([][64]byte, len(todo))
+				for j, i := range todo {
+					batch[j] = sigs[i]
+				}
+				found, err := batcher.HasBatch(batch)
+				for j, i := range todo {
+					if err != nil {
+						probeErrs[e][i] = err
+					} else {
+						hits[e][i] = found[j]
+					}
+				}
+				return nil
+			}
+			for _, i := range todo {
+				if ctx.Err() != nil {
+					probeErrs[e][i] = ctx.Err()
+					continue
+				}
+				hits[e][i], probeErrs[e][i] = epochs[e].index.Has(sigs[i])
+			}
+			return nil
+		})
+	}
+	probes.Wait()
+
+	results := make([]sigEpochResult, len(sigs))
+	var verifications errgroup.Group
+	if r.concurrency > 0 {
+		verifications.SetLimit(r.concurrency)
+	}
+	for i := range sigs {
+		i := i
+		verifications.Go(func() error {
+			// firstErr is returned if the signature isn't found in the other epochs.
+			var firstErr error
+			for e := range epochs {
+				if probeErrs[e] != nil && probeErrs[e][i] != nil && firstErr == nil {
+					firstErr = fmt.Errorf("failed to check if signature exists in epoch %d: %w", epochs[e].number, probeErrs[e][i])
+				}
+				if hits[e] == nil || !hits[e][i] {
+					continue
+				}
+				err := epochs[e].verify(ctx, sigs[i])
+				if err == nil {
+					results[i] = sigEpochResult{epoch: epochs[e].number}
+					return nil
+				}
+				// not found is a false positive of the sig-exists index.
+				if !isSigNotFound(err) && firstErr == nil {
+					firstErr = fmt.Errorf("failed to find signature in epoch %d: %w", epochs[e].number, err)
+				}
+			}
+			if firstErr != nil {
+				results[i] = sigEpochResult{err: firstErr}
+			} else {
+				results[i] = sigEpochResult{err: ErrNotFound}
+			}
+			return nil
+		})
+	}
+	verifications.Wait()
+	return results
+}
+
+// isSigNotFound tells if the error of a sig-to-cid lookup means that the signature isn't in the index.
+func isSigNotFound(err error) bool {
+	return errors.Is(err, compactindexsized.ErrNotFound) || errors.Is(err, compactindex36.ErrNotFound)
+}
+
+// sigNegativeCache remembers the signatures that weren't found, with the generation of the set of
+// epochs that was searched: a change of the epochs invalidates the entries. It has two halves:
+// when the recent one is full, it replaces the older one, so that the cache holds at most size entries.
+type sigNegativeCache struct {
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+	mu   sync.Mutex
+	// recent and older have at most size/2 entries each.
+	recent map[solana.Signature]sigNegativeEntry
+	older  map[solana.Signature]sigNegativeEntry
+}
+
+type sigNegativeEntry struct {
+	generation uint64
+	expiresAt  time.Time
+}
+
+func newSigNegativeCache(ttl time.Duration, size int) *sigNegativeCache {
+	return &sigNegativeCache{
+		ttl:    ttl,
+		size:   size,
+		now:    time.Now,
+		recent: make(map[solana.Signature]sigNegativeEntry),
+		older:  make(map[solana.Signature]sigNegativeEntry),
+	}
+}
+
+func (c *sigNegativeCache) has(sig solana.Signature, generation uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.recent[sig]
+	if !ok {
+		entry, ok = c.older[sig]
+	}
+	return ok && entry.generation == generation && c.now().Before(entry.expiresAt)
+}
+
+func (c *sigNegativeCache) add(sig solana.Signature, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.recent) >= max(c.size/2, 1) {
+		c.older = c.recent
+		c.recent = make(map[solana.Signature]sigNegativeEntry, len(c.older))
+	}
+	c.recent[sig] = sigNegativeEntry{generation: generation, expiresAt: c.now().Add(c.ttl)}
+}
+
+// sigBatchIndex is implemented by the sig-exists indexes that can probe many signatures at once
+// (deprecated/bucketteer.Reader); a batch of lookups then costs one call per epoch.
+type sigBatchIndex interface {
+	HasBatch(sigs [][64]byte) ([]bool, error)
+}
+
+// sigHashLister is implemented by the sig-exists indexes that can be summarized (bucketteer.Reader).
+type sigHashLister interface {
+	NumHashes() (uint64, error)
+	ForEachHash(fn func(hash uint64) error) error
+}
+
+// sigSummaries has the summaries of the sig-exists indexes of the epochs, which are built
+// in the background, one at a time; until its summary is built, an epoch is always probed.
+type sigSummaries struct {
+	ctx        context.Context
+	bitsPerKey uint
+	building   chan struct{}
+	mu         sync.Mutex
+	byIndex    map[SigExistsIndex]*sigSummary
+}
+
+type sigSummary struct {
+	cancel context.CancelFunc
+	// filter is nil until the summary is built.
+	filter atomic.Pointer[hashBloom]
+}
+
+func newSigSummaries(ctx context.Context, bitsPerKey uint) *sigSummaries {
+	return &sigSummaries{
+		ctx:        ctx,
+		bitsPerKey: bitsPerKey,
+		building:   make(chan struct{}, 1),
+		byIndex:    make(map[SigExistsIndex]*sigSummary),
+	}
+}
+
+// filters returns the summaries of the epochs (nil for the ones that don't have one yet); it starts
+// building the summaries of the new epochs, and drops the ones of the epochs that are gone.
+func (s *sigSummaries) filters(epochs []sigEpochCandidate) []*hashBloom {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	filters := make([]*hashBloom, len(epochs))
+	seen := make(map[SigExistsIndex]bool, len(epochs))
+	for e, epoch := range epochs {
+		lister, ok := epoch.index.(sigHashLister)
+		if !ok {
+			continue
+		}
+		seen[epoch.index] = true
+		summary, ok := s.byIndex[epoch.index]
+		if !ok {
+			ctx, cancel := context.WithCancel(s.ctx)
+			summary = &sigSummary{cancel: cancel}
+			s.byIndex[epoch.index] = summary
+			go s.build(ctx, epoch.number, summary, lister)
+		}
+		filters[e] = summary.filter.Load()
+	}
+	for index, summary := range s.byIndex {
+		if !seen[index] {
+			summary.cancel()
+			delete(s.byIndex, index)
+		}
+	}
+	return filters
+}
+
+func (s *sigSummaries) build(ctx context.Context, epoch uint64, summary *sigSummary, lister sigHashLister) {
+	select {
+	case s.building <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	defer func() { <-s.building }()
+	startedAt := time.Now()
+	numHashes, err := lister.NumHashes()
+	if errors.Is(err, errSigHashesUnsupported) {
+		return
+	}
+	if err != nil {
+		klog.Warningf("Failed to build the signature summary of epoch %d: %s", epoch, err)
+		return
+	}
+	filter := newHashBloom(numHashes, s.bitsPerKey)
+	var added uint64
+	err = lister.ForEachHash(func(hash uint64) error {
+		filter.add(hash)
+		added++
+		if added%(1<<16) == 0 {
+			return ctx.Err()
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			klog.Warningf("Failed to build the signature summary of epoch %d: %s", epoch, err)
+		}
+		return
+	}
+	summary.filter.Store(filter)
+	klog.Infof("Built the signature summary of epoch %d (%d signatures, %d MiB) in %s", epoch, numHashes, len(filter.bits)*8>>20, time.Since(startedAt))
+}
+
+// hashBloom is a bloom filter of hashes that are already uniformly distributed (see bucketteer.Hash).
+type hashBloom struct {
+	bits      []uint64
+	numHashes uint32
+}
+
+func newHashBloom(numItems uint64, bitsPerKey uint) *hashBloom {
+	numBits := max(numItems*uint64(bitsPerKey), 64)
+	numHashes := math.Round(float64(bitsPerKey) * math.Ln2)
+	return &hashBloom{
+		bits:      make([]uint64, (numBits+63)/64),
+		numHashes: uint32(min(max(numHashes, 1), 16)),
+	}
+}
+
+// positions returns the double hashing parameters of a hash.
+func (b *hashBloom) positions(hash uint64) (h1, h2, numBits uint64) {
+	return hash, bits.RotateLeft64(hash*0x9e3779b97f4a7c15, 32) | 1, uint64(len(b.bits)) * 64
+}
+
+func (b *hashBloom) add(hash uint64) {
+	h1, h2, numBits := b.positions(hash)
+	for i := uint64(0); i < uint64(b.numHashes); i++ {
+		bit := (h1 + i*h2) % numBits
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *hashBloom) mayContain(hash uint64) bool {
+	h1, h2, numBits := b.positions(hash)
+	for i := uint64(0); i < uint64(b.numHashes); i++ {
+		bit := (h1 + i*h2) % numBits
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/bucketteer"
+	"github.com/rpcpool/yellowstone-faithful/compactindexsized"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSigIndex is a sig-exists index that counts its probes.
+type fakeSigIndex struct {
+	sigs   map[solana.Signature]bool
+	err    error
+	probes atomic.Int64
+	// if not nil, the probes wait for it to be closed.
+	block chan struct{}
+}
+
+func newFakeSigIndex(sigs ...solana.Signature) *fakeSigIndex {
+	index := &fakeSigIndex{sigs: make(map[solana.Signature]bool)}
+	for _, sig := range sigs {
+		index.sigs[sig] = true
+	}
+	return index
+}
+
+func (f *fakeSigIndex) Has(sig [64]byte) (bool, error) {
+	f.probes.Add(1)
+	if f.block != nil {
+		<-f.block
+	}
+	return f.sigs[sig], f.err
+}
+
+// fakeListedSigIndex is a fakeSigIndex that can be summarized.
+type fakeListedSigIndex struct {
+	*fakeSigIndex
+}
+
+func (f *fakeListedSigIndex) NumHashes() (uint64, error) {
+	return uint64(len(f.sigs)), nil
+}
+
+func (f *fakeListedSigIndex) ForEachHash(fn func(hash uint64) error) error {
+	for sig := range f.sigs {
+		if err := fn(bucketteer.Hash(sig)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fakeBatchSigIndex is a fakeSigIndex that can probe many signatures at once.
+type fakeBatchSigIndex struct {
+	*fakeSigIndex
+	batches atomic.Int64
+}
+
+func (f *fakeBatchSigIndex) HasBatch(sigs [][64]byte) ([]bool, error) {
+	f.batches.Add(1)
+	found := make([]bool, len(sigs))
+	for i := range sigs {
+		found[i] = f.sigs[sigs[i]]
+	}
+	return found, f.err
+}
+
+// fakeSigEpochs is a set of epochs for a sigEpochResolver.
+type fakeSigEpochs struct {
+	mu         sync.Mutex
+	generation uint64
+	epochs     []sigEpochCandidate
+}
+
+// set replaces the epochs (from the most recent to the oldest).
+func (f *fakeSigEpochs) set(epochs ...sigEpochCandidate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.generation++
+	f.epochs = epochs
+}
+
+func (f *fakeSigEpochs) get() (uint64, []sigEpochCandidate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.generation, f.epochs
+}
+
+func (f *fakeSigEpochs) getGeneration() uint64 {
+	generation, _ := f.get()
+	return generation
+}
+
+// fakeSigEpoch returns an epoch that has the signatures of sigs, whatever its index says.
+func fakeSigEpoch(number uint64, index SigExistsIndex, sigs ...solana.Signature) sigEpochCandidate {
+	has := make(map[solana.Signature]bool)
+	for _, sig := range sigs {
+		has[sig] = true
+	}
+	return sigEpochCandidate{
+		number: number,
+		index:  index,
+		verify: func(ctx context.Context, sig solana.Signature) error {
+			if !has[sig] {
+				return compactindexsized.ErrNotFound
+			}
+			return nil
+		},
+	}
+}
+
+func newTestSigEpochResolver(t *testing.T, epochs *fakeSigEpochs, negativeTTL time.Duration, summaryBitsPerKey uint) *sigEpochResolver {
+	r := newSigEpochResolver(epochs.get, epochs.getGeneration, 0, negativeTTL, summaryBitsPerKey)
+	t.Cleanup(r.close)
+	return r
+}
+
+func testSig(i byte) solana.Signature {
+	return solana.Signature{i, i * 7, 1, 2, 3}
+}
+
+func TestSigEpochResolver(t *testing.T) {
+	ctx := context.Background()
+	sigA, sigB, sigC := testSig(1), testSig(2), testSig(3)
+	broken := newFakeSigIndex()
+	broken.err = errors.New("broken index")
+	epochs := &fakeSigEpochs{}
+	epochs.set(
+		fakeSigEpoch(3, newFakeSigIndex(sigA, sigB), sigB), // sigA is a false positive.
+		fakeSigEpoch(2, newFakeSigIndex(sigA), sigA),
+		fakeSigEpoch(1, broken),
+	)
+	r := newTestSigEpochResolver(t, epochs, 0, 0)
+
+	epoch, err := r.find(ctx, sigA)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), epoch)
+	epoch, err = r.find(ctx, sigB)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), epoch)
+	_, err = r.find(ctx, sigC)
+	require.ErrorContains(t, err, "epoch 1: broken index")
+	require.False(t, errors.Is(err, ErrNotFound))
+
+	epochs.set(fakeSigEpoch(3, newFakeSigIndex(sigB), sigB))
+	_, err = r.find(ctx, sigC)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	r.close()
+	_, err = r.find(ctx, sigB)
+	require.ErrorIs(t, err, errSigEpochResolverClosed)
+}
+
+func TestSigEpochResolver_Batches(t *testing.T) {
+	ctx := context.Background()
+	index := newFakeSigIndex(testSig(100))
+	index.block = make(chan struct{})
+	epochs := &fakeSigEpochs{}
+	epochs.set(fakeSigEpoch(7, index, testSig(100)))
+	r := newTestSigEpochResolver(t, epochs, 0, 0)
+
+	type result struct {
+		sig   solana.Signature
+		epoch uint64
+		err   error
+	}
+	results := make(chan result)
+	find := func(sig solana.Signature) {
+		go func() {
+			epoch, err := r.find(ctx, sig)
+			results <- result{sig, epoch, err}
+		}()
+	}
+	// fill the batch slots.
+	for i := 0; i < sigEpochResolverBatches; i++ {
+		find(testSig(byte(i)))
+		require.Eventually(t, func() bool { return index.probes.Load() == int64(i+1) }, 5*time.Second, time.Millisecond)
+	}
+	// the next lookups queue up, and they're resolved by a single batch.
+	for i := 0; i < 10; i++ {
+		find(testSig(100))
+		find(testSig(101))
+	}
+	require.Eventually(t, func() bool { return len(r.requests) == 20 }, 5*time.Second, time.Millisecond)
+	close(index.block)
+	for i := 0; i < sigEpochResolverBatches+20; i++ {
+		res := <-results
+		if res.sig == testSig(100) {
+			require.NoError(t, res.err)
+			require.Equal(t, uint64(7), res.epoch)
+		} else {
+			require.ErrorIs(t, res.err, ErrNotFound)
+		}
+	}
+	require.Equal(t, int64(sigEpochResolverBatches+2), index.probes.Load())
+}
+
+func TestSigEpochResolver_BatchIndex(t *testing.T) {
+	ctx := context.Background()
+	sigA, sigB, sigC := testSig(1), testSig(2), testSig(3)
+	index := &fakeBatchSigIndex{fakeSigIndex: newFakeSigIndex(sigA, sigB)}
+	epochs := &fakeSigEpochs{}
+	epochs.set(fakeSigEpoch(5, index, sigA))
+	r := newTestSigEpochResolver(t, epochs, 0, 0)
+
+	results := r.resolve(ctx, epochs.epochs, []solana.Signature{sigA, sigB, sigC})
+	require.NoError(t, results[0].err)
+	require.Equal(t, uint64(5), results[0].epoch)
+	// sigB is a false positive.
+	require.ErrorIs(t, results[1].err, ErrNotFound)
+	require.ErrorIs(t, results[2].err, ErrNotFound)
+	require.Equal(t, int64(1), index.batches.Load())
+	require.Zero(t, index.probes.Load())
+
+	index.err = errors.New("broken index")
+	results = r.resolve(ctx, epochs.epochs, []solana.Signature{sigA})
+	require.ErrorContains(t, results[0].err, "epoch 5: broken index")
+}
+
+func TestSigEpochResolver_Summaries(t *testing.T) {
+	ctx := context.Background()
+	var sigs []solana.Signature
+	for i := 0; i < 100; i++ {
+		sigs = append(sigs, testSig(byte(i)))
+	}
+	index := &fakeListedSigIndex{newFakeSigIndex(sigs...)}
+	epochs := &fakeSigEpochs{}
+	epochs.set(fakeSigEpoch(5, index, sigs...))
+	r := newTestSigEpochResolver(t, epochs, 0, 10)
+
+	epoch, err := r.find(ctx, sigs[0])
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), epoch)
+	require.Eventually(t, func() bool {
+		r.summaries.mu.Lock()
+		defer r.summaries.mu.Unlock()
+		return r.summaries.byIndex[index].filter.Load() != nil
+	}, 5*time.Second, time.Millisecond)
+
+	// the absent signatures (almost) never reach the index.
+	probes := index.probes.Load()
+	for i := 0; i < 100; i++ {
+		_, err := r.find(ctx, solana.Signature{byte(i), 0xff, 0xff})
+		require.ErrorIs(t, err, ErrNotFound)
+	}
+	require.Less(t, index.probes.Load()-probes, int64(10))
+	for _, sig := range sigs {
+		epoch, err := r.find(ctx, sig)
+		require.NoError(t, err)
+		require.Equal(t, uint64(5), epoch)
+	}
+
+	// the summaries of the epochs that are gone are dropped.
+	epochs.set(fakeSigEpoch(6, newFakeSigIndex()))
+	_, err = r.find(ctx, sigs[0])
+	require.ErrorIs(t, err, ErrNotFound)
+	r.summaries.mu.Lock()
+	require.Empty(t, r.summaries.byIndex)
+	r.summaries.mu.Unlock()
+}
+
+func TestSigEpochResolver_NegativeCache(t *testing.T) {
+	ctx := context.Background()
+	index := newFakeSigIndex()
+	epochs := &fakeSigEpochs{}
+	epochs.set(fakeSigEpoch(1, index))
+	r := newTestSigEpochResolver(t, epochs, time.Hour, 0)
+
+	for i := 0; i < 3; i++ {
+		_, err := r.find(ctx, testSig(1))
+		require.ErrorIs(t, err, ErrNotFound)
+	}
+	require.Equal(t, int64(1), index.probes.Load())
+
+	// a change of the epochs invalidates the cache.
+	epochs.set(fakeSigEpoch(1, index))
+	_, err := r.find(ctx, testSig(1))
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Equal(t, int64(2), index.probes.Load())
+
+	// and so does the TTL.
+	r.negative.mu.Lock()
+	r.negative.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	r.negative.mu.Unlock()
+	_, err = r.find(ctx, testSig(1))
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Equal(t, int64(3), index.probes.Load())
+
+	// the cache holds at most size entries.
+	cache := newSigNegativeCache(time.Hour, 4)
+	for i := byte(0); i < 5; i++ {
+		cache.add(testSig(i), 1)
+	}
+	require.False(t, cache.has(testSig(0), 1))
+	require.False(t, cache.has(testSig(1), 1))
+	for i := byte(2); i < 5; i++ {
+		require.True(t, cache.has(testSig(i), 1))
+	}
+	require.False(t, cache.has(testSig(4), 2))
+}
+
+func TestMultiEpoch_FindEpochNumberFromSignature(t *testing.T) {
+	multi := NewMultiEpoch(nil)
+	require.NoError(t, multi.AddEpoch(1, &Epoch{epoch: 1, sigExists: newFakeSigIndex()}))
+	require.NoError(t, multi.AddEpoch(2, &Epoch{epoch: 2}))
+	_, err := multi.findEpochNumberFromSignature(context.Background(), testSig(1))
+	require.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, multi.Close())
+	_, err = multi.findEpochNumberFromSignature(context.Background(), testSig(1))
+	require.ErrorIs(t, err, errSigEpochResolverClosed)
+}
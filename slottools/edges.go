@@ -2,18 +2,18 @@ package slottools
 
 import "encoding/binary"
 
-// CalcEpochForSlot returns the epoch for the given slot.
+// CalcEpochForSlot returns the epoch for the given slot, according to the default epoch schedule.
 func CalcEpochForSlot(slot uint64) uint64 {
-	return slot / EpochLen
+	return DefaultEpochSchedule().EpochForSlot(slot)
 }
 
+// EpochLen is the number of slots in a (normal) mainnet epoch.
 const EpochLen = 432000
 
-// CalcEpochLimits returns the start and stop slots for the given epoch (inclusive).
+// CalcEpochLimits returns the start and stop slots for the given epoch (inclusive),
+// according to the default epoch schedule.
 func CalcEpochLimits(epoch uint64) (uint64, uint64) {
-	epochStart := epoch * EpochLen
-	epochStop := epochStart + EpochLen - 1
-	return epochStart, epochStop
+	return DefaultEpochSchedule().EpochLimits(epoch)
 }
 
 // Uint64RangesHavePartialOverlapIncludingEdges returns true if the two ranges have any overlap.
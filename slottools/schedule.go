@@ -0,0 +1,148 @@
+package slottools
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// MinimumSlotsPerEpoch is the length of the first epoch when the schedule has warmup epochs.
+const MinimumSlotsPerEpoch = 32
+
+// EpochSchedule describes how slots are divided into epochs (same as Solana's EpochSchedule).
+// With warmup, epochs start at MinimumSlotsPerEpoch slots and double in length
+// until they reach SlotsPerEpoch (at FirstNormalEpoch, which starts at FirstNormalSlot).
+type EpochSchedule struct {
+	SlotsPerEpoch            uint64
+	LeaderScheduleSlotOffset uint64
+	Warmup                   bool
+	FirstNormalEpoch         uint64
+	FirstNormalSlot          uint64
+}
+
+// NewEpochSchedule creates a schedule with the given epoch length, with or without warmup epochs.
+func NewEpochSchedule(slotsPerEpoch uint64, warmup bool) EpochSchedule {
+	s := EpochSchedule{
+		SlotsPerEpoch:            slotsPerEpoch,
+		LeaderScheduleSlotOffset: slotsPerEpoch,
+		Warmup:                   warmup,
+	}
+	if warmup {
+		s.FirstNormalEpoch = uint64(trailingZeros(nextPowerOfTwo(slotsPerEpoch)) - trailingZeros(MinimumSlotsPerEpoch))
+		s.FirstNormalSlot = (pow2(s.FirstNormalEpoch) - 1) * MinimumSlotsPerEpoch
+	}
+	return s
+}
+
+var (
+	// MainnetEpochSchedule is the schedule of mainnet-beta: 432000-slot epochs starting at slot 0.
+	MainnetEpochSchedule = NewEpochSchedule(EpochLen, false)
+	// DevnetEpochSchedule is the schedule of devnet: 432000-slot epochs, after warmup epochs.
+	DevnetEpochSchedule = NewEpochSchedule(EpochLen, true)
+)
+
+// ParseEpochSchedule parses a schedule name ("mainnet", "devnet"),
+// or a custom schedule in the form "<slots_per_epoch>[,warmup]", e.g. "8192,warmup".
+func ParseEpochSchedule(s string) (EpochSchedule, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "mainnet", "mainnet-beta":
+		return MainnetEpochSchedule, nil
+	case "devnet":
+		return DevnetEpochSchedule, nil
+	}
+	lenStr, opt, hasOpt := strings.Cut(s, ",")
+	slotsPerEpoch, err := strconv.ParseUint(strings.TrimSpace(lenStr), 10, 64)
+	if err != nil {
+		return EpochSchedule{}, fmt.Errorf("invalid epoch schedule %q: %w", s, err)
+	}
+	warmup := false
+	if hasOpt {
+		if strings.TrimSpace(opt) != "warmup" {
+			return EpochSchedule{}, fmt.Errorf("invalid epoch schedule %q: unknown option %q", s, opt)
+		}
+		warmup = true
+	}
+	schedule := NewEpochSchedule(slotsPerEpoch, warmup)
+	if err := schedule.Validate(); err != nil {
+		return EpochSchedule{}, fmt.Errorf("invalid epoch schedule %q: %w", s, err)
+	}
+	return schedule, nil
+}
+
+// Validate checks that the schedule is consistent.
+func (s EpochSchedule) Validate() error {
+	if s.SlotsPerEpoch < MinimumSlotsPerEpoch {
+		return fmt.Errorf("slots per epoch must be at least %d, got %d", MinimumSlotsPerEpoch, s.SlotsPerEpoch)
+	}
+	if !s.Warmup && (s.FirstNormalEpoch != 0 || s.FirstNormalSlot != 0) {
+		return fmt.Errorf("first normal epoch/slot must be 0 without warmup")
+	}
+	if s.Warmup && s.FirstNormalSlot != (pow2(s.FirstNormalEpoch)-1)*MinimumSlotsPerEpoch {
+		return fmt.Errorf("first normal slot %d does not match first normal epoch %d", s.FirstNormalSlot, s.FirstNormalEpoch)
+	}
+	return nil
+}
+
+// EpochForSlot returns the epoch that contains the given slot.
+func (s EpochSchedule) EpochForSlot(slot uint64) uint64 {
+	if slot < s.FirstNormalSlot {
+		return uint64(trailingZeros(nextPowerOfTwo(slot+MinimumSlotsPerEpoch+1)) - trailingZeros(MinimumSlotsPerEpoch) - 1)
+	}
+	return s.FirstNormalEpoch + (slot-s.FirstNormalSlot)/s.SlotsPerEpoch
+}
+
+// SlotsInEpoch returns the number of slots in the given epoch.
+func (s EpochSchedule) SlotsInEpoch(epoch uint64) uint64 {
+	if epoch < s.FirstNormalEpoch {
+		return pow2(epoch + uint64(trailingZeros(MinimumSlotsPerEpoch)))
+	}
+	return s.SlotsPerEpoch
+}
+
+// FirstSlotInEpoch returns the first slot of the given epoch.
+func (s EpochSchedule) FirstSlotInEpoch(epoch uint64) uint64 {
+	if epoch <= s.FirstNormalEpoch {
+		return (pow2(epoch) - 1) * MinimumSlotsPerEpoch
+	}
+	return (epoch-s.FirstNormalEpoch)*s.SlotsPerEpoch + s.FirstNormalSlot
+}
+
+// EpochLimits returns the start and stop slots for the given epoch (inclusive).
+func (s EpochSchedule) EpochLimits(epoch uint64) (uint64, uint64) {
+	start := s.FirstSlotInEpoch(epoch)
+	return start, start + s.SlotsInEpoch(epoch) - 1
+}
+
+var defaultEpochSchedule atomic.Pointer[EpochSchedule]
+
+func init() {
+	SetDefaultEpochSchedule(MainnetEpochSchedule)
+}
+
+// SetDefaultEpochSchedule sets the schedule used by the package-level functions
+// (CalcEpochForSlot, CalcEpochLimits, ...). It defaults to MainnetEpochSchedule.
+func SetDefaultEpochSchedule(s EpochSchedule) {
+	defaultEpochSchedule.Store(&s)
+}
+
+// DefaultEpochSchedule returns the schedule used by the package-level functions.
+func DefaultEpochSchedule() EpochSchedule {
+	return *defaultEpochSchedule.Load()
+}
+
+func nextPowerOfTwo(v uint64) uint64 {
+	if v <= 1 {
+		return 1
+	}
+	return 1 << (64 - bits.LeadingZeros64(v-1))
+}
+
+func trailingZeros(v uint64) int {
+	return bits.TrailingZeros64(v)
+}
+
+func pow2(exp uint64) uint64 {
+	return 1 << exp
+}
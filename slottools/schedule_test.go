@@ -0,0 +1,63 @@
+package slottools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEpochScheduleWarmup(t *testing.T) {
+	s := DevnetEpochSchedule
+	require.Equal(t, uint64(14), s.FirstNormalEpoch)
+	require.Equal(t, uint64(524_256), s.FirstNormalSlot)
+	require.NoError(t, s.Validate())
+
+	require.Equal(t, uint64(0), s.EpochForSlot(0))
+	require.Equal(t, uint64(0), s.EpochForSlot(31))
+	require.Equal(t, uint64(1), s.EpochForSlot(32))
+	require.Equal(t, uint64(1), s.EpochForSlot(95))
+	require.Equal(t, uint64(2), s.EpochForSlot(96))
+	require.Equal(t, uint64(13), s.EpochForSlot(524_255))
+	require.Equal(t, uint64(14), s.EpochForSlot(524_256))
+	require.Equal(t, uint64(15), s.EpochForSlot(524_256+432_000))
+
+	for epoch := uint64(0); epoch < 20; epoch++ {
+		start, stop := s.EpochLimits(epoch)
+		require.Equal(t, epoch, s.EpochForSlot(start), "epoch %d", epoch)
+		require.Equal(t, epoch, s.EpochForSlot(stop), "epoch %d", epoch)
+		require.Equal(t, epoch+1, s.EpochForSlot(stop+1), "epoch %d", epoch)
+		require.Equal(t, s.SlotsInEpoch(epoch), stop-start+1)
+	}
+}
+
+func TestParseEpochSchedule(t *testing.T) {
+	{
+		got, err := ParseEpochSchedule("mainnet")
+		require.NoError(t, err)
+		require.Equal(t, MainnetEpochSchedule, got)
+	}
+	{
+		got, err := ParseEpochSchedule("devnet")
+		require.NoError(t, err)
+		require.Equal(t, DevnetEpochSchedule, got)
+	}
+	{
+		got, err := ParseEpochSchedule("8192,warmup")
+		require.NoError(t, err)
+		require.Equal(t, uint64(8), got.FirstNormalEpoch)
+		require.Equal(t, uint64(8160), got.FirstNormalSlot)
+	}
+	for _, bad := range []string{"foo", "16", "8192,nope"} {
+		_, err := ParseEpochSchedule(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestDefaultEpochSchedule(t *testing.T) {
+	defer SetDefaultEpochSchedule(MainnetEpochSchedule)
+	SetDefaultEpochSchedule(DevnetEpochSchedule)
+	require.Equal(t, uint64(14), CalcEpochForSlot(524_256))
+	start, stop := CalcEpochLimits(1)
+	require.Equal(t, uint64(32), start)
+	require.Equal(t, uint64(95), stop)
+}
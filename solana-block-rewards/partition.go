@@ -0,0 +1,123 @@
+package solanablockrewards
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"github.com/gagliardetto/solana-go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// NumPartitions reads the num_partitions field of encoded Rewards, which the vendored proto
+// doesn't have: it is set in the first block of an epoch whose staking rewards are
+// distributed in partitions, one per following block.
+// It returns false if the field is not set.
+func NumPartitions(buf []byte) (uint64, bool, error) {
+	var numPartitions uint64
+	found := false
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return 0, false, fmt.Errorf("invalid tag: %w", protowire.ParseError(n))
+		}
+		buf = buf[n:]
+		if num == 2 && typ == protowire.BytesType {
+			msg, n := protowire.ConsumeBytes(buf)
+			if n < 0 {
+				return 0, false, fmt.Errorf("invalid num_partitions: %w", protowire.ParseError(n))
+			}
+			buf = buf[n:]
+			// message NumPartitions { uint64 num_partitions = 1; }; the last value wins.
+			numPartitions = 0
+			found = true
+			for len(msg) > 0 {
+				num, typ, n := protowire.ConsumeTag(msg)
+				if n < 0 {
+					return 0, false, fmt.Errorf("invalid num_partitions tag: %w", protowire.ParseError(n))
+				}
+				msg = msg[n:]
+				if num == 1 && typ == protowire.VarintType {
+					v, n := protowire.ConsumeVarint(msg)
+					if n < 0 {
+						return 0, false, fmt.Errorf("invalid num_partitions value: %w", protowire.ParseError(n))
+					}
+					msg = msg[n:]
+					numPartitions = v
+					continue
+				}
+				n = protowire.ConsumeFieldValue(num, typ, msg)
+				if n < 0 {
+					return 0, false, fmt.Errorf("invalid num_partitions field %d: %w", num, protowire.ParseError(n))
+				}
+				msg = msg[n:]
+			}
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, buf)
+		if n < 0 {
+			return 0, false, fmt.Errorf("invalid field %d: %w", num, protowire.ParseError(n))
+		}
+		buf = buf[n:]
+	}
+	return numPartitions, found, nil
+}
+
+// PartitionForAddress returns the partition (0 to numPartitions-1) in which the staking reward of
+// the address is distributed, like the EpochRewardsHasher of the validator: the address and the
+// parent blockhash of the first block of the epoch are hashed with SipHash-1-3 (zero key), and the
+// hash is scaled to the number of partitions.
+func PartitionForAddress(numPartitions uint64, parentBlockhash solana.Hash, address solana.PublicKey) uint64 {
+	// Rust's Hash of a [u8; 32] writes the length (as a u64) before the bytes.
+	buf := make([]byte, 0, 8+32+8+32)
+	buf = binary.LittleEndian.AppendUint64(buf, 32)
+	buf = append(buf, parentBlockhash[:]...)
+	buf = binary.LittleEndian.AppendUint64(buf, 32)
+	buf = append(buf, address[:]...)
+	partition, _ := bits.Mul64(numPartitions, siphash13(0, 0, buf))
+	return partition
+}
+
+// siphash13 is SipHash with 1 compression round and 3 finalization rounds.
+func siphash13(k0, k1 uint64, data []byte) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+	length := len(data)
+	for len(data) >= 8 {
+		m := binary.LittleEndian.Uint64(data)
+		v3 ^= m
+		round()
+		v0 ^= m
+		data = data[8:]
+	}
+	last := uint64(length) << 56
+	for i, b := range data {
+		last |= uint64(b) << (8 * i)
+	}
+	v3 ^= last
+	round()
+	v0 ^= last
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	return v0 ^ v1 ^ v2 ^ v3
+}
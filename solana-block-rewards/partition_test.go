@@ -0,0 +1,58 @@
+package solanablockrewards
+
+import (
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSiphash13(t *testing.T) {
+	// reference values from CPython, whose bytes hash is SipHash-1-3 with a zero key when PYTHONHASHSEED=0.
+	require.Equal(t, int64(-4594863902769663758), int64(siphash13(0, 0, []byte("abc"))))
+}
+
+func TestPartitionForAddress(t *testing.T) {
+	var seed solana.Hash
+	var address solana.PublicKey
+	for i := range seed {
+		seed[i] = byte(i)
+		address[i] = byte(32 + i)
+	}
+	for numPartitions, expected := range map[uint64]uint64{1: 0, 2: 1, 16: 11, 4096: 2845} {
+		require.Equal(t, expected, PartitionForAddress(numPartitions, seed, address), "numPartitions=%d", numPartitions)
+	}
+}
+
+func TestNumPartitions(t *testing.T) {
+	buf, err := proto.Marshal(&confirmed_block.Rewards{
+		Rewards: []*confirmed_block.Reward{{Pubkey: "a", Lamports: 1, RewardType: confirmed_block.RewardType_Voting}},
+	})
+	require.NoError(t, err)
+
+	_, found, err := NumPartitions(buf)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	var numPartitions []byte
+	numPartitions = protowire.AppendTag(numPartitions, 1, protowire.VarintType)
+	numPartitions = protowire.AppendVarint(numPartitions, 43)
+	withPartitions := protowire.AppendTag(append([]byte{}, buf...), 2, protowire.BytesType)
+	withPartitions = protowire.AppendBytes(withPartitions, numPartitions)
+
+	got, found, err := NumPartitions(withPartitions)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, uint64(43), got)
+
+	// the rewards are still readable with the vendored proto.
+	rewards, err := ParseRewards(withPartitions)
+	require.NoError(t, err)
+	require.Len(t, rewards.Rewards, 1)
+
+	_, _, err = NumPartitions([]byte{0x12, 0x05})
+	require.Error(t, err)
+}
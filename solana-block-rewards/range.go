@@ -0,0 +1,50 @@
+package solanablockrewards
+
+import (
+	"fmt"
+
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// ParseRewardsRange decodes the rewards of encoded Rewards starting at offset, at most limit of them
+// (all the remaining ones if limit is 0), and returns them with the total number of rewards.
+// The other rewards are skipped without being decoded, so that a page of the rewards of an
+// epoch boundary block (hundreds of thousands of rewards) is cheap to serve.
+func ParseRewardsRange(buf []byte, offset, limit int) ([]*confirmed_block.Reward, int, error) {
+	if offset < 0 || limit < 0 {
+		return nil, 0, fmt.Errorf("invalid range: offset %d, limit %d", offset, limit)
+	}
+	rewards := make([]*confirmed_block.Reward, 0)
+	total := 0
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return nil, 0, fmt.Errorf("invalid tag: %w", protowire.ParseError(n))
+		}
+		buf = buf[n:]
+		if num == 1 && typ == protowire.BytesType {
+			raw, n := protowire.ConsumeBytes(buf)
+			if n < 0 {
+				return nil, 0, fmt.Errorf("invalid reward %d: %w", total, protowire.ParseError(n))
+			}
+			buf = buf[n:]
+			if total >= offset && (limit == 0 || len(rewards) < limit) {
+				var reward confirmed_block.Reward
+				if err := proto.Unmarshal(raw, &reward); err != nil {
+					return nil, 0, fmt.Errorf("invalid reward %d: %w", total, err)
+				}
+				rewards = append(rewards, &reward)
+			}
+			total++
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, buf)
+		if n < 0 {
+			return nil, 0, fmt.Errorf("invalid field %d: %w", num, protowire.ParseError(n))
+		}
+		buf = buf[n:]
+	}
+	return rewards, total, nil
+}
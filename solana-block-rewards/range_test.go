@@ -0,0 +1,45 @@
+package solanablockrewards
+
+import (
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseRewardsRange(t *testing.T) {
+	rewards := &confirmed_block.Rewards{}
+	for i := 0; i < 10; i++ {
+		rewards.Rewards = append(rewards.Rewards, &confirmed_block.Reward{Pubkey: string(rune('a' + i)), Lamports: int64(i)})
+	}
+	buf, err := proto.Marshal(rewards)
+	require.NoError(t, err)
+
+	got, total, err := ParseRewardsRange(buf, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, 10, total)
+	require.Len(t, got, 10)
+
+	got, total, err = ParseRewardsRange(buf, 3, 4)
+	require.NoError(t, err)
+	require.Equal(t, 10, total)
+	require.Len(t, got, 4)
+	require.Equal(t, "d", got[0].Pubkey)
+	require.Equal(t, int64(6), got[3].Lamports)
+
+	got, total, err = ParseRewardsRange(buf, 8, 5)
+	require.NoError(t, err)
+	require.Equal(t, 10, total)
+	require.Len(t, got, 2)
+
+	got, total, err = ParseRewardsRange(buf, 20, 5)
+	require.NoError(t, err)
+	require.Equal(t, 10, total)
+	require.Empty(t, got)
+
+	_, _, err = ParseRewardsRange(buf[:len(buf)-1], 0, 0)
+	require.Error(t, err)
+	_, _, err = ParseRewardsRange(buf, -1, 0)
+	require.Error(t, err)
+}
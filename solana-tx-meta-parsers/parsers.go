@@ -55,6 +55,43 @@ func (c *TransactionStatusMetaContainer) GetSerdeOldest() *metaoldest.Transactio
 	return c.vSerdeOldest
 }
 
+// Fee returns the fee paid by the transaction.
+func (c *TransactionStatusMetaContainer) Fee() uint64 {
+	switch {
+	case c.vProtobuf != nil:
+		return c.vProtobuf.Fee
+	case c.vSerdeLatest != nil:
+		return c.vSerdeLatest.Fee
+	case c.vSerdeOldest != nil:
+		return c.vSerdeOldest.Fee
+	}
+	return 0
+}
+
+// Failed returns true if the transaction failed.
+func (c *TransactionStatusMetaContainer) Failed() bool {
+	switch {
+	case c.vProtobuf != nil:
+		return c.vProtobuf.Err != nil
+	case c.vSerdeLatest != nil:
+		_, ok := c.vSerdeLatest.Status.(*metalatest.Result__Err)
+		return ok
+	case c.vSerdeOldest != nil:
+		_, ok := c.vSerdeOldest.Status.(*metaoldest.Result__Err)
+		return ok
+	}
+	return false
+}
+
+// ComputeUnitsConsumed returns the compute units consumed by the transaction;
+// ok is false if they were not recorded (the serde formats never record them).
+func (c *TransactionStatusMetaContainer) ComputeUnitsConsumed() (units uint64, ok bool) {
+	if c.vProtobuf != nil && c.vProtobuf.ComputeUnitsConsumed != nil {
+		return *c.vProtobuf.ComputeUnitsConsumed, true
+	}
+	return 0, false
+}
+
 func ParseTransactionStatusMeta(buf []byte) (*confirmed_block.TransactionStatusMeta, error) {
 	var status confirmed_block.TransactionStatusMeta
 	err := proto.Unmarshal(buf, &status)
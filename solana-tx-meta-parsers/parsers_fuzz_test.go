@@ -0,0 +1,81 @@
+package solanatxmetaparsers
+
+import (
+	"testing"
+
+	metalatest "github.com/rpcpool/yellowstone-faithful/parse_legacy_transaction_status_meta/v-latest"
+	metaoldest "github.com/rpcpool/yellowstone-faithful/parse_legacy_transaction_status_meta/v-oldest"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"google.golang.org/protobuf/proto"
+)
+
+func metaSeeds(f *testing.F) [][]byte {
+	units := uint64(150)
+	protobuf, err := proto.Marshal(&confirmed_block.TransactionStatusMeta{
+		Err:                     &confirmed_block.TransactionError{Err: []byte{8, 0, 0, 0, 0, 25, 0, 0, 0, 1, 0, 0, 0}},
+		Fee:                     5000,
+		PreBalances:             []uint64{1, 2, 3},
+		PostBalances:            []uint64{1, 2, 3},
+		LogMessages:             []string{"Program 11111111111111111111111111111111 invoke [1]"},
+		LoadedWritableAddresses: [][]byte{make([]byte, 32)},
+		ComputeUnitsConsumed:    &units,
+	})
+	if err != nil {
+		f.Fatal(err)
+	}
+	latest := metalatest.TransactionStatusMeta{
+		Status:       &metalatest.Result__Ok{},
+		Fee:          5000,
+		PreBalances:  []uint64{1, 2},
+		PostBalances: []uint64{1, 2},
+		InnerInstructions: &[]metalatest.InnerInstructions{{
+			Index:        0,
+			Instructions: []metalatest.CompiledInstruction{{ProgramIdIndex: 1}},
+		}},
+	}
+	serdeLatest, err := latest.BincodeSerialize()
+	if err != nil {
+		f.Fatal(err)
+	}
+	oldest := metaoldest.TransactionStatusMeta{
+		Status:       &metaoldest.Result__Ok{},
+		Fee:          5000,
+		PreBalances:  []uint64{1, 2},
+		PostBalances: []uint64{1, 2},
+	}
+	serdeOldest, err := oldest.BincodeSerialize()
+	if err != nil {
+		f.Fatal(err)
+	}
+	return [][]byte{protobuf, serdeLatest, serdeOldest, {}}
+}
+
+// FuzzParseTransactionStatusMetaContainer checks that malformed metas (protobuf or serde)
+// are reported as errors instead of panics. Crashers found by the fuzzer are kept in
+// testdata/fuzz/FuzzParseTransactionStatusMetaContainer and replayed by go test.
+func FuzzParseTransactionStatusMetaContainer(f *testing.F) {
+	for _, seed := range metaSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		container, err := ParseTransactionStatusMetaContainer(data)
+		if err != nil {
+			return
+		}
+		container.Fee()
+		container.Failed()
+		container.ComputeUnitsConsumed()
+	})
+}
+
+// FuzzParseLegacyTransactionStatusMeta fuzzes the serde decoders directly, as the protobuf
+// decoder accepts most inputs before they get to them.
+func FuzzParseLegacyTransactionStatusMeta(f *testing.F) {
+	for _, seed := range metaSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ParseLegacyTransactionStatusMeta(data)
+		ParseLegacyTransactionStatusMetaOldest(data)
+	})
+}
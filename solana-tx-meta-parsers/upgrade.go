@@ -0,0 +1,65 @@
+package solanatxmetaparsers
+
+import (
+	"errors"
+	"fmt"
+
+	metalatest "github.com/rpcpool/yellowstone-faithful/parse_legacy_transaction_status_meta/v-latest"
+	metaoldest "github.com/rpcpool/yellowstone-faithful/parse_legacy_transaction_status_meta/v-oldest"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+)
+
+// ErrNotUpgradable is returned by ToProtobuf for a legacy meta that cannot be converted
+// without losing information.
+var ErrNotUpgradable = errors.New("the legacy meta cannot be converted to protobuf")
+
+// ToProtobuf returns the meta in the protobuf format. A legacy (serde) meta is converted:
+// the status becomes the bincode-encoded error (the legacy TransactionError and InstructionError
+// enums have the same variants, in the same order, as the current ones), the fee and balances are
+// copied, and the fields that the legacy formats did not record are marked as none (like a
+// validator does for a missing value). A legacy meta with inner instructions is not upgradable,
+// because the legacy schema of the compiled instructions is not decoded reliably.
+func (c *TransactionStatusMetaContainer) ToProtobuf() (*confirmed_block.TransactionStatusMeta, error) {
+	switch {
+	case c.vProtobuf != nil:
+		return c.vProtobuf, nil
+	case c.vSerdeLatest != nil:
+		meta := legacyToProtobuf(c.vSerdeLatest.Fee, c.vSerdeLatest.PreBalances, c.vSerdeLatest.PostBalances)
+		if status, ok := c.vSerdeLatest.Status.(*metalatest.Result__Err); ok {
+			txErr, err := status.Value.BincodeSerialize()
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode the transaction error: %w", err)
+			}
+			meta.Err = &confirmed_block.TransactionError{Err: txErr}
+		}
+		switch {
+		case c.vSerdeLatest.InnerInstructions == nil:
+			meta.InnerInstructionsNone = true
+		case len(*c.vSerdeLatest.InnerInstructions) > 0:
+			return nil, fmt.Errorf("%w: it has inner instructions", ErrNotUpgradable)
+		}
+		return meta, nil
+	case c.vSerdeOldest != nil:
+		meta := legacyToProtobuf(c.vSerdeOldest.Fee, c.vSerdeOldest.PreBalances, c.vSerdeOldest.PostBalances)
+		if status, ok := c.vSerdeOldest.Status.(*metaoldest.Result__Err); ok {
+			txErr, err := status.Value.BincodeSerialize()
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode the transaction error: %w", err)
+			}
+			meta.Err = &confirmed_block.TransactionError{Err: txErr}
+		}
+		meta.InnerInstructionsNone = true
+		return meta, nil
+	}
+	return nil, fmt.Errorf("the container holds no value")
+}
+
+func legacyToProtobuf(fee uint64, preBalances []uint64, postBalances []uint64) *confirmed_block.TransactionStatusMeta {
+	return &confirmed_block.TransactionStatusMeta{
+		Fee:             fee,
+		PreBalances:     preBalances,
+		PostBalances:    postBalances,
+		LogMessagesNone: true,
+		ReturnDataNone:  true,
+	}
+}
@@ -0,0 +1,62 @@
+package solanatxmetaparsers
+
+import (
+	"errors"
+	"testing"
+
+	metalatest "github.com/rpcpool/yellowstone-faithful/parse_legacy_transaction_status_meta/v-latest"
+	metaoldest "github.com/rpcpool/yellowstone-faithful/parse_legacy_transaction_status_meta/v-oldest"
+	solanaerrors "github.com/rpcpool/yellowstone-faithful/solana-errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToProtobuf(t *testing.T) {
+	customErr, err := solanaerrors.FromJSONToProtobuf(map[string]any{
+		solanaerrors.InstructionError: []any{2.0, map[string]any{"Custom": 7.0}},
+	})
+	require.NoError(t, err)
+
+	latest := &TransactionStatusMetaContainer{vSerdeLatest: &metalatest.TransactionStatusMeta{
+		Status: &metalatest.Result__Err{Value: &metalatest.TransactionError__InstructionError{
+			Field0: 2,
+			Field1: func() *metalatest.InstructionError__Custom { v := metalatest.InstructionError__Custom(7); return &v }(),
+		}},
+		Fee:               5000,
+		PreBalances:       []uint64{10, 20},
+		PostBalances:      []uint64{5, 20},
+		InnerInstructions: &[]metalatest.InnerInstructions{},
+	}}
+	meta, err := latest.ToProtobuf()
+	require.NoError(t, err)
+	require.Equal(t, customErr, meta.Err.Err)
+	require.Equal(t, uint64(5000), meta.Fee)
+	require.Equal(t, []uint64{10, 20}, meta.PreBalances)
+	require.Equal(t, []uint64{5, 20}, meta.PostBalances)
+	require.False(t, meta.InnerInstructionsNone)
+	require.True(t, meta.LogMessagesNone)
+	require.True(t, meta.ReturnDataNone)
+
+	latest.vSerdeLatest.InnerInstructions = &[]metalatest.InnerInstructions{{Index: 0}}
+	_, err = latest.ToProtobuf()
+	require.True(t, errors.Is(err, ErrNotUpgradable))
+
+	oldest := &TransactionStatusMetaContainer{vSerdeOldest: &metaoldest.TransactionStatusMeta{
+		Status: &metaoldest.Result__Err{Value: &metaoldest.TransactionError__InstructionError{
+			Field0: 2,
+			Field1: func() *metaoldest.InstructionError__CustomError {
+				v := metaoldest.InstructionError__CustomError(7)
+				return &v
+			}(),
+		}},
+		Fee: 5000,
+	}}
+	meta, err = oldest.ToProtobuf()
+	require.NoError(t, err)
+	require.Equal(t, customErr, meta.Err.Err)
+	require.True(t, meta.InnerInstructionsNone)
+
+	oldest.vSerdeOldest.Status = &metaoldest.Result__Ok{}
+	meta, err = oldest.ToProtobuf()
+	require.NoError(t, err)
+	require.Nil(t, meta.Err)
+}
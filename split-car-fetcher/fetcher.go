@@ -2,13 +2,16 @@ package splitcarfetcher
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/anjor/carlet"
@@ -18,6 +21,7 @@ import (
 type SplitCarReader struct {
 	files       *carlet.CarPiecesAndMetadata
 	multireader io.ReaderAt
+	pieces      []*io.SectionReader
 	closers     []io.Closer
 }
 
@@ -65,39 +69,74 @@ func (fscr *FileSplitCarReader) Size() int64 {
 }
 
 func GetContentSizeWithHeadOrZeroRange(url string) (int64, error) {
-	// try sending a HEAD request to the server to get the file size:
-	resp, err := http.Head(url)
+	info, err := GetRemoteFileInfo(http.DefaultClient, url)
 	if err != nil {
 		return 0, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		// try sending a GET request with a zero range to the server to get the file size:
-		req := &http.Request{
-			Method: "GET",
-			URL:    resp.Request.URL,
-			Header: make(http.Header),
-		}
-		req.Header.Set("Range", "bytes=0-0")
-		resp, err = http.DefaultClient.Do(req)
-		if err != nil {
-			return 0, err
-		}
-		if resp.StatusCode != http.StatusPartialContent {
-			return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-		}
-		// now find the content length:
-		contentRange := resp.Header.Get("Content-Range")
-		if contentRange == "" {
-			return 0, fmt.Errorf("missing Content-Range header")
-		}
-		var contentLength int64
-		_, err := fmt.Sscanf(contentRange, "bytes 0-0/%d", &contentLength)
-		if err != nil {
-			return 0, err
-		}
-		return contentLength, nil
+	return info.Size, nil
+}
+
+// RemoteFileInfo is the size of a remote file, and the validators (if the server provides them)
+// used to make sure that the file didn't change between requests.
+type RemoteFileInfo struct {
+	Size         int64
+	ETag         string
+	LastModified string
+}
+
+// Validator returns the value to be used in If-Range headers; a strong ETag is preferred,
+// then Last-Modified. It's empty if the server provided neither.
+func (info RemoteFileInfo) Validator() string {
+	if info.ETag != "" && !strings.HasPrefix(info.ETag, "W/") {
+		return info.ETag
+	}
+	return info.LastModified
+}
+
+// GetRemoteFileInfo gets the size and validators of a remote file,
+// with a HEAD request or (if HEAD is not supported) with a GET request with a zero range.
+func GetRemoteFileInfo(client *http.Client, url string) (*RemoteFileInfo, error) {
+	// try sending a HEAD request to the server to get the file size:
+	resp, err := client.Head(url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return &RemoteFileInfo{
+			Size:         resp.ContentLength,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}, nil
+	}
+	// try sending a GET request with a zero range to the server to get the file size:
+	req, err := http.NewRequest("GET", resp.Request.URL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err = client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	// now find the content length:
+	contentRange := resp.Header.Get("Content-Range")
+	if contentRange == "" {
+		return nil, fmt.Errorf("missing Content-Range header")
 	}
-	return resp.ContentLength, nil
+	var contentLength int64
+	if _, err := fmt.Sscanf(contentRange, "bytes 0-0/%d", &contentLength); err != nil {
+		return nil, err
+	}
+	return &RemoteFileInfo{
+		Size:         contentLength,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
 }
 
 func NewSplitCarReader(
@@ -177,6 +216,7 @@ func NewSplitCarReader(
 
 		scr.closers = append(scr.closers, fi)
 		sectionReader := io.NewSectionReader(fi, int64(cf.HeaderSize), int64(cf.ContentSize))
+		scr.pieces = append(scr.pieces, sectionReader)
 
 		readers = append(readers, sectionReader)
 		sizes = append(sizes, int64(cf.ContentSize))
@@ -196,6 +236,31 @@ func (scr *SplitCarReader) ReadAt(p []byte, off int64) (n int, err error) {
 	return scr.multireader.ReadAt(p, off)
 }
 
+// Warmup reads the first n bytes of the content of every piece (10 pieces at a time), so that
+// the connections to the piece sources are established before the first queries need them.
+// It returns the number of pieces.
+func (scr *SplitCarReader) Warmup(ctx context.Context, n int) (int, error) {
+	wg, ctx := errgroup.WithContext(ctx)
+	wg.SetLimit(10)
+	for i, piece := range scr.pieces {
+		i, piece := i, piece
+		wg.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			buf := make([]byte, min(int64(n), piece.Size()))
+			if _, err := piece.ReadAt(buf, 0); err != nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("failed to read piece %q: %w", scr.files.CarPieces[i].CommP, err)
+			}
+			return nil
+		})
+	}
+	if err := wg.Wait(); err != nil {
+		return 0, err
+	}
+	return len(scr.pieces), nil
+}
+
 func (scr *SplitCarReader) getOriginalCarHeaderReaderAt() (io.ReaderAt, int, error) {
 	originalWholeCarHeader, originalWholeCarHeaderSize, err := scr.originalCarHeader()
 	if err != nil {
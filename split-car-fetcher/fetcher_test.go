@@ -2,9 +2,15 @@ package splitcarfetcher
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"sync"
 	"testing"
+
+	"github.com/anjor/carlet"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMulti(t *testing.T) {
@@ -126,3 +132,52 @@ func TestMulti(t *testing.T) {
 		fmt.Printf("buf = %s\n", buf)
 	}
 }
+
+type recordingPiece struct {
+	*bytes.Reader
+	mu    sync.Mutex
+	reads []int64
+}
+
+func (p *recordingPiece) ReadAt(b []byte, off int64) (int, error) {
+	p.mu.Lock()
+	p.reads = append(p.reads, off)
+	p.mu.Unlock()
+	return p.Reader.ReadAt(b, off)
+}
+
+func (p *recordingPiece) Close() error {
+	return nil
+}
+
+func TestSplitCarReader_Warmup(t *testing.T) {
+	header := []byte("original header")
+	files := &carlet.CarPiecesAndMetadata{
+		OriginalCarHeaderSize: uint64(1 + len(header)),
+		OriginalCarHeader:     base64.StdEncoding.EncodeToString(header),
+	}
+	pieces := make(map[string]*recordingPiece)
+	for i, content := range []string{"first piece", "second piece", "x"} {
+		name := fmt.Sprintf("piece-%d", i)
+		data := append([]byte("hdr"), content...)
+		pieces[name] = &recordingPiece{Reader: bytes.NewReader(data)}
+		files.CarPieces = append(files.CarPieces, carlet.CarFile{
+			Name:        name,
+			HeaderSize:  3,
+			ContentSize: uint64(len(content)),
+		})
+	}
+	scr, err := NewSplitCarReader(files, func(carFile carlet.CarFile) (ReaderAtCloserSize, error) {
+		return pieces[carFile.Name], nil
+	})
+	require.NoError(t, err)
+	defer scr.Close()
+
+	n, err := scr.Warmup(context.Background(), 4)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	for name, piece := range pieces {
+		// the content starts after the piece header:
+		require.Equal(t, []int64{3}, piece.reads, name)
+	}
+}
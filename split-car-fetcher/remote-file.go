@@ -2,50 +2,74 @@ package splitcarfetcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/goware/urlx"
 	rangecache "github.com/rpcpool/yellowstone-faithful/range-cache"
+	"k8s.io/klog/v2"
 )
 
+// ErrRemoteFileChanged is returned when a remote file doesn't match anymore
+// the validator (ETag or Last-Modified) it had when it was opened.
+var ErrRemoteFileChanged = errors.New("remote file changed since it was opened")
+
 // NewRemoteHTTPFileAsIoReaderAt returns a ReaderAtCloser for a remote file.
 // The returned ReaderAtCloser is backed by a http.Client.
-func NewRemoteHTTPFileAsIoReaderAt(ctx context.Context, url string) (ReaderAtCloserSize, int64, error) {
-	// send a request to the server to get the file size:
-	contentLength, err := GetContentSizeWithHeadOrZeroRange(url)
-	if err != nil {
-		return nil, 0, err
+// Mirrors are other URLs serving the same file; when a request to the URL in use fails,
+// the next one is tried (and used from then on).
+func NewRemoteHTTPFileAsIoReaderAt(ctx context.Context, url string, mirrors ...string) (ReaderAtCloserSize, int64, error) {
+	rr := &HTTPSingleFileRemoteReaderAt{
+		url:    url,
+		client: NewHTTPClient(),
 	}
-	if contentLength == 0 {
-		return nil, 0, fmt.Errorf("missing Content-Length/Content-Range header, or file is empty")
+	for _, u := range append([]string{url}, mirrors...) {
+		rr.mirrors = append(rr.mirrors, &remoteMirror{url: u})
 	}
-
-	// Create a cache with a default expiration time of 5 minutes, and which
-	// purges expired items every 10 minutes
-	rr := &HTTPSingleFileRemoteReaderAt{
-		url:           url,
-		contentLength: contentLength,
-		client:        NewHTTPClient(),
+	// send a request to the server to get the file size (and validators);
+	// the first URL that answers determines the expected size of the file.
+	var errs []error
+	for i, m := range rr.mirrors {
+		info, err := GetRemoteFileInfo(rr.client, m.url)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.url, err))
+			continue
+		}
+		if info.Size == 0 {
+			errs = append(errs, fmt.Errorf("%s: missing Content-Length/Content-Range header, or file is empty", m.url))
+			continue
+		}
+		m.info = info
+		rr.contentLength = info.Size
+		rr.current.Store(int64(i))
+		break
+	}
+	if rr.contentLength == 0 {
+		return nil, 0, errors.Join(errs...)
 	}
+
 	parsedURL, err := urlx.Parse(url)
 	if err != nil {
 		return nil, 0, err
 	}
 	name := parsedURL.Path
 
+	// Create a cache with a default expiration time of 5 minutes, and which
+	// purges expired items every 10 minutes
 	rc := rangecache.NewRangeCache(
-		contentLength,
+		rr.contentLength,
 		name,
-		func(p []byte, off int64) (n int, err error) {
-			return remoteReadAt(rr.client, rr.url, p, off)
-		})
+		rr.remoteReadAt,
+	)
 	rc.StartCacheGC(ctx, 1*time.Minute)
 	rr.ca = rc
 
-	return rr, contentLength, nil
+	return rr, rr.contentLength, nil
 }
 
 type HTTPSingleFileRemoteReaderAt struct {
@@ -53,6 +77,40 @@ type HTTPSingleFileRemoteReaderAt struct {
 	contentLength int64
 	client        *http.Client
 	ca            *rangecache.RangeCache
+	// mirrors[0] is url.
+	mirrors []*remoteMirror
+	// current is the index of the mirror in use.
+	current atomic.Int64
+}
+
+type remoteMirror struct {
+	url string
+	mu  sync.Mutex
+	// info is fetched when the mirror is used for the first time.
+	info *RemoteFileInfo
+}
+
+// getInfo returns the info of the mirror, fetching it if needed,
+// and checks that the mirror serves a file of the expected size.
+func (m *remoteMirror) getInfo(client *http.Client, expectedSize int64) (*RemoteFileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.info == nil {
+		info, err := GetRemoteFileInfo(client, m.url)
+		if err != nil {
+			return nil, err
+		}
+		m.info = info
+	}
+	if m.info.Size != expectedSize {
+		return nil, fmt.Errorf("size mismatch: expected %d, got %d", expectedSize, m.info.Size)
+	}
+	return m.info, nil
+}
+
+// URL returns the URL currently in use.
+func (r *HTTPSingleFileRemoteReaderAt) URL() string {
+	return r.mirrors[r.current.Load()].url
 }
 
 // Close implements io.Closer.
@@ -103,7 +161,32 @@ func (r *HTTPSingleFileRemoteReaderAt) ReadAt(p []byte, off int64) (n int, err e
 	return n, nil
 }
 
-func remoteReadAt(client *http.Client, url string, p []byte, off int64) (n int, err error) {
+// remoteReadAt reads from the mirror in use, and fails over to the next mirrors on error.
+func (r *HTTPSingleFileRemoteReaderAt) remoteReadAt(p []byte, off int64) (n int, err error) {
+	first := int(r.current.Load())
+	var errs []error
+	for attempt := 0; attempt < len(r.mirrors); attempt++ {
+		i := (first + attempt) % len(r.mirrors)
+		m := r.mirrors[i]
+		info, err := m.getInfo(r.client, r.contentLength)
+		if err == nil {
+			n, err = remoteReadAt(r.client, m.url, info.Validator(), p, off)
+		}
+		if err == nil {
+			if i != first {
+				klog.Warningf("remote file %s: switched to mirror %s", r.url, m.url)
+				r.current.Store(int64(i))
+			}
+			return n, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", m.url, err))
+	}
+	return 0, errors.Join(errs...)
+}
+
+// remoteReadAt reads len(p) bytes at offset off; if validator is not empty, it's sent as If-Range,
+// so that a file that changed since it was opened is detected instead of silently returning different data.
+func remoteReadAt(client *http.Client, url string, validator string, p []byte, off int64) (n int, err error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return 0, err
@@ -113,7 +196,10 @@ func remoteReadAt(client *http.Client, url string, p []byte, off int64) (n int,
 		req.Header.Set("Keep-Alive", "timeout=600")
 	}
 
-	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))))
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	if validator != "" {
+		req.Header.Set("If-Range", validator)
+	}
 
 	var resp *http.Response
 	err = retryExpotentialBackoff(
@@ -128,6 +214,21 @@ func remoteReadAt(client *http.Client, url string, p []byte, off int64) (n int,
 		return 0, err
 	}
 	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		var start int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || start != off {
+			return 0, fmt.Errorf("unexpected Content-Range %q for offset %d", resp.Header.Get("Content-Range"), off)
+		}
+	case http.StatusOK:
+		// The server sends the whole file when the If-Range validator doesn't match.
+		if validator != "" {
+			return 0, ErrRemoteFileChanged
+		}
+		return 0, fmt.Errorf("server does not support range requests")
+	default:
+		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
 	{
 		n, err := io.ReadFull(resp.Body, p)
 		if err != nil {
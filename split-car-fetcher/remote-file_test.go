@@ -0,0 +1,86 @@
+package splitcarfetcher
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newFileServer(content *atomic.Pointer[[]byte], etag *atomic.Pointer[string]) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", *etag.Load())
+		http.ServeContent(w, r, "file.car", time.Time{}, bytes.NewReader(*content.Load()))
+	}))
+}
+
+func TestRemoteFile(t *testing.T) {
+	var content atomic.Pointer[[]byte]
+	var etag atomic.Pointer[string]
+	original := []byte("hello world, this is a remote file")
+	content.Store(&original)
+	tag := `"v1"`
+	etag.Store(&tag)
+
+	primary := newFileServer(&content, &etag)
+	defer primary.Close()
+
+	t.Run("read", func(t *testing.T) {
+		rr, size, err := NewRemoteHTTPFileAsIoReaderAt(context.Background(), primary.URL)
+		require.NoError(t, err)
+		defer rr.Close()
+		require.Equal(t, int64(len(original)), size)
+
+		buf := make([]byte, 5)
+		_, err = rr.ReadAt(buf, 6)
+		require.NoError(t, err)
+		require.Equal(t, []byte("world"), buf)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		rr, _, err := NewRemoteHTTPFileAsIoReaderAt(context.Background(), primary.URL)
+		require.NoError(t, err)
+		defer rr.Close()
+
+		changed := []byte("HELLO WORLD, THIS IS A REMOTE FILE")
+		content.Store(&changed)
+		newTag := `"v2"`
+		etag.Store(&newTag)
+		defer func() {
+			content.Store(&original)
+			etag.Store(&tag)
+		}()
+
+		buf := make([]byte, 5)
+		_, err = rr.ReadAt(buf, 6)
+		require.ErrorIs(t, err, ErrRemoteFileChanged)
+	})
+
+	t.Run("failover", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		mirror := newFileServer(&content, &etag)
+		defer mirror.Close()
+
+		rr, _, err := NewRemoteHTTPFileAsIoReaderAt(context.Background(), down.URL, mirror.URL)
+		require.NoError(t, err)
+		defer rr.Close()
+		require.Equal(t, mirror.URL, rr.(*HTTPSingleFileRemoteReaderAt).URL())
+
+		rr2, _, err := NewRemoteHTTPFileAsIoReaderAt(context.Background(), primary.URL, mirror.URL)
+		require.NoError(t, err)
+		defer rr2.Close()
+		primary.Close()
+
+		buf := make([]byte, 5)
+		_, err = rr2.ReadAt(buf, 0)
+		require.NoError(t, err)
+		require.Equal(t, []byte("hello"), buf)
+		require.Equal(t, mirror.URL, rr2.(*HTTPSingleFileRemoteReaderAt).URL())
+		down.Close()
+	})
+}
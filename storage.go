@@ -10,9 +10,16 @@ import (
 	"github.com/ipfs/go-cid"
 	carv2 "github.com/ipld/go-car/v2"
 	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/metrics"
+	"github.com/rpcpool/yellowstone-faithful/nodetools"
+	pagecache "github.com/rpcpool/yellowstone-faithful/page-cache"
+	"github.com/rpcpool/yellowstone-faithful/readahead"
 	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
 	splitcarfetcher "github.com/rpcpool/yellowstone-faithful/split-car-fetcher"
 	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/rpcpool/yellowstone-faithful/tracing"
+	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/exp/mmap"
 	"k8s.io/klog/v2"
 )
@@ -21,9 +28,12 @@ import (
 // Supported protocols are:
 // - http://
 // - https://
+//
+// If pages is not nil, the pages read from a remote index are cached on disk.
 func openIndexStorage(
 	ctx context.Context,
 	where string,
+	pages *pagecache.Cache,
 ) (ReaderAtCloser, error) {
 	where = strings.TrimSpace(where)
 	if strings.HasPrefix(where, "http://") || strings.HasPrefix(where, "https://") {
@@ -32,6 +42,9 @@ func openIndexStorage(
 		if err != nil {
 			return nil, fmt.Errorf("failed to open remote index file %q: %w", where, err)
 		}
+		if pages != nil {
+			rac = pages.Wrap(where, size, rac)
+		}
 		if !klog.V(5).Enabled() {
 			return rac, nil
 		}
@@ -58,11 +71,12 @@ func openIndexStorage(
 	}, nil
 }
 
-func openCarStorage(ctx context.Context, where string) (*carv2.Reader, ReaderAtCloser, error) {
+// openCarStorage opens a CAR file from a local file, or from a remote URL (with optional mirrors).
+func openCarStorage(ctx context.Context, where string, mirrors ...string) (*carv2.Reader, ReaderAtCloser, error) {
 	where = strings.TrimSpace(where)
 	if strings.HasPrefix(where, "http://") || strings.HasPrefix(where, "https://") {
-		klog.Infof("opening CAR file from %q as HTTP remote file", where)
-		rem, size, err := splitcarfetcher.NewRemoteHTTPFileAsIoReaderAt(ctx, where)
+		klog.Infof("opening CAR file from %q as HTTP remote file (%d mirrors)", where, len(mirrors))
+		rem, size, err := splitcarfetcher.NewRemoteHTTPFileAsIoReaderAt(ctx, where, mirrors...)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to open remote CAR file %q: %w", where, err)
 		}
@@ -82,6 +96,22 @@ func openCarStorage(ctx context.Context, where string) (*carv2.Reader, ReaderAtC
 	return carReader, nil, nil
 }
 
+// scheduleCarReads wraps the remote CAR reader of an epoch in a read scheduler if --car-read-scheduler
+// is set: it reads ahead of the sequential streams (i.e. the replay of blocks) and caches aligned blocks
+// for the random reads (i.e. RPC).
+func scheduleCarReads(c *cli.Context, car ReaderAtCloser) ReaderAtCloser {
+	if !c.Bool("car-read-scheduler") {
+		return car
+	}
+	return readahead.NewScheduler(car, readahead.SchedulerOptions{
+		ReadAheadSize: c.Int64("car-readahead-size") * readahead.MiB,
+		CacheSize:     c.Int64("car-read-cache-size") * readahead.MiB,
+		OnRead: func(pattern readahead.Pattern, fromMemory bool) {
+			metrics.CarScheduledReads.WithLabelValues(string(pattern), boolToString(fromMemory)).Inc()
+		},
+	})
+}
+
 func readSectionFromReaderAt(reader ReaderAtCloser, offset uint64, length uint64) ([]byte, error) {
 	data := make([]byte, length)
 	_, err := reader.ReadAt(data, int64(offset))
@@ -102,13 +132,20 @@ func readNodeFromReaderAtWithOffsetAndSize(reader ReaderAtCloser, wantedCid *cid
 }
 
 type GetBlockResponse struct {
-	BlockHeight       *uint64                  `json:"blockHeight"`
-	BlockTime         *uint64                  `json:"blockTime"`
-	Blockhash         string                   `json:"blockhash"`
-	ParentSlot        uint64                   `json:"parentSlot"`
-	PreviousBlockhash *string                  `json:"previousBlockhash"`
-	Rewards           any                      `json:"rewards"` // TODO: use same format as solana
-	Transactions      []GetTransactionResponse `json:"transactions"`
+	BlockHeight         *uint64                  `json:"blockHeight"`
+	BlockTime           *uint64                  `json:"blockTime"`
+	Blockhash           string                   `json:"blockhash"`
+	ParentSlot          uint64                   `json:"parentSlot"`
+	PreviousBlockhash   *string                  `json:"previousBlockhash"`
+	Rewards             any                      `json:"rewards"`                       // TODO: use same format as solana
+	NumRewardPartitions *uint64                  `json:"numRewardPartitions,omitempty"` // only for the first block of an epoch with partitioned rewards
+	RewardsTotal        *int                     `json:"rewardsTotal,omitempty"`        // only with the rewardsOffset or rewardsLimit options
+	Transactions        []GetTransactionResponse `json:"transactions"`
+	// Entries are only returned with the showEntries option.
+	Entries []nodetools.EntrySummary `json:"entries,omitempty"`
+	// Leader is the identity of the validator that produced the block; only returned if the
+	// epoch has a leader schedule.
+	Leader *string `json:"leader,omitempty"`
 }
 
 type GetTransactionResponse struct {
@@ -123,30 +160,74 @@ type GetTransactionResponse struct {
 }
 
 func parseTransactionAndMetaFromNode(
+	ctx context.Context,
+	transactionNode *ipldbindcode.Transaction,
+	dataFrameGetter func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error),
+) (solana.Transaction, any, error) {
+	ctx, span := tracing.Start(ctx, "meta.decode", attribute.Int64("slot", int64(transactionNode.Slot)))
+	tx, meta, err := decodeTransactionAndMetaFromNode(transactionNode, dataFrameGetterWithContext(ctx, dataFrameGetter))
+	tracing.End(span, err)
+	return tx, meta, err
+}
+
+// parseTransactionFromNode is parseTransactionAndMetaFromNode without the meta, for when the
+// status of the transaction is known from the indexes.
+func parseTransactionFromNode(
+	ctx context.Context,
+	transactionNode *ipldbindcode.Transaction,
+	dataFrameGetter func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error),
+) (solana.Transaction, error) {
+	ctx, span := tracing.Start(ctx, "tx.decode", attribute.Int64("slot", int64(transactionNode.Slot)))
+	tx, err := decodeTransactionFromNode(transactionNode, dataFrameGetterWithContext(ctx, dataFrameGetter))
+	tracing.End(span, err)
+	return tx, err
+}
+
+// dataFrameGetterWithContext makes the getter use ctx, as the data frames are loaded
+// without one (context.Background()).
+func dataFrameGetterWithContext(
+	ctx context.Context,
+	dataFrameGetter func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error),
+) func(context.Context, cid.Cid) (*ipldbindcode.DataFrame, error) {
+	return func(_ context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error) {
+		return dataFrameGetter(ctx, wantedCid)
+	}
+}
+
+func decodeTransactionFromNode(
+	transactionNode *ipldbindcode.Transaction,
+	dataFrameGetter func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error),
+) (tx solana.Transaction, _ error) {
+	transactionBuffer, err := tooling.LoadDataFromDataFrames(&transactionNode.Data, dataFrameGetter)
+	if err != nil {
+		return solana.Transaction{}, err
+	}
+	if err := bin.UnmarshalBin(&tx, transactionBuffer); err != nil {
+		klog.Errorf("failed to unmarshal transaction: %v", err)
+		return solana.Transaction{}, err
+	} else if len(tx.Signatures) == 0 {
+		klog.Errorf("transaction has no signatures")
+		return solana.Transaction{}, err
+	}
+	return tx, nil
+}
+
+func decodeTransactionAndMetaFromNode(
 	transactionNode *ipldbindcode.Transaction,
 	dataFrameGetter func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error),
 ) (tx solana.Transaction, meta any, _ error) {
-	{
-		transactionBuffer, err := tooling.LoadDataFromDataFrames(&transactionNode.Data, dataFrameGetter)
-		if err != nil {
-			return solana.Transaction{}, nil, err
-		}
-		if err := bin.UnmarshalBin(&tx, transactionBuffer); err != nil {
-			klog.Errorf("failed to unmarshal transaction: %v", err)
-			return solana.Transaction{}, nil, err
-		} else if len(tx.Signatures) == 0 {
-			klog.Errorf("transaction has no signatures")
-			return solana.Transaction{}, nil, err
-		}
+	tx, err := decodeTransactionFromNode(transactionNode, dataFrameGetter)
+	if err != nil || len(tx.Signatures) == 0 {
+		return solana.Transaction{}, nil, err
 	}
 
 	{
-		metaBuffer, err := tooling.LoadDataFromDataFrames(&transactionNode.Metadata, dataFrameGetter)
+		metaChunks, err := tooling.LoadChunksFromDataFrames(&transactionNode.Metadata, dataFrameGetter)
 		if err != nil {
 			return solana.Transaction{}, nil, err
 		}
-		if len(metaBuffer) > 0 {
-			uncompressedMeta, err := tooling.DecompressZstd(metaBuffer)
+		if metaChunks.Len() > 0 {
+			uncompressedMeta, err := tooling.DecompressZstdChunks(metaChunks)
 			if err != nil {
 				klog.Errorf("failed to decompress metadata: %v", err)
 				return
@@ -171,12 +252,12 @@ func getTransactionAndMetaFromNode(
 		return nil, nil, fmt.Errorf("failed to load transaction: %w", err)
 	}
 
-	metaBuffer, err := tooling.LoadDataFromDataFrames(&transactionNode.Metadata, dataFrameGetter)
+	metaChunks, err := tooling.LoadChunksFromDataFrames(&transactionNode.Metadata, dataFrameGetter)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to load metadata: %w", err)
 	}
-	if len(metaBuffer) > 0 {
-		uncompressedMeta, err := tooling.DecompressZstd(metaBuffer)
+	if metaChunks.Len() > 0 {
+		uncompressedMeta, err := tooling.DecompressZstdChunks(metaChunks)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to decompress metadata: %w", err)
 		}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/gagliardetto/solana-go"
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	hugecache "github.com/rpcpool/yellowstone-faithful/huge-cache"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/rpcpool/yellowstone-faithful/readahead"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestScheduleCarReads(t *testing.T) {
+	ctx := context.Background()
+	config, paths, slots, _ := buildTestEpoch(t)
+	local := openTestEpoch(t, config)
+	carPath := string(config.Data.Car.URI)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, carPath)
+	}))
+	defer server.Close()
+
+	set := flag.NewFlagSet("rpc", flag.ContinueOnError)
+	set.Bool("car-read-scheduler", false, "")
+	set.Int64("car-readahead-size", 1, "")
+	set.Int64("car-read-cache-size", 1, "")
+	require.NoError(t, set.Parse([]string{"--car-read-scheduler"}))
+	c := cli.NewContext(cli.NewApp(), set, nil)
+	c.Context = ctx
+	conf := bigcache.DefaultConfig(time.Minute)
+	conf.MaxEntriesInWindow = 1000
+	conf.Verbose = false
+	allCache, err := hugecache.NewWithConfig(ctx, conf)
+	require.NoError(t, err)
+	remote, err := NewEpochFromConfig(testEpochConfig(t, server.URL+"/epoch-0.car", paths), c, allCache, nil)
+	require.NoError(t, err)
+	defer remote.Close()
+	require.IsType(t, &readahead.Scheduler{}, remote.remoteCarReader)
+
+	// the blocks are the same, read in order (sequential) and then again (random, from the cache).
+	for pass := 0; pass < 2; pass++ {
+		for _, slot := range slots {
+			want, _, err := local.GetBlock(ctx, slot)
+			require.NoError(t, err)
+			got, _, err := remote.GetBlock(ctx, slot)
+			require.NoError(t, err, "slot %d", slot)
+			require.Equal(t, want, got, "slot %d", slot)
+		}
+	}
+	_, err = remote.Warmup(ctx, false)
+	require.NoError(t, err)
+}
+
+func TestParseTransactionFromNodeSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	tx := solana.Transaction{
+		Signatures: []solana.Signature{{1}},
+		Message: solana.Message{
+			Header:       solana.MessageHeader{NumRequiredSignatures: 1},
+			AccountKeys:  solana.PublicKeySlice{solana.SystemProgramID},
+			Instructions: []solana.CompiledInstruction{{ProgramIDIndex: 0}},
+		},
+	}
+	data, err := tx.MarshalBinary()
+	require.NoError(t, err)
+	// the transaction is split in two data frames: the second one is loaded with the getter.
+	next := ipldbindcode.List__Link{cidlink.Link{Cid: carwriter.DummyCID}}
+	nextPtr := &next
+	node := &ipldbindcode.Transaction{
+		Kind: int(iplddecoders.KindTransaction),
+		Data: ipldbindcode.DataFrame{Kind: int(iplddecoders.KindDataFrame), Data: data[:10], Next: &nextPtr},
+	}
+	var getterSpan trace.SpanContext
+	getter := func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error) {
+		getterSpan = trace.SpanContextFromContext(ctx)
+		return &ipldbindcode.DataFrame{Kind: int(iplddecoders.KindDataFrame), Data: data[10:]}, nil
+	}
+
+	got, err := parseTransactionFromNode(context.Background(), node, getter)
+	require.NoError(t, err)
+	require.Equal(t, tx.Signatures, got.Signatures)
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "tx.decode", spans[0].Name())
+	// the data frame is read within the span.
+	require.Equal(t, spans[0].SpanContext().SpanID(), getterSpan.SpanID())
+}
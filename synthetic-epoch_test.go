@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/rpcpool/yellowstone-faithful/carfixture"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	solanablockrewards "github.com/rpcpool/yellowstone-faithful/solana-block-rewards"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// TestSyntheticEpoch indexes and serves a generated CAR with every kind of transaction meta.
+func TestSyntheticEpoch(t *testing.T) {
+	carPath := filepath.Join(t.TempDir(), "epoch-0.car")
+	fixture, err := carfixture.Generate(carPath, carfixture.DefaultBlocks())
+	require.NoError(t, err)
+	paths, slots, sigs, err := buildTestEpochIndexes(carPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(paths.SlotToCid)) })
+	require.Equal(t, fixture.Slots(), slots)
+	require.Len(t, sigs, len(fixture.Transactions))
+
+	epoch := openTestEpoch(t, testEpochConfig(t, carPath, paths))
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+	get := func(path string) *fasthttp.Response {
+		var req fasthttp.Request
+		req.SetRequestURI(path)
+		var reqCtx fasthttp.RequestCtx
+		reqCtx.Init(&req, nil, nil)
+		multi.apiHandler(&reqCtx)
+		var resp fasthttp.Response
+		reqCtx.Response.CopyTo(&resp)
+		return &resp
+	}
+
+	t.Run("transactions", func(t *testing.T) {
+		for _, tx := range fixture.Transactions {
+			resp := get("/api/v1/tx/" + tx.Signature.String() + "?encoding=json&maxSupportedTransactionVersion=0")
+			require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), "%s: %s", tx.Spec.Meta, resp.Body())
+			var got struct {
+				Slot    uint64 `json:"slot"`
+				Version any    `json:"version"`
+				Meta    *struct {
+					Err             any    `json:"err"`
+					Fee             uint64 `json:"fee"`
+					LoadedAddresses *struct {
+						Writable []string `json:"writable"`
+						Readonly []string `json:"readonly"`
+					} `json:"loadedAddresses"`
+				} `json:"meta"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Body(), &got))
+			require.Equal(t, tx.Slot, got.Slot)
+			if tx.Spec.AddressLookups {
+				require.Equal(t, 0.0, got.Version)
+			} else {
+				require.Equal(t, "legacy", got.Version)
+			}
+			if tx.Spec.Meta == carfixture.MetaNone {
+				require.Nil(t, got.Meta)
+				continue
+			}
+			require.NotNil(t, got.Meta, "%s", tx.Spec.Meta)
+			require.Equal(t, uint64(carfixture.Fee), got.Meta.Fee)
+			require.Equal(t, tx.Spec.Failed, got.Meta.Err != nil)
+			if tx.Spec.AddressLookups {
+				require.NotNil(t, got.Meta.LoadedAddresses)
+				require.Equal(t, []string{tx.Accounts[3].String()}, got.Meta.LoadedAddresses.Writable)
+				require.Equal(t, []string{tx.Accounts[4].String()}, got.Meta.LoadedAddresses.Readonly)
+			}
+		}
+	})
+	t.Run("blocks", func(t *testing.T) {
+		byBlock := make(map[uint64]int)
+		for _, tx := range fixture.Transactions {
+			byBlock[tx.Slot]++
+		}
+		for _, block := range fixture.Blocks {
+			resp := get("/api/v1/block/" + strconv.FormatUint(block.Slot, 10) + "?encoding=base64&rewards=false")
+			require.Equal(t, fasthttp.StatusOK, resp.StatusCode(), "slot %d: %s", block.Slot, resp.Body())
+			var got struct {
+				ParentSlot   uint64 `json:"parentSlot"`
+				Transactions []any  `json:"transactions"`
+			}
+			require.NoError(t, json.Unmarshal(resp.Body(), &got))
+			require.Equal(t, block.ParentSlot, got.ParentSlot)
+			require.Len(t, got.Transactions, byBlock[block.Slot], "slot %d", block.Slot)
+		}
+		// skipped slot
+		require.Equal(t, fasthttp.StatusNotFound, get("/api/v1/block/3").StatusCode())
+
+		resp, err := multi.GetBlock(context.Background(), &old_faithful_grpc.BlockRequest{Slot: 4})
+		require.NoError(t, err)
+		rewards, err := solanablockrewards.ParseRewards(resp.Rewards)
+		require.NoError(t, err)
+		require.Len(t, rewards.Rewards, carfixture.LargeRewards)
+	})
+}
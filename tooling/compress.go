@@ -1,6 +1,7 @@
 package tooling
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/klauspost/compress/zstd"
@@ -23,6 +24,36 @@ func DecompressZstd(data []byte) ([]byte, error) {
 	return content, nil
 }
 
+// maxZstdPrealloc caps the buffer preallocated for the decompressed data, as declared in the zstd frame header.
+const maxZstdPrealloc = 64 * 1024 * 1024
+
+// DecompressZstdChunks decompresses zstd data split into chunks, streaming the chunks
+// through the decoder instead of concatenating them first.
+func DecompressZstdChunks(chunks DataChunks) ([]byte, error) {
+	switch len(chunks) {
+	case 0:
+		return DecompressZstd(nil)
+	case 1:
+		return DecompressZstd(chunks[0])
+	}
+	dec, err := zstdDecoderPool.Get(chunks.Reader())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zstd decoder from pool: %w", err)
+	}
+	defer zstdDecoderPool.Put(dec)
+
+	var content bytes.Buffer
+	var header zstd.Header
+	if header.Decode(chunks[0]) == nil && header.HasFCS && header.FrameContentSize <= maxZstdPrealloc {
+		// ReadFrom wants MinRead bytes of room even for the last read.
+		content.Grow(int(header.FrameContentSize) + bytes.MinRead)
+	}
+	if _, err := content.ReadFrom(dec); err != nil {
+		return nil, fmt.Errorf("failed to decompress zstd data: %w", err)
+	}
+	return content.Bytes(), nil
+}
+
 var zstdEncoderPool = zstdpool.NewEncoderPool(
 	zstd.WithEncoderLevel(zstd.SpeedBetterCompression),
 	// zstd.WithEncoderLevel(zstd.SpeedFastest),
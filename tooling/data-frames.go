@@ -3,56 +3,132 @@ package tooling
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"io"
 
 	"github.com/ipfs/go-cid"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
 )
 
+// DataChunks is data stored in a chain of data frames, as the slices of the frames:
+// it can be read (and hashed, and decompressed) without concatenating the frames into a new buffer.
+type DataChunks [][]byte
+
+// Len returns the size of the data.
+func (c DataChunks) Len() int {
+	size := 0
+	for _, chunk := range c {
+		size += len(chunk)
+	}
+	return size
+}
+
+// Reader returns a reader of the data, over the chunks.
+func (c DataChunks) Reader() io.Reader {
+	if len(c) == 1 {
+		return bytes.NewReader(c[0])
+	}
+	readers := make([]io.Reader, len(c))
+	for i, chunk := range c {
+		readers[i] = bytes.NewReader(chunk)
+	}
+	return io.MultiReader(readers...)
+}
+
+// Bytes returns the data as a single slice. The data of a single frame is not copied
+// (so it must not be modified); the chunks of several frames are concatenated.
+func (c DataChunks) Bytes() []byte {
+	switch len(c) {
+	case 0:
+		return nil
+	case 1:
+		return c[0]
+	}
+	buf := make([]byte, 0, c.Len())
+	for _, chunk := range c {
+		buf = append(buf, chunk...)
+	}
+	return buf
+}
+
+// LoadDataFromDataFrames returns the data of the data frame and of the frames it links to, as a single slice.
 func LoadDataFromDataFrames(
 	firstDataFrame *ipldbindcode.DataFrame,
 	dataFrameGetter func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error),
 ) ([]byte, error) {
-	dataBuffer := new(bytes.Buffer)
+	chunks, err := LoadChunksFromDataFrames(firstDataFrame, dataFrameGetter)
+	if err != nil {
+		return nil, err
+	}
+	return chunks.Bytes(), nil
+}
+
+// LoadChunksFromDataFrames returns the data of the data frame and of the frames it links to, without copying it.
+func LoadChunksFromDataFrames(
+	firstDataFrame *ipldbindcode.DataFrame,
+	dataFrameGetter func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error),
+) (DataChunks, error) {
 	allFrames, err := getAllFramesFromDataFrame(firstDataFrame, dataFrameGetter)
 	if err != nil {
 		return nil, err
 	}
+	chunks := make(DataChunks, 0, len(allFrames))
 	for _, frame := range allFrames {
-		dataBuffer.Write(frame.Bytes())
+		if len(frame.Bytes()) > 0 {
+			chunks = append(chunks, frame.Bytes())
+		}
 	}
 	// verify the data hash (if present)
 	bufHash, ok := firstDataFrame.GetHash()
 	if !ok {
-		return dataBuffer.Bytes(), nil
+		return chunks, nil
 	}
-	err = ipldbindcode.VerifyHash(dataBuffer.Bytes(), bufHash)
+	err = ipldbindcode.VerifyHashOfChunks(chunks, bufHash)
 	if err != nil {
 		return nil, err
 	}
-	return dataBuffer.Bytes(), nil
+	return chunks, nil
 }
 
 func getAllFramesFromDataFrame(
 	firstDataFrame *ipldbindcode.DataFrame,
 	dataFrameGetter func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error),
 ) ([]*ipldbindcode.DataFrame, error) {
-	frames := []*ipldbindcode.DataFrame{firstDataFrame}
+	return appendFramesFromDataFrame(nil, firstDataFrame, dataFrameGetter, make(map[cid.Cid]struct{}))
+}
+
+// appendFramesFromDataFrame appends the frame and the frames it links to;
+// seen has the CIDs of the frames already loaded, so that a cycle of links in a corrupt CAR is an error.
+func appendFramesFromDataFrame(
+	frames []*ipldbindcode.DataFrame,
+	dataFrame *ipldbindcode.DataFrame,
+	dataFrameGetter func(ctx context.Context, wantedCid cid.Cid) (*ipldbindcode.DataFrame, error),
+	seen map[cid.Cid]struct{},
+) ([]*ipldbindcode.DataFrame, error) {
+	frames = append(frames, dataFrame)
 	// get the next data frames
-	next, ok := firstDataFrame.GetNext()
+	next, ok := dataFrame.GetNext()
 	if !ok || len(next) == 0 {
 		return frames, nil
 	}
-	for _, cid := range next {
-		nextDataFrame, err := dataFrameGetter(context.Background(), cid.(cidlink.Link).Cid)
+	for _, link := range next {
+		nextLink, ok := link.(cidlink.Link)
+		if !ok {
+			return nil, fmt.Errorf("unexpected data frame link type %T", link)
+		}
+		if _, ok := seen[nextLink.Cid]; ok {
+			return nil, fmt.Errorf("data frame %s is linked more than once", nextLink.Cid)
+		}
+		seen[nextLink.Cid] = struct{}{}
+		nextDataFrame, err := dataFrameGetter(context.Background(), nextLink.Cid)
 		if err != nil {
 			return nil, err
 		}
-		nextFrames, err := getAllFramesFromDataFrame(nextDataFrame, dataFrameGetter)
+		frames, err = appendFramesFromDataFrame(frames, nextDataFrame, dataFrameGetter, seen)
 		if err != nil {
 			return nil, err
 		}
-		frames = append(frames, nextFrames...)
 	}
 	return frames, nil
 }
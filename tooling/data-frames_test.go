@@ -0,0 +1,203 @@
+package tooling
+
+import (
+	"bytes"
+	"context"
+	"hash/crc64"
+	"io"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/bindnode"
+	"github.com/multiformats/go-multihash"
+	"github.com/rpcpool/yellowstone-faithful/ipld/ipldbindcode"
+	"github.com/rpcpool/yellowstone-faithful/iplddecoders"
+	"github.com/stretchr/testify/require"
+)
+
+// nextFrameCid is the CID that every test frame links to.
+var nextFrameCid = cid.NewCidV1(cid.DagCBOR, func() multihash.Multihash {
+	h, _ := multihash.Sum([]byte("next"), multihash.SHA2_256, -1)
+	return h
+}())
+
+func encodeDataFrame(t testing.TB, data []byte, linkNext bool) []byte {
+	frame := &ipldbindcode.DataFrame{
+		Kind: int(iplddecoders.KindDataFrame),
+		Data: data,
+	}
+	if linkNext {
+		next := ipldbindcode.List__Link{cidlink.Link{Cid: nextFrameCid}}
+		nextPtr := &next
+		frame.Next = &nextPtr
+	}
+	var buf bytes.Buffer
+	require.NoError(t, dagcbor.Encode(bindnode.Wrap(frame, ipldbindcode.Prototypes.DataFrame.Type()).Representation(), &buf))
+	return buf.Bytes()
+}
+
+// loadFuzzedDataFrames loads the data of the first frame; every link resolves to the next frame.
+func loadFuzzedDataFrames(first []byte, next []byte) ([]byte, error) {
+	firstFrame, err := iplddecoders.DecodeDataFrame(first)
+	if err != nil {
+		return nil, err
+	}
+	return LoadDataFromDataFrames(firstFrame, func(_ context.Context, c cid.Cid) (*ipldbindcode.DataFrame, error) {
+		return iplddecoders.DecodeDataFrame(next)
+	})
+}
+
+func TestLoadDataFromDataFrames(t *testing.T) {
+	data, err := loadFuzzedDataFrames(encodeDataFrame(t, []byte("hello "), true), encodeDataFrame(t, []byte("world"), false))
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello world"), data)
+
+	// A frame that links to itself.
+	_, err = loadFuzzedDataFrames(encodeDataFrame(t, []byte("hello "), true), encodeDataFrame(t, []byte("loop"), true))
+	require.ErrorContains(t, err, "linked more than once")
+
+	_, err = loadFuzzedDataFrames(encodeDataFrame(t, []byte("hello "), true), []byte{0x83, 0x06})
+	require.Error(t, err)
+}
+
+// chainDataFrames splits data into frames of frameSize bytes; the first frame has the hash of the data,
+// and links to the others.
+func chainDataFrames(data []byte, frameSize int) (*ipldbindcode.DataFrame, func(context.Context, cid.Cid) (*ipldbindcode.DataFrame, error)) {
+	hash := int(crc64.Checksum(data, crc64.MakeTable(crc64.ISO)))
+	hashPtr := &hash
+	first := &ipldbindcode.DataFrame{Kind: int(iplddecoders.KindDataFrame), Hash: &hashPtr}
+	rest := make(map[cid.Cid]*ipldbindcode.DataFrame)
+	var next ipldbindcode.List__Link
+	for i := 0; i < len(data); i += frameSize {
+		chunk := data[i:min(i+frameSize, len(data))]
+		if i == 0 {
+			first.Data = chunk
+			continue
+		}
+		// the offset makes the CIDs of frames with the same data different
+		h, _ := multihash.Sum(append([]byte(strconv.Itoa(i)), chunk...), multihash.SHA2_256, -1)
+		c := cid.NewCidV1(cid.DagCBOR, h)
+		rest[c] = &ipldbindcode.DataFrame{Kind: int(iplddecoders.KindDataFrame), Data: chunk}
+		next = append(next, cidlink.Link{Cid: c})
+	}
+	if len(next) > 0 {
+		nextPtr := &next
+		first.Next = &nextPtr
+	}
+	return first, func(_ context.Context, c cid.Cid) (*ipldbindcode.DataFrame, error) {
+		return rest[c], nil
+	}
+}
+
+func TestLoadChunksFromDataFrames(t *testing.T) {
+	data := make([]byte, 100_000)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	first, getter := chainDataFrames(data, 30_000)
+	chunks, err := LoadChunksFromDataFrames(first, getter)
+	require.NoError(t, err)
+	require.Len(t, chunks, 4)
+	require.Equal(t, len(data), chunks.Len())
+	require.Equal(t, data, chunks.Bytes())
+	read, err := io.ReadAll(chunks.Reader())
+	require.NoError(t, err)
+	require.Equal(t, data, read)
+
+	// The data of a single frame is not copied.
+	first, getter = chainDataFrames(data, len(data))
+	chunks, err = LoadChunksFromDataFrames(first, getter)
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	require.Same(t, &first.Data[0], &chunks.Bytes()[0])
+
+	// The hash covers all the frames.
+	first, getter = chainDataFrames(data, 30_000)
+	wrong := int(12345)
+	wrongPtr := &wrong
+	first.Hash = &wrongPtr
+	_, err = LoadChunksFromDataFrames(first, getter)
+	require.ErrorContains(t, err, "hash mismatch")
+}
+
+func TestDecompressZstdChunks(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	data := make([]byte, 3_000_000)
+	for i := range data {
+		// compressible, but not trivially
+		data[i] = byte(rng.Intn(16))
+	}
+	compressed, err := CompressZstd(data)
+	require.NoError(t, err)
+
+	for _, frameSize := range []int{len(compressed), 1 << 20, 100_000, 1000} {
+		first, getter := chainDataFrames(compressed, frameSize)
+		chunks, err := LoadChunksFromDataFrames(first, getter)
+		require.NoError(t, err)
+		decompressed, err := DecompressZstdChunks(chunks)
+		require.NoError(t, err, "frame size %d", frameSize)
+		require.True(t, bytes.Equal(data, decompressed), "frame size %d", frameSize)
+	}
+
+	_, err = DecompressZstdChunks(DataChunks{compressed[:100], compressed[100:1000]})
+	require.Error(t, err)
+}
+
+func BenchmarkDecompressZstdChunks(b *testing.B) {
+	rng := rand.New(rand.NewSource(3))
+	data := make([]byte, 8_000_000)
+	for i := range data {
+		data[i] = byte(rng.Intn(16))
+	}
+	compressed, err := CompressZstd(data)
+	require.NoError(b, err)
+	first, getter := chainDataFrames(compressed, 1<<20)
+	b.Run("concatenated", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf, err := LoadDataFromDataFrames(first, getter)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := DecompressZstd(buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("chunks", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			chunks, err := LoadChunksFromDataFrames(first, getter)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := DecompressZstdChunks(chunks); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// FuzzLoadDataFromDataFrames checks that corrupt data frames are reported as errors instead of panics
+// (or endless recursion). Crashers found by the fuzzer are kept in testdata/fuzz/FuzzLoadDataFromDataFrames.
+func FuzzLoadDataFromDataFrames(f *testing.F) {
+	f.Add(encodeDataFrame(f, []byte("hello"), false), []byte{})
+	f.Add(encodeDataFrame(f, []byte("hello "), true), encodeDataFrame(f, []byte("world"), false))
+	f.Add(encodeDataFrame(f, []byte("hello "), true), encodeDataFrame(f, []byte("loop"), true))
+	f.Fuzz(func(t *testing.T, first []byte, next []byte) {
+		data, err := loadFuzzedDataFrames(first, next)
+		if err != nil {
+			if data != nil {
+				t.Fatal("data returned with an error")
+			}
+			return
+		}
+		firstFrame, _ := iplddecoders.DecodeDataFrame(first)
+		if !bytes.HasPrefix(data, firstFrame.Bytes()) {
+			t.Fatal("data doesn't start with the first frame")
+		}
+	})
+}
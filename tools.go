@@ -3,10 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
-	"time"
 
 	"gopkg.in/yaml.v3"
-	"k8s.io/klog/v2"
 )
 
 func isDirectory(path string) (bool, error) {
@@ -71,26 +69,6 @@ func loadFromYAML(configFilepath string, dst any) error {
 	return yaml.NewDecoder(file).Decode(dst)
 }
 
-type timer struct {
-	reqID string
-	start time.Time
-	prev  time.Time
-}
-
-func newTimer(reqID string) *timer {
-	now := time.Now()
-	return &timer{
-		reqID: reqID,
-		start: now,
-		prev:  now,
-	}
-}
-
-func (t *timer) time(name string) {
-	klog.V(4).Infof("[%s]: %q: %s (overall %s)", t.reqID, name, time.Since(t.prev), time.Since(t.start))
-	t.prev = time.Now()
-}
-
 //	pub enum RewardType {
 //	    Fee,
 //	    Rent,
@@ -126,5 +104,3 @@ func rewardTypeStringToInt(typ string) int {
 		return 0
 	}
 }
-
-const CodeNotFound = -32009
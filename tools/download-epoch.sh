@@ -1,5 +1,7 @@
 #!/bin/bash
 
+# Deprecated: use `faithful-cli fetch-epoch`, which also verifies the files and writes the epoch config.
+
 set -o pipefail
 set -e
 
@@ -0,0 +1,173 @@
+package torrent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+var ErrInvalidBencode = errors.New("invalid bencode")
+
+// maxBencodeDepth bounds the nesting of the decoded values.
+const maxBencodeDepth = 32
+
+// decodeBencode decodes a bencoded value: an int64, a string, a []any or a map[string]any.
+// rawInfo is the encoding of the "info" entry of the top-level dictionary, if any
+// (the info hash is the SHA1 of it).
+func decodeBencode(data []byte) (value any, rawInfo []byte, err error) {
+	d := &bencodeDecoder{data: data}
+	value, err = d.value(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.pos != len(data) {
+		return nil, nil, fmt.Errorf("%w: trailing data at %d", ErrInvalidBencode, d.pos)
+	}
+	return value, d.rawInfo, nil
+}
+
+type bencodeDecoder struct {
+	data    []byte
+	pos     int
+	rawInfo []byte
+}
+
+func (d *bencodeDecoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s at %d", ErrInvalidBencode, fmt.Sprintf(format, args...), d.pos)
+}
+
+func (d *bencodeDecoder) value(depth int) (any, error) {
+	if depth > maxBencodeDepth {
+		return nil, d.errorf("too deep")
+	}
+	if d.pos >= len(d.data) {
+		return nil, d.errorf("unexpected end")
+	}
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		d.pos++
+		end := bytes.IndexByte(d.data[d.pos:], 'e')
+		if end < 0 {
+			return nil, d.errorf("unterminated integer")
+		}
+		n, err := strconv.ParseInt(string(d.data[d.pos:d.pos+end]), 10, 64)
+		if err != nil {
+			return nil, d.errorf("invalid integer")
+		}
+		d.pos += end + 1
+		return n, nil
+	case c == 'l':
+		d.pos++
+		list := []any{}
+		for {
+			if d.pos >= len(d.data) {
+				return nil, d.errorf("unterminated list")
+			}
+			if d.data[d.pos] == 'e' {
+				d.pos++
+				return list, nil
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+	case c == 'd':
+		d.pos++
+		dict := map[string]any{}
+		for {
+			if d.pos >= len(d.data) {
+				return nil, d.errorf("unterminated dictionary")
+			}
+			if d.data[d.pos] == 'e' {
+				d.pos++
+				return dict, nil
+			}
+			key, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			start := d.pos
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if depth == 0 && key == "info" {
+				d.rawInfo = d.data[start:d.pos]
+			}
+			dict[key] = v
+		}
+	case c >= '0' && c <= '9':
+		return d.string()
+	default:
+		return nil, d.errorf("unexpected %q", c)
+	}
+}
+
+func (d *bencodeDecoder) string() (string, error) {
+	colon := bytes.IndexByte(d.data[d.pos:], ':')
+	if colon < 0 {
+		return "", d.errorf("invalid string")
+	}
+	n, err := strconv.Atoi(string(d.data[d.pos : d.pos+colon]))
+	if err != nil || n < 0 {
+		return "", d.errorf("invalid string length")
+	}
+	d.pos += colon + 1
+	if n > len(d.data)-d.pos {
+		return "", d.errorf("string too long")
+	}
+	s := string(d.data[d.pos : d.pos+n])
+	d.pos += n
+	return s, nil
+}
+
+// encodeBencode encodes an int, an int64, a string, a []byte, a []string, a []any or a
+// map[string]any (with sorted keys).
+func encodeBencode(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case int:
+		fmt.Fprintf(buf, "i%de", v)
+	case int64:
+		fmt.Fprintf(buf, "i%de", v)
+	case string:
+		fmt.Fprintf(buf, "%d:%s", len(v), v)
+	case []byte:
+		fmt.Fprintf(buf, "%d:", len(v))
+		buf.Write(v)
+	case []string:
+		buf.WriteByte('l')
+		for _, s := range v {
+			fmt.Fprintf(buf, "%d:%s", len(s), s)
+		}
+		buf.WriteByte('e')
+	case []any:
+		buf.WriteByte('l')
+		for _, item := range v {
+			if err := encodeBencode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('d')
+		for _, key := range keys {
+			fmt.Fprintf(buf, "%d:%s", len(key), key)
+			if err := encodeBencode(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("can't bencode %T", v)
+	}
+	return nil
+}
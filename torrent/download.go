@@ -0,0 +1,524 @@
+package torrent
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Options are the options of Download.
+type Options struct {
+	// PeerID is the ID sent to the trackers and the peers; a random one is used if it is zero.
+	PeerID [20]byte
+	// HTTPClient is used for the trackers and the web seeds; http.DefaultClient if nil.
+	HTTPClient *http.Client
+	// MaxPeers is the number of peers to download from at the same time (default 20).
+	MaxPeers int
+	// WrapReader wraps the connections to the peers and the responses of the web seeds
+	// (to limit the bandwidth, for example).
+	WrapReader func(io.Reader) io.Reader
+	// Files are the paths of the files to download; all the files of the torrent if nil.
+	Files []string
+	// StallTimeout is how long the download can go without getting a piece before it fails
+	// (default 5 minutes).
+	StallTimeout time.Duration
+}
+
+// Download downloads the files of the torrent (or the ones in opts.Files) in dir: the file with
+// the path "a/b" is written to dir/a/b (without the name of the torrent). The pieces of the files
+// that are already there are checked and not downloaded again.
+func Download(ctx context.Context, mi *MetaInfo, dir string, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if opts.PeerID == ([20]byte{}) {
+		copy(opts.PeerID[:], "-YF0001-")
+		if _, err := rand.Read(opts.PeerID[8:]); err != nil {
+			return err
+		}
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.MaxPeers <= 0 {
+		opts.MaxPeers = 20
+	}
+	if opts.StallTimeout <= 0 {
+		opts.StallTimeout = 5 * time.Minute
+	}
+	st, err := openStorage(mi, dir, opts.Files)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	pk, err := st.check()
+	if err != nil {
+		return err
+	}
+	if pk.left() == 0 {
+		return nil
+	}
+	klog.Infof("torrent %s: %d/%d pieces to download", mi.Name, pk.left(), len(mi.Pieces))
+
+	ctx, cancel := context.WithCancel(ctx)
+	d := &downloader{mi: mi, st: st, pk: pk, opts: opts, active: make(map[netip.AddrPort]bool)}
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	for _, ws := range mi.WebSeeds {
+		wg.Add(1)
+		go func(ws string) {
+			defer wg.Done()
+			d.webSeed(ctx, ws)
+		}(ws)
+	}
+	for _, tracker := range mi.Trackers {
+		wg.Add(1)
+		go func(tracker string) {
+			defer wg.Done()
+			d.tracker(ctx, tracker, &wg)
+		}(tracker)
+	}
+
+	stalled := time.NewTimer(opts.StallTimeout)
+	defer stalled.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-pk.done:
+			return st.Sync()
+		case <-pk.progress:
+			stalled.Reset(opts.StallTimeout)
+		case <-stalled.C:
+			return fmt.Errorf("torrent %s: no piece downloaded in %s (%d/%d pieces left)", mi.Name, opts.StallTimeout, pk.left(), len(mi.Pieces))
+		}
+	}
+}
+
+type downloader struct {
+	mi   *MetaInfo
+	st   *storage
+	pk   *picker
+	opts *Options
+
+	mu     sync.Mutex
+	active map[netip.AddrPort]bool
+}
+
+// savePiece checks the piece against its hash and writes it.
+func (d *downloader) savePiece(piece int, data []byte) error {
+	if sha1.Sum(data) != d.mi.Pieces[piece] {
+		return fmt.Errorf("piece %d: hash mismatch", piece)
+	}
+	if err := d.st.WritePiece(piece, data); err != nil {
+		return err
+	}
+	d.pk.complete(piece)
+	return nil
+}
+
+// tracker announces to the tracker periodically, and downloads from the peers it returns.
+func (d *downloader) tracker(ctx context.Context, tracker string, wg *sync.WaitGroup) {
+	for {
+		left := int64(d.pk.left()) * d.mi.PieceLength
+		peers, interval, err := announce(ctx, d.opts.HTTPClient, tracker, d.mi.InfoHash, d.opts.PeerID, left)
+		if err != nil {
+			klog.Warningf("torrent %s: %v", d.mi.Name, err)
+			interval = time.Minute
+		}
+		for _, addr := range peers {
+			d.mu.Lock()
+			start := !d.active[addr] && len(d.active) < d.opts.MaxPeers
+			if start {
+				d.active[addr] = true
+			}
+			d.mu.Unlock()
+			if start {
+				wg.Add(1)
+				go func(addr netip.AddrPort) {
+					defer wg.Done()
+					if err := d.peer(ctx, addr); err != nil && ctx.Err() == nil {
+						klog.V(2).Infof("torrent %s: peer %s: %v", d.mi.Name, addr, err)
+					}
+					d.mu.Lock()
+					delete(d.active, addr)
+					d.mu.Unlock()
+				}(addr)
+			}
+		}
+		// announce again sooner if there are not enough peers.
+		d.mu.Lock()
+		if len(d.active) < d.opts.MaxPeers {
+			interval = min(interval, 2*time.Minute)
+		}
+		d.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.pk.done:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// peer downloads pieces from the peer until it has no more pieces that are needed.
+func (d *downloader) peer(ctx context.Context, addr netip.AddrPort) error {
+	p, err := dialPeer(ctx, addr, d.mi.InfoHash, d.opts.PeerID, len(d.mi.Pieces), d.opts.WrapReader)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { p.Close() })
+	defer stop()
+	defer p.Close()
+	// wait for the bitfield (or the first have).
+	if _, _, err := p.readMessage(); err != nil {
+		return err
+	}
+	for {
+		piece, ok := d.pk.next(p.has)
+		if !ok {
+			return nil
+		}
+		data, err := p.downloadPiece(piece, d.mi.PieceSize(piece))
+		if err == nil {
+			err = d.savePiece(piece, data)
+		}
+		if err != nil {
+			d.pk.release(piece)
+			return err
+		}
+	}
+}
+
+// webSeed downloads pieces from the web seed, until all the pieces are downloaded or it fails
+// too many times in a row.
+func (d *downloader) webSeed(ctx context.Context, ws string) {
+	all := func(int) bool { return true }
+	failures := 0
+	for failures < 5 {
+		piece, ok := d.pk.next(all)
+		if !ok {
+			if d.pk.left() == 0 {
+				return
+			}
+			// the pieces that are left are being downloaded from the peers.
+			select {
+			case <-ctx.Done():
+				return
+			case <-d.pk.done:
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		data, err := d.webSeedPiece(ctx, ws, piece)
+		if err == nil {
+			err = d.savePiece(piece, data)
+		}
+		if err != nil {
+			d.pk.release(piece)
+			if ctx.Err() != nil {
+				return
+			}
+			failures++
+			klog.Warningf("torrent %s: web seed %s: %v", d.mi.Name, ws, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(failures) * time.Second):
+			}
+			continue
+		}
+		failures = 0
+	}
+	klog.Warningf("torrent %s: giving up on web seed %s", d.mi.Name, ws)
+}
+
+// webSeedURL returns the URL of the file on the web seed (see BEP 19).
+func (d *downloader) webSeedURL(ws string, file File) string {
+	if !d.mi.MultiFile {
+		if strings.HasSuffix(ws, "/") {
+			return ws + url.PathEscape(d.mi.Name)
+		}
+		return ws
+	}
+	if !strings.HasSuffix(ws, "/") {
+		ws += "/"
+	}
+	elements := strings.Split(file.Path, "/")
+	for i, element := range elements {
+		elements[i] = url.PathEscape(element)
+	}
+	return ws + url.PathEscape(d.mi.Name) + "/" + strings.Join(elements, "/")
+}
+
+// webSeedPiece downloads the piece from the web seed, with a range request for each of the
+// files that the piece spans.
+func (d *downloader) webSeedPiece(ctx context.Context, ws string, piece int) ([]byte, error) {
+	start := int64(piece) * d.mi.PieceLength
+	data := make([]byte, d.mi.PieceSize(piece))
+	for _, file := range d.st.filesIn(start, int64(len(data))) {
+		from := max(start, file.Offset)
+		to := min(start+int64(len(data)), file.Offset+file.Length)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.webSeedURL(ws, file), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from-file.Offset, to-file.Offset-1))
+		resp, err := d.opts.HTTPClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var body io.Reader = resp.Body
+		if d.opts.WrapReader != nil {
+			body = d.opts.WrapReader(body)
+		}
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			_, err = io.ReadFull(body, data[from-start:to-start])
+		case http.StatusOK:
+			// the server ignored the range.
+			if _, err = io.CopyN(io.Discard, body, from-file.Offset); err == nil {
+				_, err = io.ReadFull(body, data[from-start:to-start])
+			}
+		default:
+			err = fmt.Errorf("%s: %s", req.URL, resp.Status)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// picker hands out the pieces to download.
+type picker struct {
+	mu sync.Mutex
+	// have are the pieces that are downloaded, and busy the ones being downloaded.
+	have, busy []bool
+	remaining  int
+	// progress gets a value when a piece is downloaded, and done is closed when all are.
+	progress chan struct{}
+	done     chan struct{}
+}
+
+func newPicker(have []bool) *picker {
+	pk := &picker{have: have, busy: make([]bool, len(have)), progress: make(chan struct{}, 1), done: make(chan struct{})}
+	for _, ok := range have {
+		if !ok {
+			pk.remaining++
+		}
+	}
+	if pk.remaining == 0 {
+		close(pk.done)
+	}
+	return pk
+}
+
+// next returns a piece that is needed, not being downloaded, and available.
+func (pk *picker) next(available func(int) bool) (int, bool) {
+	pk.mu.Lock()
+	defer pk.mu.Unlock()
+	for i := range pk.have {
+		if !pk.have[i] && !pk.busy[i] && available(i) {
+			pk.busy[i] = true
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// release makes the piece available again after a failed download.
+func (pk *picker) release(piece int) {
+	pk.mu.Lock()
+	defer pk.mu.Unlock()
+	pk.busy[piece] = false
+}
+
+func (pk *picker) complete(piece int) {
+	pk.mu.Lock()
+	defer pk.mu.Unlock()
+	pk.busy[piece] = false
+	if pk.have[piece] {
+		return
+	}
+	pk.have[piece] = true
+	pk.remaining--
+	if pk.remaining == 0 {
+		close(pk.done)
+	}
+	select {
+	case pk.progress <- struct{}{}:
+	default:
+	}
+}
+
+func (pk *picker) left() int {
+	pk.mu.Lock()
+	defer pk.mu.Unlock()
+	return pk.remaining
+}
+
+// storage maps the pieces of the torrent to its files.
+type storage struct {
+	mi *MetaInfo
+	// files are the open files, nil for the files that are not selected.
+	files []*os.File
+}
+
+// errNotStored is returned when reading a piece that spans a file that is not selected.
+var errNotStored = errors.New("the piece is not stored")
+
+// openStorage opens (and creates) the files of the torrent in dir; only the files in selected
+// if it is not nil.
+func openStorage(mi *MetaInfo, dir string, selected []string) (*storage, error) {
+	want := make(map[string]bool)
+	for _, path := range selected {
+		want[path] = true
+	}
+	st := &storage{mi: mi}
+	for _, file := range mi.Files {
+		if selected != nil && !want[file.Path] {
+			st.files = append(st.files, nil)
+			continue
+		}
+		delete(want, file.Path)
+		path := filepath.Join(dir, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			st.Close()
+			return nil, err
+		}
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			st.Close()
+			return nil, err
+		}
+		st.files = append(st.files, f)
+		if err := f.Truncate(file.Length); err != nil {
+			st.Close()
+			return nil, err
+		}
+	}
+	for path := range want {
+		st.Close()
+		return nil, fmt.Errorf("the torrent has no file %q", path)
+	}
+	return st, nil
+}
+
+// check hashes the pieces that are already there, and returns a picker for the others. The
+// pieces that span no selected file are not needed; the ones that span a selected file and one
+// that is not are always downloaded again, as they can't be checked.
+func (st *storage) check() (*picker, error) {
+	have := make([]bool, len(st.mi.Pieces))
+	for i := range have {
+		if !st.needed(i) {
+			have[i] = true
+			continue
+		}
+		data, err := st.ReadPiece(i)
+		if errors.Is(err, errNotStored) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		have[i] = sha1.Sum(data) == st.mi.Pieces[i]
+	}
+	return newPicker(have), nil
+}
+
+// needed returns true if the piece has data of a selected file.
+func (st *storage) needed(piece int) bool {
+	start := int64(piece) * st.mi.PieceLength
+	for i, file := range st.mi.Files {
+		if st.files[i] != nil && file.Offset < start+st.mi.PieceSize(piece) && file.Offset+file.Length > start {
+			return true
+		}
+	}
+	return false
+}
+
+// filesIn returns the files with data in [offset, offset+size).
+func (st *storage) filesIn(offset, size int64) []File {
+	var files []File
+	for _, file := range st.mi.Files {
+		if file.Offset < offset+size && file.Offset+file.Length > offset {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func (st *storage) ReadPiece(piece int) ([]byte, error) {
+	start := int64(piece) * st.mi.PieceLength
+	data := make([]byte, st.mi.PieceSize(piece))
+	for i, file := range st.mi.Files {
+		from := max(start, file.Offset)
+		to := min(start+int64(len(data)), file.Offset+file.Length)
+		if from >= to {
+			continue
+		}
+		if st.files[i] == nil {
+			return nil, errNotStored
+		}
+		if _, err := st.files[i].ReadAt(data[from-start:to-start], from-file.Offset); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// WritePiece writes the data of the piece to the selected files.
+func (st *storage) WritePiece(piece int, data []byte) error {
+	start := int64(piece) * st.mi.PieceLength
+	for i, file := range st.mi.Files {
+		from := max(start, file.Offset)
+		to := min(start+int64(len(data)), file.Offset+file.Length)
+		if from >= to || st.files[i] == nil {
+			continue
+		}
+		if _, err := st.files[i].WriteAt(data[from-start:to-start], from-file.Offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *storage) Sync() error {
+	var errs []error
+	for _, f := range st.files {
+		if f != nil {
+			errs = append(errs, f.Sync())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (st *storage) Close() error {
+	var errs []error
+	for _, f := range st.files {
+		if f != nil {
+			errs = append(errs, f.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
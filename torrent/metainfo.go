@@ -0,0 +1,256 @@
+// Package torrent is a minimal BitTorrent client to download the files of a torrent: it gets
+// the peers from HTTP trackers, downloads the pieces from them (and from the web seeds of the
+// torrent, see BEP 19), and checks every piece against its SHA1 before writing it.
+// It only downloads: it doesn't accept connections and doesn't upload.
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// File is a file of a torrent.
+type File struct {
+	// Path is the path of the file, relative to the directory of the torrent.
+	Path   string
+	Length int64
+	// Offset is the offset of the file in the data of the torrent (the concatenation of its files).
+	Offset int64
+}
+
+// MetaInfo is the content of a .torrent file.
+type MetaInfo struct {
+	// Trackers are the announce URLs, by tier.
+	Trackers []string
+	// WebSeeds are the URLs of HTTP servers that serve the files (BEP 19).
+	WebSeeds    []string
+	InfoHash    [20]byte
+	Name        string
+	PieceLength int64
+	Pieces      [][20]byte
+	Files       []File
+	// MultiFile is true if the files are in a directory named Name (for the web seeds).
+	MultiFile bool
+}
+
+// TotalLength returns the size of the data of the torrent.
+func (m *MetaInfo) TotalLength() int64 {
+	var total int64
+	for _, file := range m.Files {
+		total += file.Length
+	}
+	return total
+}
+
+// PieceSize returns the size of the piece; the last one can be shorter.
+func (m *MetaInfo) PieceSize(piece int) int64 {
+	return min(m.PieceLength, m.TotalLength()-int64(piece)*m.PieceLength)
+}
+
+// ReadMetaInfo reads a .torrent file.
+func ReadMetaInfo(path string) (*MetaInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMetaInfo(data)
+}
+
+// ParseMetaInfo parses the content of a .torrent file.
+func ParseMetaInfo(data []byte) (*MetaInfo, error) {
+	value, rawInfo, err := decodeBencode(data)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := value.(map[string]any)
+	if !ok {
+		return nil, errors.New("the torrent is not a dictionary")
+	}
+	info, ok := root["info"].(map[string]any)
+	if !ok {
+		return nil, errors.New("the torrent has no info dictionary")
+	}
+	m := &MetaInfo{InfoHash: sha1.Sum(rawInfo)}
+
+	seen := make(map[string]bool)
+	addTracker := func(v any) {
+		if s, ok := v.(string); ok && s != "" && !seen[s] {
+			seen[s] = true
+			m.Trackers = append(m.Trackers, s)
+		}
+	}
+	if tiers, ok := root["announce-list"].([]any); ok {
+		for _, tier := range tiers {
+			if urls, ok := tier.([]any); ok {
+				for _, u := range urls {
+					addTracker(u)
+				}
+			}
+		}
+	}
+	addTracker(root["announce"])
+	switch urls := root["url-list"].(type) {
+	case string:
+		m.WebSeeds = append(m.WebSeeds, urls)
+	case []any:
+		for _, u := range urls {
+			if s, ok := u.(string); ok && s != "" {
+				m.WebSeeds = append(m.WebSeeds, s)
+			}
+		}
+	}
+
+	if m.Name, ok = info["name"].(string); !ok || !isValidPathElement(m.Name) {
+		return nil, fmt.Errorf("invalid name %q", info["name"])
+	}
+	if m.PieceLength, ok = info["piece length"].(int64); !ok || m.PieceLength <= 0 {
+		return nil, errors.New("invalid piece length")
+	}
+	pieces, ok := info["pieces"].(string)
+	if !ok || len(pieces)%20 != 0 {
+		return nil, errors.New("invalid pieces")
+	}
+	for i := 0; i < len(pieces); i += 20 {
+		var hash [20]byte
+		copy(hash[:], pieces[i:])
+		m.Pieces = append(m.Pieces, hash)
+	}
+	if length, ok := info["length"].(int64); ok {
+		if length < 0 {
+			return nil, errors.New("invalid length")
+		}
+		m.Files = []File{{Path: m.Name, Length: length}}
+	} else {
+		files, ok := info["files"].([]any)
+		if !ok || len(files) == 0 {
+			return nil, errors.New("the torrent has neither a length nor files")
+		}
+		m.MultiFile = true
+		var offset int64
+		for i, f := range files {
+			file, ok := f.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid file %d", i)
+			}
+			length, ok := file["length"].(int64)
+			if !ok || length < 0 {
+				return nil, fmt.Errorf("invalid length of file %d", i)
+			}
+			elements, ok := file["path"].([]any)
+			if !ok || len(elements) == 0 {
+				return nil, fmt.Errorf("invalid path of file %d", i)
+			}
+			path := make([]string, 0, len(elements))
+			for _, element := range elements {
+				s, ok := element.(string)
+				if !ok || !isValidPathElement(s) {
+					return nil, fmt.Errorf("invalid path of file %d", i)
+				}
+				path = append(path, s)
+			}
+			m.Files = append(m.Files, File{Path: strings.Join(path, "/"), Length: length, Offset: offset})
+			offset += length
+		}
+	}
+	if numPieces := (m.TotalLength() + m.PieceLength - 1) / m.PieceLength; int64(len(m.Pieces)) != numPieces {
+		return nil, fmt.Errorf("the torrent has %d pieces, expected %d", len(m.Pieces), numPieces)
+	}
+	return m, nil
+}
+
+func isValidPathElement(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, "/\\\x00")
+}
+
+// Create returns a .torrent file with the files of dir (a multi-file torrent named name).
+func Create(dir string, name string, pieceLength int64, trackers []string, webSeeds []string) ([]byte, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var files []any
+	var pieces bytes.Buffer
+	piece := make([]byte, 0, pieceLength)
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		var length int64
+		for {
+			n, err := io.ReadFull(file, piece[len(piece):cap(piece)])
+			piece = piece[:len(piece)+n]
+			length += int64(n)
+			if len(piece) == cap(piece) {
+				sum := sha1.Sum(piece)
+				pieces.Write(sum[:])
+				piece = piece[:0]
+			}
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+		file.Close()
+		files = append(files, map[string]any{
+			"length": length,
+			"path":   strings.Split(filepath.ToSlash(rel), "/"),
+		})
+	}
+	if len(piece) > 0 {
+		sum := sha1.Sum(piece)
+		pieces.Write(sum[:])
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s has no files", dir)
+	}
+	root := map[string]any{
+		"info": map[string]any{
+			"name":         name,
+			"piece length": pieceLength,
+			"pieces":       pieces.Bytes(),
+			"files":        files,
+		},
+	}
+	if len(trackers) > 0 {
+		root["announce"] = trackers[0]
+		tiers := make([]any, 0, len(trackers))
+		for _, tracker := range trackers {
+			tiers = append(tiers, []string{tracker})
+		}
+		root["announce-list"] = tiers
+	}
+	if len(webSeeds) > 0 {
+		root["url-list"] = webSeeds
+	}
+	var buf bytes.Buffer
+	if err := encodeBencode(&buf, root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
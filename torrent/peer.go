@@ -0,0 +1,227 @@
+package torrent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"time"
+)
+
+const (
+	msgChoke         = 0
+	msgUnchoke       = 1
+	msgInterested    = 2
+	msgNotInterested = 3
+	msgHave          = 4
+	msgBitfield      = 5
+	msgRequest       = 6
+	msgPiece         = 7
+	msgCancel        = 8
+)
+
+const (
+	protocolName = "BitTorrent protocol"
+	// blockSize is the size of the requests sent to the peers.
+	blockSize = 16 << 10
+	// maxPipelined is the number of requests sent to a peer without waiting for the blocks.
+	maxPipelined = 8
+	// maxMessageSize bounds the messages read from the peers.
+	maxMessageSize = 4 << 20
+	peerTimeout    = 30 * time.Second
+	// pieceTimeout bounds the download of a piece from a peer.
+	pieceTimeout = 2 * time.Minute
+)
+
+// peerConn is a connection to a peer.
+type peerConn struct {
+	addr     netip.AddrPort
+	conn     net.Conn
+	r        *bufio.Reader
+	choked   bool
+	bitfield []byte
+	// interested is true once we told the peer that we want its pieces.
+	interested bool
+}
+
+// dialPeer connects to the peer and does the handshake.
+func dialPeer(ctx context.Context, addr netip.AddrPort, infoHash [20]byte, peerID [20]byte, numPieces int, wrapReader func(io.Reader) io.Reader) (*peerConn, error) {
+	dialer := net.Dialer{Timeout: peerTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr.String())
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader = conn
+	if wrapReader != nil {
+		r = wrapReader(r)
+	}
+	p := &peerConn{
+		addr:     addr,
+		conn:     conn,
+		r:        bufio.NewReaderSize(r, 64<<10),
+		choked:   true,
+		bitfield: make([]byte, (numPieces+7)/8),
+	}
+	if err := p.handshake(infoHash, peerID); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("peer %s: %w", addr, err)
+	}
+	return p, nil
+}
+
+func (p *peerConn) handshake(infoHash [20]byte, peerID [20]byte) error {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(len(protocolName)))
+	buf.WriteString(protocolName)
+	buf.Write(make([]byte, 8))
+	buf.Write(infoHash[:])
+	buf.Write(peerID[:])
+	p.conn.SetDeadline(time.Now().Add(peerTimeout))
+	if _, err := p.conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	reply := make([]byte, buf.Len())
+	if _, err := io.ReadFull(p.r, reply); err != nil {
+		return err
+	}
+	if !bytes.Equal(reply[:1+len(protocolName)], buf.Bytes()[:1+len(protocolName)]) {
+		return errors.New("not a BitTorrent peer")
+	}
+	if !bytes.Equal(reply[28:48], infoHash[:]) {
+		return errors.New("wrong info hash")
+	}
+	return nil
+}
+
+func (p *peerConn) Close() error {
+	return p.conn.Close()
+}
+
+// has returns true if the peer announced the piece.
+func (p *peerConn) has(piece int) bool {
+	return p.bitfield[piece/8]&(0x80>>(piece%8)) != 0
+}
+
+func (p *peerConn) send(id byte, payload ...uint32) error {
+	msg := make([]byte, 5+4*len(payload))
+	binary.BigEndian.PutUint32(msg, uint32(1+4*len(payload)))
+	msg[4] = id
+	for i, v := range payload {
+		binary.BigEndian.PutUint32(msg[5+4*i:], v)
+	}
+	p.conn.SetWriteDeadline(time.Now().Add(peerTimeout))
+	_, err := p.conn.Write(msg)
+	return err
+}
+
+// readMessage reads the next message, and updates the state of the peer; it returns the
+// message and its payload. Keepalives are skipped.
+func (p *peerConn) readMessage() (byte, []byte, error) {
+	for {
+		p.conn.SetReadDeadline(time.Now().Add(peerTimeout))
+		var header [4]byte
+		if _, err := io.ReadFull(p.r, header[:]); err != nil {
+			return 0, nil, err
+		}
+		size := binary.BigEndian.Uint32(header[:])
+		if size == 0 {
+			continue
+		}
+		if size > maxMessageSize {
+			return 0, nil, fmt.Errorf("message of %d bytes", size)
+		}
+		msg := make([]byte, size)
+		if _, err := io.ReadFull(p.r, msg); err != nil {
+			return 0, nil, err
+		}
+		id, payload := msg[0], msg[1:]
+		switch id {
+		case msgChoke:
+			p.choked = true
+		case msgUnchoke:
+			p.choked = false
+		case msgHave:
+			if len(payload) != 4 {
+				return 0, nil, errors.New("invalid have message")
+			}
+			piece := binary.BigEndian.Uint32(payload)
+			if int(piece/8) >= len(p.bitfield) {
+				return 0, nil, fmt.Errorf("have message for piece %d", piece)
+			}
+			p.bitfield[piece/8] |= 0x80 >> (piece % 8)
+		case msgBitfield:
+			if len(payload) != len(p.bitfield) {
+				return 0, nil, errors.New("invalid bitfield")
+			}
+			copy(p.bitfield, payload)
+		}
+		return id, payload, nil
+	}
+}
+
+// downloadPiece downloads the piece from the peer; the caller checks its hash.
+func (p *peerConn) downloadPiece(piece int, size int64) ([]byte, error) {
+	if !p.interested {
+		if err := p.send(msgInterested); err != nil {
+			return nil, err
+		}
+		p.interested = true
+	}
+	data := make([]byte, size)
+	received := make([]bool, (size+blockSize-1)/blockSize)
+	remaining := len(received)
+	next := 0
+	pending := 0
+	started := time.Now()
+	for remaining > 0 {
+		if time.Since(started) > pieceTimeout {
+			return nil, fmt.Errorf("piece %d: timeout", piece)
+		}
+		for !p.choked && pending < maxPipelined {
+			for next < len(received) && received[next] {
+				next++
+			}
+			if next == len(received) {
+				break
+			}
+			begin := int64(next) * blockSize
+			length := min(blockSize, size-begin)
+			if err := p.send(msgRequest, uint32(piece), uint32(begin), uint32(length)); err != nil {
+				return nil, err
+			}
+			next++
+			pending++
+		}
+		id, payload, err := p.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch id {
+		case msgChoke:
+			// the peer drops the pending requests: they are sent again once it unchokes us.
+			next, pending = 0, 0
+		case msgPiece:
+			if len(payload) < 8 {
+				return nil, errors.New("invalid piece message")
+			}
+			index := binary.BigEndian.Uint32(payload)
+			begin := int64(binary.BigEndian.Uint32(payload[4:]))
+			block := payload[8:]
+			if int(index) != piece || begin%blockSize != 0 || begin+int64(len(block)) > size {
+				continue
+			}
+			if i := begin / blockSize; !received[i] && int64(len(block)) == min(blockSize, size-begin) {
+				copy(data[begin:], block)
+				received[i] = true
+				remaining--
+				pending = max(pending-1, 0)
+			}
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,229 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBencode(t *testing.T) {
+	value := map[string]any{
+		"a":    int64(-3),
+		"info": map[string]any{"name": "x", "list": []any{"y", int64(1)}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, encodeBencode(&buf, value))
+	require.Equal(t, "d1:ai-3e4:infod4:listl1:yi1ee4:name1:xee", buf.String())
+	decoded, rawInfo, err := decodeBencode(buf.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"a":    int64(-3),
+		"info": map[string]any{"name": "x", "list": []any{"y", int64(1)}},
+	}, decoded)
+	require.Equal(t, "d4:listl1:yi1ee4:name1:xe", string(rawInfo))
+
+	for _, invalid := range []string{"", "i12", "5:abc", "l", "d1:a", "x", "i1ei2e", "d1:ai1e", nested("l") + nested("e")} {
+		_, _, err := decodeBencode([]byte(invalid))
+		require.ErrorIs(t, err, ErrInvalidBencode, invalid)
+	}
+}
+
+// nested returns s repeated past maxBencodeDepth.
+func nested(s string) string {
+	return string(bytes.Repeat([]byte(s), maxBencodeDepth+2))
+}
+
+// writeFiles writes random files in dir, and returns their content.
+func writeFiles(t *testing.T, dir string, sizes map[string]int) map[string][]byte {
+	files := make(map[string][]byte)
+	for name, size := range sizes {
+		data := make([]byte, size)
+		_, err := rand.Read(data)
+		require.NoError(t, err)
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		files[name] = data
+	}
+	return files
+}
+
+func requireFiles(t *testing.T, dir string, files map[string][]byte) {
+	for name, data := range files {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		require.Equal(t, data, got, name)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]int{"epoch-0.car": 100_000, "epoch-0.attestation.json": 500, "gsfa/a": 10})
+	data, err := Create(dir, "epoch-0", 32<<10, []string{"http://tracker/announce"}, []string{"http://seed/"})
+	require.NoError(t, err)
+	mi, err := ParseMetaInfo(data)
+	require.NoError(t, err)
+	require.Equal(t, "epoch-0", mi.Name)
+	require.True(t, mi.MultiFile)
+	require.Equal(t, []string{"http://tracker/announce"}, mi.Trackers)
+	require.Equal(t, []string{"http://seed/"}, mi.WebSeeds)
+	require.Equal(t, []File{
+		{Path: "epoch-0.attestation.json", Length: 500},
+		{Path: "epoch-0.car", Length: 100_000, Offset: 500},
+		{Path: "gsfa/a", Length: 10, Offset: 100_500},
+	}, mi.Files)
+	require.Len(t, mi.Pieces, 4)
+	require.Equal(t, int64(100_510-3*(32<<10)), mi.PieceSize(3))
+
+	// the path of a file can't leave the directory.
+	evil := bytes.Replace(data, []byte("4:gsfa"), []byte("2:.."), 1)
+	_, err = ParseMetaInfo(evil)
+	require.ErrorContains(t, err, "invalid path")
+}
+
+func TestDownload_WebSeed(t *testing.T) {
+	published := t.TempDir()
+	files := writeFiles(t, filepath.Join(published, "epoch-0"), map[string]int{"epoch-0.car": 100_000, "epoch-0.index": 5000, "sub/x": 1})
+	data, err := Create(filepath.Join(published, "epoch-0"), "epoch-0", 16<<10, nil, nil)
+	require.NoError(t, err)
+	server := httptest.NewServer(http.FileServer(http.Dir(published)))
+	defer server.Close()
+	mi, err := ParseMetaInfo(data)
+	require.NoError(t, err)
+	mi.WebSeeds = []string{server.URL}
+
+	dest := t.TempDir()
+	require.NoError(t, Download(context.Background(), mi, dest, nil))
+	requireFiles(t, dest, files)
+
+	// a corrupted piece is downloaded again.
+	corrupted := append([]byte{}, files["epoch-0.car"]...)
+	corrupted[50_000]++
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "epoch-0.car"), corrupted, 0o644))
+	require.NoError(t, Download(context.Background(), mi, dest, nil))
+	requireFiles(t, dest, files)
+
+	// only the selected files.
+	selected := t.TempDir()
+	require.NoError(t, Download(context.Background(), mi, selected, &Options{Files: []string{"epoch-0.index"}}))
+	requireFiles(t, selected, map[string][]byte{"epoch-0.index": files["epoch-0.index"]})
+	require.NoFileExists(t, filepath.Join(selected, "epoch-0.car"))
+	require.ErrorContains(t, Download(context.Background(), mi, selected, &Options{Files: []string{"missing"}}), `no file "missing"`)
+
+	// a web seed that serves wrong data makes no progress.
+	require.NoError(t, os.WriteFile(filepath.Join(published, "epoch-0", "epoch-0.car"), corrupted, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "epoch-0.car"), corrupted, 0o644))
+	err = Download(context.Background(), mi, dest, &Options{StallTimeout: 500 * time.Millisecond})
+	require.ErrorContains(t, err, "no piece downloaded")
+}
+
+// seed serves the pieces of the torrent to the peers that connect to the listener.
+func seed(listener net.Listener, mi *MetaInfo, st *storage) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			handshake := make([]byte, 68)
+			if _, err := io.ReadFull(conn, handshake); err != nil {
+				return
+			}
+			copy(handshake[48:], "-SEED-00000000000000")
+			conn.Write(handshake)
+			bitfield := make([]byte, (len(mi.Pieces)+7)/8)
+			for i := range mi.Pieces {
+				bitfield[i/8] |= 0x80 >> (i % 8)
+			}
+			writeMessage(conn, msgBitfield, bitfield)
+			for {
+				var header [4]byte
+				if _, err := io.ReadFull(conn, header[:]); err != nil {
+					return
+				}
+				msg := make([]byte, binary.BigEndian.Uint32(header[:]))
+				if _, err := io.ReadFull(conn, msg); err != nil {
+					return
+				}
+				switch msg[0] {
+				case msgInterested:
+					// choke first, to check that the requests are sent again.
+					writeMessage(conn, msgUnchoke, nil)
+					writeMessage(conn, msgChoke, nil)
+					writeMessage(conn, msgUnchoke, nil)
+				case msgRequest:
+					index := binary.BigEndian.Uint32(msg[1:])
+					begin := binary.BigEndian.Uint32(msg[5:])
+					length := binary.BigEndian.Uint32(msg[9:])
+					piece, err := st.ReadPiece(int(index))
+					if err != nil {
+						return
+					}
+					writeMessage(conn, msgPiece, append(binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, index), begin), piece[begin:begin+length]...))
+				}
+			}
+		}()
+	}
+}
+
+func writeMessage(w io.Writer, id byte, payload []byte) {
+	msg := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)))
+	msg = append(msg, id)
+	w.Write(append(msg, payload...))
+}
+
+func TestDownload_Peers(t *testing.T) {
+	published := t.TempDir()
+	files := writeFiles(t, published, map[string]int{"epoch-0.car": 200_000, "epoch-0.index": 30_000})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+	tracker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		peer := append([]byte{127, 0, 0, 1}, byte(port>>8), byte(port))
+		encodeBencode(&buf, map[string]any{"interval": int64(60), "peers": peer})
+		w.Write(buf.Bytes())
+	}))
+	defer tracker.Close()
+	data, err := Create(published, "epoch-0", 64<<10, []string{tracker.URL + "/announce", "udp://tracker.invalid:80"}, nil)
+	require.NoError(t, err)
+	mi, err := ParseMetaInfo(data)
+	require.NoError(t, err)
+	st, err := openStorage(mi, published, nil)
+	require.NoError(t, err)
+	defer st.Close()
+	go seed(listener, mi, st)
+
+	dest := t.TempDir()
+	var read int64
+	err = Download(context.Background(), mi, dest, &Options{
+		WrapReader: func(r io.Reader) io.Reader { return &countingReader{r: r, n: &read} },
+	})
+	require.NoError(t, err)
+	requireFiles(t, dest, files)
+	require.Greater(t, read, int64(230_000))
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
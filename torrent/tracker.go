@@ -0,0 +1,112 @@
+package torrent
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// announce asks an HTTP tracker for the peers of the torrent; it returns them and the interval
+// before the next announce.
+func announce(ctx context.Context, client *http.Client, tracker string, infoHash [20]byte, peerID [20]byte, left int64) ([]netip.AddrPort, time.Duration, error) {
+	if !strings.HasPrefix(tracker, "http://") && !strings.HasPrefix(tracker, "https://") {
+		return nil, 0, fmt.Errorf("unsupported tracker %q: only HTTP trackers are supported", tracker)
+	}
+	params := url.Values{
+		"info_hash":  {string(infoHash[:])},
+		"peer_id":    {string(peerID[:])},
+		"port":       {"6881"},
+		"uploaded":   {"0"},
+		"downloaded": {"0"},
+		"left":       {strconv.FormatInt(left, 10)},
+		"compact":    {"1"},
+		"event":      {"started"},
+	}
+	sep := "?"
+	if strings.Contains(tracker, "?") {
+		sep = "&"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tracker+sep+params.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("tracker %s: %s", tracker, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, 0, err
+	}
+	value, _, err := decodeBencode(body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("tracker %s: %w", tracker, err)
+	}
+	dict, ok := value.(map[string]any)
+	if !ok {
+		return nil, 0, fmt.Errorf("tracker %s: invalid response", tracker)
+	}
+	if reason, ok := dict["failure reason"].(string); ok {
+		return nil, 0, fmt.Errorf("tracker %s: %s", tracker, reason)
+	}
+	interval := 30 * time.Minute
+	if seconds, ok := dict["interval"].(int64); ok && seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+	peers, err := parsePeers(dict["peers"], 6)
+	if err != nil {
+		return nil, 0, fmt.Errorf("tracker %s: %w", tracker, err)
+	}
+	peers6, err := parsePeers(dict["peers6"], 18)
+	if err != nil {
+		return nil, 0, fmt.Errorf("tracker %s: %w", tracker, err)
+	}
+	return append(peers, peers6...), interval, nil
+}
+
+// parsePeers parses the peers of a tracker response: either compact (the address and the port,
+// addrSize bytes in total per peer) or a list of dictionaries.
+func parsePeers(v any, addrSize int) ([]netip.AddrPort, error) {
+	var peers []netip.AddrPort
+	switch v := v.(type) {
+	case nil:
+	case string:
+		if len(v)%addrSize != 0 {
+			return nil, errors.New("invalid compact peers")
+		}
+		for i := 0; i < len(v); i += addrSize {
+			addr, _ := netip.AddrFromSlice([]byte(v[i : i+addrSize-2]))
+			port := binary.BigEndian.Uint16([]byte(v[i+addrSize-2 : i+addrSize]))
+			peers = append(peers, netip.AddrPortFrom(addr, port))
+		}
+	case []any:
+		for _, p := range v {
+			peer, ok := p.(map[string]any)
+			if !ok {
+				continue
+			}
+			ip, _ := peer["ip"].(string)
+			port, _ := peer["port"].(int64)
+			addr, err := netip.ParseAddr(ip)
+			if err != nil || port <= 0 || port > 65535 {
+				continue
+			}
+			peers = append(peers, netip.AddrPortFrom(addr, uint16(port)))
+		}
+	default:
+		return nil, errors.New("invalid peers")
+	}
+	return peers, nil
+}
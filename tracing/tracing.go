@@ -0,0 +1,99 @@
+// Package tracing wires optional OpenTelemetry tracing.
+//
+// Tracing is off unless one of the standard OTEL environment variables asks for it:
+// OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or OTEL_TRACES_EXPORTER=otlp.
+// All the other OTEL_* variables (headers, sampler, service name, resource attributes, ...)
+// are honored by the SDK and the OTLP exporter.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/rpcpool/yellowstone-faithful"
+	defaultServiceName  = "old-faithful"
+)
+
+// IsEnabledByEnv returns true if the environment asks for traces to be exported.
+func IsEnabledByEnv() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		return true
+	}
+	return strings.EqualFold(os.Getenv("OTEL_TRACES_EXPORTER"), "otlp")
+}
+
+// Init installs the global tracer provider and propagator if tracing is enabled by the environment.
+// The returned function flushes and stops the exporter; it is never nil.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if !IsEnabledByEnv() {
+		return noop, nil
+	}
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return noop, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName(defaultServiceName)),
+		resource.Default(),
+	)
+	if err != nil {
+		return noop, fmt.Errorf("failed to create resource: %w", err)
+	}
+	res, err = resource.Merge(res, resource.Environment())
+	if err != nil {
+		return noop, fmt.Errorf("failed to create resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer used for all the spans of this module.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span; it's a no-op span if tracing is not enabled.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error (if any) on the span, and ends it.
+// Use it as `defer func() { tracing.End(span, err) }()` with a named error return.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Extract returns a context carrying the remote span context found in the carrier (e.g. HTTP headers, gRPC metadata).
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
@@ -0,0 +1,7 @@
+//go:build !poolguard
+// +build !poolguard
+
+package trackedpool
+
+// guardBuildTag is true when built with `-tags poolguard`.
+const guardBuildTag = false
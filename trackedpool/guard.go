@@ -0,0 +1,83 @@
+package trackedpool
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+var guardEnabled atomic.Bool
+
+func init() {
+	switch strings.ToLower(os.Getenv("FAITHFUL_POOL_GUARD")) {
+	case "1", "true", "yes", "on":
+		guardEnabled.Store(true)
+	}
+	if guardBuildTag {
+		guardEnabled.Store(true)
+	}
+}
+
+// SetGuard enables or disables the use-after-Put guards.
+func SetGuard(enabled bool) {
+	guardEnabled.Store(enabled)
+}
+
+// IsGuard returns true if the use-after-Put guards are enabled
+// (built with `-tags poolguard`, or FAITHFUL_POOL_GUARD=1).
+func IsGuard() bool {
+	return guardEnabled.Load()
+}
+
+// Guard is embedded in pooled objects to detect use-after-Put.
+// Every time the object is returned to its pool the generation is bumped,
+// so a holder that kept a reference (and the generation it got it at) can
+// detect that the object was recycled under its feet, and panic with a clear message
+// instead of silently reading or writing data that now belongs to someone else.
+//
+// When the guards are disabled all the methods are no-ops.
+type Guard struct {
+	generation atomic.Uint64
+	released   atomic.Bool
+}
+
+// Acquire marks the object as in use, and returns the current generation.
+func (g *Guard) Acquire() uint64 {
+	if !IsGuard() {
+		return 0
+	}
+	g.released.Store(false)
+	return g.generation.Load()
+}
+
+// Release marks the object as returned to the pool, and bumps the generation.
+// It panics if the object was already released (double Put).
+func (g *Guard) Release(name string) {
+	if !IsGuard() {
+		return
+	}
+	if !g.released.CompareAndSwap(false, true) {
+		panic(fmt.Sprintf("trackedpool: %s: double Put (generation %d)", name, g.generation.Load()))
+	}
+	g.generation.Add(1)
+}
+
+// Check panics if the object was returned to the pool, or if it was recycled
+// since the holder acquired it at the given generation.
+func (g *Guard) Check(name string, acquiredAt uint64) {
+	if !IsGuard() {
+		return
+	}
+	if g.released.Load() {
+		panic(fmt.Sprintf("trackedpool: %s: use after Put (generation %d)", name, g.generation.Load()))
+	}
+	if current := g.generation.Load(); current != acquiredAt {
+		panic(fmt.Sprintf("trackedpool: %s: use after Put: acquired at generation %d, now at generation %d", name, acquiredAt, current))
+	}
+}
+
+// Generation returns the current generation.
+func (g *Guard) Generation() uint64 {
+	return g.generation.Load()
+}
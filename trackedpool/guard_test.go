@@ -0,0 +1,37 @@
+package trackedpool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuard(t *testing.T) {
+	SetGuard(true)
+	defer SetGuard(guardBuildTag)
+
+	var g Guard
+	gen := g.Acquire()
+	require.NotPanics(t, func() { g.Check("test", gen) })
+
+	g.Release("test")
+	require.PanicsWithValue(t, "trackedpool: test: use after Put (generation 1)", func() { g.Check("test", gen) })
+	require.PanicsWithValue(t, "trackedpool: test: double Put (generation 1)", func() { g.Release("test") })
+
+	// Recycled: a new holder got it, and the old holder must not be able to use it.
+	newGen := g.Acquire()
+	require.NotPanics(t, func() { g.Check("test", newGen) })
+	require.PanicsWithValue(t, "trackedpool: test: use after Put: acquired at generation 0, now at generation 1", func() { g.Check("test", gen) })
+}
+
+func TestGuardDisabled(t *testing.T) {
+	if guardBuildTag {
+		t.Skip("built with -tags poolguard")
+	}
+	SetGuard(false)
+	var g Guard
+	gen := g.Acquire()
+	g.Release("test")
+	require.NotPanics(t, func() { g.Check("test", gen) })
+	require.NotPanics(t, func() { g.Release("test") })
+}
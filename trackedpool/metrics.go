@@ -0,0 +1,57 @@
+package trackedpool
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	descGets = prometheus.NewDesc(
+		"pool_gets_total",
+		"Number of objects acquired from the pool",
+		[]string{"pool"}, nil,
+	)
+	descPuts = prometheus.NewDesc(
+		"pool_puts_total",
+		"Number of objects returned to the pool",
+		[]string{"pool"}, nil,
+	)
+	descNews = prometheus.NewDesc(
+		"pool_news_total",
+		"Number of objects allocated because the pool was empty",
+		[]string{"pool"}, nil,
+	)
+	descOutstanding = prometheus.NewDesc(
+		"pool_outstanding",
+		"Number of objects acquired from the pool and not returned yet",
+		[]string{"pool"}, nil,
+	)
+	descDoublePuts = prometheus.NewDesc(
+		"pool_double_puts_total",
+		"Number of objects returned to the pool while not outstanding (debug mode only)",
+		[]string{"pool"}, nil,
+	)
+)
+
+type collector struct{}
+
+func (collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descGets
+	ch <- descPuts
+	ch <- descNews
+	ch <- descOutstanding
+	ch <- descDoublePuts
+}
+
+func (collector) Collect(ch chan<- prometheus.Metric) {
+	for _, st := range AllStats() {
+		ch <- prometheus.MustNewConstMetric(descGets, prometheus.CounterValue, float64(st.Gets), st.Name)
+		ch <- prometheus.MustNewConstMetric(descPuts, prometheus.CounterValue, float64(st.Puts), st.Name)
+		ch <- prometheus.MustNewConstMetric(descNews, prometheus.CounterValue, float64(st.News), st.Name)
+		ch <- prometheus.MustNewConstMetric(descOutstanding, prometheus.GaugeValue, float64(st.Outstanding), st.Name)
+		ch <- prometheus.MustNewConstMetric(descDoublePuts, prometheus.CounterValue, float64(st.DoublePuts), st.Name)
+	}
+}
+
+func init() {
+	prometheus.MustRegister(collector{})
+}
@@ -0,0 +1,233 @@
+// Package trackedpool is a sync.Pool wrapper that keeps statistics about its usage,
+// and that in debug mode tracks every outstanding object with the stack trace of where it was acquired.
+//
+// Debug mode is meant for diagnosing leaked objects and double-Put bugs, which otherwise
+// only surface as corrupted data. It is enabled with the FAITHFUL_POOL_DEBUG=1 environment variable,
+// or with SetDebug(true); it's expensive (a stack trace per Get), so keep it off in production.
+package trackedpool
+
+import (
+	"expvar"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+var debug atomic.Bool
+
+func init() {
+	switch strings.ToLower(os.Getenv("FAITHFUL_POOL_DEBUG")) {
+	case "1", "true", "yes", "on":
+		debug.Store(true)
+	}
+	expvar.Publish("pools", expvar.Func(func() any {
+		return AllStats()
+	}))
+}
+
+// SetDebug enables or disables debug mode for all the pools.
+// Objects acquired while debug mode was off are not tracked.
+func SetDebug(enabled bool) {
+	debug.Store(enabled)
+}
+
+// IsDebug returns true if debug mode is enabled.
+func IsDebug() bool {
+	return debug.Load()
+}
+
+// Stats is a snapshot of the usage of a pool.
+type Stats struct {
+	Name string `json:"name"`
+	// Gets is the number of Get calls.
+	Gets uint64 `json:"gets"`
+	// Puts is the number of Put calls.
+	Puts uint64 `json:"puts"`
+	// News is the number of objects that were allocated because the pool was empty.
+	News uint64 `json:"news"`
+	// Outstanding is Gets minus Puts; a number that keeps growing means objects are leaking.
+	Outstanding int64 `json:"outstanding"`
+	// DoublePuts is the number of Put calls for an object that was not outstanding (debug mode only).
+	DoublePuts uint64 `json:"double_puts"`
+}
+
+// Acquisition describes an outstanding object (debug mode only).
+type Acquisition struct {
+	At    time.Time `json:"at"`
+	Stack string    `json:"stack"`
+}
+
+type statsProvider interface {
+	Stats() Stats
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]statsProvider)
+)
+
+// AllStats returns the stats of all the pools, sorted by name.
+func AllStats() []Stats {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	out := make([]Stats, 0, len(registry))
+	for _, p := range registry {
+		out = append(out, p.Stats())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Pool is a typed sync.Pool with usage statistics and optional leak tracking.
+type Pool[T any] struct {
+	name  string
+	pool  sync.Pool
+	key   func(T) uintptr
+	reset func(T) T
+
+	gets       atomic.Uint64
+	puts       atomic.Uint64
+	news       atomic.Uint64
+	doublePuts atomic.Uint64
+
+	mu          sync.Mutex
+	outstanding map[uintptr]Acquisition
+}
+
+// New creates a pool and registers it under the given name (which must be unique).
+// newFn allocates a new object; key returns the identity of an object (e.g. its pointer),
+// and is used to track outstanding objects in debug mode; reset (optional) is called on Put.
+func New[T any](name string, newFn func() T, key func(T) uintptr, reset func(T) T) *Pool[T] {
+	p := &Pool[T]{
+		name:        name,
+		key:         key,
+		reset:       reset,
+		outstanding: make(map[uintptr]Acquisition),
+	}
+	p.pool.New = func() any {
+		p.news.Add(1)
+		return newFn()
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("trackedpool: pool %q already registered", name))
+	}
+	registry[name] = p
+	return p
+}
+
+// Name returns the name of the pool.
+func (p *Pool[T]) Name() string {
+	return p.name
+}
+
+// Get returns an object from the pool.
+func (p *Pool[T]) Get() T {
+	obj := p.pool.Get().(T)
+	p.gets.Add(1)
+	if IsDebug() {
+		p.mu.Lock()
+		p.outstanding[p.key(obj)] = Acquisition{
+			At:    time.Now(),
+			Stack: callerStack(),
+		}
+		p.mu.Unlock()
+	}
+	return obj
+}
+
+// Put returns an object to the pool.
+// In debug mode, putting an object that is not outstanding is reported and the object is dropped,
+// so that it can't be handed out twice.
+func (p *Pool[T]) Put(obj T) {
+	p.puts.Add(1)
+	if IsDebug() {
+		k := p.key(obj)
+		p.mu.Lock()
+		_, ok := p.outstanding[k]
+		delete(p.outstanding, k)
+		p.mu.Unlock()
+		if !ok {
+			p.doublePuts.Add(1)
+			klog.Errorf("trackedpool: %s: Put of an object that is not outstanding (double Put, or acquired while debug was off):\n%s", p.name, callerStack())
+			return
+		}
+	}
+	if p.reset != nil {
+		obj = p.reset(obj)
+	}
+	p.pool.Put(obj)
+}
+
+// Replace moves the tracking of an outstanding object to another one;
+// use it when the object changes identity while in use (e.g. a slice that was grown by append).
+func (p *Pool[T]) Replace(old T, replacement T) {
+	if !IsDebug() {
+		return
+	}
+	oldKey, newKey := p.key(old), p.key(replacement)
+	if oldKey == newKey {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if acq, ok := p.outstanding[oldKey]; ok {
+		delete(p.outstanding, oldKey)
+		p.outstanding[newKey] = acq
+	}
+}
+
+// Stats returns a snapshot of the usage of the pool.
+func (p *Pool[T]) Stats() Stats {
+	gets := p.gets.Load()
+	puts := p.puts.Load()
+	return Stats{
+		Name:        p.name,
+		Gets:        gets,
+		Puts:        puts,
+		News:        p.news.Load(),
+		Outstanding: int64(gets) - int64(puts),
+		DoublePuts:  p.doublePuts.Load(),
+	}
+}
+
+// Outstanding returns the objects that were acquired (in debug mode) and not returned yet,
+// oldest first.
+func (p *Pool[T]) Outstanding() []Acquisition {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]Acquisition, 0, len(p.outstanding))
+	for _, acq := range p.outstanding {
+		out = append(out, acq)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].At.Before(out[j].At)
+	})
+	return out
+}
+
+func callerStack() string {
+	pcs := make([]uintptr, 32)
+	// skip runtime.Callers, callerStack, and the Pool method.
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var buf strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&buf, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return buf.String()
+}
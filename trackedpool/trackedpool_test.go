@@ -0,0 +1,91 @@
+package trackedpool
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+type object struct {
+	value int
+}
+
+func newTestPool(name string) *Pool[*object] {
+	return New(
+		name,
+		func() *object { return &object{} },
+		func(o *object) uintptr { return uintptr(unsafe.Pointer(o)) },
+		func(o *object) *object {
+			o.value = 0
+			return o
+		},
+	)
+}
+
+func TestStats(t *testing.T) {
+	SetDebug(false)
+	p := newTestPool("test-stats")
+	a := p.Get()
+	b := p.Get()
+	a.value = 1
+	p.Put(a)
+
+	st := p.Stats()
+	require.Equal(t, "test-stats", st.Name)
+	require.Equal(t, uint64(2), st.Gets)
+	require.Equal(t, uint64(1), st.Puts)
+	require.Equal(t, int64(1), st.Outstanding)
+	require.Equal(t, uint64(0), st.DoublePuts)
+	require.Empty(t, p.Outstanding())
+
+	p.Put(b)
+	require.Equal(t, int64(0), p.Stats().Outstanding)
+
+	found := false
+	for _, st := range AllStats() {
+		if st.Name == "test-stats" {
+			found = true
+		}
+	}
+	require.True(t, found)
+}
+
+func TestDebugTracksOutstandingAndDoublePut(t *testing.T) {
+	SetDebug(true)
+	defer SetDebug(false)
+	p := newTestPool("test-debug")
+
+	a := p.Get()
+	outstanding := p.Outstanding()
+	require.Len(t, outstanding, 1)
+	require.Contains(t, outstanding[0].Stack, "TestDebugTracksOutstandingAndDoublePut")
+
+	p.Put(a)
+	require.Empty(t, p.Outstanding())
+	require.Equal(t, uint64(0), p.Stats().DoublePuts)
+
+	// Putting it again must be detected, and the object must not go back into the pool.
+	p.Put(a)
+	require.Equal(t, uint64(1), p.Stats().DoublePuts)
+}
+
+func TestDuplicateNamePanics(t *testing.T) {
+	newTestPool("test-duplicate")
+	require.Panics(t, func() {
+		newTestPool("test-duplicate")
+	})
+}
+
+func TestReplace(t *testing.T) {
+	SetDebug(true)
+	defer SetDebug(false)
+	p := newTestPool("test-replace")
+
+	a := p.Get()
+	b := &object{}
+	p.Replace(a, b)
+	p.Put(b)
+	require.Empty(t, p.Outstanding())
+	require.Equal(t, uint64(0), p.Stats().DoublePuts)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/mr-tron/base58"
+	"github.com/rpcpool/yellowstone-faithful/carwriter"
+	"github.com/rpcpool/yellowstone-faithful/carwriter/bigtablesource"
+	solanaerrors "github.com/rpcpool/yellowstone-faithful/solana-errors"
+	"github.com/rpcpool/yellowstone-faithful/third_party/solana_proto/confirmed_block"
+	"github.com/rpcpool/yellowstone-faithful/tooling"
+	"github.com/ybbus/jsonrpc/v3"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+)
+
+// txMetaSource fetches the metadata of transactions from outside the CARs (see faithful-cli backfill-tx-meta).
+type txMetaSource interface {
+	// FetchMetas returns the metadata of the transactions of the slot that the source has, by signature,
+	// as stored in the CARs (zstd-compressed protobuf).
+	FetchMetas(ctx context.Context, slot uint64, sigs []solana.Signature) (map[solana.Signature][]byte, error)
+	Close() error
+}
+
+// rpcTxMetaSource fetches the metadata with the getTransaction method of a (archival) Solana RPC.
+type rpcTxMetaSource struct {
+	client jsonrpc.RPCClient
+}
+
+func newRPCTxMetaSource(url string) *rpcTxMetaSource {
+	return &rpcTxMetaSource{client: jsonrpc.NewClient(url)}
+}
+
+func (s *rpcTxMetaSource) FetchMetas(ctx context.Context, slot uint64, sigs []solana.Signature) (map[solana.Signature][]byte, error) {
+	metas := make(map[solana.Signature][]byte, len(sigs))
+	for _, sig := range sigs {
+		var result *rpcTransactionResult
+		err := s.client.CallFor(ctx, &result, "getTransaction", sig.String(), map[string]any{
+			"encoding":                       "base64",
+			"commitment":                     "finalized",
+			"maxSupportedTransactionVersion": 0,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transaction %s: %w", sig, err)
+		}
+		if result == nil || result.Meta == nil {
+			continue
+		}
+		if result.Slot != slot {
+			klog.Warningf("Transaction %s is at slot %d in the RPC, but at slot %d in the CAR; ignoring it", sig, result.Slot, slot)
+			continue
+		}
+		meta, err := result.Meta.toProtobuf()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the metadata of transaction %s: %w", sig, err)
+		}
+		buf, err := proto.Marshal(meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the metadata of transaction %s: %w", sig, err)
+		}
+		metas[sig], err = tooling.CompressZstd(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress the metadata of transaction %s: %w", sig, err)
+		}
+	}
+	return metas, nil
+}
+
+func (s *rpcTxMetaSource) Close() error {
+	return nil
+}
+
+// rpcTransactionResult is the result of the getTransaction method; the transaction itself is not needed.
+type rpcTransactionResult struct {
+	Slot uint64              `json:"slot"`
+	Meta *rpcTransactionMeta `json:"meta"`
+}
+
+// rpcTransactionMeta is the metadata of a transaction as returned by the getTransaction method.
+type rpcTransactionMeta struct {
+	Err               any                     `json:"err"`
+	Fee               uint64                  `json:"fee"`
+	PreBalances       []uint64                `json:"preBalances"`
+	PostBalances      []uint64                `json:"postBalances"`
+	InnerInstructions *[]rpcInnerInstructions `json:"innerInstructions"`
+	LogMessages       *[]string               `json:"logMessages"`
+	PreTokenBalances  []rpcTokenBalance       `json:"preTokenBalances"`
+	PostTokenBalances []rpcTokenBalance       `json:"postTokenBalances"`
+	Rewards           []rpcReward             `json:"rewards"`
+	LoadedAddresses   *struct {
+		Writable []solana.PublicKey `json:"writable"`
+		Readonly []solana.PublicKey `json:"readonly"`
+	} `json:"loadedAddresses"`
+	ReturnData *struct {
+		ProgramId solana.PublicKey `json:"programId"`
+		Data      [2]string        `json:"data"` // [data, encoding]
+	} `json:"returnData"`
+	ComputeUnitsConsumed *uint64 `json:"computeUnitsConsumed"`
+}
+
+type rpcInnerInstructions struct {
+	Index        uint32 `json:"index"`
+	Instructions []struct {
+		ProgramIdIndex uint32   `json:"programIdIndex"`
+		Accounts       []uint16 `json:"accounts"`
+		Data           string   `json:"data"` // base58
+		StackHeight    *uint32  `json:"stackHeight"`
+	} `json:"instructions"`
+}
+
+type rpcTokenBalance struct {
+	AccountIndex  uint32 `json:"accountIndex"`
+	Mint          string `json:"mint"`
+	Owner         string `json:"owner"`
+	ProgramId     string `json:"programId"`
+	UiTokenAmount struct {
+		UiAmount       *float64 `json:"uiAmount"`
+		Decimals       uint32   `json:"decimals"`
+		Amount         string   `json:"amount"`
+		UiAmountString string   `json:"uiAmountString"`
+	} `json:"uiTokenAmount"`
+}
+
+type rpcReward struct {
+	Pubkey      string  `json:"pubkey"`
+	Lamports    int64   `json:"lamports"`
+	PostBalance uint64  `json:"postBalance"`
+	RewardType  *string `json:"rewardType"`
+	Commission  *uint8  `json:"commission"`
+}
+
+// toProtobuf converts the metadata to its protobuf form, as stored in the CARs.
+func (m *rpcTransactionMeta) toProtobuf() (*confirmed_block.TransactionStatusMeta, error) {
+	out := &confirmed_block.TransactionStatusMeta{
+		Fee:                   m.Fee,
+		PreBalances:           m.PreBalances,
+		PostBalances:          m.PostBalances,
+		InnerInstructionsNone: m.InnerInstructions == nil,
+		LogMessagesNone:       m.LogMessages == nil,
+		ReturnDataNone:        m.ReturnData == nil,
+		ComputeUnitsConsumed:  m.ComputeUnitsConsumed,
+	}
+	switch err := m.Err.(type) {
+	case nil:
+	case string:
+		// the errors without fields are strings (e.g. "AccountInUse").
+		buf, convErr := solanaerrors.FromJSONToProtobuf(map[string]any{err: nil})
+		if convErr != nil {
+			return nil, fmt.Errorf("failed to convert error %q: %w", err, convErr)
+		}
+		out.Err = &confirmed_block.TransactionError{Err: buf}
+	case map[string]any:
+		buf, convErr := solanaerrors.FromJSONToProtobuf(err)
+		if convErr != nil {
+			return nil, fmt.Errorf("failed to convert error %v: %w", err, convErr)
+		}
+		out.Err = &confirmed_block.TransactionError{Err: buf}
+	default:
+		return nil, fmt.Errorf("unexpected error type %T", m.Err)
+	}
+	if m.InnerInstructions != nil {
+		for _, inner := range *m.InnerInstructions {
+			converted := &confirmed_block.InnerInstructions{Index: inner.Index}
+			for _, ix := range inner.Instructions {
+				data, err := base58.Decode(ix.Data)
+				if err != nil {
+					return nil, fmt.Errorf("invalid inner instruction data: %w", err)
+				}
+				accounts := make([]byte, len(ix.Accounts))
+				for i, account := range ix.Accounts {
+					if account > 255 {
+						return nil, fmt.Errorf("invalid inner instruction account index %d", account)
+					}
+					accounts[i] = byte(account)
+				}
+				converted.Instructions = append(converted.Instructions, &confirmed_block.InnerInstruction{
+					ProgramIdIndex: ix.ProgramIdIndex,
+					Accounts:       accounts,
+					Data:           data,
+					StackHeight:    ix.StackHeight,
+				})
+			}
+			out.InnerInstructions = append(out.InnerInstructions, converted)
+		}
+	}
+	if m.LogMessages != nil {
+		out.LogMessages = *m.LogMessages
+	}
+	out.PreTokenBalances = convertRPCTokenBalances(m.PreTokenBalances)
+	out.PostTokenBalances = convertRPCTokenBalances(m.PostTokenBalances)
+	for _, reward := range m.Rewards {
+		converted := &confirmed_block.Reward{
+			Pubkey:      reward.Pubkey,
+			Lamports:    reward.Lamports,
+			PostBalance: reward.PostBalance,
+		}
+		if reward.RewardType != nil {
+			rewardType, ok := confirmed_block.RewardType_value[*reward.RewardType]
+			if !ok {
+				return nil, fmt.Errorf("unknown reward type %q", *reward.RewardType)
+			}
+			converted.RewardType = confirmed_block.RewardType(rewardType)
+		}
+		if reward.Commission != nil {
+			converted.Commission = strconv.Itoa(int(*reward.Commission))
+		}
+		out.Rewards = append(out.Rewards, converted)
+	}
+	if m.LoadedAddresses != nil {
+		for _, pk := range m.LoadedAddresses.Writable {
+			out.LoadedWritableAddresses = append(out.LoadedWritableAddresses, pk.Bytes())
+		}
+		for _, pk := range m.LoadedAddresses.Readonly {
+			out.LoadedReadonlyAddresses = append(out.LoadedReadonlyAddresses, pk.Bytes())
+		}
+	}
+	if m.ReturnData != nil {
+		if m.ReturnData.Data[1] != "base64" {
+			return nil, fmt.Errorf("unexpected return data encoding %q", m.ReturnData.Data[1])
+		}
+		data, err := base64.StdEncoding.DecodeString(m.ReturnData.Data[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid return data: %w", err)
+		}
+		out.ReturnData = &confirmed_block.ReturnData{
+			ProgramId: m.ReturnData.ProgramId.Bytes(),
+			Data:      data,
+		}
+	}
+	return out, nil
+}
+
+func convertRPCTokenBalances(balances []rpcTokenBalance) []*confirmed_block.TokenBalance {
+	if len(balances) == 0 {
+		return nil
+	}
+	out := make([]*confirmed_block.TokenBalance, len(balances))
+	for i, balance := range balances {
+		out[i] = &confirmed_block.TokenBalance{
+			AccountIndex: balance.AccountIndex,
+			Mint:         balance.Mint,
+			Owner:        balance.Owner,
+			ProgramId:    balance.ProgramId,
+			UiTokenAmount: &confirmed_block.UiTokenAmount{
+				Decimals:       balance.UiTokenAmount.Decimals,
+				Amount:         balance.UiTokenAmount.Amount,
+				UiAmountString: balance.UiTokenAmount.UiAmountString,
+			},
+		}
+		if balance.UiTokenAmount.UiAmount != nil {
+			out[i].UiTokenAmount.UiAmount = *balance.UiTokenAmount.UiAmount
+		}
+	}
+	return out
+}
+
+// bigtableTxMetaSource fetches the metadata from the blocks of a Solana warehouse Bigtable instance.
+type bigtableTxMetaSource struct {
+	source *bigtablesource.Source
+}
+
+func newBigtableTxMetaSource(config bigtablesource.Config) (*bigtableTxMetaSource, error) {
+	// only the transactions of the blocks are needed.
+	config.SynthesizeEntries = true
+	source, err := bigtablesource.New(config)
+	if err != nil {
+		return nil, err
+	}
+	return &bigtableTxMetaSource{source: source}, nil
+}
+
+func (s *bigtableTxMetaSource) FetchMetas(ctx context.Context, slot uint64, sigs []solana.Signature) (map[solana.Signature][]byte, error) {
+	wanted := make(map[solana.Signature]bool, len(sigs))
+	for _, sig := range sigs {
+		wanted[sig] = true
+	}
+	metas := make(map[solana.Signature][]byte, len(sigs))
+	err := s.source.Blocks(ctx, slot, slot, func(block *carwriter.Block) error {
+		for _, entry := range block.Entries {
+			for _, tx := range entry.Transactions {
+				if len(tx.Metadata) == 0 {
+					continue
+				}
+				decoded, err := solana.TransactionFromDecoder(bin.NewBinDecoder(tx.Data))
+				if err != nil {
+					return fmt.Errorf("failed to decode a transaction of block %d: %w", block.Slot, err)
+				}
+				if len(decoded.Signatures) == 0 {
+					return errors.New("transaction has no signatures")
+				}
+				if sig := decoded.Signatures[0]; wanted[sig] {
+					metas[sig] = tx.Metadata
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return metas, nil
+}
+
+func (s *bigtableTxMetaSource) Close() error {
+	return s.source.Close()
+}
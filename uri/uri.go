@@ -0,0 +1,123 @@
+// Package uri expands templated URIs (or paths) that contain epoch placeholders,
+// e.g. "https://files.old-faithful.net/{{.epoch}}/epoch-{{.epoch}}.car",
+// so that a tool can be pointed at a range of epochs instead of a list of explicit paths.
+package uri
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Template is a URI (or local path) with text/template placeholders.
+// The available placeholders are:
+//   - {{.epoch}}: the epoch number.
+type Template string
+
+// IsTemplate returns true if the URI contains placeholders.
+func (t Template) IsTemplate() bool {
+	return strings.Contains(string(t), "{{")
+}
+
+func (t Template) parse() (*template.Template, error) {
+	tmpl, err := template.New("uri").Option("missingkey=error").Parse(string(t))
+	if err != nil {
+		return nil, fmt.Errorf("invalid URI template %q: %w", string(t), err)
+	}
+	return tmpl, nil
+}
+
+// Validate checks that the template can be parsed and expanded.
+func (t Template) Validate() error {
+	_, err := t.ForEpoch(0)
+	return err
+}
+
+// ForEpoch expands the template for the given epoch.
+func (t Template) ForEpoch(epoch uint64) (string, error) {
+	tmpl, err := t.parse()
+	if err != nil {
+		return "", err
+	}
+	return execute(tmpl, epoch)
+}
+
+// ForEpochs expands the template for each of the given epochs, in the same order.
+func (t Template) ForEpochs(epochs []uint64) ([]string, error) {
+	tmpl, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(epochs))
+	for _, epoch := range epochs {
+		expanded, err := execute(tmpl, epoch)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, expanded)
+	}
+	return out, nil
+}
+
+func execute(tmpl *template.Template, epoch uint64) (string, error) {
+	var buf strings.Builder
+	err := tmpl.Execute(&buf, map[string]any{
+		"epoch": epoch,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to expand URI template for epoch %d: %w", epoch, err)
+	}
+	return buf.String(), nil
+}
+
+// ParseEpochs parses a list of epochs and epoch ranges, e.g. "0-10,12,15-16";
+// ranges are inclusive. The result is sorted and without duplicates.
+func ParseEpochs(s string) ([]uint64, error) {
+	seen := make(map[uint64]struct{})
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := strconv.ParseUint(strings.TrimSpace(from), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid epoch %q: %w", part, err)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.ParseUint(strings.TrimSpace(to), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid epoch range %q: %w", part, err)
+			}
+			if end < start {
+				return nil, fmt.Errorf("invalid epoch range %q: end is before start", part)
+			}
+		}
+		for epoch := start; epoch <= end; epoch++ {
+			seen[epoch] = struct{}{}
+		}
+	}
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("no epochs in %q", s)
+	}
+	epochs := make([]uint64, 0, len(seen))
+	for epoch := range seen {
+		epochs = append(epochs, epoch)
+	}
+	sort.Slice(epochs, func(i, j int) bool {
+		return epochs[i] < epochs[j]
+	})
+	return epochs, nil
+}
+
+// Expand expands the template for the epochs described by epochsSpec (see ParseEpochs).
+func Expand(t Template, epochsSpec string) ([]string, error) {
+	epochs, err := ParseEpochs(epochsSpec)
+	if err != nil {
+		return nil, err
+	}
+	return t.ForEpochs(epochs)
+}
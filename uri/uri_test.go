@@ -0,0 +1,39 @@
+package uri
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate(t *testing.T) {
+	tmpl := Template("https://host/{{.epoch}}/epoch-{{.epoch}}.car")
+	require.True(t, tmpl.IsTemplate())
+	require.False(t, Template("/data/epoch-1.car").IsTemplate())
+
+	got, err := tmpl.ForEpoch(42)
+	require.NoError(t, err)
+	require.Equal(t, "https://host/42/epoch-42.car", got)
+
+	all, err := Expand(tmpl, "1-2,5")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"https://host/1/epoch-1.car",
+		"https://host/2/epoch-2.car",
+		"https://host/5/epoch-5.car",
+	}, all)
+
+	require.Error(t, Template("https://host/{{.slot}}.car").Validate())
+	require.Error(t, Template("https://host/{{.epoch").Validate())
+}
+
+func TestParseEpochs(t *testing.T) {
+	got, err := ParseEpochs(" 3, 0-2 ,2")
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 1, 2, 3}, got)
+
+	for _, bad := range []string{"", "a", "5-3", "1-", ","} {
+		_, err := ParseEpochs(bad)
+		require.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,636 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gorilla/websocket"
+	old_faithful_grpc "github.com/rpcpool/yellowstone-faithful/old-faithful-proto/old-faithful-grpc"
+	rpcerrors "github.com/rpcpool/yellowstone-faithful/rpc-errors"
+	solanatxmetaparsers "github.com/rpcpool/yellowstone-faithful/solana-tx-meta-parsers"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"google.golang.org/grpc"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxWebsocketSubscriptions is the maximum number of active subscriptions per websocket connection.
+	maxWebsocketSubscriptions = 16
+	// maxWebsocketMessageSize is the maximum size of a message sent by the client.
+	maxWebsocketMessageSize = 64 * 1024
+	websocketWriteTimeout   = 30 * time.Second
+)
+
+// isWebsocketUpgrade returns whether the client asks to upgrade the connection to a websocket.
+func isWebsocketUpgrade(reqCtx *fasthttp.RequestCtx) bool {
+	return bytes.EqualFold(reqCtx.Request.Header.Peek(fasthttp.HeaderUpgrade), []byte("websocket"))
+}
+
+// websocketHandler upgrades the connection to a websocket that serves replay subscriptions
+// (blockSubscribe and transactionSubscribe over a slot range), in the shape of the Solana pubsub API.
+// If cors is set, only the origins it allows can connect from a browser; otherwise, only the same origin can.
+func (multi *MultiEpoch) websocketHandler(reqCtx *fasthttp.RequestCtx, cors *CORSConfig) {
+	httpReq := new(http.Request)
+	if err := fasthttpadaptor.ConvertRequest(reqCtx, httpReq, true); err != nil {
+		reqCtx.Error("invalid request", fasthttp.StatusBadRequest)
+		return
+	}
+	upgrader := &websocket.Upgrader{}
+	if cors != nil {
+		upgrader.CheckOrigin = func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || cors.isOriginAllowed(origin)
+		}
+	}
+	// The handshake is checked before hijacking the connection,
+	// so that an invalid one gets a regular HTTP error.
+	if !reqCtx.IsGet() || !websocket.IsWebSocketUpgrade(httpReq) || httpReq.Header.Get("Sec-Websocket-Version") != "13" || httpReq.Header.Get("Sec-Websocket-Key") == "" {
+		reqCtx.Error("invalid websocket handshake", fasthttp.StatusBadRequest)
+		return
+	}
+	if upgrader.CheckOrigin != nil && !upgrader.CheckOrigin(httpReq) {
+		reqCtx.Error("origin not allowed", fasthttp.StatusForbidden)
+		return
+	}
+	reqCtx.HijackSetNoResponse(true)
+	reqCtx.Hijack(func(c net.Conn) {
+		// fasthttp runs the hijack handler in its own goroutine.
+		defer func() {
+			if r := recover(); r != nil {
+				recoveredPanic("websocket", "session", r)
+			}
+		}()
+		conn, err := upgrader.Upgrade(&hijackedResponseWriter{conn: c, header: make(http.Header)}, httpReq, nil)
+		if err != nil {
+			klog.V(2).Infof("websocket upgrade failed: %s", err)
+			return
+		}
+		defer conn.Close()
+		ctx, done := multi.drain.stream()
+		defer done()
+		newWebsocketSession(ctx, multi, conn).serve()
+	})
+}
+
+// hijackedResponseWriter hands the connection hijacked from fasthttp to the websocket upgrader.
+type hijackedResponseWriter struct {
+	conn   net.Conn
+	header http.Header
+}
+
+func (w *hijackedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *hijackedResponseWriter) Write(b []byte) (int, error) {
+	return w.conn.Write(b)
+}
+
+func (w *hijackedResponseWriter) WriteHeader(statusCode int) {
+	fmt.Fprintf(w.conn, "HTTP/1.1 %d %s\r\nConnection: close\r\n\r\n", statusCode, http.StatusText(statusCode))
+}
+
+func (w *hijackedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.conn, bufio.NewReadWriter(bufio.NewReader(w.conn), bufio.NewWriter(w.conn)), nil
+}
+
+// websocketSession is a websocket connection and its replay subscriptions.
+type websocketSession struct {
+	multi   *MultiEpoch
+	conn    *websocket.Conn
+	ctx     context.Context
+	cancel  context.CancelFunc
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	nextID uint64
+	subs   map[uint64]context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newWebsocketSession returns the session of the connection; its subscriptions are canceled with ctx.
+func newWebsocketSession(ctx context.Context, multi *MultiEpoch, conn *websocket.Conn) *websocketSession {
+	ctx, cancel := context.WithCancel(ctx)
+	return &websocketSession{
+		multi:  multi,
+		conn:   conn,
+		ctx:    ctx,
+		cancel: cancel,
+		subs:   make(map[uint64]context.CancelFunc),
+	}
+}
+
+// serve reads the requests of the client until the connection is closed; the subscriptions are then cancelled.
+func (s *websocketSession) serve() {
+	defer s.wg.Wait()
+	defer s.cancel()
+	go s.closeWhenDrained()
+	s.conn.SetReadLimit(maxWebsocketMessageSize)
+	for {
+		_, msg, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req jsonrpc2.Request
+		if err := fasterJson.Unmarshal(msg, &req); err != nil {
+			if err := s.writeJSON(&jsonrpc2.Response{
+				Error: &jsonrpc2.Error{
+					Code:    jsonrpc2.CodeParseError,
+					Message: "Parse error",
+				},
+			}); err != nil {
+				return
+			}
+			continue
+		}
+		result, respErr, start := s.handle(&req)
+		resp := &jsonrpc2.Response{ID: req.ID, Error: respErr}
+		if respErr == nil {
+			resultRaw, err := json.Marshal(result)
+			if err != nil {
+				return
+			}
+			raw := json.RawMessage(resultRaw)
+			resp.Result = &raw
+		}
+		if err := s.writeJSON(resp); err != nil {
+			return
+		}
+		if start != nil {
+			// The replay starts only once the client got the subscription ID.
+			start()
+		}
+	}
+}
+
+// handle returns the result of the request or its error; if the request is a subscription,
+// start starts the replay.
+func (s *websocketSession) handle(req *jsonrpc2.Request) (result any, respErr *jsonrpc2.Error, start func()) {
+	if req.Params == nil {
+		req.Params = new(json.RawMessage)
+		*req.Params = json.RawMessage("[]")
+	}
+	switch req.Method {
+	case "blockSubscribe":
+		params, options, err := parseBlockReplaySubscribe(req.Params)
+		if err != nil {
+			return nil, rpcerrors.NewInvalidParams(err.Error()), nil
+		}
+		return s.subscribe(func(ctx context.Context, id uint64) error {
+			return s.replayBlocks(ctx, id, params, options)
+		})
+	case "transactionSubscribe":
+		params, encoding, err := parseTransactionReplaySubscribe(req.Params)
+		if err != nil {
+			return nil, rpcerrors.NewInvalidParams(err.Error()), nil
+		}
+		return s.subscribe(func(ctx context.Context, id uint64) error {
+			return s.replayTransactions(ctx, id, params, encoding)
+		})
+	case "blockUnsubscribe", "transactionUnsubscribe":
+		var ids []uint64
+		if err := json.Unmarshal(*req.Params, &ids); err != nil || len(ids) != 1 {
+			return nil, rpcerrors.NewInvalidParams("params must be [subscription id]"), nil
+		}
+		return s.unsubscribe(ids[0]), nil, nil
+	default:
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeMethodNotFound,
+			Message: "Method not found",
+		}, nil
+	}
+}
+
+// closeWhenDrained closes the connection once the server is draining (or the subscriptions were
+// stopped by the shutdown) and the subscriptions are complete, which ends serve.
+func (s *websocketSession) closeWhenDrained() {
+	select {
+	case <-s.multi.drain.draining():
+	case <-s.ctx.Done():
+		if !errors.Is(context.Cause(s.ctx), errShuttingDown) {
+			// the connection is closed by serve.
+			return
+		}
+	}
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for s.numSubscriptions() > 0 {
+		<-ticker.C
+	}
+	s.writeMu.Lock()
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, errShuttingDown.Error()), time.Now().Add(websocketWriteTimeout))
+	s.writeMu.Unlock()
+	s.conn.Close()
+}
+
+func (s *websocketSession) numSubscriptions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+func (s *websocketSession) subscribe(replay func(ctx context.Context, id uint64) error) (any, *jsonrpc2.Error, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.multi.drain.isDraining() {
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidRequest,
+			Message: "The server is shutting down",
+		}, nil
+	}
+	if len(s.subs) >= maxWebsocketSubscriptions {
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidRequest,
+			Message: fmt.Sprintf("Too many subscriptions; max %d", maxWebsocketSubscriptions),
+		}, nil
+	}
+	s.nextID++
+	id := s.nextID
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.subs[id] = cancel
+	return id, nil, func() {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.unsubscribe(id)
+			err := func() (err error) {
+				defer func() {
+					if r := recover(); r != nil {
+						recoveredPanic("websocket", "replay", r)
+						err = errors.New(errInternal)
+					}
+				}()
+				return replay(ctx, id)
+			}()
+			if ctx.Err() != nil {
+				if !errors.Is(context.Cause(ctx), errShuttingDown) {
+					// unsubscribed, or the connection was closed.
+					return
+				}
+				err = errShuttingDown
+			}
+			complete := map[string]any{"err": nil}
+			if err != nil {
+				klog.V(2).Infof("websocket replay subscription %d failed: %s", id, err)
+				complete["err"] = err.Error()
+			}
+			s.notify("replayComplete", id, complete)
+		}()
+	}
+}
+
+func (s *websocketSession) unsubscribe(id uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cancel, ok := s.subs[id]
+	if !ok {
+		return false
+	}
+	cancel()
+	delete(s.subs, id)
+	return true
+}
+
+func (s *websocketSession) writeJSON(v any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	if err := s.conn.WriteJSON(v); err != nil {
+		// the client is gone or too slow: stop everything.
+		s.cancel()
+		return err
+	}
+	return nil
+}
+
+type websocketNotification struct {
+	JSONRPC string                      `json:"jsonrpc"`
+	Method  string                      `json:"method"`
+	Params  websocketNotificationParams `json:"params"`
+}
+
+type websocketNotificationParams struct {
+	Result       any    `json:"result"`
+	Subscription uint64 `json:"subscription"`
+}
+
+func (s *websocketSession) notify(method string, id uint64, result any) error {
+	return s.writeJSON(&websocketNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params: websocketNotificationParams{
+			Result:       result,
+			Subscription: id,
+		},
+	})
+}
+
+// replayBlocks sends a blockNotification for each block of the range, with the getBlock result of the block.
+func (s *websocketSession) replayBlocks(ctx context.Context, id uint64, params *old_faithful_grpc.StreamBlocksRequest, options map[string]any) error {
+	return s.multi.StreamBlocks(params, &funcBlocksServer{ctx: ctx, send: func(block *old_faithful_grpc.BlockResponse) error {
+		if block.Progress != nil {
+			return nil
+		}
+		rendered, err := s.multi.renderBlock(ctx, block.Slot, options)
+		if err != nil {
+			return fmt.Errorf("slot %d: %w", block.Slot, err)
+		}
+		return s.notify("blockNotification", id, map[string]any{
+			"context": map[string]any{"slot": block.Slot},
+			"value": map[string]any{
+				"slot":  block.Slot,
+				"block": rendered,
+				"err":   nil,
+			},
+		})
+	}})
+}
+
+// replayTransactions sends a transactionNotification for each transaction of the range that matches the filter.
+func (s *websocketSession) replayTransactions(ctx context.Context, id uint64, params *old_faithful_grpc.StreamTransactionsRequest, encoding solana.EncodingType) error {
+	return s.multi.StreamTransactions(params, &funcTransactionsServer{ctx: ctx, send: func(tx *old_faithful_grpc.TransactionResponse) error {
+		if tx.Progress != nil {
+			return nil
+		}
+		rendered, err := renderReplayTransaction(ctx, tx, encoding)
+		if err != nil {
+			return fmt.Errorf("slot %d: %w", tx.Slot, err)
+		}
+		return s.notify("transactionNotification", id, map[string]any{
+			"context": map[string]any{"slot": tx.Slot},
+			"value":   rendered,
+		})
+	}})
+}
+
+// renderBlock returns the getBlock result of the slot, called with the given options.
+func (multi *MultiEpoch) renderBlock(ctx context.Context, slot uint64, options map[string]any) (json.RawMessage, error) {
+	params := []any{slot}
+	if len(options) > 0 {
+		params = append(params, options)
+	}
+	paramsRaw, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	rawMessage := json.RawMessage(paramsRaw)
+	var scratch fasthttp.RequestCtx
+	errorResp, err := multi.handleGetBlock(ctx, &requestContext{ctx: &scratch, rest: true}, &jsonrpc2.Request{Params: &rawMessage})
+	if errorResp != nil {
+		if err == nil {
+			err = errors.New(errorResp.Message)
+		}
+		return nil, err
+	}
+	return json.RawMessage(bytes.Clone(scratch.Response.Body())), nil
+}
+
+// renderReplayTransaction returns the transaction in the shape of the getTransaction result,
+// together with its signature, slot and position in the block.
+func renderReplayTransaction(ctx context.Context, tx *old_faithful_grpc.TransactionResponse, encoding solana.EncodingType) (map[string]any, error) {
+	txn, err := solana.TransactionFromDecoder(bin.NewBinDecoder(tx.Transaction.GetTransaction()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	meta, err := solanatxmetaparsers.ParseAnyTransactionStatusMeta(tx.Transaction.GetMeta())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse transaction meta: %w", err)
+	}
+	var response GetTransactionResponse
+	response.Slot = ptrToUint64(tx.Slot)
+	response.Blocktime = &tx.BlockTime
+	if txn.Message.IsVersioned() {
+		response.Version = txn.Message.GetVersion() - 1
+	} else {
+		response.Version = "legacy"
+	}
+	response.Transaction, response.Meta, err = encodeTransactionResponseBasedOnWantedEncoding(ctx, encoding, *txn, meta, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transaction: %w", err)
+	}
+	result, err := toCamelCaseResult(response, adaptTransactionMetaToExpectedOutput)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]any{
+		"signature":   txn.Signatures[0].String(),
+		"slot":        tx.Slot,
+		"transaction": result,
+	}
+	if tx.Index != nil {
+		out["index"] = *tx.Index
+	}
+	return out, nil
+}
+
+// funcBlocksServer is an OldFaithful_StreamBlocksServer that calls send for each message.
+// StreamBlocks only uses Context and Send; the other grpc.ServerStream methods must not be called.
+type funcBlocksServer struct {
+	grpc.ServerStream
+	ctx  context.Context
+	send func(*old_faithful_grpc.BlockResponse) error
+}
+
+func (s *funcBlocksServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *funcBlocksServer) Send(block *old_faithful_grpc.BlockResponse) error {
+	return s.send(block)
+}
+
+// funcTransactionsServer is an OldFaithful_StreamTransactionsServer that calls send for each message.
+// StreamTransactions only uses Context and Send; the other grpc.ServerStream methods must not be called.
+type funcTransactionsServer struct {
+	grpc.ServerStream
+	ctx  context.Context
+	send func(*old_faithful_grpc.TransactionResponse) error
+}
+
+func (s *funcTransactionsServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *funcTransactionsServer) Send(tx *old_faithful_grpc.TransactionResponse) error {
+	return s.send(tx)
+}
+
+// replaySlotRange is the slot range of a replay subscription; end defaults to the same range as the gRPC streams.
+type replaySlotRange struct {
+	Start *uint64 `json:"start"`
+	End   *uint64 `json:"end"`
+}
+
+func (r *replaySlotRange) validate() error {
+	if r == nil || r.Start == nil {
+		return fmt.Errorf("slotRange.start is required")
+	}
+	if r.End != nil && *r.End < *r.Start {
+		return fmt.Errorf("slotRange.end must be >= slotRange.start")
+	}
+	return nil
+}
+
+// parseBlockReplaySubscribe parses the params of blockSubscribe:
+//
+//	["all" | {"mentionsAccountOrProgram": <pubkey>}, {"slotRange": {"start": <slot>, "end": <slot>}, <getBlock options>...}]
+//
+// The getBlock options are encoding, transactionDetails, showRewards and maxSupportedTransactionVersion.
+func parseBlockReplaySubscribe(raw *json.RawMessage) (*old_faithful_grpc.StreamBlocksRequest, map[string]any, error) {
+	var params []json.RawMessage
+	if err := json.Unmarshal(*raw, &params); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal params: %w", err)
+	}
+	if len(params) != 2 {
+		return nil, nil, fmt.Errorf("params must be [filter, config]")
+	}
+	out := &old_faithful_grpc.StreamBlocksRequest{}
+	var filterName string
+	if err := json.Unmarshal(params[0], &filterName); err == nil {
+		if filterName != "all" {
+			return nil, nil, fmt.Errorf("unknown filter %q", filterName)
+		}
+	} else {
+		var filter struct {
+			MentionsAccountOrProgram string `json:"mentionsAccountOrProgram"`
+		}
+		if err := json.Unmarshal(params[0], &filter); err != nil {
+			return nil, nil, fmt.Errorf("filter must be \"all\" or {\"mentionsAccountOrProgram\": <pubkey>}")
+		}
+		if _, err := solana.PublicKeyFromBase58(filter.MentionsAccountOrProgram); err != nil {
+			return nil, nil, fmt.Errorf("invalid mentionsAccountOrProgram: %w", err)
+		}
+		out.Filter = &old_faithful_grpc.StreamBlocksFilter{AccountInclude: []string{filter.MentionsAccountOrProgram}}
+	}
+	var config struct {
+		SlotRange                      *replaySlotRange `json:"slotRange"`
+		Encoding                       *string          `json:"encoding"`
+		TransactionDetails             *string          `json:"transactionDetails"`
+		ShowRewards                    *bool            `json:"showRewards"`
+		MaxSupportedTransactionVersion *uint64          `json:"maxSupportedTransactionVersion"`
+	}
+	if err := json.Unmarshal(params[1], &config); err != nil {
+		return nil, nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := config.SlotRange.validate(); err != nil {
+		return nil, nil, err
+	}
+	out.StartSlot = *config.SlotRange.Start
+	out.EndSlot = config.SlotRange.End
+	options := make(map[string]any)
+	if config.Encoding != nil {
+		options["encoding"] = *config.Encoding
+	}
+	if config.TransactionDetails != nil {
+		options["transactionDetails"] = *config.TransactionDetails
+	}
+	if config.ShowRewards != nil {
+		options["rewards"] = *config.ShowRewards
+	}
+	if config.MaxSupportedTransactionVersion != nil {
+		options["maxSupportedTransactionVersion"] = *config.MaxSupportedTransactionVersion
+	}
+	// check the options now, rather than at the first block:
+	optionsRaw, err := json.Marshal([]any{out.StartSlot, options})
+	if err != nil {
+		return nil, nil, err
+	}
+	rawMessage := json.RawMessage(optionsRaw)
+	getBlockRequest, err := parseGetBlockRequest(&rawMessage)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := getBlockRequest.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return out, options, nil
+}
+
+// parseTransactionReplaySubscribe parses the params of transactionSubscribe:
+//
+//	[{"vote": <bool>, "failed": <bool>, "accounts": {"include": [...], "exclude": [...], "required": [...]}},
+//	 {"slotRange": {"start": <slot>, "end": <slot>}, "encoding": <encoding>}]
+//
+// Vote and failed transactions are included unless vote/failed are false.
+func parseTransactionReplaySubscribe(raw *json.RawMessage) (*old_faithful_grpc.StreamTransactionsRequest, solana.EncodingType, error) {
+	var params []json.RawMessage
+	if err := json.Unmarshal(*raw, &params); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal params: %w", err)
+	}
+	if len(params) != 2 {
+		return nil, "", fmt.Errorf("params must be [filter, config]")
+	}
+	var filter struct {
+		Vote     *bool `json:"vote"`
+		Failed   *bool `json:"failed"`
+		Accounts struct {
+			Include  []string `json:"include"`
+			Exclude  []string `json:"exclude"`
+			Required []string `json:"required"`
+		} `json:"accounts"`
+	}
+	if err := json.Unmarshal(params[0], &filter); err != nil {
+		return nil, "", fmt.Errorf("invalid filter: %w", err)
+	}
+	for _, accounts := range [][]string{filter.Accounts.Include, filter.Accounts.Exclude, filter.Accounts.Required} {
+		for _, account := range accounts {
+			if _, err := solana.PublicKeyFromBase58(account); err != nil {
+				return nil, "", fmt.Errorf("invalid account %q: %w", account, err)
+			}
+		}
+	}
+	vote, failed := true, true
+	if filter.Vote != nil {
+		vote = *filter.Vote
+	}
+	if filter.Failed != nil {
+		failed = *filter.Failed
+	}
+	var config struct {
+		SlotRange *replaySlotRange `json:"slotRange"`
+		Encoding  *string          `json:"encoding"`
+	}
+	if err := json.Unmarshal(params[1], &config); err != nil {
+		return nil, "", fmt.Errorf("invalid config: %w", err)
+	}
+	if err := config.SlotRange.validate(); err != nil {
+		return nil, "", err
+	}
+	encoding := defaultEncoding()
+	if config.Encoding != nil {
+		encoding = solana.EncodingType(*config.Encoding)
+		if !isAnyEncodingOf(
+			encoding,
+			solana.EncodingBase58,
+			solana.EncodingBase64,
+			solana.EncodingBase64Zstd,
+			solana.EncodingJSON,
+			solana.EncodingJSONParsed,
+		) {
+			return nil, "", fmt.Errorf("unsupported encoding")
+		}
+	}
+	return &old_faithful_grpc.StreamTransactionsRequest{
+		StartSlot: *config.SlotRange.Start,
+		EndSlot:   config.SlotRange.End,
+		Filter: &old_faithful_grpc.StreamTransactionsFilter{
+			Vote:            &vote,
+			Failed:          &failed,
+			AccountInclude:  filter.Accounts.Include,
+			AccountExclude:  filter.Accounts.Exclude,
+			AccountRequired: filter.Accounts.Required,
+		},
+	}, encoding, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+type testWebsocketMessage struct {
+	ID     *uint64          `json:"id"`
+	Result json.RawMessage  `json:"result"`
+	Error  *json.RawMessage `json:"error"`
+	Method string           `json:"method"`
+	Params struct {
+		Subscription uint64          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+func TestWebsocketReplay(t *testing.T) {
+	config, _, slots, _ := buildTestEpoch(t)
+	epoch := openTestEpoch(t, config)
+	multi := NewMultiEpoch(&Options{})
+	require.NoError(t, multi.AddEpoch(0, epoch))
+
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	server := &fasthttp.Server{Handler: newMultiEpochHandler(multi, nil)}
+	go server.Serve(ln)
+	defer server.Shutdown()
+
+	dialer := websocket.Dialer{
+		NetDial: func(network, addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+	}
+	conn, _, err := dialer.Dial("ws://faithful/", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Minute))
+
+	call := func(id uint64, method string, params string) testWebsocketMessage {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":`+strconv.FormatUint(id, 10)+`,"method":"`+method+`","params":`+params+`}`)))
+		var msg testWebsocketMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+		require.NotNil(t, msg.ID)
+		require.Equal(t, id, *msg.ID)
+		return msg
+	}
+	// readUntilComplete returns the notifications of the subscription, until its replayComplete.
+	readUntilComplete := func(subscription uint64) []testWebsocketMessage {
+		var notifications []testWebsocketMessage
+		for {
+			var msg testWebsocketMessage
+			require.NoError(t, conn.ReadJSON(&msg))
+			require.Equal(t, subscription, msg.Params.Subscription)
+			if msg.Method == "replayComplete" {
+				require.JSONEq(t, `{"err":null}`, string(msg.Params.Result))
+				return notifications
+			}
+			notifications = append(notifications, msg)
+		}
+	}
+
+	t.Run("blockSubscribe", func(t *testing.T) {
+		resp := call(1, "blockSubscribe", `["all",{"slotRange":{"start":0,"end":9},"encoding":"base64","showRewards":false}]`)
+		require.Nil(t, resp.Error)
+		var subscription uint64
+		require.NoError(t, json.Unmarshal(resp.Result, &subscription))
+
+		notifications := readUntilComplete(subscription)
+		require.Len(t, notifications, len(slots))
+		for i, notification := range notifications {
+			require.Equal(t, "blockNotification", notification.Method)
+			var result struct {
+				Context struct {
+					Slot uint64 `json:"slot"`
+				} `json:"context"`
+				Value struct {
+					Slot  uint64         `json:"slot"`
+					Block map[string]any `json:"block"`
+					Err   any            `json:"err"`
+				} `json:"value"`
+			}
+			require.NoError(t, json.Unmarshal(notification.Params.Result, &result))
+			require.Equal(t, slots[i], result.Context.Slot)
+			require.Equal(t, slots[i], result.Value.Slot)
+			require.Nil(t, result.Value.Err)
+			require.Contains(t, result.Value.Block, "blockhash")
+		}
+	})
+	t.Run("transactionSubscribe", func(t *testing.T) {
+		resp := call(2, "transactionSubscribe", `[{},{"slotRange":{"start":1,"end":1}}]`)
+		require.Nil(t, resp.Error)
+		var subscription uint64
+		require.NoError(t, json.Unmarshal(resp.Result, &subscription))
+		notifications := readUntilComplete(subscription)
+		require.NotEmpty(t, notifications)
+		for _, notification := range notifications {
+			require.Equal(t, "transactionNotification", notification.Method)
+			var result struct {
+				Value struct {
+					Signature   string         `json:"signature"`
+					Slot        uint64         `json:"slot"`
+					Transaction map[string]any `json:"transaction"`
+				} `json:"value"`
+			}
+			require.NoError(t, json.Unmarshal(notification.Params.Result, &result))
+			require.NotEmpty(t, result.Value.Signature)
+			require.Equal(t, uint64(1), result.Value.Slot)
+			require.Contains(t, result.Value.Transaction, "meta")
+		}
+
+		// The fixture only has vote transactions.
+		resp = call(3, "transactionSubscribe", `[{"vote":false},{"slotRange":{"start":1,"end":1}}]`)
+		require.Nil(t, resp.Error)
+		require.NoError(t, json.Unmarshal(resp.Result, &subscription))
+		require.Empty(t, readUntilComplete(subscription))
+	})
+	t.Run("errors", func(t *testing.T) {
+		require.NotNil(t, call(4, "blockSubscribe", `["all",{}]`).Error)
+		require.NotNil(t, call(5, "blockSubscribe", `["all",{"slotRange":{"start":5,"end":4}}]`).Error)
+		require.NotNil(t, call(6, "blockSubscribe", `[{"mentionsAccountOrProgram":"bogus"},{"slotRange":{"start":0}}]`).Error)
+		require.NotNil(t, call(7, "transactionSubscribe", `[{},{"slotRange":{"start":0},"encoding":"bogus"}]`).Error)
+		require.NotNil(t, call(8, "slotSubscribe", `[]`).Error)
+		resp := call(9, "blockUnsubscribe", `[12345]`)
+		require.Nil(t, resp.Error)
+		require.JSONEq(t, `false`, string(resp.Result))
+	})
+}